@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/insecurecleartextkeyset"
+	"github.com/google/tink/go/keyset"
+	tinkpb "github.com/google/tink/go/proto/tink_go_proto"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+)
+
+// tinkTemplates are the key templates the tink provider accepts
+var tinkTemplates = map[string]func() *tinkpb.KeyTemplate{
+	"AES128_GCM":             aead.AES128GCMKeyTemplate,
+	"AES256_GCM":             aead.AES256GCMKeyTemplate,
+	"AES128_CTR_HMAC_SHA256": aead.AES128CTRHMACSHA256KeyTemplate,
+	"AES256_CTR_HMAC_SHA256": aead.AES256CTRHMACSHA256KeyTemplate,
+}
+
+// generateAES generates the 256-bit key of an aes provider
+func generateAES(args []string) error {
+	fs := flag.NewFlagSet("aes", flag.ContinueOnError)
+	out := fs.String("out", "", "write the key to this file instead of printing it")
+	force := fs.Bool("force", false, "overwrite an existing --out file")
+	asYAML := fs.Bool("yaml", false, "print a provider snippet for encryption.providers")
+	alias := fs.String("alias", "aes-key", "provider alias of the --yaml snippet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	keyBase64 := base64.StdEncoding.EncodeToString(key)
+
+	if *out != "" {
+		if err := writeKeyFile(*out, []byte(keyBase64+"\n"), 0o600, *force); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote AES-256 key to %s\n", *out)
+		if *asYAML {
+			return printSnippet(*alias, "aes", map[string]string{"aes_key": "${AES_ENCRYPTION_KEY}"},
+				fmt.Sprintf("export AES_ENCRYPTION_KEY=\"$(cat %s)\"", *out))
+		}
+		return nil
+	}
+	if *asYAML {
+		return printSnippet(*alias, "aes", map[string]string{"aes_key": keyBase64})
+	}
+
+	fmt.Printf("Generated AES-256 key (base64 encoded):\n%s\n", keyBase64)
+	fmt.Printf("\nYou can use this key in your configuration:\n")
+	fmt.Printf("aes_key: \"%s\"\n", keyBase64)
+	fmt.Printf("\nOr set it as an environment variable:\n")
+	fmt.Printf("export AES_ENCRYPTION_KEY=\"%s\"\n", keyBase64)
+	return nil
+}
+
+// generateRSA generates the key pair of an rsa provider
+func generateRSA(args []string) error {
+	fs := flag.NewFlagSet("rsa", flag.ContinueOnError)
+	bits := fs.Int("bits", 4096, "key size: 2048, 3072 or 4096")
+	format := fs.String("format", "pkcs8", "PEM format: pkcs8 (PRIVATE KEY/PUBLIC KEY) or pkcs1 (RSA PRIVATE KEY/RSA PUBLIC KEY)")
+	outDir := fs.String("out-dir", "", "write private-key.pem and public-key.pem to this directory instead of printing them")
+	force := fs.Bool("force", false, "overwrite existing files in --out-dir")
+	asYAML := fs.Bool("yaml", false, "print a provider snippet for encryption.providers")
+	alias := fs.String("alias", "rsa-key", "provider alias of the --yaml snippet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *bits {
+	case 2048, 3072, 4096:
+	default:
+		return fmt.Errorf("--bits must be 2048, 3072 or 4096, got %d", *bits)
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, *bits)
+	if err != nil {
+		return err
+	}
+	var privateBlock, publicBlock *pem.Block
+	switch *format {
+	case "pkcs8":
+		privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return err
+		}
+		publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return err
+		}
+		privateBlock = &pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}
+		publicBlock = &pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}
+	case "pkcs1":
+		privateBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+		publicBlock = &pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)}
+	default:
+		return fmt.Errorf("--format must be pkcs8 or pkcs1, got %q", *format)
+	}
+	privatePEM, publicPEM := pem.EncodeToMemory(privateBlock), pem.EncodeToMemory(publicBlock)
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o700); err != nil {
+			return err
+		}
+		privatePath, publicPath := filepath.Join(*outDir, "private-key.pem"), filepath.Join(*outDir, "public-key.pem")
+		if err := writeKeyFile(privatePath, privatePEM, 0o600, *force); err != nil {
+			return err
+		}
+		if err := writeKeyFile(publicPath, publicPEM, 0o644, *force); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote RSA-%d key pair to %s and %s\n", *bits, privatePath, publicPath)
+		if *asYAML {
+			return printSnippet(*alias, "rsa", map[string]string{
+				"public_key_pem":  string(publicPEM),
+				"private_key_pem": "${RSA_PRIVATE_KEY}",
+			}, fmt.Sprintf("export RSA_PRIVATE_KEY=\"$(cat %s)\"", privatePath))
+		}
+		return nil
+	}
+	if *asYAML {
+		return printSnippet(*alias, "rsa", map[string]string{
+			"public_key_pem":  string(publicPEM),
+			"private_key_pem": string(privatePEM),
+		})
+	}
+
+	fmt.Print(string(privatePEM))
+	fmt.Print(string(publicPEM))
+	return nil
+}
+
+// generateTink generates a Tink AEAD keyset in cleartext JSON. The tink
+// provider wraps DEKs with a KMS key (kek_uri), so the keyset is meant for
+// Tink tooling and local tests; keep it out of version control.
+func generateTink(args []string) error {
+	fs := flag.NewFlagSet("tink", flag.ContinueOnError)
+	template := fs.String("template", "AES256_GCM", "key template: AES128_GCM, AES256_GCM, AES128_CTR_HMAC_SHA256 or AES256_CTR_HMAC_SHA256")
+	out := fs.String("out", "", "write the keyset to this file instead of printing it")
+	force := fs.Bool("force", false, "overwrite an existing --out file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	newTemplate, ok := tinkTemplates[*template]
+	if !ok {
+		return fmt.Errorf("unsupported --template %q", *template)
+	}
+
+	handle, err := keyset.NewHandle(newTemplate())
+	if err != nil {
+		return fmt.Errorf("failed to create keyset: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := insecurecleartextkeyset.Write(handle, keyset.NewJSONWriter(&buf)); err != nil {
+		return fmt.Errorf("failed to encode keyset: %w", err)
+	}
+	buf.WriteByte('\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := writeKeyFile(*out, buf.Bytes(), 0o600, *force); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s keyset to %s\n", *template, *out)
+	return nil
+}
+
+// generateHMAC generates a random HMAC key within the sizes the integrity
+// verification accepts
+func generateHMAC(args []string) error {
+	fs := flag.NewFlagSet("hmac", flag.ContinueOnError)
+	size := fs.Int("size", 32, fmt.Sprintf("key size in bytes (%d-%d)", validation.MinHMACKeySize, validation.MaxHMACKeySize))
+	encoding := fs.String("encoding", "base64", "output encoding: base64 or hex")
+	out := fs.String("out", "", "write the key to this file instead of printing it")
+	force := fs.Bool("force", false, "overwrite an existing --out file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size < validation.MinHMACKeySize || *size > validation.MaxHMACKeySize {
+		return fmt.Errorf("--size must be between %d and %d bytes, got %d", validation.MinHMACKeySize, validation.MaxHMACKeySize, *size)
+	}
+
+	key := make([]byte, *size)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	var encoded string
+	switch *encoding {
+	case "base64":
+		encoded = base64.StdEncoding.EncodeToString(key)
+	case "hex":
+		encoded = hex.EncodeToString(key)
+	default:
+		return fmt.Errorf("--encoding must be base64 or hex, got %q", *encoding)
+	}
+
+	if *out == "" {
+		fmt.Println(encoded)
+		return nil
+	}
+	if err := writeKeyFile(*out, []byte(encoded+"\n"), 0o600, *force); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d-byte HMAC key to %s\n", *size, *out)
+	return nil
+}
+
+// writeKeyFile writes data to path with perm. An existing file is only
+// replaced with force, so a key in use is not lost by accident.
+func writeKeyFile(path string, data []byte, perm os.FileMode, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, perm) // #nosec G304 - path is chosen by the operator
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+	// OpenFile does not change the mode of an existing file
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// snippet is the encryption.providers entry printed by --yaml
+type snippet struct {
+	Alias  string            `yaml:"alias"`
+	Type   string            `yaml:"type"`
+	Config map[string]string `yaml:"config"`
+}
+
+// providerSnippet renders a provider as a list item, ready to paste below
+// encryption.providers
+func providerSnippet(alias, providerType string, settings map[string]string) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode([]snippet{{Alias: alias, Type: providerType, Config: settings}}); err != nil {
+		return "", fmt.Errorf("failed to encode provider snippet: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode provider snippet: %w", err)
+	}
+	return "# Add to encryption.providers:\n" + buf.String(), nil
+}
+
+// printSnippet prints a provider entry for encryption.providers, followed by
+// the commands setting the environment variables it references
+func printSnippet(alias, providerType string, settings map[string]string, env ...string) error {
+	snippet, err := providerSnippet(alias, providerType, settings)
+	if err != nil {
+		return err
+	}
+	fmt.Print(snippet)
+	if len(env) > 0 {
+		fmt.Printf("\n# Set the referenced variables before starting the proxy:\n# %s\n", strings.Join(env, "\n# "))
+	}
+	return nil
+}
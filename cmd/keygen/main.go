@@ -1,26 +1,67 @@
+// Command keygen generates key material for the proxy:
+//
+//	keygen [aes]  [--yaml [--alias a]] [--out aes.key]
+//	keygen rsa    [--bits 4096] [--format pkcs8|pkcs1] [--yaml [--alias a]] [--out-dir keys/]
+//	keygen tink   [--template AES256_GCM] [--out keyset.json]
+//	keygen hmac   [--size 32] [--encoding base64|hex] [--out hmac.key]
+//	keygen recover-object ...
+//
+// Without --out the key is printed. With --out the key is written to a file
+// only its owner may read, and --yaml prints a provider snippet referencing
+// it through an environment variable instead of embedding it.
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"os"
 )
 
 func main() {
-	// Generate a new AES-256 key (32 bytes)
-	key := make([]byte, 32)
-	if _, err := rand.Read(key); err != nil {
+	command, args := "aes", os.Args[1:]
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "help") {
+		usage()
+		return
+	}
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "aes":
+		err = generateAES(args)
+	case "rsa":
+		err = generateRSA(args)
+	case "tink":
+		err = generateTink(args)
+	case "hmac":
+		err = generateHMAC(args)
+	case "recover-object":
+		if err := recoverObject(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error recovering object: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
 		os.Exit(1)
 	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: keygen <command> [flags]
 
-	// Encode to base64
-	keyBase64 := base64.StdEncoding.EncodeToString(key)
+Commands:
+  aes             AES-256 key of an aes provider (default)
+  rsa             RSA key pair of an rsa provider
+  tink            Tink AEAD keyset in cleartext JSON
+  hmac            random HMAC key
+  recover-object  decrypt an object with an offline recovery identity
 
-	fmt.Printf("Generated AES-256 key (base64 encoded):\n%s\n", keyBase64)
-	fmt.Printf("\nYou can use this key in your configuration:\n")
-	fmt.Printf("aes_key: \"%s\"\n", keyBase64)
-	fmt.Printf("\nOr set it as an environment variable:\n")
-	fmt.Printf("export AES_ENCRYPTION_KEY=\"%s\"\n", keyBase64)
+Run "keygen <command> --help" for the flags of a command.
+`)
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
+)
+
+// recoverObject decrypts an object read directly from the backend with an
+// offline recovery identity:
+//
+//	aws s3api head-object --bucket b --key k > head.json
+//	aws s3api get-object --bucket b --key k object.enc
+//	keygen recover-object --identity recovery.key --metadata head.json --key k --in object.enc --out object
+func recoverObject(args []string) error {
+	fs := flag.NewFlagSet("recover-object", flag.ContinueOnError)
+	identityFile := fs.String("identity", "", "age identity file of a recovery recipient (required)")
+	metadataFile := fs.String("metadata", "", "object metadata as JSON: head-object output or a plain map (required)")
+	objectKey := fs.String("key", "", "object key the data was stored under (required)")
+	metadataPrefix := fs.String("metadata-prefix", "s3ep-", "encryption.metadata_key_prefix of the proxy")
+	in := fs.String("in", "-", "encrypted object data, - for stdin")
+	out := fs.String("out", "-", "decrypted output, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *identityFile == "" || *metadataFile == "" || *objectKey == "" {
+		fs.Usage()
+		return fmt.Errorf("--identity, --metadata and --key are required")
+	}
+
+	identityData, err := os.ReadFile(*identityFile)
+	if err != nil {
+		return err
+	}
+	identities, err := keyencryption.ParseAgeIdentities(identityData)
+	if err != nil {
+		return err
+	}
+	metadataData, err := os.ReadFile(*metadataFile)
+	if err != nil {
+		return err
+	}
+	metadata, err := orchestration.ParseObjectMetadata(metadataData)
+	if err != nil {
+		return err
+	}
+
+	src := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+	plaintext, err := orchestration.RecoverObject(context.Background(), src, metadata, *objectKey, *metadataPrefix, identities)
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		_, err = io.Copy(os.Stdout, plaintext)
+		return err
+	}
+
+	// Written to a temporary file first, so a failed integrity check leaves
+	// no partial plaintext behind under the output name
+	f, err := os.CreateTemp(filepath.Dir(*out), ".recover-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, plaintext); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), *out)
+}
@@ -26,6 +26,15 @@ type LicenseClaims struct {
 }
 
 func main() {
+	// license-tool revocation-list revoked.json > revocations.jwt
+	if len(os.Args) == 3 && os.Args[1] == "revocation-list" {
+		if err := generateRevocationList(os.Args[2]); err != nil {
+			fmt.Printf("❌ Error generating revocation list: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("🔑 S3 Encryption Proxy - License Generator")
 	fmt.Println("==========================================")
 	fmt.Println()
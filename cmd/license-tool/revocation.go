@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+)
+
+// generateRevocationList signs the revoked licenses listed in entriesPath and
+// prints the revocation list to publish at license.revocation.source. The
+// entries file is a JSON array of {"jti", "revoked_at", "reason"} objects and
+// must hold every revoked license: each list replaces the previous one.
+func generateRevocationList(entriesPath string) error {
+	data, err := os.ReadFile(entriesPath) // #nosec G304 - path is given by the operator
+	if err != nil {
+		return err
+	}
+	var revoked []license.RevokedLicense
+	if err := json.Unmarshal(data, &revoked); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", entriesPath, err)
+	}
+	now := time.Now()
+	for i, entry := range revoked {
+		if entry.ID == "" {
+			return fmt.Errorf("entry %d has no jti", i)
+		}
+		if entry.RevokedAt.IsZero() {
+			revoked[i].RevokedAt = now.UTC().Truncate(time.Second)
+		}
+	}
+
+	privateKeyPath, _, err := findRSAKeys()
+	if err != nil {
+		return err
+	}
+	privateKey, err := loadPrivateKey(privateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	list := license.RevocationList{
+		Revoked: revoked,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   "s3ep.com",
+			Subject:  license.RevocationSubject,
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, list).SignedString(privateKey)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Signed revocation list with %d revoked licenses\n", len(revoked))
+	fmt.Println(token)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+)
+
+var attestationPublicKeyFile string
+
+var verifyAttestationCmd = &cobra.Command{
+	Use:   "verify-attestation <manifest.json>",
+	Short: "Verify the signature of an exported attestation manifest",
+	Long: `Verifies a manifest exported from /admin/attestations against the public key
+served at /admin/attestations/public-key and prints a summary of its contents.
+Neither the proxy configuration nor the S3 backend is needed.
+
+Exits with a non-zero status if the signature does not match.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runVerifyAttestation,
+}
+
+func init() {
+	verifyAttestationCmd.Flags().StringVar(&attestationPublicKeyFile, "public-key", "", "PEM encoded Ed25519 public key of the proxy")
+	_ = verifyAttestationCmd.MarkFlagRequired("public-key")
+	rootCmd.AddCommand(verifyAttestationCmd)
+}
+
+func runVerifyAttestation(_ *cobra.Command, args []string) {
+	keyData, err := os.ReadFile(attestationPublicKeyFile) // #nosec G304 - path given by the operator
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to read public key")
+	}
+	publicKey, err := attestation.ParsePublicKey(keyData)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load public key")
+	}
+
+	data, err := os.ReadFile(args[0]) // #nosec G304 - path given by the operator
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to read manifest")
+	}
+	var signed attestation.SignedManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		logrus.WithError(err).Fatal("Failed to decode manifest")
+	}
+
+	manifest, err := attestation.Verify(&signed, publicKey)
+	if err != nil {
+		fmt.Printf("FAIL  %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("OK    %s: signed by key %s\n", args[0], signed.KeyID)
+	fmt.Printf("      bucket=%s prefix=%q objects=%d generated=%s\n",
+		manifest.Bucket, manifest.Prefix, manifest.ObjectCount, manifest.GeneratedAt.Format("2006-01-02T15:04:05Z07:00"))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+var (
+	auditPublicKeyFile string
+	auditRequireSealed bool
+)
+
+var verifyAuditLogCmd = &cobra.Command{
+	Use:   "verify-audit-log <directory>",
+	Short: "Verify the hash chain and seal signatures of an audit log",
+	Long: `Verifies the audit log segments in a directory: every record hash, the chain
+of previous hashes across all segments, and the Merkle root and Ed25519
+signature of every segment seal. Neither the proxy configuration nor the S3
+backend is needed.
+
+The public key is the PKIX PEM encoding of the audit signing key, for example
+from "openssl pkey -in audit-signing-key.pem -pubout".
+
+The last segment may still be open while the proxy is running; its records are
+chain-checked but not yet signed. Use --require-sealed to treat that as a
+failure, e.g. for logs copied from a stopped proxy.
+
+Exits with a non-zero status if the log does not verify.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runVerifyAuditLog,
+}
+
+func init() {
+	verifyAuditLogCmd.Flags().StringVar(&auditPublicKeyFile, "public-key", "", "PEM encoded Ed25519 public key of the audit signing key")
+	verifyAuditLogCmd.Flags().BoolVar(&auditRequireSealed, "require-sealed", false, "fail if the last segment is not sealed")
+	_ = verifyAuditLogCmd.MarkFlagRequired("public-key")
+	rootCmd.AddCommand(verifyAuditLogCmd)
+}
+
+func runVerifyAuditLog(_ *cobra.Command, args []string) {
+	keyData, err := os.ReadFile(auditPublicKeyFile) // #nosec G304 - path given by the operator
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to read public key")
+	}
+	publicKey, err := attestation.ParsePublicKey(keyData)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load public key")
+	}
+
+	report, err := audit.Verify(args[0], publicKey)
+	if err != nil {
+		fmt.Printf("FAIL  %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("OK    %s: %d records in %d segments, seals signed by key %s\n", args[0], report.Records, report.Segments, report.KeyID)
+	fmt.Printf("      records %d to %d\n", report.FirstSeq, report.LastSeq)
+	if !report.FromGenesis {
+		fmt.Printf("      the log does not start at record 1: older segments were removed\n")
+	}
+	if report.OpenRecords > 0 || report.Sealed < report.Segments {
+		fmt.Printf("      last segment is not sealed yet (%d records not covered by a signature)\n", report.OpenRecords)
+		if auditRequireSealed {
+			os.Exit(1)
+		}
+	}
+}
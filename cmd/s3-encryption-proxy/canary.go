@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/alerting"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/canary"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// startCanary runs the synthetic canary against the proxy's own endpoint
+// until ctx is cancelled
+func startCanary(ctx context.Context, cfg *config.Config) {
+	if !cfg.Canary.Enabled {
+		return
+	}
+
+	client, ok := cfg.CanaryCredentials()
+	if !ok {
+		logrus.Error("Canary has no S3 client credentials to sign with, not starting it")
+		return
+	}
+
+	endpoint := cfg.Canary.Endpoint
+	if endpoint == "" {
+		endpoint = canary.DefaultEndpoint(cfg.BindAddress, cfg.TLS.Enabled)
+	}
+	region := cfg.S3Backend.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var alerter canary.Alerter
+	if cfg.Canary.AlertWebhookURL != "" {
+		alerter = alerting.NewWebhook[canary.Alert](cfg.Canary.AlertWebhookURL)
+	}
+
+	c := canary.New(canary.Config{
+		Endpoint:           endpoint,
+		Region:             region,
+		Bucket:             cfg.Canary.Bucket,
+		KeyPrefix:          cfg.Canary.KeyPrefix,
+		AccessKeyID:        client.AccessKeyID,
+		SecretKey:          client.SecretKey,
+		Interval:           time.Duration(cfg.Canary.Interval) * time.Second,
+		Timeout:            time.Duration(cfg.Canary.Timeout) * time.Second,
+		ObjectSize:         cfg.Canary.ObjectSize,
+		FailureThreshold:   cfg.Canary.FailureThreshold,
+		InsecureSkipVerify: cfg.Canary.InsecureSkipVerify,
+	}, alerter, logrus.WithField("component", "canary"))
+
+	logrus.WithFields(logrus.Fields{
+		"endpoint":   endpoint,
+		"bucket":     cfg.Canary.Bucket,
+		"access_key": client.AccessKeyID,
+		"interval":   cfg.Canary.Interval,
+	}).Info("Synthetic canary enabled")
+	go c.Run(ctx)
+}
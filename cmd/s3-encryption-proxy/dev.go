@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// applyDevMode switches the configuration to developer mode before it is
+// loaded: in-memory backend, relaxed client authentication and debug tracing
+func applyDevMode() {
+	if err := config.ApplyDevDefaults(devProvider); err != nil {
+		logrus.WithError(err).Fatal("Failed to enable developer mode")
+	}
+}
+
+// devLogFormatter is a compact, colored formatter for reading request and
+// encryption traces in a terminal
+func devLogFormatter() logrus.Formatter {
+	return &logrus.TextFormatter{
+		ForceColors:     true,
+		FullTimestamp:   true,
+		TimestampFormat: "15:04:05.000",
+		PadLevelText:    true,
+	}
+}
+
+// logDevBanner tells developers how to connect to the proxy in developer mode
+func logDevBanner(cfg *config.Config) {
+	logrus.WithFields(logrus.Fields{
+		"endpoint":          "http://" + cfg.BindAddress,
+		"access_key_id":     config.DevAccessKeyID,
+		"secret_access_key": config.DevSecretKey,
+		"provider":          cfg.Encryption.EncryptionMethodAlias,
+		"region":            cfg.S3Backend.Region,
+	}).Warn("🧪 Developer mode: in-memory backend, any S3 credentials are accepted. Do not use in production")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/reconciler"
+)
+
+var (
+	gcOlderThan      time.Duration
+	gcDryRun         bool
+	gcIncludeOrphans bool
+	gcBuckets        []string
+	gcOutput         string
+	gcTimeout        time.Duration
+)
+
+var gcMultipartCmd = &cobra.Command{
+	Use:   "gc-multipart",
+	Short: "Abort stale incomplete multipart uploads and delete their sessions",
+	Long: `Lists the incomplete multipart uploads of the backend and aborts those
+initiated through the proxy before --older-than, which frees their parts, and
+deletes their encryption sessions. Sessions older than --older-than whose
+upload no longer exists are deleted as well.
+
+Uploads without a session are left alone unless --include-orphans is given:
+they were initiated by other clients of the backend, or their session is lost.
+
+The command reads the session store of the configuration, so it needs a shared
+store (redis or etcd). With the memory store, use the admin endpoint
+POST /admin/multipart-gc of the running proxy instead.
+
+Prints the removed uploads with their reclaimed parts, or a JSON report with
+--output json, and exits with a non-zero status if anything failed.`,
+	Run: runGCMultipart,
+}
+
+func init() {
+	gcMultipartCmd.Flags().DurationVar(&gcOlderThan, "older-than", 24*time.Hour, "only remove uploads and sessions created before this long ago")
+	gcMultipartCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "only report what would be removed")
+	gcMultipartCmd.Flags().BoolVar(&gcIncludeOrphans, "include-orphans", false, "also abort backend uploads without a session")
+	gcMultipartCmd.Flags().StringSliceVar(&gcBuckets, "bucket", nil, "bucket to collect, repeatable (default: session_store.reconciliation.buckets, or every bucket)")
+	gcMultipartCmd.Flags().StringVar(&gcOutput, "output", "text", "report format: text or json")
+	gcMultipartCmd.Flags().DurationVar(&gcTimeout, "timeout", 10*time.Minute, "time limit of the collection")
+	rootCmd.AddCommand(gcMultipartCmd)
+}
+
+func runGCMultipart(_ *cobra.Command, _ []string) {
+	if gcOutput != "text" && gcOutput != "json" {
+		logrus.WithField("output", gcOutput).Fatal("Invalid report format, use 'text' or 'json'")
+	}
+	if gcOlderThan < 0 {
+		logrus.WithField("older_than", gcOlderThan).Fatal("--older-than must not be negative")
+	}
+	logrus.SetLevel(logrus.WarnLevel)
+
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load configuration")
+	}
+	if cfg.SessionStore.Type == "" || cfg.SessionStore.Type == config.SessionStoreMemory {
+		logrus.Fatal("gc-multipart needs a shared session store (redis or etcd); with the memory store, use POST " + reconciler.BasePath + " on the monitoring port of the proxy")
+	}
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create proxy server")
+	}
+	defer func() { _ = server.GetEncryptionManager().Shutdown(context.Background()) }()
+
+	buckets := gcBuckets
+	if len(buckets) == 0 {
+		buckets = cfg.SessionStore.Reconciliation.Buckets
+	}
+	r := reconciler.New(server.GetS3Backend(), server.GetEncryptionManager(), reconciler.Config{Buckets: buckets},
+		logrus.WithField("component", "multipart-gc"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), gcTimeout)
+	defer cancel()
+	report, err := r.CollectGarbage(ctx, reconciler.GCOptions{
+		OlderThan:      gcOlderThan,
+		IncludeOrphans: gcIncludeOrphans,
+		DryRun:         gcDryRun,
+	})
+	if err != nil && report == nil {
+		logrus.WithError(err).Fatal("Multipart garbage collection failed")
+	}
+
+	if printErr := printGCReport(report); printErr != nil {
+		logrus.WithError(printErr).Fatal("Failed to print report")
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Multipart garbage collection was interrupted")
+		os.Exit(1)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printGCReport(report *reconciler.GCReport) error {
+	if gcOutput == "json" {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		return out.Encode(report)
+	}
+	for _, upload := range report.Uploads {
+		line := fmt.Sprintf("%-13s %s/%s upload %s, %d parts, %d bytes", upload.Action, upload.Bucket, upload.Key, upload.UploadID, upload.Parts, upload.Bytes)
+		if upload.Error != "" {
+			line += ": " + upload.Error
+		}
+		if _, err := fmt.Println(line); err != nil {
+			return err
+		}
+	}
+	verb := "Reclaimed"
+	if report.DryRun {
+		verb = "Would reclaim"
+	}
+	_, err := fmt.Printf("%s %d parts (%d bytes) of %d uploads and %d sessions in %d buckets, %d failed\n",
+		verb, report.PartsReclaimed, report.BytesReclaimed, report.UploadsAborted, report.SessionsDiscarded, report.Buckets, report.Failed)
+	return err
+}
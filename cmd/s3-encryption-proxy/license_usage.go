@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/licenseusage"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+)
+
+// startLicenseUsage counts the bytes the encryption manager encrypts and
+// decrypts, saving the counters to the state file until ctx is cancelled.
+// It returns nil if license.usage is disabled.
+func startLicenseUsage(ctx context.Context, cfg *config.Config, proxyServer *proxy.Server) *licenseusage.Meter {
+	u := cfg.License.Usage
+	if !u.Enabled {
+		return nil
+	}
+
+	logger := logrus.WithField("component", "license-usage")
+	meter, err := licenseusage.NewMeter(u.StateFile, u.RetentionMonths, logger)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to restore license usage")
+	}
+	proxyServer.GetEncryptionManager().SetUsageRecorder(meter)
+	if u.StateFile != "" {
+		go meter.Run(ctx, time.Duration(u.SaveInterval)*time.Second)
+	}
+	logger.WithField("state_file", u.StateFile).Info("License usage accounting enabled")
+	return meter
+}
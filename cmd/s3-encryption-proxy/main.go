@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -10,9 +11,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/authz"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/configreload"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/kekrotation"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/licenseusage"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/listexport"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/reconciler"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tuning"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/usage"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +39,8 @@ var (
 	cfgFile           string
 	monitoringEnabled bool
 	monitoringPort    string
+	devMode           bool
+	devProvider       string
 
 	rootCmd = &cobra.Command{
 		Use:   "s3-encryption-proxy",
@@ -48,7 +62,10 @@ DEK Providers (data encryption):
 - None provider (pass-through for testing/development)
 
 All configuration is done through YAML configuration files. Use --config to specify
-a configuration file, or the proxy will look for configuration in standard locations.`,
+a configuration file, or the proxy will look for configuration in standard locations.
+
+Use --dev to try S3 integrations locally: the proxy then stores objects in memory,
+accepts any S3 credentials and traces requests and encryption at debug level.`,
 		Run: runProxy,
 	}
 )
@@ -58,6 +75,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to configuration file (YAML format)")
 	rootCmd.PersistentFlags().BoolVar(&monitoringEnabled, "monitoring", false, "enable Prometheus monitoring endpoint")
 	rootCmd.PersistentFlags().StringVar(&monitoringPort, "monitoring-port", ":9090", "port for Prometheus monitoring endpoint")
+	rootCmd.Flags().BoolVar(&devMode, "dev", false, "developer mode: in-memory backend, relaxed authentication and verbose tracing")
+	rootCmd.Flags().StringVar(&devProvider, "dev-provider", "none", "encryption provider type in developer mode (none or aes)")
 }
 
 func initConfig() {
@@ -72,47 +91,37 @@ func runProxy(_ *cobra.Command, _ []string) {
 		"buildTime": buildTime,
 	}).Info("S3 Encryption Proxy build information")
 
+	if devMode {
+		applyDevMode()
+	}
+
 	// Load configuration and start license monitoring
 	cfg, licenseValidator, err := config.LoadAndStartLicense()
 	if err != nil {
 		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
-
-	// Override monitoring configuration from command line flags
-	if monitoringEnabled {
-		cfg.Monitoring.Enabled = true
-		if monitoringPort != ":9090" {
-			cfg.Monitoring.BindAddress = monitoringPort
-		}
-	}
+	applyFlagOverrides(cfg)
 
 	// Set up Prometheus metrics with build information
 	monitoring.SetServerInfo(version, commit, buildTime)
 
-	// Set license information in metrics if available
+	// Keep the license metrics current across runtime re-validations and reloads
 	if licenseValidator != nil {
-		// Get license validation result to access claims
-		token := os.Getenv("S3EP_LICENSE_TOKEN")
-		if token == "" {
-			// Try to load from file
-			if cfg.LicenseFile != "" {
-				if data, err := os.ReadFile(cfg.LicenseFile); err == nil {
-					token = strings.TrimSpace(string(data))
-				}
-			}
-		}
-
-		if token != "" {
-			if result := licenseValidator.ValidateLicense(token); result.Valid && result.Info != nil {
+		licenseValidator.SetStatusHandler(func(status license.Status) {
+			if info := licenseValidator.GetLicenseInfo(); info != nil && info.Claims != nil {
 				monitoring.SetLicenseInfo(
-					result.Info.Claims.LicenseeName,
-					result.Info.Claims.LicenseeCompany,
-					result.Info.ExpiresAt.Format("2006-01-02 15:04:05 UTC"),
-					true,
-					float64(result.Info.ExpiresAt.Unix()),
+					info.Claims.LicenseeName,
+					info.Claims.LicenseeCompany,
+					info.ExpiresAt.Format("2006-01-02 15:04:05 UTC"),
+					status.Valid,
+					float64(info.ExpiresAt.Unix()),
 				)
 			}
-		}
+			if status.ExpiresAt.IsZero() {
+				return
+			}
+			monitoring.SetLicenseStatus(status.Remaining, status.WarningThreshold, status.InGracePeriod, status.Restricted)
+		})
 	}
 
 	// Set log level
@@ -134,6 +143,12 @@ func runProxy(_ *cobra.Command, _ []string) {
 	default:
 		logrus.WithField("log_format", cfg.LogFormat).Fatal("Invalid log format, use 'text' or 'json'")
 	}
+	if cfg.DevMode {
+		logrus.SetFormatter(devLogFormatter())
+		logDevBanner(cfg)
+	}
+	// Log lines written for a request carry its x-amz-request-id
+	logrus.AddHook(requestid.Hook{})
 
 	// Check for "none" encryption method and warn user
 	if cfg.Encryption.EncryptionMethodAlias != "" {
@@ -148,17 +163,27 @@ func runProxy(_ *cobra.Command, _ []string) {
 		}
 	}
 
+	// Export spans of the request path
+	stopTracing := startTracing(cfg)
+
 	// Create and start the proxy server
 	proxyServer, err := proxy.NewServer(cfg)
 	if err != nil {
 		logrus.WithError(err).Fatal("Failed to create proxy server")
 	}
 
+	// Verify every provider can wrap, unwrap and round-trip data before serving
+	runStartupSelfTest(cfg, proxyServer.GetEncryptionManager())
+
+	// Reject uploads once the license has expired beyond its grace period
+	if licenseValidator != nil {
+		proxyServer.SetLicenseRestriction(licenseValidator.Restricted)
+	}
+
 	// Graceful shutdown state tracking
 	var (
-		activeRequests int64     // Active request counter
-		shutdownMode   int32     // 0 = normal, 1 = shutting down
-		shutdownStart  time.Time // When shutdown started
+		shutdownMode  int32     // 0 = normal, 1 = shutting down
+		shutdownStart time.Time // When shutdown started
 	)
 
 	// Set shutdown state handler for health checks
@@ -166,24 +191,151 @@ func runProxy(_ *cobra.Command, _ []string) {
 		return atomic.LoadInt32(&shutdownMode) == 1, shutdownStart
 	})
 
-	// Set request tracking handlers
-	proxyServer.SetRequestTracker(
-		func() { atomic.AddInt64(&activeRequests, 1) },  // on request start
-		func() { atomic.AddInt64(&activeRequests, -1) }, // on request end
-	)
-
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Fetch remote key material before the health endpoint reports ready
+	if cfg.KeyPreload.Enabled {
+		manager := proxyServer.GetEncryptionManager()
+		proxyServer.SetReadinessHandler(manager.KeysReady)
+		go manager.RunKeyPreload(ctx,
+			time.Duration(cfg.KeyPreload.RefreshInterval)*time.Second,
+			time.Duration(cfg.KeyPreload.Timeout)*time.Second)
+	}
+
+	// Bytes encrypted and decrypted per month, for volume-based licenses
+	usageMeter := startLicenseUsage(ctx, cfg, proxyServer)
+
+	// Backend, provider and license checks of the readiness and startup probes
+	startProbes(ctx, cfg, proxyServer)
+
+	// Client scopes from the authorization policy file, reloaded when it changes
+	if cfg.Authorization.PolicyFile != "" {
+		policy, err := authz.NewFilePolicy(cfg.Authorization.PolicyFile, logrus.WithField("component", "authz"))
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load authorization policy")
+		}
+		proxyServer.SetScopeSource(policy)
+		go policy.Run(ctx, time.Duration(cfg.Authorization.ReloadInterval)*time.Second)
+		logrus.WithField("policy_file", cfg.Authorization.PolicyFile).Info("Authorization policy loaded")
+	}
+
+	// Apply log level, rate limit, bucket policy and provider changes without a restart
+	reloader := startConfigReload(ctx, cfg, proxyServer)
+
 	// Start monitoring server if enabled
 	var monitoringServer *monitoring.Server
+	var listExportMgr *listexport.Manager
+	var kekRotationMgr *kekrotation.Manager
 	if cfg.Monitoring.Enabled {
 		monitoringConfig := &monitoring.Config{
-			BindAddress:  cfg.Monitoring.BindAddress,
-			MetricsPath:  cfg.Monitoring.MetricsPath,
-			PprofEnabled: cfg.Monitoring.PprofEnabled,
+			BindAddress:   cfg.Monitoring.BindAddress,
+			MetricsPath:   cfg.Monitoring.MetricsPath,
+			PprofEnabled:  cfg.Monitoring.PprofEnabled,
+			AdminHandlers: map[string]http.Handler{},
 		}
+
+		if bm := cfg.Monitoring.BucketMetrics; bm.Enabled {
+			limiter := monitoring.EnableBucketMetrics(bm.MaxBuckets, bm.OverflowLabel)
+			interval := time.Duration(bm.RebalanceInterval) * time.Second
+			if interval <= 0 {
+				interval = 10 * time.Minute
+			}
+			go limiter.Run(ctx, interval)
+			logrus.WithFields(logrus.Fields{
+				"max_buckets":        bm.MaxBuckets,
+				"overflow_label":     bm.OverflowLabel,
+				"rebalance_interval": interval,
+			}).Info("Per-bucket metrics enabled")
+		}
+
+		// Admin list export jobs write straight to the backend, bypassing encryption
+		if cfg.Monitoring.ListExport.Enabled {
+			listExportMgr = listexport.NewManager(proxyServer.GetS3Backend(), listexport.Config{
+				DiagnosticsBucket: cfg.Monitoring.ListExport.DiagnosticsBucket,
+				KeyPrefix:         cfg.Monitoring.ListExport.KeyPrefix,
+				PartSize:          cfg.Monitoring.ListExport.PartSize,
+				MaxConcurrentJobs: cfg.Monitoring.ListExport.MaxConcurrentJobs,
+			}, logrus.WithField("component", "admin"))
+			exportHandler := listexport.NewHandler(listExportMgr, logrus.WithField("component", "admin"))
+			monitoringConfig.AdminHandlers[listexport.BasePath] = exportHandler
+			monitoringConfig.AdminHandlers[listexport.BasePath+"/"] = exportHandler
+			logrus.WithField("diagnostics_bucket", cfg.Monitoring.ListExport.DiagnosticsBucket).Info("List export endpoints enabled on monitoring port")
+		}
+
+		// Attestation manifests read objects through the backend and decrypt them for checksums
+		if cfg.Monitoring.Attestation.Enabled {
+			signingKey, err := attestation.LoadSigningKey(cfg.Monitoring.Attestation.SigningKeyFile)
+			if err != nil {
+				logrus.WithError(err).Fatal("Failed to load attestation signing key")
+			}
+			encryptionMgr := proxyServer.GetEncryptionManager()
+			exporter := attestation.NewExporter(proxyServer.GetS3Backend(), encryptionMgr, encryptionMgr.GetMetadataKeyPrefix(),
+				signingKey, cfg.Monitoring.Attestation.MaxObjects, logrus.WithField("component", "admin"))
+			attestationHandler := attestation.NewHandler(exporter, logrus.WithField("component", "admin"))
+			monitoringConfig.AdminHandlers[attestation.BasePath] = attestationHandler
+			monitoringConfig.AdminHandlers[attestation.BasePath+"/"] = attestationHandler
+			logrus.WithField("key_id", attestation.KeyID(exporter.PublicKey())).Info("Attestation export endpoints enabled on monitoring port")
+		}
+
+		// KEK rotation jobs re-wrap DEKs and store them with backend self-copies
+		if cfg.Monitoring.KEKRotation.Enabled {
+			kekRotationMgr = kekrotation.NewManager(proxyServer.GetS3Backend(), proxyServer.GetEncryptionManager(), kekrotation.Config{
+				MaxConcurrentJobs: cfg.Monitoring.KEKRotation.MaxConcurrentJobs,
+				Concurrency:       cfg.Monitoring.KEKRotation.Concurrency,
+			}, logrus.WithField("component", "admin"))
+			rotationHandler := kekrotation.NewHandler(kekRotationMgr, logrus.WithField("component", "admin"))
+			monitoringConfig.AdminHandlers[kekrotation.BasePath] = rotationHandler
+			monitoringConfig.AdminHandlers[kekrotation.BasePath+"/"] = rotationHandler
+			logrus.WithField("active_provider", proxyServer.GetEncryptionManager().GetActiveProviderAlias()).Info("KEK rotation endpoints enabled on monitoring port")
+		}
+
+		// Multipart garbage collection aborts backend uploads and deletes sessions
+		if cfg.Monitoring.MultipartGC.Enabled {
+			gc := reconciler.New(proxyServer.GetS3Backend(), proxyServer.GetEncryptionManager(), reconciler.Config{
+				Buckets: cfg.SessionStore.Reconciliation.Buckets,
+			}, logrus.WithField("component", "admin"))
+			monitoringConfig.AdminHandlers[reconciler.BasePath] = reconciler.NewHandler(gc, logrus.WithField("component", "admin"))
+			logrus.Info("Multipart garbage collection endpoint enabled on monitoring port")
+		}
+
+		// Usage collection lists buckets and reads metadata straight from the
+		// backend; its counts are the baseline of the upload quotas
+		if uc := cfg.Monitoring.Usage; uc.Enabled {
+			collector := usage.NewCollector(proxyServer.GetS3Backend(), proxyServer.GetEncryptionManager().GetMetadataKeyPrefix(), usage.Config{
+				Interval:        time.Duration(uc.Interval) * time.Second,
+				Buckets:         uc.Buckets,
+				MaxBuckets:      uc.MaxBuckets,
+				HeadConcurrency: uc.HeadConcurrency,
+			}, logrus.WithField("component", "admin"))
+			go collector.Run(ctx)
+			proxyServer.SetUsageSource(collector)
+			usageHandler := usage.NewHandler(collector, logrus.WithField("component", "admin"))
+			monitoringConfig.AdminHandlers[usage.BasePath] = usageHandler
+			monitoringConfig.AdminHandlers[usage.BasePath+"/"] = usageHandler
+			logrus.WithFields(logrus.Fields{
+				"interval": uc.Interval,
+				"buckets":  len(uc.Buckets),
+			}).Info("Bucket usage collector enabled")
+		}
+
+		// The reload status only reads state, so it is always available
+		reloadHandler := configreload.NewHandler(reloader, logrus.WithField("component", "admin"))
+		monitoringConfig.AdminHandlers[configreload.BasePath] = reloadHandler
+
+		// The license status is always available; POST reloads a renewed token
+		if licenseValidator != nil {
+			monitoringConfig.AdminHandlers[license.BasePath] = license.NewHandler(licenseValidator, logrus.WithField("component", "admin"))
+		}
+
+		// Monthly encrypted and decrypted bytes for licensees to self-audit
+		if usageMeter != nil {
+			monitoringConfig.AdminHandlers[licenseusage.BasePath] = licenseusage.NewHandler(usageMeter, logrus.WithField("component", "admin"))
+		}
+
+		// Tuning stats only read counters, so they are always available
+		monitoringConfig.AdminHandlers[tuning.BasePath] = tuning.NewHandler(tuning.SettingsFromConfig(cfg), logrus.WithField("component", "admin"))
 		monitoringServer = monitoring.NewServer(monitoringConfig)
 
 		// Start monitoring server in background
@@ -198,78 +350,92 @@ func runProxy(_ *cobra.Command, _ []string) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start server in goroutine
+	// Start server in goroutine; it returns once it drained on shutdown
+	serverDone := make(chan error, 1)
 	go func() {
 		logrus.WithField("address", cfg.BindAddress).Info("Starting S3 encryption proxy server")
-		if err := proxyServer.Start(ctx); err != nil && err != context.Canceled {
-			logrus.WithError(err).Fatal("Proxy server failed")
-		}
+		serverDone <- proxyServer.Start(ctx)
 	}()
 
+	// Probe the full client path through the proxy endpoint
+	startCanary(ctx, cfg)
+
+	// Verify stored objects in the background
+	startScrubber(ctx, cfg, proxyServer)
+	startReconciler(ctx, cfg, proxyServer)
+
 	// Wait for shutdown signal
-	sig := <-sigChan
+	var sig os.Signal
+	select {
+	case sig = <-sigChan:
+	case err := <-serverDone:
+		logrus.WithError(err).Fatal("Proxy server failed")
+	}
 	logrus.WithField("signal", sig.String()).Info("Received shutdown signal, initiating graceful shutdown...")
 
 	// Enter shutdown mode - health endpoint will now return 503
-	atomic.StoreInt32(&shutdownMode, 1)
 	shutdownStart = time.Now()
-
-	// Stop accepting new connections
-	cancel()
-
-	// Wait for active requests to complete with timeout
-	shutdownTimeout := 30 * time.Second
-	if cfg.ShutdownTimeout > 0 {
-		shutdownTimeout = time.Duration(cfg.ShutdownTimeout) * time.Second
-	}
+	atomic.StoreInt32(&shutdownMode, 1)
 
 	logrus.WithFields(logrus.Fields{
-		"timeout":        shutdownTimeout,
-		"activeRequests": atomic.LoadInt64(&activeRequests),
+		"timeout":        proxyServer.ShutdownTimeout(),
+		"activeRequests": proxyServer.ActiveRequests(),
 	}).Info("Waiting for active requests to complete...")
 
-	// Graceful shutdown with active request monitoring
-	shutdownComplete := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				active := atomic.LoadInt64(&activeRequests)
-				if active == 0 {
-					logrus.Info("All requests completed, shutting down immediately")
-					close(shutdownComplete)
-					return
-				}
-				logrus.WithField("activeRequests", active).Debug("Still waiting for requests to complete...")
-			case <-time.After(shutdownTimeout):
-				active := atomic.LoadInt64(&activeRequests)
-				if active > 0 {
-					logrus.WithField("activeRequests", active).Warn("Shutdown timeout reached, forcing shutdown with active requests")
-				}
-				close(shutdownComplete)
-				return
-			}
-		}
-	}()
+	// Stop accepting new connections and drain the active requests. The
+	// server cancels requests still running at the timeout and saves the
+	// unfinished multipart sessions before it returns.
+	cancel()
+	if err := <-serverDone; err != nil {
+		logrus.WithError(err).Warn("Proxy server did not drain all requests")
+	}
 
-	// Wait for graceful shutdown to complete
-	<-shutdownComplete
+	// Cancel running list exports; partial uploads are aborted
+	if listExportMgr != nil {
+		listExportMgr.Shutdown()
+	}
+
+	// Cancel running KEK rotations; they resume from their last_key
+	if kekRotationMgr != nil {
+		kekRotationMgr.Shutdown()
+	}
 
 	// Stop license validator
 	if licenseValidator != nil {
 		licenseValidator.Stop()
 	}
 
+	// Keep the usage since the last save
+	if usageMeter != nil {
+		if err := usageMeter.Save(); err != nil {
+			logrus.WithError(err).Error("Failed to save license usage")
+		}
+	}
+
+	// Flush the spans of the last requests
+	stopTracing()
+
 	duration := time.Since(shutdownStart)
 	logrus.WithFields(logrus.Fields{
 		"duration":       duration,
-		"activeRequests": atomic.LoadInt64(&activeRequests),
+		"activeRequests": proxyServer.ActiveRequests(),
 	}).Info("Graceful shutdown completed")
 }
 
+// applyFlagOverrides sets the command line flags that override the
+// configuration, also on reloaded configurations
+func applyFlagOverrides(cfg *config.Config) {
+	cfg.DevMode = devMode
+
+	// Override monitoring configuration from command line flags
+	if monitoringEnabled {
+		cfg.Monitoring.Enabled = true
+		if monitoringPort != ":9090" {
+			cfg.Monitoring.BindAddress = monitoringPort
+		}
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/probes"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+)
+
+// startProbes runs the backend, provider and license checks reported by
+// /readyz and /startupz until ctx is cancelled
+func startProbes(ctx context.Context, cfg *config.Config, proxyServer *proxy.Server) {
+	p := cfg.Probes
+	prober := probes.NewProber(time.Duration(p.Timeout)*time.Second, logrus.WithField("component", "probes"))
+
+	backend := proxyServer.GetS3Backend()
+	prober.Register("backend", time.Duration(p.BackendInterval)*time.Second, func(ctx context.Context) []preflight.Check {
+		return []preflight.Check{preflight.CheckBackend(ctx, backend, p.Bucket)}
+	})
+	encryptionMgr := proxyServer.GetEncryptionManager()
+	prober.Register("providers", time.Duration(p.ProviderInterval)*time.Second, func(ctx context.Context) []preflight.Check {
+		return preflight.CheckProviders(ctx, encryptionMgr)
+	})
+	// The token is read again, so a renewed license file is picked up
+	validator := license.NewValidatorWithOptions(cfg.GetLicenseOptions())
+	prober.Register("license", time.Duration(p.LicenseInterval)*time.Second, func(context.Context) []preflight.Check {
+		return []preflight.Check{preflight.CheckLicense(validator, license.LoadLicense(cfg.LicenseFile))}
+	})
+
+	proxyServer.SetProber(prober)
+	go prober.Run(ctx)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/reconciler"
+)
+
+// startReconciler runs the multipart reconciliation against the backend
+// until ctx is cancelled
+func startReconciler(ctx context.Context, cfg *config.Config, proxyServer *proxy.Server) {
+	rc := cfg.SessionStore.Reconciliation
+	if !rc.Enabled {
+		return
+	}
+
+	encryptionMgr := proxyServer.GetEncryptionManager()
+	if encryptionMgr.IsNoneProvider() {
+		logrus.Info("Multipart reconciliation disabled: the none provider keeps no multipart sessions")
+		return
+	}
+	if rc.Policy != config.ReconcilePolicyKeep && (cfg.SessionStore.Type == "" || cfg.SessionStore.Type == config.SessionStoreMemory) {
+		logrus.WithField("policy", rc.Policy).Warn("Multipart reconciliation with the memory session store treats uploads of other replicas as orphaned; run a single replica or use a shared session store")
+	}
+
+	r := reconciler.New(proxyServer.GetS3Backend(), encryptionMgr, reconciler.Config{
+		Buckets:  rc.Buckets,
+		Interval: time.Duration(rc.Interval) * time.Second,
+		MinAge:   time.Duration(rc.MinAge) * time.Second,
+		Policy:   rc.Policy,
+	}, logrus.WithField("component", "multipart-reconciler"))
+
+	logrus.WithFields(logrus.Fields{
+		"policy":   rc.Policy,
+		"interval": rc.Interval,
+		"buckets":  len(rc.Buckets),
+	}).Info("Multipart reconciliation enabled")
+	go r.Run(ctx)
+}
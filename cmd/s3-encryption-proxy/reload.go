@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/configreload"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+)
+
+// startConfigReload reloads the configuration on SIGHUP and, with
+// config_reload.watch_interval, when the file changes, until ctx is cancelled
+func startConfigReload(ctx context.Context, cfg *config.Config, proxyServer *proxy.Server) *configreload.Reloader {
+	load := func() (*config.Config, error) {
+		next, err := config.Reload()
+		if err != nil {
+			return nil, err
+		}
+		applyFlagOverrides(next)
+		return next, nil
+	}
+	path := config.FileUsed()
+	reloader := configreload.NewReloader(path, cfg, load, proxyServer.ApplyReload, logrus.WithField("component", "config-reload"))
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sighup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				reloader.Reload(configreload.TriggerSignal)
+			}
+		}
+	}()
+
+	if cfg.ConfigReload.WatchInterval > 0 && path != "" {
+		go reloader.Run(ctx, time.Duration(cfg.ConfigReload.WatchInterval)*time.Second)
+		logrus.WithFields(logrus.Fields{
+			"config_file":    path,
+			"watch_interval": cfg.ConfigReload.WatchInterval,
+		}).Info("Watching configuration file for changes")
+	}
+	return reloader
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/alerting"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/scrubber"
+)
+
+// startScrubber runs the integrity scrubber against the backend until ctx is
+// cancelled
+func startScrubber(ctx context.Context, cfg *config.Config, proxyServer *proxy.Server) {
+	if !cfg.Scrubber.Enabled {
+		return
+	}
+
+	var alerter scrubber.Alerter
+	if cfg.Scrubber.AlertWebhookURL != "" {
+		alerter = alerting.NewWebhook[scrubber.Alert](cfg.Scrubber.AlertWebhookURL)
+	}
+
+	encryptionMgr := proxyServer.GetEncryptionManager()
+	s := scrubber.New(proxyServer.GetS3Backend(), encryptionMgr, encryptionMgr.GetMetadataKeyPrefix(), scrubber.Config{
+		Buckets:        cfg.Scrubber.Buckets,
+		SamplesPerHour: cfg.Scrubber.SamplesPerHour,
+		ListBatchSize:  cfg.Scrubber.ListBatchSize,
+		MaxObjectSize:  cfg.Scrubber.MaxObjectSize,
+		Timeout:        time.Duration(cfg.Scrubber.Timeout) * time.Second,
+		VerifyHMAC:     cfg.Encryption.IntegrityVerification != "" && cfg.Encryption.IntegrityVerification != config.HMACVerificationOff,
+	}, alerter, logrus.WithField("component", "scrubber"))
+
+	logrus.WithFields(logrus.Fields{
+		"samples_per_hour": cfg.Scrubber.SamplesPerHour,
+		"buckets":          len(cfg.Scrubber.Buckets),
+	}).Info("Integrity scrubber enabled")
+	go s.Run(ctx)
+}
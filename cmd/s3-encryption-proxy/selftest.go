@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+var selfTestCmd = &cobra.Command{
+	Use:   "self-test",
+	Short: "Round-trip a probe payload through every configured encryption provider",
+	Long: `Encrypts and decrypts an in-memory probe payload through the envelope pipeline
+of every configured provider (DEK wrap/unwrap, AES-CTR, AES-GCM and HMAC) and
+reports the latency per provider. No S3 backend is contacted.
+
+Exits with a non-zero status if any provider fails.`,
+	Run: runSelfTest,
+}
+
+func init() {
+	rootCmd.AddCommand(selfTestCmd)
+}
+
+func runSelfTest(_ *cobra.Command, _ []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load configuration")
+	}
+
+	manager, err := orchestration.NewManager(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create encryption manager")
+	}
+	defer func() { _ = manager.Shutdown(context.Background()) }()
+
+	results := manager.SelfTest(context.Background())
+	for _, result := range results {
+		active := ""
+		if result.IsActive {
+			active = " (active)"
+		}
+		switch {
+		case result.Skipped:
+			fmt.Printf("SKIP  %s [%s]%s: no key encryption\n", result.Alias, result.Type, active)
+		case result.Err != nil:
+			fmt.Printf("FAIL  %s [%s]%s: %v\n", result.Alias, result.Type, active, result.Err)
+		default:
+			fmt.Printf("OK    %s [%s]%s: %s\n", result.Alias, result.Type, active, result.Duration)
+		}
+	}
+
+	if orchestration.SelfTestFailed(results) {
+		os.Exit(1)
+	}
+}
+
+// runStartupSelfTest runs the provider self-test before the proxy starts
+// serving. In strict mode a failing provider aborts startup.
+func runStartupSelfTest(cfg *config.Config, manager *orchestration.Manager) {
+	if !cfg.SelfTest.Enabled {
+		return
+	}
+
+	results := manager.SelfTest(context.Background())
+	for _, result := range results {
+		log := logrus.WithFields(logrus.Fields{
+			"provider_alias": result.Alias,
+			"provider_type":  result.Type,
+			"fingerprint":    result.Fingerprint,
+			"is_active":      result.IsActive,
+		})
+		switch {
+		case result.Skipped:
+			log.Info("Provider self-test skipped")
+		case result.Err != nil:
+			log.WithError(result.Err).WithField("duration", result.Duration).Error("Provider self-test failed")
+		default:
+			log.WithField("duration", result.Duration).Info("Provider self-test passed")
+		}
+	}
+
+	if orchestration.SelfTestFailed(results) {
+		if cfg.SelfTest.Strict {
+			logrus.Fatal("Provider self-test failed, aborting startup (self_test.strict is enabled)")
+		}
+		logrus.Warn("Provider self-test failed, starting anyway (self_test.strict is disabled)")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
+)
+
+// startTracing exports the spans of the request path when tracing is enabled
+// and returns the function flushing the pending spans on shutdown
+func startTracing(cfg *config.Config) func() {
+	if !cfg.Tracing.Enabled {
+		return func() {}
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to set up tracing")
+	}
+	logrus.WithFields(logrus.Fields{
+		"endpoint":     cfg.Tracing.Endpoint,
+		"service_name": cfg.Tracing.ServiceName,
+		"sample_ratio": cfg.Tracing.SampleRatio,
+	}).Info("OpenTelemetry tracing enabled")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Tracing.Timeout)*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("Failed to flush trace spans")
+		}
+	}
+}
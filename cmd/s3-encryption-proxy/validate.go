@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+)
+
+var (
+	validateBucket  string
+	validateOutput  string
+	validateTimeout time.Duration
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a configuration before it is deployed",
+	Long: `Loads the configuration and checks it the way the proxy would use it:
+
+- the license token
+- every provider, whose DEK wrap and unwrap reaches a KMS for remote providers
+- the connection and credentials of the S3 backend
+- with --bucket, an encrypt/decrypt round trip of a temporary object under
+  ` + preflight.RoundTripKeyPrefix + ` in that bucket, deleted afterwards
+
+Prints one line per check, or a JSON report with --output json, and exits
+with a non-zero status if any check failed.`,
+	Run: runValidate,
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validateBucket, "bucket", "", "bucket for the backend check and the round trip")
+	validateCmd.Flags().StringVar(&validateOutput, "output", "text", "report format: text or json")
+	validateCmd.Flags().DurationVar(&validateTimeout, "timeout", 30*time.Second, "time limit of all checks")
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(_ *cobra.Command, _ []string) {
+	if validateOutput != "text" && validateOutput != "json" {
+		logrus.WithField("output", validateOutput).Fatal("Invalid report format, use 'text' or 'json'")
+	}
+	logrus.SetLevel(logrus.WarnLevel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	report := validate(ctx)
+
+	if err := printValidationReport(report); err != nil {
+		logrus.WithError(err).Fatal("Failed to print report")
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+// validate runs every check that the configuration allows. Checks that
+// depend on a step that failed are left out.
+func validate(ctx context.Context) *preflight.Report {
+	report := &preflight.Report{}
+
+	cfg, err := config.Load()
+	if err != nil {
+		report.Add(preflight.Check{Name: "configuration", Status: preflight.StatusFail, Detail: err.Error()})
+		return report
+	}
+	report.Add(preflight.Check{Name: "configuration", Status: preflight.StatusOK, Detail: fmt.Sprintf("%d providers, active %s", len(cfg.Encryption.Providers), cfg.Encryption.EncryptionMethodAlias)})
+
+	report.Add(preflight.CheckLicense(license.NewValidatorWithOptions(cfg.GetLicenseOptions()), license.LoadLicense(cfg.LicenseFile)))
+
+	// Key lengths and encodings are checked when the providers are created
+	manager, err := orchestration.NewManager(cfg)
+	if err != nil {
+		report.Add(preflight.Check{Name: "providers", Status: preflight.StatusFail, Detail: err.Error()})
+		return report
+	}
+	defer func() { _ = manager.Shutdown(context.Background()) }()
+	for _, check := range preflight.CheckProviders(ctx, manager) {
+		report.Add(check)
+	}
+
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		report.Add(preflight.Check{Name: "backend", Status: preflight.StatusFail, Detail: err.Error()})
+		return report
+	}
+	defer func() { _ = server.GetEncryptionManager().Shutdown(context.Background()) }()
+	backendCheck := preflight.CheckBackend(ctx, server.GetS3Backend(), validateBucket)
+	report.Add(backendCheck)
+	if backendCheck.Status == preflight.StatusFail {
+		return report
+	}
+	report.Add(preflight.CheckRoundTrip(ctx, server.GetS3Backend(), server.GetEncryptionManager(), validateBucket))
+	return report
+}
+
+func printValidationReport(report *preflight.Report) error {
+	if validateOutput == "json" {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		return out.Encode(report)
+	}
+	for _, check := range report.Checks {
+		line := fmt.Sprintf("%-5s %s", strings.ToUpper(string(check.Status)), check.Name)
+		if check.Detail != "" {
+			line += ": " + check.Detail
+		}
+		if check.DurationMS > 0 {
+			line += fmt.Sprintf(" (%dms)", check.DurationMS)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Command s3ep-decrypt decrypts an object read directly from the backend with
+// the providers of the proxy configuration, for disaster recovery while the
+// proxy is unavailable:
+//
+//	aws s3api head-object --bucket b --key k > head.json
+//	aws s3api get-object --bucket b --key k object.enc
+//	s3ep-decrypt --config config.yaml --metadata head.json --key k --in object.enc --out object
+//
+// The configuration needs the providers that wrapped the object's DEK; the
+// S3 backend it names is not contacted. Objects whose DEK can only be
+// recovered with an offline age identity are decrypted with
+// "s3ep-keygen recover-object" instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decrypting object: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("s3ep-decrypt", flag.ContinueOnError)
+	cfgFile := fs.String("config", "", "configuration file of the proxy with the providers (required)")
+	metadataFile := fs.String("metadata", "", "object metadata as JSON: head-object output or a plain map (required)")
+	objectKey := fs.String("key", "", "object key the data was stored under (required)")
+	encryptionContext := fs.String("encryption-context", "", "encryption context the object was written with, as sent in "+orchestration.EncryptionContextHeader)
+	in := fs.String("in", "-", "encrypted object data, - for stdin")
+	out := fs.String("out", "-", "decrypted output, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *cfgFile == "" || *metadataFile == "" || *objectKey == "" {
+		fs.Usage()
+		return fmt.Errorf("--config, --metadata and --key are required")
+	}
+	logrus.SetLevel(logrus.WarnLevel)
+
+	config.InitConfig(*cfgFile)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	encryptionMgr, err := orchestration.NewManager(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = encryptionMgr.Shutdown(context.Background()) }()
+
+	metadataData, err := os.ReadFile(*metadataFile)
+	if err != nil {
+		return err
+	}
+	metadata, err := orchestration.ParseObjectMetadata(metadataData)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *encryptionContext != "" {
+		parsed, err := orchestration.ParseEncryptionContext(*encryptionContext)
+		if err != nil {
+			return err
+		}
+		ctx = orchestration.WithEncryptionContext(ctx, parsed)
+	}
+
+	src := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+	plaintext, err := encryptionMgr.DecryptObject(ctx, src, metadata, *objectKey)
+	if err != nil {
+		return err
+	}
+	defer plaintext.Close()
+
+	if *out == "-" {
+		_, err = io.Copy(os.Stdout, plaintext)
+		return err
+	}
+
+	// Written to a temporary file first, so a failed integrity check leaves
+	// no partial plaintext behind under the output name
+	f, err := os.CreateTemp(filepath.Dir(*out), ".decrypt-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, plaintext); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), *out)
+}
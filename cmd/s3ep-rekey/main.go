@@ -0,0 +1,116 @@
+// Command s3ep-rekey re-encrypts the objects of a bucket with the active
+// provider of the proxy configuration:
+//
+//	s3ep-rekey --config config.yaml --bucket my-bucket [--prefix tenant-a/] [--from none] --checkpoint rekey.json
+//
+// Unlike a KEK rotation, every selected object is decrypted and written
+// again with a new DEK, which also encrypts buckets that were filled without
+// the proxy or with the "none" provider. --from selects the objects: "none"
+// for unencrypted objects, a provider alias for objects encrypted with that
+// provider, or by default every object not encrypted with the active
+// provider. --dry-run only counts them. An interrupted run is resumed by
+// starting it again with the same --checkpoint.
+//
+// --from legacy-prefix rewrites the metadata of the objects stored under one
+// of encryption.legacy_metadata_key_prefixes to the current
+// metadata_key_prefix instead, without re-encrypting them.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/rekey"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error re-encrypting objects: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("s3ep-rekey", flag.ContinueOnError)
+	cfgFile := fs.String("config", "", "configuration file of the proxy (required)")
+	bucket := fs.String("bucket", "", "bucket to re-encrypt (required)")
+	prefix := fs.String("prefix", "", "only re-encrypt keys with this prefix")
+	from := fs.String("from", "", `objects to re-encrypt: "none" for unencrypted objects, a provider alias, or all not encrypted with the active provider; "legacy-prefix" migrates metadata prefixes`)
+	workers := fs.Int("workers", 4, "objects re-encrypted in parallel")
+	dryRun := fs.Bool("dry-run", false, "only count the objects that would be re-encrypted")
+	checkpoint := fs.String("checkpoint", "", "file progress is saved to and resumed from")
+	tempDir := fs.String("temp-dir", "", "directory of the temporary plaintext files")
+	verbose := fs.Bool("verbose", false, "log every re-encrypted object")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *cfgFile == "" || *bucket == "" {
+		fs.Usage()
+		return fmt.Errorf("--config and --bucket are required")
+	}
+	if *verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	} else {
+		logrus.SetLevel(logrus.WarnLevel)
+	}
+
+	config.InitConfig(*cfgFile)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	// Objects are migrated by the run, not in the background of its reads
+	cfg.Encryption.MetadataPrefixMigration = config.MetadataPrefixMigrationOff
+	server, err := proxy.NewServer(cfg)
+	if err != nil {
+		return err
+	}
+	encryptionMgr := server.GetEncryptionManager()
+	defer func() { _ = encryptionMgr.Shutdown(context.Background()) }()
+
+	// Set if encryption.legacy_metadata_key_prefixes are configured
+	migrator, _ := server.GetS3Backend().(rekey.PrefixMigrator)
+
+	rekeyer, err := rekey.NewRekeyer(server.GetS3Backend(), encryptionMgr, rekey.Config{
+		Bucket:             *bucket,
+		Prefix:             *prefix,
+		From:               *from,
+		Workers:            *workers,
+		DryRun:             *dryRun,
+		Checkpoint:         *checkpoint,
+		StreamingThreshold: cfg.GetStreamingThreshold(),
+		TempDir:            *tempDir,
+		PrefixMigrator:     migrator,
+	}, logrus.NewEntry(logrus.StandardLogger()))
+	if err != nil {
+		return err
+	}
+
+	// An interrupted run stops after the objects in flight; the checkpoint
+	// resumes it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, runErr := rekeyer.Run(ctx)
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err := out.Encode(report); err != nil {
+		return err
+	}
+	switch {
+	case runErr != nil:
+		return runErr
+	case report.ObjectsFailed > 0:
+		return fmt.Errorf("%d objects could not be re-encrypted", report.ObjectsFailed)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package accesslog writes one line per S3 request in the Apache common or
+// combined log format or as JSON, separate from the proxy's own log.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format is the layout of an access log line
+type Format string
+
+const (
+	// FormatCommon is the Apache common log format followed by the S3 fields
+	FormatCommon Format = "common"
+	// FormatCombined is the Apache combined log format followed by the S3 fields
+	FormatCombined Format = "combined"
+	// FormatJSON writes every entry as a JSON object
+	FormatJSON Format = "json"
+)
+
+// Outputs that are not file paths
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// clfTime is the timestamp layout of the common log format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// Entry is one request
+type Entry struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	AccessKeyID string    `json:"access_key_id,omitempty"`
+	Method      string    `json:"method"`
+	URI         string    `json:"uri"`
+	Proto       string    `json:"proto"`
+	Operation   string    `json:"operation"`
+	Bucket      string    `json:"bucket,omitempty"`
+	Key         string    `json:"key,omitempty"`
+	Status      int       `json:"status"`
+	BytesIn     int64     `json:"bytes_in"`
+	BytesOut    int64     `json:"bytes_out"`
+	DurationMs  int64     `json:"duration_ms"`
+	Encrypted   bool      `json:"encrypted"`
+	Decrypted   bool      `json:"decrypted"`
+	Referer     string    `json:"referer,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+}
+
+// Logger writes entries to an output. It is safe for concurrent use.
+type Logger struct {
+	format Format
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer // nil for stdout and stderr
+}
+
+// Open creates a logger writing in format to output: "stdout", "stderr" or
+// the path of a file that lines are appended to
+func Open(format Format, output string) (*Logger, error) {
+	switch format {
+	case FormatCommon, FormatCombined, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+
+	switch output {
+	case "", OutputStdout:
+		return New(format, os.Stdout), nil
+	case OutputStderr:
+		return New(format, os.Stderr), nil
+	}
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640) // #nosec G304 - path is configured by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	logger := New(format, file)
+	logger.closer = file
+	return logger, nil
+}
+
+// New creates a logger writing in format to out
+func New(format Format, out io.Writer) *Logger {
+	return &Logger{format: format, out: out}
+}
+
+// Log writes entry as one line
+func (l *Logger) Log(entry Entry) error {
+	line, err := l.line(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.out.Write(line)
+	return err
+}
+
+// Close closes the log file; stdout and stderr stay open
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// line formats entry, including the trailing newline
+func (l *Logger) line(entry Entry) ([]byte, error) {
+	if l.format == FormatJSON {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		return append(line, '\n'), nil
+	}
+
+	// host ident authuser [time] "request" status bytes
+	var b strings.Builder
+	host := entry.RemoteAddr
+	if i := strings.LastIndexByte(host, ':'); i > 0 {
+		host = strings.Trim(host[:i], "[]")
+	}
+	fmt.Fprintf(&b, "%s - %s [%s] %s %d %s",
+		orDash(host), orDash(entry.AccessKeyID), entry.Time.Format(clfTime),
+		quote(entry.Method+" "+entry.URI+" "+entry.Proto), entry.Status, bytesField(entry.BytesOut))
+	if l.format == FormatCombined {
+		fmt.Fprintf(&b, " %s %s", quote(entry.Referer), quote(entry.UserAgent))
+	}
+	// S3 fields: "operation" "bucket" "key" bytes_in duration_ms crypto request_id
+	fmt.Fprintf(&b, " %s %s %s %s %d %s %s\n",
+		quote(entry.Operation), quote(entry.Bucket), quote(entry.Key), bytesField(entry.BytesIn),
+		entry.DurationMs, cryptoField(entry.Encrypted, entry.Decrypted), orDash(entry.RequestID))
+	return []byte(b.String()), nil
+}
+
+// quote returns s as a quoted field, "-" if empty. Quotes, backslashes and
+// control characters are escaped, so a key cannot break the line.
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// bytesField returns n, or "-" for no bytes like the common log format
+func bytesField(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// cryptoField names what the proxy did with the request's data
+func cryptoField(encrypted, decrypted bool) string {
+	switch {
+	case encrypted && decrypted:
+		return "encrypt+decrypt"
+	case encrypted:
+		return "encrypt"
+	case decrypted:
+		return "decrypt"
+	default:
+		return "-"
+	}
+}
@@ -0,0 +1,97 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry() Entry {
+	return Entry{
+		Time:        time.Date(2026, 10, 16, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		RequestID:   "req-1",
+		RemoteAddr:  "192.0.2.10:51234",
+		AccessKeyID: "client-key",
+		Method:      "PUT",
+		URI:         "/bucket/dir/file.txt",
+		Proto:       "HTTP/1.1",
+		Operation:   "PutObject",
+		Bucket:      "bucket",
+		Key:         "dir/file.txt",
+		Status:      200,
+		BytesIn:     2326,
+		DurationMs:  12,
+		Encrypted:   true,
+		UserAgent:   "aws-cli/2.0",
+	}
+}
+
+func TestLogger_Formats(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatCommon, `192.0.2.10 - client-key [16/Oct/2026:13:55:36 -0700] "PUT /bucket/dir/file.txt HTTP/1.1" 200 - "PutObject" "bucket" "dir/file.txt" 2326 12 encrypt req-1` + "\n"},
+		{FormatCombined, `192.0.2.10 - client-key [16/Oct/2026:13:55:36 -0700] "PUT /bucket/dir/file.txt HTTP/1.1" 200 - "-" "aws-cli/2.0" "PutObject" "bucket" "dir/file.txt" 2326 12 encrypt req-1` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, New(tt.format, &out).Log(testEntry()))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, New(FormatJSON, &out).Log(testEntry()))
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.True(t, testEntry().Time.Equal(entry.Time))
+	entry.Time = testEntry().Time
+	assert.Equal(t, testEntry(), entry)
+}
+
+func TestLogger_EscapesFields(t *testing.T) {
+	entry := testEntry()
+	entry.Key = "evil\"key\nwith newline"
+	entry.RemoteAddr = "[2001:db8::1]:443"
+	var out bytes.Buffer
+	require.NoError(t, New(FormatCommon, &out).Log(entry))
+
+	assert.Contains(t, out.String(), `"evil\"key\x0awith newline"`)
+	assert.Contains(t, out.String(), "2001:db8::1 - ")
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")))
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := Open(FormatCommon, path)
+	require.NoError(t, err)
+	require.NoError(t, logger.Log(testEntry()))
+	require.NoError(t, logger.Close())
+
+	logger, err = Open(FormatCommon, path)
+	require.NoError(t, err)
+	require.NoError(t, logger.Log(testEntry()))
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(data, []byte("\n")), "lines are appended")
+
+	_, err = Open("apache", path)
+	assert.Error(t, err)
+
+	logger, err = Open(FormatJSON, OutputStdout)
+	require.NoError(t, err)
+	assert.NoError(t, logger.Close(), "stdout is not closed")
+}
@@ -0,0 +1,54 @@
+// Package alerting delivers the alerts of the background jobs, such as the
+// canary and the scrubber, to webhooks
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// timeout bounds the delivery of a single alert
+const timeout = 10 * time.Second
+
+// Webhook posts alerts of type T as JSON to a URL
+type Webhook[T any] struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook posting to url
+func NewWebhook[T any](url string) *Webhook[T] {
+	return &Webhook[T]{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts alert to the webhook and fails on non-2xx responses
+func (w *Webhook[T]) Send(ctx context.Context, alert T) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
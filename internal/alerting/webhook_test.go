@@ -0,0 +1,39 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAlert struct {
+	State  string `json:"state"`
+	Bucket string `json:"bucket"`
+}
+
+func TestWebhook_Send(t *testing.T) {
+	received := make(chan testAlert, 1)
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert testAlert
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received <- alert
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook[testAlert](server.URL)
+	require.NoError(t, webhook.Send(context.Background(), testAlert{State: "firing", Bucket: "canary"}))
+	alert := <-received
+	assert.Equal(t, "firing", alert.State)
+	assert.Equal(t, "canary", alert.Bucket)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, webhook.Send(context.Background(), testAlert{State: "resolved"}))
+}
@@ -0,0 +1,200 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMaxObjects = 100000
+
+// ErrTooManyObjects is returned when an export would exceed the object limit.
+// Manifests are never truncated, as a partial manifest would be misleading.
+var ErrTooManyObjects = errors.New("too many objects for one attestation manifest")
+
+// Backend is the subset of the S3 client used by the exporter
+type Backend interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Decryptor decrypts object bodies for plaintext checksums
+type Decryptor interface {
+	CreateDecryptionReader(ctx context.Context, reader io.Reader, metadata map[string]string) (io.Reader, error)
+}
+
+// Request selects the objects of a manifest. Objects are included when their
+// LastModified is within [From, To); zero bounds are open.
+type Request struct {
+	Bucket    string    `json:"bucket"`
+	Prefix    string    `json:"prefix,omitempty"`
+	From      time.Time `json:"from,omitzero"`
+	To        time.Time `json:"to,omitzero"`
+	Checksums bool      `json:"checksums,omitempty"` // decrypt every object to record its plaintext SHA-256
+}
+
+// Exporter builds and signs attestation manifests
+type Exporter struct {
+	backend        Backend
+	decryptor      Decryptor
+	metadataPrefix string
+	signingKey     ed25519.PrivateKey
+	maxObjects     int
+	logger         *logrus.Entry
+}
+
+// NewExporter creates a new exporter. maxObjects <= 0 uses the default limit.
+func NewExporter(backend Backend, decryptor Decryptor, metadataPrefix string, signingKey ed25519.PrivateKey, maxObjects int, logger *logrus.Entry) *Exporter {
+	if maxObjects <= 0 {
+		maxObjects = defaultMaxObjects
+	}
+	return &Exporter{
+		backend:        backend,
+		decryptor:      decryptor,
+		metadataPrefix: metadataPrefix,
+		signingKey:     signingKey,
+		maxObjects:     maxObjects,
+		logger:         logger,
+	}
+}
+
+// PublicKey returns the key manifests are verified with
+func (e *Exporter) PublicKey() ed25519.PublicKey {
+	return e.signingKey.Public().(ed25519.PublicKey)
+}
+
+// Export lists the objects selected by req, reads their encryption metadata
+// and returns the signed manifest
+func (e *Exporter) Export(ctx context.Context, req Request) (*SignedManifest, error) {
+	if req.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	manifest := &Manifest{
+		Version:     ManifestVersion,
+		GeneratedAt: time.Now().UTC(),
+		Bucket:      req.Bucket,
+		Prefix:      req.Prefix,
+		From:        req.From,
+		To:          req.To,
+		Objects:     []Entry{},
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(e.backend, &s3.ListObjectsV2Input{
+		Bucket: aws.String(req.Bucket),
+		Prefix: aws.String(req.Prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			modified := aws.ToTime(object.LastModified)
+			if (!req.From.IsZero() && modified.Before(req.From)) || (!req.To.IsZero() && !modified.Before(req.To)) {
+				continue
+			}
+			if len(manifest.Objects) >= e.maxObjects {
+				return nil, fmt.Errorf("%w (limit %d)", ErrTooManyObjects, e.maxObjects)
+			}
+
+			entry, err := e.entry(ctx, req, aws.ToString(object.Key))
+			if err != nil {
+				return nil, err
+			}
+			manifest.Objects = append(manifest.Objects, *entry)
+		}
+	}
+	manifest.ObjectCount = len(manifest.Objects)
+
+	signed, err := Sign(manifest, e.signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"bucket":    req.Bucket,
+		"prefix":    req.Prefix,
+		"objects":   manifest.ObjectCount,
+		"checksums": req.Checksums,
+		"key_id":    signed.KeyID,
+	}).Info("Exported attestation manifest")
+	return signed, nil
+}
+
+// entry reads the current version of key. HEAD is used instead of the listing
+// so the metadata, version and ETag describe the same object version.
+func (e *Exporter) entry(ctx context.Context, req Request, key string) (*Entry, error) {
+	head, err := e.backend.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(req.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	_, encrypted := head.Metadata[e.metadataPrefix+"encrypted-dek"]
+	entry := &Entry{
+		Key:            key,
+		VersionID:      aws.ToString(head.VersionId),
+		ETag:           aws.ToString(head.ETag),
+		Size:           aws.ToInt64(head.ContentLength),
+		LastModified:   aws.ToTime(head.LastModified).UTC(),
+		Encrypted:      encrypted,
+		DEKAlgorithm:   head.Metadata[e.metadataPrefix+"dek-algorithm"],
+		KeyFingerprint: head.Metadata[e.metadataPrefix+"kek-fingerprint"],
+		HMAC:           head.Metadata[e.metadataPrefix+"hmac"],
+	}
+
+	if req.Checksums {
+		sum, err := e.plaintextSHA256(ctx, req.Bucket, key, entry.VersionID, head.Metadata, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", key, err)
+		}
+		entry.PlaintextSHA256 = sum
+	}
+	return entry, nil
+}
+
+// plaintextSHA256 downloads the attested version of key and hashes its plaintext
+func (e *Exporter) plaintextSHA256(ctx context.Context, bucket, key, versionID string, metadata map[string]string, encrypted bool) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	output, err := e.backend.GetObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	var body io.Reader = output.Body
+	if encrypted {
+		if body, err = e.decryptor.CreateDecryptionReader(ctx, output.Body, metadata); err != nil {
+			return "", err
+		}
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,208 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObject struct {
+	modified time.Time
+	body     []byte
+	metadata map[string]string
+}
+
+// fakeBackend serves objects sorted by key, two per listing page
+type fakeBackend struct {
+	keys    []string
+	objects map[string]fakeObject
+}
+
+func (f *fakeBackend) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var matching []string
+	for _, key := range f.keys {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			matching = append(matching, key)
+		}
+	}
+	start := 0
+	if params.ContinuationToken != nil {
+		_, _ = fmt.Sscanf(aws.ToString(params.ContinuationToken), "%d", &start)
+	}
+	end := min(start+2, len(matching))
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(matching))}
+	for _, key := range matching[start:end] {
+		object := f.objects[key]
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), LastModified: aws.Time(object.modified)})
+	}
+	if end < len(matching) {
+		out.NextContinuationToken = aws.String(fmt.Sprintf("%d", end))
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	object := f.objects[aws.ToString(params.Key)]
+	return &s3.HeadObjectOutput{
+		ETag:          aws.String(`"` + aws.ToString(params.Key) + `"`),
+		ContentLength: aws.Int64(int64(len(object.body))),
+		LastModified:  aws.Time(object.modified),
+		VersionId:     aws.String("v1"),
+		Metadata:      object.metadata,
+	}, nil
+}
+
+func (f *fakeBackend) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	object := f.objects[aws.ToString(params.Key)]
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(object.body))}, nil
+}
+
+// prefixDecryptor "decrypts" by stripping an "enc:" prefix
+type prefixDecryptor struct{}
+
+func (prefixDecryptor) CreateDecryptionReader(_ context.Context, reader io.Reader, _ map[string]string) (io.Reader, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(bytes.TrimPrefix(data, []byte("enc:"))), nil
+}
+
+var (
+	day1 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 = day1.AddDate(0, 0, 1)
+	day3 = day1.AddDate(0, 0, 2)
+)
+
+func newTestBackend() *fakeBackend {
+	encrypted := map[string]string{
+		"s3ep-encrypted-dek":   "ZGVr",
+		"s3ep-dek-algorithm":   "aes-ctr",
+		"s3ep-kek-fingerprint": "fp-1",
+		"s3ep-hmac":            "aG1hYw==",
+	}
+	return &fakeBackend{
+		keys: []string{"ledger/a", "ledger/b", "ledger/c", "other/d"},
+		objects: map[string]fakeObject{
+			"ledger/a": {modified: day1, body: []byte("enc:alpha"), metadata: encrypted},
+			"ledger/b": {modified: day2, body: []byte("enc:bravo"), metadata: encrypted},
+			"ledger/c": {modified: day3, body: []byte("charlie")},
+			"other/d":  {modified: day2, body: []byte("delta")},
+		},
+	}
+}
+
+func newTestExporter(t *testing.T, maxObjects int) (*Exporter, ed25519.PublicKey) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	key := newTestKey(t)
+	return NewExporter(newTestBackend(), prefixDecryptor{}, "s3ep-", key, maxObjects, logger.WithField("component", "test")),
+		key.Public().(ed25519.PublicKey)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExport_PrefixTimeRangeAndChecksums(t *testing.T) {
+	exporter, publicKey := newTestExporter(t, 0)
+
+	signed, err := exporter.Export(context.Background(), Request{
+		Bucket:    "bucket",
+		Prefix:    "ledger/",
+		From:      day2,
+		Checksums: true,
+	})
+	require.NoError(t, err)
+
+	manifest, err := Verify(signed, publicKey)
+	require.NoError(t, err)
+	require.Equal(t, 2, manifest.ObjectCount)
+	require.Len(t, manifest.Objects, 2)
+
+	b := manifest.Objects[0]
+	assert.Equal(t, "ledger/b", b.Key)
+	assert.Equal(t, "v1", b.VersionID)
+	assert.True(t, b.Encrypted)
+	assert.Equal(t, "aes-ctr", b.DEKAlgorithm)
+	assert.Equal(t, "fp-1", b.KeyFingerprint)
+	assert.Equal(t, "aG1hYw==", b.HMAC)
+	assert.Equal(t, sha256Hex("bravo"), b.PlaintextSHA256, "checksum must cover the plaintext")
+
+	c := manifest.Objects[1]
+	assert.Equal(t, "ledger/c", c.Key)
+	assert.False(t, c.Encrypted)
+	assert.Empty(t, c.HMAC)
+	assert.Equal(t, sha256Hex("charlie"), c.PlaintextSHA256)
+
+	signed, err = exporter.Export(context.Background(), Request{Bucket: "bucket", Prefix: "ledger/", To: day2})
+	require.NoError(t, err)
+	manifest, err = Verify(signed, publicKey)
+	require.NoError(t, err)
+	require.Len(t, manifest.Objects, 1)
+	assert.Equal(t, "ledger/a", manifest.Objects[0].Key)
+	assert.Empty(t, manifest.Objects[0].PlaintextSHA256, "checksums are opt-in")
+}
+
+func TestExport_Validation(t *testing.T) {
+	exporter, _ := newTestExporter(t, 2)
+
+	_, err := exporter.Export(context.Background(), Request{})
+	assert.Error(t, err)
+
+	_, err = exporter.Export(context.Background(), Request{Bucket: "bucket", From: day2, To: day1})
+	assert.Error(t, err)
+
+	_, err = exporter.Export(context.Background(), Request{Bucket: "bucket"})
+	assert.ErrorIs(t, err, ErrTooManyObjects)
+}
+
+func TestHandler_ExportAndPublicKey(t *testing.T) {
+	exporter, publicKey := newTestExporter(t, 2)
+	handler := NewHandler(exporter, exporter.logger)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{"bucket":"bucket","prefix":"ledger/","from":"2026-01-02T00:00:00Z"}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var signed SignedManifest
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &signed))
+	manifest, err := Verify(&signed, publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, 2, manifest.ObjectCount)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{"bucket":"bucket"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, BasePath+"/public-key", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	parsed, err := ParsePublicKey(rr.Body.Bytes())
+	require.NoError(t, err)
+	assert.True(t, parsed.Equal(publicKey))
+}
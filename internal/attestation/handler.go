@@ -0,0 +1,91 @@
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the attestation endpoints are mounted on the monitoring server
+const BasePath = "/admin/attestations"
+
+// maxRequestBodySize bounds the JSON body of an export request
+const maxRequestBodySize = 64 * 1024
+
+// Handler exposes the exporter over HTTP:
+//
+//	POST /admin/attestations             export a signed manifest ({"bucket","prefix","from","to","checksums"})
+//	GET  /admin/attestations/public-key  PEM public key to verify manifests with
+type Handler struct {
+	exporter *Exporter
+	logger   *logrus.Entry
+	mux      *http.ServeMux
+}
+
+// NewHandler creates a new attestation HTTP handler
+func NewHandler(exporter *Exporter, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		exporter: exporter,
+		logger:   logger,
+		mux:      http.NewServeMux(),
+	}
+	h.mux.HandleFunc("POST "+BasePath, h.handleExport)
+	h.mux.HandleFunc("GET "+BasePath+"/public-key", h.handlePublicKey)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize)).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Bucket == "" {
+		h.writeError(w, http.StatusBadRequest, "bucket is required")
+		return
+	}
+
+	signed, err := h.exporter.Export(r.Context(), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrTooManyObjects) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		h.logger.WithError(err).WithField("bucket", req.Bucket).Warn("Attestation export failed")
+		h.writeError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="attestation-`+signed.KeyID+`.json"`)
+	h.writeJSON(w, http.StatusOK, signed)
+}
+
+func (h *Handler) handlePublicKey(w http.ResponseWriter, _ *http.Request) {
+	encoded, err := EncodePublicKey(h.exporter.PublicKey())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	_, _ = w.Write(encoded)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write attestation response")
+	}
+}
@@ -0,0 +1,153 @@
+// Package attestation exports signed manifests of the objects stored under a
+// prefix, listing per object its key, version, checksums, HMAC and key
+// fingerprint. Auditors can verify a manifest with the public key alone, as
+// proof of which data existed at a point in time and that it was
+// integrity-protected.
+package attestation
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ManifestVersion is the format version written to every manifest
+const ManifestVersion = 1
+
+// SignatureAlgorithm is the only supported manifest signature algorithm
+const SignatureAlgorithm = "ed25519"
+
+// ErrInvalidSignature is returned when a manifest does not match its signature
+var ErrInvalidSignature = errors.New("manifest signature is invalid")
+
+// Manifest lists the objects that existed under a prefix in a time range
+type Manifest struct {
+	Version     int       `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Bucket      string    `json:"bucket"`
+	Prefix      string    `json:"prefix"`
+	From        time.Time `json:"from,omitzero"`
+	To          time.Time `json:"to,omitzero"`
+	ObjectCount int       `json:"object_count"`
+	Objects     []Entry   `json:"objects"`
+}
+
+// Entry describes one object. HMAC, DEKAlgorithm and KeyFingerprint are taken
+// from the encryption metadata; PlaintextSHA256 is only set when the export
+// decrypted the object to compute it.
+type Entry struct {
+	Key             string    `json:"key"`
+	VersionID       string    `json:"version_id,omitempty"`
+	ETag            string    `json:"etag"`
+	Size            int64     `json:"size"`
+	LastModified    time.Time `json:"last_modified"`
+	Encrypted       bool      `json:"encrypted"`
+	DEKAlgorithm    string    `json:"dek_algorithm,omitempty"`
+	KeyFingerprint  string    `json:"key_fingerprint,omitempty"`
+	HMAC            string    `json:"hmac,omitempty"`
+	PlaintextSHA256 string    `json:"plaintext_sha256,omitempty"`
+}
+
+// SignedManifest is the exported document. Signature covers the exact bytes of
+// Manifest, which is why it is kept as raw JSON.
+type SignedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Algorithm string          `json:"algorithm"`
+	KeyID     string          `json:"key_id"`
+	Signature []byte          `json:"signature"`
+}
+
+// Sign serializes manifest and signs it with key
+func Sign(manifest *Manifest, key ed25519.PrivateKey) (*SignedManifest, error) {
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	return &SignedManifest{
+		Manifest:  payload,
+		Algorithm: SignatureAlgorithm,
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, payload),
+	}, nil
+}
+
+// Verify checks the signature of signed against publicKey and returns the
+// decoded manifest
+func Verify(signed *SignedManifest, publicKey ed25519.PublicKey) (*Manifest, error) {
+	if signed.Algorithm != SignatureAlgorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", signed.Algorithm)
+	}
+	if !ed25519.Verify(publicKey, signed.Manifest, signed.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(signed.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// KeyID identifies a signing key by the first 16 bytes of the SHA-256 of its
+// public key
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:16])
+}
+
+// LoadSigningKey reads a PKCS#8 PEM encoded Ed25519 private key
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from the configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// EncodePublicKey returns the PKIX PEM encoding of publicKey, for auditors to
+// verify manifests with
+func EncodePublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKey decodes a PKIX PEM encoded Ed25519 public key as written by
+// EncodePublicKey
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an Ed25519 key")
+	}
+	return key, nil
+}
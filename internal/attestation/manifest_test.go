@@ -0,0 +1,90 @@
+package attestation
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSignVerify_RoundTrip(t *testing.T) {
+	key := newTestKey(t)
+	manifest := &Manifest{
+		Version:     ManifestVersion,
+		GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Bucket:      "bucket",
+		Prefix:      "ledger/",
+		ObjectCount: 1,
+		Objects:     []Entry{{Key: "ledger/2025.csv", ETag: `"etag"`, Size: 10, Encrypted: true, HMAC: "aG1hYw=="}},
+	}
+
+	signed, err := Sign(manifest, key)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureAlgorithm, signed.Algorithm)
+	assert.Equal(t, KeyID(key.Public().(ed25519.PublicKey)), signed.KeyID)
+
+	verified, err := Verify(signed, key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, manifest, verified)
+}
+
+func TestVerify_RejectsTamperingAndOtherKeys(t *testing.T) {
+	key := newTestKey(t)
+	signed, err := Sign(&Manifest{Version: ManifestVersion, Bucket: "bucket", Objects: []Entry{{Key: "a", HMAC: "x"}}}, key)
+	require.NoError(t, err)
+
+	_, err = Verify(signed, newTestKey(t).Public().(ed25519.PublicKey))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	tampered := *signed
+	tampered.Manifest = []byte(string(signed.Manifest[:len(signed.Manifest)-1]) + " }")
+	_, err = Verify(&tampered, key.Public().(ed25519.PublicKey))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	tampered = *signed
+	tampered.Algorithm = "rsa"
+	_, err = Verify(&tampered, key.Public().(ed25519.PublicKey))
+	assert.Error(t, err)
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	key := newTestKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signing.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	loaded, err := LoadSigningKey(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(loaded))
+
+	encoded, err := EncodePublicKey(loaded.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKey(encoded)
+	require.NoError(t, err)
+	assert.True(t, publicKey.Equal(key.Public()))
+
+	garbage := filepath.Join(dir, "garbage.pem")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a key"), 0o600))
+	_, err = LoadSigningKey(garbage)
+	assert.Error(t, err)
+
+	_, err = LoadSigningKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
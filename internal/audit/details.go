@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// Details collects what the encryption layer learns about a request while it
+// is served: the provider fingerprint of the object's DEK, the result of its
+// HMAC verification and whether data was encrypted or decrypted. The methods
+// are safe for concurrent use and do nothing on a nil Details.
+type Details struct {
+	mutex          sync.Mutex
+	kekFingerprint string
+	integrity      string
+	encrypted      bool
+	decrypted      bool
+}
+
+type detailsKey struct{}
+
+// WithDetails attaches new Details to ctx
+func WithDetails(ctx context.Context) (context.Context, *Details) {
+	details := &Details{}
+	return context.WithValue(ctx, detailsKey{}, details), details
+}
+
+// DetailsFrom returns the Details attached to ctx, or nil for requests that
+// are not audited
+func DetailsFrom(ctx context.Context) *Details {
+	details, _ := ctx.Value(detailsKey{}).(*Details)
+	return details
+}
+
+// SetKEKFingerprint records the fingerprint of the provider that wrapped or
+// unwrapped the DEK
+func (d *Details) SetKEKFingerprint(fingerprint string) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.kekFingerprint = fingerprint
+}
+
+// SetIntegrity records the result of the HMAC verification. A failure is
+// kept even if another verification of the request succeeds later.
+func (d *Details) SetIntegrity(result string) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.integrity != IntegrityFailed {
+		d.integrity = result
+	}
+}
+
+// MarkEncrypted records that data of the request was encrypted
+func (d *Details) MarkEncrypted() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.encrypted = true
+}
+
+// MarkDecrypted records that data of the request was decrypted
+func (d *Details) MarkDecrypted() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.decrypted = true
+}
+
+// Crypto reports whether data of the request was encrypted and decrypted
+func (d *Details) Crypto() (encrypted, decrypted bool) {
+	if d == nil {
+		return false, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.encrypted, d.decrypted
+}
+
+// apply copies the collected details into record
+func (d *Details) apply(record *Record) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	record.KEKFingerprint = d.kekFingerprint
+	record.Integrity = d.integrity
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/kafkarest"
+)
+
+// KafkaSink produces records to a Kafka topic through a Kafka REST Proxy,
+// keyed by bucket so the records of a bucket stay in order on one partition
+type KafkaSink struct {
+	producer *kafkarest.Producer[Record]
+}
+
+// NewKafkaSink creates a sink producing to topic through the REST Proxy at
+// restURL
+func NewKafkaSink(restURL, topic string, headers map[string]string) *KafkaSink {
+	return &KafkaSink{producer: kafkarest.NewProducer[Record](restURL, topic, headers)}
+}
+
+// Send implements Sink
+func (s *KafkaSink) Send(ctx context.Context, records []Record) error {
+	batch := make([]kafkarest.Record[Record], 0, len(records))
+	for _, record := range records {
+		batch = append(batch, kafkarest.Record[Record]{Key: record.Bucket, Value: record})
+	}
+	return s.producer.Produce(ctx, batch)
+}
+
+// Close implements Sink
+func (s *KafkaSink) Close() error {
+	s.producer.Close()
+	return nil
+}
@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// ErrClosed is returned when appending to a closed log
+var ErrClosed = errors.New("audit log is closed")
+
+// Options configures a Log
+type Options struct {
+	Directory  string             // Directory holding the segment files
+	SigningKey ed25519.PrivateKey // Key the seals are signed with
+	MaxRecords int                // Records after which a segment is sealed
+	MaxAge     time.Duration      // Age after which a segment with records is sealed, 0 = no limit
+}
+
+// Log appends records to the open segment and seals it once it is full, old
+// or the log is closed
+type Log struct {
+	opts   Options
+	keyID  string
+	logger *logrus.Entry
+
+	mutex    sync.Mutex
+	file     *os.File // open segment, nil until the first record after a seal
+	segment  uint64   // number of the open or next segment
+	nextSeq  uint64
+	prevHash string
+	closed   bool
+
+	// State of the open segment
+	firstSeq    uint64
+	segmentPrev string
+	hashes      []string
+	ageTimer    *time.Timer
+}
+
+// Open opens the log in opts.Directory and continues the chain of the
+// segments found there. A segment left open by an unclean shutdown is sealed
+// first; a last line cut off while it was written is dropped.
+func Open(opts Options, logger *logrus.Entry) (*Log, error) {
+	if err := os.MkdirAll(opts.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	l := &Log{
+		opts:     opts,
+		keyID:    attestation.KeyID(opts.SigningKey.Public().(ed25519.PublicKey)),
+		logger:   logger,
+		segment:  1,
+		nextSeq:  1,
+		prevHash: GenesisHash,
+	}
+	if err := l.resume(); err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"directory": opts.Directory,
+		"key_id":    l.keyID,
+		"next_seq":  l.nextSeq,
+	}).Info("Audit log opened")
+	return l, nil
+}
+
+// resume restores the chain state from the last segment
+func (l *Log) resume() error {
+	numbers, err := listSegments(l.opts.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to list audit segments: %w", err)
+	}
+
+	for i := len(numbers) - 1; i >= 0; i-- {
+		path := filepath.Join(l.opts.Directory, segmentName(numbers[i]))
+		segment, err := readSegment(path, numbers[i])
+		if err != nil {
+			return fmt.Errorf("failed to read audit segment: %w", err)
+		}
+
+		if segment.sealLine != nil {
+			var seal Seal
+			if err := json.Unmarshal(segment.sealRaw, &seal); err != nil {
+				return fmt.Errorf("failed to decode seal of %s: %w", filepath.Base(path), err)
+			}
+			l.segment = segment.number + 1
+			l.nextSeq = seal.LastSeq + 1
+			l.prevHash = seal.LastHash
+			return nil
+		}
+
+		if segment.partial() {
+			l.logger.WithField("segment", filepath.Base(path)).Warn("Dropping incomplete last audit record")
+			if err := os.Truncate(path, segment.complete); err != nil {
+				return fmt.Errorf("failed to truncate audit segment: %w", err)
+			}
+		}
+
+		// An open segment without records holds nothing worth keeping
+		if len(segment.records) == 0 {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove empty audit segment: %w", err)
+			}
+			continue
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600) // #nosec G304 - path is inside the audit directory
+		if err != nil {
+			return fmt.Errorf("failed to open audit segment: %w", err)
+		}
+		first, last := segment.records[0], segment.records[len(segment.records)-1]
+		l.file = file
+		l.segment = segment.number
+		l.firstSeq = first.record.Sequence
+		l.segmentPrev = first.record.PrevHash
+		l.nextSeq = last.record.Sequence + 1
+		l.prevHash = last.hash
+		for _, record := range segment.records {
+			l.hashes = append(l.hashes, record.hash)
+		}
+
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		return l.seal(SealReasonRecovered)
+	}
+	return nil
+}
+
+// Append fills in the sequence number and previous hash of record and
+// writes it to the open segment
+func (l *Log) Append(record Record) error {
+	_, err := l.appendRecord(record)
+	return err
+}
+
+// appendRecord is Append, returning the record as written
+func (l *Log) appendRecord(record Record) (Record, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed {
+		return record, ErrClosed
+	}
+	if l.file == nil {
+		if err := l.openSegment(); err != nil {
+			return record, err
+		}
+	}
+
+	record.Sequence = l.nextSeq
+	record.PrevHash = l.prevHash
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return record, fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	hash := hashRecord(raw)
+	data, err := json.Marshal(line{Record: raw, Hash: hash})
+	if err != nil {
+		return record, fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return record, fmt.Errorf("failed to write audit record: %w", err)
+	}
+
+	l.nextSeq++
+	l.prevHash = hash
+	l.hashes = append(l.hashes, hash)
+
+	if len(l.hashes) >= l.opts.MaxRecords {
+		return record, l.seal(SealReasonSize)
+	}
+	return record, nil
+}
+
+// Close seals the open segment
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.seal(SealReasonShutdown)
+}
+
+// openSegment creates the file of the next segment
+func (l *Log) openSegment() error {
+	path := filepath.Join(l.opts.Directory, segmentName(l.segment))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o600) // #nosec G304 - path is inside the audit directory
+	if err != nil {
+		return fmt.Errorf("failed to create audit segment: %w", err)
+	}
+
+	l.file = file
+	l.firstSeq = l.nextSeq
+	l.segmentPrev = l.prevHash
+	l.hashes = nil
+	if l.opts.MaxAge > 0 {
+		segment := l.segment
+		l.ageTimer = time.AfterFunc(l.opts.MaxAge, func() { l.sealAged(segment) })
+	}
+	return nil
+}
+
+// sealAged seals segment if it is still open
+func (l *Log) sealAged(segment uint64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed || l.file == nil || l.segment != segment {
+		return
+	}
+	if err := l.seal(SealReasonAge); err != nil {
+		l.logger.WithError(err).Error("Failed to seal audit segment")
+	}
+}
+
+// seal writes the signed seal of the open segment and closes its file. The
+// caller holds the mutex.
+func (l *Log) seal(reason string) error {
+	if l.file == nil {
+		return nil
+	}
+	if l.ageTimer != nil {
+		l.ageTimer.Stop()
+		l.ageTimer = nil
+	}
+
+	root, err := MerkleRoot(l.hashes)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(Seal{
+		Segment:    l.segment,
+		FirstSeq:   l.firstSeq,
+		LastSeq:    l.nextSeq - 1,
+		Records:    len(l.hashes),
+		PrevHash:   l.segmentPrev,
+		LastHash:   l.prevHash,
+		MerkleRoot: root,
+		SealedAt:   time.Now().UTC(),
+		Reason:     reason,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit seal: %w", err)
+	}
+	data, err := json.Marshal(line{
+		Seal:      raw,
+		Algorithm: SignatureAlgorithm,
+		KeyID:     l.keyID,
+		Signature: ed25519.Sign(l.opts.SigningKey, raw),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit seal: %w", err)
+	}
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit seal: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit segment: %w", err)
+	}
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit segment: %w", err)
+	}
+
+	monitoring.RecordAuditSeal(reason)
+	l.logger.WithFields(logrus.Fields{
+		"segment":     l.segment,
+		"records":     len(l.hashes),
+		"merkle_root": root,
+		"reason":      reason,
+	}).Debug("Audit segment sealed")
+
+	l.file = nil
+	l.hashes = nil
+	l.segment++
+	return nil
+}
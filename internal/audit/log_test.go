@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func openTestLog(t *testing.T, dir string, key ed25519.PrivateKey, maxRecords int) *Log {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	l, err := Open(Options{Directory: dir, SigningKey: key, MaxRecords: maxRecords}, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	return l
+}
+
+func appendRecords(t *testing.T, l *Log, n int) {
+	for i := 0; i < n; i++ {
+		require.NoError(t, l.Append(Record{Time: time.Now().UTC(), Method: "GET", Bucket: "bucket", Key: fmt.Sprintf("key-%d", i), Status: 200}))
+	}
+}
+
+// writeTestLog writes 7 records in segments of 3, the last one sealed on close
+func writeTestLog(t *testing.T) (string, ed25519.PrivateKey) {
+	dir := t.TempDir()
+	key := newTestKey(t)
+	l := openTestLog(t, dir, key, 3)
+	appendRecords(t, l, 7)
+	require.NoError(t, l.Close())
+	return dir, key
+}
+
+func publicKey(key ed25519.PrivateKey) ed25519.PublicKey {
+	return key.Public().(ed25519.PublicKey)
+}
+
+func TestLog_WriteAndVerify(t *testing.T) {
+	dir, key := writeTestLog(t)
+
+	report, err := Verify(dir, publicKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Segments)
+	assert.Equal(t, 3, report.Sealed)
+	assert.Equal(t, uint64(7), report.Records)
+	assert.Equal(t, uint64(1), report.FirstSeq)
+	assert.Equal(t, uint64(7), report.LastSeq)
+	assert.True(t, report.FromGenesis)
+	assert.Zero(t, report.OpenRecords)
+
+	segment, err := readSegment(filepath.Join(dir, segmentName(3)), 3)
+	require.NoError(t, err)
+	assert.Contains(t, string(segment.sealRaw), `"reason":"shutdown"`)
+
+	// Closing a log without records leaves no empty segment behind
+	emptyDir := t.TempDir()
+	l := openTestLog(t, emptyDir, key, 3)
+	require.NoError(t, l.Close())
+	assert.ErrorIs(t, l.Append(Record{}), ErrClosed)
+	numbers, err := listSegments(emptyDir)
+	require.NoError(t, err)
+	assert.Empty(t, numbers)
+}
+
+func TestLog_ContinuesChainAcrossRestarts(t *testing.T) {
+	dir, key := writeTestLog(t)
+
+	l := openTestLog(t, dir, key, 3)
+	appendRecords(t, l, 2)
+
+	// The proxy stops without sealing and the last record is cut off
+	path := filepath.Join(dir, segmentName(4))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"record":{"seq":10,`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	report, err := Verify(dir, publicKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.OpenRecords)
+
+	// The next start drops the partial line and seals the segment
+	l = openTestLog(t, dir, key, 3)
+	appendRecords(t, l, 1)
+	require.NoError(t, l.Close())
+
+	report, err = Verify(dir, publicKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Segments)
+	assert.Equal(t, 5, report.Sealed)
+	assert.Equal(t, uint64(10), report.Records)
+
+	segment, err := readSegment(path, 4)
+	require.NoError(t, err)
+	assert.Contains(t, string(segment.sealRaw), `"reason":"recovered"`)
+}
+
+func TestLog_SealsByAge(t *testing.T) {
+	dir := t.TempDir()
+	key := newTestKey(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	l, err := Open(Options{Directory: dir, SigningKey: key, MaxRecords: 100, MaxAge: 20 * time.Millisecond}, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	appendRecords(t, l, 2)
+
+	assert.Eventually(t, func() bool {
+		report, err := Verify(dir, publicKey(key))
+		return err == nil && report.Sealed == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	appendRecords(t, l, 1)
+	require.NoError(t, l.Close())
+	report, err := Verify(dir, publicKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Sealed)
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, dir string)
+		errorMsg string
+	}{
+		{name: "edited record", tamper: func(t *testing.T, dir string) {
+			replaceInSegment(t, dir, 2, `"key-4"`, `"key-X"`)
+		}, errorMsg: "record 5 does not match its hash"},
+		{name: "edited record with new hash", tamper: func(t *testing.T, dir string) {
+			segment := readTestSegment(t, dir, 2)
+			raw := string(segment.records[1].raw)
+			edited := strings.Replace(raw, `"key-4"`, `"key-X"`, 1)
+			replaceInSegment(t, dir, 2, raw, edited)
+			replaceInSegment(t, dir, 2, segment.records[1].hash, hashRecord([]byte(edited)))
+		}, errorMsg: "record 6 does not link to the record before it"},
+		{name: "removed record", tamper: func(t *testing.T, dir string) {
+			removeLine(t, dir, 2, 1)
+		}, errorMsg: "has record 6 where record 5 was expected"},
+		{name: "removed segment", tamper: func(t *testing.T, dir string) {
+			require.NoError(t, os.Remove(filepath.Join(dir, segmentName(2))))
+		}, errorMsg: "segments 2 to 2 are missing"},
+		{name: "removed seal", tamper: func(t *testing.T, dir string) {
+			removeLine(t, dir, 1, 3)
+		}, errorMsg: "is not sealed"},
+		{name: "forged seal", tamper: func(t *testing.T, dir string) {
+			replaceInSegment(t, dir, 3, `"reason":"shutdown"`, `"reason":"size"`)
+		}, errorMsg: "seal signature is invalid"},
+		{name: "appended after seal", tamper: func(t *testing.T, dir string) {
+			segment := readTestSegment(t, dir, 1)
+			appendLine(t, dir, 1, fmt.Sprintf(`{"record":%s,"hash":"%s"}`, segment.records[0].raw, segment.records[0].hash))
+		}, errorMsg: "data after the seal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, key := writeTestLog(t)
+			tt.tamper(t, dir)
+
+			_, err := Verify(dir, publicKey(key))
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrTampered)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestVerify_WrongKey(t *testing.T) {
+	dir, _ := writeTestLog(t)
+	_, err := Verify(dir, publicKey(newTestKey(t)))
+	assert.ErrorIs(t, err, ErrTampered)
+	assert.Contains(t, err.Error(), "seal signature is invalid")
+}
+
+func TestVerify_RemovedOldSegments(t *testing.T) {
+	dir, key := writeTestLog(t)
+	require.NoError(t, os.Remove(filepath.Join(dir, segmentName(1))))
+
+	report, err := Verify(dir, publicKey(key))
+	require.NoError(t, err)
+	assert.False(t, report.FromGenesis)
+	assert.Equal(t, uint64(4), report.FirstSeq)
+}
+
+func TestMerkleRoot(t *testing.T) {
+	leaf := func(b byte) string { return hex.EncodeToString(bytes.Repeat([]byte{b}, 32)) }
+	hashLeaf := func(h string) []byte {
+		decoded, _ := hex.DecodeString(h)
+		sum := sha256.Sum256(append([]byte{0}, decoded...))
+		return sum[:]
+	}
+	node := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{1}, left...), right...))
+		return sum[:]
+	}
+
+	empty := sha256.Sum256(nil)
+	root, err := MerkleRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(empty[:]), root)
+
+	// Three leaves split as ((a, b), c)
+	a, b, c := leaf(1), leaf(2), leaf(3)
+	root, err = MerkleRoot([]string{a, b, c})
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(node(node(hashLeaf(a), hashLeaf(b)), hashLeaf(c))), root)
+
+	_, err = MerkleRoot([]string{"not hex"})
+	assert.Error(t, err)
+}
+
+func readTestSegment(t *testing.T, dir string, number uint64) *segmentFile {
+	segment, err := readSegment(filepath.Join(dir, segmentName(number)), number)
+	require.NoError(t, err)
+	return segment
+}
+
+func replaceInSegment(t *testing.T, dir string, number uint64, old, replacement string) {
+	path := filepath.Join(dir, segmentName(number))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), old)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), old, replacement, 1)), 0o600))
+}
+
+func removeLine(t *testing.T, dir string, number uint64, index int) {
+	path := filepath.Join(dir, segmentName(number))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(append(lines[:index], lines[index+1:]...), "")), 0o600))
+}
+
+func appendLine(t *testing.T, dir string, number uint64, content string) {
+	file, err := os.OpenFile(filepath.Join(dir, segmentName(number)), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(content + "\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
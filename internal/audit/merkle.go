@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// MerkleRoot returns the hex Merkle tree hash of the given hex record hashes,
+// computed as in RFC 6962 section 2.1: leaves are hashed with a 0x00 prefix
+// and inner nodes with a 0x01 prefix, so a leaf cannot pass for a node
+func MerkleRoot(hashes []string) (string, error) {
+	leaves := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		decoded, err := hex.DecodeString(h)
+		if err != nil {
+			return "", fmt.Errorf("invalid record hash %q: %w", h, err)
+		}
+		leaves[i] = decoded
+	}
+	return hex.EncodeToString(merkleTreeHash(leaves)), nil
+}
+
+func merkleTreeHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		sum := sha256.Sum256(append([]byte{0x00}, leaves[0]...))
+		return sum[:]
+	}
+
+	// Split at the largest power of two smaller than the number of leaves
+	split := 1
+	for split*2 < len(leaves) {
+		split *= 2
+	}
+	node := make([]byte, 0, 1+2*sha256.Size)
+	node = append(node, 0x01)
+	node = append(node, merkleTreeHash(leaves[:split])...)
+	node = append(node, merkleTreeHash(leaves[split:])...)
+	sum := sha256.Sum256(node)
+	return sum[:]
+}
@@ -0,0 +1,124 @@
+// Package audit writes a tamper-evident log of the requests served by the
+// proxy. Every record carries the hash of the record before it, so removing,
+// reordering or editing a record breaks the chain. Records are written to
+// segment files; a segment is closed with a seal holding the Merkle root of
+// its record hashes, signed with an Ed25519 key. Auditors verify a log with
+// Verify and the public key alone. A Recorder also forwards the records to
+// Sinks such as syslog, webhooks and Kafka.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SignatureAlgorithm is the only supported seal signature algorithm
+const SignatureAlgorithm = "ed25519"
+
+// GenesisHash is the previous hash of the first record of a log
+var GenesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Record is one audited request. Sequence and PrevHash are only set for
+// records written to the log; sinks get them as the log wrote them.
+type Record struct {
+	Sequence       uint64    `json:"seq"`
+	Time           time.Time `json:"time"`
+	PrevHash       string    `json:"prev_hash"`
+	RequestID      string    `json:"request_id,omitempty"`
+	AccessKeyID    string    `json:"access_key_id,omitempty"` // As presented; requests denied with 403 were not authenticated
+	RemoteAddr     string    `json:"remote_addr"`
+	Method         string    `json:"method"`
+	Operation      string    `json:"operation,omitempty"` // S3 API operation, e.g. GetObject
+	Bucket         string    `json:"bucket,omitempty"`
+	Key            string    `json:"key,omitempty"`
+	Query          []string  `json:"query_parameters,omitempty"` // names only, values may carry presigned credentials
+	Status         int       `json:"status"`                     // 0 if the connection was aborted
+	Outcome        string    `json:"outcome,omitempty"`          // One of the Outcome constants
+	BytesReceived  int64     `json:"bytes_received"`
+	BytesSent      int64     `json:"bytes_sent"`
+	KEKFingerprint string    `json:"kek_fingerprint,omitempty"` // Provider that wrapped or unwrapped the DEK of the object
+	Integrity      string    `json:"integrity,omitempty"`       // One of the Integrity constants; empty if no HMAC was checked
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// Outcomes of audited requests
+const (
+	OutcomeSuccess     = "success"
+	OutcomeDenied      = "denied"       // 401 or 403
+	OutcomeClientError = "client_error" // other 4xx
+	OutcomeServerError = "server_error" // 5xx
+	OutcomeAborted     = "aborted"      // the connection was aborted
+)
+
+// OutcomeOf returns the outcome of a request answered with status, 0 for
+// aborted connections
+func OutcomeOf(status int) string {
+	switch {
+	case status == 0:
+		return OutcomeAborted
+	case status == 401 || status == 403:
+		return OutcomeDenied
+	case status >= 500:
+		return OutcomeServerError
+	case status >= 400:
+		return OutcomeClientError
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// Results of the HMAC verification of a read object
+const (
+	IntegrityVerified = "verified"
+	IntegrityFailed   = "failed"
+)
+
+// Seal closes a segment. Signature covers the exact bytes of the seal, which
+// is why segment files keep it as raw JSON.
+type Seal struct {
+	Segment    uint64    `json:"segment"`
+	FirstSeq   uint64    `json:"first_seq"`
+	LastSeq    uint64    `json:"last_seq"`
+	Records    int       `json:"records"`
+	PrevHash   string    `json:"prev_hash"` // Previous hash of the first record, linking the previous segment
+	LastHash   string    `json:"last_hash"`
+	MerkleRoot string    `json:"merkle_root"`
+	SealedAt   time.Time `json:"sealed_at"`
+	Reason     string    `json:"reason"` // size, age, shutdown or recovered
+}
+
+// Seal reasons
+const (
+	SealReasonSize      = "size"
+	SealReasonAge       = "age"
+	SealReasonShutdown  = "shutdown"
+	SealReasonRecovered = "recovered" // sealed at startup after an unclean shutdown
+)
+
+// line is one line of a segment file: either a record with its hash or the
+// signed seal
+type line struct {
+	Record    json.RawMessage `json:"record,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+	Seal      json.RawMessage `json:"seal,omitempty"`
+	Algorithm string          `json:"algorithm,omitempty"`
+	KeyID     string          `json:"key_id,omitempty"`
+	Signature []byte          `json:"signature,omitempty"`
+}
+
+// hashRecord returns the hex SHA-256 of the encoded record
+func hashRecord(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// segmentName returns the file name of segment number n
+func segmentName(n uint64) string {
+	return fmt.Sprintf("segment-%020d.jsonl", n)
+}
+
+// segmentPattern matches segment file names
+const segmentPattern = "segment-*.jsonl"
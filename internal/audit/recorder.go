@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// Sink receives audit records outside the proxy, e.g. a syslog server, a
+// webhook or Kafka. Send is called with batches of records from a single
+// goroutine per sink and must not keep the slice after they return.
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// SinkOptions configures how records are handed to a sink
+type SinkOptions struct {
+	Name          string        // Used in logs and metrics
+	QueueSize     int           // Records waiting for the sink before new ones are dropped
+	BatchSize     int           // Records per Send
+	FlushInterval time.Duration // Time a partial batch waits for more records
+	Timeout       time.Duration // Time a Send may take
+}
+
+// Recorder writes audit records to the log, if there is one, and forwards
+// them to the sinks. Sinks get records asynchronously: a slow or unreachable
+// sink never delays requests, its records are dropped once its queue is full.
+type Recorder struct {
+	log        *Log
+	forwarders []*forwarder
+	logger     *logrus.Entry
+}
+
+// NewRecorder creates a recorder without sinks; log may be nil
+func NewRecorder(log *Log, logger *logrus.Entry) *Recorder {
+	return &Recorder{log: log, logger: logger}
+}
+
+// AddSink starts a goroutine that forwards records to sink until Close
+func (r *Recorder) AddSink(sink Sink, opts SinkOptions) {
+	f := &forwarder{
+		sink:   sink,
+		opts:   opts,
+		queue:  make(chan Record, opts.QueueSize),
+		done:   make(chan struct{}),
+		logger: r.logger.WithField("sink", opts.Name),
+	}
+	r.forwarders = append(r.forwarders, f)
+	go f.run()
+}
+
+// Record completes record with the details collected in ctx, appends it to
+// the log and queues it for the sinks. It returns the error of the log; the
+// sinks report theirs in logs and metrics.
+func (r *Recorder) Record(ctx context.Context, record Record) error {
+	DetailsFrom(ctx).apply(&record)
+	record.Outcome = OutcomeOf(record.Status)
+
+	var err error
+	if r.log != nil {
+		record, err = r.log.appendRecord(record)
+		monitoring.RecordAuditRecord(err)
+	}
+	for _, f := range r.forwarders {
+		f.enqueue(record)
+	}
+	return err
+}
+
+// Close delivers the queued records, closes the sinks and seals the log
+func (r *Recorder) Close() error {
+	var errs []error
+	for _, f := range r.forwarders {
+		errs = append(errs, f.close())
+	}
+	if r.log != nil {
+		errs = append(errs, r.log.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// forwarder batches the records of one sink
+type forwarder struct {
+	sink   Sink
+	opts   SinkOptions
+	queue  chan Record
+	done   chan struct{}
+	logger *logrus.Entry
+
+	mutex  sync.RWMutex // guards closed and sending to queue
+	closed bool
+}
+
+// enqueue queues record, dropping it if the queue is full
+func (f *forwarder) enqueue(record Record) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- record:
+	default:
+		monitoring.RecordAuditSinkRecords(f.opts.Name, "dropped", 1)
+	}
+}
+
+// run sends batches until the queue is closed
+func (f *forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, f.opts.BatchSize)
+	for {
+		select {
+		case record, ok := <-f.queue:
+			if !ok {
+				f.send(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= f.opts.BatchSize {
+				f.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			f.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// send hands batch to the sink
+func (f *forwarder) send(batch []Record) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.opts.Timeout)
+	defer cancel()
+	if err := f.sink.Send(ctx, batch); err != nil {
+		monitoring.RecordAuditSinkRecords(f.opts.Name, "failed", len(batch))
+		f.logger.WithError(err).WithField("records", len(batch)).Error("Failed to forward audit records")
+		return
+	}
+	monitoring.RecordAuditSinkRecords(f.opts.Name, "sent", len(batch))
+}
+
+// close stops accepting records, sends the queued ones and closes the sink
+func (f *forwarder) close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.queue)
+	f.mutex.Unlock()
+
+	<-f.done
+	return f.sink.Close()
+}
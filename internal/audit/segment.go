@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// segmentRecord is a record line of a segment file
+type segmentRecord struct {
+	raw    json.RawMessage
+	hash   string
+	record Record
+}
+
+// segmentFile is the parsed content of a segment file
+type segmentFile struct {
+	number   uint64
+	path     string
+	records  []segmentRecord
+	sealRaw  json.RawMessage
+	sealLine *line
+	// Length of the complete lines; a shorter file length means the last
+	// line was cut off while it was written
+	complete int64
+	size     int64
+}
+
+// partial reports whether the file ends in an incomplete line
+func (s *segmentFile) partial() bool {
+	return s.complete < s.size
+}
+
+// readSegment parses a segment file. Lines after the seal and lines that are
+// not valid JSON are errors; an incomplete last line is not.
+func readSegment(path string, number uint64) (*segmentFile, error) {
+	file, err := os.Open(path) // #nosec G304 - path is inside the audit directory
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	segment := &segmentFile{number: number, path: path}
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		data, err := reader.ReadBytes('\n')
+		segment.size += int64(len(data))
+		if errors.Is(err, io.EOF) {
+			return segment, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		segment.complete = segment.size
+
+		if segment.sealLine != nil {
+			return nil, fmt.Errorf("%s line %d: data after the seal", filepath.Base(path), lineNumber)
+		}
+		var parsed line
+		if err := json.Unmarshal(bytes.TrimSuffix(data, []byte("\n")), &parsed); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filepath.Base(path), lineNumber, err)
+		}
+		switch {
+		case parsed.Seal != nil:
+			segment.sealRaw = parsed.Seal
+			segment.sealLine = &parsed
+		case parsed.Record != nil:
+			var record Record
+			if err := json.Unmarshal(parsed.Record, &record); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", filepath.Base(path), lineNumber, err)
+			}
+			segment.records = append(segment.records, segmentRecord{raw: parsed.Record, hash: parsed.Hash, record: record})
+		default:
+			return nil, fmt.Errorf("%s line %d: neither a record nor a seal", filepath.Base(path), lineNumber)
+		}
+	}
+}
+
+// listSegments returns the segment numbers in dir in ascending order
+func listSegments(dir string) ([]uint64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, segmentPattern))
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]uint64, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "segment-"), ".jsonl")
+		number, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSinkOptions(name string) SinkOptions {
+	return SinkOptions{Name: name, QueueSize: 100, BatchSize: 2, FlushInterval: 10 * time.Millisecond, Timeout: time.Second}
+}
+
+func newTestRecorder() *Recorder {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewRecorder(nil, logrus.NewEntry(logger))
+}
+
+func TestRecorder_WebhookSink(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]Record
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var batch []Record
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		mutex.Lock()
+		batches = append(batches, batch)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	recorder := newTestRecorder()
+	recorder.AddSink(NewWebhookSink(server.URL, map[string]string{"Authorization": "Bearer token"}), testSinkOptions("webhook"))
+
+	ctx, details := WithDetails(context.Background())
+	details.SetKEKFingerprint("fingerprint")
+	details.SetIntegrity(IntegrityFailed)
+	details.SetIntegrity(IntegrityVerified)
+	for _, status := range []int{200, 403, 503} {
+		require.NoError(t, recorder.Record(ctx, Record{Method: "GET", Bucket: "bucket", Status: status}))
+	}
+	require.NoError(t, recorder.Close())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var records []Record
+	for _, batch := range batches {
+		assert.LessOrEqual(t, len(batch), 2)
+		records = append(records, batch...)
+	}
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{OutcomeSuccess, OutcomeDenied, OutcomeServerError},
+		[]string{records[0].Outcome, records[1].Outcome, records[2].Outcome})
+	assert.Equal(t, "fingerprint", records[0].KEKFingerprint)
+	assert.Equal(t, IntegrityFailed, records[0].Integrity, "a failed verification is not overwritten")
+}
+
+func TestRecorder_KafkaSink(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kafka/topics/audit.events", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	recorder := newTestRecorder()
+	recorder.AddSink(NewKafkaSink(server.URL+"/kafka/", "audit.events", nil), testSinkOptions("kafka"))
+	require.NoError(t, recorder.Record(context.Background(), Record{Method: "PUT", Operation: "PutObject", Bucket: "bucket", Status: 200}))
+	require.NoError(t, recorder.Close())
+
+	var batch struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Record `json:"value"`
+		} `json:"records"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(<-received), &batch))
+	require.Len(t, batch.Records, 1)
+	assert.Equal(t, "bucket", batch.Records[0].Key)
+	assert.Equal(t, "PutObject", batch.Records[0].Value.Operation)
+}
+
+func TestRecorder_SyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	facility, ok := SyslogFacility("local3")
+	require.True(t, ok)
+	recorder := newTestRecorder()
+	recorder.AddSink(NewSyslogSink("udp", conn.LocalAddr().String(), facility, "s3-encryption-proxy"), testSinkOptions("syslog"))
+	require.NoError(t, recorder.Record(context.Background(), Record{Method: "GET", Operation: "GetObject", Status: 500, Time: time.Now()}))
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	message := string(buf[:n])
+	assert.True(t, strings.HasPrefix(message, "<156>1 "), message) // local3, warning
+	assert.Contains(t, message, " s3-encryption-proxy ")
+	assert.Contains(t, message, " GetObject - {")
+	assert.Contains(t, message, `"outcome":"server_error"`)
+}
+
+// blockingSink blocks every Send until release is closed
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Send(context.Context, []Record) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestRecorder_SlowSinkDoesNotBlock(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	recorder := newTestRecorder()
+	recorder.AddSink(sink, SinkOptions{Name: "slow", QueueSize: 1, BatchSize: 1, FlushInterval: time.Second, Timeout: time.Second})
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			_ = recorder.Record(context.Background(), Record{Status: 200})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording blocked on a slow sink")
+	}
+
+	close(sink.release)
+	require.NoError(t, recorder.Close())
+	assert.NoError(t, recorder.Record(context.Background(), Record{Status: 200}), "records after close are dropped")
+}
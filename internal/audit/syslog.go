@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogFacilities are the facilities audit messages can be sent with
+var syslogFacilities = map[string]int{
+	"auth": 4, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogFacility returns the code of the facility with name, e.g. "local0"
+func SyslogFacility(name string) (int, bool) {
+	facility, ok := syslogFacilities[name]
+	return facility, ok
+}
+
+// syslogSeverity maps outcomes to syslog severities: failures are warnings,
+// everything else is informational
+func syslogSeverity(record Record) int {
+	if record.Outcome == OutcomeServerError || record.Integrity == IntegrityFailed {
+		return 4 // warning
+	}
+	return 6 // informational
+}
+
+// SyslogSink sends each record as JSON in an RFC 5424 message. Messages on
+// stream connections (tcp, unix) are framed by octet counting (RFC 6587).
+type SyslogSink struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// NewSyslogSink creates a sink sending to address over network ("udp",
+// "tcp" or "unix"). The connection is opened on the first Send and reopened
+// after errors.
+func NewSyslogSink(network, address string, facility int, appName string) *SyslogSink {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{network: network, address: address, facility: facility, appName: appName, hostname: hostname}
+}
+
+// Send implements Sink
+func (s *SyslogSink) Send(ctx context.Context, records []Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, s.network, s.address)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, record := range records {
+		message, err := s.format(record)
+		if err != nil {
+			return err
+		}
+		if _, err := s.conn.Write(message); err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// format encodes record as a syslog message
+func (s *SyslogSink) format(record Record) ([]byte, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	msgID := record.Operation
+	if msgID == "" {
+		msgID = "-"
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		s.facility*8+syslogSeverity(record), record.Time.UTC().Format(time.RFC3339Nano), s.hostname, s.appName, os.Getpid(), msgID)
+	message := append([]byte(header), body...)
+	if s.network == "udp" {
+		return message, nil
+	}
+	return append([]byte(strconv.Itoa(len(message))+" "), message...), nil
+}
+
+// Close implements Sink
+func (s *SyslogSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+)
+
+// ErrTampered is returned when an audit log does not verify
+var ErrTampered = errors.New("audit log verification failed")
+
+// Report summarizes a verified audit log
+type Report struct {
+	Segments    int    // Segment files checked
+	Sealed      int    // Segments with a valid seal
+	Records     uint64 // Records checked
+	FirstSeq    uint64 // Sequence number of the first record
+	LastSeq     uint64 // Sequence number of the last record
+	FromGenesis bool   // The first record starts the log; false if older segments were removed
+	OpenRecords int    // Records of a last segment that has not been sealed yet
+	KeyID       string // Key the seals were signed with
+}
+
+// Verify checks the segments in dir against publicKey: every record hash, the
+// chain of previous hashes across all segments, and the Merkle root and
+// signature of every seal. Only the last segment may be unsealed, since the
+// proxy may still be writing it; its records are chain-checked but not yet
+// covered by a signature.
+func Verify(dir string, publicKey ed25519.PublicKey) (*Report, error) {
+	numbers, err := listSegments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit segments: %w", err)
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("no audit segments in %s", dir)
+	}
+
+	report := &Report{Segments: len(numbers), KeyID: attestation.KeyID(publicKey)}
+	var prevHash string
+	var nextSeq uint64
+	for i, number := range numbers {
+		path := filepath.Join(dir, segmentName(number))
+		name := filepath.Base(path)
+		last := i == len(numbers)-1
+
+		if i > 0 && number != numbers[i-1]+1 {
+			return report, fmt.Errorf("%w: segments %d to %d are missing", ErrTampered, numbers[i-1]+1, number-1)
+		}
+		segment, err := readSegment(path, number)
+		if err != nil {
+			return report, fmt.Errorf("%w: %v", ErrTampered, err)
+		}
+		if segment.partial() && (!last || segment.sealLine != nil) {
+			return report, fmt.Errorf("%w: %s ends in an incomplete line", ErrTampered, name)
+		}
+
+		hashes := make([]string, 0, len(segment.records))
+		for j, entry := range segment.records {
+			if i == 0 && j == 0 {
+				prevHash = entry.record.PrevHash
+				nextSeq = entry.record.Sequence
+				report.FirstSeq = nextSeq
+				report.FromGenesis = prevHash == GenesisHash && nextSeq == 1
+			}
+			if hash := hashRecord(entry.raw); hash != entry.hash {
+				return report, fmt.Errorf("%w: %s record %d does not match its hash", ErrTampered, name, entry.record.Sequence)
+			}
+			if entry.record.Sequence != nextSeq {
+				return report, fmt.Errorf("%w: %s has record %d where record %d was expected", ErrTampered, name, entry.record.Sequence, nextSeq)
+			}
+			if entry.record.PrevHash != prevHash {
+				return report, fmt.Errorf("%w: %s record %d does not link to the record before it", ErrTampered, name, entry.record.Sequence)
+			}
+			hashes = append(hashes, entry.hash)
+			prevHash = entry.hash
+			nextSeq++
+			report.Records++
+			report.LastSeq = entry.record.Sequence
+		}
+
+		if segment.sealLine == nil {
+			if !last {
+				return report, fmt.Errorf("%w: %s is not sealed", ErrTampered, name)
+			}
+			report.OpenRecords = len(hashes)
+			continue
+		}
+		if err := verifySeal(segment, hashes, publicKey); err != nil {
+			return report, fmt.Errorf("%w: %s: %v", ErrTampered, name, err)
+		}
+		report.Sealed++
+	}
+	return report, nil
+}
+
+// verifySeal checks the signature of a segment seal and that it covers
+// exactly the records of the segment
+func verifySeal(segment *segmentFile, hashes []string, publicKey ed25519.PublicKey) error {
+	sealLine := segment.sealLine
+	if sealLine.Algorithm != SignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm: %s", sealLine.Algorithm)
+	}
+	if !ed25519.Verify(publicKey, segment.sealRaw, sealLine.Signature) {
+		return fmt.Errorf("seal signature is invalid (signed by key %s)", sealLine.KeyID)
+	}
+
+	var seal Seal
+	if err := json.Unmarshal(segment.sealRaw, &seal); err != nil {
+		return fmt.Errorf("failed to decode seal: %w", err)
+	}
+	if len(hashes) == 0 {
+		return fmt.Errorf("seal without records")
+	}
+	first, last := segment.records[0].record, segment.records[len(segment.records)-1].record
+	root, err := MerkleRoot(hashes)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case seal.Segment != segment.number:
+		return fmt.Errorf("seal is for segment %d", seal.Segment)
+	case seal.Records != len(hashes) || seal.FirstSeq != first.Sequence || seal.LastSeq != last.Sequence:
+		return fmt.Errorf("seal covers records %d to %d (%d), segment holds %d to %d (%d)",
+			seal.FirstSeq, seal.LastSeq, seal.Records, first.Sequence, last.Sequence, len(hashes))
+	case seal.PrevHash != first.PrevHash || seal.LastHash != hashes[len(hashes)-1]:
+		return fmt.Errorf("seal does not match the chain of the segment")
+	case seal.MerkleRoot != root:
+		return fmt.Errorf("seal Merkle root %s does not match the records (%s)", seal.MerkleRoot, root)
+	}
+	return nil
+}
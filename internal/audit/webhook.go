@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookSink POSTs batches of records as a JSON array to a URL
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookSink creates a sink posting to url with the extra headers, e.g.
+// an Authorization header
+func NewWebhookSink(url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{url: url, headers: headers, client: &http.Client{}}
+}
+
+// Send implements Sink
+func (s *WebhookSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit records: %w", err)
+	}
+	return postJSON(ctx, s.client, s.url, "application/json", s.headers, body)
+}
+
+// Close implements Sink
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// postJSON posts body and fails unless the response is a 2xx
+func postJSON(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post audit records: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post audit records: %s answered %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package authz maps the access keys of S3 clients to the buckets, key
+// prefixes and operations they may use. The mapping is read from
+// authorization.policy_file and replaced whenever the file changes, so tenants
+// can be added or restricted while the proxy serves requests.
+package authz
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// ScopeSource provides the scopes of S3 clients. ok is false for clients the
+// source has no entry for.
+type ScopeSource interface {
+	Scopes(accessKeyID string) (scopes []config.S3ClientScope, ok bool)
+}
+
+// FilePolicy is a ScopeSource backed by a YAML policy file
+type FilePolicy struct {
+	path   string
+	logger *logrus.Entry
+
+	mu      sync.RWMutex
+	scopes  map[string][]config.S3ClientScope
+	version [sha256.Size]byte
+}
+
+// NewFilePolicy loads the policy file at path. It fails if the file cannot be
+// read or is invalid.
+func NewFilePolicy(path string, logger *logrus.Entry) (*FilePolicy, error) {
+	p := &FilePolicy{path: path, logger: logger}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Scopes returns the scopes the policy file lists for accessKeyID
+func (p *FilePolicy) Scopes(accessKeyID string) ([]config.S3ClientScope, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	scopes, ok := p.scopes[accessKeyID]
+	return scopes, ok
+}
+
+// Reload reads the policy file and replaces the policy if the file changed.
+// An unreadable or invalid file leaves the current policy in place.
+func (p *FilePolicy) Reload() (changed bool, err error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read authorization policy: %w", err)
+	}
+	version := sha256.Sum256(data)
+
+	p.mu.RLock()
+	unchanged := p.scopes != nil && version == p.version
+	p.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	policy, err := config.ParseAuthorizationPolicy(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", p.path, err)
+	}
+	scopes := make(map[string][]config.S3ClientScope, len(policy.Clients))
+	for _, client := range policy.Clients {
+		scopes[client.AccessKeyID] = client.Scopes
+	}
+
+	p.mu.Lock()
+	p.scopes, p.version = scopes, version
+	p.mu.Unlock()
+	return true, nil
+}
+
+// Run checks the policy file for changes every interval until ctx is done
+func (p *FilePolicy) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := p.Reload()
+			if err != nil {
+				p.logger.WithError(err).Error("Failed to reload authorization policy, keeping the current one")
+				continue
+			}
+			if changed {
+				p.mu.RLock()
+				clients := len(p.scopes)
+				p.mu.RUnlock()
+				p.logger.WithFields(logrus.Fields{"policy_file": p.path, "clients": clients}).Info("Reloaded authorization policy")
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package authz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestFilePolicy_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+clients:
+  - access_key_id: tenant-a
+    scopes:
+      - buckets: ["tenant-a-*"]
+        operations: ["read", "list"]
+`), 0o600))
+
+	policy, err := NewFilePolicy(path, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	scopes, ok := policy.Scopes("tenant-a")
+	require.True(t, ok)
+	assert.Equal(t, []config.S3ClientScope{{Buckets: []string{"tenant-a-*"}, Operations: []string{"read", "list"}}}, scopes)
+	_, ok = policy.Scopes("tenant-b")
+	assert.False(t, ok)
+
+	changed, err := policy.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+clients:
+  - access_key_id: tenant-b
+    scopes:
+      - buckets: ["tenant-b"]
+        prefixes: ["inbox/"]
+        operations: ["write", "multipart"]
+`), 0o600))
+	changed, err = policy.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, ok = policy.Scopes("tenant-a")
+	assert.False(t, ok)
+	scopes, ok = policy.Scopes("tenant-b")
+	require.True(t, ok)
+	assert.Equal(t, []string{"inbox/"}, scopes[0].Prefixes)
+
+	// An invalid file keeps the current policy
+	require.NoError(t, os.WriteFile(path, []byte(`
+clients:
+  - access_key_id: tenant-c
+    scopes:
+      - operations: ["admin"]
+`), 0o600))
+	_, err = policy.Reload()
+	assert.ErrorContains(t, err, "clients[0].scopes[0].operations")
+	_, ok = policy.Scopes("tenant-b")
+	assert.True(t, ok)
+}
+
+func TestNewFilePolicy_Errors(t *testing.T) {
+	_, err := NewFilePolicy(filepath.Join(t.TempDir(), "missing.yaml"), logrus.NewEntry(logrus.New()))
+	assert.ErrorContains(t, err, "failed to read authorization policy")
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+clients:
+  - access_key_id: tenant-a
+    scopes: [{buckets: ["a"]}]
+  - access_key_id: tenant-a
+    scopes: [{buckets: ["b"]}]
+`), 0o600))
+	_, err = NewFilePolicy(path, logrus.NewEntry(logrus.New()))
+	assert.ErrorContains(t, err, "duplicate access_key_id")
+}
@@ -0,0 +1,31 @@
+package canary
+
+import (
+	"context"
+	"time"
+)
+
+// AlertState is the state reported by an alert
+type AlertState string
+
+const (
+	// AlertFiring is sent once the failure threshold is reached
+	AlertFiring AlertState = "firing"
+	// AlertResolved is sent on the first success after a firing alert
+	AlertResolved AlertState = "resolved"
+)
+
+// Alert describes a canary state change
+type Alert struct {
+	State               AlertState `json:"state"`
+	Endpoint            string     `json:"endpoint"`
+	Bucket              string     `json:"bucket"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Error               string     `json:"error,omitempty"`
+	Time                time.Time  `json:"time"`
+}
+
+// Alerter delivers canary alerts
+type Alerter interface {
+	Send(ctx context.Context, alert Alert) error
+}
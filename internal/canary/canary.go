@@ -0,0 +1,249 @@
+// Package canary implements a synthetic client that periodically writes,
+// reads back and deletes a probe object through the proxy's own S3 endpoint.
+// The requests take the full client path, including signature validation,
+// encryption and the backend round-trip, so breakage shows up in the canary
+// metrics and alerts before real clients notice.
+package canary
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// Config holds canary configuration
+type Config struct {
+	Endpoint           string // Proxy URL, e.g. http://127.0.0.1:8080
+	Region             string
+	Bucket             string
+	KeyPrefix          string
+	AccessKeyID        string
+	SecretKey          string
+	Interval           time.Duration
+	Timeout            time.Duration
+	ObjectSize         int
+	FailureThreshold   int // consecutive failures before an alert is raised
+	InsecureSkipVerify bool
+}
+
+// Canary runs the probe and tracks the alert state
+type Canary struct {
+	client  *s3.Client
+	cfg     Config
+	alerter Alerter
+	logger  *logrus.Entry
+
+	mu       sync.Mutex
+	failures int  // consecutive failed runs
+	alerting bool // an alert was raised and has not recovered yet
+}
+
+// New creates a canary. alerter may be nil, in which case failures are only
+// logged and recorded in the metrics.
+func New(cfg Config, alerter Alerter, logger *logrus.Entry) *Canary {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 1
+	}
+
+	httpClient := &http.Client{}
+	if cfg.InsecureSkipVerify {
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402 - opt-in for self-signed proxy certificates
+		}
+	}
+
+	client := s3.New(s3.Options{
+		Region:       cfg.Region,
+		Credentials:  credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretKey, ""),
+		BaseEndpoint: aws.String(cfg.Endpoint),
+		UsePathStyle: true,
+		HTTPClient:   httpClient,
+		// The proxy must see the same requests as a plain client
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return &Canary{
+		client:  client,
+		cfg:     cfg,
+		alerter: alerter,
+		logger:  logger,
+	}
+}
+
+// DefaultEndpoint returns the loopback URL of a proxy listening on bindAddress
+func DefaultEndpoint(bindAddress string, tlsEnabled bool) string {
+	_, port, err := net.SplitHostPort(bindAddress)
+	if err != nil || port == "" {
+		port = "8080"
+	}
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort("127.0.0.1", port)
+}
+
+// Run probes once per interval until ctx is cancelled. The first probe runs
+// after one interval, once the proxy listener is up.
+func (c *Canary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		err := c.Probe(runCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		c.observe(ctx, err)
+	}
+}
+
+// Probe writes, reads back and deletes a probe object once. The object is
+// deleted even when the read fails.
+func (c *Canary) Probe(ctx context.Context) (err error) {
+	defer func() { monitoring.RecordCanaryRun(err == nil) }()
+
+	payload := make([]byte, c.cfg.ObjectSize)
+	if _, err := rand.Read(payload); err != nil {
+		return fmt.Errorf("failed to generate probe payload: %w", err)
+	}
+	key := c.probeKey()
+
+	err = c.step("put", func() error {
+		_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(c.cfg.Bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(payload),
+			ContentLength: aws.Int64(int64(len(payload))),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+
+	getErr := c.step("get", func() error {
+		out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(c.cfg.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = out.Body.Close() }()
+
+		body, err := io.ReadAll(out.Body)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(body, payload) {
+			return fmt.Errorf("read back %d bytes that differ from the %d bytes written", len(body), len(payload))
+		}
+		return nil
+	})
+
+	deleteErr := c.step("delete", func() error {
+		_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(c.cfg.Bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+
+	if getErr != nil {
+		getErr = fmt.Errorf("get %s: %w", key, getErr)
+	}
+	if deleteErr != nil {
+		deleteErr = fmt.Errorf("delete %s: %w", key, deleteErr)
+	}
+	return errors.Join(getErr, deleteErr)
+}
+
+// step runs fn and records its duration
+func (c *Canary) step(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	monitoring.RecordCanaryStep(name, time.Since(start), err)
+	return err
+}
+
+// probeKey returns a key unique to this instance and run, so replicas sharing
+// a bucket do not delete each other's probes
+func (c *Canary) probeKey() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return c.cfg.KeyPrefix + host + "/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// observe updates the failure count and raises or resolves the alert
+func (c *Canary) observe(ctx context.Context, err error) {
+	c.mu.Lock()
+	var alert *Alert
+	if err != nil {
+		c.failures++
+		c.logger.WithError(err).WithField("consecutive_failures", c.failures).Warn("Canary run failed")
+		if !c.alerting && c.failures >= c.cfg.FailureThreshold {
+			c.alerting = true
+			alert = &Alert{State: AlertFiring, ConsecutiveFailures: c.failures, Error: err.Error()}
+		}
+	} else {
+		c.logger.Debug("Canary run succeeded")
+		if c.alerting {
+			c.alerting = false
+			alert = &Alert{State: AlertResolved, ConsecutiveFailures: c.failures}
+		}
+		c.failures = 0
+	}
+	c.mu.Unlock()
+
+	if alert == nil {
+		return
+	}
+	alert.Bucket = c.cfg.Bucket
+	alert.Endpoint = c.cfg.Endpoint
+	alert.Time = time.Now().UTC()
+
+	log := c.logger.WithFields(logrus.Fields{
+		"state":                alert.State,
+		"consecutive_failures": alert.ConsecutiveFailures,
+	})
+	if alert.State == AlertFiring {
+		log.WithField("error", alert.Error).Error("Canary alert: requests through the proxy are failing")
+	} else {
+		log.Info("Canary alert resolved: requests through the proxy succeed again")
+	}
+
+	if c.alerter != nil {
+		if err := c.alerter.Send(context.WithoutCancel(ctx), *alert); err != nil {
+			c.logger.WithError(err).Error("Failed to deliver canary alert")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 stores objects in memory; corrupt flips the first byte on GET
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	deletes int
+	corrupt bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIACANARY/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body = append([]byte(nil), body...)
+		if f.corrupt {
+			body[0] ^= 0xff
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		f.deletes++
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestCanary(t *testing.T, backend *fakeS3, alerter Alerter) *Canary {
+	t.Helper()
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return New(Config{
+		Endpoint:         server.URL,
+		Region:           "us-east-1",
+		Bucket:           "canary",
+		KeyPrefix:        ".s3ep-canary/",
+		AccessKeyID:      "AKIACANARY",
+		SecretKey:        "secret",
+		Interval:         time.Minute,
+		Timeout:          5 * time.Second,
+		ObjectSize:       1024,
+		FailureThreshold: 2,
+	}, alerter, logger.WithField("component", "canary"))
+}
+
+func TestProbe_RoundTrip(t *testing.T) {
+	backend := &fakeS3{objects: map[string][]byte{}}
+	c := newTestCanary(t, backend, nil)
+
+	require.NoError(t, c.Probe(context.Background()))
+	assert.Empty(t, backend.objects, "probe object must be deleted")
+	assert.Equal(t, 1, backend.deletes)
+}
+
+func TestProbe_DetectsCorruptionAndCleansUp(t *testing.T) {
+	backend := &fakeS3{objects: map[string][]byte{}, corrupt: true}
+	c := newTestCanary(t, backend, nil)
+
+	err := c.Probe(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "differ")
+	assert.Empty(t, backend.objects, "probe object must be deleted after a failed read")
+}
+
+type recordingAlerter struct {
+	alerts []Alert
+}
+
+func (r *recordingAlerter) Send(_ context.Context, alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestObserve_AlertsAfterThresholdAndResolves(t *testing.T) {
+	alerter := &recordingAlerter{}
+	c := newTestCanary(t, &fakeS3{objects: map[string][]byte{}}, alerter)
+	ctx := context.Background()
+
+	c.observe(ctx, errors.New("access denied"))
+	assert.Empty(t, alerter.alerts, "a single failure is below the threshold")
+
+	c.observe(ctx, errors.New("access denied"))
+	c.observe(ctx, errors.New("access denied"))
+	require.Len(t, alerter.alerts, 1, "the alert fires once per incident")
+	assert.Equal(t, AlertFiring, alerter.alerts[0].State)
+	assert.Equal(t, 2, alerter.alerts[0].ConsecutiveFailures)
+	assert.Equal(t, "access denied", alerter.alerts[0].Error)
+
+	c.observe(ctx, nil)
+	c.observe(ctx, nil)
+	require.Len(t, alerter.alerts, 2)
+	assert.Equal(t, AlertResolved, alerter.alerts[1].State)
+	assert.Equal(t, 3, alerter.alerts[1].ConsecutiveFailures)
+}
+
+func TestDefaultEndpoint(t *testing.T) {
+	assert.Equal(t, "http://127.0.0.1:8080", DefaultEndpoint(":8080", false))
+	assert.Equal(t, "https://127.0.0.1:8443", DefaultEndpoint("0.0.0.0:8443", true))
+	assert.Equal(t, "http://127.0.0.1:8080", DefaultEndpoint("", false))
+}
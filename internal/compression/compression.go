@@ -0,0 +1,205 @@
+// Package compression compresses object data before it is encrypted.
+// Ciphertext does not compress, so this is the only point where the proxy can
+// save storage for text and log data. The codec an object was compressed with
+// is recorded in its metadata and undone after decryption on GET.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs recorded in the compression metadata
+const (
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+// MetadataKey (with the metadata prefix) names the codec of a compressed
+// object. Objects without it are stored uncompressed.
+const MetadataKey = "compression"
+
+// Levels accepted by New for each codec; 0 selects the default of the codec
+const (
+	MaxGzipLevel = gzip.BestCompression
+	MaxZstdLevel = 22
+)
+
+// streamChunkSize is how much of the source a streaming compressor reads at a
+// time
+const streamChunkSize = 64 * 1024
+
+// Compressor compresses objects of at least minSize bytes with one codec
+type Compressor struct {
+	codec   string
+	level   int
+	minSize int64
+}
+
+// New creates a Compressor. An empty codec selects gzip, level 0 the default
+// level of the codec. zstd levels follow the zstd command line tool and are
+// mapped onto the speeds the encoder implements.
+func New(codec string, level int, minSize int64) (*Compressor, error) {
+	if codec == "" {
+		codec = CodecGzip
+	}
+	switch codec {
+	case CodecGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > MaxGzipLevel {
+			return nil, fmt.Errorf("invalid gzip level %d: must be between %d and %d", level, gzip.BestSpeed, MaxGzipLevel)
+		}
+	case CodecZstd:
+		if level < 0 || level > MaxZstdLevel {
+			return nil, fmt.Errorf("invalid zstd level %d: must be between 1 and %d", level, MaxZstdLevel)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+	}
+	return &Compressor{codec: codec, level: level, minSize: minSize}, nil
+}
+
+// Codec returns the codec recorded for objects this Compressor compressed
+func (c *Compressor) Codec() string {
+	return c.codec
+}
+
+// Compresses reports whether objects of size bytes are compressed: those of
+// at least the minimum size, and those of unknown size (negative)
+func (c *Compressor) Compresses(size int64) bool {
+	return size < 0 || size >= c.minSize
+}
+
+// Compress compresses data. ok is false, and data should be stored as is, if
+// data is smaller than the minimum size or does not get smaller.
+func (c *Compressor) Compress(data []byte) (compressed []byte, ok bool, err error) {
+	if int64(len(data)) < c.minSize {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 2)
+	writer, err := c.newWriter(&buf)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, false, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if buf.Len() >= len(data) {
+		return nil, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// CompressReader returns a reader of src compressed. Unlike Compress it does
+// not hold the data in memory, so it cannot tell whether the data gets
+// smaller: it is compressed whatever its size.
+func (c *Compressor) CompressReader(src io.Reader) (io.Reader, error) {
+	r := &compressingReader{src: src, chunk: make([]byte, streamChunkSize)}
+	writer, err := c.newWriter(&r.out)
+	if err != nil {
+		return nil, err
+	}
+	r.writer = writer
+	return r, nil
+}
+
+// newWriter returns a writer compressing into w
+func (c *Compressor) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c.codec {
+	case CodecZstd:
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if c.level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level)))
+		}
+		return zstd.NewWriter(w, options...)
+	default:
+		return gzip.NewWriterLevel(w, c.level)
+	}
+}
+
+// compressingReader compresses its source as it is read, one chunk at a time
+type compressingReader struct {
+	src    io.Reader
+	writer io.WriteCloser
+	chunk  []byte
+	out    bytes.Buffer
+	err    error // returned once out is drained; io.EOF after the last chunk
+}
+
+func (r *compressingReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.compressNext()
+	}
+	return r.out.Read(p)
+}
+
+// compressNext compresses the next chunk of the source into out, and
+// finishes the stream at its end
+func (r *compressingReader) compressNext() error {
+	n, err := r.src.Read(r.chunk)
+	if n > 0 {
+		if _, werr := r.writer.Write(r.chunk[:n]); werr != nil {
+			return fmt.Errorf("failed to compress data: %w", werr)
+		}
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		if err := r.writer.Close(); err != nil {
+			return fmt.Errorf("failed to compress data: %w", err)
+		}
+		return io.EOF
+	case err != nil:
+		return err
+	}
+	return nil
+}
+
+// NewReader returns a reader of the decompressed data of an object compressed
+// with codec. Closing it closes r.
+func NewReader(codec string, r io.ReadCloser) (io.ReadCloser, error) {
+	switch codec {
+	case CodecGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		gz.Multistream(false)
+		return &reader{ReadCloser: gz, source: r}, nil
+	case CodecZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		return &reader{ReadCloser: decoder.IOReadCloser(), source: r}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+	}
+}
+
+// reader closes the compressed source along with the decompressor
+type reader struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (r *reader) Close() error {
+	err := r.ReadCloser.Close()
+	if sourceErr := r.source.Close(); err == nil {
+		err = sourceErr
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package compression
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressor_RoundTrip(t *testing.T) {
+	for _, codec := range []string{CodecGzip, CodecZstd} {
+		t.Run(codec, func(t *testing.T) {
+			compressor, err := New(codec, 0, 1024)
+			require.NoError(t, err)
+			assert.Equal(t, codec, compressor.Codec())
+
+			data := bytes.Repeat([]byte("2026-10-16T12:00:00Z INFO request served\n"), 1000)
+			compressed, ok, err := compressor.Compress(data)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Less(t, len(compressed), len(data)/10)
+
+			reader, err := NewReader(codec, io.NopCloser(bytes.NewReader(compressed)))
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func TestCompressor_CompressReader(t *testing.T) {
+	for _, codec := range []string{CodecGzip, CodecZstd} {
+		t.Run(codec, func(t *testing.T) {
+			compressor, err := New(codec, 0, 1024)
+			require.NoError(t, err)
+
+			// Larger than the chunks the source is read in
+			data := bytes.Repeat([]byte("2026-10-16T12:00:00Z INFO request served\n"), 10000)
+			compressed, err := compressor.CompressReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			stored, err := io.ReadAll(compressed)
+			require.NoError(t, err)
+			assert.Less(t, len(stored), len(data)/10)
+
+			reader, err := NewReader(codec, io.NopCloser(bytes.NewReader(stored)))
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, decompressed))
+
+			// Empty sources still produce a stream that decompresses
+			compressed, err = compressor.CompressReader(bytes.NewReader(nil))
+			require.NoError(t, err)
+			stored, err = io.ReadAll(compressed)
+			require.NoError(t, err)
+			reader, err = NewReader(codec, io.NopCloser(bytes.NewReader(stored)))
+			require.NoError(t, err)
+			decompressed, err = io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Empty(t, decompressed)
+		})
+	}
+}
+
+func TestCompressor_CompressReaderReturnsSourceErrors(t *testing.T) {
+	compressor, err := New(CodecZstd, 0, 0)
+	require.NoError(t, err)
+	compressed, err := compressor.CompressReader(iotest.ErrReader(errors.New("connection reset")))
+	require.NoError(t, err)
+	_, err = io.ReadAll(compressed)
+	assert.ErrorContains(t, err, "connection reset")
+}
+
+func TestCompressor_Skips(t *testing.T) {
+	compressor, err := New(CodecGzip, 9, 1024)
+	require.NoError(t, err)
+
+	// Below the minimum size
+	_, ok, err := compressor.Compress(bytes.Repeat([]byte("a"), 1023))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Random data does not get smaller
+	random := make([]byte, 64*1024)
+	_, _ = rand.Read(random)
+	_, ok, err = compressor.Compress(random)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCompression_Errors(t *testing.T) {
+	_, err := New("lz4", 0, 0)
+	assert.ErrorContains(t, err, "unsupported compression codec")
+	_, err = New(CodecGzip, 10, 0)
+	assert.ErrorContains(t, err, "invalid gzip level")
+	_, err = New(CodecZstd, 23, 0)
+	assert.ErrorContains(t, err, "invalid zstd level")
+
+	_, err = NewReader("lz4", io.NopCloser(bytes.NewReader(nil)))
+	assert.ErrorContains(t, err, "unsupported compression codec")
+	_, err = NewReader(CodecGzip, io.NopCloser(bytes.NewReader([]byte("not gzip"))))
+	assert.ErrorContains(t, err, "gzip header")
+	reader, err := NewReader(CodecZstd, io.NopCloser(bytes.NewReader([]byte("not zstd"))))
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+
+	// A truncated stream fails instead of returning partial data silently
+	compressor, err := New(CodecGzip, 0, 0)
+	require.NoError(t, err)
+	compressed, ok, err := compressor.Compress(bytes.Repeat([]byte("log line\n"), 1000))
+	require.NoError(t, err)
+	require.True(t, ok)
+	reader, err = NewReader(CodecGzip, io.NopCloser(bytes.NewReader(compressed[:len(compressed)-4])))
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
@@ -1,10 +1,23 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
+	"net/url"
 	"os"
-
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/accesslog"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/notification"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
 	"github.com/spf13/viper"
 )
 
@@ -26,6 +39,153 @@ const (
 	HMACVerificationHybrid = "hybrid"
 )
 
+// SSE-C mode constants: how requests with customer-provided keys
+// (x-amz-server-side-encryption-customer-*) are handled
+const (
+	// SSECModeReject - Reject SSE-C requests with 400 InvalidRequest
+	SSECModeReject = "reject"
+
+	// SSECModePassthrough - Forward SSE-C requests untouched. The backend
+	// encrypts these objects with the customer key, the proxy does not.
+	SSECModePassthrough = "passthrough"
+)
+
+// ETag mode constants: which ETag responses report for encrypted objects
+const (
+	// ETagModeBackend - report the backend ETag, computed over the ciphertext
+	ETagModeBackend = "backend"
+
+	// ETagModePlaintext - report the MD5 of the plaintext, recorded in the
+	// object metadata at upload, like S3 does for unencrypted objects
+	ETagModePlaintext = "plaintext"
+)
+
+// SSE header emulation algorithm constants, the values reported in
+// x-amz-server-side-encryption
+const (
+	// SSEHeaderAlgorithmKMS - report SSE-KMS with the configured key id
+	SSEHeaderAlgorithmKMS = "aws:kms"
+
+	// SSEHeaderAlgorithmAES256 - report SSE-S3
+	SSEHeaderAlgorithmAES256 = "AES256"
+)
+
+// Integrity MAC algorithm constants. The algorithm is recorded in the object
+// metadata, so objects written with different algorithms verify side by side.
+const (
+	// IntegrityAlgorithmHMACSHA256 - HMAC-SHA256, the default and the algorithm
+	// of objects that do not record one
+	IntegrityAlgorithmHMACSHA256 = "hmac-sha256"
+
+	// IntegrityAlgorithmHMACSHA512 - HMAC-SHA512, for deployments that mandate SHA-512
+	IntegrityAlgorithmHMACSHA512 = "hmac-sha512"
+
+	// IntegrityAlgorithmBLAKE3 - keyed BLAKE3, several times faster on large objects
+	IntegrityAlgorithmBLAKE3 = "blake3-keyed"
+)
+
+// Multipart metadata phase constants (where s3ep metadata is attached to multipart objects)
+const (
+	// MultipartMetadataPhaseCopy re-applies metadata after CompleteMultipartUpload via
+	// a CopyObject self-copy with MetadataDirective=REPLACE.
+	MultipartMetadataPhaseCopy = "copy"
+
+	// MultipartMetadataPhaseComplete sends metadata as x-amz-meta-* headers with
+	// CompleteMultipartUpload, for backends that only accept it at that point. The
+	// result is verified and re-applied via CopyObject if the backend dropped it.
+	MultipartMetadataPhaseComplete = "complete"
+)
+
+// Stale multipart session policy constants (what happens when a client retries
+// CreateMultipartUpload for a bucket/key it already has an upload in progress for)
+const (
+	// StaleSessionPolicyKeep leaves the previous session alone until it expires
+	StaleSessionPolicyKeep = "keep"
+
+	// StaleSessionPolicyAbort aborts the previous upload on the backend and
+	// discards its session before the new upload is created.
+	StaleSessionPolicyAbort = "abort"
+
+	// StaleSessionPolicyReuse returns the previous upload ID if no part has been
+	// uploaded to it yet, and otherwise behaves like StaleSessionPolicyAbort.
+	StaleSessionPolicyReuse = "reuse"
+)
+
+// Policies for orphaned multipart uploads found by the reconciliation job:
+// backend uploads without an encryption session and sessions without a
+// backend upload
+const (
+	// ReconcilePolicyKeep only logs and counts orphans
+	ReconcilePolicyKeep = "keep"
+
+	// ReconcilePolicyAbort aborts orphaned backend uploads and deletes
+	// orphaned sessions
+	ReconcilePolicyAbort = "abort"
+
+	// ReconcilePolicyAdopt gives orphaned backend uploads without parts a new
+	// encryption session, so the client can still upload them, and otherwise
+	// behaves like ReconcilePolicyAbort
+	ReconcilePolicyAdopt = "adopt"
+)
+
+// Multipart session store backends
+const (
+	// SessionStoreMemory keeps sessions in the memory of the proxy process
+	SessionStoreMemory = "memory"
+
+	// SessionStoreRedis keeps sessions in Redis, shared by all replicas
+	SessionStoreRedis = "redis"
+
+	// SessionStoreEtcd keeps sessions in etcd, shared by all replicas
+	SessionStoreEtcd = "etcd"
+)
+
+// Object key canonicalization settings (see KeyCanonicalizationConfig)
+const (
+	// KeySlashesPreserve keeps repeated and leading slashes, like S3
+	KeySlashesPreserve = "preserve"
+
+	// KeySlashesCollapse folds runs of slashes into one and drops a leading slash
+	KeySlashesCollapse = "collapse"
+
+	// KeyDotSegmentsPreserve keeps "." and ".." segments as part of the key, like S3
+	KeyDotSegmentsPreserve = "preserve"
+
+	// KeyDotSegmentsRemove drops "." segments and resolves ".." against the
+	// previous segment. A ".." above the bucket is rejected.
+	KeyDotSegmentsRemove = "remove"
+
+	// KeyDotSegmentsReject rejects keys with "." or ".." segments
+	KeyDotSegmentsReject = "reject"
+
+	// KeyEncodedSlashesDecode treats %2F as a slash, like S3
+	KeyEncodedSlashesDecode = "decode"
+
+	// KeyEncodedSlashesReject rejects paths with %2F
+	KeyEncodedSlashesReject = "reject"
+)
+
+// DefaultBackendRouteName names the s3_backend endpoint in backend routing
+// logs and metrics
+const DefaultBackendRouteName = "default"
+
+// BackendProfile describes the quirks of an S3 backend the proxy has to work around
+type BackendProfile struct {
+	Name                    string
+	MultipartMetadataPhase  string // MultipartMetadataPhaseCopy or MultipartMetadataPhaseComplete
+	VerifyMultipartMetadata bool   // HEAD the completed object and fail if encryption metadata is missing
+}
+
+// backendProfiles lists the known compatibility profiles. Unknown backends use
+// "generic". Verifying that metadata landed on the object costs a HEAD per
+// upload, so copy-phase profiles only do it with verify_multipart_metadata.
+var backendProfiles = map[string]BackendProfile{
+	"generic": {Name: "generic", MultipartMetadataPhase: MultipartMetadataPhaseCopy},
+	"aws":     {Name: "aws", MultipartMetadataPhase: MultipartMetadataPhaseCopy},
+	"minio":   {Name: "minio", MultipartMetadataPhase: MultipartMetadataPhaseCopy},
+	"ceph":    {Name: "ceph", MultipartMetadataPhase: MultipartMetadataPhaseCopy},
+}
+
 // TLSConfig holds TLS configuration
 type TLSConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
@@ -33,6 +193,27 @@ type TLSConfig struct {
 	KeyFile  string `mapstructure:"key_file"`
 }
 
+// ListenerConfig holds request limits and hardening settings for the
+// client-facing listener
+type ListenerConfig struct {
+	MaxHeaderBytes    int      `mapstructure:"max_header_bytes"`    // Maximum size of request headers (default: 64KB)
+	ReadHeaderTimeout int      `mapstructure:"read_header_timeout"` // Seconds to receive request headers, guards against slowloris (default: 10)
+	MaxQueryParams    int      `mapstructure:"max_query_params"`    // Maximum number of query parameters (default: 100)
+	MaxContentLength  int64    `mapstructure:"max_content_length"`  // Largest accepted Content-Length (default: 5TB, the S3 object size limit)
+	AllowedHosts      []string `mapstructure:"allowed_hosts"`       // Accepted Host headers incl. subdomains for virtual-hosted style; empty = any
+	HSTSMaxAge        int      `mapstructure:"hsts_max_age"`        // Strict-Transport-Security max-age in seconds, only sent with TLS (default: 0 = off)
+}
+
+// KeyCanonicalizationConfig defines how the object key of a request path is
+// canonicalized before routing, so an object is always stored and read under
+// the same key. The defaults treat keys like S3 does: byte for byte, with
+// percent-encoding decoded once.
+type KeyCanonicalizationConfig struct {
+	Slashes        string `mapstructure:"slashes"`         // "preserve" or "collapse" (default: preserve)
+	DotSegments    string `mapstructure:"dot_segments"`    // "preserve", "remove" or "reject" (default: preserve)
+	EncodedSlashes string `mapstructure:"encoded_slashes"` // "decode" or "reject" (default: decode)
+}
+
 // S3BackendConfig holds S3 backend configuration
 type S3BackendConfig struct {
 	TargetEndpoint     string `mapstructure:"target_endpoint"`
@@ -41,14 +222,121 @@ type S3BackendConfig struct {
 	SecretKey          string `mapstructure:"secret_key"`
 	UseTLS             bool   `mapstructure:"use_tls"`
 	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Only for development/testing
+
+	// Backend compatibility
+	CompatibilityProfile   string `mapstructure:"compatibility_profile"`    // generic (default), aws, minio, ceph
+	MultipartMetadataPhase string `mapstructure:"multipart_metadata_phase"` // Override the profile: copy or complete
+	// HEAD every completed multipart object after the metadata self-copy (default: false)
+	VerifyMultipartMetadata bool `mapstructure:"verify_multipart_metadata"`
+
+	// Read replicas of the backend above. Writes always go to the backend above.
+	Replicas                   []BackendReplica `mapstructure:"replicas"`
+	ReadPreference             string           `mapstructure:"read_preference"`               // primary (default): replicas serve reads the backend fails; nearest: reads go to the healthy endpoint with the lowest latency
+	ReplicaHealthCheckInterval int              `mapstructure:"replica_health_check_interval"` // Seconds between health checks of the backend and its replicas (default: 15)
+
+	// Bucket-to-backend routing. Buckets not matched by any route use the backend above.
+	Routes                   []BackendRoute `mapstructure:"routes"`
+	RouteHealthCheckInterval int            `mapstructure:"route_health_check_interval"` // Seconds between route health checks (default: 30, 0 = off)
+
+	// Server access logging enrichment, so log pipelines can tell proxy traffic from direct backend access
+	AccessLogging AccessLoggingConfig `mapstructure:"access_logging"`
+
+	// Sanity checks on object bodies read from the backend
+	ResponseValidation ResponseValidationConfig `mapstructure:"response_validation"`
+}
+
+// ResponseValidationConfig checks GET object bodies received from the backend
+// before they are decrypted or passed on
+type ResponseValidationConfig struct {
+	Enabled    bool `mapstructure:"enabled"`     // Compare received bytes with Content-Length and resume broken reads (default: true)
+	MaxRetries int  `mapstructure:"max_retries"` // Ranged re-reads per GET after a truncated or failed read (default: 2)
+	VerifyETag bool `mapstructure:"verify_etag"` // Compare the MD5 of single-part bodies with their ETag (default: false)
+}
+
+// AccessLoggingConfig marks proxy traffic in the backend's server access logs
+type AccessLoggingConfig struct {
+	// TargetPrefix is prepended to the TargetPrefix of bucket logging
+	// configurations set through the proxy and stripped again on GET
+	TargetPrefix string `mapstructure:"target_prefix"`
+	// UserAgentTag is appended to the User-Agent of every backend request
+	UserAgentTag string `mapstructure:"user_agent_tag"`
+}
+
+// BackendRoute sends requests for a set of buckets to a separate backend
+// endpoint with its own credentials
+type BackendRoute struct {
+	Name               string   `mapstructure:"name"`                 // Route name, used in logs and metrics
+	Buckets            []string `mapstructure:"buckets"`              // Bucket names; a trailing "*" matches a name prefix
+	TargetEndpoint     string   `mapstructure:"target_endpoint"`      // Backend endpoint of this route
+	Region             string   `mapstructure:"region"`               // Region (default: the s3_backend region)
+	AccessKeyID        string   `mapstructure:"access_key_id"`        // Credentials for this backend
+	SecretKey          string   `mapstructure:"secret_key"`           // Credentials for this backend
+	InsecureSkipVerify bool     `mapstructure:"insecure_skip_verify"` // Only for development/testing
+	HealthCheckBucket  string   `mapstructure:"health_check_bucket"`  // Bucket probed with HeadBucket; empty = ListBuckets
+}
+
+// BackendReplica is a replica of the s3_backend endpoint holding the same
+// buckets, e.g. through bucket or site replication
+type BackendReplica struct {
+	Name               string `mapstructure:"name"`                 // Replica name, used in logs and metrics
+	TargetEndpoint     string `mapstructure:"target_endpoint"`      // Backend endpoint of this replica
+	Region             string `mapstructure:"region"`               // Region (default: the s3_backend region)
+	AccessKeyID        string `mapstructure:"access_key_id"`        // Credentials (default: the s3_backend credentials)
+	SecretKey          string `mapstructure:"secret_key"`           // Credentials (default: the s3_backend credentials)
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Only for development/testing
+	HealthCheckBucket  string `mapstructure:"health_check_bucket"`  // Bucket probed with HeadBucket; empty = ListBuckets
 }
 
+// Read preferences of s3_backend.read_preference
+const (
+	// ReadPreferencePrimary reads from the backend and fails over to replicas
+	ReadPreferencePrimary = "primary"
+	// ReadPreferenceNearest reads from the healthy endpoint with the lowest
+	// health check latency
+	ReadPreferenceNearest = "nearest"
+)
+
 // EncryptionProvider holds configuration for a single encryption provider
 type EncryptionProvider struct {
 	Alias       string                 `mapstructure:"alias"`       // Unique identifier for this provider
 	Type        string                 `mapstructure:"type"`        // "tink" or "aes-gcm"
 	Description string                 `mapstructure:"description"` // Optional description for this provider
 	Config      map[string]interface{} `mapstructure:",remain"`     // Provider-specific configuration parameters
+
+	// Integrity MAC for objects written while this provider is active; empty
+	// uses encryption.integrity_algorithm
+	IntegrityAlgorithm string `mapstructure:"integrity_algorithm"`
+
+	// Data cipher for objects written while this provider is active: "aes"
+	// (default: aes-gcm and aes-ctr), "chacha20" (chacha20-poly1305 and
+	// xchacha20), "aes-gcm-siv" (aes-gcm-siv and aes-ctr) or "auto" (chacha20
+	// on CPUs without AES instructions)
+	DataCipher string `mapstructure:"data_cipher"`
+
+	// Client-side limit on DEK wrap/unwrap calls, so that write bursts stay
+	// below the request quota of a remote KMS instead of failing with
+	// throttling errors. Concurrent unwraps of the same DEK share one call.
+	RateLimit    float64 `mapstructure:"rate_limit"`    // Key operations per second (default: 0 = unlimited)
+	RateBurst    int     `mapstructure:"rate_burst"`    // Operations allowed at once on top of the rate (default: rate_limit rounded up)
+	MaxQueue     int     `mapstructure:"max_queue"`     // Operations waiting for the limit before new ones are rejected (default: 1000)
+	QueueTimeout int     `mapstructure:"queue_timeout"` // Seconds an operation may wait for the limit (default: 5)
+}
+
+// IntegrityAlgorithmOverride selects the integrity MAC for objects written to
+// matching buckets
+type IntegrityAlgorithmOverride struct {
+	Buckets   []string `mapstructure:"buckets"`   // Bucket names; a trailing "*" matches a name prefix
+	Algorithm string   `mapstructure:"algorithm"` // hmac-sha256, hmac-sha512 or blake3-keyed
+}
+
+// TenantConfig assigns a tenant, identified by the access keys of its S3
+// clients or by the buckets it owns, a dedicated provider. Requests match a
+// tenant by access key first, then by bucket.
+type TenantConfig struct {
+	Name       string   `mapstructure:"name"`        // Tenant name used in logs and metrics
+	Provider   string   `mapstructure:"provider"`    // Alias of the provider reserved for the tenant
+	AccessKeys []string `mapstructure:"access_keys"` // Access key IDs of s3_clients belonging to the tenant
+	Buckets    []string `mapstructure:"buckets"`     // Bucket names; a trailing "*" matches a name prefix
 }
 
 // EncryptionConfig holds encryption configuration with multiple providers
@@ -62,12 +350,146 @@ type EncryptionConfig struct {
 	// - any value: use that value as prefix
 	MetadataKeyPrefix *string `mapstructure:"metadata_key_prefix"`
 
+	// Prefixes encryption metadata was written with before metadata_key_prefix
+	// was changed. Objects whose metadata carries one of them are read as if
+	// it carried the current prefix.
+	LegacyMetadataKeyPrefixes []string `mapstructure:"legacy_metadata_key_prefixes"`
+
+	// Rewrite the metadata of objects stored under a legacy prefix to
+	// metadata_key_prefix: "off" or "on_read", a metadata-only self-copy after
+	// they are read (default: off). Like plaintext_size_backfill, the copy
+	// updates Last-Modified and resets object ACLs; objects in versioned
+	// buckets are left alone. s3ep-rekey --from legacy-prefix rewrites a
+	// whole bucket.
+	MetadataPrefixMigration string `mapstructure:"metadata_prefix_migration"`
+
 	// List of available encryption providers (used for reading/decrypting files)
 	Providers []EncryptionProvider `mapstructure:"providers"`
 
 	// HMAC verification mode for integrity checking of encrypted data
 	// Options: "off", "lax", "strict", "hybrid" (default: "off")
 	IntegrityVerification string `mapstructure:"integrity_verification"`
+
+	// Integrity MAC used for new objects: "hmac-sha256", "hmac-sha512" or
+	// "blake3-keyed" (default: "hmac-sha256"). A bucket override takes
+	// precedence over the integrity_algorithm of the active provider, which
+	// takes precedence over this value. Reads always use the recorded algorithm.
+	IntegrityAlgorithm          string                       `mapstructure:"integrity_algorithm"`
+	IntegrityAlgorithmOverrides []IntegrityAlgorithmOverride `mapstructure:"integrity_algorithm_overrides"`
+
+	// Record the plaintext size of legacy AES-GCM objects on their first full
+	// GET via a metadata-only self-copy, for tools that read the metadata
+	// directly; HEAD derives it without the copy. The copy updates
+	// Last-Modified and resets object ACLs.
+	// Listings report the plaintext size with list_plaintext_sizes.
+	PlaintextSizeBackfill bool `mapstructure:"plaintext_size_backfill"` // default: false
+
+	// Reject GETs of objects stored with an x-s3ep-encryption-context unless
+	// the request presents the same context. A context presented by the
+	// client is always checked.
+	StrictEncryptionContext bool `mapstructure:"strict_encryption_context"` // default: false
+
+	// age X25519 recipients ("age1...") every new DEK is additionally wrapped
+	// for, next to the active provider. The matching offline identities can
+	// decrypt the data even if the provider key is lost. The recovery copy
+	// adds about 200 bytes plus 130 bytes per recipient of metadata per object.
+	RecoveryRecipients []string `mapstructure:"recovery_recipients"`
+
+	// Handling of SSE-C requests: "reject" or "passthrough" (default:
+	// "reject"). Passthrough stores single-part uploads without proxy
+	// encryption and marks them in metadata; multipart uploads and copies
+	// with SSE-C are still rejected.
+	SSECMode string `mapstructure:"sse_c_mode"`
+
+	// Provider aliases clients may select for a new object with the
+	// x-s3ep-encryption-provider header on PutObject and
+	// CreateMultipartUpload, instead of encryption_method_alias. The active
+	// provider can always be selected. DEKs wrapped by a selectable provider
+	// are not re-wrapped by rotation jobs. (default: none)
+	SelectableProviders []string `mapstructure:"selectable_providers"`
+
+	// Tenants with a dedicated provider. The provider of a tenant wraps the
+	// DEKs of all objects its requests write and unwraps DEKs only for its
+	// requests, so no other client can read the tenant's objects through the
+	// proxy. DEKs of tenant providers are not re-wrapped by rotation jobs.
+	// (default: none)
+	Tenants []TenantConfig `mapstructure:"tenants"`
+
+	// Server-side encryption headers reported for objects the proxy encrypted
+	SSEHeaders SSEHeaderEmulationConfig `mapstructure:"sse_headers"`
+
+	// ETag reported for encrypted objects on PUT, GET, HEAD and listings:
+	// "backend" or "plaintext" (default: "backend"). Plaintext mode records
+	// the MD5 of single PUTs, so clients can compare it with a local MD5;
+	// objects without a recorded MD5 keep the backend ETag. Listings HEAD
+	// every listed object in plaintext mode.
+	ETagMode string `mapstructure:"etag_mode"`
+
+	// Report plaintext sizes in ListObjects and ListObjectsV2, as HEAD does.
+	// Listings carry no metadata, so every listed object is HEADed; with
+	// etag_mode "plaintext" the same HEAD also supplies the ETag.
+	ListPlaintextSizes bool `mapstructure:"list_plaintext_sizes"` // default: false
+
+	// Leave the objects the proxy writes for itself, the canary probes below
+	// canary.key_prefix, out of listings. KeyCount shrinks accordingly, so a
+	// page can hold fewer than max-keys entries.
+	HideInternalKeys bool `mapstructure:"hide_internal_keys"` // default: false
+
+	// Encryption metadata kept in a companion object instead of the object's
+	// user metadata, for backends with tight metadata size limits
+	MetadataSidecar MetadataSidecarConfig `mapstructure:"metadata_sidecar"`
+
+	// User metadata limits of the backend, enforced before writes reach it
+	MetadataLimits MetadataLimitsConfig `mapstructure:"metadata_limits"`
+}
+
+// MetadataLimitsConfig describes how much user metadata the backend accepts.
+// Encryption metadata values longer than MaxValueSize, such as DEKs wrapped
+// with large RSA keys, are split across numbered keys and joined again on GET
+// and HEAD. Writes whose metadata, after any sidecar and splitting, still
+// exceeds MaxSize are refused with MetadataTooLarge instead of being sent.
+type MetadataLimitsConfig struct {
+	MaxSize      int `mapstructure:"max_size"`       // Bytes of user metadata, keys and values, per object; 0 = not checked (default: 0, AWS S3: 2048)
+	MaxValueSize int `mapstructure:"max_value_size"` // Longest encryption metadata value stored in one key; 0 = not split (default: 0)
+}
+
+// minMetadataValueSize is the smallest max_value_size that leaves room for
+// more than the chunk numbering in each key
+const minMetadataValueSize = 64
+
+// MetadataSidecarConfig moves the encryption metadata of new objects, the
+// wrapped DEK, HMAC, recovery copies and the like, into a sidecar object
+// written next to the object as <key>.s3ep-meta, or into an index bucket. The
+// object keeps a marker pointing to its sidecar, which is read back on GET and
+// HEAD. Objects written with a sidecar stay readable in every mode.
+type MetadataSidecarConfig struct {
+	Mode        string `mapstructure:"mode"`         // off, always or oversize (default: off)
+	InlineLimit int    `mapstructure:"inline_limit"` // oversize: bytes of user metadata, keys and values, kept on the object (default: 2048)
+	IndexBucket string `mapstructure:"index_bucket"` // Bucket of all sidecars, keyed <bucket>/<key>.s3ep-meta; empty = next to the object
+}
+
+// Metadata prefix migration modes
+const (
+	MetadataPrefixMigrationOff    = "off"
+	MetadataPrefixMigrationOnRead = "on_read"
+)
+
+// Metadata sidecar modes
+const (
+	MetadataSidecarModeOff      = "off"
+	MetadataSidecarModeAlways   = "always"
+	MetadataSidecarModeOversize = "oversize"
+)
+
+// SSEHeaderEmulationConfig makes PUT, GET and HEAD responses for objects the
+// proxy encrypted carry x-amz-server-side-encryption headers, as if the
+// backend had encrypted them. Compliance scanners and SDKs that expect
+// encrypted objects then accept proxy-encrypted ones. The headers describe no
+// real backend encryption; the key id is virtual and not sent to the backend.
+type SSEHeaderEmulationConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`    // Report the headers (default: false)
+	Algorithm string `mapstructure:"algorithm"`  // "aws:kms" or "AES256" (default: "aws:kms")
+	KMSKeyID  string `mapstructure:"kms_key_id"` // Key id reported with aws:kms, required for it
 }
 
 // S3ClientCredentials holds credentials for a single S3 client
@@ -76,6 +498,49 @@ type S3ClientCredentials struct {
 	AccessKeyID string `mapstructure:"access_key_id"` // S3 Access Key ID
 	SecretKey   string `mapstructure:"secret_key"`    // S3 Secret Access Key
 	Description string `mapstructure:"description"`   // Optional description for this client
+
+	// Requests this client may make. Without scopes the client may make any
+	// request; with scopes a request needs a matching scope. Scopes in
+	// authorization.policy_file replace these.
+	Scopes []S3ClientScope `mapstructure:"scopes"`
+}
+
+// Operation classes of S3 client scopes
+const (
+	ScopeOperationRead   = "read"   // GetObject, HeadObject, SelectObjectContent, the source of a copy
+	ScopeOperationWrite  = "write"  // PutObject, CopyObject, object ACLs and tags; implies multipart
+	ScopeOperationDelete = "delete" // DeleteObject, DeleteObjects
+	ScopeOperationList   = "list"   // ListBuckets, ListObjects, ListMultipartUploads
+	ScopeOperationBucket = "bucket" // Creating and deleting buckets and changing their configuration
+
+	// Creating, uploading parts of, completing, aborting and listing the
+	// parts of multipart uploads
+	ScopeOperationMultipart = "multipart"
+)
+
+// S3ClientScope allows a set of operations on objects in a set of buckets.
+// Empty fields match everything.
+type S3ClientScope struct {
+	Buckets    []string `mapstructure:"buckets"`    // Bucket names; a trailing "*" matches a name prefix
+	Prefixes   []string `mapstructure:"prefixes"`   // Object key prefixes; a scope with prefixes allows no bucket-level requests
+	Operations []string `mapstructure:"operations"` // Operation classes: read, write, delete, list, bucket
+}
+
+// AuthorizationConfig loads the scopes of S3 clients from a policy file
+// instead of s3_clients, so tenants can be added and restricted without a
+// restart. Clients the file does not list keep their s3_clients scopes; a
+// client without scopes in either place is denied every request.
+type AuthorizationConfig struct {
+	PolicyFile     string `mapstructure:"policy_file"`     // YAML file of client scopes (default: "" = s3_clients scopes only)
+	ReloadInterval int    `mapstructure:"reload_interval"` // Seconds between checks of the file for changes (default: 10)
+}
+
+// ConfigReloadConfig controls reloads of the configuration file, which are
+// also triggered by SIGHUP and the admin API. A reload applies changes of
+// log_level, rate_limiting, bucket_policies and added encryption providers,
+// and is rejected as a whole if any other setting changed.
+type ConfigReloadConfig struct {
+	WatchInterval int `mapstructure:"watch_interval"` // Seconds between checks of the file for changes (default: 0 = reload on SIGHUP only)
 }
 
 // S3SecurityConfig holds S3 client authentication security configuration
@@ -134,12 +599,525 @@ type OptimizationsConfig struct {
 	// after each part has been encrypted in order. Encryption stays sequential
 	// (CTR streams require it); only the S3 network round-trip is parallelised.
 	MultipartUploadConcurrency int `mapstructure:"multipart_upload_concurrency" validate:"min=1,max=32"` // 1-32, default: 4
+
+	// Automatic Multipart Conversion
+	// Single PUTs whose plaintext size reaches the threshold are uploaded to the
+	// backend as a multipart upload, streaming parts as the body arrives. Each
+	// part is retried on its own, so a failed part does not fail the whole PUT.
+	AutoMultipartThreshold   int64 `mapstructure:"auto_multipart_threshold"`    // Plaintext size in bytes, 0 = disabled (default: 5GB, the S3 single PUT limit)
+	AutoMultipartPartRetries int   `mapstructure:"auto_multipart_part_retries"` // Extra attempts per failed part (default: 3)
+
+	// Stale Multipart Sessions
+	// Sessions are matched on bucket, key and the client's access key ID;
+	// requests without an access key are never matched.
+	MultipartStaleSessionPolicy string `mapstructure:"multipart_stale_session_policy"` // keep, abort or reuse (default: keep)
+
+	// Parallel Part Encryption
+	// With workers set, each part of a new multipart upload is encrypted on
+	// its own at a keystream offset derived from its part number and the part
+	// size declared on initiation, so parts no longer wait for each other. The
+	// value bounds the parts encrypted at once.
+	MultipartEncryptionWorkers int `mapstructure:"multipart_encryption_workers"` // 0 = parts in order (default: 0, max: 256)
+
+	// Crypto Parallelism
+	// AES-CTR chunks are split by keystream offset and processed by several
+	// workers, so a single large transfer is not limited to one core. Slices
+	// are at least 16KB, so streaming_buffer_size also bounds GET parallelism.
+	CryptoWorkersPerStream int `mapstructure:"crypto_workers_per_stream"` // Workers per stream, 0 = GOMAXPROCS (default: 0)
+	CryptoWorkersMax       int `mapstructure:"crypto_workers_max"`        // Helper workers across all streams, 0 = GOMAXPROCS (default: 0)
+
+	// GET Read-Ahead
+	// Number of streaming_buffer_size chunks a GET fetches from the backend
+	// and decrypts in parallel ahead of the client, so backend latency
+	// overlaps with decryption. Costs read-ahead+2 buffers per GET.
+	StreamingReadAhead int `mapstructure:"streaming_read_ahead"` // Chunks, 0 or 1 = sequential (default: 0, max: 64)
+
+	// Network Tuning
+	// Socket and buffer sizes for high bandwidth-delay links (10Gbit, WAN)
+	Network NetworkTuningConfig `mapstructure:"network"`
+
+	// DEK Cache
+	// Decrypted DEKs are kept in memory, so repeated reads of an object do not
+	// call the key encryption provider again
+	DEKCache DEKCacheConfig `mapstructure:"dek_cache"`
+}
+
+// DEKCacheConfig holds the settings of the LRU cache of decrypted DEKs.
+// Evicted and expired DEKs are zeroed in memory.
+type DEKCacheConfig struct {
+	Enabled     *bool  `mapstructure:"enabled"`      // Cache decrypted DEKs (default: true)
+	Size        int    `mapstructure:"size"`         // Maximum cached DEKs, least recently used evicted first, 0 = 1024 (default: 1024)
+	TTL         int    `mapstructure:"ttl"`          // Seconds a DEK stays cached after it was unwrapped, 0 = until evicted (default: 3600)
+	PersistPath string `mapstructure:"persist_path"` // File the cache is saved to on shutdown and restored from on start, empty = not persisted (default: "")
+}
+
+// IsEnabled reports whether decrypted DEKs are cached, which is the default
+func (c DEKCacheConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// NetworkTuningConfig holds the buffer and socket settings of the client
+// listener and the backend transport
+type NetworkTuningConfig struct {
+	ResponseBufferSize int                    `mapstructure:"response_buffer_size"` // Buffer for streaming GET bodies to clients in bytes, 4KB - 8MB (default: 128KB)
+	Listener           SocketTuningConfig     `mapstructure:"listener"`             // Accepted client connections
+	Backend            BackendTransportConfig `mapstructure:"backend"`              // Connections to the S3 backend
+}
+
+// SocketTuningConfig holds TCP options applied to every connection. Socket
+// buffer sizes are hints capped by the kernel (net.core.rmem_max/wmem_max on
+// Linux), and setting one disables the kernel's autotuning for that buffer.
+type SocketTuningConfig struct {
+	TCPNoDelay    *bool `mapstructure:"tcp_nodelay"`           // Disable Nagle's algorithm (default: true)
+	SendBuffer    int   `mapstructure:"socket_send_buffer"`    // SO_SNDBUF in bytes, 0 = kernel autotuning (default: 0)
+	ReceiveBuffer int   `mapstructure:"socket_receive_buffer"` // SO_RCVBUF in bytes, 0 = kernel autotuning (default: 0)
+}
+
+// NoDelay reports whether TCP_NODELAY is set, which is Go's default
+func (s SocketTuningConfig) NoDelay() bool {
+	return s.TCPNoDelay == nil || *s.TCPNoDelay
+}
+
+// BackendTransportConfig holds the socket and HTTP transport buffer settings
+// of backend connections
+type BackendTransportConfig struct {
+	SocketTuningConfig `mapstructure:",squash"`
+	WriteBufferSize    int `mapstructure:"write_buffer_size"` // HTTP transport write buffer in bytes, 0 = 4KB (default: 0)
+	ReadBufferSize     int `mapstructure:"read_buffer_size"`  // HTTP transport read buffer in bytes, 0 = 4KB (default: 0)
 } // MonitoringConfig holds monitoring configuration
 type MonitoringConfig struct {
 	Enabled      bool   `mapstructure:"enabled"`       // Enable/disable monitoring
 	BindAddress  string `mapstructure:"bind_address"`  // Address to bind monitoring server (default: :9090)
 	MetricsPath  string `mapstructure:"metrics_path"`  // Path for metrics endpoint (default: /metrics)
 	PprofEnabled bool   `mapstructure:"pprof_enabled"` // Expose /debug/pprof on the monitoring port (admin-only; default: false)
+
+	ListExport    ListExportConfig    `mapstructure:"list_export"`    // Admin list export jobs (served on the monitoring port)
+	BucketMetrics BucketMetricsConfig `mapstructure:"bucket_metrics"` // Per-bucket S3 operation metrics with cardinality limits
+	Attestation   AttestationConfig   `mapstructure:"attestation"`    // Signed object manifests for auditors (served on the monitoring port)
+	Usage         UsageConfig         `mapstructure:"usage"`          // Periodic per-bucket object count and plaintext size collection
+	KEKRotation   KEKRotationConfig   `mapstructure:"kek_rotation"`   // Admin jobs re-wrapping DEKs with the active provider (served on the monitoring port)
+	MultipartGC   MultipartGCConfig   `mapstructure:"multipart_gc"`   // Admin garbage collection of stale multipart uploads (served on the monitoring port)
+}
+
+// TracingConfig configures OpenTelemetry tracing of the request path: the
+// router, handlers, encryption, KEK providers and backend S3 calls. Spans are
+// exported over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`      // Export spans (default: false)
+	Endpoint    string            `mapstructure:"endpoint"`     // OTLP/HTTP collector URL, e.g. http://otel-collector:4318 (required when enabled)
+	Headers     map[string]string `mapstructure:"headers"`      // Extra headers of export requests, e.g. for authentication
+	ServiceName string            `mapstructure:"service_name"` // service.name of the spans (default: s3-encryption-proxy)
+	SampleRatio float64           `mapstructure:"sample_ratio"` // Fraction of new traces sampled, 0-1; sampled callers are always followed (default: 1)
+	Timeout     int               `mapstructure:"timeout"`      // Export timeout in seconds (default: 10)
+}
+
+// RateLimitingConfig throttles the clients of the S3 API. Every client, told
+// apart by its access key or IP address, gets a request rate and a bandwidth;
+// requests above the rate, or sent while the client's transfers are backlogged,
+// are rejected with 503 SlowDown, and request and response bodies are paced to
+// the bandwidth.
+type RateLimitingConfig struct {
+	Enabled           bool                    `mapstructure:"enabled"`             // Throttle clients (default: false)
+	KeyBy             string                  `mapstructure:"key_by"`              // access_key or ip; unsigned requests are always keyed by IP (default: access_key)
+	RequestsPerSecond float64                 `mapstructure:"requests_per_second"` // Requests per second of a client (default: 0 = unlimited)
+	RequestBurst      int                     `mapstructure:"request_burst"`       // Requests allowed at once on top of the rate (default: requests_per_second rounded up)
+	BytesPerSecond    int64                   `mapstructure:"bytes_per_second"`    // Request and response body bytes per second of a client (default: 0 = unlimited)
+	BandwidthBurst    int64                   `mapstructure:"bandwidth_burst"`     // Bytes sent at once on top of the bandwidth (default: bytes_per_second)
+	MaxClients        int                     `mapstructure:"max_clients"`         // Clients tracked at once; the longest idle one is forgotten (default: 10000)
+	Clients           []RateLimitClientConfig `mapstructure:"clients"`             // Limits of individual access keys
+}
+
+// RateLimitClientConfig overrides the limits of one access key. Limits left
+// at 0 are taken from the rate_limiting section.
+type RateLimitClientConfig struct {
+	AccessKeyID       string  `mapstructure:"access_key_id"`
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	RequestBurst      int     `mapstructure:"request_burst"`
+	BytesPerSecond    int64   `mapstructure:"bytes_per_second"`
+	BandwidthBurst    int64   `mapstructure:"bandwidth_burst"`
+}
+
+// Rate limiting client keys
+const (
+	RateLimitKeyByAccessKey = "access_key"
+	RateLimitKeyByIP        = "ip"
+)
+
+// KEKRotationConfig configures the admin KEK rotation job, which re-wraps the
+// DEKs of a bucket's objects with the active provider after a KEK rotation
+type KEKRotationConfig struct {
+	Enabled           bool `mapstructure:"enabled"`             // Expose /admin/kek-rotations on the monitoring port (default: false)
+	MaxConcurrentJobs int  `mapstructure:"max_concurrent_jobs"` // Jobs allowed to run at the same time (0: default of 1)
+	Concurrency       int  `mapstructure:"concurrency"`         // Objects re-wrapped in parallel per job (0: default of 4, max: 64)
+}
+
+// MultipartGCConfig configures the admin garbage collection of incomplete
+// multipart uploads initiated through the proxy and their sessions
+type MultipartGCConfig struct {
+	Enabled bool `mapstructure:"enabled"` // Expose /admin/multipart-gc on the monitoring port (default: false)
+}
+
+// UsageConfig configures the bucket usage collector, which periodically lists
+// buckets and reads the stored plaintext sizes to report per-bucket object
+// counts and logical bytes as metrics and on /admin/usage
+type UsageConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`          // Run the collector (default: false)
+	Interval        int      `mapstructure:"interval"`         // Seconds between collections (default: 3600, min: 60)
+	Buckets         []string `mapstructure:"buckets"`          // Buckets to collect (default: all buckets of the backend)
+	MaxBuckets      int      `mapstructure:"max_buckets"`      // Upper bound on collected buckets and metric series (default: 1000)
+	HeadConcurrency int      `mapstructure:"head_concurrency"` // HeadObject requests in flight per bucket (default: 8, max: 64)
+}
+
+// AttestationConfig configures the admin attestation export, which produces a
+// signed manifest of the objects under a prefix for auditors
+type AttestationConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`          // Expose /admin/attestations on the monitoring port (default: false)
+	SigningKeyFile string `mapstructure:"signing_key_file"` // PKCS#8 PEM Ed25519 private key used to sign manifests (required when enabled)
+	MaxObjects     int    `mapstructure:"max_objects"`      // Largest manifest; bigger exports are rejected, never truncated (default: 100000)
+}
+
+// BucketMetricsConfig controls per-bucket metrics. Only the max_buckets busiest
+// buckets get their own label value; all others are aggregated under
+// overflow_label, so a bucket-per-customer deployment keeps a bounded number
+// of series.
+type BucketMetricsConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`            // Record S3 operation metrics with a bucket label (default: false)
+	MaxBuckets        int    `mapstructure:"max_buckets"`        // Distinct bucket label values (default: 50)
+	OverflowLabel     string `mapstructure:"overflow_label"`     // Label value for all other buckets (default: _other)
+	RebalanceInterval int    `mapstructure:"rebalance_interval"` // Seconds between top-N recalculations (default: 600)
+}
+
+// ListExportConfig configures the admin list export job, which streams a full
+// bucket listing into a gzip-compressed CSV/JSONL object in a diagnostics bucket
+type ListExportConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`             // Expose /admin/list-exports on the monitoring port (default: false)
+	DiagnosticsBucket string `mapstructure:"diagnostics_bucket"`  // Backend bucket receiving the export objects (required when enabled)
+	KeyPrefix         string `mapstructure:"key_prefix"`          // Key prefix for export objects (default: list-exports/)
+	PartSize          int64  `mapstructure:"part_size"`           // Compressed bytes per uploaded part (default: 8MB, min: 5MB)
+	MaxConcurrentJobs int    `mapstructure:"max_concurrent_jobs"` // Jobs allowed to run at the same time (0: default of 2)
+}
+
+// LicenseConfig controls license validation tolerance and runtime re-validation
+type LicenseConfig struct {
+	ClockSkewTolerance   int                `mapstructure:"clock_skew_tolerance"`  // Seconds accepted on the nbf/exp claims (default: 300)
+	RevalidationInterval int                `mapstructure:"revalidation_interval"` // Seconds between runtime re-validations (default: 3600)
+	WarningDays          []int              `mapstructure:"warning_days"`          // Days before expiry to warn at (default: [30, 7, 1])
+	GraceDays            int                `mapstructure:"grace_days"`            // Days an expired license is still accepted with a warning (default: 0)
+	Enforcement          string             `mapstructure:"enforcement"`           // After the grace period: "restrict" rejects uploads, "shutdown" stops the proxy (default: restrict)
+	Usage                LicenseUsageConfig `mapstructure:"usage"`
+	Revocation           RevocationConfig   `mapstructure:"revocation"`
+}
+
+// RevocationConfig controls the check of the license against a signed
+// revocation list
+type RevocationConfig struct {
+	Source          string `mapstructure:"source"`           // https URL or file path of the signed list, empty = no check (default: "")
+	RefreshInterval int    `mapstructure:"refresh_interval"` // Seconds between reads of the source (default: 3600)
+	MaxAge          int    `mapstructure:"max_age"`          // Seconds the last verified list is used while the source fails (default: 604800)
+	FailClosed      bool   `mapstructure:"fail_closed"`      // Treat the license as invalid while no list is available (default: false)
+	CacheFile       string `mapstructure:"cache_file"`       // File the last verified list is kept in across restarts (default: "")
+}
+
+// LicenseUsageConfig controls the accounting of encrypted and decrypted bytes
+// per month
+type LicenseUsageConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`          // Count the bytes (default: true)
+	StateFile       string `mapstructure:"state_file"`       // File the counters are saved to and restored from, empty = not persisted (default: "")
+	SaveInterval    int    `mapstructure:"save_interval"`    // Seconds between saves of the state file (default: 60)
+	RetentionMonths int    `mapstructure:"retention_months"` // Months kept, 0 = all (default: 24)
+}
+
+// SelfTestConfig controls the provider self-test run at startup
+type SelfTestConfig struct {
+	Enabled bool `mapstructure:"enabled"` // Round-trip a probe through every provider at startup (default: false)
+	Strict  bool `mapstructure:"strict"`  // Abort startup when a provider fails (default: false, only logs an error)
+}
+
+// CanaryConfig configures the synthetic canary, which periodically writes,
+// reads back and deletes a probe object through the proxy's own S3 endpoint
+type CanaryConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`              // Run the canary (default: false)
+	Endpoint           string `mapstructure:"endpoint"`             // Proxy URL to probe (default: loopback address of bind_address)
+	Bucket             string `mapstructure:"bucket"`               // Bucket the probe object is written to (required when enabled)
+	KeyPrefix          string `mapstructure:"key_prefix"`           // Key prefix of probe objects (default: .s3ep-canary/)
+	AccessKeyID        string `mapstructure:"access_key_id"`        // s3_clients entry the canary signs with (default: the first one)
+	Interval           int    `mapstructure:"interval"`             // Seconds between runs (default: 60)
+	Timeout            int    `mapstructure:"timeout"`              // Seconds allowed per run (default: 10)
+	ObjectSize         int    `mapstructure:"object_size"`          // Probe payload size in bytes (default: 4096, max: 16MB)
+	FailureThreshold   int    `mapstructure:"failure_threshold"`    // Consecutive failed runs before alerting (default: 3)
+	AlertWebhookURL    string `mapstructure:"alert_webhook_url"`    // Optional URL receiving a JSON POST when an alert fires or resolves
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Skip verification of the proxy TLS certificate (default: false)
+}
+
+// ScrubberConfig configures the background integrity scrubber, which samples
+// stored objects and decrypts and verifies them without serving them
+type ScrubberConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`           // Run the scrubber (default: false)
+	Buckets         []string `mapstructure:"buckets"`           // Buckets to sample from (default: every bucket of the backend)
+	SamplesPerHour  int      `mapstructure:"samples_per_hour"`  // Objects verified per hour, spread over the hour (default: 60)
+	ListBatchSize   int      `mapstructure:"list_batch_size"`   // Keys listed per bucket and hour to sample from (default: 10000)
+	MaxObjectSize   int64    `mapstructure:"max_object_size"`   // Larger objects are not sampled, 0 = no limit (default: 1GB)
+	Timeout         int      `mapstructure:"timeout"`           // Seconds allowed to verify one object (default: 300)
+	AlertWebhookURL string   `mapstructure:"alert_webhook_url"` // Optional URL receiving a JSON POST for every object that fails verification
+}
+
+// CompressionConfig controls compression of object data before encryption.
+// Objects encrypted in memory are stored compressed if that makes them
+// smaller; streamed uploads are compressed as they are read and uploaded in
+// parts. Client multipart uploads are stored as sent.
+type CompressionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`  // Compress new objects (default: false)
+	Codec   string `mapstructure:"codec"`    // gzip or zstd (default: gzip)
+	Level   int    `mapstructure:"level"`    // 1 (fastest) to 9 (smallest) for gzip, to 22 for zstd, 0 = codec default (default: 0)
+	MinSize int64  `mapstructure:"min_size"` // Smaller objects are stored uncompressed (default: 1024)
+}
+
+// BucketPoliciesConfig declares per-bucket rules the proxy enforces on S3
+// requests. The first rule with a matching bucket pattern applies, buckets no
+// rule matches get the default policy.
+type BucketPoliciesConfig struct {
+	Default BucketPolicy       `mapstructure:"default"` // Policy of buckets no rule matches (default: no restrictions)
+	Rules   []BucketPolicyRule `mapstructure:"rules"`
+}
+
+// BucketPolicy restricts the requests made to a bucket. Violations are
+// rejected with 403 AccessDenied. PublicRead opens object reads to unsigned
+// requests instead.
+type BucketPolicy struct {
+	RequireEncryption   bool `mapstructure:"require_encryption"`     // Reject uploads the proxy would store unencrypted, with a provider of type "none"
+	DenySSECPassthrough bool `mapstructure:"deny_sse_c_passthrough"` // Reject SSE-C uploads, which encryption.sse_c_mode "passthrough" stores without proxy encryption
+	ReadOnly            bool `mapstructure:"read_only"`              // Reject writes, deletes and bucket configuration changes
+
+	PublicRead         bool     `mapstructure:"public_read"`          // Serve GET and HEAD of objects to unsigned requests; everything else still needs credentials
+	PublicReadPrefixes []string `mapstructure:"public_read_prefixes"` // Object key prefixes public_read applies to (default: all keys)
+}
+
+// IsZero reports whether p neither restricts nor opens anything
+func (p BucketPolicy) IsZero() bool {
+	return !p.RequireEncryption && !p.DenySSECPassthrough && !p.ReadOnly && !p.PublicRead && len(p.PublicReadPrefixes) == 0
+}
+
+// PublicReadable reports whether p serves the object key to unsigned requests
+func (p BucketPolicy) PublicReadable(key string) bool {
+	if !p.PublicRead {
+		return false
+	}
+	if len(p.PublicReadPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range p.PublicReadPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// BucketPolicyRule applies a policy to the buckets matching its patterns
+type BucketPolicyRule struct {
+	Buckets      []string `mapstructure:"buckets"` // Glob patterns of bucket names (*, ? and [...] as in path.Match)
+	BucketPolicy `mapstructure:",squash"`
+}
+
+// UploadLimitsConfig limits the size of uploads below the S3 limits, to
+// protect backends with little capacity. Oversized uploads are rejected with
+// 400 EntityTooLarge, uploads beyond a quota with 403 QuotaExceeded.
+type UploadLimitsConfig struct {
+	MaxObjectSize int64             `mapstructure:"max_object_size"` // Largest object in bytes, of PutObject and the parts of a multipart upload together (default: 0 = no limit)
+	MaxPartSize   int64             `mapstructure:"max_part_size"`   // Largest UploadPart body in bytes (default: 0 = no limit)
+	Quotas        []BucketQuotaRule `mapstructure:"quotas"`          // Storage quotas, checked against monitoring.usage (default: none)
+}
+
+// BucketQuotaRule limits the plaintext bytes stored in each matching bucket
+type BucketQuotaRule struct {
+	Buckets  []string `mapstructure:"buckets"`   // Glob patterns of bucket names (*, ? and [...] as in path.Match)
+	MaxBytes int64    `mapstructure:"max_bytes"` // Plaintext bytes each bucket may hold
+}
+
+// ReplicationConfig mirrors the objects written through the proxy to a
+// second S3 endpoint. Objects are copied as stored, ciphertext and metadata,
+// in the background after each write.
+type ReplicationConfig struct {
+	Enabled            bool     `mapstructure:"enabled"`              // Replicate written objects (default: false)
+	TargetEndpoint     string   `mapstructure:"target_endpoint"`      // Endpoint the objects are copied to
+	Region             string   `mapstructure:"region"`               // Region (default: the s3_backend region)
+	AccessKeyID        string   `mapstructure:"access_key_id"`        // Credentials (default: those of s3_backend)
+	SecretKey          string   `mapstructure:"secret_key"`           // Credentials (default: those of s3_backend)
+	InsecureSkipVerify bool     `mapstructure:"insecure_skip_verify"` // Skip TLS certificate verification of the target
+	Buckets            []string `mapstructure:"buckets"`              // Glob patterns of replicated buckets (default: all buckets)
+	QueueSize          int      `mapstructure:"queue_size"`           // Objects waiting to be copied before new ones are dropped (default: 10000)
+	Workers            int      `mapstructure:"workers"`              // Objects copied in parallel (default: 4)
+	MaxRetries         int      `mapstructure:"max_retries"`          // Further attempts after a failed copy (default: 5)
+	RetryBackoff       int      `mapstructure:"retry_backoff"`        // Seconds before the first retry, doubled per attempt up to 5 minutes (default: 5)
+}
+
+// ObjectCacheConfig keeps decrypted responses of small objects in memory
+// and optionally on disk. Writes through the proxy invalidate them; changes
+// made around this proxy show after at most the TTL.
+type ObjectCacheConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`         // Cache GET responses of small objects (default: false)
+	TTL           int      `mapstructure:"ttl"`             // Seconds an entry is served (default: 60)
+	MaxObjectSize int64    `mapstructure:"max_object_size"` // Largest cached object in bytes (default: 1048576)
+	MemorySize    int64    `mapstructure:"memory_size"`     // Bytes of objects kept in memory, 0 = no memory tier (default: 67108864)
+	DiskDirectory string   `mapstructure:"disk_directory"`  // Directory of the disk tier, which stores plaintext (default: "" = no disk tier)
+	DiskSize      int64    `mapstructure:"disk_size"`       // Bytes of objects kept on disk (default: 1073741824)
+	Buckets       []string `mapstructure:"buckets"`         // Glob patterns of cached buckets (default: all buckets)
+}
+
+// ProbesConfig controls the checks behind the /readyz and /startupz probes.
+// Every check runs in the background at its interval, and the probes report
+// the latest results, so probing does not load the backend or the KMS.
+type ProbesConfig struct {
+	BackendInterval  int    `mapstructure:"backend_interval"`  // Seconds between checks that the S3 backend is reachable (default: 15)
+	ProviderInterval int    `mapstructure:"provider_interval"` // Seconds between DEK wrap/unwrap self-tests of the providers, which reach the KMS of remote providers (default: 60)
+	LicenseInterval  int    `mapstructure:"license_interval"`  // Seconds between license checks (default: 300)
+	Timeout          int    `mapstructure:"timeout"`           // Seconds allowed per check (default: 10)
+	Bucket           string `mapstructure:"bucket"`            // Bucket whose objects the backend check lists (default: "" = list the buckets)
+}
+
+// KeyPreloadConfig controls preloading of remote key material (Tink/KMS keysets)
+type KeyPreloadConfig struct {
+	Enabled         bool `mapstructure:"enabled"`          // Preload keys at startup and gate /health on it (default: false)
+	RefreshInterval int  `mapstructure:"refresh_interval"` // Seconds between refreshes, and retries before the first success (default: 300)
+	Timeout         int  `mapstructure:"timeout"`          // Seconds allowed per preload attempt (default: 30)
+}
+
+// RecoveryConfig holds what is kept when a request handler panics. Panics are
+// always answered with 500 InternalError and logged with their stack.
+type RecoveryConfig struct {
+	CrashBundleDir  string `mapstructure:"crash_bundle_dir"`  // Directory a JSON crash bundle is written to per panic (default: "" = off)
+	MaxCrashBundles int    `mapstructure:"max_crash_bundles"` // Bundles in the directory after which new ones are skipped (default: 100)
+}
+
+// AccessLogConfig configures the access log, one line per S3 request
+type AccessLogConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // Write the access log (default: false)
+	Format  string `mapstructure:"format"`  // common, combined or json (default: combined)
+	Output  string `mapstructure:"output"`  // stdout, stderr or the path of a file lines are appended to (default: stdout)
+}
+
+// AuditConfig configures the tamper-evident audit log of S3 requests and the
+// sinks its records are forwarded to. Sinks work with or without the log.
+type AuditConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`             // Record every S3 request in the log (default: false)
+	Directory         string `mapstructure:"directory"`           // Directory of the segment files (required when enabled)
+	SigningKeyFile    string `mapstructure:"signing_key_file"`    // PKCS#8 PEM Ed25519 private key the segment seals are signed with (required when enabled)
+	SegmentMaxRecords int    `mapstructure:"segment_max_records"` // Records after which a segment is sealed (default: 10000)
+	SegmentMaxAge     int    `mapstructure:"segment_max_age"`     // Seconds after which a segment with records is sealed (default: 3600)
+
+	Sinks             []AuditSinkConfig `mapstructure:"sinks"`               // Systems every record is forwarded to (default: none)
+	SinkQueueSize     int               `mapstructure:"sink_queue_size"`     // Records waiting per sink before new ones are dropped (default: 10000)
+	SinkBatchSize     int               `mapstructure:"sink_batch_size"`     // Records per webhook or Kafka request (default: 100)
+	SinkFlushInterval int               `mapstructure:"sink_flush_interval"` // Milliseconds a partial batch waits for more records (default: 1000)
+	SinkTimeout       int               `mapstructure:"sink_timeout"`        // Seconds a delivery to a sink may take (default: 10)
+}
+
+// Audit sink types
+const (
+	AuditSinkSyslog  = "syslog"
+	AuditSinkWebhook = "webhook"
+	AuditSinkKafka   = "kafka"
+)
+
+// AuditSinkConfig forwards audit records to a system outside the proxy
+type AuditSinkConfig struct {
+	Name     string            `mapstructure:"name"`     // Used in logs and metrics (default: the type)
+	Type     string            `mapstructure:"type"`     // syslog, webhook or kafka
+	Network  string            `mapstructure:"network"`  // syslog: udp, tcp or unix (default: udp)
+	Address  string            `mapstructure:"address"`  // syslog: host:port, or the socket path for unix
+	Facility string            `mapstructure:"facility"` // syslog: auth, authpriv or local0 to local7 (default: local0)
+	URL      string            `mapstructure:"url"`      // webhook: endpoint records are POSTed to; kafka: base URL of a Kafka REST Proxy
+	Topic    string            `mapstructure:"topic"`    // kafka: topic records are produced to
+	Headers  map[string]string `mapstructure:"headers"`  // webhook, kafka: extra request headers, e.g. Authorization
+}
+
+// NotificationsConfig configures S3 event notifications (s3:ObjectCreated:*,
+// s3:ObjectRemoved:*) of the object writes and deletes of clients
+type NotificationsConfig struct {
+	Targets       []NotificationTargetConfig `mapstructure:"targets"`        // Systems matching events are sent to (default: none)
+	QueueSize     int                        `mapstructure:"queue_size"`     // Events waiting per target before new ones are dropped (default: 10000)
+	BatchSize     int                        `mapstructure:"batch_size"`     // Events per webhook, Kafka or SQS request; SQS takes at most 10 per request (default: 10)
+	FlushInterval int                        `mapstructure:"flush_interval"` // Milliseconds a partial batch waits for more events (default: 1000)
+	Timeout       int                        `mapstructure:"timeout"`        // Seconds a delivery to a target may take (default: 10)
+}
+
+// Notification target types
+const (
+	NotificationTargetWebhook = "webhook"
+	NotificationTargetKafka   = "kafka"
+	NotificationTargetSQS     = "sqs"
+)
+
+// NotificationTargetConfig sends the events matching its filter to a system
+// outside the proxy
+type NotificationTargetConfig struct {
+	Name        string            `mapstructure:"name"`          // configurationId of the events, used in logs and metrics (default: the type)
+	Type        string            `mapstructure:"type"`          // webhook, kafka or sqs
+	URL         string            `mapstructure:"url"`           // webhook: endpoint events are POSTed to; kafka: base URL of a Kafka REST Proxy; sqs: queue URL
+	Topic       string            `mapstructure:"topic"`         // kafka: topic events are produced to
+	Headers     map[string]string `mapstructure:"headers"`       // webhook, kafka: extra request headers, e.g. Authorization
+	Region      string            `mapstructure:"region"`        // sqs: region of the queue (default: the region of an AWS queue URL)
+	AccessKeyID string            `mapstructure:"access_key_id"` // sqs: credentials allowed to send to the queue
+	SecretKey   string            `mapstructure:"secret_key"`    // sqs: credentials allowed to send to the queue
+	Events      []string          `mapstructure:"events"`        // Patterns of event names, e.g. s3:ObjectCreated:* (default: all events)
+	Buckets     []string          `mapstructure:"buckets"`       // Glob patterns of buckets (default: all buckets)
+	Prefix      string            `mapstructure:"prefix"`        // Prefix of object keys (default: any)
+	Suffix      string            `mapstructure:"suffix"`        // Suffix of object keys (default: any)
+}
+
+// TargetName returns the name of target i, its type if it has none
+func (n NotificationsConfig) TargetName(i int) string {
+	if n.Targets[i].Name != "" {
+		return n.Targets[i].Name
+	}
+	return n.Targets[i].Type
+}
+
+// SessionStoreConfig selects where multipart upload sessions are kept. With
+// redis or etcd, uploads survive a restart of the proxy and their parts can be
+// sent to any replica.
+type SessionStoreConfig struct {
+	Type      string                  `mapstructure:"type"`       // memory, redis or etcd (default: memory)
+	KeyPrefix string                  `mapstructure:"key_prefix"` // Prefix of the keys sessions are stored under (default: s3ep/multipart/)
+	Timeout   int                     `mapstructure:"timeout"`    // Seconds a store operation may take (default: 5)
+	Redis     RedisSessionStoreConfig `mapstructure:"redis"`
+	Etcd      EtcdSessionStoreConfig  `mapstructure:"etcd"`
+
+	// File the sessions of the memory store are saved to on shutdown and
+	// restored from on start, empty = lost on shutdown (default: "")
+	PersistPath string `mapstructure:"persist_path"`
+
+	// Reconciliation of the sessions with the multipart uploads of the backend
+	Reconciliation ReconciliationConfig `mapstructure:"reconciliation"`
+}
+
+// ReconciliationConfig configures the background job that matches the
+// multipart uploads of the backend against the session store. Uploads that
+// lost their session (e.g. with the memory store after a restart) can never
+// be completed into a decryptable object, and sessions whose upload is gone
+// are never cleaned up by a client.
+type ReconciliationConfig struct {
+	Enabled  bool     `mapstructure:"enabled"`  // Run the reconciliation (default: false)
+	Interval int      `mapstructure:"interval"` // Seconds between two runs (default: 3600)
+	MinAge   int      `mapstructure:"min_age"`  // Seconds an upload or session has to exist before it counts as orphaned (default: 3600)
+	Policy   string   `mapstructure:"policy"`   // keep, abort or adopt (default: keep)
+	Buckets  []string `mapstructure:"buckets"`  // Buckets to reconcile (default: every bucket of the backend)
+}
+
+// RedisSessionStoreConfig holds the connection settings of the redis session store
+type RedisSessionStoreConfig struct {
+	Address  string `mapstructure:"address"`  // host:port of the Redis server (required for type redis)
+	Username string `mapstructure:"username"` // ACL user name (optional)
+	Password string `mapstructure:"password"` // Password, supports ${VAR} references (optional)
+	DB       int    `mapstructure:"db"`       // Database number (default: 0)
+	TLS      bool   `mapstructure:"tls"`      // Connect with TLS (default: false)
+}
+
+// EtcdSessionStoreConfig holds the connection settings of the etcd session store
+type EtcdSessionStoreConfig struct {
+	Endpoints []string `mapstructure:"endpoints"` // Client URLs of the etcd cluster (required for type etcd)
+	Username  string   `mapstructure:"username"`  // User name (optional)
+	Password  string   `mapstructure:"password"`  // Password, supports ${VAR} references (optional)
 }
 
 // Config holds the application configuration
@@ -152,9 +1130,36 @@ type Config struct {
 	ShutdownTimeout   int       `mapstructure:"shutdown_timeout"` // Graceful shutdown timeout in seconds
 	TLS               TLSConfig `mapstructure:"tls"`
 
+	// Reloads of the configuration file while the proxy runs
+	ConfigReload ConfigReloadConfig `mapstructure:"config_reload"`
+
+	// Client listener hardening
+	Listener ListenerConfig `mapstructure:"listener"`
+
+	// Per-client request rate and bandwidth limits
+	RateLimiting RateLimitingConfig `mapstructure:"rate_limiting"`
+
+	// Object key canonicalization of request paths
+	KeyCanonicalization KeyCanonicalizationConfig `mapstructure:"key_canonicalization"`
+
+	// Handler panic recovery
+	Recovery RecoveryConfig `mapstructure:"recovery"`
+
+	// Hash-chained, signed audit log of S3 requests
+	Audit AuditConfig `mapstructure:"audit"`
+
+	// S3 event notifications of object writes and deletes
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+
+	// One line per S3 request, separate from the proxy log
+	AccessLog AccessLogConfig `mapstructure:"access_log"`
+
 	// Monitoring configuration
 	Monitoring MonitoringConfig `mapstructure:"monitoring"`
 
+	// OpenTelemetry tracing of the request path
+	Tracing TracingConfig `mapstructure:"tracing"`
+
 	// S3 configuration
 	S3Backend      S3BackendConfig `mapstructure:"s3_backend"`
 	TargetEndpoint string          `mapstructure:"target_endpoint"`
@@ -166,18 +1171,59 @@ type Config struct {
 	S3Clients  []S3ClientCredentials `mapstructure:"s3_clients"`
 	S3Security S3SecurityConfig      `mapstructure:"s3_security"`
 
+	// Per-client scopes from a policy file that is reloaded when it changes
+	Authorization AuthorizationConfig `mapstructure:"authorization"`
+
 	// Legacy S3 TLS configuration (for backward compatibility)
 	UseTLS              bool `mapstructure:"use_tls"`
 	SkipSSLVerification bool `mapstructure:"skip_ssl_verification"`
 
 	// License configuration
-	LicenseFile string `mapstructure:"license_file"` // Path to license file (default: config/license.jwt)
+	LicenseFile string        `mapstructure:"license_file"` // Path to license file (default: config/license.jwt)
+	License     LicenseConfig `mapstructure:"license"`
 
 	// Encryption configuration
 	Encryption EncryptionConfig `mapstructure:"encryption"`
 
+	// Provider self-test at startup
+	SelfTest SelfTestConfig `mapstructure:"self_test"`
+
+	// Key material preloading and readiness gate
+	KeyPreload KeyPreloadConfig `mapstructure:"key_preload"`
+
+	// Deep checks behind the /readyz and /startupz probes
+	Probes ProbesConfig `mapstructure:"probes"`
+
+	// Synthetic canary requests through the proxy endpoint
+	Canary CanaryConfig `mapstructure:"canary"`
+
+	// Background decryption and integrity verification of stored objects
+	Scrubber ScrubberConfig `mapstructure:"scrubber"`
+
+	// Compression of object data before encryption
+	Compression CompressionConfig `mapstructure:"compression"`
+
+	// Per-bucket restrictions of S3 requests
+	BucketPolicies BucketPoliciesConfig `mapstructure:"bucket_policies"`
+
+	// Object and part size limits and per-bucket storage quotas
+	UploadLimits UploadLimitsConfig `mapstructure:"upload_limits"`
+
+	// Asynchronous copies of written objects to a second S3 endpoint
+	Replication ReplicationConfig `mapstructure:"replication"`
+
+	// Cache of decrypted small objects
+	ObjectCache ObjectCacheConfig `mapstructure:"object_cache"`
+
 	// Performance optimizations configuration
 	Optimizations OptimizationsConfig `mapstructure:"optimizations"`
+
+	// Persistence of multipart upload sessions
+	SessionStore SessionStoreConfig `mapstructure:"session_store"`
+
+	// Developer mode, only set by the --dev flag: in-memory backend and
+	// relaxed client authentication
+	DevMode bool `mapstructure:"-"`
 }
 
 // InitConfig initializes the configuration system
@@ -216,29 +1262,53 @@ func InitConfig(cfgFile string) {
 
 // Load loads the configuration from viper
 func Load() (*Config, error) {
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
-	}
-
-	// Handle legacy configuration migration
-	migrateLegacyConfig(&cfg)
-
-	// Handle provider configs manually due to viper's unmarshaling issues
-	if err := loadProviderConfigs(&cfg); err != nil {
-		return nil, fmt.Errorf("provider config loading failed: %w", err)
+	cfg, err := unmarshal(viper.GetViper())
+	if err != nil {
+		return nil, err
 	}
 
 	// Expand ${VAR} environment variable references in config values
-	if err := expandConfigEnvVars(&cfg); err != nil {
+	if err := expandConfigEnvVars(cfg); err != nil {
 		return nil, fmt.Errorf("environment variable expansion failed: %w", err)
 	}
 
 	// Validate required fields
-	if err := validate(&cfg); err != nil {
+	if err := validate(cfg); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
 
+	return cfg, nil
+}
+
+// Parse reads a YAML configuration document with the same defaults and
+// provider handling as Load, but without the environment and the global
+// viper instance. ${VAR} references are kept as they are and the result is
+// not validated; see Check.
+func Parse(data []byte) (*Config, error) {
+	v := viper.New()
+	applyDefaults(v)
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return unmarshal(v)
+}
+
+// unmarshal decodes the configuration held by v
+func unmarshal(v *viper.Viper) (*Config, error) {
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Handle legacy configuration migration
+	migrateLegacyConfig(v, &cfg)
+
+	// Handle provider configs manually due to viper's unmarshaling issues
+	if err := loadProviderConfigs(v, &cfg); err != nil {
+		return nil, fmt.Errorf("provider config loading failed: %w", err)
+	}
+
 	return &cfg, nil
 }
 
@@ -251,7 +1321,7 @@ func LoadAndStartLicense() (*Config, *license.LicenseValidator, error) {
 
 	// Create and configure license validator for runtime monitoring
 	licenseToken := license.LoadLicense(cfg.LicenseFile)
-	validator := license.NewValidator()
+	validator := license.NewValidatorWithOptions(cfg.GetLicenseOptions())
 	result := validator.ValidateLicense(licenseToken)
 
 	// Start runtime monitoring if license is valid
@@ -263,38 +1333,38 @@ func LoadAndStartLicense() (*Config, *license.LicenseValidator, error) {
 }
 
 // migrateLegacyConfig handles migration from legacy configuration parameters
-func migrateLegacyConfig(cfg *Config) {
+func migrateLegacyConfig(v *viper.Viper, cfg *Config) {
 	migratedFields := []string{}
 
 	// Migrate legacy S3 configuration to new s3_backend structure - only if explicitly set
-	if viper.IsSet("target_endpoint") && !viper.IsSet("s3_backend.target_endpoint") && cfg.TargetEndpoint != "" {
+	if v.IsSet("target_endpoint") && !v.IsSet("s3_backend.target_endpoint") && cfg.TargetEndpoint != "" {
 		cfg.S3Backend.TargetEndpoint = cfg.TargetEndpoint
 		migratedFields = append(migratedFields, "target_endpoint")
 	}
 
-	if viper.IsSet("region") && !viper.IsSet("s3_backend.region") && cfg.Region != "" {
+	if v.IsSet("region") && !v.IsSet("s3_backend.region") && cfg.Region != "" {
 		cfg.S3Backend.Region = cfg.Region
 		migratedFields = append(migratedFields, "region")
 	}
 
-	if viper.IsSet("access_key_id") && !viper.IsSet("s3_backend.access_key_id") && cfg.AccessKeyID != "" {
+	if v.IsSet("access_key_id") && !v.IsSet("s3_backend.access_key_id") && cfg.AccessKeyID != "" {
 		cfg.S3Backend.AccessKeyID = cfg.AccessKeyID
 		migratedFields = append(migratedFields, "access_key_id")
 	}
 
-	if viper.IsSet("secret_key") && !viper.IsSet("s3_backend.secret_key") && cfg.SecretKey != "" {
+	if v.IsSet("secret_key") && !v.IsSet("s3_backend.secret_key") && cfg.SecretKey != "" {
 		cfg.S3Backend.SecretKey = cfg.SecretKey
 		migratedFields = append(migratedFields, "secret_key")
 	}
 
 	// Only migrate if the legacy field was explicitly set in config (not just default)
-	if cfg.UseTLS != viper.GetBool("s3_backend.use_tls") && viper.IsSet("use_tls") && !viper.IsSet("s3_backend.use_tls") {
+	if cfg.UseTLS != v.GetBool("s3_backend.use_tls") && v.IsSet("use_tls") && !v.IsSet("s3_backend.use_tls") {
 		cfg.S3Backend.UseTLS = cfg.UseTLS
 		migratedFields = append(migratedFields, "use_tls")
 	}
 
 	// Migrate legacy skip_ssl_verification to new s3_backend.insecure_skip_verify
-	if cfg.SkipSSLVerification != viper.GetBool("s3_backend.insecure_skip_verify") && viper.IsSet("skip_ssl_verification") && !viper.IsSet("s3_backend.insecure_skip_verify") {
+	if cfg.SkipSSLVerification != v.GetBool("s3_backend.insecure_skip_verify") && v.IsSet("skip_ssl_verification") && !v.IsSet("s3_backend.insecure_skip_verify") {
 		cfg.S3Backend.InsecureSkipVerify = cfg.SkipSSLVerification
 		migratedFields = append(migratedFields, "skip_ssl_verification")
 	}
@@ -309,65 +1379,261 @@ func migrateLegacyConfig(cfg *Config) {
 	}
 }
 
-// setDefaults sets default configuration values
+// setDefaults sets default configuration values on the global viper instance
 func setDefaults() {
-	viper.SetDefault("bind_address", "0.0.0.0:8080")
-	viper.SetDefault("log_level", "info")
-	viper.SetDefault("log_format", "text")
-	viper.SetDefault("log_health_requests", false)
+	applyDefaults(viper.GetViper())
+}
+
+// applyDefaults sets default configuration values on v
+func applyDefaults(v *viper.Viper) {
+	v.SetDefault("bind_address", "0.0.0.0:8080")
+	v.SetDefault("log_level", "info")
+	v.SetDefault("log_format", "text")
+	v.SetDefault("log_health_requests", false)
 
 	// New s3_backend configuration defaults
-	viper.SetDefault("s3_backend.region", "us-east-1")
-	viper.SetDefault("s3_backend.use_tls", true)
-	viper.SetDefault("s3_backend.insecure_skip_verify", false)
+	v.SetDefault("s3_backend.region", "us-east-1")
+	v.SetDefault("s3_backend.use_tls", true)
+	v.SetDefault("s3_backend.insecure_skip_verify", false)
+	v.SetDefault("s3_backend.compatibility_profile", "generic")
 
 	// Legacy S3 configuration defaults (for backward compatibility)
-	viper.SetDefault("region", "us-east-1")
-	viper.SetDefault("use_tls", true)
-	viper.SetDefault("skip_ssl_verification", false)
+	v.SetDefault("region", "us-east-1")
+	v.SetDefault("use_tls", true)
+	v.SetDefault("skip_ssl_verification", false)
 
 	// TLS defaults
-	viper.SetDefault("tls.enabled", false)
+	v.SetDefault("tls.enabled", false)
+	v.SetDefault("listener.max_header_bytes", 64*1024)
+	v.SetDefault("listener.read_header_timeout", 10)
+	v.SetDefault("listener.max_query_params", 100)
+	v.SetDefault("listener.max_content_length", int64(5)*1024*1024*1024*1024) // 5TB
+	v.SetDefault("listener.hsts_max_age", 0)
+	v.SetDefault("key_canonicalization.slashes", KeySlashesPreserve)
+	v.SetDefault("key_canonicalization.dot_segments", KeyDotSegmentsPreserve)
+	v.SetDefault("key_canonicalization.encoded_slashes", KeyEncodedSlashesDecode)
+
+	// Backend routing defaults
+	v.SetDefault("s3_backend.route_health_check_interval", 30)
+	v.SetDefault("s3_backend.read_preference", ReadPreferencePrimary)
+	v.SetDefault("s3_backend.replica_health_check_interval", 15)
+	v.SetDefault("s3_backend.response_validation.enabled", true)
+	v.SetDefault("s3_backend.response_validation.max_retries", 2)
+	v.SetDefault("s3_backend.response_validation.verify_etag", false)
+
+	// Replication defaults
+	v.SetDefault("replication.enabled", false)
+	v.SetDefault("replication.queue_size", 10000)
+	v.SetDefault("replication.workers", 4)
+	v.SetDefault("replication.max_retries", 5)
+	v.SetDefault("replication.retry_backoff", 5)
+
+	// Object cache defaults
+	v.SetDefault("object_cache.enabled", false)
+	v.SetDefault("object_cache.ttl", 60)
+	v.SetDefault("object_cache.max_object_size", 1024*1024)       // 1MB
+	v.SetDefault("object_cache.memory_size", 64*1024*1024)        // 64MB
+	v.SetDefault("object_cache.disk_size", int64(1024*1024*1024)) // 1GB
 
 	// Monitoring defaults
-	viper.SetDefault("monitoring.enabled", false)
-	viper.SetDefault("monitoring.bind_address", ":9090")
-	viper.SetDefault("monitoring.metrics_path", "/metrics")
+	v.SetDefault("monitoring.enabled", false)
+	v.SetDefault("monitoring.bind_address", ":9090")
+	v.SetDefault("monitoring.metrics_path", "/metrics")
+	v.SetDefault("monitoring.list_export.enabled", false)
+	v.SetDefault("monitoring.list_export.key_prefix", "list-exports/")
+	v.SetDefault("monitoring.list_export.part_size", 8*1024*1024) // 8MB default
+	v.SetDefault("monitoring.list_export.max_concurrent_jobs", 2)
+	v.SetDefault("monitoring.attestation.enabled", false)
+	v.SetDefault("monitoring.attestation.max_objects", 100000)
+	v.SetDefault("monitoring.kek_rotation.enabled", false)
+	v.SetDefault("monitoring.kek_rotation.max_concurrent_jobs", 1)
+	v.SetDefault("monitoring.kek_rotation.concurrency", 4)
+	v.SetDefault("monitoring.multipart_gc.enabled", false)
+	v.SetDefault("monitoring.usage.enabled", false)
+	v.SetDefault("monitoring.usage.interval", 3600) // 1 hour
+	v.SetDefault("monitoring.usage.max_buckets", 1000)
+	v.SetDefault("monitoring.usage.head_concurrency", 8)
+	v.SetDefault("monitoring.bucket_metrics.enabled", false)
+	v.SetDefault("monitoring.bucket_metrics.max_buckets", 50)
+	v.SetDefault("monitoring.bucket_metrics.overflow_label", "_other")
+	v.SetDefault("monitoring.bucket_metrics.rebalance_interval", 600) // 10 minutes
 
 	// License defaults
-	viper.SetDefault("license_file", "config/license.jwt")
+	v.SetDefault("license_file", "config/license.jwt")
+	v.SetDefault("license.clock_skew_tolerance", 300)
+	v.SetDefault("license.revalidation_interval", 3600)
+	v.SetDefault("license.warning_days", []int{30, 7, 1})
+	v.SetDefault("license.grace_days", 0)
+	v.SetDefault("license.enforcement", string(license.EnforcementRestrict))
+	v.SetDefault("license.usage.enabled", true)
+	v.SetDefault("license.usage.state_file", "")
+	v.SetDefault("license.usage.save_interval", 60)
+	v.SetDefault("license.usage.retention_months", 24)
+	v.SetDefault("license.revocation.source", "")
+	v.SetDefault("license.revocation.refresh_interval", 3600)
+	v.SetDefault("license.revocation.max_age", 7*24*3600)
+	v.SetDefault("license.revocation.fail_closed", false)
+	v.SetDefault("license.revocation.cache_file", "")
+
+	// Self-test defaults
+	v.SetDefault("self_test.enabled", false)
+	v.SetDefault("self_test.strict", false)
+
+	// Key preload defaults
+	v.SetDefault("key_preload.enabled", false)
+	v.SetDefault("key_preload.refresh_interval", 300)
+	v.SetDefault("key_preload.timeout", 30)
+
+	// Probe defaults
+	v.SetDefault("probes.backend_interval", 15)
+	v.SetDefault("probes.provider_interval", 60)
+	v.SetDefault("probes.license_interval", 300)
+	v.SetDefault("probes.timeout", 10)
+
+	// Canary defaults
+	v.SetDefault("canary.enabled", false)
+	v.SetDefault("canary.key_prefix", ".s3ep-canary/")
+	v.SetDefault("canary.interval", 60)
+	v.SetDefault("canary.timeout", 10)
+	v.SetDefault("canary.object_size", 4096)
+	v.SetDefault("canary.failure_threshold", 3)
+
+	// Scrubber defaults
+	v.SetDefault("scrubber.enabled", false)
+	v.SetDefault("scrubber.samples_per_hour", 60)
+	v.SetDefault("scrubber.list_batch_size", 10000)
+	v.SetDefault("scrubber.max_object_size", 1024*1024*1024)
+	v.SetDefault("scrubber.timeout", 300)
+
+	// Compression defaults
+	v.SetDefault("compression.enabled", false)
+	v.SetDefault("compression.codec", "gzip")
+	v.SetDefault("compression.level", 0)
+	v.SetDefault("compression.min_size", 1024)
 
 	// Optimizations defaults
-	viper.SetDefault("optimizations.streaming_buffer_size", 64*1024)          // 64KB default
-	viper.SetDefault("optimizations.enable_adaptive_buffering", false)        // Disabled by default
-	viper.SetDefault("optimizations.streaming_segment_size", 12*1024*1024)    // 12MB default
-	viper.SetDefault("optimizations.streaming_threshold", 5*1024*1024)        // 5MB default
-	viper.SetDefault("optimizations.clean_aws_signature_v4_chunked", true)    // Enable by default
-	viper.SetDefault("optimizations.clean_http_transfer_chunked", true)       // Enable by default
-	viper.SetDefault("optimizations.multipart_session_cleanup_interval", 300) // 5 minutes default
-	viper.SetDefault("optimizations.multipart_session_max_age", 3600)         // 1 hour default
-	viper.SetDefault("optimizations.multipart_upload_concurrency", 4)         // 4 parallel S3 UploadPart calls
+	v.SetDefault("optimizations.streaming_buffer_size", 64*1024)          // 64KB default
+	v.SetDefault("optimizations.enable_adaptive_buffering", false)        // Disabled by default
+	v.SetDefault("optimizations.streaming_segment_size", 12*1024*1024)    // 12MB default
+	v.SetDefault("optimizations.streaming_threshold", 5*1024*1024)        // 5MB default
+	v.SetDefault("optimizations.clean_aws_signature_v4_chunked", true)    // Enable by default
+	v.SetDefault("optimizations.clean_http_transfer_chunked", true)       // Enable by default
+	v.SetDefault("optimizations.multipart_session_cleanup_interval", 300) // 5 minutes default
+	v.SetDefault("optimizations.multipart_session_max_age", 3600)         // 1 hour default
+	v.SetDefault("optimizations.multipart_upload_concurrency", 4)         // 4 parallel S3 UploadPart calls
+	v.SetDefault("optimizations.auto_multipart_threshold", int64(5*1024*1024*1024))
+	v.SetDefault("optimizations.auto_multipart_part_retries", 3)
+	v.SetDefault("optimizations.multipart_stale_session_policy", StaleSessionPolicyKeep)
+	v.SetDefault("optimizations.multipart_encryption_workers", 0)
+	v.SetDefault("optimizations.crypto_workers_per_stream", 0) // 0 = GOMAXPROCS
+	v.SetDefault("optimizations.crypto_workers_max", 0)        // 0 = GOMAXPROCS
+	v.SetDefault("optimizations.streaming_read_ahead", 0)      // sequential
+	v.SetDefault("optimizations.dek_cache.size", 1024)
+	v.SetDefault("optimizations.dek_cache.ttl", 3600)
+
+	// Multipart session store defaults
+	v.SetDefault("session_store.type", SessionStoreMemory)
+	v.SetDefault("session_store.key_prefix", "s3ep/multipart/")
+	v.SetDefault("session_store.timeout", 5)
+	v.SetDefault("session_store.reconciliation.enabled", false)
+	v.SetDefault("session_store.reconciliation.interval", 3600)
+	v.SetDefault("session_store.reconciliation.min_age", 3600)
+	v.SetDefault("session_store.reconciliation.policy", ReconcilePolicyKeep)
 
 	// New encryption defaults
-	viper.SetDefault("encryption.algorithm", "AES256_GCM")
-	viper.SetDefault("encryption.key_rotation_days", 90)
-	viper.SetDefault("encryption.metadata_key_prefix", "s3ep-")
+	v.SetDefault("encryption.algorithm", "AES256_GCM")
+	v.SetDefault("encryption.key_rotation_days", 90)
+	v.SetDefault("encryption.metadata_key_prefix", "s3ep-")
+	v.SetDefault("encryption.metadata_prefix_migration", MetadataPrefixMigrationOff)
 
 	// Integrity verification defaults
-	viper.SetDefault("encryption.integrity_verification", "off")
+	v.SetDefault("encryption.integrity_verification", "off")
+	v.SetDefault("encryption.integrity_algorithm", IntegrityAlgorithmHMACSHA256)
+	v.SetDefault("encryption.plaintext_size_backfill", false)
+	v.SetDefault("encryption.strict_encryption_context", false)
+	v.SetDefault("encryption.sse_c_mode", SSECModeReject)
+	v.SetDefault("encryption.sse_headers.enabled", false)
+	v.SetDefault("encryption.sse_headers.algorithm", SSEHeaderAlgorithmKMS)
+	v.SetDefault("encryption.etag_mode", ETagModeBackend)
+	v.SetDefault("encryption.list_plaintext_sizes", false)
+	v.SetDefault("encryption.hide_internal_keys", false)
+	v.SetDefault("encryption.metadata_sidecar.mode", MetadataSidecarModeOff)
+	v.SetDefault("encryption.metadata_sidecar.inline_limit", 2048)
+	v.SetDefault("encryption.metadata_limits.max_size", 0)
+	v.SetDefault("encryption.metadata_limits.max_value_size", 0)
+
+	// Panic recovery defaults
+	v.SetDefault("recovery.max_crash_bundles", 100)
+
+	// Access log defaults
+	v.SetDefault("access_log.enabled", false)
+	v.SetDefault("access_log.format", string(accesslog.FormatCombined))
+	v.SetDefault("access_log.output", accesslog.OutputStdout)
+
+	// Audit log defaults
+	v.SetDefault("audit.enabled", false)
+	v.SetDefault("audit.segment_max_records", 10000)
+	v.SetDefault("audit.segment_max_age", 3600) // 1 hour
+	v.SetDefault("audit.sink_queue_size", 10000)
+	v.SetDefault("audit.sink_batch_size", 100)
+	v.SetDefault("audit.sink_flush_interval", 1000) // 1 second
+	v.SetDefault("audit.sink_timeout", 10)
+
+	// Event notification defaults
+	v.SetDefault("notifications.queue_size", 10000)
+	v.SetDefault("notifications.batch_size", 10)
+	v.SetDefault("notifications.flush_interval", 1000) // 1 second
+	v.SetDefault("notifications.timeout", 10)
+
+	// Client rate limiting defaults
+	v.SetDefault("rate_limiting.enabled", false)
+	v.SetDefault("rate_limiting.key_by", RateLimitKeyByAccessKey)
+	v.SetDefault("rate_limiting.max_clients", 10000)
+
+	// Tracing defaults
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.service_name", "s3-encryption-proxy")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("tracing.timeout", 10)
+
+	// Configuration reload defaults
+	v.SetDefault("config_reload.watch_interval", 0)
 
 	// S3 Security defaults
-	viper.SetDefault("s3_security.max_clock_skew_seconds", 900)
-	viper.SetDefault("s3_security.enable_rate_limiting", true)
-	viper.SetDefault("s3_security.max_requests_per_minute", 100)
-	viper.SetDefault("s3_security.enable_security_logging", true)
-	viper.SetDefault("s3_security.max_failed_attempts", 10)
-	viper.SetDefault("s3_security.unblock_ip_seconds", 60)
+	v.SetDefault("authorization.reload_interval", 10)
+	v.SetDefault("s3_security.max_clock_skew_seconds", 900)
+	v.SetDefault("s3_security.enable_rate_limiting", true)
+	v.SetDefault("s3_security.max_requests_per_minute", 100)
+	v.SetDefault("s3_security.enable_security_logging", true)
+	v.SetDefault("s3_security.max_failed_attempts", 10)
+	v.SetDefault("s3_security.unblock_ip_seconds", 60)
 
 }
 
 // validate validates the configuration
 func validate(cfg *Config) error {
+	if err := Check(cfg); err != nil {
+		return err
+	}
+
+	// Check if certificate files exist
+	if cfg.TLS.Enabled {
+		if _, err := os.Stat(cfg.TLS.CertFile); os.IsNotExist(err) {
+			return fmt.Errorf("TLS certificate file does not exist: %s", cfg.TLS.CertFile)
+		}
+		if _, err := os.Stat(cfg.TLS.KeyFile); os.IsNotExist(err) {
+			return fmt.Errorf("TLS key file does not exist: %s", cfg.TLS.KeyFile)
+		}
+	}
+
+	// Validate the license and the provider types it allows
+	return validateLicense(cfg)
+}
+
+// Check validates cfg like Load does, except for what depends on the host it
+// runs on: the license, the existence of the TLS files and the values of
+// ${VAR} references
+func Check(cfg *Config) error {
 	// Use migrated S3 configuration for validation
 	targetEndpoint := cfg.S3Backend.TargetEndpoint
 	if targetEndpoint == "" {
@@ -386,18 +1652,112 @@ func validate(cfg *Config) error {
 		if cfg.TLS.KeyFile == "" {
 			return fmt.Errorf("tls.key_file is required when TLS is enabled")
 		}
+	}
 
-		// Check if certificate files exist
-		if _, err := os.Stat(cfg.TLS.CertFile); os.IsNotExist(err) {
-			return fmt.Errorf("TLS certificate file does not exist: %s", cfg.TLS.CertFile)
-		}
-		if _, err := os.Stat(cfg.TLS.KeyFile); os.IsNotExist(err) {
-			return fmt.Errorf("TLS key file does not exist: %s", cfg.TLS.KeyFile)
-		}
+	if cfg.ConfigReload.WatchInterval < 0 {
+		return fmt.Errorf("config_reload.watch_interval: must not be negative, got %d", cfg.ConfigReload.WatchInterval)
+	}
+
+	// Validate listener limits
+	if err := validateListener(cfg); err != nil {
+		return err
+	}
+
+	// Validate object key canonicalization
+	if err := validateKeyCanonicalization(cfg); err != nil {
+		return err
+	}
+
+	// Validate panic recovery
+	if err := validateRecovery(cfg); err != nil {
+		return err
+	}
+
+	// Validate the audit log
+	if err := validateAudit(cfg); err != nil {
+		return err
+	}
+
+	// Validate the event notifications
+	if err := validateNotifications(cfg); err != nil {
+		return err
+	}
+
+	// Validate the access log
+	if err := validateAccessLog(cfg); err != nil {
+		return err
+	}
+
+	// Validate the span export
+	if err := validateTracing(cfg); err != nil {
+		return err
+	}
+
+	// Validate the client rate limits
+	if err := validateRateLimiting(cfg); err != nil {
+		return err
+	}
+
+	// Validate the multipart session store
+	if err := validateSessionStore(cfg); err != nil {
+		return err
+	}
+	if err := validateReconciliation(cfg); err != nil {
+		return err
+	}
+
+	// Validate backend compatibility settings
+	if err := validateBackendCompatibility(cfg); err != nil {
+		return err
+	}
+
+	// Validate bucket-to-backend routes
+	if err := validateBackendRoutes(cfg); err != nil {
+		return err
+	}
+
+	// Validate backend replicas
+	if err := validateBackendReplicas(cfg); err != nil {
+		return err
+	}
+
+	// Validate replication to a second backend
+	if err := validateReplication(cfg); err != nil {
+		return err
+	}
+
+	// Validate the object cache
+	if err := validateObjectCache(cfg); err != nil {
+		return err
+	}
+
+	// Validate backend response checks
+	if err := validateResponseValidation(cfg); err != nil {
+		return err
+	}
+
+	// Validate server access logging enrichment
+	if err := validateAccessLogging(cfg); err != nil {
+		return err
+	}
+
+	// Validate license re-validation settings
+	if err := validateLicenseConfig(cfg); err != nil {
+		return err
+	}
+
+	// Validate encryption configuration
+	if err := validateEncryption(cfg); err != nil {
+		return err
+	}
+
+	// Validate key preloading
+	if err := validateKeyPreload(cfg); err != nil {
+		return err
 	}
 
-	// Validate license and encryption configuration
-	if err := validateLicenseAndEncryption(cfg); err != nil {
+	// Validate the probe checks
+	if err := validateProbes(cfg); err != nil {
 		return err
 	}
 
@@ -406,17 +1766,47 @@ func validate(cfg *Config) error {
 		return err
 	}
 
+	if err := validateCompression(cfg); err != nil {
+		return err
+	}
+
+	// Validate monitoring configuration
+	if err := validateMonitoring(cfg); err != nil {
+		return err
+	}
+
 	// Validate S3 client authentication configuration
 	if err := validateS3Clients(cfg); err != nil {
 		return err
 	}
 
+	// Validate the canary, which signs with one of the S3 clients
+	if err := validateCanary(cfg); err != nil {
+		return err
+	}
+
+	if err := validateScrubber(cfg); err != nil {
+		return err
+	}
+
+	if err := validateBucketPolicies(cfg); err != nil {
+		return err
+	}
+
+	if err := validateUploadLimits(cfg); err != nil {
+		return err
+	}
+
+	if err := validateTenants(cfg); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // loadProviderConfigs loads provider configurations directly from viper to avoid unmarshaling issues
-func loadProviderConfigs(cfg *Config) error {
-	providersData := viper.Get("encryption.providers")
+func loadProviderConfigs(v *viper.Viper, cfg *Config) error {
+	providersData := v.Get("encryption.providers")
 	if providersData == nil {
 		// No providers configured, that's okay - just leave empty
 		return nil
@@ -490,138 +1880,1591 @@ func createProviderFromProviderMap(providerMap map[string]interface{}) (Encrypti
 		provider.Description = desc
 	}
 
-	// Extract config map directly
-	if configData, exists := providerMap["config"]; exists {
-		if configMap, ok := configData.(map[string]interface{}); ok {
-			provider.Config = configMap
+	// Extract config map directly
+	if configData, exists := providerMap["config"]; exists {
+		if configMap, ok := configData.(map[string]interface{}); ok {
+			provider.Config = configMap
+		}
+	}
+
+	// Map the provider settings next to the config map
+	var settings struct {
+		IntegrityAlgorithm string  `mapstructure:"integrity_algorithm"`
+		RateLimit          float64 `mapstructure:"rate_limit"`
+		RateBurst          int     `mapstructure:"rate_burst"`
+		MaxQueue           int     `mapstructure:"max_queue"`
+		QueueTimeout       int     `mapstructure:"queue_timeout"`
+	}
+	if err := mapstructure.WeakDecode(providerMap, &settings); err != nil {
+		return provider, fmt.Errorf("provider '%s': %w", provider.Alias, err)
+	}
+	provider.IntegrityAlgorithm = settings.IntegrityAlgorithm
+	provider.RateLimit = settings.RateLimit
+	provider.RateBurst = settings.RateBurst
+	provider.MaxQueue = settings.MaxQueue
+	provider.QueueTimeout = settings.QueueTimeout
+
+	return provider, nil
+}
+
+// validateLicenseConfig validates the license tolerance and re-validation settings
+func validateLicenseConfig(cfg *Config) error {
+	if cfg.License.ClockSkewTolerance < 0 {
+		return fmt.Errorf("license.clock_skew_tolerance: must not be negative, got %d", cfg.License.ClockSkewTolerance)
+	}
+	if cfg.License.RevalidationInterval < 0 {
+		return fmt.Errorf("license.revalidation_interval: must not be negative, got %d", cfg.License.RevalidationInterval)
+	}
+	for i, days := range cfg.License.WarningDays {
+		if days <= 0 {
+			return fmt.Errorf("license.warning_days[%d]: must be positive, got %d", i, days)
+		}
+	}
+	if cfg.License.GraceDays < 0 {
+		return fmt.Errorf("license.grace_days: must not be negative, got %d", cfg.License.GraceDays)
+	}
+	switch license.Enforcement(cfg.License.Enforcement) {
+	case "", license.EnforcementRestrict, license.EnforcementShutdown:
+	default:
+		return fmt.Errorf("license.enforcement: must be %q or %q, got %q",
+			license.EnforcementRestrict, license.EnforcementShutdown, cfg.License.Enforcement)
+	}
+	if u := cfg.License.Usage; u.Enabled {
+		if u.StateFile != "" && u.SaveInterval <= 0 {
+			return fmt.Errorf("license.usage.save_interval: must be positive, got %d", u.SaveInterval)
+		}
+		if u.RetentionMonths < 0 {
+			return fmt.Errorf("license.usage.retention_months: must not be negative, got %d", u.RetentionMonths)
+		}
+	}
+	if r := cfg.License.Revocation; r.Source != "" {
+		if strings.HasPrefix(r.Source, "http://") || strings.HasPrefix(r.Source, "https://") {
+			if u, err := url.Parse(r.Source); err != nil || u.Host == "" {
+				return fmt.Errorf("license.revocation.source: invalid URL %q", r.Source)
+			}
+		}
+		if r.RefreshInterval < 0 {
+			return fmt.Errorf("license.revocation.refresh_interval: must not be negative, got %d", r.RefreshInterval)
+		}
+		if r.MaxAge < 0 {
+			return fmt.Errorf("license.revocation.max_age: must not be negative, got %d", r.MaxAge)
+		}
+		if r.MaxAge > 0 && r.MaxAge < r.RefreshInterval {
+			return fmt.Errorf("license.revocation.max_age: must be at least refresh_interval (%d), got %d", r.RefreshInterval, r.MaxAge)
+		}
+	}
+	return nil
+}
+
+// validateCanary validates the synthetic canary settings
+func validateCanary(cfg *Config) error {
+	c := cfg.Canary
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Bucket == "" {
+		return fmt.Errorf("canary.bucket is required when the canary is enabled")
+	}
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("canary.endpoint: must be an http(s) URL, got %q", c.Endpoint)
+		}
+	}
+	if c.AccessKeyID != "" {
+		found := false
+		for _, client := range cfg.S3Clients {
+			if client.AccessKeyID == c.AccessKeyID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("canary.access_key_id: %s is not configured in s3_clients", c.AccessKeyID)
+		}
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("canary.interval: must be positive, got %d", c.Interval)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("canary.timeout: must be positive, got %d", c.Timeout)
+	}
+	if c.ObjectSize <= 0 || c.ObjectSize > 16*1024*1024 {
+		return fmt.Errorf("canary.object_size: must be between 1 and 16MB (16777216 bytes), got %d", c.ObjectSize)
+	}
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("canary.failure_threshold: must be positive, got %d", c.FailureThreshold)
+	}
+	if c.AlertWebhookURL != "" {
+		if u, err := url.Parse(c.AlertWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("canary.alert_webhook_url: must be an http(s) URL")
+		}
+	}
+	return nil
+}
+
+// validateBucketPolicies validates the bucket policy rules
+func validateBucketPolicies(cfg *Config) error {
+	if err := validatePublicRead("bucket_policies.default", cfg.BucketPolicies.Default); err != nil {
+		return err
+	}
+	for i, rule := range cfg.BucketPolicies.Rules {
+		if len(rule.Buckets) == 0 {
+			return fmt.Errorf("bucket_policies.rules[%d].buckets: at least one bucket is required", i)
+		}
+		for _, pattern := range rule.Buckets {
+			if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+				return fmt.Errorf("bucket_policies.rules[%d].buckets: invalid pattern '%s'", i, pattern)
+			}
+		}
+		if err := validatePublicRead(fmt.Sprintf("bucket_policies.rules[%d]", i), rule.BucketPolicy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePublicRead validates the public read settings of a bucket policy
+func validatePublicRead(section string, policy BucketPolicy) error {
+	if len(policy.PublicReadPrefixes) > 0 && !policy.PublicRead {
+		return fmt.Errorf("%s.public_read_prefixes: requires public_read", section)
+	}
+	for _, prefix := range policy.PublicReadPrefixes {
+		if prefix == "" {
+			return fmt.Errorf("%s.public_read_prefixes: empty prefix, omit public_read_prefixes to open every key", section)
+		}
+	}
+	return nil
+}
+
+// BucketPolicyFor returns the policy of bucket: that of the first rule with a
+// matching pattern, else the default policy
+func (cfg *Config) BucketPolicyFor(bucket string) BucketPolicy {
+	for _, rule := range cfg.BucketPolicies.Rules {
+		for _, pattern := range rule.Buckets {
+			if matched, _ := path.Match(pattern, bucket); matched {
+				return rule.BucketPolicy
+			}
+		}
+	}
+	return cfg.BucketPolicies.Default
+}
+
+// validateUploadLimits validates the upload size limits and bucket quotas
+func validateUploadLimits(cfg *Config) error {
+	limits := cfg.UploadLimits
+	if limits.MaxObjectSize < 0 {
+		return fmt.Errorf("upload_limits.max_object_size: must not be negative, got %d", limits.MaxObjectSize)
+	}
+	if limits.MaxPartSize < 0 {
+		return fmt.Errorf("upload_limits.max_part_size: must not be negative, got %d", limits.MaxPartSize)
+	}
+	if limits.MaxObjectSize > 0 && limits.MaxPartSize > limits.MaxObjectSize {
+		return fmt.Errorf("upload_limits.max_part_size: must not exceed max_object_size (%d), got %d", limits.MaxObjectSize, limits.MaxPartSize)
+	}
+	for i, rule := range limits.Quotas {
+		if len(rule.Buckets) == 0 {
+			return fmt.Errorf("upload_limits.quotas[%d].buckets: at least one bucket is required", i)
+		}
+		for _, pattern := range rule.Buckets {
+			if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+				return fmt.Errorf("upload_limits.quotas[%d].buckets: invalid pattern '%s'", i, pattern)
+			}
+		}
+		if rule.MaxBytes <= 0 {
+			return fmt.Errorf("upload_limits.quotas[%d].max_bytes: must be positive, got %d", i, rule.MaxBytes)
+		}
+	}
+	if len(limits.Quotas) > 0 && !cfg.Monitoring.Usage.Enabled {
+		return fmt.Errorf("upload_limits.quotas requires monitoring.usage.enabled (quotas are checked against the collected bucket usage)")
+	}
+	return nil
+}
+
+// QuotaFor returns the quota in bytes of bucket: that of the first rule with
+// a matching pattern, or 0 without a quota
+func (l UploadLimitsConfig) QuotaFor(bucket string) int64 {
+	for _, rule := range l.Quotas {
+		for _, pattern := range rule.Buckets {
+			if matched, _ := path.Match(pattern, bucket); matched {
+				return rule.MaxBytes
+			}
+		}
+	}
+	return 0
+}
+
+// validateScrubber validates the integrity scrubber settings
+func validateScrubber(cfg *Config) error {
+	s := cfg.Scrubber
+	if !s.Enabled {
+		return nil
+	}
+
+	if s.SamplesPerHour <= 0 || s.SamplesPerHour > 3600 {
+		return fmt.Errorf("scrubber.samples_per_hour: must be between 1 and 3600, got %d", s.SamplesPerHour)
+	}
+	if s.ListBatchSize <= 0 {
+		return fmt.Errorf("scrubber.list_batch_size: must be positive, got %d", s.ListBatchSize)
+	}
+	if s.MaxObjectSize < 0 {
+		return fmt.Errorf("scrubber.max_object_size: must not be negative, got %d", s.MaxObjectSize)
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("scrubber.timeout: must be positive, got %d", s.Timeout)
+	}
+	for _, bucket := range s.Buckets {
+		if bucket == "" {
+			return fmt.Errorf("scrubber.buckets: bucket names must not be empty")
+		}
+	}
+	if s.AlertWebhookURL != "" {
+		if u, err := url.Parse(s.AlertWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("scrubber.alert_webhook_url: must be an http(s) URL")
+		}
+	}
+	return nil
+}
+
+// CanaryCredentials returns the S3 client credentials the canary signs with
+func (c *Config) CanaryCredentials() (S3ClientCredentials, bool) {
+	for _, client := range c.S3Clients {
+		if c.Canary.AccessKeyID == "" || client.AccessKeyID == c.Canary.AccessKeyID {
+			return client, true
+		}
+	}
+	return S3ClientCredentials{}, false
+}
+
+// validateCompression validates the codec, level and minimum size of compression
+func validateCompression(cfg *Config) error {
+	if !cfg.Compression.Enabled {
+		return nil
+	}
+	maxLevel := 9
+	switch cfg.Compression.Codec {
+	case "", "gzip":
+	case "zstd":
+		maxLevel = 22
+	default:
+		return fmt.Errorf("compression.codec must be 'gzip' or 'zstd', got: %s", cfg.Compression.Codec)
+	}
+	if cfg.Compression.Level < 0 || cfg.Compression.Level > maxLevel {
+		return fmt.Errorf("compression.level: must be between 0 and %d, got %d", maxLevel, cfg.Compression.Level)
+	}
+	if cfg.Compression.MinSize < 0 {
+		return fmt.Errorf("compression.min_size: must not be negative, got %d", cfg.Compression.MinSize)
+	}
+	return nil
+}
+
+// validateSSEHeaders validates the emulated server-side encryption headers
+func validateSSEHeaders(cfg *Config) error {
+	sse := &cfg.Encryption.SSEHeaders
+	if !sse.Enabled {
+		return nil
+	}
+	if sse.Algorithm == "" {
+		sse.Algorithm = SSEHeaderAlgorithmKMS
+	}
+	switch sse.Algorithm {
+	case SSEHeaderAlgorithmKMS:
+		if sse.KMSKeyID == "" {
+			return fmt.Errorf("encryption.sse_headers.kms_key_id is required with algorithm 'aws:kms'")
+		}
+	case SSEHeaderAlgorithmAES256:
+		if sse.KMSKeyID != "" {
+			return fmt.Errorf("encryption.sse_headers.kms_key_id is only valid with algorithm 'aws:kms'")
+		}
+	default:
+		return fmt.Errorf("encryption.sse_headers.algorithm must be one of: 'aws:kms', 'AES256', got: %s", sse.Algorithm)
+	}
+	return nil
+}
+
+// validateMetadataSidecar validates where encryption metadata is stored
+func validateMetadataSidecar(cfg *Config) error {
+	sidecar := &cfg.Encryption.MetadataSidecar
+	switch sidecar.Mode {
+	case MetadataSidecarModeOff, MetadataSidecarModeAlways:
+	case MetadataSidecarModeOversize:
+		if sidecar.InlineLimit < 1 {
+			return fmt.Errorf("encryption.metadata_sidecar.inline_limit must be at least 1 with mode 'oversize', got: %d", sidecar.InlineLimit)
+		}
+	case "":
+		sidecar.Mode = MetadataSidecarModeOff
+	default:
+		return fmt.Errorf("encryption.metadata_sidecar.mode must be one of: 'off', 'always', 'oversize', got: %s", sidecar.Mode)
+	}
+	if strings.ContainsAny(sidecar.IndexBucket, "/ ") {
+		return fmt.Errorf("encryption.metadata_sidecar.index_bucket must be a bucket name, got: %s", sidecar.IndexBucket)
+	}
+	return nil
+}
+
+// validateMetadataLimits validates the user metadata limits of the backend
+func validateMetadataLimits(cfg *Config) error {
+	limits := cfg.Encryption.MetadataLimits
+	if limits.MaxSize < 0 {
+		return fmt.Errorf("encryption.metadata_limits.max_size cannot be negative, got: %d", limits.MaxSize)
+	}
+	if limits.MaxValueSize < 0 {
+		return fmt.Errorf("encryption.metadata_limits.max_value_size cannot be negative, got: %d", limits.MaxValueSize)
+	}
+	if limits.MaxValueSize > 0 && limits.MaxValueSize < minMetadataValueSize {
+		return fmt.Errorf("encryption.metadata_limits.max_value_size must be at least %d, got: %d", minMetadataValueSize, limits.MaxValueSize)
+	}
+	if limits.MaxSize > 0 && limits.MaxValueSize > limits.MaxSize {
+		return fmt.Errorf("encryption.metadata_limits.max_value_size (%d) cannot exceed max_size (%d)", limits.MaxValueSize, limits.MaxSize)
+	}
+	// Metadata kept inline up to inline_limit would be refused above max_size
+	sidecar := cfg.Encryption.MetadataSidecar
+	if limits.MaxSize > 0 && sidecar.Mode == MetadataSidecarModeOversize && sidecar.InlineLimit > limits.MaxSize {
+		return fmt.Errorf("encryption.metadata_sidecar.inline_limit (%d) cannot exceed encryption.metadata_limits.max_size (%d)", sidecar.InlineLimit, limits.MaxSize)
+	}
+	return nil
+}
+
+// validateLegacyMetadataPrefixes validates the prefixes of objects written
+// before metadata_key_prefix was changed
+func validateLegacyMetadataPrefixes(cfg *Config) error {
+	current := "s3ep-"
+	if cfg.Encryption.MetadataKeyPrefix != nil {
+		current = *cfg.Encryption.MetadataKeyPrefix
+	}
+	seen := make(map[string]bool)
+	for i, prefix := range cfg.Encryption.LegacyMetadataKeyPrefixes {
+		switch lower := strings.ToLower(prefix); {
+		case prefix == "":
+			return fmt.Errorf("encryption.legacy_metadata_key_prefixes[%d] must not be empty", i)
+		case lower == strings.ToLower(current):
+			return fmt.Errorf("encryption.legacy_metadata_key_prefixes[%d] is the current metadata_key_prefix '%s'", i, prefix)
+		case seen[lower]:
+			return fmt.Errorf("encryption.legacy_metadata_key_prefixes[%d] '%s' is listed twice", i, prefix)
+		default:
+			seen[lower] = true
+		}
+	}
+
+	switch cfg.Encryption.MetadataPrefixMigration {
+	case MetadataPrefixMigrationOff:
+	case MetadataPrefixMigrationOnRead:
+		if len(cfg.Encryption.LegacyMetadataKeyPrefixes) == 0 {
+			return fmt.Errorf("encryption.metadata_prefix_migration 'on_read' requires encryption.legacy_metadata_key_prefixes")
+		}
+	case "":
+		cfg.Encryption.MetadataPrefixMigration = MetadataPrefixMigrationOff
+	default:
+		return fmt.Errorf("encryption.metadata_prefix_migration must be one of: 'off', 'on_read', got: %s", cfg.Encryption.MetadataPrefixMigration)
+	}
+	return nil
+}
+
+// validateKeyPreload validates the key preload intervals
+func validateKeyPreload(cfg *Config) error {
+	if !cfg.KeyPreload.Enabled {
+		return nil
+	}
+	if cfg.KeyPreload.RefreshInterval <= 0 {
+		return fmt.Errorf("key_preload.refresh_interval: must be positive, got %d", cfg.KeyPreload.RefreshInterval)
+	}
+	if cfg.KeyPreload.Timeout <= 0 {
+		return fmt.Errorf("key_preload.timeout: must be positive, got %d", cfg.KeyPreload.Timeout)
+	}
+	return nil
+}
+
+// validateProbes validates the probe check intervals
+func validateProbes(cfg *Config) error {
+	p := cfg.Probes
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"backend_interval", p.BackendInterval},
+		{"provider_interval", p.ProviderInterval},
+		{"license_interval", p.LicenseInterval},
+		{"timeout", p.Timeout},
+	} {
+		if setting.value <= 0 {
+			return fmt.Errorf("probes.%s: must be positive, got %d", setting.name, setting.value)
+		}
+	}
+	return nil
+}
+
+// validateLicense validates the license and that it allows the active provider type
+func validateLicense(cfg *Config) error {
+	// Load and validate license
+	licenseToken := license.LoadLicense(cfg.LicenseFile)
+	validator := license.NewValidatorWithOptions(cfg.GetLicenseOptions())
+	result := validator.ValidateLicense(licenseToken)
+
+	// Log license information
+	license.LogLicenseInfo(result)
+
+	// Check if encryption provider requires license
+	if cfg.Encryption.EncryptionMethodAlias != "" {
+		// Find the active provider
+		for _, provider := range cfg.Encryption.Providers {
+			if provider.Alias == cfg.Encryption.EncryptionMethodAlias {
+				// Log provider restriction info
+				license.LogProviderRestriction(provider.Type, provider.Alias, result.Valid)
+
+				// Validate provider type against license
+				if err := validator.ValidateProviderType(provider.Type); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateEncryption validates the encryption configuration
+func validateEncryption(cfg *Config) error {
+	// Validate HMAC verification mode
+	switch cfg.Encryption.IntegrityVerification {
+	case HMACVerificationOff, HMACVerificationLax, HMACVerificationStrict, HMACVerificationHybrid:
+		// Valid values
+	case "": // Default to off if not specified
+		cfg.Encryption.IntegrityVerification = HMACVerificationOff
+	default:
+		return fmt.Errorf("encryption.integrity_verification must be one of: 'off', 'lax', 'strict', 'hybrid', got: %s", cfg.Encryption.IntegrityVerification)
+	}
+	switch cfg.Encryption.SSECMode {
+	case SSECModeReject, SSECModePassthrough:
+	case "":
+		cfg.Encryption.SSECMode = SSECModeReject
+	default:
+		return fmt.Errorf("encryption.sse_c_mode must be one of: 'reject', 'passthrough', got: %s", cfg.Encryption.SSECMode)
+	}
+	switch cfg.Encryption.ETagMode {
+	case ETagModeBackend, ETagModePlaintext:
+	case "":
+		cfg.Encryption.ETagMode = ETagModeBackend
+	default:
+		return fmt.Errorf("encryption.etag_mode must be one of: 'backend', 'plaintext', got: %s", cfg.Encryption.ETagMode)
+	}
+	if err := validateSSEHeaders(cfg); err != nil {
+		return err
+	}
+	if err := validateIntegrityAlgorithms(cfg); err != nil {
+		return err
+	}
+	if err := validateRecoveryRecipients(cfg); err != nil {
+		return err
+	}
+	if err := validateMetadataSidecar(cfg); err != nil {
+		return err
+	}
+	if err := validateMetadataLimits(cfg); err != nil {
+		return err
+	}
+	if err := validateLegacyMetadataPrefixes(cfg); err != nil {
+		return err
+	}
+
+	// If using new encryption config format
+	if cfg.Encryption.EncryptionMethodAlias != "" || len(cfg.Encryption.Providers) > 0 {
+		// Validate that encryption_method_alias is specified
+		if cfg.Encryption.EncryptionMethodAlias == "" {
+			return fmt.Errorf("encryption.encryption_method_alias is required when using encryption.providers")
+		}
+
+		// Validate that providers list is not empty
+		if len(cfg.Encryption.Providers) == 0 {
+			return fmt.Errorf("encryption.providers cannot be empty")
+		}
+
+		// Find the active provider
+		var activeProvider *EncryptionProvider
+		aliasMap := make(map[string]bool)
+
+		for i := range cfg.Encryption.Providers {
+			provider := &cfg.Encryption.Providers[i]
+
+			// Validate provider fields
+			if provider.Alias == "" {
+				return fmt.Errorf("encryption.providers[%d].alias is required", i)
+			}
+
+			// Check for duplicate aliases
+			if aliasMap[provider.Alias] {
+				return fmt.Errorf("duplicate encryption provider alias: %s", provider.Alias)
+			}
+			aliasMap[provider.Alias] = true
+
+			// Validate provider type and required fields
+			if err := validateProvider(provider, i); err != nil {
+				return err
+			}
+
+			// aes-gcm-siv holds whole objects in memory, so every object below
+			// the streaming threshold must fit its limit
+			if provider.DataCipher == "aes-gcm-siv" && cfg.GetStreamingThreshold() > dataencryption.GCMSIVMaxSize {
+				return fmt.Errorf("encryption.providers[%d].data_cipher 'aes-gcm-siv' requires optimizations.streaming_threshold of at most %d bytes, got %d", i, dataencryption.GCMSIVMaxSize, cfg.GetStreamingThreshold())
+			}
+
+			// Check if this is the active provider
+			if provider.Alias == cfg.Encryption.EncryptionMethodAlias {
+				activeProvider = provider
+			}
+		}
+
+		// Validate that the active provider exists
+		if activeProvider == nil {
+			return fmt.Errorf("encryption_method_alias '%s' does not match any provider alias", cfg.Encryption.EncryptionMethodAlias)
+		}
+
+		return validateSelectableProviders(cfg, activeProvider)
+	}
+	if len(cfg.Encryption.SelectableProviders) > 0 {
+		return fmt.Errorf("encryption.selectable_providers requires encryption.providers")
+	}
+
+	// If no explicit alias but providers exist, validate at least
+	// Note: Having no providers is valid for non-encryption use cases (e.g., TLS only)
+
+	return nil
+}
+
+// validateIntegrityAlgorithms validates the integrity MAC selection
+func validateIntegrityAlgorithms(cfg *Config) error {
+	if cfg.Encryption.IntegrityAlgorithm != "" && !IsIntegrityAlgorithm(cfg.Encryption.IntegrityAlgorithm) {
+		return fmt.Errorf("encryption.integrity_algorithm must be one of: 'hmac-sha256', 'hmac-sha512', 'blake3-keyed', got: %s", cfg.Encryption.IntegrityAlgorithm)
+	}
+	for i, provider := range cfg.Encryption.Providers {
+		if provider.IntegrityAlgorithm != "" && !IsIntegrityAlgorithm(provider.IntegrityAlgorithm) {
+			return fmt.Errorf("encryption.providers[%d].integrity_algorithm must be one of: 'hmac-sha256', 'hmac-sha512', 'blake3-keyed', got: %s", i, provider.IntegrityAlgorithm)
+		}
+	}
+
+	patterns := make(map[string]bool)
+	for i, override := range cfg.Encryption.IntegrityAlgorithmOverrides {
+		if !IsIntegrityAlgorithm(override.Algorithm) {
+			return fmt.Errorf("encryption.integrity_algorithm_overrides[%d].algorithm must be one of: 'hmac-sha256', 'hmac-sha512', 'blake3-keyed', got: %s", i, override.Algorithm)
+		}
+		if len(override.Buckets) == 0 {
+			return fmt.Errorf("encryption.integrity_algorithm_overrides[%d].buckets: at least one bucket is required", i)
+		}
+		for _, pattern := range override.Buckets {
+			if pattern == "" || pattern == "*" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return fmt.Errorf("encryption.integrity_algorithm_overrides[%d].buckets: invalid pattern '%s' (use a bucket name, optionally ending in '*')", i, pattern)
+			}
+			if patterns[pattern] {
+				return fmt.Errorf("encryption.integrity_algorithm_overrides[%d].buckets: pattern '%s' is already used", i, pattern)
+			}
+			patterns[pattern] = true
+		}
+	}
+	return nil
+}
+
+// IsIntegrityAlgorithm reports whether name is a supported integrity MAC algorithm
+func IsIntegrityAlgorithm(name string) bool {
+	switch name {
+	case IntegrityAlgorithmHMACSHA256, IntegrityAlgorithmHMACSHA512, IntegrityAlgorithmBLAKE3:
+		return true
+	}
+	return false
+}
+
+// IntegrityAlgorithmFor returns the integrity MAC for new objects in bucket:
+// an exact bucket override, then the longest matching prefix override, then
+// the active provider's algorithm, then encryption.integrity_algorithm
+func (cfg *Config) IntegrityAlgorithmFor(bucket string) string {
+	return cfg.IntegrityAlgorithmForProvider(bucket, "")
+}
+
+// IntegrityAlgorithmForProvider is IntegrityAlgorithmFor for objects whose
+// DEK is wrapped by the provider with alias instead of the active one. An
+// empty alias selects the active provider.
+func (cfg *Config) IntegrityAlgorithmForProvider(bucket, alias string) string {
+	if bucket != "" {
+		prefixMatch, prefixLen := "", -1
+		for _, override := range cfg.Encryption.IntegrityAlgorithmOverrides {
+			for _, pattern := range override.Buckets {
+				if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+					if strings.HasPrefix(bucket, prefix) && len(prefix) > prefixLen {
+						prefixMatch, prefixLen = override.Algorithm, len(prefix)
+					}
+				} else if pattern == bucket {
+					return override.Algorithm
+				}
+			}
+		}
+		if prefixLen >= 0 {
+			return prefixMatch
+		}
+	}
+
+	if alias == "" {
+		alias = cfg.Encryption.EncryptionMethodAlias
+	}
+	if provider := cfg.providerByAlias(alias); provider != nil && provider.IntegrityAlgorithm != "" {
+		return provider.IntegrityAlgorithm
+	}
+	if cfg.Encryption.IntegrityAlgorithm != "" {
+		return cfg.Encryption.IntegrityAlgorithm
+	}
+	return IntegrityAlgorithmHMACSHA256
+}
+
+// maxRecoveryRecipients keeps the recovery copies within the S3 metadata limit
+const maxRecoveryRecipients = 5
+
+// validateRecoveryRecipients validates the age recovery recipients
+func validateRecoveryRecipients(cfg *Config) error {
+	recipients := cfg.Encryption.RecoveryRecipients
+	if len(recipients) > maxRecoveryRecipients {
+		return fmt.Errorf("encryption.recovery_recipients: at most %d recipients are supported, got %d", maxRecoveryRecipients, len(recipients))
+	}
+	for i, recipient := range recipients {
+		if _, err := keyencryption.ParseAgeRecipient(recipient); err != nil {
+			return fmt.Errorf("encryption.recovery_recipients[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateSelectableProviders validates the providers clients may select per
+// object. Objects of the none provider bypass encryption entirely, so it can
+// neither be selected nor be active while others are selectable.
+func validateSelectableProviders(cfg *Config, activeProvider *EncryptionProvider) error {
+	if len(cfg.Encryption.SelectableProviders) == 0 {
+		return nil
+	}
+	if activeProvider.Type == "none" {
+		return fmt.Errorf("encryption.selectable_providers cannot be used while the none provider is active")
+	}
+
+	selected := make(map[string]bool)
+	for i, alias := range cfg.Encryption.SelectableProviders {
+		provider := cfg.providerByAlias(alias)
+		if provider == nil {
+			return fmt.Errorf("encryption.selectable_providers[%d]: '%s' does not match any provider alias", i, alias)
+		}
+		if provider.Type == "none" {
+			return fmt.Errorf("encryption.selectable_providers[%d]: the none provider '%s' cannot be selected", i, alias)
+		}
+		if selected[alias] {
+			return fmt.Errorf("encryption.selectable_providers[%d]: '%s' is already listed", i, alias)
+		}
+		selected[alias] = true
+	}
+	return nil
+}
+
+// validateTenants validates the tenants and their dedicated providers. A
+// tenant provider must be reserved to its tenant: it can be neither active
+// nor selectable nor shared with another tenant, and it must encrypt.
+func validateTenants(cfg *Config) error {
+	names := make(map[string]bool)
+	providers := make(map[string]bool)
+	accessKeys := make(map[string]bool)
+	patterns := make(map[string]bool)
+	for i, tenant := range cfg.Encryption.Tenants {
+		if tenant.Name == "" {
+			return fmt.Errorf("encryption.tenants[%d].name is required", i)
+		}
+		if names[tenant.Name] {
+			return fmt.Errorf("encryption.tenants[%d].name: '%s' is already used", i, tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		provider := cfg.providerByAlias(tenant.Provider)
+		switch {
+		case provider == nil:
+			return fmt.Errorf("encryption.tenants[%d].provider: '%s' does not match any provider alias", i, tenant.Provider)
+		case provider.Type == "none":
+			return fmt.Errorf("encryption.tenants[%d].provider: the none provider '%s' cannot be dedicated to a tenant", i, tenant.Provider)
+		case cfg.IsSelectableProvider(tenant.Provider):
+			return fmt.Errorf("encryption.tenants[%d].provider: '%s' is active or selectable and cannot be dedicated to a tenant", i, tenant.Provider)
+		case providers[tenant.Provider]:
+			return fmt.Errorf("encryption.tenants[%d].provider: '%s' is already dedicated to another tenant", i, tenant.Provider)
+		}
+		providers[tenant.Provider] = true
+
+		if len(tenant.AccessKeys) == 0 && len(tenant.Buckets) == 0 {
+			return fmt.Errorf("encryption.tenants[%d]: at least one access key or bucket is required", i)
+		}
+		for _, accessKey := range tenant.AccessKeys {
+			if !slices.ContainsFunc(cfg.S3Clients, func(client S3ClientCredentials) bool { return client.AccessKeyID == accessKey }) {
+				return fmt.Errorf("encryption.tenants[%d].access_keys: '%s' does not match any s3_clients entry", i, accessKey)
+			}
+			if accessKeys[accessKey] {
+				return fmt.Errorf("encryption.tenants[%d].access_keys: '%s' already belongs to a tenant", i, accessKey)
+			}
+			accessKeys[accessKey] = true
+		}
+		for _, pattern := range tenant.Buckets {
+			if pattern == "" || pattern == "*" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return fmt.Errorf("encryption.tenants[%d].buckets: invalid pattern '%s' (use a bucket name, optionally ending in '*')", i, pattern)
+			}
+			if patterns[pattern] {
+				return fmt.Errorf("encryption.tenants[%d].buckets: pattern '%s' already belongs to a tenant", i, pattern)
+			}
+			patterns[pattern] = true
+		}
+	}
+	return nil
+}
+
+// TenantFor returns the tenant of a request signed with accessKeyID to
+// bucket: the tenant the access key belongs to, else the one with an exact
+// bucket pattern, else the one with the longest matching prefix pattern. It
+// returns nil for requests of no tenant.
+func (cfg *Config) TenantFor(accessKeyID, bucket string) *TenantConfig {
+	tenants := cfg.Encryption.Tenants
+	if accessKeyID != "" {
+		for i := range tenants {
+			if slices.Contains(tenants[i].AccessKeys, accessKeyID) {
+				return &tenants[i]
+			}
+		}
+	}
+	if bucket == "" {
+		return nil
+	}
+	var prefixMatch *TenantConfig
+	prefixLen := -1
+	for i := range tenants {
+		for _, pattern := range tenants[i].Buckets {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				if strings.HasPrefix(bucket, prefix) && len(prefix) > prefixLen {
+					prefixMatch, prefixLen = &tenants[i], len(prefix)
+				}
+			} else if pattern == bucket {
+				return &tenants[i]
+			}
+		}
+	}
+	return prefixMatch
+}
+
+// TenantOfProvider returns the tenant the provider with alias is dedicated
+// to, or nil for shared providers
+func (cfg *Config) TenantOfProvider(alias string) *TenantConfig {
+	for i := range cfg.Encryption.Tenants {
+		if cfg.Encryption.Tenants[i].Provider == alias {
+			return &cfg.Encryption.Tenants[i]
+		}
+	}
+	return nil
+}
+
+// providerByAlias returns the configured provider with alias, or nil
+func (cfg *Config) providerByAlias(alias string) *EncryptionProvider {
+	for i := range cfg.Encryption.Providers {
+		if cfg.Encryption.Providers[i].Alias == alias {
+			return &cfg.Encryption.Providers[i]
+		}
+	}
+	return nil
+}
+
+// IsSelectableProvider reports whether clients may select the provider with
+// alias for new objects: the active provider and those listed in
+// encryption.selectable_providers
+func (cfg *Config) IsSelectableProvider(alias string) bool {
+	return alias == cfg.Encryption.EncryptionMethodAlias || slices.Contains(cfg.Encryption.SelectableProviders, alias)
+}
+
+// validateProvider validates a single encryption provider
+func validateProvider(provider *EncryptionProvider, index int) error {
+	switch provider.Type {
+	case "tink":
+		return fmt.Errorf("encryption.providers[%d]: tink encryption is not yet implemented with the new architecture", index)
+	case "aes":
+		if aesKey, ok := provider.Config["aes_key"].(string); !ok || aesKey == "" {
+			return fmt.Errorf("encryption.providers[%d]: aes_key is required when using aes encryption", index)
+		}
+	case "rsa":
+		if publicKeyPEM, ok := provider.Config["public_key_pem"].(string); !ok || publicKeyPEM == "" {
+			return fmt.Errorf("encryption.providers[%d]: public_key_pem is required when using rsa encryption", index)
+		}
+		if privateKeyPEM, ok := provider.Config["private_key_pem"].(string); !ok || privateKeyPEM == "" {
+			return fmt.Errorf("encryption.providers[%d]: private_key_pem is required when using rsa encryption", index)
+		}
+	case "vault-transit":
+		if err := validateVaultTransitProvider(provider, index); err != nil {
+			return err
+		}
+	case "none":
+		// No validation needed for "none" provider - no encryption parameters required
+	default:
+		return fmt.Errorf("encryption.providers[%d].type: unsupported encryption type: %s (supported: aes, rsa, vault-transit, none)", index, provider.Type)
+	}
+
+	switch provider.DataCipher {
+	case "", "aes", "chacha20", "aes-gcm-siv", "auto":
+	default:
+		return fmt.Errorf("encryption.providers[%d].data_cipher must be one of: 'aes', 'chacha20', 'aes-gcm-siv', 'auto', got: %s", index, provider.DataCipher)
+	}
+
+	return validateProviderRateLimit(provider, index)
+}
+
+// validateVaultTransitProvider validates the Vault address, Transit key and
+// the credentials of the auth method of a vault-transit provider
+func validateVaultTransitProvider(provider *EncryptionProvider, index int) error {
+	option := func(key string) string {
+		value, _ := provider.Config[key].(string)
+		return value
+	}
+	for _, key := range []string{"address", "key_name"} {
+		if option(key) == "" {
+			return fmt.Errorf("encryption.providers[%d]: %s is required when using vault-transit encryption", index, key)
+		}
+	}
+
+	var required []string
+	switch method := option("auth_method"); method {
+	case "", "token":
+		required = []string{"token"}
+	case "approle":
+		required = []string{"role_id", "secret_id"}
+	case "kubernetes":
+		required = []string{"kubernetes_role"}
+	default:
+		return fmt.Errorf("encryption.providers[%d].config.auth_method: unsupported vault auth method '%s' (supported: token, approle, kubernetes)", index, method)
+	}
+	for _, key := range required {
+		if option(key) == "" {
+			return fmt.Errorf("encryption.providers[%d]: %s is required for vault %s auth", index, key, cmp.Or(option("auth_method"), "token"))
+		}
+	}
+	return nil
+}
+
+// validateProviderRateLimit validates the key operation rate limit of a provider
+func validateProviderRateLimit(provider *EncryptionProvider, index int) error {
+	if provider.RateLimit < 0 {
+		return fmt.Errorf("encryption.providers[%d].rate_limit: must not be negative, got %g", index, provider.RateLimit)
+	}
+	if provider.RateBurst < 0 {
+		return fmt.Errorf("encryption.providers[%d].rate_burst: must not be negative, got %d", index, provider.RateBurst)
+	}
+	if provider.MaxQueue < 0 {
+		return fmt.Errorf("encryption.providers[%d].max_queue: must not be negative, got %d", index, provider.MaxQueue)
+	}
+	if provider.QueueTimeout < 0 {
+		return fmt.Errorf("encryption.providers[%d].queue_timeout: must not be negative, got %d", index, provider.QueueTimeout)
+	}
+	if provider.RateLimit == 0 && (provider.RateBurst > 0 || provider.MaxQueue > 0 || provider.QueueTimeout > 0) {
+		return fmt.Errorf("encryption.providers[%d]: rate_burst, max_queue and queue_timeout require rate_limit", index)
+	}
+	return nil
+}
+
+// validateBackendCompatibility validates the backend compatibility profile and overrides
+func validateBackendCompatibility(cfg *Config) error {
+	if profile := cfg.S3Backend.CompatibilityProfile; profile != "" {
+		if _, ok := backendProfiles[profile]; !ok {
+			return fmt.Errorf("s3_backend.compatibility_profile: unknown profile '%s' (valid: generic, aws, minio, ceph)", profile)
+		}
+	}
+
+	switch cfg.S3Backend.MultipartMetadataPhase {
+	case "", MultipartMetadataPhaseCopy, MultipartMetadataPhaseComplete:
+		return nil
+	default:
+		return fmt.Errorf("s3_backend.multipart_metadata_phase: invalid value '%s' (valid: copy, complete)", cfg.S3Backend.MultipartMetadataPhase)
+	}
+}
+
+// validateResponseValidation validates the backend response checks
+func validateResponseValidation(cfg *Config) error {
+	if retries := cfg.S3Backend.ResponseValidation.MaxRetries; retries < 0 || retries > 10 {
+		return fmt.Errorf("s3_backend.response_validation.max_retries: must be between 0 and 10, got %d", retries)
+	}
+	return nil
+}
+
+// validateBackendRoutes validates the bucket-to-backend routing table
+func validateBackendRoutes(cfg *Config) error {
+	if cfg.S3Backend.RouteHealthCheckInterval < 0 {
+		return fmt.Errorf("s3_backend.route_health_check_interval: must not be negative, got %d", cfg.S3Backend.RouteHealthCheckInterval)
+	}
+
+	names := make(map[string]bool)
+	patterns := make(map[string]string)
+	for i, route := range cfg.S3Backend.Routes {
+		if route.Name == "" {
+			return fmt.Errorf("s3_backend.routes[%d].name is required", i)
+		}
+		if route.Name == DefaultBackendRouteName || names[route.Name] {
+			return fmt.Errorf("s3_backend.routes[%d].name: '%s' is reserved or already used", i, route.Name)
+		}
+		names[route.Name] = true
+
+		if route.TargetEndpoint == "" {
+			return fmt.Errorf("s3_backend.routes[%d].target_endpoint is required", i)
+		}
+		if len(route.Buckets) == 0 {
+			return fmt.Errorf("s3_backend.routes[%d].buckets: at least one bucket is required", i)
+		}
+		for _, pattern := range route.Buckets {
+			if pattern == "" || pattern == "*" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return fmt.Errorf("s3_backend.routes[%d].buckets: invalid pattern '%s' (use a bucket name, optionally ending in '*')", i, pattern)
+			}
+			if other, ok := patterns[pattern]; ok {
+				return fmt.Errorf("s3_backend.routes[%d].buckets: pattern '%s' is already routed to '%s'", i, pattern, other)
+			}
+			patterns[pattern] = route.Name
+		}
+	}
+	return nil
+}
+
+// validateBackendReplicas validates the replicas of the default backend
+func validateBackendReplicas(cfg *Config) error {
+	b := cfg.S3Backend
+	switch b.ReadPreference {
+	case "", ReadPreferencePrimary, ReadPreferenceNearest:
+	default:
+		return fmt.Errorf("s3_backend.read_preference: must be '%s' or '%s', got '%s'", ReadPreferencePrimary, ReadPreferenceNearest, b.ReadPreference)
+	}
+	if len(b.Replicas) == 0 {
+		return nil
+	}
+	if b.ReplicaHealthCheckInterval < 1 {
+		return fmt.Errorf("s3_backend.replica_health_check_interval: must be at least 1 with replicas, got %d", b.ReplicaHealthCheckInterval)
+	}
+
+	names := map[string]bool{DefaultBackendRouteName: true}
+	for i, replica := range b.Replicas {
+		if replica.Name == "" {
+			return fmt.Errorf("s3_backend.replicas[%d].name is required", i)
+		}
+		if names[replica.Name] {
+			return fmt.Errorf("s3_backend.replicas[%d].name: '%s' is reserved or already used", i, replica.Name)
+		}
+		names[replica.Name] = true
+		if replica.TargetEndpoint == "" {
+			return fmt.Errorf("s3_backend.replicas[%d].target_endpoint is required", i)
+		}
+		if (replica.AccessKeyID == "") != (replica.SecretKey == "") {
+			return fmt.Errorf("s3_backend.replicas[%d]: access_key_id and secret_key must be set together", i)
+		}
+	}
+	return nil
+}
+
+// validateReplication validates the replication target and queue
+func validateReplication(cfg *Config) error {
+	r := cfg.Replication
+	if !r.Enabled {
+		return nil
+	}
+	if r.TargetEndpoint == "" {
+		return fmt.Errorf("replication.target_endpoint is required when replication is enabled")
+	}
+	if (r.AccessKeyID == "") != (r.SecretKey == "") {
+		return fmt.Errorf("replication: access_key_id and secret_key must be set together")
+	}
+	for _, pattern := range r.Buckets {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("replication.buckets: invalid pattern '%s'", pattern)
+		}
+	}
+	if r.QueueSize < 1 {
+		return fmt.Errorf("replication.queue_size: must be at least 1, got %d", r.QueueSize)
+	}
+	if r.Workers < 1 {
+		return fmt.Errorf("replication.workers: must be at least 1, got %d", r.Workers)
+	}
+	if r.MaxRetries < 0 {
+		return fmt.Errorf("replication.max_retries: must not be negative, got %d", r.MaxRetries)
+	}
+	if r.RetryBackoff < 1 {
+		return fmt.Errorf("replication.retry_backoff: must be at least 1, got %d", r.RetryBackoff)
+	}
+	return nil
+}
+
+// validateObjectCache validates the object cache tiers
+func validateObjectCache(cfg *Config) error {
+	c := cfg.ObjectCache
+	if !c.Enabled {
+		return nil
+	}
+	if c.TTL < 1 {
+		return fmt.Errorf("object_cache.ttl: must be at least 1, got %d", c.TTL)
+	}
+	if c.MaxObjectSize < 1 {
+		return fmt.Errorf("object_cache.max_object_size: must be positive, got %d", c.MaxObjectSize)
+	}
+	if c.MemorySize < 0 {
+		return fmt.Errorf("object_cache.memory_size: must not be negative, got %d", c.MemorySize)
+	}
+	if c.MemorySize == 0 && c.DiskDirectory == "" {
+		return fmt.Errorf("object_cache: memory_size or disk_directory is required")
+	}
+	if c.MemorySize > 0 && c.MemorySize < c.MaxObjectSize {
+		return fmt.Errorf("object_cache.memory_size: must be at least max_object_size (%d), got %d", c.MaxObjectSize, c.MemorySize)
+	}
+	if c.DiskDirectory != "" && c.DiskSize < c.MaxObjectSize {
+		return fmt.Errorf("object_cache.disk_size: must be at least max_object_size (%d), got %d", c.MaxObjectSize, c.DiskSize)
+	}
+	for _, pattern := range c.Buckets {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("object_cache.buckets: invalid pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// validateAccessLogging validates the server access logging enrichment
+func validateAccessLogging(cfg *Config) error {
+	logging := cfg.S3Backend.AccessLogging
+	if strings.HasPrefix(logging.TargetPrefix, "/") {
+		return fmt.Errorf("s3_backend.access_logging.target_prefix: must not start with '/', got '%s'", logging.TargetPrefix)
+	}
+	if len(logging.TargetPrefix) > 256 {
+		return fmt.Errorf("s3_backend.access_logging.target_prefix: maximum length is 256 characters, got %d", len(logging.TargetPrefix))
+	}
+	// The SDK replaces anything outside the HTTP token characters with '-'
+	for _, r := range logging.UserAgentTag {
+		isAlnum := r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z'
+		if !isAlnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return fmt.Errorf("s3_backend.access_logging.user_agent_tag: invalid character %q in '%s'", r, logging.UserAgentTag)
+		}
+	}
+	return nil
+}
+
+// validateListener validates the client listener limits
+func validateListener(cfg *Config) error {
+	l := cfg.Listener
+	if l.MaxHeaderBytes < 0 {
+		return fmt.Errorf("listener.max_header_bytes: must not be negative, got %d", l.MaxHeaderBytes)
+	}
+	if l.MaxHeaderBytes > 0 && l.MaxHeaderBytes < 8*1024 {
+		return fmt.Errorf("listener.max_header_bytes: minimum value is 8KB (8192 bytes), got %d", l.MaxHeaderBytes)
+	}
+	if l.ReadHeaderTimeout < 0 {
+		return fmt.Errorf("listener.read_header_timeout: must not be negative, got %d", l.ReadHeaderTimeout)
+	}
+	if l.MaxQueryParams < 0 {
+		return fmt.Errorf("listener.max_query_params: must not be negative, got %d", l.MaxQueryParams)
+	}
+	if l.MaxContentLength < 0 {
+		return fmt.Errorf("listener.max_content_length: must not be negative, got %d", l.MaxContentLength)
+	}
+	if l.HSTSMaxAge < 0 {
+		return fmt.Errorf("listener.hsts_max_age: must not be negative, got %d", l.HSTSMaxAge)
+	}
+	for i, host := range l.AllowedHosts {
+		if strings.TrimSpace(host) == "" || strings.ContainsAny(host, ":/ ") {
+			return fmt.Errorf("listener.allowed_hosts[%d]: must be a bare host name without port or scheme, got %q", i, host)
+		}
+	}
+	return nil
+}
+
+// validateKeyCanonicalization validates the object key canonicalization
+// settings. Empty values select the defaults.
+func validateKeyCanonicalization(cfg *Config) error {
+	k := cfg.KeyCanonicalization
+	switch k.Slashes {
+	case "", KeySlashesPreserve, KeySlashesCollapse:
+	default:
+		return fmt.Errorf("key_canonicalization.slashes: must be %q or %q, got %q", KeySlashesPreserve, KeySlashesCollapse, k.Slashes)
+	}
+	switch k.DotSegments {
+	case "", KeyDotSegmentsPreserve, KeyDotSegmentsRemove, KeyDotSegmentsReject:
+	default:
+		return fmt.Errorf("key_canonicalization.dot_segments: must be %q, %q or %q, got %q",
+			KeyDotSegmentsPreserve, KeyDotSegmentsRemove, KeyDotSegmentsReject, k.DotSegments)
+	}
+	switch k.EncodedSlashes {
+	case "", KeyEncodedSlashesDecode, KeyEncodedSlashesReject:
+	default:
+		return fmt.Errorf("key_canonicalization.encoded_slashes: must be %q or %q, got %q", KeyEncodedSlashesDecode, KeyEncodedSlashesReject, k.EncodedSlashes)
+	}
+	return nil
+}
+
+// validateRecovery validates the panic recovery configuration
+func validateRecovery(cfg *Config) error {
+	if cfg.Recovery.MaxCrashBundles < 0 {
+		return fmt.Errorf("recovery.max_crash_bundles: must not be negative, got %d", cfg.Recovery.MaxCrashBundles)
+	}
+	if cfg.Recovery.CrashBundleDir != "" && cfg.Recovery.MaxCrashBundles == 0 {
+		return fmt.Errorf("recovery.max_crash_bundles: must be positive when recovery.crash_bundle_dir is set")
+	}
+	return nil
+}
+
+// validateAudit validates the audit log settings
+func validateAudit(cfg *Config) error {
+	if err := validateAuditSinks(cfg); err != nil {
+		return err
+	}
+	if !cfg.Audit.Enabled {
+		return nil
+	}
+	if cfg.Audit.Directory == "" {
+		return fmt.Errorf("audit.directory is required when the audit log is enabled")
+	}
+	if cfg.Audit.SigningKeyFile == "" {
+		return fmt.Errorf("audit.signing_key_file is required when the audit log is enabled")
+	}
+	if cfg.Audit.SegmentMaxRecords < 1 {
+		return fmt.Errorf("audit.segment_max_records: must be at least 1, got %d", cfg.Audit.SegmentMaxRecords)
+	}
+	if cfg.Audit.SegmentMaxAge < 0 {
+		return fmt.Errorf("audit.segment_max_age: must not be negative, got %d", cfg.Audit.SegmentMaxAge)
+	}
+	return nil
+}
+
+// validateTracing validates the OpenTelemetry tracing settings
+func validateTracing(cfg *Config) error {
+	t := cfg.Tracing
+	if !t.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("tracing.endpoint: must be an http(s) URL, got %q", t.Endpoint)
+	}
+	if t.ServiceName == "" {
+		return fmt.Errorf("tracing.service_name is required when tracing is enabled")
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio: must be between 0 and 1, got %g", t.SampleRatio)
+	}
+	if t.Timeout < 1 {
+		return fmt.Errorf("tracing.timeout: must be at least 1, got %d", t.Timeout)
+	}
+	return nil
+}
+
+// validateRateLimiting validates the per-client rate and bandwidth limits
+func validateRateLimiting(cfg *Config) error {
+	rl := cfg.RateLimiting
+	if !rl.Enabled {
+		return nil
+	}
+	if rl.KeyBy != RateLimitKeyByAccessKey && rl.KeyBy != RateLimitKeyByIP {
+		return fmt.Errorf("rate_limiting.key_by: must be %s or %s, got %q", RateLimitKeyByAccessKey, RateLimitKeyByIP, rl.KeyBy)
+	}
+	if rl.MaxClients < 1 {
+		return fmt.Errorf("rate_limiting.max_clients: must be at least 1, got %d", rl.MaxClients)
+	}
+	if err := validateClientLimits("rate_limiting", rl.RequestsPerSecond, rl.RequestBurst, rl.BytesPerSecond, rl.BandwidthBurst); err != nil {
+		return err
+	}
+	if rl.RequestsPerSecond == 0 && rl.BytesPerSecond == 0 && len(rl.Clients) == 0 {
+		return fmt.Errorf("rate_limiting: requests_per_second, bytes_per_second or clients is required when rate limiting is enabled")
+	}
+
+	seen := make(map[string]bool, len(rl.Clients))
+	for i, client := range rl.Clients {
+		prefix := fmt.Sprintf("rate_limiting.clients[%d]", i)
+		if client.AccessKeyID == "" {
+			return fmt.Errorf("%s.access_key_id is required", prefix)
+		}
+		if seen[client.AccessKeyID] {
+			return fmt.Errorf("%s: duplicate access key %q", prefix, client.AccessKeyID)
+		}
+		seen[client.AccessKeyID] = true
+		if err := validateClientLimits(prefix, client.RequestsPerSecond, client.RequestBurst, client.BytesPerSecond, client.BandwidthBurst); err != nil {
+			return err
+		}
+	}
+	if len(rl.Clients) > 0 && rl.KeyBy != RateLimitKeyByAccessKey {
+		return fmt.Errorf("rate_limiting.clients requires key_by %s", RateLimitKeyByAccessKey)
+	}
+	return nil
+}
+
+// validateClientLimits validates the limits of rate_limiting or one of its
+// clients
+func validateClientLimits(prefix string, requestsPerSecond float64, requestBurst int, bytesPerSecond, bandwidthBurst int64) error {
+	if requestsPerSecond < 0 {
+		return fmt.Errorf("%s.requests_per_second: must not be negative, got %g", prefix, requestsPerSecond)
+	}
+	if requestBurst < 0 {
+		return fmt.Errorf("%s.request_burst: must not be negative, got %d", prefix, requestBurst)
+	}
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("%s.bytes_per_second: must not be negative, got %d", prefix, bytesPerSecond)
+	}
+	if bandwidthBurst < 0 {
+		return fmt.Errorf("%s.bandwidth_burst: must not be negative, got %d", prefix, bandwidthBurst)
+	}
+	return nil
+}
+
+// auditSyslogFacilities are the facilities audit records can be sent with
+var auditSyslogFacilities = []string{"auth", "authpriv", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+
+// validateAccessLog validates the access log format
+func validateAccessLog(cfg *Config) error {
+	if !cfg.AccessLog.Enabled {
+		return nil
+	}
+	switch accesslog.Format(cfg.AccessLog.Format) {
+	case "", accesslog.FormatCommon, accesslog.FormatCombined, accesslog.FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("access_log.format: must be %q, %q or %q, got %q",
+			accesslog.FormatCommon, accesslog.FormatCombined, accesslog.FormatJSON, cfg.AccessLog.Format)
+	}
+}
+
+// validateAuditSinks validates the audit sinks
+func validateAuditSinks(cfg *Config) error {
+	if len(cfg.Audit.Sinks) == 0 {
+		return nil
+	}
+	if cfg.Audit.SinkQueueSize < 1 {
+		return fmt.Errorf("audit.sink_queue_size: must be at least 1, got %d", cfg.Audit.SinkQueueSize)
+	}
+	if cfg.Audit.SinkBatchSize < 1 {
+		return fmt.Errorf("audit.sink_batch_size: must be at least 1, got %d", cfg.Audit.SinkBatchSize)
+	}
+	if cfg.Audit.SinkFlushInterval < 1 {
+		return fmt.Errorf("audit.sink_flush_interval: must be at least 1, got %d", cfg.Audit.SinkFlushInterval)
+	}
+	if cfg.Audit.SinkTimeout < 1 {
+		return fmt.Errorf("audit.sink_timeout: must be at least 1, got %d", cfg.Audit.SinkTimeout)
+	}
+
+	names := make(map[string]bool)
+	for i, sink := range cfg.Audit.Sinks {
+		switch sink.Type {
+		case AuditSinkSyslog:
+			switch sink.Network {
+			case "", "udp", "tcp", "unix":
+			default:
+				return fmt.Errorf("audit.sinks[%d].network must be 'udp', 'tcp' or 'unix', got: %s", i, sink.Network)
+			}
+			if sink.Address == "" {
+				return fmt.Errorf("audit.sinks[%d].address is required for syslog sinks", i)
+			}
+			if sink.Facility != "" && !slices.Contains(auditSyslogFacilities, sink.Facility) {
+				return fmt.Errorf("audit.sinks[%d].facility must be one of %s, got: %s", i, strings.Join(auditSyslogFacilities, ", "), sink.Facility)
+			}
+		case AuditSinkWebhook, AuditSinkKafka:
+			parsed, err := url.Parse(sink.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("audit.sinks[%d].url must be an http or https URL, got: '%s'", i, sink.URL)
+			}
+			if sink.Type == AuditSinkKafka && sink.Topic == "" {
+				return fmt.Errorf("audit.sinks[%d].topic is required for kafka sinks", i)
+			}
+		default:
+			return fmt.Errorf("audit.sinks[%d].type must be 'syslog', 'webhook' or 'kafka', got: '%s'", i, sink.Type)
+		}
+
+		name := cfg.Audit.SinkName(i)
+		if names[name] {
+			return fmt.Errorf("audit.sinks[%d].name: '%s' is already used", i, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// notificationEventNames are the event names notification filters match
+var notificationEventNames = []string{
+	"s3:ObjectCreated:Put",
+	"s3:ObjectCreated:Copy",
+	"s3:ObjectCreated:CompleteMultipartUpload",
+	"s3:ObjectRemoved:Delete",
+	"s3:ObjectRemoved:DeleteMarkerCreated",
+}
+
+// validateNotifications validates the event notification targets
+func validateNotifications(cfg *Config) error {
+	n := cfg.Notifications
+	if len(n.Targets) == 0 {
+		return nil
+	}
+	if n.QueueSize < 1 {
+		return fmt.Errorf("notifications.queue_size: must be at least 1, got %d", n.QueueSize)
+	}
+	if n.BatchSize < 1 {
+		return fmt.Errorf("notifications.batch_size: must be at least 1, got %d", n.BatchSize)
+	}
+	if n.FlushInterval < 1 {
+		return fmt.Errorf("notifications.flush_interval: must be at least 1, got %d", n.FlushInterval)
+	}
+	if n.Timeout < 1 {
+		return fmt.Errorf("notifications.timeout: must be at least 1, got %d", n.Timeout)
+	}
+
+	names := make(map[string]bool)
+	for i, target := range n.Targets {
+		parsed, err := url.Parse(target.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("notifications.targets[%d].url must be an http or https URL, got: '%s'", i, target.URL)
+		}
+		switch target.Type {
+		case NotificationTargetWebhook:
+		case NotificationTargetKafka:
+			if target.Topic == "" {
+				return fmt.Errorf("notifications.targets[%d].topic is required for kafka targets", i)
+			}
+		case NotificationTargetSQS:
+			if target.AccessKeyID == "" || target.SecretKey == "" {
+				return fmt.Errorf("notifications.targets[%d]: access_key_id and secret_key are required for sqs targets", i)
+			}
+			if target.Region == "" && notification.SQSRegion(parsed.Hostname()) == "" {
+				return fmt.Errorf("notifications.targets[%d].region is required for queues outside AWS, got url: '%s'", i, target.URL)
+			}
+		default:
+			return fmt.Errorf("notifications.targets[%d].type must be 'webhook', 'kafka' or 'sqs', got: '%s'", i, target.Type)
+		}
+
+		for _, pattern := range target.Events {
+			if !slices.ContainsFunc(notificationEventNames, func(name string) bool {
+				matched, _ := path.Match(pattern, name)
+				return matched
+			}) {
+				return fmt.Errorf("notifications.targets[%d].events: '%s' matches no event, events are %s", i, pattern, strings.Join(notificationEventNames, ", "))
+			}
+		}
+		for _, pattern := range target.Buckets {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("notifications.targets[%d].buckets: invalid pattern '%s'", i, pattern)
+			}
+		}
+
+		name := n.TargetName(i)
+		if names[name] {
+			return fmt.Errorf("notifications.targets[%d].name: '%s' is already used", i, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// SinkName returns the name of sink i, its type if it has none
+func (a AuditConfig) SinkName(i int) string {
+	if a.Sinks[i].Name != "" {
+		return a.Sinks[i].Name
+	}
+	return a.Sinks[i].Type
+}
+
+// validateReconciliation validates the multipart reconciliation settings
+func validateReconciliation(cfg *Config) error {
+	r := cfg.SessionStore.Reconciliation
+	if !r.Enabled {
+		return nil
+	}
+
+	if r.Interval < 1 {
+		return fmt.Errorf("session_store.reconciliation.interval: must be at least 1, got %d", r.Interval)
+	}
+	if r.MinAge < 0 {
+		return fmt.Errorf("session_store.reconciliation.min_age: must not be negative, got %d", r.MinAge)
+	}
+	switch r.Policy {
+	case "", ReconcilePolicyKeep, ReconcilePolicyAbort, ReconcilePolicyAdopt:
+	default:
+		return fmt.Errorf("session_store.reconciliation.policy must be one of: '%s', '%s', '%s', got: %s",
+			ReconcilePolicyKeep, ReconcilePolicyAbort, ReconcilePolicyAdopt, r.Policy)
+	}
+	for _, bucket := range r.Buckets {
+		if bucket == "" {
+			return fmt.Errorf("session_store.reconciliation.buckets: bucket names must not be empty")
 		}
 	}
-
-	return provider, nil
+	return nil
 }
 
-// validateLicenseAndEncryption validates both license and encryption configuration
-func validateLicenseAndEncryption(cfg *Config) error {
-	// Load and validate license
-	licenseToken := license.LoadLicense(cfg.LicenseFile)
-	validator := license.NewValidator()
-	result := validator.ValidateLicense(licenseToken)
-
-	// Log license information
-	license.LogLicenseInfo(result)
+// validateSessionStore validates the multipart session store settings
+func validateSessionStore(cfg *Config) error {
+	store := cfg.SessionStore
+	switch store.Type {
+	case "", SessionStoreMemory:
+		if store.PersistPath == "" {
+			return nil
+		}
+		// Saved sessions are continued by the next process like shared ones
+		return validateSessionIntegrity(cfg, "session_store.persist_path")
+	case SessionStoreRedis:
+		if store.Redis.Address == "" {
+			return fmt.Errorf("session_store.redis.address is required for the redis session store")
+		}
+		if store.Redis.DB < 0 {
+			return fmt.Errorf("session_store.redis.db: must not be negative, got %d", store.Redis.DB)
+		}
+	case SessionStoreEtcd:
+		if len(store.Etcd.Endpoints) == 0 {
+			return fmt.Errorf("session_store.etcd.endpoints is required for the etcd session store")
+		}
+	default:
+		return fmt.Errorf("session_store.type must be one of: 'memory', 'redis', 'etcd', got: %s", store.Type)
+	}
 
-	// Validate encryption configuration
-	if err := validateEncryption(cfg); err != nil {
-		return err
+	if store.KeyPrefix == "" {
+		return fmt.Errorf("session_store.key_prefix must not be empty for the %s session store", store.Type)
+	}
+	if store.Timeout < 1 {
+		return fmt.Errorf("session_store.timeout: must be at least 1, got %d", store.Timeout)
+	}
+	if store.PersistPath != "" {
+		return fmt.Errorf("session_store.persist_path is only supported by the memory session store, the %s store keeps its sessions", store.Type)
 	}
 
-	// Check if encryption provider requires license
-	if cfg.Encryption.EncryptionMethodAlias != "" {
-		// Find the active provider
-		for _, provider := range cfg.Encryption.Providers {
-			if provider.Alias == cfg.Encryption.EncryptionMethodAlias {
-				// Log provider restriction info
-				license.LogProviderRestriction(provider.Type, provider.Alias, result.Valid)
+	// Sessions continue on other replicas, which needs the running MAC state
+	return validateSessionIntegrity(cfg, "session_store.type: "+store.Type)
+}
 
-				// Validate provider type against license
-				if err := validator.ValidateProviderType(provider.Type); err != nil {
-					return err
-				}
-				break
-			}
+// validateSessionIntegrity checks that sessions continued by another process
+// can restore their running MAC state
+func validateSessionIntegrity(cfg *Config, setting string) error {
+	if cfg.Encryption.IntegrityVerification == "" || cfg.Encryption.IntegrityVerification == HMACVerificationOff {
+		return nil
+	}
+	algorithms := []string{cfg.IntegrityAlgorithmFor("")}
+	for _, override := range cfg.Encryption.IntegrityAlgorithmOverrides {
+		algorithms = append(algorithms, override.Algorithm)
+	}
+	for _, algorithm := range algorithms {
+		if algorithm == IntegrityAlgorithmBLAKE3 {
+			return fmt.Errorf("%s cannot be used with integrity algorithm '%s', its state cannot be shared", setting, IntegrityAlgorithmBLAKE3)
 		}
 	}
-
 	return nil
 }
 
-// validateEncryption validates the encryption configuration
-func validateEncryption(cfg *Config) error {
-	// Validate HMAC verification mode
-	switch cfg.Encryption.IntegrityVerification {
-	case HMACVerificationOff, HMACVerificationLax, HMACVerificationStrict, HMACVerificationHybrid:
-		// Valid values
-	case "": // Default to off if not specified
-		cfg.Encryption.IntegrityVerification = HMACVerificationOff
-	default:
-		return fmt.Errorf("encryption.integrity_verification must be one of: 'off', 'lax', 'strict', 'hybrid', got: %s", cfg.Encryption.IntegrityVerification)
+// validateMonitoring validates monitoring and admin endpoint configuration
+func validateMonitoring(cfg *Config) error {
+	if err := validateBucketMetrics(cfg); err != nil {
+		return err
+	}
+	if err := validateAttestation(cfg); err != nil {
+		return err
+	}
+	if err := validateUsage(cfg); err != nil {
+		return err
+	}
+	if err := validateKEKRotation(cfg); err != nil {
+		return err
+	}
+	if err := validateMultipartGC(cfg); err != nil {
+		return err
 	}
 
-	// If using new encryption config format
-	if cfg.Encryption.EncryptionMethodAlias != "" || len(cfg.Encryption.Providers) > 0 {
-		// Validate that encryption_method_alias is specified
-		if cfg.Encryption.EncryptionMethodAlias == "" {
-			return fmt.Errorf("encryption.encryption_method_alias is required when using encryption.providers")
-		}
-
-		// Validate that providers list is not empty
-		if len(cfg.Encryption.Providers) == 0 {
-			return fmt.Errorf("encryption.providers cannot be empty")
-		}
-
-		// Find the active provider
-		var activeProvider *EncryptionProvider
-		aliasMap := make(map[string]bool)
+	le := cfg.Monitoring.ListExport
+	if !le.Enabled {
+		return nil
+	}
 
-		for i := range cfg.Encryption.Providers {
-			provider := &cfg.Encryption.Providers[i]
+	if !cfg.Monitoring.Enabled {
+		return fmt.Errorf("monitoring.list_export requires monitoring.enabled (export endpoints are served on the monitoring port)")
+	}
+	if le.DiagnosticsBucket == "" {
+		return fmt.Errorf("monitoring.list_export.diagnostics_bucket is required when list export is enabled")
+	}
+	if le.PartSize != 0 && le.PartSize < 5*1024*1024 {
+		return fmt.Errorf("monitoring.list_export.part_size: minimum value is 5MB (5242880 bytes), got %d", le.PartSize)
+	}
+	if le.MaxConcurrentJobs < 0 {
+		return fmt.Errorf("monitoring.list_export.max_concurrent_jobs: must not be negative (0 uses the default of 2), got %d", le.MaxConcurrentJobs)
+	}
 
-			// Validate provider fields
-			if provider.Alias == "" {
-				return fmt.Errorf("encryption.providers[%d].alias is required", i)
-			}
+	return nil
+}
 
-			// Check for duplicate aliases
-			if aliasMap[provider.Alias] {
-				return fmt.Errorf("duplicate encryption provider alias: %s", provider.Alias)
-			}
-			aliasMap[provider.Alias] = true
+// validateAttestation validates the attestation export settings
+func validateAttestation(cfg *Config) error {
+	at := cfg.Monitoring.Attestation
+	if !at.Enabled {
+		return nil
+	}
 
-			// Validate provider type and required fields
-			if err := validateProvider(provider, i); err != nil {
-				return err
-			}
+	if !cfg.Monitoring.Enabled {
+		return fmt.Errorf("monitoring.attestation requires monitoring.enabled (attestation endpoints are served on the monitoring port)")
+	}
+	if at.SigningKeyFile == "" {
+		return fmt.Errorf("monitoring.attestation.signing_key_file is required when attestation is enabled")
+	}
+	if at.MaxObjects < 0 {
+		return fmt.Errorf("monitoring.attestation.max_objects: must not be negative, got %d", at.MaxObjects)
+	}
+	return nil
+}
 
-			// Check if this is the active provider
-			if provider.Alias == cfg.Encryption.EncryptionMethodAlias {
-				activeProvider = provider
-			}
-		}
+// validateUsage validates the bucket usage collector settings
+func validateUsage(cfg *Config) error {
+	usage := cfg.Monitoring.Usage
+	if !usage.Enabled {
+		return nil
+	}
 
-		// Validate that the active provider exists
-		if activeProvider == nil {
-			return fmt.Errorf("encryption_method_alias '%s' does not match any provider alias", cfg.Encryption.EncryptionMethodAlias)
+	if !cfg.Monitoring.Enabled {
+		return fmt.Errorf("monitoring.usage requires monitoring.enabled (usage metrics and endpoints are served on the monitoring port)")
+	}
+	if usage.Interval != 0 && usage.Interval < 60 {
+		return fmt.Errorf("monitoring.usage.interval: minimum value is 60 seconds, got %d", usage.Interval)
+	}
+	if usage.MaxBuckets < 0 {
+		return fmt.Errorf("monitoring.usage.max_buckets: must not be negative, got %d", usage.MaxBuckets)
+	}
+	if usage.HeadConcurrency < 0 || usage.HeadConcurrency > 64 {
+		return fmt.Errorf("monitoring.usage.head_concurrency: must be between 1 and 64, got %d", usage.HeadConcurrency)
+	}
+	for i, bucket := range usage.Buckets {
+		if strings.TrimSpace(bucket) == "" {
+			return fmt.Errorf("monitoring.usage.buckets[%d]: must not be empty", i)
 		}
+	}
+	return nil
+}
 
+// validateKEKRotation validates the KEK rotation job settings
+func validateKEKRotation(cfg *Config) error {
+	rotation := cfg.Monitoring.KEKRotation
+	if !rotation.Enabled {
 		return nil
 	}
 
-	// If no explicit alias but providers exist, validate at least
-	// Note: Having no providers is valid for non-encryption use cases (e.g., TLS only)
+	if !cfg.Monitoring.Enabled {
+		return fmt.Errorf("monitoring.kek_rotation requires monitoring.enabled (rotation endpoints are served on the monitoring port)")
+	}
+	if rotation.MaxConcurrentJobs < 0 {
+		return fmt.Errorf("monitoring.kek_rotation.max_concurrent_jobs: must not be negative (0 uses the default of 1), got %d", rotation.MaxConcurrentJobs)
+	}
+	if rotation.Concurrency < 0 || rotation.Concurrency > 64 {
+		return fmt.Errorf("monitoring.kek_rotation.concurrency: must be between 0 and 64 (0 uses the default of 4), got %d", rotation.Concurrency)
+	}
+	return nil
+}
 
+// validateMultipartGC validates the multipart garbage collection settings
+func validateMultipartGC(cfg *Config) error {
+	if cfg.Monitoring.MultipartGC.Enabled && !cfg.Monitoring.Enabled {
+		return fmt.Errorf("monitoring.multipart_gc requires monitoring.enabled (the garbage collection endpoint is served on the monitoring port)")
+	}
 	return nil
 }
 
-// validateProvider validates a single encryption provider
-func validateProvider(provider *EncryptionProvider, index int) error {
-	switch provider.Type {
-	case "tink":
-		return fmt.Errorf("encryption.providers[%d]: tink encryption is not yet implemented with the new architecture", index)
-	case "aes":
-		if aesKey, ok := provider.Config["aes_key"].(string); !ok || aesKey == "" {
-			return fmt.Errorf("encryption.providers[%d]: aes_key is required when using aes encryption", index)
-		}
-	case "rsa":
-		if publicKeyPEM, ok := provider.Config["public_key_pem"].(string); !ok || publicKeyPEM == "" {
-			return fmt.Errorf("encryption.providers[%d]: public_key_pem is required when using rsa encryption", index)
-		}
-		if privateKeyPEM, ok := provider.Config["private_key_pem"].(string); !ok || privateKeyPEM == "" {
-			return fmt.Errorf("encryption.providers[%d]: private_key_pem is required when using rsa encryption", index)
-		}
-	case "none":
-		// No validation needed for "none" provider - no encryption parameters required
-	default:
-		return fmt.Errorf("encryption.providers[%d].type: unsupported encryption type: %s (supported: aes, rsa, none)", index, provider.Type)
+// validateBucketMetrics validates the per-bucket metrics cardinality limits
+func validateBucketMetrics(cfg *Config) error {
+	bm := cfg.Monitoring.BucketMetrics
+	if !bm.Enabled {
+		return nil
+	}
+
+	if bm.MaxBuckets < 1 || bm.MaxBuckets > 10000 {
+		return fmt.Errorf("monitoring.bucket_metrics.max_buckets: must be between 1 and 10000, got %d", bm.MaxBuckets)
+	}
+	if bm.OverflowLabel == "" {
+		return fmt.Errorf("monitoring.bucket_metrics.overflow_label must not be empty")
+	}
+	if bm.RebalanceInterval != 0 && bm.RebalanceInterval < 60 {
+		return fmt.Errorf("monitoring.bucket_metrics.rebalance_interval: minimum value is 60 seconds, got %d", bm.RebalanceInterval)
 	}
 
 	return nil
@@ -667,6 +3510,70 @@ func validateOptimizations(cfg *Config) error {
 		}
 	}
 
+	// Parts must be at least 5MB, so smaller thresholds would not save anything
+	if cfg.Optimizations.AutoMultipartThreshold != 0 && cfg.Optimizations.AutoMultipartThreshold < 5*1024*1024 {
+		return fmt.Errorf("optimizations.auto_multipart_threshold: minimum value is 5MB (5242880 bytes) or 0 to disable, got %d", cfg.Optimizations.AutoMultipartThreshold)
+	}
+	if cfg.Optimizations.AutoMultipartPartRetries < 0 || cfg.Optimizations.AutoMultipartPartRetries > 10 {
+		return fmt.Errorf("optimizations.auto_multipart_part_retries: must be between 0 and 10, got %d", cfg.Optimizations.AutoMultipartPartRetries)
+	}
+
+	if cfg.Optimizations.CryptoWorkersPerStream < 0 {
+		return fmt.Errorf("optimizations.crypto_workers_per_stream: minimum value is 0 (auto), got %d", cfg.Optimizations.CryptoWorkersPerStream)
+	}
+	if cfg.Optimizations.CryptoWorkersMax < 0 {
+		return fmt.Errorf("optimizations.crypto_workers_max: minimum value is 0 (auto), got %d", cfg.Optimizations.CryptoWorkersMax)
+	}
+
+	if cfg.Optimizations.MultipartEncryptionWorkers < 0 || cfg.Optimizations.MultipartEncryptionWorkers > 256 {
+		return fmt.Errorf("optimizations.multipart_encryption_workers: must be between 0 and 256, got %d", cfg.Optimizations.MultipartEncryptionWorkers)
+	}
+
+	if cfg.Optimizations.StreamingReadAhead < 0 || cfg.Optimizations.StreamingReadAhead > 64 {
+		return fmt.Errorf("optimizations.streaming_read_ahead: must be between 0 and 64, got %d", cfg.Optimizations.StreamingReadAhead)
+	}
+
+	if cfg.Optimizations.DEKCache.Size < 0 {
+		return fmt.Errorf("optimizations.dek_cache.size: minimum value is 0 (default), got %d", cfg.Optimizations.DEKCache.Size)
+	}
+	if cfg.Optimizations.DEKCache.TTL < 0 {
+		return fmt.Errorf("optimizations.dek_cache.ttl: minimum value is 0 (no expiry), got %d", cfg.Optimizations.DEKCache.TTL)
+	}
+
+	switch cfg.Optimizations.MultipartStaleSessionPolicy {
+	case "", StaleSessionPolicyKeep, StaleSessionPolicyAbort, StaleSessionPolicyReuse:
+	default:
+		return fmt.Errorf("optimizations.multipart_stale_session_policy: must be one of %q, %q or %q, got %q",
+			StaleSessionPolicyKeep, StaleSessionPolicyAbort, StaleSessionPolicyReuse, cfg.Optimizations.MultipartStaleSessionPolicy)
+	}
+
+	return validateNetworkTuning(cfg)
+}
+
+// validateNetworkTuning validates the buffer and socket sizes of optimizations.network
+func validateNetworkTuning(cfg *Config) error {
+	network := cfg.Optimizations.Network
+	if size := network.ResponseBufferSize; size != 0 && (size < 4*1024 || size > 8*1024*1024) {
+		return fmt.Errorf("optimizations.network.response_buffer_size: must be between 4KB and 8MB (4096 - 8388608 bytes), got %d", size)
+	}
+
+	sizes := []struct {
+		field string
+		value int
+		max   int
+	}{
+		{"listener.socket_send_buffer", network.Listener.SendBuffer, 256 * 1024 * 1024},
+		{"listener.socket_receive_buffer", network.Listener.ReceiveBuffer, 256 * 1024 * 1024},
+		{"backend.socket_send_buffer", network.Backend.SendBuffer, 256 * 1024 * 1024},
+		{"backend.socket_receive_buffer", network.Backend.ReceiveBuffer, 256 * 1024 * 1024},
+		{"backend.write_buffer_size", network.Backend.WriteBufferSize, 16 * 1024 * 1024},
+		{"backend.read_buffer_size", network.Backend.ReadBufferSize, 16 * 1024 * 1024},
+	}
+	for _, size := range sizes {
+		if size.value < 0 || size.value > size.max {
+			return fmt.Errorf("optimizations.network.%s: must be between 0 and %d bytes, got %d", size.field, size.max, size.value)
+		}
+	}
 	return nil
 }
 
@@ -696,15 +3603,20 @@ func validateS3Clients(cfg *Config) error {
 			return fmt.Errorf("s3_clients[%d].secret_key is required", i)
 		}
 
-		// Security validation: minimum key length
-		if len(client.AccessKeyID) < 8 {
+		// Security validation: minimum key length. Only Check sees ${VAR}
+		// references, whose length says nothing about the value.
+		if len(client.AccessKeyID) < 8 && !hasEnvVarReference(client.AccessKeyID) {
 			return fmt.Errorf("s3_clients[%d].access_key_id must be at least 8 characters long", i)
 		}
 
-		if len(client.SecretKey) < 16 {
+		if len(client.SecretKey) < 16 && !hasEnvVarReference(client.SecretKey) {
 			return fmt.Errorf("s3_clients[%d].secret_key must be at least 16 characters long", i)
 		}
 
+		if err := validateS3ClientScopes(client.Scopes, fmt.Sprintf("s3_clients[%d]", i)); err != nil {
+			return err
+		}
+
 		// Check for duplicate access_key_ids
 		for j := i + 1; j < len(cfg.S3Clients); j++ {
 			if cfg.S3Clients[j].AccessKeyID == client.AccessKeyID {
@@ -718,9 +3630,82 @@ func validateS3Clients(cfg *Config) error {
 		return err
 	}
 
+	if cfg.Authorization.PolicyFile != "" && cfg.Authorization.ReloadInterval <= 0 {
+		return fmt.Errorf("authorization.reload_interval: must be positive, got %d", cfg.Authorization.ReloadInterval)
+	}
+
+	return nil
+}
+
+// validateS3ClientScopes validates the scopes of the client configured at
+// field, as in "s3_clients[0]"
+func validateS3ClientScopes(scopes []S3ClientScope, field string) error {
+	for i, scope := range scopes {
+		for _, pattern := range scope.Buckets {
+			if pattern == "" || pattern == "*" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return fmt.Errorf("%s.scopes[%d].buckets: invalid pattern '%s' (use a bucket name, optionally ending in '*')", field, i, pattern)
+			}
+		}
+		for _, prefix := range scope.Prefixes {
+			if prefix == "" {
+				return fmt.Errorf("%s.scopes[%d].prefixes: empty prefix (omit prefixes to allow all keys)", field, i)
+			}
+		}
+		for _, operation := range scope.Operations {
+			switch operation {
+			case ScopeOperationRead, ScopeOperationWrite, ScopeOperationDelete, ScopeOperationList, ScopeOperationBucket, ScopeOperationMultipart:
+			default:
+				return fmt.Errorf("%s.scopes[%d].operations must be one of: 'read', 'write', 'delete', 'list', 'bucket', 'multipart', got: %s", field, i, operation)
+			}
+		}
+	}
 	return nil
 }
 
+// AuthorizationPolicy is the content of authorization.policy_file: the
+// scopes of S3 clients, by access key
+type AuthorizationPolicy struct {
+	Clients []AuthorizationPolicyClient `mapstructure:"clients"`
+}
+
+// AuthorizationPolicyClient holds the scopes of one S3 client. The client
+// authenticates with its s3_clients entry.
+type AuthorizationPolicyClient struct {
+	AccessKeyID string          `mapstructure:"access_key_id"`
+	Scopes      []S3ClientScope `mapstructure:"scopes"`
+}
+
+// ParseAuthorizationPolicy reads and validates a YAML authorization policy
+func ParseAuthorizationPolicy(data []byte) (*AuthorizationPolicy, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read authorization policy: %w", err)
+	}
+	var policy AuthorizationPolicy
+	if err := v.Unmarshal(&policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal authorization policy: %w", err)
+	}
+
+	seen := make(map[string]int)
+	for i, client := range policy.Clients {
+		if client.AccessKeyID == "" {
+			return nil, fmt.Errorf("clients[%d].access_key_id is required", i)
+		}
+		if j, ok := seen[client.AccessKeyID]; ok {
+			return nil, fmt.Errorf("clients[%d] and clients[%d] have duplicate access_key_id: %s", j, i, client.AccessKeyID)
+		}
+		seen[client.AccessKeyID] = i
+		if len(client.Scopes) == 0 {
+			return nil, fmt.Errorf("clients[%d].scopes: at least one scope is required", i)
+		}
+		if err := validateS3ClientScopes(client.Scopes, fmt.Sprintf("clients[%d]", i)); err != nil {
+			return nil, err
+		}
+	}
+	return &policy, nil
+}
+
 // validateS3Security validates S3 security configuration
 func validateS3Security(cfg *Config) error {
 	sec := cfg.S3Security
@@ -858,6 +3843,83 @@ func (provider *EncryptionProvider) GetProviderConfig() map[string]interface{} {
 	return provider.Config
 }
 
+// GetBackendProfile returns the effective backend compatibility profile with
+// the multipart_metadata_phase override applied
+func (c *Config) GetBackendProfile() BackendProfile {
+	profile, ok := backendProfiles[c.S3Backend.CompatibilityProfile]
+	if !ok {
+		profile = backendProfiles["generic"]
+	}
+
+	if c.S3Backend.MultipartMetadataPhase != "" {
+		profile.MultipartMetadataPhase = c.S3Backend.MultipartMetadataPhase
+	}
+	// Metadata sent as headers with Complete cannot be moved to a sidecar or
+	// split across keys
+	if mode := c.Encryption.MetadataSidecar.Mode; (mode != "" && mode != MetadataSidecarModeOff) || c.Encryption.MetadataLimits.MaxValueSize > 0 {
+		profile.MultipartMetadataPhase = MultipartMetadataPhaseCopy
+	}
+	// Metadata sent with Complete may be silently dropped, so it is always verified
+	if profile.MultipartMetadataPhase == MultipartMetadataPhaseComplete || c.S3Backend.VerifyMultipartMetadata {
+		profile.VerifyMultipartMetadata = true
+	}
+
+	return profile
+}
+
+// GetListenerConfig returns the listener settings with defaults applied to
+// unset limits (MaxContentLength, MaxQueryParams, MaxHeaderBytes,
+// ReadHeaderTimeout)
+func (cfg *Config) GetListenerConfig() ListenerConfig {
+	l := cfg.Listener
+	if l.MaxHeaderBytes == 0 {
+		l.MaxHeaderBytes = 64 * 1024
+	}
+	if l.ReadHeaderTimeout == 0 {
+		l.ReadHeaderTimeout = 10
+	}
+	if l.MaxQueryParams == 0 {
+		l.MaxQueryParams = 100
+	}
+	if l.MaxContentLength == 0 {
+		l.MaxContentLength = 5 * 1024 * 1024 * 1024 * 1024
+	}
+	return l
+}
+
+// GetLicenseOptions returns the license validator options. The token is
+// reloaded from the license sources on every runtime re-validation.
+func (cfg *Config) GetLicenseOptions() license.Options {
+	options := license.DefaultOptions()
+	options.ClockSkew = time.Duration(cfg.License.ClockSkewTolerance) * time.Second
+	if cfg.License.RevalidationInterval > 0 {
+		options.RevalidationInterval = time.Duration(cfg.License.RevalidationInterval) * time.Second
+	}
+	if len(cfg.License.WarningDays) > 0 {
+		options.WarningThresholds = cfg.License.WarningDays
+	}
+	options.GracePeriod = time.Duration(cfg.License.GraceDays) * 24 * time.Hour
+	if cfg.License.Enforcement != "" {
+		options.Enforcement = license.Enforcement(cfg.License.Enforcement)
+	}
+
+	if r := cfg.License.Revocation; r.Source != "" {
+		options.Revocation = license.RevocationOptions{
+			Source:          r.Source,
+			RefreshInterval: time.Duration(r.RefreshInterval) * time.Second,
+			MaxAge:          time.Duration(r.MaxAge) * time.Second,
+			FailClosed:      r.FailClosed,
+			CacheFile:       r.CacheFile,
+		}
+	}
+
+	licenseFile := cfg.LicenseFile
+	options.TokenLoader = func() string {
+		return license.LoadLicense(licenseFile)
+	}
+	return options
+}
+
 // GetStreamingSegmentSize returns the streaming segment size from optimizations config
 func (cfg *Config) GetStreamingSegmentSize() int64 {
 	// Use optimizations.streaming_segment_size
@@ -869,6 +3931,59 @@ func (cfg *Config) GetStreamingSegmentSize() int64 {
 	return 12 * 1024 * 1024
 }
 
+// GetStreamingReadAheadSize returns the chunk size streaming readers use for
+// crypto operations. It is streaming_buffer_size (default 64KB), rounded down
+// to a multiple of the AES block size that divides the streaming segment size
+// evenly, so chunk boundaries line up with segment boundaries.
+func (cfg *Config) GetStreamingReadAheadSize() int {
+	const aesBlockSize = 16
+
+	size := cfg.Optimizations.StreamingBufferSize
+	if size <= 0 {
+		size = 64 * 1024
+	}
+	size -= size % aesBlockSize
+
+	segmentSize := cfg.GetStreamingSegmentSize()
+	for candidate := size; candidate >= aesBlockSize; candidate -= aesBlockSize {
+		if segmentSize%int64(candidate) == 0 {
+			return candidate
+		}
+	}
+	return size
+}
+
+// S3 part size limits (all parts but the last one)
+const (
+	minBackendPartSize = 5 * 1024 * 1024        // 5MiB
+	maxBackendPartSize = 5 * 1024 * 1024 * 1024 // 5GiB
+)
+
+// GetRecommendedPartSize returns the part size clients are advised to use for
+// multipart uploads: the streaming segment size, within the backend part size
+// limits and rounded up to the AES block size. Parts of this size line up
+// with the crypto chunks of GetStreamingReadAheadSize.
+func (cfg *Config) GetRecommendedPartSize() int64 {
+	const aesBlockSize = 16
+
+	size := min(max(cfg.GetStreamingSegmentSize(), minBackendPartSize), maxBackendPartSize)
+	return (size + aesBlockSize - 1) / aesBlockSize * aesBlockSize
+}
+
+// GetCryptoParallelism returns the number of AES-CTR workers per stream and the
+// cap on helper workers across all streams. Unset values default to GOMAXPROCS.
+func (cfg *Config) GetCryptoParallelism() (perStream, global int) {
+	perStream = cfg.Optimizations.CryptoWorkersPerStream
+	if perStream <= 0 {
+		perStream = runtime.GOMAXPROCS(0)
+	}
+	global = cfg.Optimizations.CryptoWorkersMax
+	if global <= 0 {
+		global = runtime.GOMAXPROCS(0)
+	}
+	return perStream, global
+}
+
 // GetStreamingThreshold returns the threshold size for choosing between GCM and CTR encryption
 // Files smaller than this threshold use GCM, larger files use CTR
 func (cfg *Config) GetStreamingThreshold() int64 {
@@ -881,6 +3996,14 @@ func (cfg *Config) GetStreamingThreshold() int64 {
 	return 5 * 1024 * 1024
 }
 
+// GetResponseBufferSize returns the buffer size for streaming GET bodies to clients
+func (cfg *Config) GetResponseBufferSize() int {
+	if cfg.Optimizations.Network.ResponseBufferSize > 0 {
+		return cfg.Optimizations.Network.ResponseBufferSize
+	}
+	return 128 * 1024
+}
+
 // GetStreamingBufferSize returns the streaming buffer size from optimizations config
 func (cfg *Config) GetStreamingBufferSize() int {
 	// Use optimizations.streaming_buffer_size
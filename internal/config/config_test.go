@@ -1,11 +1,15 @@
 package config
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
 )
 
 func TestLoad_ValidTinkConfig(t *testing.T) {
@@ -54,6 +58,52 @@ func TestLoad_ValidNoneConfig(t *testing.T) {
 	assert.Empty(t, provider.Config)
 }
 
+func TestLoad_ProviderSettings(t *testing.T) {
+	viper.Reset()
+	setDefaults()
+	defer viper.Reset()
+
+	viper.Set("s3_backend.target_endpoint", "http://localhost:9000")
+	viper.Set("s3_clients", []map[string]interface{}{
+		{"type": "static", "access_key_id": "testuser123456", "secret_key": "testsecret123456"},
+	})
+	viper.Set("encryption.encryption_method_alias", "none")
+	viper.Set("encryption.providers", []interface{}{
+		map[string]interface{}{
+			"alias":               "none",
+			"type":                "none",
+			"integrity_algorithm": IntegrityAlgorithmBLAKE3,
+			"rate_limit":          50,
+			"rate_burst":          "10",
+			"max_queue":           200,
+			"queue_timeout":       2,
+		},
+	})
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Len(t, cfg.Encryption.Providers, 1)
+
+	provider := cfg.Encryption.Providers[0]
+	assert.Equal(t, IntegrityAlgorithmBLAKE3, provider.IntegrityAlgorithm)
+	assert.Equal(t, float64(50), provider.RateLimit)
+	assert.Equal(t, 10, provider.RateBurst)
+	assert.Equal(t, 200, provider.MaxQueue)
+	assert.Equal(t, 2, provider.QueueTimeout)
+
+	viper.Set("encryption.providers", []interface{}{
+		map[string]interface{}{"alias": "none", "type": "none", "integrity_algorithm": []string{"sha256"}},
+	})
+	_, err = Load()
+	assert.ErrorContains(t, err, "integrity_algorithm")
+
+	viper.Set("encryption.providers", []interface{}{
+		map[string]interface{}{"alias": "none", "type": "none", "rate_limit": "fast"},
+	})
+	_, err = Load()
+	assert.ErrorContains(t, err, "rate_limit")
+}
+
 func TestLoad_MissingTargetEndpoint(t *testing.T) {
 	viper.Reset()
 	setDefaults()
@@ -252,6 +302,210 @@ func TestValidateEncryption_ValidAES(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateEncryption_SSECMode(t *testing.T) {
+	cfg := &Config{TargetEndpoint: "http://localhost:9000"}
+	assert.NoError(t, validateEncryption(cfg))
+	assert.Equal(t, SSECModeReject, cfg.Encryption.SSECMode)
+
+	cfg.Encryption.SSECMode = SSECModePassthrough
+	assert.NoError(t, validateEncryption(cfg))
+
+	cfg.Encryption.SSECMode = "encrypt"
+	err := validateEncryption(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "encryption.sse_c_mode")
+}
+
+func TestValidateEncryption_ETagMode(t *testing.T) {
+	cfg := &Config{TargetEndpoint: "http://localhost:9000"}
+	assert.NoError(t, validateEncryption(cfg))
+	assert.Equal(t, ETagModeBackend, cfg.Encryption.ETagMode)
+
+	cfg.Encryption.ETagMode = ETagModePlaintext
+	assert.NoError(t, validateEncryption(cfg))
+
+	cfg.Encryption.ETagMode = "md5"
+	assert.ErrorContains(t, validateEncryption(cfg), "encryption.etag_mode")
+}
+
+func TestValidateMetadataSidecar(t *testing.T) {
+	validate := func(sidecar MetadataSidecarConfig) (*Config, error) {
+		cfg := &Config{Encryption: EncryptionConfig{MetadataSidecar: sidecar}}
+		return cfg, validateMetadataSidecar(cfg)
+	}
+
+	cfg, err := validate(MetadataSidecarConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, MetadataSidecarModeOff, cfg.Encryption.MetadataSidecar.Mode)
+
+	_, err = validate(MetadataSidecarConfig{Mode: MetadataSidecarModeAlways, IndexBucket: "s3ep-index"})
+	assert.NoError(t, err)
+	_, err = validate(MetadataSidecarConfig{Mode: MetadataSidecarModeOversize, InlineLimit: 2048})
+	assert.NoError(t, err)
+
+	_, err = validate(MetadataSidecarConfig{Mode: MetadataSidecarModeOversize})
+	assert.ErrorContains(t, err, "encryption.metadata_sidecar.inline_limit")
+	_, err = validate(MetadataSidecarConfig{Mode: "index"})
+	assert.ErrorContains(t, err, "encryption.metadata_sidecar.mode")
+	_, err = validate(MetadataSidecarConfig{Mode: MetadataSidecarModeAlways, IndexBucket: "index/sidecars"})
+	assert.ErrorContains(t, err, "encryption.metadata_sidecar.index_bucket")
+}
+
+func TestValidateMetadataLimits(t *testing.T) {
+	validate := func(limits MetadataLimitsConfig, sidecar MetadataSidecarConfig) error {
+		return validateMetadataLimits(&Config{Encryption: EncryptionConfig{MetadataLimits: limits, MetadataSidecar: sidecar}})
+	}
+
+	assert.NoError(t, validate(MetadataLimitsConfig{}, MetadataSidecarConfig{}))
+	assert.NoError(t, validate(MetadataLimitsConfig{MaxSize: 2048, MaxValueSize: 1024}, MetadataSidecarConfig{Mode: MetadataSidecarModeOversize, InlineLimit: 2048}))
+	assert.NoError(t, validate(MetadataLimitsConfig{MaxValueSize: 256}, MetadataSidecarConfig{}))
+
+	assert.ErrorContains(t, validate(MetadataLimitsConfig{MaxSize: -1}, MetadataSidecarConfig{}), "max_size cannot be negative")
+	assert.ErrorContains(t, validate(MetadataLimitsConfig{MaxValueSize: -1}, MetadataSidecarConfig{}), "max_value_size cannot be negative")
+	assert.ErrorContains(t, validate(MetadataLimitsConfig{MaxValueSize: 16}, MetadataSidecarConfig{}), "must be at least 64")
+	assert.ErrorContains(t, validate(MetadataLimitsConfig{MaxSize: 1024, MaxValueSize: 2048}, MetadataSidecarConfig{}), "cannot exceed max_size")
+	assert.ErrorContains(t, validate(MetadataLimitsConfig{MaxSize: 1024}, MetadataSidecarConfig{Mode: MetadataSidecarModeOversize, InlineLimit: 2048}), "inline_limit (2048) cannot exceed")
+}
+
+func TestValidateLegacyMetadataPrefixes(t *testing.T) {
+	validate := func(prefix string, legacy []string, migration string) (*Config, error) {
+		cfg := &Config{Encryption: EncryptionConfig{
+			MetadataKeyPrefix:         &prefix,
+			LegacyMetadataKeyPrefixes: legacy,
+			MetadataPrefixMigration:   migration,
+		}}
+		return cfg, validateLegacyMetadataPrefixes(cfg)
+	}
+
+	cfg, err := validate("s3ep-", nil, "")
+	assert.NoError(t, err)
+	assert.Equal(t, MetadataPrefixMigrationOff, cfg.Encryption.MetadataPrefixMigration)
+
+	_, err = validate("acme-", []string{"s3ep-", "old-"}, MetadataPrefixMigrationOnRead)
+	assert.NoError(t, err)
+	_, err = validate("s3ep-acme-", []string{"s3ep-"}, MetadataPrefixMigrationOff)
+	assert.NoError(t, err)
+
+	_, err = validate("acme-", []string{""}, "")
+	assert.ErrorContains(t, err, "must not be empty")
+	_, err = validate("acme-", []string{"ACME-"}, "")
+	assert.ErrorContains(t, err, "is the current metadata_key_prefix")
+	_, err = validate("acme-", []string{"s3ep-", "s3ep-"}, "")
+	assert.ErrorContains(t, err, "is listed twice")
+	_, err = validate("acme-", nil, MetadataPrefixMigrationOnRead)
+	assert.ErrorContains(t, err, "requires encryption.legacy_metadata_key_prefixes")
+	_, err = validate("acme-", []string{"s3ep-"}, "always")
+	assert.ErrorContains(t, err, "encryption.metadata_prefix_migration")
+}
+
+func TestValidateSSEHeaders(t *testing.T) {
+	validate := func(sse SSEHeaderEmulationConfig) (*Config, error) {
+		cfg := &Config{Encryption: EncryptionConfig{SSEHeaders: sse}}
+		return cfg, validateSSEHeaders(cfg)
+	}
+
+	// Disabled settings are not checked
+	_, err := validate(SSEHeaderEmulationConfig{Algorithm: "aws:kms:dsse"})
+	assert.NoError(t, err)
+
+	cfg, err := validate(SSEHeaderEmulationConfig{Enabled: true, KMSKeyID: "arn:aws:kms:eu-central-1:111122223333:key/proxy"})
+	assert.NoError(t, err)
+	assert.Equal(t, SSEHeaderAlgorithmKMS, cfg.Encryption.SSEHeaders.Algorithm)
+
+	_, err = validate(SSEHeaderEmulationConfig{Enabled: true, Algorithm: SSEHeaderAlgorithmAES256})
+	assert.NoError(t, err)
+
+	_, err = validate(SSEHeaderEmulationConfig{Enabled: true, Algorithm: SSEHeaderAlgorithmKMS})
+	assert.ErrorContains(t, err, "kms_key_id is required")
+	_, err = validate(SSEHeaderEmulationConfig{Enabled: true, Algorithm: SSEHeaderAlgorithmAES256, KMSKeyID: "key"})
+	assert.ErrorContains(t, err, "only valid with algorithm 'aws:kms'")
+	_, err = validate(SSEHeaderEmulationConfig{Enabled: true, Algorithm: "aws:kms:dsse", KMSKeyID: "key"})
+	assert.ErrorContains(t, err, "encryption.sse_headers.algorithm")
+}
+
+func TestValidateEncryption_SelectableProviders(t *testing.T) {
+	newConfig := func(active string, selectable ...string) *Config {
+		return &Config{
+			TargetEndpoint: "http://localhost:9000",
+			Encryption: EncryptionConfig{
+				EncryptionMethodAlias: active,
+				SelectableProviders:   selectable,
+				Providers: []EncryptionProvider{
+					{Alias: "default", Type: "aes", Config: map[string]interface{}{"aes_key": "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI="}},
+					{Alias: "tenant-a", Type: "aes", Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}},
+					{Alias: "plain", Type: "none"},
+				},
+			},
+		}
+	}
+
+	cfg := newConfig("default", "tenant-a")
+	require.NoError(t, validateEncryption(cfg))
+	assert.True(t, cfg.IsSelectableProvider("tenant-a"))
+	assert.True(t, cfg.IsSelectableProvider("default"), "the active provider can always be selected")
+	assert.False(t, cfg.IsSelectableProvider("plain"))
+
+	for name, cfg := range map[string]*Config{
+		"unknown alias":   newConfig("default", "tenant-b"),
+		"none provider":   newConfig("default", "plain"),
+		"duplicate alias": newConfig("default", "tenant-a", "tenant-a"),
+		"none active":     newConfig("plain", "tenant-a"),
+	} {
+		err := validateEncryption(cfg)
+		assert.ErrorContains(t, err, "encryption.selectable_providers", name)
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	newConfig := func(tenants ...TenantConfig) *Config {
+		return &Config{
+			S3Clients: []S3ClientCredentials{{Type: "static", AccessKeyID: "ACMEKEY"}, {Type: "static", AccessKeyID: "GLOBEXKEY"}},
+			Encryption: EncryptionConfig{
+				EncryptionMethodAlias: "default",
+				SelectableProviders:   []string{"selectable"},
+				Providers: []EncryptionProvider{
+					{Alias: "default", Type: "aes"},
+					{Alias: "selectable", Type: "aes"},
+					{Alias: "acme", Type: "aes"},
+					{Alias: "globex", Type: "aes"},
+					{Alias: "plain", Type: "none"},
+				},
+				Tenants: tenants,
+			},
+		}
+	}
+	acme := TenantConfig{Name: "acme", Provider: "acme", AccessKeys: []string{"ACMEKEY"}, Buckets: []string{"acme-*"}}
+	globex := TenantConfig{Name: "globex", Provider: "globex", Buckets: []string{"globex", "shared-globex-*"}}
+
+	cfg := newConfig(acme, globex)
+	require.NoError(t, validateTenants(cfg))
+	assert.Equal(t, "acme", cfg.TenantFor("ACMEKEY", "globex").Name, "the access key takes precedence")
+	assert.Equal(t, "acme", cfg.TenantFor("GLOBEXKEY", "acme-data").Name)
+	assert.Equal(t, "globex", cfg.TenantFor("", "globex").Name)
+	assert.Equal(t, "globex", cfg.TenantFor("", "shared-globex-1").Name)
+	assert.Nil(t, cfg.TenantFor("GLOBEXKEY", "shared"))
+	assert.Equal(t, "acme", cfg.TenantOfProvider("acme").Name)
+	assert.Nil(t, cfg.TenantOfProvider("default"))
+
+	for name, tenants := range map[string][]TenantConfig{
+		"missing name":        {{Provider: "acme", Buckets: []string{"acme"}}},
+		"duplicate name":      {acme, {Name: "acme", Provider: "globex", Buckets: []string{"globex"}}},
+		"unknown provider":    {{Name: "acme", Provider: "missing", Buckets: []string{"acme"}}},
+		"none provider":       {{Name: "acme", Provider: "plain", Buckets: []string{"acme"}}},
+		"active provider":     {{Name: "acme", Provider: "default", Buckets: []string{"acme"}}},
+		"selectable provider": {{Name: "acme", Provider: "selectable", Buckets: []string{"acme"}}},
+		"shared provider":     {acme, {Name: "globex", Provider: "acme", Buckets: []string{"globex"}}},
+		"no match":            {{Name: "acme", Provider: "acme"}},
+		"unknown access key":  {{Name: "acme", Provider: "acme", AccessKeys: []string{"MISSING"}}},
+		"shared access key":   {acme, {Name: "globex", Provider: "globex", AccessKeys: []string{"ACMEKEY"}}},
+		"invalid pattern":     {{Name: "acme", Provider: "acme", Buckets: []string{"*"}}},
+		"shared pattern":      {acme, {Name: "globex", Provider: "globex", Buckets: []string{"acme-*"}}},
+	} {
+		err := validateTenants(newConfig(tenants...))
+		assert.ErrorContains(t, err, "encryption.tenants", name)
+	}
+}
+
 func TestValidateEncryption_MissingActiveProvider(t *testing.T) {
 	cfg := &Config{
 		TargetEndpoint: "http://localhost:9000",
@@ -298,6 +552,49 @@ func TestValidateEncryption_MissingAESKey(t *testing.T) {
 	assert.Contains(t, err.Error(), "aes_key is required when using aes encryption")
 }
 
+func TestValidateEncryption_VaultTransit(t *testing.T) {
+	validate := func(options map[string]interface{}) error {
+		return validateEncryption(&Config{
+			Encryption: EncryptionConfig{
+				EncryptionMethodAlias: "vault",
+				Providers:             []EncryptionProvider{{Alias: "vault", Type: "vault-transit", Config: options}},
+			},
+		})
+	}
+
+	assert.NoError(t, validate(map[string]interface{}{"address": "https://vault:8200", "key_name": "s3", "token": "t"}))
+	assert.NoError(t, validate(map[string]interface{}{"address": "https://vault:8200", "key_name": "s3", "auth_method": "kubernetes", "kubernetes_role": "proxy"}))
+	assert.ErrorContains(t, validate(map[string]interface{}{"key_name": "s3", "token": "t"}), "address is required")
+	assert.ErrorContains(t, validate(map[string]interface{}{"address": "https://vault:8200", "key_name": "s3", "auth_method": "approle", "role_id": "r"}), "secret_id is required for vault approle auth")
+	assert.ErrorContains(t, validate(map[string]interface{}{"address": "https://vault:8200", "key_name": "s3", "auth_method": "ldap"}), "unsupported vault auth method")
+}
+
+func TestValidateEncryption_DataCipher(t *testing.T) {
+	validate := func(dataCipher string, streamingThreshold int64) error {
+		return validateEncryption(&Config{
+			Optimizations: OptimizationsConfig{StreamingThreshold: streamingThreshold},
+			Encryption: EncryptionConfig{
+				EncryptionMethodAlias: "default",
+				Providers: []EncryptionProvider{{
+					Alias:      "default",
+					Type:       "aes",
+					DataCipher: dataCipher,
+					Config:     map[string]interface{}{"aes_key": "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI="},
+				}},
+			},
+		})
+	}
+
+	for _, dataCipher := range []string{"", "aes", "chacha20", "aes-gcm-siv", "auto"} {
+		assert.NoError(t, validate(dataCipher, 0), dataCipher)
+	}
+	assert.ErrorContains(t, validate("chacha20-poly1305", 0), "data_cipher must be one of")
+
+	// aes-gcm-siv cannot seal objects larger than its limit
+	assert.NoError(t, validate("aes", 128<<20))
+	assert.ErrorContains(t, validate("aes-gcm-siv", 128<<20), "streaming_threshold of at most")
+}
+
 func TestValidateEncryption_UnsupportedType(t *testing.T) {
 	cfg := &Config{
 		TargetEndpoint: "http://localhost:9000",
@@ -317,3 +614,1139 @@ func TestValidateEncryption_UnsupportedType(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported encryption type: unsupported")
 }
+
+func TestGetBackendProfile(t *testing.T) {
+	tests := []struct {
+		name          string
+		backend       S3BackendConfig
+		expectedName  string
+		expectedPhase string
+		expectVerify  bool
+	}{
+		{"default is generic", S3BackendConfig{}, "generic", MultipartMetadataPhaseCopy, false},
+		{"verification opt-in", S3BackendConfig{VerifyMultipartMetadata: true}, "generic", MultipartMetadataPhaseCopy, true},
+		{"aws profile", S3BackendConfig{CompatibilityProfile: "aws"}, "aws", MultipartMetadataPhaseCopy, false},
+		{"complete override forces verification", S3BackendConfig{CompatibilityProfile: "minio", MultipartMetadataPhase: MultipartMetadataPhaseComplete}, "minio", MultipartMetadataPhaseComplete, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{S3Backend: tt.backend}
+			profile := cfg.GetBackendProfile()
+			assert.Equal(t, tt.expectedName, profile.Name)
+			assert.Equal(t, tt.expectedPhase, profile.MultipartMetadataPhase)
+			assert.Equal(t, tt.expectVerify, profile.VerifyMultipartMetadata)
+		})
+	}
+
+	// Metadata moved to sidecars is applied with the self-copy
+	cfg := &Config{
+		S3Backend:  S3BackendConfig{MultipartMetadataPhase: MultipartMetadataPhaseComplete},
+		Encryption: EncryptionConfig{MetadataSidecar: MetadataSidecarConfig{Mode: MetadataSidecarModeAlways}},
+	}
+	profile := cfg.GetBackendProfile()
+	assert.Equal(t, MultipartMetadataPhaseCopy, profile.MultipartMetadataPhase)
+	assert.False(t, profile.VerifyMultipartMetadata)
+
+	// So is metadata split across keys
+	cfg.Encryption = EncryptionConfig{MetadataLimits: MetadataLimitsConfig{MaxValueSize: 1024}}
+	assert.Equal(t, MultipartMetadataPhaseCopy, cfg.GetBackendProfile().MultipartMetadataPhase)
+}
+
+func TestValidateBackendCompatibility(t *testing.T) {
+	assert.NoError(t, validateBackendCompatibility(&Config{S3Backend: S3BackendConfig{CompatibilityProfile: "ceph"}}))
+	assert.Error(t, validateBackendCompatibility(&Config{S3Backend: S3BackendConfig{CompatibilityProfile: "unknown"}}))
+	assert.Error(t, validateBackendCompatibility(&Config{S3Backend: S3BackendConfig{MultipartMetadataPhase: "create"}}))
+}
+
+func TestValidateBackendRoutes(t *testing.T) {
+	route := func(name string, buckets ...string) BackendRoute {
+		return BackendRoute{Name: name, TargetEndpoint: "https://s3.other.example.com", Buckets: buckets}
+	}
+	tests := []struct {
+		name     string
+		routes   []BackendRoute
+		errorMsg string
+	}{
+		{name: "no routes"},
+		{name: "valid", routes: []BackendRoute{route("archive", "archive-*"), route("logs", "logs", "audit")}},
+		{name: "missing name", routes: []BackendRoute{route("", "a")}, errorMsg: "routes[0].name is required"},
+		{name: "reserved name", routes: []BackendRoute{route(DefaultBackendRouteName, "a")}, errorMsg: "reserved"},
+		{name: "duplicate name", routes: []BackendRoute{route("a", "a"), route("a", "b")}, errorMsg: "routes[1].name"},
+		{name: "missing endpoint", routes: []BackendRoute{{Name: "a", Buckets: []string{"a"}}}, errorMsg: "target_endpoint is required"},
+		{name: "no buckets", routes: []BackendRoute{route("a")}, errorMsg: "at least one bucket"},
+		{name: "catch-all", routes: []BackendRoute{route("a", "*")}, errorMsg: "invalid pattern"},
+		{name: "inner wildcard", routes: []BackendRoute{route("a", "a*b")}, errorMsg: "invalid pattern"},
+		{name: "pattern on two routes", routes: []BackendRoute{route("a", "x-*"), route("b", "x-*")}, errorMsg: "already routed to 'a'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackendRoutes(&Config{S3Backend: S3BackendConfig{Routes: tt.routes}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateBackendReplicas(t *testing.T) {
+	replica := func(name string) BackendReplica {
+		return BackendReplica{Name: name, TargetEndpoint: "https://s3.dc2.example.com"}
+	}
+	tests := []struct {
+		name     string
+		backend  S3BackendConfig
+		errorMsg string
+	}{
+		{name: "no replicas"},
+		{name: "valid", backend: S3BackendConfig{Replicas: []BackendReplica{replica("dc2"), replica("dc3")}, ReadPreference: ReadPreferenceNearest, ReplicaHealthCheckInterval: 15}},
+		{name: "own credentials", backend: S3BackendConfig{Replicas: []BackendReplica{{Name: "dc2", TargetEndpoint: "https://dc2", AccessKeyID: "a", SecretKey: "s"}}, ReplicaHealthCheckInterval: 15}},
+		{name: "unknown read preference", backend: S3BackendConfig{ReadPreference: "fastest"}, errorMsg: "s3_backend.read_preference"},
+		{name: "no health checks", backend: S3BackendConfig{Replicas: []BackendReplica{replica("dc2")}}, errorMsg: "replica_health_check_interval"},
+		{name: "missing name", backend: S3BackendConfig{Replicas: []BackendReplica{replica("")}, ReplicaHealthCheckInterval: 15}, errorMsg: "replicas[0].name is required"},
+		{name: "reserved name", backend: S3BackendConfig{Replicas: []BackendReplica{replica(DefaultBackendRouteName)}, ReplicaHealthCheckInterval: 15}, errorMsg: "reserved"},
+		{name: "duplicate name", backend: S3BackendConfig{Replicas: []BackendReplica{replica("dc2"), replica("dc2")}, ReplicaHealthCheckInterval: 15}, errorMsg: "replicas[1].name"},
+		{name: "missing endpoint", backend: S3BackendConfig{Replicas: []BackendReplica{{Name: "dc2"}}, ReplicaHealthCheckInterval: 15}, errorMsg: "target_endpoint is required"},
+		{name: "half credentials", backend: S3BackendConfig{Replicas: []BackendReplica{{Name: "dc2", TargetEndpoint: "https://dc2", AccessKeyID: "a"}}, ReplicaHealthCheckInterval: 15}, errorMsg: "set together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackendReplicas(&Config{S3Backend: tt.backend})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateReplication(t *testing.T) {
+	valid := func(modify func(r *ReplicationConfig)) ReplicationConfig {
+		r := ReplicationConfig{Enabled: true, TargetEndpoint: "https://s3.dr.example.com", QueueSize: 10000, Workers: 4, MaxRetries: 5, RetryBackoff: 5}
+		if modify != nil {
+			modify(&r)
+		}
+		return r
+	}
+	tests := []struct {
+		name        string
+		replication ReplicationConfig
+		errorMsg    string
+	}{
+		{name: "disabled", replication: ReplicationConfig{Workers: 0}},
+		{name: "valid", replication: valid(nil)},
+		{name: "bucket patterns", replication: valid(func(r *ReplicationConfig) { r.Buckets = []string{"prod-*", "billing"} })},
+		{name: "no retries", replication: valid(func(r *ReplicationConfig) { r.MaxRetries = 0 })},
+		{name: "missing endpoint", replication: valid(func(r *ReplicationConfig) { r.TargetEndpoint = "" }), errorMsg: "replication.target_endpoint is required"},
+		{name: "half credentials", replication: valid(func(r *ReplicationConfig) { r.SecretKey = "s" }), errorMsg: "set together"},
+		{name: "invalid pattern", replication: valid(func(r *ReplicationConfig) { r.Buckets = []string{"prod-["} }), errorMsg: "replication.buckets"},
+		{name: "empty queue", replication: valid(func(r *ReplicationConfig) { r.QueueSize = 0 }), errorMsg: "replication.queue_size"},
+		{name: "no workers", replication: valid(func(r *ReplicationConfig) { r.Workers = 0 }), errorMsg: "replication.workers"},
+		{name: "negative retries", replication: valid(func(r *ReplicationConfig) { r.MaxRetries = -1 }), errorMsg: "replication.max_retries"},
+		{name: "no backoff", replication: valid(func(r *ReplicationConfig) { r.RetryBackoff = 0 }), errorMsg: "replication.retry_backoff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReplication(&Config{Replication: tt.replication})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	valid := func(targets ...NotificationTargetConfig) NotificationsConfig {
+		return NotificationsConfig{Targets: targets, QueueSize: 10000, BatchSize: 10, FlushInterval: 1000, Timeout: 10}
+	}
+	webhook := NotificationTargetConfig{Type: NotificationTargetWebhook, URL: "https://hooks.example.com/s3"}
+	kafka := NotificationTargetConfig{Type: NotificationTargetKafka, URL: "http://kafka-rest:8082", Topic: "s3-events"}
+	sqs := NotificationTargetConfig{Type: NotificationTargetSQS, URL: "https://sqs.eu-central-1.amazonaws.com/123456789012/events", AccessKeyID: "a", SecretKey: "s"}
+	with := func(target NotificationTargetConfig, modify func(t *NotificationTargetConfig)) NotificationTargetConfig {
+		modify(&target)
+		return target
+	}
+	tests := []struct {
+		name          string
+		notifications NotificationsConfig
+		errorMsg      string
+	}{
+		{name: "no targets", notifications: NotificationsConfig{}},
+		{name: "all types", notifications: valid(webhook, kafka, sqs)},
+		{name: "filters", notifications: valid(with(webhook, func(t *NotificationTargetConfig) {
+			t.Events, t.Buckets, t.Prefix, t.Suffix = []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:Delete"}, []string{"data-*"}, "images/", ".png"
+		}))},
+		{name: "sqs outside AWS", notifications: valid(with(sqs, func(t *NotificationTargetConfig) { t.URL, t.Region = "http://elasticmq:9324/queue/events", "elasticmq" }))},
+		{name: "no queue", notifications: NotificationsConfig{Targets: []NotificationTargetConfig{webhook}, BatchSize: 10, FlushInterval: 1000, Timeout: 10}, errorMsg: "notifications.queue_size"},
+		{name: "unknown type", notifications: valid(with(webhook, func(t *NotificationTargetConfig) { t.Type = "amqp" })), errorMsg: "notifications.targets[0].type"},
+		{name: "invalid url", notifications: valid(with(webhook, func(t *NotificationTargetConfig) { t.URL = "hooks.example.com" })), errorMsg: "notifications.targets[0].url"},
+		{name: "kafka without topic", notifications: valid(with(kafka, func(t *NotificationTargetConfig) { t.Topic = "" })), errorMsg: "topic is required"},
+		{name: "sqs without credentials", notifications: valid(with(sqs, func(t *NotificationTargetConfig) { t.SecretKey = "" })), errorMsg: "secret_key are required"},
+		{name: "sqs without region", notifications: valid(with(sqs, func(t *NotificationTargetConfig) { t.URL = "http://elasticmq:9324/queue/events" })), errorMsg: "region is required"},
+		{name: "unknown event", notifications: valid(with(webhook, func(t *NotificationTargetConfig) { t.Events = []string{"s3:ObjectRestore:*"} })), errorMsg: "matches no event"},
+		{name: "invalid bucket pattern", notifications: valid(with(webhook, func(t *NotificationTargetConfig) { t.Buckets = []string{"data-["} })), errorMsg: "notifications.targets[0].buckets"},
+		{name: "duplicate name", notifications: valid(webhook, webhook), errorMsg: "'webhook' is already used"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNotifications(&Config{Notifications: tt.notifications})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateObjectCache(t *testing.T) {
+	valid := func(modify func(c *ObjectCacheConfig)) ObjectCacheConfig {
+		c := ObjectCacheConfig{Enabled: true, TTL: 60, MaxObjectSize: 1 << 20, MemorySize: 64 << 20, DiskSize: 1 << 30}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+	tests := []struct {
+		name     string
+		cache    ObjectCacheConfig
+		errorMsg string
+	}{
+		{name: "disabled", cache: ObjectCacheConfig{}},
+		{name: "memory", cache: valid(nil)},
+		{name: "memory and disk", cache: valid(func(c *ObjectCacheConfig) { c.DiskDirectory = "/var/cache/s3ep" })},
+		{name: "disk only", cache: valid(func(c *ObjectCacheConfig) { c.MemorySize, c.DiskDirectory = 0, "/var/cache/s3ep" })},
+		{name: "bucket patterns", cache: valid(func(c *ObjectCacheConfig) { c.Buckets = []string{"config", "thumbs-*"} })},
+		{name: "no ttl", cache: valid(func(c *ObjectCacheConfig) { c.TTL = 0 }), errorMsg: "object_cache.ttl"},
+		{name: "no object size", cache: valid(func(c *ObjectCacheConfig) { c.MaxObjectSize = 0 }), errorMsg: "object_cache.max_object_size"},
+		{name: "negative memory", cache: valid(func(c *ObjectCacheConfig) { c.MemorySize = -1 }), errorMsg: "object_cache.memory_size"},
+		{name: "no tier", cache: valid(func(c *ObjectCacheConfig) { c.MemorySize = 0 }), errorMsg: "memory_size or disk_directory is required"},
+		{name: "memory below object size", cache: valid(func(c *ObjectCacheConfig) { c.MemorySize = 1024 }), errorMsg: "object_cache.memory_size: must be at least"},
+		{name: "disk below object size", cache: valid(func(c *ObjectCacheConfig) { c.DiskDirectory, c.DiskSize = "/var/cache/s3ep", 1024 }), errorMsg: "object_cache.disk_size"},
+		{name: "invalid pattern", cache: valid(func(c *ObjectCacheConfig) { c.Buckets = []string{"thumbs-["} }), errorMsg: "object_cache.buckets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateObjectCache(&Config{ObjectCache: tt.cache})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateAccessLogging(t *testing.T) {
+	tests := []struct {
+		name     string
+		logging  AccessLoggingConfig
+		errorMsg string
+	}{
+		{name: "disabled"},
+		{name: "valid", logging: AccessLoggingConfig{TargetPrefix: "via-proxy/", UserAgentTag: "s3ep-gateway.eu-1"}},
+		{name: "leading slash", logging: AccessLoggingConfig{TargetPrefix: "/proxy/"}, errorMsg: "must not start with '/'"},
+		{name: "prefix too long", logging: AccessLoggingConfig{TargetPrefix: strings.Repeat("p", 257)}, errorMsg: "maximum length"},
+		{name: "space in tag", logging: AccessLoggingConfig{UserAgentTag: "s3ep proxy"}, errorMsg: "invalid character"},
+		{name: "slash in tag", logging: AccessLoggingConfig{UserAgentTag: "s3ep/1.0"}, errorMsg: "invalid character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAccessLogging(&Config{S3Backend: S3BackendConfig{AccessLogging: tt.logging}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateNetworkTuning(t *testing.T) {
+	tests := []struct {
+		name     string
+		network  NetworkTuningConfig
+		errorMsg string
+	}{
+		{name: "defaults"},
+		{name: "valid", network: NetworkTuningConfig{
+			ResponseBufferSize: 1024 * 1024,
+			Listener:           SocketTuningConfig{SendBuffer: 8 * 1024 * 1024},
+			Backend:            BackendTransportConfig{SocketTuningConfig: SocketTuningConfig{ReceiveBuffer: 16 * 1024 * 1024}, ReadBufferSize: 256 * 1024},
+		}},
+		{name: "response buffer too small", network: NetworkTuningConfig{ResponseBufferSize: 1024}, errorMsg: "response_buffer_size"},
+		{name: "response buffer too large", network: NetworkTuningConfig{ResponseBufferSize: 16 * 1024 * 1024}, errorMsg: "response_buffer_size"},
+		{name: "negative socket buffer", network: NetworkTuningConfig{Listener: SocketTuningConfig{ReceiveBuffer: -1}}, errorMsg: "listener.socket_receive_buffer"},
+		{name: "transport buffer too large", network: NetworkTuningConfig{Backend: BackendTransportConfig{WriteBufferSize: 32 * 1024 * 1024}}, errorMsg: "backend.write_buffer_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNetworkTuning(&Config{Optimizations: OptimizationsConfig{Network: tt.network}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+
+	assert.Equal(t, 128*1024, (&Config{}).GetResponseBufferSize())
+	assert.True(t, SocketTuningConfig{}.NoDelay())
+}
+
+func TestValidateUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		monitoring bool
+		usage      UsageConfig
+		errorMsg   string
+	}{
+		{name: "disabled"},
+		{name: "valid", monitoring: true, usage: UsageConfig{Enabled: true, Interval: 3600, Buckets: []string{"data"}, HeadConcurrency: 8}},
+		{name: "monitoring disabled", usage: UsageConfig{Enabled: true}, errorMsg: "requires monitoring.enabled"},
+		{name: "interval too short", monitoring: true, usage: UsageConfig{Enabled: true, Interval: 10}, errorMsg: "monitoring.usage.interval"},
+		{name: "concurrency too high", monitoring: true, usage: UsageConfig{Enabled: true, HeadConcurrency: 100}, errorMsg: "monitoring.usage.head_concurrency"},
+		{name: "empty bucket name", monitoring: true, usage: UsageConfig{Enabled: true, Buckets: []string{" "}}, errorMsg: "monitoring.usage.buckets[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUsage(&Config{Monitoring: MonitoringConfig{Enabled: tt.monitoring, Usage: tt.usage}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateKEKRotation(t *testing.T) {
+	assert.NoError(t, validateKEKRotation(&Config{}))
+	assert.NoError(t, validateKEKRotation(&Config{Monitoring: MonitoringConfig{Enabled: true, KEKRotation: KEKRotationConfig{Enabled: true, Concurrency: 8}}}))
+
+	err := validateKEKRotation(&Config{Monitoring: MonitoringConfig{KEKRotation: KEKRotationConfig{Enabled: true}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires monitoring.enabled")
+
+	err = validateKEKRotation(&Config{Monitoring: MonitoringConfig{Enabled: true, KEKRotation: KEKRotationConfig{Enabled: true, Concurrency: 100}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "monitoring.kek_rotation.concurrency")
+
+	err = validateKEKRotation(&Config{Monitoring: MonitoringConfig{Enabled: true, KEKRotation: KEKRotationConfig{Enabled: true, MaxConcurrentJobs: -1}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "monitoring.kek_rotation.max_concurrent_jobs: must not be negative")
+}
+
+func TestValidateMultipartGC(t *testing.T) {
+	assert.NoError(t, validateMultipartGC(&Config{}))
+	assert.NoError(t, validateMultipartGC(&Config{Monitoring: MonitoringConfig{Enabled: true, MultipartGC: MultipartGCConfig{Enabled: true}}}))
+
+	err := validateMultipartGC(&Config{Monitoring: MonitoringConfig{MultipartGC: MultipartGCConfig{Enabled: true}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires monitoring.enabled")
+}
+
+func TestGetLicenseOptions(t *testing.T) {
+	options := (&Config{}).GetLicenseOptions()
+	assert.Equal(t, time.Duration(0), options.ClockSkew)
+	assert.Equal(t, time.Hour, options.RevalidationInterval)
+	assert.Equal(t, []int{30, 7, 1}, options.WarningThresholds)
+	assert.NotNil(t, options.TokenLoader)
+
+	options = (&Config{License: LicenseConfig{
+		ClockSkewTolerance:   120,
+		RevalidationInterval: 600,
+		WarningDays:          []int{14},
+		GraceDays:            3,
+		Enforcement:          "shutdown",
+	}}).GetLicenseOptions()
+	assert.Equal(t, 2*time.Minute, options.ClockSkew)
+	assert.Equal(t, 10*time.Minute, options.RevalidationInterval)
+	assert.Equal(t, []int{14}, options.WarningThresholds)
+	assert.Equal(t, 72*time.Hour, options.GracePeriod)
+	assert.Equal(t, license.EnforcementShutdown, options.Enforcement)
+
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{ClockSkewTolerance: -1}}))
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{WarningDays: []int{7, 0}}}))
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{GraceDays: -1}}))
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{Enforcement: "warn"}}))
+	assert.NoError(t, validateLicenseConfig(&Config{License: LicenseConfig{Enforcement: "restrict"}}))
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{Usage: LicenseUsageConfig{Enabled: true, StateFile: "usage.json"}}}))
+	assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{Usage: LicenseUsageConfig{Enabled: true, RetentionMonths: -1}}}))
+}
+
+func TestGetLicenseOptions_Revocation(t *testing.T) {
+	options := (&Config{}).GetLicenseOptions()
+	assert.Empty(t, options.Revocation.Source)
+
+	revocation := RevocationConfig{
+		Source:          "https://s3ep.com/revocations.jwt",
+		RefreshInterval: 600,
+		MaxAge:          86400,
+		FailClosed:      true,
+		CacheFile:       "/var/lib/s3ep/revocations.jwt",
+	}
+	options = (&Config{License: LicenseConfig{Revocation: revocation}}).GetLicenseOptions()
+	assert.Equal(t, license.RevocationOptions{
+		Source:          revocation.Source,
+		RefreshInterval: 10 * time.Minute,
+		MaxAge:          24 * time.Hour,
+		FailClosed:      true,
+		CacheFile:       revocation.CacheFile,
+	}, options.Revocation)
+	assert.NoError(t, validateLicenseConfig(&Config{License: LicenseConfig{Revocation: revocation}}))
+
+	invalid := []RevocationConfig{
+		{Source: "https://", RefreshInterval: 600},
+		{Source: "revocations.jwt", RefreshInterval: -1},
+		{Source: "revocations.jwt", MaxAge: -1},
+		{Source: "revocations.jwt", RefreshInterval: 3600, MaxAge: 60},
+	}
+	for _, r := range invalid {
+		assert.Error(t, validateLicenseConfig(&Config{License: LicenseConfig{Revocation: r}}), "%+v", r)
+	}
+}
+
+func TestIntegrityAlgorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption EncryptionConfig
+		errorMsg   string
+	}{
+		{name: "defaults"},
+		{name: "valid", encryption: EncryptionConfig{
+			IntegrityAlgorithm: IntegrityAlgorithmBLAKE3,
+			Providers:          []EncryptionProvider{{Alias: "a", IntegrityAlgorithm: IntegrityAlgorithmHMACSHA512}},
+			IntegrityAlgorithmOverrides: []IntegrityAlgorithmOverride{
+				{Buckets: []string{"finance-*", "ledger"}, Algorithm: IntegrityAlgorithmHMACSHA512},
+			},
+		}},
+		{name: "unknown default", encryption: EncryptionConfig{IntegrityAlgorithm: "hmac-md5"}, errorMsg: "encryption.integrity_algorithm"},
+		{name: "unknown provider algorithm", encryption: EncryptionConfig{Providers: []EncryptionProvider{{Alias: "a", IntegrityAlgorithm: "sha1"}}}, errorMsg: "encryption.providers[0].integrity_algorithm"},
+		{name: "override without buckets", encryption: EncryptionConfig{IntegrityAlgorithmOverrides: []IntegrityAlgorithmOverride{{Algorithm: IntegrityAlgorithmBLAKE3}}}, errorMsg: "at least one bucket"},
+		{name: "invalid pattern", encryption: EncryptionConfig{IntegrityAlgorithmOverrides: []IntegrityAlgorithmOverride{{Buckets: []string{"a*b"}, Algorithm: IntegrityAlgorithmBLAKE3}}}, errorMsg: "invalid pattern"},
+		{name: "duplicate pattern", encryption: EncryptionConfig{IntegrityAlgorithmOverrides: []IntegrityAlgorithmOverride{
+			{Buckets: []string{"media"}, Algorithm: IntegrityAlgorithmBLAKE3},
+			{Buckets: []string{"media"}, Algorithm: IntegrityAlgorithmHMACSHA512},
+		}}, errorMsg: "already used"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIntegrityAlgorithms(&Config{Encryption: tt.encryption})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+
+	cfg := &Config{Encryption: EncryptionConfig{
+		EncryptionMethodAlias: "active",
+		Providers: []EncryptionProvider{
+			{Alias: "inactive", IntegrityAlgorithm: IntegrityAlgorithmBLAKE3},
+			{Alias: "active", IntegrityAlgorithm: IntegrityAlgorithmHMACSHA512},
+		},
+		IntegrityAlgorithmOverrides: []IntegrityAlgorithmOverride{
+			{Buckets: []string{"media-*"}, Algorithm: IntegrityAlgorithmBLAKE3},
+			{Buckets: []string{"media-raw*"}, Algorithm: IntegrityAlgorithmHMACSHA256},
+			{Buckets: []string{"media-raw-eu"}, Algorithm: IntegrityAlgorithmHMACSHA512},
+		},
+	}}
+	assert.Equal(t, IntegrityAlgorithmBLAKE3, cfg.IntegrityAlgorithmFor("media-eu"))
+	assert.Equal(t, IntegrityAlgorithmHMACSHA256, cfg.IntegrityAlgorithmFor("media-raw-us"), "longest prefix wins")
+	assert.Equal(t, IntegrityAlgorithmHMACSHA512, cfg.IntegrityAlgorithmFor("media-raw-eu"), "exact name wins")
+	assert.Equal(t, IntegrityAlgorithmHMACSHA512, cfg.IntegrityAlgorithmFor("other"), "active provider")
+	assert.Equal(t, IntegrityAlgorithmHMACSHA256, (&Config{}).IntegrityAlgorithmFor("other"))
+}
+
+func TestValidateS3ClientScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []S3ClientScope
+		errorMsg string
+	}{
+		{name: "no scopes"},
+		{name: "valid", scopes: []S3ClientScope{
+			{Buckets: []string{"data", "logs-*"}, Prefixes: []string{"app/"}, Operations: []string{ScopeOperationRead, ScopeOperationWrite}},
+			{Operations: []string{ScopeOperationList}},
+		}},
+		{name: "invalid bucket pattern", scopes: []S3ClientScope{{Buckets: []string{"a*b"}}}, errorMsg: "scopes[0].buckets: invalid pattern"},
+		{name: "match-all bucket pattern", scopes: []S3ClientScope{{Buckets: []string{"*"}}}, errorMsg: "invalid pattern"},
+		{name: "empty prefix", scopes: []S3ClientScope{{Prefixes: []string{""}}}, errorMsg: "empty prefix"},
+		{name: "unknown operation", scopes: []S3ClientScope{{}, {Operations: []string{"admin"}}}, errorMsg: "scopes[1].operations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{S3Clients: []S3ClientCredentials{{
+				Type:        "static",
+				AccessKeyID: "app-client",
+				SecretKey:   "app-client-secret-key",
+				Scopes:      tt.scopes,
+			}}}
+			err := validateS3Clients(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateRecovery(t *testing.T) {
+	assert.NoError(t, validateRecovery(&Config{}))
+	assert.NoError(t, validateRecovery(&Config{Recovery: RecoveryConfig{CrashBundleDir: "/tmp/crash", MaxCrashBundles: 10}}))
+
+	err := validateRecovery(&Config{Recovery: RecoveryConfig{MaxCrashBundles: -1}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "recovery.max_crash_bundles")
+
+	err = validateRecovery(&Config{Recovery: RecoveryConfig{CrashBundleDir: "/tmp/crash"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be positive")
+}
+
+func TestValidateKeyCanonicalization(t *testing.T) {
+	assert.NoError(t, validateKeyCanonicalization(&Config{}))
+	assert.NoError(t, validateKeyCanonicalization(&Config{KeyCanonicalization: KeyCanonicalizationConfig{
+		Slashes: KeySlashesCollapse, DotSegments: KeyDotSegmentsRemove, EncodedSlashes: KeyEncodedSlashesReject,
+	}}))
+
+	for field, k := range map[string]KeyCanonicalizationConfig{
+		"key_canonicalization.slashes":         {Slashes: "merge"},
+		"key_canonicalization.dot_segments":    {DotSegments: "resolve"},
+		"key_canonicalization.encoded_slashes": {EncodedSlashes: "keep"},
+	} {
+		err := validateKeyCanonicalization(&Config{KeyCanonicalization: k})
+		require.Error(t, err, field)
+		assert.Contains(t, err.Error(), field)
+	}
+}
+
+func TestValidateAccessLog(t *testing.T) {
+	assert.NoError(t, validateAccessLog(&Config{AccessLog: AccessLogConfig{Format: "apache"}}), "not checked while disabled")
+	for _, format := range []string{"", "common", "combined", "json"} {
+		assert.NoError(t, validateAccessLog(&Config{AccessLog: AccessLogConfig{Enabled: true, Format: format}}), format)
+	}
+	err := validateAccessLog(&Config{AccessLog: AccessLogConfig{Enabled: true, Format: "apache"}})
+	assert.ErrorContains(t, err, "access_log.format")
+}
+
+func TestValidateUploadLimits(t *testing.T) {
+	quotas := []BucketQuotaRule{{Buckets: []string{"team-*"}, MaxBytes: 1 << 40}}
+	withUsage := MonitoringConfig{Enabled: true, Usage: UsageConfig{Enabled: true}}
+
+	tests := []struct {
+		name       string
+		limits     UploadLimitsConfig
+		monitoring MonitoringConfig
+		errorMsg   string
+	}{
+		{name: "no limits"},
+		{name: "sizes", limits: UploadLimitsConfig{MaxObjectSize: 1 << 30, MaxPartSize: 1 << 28}},
+		{name: "part size only", limits: UploadLimitsConfig{MaxPartSize: 1 << 28}},
+		{name: "quotas", limits: UploadLimitsConfig{Quotas: quotas}, monitoring: withUsage},
+		{name: "negative object size", limits: UploadLimitsConfig{MaxObjectSize: -1}, errorMsg: "upload_limits.max_object_size"},
+		{name: "negative part size", limits: UploadLimitsConfig{MaxPartSize: -1}, errorMsg: "upload_limits.max_part_size"},
+		{name: "part larger than object", limits: UploadLimitsConfig{MaxObjectSize: 1 << 20, MaxPartSize: 1 << 21}, errorMsg: "must not exceed max_object_size"},
+		{name: "quota without buckets", limits: UploadLimitsConfig{Quotas: []BucketQuotaRule{{MaxBytes: 1}}}, monitoring: withUsage, errorMsg: "upload_limits.quotas[0].buckets"},
+		{name: "invalid pattern", limits: UploadLimitsConfig{Quotas: []BucketQuotaRule{{Buckets: []string{"["}, MaxBytes: 1}}}, monitoring: withUsage, errorMsg: "invalid pattern"},
+		{name: "quota without bytes", limits: UploadLimitsConfig{Quotas: []BucketQuotaRule{{Buckets: []string{"a"}}}}, monitoring: withUsage, errorMsg: "upload_limits.quotas[0].max_bytes"},
+		{name: "quotas without usage", limits: UploadLimitsConfig{Quotas: quotas}, errorMsg: "requires monitoring.usage.enabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUploadLimits(&Config{UploadLimits: tt.limits, Monitoring: tt.monitoring})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestQuotaFor(t *testing.T) {
+	limits := UploadLimitsConfig{Quotas: []BucketQuotaRule{
+		{Buckets: []string{"archive"}, MaxBytes: 10},
+		{Buckets: []string{"team-*", "archive"}, MaxBytes: 20},
+	}}
+	assert.Equal(t, int64(10), limits.QuotaFor("archive"), "the first matching rule wins")
+	assert.Equal(t, int64(20), limits.QuotaFor("team-a"))
+	assert.Equal(t, int64(0), limits.QuotaFor("other"))
+}
+
+func TestValidateAudit(t *testing.T) {
+	enabled := AuditConfig{Enabled: true, Directory: "/var/lib/s3ep/audit", SigningKeyFile: "/etc/s3ep/audit.pem", SegmentMaxRecords: 10000, SegmentMaxAge: 3600,
+		SinkQueueSize: 10000, SinkBatchSize: 100, SinkFlushInterval: 1000, SinkTimeout: 10}
+
+	tests := []struct {
+		name     string
+		modify   func(a *AuditConfig)
+		errorMsg string
+	}{
+		{name: "valid"},
+		{name: "disabled", modify: func(a *AuditConfig) { *a = AuditConfig{} }},
+		{name: "no age limit", modify: func(a *AuditConfig) { a.SegmentMaxAge = 0 }},
+		{name: "missing directory", modify: func(a *AuditConfig) { a.Directory = "" }, errorMsg: "audit.directory"},
+		{name: "missing signing key", modify: func(a *AuditConfig) { a.SigningKeyFile = "" }, errorMsg: "audit.signing_key_file"},
+		{name: "no records per segment", modify: func(a *AuditConfig) { a.SegmentMaxRecords = 0 }, errorMsg: "audit.segment_max_records"},
+		{name: "negative age", modify: func(a *AuditConfig) { a.SegmentMaxAge = -1 }, errorMsg: "audit.segment_max_age"},
+		{name: "sinks without log", modify: func(a *AuditConfig) {
+			*a = AuditConfig{Sinks: []AuditSinkConfig{{Type: AuditSinkSyslog, Address: "syslog:514"}}, SinkQueueSize: 10, SinkBatchSize: 1, SinkFlushInterval: 1, SinkTimeout: 1}
+		}},
+		{name: "sinks", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{
+				{Type: AuditSinkSyslog, Network: "tcp", Address: "syslog:514", Facility: "local3"},
+				{Type: AuditSinkWebhook, URL: "https://siem.example.com/ingest"},
+				{Name: "events", Type: AuditSinkKafka, URL: "http://kafka-rest:8082", Topic: "audit"},
+			}
+		}},
+		{name: "unknown sink type", modify: func(a *AuditConfig) { a.Sinks = []AuditSinkConfig{{Type: "file"}} }, errorMsg: "audit.sinks[0].type"},
+		{name: "syslog without address", modify: func(a *AuditConfig) { a.Sinks = []AuditSinkConfig{{Type: AuditSinkSyslog}} }, errorMsg: "audit.sinks[0].address"},
+		{name: "unknown syslog network", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{{Type: AuditSinkSyslog, Network: "sctp", Address: "syslog:514"}}
+		}, errorMsg: "audit.sinks[0].network"},
+		{name: "unknown syslog facility", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{{Type: AuditSinkSyslog, Address: "syslog:514", Facility: "kern"}}
+		}, errorMsg: "audit.sinks[0].facility"},
+		{name: "webhook without url", modify: func(a *AuditConfig) { a.Sinks = []AuditSinkConfig{{Type: AuditSinkWebhook}} }, errorMsg: "audit.sinks[0].url"},
+		{name: "kafka without topic", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{{Type: AuditSinkKafka, URL: "http://kafka-rest:8082"}}
+		}, errorMsg: "audit.sinks[0].topic"},
+		{name: "duplicate sink name", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{{Type: AuditSinkWebhook, URL: "https://a.example.com"}, {Type: AuditSinkWebhook, URL: "https://b.example.com"}}
+		}, errorMsg: "audit.sinks[1].name"},
+		{name: "no sink queue", modify: func(a *AuditConfig) {
+			a.Sinks = []AuditSinkConfig{{Type: AuditSinkWebhook, URL: "https://a.example.com"}}
+			a.SinkQueueSize = 0
+		}, errorMsg: "audit.sink_queue_size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Audit: enabled}
+			if tt.modify != nil {
+				tt.modify(&cfg.Audit)
+			}
+			err := validateAudit(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateSessionStore(t *testing.T) {
+	redis := SessionStoreConfig{Type: SessionStoreRedis, KeyPrefix: "s3ep/multipart/", Timeout: 5, Redis: RedisSessionStoreConfig{Address: "redis:6379"}}
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *Config)
+		errorMsg string
+	}{
+		{name: "valid redis"},
+		{name: "memory", modify: func(cfg *Config) { cfg.SessionStore = SessionStoreConfig{Type: SessionStoreMemory} }},
+		{name: "unset", modify: func(cfg *Config) { cfg.SessionStore = SessionStoreConfig{} }},
+		{name: "valid etcd", modify: func(cfg *Config) {
+			cfg.SessionStore.Type = SessionStoreEtcd
+			cfg.SessionStore.Etcd.Endpoints = []string{"http://etcd:2379"}
+		}},
+		{name: "unknown type", modify: func(cfg *Config) { cfg.SessionStore.Type = "consul" }, errorMsg: "session_store.type must be one of"},
+		{name: "missing redis address", modify: func(cfg *Config) { cfg.SessionStore.Redis.Address = "" }, errorMsg: "session_store.redis.address"},
+		{name: "negative redis db", modify: func(cfg *Config) { cfg.SessionStore.Redis.DB = -1 }, errorMsg: "session_store.redis.db"},
+		{name: "missing etcd endpoints", modify: func(cfg *Config) { cfg.SessionStore.Type = SessionStoreEtcd }, errorMsg: "session_store.etcd.endpoints"},
+		{name: "empty key prefix", modify: func(cfg *Config) { cfg.SessionStore.KeyPrefix = "" }, errorMsg: "session_store.key_prefix"},
+		{name: "no timeout", modify: func(cfg *Config) { cfg.SessionStore.Timeout = 0 }, errorMsg: "session_store.timeout"},
+		{name: "blake3 without integrity verification", modify: func(cfg *Config) {
+			cfg.Encryption.IntegrityVerification = HMACVerificationOff
+			cfg.Encryption.IntegrityAlgorithm = IntegrityAlgorithmBLAKE3
+		}},
+		{name: "blake3", modify: func(cfg *Config) {
+			cfg.Encryption.IntegrityAlgorithm = IntegrityAlgorithmBLAKE3
+		}, errorMsg: "cannot be used with integrity algorithm"},
+		{name: "blake3 override", modify: func(cfg *Config) {
+			cfg.Encryption.IntegrityAlgorithmOverrides = []IntegrityAlgorithmOverride{{Buckets: []string{"fast-*"}, Algorithm: IntegrityAlgorithmBLAKE3}}
+		}, errorMsg: "cannot be used with integrity algorithm"},
+		{name: "blake3 with memory store", modify: func(cfg *Config) {
+			cfg.SessionStore.Type = SessionStoreMemory
+			cfg.Encryption.IntegrityAlgorithm = IntegrityAlgorithmBLAKE3
+		}},
+		{name: "persisted memory store", modify: func(cfg *Config) {
+			cfg.SessionStore = SessionStoreConfig{Type: SessionStoreMemory, PersistPath: "/var/lib/s3ep/sessions"}
+		}},
+		{name: "blake3 with persisted memory store", modify: func(cfg *Config) {
+			cfg.SessionStore = SessionStoreConfig{Type: SessionStoreMemory, PersistPath: "/var/lib/s3ep/sessions"}
+			cfg.Encryption.IntegrityAlgorithm = IntegrityAlgorithmBLAKE3
+		}, errorMsg: "session_store.persist_path cannot be used with integrity algorithm"},
+		{name: "persist path with redis", modify: func(cfg *Config) { cfg.SessionStore.PersistPath = "/var/lib/s3ep/sessions" }, errorMsg: "only supported by the memory session store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{SessionStore: redis}
+			cfg.Encryption.IntegrityVerification = HMACVerificationStrict
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := validateSessionStore(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateReconciliation(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(r *ReconciliationConfig)
+		errorMsg string
+	}{
+		{name: "valid"},
+		{name: "disabled", modify: func(r *ReconciliationConfig) { *r = ReconciliationConfig{Policy: "invalid"} }},
+		{name: "adopt", modify: func(r *ReconciliationConfig) { r.Policy = ReconcilePolicyAdopt }},
+		{name: "no interval", modify: func(r *ReconciliationConfig) { r.Interval = 0 }, errorMsg: "session_store.reconciliation.interval"},
+		{name: "negative min age", modify: func(r *ReconciliationConfig) { r.MinAge = -1 }, errorMsg: "session_store.reconciliation.min_age"},
+		{name: "unknown policy", modify: func(r *ReconciliationConfig) { r.Policy = "delete" }, errorMsg: "session_store.reconciliation.policy must be one of"},
+		{name: "empty bucket", modify: func(r *ReconciliationConfig) { r.Buckets = []string{""} }, errorMsg: "session_store.reconciliation.buckets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.SessionStore.Reconciliation = ReconciliationConfig{Enabled: true, Interval: 3600, MinAge: 3600, Policy: ReconcilePolicyAbort}
+			if tt.modify != nil {
+				tt.modify(&cfg.SessionStore.Reconciliation)
+			}
+			err := validateReconciliation(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateResponseValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation ResponseValidationConfig
+		errorMsg   string
+	}{
+		{name: "disabled"},
+		{name: "defaults", validation: ResponseValidationConfig{Enabled: true, MaxRetries: 2}},
+		{name: "no retries", validation: ResponseValidationConfig{Enabled: true, VerifyETag: true}},
+		{name: "negative retries", validation: ResponseValidationConfig{Enabled: true, MaxRetries: -1}, errorMsg: "must be between 0 and 10"},
+		{name: "too many retries", validation: ResponseValidationConfig{Enabled: true, MaxRetries: 11}, errorMsg: "must be between 0 and 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{S3Backend: S3BackendConfig{ResponseValidation: tt.validation}}
+			err := validateResponseValidation(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateRecoveryRecipients(t *testing.T) {
+	const recipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+
+	tests := []struct {
+		name       string
+		recipients []string
+		errorMsg   string
+	}{
+		{name: "none"},
+		{name: "valid", recipients: []string{recipient}},
+		{name: "identity instead of recipient", recipients: []string{"AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"}, errorMsg: "recovery_recipients[0]"},
+		{name: "bad checksum", recipients: []string{recipient, strings.TrimSuffix(recipient, "j") + "q"}, errorMsg: "recovery_recipients[1]"},
+		{name: "too many", recipients: []string{recipient, recipient, recipient, recipient, recipient, recipient}, errorMsg: "at most 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Encryption: EncryptionConfig{RecoveryRecipients: tt.recipients}}
+			err := validateRecoveryRecipients(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateProviderRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider EncryptionProvider
+		errorMsg string
+	}{
+		{name: "unlimited"},
+		{name: "rate only", provider: EncryptionProvider{RateLimit: 50}},
+		{name: "all settings", provider: EncryptionProvider{RateLimit: 0.5, RateBurst: 10, MaxQueue: 100, QueueTimeout: 2}},
+		{name: "negative rate", provider: EncryptionProvider{RateLimit: -1}, errorMsg: "rate_limit: must not be negative"},
+		{name: "negative burst", provider: EncryptionProvider{RateLimit: 1, RateBurst: -1}, errorMsg: "rate_burst"},
+		{name: "negative queue", provider: EncryptionProvider{RateLimit: 1, MaxQueue: -1}, errorMsg: "max_queue"},
+		{name: "negative timeout", provider: EncryptionProvider{RateLimit: 1, QueueTimeout: -1}, errorMsg: "queue_timeout"},
+		{name: "queue without rate", provider: EncryptionProvider{MaxQueue: 10}, errorMsg: "require rate_limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			provider.Type = "none"
+			err := validateProvider(&provider, 0)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateListener(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener ListenerConfig
+		errorMsg string
+	}{
+		{name: "defaults", listener: ListenerConfig{}},
+		{name: "valid", listener: ListenerConfig{MaxHeaderBytes: 32 * 1024, AllowedHosts: []string{"s3.example.com"}}},
+		{name: "header limit too small", listener: ListenerConfig{MaxHeaderBytes: 1024}, errorMsg: "minimum value is 8KB"},
+		{name: "negative content length", listener: ListenerConfig{MaxContentLength: -1}, errorMsg: "max_content_length"},
+		{name: "host with port", listener: ListenerConfig{AllowedHosts: []string{"s3.example.com:443"}}, errorMsg: "allowed_hosts[0]"},
+		{name: "host with scheme", listener: ListenerConfig{AllowedHosts: []string{"https://s3.example.com"}}, errorMsg: "allowed_hosts[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateListener(&Config{Listener: tt.listener})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestGetListenerConfig_Defaults(t *testing.T) {
+	l := (&Config{}).GetListenerConfig()
+	assert.Equal(t, 64*1024, l.MaxHeaderBytes)
+	assert.Equal(t, 10, l.ReadHeaderTimeout)
+	assert.Equal(t, 100, l.MaxQueryParams)
+	assert.Equal(t, int64(5)*1024*1024*1024*1024, l.MaxContentLength)
+}
+
+func TestValidateKeyPreload(t *testing.T) {
+	assert.NoError(t, validateKeyPreload(&Config{}))
+	assert.NoError(t, validateKeyPreload(&Config{KeyPreload: KeyPreloadConfig{Enabled: true, RefreshInterval: 300, Timeout: 30}}))
+
+	err := validateKeyPreload(&Config{KeyPreload: KeyPreloadConfig{Enabled: true, Timeout: 30}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key_preload.refresh_interval")
+
+	err = validateKeyPreload(&Config{KeyPreload: KeyPreloadConfig{Enabled: true, RefreshInterval: 300}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key_preload.timeout")
+}
+
+func TestValidateCompression(t *testing.T) {
+	validate := func(compression CompressionConfig) error {
+		return validateCompression(&Config{Compression: compression})
+	}
+	assert.NoError(t, validate(CompressionConfig{Codec: "lz4"}))
+	assert.NoError(t, validate(CompressionConfig{Enabled: true, Codec: "gzip", Level: 9, MinSize: 1024}))
+
+	assert.NoError(t, validate(CompressionConfig{Enabled: true, Codec: "zstd", Level: 19}))
+	assert.ErrorContains(t, validate(CompressionConfig{Enabled: true, Codec: "zstd", Level: 23}), "compression.level")
+	assert.ErrorContains(t, validate(CompressionConfig{Enabled: true, Codec: "lz4"}), "compression.codec")
+	assert.ErrorContains(t, validate(CompressionConfig{Enabled: true, Codec: "gzip", Level: 10}), "compression.level")
+	assert.ErrorContains(t, validate(CompressionConfig{Enabled: true, Codec: "gzip", MinSize: -1}), "compression.min_size")
+}
+
+func TestValidateTracing(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *TracingConfig)
+		errorMsg string
+	}{
+		{name: "valid"},
+		{name: "disabled", modify: func(c *TracingConfig) { *c = TracingConfig{} }},
+		{name: "missing endpoint", modify: func(c *TracingConfig) { c.Endpoint = "" }, errorMsg: "tracing.endpoint"},
+		{name: "endpoint without scheme", modify: func(c *TracingConfig) { c.Endpoint = "otel-collector:4318" }, errorMsg: "tracing.endpoint"},
+		{name: "missing service name", modify: func(c *TracingConfig) { c.ServiceName = "" }, errorMsg: "tracing.service_name"},
+		{name: "sample ratio above one", modify: func(c *TracingConfig) { c.SampleRatio = 1.5 }, errorMsg: "tracing.sample_ratio"},
+		{name: "no timeout", modify: func(c *TracingConfig) { c.Timeout = 0 }, errorMsg: "tracing.timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Tracing: TracingConfig{Enabled: true, Endpoint: "http://otel-collector:4318", ServiceName: "s3-encryption-proxy", SampleRatio: 0.5, Timeout: 10}}
+			if tt.modify != nil {
+				tt.modify(&cfg.Tracing)
+			}
+			err := validateTracing(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateRateLimiting(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *RateLimitingConfig)
+		errorMsg string
+	}{
+		{name: "valid"},
+		{name: "disabled", modify: func(c *RateLimitingConfig) { *c = RateLimitingConfig{} }},
+		{name: "bandwidth only", modify: func(c *RateLimitingConfig) { c.RequestsPerSecond = 0 }},
+		{name: "unknown key", modify: func(c *RateLimitingConfig) { c.KeyBy = "user" }, errorMsg: "rate_limiting.key_by"},
+		{name: "no max clients", modify: func(c *RateLimitingConfig) { c.MaxClients = 0 }, errorMsg: "rate_limiting.max_clients"},
+		{name: "negative rate", modify: func(c *RateLimitingConfig) { c.RequestsPerSecond = -1 }, errorMsg: "rate_limiting.requests_per_second"},
+		{name: "negative bandwidth burst", modify: func(c *RateLimitingConfig) { c.BandwidthBurst = -1 }, errorMsg: "rate_limiting.bandwidth_burst"},
+		{name: "no limits", modify: func(c *RateLimitingConfig) {
+			c.RequestsPerSecond, c.BytesPerSecond, c.Clients = 0, 0, nil
+		}, errorMsg: "is required when rate limiting is enabled"},
+		{name: "client without access key", modify: func(c *RateLimitingConfig) { c.Clients[0].AccessKeyID = "" }, errorMsg: "rate_limiting.clients[0].access_key_id"},
+		{name: "duplicate client", modify: func(c *RateLimitingConfig) { c.Clients = append(c.Clients, c.Clients[0]) }, errorMsg: "duplicate access key"},
+		{name: "negative client rate", modify: func(c *RateLimitingConfig) { c.Clients[0].BytesPerSecond = -1 }, errorMsg: "rate_limiting.clients[0].bytes_per_second"},
+		{name: "clients keyed by ip", modify: func(c *RateLimitingConfig) { c.KeyBy = RateLimitKeyByIP }, errorMsg: "requires key_by access_key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RateLimiting: RateLimitingConfig{
+				Enabled:           true,
+				KeyBy:             RateLimitKeyByAccessKey,
+				RequestsPerSecond: 50,
+				BytesPerSecond:    10 << 20,
+				MaxClients:        100,
+				Clients:           []RateLimitClientConfig{{AccessKeyID: "batch", RequestsPerSecond: 500}},
+			}}
+			if tt.modify != nil {
+				tt.modify(&cfg.RateLimiting)
+			}
+			err := validateRateLimiting(cfg)
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateCanary(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			S3Clients: []S3ClientCredentials{{Type: "static", AccessKeyID: "app", SecretKey: "secret"}, {Type: "static", AccessKeyID: "canary", SecretKey: "secret"}},
+			Canary: CanaryConfig{
+				Enabled:          true,
+				Bucket:           "canary-bucket",
+				Interval:         60,
+				Timeout:          10,
+				ObjectSize:       4096,
+				FailureThreshold: 3,
+			},
+		}
+	}
+
+	require.NoError(t, validateCanary(valid()))
+	client, ok := valid().CanaryCredentials()
+	require.True(t, ok)
+	assert.Equal(t, "app", client.AccessKeyID)
+
+	cfg := valid()
+	cfg.Canary.AccessKeyID = "canary"
+	require.NoError(t, validateCanary(cfg))
+	client, _ = cfg.CanaryCredentials()
+	assert.Equal(t, "canary", client.AccessKeyID)
+
+	tests := []struct {
+		name     string
+		modify   func(c *CanaryConfig)
+		errorMsg string
+	}{
+		{"missing bucket", func(c *CanaryConfig) { c.Bucket = "" }, "canary.bucket"},
+		{"unknown access key", func(c *CanaryConfig) { c.AccessKeyID = "other" }, "canary.access_key_id"},
+		{"bad endpoint", func(c *CanaryConfig) { c.Endpoint = "127.0.0.1:8080" }, "canary.endpoint"},
+		{"zero interval", func(c *CanaryConfig) { c.Interval = 0 }, "canary.interval"},
+		{"oversized object", func(c *CanaryConfig) { c.ObjectSize = 32 * 1024 * 1024 }, "canary.object_size"},
+		{"zero threshold", func(c *CanaryConfig) { c.FailureThreshold = 0 }, "canary.failure_threshold"},
+		{"bad webhook", func(c *CanaryConfig) { c.AlertWebhookURL = "ftp://alerts" }, "canary.alert_webhook_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg.Canary)
+			err := validateCanary(cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateScrubber(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Scrubber: ScrubberConfig{
+				Enabled:        true,
+				SamplesPerHour: 60,
+				ListBatchSize:  10000,
+				MaxObjectSize:  1024 * 1024 * 1024,
+				Timeout:        300,
+			},
+		}
+	}
+
+	require.NoError(t, validateScrubber(valid()))
+	require.NoError(t, validateScrubber(&Config{}), "a disabled scrubber is not validated")
+
+	tests := []struct {
+		name     string
+		modify   func(s *ScrubberConfig)
+		errorMsg string
+	}{
+		{"zero samples", func(s *ScrubberConfig) { s.SamplesPerHour = 0 }, "scrubber.samples_per_hour"},
+		{"too many samples", func(s *ScrubberConfig) { s.SamplesPerHour = 3601 }, "scrubber.samples_per_hour"},
+		{"zero list batch", func(s *ScrubberConfig) { s.ListBatchSize = 0 }, "scrubber.list_batch_size"},
+		{"negative max object size", func(s *ScrubberConfig) { s.MaxObjectSize = -1 }, "scrubber.max_object_size"},
+		{"zero timeout", func(s *ScrubberConfig) { s.Timeout = 0 }, "scrubber.timeout"},
+		{"empty bucket", func(s *ScrubberConfig) { s.Buckets = []string{"data", ""} }, "scrubber.buckets"},
+		{"bad webhook", func(s *ScrubberConfig) { s.AlertWebhookURL = "alerts.example.com" }, "scrubber.alert_webhook_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg.Scrubber)
+			err := validateScrubber(cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Setenv("S3EP_LOG_LEVEL", "error")
+	cfg, err := Parse([]byte(`
+s3_backend:
+  target_endpoint: "https://s3.example.com"
+s3_clients:
+  - type: static
+    access_key_id: "${CLIENT_ID}"
+    secret_key: "${CLIENT_SECRET}"
+encryption:
+  encryption_method_alias: plain
+  providers:
+    - alias: plain
+      type: none
+      rate_limit: 10
+`))
+	require.NoError(t, err)
+
+	// Defaults apply, the environment does not and references are kept
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, "${CLIENT_SECRET}", cfg.S3Clients[0].SecretKey)
+	assert.Equal(t, float64(10), cfg.Encryption.Providers[0].RateLimit)
+	assert.NoError(t, Check(cfg))
+
+	cfg.S3Clients[0].SecretKey = "short"
+	assert.ErrorContains(t, Check(cfg), "secret_key must be at least 16 characters")
+
+	_, err = Parse([]byte("s3_backend: [unclosed"))
+	assert.Error(t, err)
+}
+
+func TestBucketPolicies(t *testing.T) {
+	cfg, err := Parse([]byte(`
+bucket_policies:
+  default:
+    require_encryption: true
+  rules:
+    - buckets: ["archive-*", "audit-log"]
+      read_only: true
+    - buckets: ["public-?"]
+      deny_sse_c_passthrough: true
+    - buckets: ["static-assets"]
+      public_read: true
+      public_read_prefixes: ["img/", "css/"]
+`))
+	require.NoError(t, err)
+	require.NoError(t, validateBucketPolicies(cfg))
+
+	assert.Equal(t, BucketPolicy{ReadOnly: true}, cfg.BucketPolicyFor("archive-2026"))
+	assert.Equal(t, BucketPolicy{ReadOnly: true}, cfg.BucketPolicyFor("audit-log"))
+	assert.Equal(t, BucketPolicy{DenySSECPassthrough: true}, cfg.BucketPolicyFor("public-a"))
+	assert.Equal(t, BucketPolicy{RequireEncryption: true}, cfg.BucketPolicyFor("public-ab"))
+	assert.Equal(t, BucketPolicy{RequireEncryption: true}, cfg.BucketPolicyFor("data"))
+
+	assets := cfg.BucketPolicyFor("static-assets")
+	assert.True(t, assets.PublicReadable("img/logo.png"))
+	assert.False(t, assets.PublicReadable("private/report.pdf"))
+	assert.False(t, cfg.BucketPolicyFor("data").PublicReadable("img/logo.png"))
+	assert.True(t, BucketPolicy{PublicRead: true}.PublicReadable("any/key"))
+
+	invalid := func(rule BucketPolicyRule) error {
+		return validateBucketPolicies(&Config{BucketPolicies: BucketPoliciesConfig{Rules: []BucketPolicyRule{rule}}})
+	}
+	assert.ErrorContains(t, invalid(BucketPolicyRule{}), "at least one bucket")
+	assert.ErrorContains(t, invalid(BucketPolicyRule{Buckets: []string{""}}), "invalid pattern")
+	assert.ErrorContains(t, invalid(BucketPolicyRule{Buckets: []string{"logs-[a"}}), "invalid pattern")
+	assert.ErrorContains(t, invalid(BucketPolicyRule{Buckets: []string{"www"}, BucketPolicy: BucketPolicy{PublicReadPrefixes: []string{"img/"}}}), "requires public_read")
+	assert.ErrorContains(t, invalid(BucketPolicyRule{Buckets: []string{"www"}, BucketPolicy: BucketPolicy{PublicRead: true, PublicReadPrefixes: []string{""}}}), "empty prefix")
+}
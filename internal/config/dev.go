@@ -0,0 +1,66 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Developer mode settings
+const (
+	// DevBackendEndpoint is the target endpoint reported for the in-memory backend
+	DevBackendEndpoint = "memory://"
+	// DevBindAddress only listens on loopback, since client authentication is relaxed
+	DevBindAddress = "127.0.0.1:8080"
+	// DevAccessKeyID and DevSecretKey are the credentials documented for
+	// developer mode; any other credentials are accepted as well
+	DevAccessKeyID = "s3ep-dev"
+	DevSecretKey   = "s3ep-dev-secret-key"
+)
+
+// DevProviderTypes are the encryption provider types selectable in developer mode
+var DevProviderTypes = []string{"none", "aes"}
+
+// ApplyDevDefaults overrides the backend, client and encryption settings for
+// developer mode. Both a none and an AES provider with a random key are
+// registered; providerType selects the active one. Objects are lost on
+// restart, together with the key. A bind address set in the configuration
+// file is kept.
+func ApplyDevDefaults(providerType string) error {
+	if providerType != "none" && providerType != "aes" {
+		return fmt.Errorf("dev provider: unsupported type '%s' (supported: %v)", providerType, DevProviderTypes)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate developer mode AES key: %w", err)
+	}
+
+	if !viper.InConfig("bind_address") {
+		viper.Set("bind_address", DevBindAddress)
+	}
+	viper.Set("log_level", "debug")
+	viper.Set("log_format", "text")
+	viper.Set("tls.enabled", false)
+	viper.Set("s3_backend.target_endpoint", DevBackendEndpoint)
+	viper.Set("s3_backend.region", "us-east-1")
+	viper.Set("s3_backend.routes", nil)
+	viper.Set("s3_clients", []map[string]interface{}{{
+		"type":          "static",
+		"access_key_id": DevAccessKeyID,
+		"secret_key":    DevSecretKey,
+		"description":   "developer mode",
+	}})
+	viper.Set("encryption.encryption_method_alias", "dev-"+providerType)
+	viper.Set("encryption.providers", []map[string]interface{}{
+		{"alias": "dev-none", "type": "none", "description": "developer mode, no encryption"},
+		{"alias": "dev-aes", "type": "aes", "description": "developer mode, random key", "config": map[string]interface{}{
+			"aes_key": base64.StdEncoding.EncodeToString(key),
+		}},
+	})
+	viper.Set("canary.enabled", false)
+	viper.Set("key_preload.enabled", false)
+	return nil
+}
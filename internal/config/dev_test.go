@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDevDefaults(t *testing.T) {
+	viper.Reset()
+	setDefaults()
+	defer viper.Reset()
+
+	require.NoError(t, ApplyDevDefaults("none"))
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, DevBackendEndpoint, cfg.S3Backend.TargetEndpoint)
+	assert.Equal(t, DevBindAddress, cfg.BindAddress)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "dev-none", cfg.Encryption.EncryptionMethodAlias)
+	require.Len(t, cfg.Encryption.Providers, 2)
+	assert.Equal(t, "aes", cfg.Encryption.Providers[1].Type)
+	assert.NotEmpty(t, cfg.Encryption.Providers[1].Config["aes_key"])
+	require.Len(t, cfg.S3Clients, 1)
+	assert.Equal(t, DevAccessKeyID, cfg.S3Clients[0].AccessKeyID)
+	assert.False(t, cfg.DevMode, "DevMode is only set by the --dev flag")
+}
+
+func TestApplyDevDefaults_UnsupportedProvider(t *testing.T) {
+	err := ApplyDevDefaults("rsa")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported type 'rsa'")
+}
@@ -41,6 +41,11 @@ func expandEnvVars(value string) (string, error) {
 	return result.String(), nil
 }
 
+// hasEnvVarReference reports whether value contains a ${VAR} reference
+func hasEnvVarReference(value string) bool {
+	return envVarPattern.MatchString(value)
+}
+
 // expandConfigEnvVars expands ${VAR} references in all supported config fields.
 func expandConfigEnvVars(cfg *Config) error {
 	// s3_backend credentials
@@ -56,6 +61,21 @@ func expandConfigEnvVars(cfg *Config) error {
 	}
 	cfg.S3Backend.SecretKey = val
 
+	// s3_backend route credentials
+	for i := range cfg.S3Backend.Routes {
+		val, err = expandEnvVars(cfg.S3Backend.Routes[i].AccessKeyID)
+		if err != nil {
+			return fmt.Errorf("s3_backend.routes[%d].access_key_id: %w", i, err)
+		}
+		cfg.S3Backend.Routes[i].AccessKeyID = val
+
+		val, err = expandEnvVars(cfg.S3Backend.Routes[i].SecretKey)
+		if err != nil {
+			return fmt.Errorf("s3_backend.routes[%d].secret_key: %w", i, err)
+		}
+		cfg.S3Backend.Routes[i].SecretKey = val
+	}
+
 	// s3_clients credentials
 	for i := range cfg.S3Clients {
 		val, err = expandEnvVars(cfg.S3Clients[i].AccessKeyID)
@@ -71,6 +91,19 @@ func expandConfigEnvVars(cfg *Config) error {
 		cfg.S3Clients[i].SecretKey = val
 	}
 
+	// session store passwords
+	val, err = expandEnvVars(cfg.SessionStore.Redis.Password)
+	if err != nil {
+		return fmt.Errorf("session_store.redis.password: %w", err)
+	}
+	cfg.SessionStore.Redis.Password = val
+
+	val, err = expandEnvVars(cfg.SessionStore.Etcd.Password)
+	if err != nil {
+		return fmt.Errorf("session_store.etcd.password: %w", err)
+	}
+	cfg.SessionStore.Etcd.Password = val
+
 	// encryption provider config values
 	for i := range cfg.Encryption.Providers {
 		for key, val := range cfg.Encryption.Providers[i].Config {
@@ -94,6 +94,28 @@ func TestExpandConfigEnvVars_S3Backend(t *testing.T) {
 	assert.Equal(t, "my-secret-key", cfg.S3Backend.SecretKey)
 }
 
+func TestExpandConfigEnvVars_S3BackendRoutes(t *testing.T) {
+	t.Setenv("TEST_ROUTE_KEY", "route-key-id")
+	t.Setenv("TEST_ROUTE_SECRET", "route-secret-value")
+
+	cfg := &Config{
+		S3Backend: S3BackendConfig{
+			Routes: []BackendRoute{
+				{
+					Name:        "archive",
+					AccessKeyID: "${TEST_ROUTE_KEY}",
+					SecretKey:   "${TEST_ROUTE_SECRET}",
+				},
+			},
+		},
+	}
+
+	err := expandConfigEnvVars(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "route-key-id", cfg.S3Backend.Routes[0].AccessKeyID)
+	assert.Equal(t, "route-secret-value", cfg.S3Backend.Routes[0].SecretKey)
+}
+
 func TestExpandConfigEnvVars_S3Clients(t *testing.T) {
 	t.Setenv("TEST_CLIENT_KEY", "client-key-id")
 	t.Setenv("TEST_CLIENT_SECRET", "client-secret-value")
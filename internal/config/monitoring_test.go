@@ -0,0 +1,165 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMonitoring_ListExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MonitoringConfig
+		errorMsg string
+	}{
+		{
+			name:   "disabled list export is not validated",
+			config: MonitoringConfig{ListExport: ListExportConfig{Enabled: false}},
+		},
+		{
+			name: "valid list export",
+			config: MonitoringConfig{
+				Enabled:    true,
+				ListExport: ListExportConfig{Enabled: true, DiagnosticsBucket: "diagnostics", PartSize: 8 * 1024 * 1024},
+			},
+		},
+		{
+			name:     "requires monitoring server",
+			config:   MonitoringConfig{ListExport: ListExportConfig{Enabled: true, DiagnosticsBucket: "diagnostics"}},
+			errorMsg: "requires monitoring.enabled",
+		},
+		{
+			name:     "requires diagnostics bucket",
+			config:   MonitoringConfig{Enabled: true, ListExport: ListExportConfig{Enabled: true}},
+			errorMsg: "diagnostics_bucket is required",
+		},
+		{
+			name: "part size too small",
+			config: MonitoringConfig{
+				Enabled:    true,
+				ListExport: ListExportConfig{Enabled: true, DiagnosticsBucket: "diagnostics", PartSize: 1024},
+			},
+			errorMsg: "minimum value is 5MB",
+		},
+		{
+			name: "zero concurrent jobs use the default",
+			config: MonitoringConfig{
+				Enabled:    true,
+				ListExport: ListExportConfig{Enabled: true, DiagnosticsBucket: "diagnostics", MaxConcurrentJobs: 0},
+			},
+		},
+		{
+			name: "negative concurrent jobs",
+			config: MonitoringConfig{
+				Enabled:    true,
+				ListExport: ListExportConfig{Enabled: true, DiagnosticsBucket: "diagnostics", MaxConcurrentJobs: -1},
+			},
+			errorMsg: "max_concurrent_jobs: must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMonitoring(&Config{Monitoring: tt.config})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateMonitoring_BucketMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   BucketMetricsConfig
+		errorMsg string
+	}{
+		{
+			name:   "disabled bucket metrics are not validated",
+			config: BucketMetricsConfig{Enabled: false, MaxBuckets: -1},
+		},
+		{
+			name:   "valid bucket metrics",
+			config: BucketMetricsConfig{Enabled: true, MaxBuckets: 50, OverflowLabel: "_other", RebalanceInterval: 600},
+		},
+		{
+			name:     "max buckets out of range",
+			config:   BucketMetricsConfig{Enabled: true, MaxBuckets: 0, OverflowLabel: "_other"},
+			errorMsg: "must be between 1 and 10000",
+		},
+		{
+			name:     "overflow label required",
+			config:   BucketMetricsConfig{Enabled: true, MaxBuckets: 10},
+			errorMsg: "overflow_label must not be empty",
+		},
+		{
+			name:     "rebalance interval too short",
+			config:   BucketMetricsConfig{Enabled: true, MaxBuckets: 10, OverflowLabel: "_other", RebalanceInterval: 5},
+			errorMsg: "minimum value is 60 seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMonitoring(&Config{Monitoring: MonitoringConfig{BucketMetrics: tt.config}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestValidateMonitoring_Attestation(t *testing.T) {
+	tests := []struct {
+		name       string
+		monitoring bool
+		config     AttestationConfig
+		errorMsg   string
+	}{
+		{
+			name:   "disabled attestation is not validated",
+			config: AttestationConfig{Enabled: false, MaxObjects: -1},
+		},
+		{
+			name:       "valid attestation",
+			monitoring: true,
+			config:     AttestationConfig{Enabled: true, SigningKeyFile: "/etc/s3ep/attestation.pem", MaxObjects: 1000},
+		},
+		{
+			name:     "requires monitoring",
+			config:   AttestationConfig{Enabled: true, SigningKeyFile: "/etc/s3ep/attestation.pem"},
+			errorMsg: "requires monitoring.enabled",
+		},
+		{
+			name:       "signing key required",
+			monitoring: true,
+			config:     AttestationConfig{Enabled: true},
+			errorMsg:   "signing_key_file is required",
+		},
+		{
+			name:       "negative max objects",
+			monitoring: true,
+			config:     AttestationConfig{Enabled: true, SigningKeyFile: "/etc/s3ep/attestation.pem", MaxObjects: -1},
+			errorMsg:   "must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAttestation(&Config{Monitoring: MonitoringConfig{Enabled: tt.monitoring, Attestation: tt.config}})
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
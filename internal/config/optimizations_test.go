@@ -1,6 +1,7 @@
 package config
 
 import (
+	"runtime"
 	"testing"
 )
 
@@ -93,6 +94,55 @@ func TestOptimizationsConfig(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "valid stale session policy",
+			config: &Config{
+				Optimizations: OptimizationsConfig{
+					MultipartStaleSessionPolicy: StaleSessionPolicyReuse,
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "unknown stale session policy",
+			config: &Config{
+				Optimizations: OptimizationsConfig{
+					MultipartStaleSessionPolicy: "replace",
+				},
+			},
+			expectError: true,
+			errorMsg:    "multipart_stale_session_policy",
+		},
+		{
+			name: "too many multipart encryption workers",
+			config: &Config{
+				Optimizations: OptimizationsConfig{
+					MultipartEncryptionWorkers: 257,
+				},
+			},
+			expectError: true,
+			errorMsg:    "multipart_encryption_workers",
+		},
+		{
+			name: "streaming read-ahead too deep",
+			config: &Config{
+				Optimizations: OptimizationsConfig{
+					StreamingReadAhead: 65,
+				},
+			},
+			expectError: true,
+			errorMsg:    "streaming_read_ahead",
+		},
+		{
+			name: "negative DEK cache ttl",
+			config: &Config{
+				Optimizations: OptimizationsConfig{
+					DEKCache: DEKCacheConfig{TTL: -1},
+				},
+			},
+			expectError: true,
+			errorMsg:    "dek_cache.ttl",
+		},
 	}
 
 	for _, tt := range tests {
@@ -331,3 +381,109 @@ func getTestAdaptiveBufferSize(cfg *Config, expectedSize int64) int {
 
 	return adaptiveSize
 }
+
+func TestGetStreamingReadAheadSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		bufferSize   int
+		segmentSize  int64
+		expectedSize int
+	}{
+		{"defaults", 0, 0, 64 * 1024},
+		{"buffer divides segment", 1024 * 1024, 12 * 1024 * 1024, 1024 * 1024},
+		{"rounded to AES block", 65540, 12 * 1024 * 1024, 65536},
+		{"aligned to odd segment size", 64 * 1024, 5*1024*1024 + 48, 24848},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Optimizations: OptimizationsConfig{
+					StreamingBufferSize:  tt.bufferSize,
+					StreamingSegmentSize: tt.segmentSize,
+				},
+			}
+			actualSize := cfg.GetStreamingReadAheadSize()
+			if actualSize != tt.expectedSize {
+				t.Errorf("expected read-ahead size %d, got %d", tt.expectedSize, actualSize)
+			}
+			if cfg.GetStreamingSegmentSize()%int64(actualSize) != 0 {
+				t.Errorf("read-ahead size %d does not divide segment size %d", actualSize, cfg.GetStreamingSegmentSize())
+			}
+		})
+	}
+}
+
+func TestGetRecommendedPartSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		segmentSize  int64
+		expectedSize int64
+	}{
+		{"default segment size", 0, 12 * 1024 * 1024},
+		{"configured segment size", 64 * 1024 * 1024, 64 * 1024 * 1024},
+		{"raised to backend minimum", 1024 * 1024, 5 * 1024 * 1024},
+		{"capped at backend maximum", 6 * 1024 * 1024 * 1024, 5 * 1024 * 1024 * 1024},
+		{"rounded up to AES block", 5*1024*1024 + 1, 5*1024*1024 + 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Optimizations: OptimizationsConfig{StreamingSegmentSize: tt.segmentSize}}
+			if actualSize := cfg.GetRecommendedPartSize(); actualSize != tt.expectedSize {
+				t.Errorf("expected recommended part size %d, got %d", tt.expectedSize, actualSize)
+			}
+		})
+	}
+}
+
+func TestGetCryptoParallelism(t *testing.T) {
+	cfg := &Config{}
+	perStream, global := cfg.GetCryptoParallelism()
+	if perStream != runtime.GOMAXPROCS(0) || global != runtime.GOMAXPROCS(0) {
+		t.Errorf("expected GOMAXPROCS defaults, got %d/%d", perStream, global)
+	}
+
+	cfg.Optimizations.CryptoWorkersPerStream = 4
+	cfg.Optimizations.CryptoWorkersMax = 32
+	perStream, global = cfg.GetCryptoParallelism()
+	if perStream != 4 || global != 32 {
+		t.Errorf("expected 4/32, got %d/%d", perStream, global)
+	}
+
+	cfg.Optimizations.CryptoWorkersMax = -1
+	if err := validateOptimizations(cfg); err == nil {
+		t.Error("expected error for negative crypto_workers_max")
+	}
+}
+
+func TestValidateOptimizations_AutoMultipart(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int64
+		retries     int
+		expectError bool
+	}{
+		{name: "disabled", threshold: 0, retries: 0},
+		{name: "5GB threshold", threshold: 5 * 1024 * 1024 * 1024, retries: 3},
+		{name: "threshold below minimum part size", threshold: 1024 * 1024, expectError: true},
+		{name: "negative retries", threshold: 0, retries: -1, expectError: true},
+		{name: "too many retries", threshold: 0, retries: 11, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Optimizations: OptimizationsConfig{
+				AutoMultipartThreshold:   tt.threshold,
+				AutoMultipartPartRetries: tt.retries,
+			}}
+			err := validateOptimizations(cfg)
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
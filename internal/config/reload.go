@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// reloadableSettings are the top-level settings a reload applies while the
+// proxy runs
+var reloadableSettings = map[string]bool{
+	"log_level":       true,
+	"rate_limiting":   true,
+	"bucket_policies": true,
+}
+
+// ReloadPlan lists the settings that differ between the running and a
+// reloaded configuration
+type ReloadPlan struct {
+	Applied        []string             // Changed settings a reload applies
+	Rejected       []string             // Changed settings that need a restart
+	AddedProviders []EncryptionProvider // Providers that are new in the reloaded configuration
+}
+
+// Reload reads the configuration file of InitConfig again and loads it like
+// Load. Values set on the global viper instance, such as those of developer
+// mode, are kept.
+func Reload() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return Load()
+}
+
+// PlanReload compares the running configuration with a reloaded one. Settings
+// are named by their keys, as in "rate_limiting" or "encryption.sse_c_mode".
+// Providers may be added, but a provider whose settings changed or that was
+// removed is rejected as "encryption.providers.<alias>".
+func PlanReload(current, next *Config) ReloadPlan {
+	var plan ReloadPlan
+	for _, name := range changedSettings(reflect.ValueOf(*current), reflect.ValueOf(*next)) {
+		switch {
+		case reloadableSettings[name]:
+			plan.Applied = append(plan.Applied, name)
+		case name == "encryption":
+			planEncryption(&plan, current.Encryption, next.Encryption)
+		default:
+			plan.Rejected = append(plan.Rejected, name)
+		}
+	}
+	return plan
+}
+
+// planEncryption adds the changes of the encryption section to plan
+func planEncryption(plan *ReloadPlan, current, next EncryptionConfig) {
+	for _, name := range changedSettings(reflect.ValueOf(current), reflect.ValueOf(next)) {
+		if name != "providers" {
+			plan.Rejected = append(plan.Rejected, "encryption."+name)
+		}
+	}
+
+	nextProviders := make(map[string]EncryptionProvider, len(next.Providers))
+	for _, provider := range next.Providers {
+		nextProviders[provider.Alias] = provider
+	}
+	currentAliases := make(map[string]bool, len(current.Providers))
+	for _, provider := range current.Providers {
+		currentAliases[provider.Alias] = true
+		if reloaded, ok := nextProviders[provider.Alias]; !ok || !reflect.DeepEqual(provider, reloaded) {
+			plan.Rejected = append(plan.Rejected, "encryption.providers."+provider.Alias)
+		}
+	}
+	for _, provider := range next.Providers {
+		if !currentAliases[provider.Alias] {
+			plan.Applied = append(plan.Applied, "encryption.providers."+provider.Alias)
+			plan.AddedProviders = append(plan.AddedProviders, provider)
+		}
+	}
+}
+
+// changedSettings returns the keys of the fields of two structs of the same
+// type whose values differ. Fields without a key are not compared.
+func changedSettings(current, next reflect.Value) []string {
+	var changed []string
+	for i := range current.NumField() {
+		name, _, _ := strings.Cut(current.Type().Field(i).Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), next.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// FileUsed returns the path of the configuration file InitConfig read, or ""
+func FileUsed() string {
+	return viper.ConfigFileUsed()
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadBaseConfig = `
+target_endpoint: "http://localhost:9000"
+log_level: info
+encryption:
+  encryption_method_alias: default
+  providers:
+    - alias: default
+      type: aes
+      config:
+        aes_key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+`
+
+func TestPlanReload(t *testing.T) {
+	current, err := Parse([]byte(reloadBaseConfig))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		config   string
+		applied  []string
+		rejected []string
+		added    []string
+	}{
+		{name: "unchanged", config: reloadBaseConfig},
+		{
+			name: "reloadable settings",
+			config: reloadBaseConfig + `
+rate_limiting:
+  enabled: true
+  requests_per_second: 10
+bucket_policies:
+  default:
+    read_only: true
+`,
+			applied: []string{"rate_limiting", "bucket_policies"},
+		},
+		{
+			name: "log level",
+			config: `
+target_endpoint: "http://localhost:9000"
+log_level: debug
+encryption:
+  encryption_method_alias: default
+  providers:
+    - alias: default
+      type: aes
+      config:
+        aes_key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+`,
+			applied: []string{"log_level"},
+		},
+		{
+			name: "added provider",
+			config: reloadBaseConfig + `
+    - alias: plain
+      type: none
+`,
+			applied: []string{"encryption.providers.plain"},
+			added:   []string{"plain"},
+		},
+		{
+			name: "non-reloadable settings",
+			config: reloadBaseConfig + `
+  sse_c_mode: passthrough
+bind_address: "0.0.0.0:9999"
+`,
+			rejected: []string{"bind_address", "encryption.sse_c_mode"},
+		},
+		{
+			name: "changed and removed providers",
+			config: `
+target_endpoint: "http://localhost:9000"
+log_level: info
+encryption:
+  encryption_method_alias: default
+  providers:
+    - alias: default
+      type: aes
+      config:
+        aes_key: "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+`,
+			rejected: []string{"encryption.providers.default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := Parse([]byte(tt.config))
+			require.NoError(t, err)
+
+			plan := PlanReload(current, next)
+			assert.ElementsMatch(t, tt.applied, plan.Applied)
+			assert.ElementsMatch(t, tt.rejected, plan.Rejected)
+			var added []string
+			for _, provider := range plan.AddedProviders {
+				added = append(added, provider.Alias)
+			}
+			assert.Equal(t, tt.added, added)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package configreload
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the reload endpoints are mounted on the monitoring server
+const BasePath = "/admin/config-reload"
+
+// Handler exposes the reloader over HTTP:
+//
+//	GET  /admin/config-reload  status of the last reload
+//	POST /admin/config-reload  reload now and return its status
+type Handler struct {
+	reloader *Reloader
+	logger   *logrus.Entry
+	mux      *http.ServeMux
+}
+
+// NewHandler creates a new config reload HTTP handler
+func NewHandler(reloader *Reloader, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		reloader: reloader,
+		logger:   logger,
+		mux:      http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+BasePath, h.handleStatus)
+	h.mux.HandleFunc("POST "+BasePath, h.handleReload)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.reloader.Status())
+}
+
+// handleReload answers 409 Conflict if the reload was rejected and 500 if it
+// failed; the running configuration is kept in both cases
+func (h *Handler) handleReload(w http.ResponseWriter, _ *http.Request) {
+	status := h.reloader.Reload(TriggerAPI)
+	switch status.State {
+	case StateRejected:
+		h.writeJSON(w, http.StatusConflict, status)
+	case StateFailed:
+		h.writeJSON(w, http.StatusInternalServerError, status)
+	default:
+		h.writeJSON(w, http.StatusOK, status)
+	}
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write config reload response")
+	}
+}
@@ -0,0 +1,203 @@
+// Package configreload reloads the configuration file while the proxy runs.
+// A reload is triggered by SIGHUP, the admin API or a change of the file, and
+// applies the settings that can change without a restart. If any other
+// setting changed, the whole reload is rejected and the running configuration
+// is kept.
+package configreload
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// State is the outcome of the last reload
+type State string
+
+const (
+	StateInitial   State = "initial"   // no reload yet
+	StateApplied   State = "applied"   // reloadable settings changed and were applied
+	StateUnchanged State = "unchanged" // nothing changed
+	StateRejected  State = "rejected"  // settings that need a restart changed
+	StateFailed    State = "failed"    // the configuration could not be loaded or applied
+)
+
+// Reload triggers
+const (
+	TriggerSignal = "sighup"
+	TriggerFile   = "file"
+	TriggerAPI    = "api"
+)
+
+// Status describes the last reload
+type Status struct {
+	State       State      `json:"state"`
+	Generation  int        `json:"generation"` // Reloads applied since startup
+	Trigger     string     `json:"trigger,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastApplied *time.Time `json:"last_applied,omitempty"`
+	Applied     []string   `json:"applied,omitempty"`
+	Rejected    []string   `json:"rejected,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Loader loads the configuration again, e.g. config.Reload
+type Loader func() (*config.Config, error)
+
+// Applier applies the reloadable settings of next that plan lists
+type Applier func(next *config.Config, plan config.ReloadPlan) error
+
+// Reloader reloads the configuration and keeps the status of the last reload
+type Reloader struct {
+	path   string
+	load   Loader
+	apply  Applier
+	logger *logrus.Entry
+	now    func() time.Time
+
+	reloadMu sync.Mutex // serializes reloads
+	current  *config.Config
+	version  [sha256.Size]byte // of the file last loaded
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewReloader creates a reloader of the configuration file at path, which
+// current was loaded from. path may be empty if there is no file; then
+// reloads are only triggered explicitly.
+func NewReloader(path string, current *config.Config, load Loader, apply Applier, logger *logrus.Entry) *Reloader {
+	r := &Reloader{
+		path:    path,
+		load:    load,
+		apply:   apply,
+		logger:  logger,
+		now:     time.Now,
+		current: current,
+		status:  Status{State: StateInitial},
+	}
+	if version, err := r.fileVersion(); err == nil {
+		r.version = version
+	}
+	return r
+}
+
+// Status returns the status of the last reload
+func (r *Reloader) Status() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// Reload loads the configuration and applies it if only reloadable settings
+// changed. The returned status is also kept for Status.
+func (r *Reloader) Reload(trigger string) Status {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	if version, err := r.fileVersion(); err == nil {
+		r.version = version
+	}
+
+	now := r.now()
+	r.mu.RLock()
+	status := Status{Generation: r.status.Generation, Trigger: trigger, LastAttempt: &now, LastApplied: r.status.LastApplied}
+	r.mu.RUnlock()
+
+	r.attempt(&status, now)
+
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+	r.log(status)
+	return status
+}
+
+// attempt loads and applies the configuration, recording the outcome in
+// status
+func (r *Reloader) attempt(status *Status, now time.Time) {
+	next, err := r.load()
+	if err != nil {
+		status.State, status.Error = StateFailed, err.Error()
+		return
+	}
+	plan := config.PlanReload(r.current, next)
+	status.Applied, status.Rejected = plan.Applied, plan.Rejected
+	switch {
+	case len(plan.Rejected) > 0:
+		status.State = StateRejected
+		status.Error = fmt.Sprintf("settings that need a restart changed: %s", strings.Join(plan.Rejected, ", "))
+	case len(plan.Applied) == 0:
+		status.State = StateUnchanged
+	default:
+		if err := r.apply(next, plan); err != nil {
+			status.State, status.Error = StateFailed, err.Error()
+			return
+		}
+		r.current = next
+		status.State = StateApplied
+		status.Generation++
+		status.LastApplied = &now
+	}
+}
+
+// Run reloads the configuration whenever the file changes, checking it every
+// interval, until ctx is done
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version, err := r.fileVersion()
+			if err != nil {
+				r.logger.WithError(err).Warn("Failed to check the configuration file for changes")
+				continue
+			}
+			r.reloadMu.Lock()
+			changed := version != r.version
+			r.reloadMu.Unlock()
+			if changed {
+				r.Reload(TriggerFile)
+			}
+		}
+	}
+}
+
+// fileVersion returns the hash of the configuration file
+func (r *Reloader) fileVersion() ([sha256.Size]byte, error) {
+	if r.path == "" {
+		return [sha256.Size]byte{}, nil
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	return sha256.Sum256(data), nil
+}
+
+func (r *Reloader) log(status Status) {
+	logger := r.logger.WithFields(logrus.Fields{
+		"trigger":    status.Trigger,
+		"generation": status.Generation,
+	})
+	switch status.State {
+	case StateApplied:
+		logger.WithField("applied", status.Applied).Info("Reloaded configuration")
+	case StateUnchanged:
+		logger.Info("Reloaded configuration, nothing changed")
+	case StateRejected:
+		logger.WithField("rejected", status.Rejected).Error("Rejected configuration reload, keeping the current configuration: restart the proxy to apply it")
+	default:
+		logger.WithField("error", status.Error).Error("Failed to reload configuration, keeping the current one")
+	}
+}
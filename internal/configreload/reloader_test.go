@@ -0,0 +1,132 @@
+package configreload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// testReloader reloads whatever next holds and records the applied plans
+type testReloader struct {
+	*Reloader
+	next    *config.Config
+	loadErr error
+	applied []config.ReloadPlan
+}
+
+func newTestReloader(t *testing.T, path string) *testReloader {
+	t.Helper()
+	r := &testReloader{}
+	current := &config.Config{LogLevel: "info", BindAddress: ":8080"}
+	load := func() (*config.Config, error) {
+		if r.loadErr != nil {
+			return nil, r.loadErr
+		}
+		return r.next, nil
+	}
+	apply := func(_ *config.Config, plan config.ReloadPlan) error {
+		r.applied = append(r.applied, plan)
+		return nil
+	}
+	r.Reloader = NewReloader(path, current, load, apply, logrus.NewEntry(logrus.New()))
+	r.next = current
+	return r
+}
+
+func TestReloader_Reload(t *testing.T) {
+	r := newTestReloader(t, "")
+	assert.Equal(t, StateInitial, r.Status().State)
+
+	assert.Equal(t, StateUnchanged, r.Reload(TriggerSignal).State)
+	assert.Empty(t, r.applied)
+
+	r.next = &config.Config{LogLevel: "debug", BindAddress: ":8080"}
+	status := r.Reload(TriggerSignal)
+	assert.Equal(t, StateApplied, status.State)
+	assert.Equal(t, []string{"log_level"}, status.Applied)
+	assert.Equal(t, 1, status.Generation)
+	require.NotNil(t, status.LastApplied)
+	require.Len(t, r.applied, 1)
+
+	r.next = &config.Config{LogLevel: "warn", BindAddress: ":9999"}
+	status = r.Reload(TriggerAPI)
+	assert.Equal(t, StateRejected, status.State)
+	assert.Equal(t, []string{"bind_address"}, status.Rejected)
+	assert.Contains(t, status.Error, "bind_address")
+	assert.Equal(t, 1, status.Generation)
+	assert.NotNil(t, status.LastApplied, "the last applied reload is kept")
+	assert.Len(t, r.applied, 1, "a rejected reload applies nothing")
+
+	r.loadErr = errors.New("config validation failed")
+	status = r.Reload(TriggerAPI)
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, "config validation failed", status.Error)
+	assert.Equal(t, status, r.Status())
+
+	// Changes are planned against the last applied configuration
+	r.loadErr = nil
+	r.next = &config.Config{LogLevel: "debug", BindAddress: ":8080"}
+	assert.Equal(t, StateUnchanged, r.Reload(TriggerSignal).State)
+}
+
+func TestReloader_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("log_level: info\n"), 0o600))
+	r := newTestReloader(t, path)
+	r.next = &config.Config{LogLevel: "debug", BindAddress: ":8080"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, StateInitial, r.Status().State, "an unchanged file is not reloaded")
+
+	require.NoError(t, os.WriteFile(path, []byte("log_level: debug\n"), 0o600))
+	assert.Eventually(t, func() bool { return r.Status().State == StateApplied }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, TriggerFile, r.Status().Trigger)
+}
+
+func TestHandler(t *testing.T) {
+	r := newTestReloader(t, "")
+	handler := NewHandler(r.Reloader, logrus.NewEntry(logrus.New()))
+	serve := func(method string) (int, Status) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, BasePath, nil))
+		var status Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return w.Code, status
+	}
+
+	code, status := serve(http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StateInitial, status.State)
+
+	r.next = &config.Config{LogLevel: "debug", BindAddress: ":8080"}
+	code, status = serve(http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StateApplied, status.State)
+	assert.Equal(t, TriggerAPI, status.Trigger)
+
+	r.next = &config.Config{LogLevel: "debug", BindAddress: ":9999"}
+	code, status = serve(http.MethodPost)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, StateRejected, status.State)
+
+	code, status = serve(http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StateRejected, status.State)
+	assert.Equal(t, 1, status.Generation)
+}
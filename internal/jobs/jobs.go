@@ -0,0 +1,241 @@
+// Package jobs tracks the background jobs of the admin API, such as list
+// exports and KEK rotations: it assigns job IDs, bounds the number of jobs
+// running at the same time, keeps the last finished jobs for their callers
+// and cancels the running ones on shutdown.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// maxRetainedJobs bounds the jobs kept per manager; the oldest finished ones
+// are dropped first
+const maxRetainedJobs = 100
+
+// Status is the part of a job the manager maintains. Job types embed it.
+type Status struct {
+	ID         string     `json:"id"`
+	State      State      `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (s *Status) status() *Status {
+	return s
+}
+
+// Job is a pointer to a job type that embeds Status
+type Job[J any] interface {
+	*J
+	status() *Status
+}
+
+// Config holds the limits and errors of a job manager
+type Config[J any] struct {
+	MaxConcurrentJobs int
+	ErrTooManyJobs    error     // returned by Start when MaxConcurrentJobs jobs are running
+	ErrJobNotFound    error     // returned for unknown job IDs
+	Snapshot          func(J) J // copies a job for callers (default: a plain copy)
+}
+
+type entry[J any] struct {
+	job    J
+	cancel context.CancelFunc
+}
+
+// Manager starts and tracks jobs of type J
+type Manager[J any, P Job[J]] struct {
+	config Config[J]
+
+	mu      sync.Mutex
+	jobs    map[string]*entry[J]
+	running int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a new job manager
+func NewManager[J any, P Job[J]](cfg Config[J]) *Manager[J, P] {
+	if cfg.Snapshot == nil {
+		cfg.Snapshot = func(job J) J { return job }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager[J, P]{
+		config: cfg,
+		jobs:   make(map[string]*entry[J]),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start creates a job with newJob from a running status with a new ID and
+// runs it in the background. The job is finished with the error run returns:
+// completed on nil, cancelled once its context was cancelled, failed
+// otherwise. run reads and changes the job under Update.
+func (m *Manager[J, P]) Start(newJob func(status Status) J, run func(ctx context.Context, job P) error) (J, error) {
+	id, err := newJobID()
+	if err != nil {
+		var zero J
+		return zero, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running >= m.config.MaxConcurrentJobs {
+		var zero J
+		return zero, m.config.ErrTooManyJobs
+	}
+
+	jobCtx, cancel := context.WithCancel(m.ctx)
+	e := &entry[J]{
+		job: newJob(Status{
+			ID:        id,
+			State:     StateRunning,
+			StartedAt: time.Now().UTC(),
+		}),
+		cancel: cancel,
+	}
+	m.jobs[id] = e
+	m.running++
+	m.pruneLocked()
+
+	m.wg.Add(1)
+	go m.run(jobCtx, e, run)
+
+	return m.config.Snapshot(e.job), nil
+}
+
+// Update runs fn with the jobs locked, for run to read and change its job
+func (m *Manager[J, P]) Update(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager[J, P]) Get(id string) (J, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.jobs[id]
+	if !ok {
+		var zero J
+		return zero, m.config.ErrJobNotFound
+	}
+	return m.config.Snapshot(e.job), nil
+}
+
+// List returns snapshots of all retained jobs, newest first
+func (m *Manager[J, P]) List() []J {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]*entry[J], 0, len(m.jobs))
+	for _, e := range m.jobs {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return P(&entries[i].job).status().StartedAt.After(P(&entries[j].job).status().StartedAt)
+	})
+	jobs := make([]J, 0, len(entries))
+	for _, e := range entries {
+		jobs = append(jobs, m.config.Snapshot(e.job))
+	}
+	return jobs
+}
+
+// Cancel stops a running job
+func (m *Manager[J, P]) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.jobs[id]
+	if !ok {
+		return m.config.ErrJobNotFound
+	}
+	e.cancel()
+	return nil
+}
+
+// Shutdown cancels all running jobs and waits for them to finish
+func (m *Manager[J, P]) Shutdown() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run runs a single job and records how it finished
+func (m *Manager[J, P]) run(ctx context.Context, e *entry[J], run func(ctx context.Context, job P) error) {
+	defer m.wg.Done()
+
+	err := run(ctx, P(&e.job))
+
+	finishedAt := time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := P(&e.job).status()
+	status.FinishedAt = &finishedAt
+	switch {
+	case err == nil:
+		status.State = StateCompleted
+	case errors.Is(err, context.Canceled):
+		status.State = StateCancelled
+		status.Error = "cancelled"
+	default:
+		status.State = StateFailed
+		status.Error = err.Error()
+	}
+	e.cancel()
+	m.running--
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxRetainedJobs. Caller holds m.mu.
+func (m *Manager[J, P]) pruneLocked() {
+	if len(m.jobs) <= maxRetainedJobs {
+		return
+	}
+	finished := make([]*Status, 0, len(m.jobs))
+	for _, e := range m.jobs {
+		if status := P(&e.job).status(); status.State != StateRunning {
+			finished = append(finished, status)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].StartedAt.Before(finished[j].StartedAt)
+	})
+	for _, status := range finished {
+		if len(m.jobs) <= maxRetainedJobs {
+			return
+		}
+		delete(m.jobs, status.ID)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errTooMany  = errors.New("too many test jobs")
+	errNotFound = errors.New("test job not found")
+)
+
+type testJob struct {
+	Status
+	Name  string
+	Steps int
+}
+
+func newTestManager(maxConcurrent int) *Manager[testJob, *testJob] {
+	return NewManager[testJob](Config[testJob]{
+		MaxConcurrentJobs: maxConcurrent,
+		ErrTooManyJobs:    errTooMany,
+		ErrJobNotFound:    errNotFound,
+	})
+}
+
+func waitForJob(t *testing.T, m *Manager[testJob, *testJob], id string) testJob {
+	t.Helper()
+	var job testJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(id)
+		require.NoError(t, err)
+		return job.State != StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager_FinishesJobs(t *testing.T) {
+	m := newTestManager(3)
+	defer m.Shutdown()
+
+	newJob := func(name string) func(Status) testJob {
+		return func(status Status) testJob { return testJob{Status: status, Name: name} }
+	}
+	completed, err := m.Start(newJob("completed"), func(_ context.Context, job *testJob) error {
+		m.Update(func() { job.Steps = 3 })
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, completed.State)
+	assert.Len(t, completed.ID, 16)
+	failed, err := m.Start(newJob("failed"), func(context.Context, *testJob) error {
+		return errors.New("backend unavailable")
+	})
+	require.NoError(t, err)
+	cancelled, err := m.Start(newJob("cancelled"), func(ctx context.Context, _ *testJob) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.Cancel(cancelled.ID))
+
+	job := waitForJob(t, m, completed.ID)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, 3, job.Steps)
+	require.NotNil(t, job.FinishedAt)
+
+	job = waitForJob(t, m, failed.ID)
+	assert.Equal(t, StateFailed, job.State)
+	assert.Equal(t, "backend unavailable", job.Error)
+
+	job = waitForJob(t, m, cancelled.ID)
+	assert.Equal(t, StateCancelled, job.State)
+	assert.Equal(t, "cancelled", job.Error)
+
+	_, err = m.Get("unknown")
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, m.Cancel("unknown"), errNotFound)
+}
+
+func TestManager_LimitsConcurrentJobs(t *testing.T) {
+	m := newTestManager(1)
+	defer m.Shutdown()
+
+	release := make(chan struct{})
+	first, err := m.Start(func(status Status) testJob { return testJob{Status: status} }, func(context.Context, *testJob) error {
+		<-release
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = m.Start(func(status Status) testJob { return testJob{Status: status} }, func(context.Context, *testJob) error { return nil })
+	assert.ErrorIs(t, err, errTooMany)
+
+	close(release)
+	waitForJob(t, m, first.ID)
+	_, err = m.Start(func(status Status) testJob { return testJob{Status: status} }, func(context.Context, *testJob) error { return nil })
+	assert.NoError(t, err)
+}
+
+func TestManager_PrunesOldestFinishedJobs(t *testing.T) {
+	m := newTestManager(1)
+	defer m.Shutdown()
+
+	var ids []string
+	for range maxRetainedJobs + 5 {
+		job, err := m.Start(func(status Status) testJob { return testJob{Status: status} }, func(context.Context, *testJob) error { return nil })
+		require.NoError(t, err)
+		waitForJob(t, m, job.ID)
+		ids = append(ids, job.ID)
+	}
+
+	jobs := m.List()
+	require.Len(t, jobs, maxRetainedJobs)
+	assert.Equal(t, ids[len(ids)-1], jobs[0].ID, "newest first")
+	_, err := m.Get(ids[0])
+	assert.ErrorIs(t, err, errNotFound)
+	_, err = m.Get(ids[len(ids)-1])
+	assert.NoError(t, err)
+}
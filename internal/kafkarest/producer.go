@@ -0,0 +1,78 @@
+// Package kafkarest produces JSON records to a Kafka topic through a Kafka
+// REST Proxy (v2 API). It is shared by the audit log and the event
+// notification sinks, which differ only in the records they produce.
+package kafkarest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ContentType is the content type of JSON records of the Kafka REST Proxy v2
+// API
+const ContentType = "application/vnd.kafka.json.v2+json"
+
+// Record is one Kafka record with a JSON value of type T. Records with the
+// same key go to the same partition and stay in order.
+type Record[T any] struct {
+	Key   string `json:"key,omitempty"`
+	Value T      `json:"value"`
+}
+
+// Producer produces records with values of type T to one topic
+type Producer[T any] struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewProducer creates a producer to topic through the REST Proxy at restURL
+// with the extra headers, e.g. an Authorization header
+func NewProducer[T any](restURL, topic string, headers map[string]string) *Producer[T] {
+	return &Producer[T]{
+		url:     strings.TrimSuffix(restURL, "/") + "/topics/" + url.PathEscape(topic),
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+// Produce posts records to the topic in one request
+func (p *Producer[T]) Produce(ctx context.Context, records []Record[T]) error {
+	body, err := json.Marshal(struct {
+		Records []Record[T] `json:"records"`
+	}{Records: records})
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce records: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to produce records: %s answered %s", p.url, resp.Status)
+	}
+	return nil
+}
+
+// Close closes the idle connections to the REST Proxy
+func (p *Producer[T]) Close() {
+	p.client.CloseIdleConnections()
+}
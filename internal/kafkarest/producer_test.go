@@ -0,0 +1,48 @@
+package kafkarest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testValue struct {
+	Name string `json:"name"`
+}
+
+func TestProducer_Produce(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   *string   `json:"key"`
+			Value testValue `json:"value"`
+		} `json:"records"`
+	}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kafka/topics/audit.events", r.URL.Path)
+		assert.Equal(t, ContentType, r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	producer := NewProducer[testValue](server.URL+"/kafka/", "audit.events", map[string]string{"Authorization": "Bearer token"})
+	defer producer.Close()
+	require.NoError(t, producer.Produce(context.Background(), []Record[testValue]{
+		{Key: "bucket", Value: testValue{Name: "a"}},
+		{Value: testValue{Name: "b"}},
+	}))
+	require.Len(t, body.Records, 2)
+	assert.Equal(t, "bucket", *body.Records[0].Key)
+	assert.Equal(t, "a", body.Records[0].Value.Name)
+	assert.Nil(t, body.Records[1].Key, "an empty key is left out")
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, producer.Produce(context.Background(), []Record[testValue]{{Value: testValue{Name: "c"}}}))
+}
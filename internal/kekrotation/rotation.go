@@ -0,0 +1,340 @@
+// Package kekrotation implements the admin KEK rotation job. A KEK is
+// rotated by configuring a new provider, making it the active one and keeping
+// the old provider configured for decryption. The job then walks a bucket and
+// re-wraps the DEK of every object that still uses another provider, so the
+// old provider can be removed once all jobs completed. Only object metadata
+// is rewritten; the ciphertext is copied by the backend without passing
+// through the proxy.
+package kekrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/jobs"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// State is the lifecycle state of a rotation job
+type State = jobs.State
+
+const (
+	StateRunning   = jobs.StateRunning
+	StateCompleted = jobs.StateCompleted
+	StateFailed    = jobs.StateFailed
+	StateCancelled = jobs.StateCancelled
+)
+
+// Results of one object, as reported in s3ep_kek_rewrap_objects_total
+const (
+	// ResultRewrapped means the DEK was re-wrapped with the active provider
+	ResultRewrapped = "rewrapped"
+	// ResultCurrent means the DEK already uses the active provider or the
+	// object is not encrypted
+	ResultCurrent = "current"
+	// ResultFailed means the DEK could not be re-wrapped or stored
+	ResultFailed = "failed"
+)
+
+const (
+	defaultMaxConcurrentJobs = 1
+	defaultConcurrency       = 4
+
+	// maxReportedFailures bounds the failed keys kept per job
+	maxReportedFailures = 100
+
+	// maxCopyObjectSize is the largest object CopyObject can copy
+	maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+)
+
+var (
+	// ErrTooManyJobs is returned when the concurrent job limit is reached
+	ErrTooManyJobs = errors.New("too many KEK rotation jobs running")
+	// ErrJobNotFound is returned for unknown job IDs
+	ErrJobNotFound = errors.New("KEK rotation job not found")
+)
+
+// Backend is the subset of the S3 client used by the rotation job
+type Backend interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+}
+
+// Rewrapper re-wraps the DEK in object metadata with the active provider
+type Rewrapper interface {
+	RewrapDEK(ctx context.Context, metadata map[string]string, objectKey string) (map[string]string, bool, error)
+	GetActiveProviderAlias() string
+}
+
+// Config holds rotation job configuration
+type Config struct {
+	MaxConcurrentJobs int // Maximum number of jobs running at the same time (default: 1)
+	Concurrency       int // Objects re-wrapped in parallel per job (default: 4)
+}
+
+// Request describes a rotation job to start
+type Request struct {
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix,omitempty"`
+	StartAfter string `json:"start_after,omitempty"` // resume after the last_key of an earlier job
+}
+
+// Failure is an object whose DEK could not be re-wrapped
+type Failure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Job is a point-in-time snapshot of a rotation job
+type Job struct {
+	jobs.Status
+	Bucket           string    `json:"bucket"`
+	Prefix           string    `json:"prefix,omitempty"`
+	ActiveProvider   string    `json:"active_provider"`
+	ObjectsScanned   int64     `json:"objects_scanned"`
+	ObjectsRewrapped int64     `json:"objects_rewrapped"`
+	ObjectsCurrent   int64     `json:"objects_current"`
+	ObjectsFailed    int64     `json:"objects_failed"`
+	LastKey          string    `json:"last_key,omitempty"` // every key up to this one was processed
+	Failures         []Failure `json:"failures,omitempty"` // the first failed keys
+}
+
+// Manager starts and tracks rotation jobs
+type Manager struct {
+	backend   Backend
+	rewrapper Rewrapper
+	config    Config
+	logger    *logrus.Entry
+	jobs      *jobs.Manager[Job, *Job]
+}
+
+// NewManager creates a new rotation job manager
+func NewManager(backend Backend, rewrapper Rewrapper, cfg Config, logger *logrus.Entry) *Manager {
+	if cfg.MaxConcurrentJobs <= 0 {
+		cfg.MaxConcurrentJobs = defaultMaxConcurrentJobs
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+
+	return &Manager{
+		backend:   backend,
+		rewrapper: rewrapper,
+		config:    cfg,
+		logger:    logger.WithField("component", "kek-rotation"),
+		jobs: jobs.NewManager[Job](jobs.Config[Job]{
+			MaxConcurrentJobs: cfg.MaxConcurrentJobs,
+			ErrTooManyJobs:    ErrTooManyJobs,
+			ErrJobNotFound:    ErrJobNotFound,
+			Snapshot:          snapshot,
+		}),
+	}
+}
+
+// Start validates the request and launches a rotation job in the background
+func (m *Manager) Start(req Request) (Job, error) {
+	if req.Bucket == "" {
+		return Job{}, fmt.Errorf("bucket is required")
+	}
+
+	return m.jobs.Start(func(status jobs.Status) Job {
+		return Job{
+			Status:         status,
+			Bucket:         req.Bucket,
+			Prefix:         req.Prefix,
+			ActiveProvider: m.rewrapper.GetActiveProviderAlias(),
+			LastKey:        req.StartAfter,
+		}
+	}, func(ctx context.Context, job *Job) error {
+		return m.run(ctx, job, req.StartAfter)
+	})
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (Job, error) {
+	return m.jobs.Get(id)
+}
+
+// List returns snapshots of all retained jobs, newest first
+func (m *Manager) List() []Job {
+	return m.jobs.List()
+}
+
+// Cancel stops a running job. Objects already re-wrapped keep the new wrap;
+// the job can be resumed with its last_key as start_after.
+func (m *Manager) Cancel(id string) error {
+	return m.jobs.Cancel(id)
+}
+
+// Shutdown cancels all running jobs and waits for them to finish
+func (m *Manager) Shutdown() {
+	m.jobs.Shutdown()
+}
+
+// snapshot copies the job so callers do not share the failures slice
+func snapshot(job Job) Job {
+	job.Failures = append([]Failure(nil), job.Failures...)
+	return job
+}
+
+// run re-wraps the objects of a single job
+func (m *Manager) run(ctx context.Context, entry *Job, startAfter string) error {
+	var job Job
+	m.jobs.Update(func() { job = *entry })
+
+	log := m.logger.WithFields(logrus.Fields{
+		"job_id":          job.ID,
+		"bucket":          job.Bucket,
+		"prefix":          job.Prefix,
+		"active_provider": job.ActiveProvider,
+	})
+	log.Info("Starting KEK rotation")
+
+	err := m.rotate(ctx, entry, job.Bucket, job.Prefix, startAfter)
+
+	m.jobs.Update(func() { job = *entry })
+	fields := logrus.Fields{
+		"scanned":   job.ObjectsScanned,
+		"rewrapped": job.ObjectsRewrapped,
+		"current":   job.ObjectsCurrent,
+		"failed":    job.ObjectsFailed,
+		"duration":  time.Since(job.StartedAt),
+	}
+	if err != nil {
+		log.WithError(err).WithFields(fields).Warn("KEK rotation did not complete")
+		return err
+	}
+	log.WithFields(fields).Info("KEK rotation completed")
+	return nil
+}
+
+// rotate lists the bucket and re-wraps every page with bounded concurrency.
+// Progress is published after every page, so last_key only advances past
+// keys that were processed.
+func (m *Manager) rotate(ctx context.Context, entry *Job, bucket, prefix, startAfter string) error {
+	paginator := s3.NewListObjectsV2Paginator(m.backend, &s3.ListObjectsV2Input{
+		Bucket:     aws.String(bucket),
+		Prefix:     optionalString(prefix),
+		StartAfter: optionalString(startAfter),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		results := make([]string, len(page.Contents))
+		errs := make([]error, len(page.Contents))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, m.config.Concurrency)
+		for i, object := range page.Contents {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i], errs[i] = m.rewrapObject(ctx, bucket, object)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		m.jobs.Update(func() {
+			for i, object := range page.Contents {
+				monitoring.RecordKEKRewrap(results[i])
+				entry.ObjectsScanned++
+				switch results[i] {
+				case ResultRewrapped:
+					entry.ObjectsRewrapped++
+				case ResultCurrent:
+					entry.ObjectsCurrent++
+				default:
+					entry.ObjectsFailed++
+					if len(entry.Failures) < maxReportedFailures {
+						entry.Failures = append(entry.Failures, Failure{Key: aws.ToString(object.Key), Error: errs[i].Error()})
+					}
+				}
+				entry.LastKey = aws.ToString(object.Key)
+			}
+		})
+	}
+	return nil
+}
+
+// rewrapObject re-wraps the DEK of one object and stores it with a
+// metadata-only self-copy. The copy is conditional on the ETag that was read,
+// so an object overwritten in the meantime (with a current DEK) is left alone.
+// In a versioned bucket the copy is a new version: the previous versions keep
+// the old wrap, so the old provider stays needed to read them until they are
+// expired or deleted.
+func (m *Manager) rewrapObject(ctx context.Context, bucket string, object types.Object) (string, error) {
+	key := aws.ToString(object.Key)
+	head, err := m.backend.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    object.Key,
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			// Deleted after it was listed
+			return ResultCurrent, nil
+		}
+		return ResultFailed, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	metadata, changed, err := m.rewrapper.RewrapDEK(ctx, head.Metadata, key)
+	if err != nil {
+		return ResultFailed, err
+	}
+	if !changed {
+		return ResultCurrent, nil
+	}
+	if aws.ToInt64(head.ContentLength) > maxCopyObjectSize {
+		return ResultFailed, fmt.Errorf("objects larger than 5 GiB cannot be re-wrapped with CopyObject")
+	}
+
+	// REPLACE drops every attribute that is not sent again. Tags are copied
+	// by their own directive.
+	input := &s3.CopyObjectInput{
+		Bucket:                  aws.String(bucket),
+		Key:                     object.Key,
+		CopySource:              aws.String(bucket + "/" + url.PathEscape(key)),
+		CopySourceIfMatch:       head.ETag,
+		Metadata:                metadata,
+		MetadataDirective:       types.MetadataDirectiveReplace,
+		TaggingDirective:        types.TaggingDirectiveCopy,
+		ContentType:             head.ContentType,
+		CacheControl:            head.CacheControl,
+		ContentDisposition:      head.ContentDisposition,
+		ContentEncoding:         head.ContentEncoding,
+		ContentLanguage:         head.ContentLanguage,
+		WebsiteRedirectLocation: head.WebsiteRedirectLocation,
+	}
+	if head.StorageClass != "" {
+		input.StorageClass = head.StorageClass
+	}
+	if _, err := m.backend.CopyObject(ctx, input); err != nil {
+		return ResultFailed, fmt.Errorf("failed to store re-wrapped DEK: %w", err)
+	}
+	return ResultRewrapped, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
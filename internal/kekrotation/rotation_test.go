@@ -0,0 +1,216 @@
+package kekrotation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+)
+
+var (
+	oldProvider = config.EncryptionProvider{
+		Alias:  "kek-2025",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+	}
+	newProvider = config.EncryptionProvider{
+		Alias:  "kek-2026",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+	}
+)
+
+func newEncryptionManager(t *testing.T, active string, providers ...config.EncryptionProvider) *orchestration.Manager {
+	t.Helper()
+	manager, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: active,
+			Providers:             providers,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+// putEncrypted stores plaintext encrypted by manager like the PUT handler does
+func putEncrypted(t *testing.T, store *backend.MemoryBackend, manager *orchestration.Manager, key string, plaintext []byte) {
+	t.Helper()
+	result, err := manager.EncryptData(context.Background(), bufio.NewReader(bytes.NewReader(plaintext)), key)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	_, err = store.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:      aws.String("data"),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(ciphertext),
+		Metadata:    result.Metadata,
+		ContentType: aws.String("text/plain"),
+	})
+	require.NoError(t, err)
+}
+
+// copyRecordingBackend keeps the CopyObject requests of the rotation
+type copyRecordingBackend struct {
+	*backend.MemoryBackend
+
+	mu     sync.Mutex
+	copies []*s3.CopyObjectInput
+}
+
+func (b *copyRecordingBackend) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.mu.Lock()
+	b.copies = append(b.copies, params)
+	b.mu.Unlock()
+	return b.MemoryBackend.CopyObject(ctx, params, optFns...)
+}
+
+func waitForJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	var job Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(id)
+		require.NoError(t, err)
+		return job.State != StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager_Rotate(t *testing.T) {
+	ctx := context.Background()
+	store := backend.NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	_, err := store.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("data")})
+	require.NoError(t, err)
+
+	old := newEncryptionManager(t, "kek-2025", oldProvider)
+	rotating := newEncryptionManager(t, "kek-2026", newProvider, oldProvider)
+	plaintext := []byte(strings.Repeat("rotate me ", 100))
+
+	putEncrypted(t, store, old, "a/old-1.txt", plaintext)
+	putEncrypted(t, store, old, "a/old-2.txt", plaintext)
+	putEncrypted(t, store, rotating, "a/new.txt", plaintext)
+	_, err = store.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("data"), Key: aws.String("a/plain.txt"), Body: strings.NewReader("plain")})
+	require.NoError(t, err)
+	tags := []types.Tag{{Key: aws.String("team"), Value: aws.String("storage")}}
+	_, err = store.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{Bucket: aws.String("data"), Key: aws.String("a/old-1.txt"), Tagging: &types.Tagging{TagSet: tags}})
+	require.NoError(t, err)
+
+	recording := &copyRecordingBackend{MemoryBackend: store}
+	m := NewManager(recording, rotating, Config{Concurrency: 2}, logrus.NewEntry(logrus.New()))
+	defer m.Shutdown()
+
+	started, err := m.Start(Request{Bucket: "data", Prefix: "a/"})
+	require.NoError(t, err)
+	assert.Equal(t, "kek-2026", started.ActiveProvider)
+
+	job := waitForJob(t, m, started.ID)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(4), job.ObjectsScanned)
+	assert.Equal(t, int64(2), job.ObjectsRewrapped)
+	assert.Equal(t, int64(2), job.ObjectsCurrent)
+	assert.Zero(t, job.ObjectsFailed)
+	assert.Equal(t, "a/plain.txt", job.LastKey)
+
+	// The self-copies keep the tags of the objects
+	require.Len(t, recording.copies, 2)
+	for _, input := range recording.copies {
+		assert.Equal(t, types.TaggingDirectiveCopy, input.TaggingDirective)
+	}
+	tagging, err := store.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String("data"), Key: aws.String("a/old-1.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, tags, tagging.TagSet)
+
+	// Without the old KEK every object still decrypts
+	current := newEncryptionManager(t, "kek-2026", newProvider)
+	for _, key := range []string{"a/old-1.txt", "a/old-2.txt", "a/new.txt"} {
+		object, err := store.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("data"), Key: aws.String(key)})
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", aws.ToString(object.ContentType), "REPLACE keeps the content type")
+		decrypted, err := current.DecryptData(ctx, bufio.NewReader(object.Body), object.Metadata, key)
+		require.NoError(t, err, key)
+		data, err := io.ReadAll(decrypted)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, data, key)
+	}
+
+	// A second run finds nothing left to do
+	again, err := m.Start(Request{Bucket: "data"})
+	require.NoError(t, err)
+	job = waitForJob(t, m, again.ID)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Zero(t, job.ObjectsRewrapped)
+	assert.Equal(t, int64(4), job.ObjectsCurrent)
+}
+
+func TestManager_RotateReportsFailures(t *testing.T) {
+	ctx := context.Background()
+	store := backend.NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	_, err := store.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("data")})
+	require.NoError(t, err)
+
+	putEncrypted(t, store, newEncryptionManager(t, "kek-2025", oldProvider), "lost.txt", []byte("data"))
+
+	// The old KEK was removed before the rotation
+	m := NewManager(store, newEncryptionManager(t, "kek-2026", newProvider), Config{}, logrus.NewEntry(logrus.New()))
+	defer m.Shutdown()
+
+	started, err := m.Start(Request{Bucket: "data"})
+	require.NoError(t, err)
+	job := waitForJob(t, m, started.ID)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(1), job.ObjectsFailed)
+	require.Len(t, job.Failures, 1)
+	assert.Equal(t, "lost.txt", job.Failures[0].Key)
+	assert.ErrorIs(t, m.Cancel("unknown"), ErrJobNotFound)
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	store := backend.NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	_, err := store.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("data")})
+	require.NoError(t, err)
+
+	m := NewManager(store, newEncryptionManager(t, "kek-2026", newProvider), Config{}, logrus.NewEntry(logrus.New()))
+	defer m.Shutdown()
+	h := NewHandler(m, logrus.NewEntry(logrus.New()))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{"bucket":"data"}`)))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, BasePath+"/"+job.ID, rec.Header().Get("Location"))
+	waitForJob(t, m, job.ID)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/"+job.ID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"state":"completed"`)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,105 @@
+package license
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the license endpoints are mounted on the monitoring server
+const BasePath = "/admin/license"
+
+// Handler exposes the license status over HTTP:
+//
+//	GET  /admin/license  status of the current license
+//	POST /admin/license  reload the license token and return the status
+type Handler struct {
+	validator *LicenseValidator
+	logger    *logrus.Entry
+	mux       *http.ServeMux
+}
+
+// StatusResponse is the license status returned by the endpoints
+type StatusResponse struct {
+	Valid                 bool        `json:"valid"`
+	LicensedTo            string      `json:"licensed_to,omitempty"`
+	Company               string      `json:"company,omitempty"`
+	ExpiresAt             *time.Time  `json:"expires_at,omitempty"`
+	RemainingSeconds      int64       `json:"remaining_seconds"`
+	WarningThresholdDays  int         `json:"warning_threshold_days,omitempty"`
+	InGracePeriod         bool        `json:"in_grace_period"`
+	GraceEndsAt           *time.Time  `json:"grace_ends_at,omitempty"`
+	Restricted            bool        `json:"restricted"`
+	Revoked               bool        `json:"revoked"`
+	RevocationUnavailable bool        `json:"revocation_unavailable,omitempty"`
+	Enforcement           Enforcement `json:"enforcement"`
+	Error                 string      `json:"error,omitempty"` // why a reload kept the current license
+}
+
+// NewHandler creates a new license HTTP handler
+func NewHandler(validator *LicenseValidator, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		validator: validator,
+		logger:    logger,
+		mux:       http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+BasePath, h.handleStatus)
+	h.mux.HandleFunc("POST "+BasePath, h.handleReload)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.response(h.validator.Status()))
+}
+
+// handleReload answers 422 Unprocessable Entity if the reloaded token was not
+// accepted; the current license is kept then
+func (h *Handler) handleReload(w http.ResponseWriter, _ *http.Request) {
+	status, err := h.validator.Reload()
+	response := h.response(status)
+	if err != nil {
+		h.logger.WithError(err).Warn("License reload failed, keeping the current license")
+		response.Error = err.Error()
+		h.writeJSON(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+	h.logger.WithField("restricted", status.Restricted).Info("License reloaded")
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) response(status Status) StatusResponse {
+	response := StatusResponse{
+		Valid:                 status.Valid,
+		RemainingSeconds:      int64(status.Remaining.Seconds()),
+		WarningThresholdDays:  status.WarningThreshold,
+		InGracePeriod:         status.InGracePeriod,
+		Restricted:            status.Restricted,
+		Revoked:               status.Revoked,
+		RevocationUnavailable: status.RevocationUnavailable,
+		Enforcement:           h.validator.Enforcement(),
+	}
+	if info := h.validator.GetLicenseInfo(); info != nil && info.Claims != nil {
+		response.LicensedTo = info.Claims.LicenseeName
+		response.Company = info.Claims.LicenseeCompany
+	}
+	if !status.ExpiresAt.IsZero() {
+		response.ExpiresAt = &status.ExpiresAt
+		response.GraceEndsAt = &status.GraceEndsAt
+	}
+	return response
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write license response")
+	}
+}
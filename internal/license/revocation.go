@@ -0,0 +1,241 @@
+package license
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// RevocationSubject is the subject claim of a revocation list, so a license
+// token cannot be passed off as one
+const RevocationSubject = "s3-encryption-proxy-revocations"
+
+// revocationFetchTimeout limits a download of the revocation list
+const revocationFetchTimeout = 10 * time.Second
+
+// maxRevocationListSize limits the revocation list read from the source
+const maxRevocationListSize = 1 << 20
+
+// errRevocationUnavailable is returned when no verified revocation list is
+// recent enough to decide whether a license was revoked
+var errRevocationUnavailable = errors.New("license revocation list unavailable")
+
+// RevocationList is a signed list of revoked license IDs. It is a JWT signed
+// with the license key, like the licenses it revokes.
+type RevocationList struct {
+	Revoked []RevokedLicense `json:"revoked"`
+	jwt.RegisteredClaims
+}
+
+// RevokedLicense is a revoked license, identified by its jti claim
+type RevokedLicense struct {
+	ID        string    `json:"jti"`
+	RevokedAt time.Time `json:"revoked_at"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// RevocationOptions controls the revocation check. It is disabled while
+// Source is empty.
+type RevocationOptions struct {
+	// Source is the http(s) URL or file path the signed list is read from
+	Source string
+	// RefreshInterval is the time between reads of the source
+	RefreshInterval time.Duration
+	// MaxAge is how long the last verified list is used while reading the
+	// source fails
+	MaxAge time.Duration
+	// FailClosed treats the license as invalid while no list is available
+	// within MaxAge. Otherwise the license stays valid and a warning is logged.
+	FailClosed bool
+	// CacheFile keeps the last verified list, so it is available after a
+	// restart while the source is not
+	CacheFile string
+}
+
+// revocationChecker reads, verifies and caches the revocation list
+type revocationChecker struct {
+	options RevocationOptions
+	key     *rsa.PublicKey
+	skew    time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	revoked     map[string]RevokedLicense // nil until a list was verified
+	issuedAt    time.Time                 // of the current list
+	verifiedAt  time.Time                 // last successful read of the source
+	attemptedAt time.Time                 // last read of the source
+	cacheLoaded bool
+}
+
+// newRevocationChecker creates a checker verifying lists with key
+func newRevocationChecker(options RevocationOptions, key *rsa.PublicKey, skew time.Duration) *revocationChecker {
+	return &revocationChecker{
+		options: options,
+		key:     key,
+		skew:    skew,
+		client:  &http.Client{Timeout: revocationFetchTimeout},
+	}
+}
+
+// refresh reads the list from the source if RefreshInterval has passed since
+// the last read. A list that cannot be read or verified leaves the current
+// one in place.
+func (c *revocationChecker) refresh(now time.Time) error {
+	c.mu.Lock()
+	if !c.cacheLoaded {
+		c.cacheLoaded = true
+		c.loadCache()
+	}
+	due := c.attemptedAt.IsZero() || !now.Before(c.attemptedAt.Add(c.options.RefreshInterval))
+	if due {
+		c.attemptedAt = now
+	}
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	data, err := c.fetch()
+	if err != nil {
+		return fmt.Errorf("failed to read license revocation list from %s: %w", c.options.Source, err)
+	}
+	list, err := c.parse(data, now)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revoked != nil && list.IssuedAt.Before(c.issuedAt) {
+		return fmt.Errorf("license revocation list issued at %s is older than the current one issued at %s",
+			list.IssuedAt.Format(time.RFC3339), c.issuedAt.Format(time.RFC3339))
+	}
+	c.set(list, now)
+	if c.options.CacheFile != "" {
+		if err := writeFileAtomic(c.options.CacheFile, data); err != nil {
+			logrus.WithError(err).Warn("Failed to cache the license revocation list")
+		}
+	}
+	return nil
+}
+
+// lookup returns the revocation of the license with id, nil if it was not
+// revoked, or errRevocationUnavailable if no list was verified within MaxAge
+func (c *revocationChecker) lookup(id string, now time.Time) (*RevokedLicense, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revoked == nil || now.After(c.verifiedAt.Add(c.options.MaxAge)) {
+		return nil, errRevocationUnavailable
+	}
+	if revoked, ok := c.revoked[id]; ok {
+		return &revoked, nil
+	}
+	return nil, nil
+}
+
+// set makes list the current list. c.mu must be held.
+func (c *revocationChecker) set(list *RevocationList, verifiedAt time.Time) {
+	c.revoked = make(map[string]RevokedLicense, len(list.Revoked))
+	for _, revoked := range list.Revoked {
+		c.revoked[revoked.ID] = revoked
+	}
+	c.issuedAt = list.IssuedAt.Time
+	c.verifiedAt = verifiedAt
+}
+
+// loadCache restores the list of the cache file, verified when the file was
+// written. c.mu must be held.
+func (c *revocationChecker) loadCache() {
+	if c.options.CacheFile == "" {
+		return
+	}
+	data, err := os.ReadFile(c.options.CacheFile) // #nosec G304 - path is configured by the operator
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to read the cached license revocation list")
+		return
+	}
+	fileInfo, err := os.Stat(c.options.CacheFile)
+	if err != nil {
+		return
+	}
+	list, err := c.parse(data, time.Now())
+	if err != nil {
+		logrus.WithError(err).Warn("Ignoring the cached license revocation list")
+		return
+	}
+	c.set(list, fileInfo.ModTime())
+}
+
+// fetch reads the raw list from the source
+func (c *revocationChecker) fetch() ([]byte, error) {
+	source := c.options.Source
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source) // #nosec G304 - path is configured by the operator
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only body
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRevocationListSize))
+}
+
+// parse verifies the signature and claims of a revocation list
+func (c *revocationChecker) parse(data []byte, now time.Time) (*RevocationList, error) {
+	list := &RevocationList{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(string(data)), list, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return c.key, nil
+	}, jwt.WithLeeway(c.skew), jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithSubject(RevocationSubject), jwt.WithIssuedAt())
+	if err != nil {
+		return nil, fmt.Errorf("invalid license revocation list: %w", err)
+	}
+	if list.IssuedAt == nil {
+		return nil, fmt.Errorf("invalid license revocation list: missing iat claim")
+	}
+	return list, nil
+}
+
+// writeFileAtomic replaces path with data, so a crash leaves the previous file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // already renamed on success
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // the write error is reported
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
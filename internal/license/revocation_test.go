@@ -0,0 +1,200 @@
+package license
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signRevocationList(t *testing.T, key *rsa.PrivateKey, issuedAt time.Time, subject string, revoked ...string) string {
+	t.Helper()
+	list := RevocationList{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:  subject,
+		IssuedAt: jwt.NewNumericDate(issuedAt),
+	}}
+	for _, id := range revoked {
+		list.Revoked = append(list.Revoked, RevokedLicense{ID: id, RevokedAt: issuedAt, Reason: "refunded"})
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, list).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+// revocationServer serves the list stored in body, or 503 while it is empty
+func revocationServer(t *testing.T, body *atomic.Value) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		list, _ := body.Load().(string)
+		if list == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(list))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRevocationTestValidator(t *testing.T, key *rsa.PrivateKey, revocation RevocationOptions, licenseID string) *LicenseValidator {
+	t.Helper()
+	options := DefaultOptions()
+	options.Revocation = revocation
+	validator := NewValidatorWithOptions(options)
+	validator.revocation.key = &key.PublicKey
+	validator.info = &LicenseInfo{
+		Valid:     true,
+		Claims:    &LicenseClaims{RegisteredClaims: jwt.RegisteredClaims{ID: licenseID}},
+		ExpiresAt: time.Now().Add(365 * 24 * time.Hour),
+	}
+	return validator
+}
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestRevocation_RevokedLicenseIsRestricted(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	var body atomic.Value
+	body.Store(signRevocationList(t, key, now, RevocationSubject, "lic-revoked"))
+	server := revocationServer(t, &body)
+
+	validator := newRevocationTestValidator(t, key, RevocationOptions{Source: server.URL}, "lic-revoked")
+	status, err := validator.revalidate(now)
+	require.NoError(t, err)
+	assert.True(t, status.Revoked)
+	assert.False(t, status.Valid)
+	assert.True(t, status.Restricted)
+	assert.False(t, status.RevocationUnavailable)
+
+	assert.ErrorContains(t, validator.checkRevocation("lic-revoked", now), "revoked on")
+	assert.NoError(t, validator.checkRevocation("lic-other", now))
+	assert.NoError(t, validator.checkRevocation("", now), "licenses without an ID cannot be revoked")
+
+	other := newRevocationTestValidator(t, key, RevocationOptions{Source: server.URL}, "lic-other")
+	status, err = other.revalidate(now)
+	require.NoError(t, err)
+	assert.True(t, status.Valid)
+	assert.False(t, status.Revoked)
+}
+
+func TestRevocation_FailOpenAndFailClosed(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	var body atomic.Value
+	server := revocationServer(t, &body)
+
+	validator := newRevocationTestValidator(t, key, RevocationOptions{Source: server.URL}, "lic-1")
+	status, _ := validator.revalidate(now)
+	assert.True(t, status.RevocationUnavailable)
+	assert.True(t, status.Valid, "fails open by default")
+	assert.NoError(t, validator.checkRevocation("lic-1", now))
+
+	validator = newRevocationTestValidator(t, key, RevocationOptions{Source: server.URL, FailClosed: true}, "lic-1")
+	status, _ = validator.revalidate(now)
+	assert.True(t, status.RevocationUnavailable)
+	assert.False(t, status.Valid)
+	assert.True(t, status.Restricted)
+	assert.ErrorIs(t, validator.checkRevocation("lic-1", now), errRevocationUnavailable)
+}
+
+func TestRevocation_ListIsUsedForMaxAge(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	var body atomic.Value
+	body.Store(signRevocationList(t, key, now, RevocationSubject, "lic-1"))
+	server := revocationServer(t, &body)
+
+	options := RevocationOptions{Source: server.URL, RefreshInterval: time.Minute, MaxAge: time.Hour, FailClosed: true}
+	validator := newRevocationTestValidator(t, key, options, "lic-2")
+	status, _ := validator.revalidate(now)
+	assert.True(t, status.Valid)
+
+	body.Store("")
+	status, _ = validator.revalidate(now.Add(30 * time.Minute))
+	assert.True(t, status.Valid, "the last list is used while the source fails")
+	assert.False(t, status.RevocationUnavailable)
+
+	status, _ = validator.revalidate(now.Add(61 * time.Minute))
+	assert.True(t, status.RevocationUnavailable)
+	assert.False(t, status.Valid)
+}
+
+func TestRevocation_RejectsInvalidLists(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	var body atomic.Value
+	server := revocationServer(t, &body)
+	checker := newRevocationChecker(RevocationOptions{Source: server.URL}, &key.PublicKey, time.Minute)
+
+	body.Store(signRevocationList(t, generateTestKey(t), now, RevocationSubject, "lic-1"))
+	assert.ErrorContains(t, checker.refresh(now), "invalid license revocation list", "signed by another key")
+
+	body.Store(signRevocationList(t, key, now, "s3-encryption-proxy-license", "lic-1"))
+	assert.ErrorContains(t, checker.refresh(now), "invalid license revocation list", "a license is no revocation list")
+
+	body.Store(signRevocationList(t, key, now, RevocationSubject, "lic-1"))
+	require.NoError(t, checker.refresh(now))
+
+	body.Store(signRevocationList(t, key, now.Add(-time.Hour), RevocationSubject))
+	assert.ErrorContains(t, checker.refresh(now), "older than the current one", "an old list cannot replace a newer one")
+	revoked, err := checker.lookup("lic-1", now)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked)
+}
+
+func TestRevocation_RefreshInterval(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	var requests atomic.Int32
+	list := signRevocationList(t, key, now, RevocationSubject)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(list))
+	}))
+	defer server.Close()
+
+	checker := newRevocationChecker(RevocationOptions{Source: server.URL, RefreshInterval: time.Hour}, &key.PublicKey, 0)
+	require.NoError(t, checker.refresh(now))
+	require.NoError(t, checker.refresh(now.Add(time.Minute)))
+	assert.Equal(t, int32(1), requests.Load())
+	require.NoError(t, checker.refresh(now.Add(time.Hour)))
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRevocation_FileSourceAndCache(t *testing.T) {
+	key := generateTestKey(t)
+	now := time.Now()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "revocations.jwt")
+	cacheFile := filepath.Join(dir, "revocations-cache.jwt")
+	require.NoError(t, os.WriteFile(source, []byte(signRevocationList(t, key, now, RevocationSubject, "lic-1")+"\n"), 0o600))
+
+	options := RevocationOptions{Source: source, RefreshInterval: time.Hour, MaxAge: 24 * time.Hour, CacheFile: cacheFile}
+	checker := newRevocationChecker(options, &key.PublicKey, 0)
+	require.NoError(t, checker.refresh(now))
+	assert.FileExists(t, cacheFile)
+
+	// after a restart the cached list is used while the source is gone
+	require.NoError(t, os.Remove(source))
+	restarted := newRevocationChecker(options, &key.PublicKey, 0)
+	assert.Error(t, restarted.refresh(now))
+	revoked, err := restarted.lookup("lic-1", now)
+	require.NoError(t, err)
+	require.NotNil(t, revoked)
+	assert.Equal(t, "refunded", revoked.Reason)
+}
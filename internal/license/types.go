@@ -1,6 +1,7 @@
 package license
 
 import (
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -41,9 +42,81 @@ type TimeRemaining struct {
 //
 //nolint:revive // Exported type name matches domain context
 type LicenseValidator struct {
-	info     *LicenseInfo
+	options  Options
 	stopChan chan struct{}
 	doneChan chan struct{}
+
+	mu            sync.Mutex
+	info          *LicenseInfo
+	started       bool
+	statusHandler func(Status)
+	lastThreshold int  // warning threshold reported last, to log each threshold once
+	restricted    bool // restriction reported last, to log each change once
+	revoked       bool // revocation reported last, to log each change once
+
+	revocation *revocationChecker // nil without a revocation list
+}
+
+// Enforcement is what happens once a license has expired beyond its grace
+// period
+type Enforcement string
+
+const (
+	// EnforcementRestrict rejects uploads but keeps serving reads, so existing
+	// data stays readable until the license is renewed
+	EnforcementRestrict Enforcement = "restrict"
+	// EnforcementShutdown stops the proxy
+	EnforcementShutdown Enforcement = "shutdown"
+)
+
+// Options controls validation tolerance and runtime re-validation
+type Options struct {
+	// ClockSkew is accepted on the nbf and exp claims, so nodes whose clocks
+	// differ slightly do not disagree about validity during rolling restarts
+	ClockSkew time.Duration
+	// GracePeriod is the time after expiry (and clock skew) during which the
+	// license is still accepted with a warning
+	GracePeriod time.Duration
+	// Enforcement applies once the grace period is over
+	Enforcement Enforcement
+	// RevalidationInterval is the time between runtime re-validations
+	RevalidationInterval time.Duration
+	// WarningThresholds are the days before expiry at which a warning is logged
+	WarningThresholds []int
+	// Revocation checks the license ID against a signed revocation list
+	Revocation RevocationOptions
+	// TokenLoader reloads the token on re-validation, so a renewed license is
+	// picked up without a restart. nil re-checks the current license only.
+	TokenLoader func() string
+}
+
+// DefaultOptions returns the options used by NewValidator
+func DefaultOptions() Options {
+	return Options{
+		ClockSkew:            5 * time.Minute,
+		RevalidationInterval: time.Hour,
+		WarningThresholds:    []int{30, 7, 1},
+		Enforcement:          EnforcementRestrict,
+		Revocation: RevocationOptions{
+			RefreshInterval: time.Hour,
+			MaxAge:          7 * 24 * time.Hour,
+		},
+	}
+}
+
+// Status is the license state reported after each runtime re-validation
+type Status struct {
+	Valid            bool
+	ExpiresAt        time.Time     // zero for licenses without expiry
+	Remaining        time.Duration // time until exp, 0 once expired
+	WarningThreshold int           // smallest warning threshold (days) reached, 0 if none
+	InGracePeriod    bool          // past exp but within the clock skew and grace period
+	GraceEndsAt      time.Time     // when the license stops being accepted, zero without expiry
+	Restricted       bool          // uploads are rejected because the license is no longer valid
+	Revoked          bool          // the license ID is on the revocation list
+	// RevocationUnavailable is set while no revocation list was verified
+	// within its max age; the license is only invalid then if failing closed
+	RevocationUnavailable bool
 }
 
 // ValidationResult represents the result of license validation
@@ -34,16 +34,54 @@ kqRHiSyfHQNh3MjKVi5iOeXty40Gt8qn81vf6cHrQpfWfJnAhvIvLTlA92T8/zJM
 8mWFN0VNajEzeVKrEUPcvK8CAwEAAQ==
 -----END PUBLIC KEY-----`
 
-// NewValidator creates a new license validator instance
+// NewValidator creates a new license validator instance with DefaultOptions
 func NewValidator() *LicenseValidator {
-	return &LicenseValidator{
+	return NewValidatorWithOptions(DefaultOptions())
+}
+
+// NewValidatorWithOptions creates a new license validator instance
+func NewValidatorWithOptions(options Options) *LicenseValidator {
+	defaults := DefaultOptions()
+	if options.ClockSkew < 0 {
+		options.ClockSkew = 0
+	}
+	if options.GracePeriod < 0 {
+		options.GracePeriod = 0
+	}
+	if options.Enforcement == "" {
+		options.Enforcement = defaults.Enforcement
+	}
+	if options.RevalidationInterval <= 0 {
+		options.RevalidationInterval = defaults.RevalidationInterval
+	}
+	if options.Revocation.RefreshInterval <= 0 {
+		options.Revocation.RefreshInterval = defaults.Revocation.RefreshInterval
+	}
+	if options.Revocation.MaxAge <= 0 {
+		options.Revocation.MaxAge = defaults.Revocation.MaxAge
+	}
+
+	v := &LicenseValidator{
+		options:  options,
 		stopChan: make(chan struct{}),
 		doneChan: make(chan struct{}),
 	}
+	if options.Revocation.Source != "" {
+		// The embedded key is constant and parsed by every validation
+		publicKey, _ := parseEmbeddedPublicKey()
+		v.revocation = newRevocationChecker(options.Revocation, publicKey, options.ClockSkew)
+	}
+	return v
 }
 
 // ValidateLicense validates a JWT license token
 func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult {
+	return v.validateAt(tokenString, time.Now())
+}
+
+// validateAt validates tokenString as of now, accepting nbf within the
+// configured clock skew and exp within the clock skew and grace period
+func (v *LicenseValidator) validateAt(tokenString string, now time.Time) *ValidationResult {
 	if tokenString == "" {
 		return &ValidationResult{
 			Valid:   false,
@@ -68,7 +106,7 @@ func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return publicKey, nil
-	})
+	}, jwt.WithLeeway(v.tolerance()), jwt.WithTimeFunc(func() time.Time { return now }))
 
 	if err != nil {
 		return &ValidationResult{
@@ -88,9 +126,17 @@ func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult
 		}
 	}
 
+	// The leeway covers the grace period, which only applies to exp
+	if claims.NotBefore != nil && now.Add(v.options.ClockSkew).Before(claims.NotBefore.Time) {
+		return &ValidationResult{
+			Valid:   false,
+			Error:   fmt.Errorf("license is not valid before %s", claims.NotBefore.Time.Format("2006-01-02 15:04:05 MST")),
+			Message: "License is not valid yet",
+		}
+	}
+
 	// Check expiration
-	now := time.Now()
-	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Time) {
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(v.tolerance())) {
 		return &ValidationResult{
 			Valid:   false,
 			Error:   fmt.Errorf("license expired on %s", claims.ExpiresAt.Time.Format("2006-01-02 15:04:05 MST")),
@@ -98,6 +144,15 @@ func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult
 		}
 	}
 
+	// Check the license ID against the revocation list
+	if err := v.checkRevocation(claims.ID, now); err != nil {
+		return &ValidationResult{
+			Valid:   false,
+			Error:   err,
+			Message: "License has been revoked",
+		}
+	}
+
 	// Calculate time remaining
 	var expiresAt time.Time
 	var timeRemaining TimeRemaining
@@ -114,7 +169,9 @@ func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult
 		TimeRemaining: timeRemaining,
 	}
 
+	v.mu.Lock()
 	v.info = info
+	v.mu.Unlock()
 
 	return &ValidationResult{
 		Valid:   true,
@@ -125,7 +182,7 @@ func (v *LicenseValidator) ValidateLicense(tokenString string) *ValidationResult
 
 // ValidateProviderType checks if the provider type is allowed without a license
 func (v *LicenseValidator) ValidateProviderType(providerType string) error {
-	if v.info == nil || !v.info.Valid {
+	if info := v.GetLicenseInfo(); info == nil || !info.Valid {
 		if providerType != "none" {
 			return fmt.Errorf(
 				"license required for encryption provider type '%s'\n"+
@@ -138,52 +195,277 @@ func (v *LicenseValidator) ValidateProviderType(providerType string) error {
 	return nil
 }
 
-// StartRuntimeMonitoring starts background monitoring of license validity
+// StartRuntimeMonitoring starts background re-validation of the license.
+// The license is re-validated every RevalidationInterval and right after the
+// grace period. Once no valid license remains, uploads are rejected until a
+// renewed license is loaded, or with EnforcementShutdown the proxy shuts down.
 func (v *LicenseValidator) StartRuntimeMonitoring() {
-	if v.info == nil || !v.info.Valid {
+	v.mu.Lock()
+	if v.info == nil || !v.info.Valid || v.started {
+		v.mu.Unlock()
 		logrus.Debug("No valid license - skipping runtime monitoring")
 		return
 	}
+	v.started = true
+	v.mu.Unlock()
 
-	logrus.Info("Starting license runtime monitoring (checks every 60 minutes)")
-
-	ticker := time.NewTicker(60 * time.Minute)
-
-	go func() {
-		defer close(v.doneChan)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				now := time.Now()
-				if now.After(v.info.ExpiresAt) {
-					logrus.Error("License expired during runtime - initiating graceful shutdown")
-					v.gracefulShutdown()
-					return
-				} else {
-					// Update remaining time and log if approaching expiration
-					remaining := calculateTimeRemaining(now, v.info.ExpiresAt)
-					if remaining.Total < 30*24*time.Hour { // 30 days
-						logrus.Warnf("License expires in %d days - please renew soon", remaining.Days)
-					}
-				}
-			case <-v.stopChan:
-				logrus.Debug("License monitoring stopped")
+	logrus.WithFields(logrus.Fields{
+		"interval":     v.options.RevalidationInterval,
+		"clock_skew":   v.options.ClockSkew,
+		"grace_period": v.options.GracePeriod,
+		"enforcement":  v.options.Enforcement,
+	}).Info("Starting license runtime monitoring")
+
+	go v.monitor()
+}
+
+func (v *LicenseValidator) monitor() {
+	defer close(v.doneChan)
+
+	v.report(v.statusAt(time.Now()))
+
+	timer := time.NewTimer(v.nextCheckDelay(time.Now()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			status, err := v.revalidate(time.Now())
+			if err != nil {
+				logrus.WithError(err).Warn("License re-validation failed, keeping the current license")
+			}
+			if !status.Valid && v.options.Enforcement == EnforcementShutdown {
+				logrus.Error("License is no longer valid during runtime - initiating graceful shutdown")
+				v.gracefulShutdown()
 				return
 			}
+			timer.Reset(v.nextCheckDelay(time.Now()))
+		case <-v.stopChan:
+			logrus.Debug("License monitoring stopped")
+			return
 		}
-	}()
+	}
+}
+
+// Reload reads the license token again and validates it, so a renewed
+// license is used without a restart. A token that fails validation does not
+// replace the current license; its error is returned with the status of the
+// current license.
+func (v *LicenseValidator) Reload() (Status, error) {
+	if v.options.TokenLoader == nil {
+		return v.Status(), fmt.Errorf("no license source to reload from")
+	}
+	return v.revalidate(time.Now())
+}
+
+// revalidate reloads and validates the license token and reports the
+// resulting status. A reloaded token that fails validation does not replace
+// the current license, so a license file that is briefly unreadable or being
+// replaced does not stop the proxy.
+func (v *LicenseValidator) revalidate(now time.Time) (Status, error) {
+	v.refreshRevocation(now)
+
+	var err error
+	if v.options.TokenLoader != nil {
+		token := v.options.TokenLoader()
+		if token == "" {
+			err = fmt.Errorf("no license token found")
+		} else if result := v.validateAt(token, now); !result.Valid {
+			err = result.Error
+			if err == nil {
+				err = fmt.Errorf("%s", result.Message)
+			}
+		}
+	}
+
+	status := v.statusAt(now)
+	v.report(status)
+	return status, err
+}
+
+// Status returns the status of the current license
+func (v *LicenseValidator) Status() Status {
+	return v.statusAt(time.Now())
+}
+
+// Restricted reports whether uploads are rejected because the license
+// expired beyond its grace period or was revoked
+func (v *LicenseValidator) Restricted() bool {
+	return v.Status().Restricted
+}
+
+// Enforcement returns what happens once the license has expired
+func (v *LicenseValidator) Enforcement() Enforcement {
+	return v.options.Enforcement
+}
+
+// tolerance is the time after exp during which a license is still accepted
+func (v *LicenseValidator) tolerance() time.Duration {
+	return v.options.ClockSkew + v.options.GracePeriod
+}
+
+// statusAt returns the status of the current license as of now
+func (v *LicenseValidator) statusAt(now time.Time) Status {
+	info := v.GetLicenseInfo()
+	if info == nil || !info.Valid {
+		return Status{}
+	}
+
+	status := Status{Valid: true, ExpiresAt: info.ExpiresAt}
+	if !info.ExpiresAt.IsZero() {
+		status.GraceEndsAt = info.ExpiresAt.Add(v.tolerance())
+		status.Remaining = info.ExpiresAt.Sub(now)
+		if status.Remaining <= 0 {
+			status.Remaining = 0
+			status.Valid = !now.After(status.GraceEndsAt)
+			status.InGracePeriod = status.Valid
+		}
+		for _, days := range v.options.WarningThresholds {
+			if days > 0 && status.Remaining <= time.Duration(days)*24*time.Hour &&
+				(status.WarningThreshold == 0 || days < status.WarningThreshold) {
+				status.WarningThreshold = days
+			}
+		}
+	}
+
+	if v.revocation != nil && info.Claims != nil && info.Claims.ID != "" {
+		revoked, err := v.revocation.lookup(info.Claims.ID, now)
+		status.Revoked = revoked != nil
+		status.RevocationUnavailable = err != nil
+		if status.Revoked || (status.RevocationUnavailable && v.options.Revocation.FailClosed) {
+			status.Valid = false
+			status.InGracePeriod = false
+		}
+	}
+	status.Restricted = !status.Valid && v.options.Enforcement == EnforcementRestrict
+	return status
+}
+
+// refreshRevocation reads the revocation list again if it is due. A failed
+// read keeps the current list, which is used for up to its max age.
+func (v *LicenseValidator) refreshRevocation(now time.Time) {
+	if v.revocation == nil {
+		return
+	}
+	if err := v.revocation.refresh(now); err != nil {
+		logrus.WithError(err).Warn("Failed to refresh the license revocation list, keeping the current list")
+	}
+}
+
+// checkRevocation refreshes the revocation list and returns an error if the
+// license with id was revoked, or if no list is available and the check
+// fails closed. Licenses without an ID cannot be revoked.
+func (v *LicenseValidator) checkRevocation(id string, now time.Time) error {
+	if v.revocation == nil || id == "" {
+		return nil
+	}
+	v.refreshRevocation(now)
+
+	revoked, err := v.revocation.lookup(id, now)
+	switch {
+	case revoked != nil && revoked.Reason != "":
+		return fmt.Errorf("license %s was revoked on %s: %s", id, revoked.RevokedAt.Format(time.DateOnly), revoked.Reason)
+	case revoked != nil:
+		return fmt.Errorf("license %s was revoked on %s", id, revoked.RevokedAt.Format(time.DateOnly))
+	case err != nil && v.options.Revocation.FailClosed:
+		return fmt.Errorf("%w and revocation.fail_closed is set", err)
+	case err != nil:
+		logrus.WithError(err).Warn("Accepting the license without a revocation check (fail open)")
+	}
+	return nil
+}
+
+// nextCheckDelay returns the time until the next re-validation: the regular
+// interval or the revocation list refresh interval, or shortly after the
+// grace period if that ends first
+func (v *LicenseValidator) nextCheckDelay(now time.Time) time.Duration {
+	delay := v.options.RevalidationInterval
+	if v.revocation != nil {
+		delay = min(delay, v.options.Revocation.RefreshInterval)
+	}
+	if info := v.GetLicenseInfo(); info != nil && !info.ExpiresAt.IsZero() {
+		graceEnd := info.ExpiresAt.Add(v.tolerance())
+		if untilGraceEnd := graceEnd.Sub(now) + time.Second; !now.After(graceEnd) && untilGraceEnd < delay {
+			delay = untilGraceEnd
+		}
+	}
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay
+}
+
+// report logs threshold crossings and restriction changes and passes status
+// to the status handler
+func (v *LicenseValidator) report(status Status) {
+	v.mu.Lock()
+	crossed := status.WarningThreshold != 0 && status.WarningThreshold != v.lastThreshold
+	v.lastThreshold = status.WarningThreshold
+	restrictionChanged := status.Restricted != v.restricted
+	v.restricted = status.Restricted
+	revocationChanged := status.Revoked != v.revoked
+	v.revoked = status.Revoked
+	handler := v.statusHandler
+	v.mu.Unlock()
+
+	switch {
+	case revocationChanged && status.Revoked:
+		logrus.WithField("enforcement", v.options.Enforcement).
+			Error("License has been revoked - the enforcement applies until a new license is loaded")
+	case restrictionChanged && status.Restricted && status.RevocationUnavailable && v.options.Revocation.FailClosed:
+		logrus.Error("License revocation list is unavailable - uploads are rejected until it can be read again (fail closed)")
+	case restrictionChanged && status.Restricted:
+		logrus.WithField("expired_at", status.ExpiresAt.Format("2006-01-02 15:04:05 MST")).
+			Error("License has expired - uploads are rejected until a renewed license is loaded, reads keep working")
+	case restrictionChanged:
+		logrus.Info("Valid license loaded - uploads are accepted again")
+	case status.InGracePeriod:
+		logrus.WithFields(logrus.Fields{
+			"expired_at":    status.ExpiresAt.Format("2006-01-02 15:04:05 MST"),
+			"grace_ends_at": status.GraceEndsAt.Format("2006-01-02 15:04:05 MST"),
+			"enforcement":   v.options.Enforcement,
+		}).Warn("License has expired, running within the grace period - please renew now")
+	case crossed:
+		logrus.WithFields(logrus.Fields{
+			"expires_at":     status.ExpiresAt.Format("2006-01-02 15:04:05 MST"),
+			"days_remaining": int(status.Remaining.Hours() / 24),
+			"threshold_days": status.WarningThreshold,
+		}).Warn("License expires soon - please renew at https://s3ep.com")
+	}
+
+	if handler != nil {
+		handler(status)
+	}
+}
+
+// SetStatusHandler registers fn to receive the license status after every
+// re-validation (e.g. to export metrics). fn is called once immediately.
+func (v *LicenseValidator) SetStatusHandler(fn func(Status)) {
+	v.mu.Lock()
+	v.statusHandler = fn
+	v.mu.Unlock()
+
+	if fn != nil {
+		fn(v.statusAt(time.Now()))
+	}
 }
 
 // Stop gracefully stops the license validator
 func (v *LicenseValidator) Stop() {
+	v.mu.Lock()
+	started := v.started
+	v.mu.Unlock()
+
 	close(v.stopChan)
-	<-v.doneChan
+	if started {
+		<-v.doneChan
+	}
 }
 
 // GetLicenseInfo returns the current license information
 func (v *LicenseValidator) GetLicenseInfo() *LicenseInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	return v.info
 }
 
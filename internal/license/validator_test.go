@@ -225,3 +225,154 @@ func TestLicenseClaims(t *testing.T) {
 	assert.Equal(t, "cluster-prod-01", claims.KubernetesClusterID)
 	assert.NotNil(t, claims.ExpiresAt)
 }
+
+func newTestValidator(expiresAt time.Time, options Options) *LicenseValidator {
+	validator := NewValidatorWithOptions(options)
+	validator.info = &LicenseInfo{
+		Valid:     true,
+		Claims:    &LicenseClaims{LicenseeName: "Test User"},
+		ExpiresAt: expiresAt,
+	}
+	return validator
+}
+
+func TestStatusAt_WarningThresholdsAndGracePeriod(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	options := DefaultOptions()
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		expected  Status
+	}{
+		{name: "far from expiry", expiresIn: 90 * 24 * time.Hour, expected: Status{Valid: true}},
+		{name: "within 30 days", expiresIn: 20 * 24 * time.Hour, expected: Status{Valid: true, WarningThreshold: 30}},
+		{name: "within 7 days", expiresIn: 7 * 24 * time.Hour, expected: Status{Valid: true, WarningThreshold: 7}},
+		{name: "last day", expiresIn: time.Hour, expected: Status{Valid: true, WarningThreshold: 1}},
+		{name: "expired within skew", expiresIn: -time.Minute, expected: Status{Valid: true, WarningThreshold: 1, InGracePeriod: true}},
+		{name: "expired beyond skew", expiresIn: -time.Hour, expected: Status{Valid: false, WarningThreshold: 1, Restricted: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt := now.Add(tt.expiresIn)
+			status := newTestValidator(expiresAt, options).statusAt(now)
+
+			tt.expected.ExpiresAt = expiresAt
+			tt.expected.GraceEndsAt = expiresAt.Add(options.ClockSkew)
+			tt.expected.Remaining = max(tt.expiresIn, 0)
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
+
+func TestStatusAt_NoExpiry(t *testing.T) {
+	status := newTestValidator(time.Time{}, DefaultOptions()).statusAt(time.Now())
+	assert.Equal(t, Status{Valid: true}, status)
+
+	assert.Equal(t, Status{}, NewValidator().statusAt(time.Now()), "no license is never valid")
+}
+
+func TestNextCheckDelay(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	options := Options{ClockSkew: 5 * time.Minute, RevalidationInterval: time.Hour}
+
+	validator := newTestValidator(now.Add(48*time.Hour), options)
+	assert.Equal(t, time.Hour, validator.nextCheckDelay(now))
+
+	// wake up right after the tolerated expiry instead of up to an hour later
+	validator = newTestValidator(now.Add(10*time.Minute), options)
+	assert.Equal(t, 15*time.Minute+time.Second, validator.nextCheckDelay(now))
+
+	// past the grace period a restricted proxy keeps checking at the interval
+	validator = newTestValidator(now.Add(-time.Hour), options)
+	assert.Equal(t, time.Hour, validator.nextCheckDelay(now))
+
+	options.GracePeriod = 2 * time.Hour
+	validator = newTestValidator(now.Add(-time.Hour), options)
+	assert.Equal(t, time.Hour, validator.nextCheckDelay(now))
+	validator = newTestValidator(now.Add(-90*time.Minute), options)
+	assert.Equal(t, 35*time.Minute+time.Second, validator.nextCheckDelay(now))
+}
+
+func TestStatusAt_GracePeriodAndEnforcement(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	options := DefaultOptions()
+	options.ClockSkew = 0
+	options.GracePeriod = 7 * 24 * time.Hour
+
+	status := newTestValidator(now.Add(-3*24*time.Hour), options).statusAt(now)
+	assert.True(t, status.Valid)
+	assert.True(t, status.InGracePeriod)
+	assert.False(t, status.Restricted)
+	assert.Equal(t, now.Add(4*24*time.Hour), status.GraceEndsAt)
+
+	status = newTestValidator(now.Add(-8*24*time.Hour), options).statusAt(now)
+	assert.False(t, status.Valid)
+	assert.False(t, status.InGracePeriod)
+	assert.True(t, status.Restricted, "restrict is the default enforcement")
+
+	options.Enforcement = EnforcementShutdown
+	status = newTestValidator(now.Add(-8*24*time.Hour), options).statusAt(now)
+	assert.False(t, status.Valid)
+	assert.False(t, status.Restricted, "shutdown stops the proxy instead of restricting it")
+}
+
+func TestReload_RenewedLicenseLiftsRestriction(t *testing.T) {
+	now := time.Now()
+	options := DefaultOptions()
+	options.TokenLoader = func() string { return "" }
+	validator := newTestValidator(now.Add(-time.Hour), options)
+	assert.True(t, validator.Restricted())
+
+	status, err := validator.Reload()
+	assert.Error(t, err, "no token was found")
+	assert.True(t, status.Restricted)
+
+	// stands in for a renewed token passing validation
+	validator.mu.Lock()
+	validator.info = &LicenseInfo{Valid: true, Claims: &LicenseClaims{}, ExpiresAt: now.Add(365 * 24 * time.Hour)}
+	validator.mu.Unlock()
+	assert.False(t, validator.Restricted())
+}
+
+func TestReload_WithoutTokenLoader(t *testing.T) {
+	options := DefaultOptions()
+	_, err := newTestValidator(time.Now().Add(time.Hour), options).Reload()
+	assert.Error(t, err)
+}
+
+func TestRevalidate_KeepsLicenseWhenReloadFails(t *testing.T) {
+	now := time.Now()
+	options := DefaultOptions()
+	options.TokenLoader = func() string { return "invalid.jwt.token" }
+	validator := newTestValidator(now.Add(20*24*time.Hour), options)
+
+	var reported []Status
+	validator.SetStatusHandler(func(status Status) { reported = append(reported, status) })
+
+	status, err := validator.revalidate(now)
+	assert.Error(t, err)
+	assert.True(t, status.Valid)
+	assert.Equal(t, 30, status.WarningThreshold)
+	assert.True(t, validator.GetLicenseInfo().Valid)
+	require.Len(t, reported, 2, "handler is called on registration and after re-validation")
+	assert.Equal(t, status, reported[1])
+}
+
+func TestStop_WithoutMonitoring(t *testing.T) {
+	validator := NewValidator()
+	validator.StartRuntimeMonitoring() // no license, nothing started
+
+	done := make(chan struct{})
+	go func() {
+		validator.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked without a running monitor")
+	}
+}
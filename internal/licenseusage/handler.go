@@ -0,0 +1,57 @@
+package licenseusage
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the usage endpoint is mounted on the monitoring server
+const BasePath = "/admin/license/usage"
+
+// Handler exposes the usage counters over HTTP:
+//
+//	GET /admin/license/usage  encrypted and decrypted bytes per month
+type Handler struct {
+	meter  *Meter
+	logger *logrus.Entry
+	mux    *http.ServeMux
+}
+
+// Report is the usage returned by the endpoint
+type Report struct {
+	Periods   []Period   `json:"periods"`            // oldest first
+	Persisted bool       `json:"persisted"`          // counters survive restarts
+	SavedAt   *time.Time `json:"saved_at,omitempty"` // last save of the state file
+}
+
+// NewHandler creates a new license usage HTTP handler
+func NewHandler(meter *Meter, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		meter:  meter,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+BasePath, h.handleUsage)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleUsage(w http.ResponseWriter, _ *http.Request) {
+	report := Report{Periods: h.meter.Periods(), Persisted: h.meter.path != ""}
+	if savedAt := h.meter.SavedAt(); !savedAt.IsZero() {
+		report.SavedAt = &savedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.WithError(err).Error("Failed to write license usage response")
+	}
+}
@@ -0,0 +1,238 @@
+// Package licenseusage accounts the plaintext bytes the proxy encrypts and
+// decrypts per calendar month (UTC), so licensees of a volume-based license
+// can audit their usage. The counters are saved to a state file and restored
+// on start, so they survive restarts.
+package licenseusage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stateVersion is the version of the state file format
+const stateVersion = 1
+
+// monthFormat names the periods, e.g. "2026-10"
+const monthFormat = "2006-01"
+
+// Period is the usage of one calendar month
+type Period struct {
+	Month          string `json:"month"` // YYYY-MM in UTC
+	EncryptedBytes int64  `json:"encrypted_bytes"`
+	DecryptedBytes int64  `json:"decrypted_bytes"`
+}
+
+// state is the content of the state file
+type state struct {
+	Version int      `json:"version"`
+	Periods []Period `json:"periods"`
+}
+
+// Meter counts encrypted and decrypted bytes per month. It implements
+// orchestration.UsageRecorder.
+type Meter struct {
+	path      string // state file, empty = not persisted
+	retention int    // months kept, 0 = all
+	logger    *logrus.Entry
+	now       func() time.Time
+
+	mu      sync.Mutex
+	periods map[string]*Period // by month
+	dirty   bool               // changed since the last save
+	savedAt time.Time
+}
+
+// NewMeter creates a meter that keeps the last retention months, restoring
+// its counters from the state file at path. path may be empty, then the
+// counters start at zero and are not saved. A missing state file is not an
+// error.
+func NewMeter(path string, retention int, logger *logrus.Entry) (*Meter, error) {
+	m := &Meter{
+		path:      path,
+		retention: retention,
+		logger:    logger,
+		now:       time.Now,
+		periods:   make(map[string]*Period),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddEncrypted counts n plaintext bytes encrypted now
+func (m *Meter) AddEncrypted(n int64) {
+	m.mu.Lock()
+	m.current().EncryptedBytes += n
+	m.dirty = true
+	m.mu.Unlock()
+}
+
+// AddDecrypted counts n plaintext bytes decrypted now
+func (m *Meter) AddDecrypted(n int64) {
+	m.mu.Lock()
+	m.current().DecryptedBytes += n
+	m.dirty = true
+	m.mu.Unlock()
+}
+
+// current returns the period of this month. m.mu must be held.
+func (m *Meter) current() *Period {
+	month := m.now().UTC().Format(monthFormat)
+	period, ok := m.periods[month]
+	if !ok {
+		period = &Period{Month: month}
+		m.periods[month] = period
+		m.prune()
+	}
+	return period
+}
+
+// prune drops the periods beyond the retention. m.mu must be held.
+func (m *Meter) prune() {
+	if m.retention <= 0 || len(m.periods) <= m.retention {
+		return
+	}
+	months := m.months()
+	for _, month := range months[:len(months)-m.retention] {
+		delete(m.periods, month)
+	}
+}
+
+// months returns the months with usage, oldest first. m.mu must be held.
+func (m *Meter) months() []string {
+	months := make([]string, 0, len(m.periods))
+	for month := range m.periods {
+		months = append(months, month)
+	}
+	slices.Sort(months)
+	return months
+}
+
+// Periods returns the usage of every month kept, oldest first
+func (m *Meter) Periods() []Period {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	periods := make([]Period, 0, len(m.periods))
+	for _, month := range m.months() {
+		periods = append(periods, *m.periods[month])
+	}
+	return periods
+}
+
+// SavedAt returns when the counters were last saved, zero if never
+func (m *Meter) SavedAt() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.savedAt
+}
+
+// Save writes the counters to the state file if they changed. The file is
+// replaced atomically, so a crash leaves the previous counters.
+func (m *Meter) Save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	st := state{Version: stateVersion, Periods: make([]Period, 0, len(m.periods))}
+	for _, month := range m.months() {
+		st.Periods = append(st.Periods, *m.periods[month])
+	}
+	m.dirty = false
+	m.mu.Unlock()
+
+	if err := m.write(st); err != nil {
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+		return err
+	}
+
+	m.mu.Lock()
+	m.savedAt = m.now()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Meter) write(st state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode license usage: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to save license usage: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // already renamed on success
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // the write error is reported
+		return fmt.Errorf("failed to save license usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save license usage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to save license usage: %w", err)
+	}
+	return nil
+}
+
+// load restores the counters from the state file
+func (m *Meter) load() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read license usage state: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("failed to parse license usage state %s: %w", m.path, err)
+	}
+	if st.Version != stateVersion {
+		return fmt.Errorf("license usage state %s has unsupported version %d", m.path, st.Version)
+	}
+	for _, period := range st.Periods {
+		if _, err := time.Parse(monthFormat, period.Month); err != nil {
+			return fmt.Errorf("license usage state %s has an invalid month %q", m.path, period.Month)
+		}
+		m.periods[period.Month] = &period
+	}
+	m.prune()
+	return nil
+}
+
+// Run saves the counters every interval until ctx is done. Call Save on
+// shutdown to keep the usage since the last interval.
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Save(); err != nil {
+				m.logger.WithError(err).Warn("Failed to save license usage, retrying at the next interval")
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package licenseusage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMeter(t *testing.T, path string, retention int, now *time.Time) *Meter {
+	t.Helper()
+	meter, err := NewMeter(path, retention, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	meter.now = func() time.Time { return *now }
+	return meter
+}
+
+func TestMeter_CountsPerMonth(t *testing.T) {
+	now := time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC)
+	meter := newTestMeter(t, "", 0, &now)
+
+	meter.AddEncrypted(100)
+	meter.AddDecrypted(40)
+	now = now.Add(2 * time.Hour)
+	meter.AddEncrypted(5)
+
+	assert.Equal(t, []Period{
+		{Month: "2026-09", EncryptedBytes: 100, DecryptedBytes: 40},
+		{Month: "2026-10", EncryptedBytes: 5},
+	}, meter.Periods())
+	assert.NoError(t, meter.Save(), "nothing is saved without a state file")
+}
+
+func TestMeter_Retention(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	meter := newTestMeter(t, "", 2, &now)
+	for range 3 {
+		meter.AddEncrypted(1)
+		now = now.AddDate(0, 1, 0)
+	}
+
+	periods := meter.Periods()
+	require.Len(t, periods, 2)
+	assert.Equal(t, "2026-02", periods[0].Month)
+	assert.Equal(t, "2026-03", periods[1].Month)
+}
+
+func TestMeter_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	meter := newTestMeter(t, path, 0, &now)
+	meter.AddEncrypted(1 << 20)
+	meter.AddDecrypted(1 << 10)
+	require.NoError(t, meter.Save())
+	assert.Equal(t, now, meter.SavedAt())
+
+	restarted := newTestMeter(t, path, 0, &now)
+	restarted.AddEncrypted(1)
+	assert.Equal(t, []Period{{Month: "2026-10", EncryptedBytes: 1<<20 + 1, DecryptedBytes: 1 << 10}}, restarted.Periods())
+	assert.True(t, restarted.SavedAt().IsZero())
+}
+
+func TestNewMeter_InvalidState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	logger := logrus.NewEntry(logrus.New())
+
+	_, err := NewMeter(path, 0, logger)
+	assert.NoError(t, err, "a missing state file starts at zero")
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = NewMeter(path, 0, logger)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":2,"periods":[]}`), 0o600))
+	_, err = NewMeter(path, 0, logger)
+	assert.ErrorContains(t, err, "unsupported version")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":1,"periods":[{"month":"October"}]}`), 0o600))
+	_, err = NewMeter(path, 0, logger)
+	assert.ErrorContains(t, err, "invalid month")
+}
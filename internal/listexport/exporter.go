@@ -0,0 +1,257 @@
+// Package listexport implements the admin "list export" job, which streams the
+// complete listing of a backend bucket into a gzip-compressed CSV or JSONL
+// object in a diagnostics bucket. It exists for buckets with tens of millions
+// of keys, where crawling ListObjectsV2 page by page through the proxy is
+// impractical for operators.
+package listexport
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/jobs"
+)
+
+// Format selects the row encoding of an export object
+type Format string
+
+const (
+	// FormatCSV writes one CSV record per key with a header row
+	FormatCSV Format = "csv"
+	// FormatJSONL writes one JSON document per key and line
+	FormatJSONL Format = "jsonl"
+)
+
+// State is the lifecycle state of an export job
+type State = jobs.State
+
+const (
+	StateRunning   = jobs.StateRunning
+	StateCompleted = jobs.StateCompleted
+	StateFailed    = jobs.StateFailed
+	StateCancelled = jobs.StateCancelled
+)
+
+const (
+	defaultKeyPrefix         = "list-exports/"
+	defaultPartSize          = 8 * 1024 * 1024
+	minPartSize              = 5 * 1024 * 1024 // S3 minimum for all but the last part
+	defaultMaxConcurrentJobs = 2
+)
+
+var (
+	// ErrTooManyJobs is returned when the concurrent job limit is reached
+	ErrTooManyJobs = errors.New("too many list export jobs running")
+	// ErrJobNotFound is returned for unknown job IDs
+	ErrJobNotFound = errors.New("list export job not found")
+)
+
+// Backend is the subset of the S3 client used by the exporter
+type Backend interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// Config holds list export configuration
+type Config struct {
+	DiagnosticsBucket string // Bucket that receives export objects
+	KeyPrefix         string // Key prefix for export objects (default: list-exports/)
+	PartSize          int64  // Compressed bytes per uploaded part (default: 8MB)
+	MaxConcurrentJobs int    // Maximum number of jobs running at the same time (default: 2)
+}
+
+// Request describes a list export to start
+type Request struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+	Format Format `json:"format,omitempty"`
+}
+
+// Job is a point-in-time snapshot of an export job
+type Job struct {
+	jobs.Status
+	Bucket            string `json:"bucket"`
+	Prefix            string `json:"prefix,omitempty"`
+	Format            Format `json:"format"`
+	DestinationBucket string `json:"destination_bucket"`
+	DestinationKey    string `json:"destination_key"`
+	ObjectsExported   int64  `json:"objects_exported"`
+	BytesUploaded     int64  `json:"bytes_uploaded"`
+}
+
+// Manager starts and tracks list export jobs
+type Manager struct {
+	backend Backend
+	config  Config
+	logger  *logrus.Entry
+	jobs    *jobs.Manager[Job, *Job]
+}
+
+// NewManager creates a new list export manager
+func NewManager(backend Backend, cfg Config, logger *logrus.Entry) *Manager {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultKeyPrefix
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	if cfg.PartSize < minPartSize {
+		cfg.PartSize = minPartSize
+	}
+	if cfg.MaxConcurrentJobs <= 0 {
+		cfg.MaxConcurrentJobs = defaultMaxConcurrentJobs
+	}
+
+	return &Manager{
+		backend: backend,
+		config:  cfg,
+		logger:  logger.WithField("component", "list-export"),
+		jobs: jobs.NewManager[Job](jobs.Config[Job]{
+			MaxConcurrentJobs: cfg.MaxConcurrentJobs,
+			ErrTooManyJobs:    ErrTooManyJobs,
+			ErrJobNotFound:    ErrJobNotFound,
+		}),
+	}
+}
+
+// Start validates the request and launches an export job in the background
+func (m *Manager) Start(req Request) (Job, error) {
+	if req.Bucket == "" {
+		return Job{}, fmt.Errorf("bucket is required")
+	}
+	if req.Format == "" {
+		req.Format = FormatCSV
+	}
+	if req.Format != FormatCSV && req.Format != FormatJSONL {
+		return Job{}, fmt.Errorf("unsupported format %q (use csv or jsonl)", req.Format)
+	}
+
+	return m.jobs.Start(func(status jobs.Status) Job {
+		return Job{
+			Status:            status,
+			Bucket:            req.Bucket,
+			Prefix:            req.Prefix,
+			Format:            req.Format,
+			DestinationBucket: m.config.DiagnosticsBucket,
+			DestinationKey:    fmt.Sprintf("%s%s/%s-%s.%s.gz", m.config.KeyPrefix, req.Bucket, status.StartedAt.Format("20060102T150405Z"), status.ID, req.Format),
+		}
+	}, m.run)
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (Job, error) {
+	return m.jobs.Get(id)
+}
+
+// List returns snapshots of all retained jobs, newest first
+func (m *Manager) List() []Job {
+	return m.jobs.List()
+}
+
+// Cancel stops a running job; the partial upload is aborted
+func (m *Manager) Cancel(id string) error {
+	return m.jobs.Cancel(id)
+}
+
+// Shutdown cancels all running jobs and waits for them to finish
+func (m *Manager) Shutdown() {
+	m.jobs.Shutdown()
+}
+
+// run performs the listing and upload for a single job
+func (m *Manager) run(ctx context.Context, entry *Job) error {
+	var job Job
+	m.jobs.Update(func() { job = *entry })
+
+	log := m.logger.WithFields(logrus.Fields{
+		"job_id":      job.ID,
+		"bucket":      job.Bucket,
+		"prefix":      job.Prefix,
+		"format":      job.Format,
+		"destination": job.DestinationBucket + "/" + job.DestinationKey,
+	})
+	log.Info("Starting list export")
+
+	progress := func(objects, bytes int64) {
+		m.jobs.Update(func() {
+			entry.ObjectsExported = objects
+			entry.BytesUploaded = bytes
+		})
+	}
+	exported, uploaded, err := m.export(ctx, job, progress)
+	progress(exported, uploaded)
+
+	fields := logrus.Fields{
+		"objects":  exported,
+		"bytes":    uploaded,
+		"duration": time.Since(job.StartedAt),
+	}
+	if err != nil {
+		log.WithError(err).WithFields(fields).Warn("List export did not complete")
+		return err
+	}
+	log.WithFields(fields).Info("List export completed")
+	return nil
+}
+
+// export streams the listing through the row encoder and gzip into a
+// multipart upload. Progress is reported after every listing page.
+func (m *Manager) export(ctx context.Context, job Job, progress func(objects, bytes int64)) (int64, int64, error) {
+	upload := newPartWriter(ctx, m.backend, job.DestinationBucket, job.DestinationKey, m.config.PartSize)
+	gz := gzip.NewWriter(upload)
+	rows := newRowWriter(job.Format, gz)
+
+	fail := func(err error) (int64, int64, error) {
+		upload.Abort()
+		return rows.count, upload.uploaded, err
+	}
+
+	if err := rows.WriteHeader(); err != nil {
+		return fail(err)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(m.backend, &s3.ListObjectsV2Input{
+		Bucket: aws.String(job.Bucket),
+		Prefix: optionalString(job.Prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fail(fmt.Errorf("failed to list objects: %w", err))
+		}
+		for _, obj := range page.Contents {
+			if err := rows.WriteObject(obj); err != nil {
+				return fail(err)
+			}
+		}
+		progress(rows.count, upload.uploaded)
+	}
+
+	if err := rows.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := gz.Close(); err != nil {
+		return fail(fmt.Errorf("failed to finish gzip stream: %w", err))
+	}
+	if err := upload.Close(); err != nil {
+		return fail(err)
+	}
+	return rows.count, upload.uploaded, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
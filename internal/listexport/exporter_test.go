@@ -0,0 +1,268 @@
+package listexport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend serves a fixed listing in pages and records multipart uploads
+type fakeBackend struct {
+	mu       sync.Mutex
+	keys     []string
+	pageSize int
+	listErr  error
+
+	parts     map[int32][]byte
+	completed bool
+	aborted   bool
+}
+
+func newFakeBackend(n, pageSize int) *fakeBackend {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("data/object-%05d", i)
+	}
+	return &fakeBackend{keys: keys, pageSize: pageSize, parts: make(map[int32][]byte)}
+}
+
+func (f *fakeBackend) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	start := 0
+	if params.ContinuationToken != nil {
+		_, _ = fmt.Sscanf(aws.ToString(params.ContinuationToken), "%d", &start)
+	}
+	end := min(start+f.pageSize, len(f.keys))
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(f.keys))}
+	modified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, key := range f.keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(128),
+			ETag:         aws.String(`"etag"`),
+			LastModified: &modified,
+			StorageClass: types.ObjectStorageClassStandard,
+		})
+	}
+	if end < len(f.keys) {
+		out.NextContinuationToken = aws.String(fmt.Sprintf("%d", end))
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) CreateMultipartUpload(_ context.Context, _ *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeBackend) UploadPart(_ context.Context, params *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[aws.ToInt32(params.PartNumber)] = data
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"part-%d"`, aws.ToInt32(params.PartNumber)))}, nil
+}
+
+func (f *fakeBackend) CompleteMultipartUpload(_ context.Context, _ *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed = true
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeBackend) AbortMultipartUpload(_ context.Context, _ *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// object reassembles and decompresses the uploaded export
+func (f *fakeBackend) object(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var compressed bytes.Buffer
+	for i := int32(1); i <= int32(len(f.parts)); i++ {
+		compressed.Write(f.parts[i])
+	}
+	gz, err := gzip.NewReader(&compressed)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func newTestManager(backend Backend) *Manager {
+	return NewManager(backend, Config{DiagnosticsBucket: "diagnostics"}, logrus.NewEntry(logrus.New()))
+}
+
+func waitForJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	var job Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(id)
+		require.NoError(t, err)
+		return job.State != StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager_ExportCSV(t *testing.T) {
+	backend := newFakeBackend(2500, 1000)
+	m := newTestManager(backend)
+	defer m.Shutdown()
+
+	job, err := m.Start(Request{Bucket: "big-bucket", Prefix: "data/"})
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, job.Format)
+	assert.Equal(t, "diagnostics", job.DestinationBucket)
+	assert.True(t, strings.HasPrefix(job.DestinationKey, "list-exports/big-bucket/"))
+	assert.True(t, strings.HasSuffix(job.DestinationKey, ".csv.gz"))
+
+	job = waitForJob(t, m, job.ID)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(2500), job.ObjectsExported)
+	assert.NotNil(t, job.FinishedAt)
+	assert.True(t, backend.completed)
+
+	records, err := csv.NewReader(strings.NewReader(backend.object(t))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2501)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, []string{"data/object-00000", "128", `"etag"`, "2025-01-02T03:04:05Z", "STANDARD"}, records[1])
+	assert.Equal(t, "data/object-02499", records[2500][0])
+}
+
+func TestManager_ExportJSONL(t *testing.T) {
+	backend := newFakeBackend(3, 2)
+	m := newTestManager(backend)
+	defer m.Shutdown()
+
+	job, err := m.Start(Request{Bucket: "bucket", Format: FormatJSONL})
+	require.NoError(t, err)
+	job = waitForJob(t, m, job.ID)
+	require.Equal(t, StateCompleted, job.State)
+
+	lines := strings.Split(strings.TrimSpace(backend.object(t)), "\n")
+	require.Len(t, lines, 3)
+	var row exportRow
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &row))
+	assert.Equal(t, "data/object-00002", row.Key)
+	assert.Equal(t, int64(128), row.Size)
+}
+
+func TestManager_EmptyBucketStillProducesObject(t *testing.T) {
+	backend := newFakeBackend(0, 10)
+	m := newTestManager(backend)
+	defer m.Shutdown()
+
+	job, err := m.Start(Request{Bucket: "empty"})
+	require.NoError(t, err)
+	job = waitForJob(t, m, job.ID)
+
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(0), job.ObjectsExported)
+	assert.Equal(t, strings.Join(csvHeader, ",")+"\n", backend.object(t))
+}
+
+func TestManager_ListErrorAbortsUpload(t *testing.T) {
+	backend := newFakeBackend(10, 10)
+	backend.listErr = errors.New("access denied")
+	m := newTestManager(backend)
+	defer m.Shutdown()
+
+	job, err := m.Start(Request{Bucket: "bucket"})
+	require.NoError(t, err)
+	job = waitForJob(t, m, job.ID)
+
+	assert.Equal(t, StateFailed, job.State)
+	assert.Contains(t, job.Error, "access denied")
+	assert.False(t, backend.completed)
+}
+
+func TestManager_StartValidation(t *testing.T) {
+	m := newTestManager(newFakeBackend(0, 1))
+	defer m.Shutdown()
+
+	_, err := m.Start(Request{})
+	assert.Error(t, err)
+
+	_, err = m.Start(Request{Bucket: "b", Format: "xml"})
+	assert.Error(t, err)
+
+	_, err = m.Get("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestPartWriter_SplitsIntoParts(t *testing.T) {
+	backend := newFakeBackend(0, 1)
+	pw := newPartWriter(context.Background(), backend, "diagnostics", "key", minPartSize)
+
+	data := bytes.Repeat([]byte("x"), minPartSize*2+10)
+	_, err := pw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+
+	require.Len(t, backend.parts, 3)
+	assert.Len(t, backend.parts[1], minPartSize)
+	assert.Len(t, backend.parts[3], 10)
+	assert.Equal(t, int64(len(data)), pw.uploaded)
+	assert.True(t, backend.completed)
+}
+
+func TestHandler_StartAndGet(t *testing.T) {
+	backend := newFakeBackend(5, 10)
+	m := newTestManager(backend)
+	defer m.Shutdown()
+	h := NewHandler(m, logrus.NewEntry(logrus.New()))
+
+	req := httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{"bucket":"bucket","format":"jsonl"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var job Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, BasePath+"/"+job.ID, rec.Header().Get("Location"))
+	waitForJob(t, m, job.ID)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/"+job.ID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, int64(5), job.ObjectsExported)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, BasePath, strings.NewReader(`{"format":"csv"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
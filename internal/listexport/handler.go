@@ -0,0 +1,101 @@
+package listexport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the export endpoints are mounted on the monitoring server
+const BasePath = "/admin/list-exports"
+
+// maxRequestBodySize bounds the JSON body of a start request
+const maxRequestBodySize = 64 * 1024
+
+// Handler exposes the list export manager over HTTP:
+//
+//	POST   /admin/list-exports       start a job ({"bucket","prefix","format"})
+//	GET    /admin/list-exports       list retained jobs
+//	GET    /admin/list-exports/{id}  job status
+//	DELETE /admin/list-exports/{id}  cancel a running job
+type Handler struct {
+	manager *Manager
+	logger  *logrus.Entry
+	mux     *http.ServeMux
+}
+
+// NewHandler creates a new list export HTTP handler
+func NewHandler(manager *Manager, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		manager: manager,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("POST "+BasePath, h.handleStart)
+	h.mux.HandleFunc("GET "+BasePath, h.handleList)
+	h.mux.HandleFunc("GET "+BasePath+"/{id}", h.handleGet)
+	h.mux.HandleFunc("DELETE "+BasePath+"/{id}", h.handleCancel)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleStart(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize)).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	job, err := h.manager.Start(req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrTooManyJobs) {
+			status = http.StatusTooManyRequests
+		}
+		h.writeError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", BasePath+"/"+job.ID)
+	h.writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": h.manager.List()})
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	job, err := h.manager.Get(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.Cancel(r.PathValue("id")); err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write list export response")
+	}
+}
@@ -0,0 +1,206 @@
+package listexport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// csvHeader lists the exported columns. Size is the stored (encrypted) size
+// reported by the backend, not the plaintext size.
+var csvHeader = []string{"key", "size", "etag", "last_modified", "storage_class"}
+
+// exportRow is the JSONL representation of a listed object
+type exportRow struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified,omitempty"`
+	StorageClass string `json:"storage_class,omitempty"`
+}
+
+// rowWriter encodes listed objects as CSV or JSONL
+type rowWriter struct {
+	format Format
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+	count  int64
+}
+
+func newRowWriter(format Format, w io.Writer) *rowWriter {
+	buf := bufio.NewWriterSize(w, 64*1024)
+	rw := &rowWriter{format: format, buf: buf}
+	if format == FormatJSONL {
+		rw.json = json.NewEncoder(buf)
+	} else {
+		rw.csv = csv.NewWriter(buf)
+	}
+	return rw
+}
+
+// WriteHeader writes the CSV header row; JSONL has none
+func (rw *rowWriter) WriteHeader() error {
+	if rw.csv == nil {
+		return nil
+	}
+	if err := rw.csv.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return nil
+}
+
+// WriteObject writes one listed object
+func (rw *rowWriter) WriteObject(obj types.Object) error {
+	row := exportRow{
+		Key:          aws.ToString(obj.Key),
+		Size:         aws.ToInt64(obj.Size),
+		ETag:         aws.ToString(obj.ETag),
+		StorageClass: string(obj.StorageClass),
+	}
+	if obj.LastModified != nil {
+		row.LastModified = obj.LastModified.UTC().Format(time.RFC3339)
+	}
+
+	var err error
+	if rw.json != nil {
+		err = rw.json.Encode(row)
+	} else {
+		err = rw.csv.Write([]string{row.Key, strconv.FormatInt(row.Size, 10), row.ETag, row.LastModified, row.StorageClass})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export row for key %q: %w", row.Key, err)
+	}
+	rw.count++
+	return nil
+}
+
+// Flush pushes buffered rows to the underlying writer
+func (rw *rowWriter) Flush() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			return fmt.Errorf("failed to flush CSV rows: %w", err)
+		}
+	}
+	if err := rw.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush export rows: %w", err)
+	}
+	return nil
+}
+
+// partWriter buffers written bytes and uploads them as multipart parts of
+// partSize, so exports of any size are written with bounded memory. The
+// multipart upload is created lazily on the first flushed part.
+type partWriter struct {
+	ctx      context.Context
+	backend  Backend
+	bucket   string
+	key      string
+	partSize int64
+
+	uploadID string
+	buf      bytes.Buffer
+	parts    []types.CompletedPart
+	uploaded int64
+}
+
+func newPartWriter(ctx context.Context, backend Backend, bucket, key string, partSize int64) *partWriter {
+	return &partWriter{
+		ctx:      ctx,
+		backend:  backend,
+		bucket:   bucket,
+		key:      key,
+		partSize: partSize,
+	}
+}
+
+// Write implements io.Writer
+func (pw *partWriter) Write(p []byte) (int, error) {
+	n, _ := pw.buf.Write(p)
+	for int64(pw.buf.Len()) >= pw.partSize {
+		if err := pw.uploadPart(pw.buf.Next(int(pw.partSize))); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close uploads the remaining bytes and completes the multipart upload
+func (pw *partWriter) Close() error {
+	if pw.buf.Len() > 0 || len(pw.parts) == 0 {
+		if err := pw.uploadPart(pw.buf.Next(pw.buf.Len())); err != nil {
+			return err
+		}
+	}
+
+	_, err := pw.backend.CompleteMultipartUpload(pw.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(pw.bucket),
+		Key:             aws.String(pw.key),
+		UploadId:        aws.String(pw.uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: pw.parts},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete export upload: %w", err)
+	}
+	return nil
+}
+
+// Abort discards the multipart upload if one was created. It uses a fresh
+// context because the job context is usually already cancelled at this point.
+func (pw *partWriter) Abort() {
+	if pw.uploadID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, _ = pw.backend.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(pw.bucket),
+		Key:      aws.String(pw.key),
+		UploadId: aws.String(pw.uploadID),
+	})
+}
+
+func (pw *partWriter) uploadPart(data []byte) error {
+	if pw.uploadID == "" {
+		out, err := pw.backend.CreateMultipartUpload(pw.ctx, &s3.CreateMultipartUploadInput{
+			Bucket:      aws.String(pw.bucket),
+			Key:         aws.String(pw.key),
+			ContentType: aws.String("application/gzip"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create export upload: %w", err)
+		}
+		pw.uploadID = aws.ToString(out.UploadId)
+	}
+
+	partNumber := int32(len(pw.parts) + 1) // #nosec G115 - bounded by S3's 10000 part limit
+	out, err := pw.backend.UploadPart(pw.ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(pw.bucket),
+		Key:           aws.String(pw.key),
+		UploadId:      aws.String(pw.uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload export part %d: %w", partNumber, err)
+	}
+
+	pw.parts = append(pw.parts, types.CompletedPart{
+		PartNumber: aws.Int32(partNumber),
+		ETag:       out.ETag,
+	})
+	pw.uploaded += int64(len(data))
+	return nil
+}
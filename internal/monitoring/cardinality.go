@@ -0,0 +1,140 @@
+package monitoring
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// partialDeleter is implemented by CounterVec, HistogramVec and GaugeVec
+type partialDeleter interface {
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+// LabelLimiter bounds the number of distinct values of one metric label.
+//
+// Up to maxValues values are admitted and reported as-is; any other value is
+// reported as the overflow value. Rebalance periodically re-admits the values
+// with the most traffic since the previous rebalance (top-N) and deletes the
+// series of evicted values from the registered vectors, so the series count
+// stays bounded even when the set of active values drifts over time.
+type LabelLimiter struct {
+	mu sync.Mutex
+
+	label     string
+	maxValues int
+	overflow  string
+	vecs      []partialDeleter
+
+	admitted map[string]struct{}
+	// hits counts observations per value since the last rebalance. It is
+	// bounded to trackLimit entries so that tracking itself cannot grow
+	// without limit.
+	hits       map[string]uint64
+	trackLimit int
+}
+
+// NewLabelLimiter creates a limiter for label on the given vectors
+func NewLabelLimiter(label string, maxValues int, overflow string, vecs ...partialDeleter) *LabelLimiter {
+	if maxValues < 1 {
+		maxValues = 1
+	}
+	return &LabelLimiter{
+		label:      label,
+		maxValues:  maxValues,
+		overflow:   overflow,
+		vecs:       vecs,
+		admitted:   make(map[string]struct{}, maxValues),
+		hits:       make(map[string]uint64),
+		trackLimit: 10 * maxValues,
+	}
+}
+
+// Value records an observation for value and returns the label value to use
+func (l *LabelLimiter) Value(value string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n, ok := l.hits[value]; ok || len(l.hits) < l.trackLimit {
+		l.hits[value] = n + 1
+	}
+
+	if _, ok := l.admitted[value]; ok {
+		return value
+	}
+	if len(l.admitted) < l.maxValues {
+		l.admitted[value] = struct{}{}
+		return value
+	}
+	return l.overflow
+}
+
+// Rebalance admits the busiest values since the last call and returns the
+// values that were evicted. Admitted values without traffic keep their slot
+// while there is room.
+func (l *LabelLimiter) Rebalance() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ranked := make([]string, 0, len(l.hits))
+	for value := range l.hits {
+		ranked = append(ranked, value)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if l.hits[ranked[i]] != l.hits[ranked[j]] {
+			return l.hits[ranked[i]] > l.hits[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	if len(ranked) > l.maxValues {
+		ranked = ranked[:l.maxValues]
+	}
+
+	next := make(map[string]struct{}, l.maxValues)
+	for _, value := range ranked {
+		next[value] = struct{}{}
+	}
+
+	// Keep idle admitted values while slots are free, in a stable order
+	idle := make([]string, 0, len(l.admitted))
+	for value := range l.admitted {
+		if _, ok := next[value]; !ok {
+			idle = append(idle, value)
+		}
+	}
+	sort.Strings(idle)
+
+	var evicted []string
+	for _, value := range idle {
+		if len(next) < l.maxValues {
+			next[value] = struct{}{}
+			continue
+		}
+		evicted = append(evicted, value)
+		for _, vec := range l.vecs {
+			vec.DeletePartialMatch(prometheus.Labels{l.label: value})
+		}
+	}
+
+	l.admitted = next
+	l.hits = make(map[string]uint64, len(l.hits))
+	return evicted
+}
+
+// Run calls Rebalance every interval until ctx is cancelled
+func (l *LabelLimiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.Rebalance()
+		}
+	}
+}
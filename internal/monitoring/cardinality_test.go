@@ -0,0 +1,66 @@
+package monitoring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total"}, []string{"bucket"})
+}
+
+func TestLabelLimiter_OverflowBeyondLimit(t *testing.T) {
+	limiter := NewLabelLimiter("bucket", 2, "_other")
+
+	assert.Equal(t, "a", limiter.Value("a"))
+	assert.Equal(t, "b", limiter.Value("b"))
+	assert.Equal(t, "_other", limiter.Value("c"))
+	assert.Equal(t, "a", limiter.Value("a"), "admitted values keep their label")
+}
+
+func TestLabelLimiter_RebalanceKeepsBusiest(t *testing.T) {
+	counter := newTestCounter()
+	limiter := NewLabelLimiter("bucket", 2, "_other", counter)
+
+	record := func(bucket string, n int) {
+		for i := 0; i < n; i++ {
+			counter.WithLabelValues(limiter.Value(bucket)).Inc()
+		}
+	}
+	record("quiet-1", 1)
+	record("quiet-2", 1)
+	record("busy", 10)
+	assert.Equal(t, float64(10), testutil.ToFloat64(counter.WithLabelValues("_other")))
+
+	evicted := limiter.Rebalance()
+	assert.Equal(t, []string{"quiet-2"}, evicted)
+	assert.Equal(t, "busy", limiter.Value("busy"))
+	assert.Equal(t, "quiet-1", limiter.Value("quiet-1"))
+	assert.Equal(t, "_other", limiter.Value("quiet-2"))
+
+	// series of evicted values are removed, so the series count stays bounded
+	assert.Equal(t, 2, testutil.CollectAndCount(counter), "quiet-1 and _other remain")
+}
+
+func TestLabelLimiter_IdleValuesKeepSlotsWhileFree(t *testing.T) {
+	limiter := NewLabelLimiter("bucket", 3, "_other")
+	limiter.Value("a")
+	limiter.Rebalance()
+
+	// "a" had no traffic in the last window but there is room for it
+	limiter.Value("b")
+	assert.Empty(t, limiter.Rebalance())
+	assert.Equal(t, "a", limiter.Value("a"))
+}
+
+func TestLabelLimiter_TrackingIsBounded(t *testing.T) {
+	limiter := NewLabelLimiter("bucket", 1, "_other")
+	for i := 0; i < 1000; i++ {
+		limiter.Value(fmt.Sprintf("bucket-%d", i))
+	}
+	assert.LessOrEqual(t, len(limiter.hits), 10)
+}
@@ -2,6 +2,7 @@ package monitoring
 
 import (
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -79,6 +80,107 @@ var (
 		[]string{"operation", "bucket"},
 	)
 
+	// Backend route metrics (bucket-to-backend routing)
+	BackendRouteRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_backend_route_requests_total",
+			Help: "Total number of backend requests per backend route",
+		},
+		[]string{"route", "operation", "status"},
+	)
+
+	BackendRouteRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "s3ep_backend_route_request_duration_seconds",
+			Help:    "Backend request duration in seconds per backend route",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "operation"},
+	)
+
+	BackendRouteUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_backend_route_up",
+			Help: "Result of the last backend route health check (1 = healthy, 0 = unhealthy)",
+		},
+		[]string{"route"},
+	)
+
+	// Backend endpoint metrics (s3_backend and its replicas)
+	BackendEndpointRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_backend_endpoint_requests_total",
+			Help: "Total number of backend requests per endpoint of s3_backend and its replicas",
+		},
+		[]string{"endpoint", "operation", "status"},
+	)
+
+	BackendEndpointUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_backend_endpoint_up",
+			Help: "Result of the last backend endpoint health check (1 = healthy, 0 = unhealthy)",
+		},
+		[]string{"endpoint"},
+	)
+
+	BackendEndpointLatency = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_backend_endpoint_latency_seconds",
+			Help: "Smoothed health check latency of a backend endpoint, which orders reads with read_preference nearest",
+		},
+		[]string{"endpoint"},
+	)
+
+	BackendFailoversTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_backend_failovers_total",
+			Help: "Total number of reads retried on another backend endpoint, by the endpoint that failed",
+		},
+		[]string{"endpoint", "operation"},
+	)
+
+	// Replication metrics
+	ReplicationQueueLength = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_replication_queue_length",
+			Help: "Number of objects waiting to be copied to the replication target",
+		},
+	)
+
+	ReplicationObjectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_replication_objects_total",
+			Help: "Total number of objects processed by replication, by result (replicated, retried, failed, dropped, skipped)",
+		},
+		[]string{"result"},
+	)
+
+	// Object cache metrics
+	ObjectCacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_object_cache_lookups_total",
+			Help: "Total number of object cache lookups, by result (memory, disk, miss)",
+		},
+		[]string{"result"},
+	)
+
+	ObjectCacheBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_object_cache_bytes",
+			Help: "Bytes of object bodies held by the object cache, by tier (memory, disk)",
+		},
+		[]string{"tier"},
+	)
+
+	// Event notification metrics
+	NotificationEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_notification_events_total",
+			Help: "Event notifications delivered to a target, by result (sent, failed, dropped on a full queue)",
+		},
+		[]string{"target", "result"},
+	)
+
 	// Encryption metrics
 	EncryptionOperationsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -123,6 +225,296 @@ var (
 		[]string{"status"},
 	)
 
+	// Synthetic canary metrics
+	CanaryRunsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_canary_runs_total",
+			Help: "Total number of canary runs through the proxy endpoint",
+		},
+		[]string{"status"},
+	)
+
+	CanaryStepDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "s3ep_canary_step_duration_seconds",
+			Help:    "Duration of the canary put, get and delete steps in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"step", "status"},
+	)
+
+	CanaryUp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_canary_up",
+			Help: "Result of the last canary run (1 = success, 0 = failure)",
+		},
+	)
+
+	CanaryLastSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_canary_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful canary run",
+		},
+	)
+
+	// Integrity scrubber metrics
+	ScrubberObjectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_scrubber_objects_total",
+			Help: "Objects sampled by the integrity scrubber by result (ok, unverified, failed, error, skipped)",
+		},
+		[]string{"result"},
+	)
+
+	ScrubberBytesTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "s3ep_scrubber_bytes_total",
+			Help: "Plaintext bytes decrypted by the integrity scrubber",
+		},
+	)
+
+	ScrubberObjectDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "s3ep_scrubber_object_duration_seconds",
+			Help:    "Time to read, decrypt and verify one sampled object in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+	)
+
+	ScrubberSampleErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "s3ep_scrubber_sample_errors_total",
+			Help: "Scrubber runs that could not list the buckets to sample from",
+		},
+	)
+
+	ScrubberLastFailure = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_scrubber_last_failure_timestamp_seconds",
+			Help: "Unix timestamp of the last object that failed decryption or integrity verification",
+		},
+	)
+
+	// Multipart reconciliation metrics
+	MultipartOrphansTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_multipart_orphans_total",
+			Help: "Orphaned multipart uploads (backend uploads without session, sessions without backend upload) found by the reconciliation, by kind and action",
+		},
+		[]string{"kind", "action"},
+	)
+
+	MultipartReconcileErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "s3ep_multipart_reconcile_errors_total",
+			Help: "Reconciliation runs that could not list the session store or a bucket's multipart uploads",
+		},
+	)
+
+	// KEK rotation metrics
+	KEKRewrapObjectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_kek_rewrap_objects_total",
+			Help: "Objects processed by KEK rotation jobs by result (rewrapped, current, failed)",
+		},
+		[]string{"result"},
+	)
+
+	// Bucket usage collector metrics
+	BucketUsageObjects = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_bucket_usage_objects",
+			Help: "Number of objects per bucket at the last usage collection",
+		},
+		[]string{"bucket"},
+	)
+
+	BucketUsagePlaintextBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_bucket_usage_plaintext_bytes",
+			Help: "Logical (plaintext) bytes per bucket at the last usage collection",
+		},
+		[]string{"bucket"},
+	)
+
+	BucketUsageStoredBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_bucket_usage_stored_bytes",
+			Help: "Bytes stored in the backend per bucket at the last usage collection",
+		},
+		[]string{"bucket"},
+	)
+
+	UsageCollectionDuration = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_usage_collection_duration_seconds",
+			Help: "Duration of the last usage collection in seconds",
+		},
+	)
+
+	UsageCollectionLastSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_usage_collection_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last usage collection without errors",
+		},
+	)
+
+	// Backend response validation metrics
+	BackendTruncatedResponses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_backend_truncated_responses_total",
+			Help: "Backend GET bodies that ended early or broke off, by whether a ranged re-read recovered them",
+		},
+		[]string{"result"},
+	)
+
+	BackendETagMismatches = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "s3ep_backend_etag_mismatches_total",
+			Help: "Backend GET bodies whose MD5 did not match their single-part ETag",
+		},
+	)
+
+	HandlerPanics = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_handler_panics_total",
+			Help: "Request handler panics recovered and answered with 500 InternalError",
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	// Provider key operation rate limit metrics
+	ProviderRateLimitQueued = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "s3ep_provider_rate_limit_queued",
+			Help: "Key operations waiting for the rate limit of a provider",
+		},
+		[]string{"provider"},
+	)
+
+	ProviderRateLimitWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "s3ep_provider_rate_limit_wait_seconds",
+			Help:    "Time key operations waited for the rate limit of a provider in seconds",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"provider", "operation"},
+	)
+
+	ProviderRateLimitRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_provider_rate_limit_rejected_total",
+			Help: "Key operations rejected because the rate limit queue of a provider was full or timed out",
+		},
+		[]string{"provider", "operation"},
+	)
+
+	ClientRateLimited = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_client_rate_limited_total",
+			Help: "S3 requests rejected with SlowDown because the client exceeded its request rate or bandwidth",
+		},
+		[]string{"reason"},
+	)
+
+	ClientBandwidthWait = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_client_bandwidth_wait_seconds_total",
+			Help: "Time request and response bodies were held back to keep clients within their bandwidth",
+		},
+		[]string{"direction"},
+	)
+
+	ProviderCoalescedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_provider_coalesced_requests_total",
+			Help: "DEK unwraps served by a concurrent identical unwrap instead of a provider call",
+		},
+		[]string{"provider"},
+	)
+
+	// Provider key operation latency, errors and DEK cache
+	ProviderDEKDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "s3ep_provider_dek_duration_seconds",
+			Help:    "Duration of DEK wrap (encrypt) and unwrap (decrypt) calls to a provider in seconds, failed calls included",
+			Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"provider", "operation"},
+	)
+
+	ProviderDEKErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_provider_dek_errors_total",
+			Help: "Failed DEK wrap and unwrap calls to a provider by error type (throttle, auth, network, canceled, other)",
+		},
+		[]string{"provider", "operation", "type"},
+	)
+
+	ProviderKeyFallbacks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_provider_key_fallbacks_total",
+			Help: "DEK wraps and unwraps served by a fallback key of a provider, e.g. a KMS key replica in another region",
+		},
+		[]string{"provider", "operation"},
+	)
+
+	ProviderDEKCache = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_provider_dek_cache_total",
+			Help: "DEK unwraps answered from the DEK cache (hit) or passed to the provider (miss)",
+		},
+		[]string{"provider", "result"},
+	)
+
+	DEKCacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_dek_cache_evictions_total",
+			Help: "DEKs removed and zeroed in the DEK cache by reason (capacity, expired, cleared)",
+		},
+		[]string{"reason"},
+	)
+
+	DEKCacheEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_dek_cache_entries",
+			Help: "DEKs currently held in the DEK cache",
+		},
+	)
+
+	TenantDEKOperations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_tenant_dek_operations_total",
+			Help: "DEK wraps and unwraps with the provider dedicated to a tenant, by whether tenant isolation allowed them",
+		},
+		[]string{"tenant", "operation", "result"},
+	)
+
+	// Audit log metrics
+	AuditRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_audit_records_total",
+			Help: "Requests appended to the audit log, by whether the write succeeded",
+		},
+		[]string{"result"},
+	)
+
+	AuditSegmentsSealed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_audit_segments_sealed_total",
+			Help: "Audit log segments sealed with a signed Merkle root, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	AuditSinkRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "s3ep_audit_sink_records_total",
+			Help: "Audit records forwarded to a sink, by result (sent, failed, dropped on a full queue)",
+		},
+		[]string{"sink", "result"},
+	)
+
 	// License metrics
 	LicenseInfo = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -146,6 +538,27 @@ var (
 		},
 	)
 
+	LicenseWarningThreshold = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_license_warning_threshold_days",
+			Help: "Smallest license expiry warning threshold reached in days (0 = none)",
+		},
+	)
+
+	LicenseGracePeriod = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_license_grace_period",
+			Help: "1 while the license has expired but is still accepted within the clock skew tolerance and grace period",
+		},
+	)
+
+	LicenseRestricted = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "s3ep_license_restricted",
+			Help: "1 while uploads are rejected because the license expired beyond its grace period",
+		},
+	)
+
 	// Performance metrics for proxy vs direct access
 	ProxyPerformance = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -218,6 +631,20 @@ var (
 	)
 )
 
+// bucketLimiter bounds the bucket label of the S3 operation metrics; nil
+// while per-bucket metrics are disabled
+var bucketLimiter atomic.Pointer[LabelLimiter]
+
+// EnableBucketMetrics turns on per-bucket S3 operation metrics. At most
+// maxBuckets buckets get their own label value, all others are reported as
+// overflow. The returned limiter must be rebalanced periodically (see
+// LabelLimiter.Run) so the busiest buckets keep their own series.
+func EnableBucketMetrics(maxBuckets int, overflow string) *LabelLimiter {
+	limiter := NewLabelLimiter("bucket", maxBuckets, overflow, S3OperationsTotal, S3OperationDuration)
+	bucketLimiter.Store(limiter)
+	return limiter
+}
+
 // SetServerInfo sets server build information
 func SetServerInfo(version, commit, buildTime string) {
 	ServerInfo.WithLabelValues(version, commit, buildTime).Set(1)
@@ -229,6 +656,8 @@ func SetLicenseInfo(licensedTo, company, expiresAt string, valid bool, expiryTim
 	if valid {
 		value = 1
 	}
+	// A reloaded license replaces the previous one
+	LicenseInfo.Reset()
 	LicenseInfo.WithLabelValues(licensedTo, company, expiresAt).Set(value)
 	LicenseExpiryTime.Set(expiryTimestamp)
 
@@ -240,6 +669,22 @@ func SetLicenseInfo(licensedTo, company, expiresAt string, valid bool, expiryTim
 	LicenseDaysRemaining.Set(daysRemaining)
 }
 
+// SetLicenseStatus updates the license metrics after a runtime re-validation
+func SetLicenseStatus(remaining time.Duration, warningThresholdDays int, inGracePeriod, restricted bool) {
+	LicenseDaysRemaining.Set(remaining.Hours() / 24)
+	LicenseWarningThreshold.Set(float64(warningThresholdDays))
+	value := float64(0)
+	if inGracePeriod {
+		value = 1
+	}
+	LicenseGracePeriod.Set(value)
+	value = 0
+	if restricted {
+		value = 1
+	}
+	LicenseRestricted.Set(value)
+}
+
 // SetProviderInfo sets encryption provider information
 func SetProviderInfo(alias, providerType, fingerprint string, isActive bool) {
 	value := float64(0)
@@ -249,6 +694,276 @@ func SetProviderInfo(alias, providerType, fingerprint string, isActive bool) {
 	EncryptionProvidersInfo.WithLabelValues(alias, providerType, fingerprint, prometheusFmtBool(isActive)).Set(value)
 }
 
+// RecordBackendRouteRequest records a backend request sent through a backend route
+func RecordBackendRouteRequest(route, operation string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	BackendRouteRequestsTotal.WithLabelValues(route, operation, status).Inc()
+	BackendRouteRequestDuration.WithLabelValues(route, operation).Observe(duration.Seconds())
+}
+
+// RecordBackendEndpointRequest records a request sent to an endpoint of
+// s3_backend or its replicas
+func RecordBackendEndpointRequest(endpoint, operation string, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	BackendEndpointRequestsTotal.WithLabelValues(endpoint, operation, status).Inc()
+}
+
+// SetBackendEndpointHealth records the result of a backend endpoint health
+// check and the smoothed latency of the endpoint
+func SetBackendEndpointHealth(endpoint string, up bool, latency time.Duration) {
+	value := float64(0)
+	if up {
+		value = 1
+	}
+	BackendEndpointUp.WithLabelValues(endpoint).Set(value)
+	BackendEndpointLatency.WithLabelValues(endpoint).Set(latency.Seconds())
+}
+
+// RecordBackendFailover records a read retried on another endpoint after
+// endpoint failed
+func RecordBackendFailover(endpoint, operation string) {
+	BackendFailoversTotal.WithLabelValues(endpoint, operation).Inc()
+}
+
+// SetReplicationQueueLength records the number of objects waiting for
+// replication
+func SetReplicationQueueLength(length int) {
+	ReplicationQueueLength.Set(float64(length))
+}
+
+// RecordObjectCacheLookup records an object cache lookup
+func RecordObjectCacheLookup(result string) {
+	ObjectCacheLookupsTotal.WithLabelValues(result).Inc()
+}
+
+// SetObjectCacheSize records the bytes held by a tier of the object cache
+func SetObjectCacheSize(tier string, bytes int64) {
+	ObjectCacheBytes.WithLabelValues(tier).Set(float64(bytes))
+}
+
+// RecordReplication records the result of processing an object for
+// replication
+func RecordReplication(result string) {
+	ReplicationObjectsTotal.WithLabelValues(result).Inc()
+}
+
+// RecordNotificationEvents records events delivered to a notification target
+func RecordNotificationEvents(target, result string, events int) {
+	NotificationEventsTotal.WithLabelValues(target, result).Add(float64(events))
+}
+
+// SetBackendRouteUp records the result of a backend route health check
+func SetBackendRouteUp(route string, up bool) {
+	value := float64(0)
+	if up {
+		value = 1
+	}
+	BackendRouteUp.WithLabelValues(route).Set(value)
+}
+
+// RecordCanaryStep records the duration of a single canary step
+func RecordCanaryStep(step string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	CanaryStepDuration.WithLabelValues(step, status).Observe(duration.Seconds())
+}
+
+// RecordCanaryRun records the outcome of a complete canary run
+func RecordCanaryRun(success bool) {
+	if !success {
+		CanaryRunsTotal.WithLabelValues("error").Inc()
+		CanaryUp.Set(0)
+		return
+	}
+	CanaryRunsTotal.WithLabelValues("success").Inc()
+	CanaryUp.Set(1)
+	CanaryLastSuccess.SetToCurrentTime()
+}
+
+// RecordScrubbedObject records the result of one object verified by the
+// integrity scrubber
+func RecordScrubbedObject(result string, plaintextBytes int64, duration time.Duration) {
+	ScrubberObjectsTotal.WithLabelValues(result).Inc()
+	ScrubberBytesTotal.Add(float64(plaintextBytes))
+	ScrubberObjectDuration.Observe(duration.Seconds())
+	if result == "failed" {
+		ScrubberLastFailure.SetToCurrentTime()
+	}
+}
+
+// RecordScrubberSampleError counts a scrubber run that could not sample
+func RecordScrubberSampleError() {
+	ScrubberSampleErrors.Inc()
+}
+
+// RecordMultipartOrphan records an orphan found by the multipart
+// reconciliation and what was done with it
+func RecordMultipartOrphan(kind, action string) {
+	MultipartOrphansTotal.WithLabelValues(kind, action).Inc()
+}
+
+// RecordMultipartReconcileError counts a reconciliation run that could not
+// list the session store or a bucket
+func RecordMultipartReconcileError() {
+	MultipartReconcileErrors.Inc()
+}
+
+// RecordKEKRewrap records the result of one object processed by a KEK
+// rotation job
+func RecordKEKRewrap(result string) {
+	KEKRewrapObjectsTotal.WithLabelValues(result).Inc()
+}
+
+// SetBucketUsage records the usage of one bucket
+func SetBucketUsage(bucket string, objects, plaintextBytes, storedBytes int64) {
+	BucketUsageObjects.WithLabelValues(bucket).Set(float64(objects))
+	BucketUsagePlaintextBytes.WithLabelValues(bucket).Set(float64(plaintextBytes))
+	BucketUsageStoredBytes.WithLabelValues(bucket).Set(float64(storedBytes))
+}
+
+// DeleteBucketUsage removes the usage series of a bucket that is no longer collected
+func DeleteBucketUsage(bucket string) {
+	BucketUsageObjects.DeleteLabelValues(bucket)
+	BucketUsagePlaintextBytes.DeleteLabelValues(bucket)
+	BucketUsageStoredBytes.DeleteLabelValues(bucket)
+}
+
+// RecordUsageCollection records the outcome of a complete usage collection
+func RecordUsageCollection(duration time.Duration, err error) {
+	UsageCollectionDuration.Set(duration.Seconds())
+	if err == nil {
+		UsageCollectionLastSuccess.SetToCurrentTime()
+	}
+}
+
+// RecordBackendTruncation counts a truncated or broken backend GET body;
+// resumed reports whether a ranged re-read recovered it
+func RecordBackendTruncation(resumed bool) {
+	result := "failed"
+	if resumed {
+		result = "resumed"
+	}
+	BackendTruncatedResponses.WithLabelValues(result).Inc()
+}
+
+// RecordBackendETagMismatch counts a backend GET body that did not match its ETag
+func RecordBackendETagMismatch() {
+	BackendETagMismatches.Inc()
+}
+
+// RecordHandlerPanic counts a recovered request handler panic
+func RecordHandlerPanic(method, endpoint string) {
+	HandlerPanics.WithLabelValues(method, endpoint).Inc()
+}
+
+// RecordProviderRateLimitWait records the time a key operation waited for
+// the rate limit of a provider
+func RecordProviderRateLimitWait(provider, operation string, wait time.Duration) {
+	ProviderRateLimitWait.WithLabelValues(provider, operation).Observe(wait.Seconds())
+}
+
+// RecordProviderRateLimitRejected counts a key operation rejected by the rate
+// limit of a provider
+func RecordProviderRateLimitRejected(provider, operation string) {
+	ProviderRateLimitRejected.WithLabelValues(provider, operation).Inc()
+}
+
+// RecordClientRateLimited counts a request rejected by the client rate limits,
+// reason being requests or bandwidth
+func RecordClientRateLimited(reason string) {
+	ClientRateLimited.WithLabelValues(reason).Inc()
+}
+
+// RecordClientBandwidthWait adds the time a body was paced to the bandwidth
+// of its client, direction being upload or download
+func RecordClientBandwidthWait(direction string, wait time.Duration) {
+	ClientBandwidthWait.WithLabelValues(direction).Add(wait.Seconds())
+}
+
+// SetProviderRateLimitQueued sets the number of key operations waiting for the
+// rate limit of a provider
+func SetProviderRateLimitQueued(provider string, queued int64) {
+	ProviderRateLimitQueued.WithLabelValues(provider).Set(float64(queued))
+}
+
+// RecordProviderCoalescedRequest counts a DEK unwrap that shared a concurrent
+// identical call
+func RecordProviderCoalescedRequest(provider string) {
+	ProviderCoalescedRequests.WithLabelValues(provider).Inc()
+}
+
+// RecordProviderDEKOperation records the duration of a DEK wrap or unwrap
+// call to a provider and counts it by errorType if it failed ("" = success)
+func RecordProviderDEKOperation(provider, operation string, duration time.Duration, errorType string) {
+	ProviderDEKDuration.WithLabelValues(provider, operation).Observe(duration.Seconds())
+	if errorType != "" {
+		ProviderDEKErrors.WithLabelValues(provider, operation, errorType).Inc()
+	}
+}
+
+// RecordProviderKeyFallback counts a DEK wrap or unwrap served by a fallback
+// key of a provider
+func RecordProviderKeyFallback(provider, operation string) {
+	ProviderKeyFallbacks.WithLabelValues(provider, operation).Inc()
+}
+
+// RecordProviderDEKCache counts a DEK unwrap served from the DEK cache
+// (hit = true) or passed to the provider
+func RecordProviderDEKCache(provider string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	ProviderDEKCache.WithLabelValues(provider, result).Inc()
+}
+
+// RecordDEKCacheEviction counts a DEK removed from the DEK cache
+func RecordDEKCacheEviction(reason string) {
+	DEKCacheEvictions.WithLabelValues(reason).Inc()
+}
+
+// SetDEKCacheEntries records the number of DEKs in the DEK cache
+func SetDEKCacheEntries(entries int) {
+	DEKCacheEntries.Set(float64(entries))
+}
+
+// RecordTenantDEKOperation counts a DEK wrap or unwrap with the provider of
+// tenant, allowed or denied by tenant isolation
+func RecordTenantDEKOperation(tenant, operation string, allowed bool) {
+	result := "allowed"
+	if !allowed {
+		result = "denied"
+	}
+	TenantDEKOperations.WithLabelValues(tenant, operation, result).Inc()
+}
+
+// RecordAuditRecord counts a request appended to the audit log
+func RecordAuditRecord(err error) {
+	result := "written"
+	if err != nil {
+		result = "failed"
+	}
+	AuditRecords.WithLabelValues(result).Inc()
+}
+
+// RecordAuditSeal counts a sealed audit log segment
+func RecordAuditSeal(reason string) {
+	AuditSegmentsSealed.WithLabelValues(reason).Inc()
+}
+
+// RecordAuditSinkRecords counts records of an audit sink by result
+func RecordAuditSinkRecords(sink, result string, records int) {
+	AuditSinkRecords.WithLabelValues(sink, result).Add(float64(records))
+}
+
 // RecordHMACOperation records HMAC operation metrics
 func RecordHMACOperation(operation, algorithm, policyDecision, contentType string, duration time.Duration, dataSizeMB float64, hmacEnabled bool) {
 	// Count operations
@@ -3,6 +3,7 @@ package monitoring
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -53,11 +54,38 @@ func HTTPMiddleware(next http.Handler) http.Handler {
 
 		RequestsTotal.WithLabelValues(method, endpoint, statusCode).Inc()
 		RequestDuration.WithLabelValues(method, endpoint).Observe(duration)
+
+		// Per-bucket metrics are opt-in, see EnableBucketMetrics
+		if bucketLimiter.Load() != nil {
+			if bucket := mux.Vars(r)["bucket"]; bucket != "" {
+				RecordS3Operation(s3OperationName(method, mux.Vars(r)["key"] != ""), bucket, s3OperationStatus(wrapped.statusCode), time.Since(start))
+			}
+		}
 	})
 }
 
-// S3OperationMetrics records metrics for S3 operations
+// s3OperationName derives a low-cardinality operation label from the request
+func s3OperationName(method string, hasKey bool) string {
+	if hasKey {
+		return strings.ToLower(method) + "_object"
+	}
+	return strings.ToLower(method) + "_bucket"
+}
+
+// s3OperationStatus maps an HTTP status code to the status label
+func s3OperationStatus(statusCode int) string {
+	if statusCode >= 400 {
+		return "error"
+	}
+	return "success"
+}
+
+// S3OperationMetrics records metrics for S3 operations. When bucket metrics
+// are enabled the bucket label is subject to their cardinality limit.
 func RecordS3Operation(operation, bucket, status string, duration time.Duration) {
+	if limiter := bucketLimiter.Load(); limiter != nil {
+		bucket = limiter.Value(bucket)
+	}
 	S3OperationsTotal.WithLabelValues(operation, bucket, status).Inc()
 	S3OperationDuration.WithLabelValues(operation, bucket).Observe(duration.Seconds())
 }
@@ -22,6 +22,10 @@ type Config struct {
 	BindAddress  string
 	MetricsPath  string
 	PprofEnabled bool // Register /debug/pprof handlers on the monitoring mux (admin-only)
+
+	// AdminHandlers are additional admin-only handlers mounted on the
+	// monitoring mux, keyed by ServeMux pattern
+	AdminHandlers map[string]http.Handler
 }
 
 // NewServer creates a new monitoring server
@@ -61,6 +65,10 @@ func NewServer(cfg *Config) *Server {
 		logger.Warn("pprof endpoints enabled on monitoring port — restrict network access to admins")
 	}
 
+	for pattern, handler := range cfg.AdminHandlers {
+		mux.Handle(pattern, handler)
+	}
+
 	httpServer := &http.Server{
 		Addr:        cfg.BindAddress,
 		Handler:     mux,
@@ -0,0 +1,191 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// Target receives events outside the proxy. Send is called with batches of
+// events from a single goroutine per target and must not keep the slice
+// after it returns.
+type Target interface {
+	Send(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Filter selects the events a target gets. Empty fields match everything.
+type Filter struct {
+	Events  []string // Patterns of event names with the s3: prefix, e.g. s3:ObjectCreated:*
+	Buckets []string // Glob patterns of buckets
+	Prefix  string   // Prefix of object keys
+	Suffix  string   // Suffix of object keys
+}
+
+// Matches reports whether the filter selects event
+func (f Filter) Matches(event *Event) bool {
+	if !matchesAny(f.Events, "s3:"+event.EventName) || !matchesAny(f.Buckets, event.S3.Bucket.Name) {
+		return false
+	}
+	return strings.HasPrefix(event.key, f.Prefix) && strings.HasSuffix(event.key, f.Suffix)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetOptions configures how events are handed to a target
+type TargetOptions struct {
+	Name          string        // Used as configurationId of the events, in logs and in metrics
+	Filter        Filter        // Events the target gets
+	QueueSize     int           // Events waiting for the target before new ones are dropped
+	BatchSize     int           // Events per Send
+	FlushInterval time.Duration // Time a partial batch waits for more events
+	Timeout       time.Duration // Time a Send may take
+}
+
+// Emitter forwards events to the targets whose filter matches. Targets get
+// events asynchronously: a slow or unreachable target never delays requests,
+// its events are dropped once its queue is full.
+type Emitter struct {
+	region     string
+	forwarders []*forwarder
+	logger     *logrus.Entry
+}
+
+// NewEmitter creates an emitter without targets; region is the awsRegion of
+// the events
+func NewEmitter(region string, logger *logrus.Entry) *Emitter {
+	return &Emitter{region: region, logger: logger}
+}
+
+// AddTarget starts a goroutine that forwards events to target until Close
+func (e *Emitter) AddTarget(target Target, opts TargetOptions) {
+	f := &forwarder{
+		target: target,
+		opts:   opts,
+		queue:  make(chan Event, opts.QueueSize),
+		done:   make(chan struct{}),
+		logger: e.logger.WithField("target", opts.Name),
+	}
+	e.forwarders = append(e.forwarders, f)
+	go f.run()
+}
+
+// Emit queues event for the targets whose filter matches it
+func (e *Emitter) Emit(event Event) {
+	event.AWSRegion = e.region
+	for _, f := range e.forwarders {
+		if f.opts.Filter.Matches(&event) {
+			targeted := event
+			targeted.S3.ConfigurationID = f.opts.Name
+			f.enqueue(targeted)
+		}
+	}
+}
+
+// Close delivers the queued events and closes the targets
+func (e *Emitter) Close() error {
+	var errs []error
+	for _, f := range e.forwarders {
+		errs = append(errs, f.close())
+	}
+	return errors.Join(errs...)
+}
+
+// forwarder batches the events of one target
+type forwarder struct {
+	target Target
+	opts   TargetOptions
+	queue  chan Event
+	done   chan struct{}
+	logger *logrus.Entry
+
+	mutex  sync.RWMutex // guards closed and sending to queue
+	closed bool
+}
+
+// enqueue queues event, dropping it if the queue is full
+func (f *forwarder) enqueue(event Event) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- event:
+	default:
+		monitoring.RecordNotificationEvents(f.opts.Name, "dropped", 1)
+	}
+}
+
+// run sends batches until the queue is closed
+func (f *forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, f.opts.BatchSize)
+	for {
+		select {
+		case event, ok := <-f.queue:
+			if !ok {
+				f.send(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= f.opts.BatchSize {
+				f.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			f.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// send hands batch to the target
+func (f *forwarder) send(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.opts.Timeout)
+	defer cancel()
+	if err := f.target.Send(ctx, batch); err != nil {
+		monitoring.RecordNotificationEvents(f.opts.Name, "failed", len(batch))
+		f.logger.WithError(err).WithField("events", len(batch)).Error("Failed to deliver event notifications")
+		return
+	}
+	monitoring.RecordNotificationEvents(f.opts.Name, "sent", len(batch))
+}
+
+// close stops accepting events, sends the queued ones and closes the target
+func (f *forwarder) close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.queue)
+	f.mutex.Unlock()
+
+	<-f.done
+	return f.target.Close()
+}
@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTargetOptions(name string, filter Filter) TargetOptions {
+	return TargetOptions{Name: name, Filter: filter, QueueSize: 100, BatchSize: 2, FlushInterval: 10 * time.Millisecond, Timeout: time.Second}
+}
+
+func newTestEmitter() *Emitter {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewEmitter("eu-central-1", logrus.NewEntry(logger))
+}
+
+// recordingTarget keeps the events it gets
+type recordingTarget struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (t *recordingTarget) Send(_ context.Context, events []Event) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events = append(t.events, events...)
+	return nil
+}
+
+func (t *recordingTarget) Close() error { return nil }
+
+func TestNewEvent(t *testing.T) {
+	event := NewEvent(ObjectCreatedPut, "bucket", "dir/a b+c.txt", time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC))
+	assert.Equal(t, "2026-01-02T03:04:05.006Z", event.EventTime)
+	assert.Equal(t, "dir/a+b%2Bc.txt", event.S3.Object.Key)
+	assert.Equal(t, "dir/a b+c.txt", event.Key())
+	assert.Equal(t, "arn:aws:s3:::bucket", event.S3.Bucket.ARN)
+	assert.Len(t, event.S3.Object.Sequencer, 16)
+}
+
+func TestEmitter_Filters(t *testing.T) {
+	emitter := newTestEmitter()
+	all := &recordingTarget{}
+	created := &recordingTarget{}
+	images := &recordingTarget{}
+	emitter.AddTarget(all, testTargetOptions("all", Filter{}))
+	emitter.AddTarget(created, testTargetOptions("created", Filter{Events: []string{"s3:ObjectCreated:*"}, Buckets: []string{"data-*"}}))
+	emitter.AddTarget(images, testTargetOptions("images", Filter{Prefix: "images/", Suffix: ".png"}))
+
+	now := time.Now()
+	emitter.Emit(NewEvent(ObjectCreatedPut, "data-1", "images/a.png", now))
+	emitter.Emit(NewEvent(ObjectRemovedDelete, "data-1", "images/a.png", now))
+	emitter.Emit(NewEvent(ObjectCreatedCopy, "other", "images/a.jpg", now))
+	require.NoError(t, emitter.Close())
+
+	assert.Len(t, all.events, 3)
+	require.Len(t, created.events, 1)
+	assert.Equal(t, ObjectCreatedPut, created.events[0].EventName)
+	assert.Equal(t, "created", created.events[0].S3.ConfigurationID)
+	assert.Equal(t, "eu-central-1", created.events[0].AWSRegion)
+	assert.Len(t, images.events, 2)
+}
+
+func TestEmitter_WebhookTarget(t *testing.T) {
+	var mutex sync.Mutex
+	var messages []Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var message Message
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		mutex.Lock()
+		messages = append(messages, message)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	emitter := newTestEmitter()
+	emitter.AddTarget(NewWebhookTarget(server.URL, map[string]string{"Authorization": "Bearer token"}), testTargetOptions("webhook", Filter{}))
+	for _, key := range []string{"a", "b", "c"} {
+		emitter.Emit(NewEvent(ObjectCreatedPut, "bucket", key, time.Now()))
+	}
+	require.NoError(t, emitter.Close())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var keys []string
+	for _, message := range messages {
+		assert.LessOrEqual(t, len(message.Records), 2)
+		for _, event := range message.Records {
+			assert.Equal(t, "aws:s3", event.EventSource)
+			keys = append(keys, event.S3.Object.Key)
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestEmitter_KafkaTarget(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   string  `json:"key"`
+			Value Message `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/s3-events", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	emitter := newTestEmitter()
+	emitter.AddTarget(NewKafkaTarget(server.URL+"/", "s3-events", nil), testTargetOptions("kafka", Filter{}))
+	emitter.Emit(NewEvent(ObjectRemovedDelete, "bucket", "dir/key", time.Now()))
+	require.NoError(t, emitter.Close())
+
+	require.Len(t, body.Records, 1)
+	assert.Equal(t, "bucket/dir/key", body.Records[0].Key)
+	require.Len(t, body.Records[0].Value.Records, 1)
+	assert.Equal(t, ObjectRemovedDelete, body.Records[0].Value.Records[0].EventName)
+}
+
+func TestSQSTarget(t *testing.T) {
+	var requests []int
+	var failures int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/", r.URL.Path)
+		assert.Equal(t, "AmazonSQS.SendMessageBatch", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/sqs/aws4_request")
+		var batch struct {
+			QueueURL string `json:"QueueUrl"`
+			Entries  []struct {
+				ID          string `json:"Id"`
+				MessageBody string `json:"MessageBody"`
+			} `json:"Entries"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		assert.True(t, strings.HasSuffix(batch.QueueURL, "/123456789012/events"))
+		var message Message
+		assert.NoError(t, json.Unmarshal([]byte(batch.Entries[0].MessageBody), &message))
+		assert.Len(t, message.Records, 1)
+		requests = append(requests, len(batch.Entries))
+		if failures > 0 {
+			failures--
+			_, _ = io.WriteString(w, `{"Failed":[{"Id":"0","Code":"InternalError","Message":"try again","SenderFault":false}]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"Successful":[]}`)
+	}))
+	defer server.Close()
+
+	target, err := NewSQSTarget(server.URL+"/123456789012/events", "eu-west-1", "AKID", "secret")
+	require.NoError(t, err)
+	events := make([]Event, 12)
+	for i := range events {
+		events[i] = NewEvent(ObjectCreatedPut, "bucket", "key", time.Now())
+	}
+	require.NoError(t, target.Send(context.Background(), events))
+	assert.Equal(t, []int{10, 2}, requests, "at most 10 messages per batch")
+
+	failures = 1
+	assert.ErrorContains(t, target.Send(context.Background(), events[:1]), "InternalError")
+
+	_, err = NewSQSTarget("http://localhost:9324/queue/events", "", "AKID", "secret")
+	assert.ErrorContains(t, err, "region")
+	assert.Equal(t, "eu-central-1", SQSRegion("sqs.eu-central-1.amazonaws.com"))
+}
@@ -0,0 +1,102 @@
+// Package notification emits S3 event notifications (s3:ObjectCreated:*,
+// s3:ObjectRemoved:*) for the object operations served by the proxy. The
+// backend behind the proxy only sees ciphertext objects, metadata sidecars and
+// copies; the proxy knows the operations its clients made, so it reports them
+// in the S3 event message format to webhooks, Kafka and SQS.
+package notification
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event names, without the "s3:" prefix of event filters
+const (
+	ObjectCreatedPut                     = "ObjectCreated:Put"
+	ObjectCreatedCopy                    = "ObjectCreated:Copy"
+	ObjectCreatedCompleteMultipartUpload = "ObjectCreated:CompleteMultipartUpload"
+	ObjectRemovedDelete                  = "ObjectRemoved:Delete"
+	ObjectRemovedDeleteMarkerCreated     = "ObjectRemoved:DeleteMarkerCreated"
+)
+
+// Event is a record of an S3 event message
+type Event struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AWSRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters RequestParameters `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                Entity            `json:"s3"`
+
+	key string // the object key, S3.Object.Key is URL-encoded
+}
+
+// Identity is the access key that made the request
+type Identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+// RequestParameters describe the client of the request
+type RequestParameters struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+// Entity is the bucket and object of an event
+type Entity struct {
+	SchemaVersion   string `json:"s3SchemaVersion"`
+	ConfigurationID string `json:"configurationId"` // The name of the target
+	Bucket          Bucket `json:"bucket"`
+	Object          Object `json:"object"`
+}
+
+// Bucket is the bucket of an event
+type Bucket struct {
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+}
+
+// Object is the object of an event. Size is the plaintext size; it is only
+// known for PutObject.
+type Object struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// Message is the body delivered to targets
+type Message struct {
+	Records []Event `json:"Records"`
+}
+
+// NewEvent creates the event name of an object that happened at t
+func NewEvent(name, bucket, key string, t time.Time) Event {
+	return Event{
+		EventVersion:     "2.1",
+		EventSource:      "aws:s3",
+		EventTime:        t.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:        name,
+		ResponseElements: map[string]string{},
+		S3: Entity{
+			SchemaVersion: "1.0",
+			Bucket:        Bucket{Name: bucket, ARN: "arn:aws:s3:::" + bucket},
+			Object: Object{
+				// Like S3, keys are form-encoded with their slashes kept
+				Key:       strings.ReplaceAll(url.QueryEscape(key), "%2F", "/"),
+				Sequencer: fmt.Sprintf("%016X", t.UnixNano()),
+			},
+		},
+		key: key,
+	}
+}
+
+// Key returns the object key of the event
+func (e Event) Key() string {
+	return e.key
+}
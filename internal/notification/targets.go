@@ -0,0 +1,228 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/kafkarest"
+)
+
+// WebhookTarget POSTs batches of events as one S3 event message to a URL
+type WebhookTarget struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookTarget creates a target posting to url with the extra headers,
+// e.g. an Authorization header
+func NewWebhookTarget(url string, headers map[string]string) *WebhookTarget {
+	return &WebhookTarget{url: url, headers: headers, client: &http.Client{}}
+}
+
+// Send implements Target
+func (t *WebhookTarget) Send(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(Message{Records: events})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	return post(ctx, t.client, t.url, "application/json", t.headers, body)
+}
+
+// Close implements Target
+func (t *WebhookTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// KafkaTarget produces one S3 event message per event to a Kafka topic
+// through a Kafka REST Proxy, keyed by bucket and key so the events of an
+// object stay in order on one partition
+type KafkaTarget struct {
+	producer *kafkarest.Producer[Message]
+}
+
+// NewKafkaTarget creates a target producing to topic through the REST Proxy
+// at restURL
+func NewKafkaTarget(restURL, topic string, headers map[string]string) *KafkaTarget {
+	return &KafkaTarget{producer: kafkarest.NewProducer[Message](restURL, topic, headers)}
+}
+
+// Send implements Target
+func (t *KafkaTarget) Send(ctx context.Context, events []Event) error {
+	batch := make([]kafkarest.Record[Message], 0, len(events))
+	for _, event := range events {
+		batch = append(batch, kafkarest.Record[Message]{
+			Key:   event.S3.Bucket.Name + "/" + event.key,
+			Value: Message{Records: []Event{event}},
+		})
+	}
+	return t.producer.Produce(ctx, batch)
+}
+
+// Close implements Target
+func (t *KafkaTarget) Close() error {
+	t.producer.Close()
+	return nil
+}
+
+// sqsMaxBatch is the most messages SendMessageBatch takes
+const sqsMaxBatch = 10
+
+// SQSTarget sends one S3 event message per event to an SQS queue, with
+// SendMessageBatch requests of the SQS JSON protocol signed with Signature V4
+type SQSTarget struct {
+	queueURL    string
+	endpoint    string
+	region      string
+	credentials aws.Credentials
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// NewSQSTarget creates a target sending to the queue at queueURL, e.g.
+// https://sqs.eu-central-1.amazonaws.com/123456789012/events. An empty
+// region is taken from an AWS queue URL.
+func NewSQSTarget(queueURL, region, accessKeyID, secretKey string) (*SQSTarget, error) {
+	parsed, err := url.Parse(queueURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid SQS queue URL: %s", queueURL)
+	}
+	if region == "" {
+		region = SQSRegion(parsed.Host)
+	}
+	if region == "" {
+		return nil, fmt.Errorf("the region of SQS queue %s is unknown", queueURL)
+	}
+	return &SQSTarget{
+		queueURL:    queueURL,
+		endpoint:    parsed.Scheme + "://" + parsed.Host + "/",
+		region:      region,
+		credentials: aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretKey},
+		signer:      v4.NewSigner(),
+		client:      &http.Client{},
+	}, nil
+}
+
+// SQSRegion returns the region of an AWS SQS endpoint host like
+// sqs.eu-central-1.amazonaws.com, or ""
+func SQSRegion(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 4 && parts[0] == "sqs" && parts[2] == "amazonaws" {
+		return parts[1]
+	}
+	return ""
+}
+
+type sqsBatchEntry struct {
+	ID          string `json:"Id"`
+	MessageBody string `json:"MessageBody"`
+}
+
+type sqsBatchResult struct {
+	Failed []struct {
+		ID      string `json:"Id"`
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	} `json:"Failed"`
+}
+
+// Send implements Target
+func (t *SQSTarget) Send(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += sqsMaxBatch {
+		end := min(start+sqsMaxBatch, len(events))
+		if err := t.sendBatch(ctx, events[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SQSTarget) sendBatch(ctx context.Context, events []Event) error {
+	entries := make([]sqsBatchEntry, 0, len(events))
+	for i, event := range events {
+		message, err := json.Marshal(Message{Records: []Event{event}})
+		if err != nil {
+			return fmt.Errorf("failed to encode events: %w", err)
+		}
+		entries = append(entries, sqsBatchEntry{ID: strconv.Itoa(i), MessageBody: string(message)})
+	}
+	body, err := json.Marshal(struct {
+		QueueURL string          `json:"QueueUrl"`
+		Entries  []sqsBatchEntry `json:"Entries"`
+	}{QueueURL: t.queueURL, Entries: entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS.SendMessageBatch")
+	hash := sha256.Sum256(body)
+	if err := t.signer.SignHTTP(ctx, t.credentials, req, hex.EncodeToString(hash[:]), "sqs", t.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign SQS request: %w", err)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send events to SQS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send events to SQS: %s answered %s: %s", t.endpoint, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var result sqsBatchResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to decode SQS response: %w", err)
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("SQS rejected %d of %d events: %s: %s", len(result.Failed), len(events), result.Failed[0].Code, result.Failed[0].Message)
+	}
+	return nil
+}
+
+// Close implements Target
+func (t *SQSTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// post posts body and fails unless the response is a 2xx
+func post(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post events: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post events: %s answered %s", url, resp.Status)
+	}
+	return nil
+}
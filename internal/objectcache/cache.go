@@ -0,0 +1,298 @@
+// Package objectcache keeps recently served, decrypted object responses in
+// memory and optionally on disk, so repeated reads of small hot objects skip
+// the backend and decryption.
+package objectcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// fileSuffix marks the files of the disk tier, which are removed on start
+const fileSuffix = ".s3ep-cache"
+
+// Config holds object cache configuration
+type Config struct {
+	TTL           time.Duration // Lifetime of an entry
+	MaxObjectSize int64         // Largest cached body in bytes
+	MemorySize    int64         // Bytes of bodies kept in memory, 0 = no memory tier
+	DiskDirectory string        // Directory of the disk tier, "" = no disk tier
+	DiskSize      int64         // Bytes of bodies kept on disk
+}
+
+// Object is a cached response
+type Object struct {
+	Header http.Header // Response headers set by the handler
+	Size   int64
+}
+
+type entry struct {
+	Object
+	id      string // bucket/key
+	variant string // client, when entries are kept per client
+	expires time.Time
+	body    []byte // memory tier
+	file    string // disk tier
+	element *list.Element
+}
+
+// Fill is a response being captured for the cache. It is only stored if its
+// object was not invalidated since the fill began.
+type Fill struct {
+	cache       *Cache
+	id, variant string
+	invalidated bool
+}
+
+// Cache is an LRU cache of object responses, bounded by the bytes of their
+// bodies per tier. Entries leaving the memory tier move to the disk tier.
+type Cache struct {
+	config Config
+	logger *logrus.Entry
+	now    func() time.Time
+
+	mu         sync.Mutex
+	objects    map[string]map[string]*entry // by id, then variant
+	memory     *list.List                   // most recently used first
+	disk       *list.List
+	memoryUsed int64
+	diskUsed   int64
+	fills      map[string][]*Fill // in flight, by id
+}
+
+// New creates a cache. Files left in the disk directory by a previous run
+// are removed, as their objects may have changed since.
+func New(cfg Config, logger *logrus.Entry) (*Cache, error) {
+	if cfg.DiskDirectory != "" {
+		if err := os.MkdirAll(cfg.DiskDirectory, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		stale, err := filepath.Glob(filepath.Join(cfg.DiskDirectory, "*"+fileSuffix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cache directory: %w", err)
+		}
+		for _, file := range stale {
+			if err := os.Remove(file); err != nil {
+				return nil, fmt.Errorf("failed to clear cache directory: %w", err)
+			}
+		}
+	}
+	return &Cache{
+		config:  cfg,
+		logger:  logger,
+		now:     time.Now,
+		objects: make(map[string]map[string]*entry),
+		memory:  list.New(),
+		disk:    list.New(),
+		fills:   make(map[string][]*Fill),
+	}, nil
+}
+
+// MaxObjectSize returns the size of the largest body the cache stores
+func (c *Cache) MaxObjectSize() int64 {
+	return c.config.MaxObjectSize
+}
+
+func objectID(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// Get returns the cached response of an object and a reader of its body
+func (c *Cache) Get(bucket, key, variant string) (*Object, io.ReadCloser, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.objects[objectID(bucket, key)][variant]
+	if e == nil {
+		monitoring.RecordObjectCacheLookup("miss")
+		return nil, nil, false
+	}
+	if !c.now().Before(e.expires) {
+		c.remove(e)
+		monitoring.RecordObjectCacheLookup("miss")
+		return nil, nil, false
+	}
+
+	if e.file == "" {
+		c.memory.MoveToFront(e.element)
+		monitoring.RecordObjectCacheLookup("memory")
+		return &e.Object, io.NopCloser(bytes.NewReader(e.body)), true
+	}
+	// An open file stays readable when the entry is evicted meanwhile
+	f, err := os.Open(e.file)
+	if err != nil {
+		c.logger.WithError(err).Warn("Failed to read cached object")
+		c.remove(e)
+		monitoring.RecordObjectCacheLookup("miss")
+		return nil, nil, false
+	}
+	c.disk.MoveToFront(e.element)
+	monitoring.RecordObjectCacheLookup("disk")
+	return &e.Object, f, true
+}
+
+// Begin starts capturing a response of an object
+func (c *Cache) Begin(bucket, key, variant string) *Fill {
+	fill := &Fill{cache: c, id: objectID(bucket, key), variant: variant}
+	c.mu.Lock()
+	c.fills[fill.id] = append(c.fills[fill.id], fill)
+	c.mu.Unlock()
+	return fill
+}
+
+// Commit stores the captured response, unless its object was invalidated
+// since Begin. A nil body ends the fill without storing anything.
+func (f *Fill) Commit(object Object, body []byte) {
+	c := f.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fills := c.fills[f.id]
+	for i, fill := range fills {
+		if fill == f {
+			fills = append(fills[:i], fills[i+1:]...)
+			break
+		}
+	}
+	if len(fills) == 0 {
+		delete(c.fills, f.id)
+	} else {
+		c.fills[f.id] = fills
+	}
+
+	if f.invalidated || body == nil || int64(len(body)) > c.config.MaxObjectSize {
+		return
+	}
+	if previous := c.objects[f.id][f.variant]; previous != nil {
+		c.remove(previous)
+	}
+	object.Size = int64(len(body))
+	e := &entry{Object: object, id: f.id, variant: f.variant, expires: c.now().Add(c.config.TTL), body: body}
+	if c.objects[f.id] == nil {
+		c.objects[f.id] = make(map[string]*entry)
+	}
+	c.objects[f.id][f.variant] = e
+
+	if c.config.MemorySize > 0 {
+		e.element = c.memory.PushFront(e)
+		c.memoryUsed += e.Size
+		for c.memoryUsed > c.config.MemorySize {
+			c.spill(c.memory.Back().Value.(*entry))
+		}
+	} else {
+		c.spill(e)
+	}
+	monitoring.SetObjectCacheSize("memory", c.memoryUsed)
+	monitoring.SetObjectCacheSize("disk", c.diskUsed)
+}
+
+// spill moves an entry from the memory tier to the disk tier, or drops it
+// without one
+func (c *Cache) spill(e *entry) {
+	if e.element != nil {
+		c.memory.Remove(e.element)
+		c.memoryUsed -= e.Size
+		e.element = nil
+	}
+	if c.config.DiskDirectory == "" || e.Size > c.config.DiskSize {
+		c.forget(e)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(e.variant + "\x00" + e.id))
+	e.file = filepath.Join(c.config.DiskDirectory, hex.EncodeToString(sum[:])+fileSuffix)
+	if err := os.WriteFile(e.file, e.body, 0o600); err != nil {
+		c.logger.WithError(err).Warn("Failed to write cached object to disk")
+		_ = os.Remove(e.file)
+		c.forget(e)
+		return
+	}
+	e.body = nil
+	e.element = c.disk.PushFront(e)
+	c.diskUsed += e.Size
+	for c.diskUsed > c.config.DiskSize {
+		c.remove(c.disk.Back().Value.(*entry))
+	}
+}
+
+// remove drops an entry from its tier
+func (c *Cache) remove(e *entry) {
+	if e.element != nil {
+		if e.file == "" {
+			c.memory.Remove(e.element)
+			c.memoryUsed -= e.Size
+		} else {
+			c.disk.Remove(e.element)
+			c.diskUsed -= e.Size
+			if err := os.Remove(e.file); err != nil && !os.IsNotExist(err) {
+				c.logger.WithError(err).Warn("Failed to remove cached object from disk")
+			}
+		}
+		e.element = nil
+	}
+	c.forget(e)
+}
+
+// forget drops an entry from the index
+func (c *Cache) forget(e *entry) {
+	variants := c.objects[e.id]
+	if variants[e.variant] == e {
+		delete(variants, e.variant)
+		if len(variants) == 0 {
+			delete(c.objects, e.id)
+		}
+	}
+}
+
+// Invalidate drops every cached response of an object, and keeps responses
+// being captured for it from being stored
+func (c *Cache) Invalidate(bucket, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidate(objectID(bucket, key))
+	monitoring.SetObjectCacheSize("memory", c.memoryUsed)
+	monitoring.SetObjectCacheSize("disk", c.diskUsed)
+}
+
+// InvalidateBucket drops the cached responses of every object of a bucket
+func (c *Cache) InvalidateBucket(bucket string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := bucket + "/"
+	for id := range c.objects {
+		if strings.HasPrefix(id, prefix) {
+			c.invalidate(id)
+		}
+	}
+	for id := range c.fills {
+		if strings.HasPrefix(id, prefix) {
+			c.invalidate(id)
+		}
+	}
+	monitoring.SetObjectCacheSize("memory", c.memoryUsed)
+	monitoring.SetObjectCacheSize("disk", c.diskUsed)
+}
+
+func (c *Cache) invalidate(id string) {
+	for _, e := range c.objects[id] {
+		c.remove(e)
+	}
+	for _, fill := range c.fills[id] {
+		fill.invalidated = true
+	}
+}
@@ -0,0 +1,116 @@
+package objectcache
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T, cfg Config) *Cache {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cache, err := New(cfg, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	return cache
+}
+
+func store(cache *Cache, key, variant, body string) {
+	cache.Begin("bucket", key, variant).Commit(Object{Header: http.Header{"Content-Type": {"text/plain"}}}, []byte(body))
+}
+
+// cached returns the cached body of key, or "" on a miss
+func cached(t *testing.T, cache *Cache, key, variant string) string {
+	t.Helper()
+	object, body, ok := cache.Get("bucket", key, variant)
+	if !ok {
+		return ""
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), object.Size)
+	assert.Equal(t, "text/plain", object.Header.Get("Content-Type"))
+	return string(data)
+}
+
+func TestCache_TTLAndVariants(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTestCache(t, Config{TTL: time.Minute, MaxObjectSize: 10, MemorySize: 100})
+	cache.now = func() time.Time { return now }
+
+	store(cache, "a", "", "alpha")
+	store(cache, "a", "client-1", "alpha-1")
+	store(cache, "large", "", "01234567890")
+	assert.Equal(t, "alpha", cached(t, cache, "a", ""))
+	assert.Equal(t, "alpha-1", cached(t, cache, "a", "client-1"))
+	assert.Empty(t, cached(t, cache, "a", "client-2"))
+	assert.Empty(t, cached(t, cache, "large", ""), "above max_object_size")
+
+	now = now.Add(time.Minute)
+	assert.Empty(t, cached(t, cache, "a", ""))
+	assert.Equal(t, int64(len("alpha-1")), cache.memoryUsed, "expired entries are removed on lookup")
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	cache := newTestCache(t, Config{TTL: time.Minute, MaxObjectSize: 10, MemorySize: 100})
+
+	store(cache, "a", "", "alpha")
+	store(cache, "a", "client-1", "alpha-1")
+	store(cache, "b", "", "beta")
+	cache.Invalidate("bucket", "a")
+	assert.Empty(t, cached(t, cache, "a", ""))
+	assert.Empty(t, cached(t, cache, "a", "client-1"))
+	assert.Equal(t, "beta", cached(t, cache, "b", ""))
+
+	// A read racing a write is not stored
+	fill := cache.Begin("bucket", "a", "")
+	cache.Invalidate("bucket", "a")
+	fill.Commit(Object{}, []byte("stale"))
+	assert.Empty(t, cached(t, cache, "a", ""))
+	assert.Empty(t, cache.fills)
+
+	fill = cache.Begin("bucket", "c", "")
+	cache.InvalidateBucket("bucket")
+	fill.Commit(Object{}, []byte("stale"))
+	assert.Empty(t, cached(t, cache, "b", ""))
+	assert.Empty(t, cached(t, cache, "c", ""))
+	assert.Zero(t, cache.memoryUsed)
+}
+
+func TestCache_SpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, "old"+fileSuffix)
+	require.NoError(t, os.WriteFile(leftover, []byte("old"), 0o600))
+	cache := newTestCache(t, Config{TTL: time.Minute, MaxObjectSize: 4, MemorySize: 8, DiskDirectory: dir, DiskSize: 8})
+	assert.NoFileExists(t, leftover, "files of a previous run are removed")
+
+	store(cache, "a", "", "aaaa")
+	store(cache, "b", "", "bbbb")
+	store(cache, "c", "", "cccc")
+	assert.Equal(t, int64(8), cache.memoryUsed)
+	assert.Equal(t, int64(4), cache.diskUsed)
+	assert.Equal(t, "aaaa", cached(t, cache, "a", ""), "served from disk")
+
+	// The disk tier evicts its least recently used entry
+	store(cache, "d", "", "dddd")
+	store(cache, "e", "", "eeee")
+	assert.Empty(t, cached(t, cache, "a", ""))
+	assert.Equal(t, "bbbb", cached(t, cache, "b", ""))
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	cache.InvalidateBucket("bucket")
+	files, err = filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Zero(t, cache.diskUsed)
+}
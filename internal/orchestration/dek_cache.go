@@ -0,0 +1,403 @@
+package orchestration
+
+import (
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// defaultDEKCacheSize bounds the LRU of decrypted DEKs. Entries are tiny (~32 B
+// of key material plus map overhead), so the absolute memory cost at the bound
+// is negligible — the bound exists to stop unbounded growth on long-running
+// proxies that touch many distinct objects.
+const defaultDEKCacheSize = 1024
+
+// dekCacheFileVersion is the format version of a persisted DEK cache
+const dekCacheFileVersion = 1
+
+// Reasons a DEK leaves the cache, as reported in s3ep_dek_cache_evictions_total
+const (
+	dekEvictedCapacity = "capacity"
+	dekEvictedExpired  = "expired"
+	dekEvictedCleared  = "cleared"
+)
+
+type dekCacheEntry struct {
+	key         string
+	fingerprint string
+	dek         []byte
+	expires     time.Time // zero without TTL
+}
+
+// dekCache is a bounded LRU of decrypted DEKs with an optional TTL. It owns
+// the key material it stores: DEKs are copied in and out, and zeroed when
+// they are evicted, expire or are replaced.
+type dekCache struct {
+	mutex    sync.Mutex
+	capacity int // 0 disables the cache
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List // front = most recently used
+	now      func() time.Time
+}
+
+// newDEKCacheFromConfig creates the cache configured in
+// optimizations.dek_cache
+func newDEKCacheFromConfig(cfg config.DEKCacheConfig) *dekCache {
+	if !cfg.IsEnabled() {
+		return newDEKCache(0, 0)
+	}
+	capacity := cfg.Size
+	if capacity <= 0 {
+		capacity = defaultDEKCacheSize
+	}
+	return newDEKCache(capacity, time.Duration(cfg.TTL)*time.Second)
+}
+
+func newDEKCache(capacity int, ttl time.Duration) *dekCache {
+	return &dekCache{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// get returns a copy of the cached DEK and promotes the entry to MRU. An
+// expired entry is removed and reported as a miss.
+func (c *dekCache) get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*dekCacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(elem, dekEvictedExpired)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return append([]byte(nil), entry.dek...), true
+}
+
+// put inserts (or refreshes) an entry and evicts the LRU entries beyond
+// capacity. The DEK is copied, so the caller keeps ownership of its slice.
+func (c *dekCache) put(key, fingerprint string, dek []byte) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+	c.insert(&dekCacheEntry{key: key, fingerprint: fingerprint, dek: append([]byte(nil), dek...), expires: expires})
+}
+
+func (c *dekCache) insert(entry *dekCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.capacity <= 0 {
+		clear(entry.dek)
+		return
+	}
+	if elem, ok := c.items[entry.key]; ok {
+		old := elem.Value.(*dekCacheEntry)
+		clear(old.dek)
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back(), dekEvictedCapacity)
+	}
+	monitoring.SetDEKCacheEntries(c.order.Len())
+}
+
+// remove drops an entry and zeroes its DEK. The caller must hold c.mutex.
+func (c *dekCache) remove(elem *list.Element, reason string) {
+	entry := elem.Value.(*dekCacheEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	clear(entry.dek)
+	monitoring.RecordDEKCacheEviction(reason)
+	monitoring.SetDEKCacheEntries(c.order.Len())
+}
+
+// clear zeroes and drops every entry and returns how many there were
+func (c *dekCache) clear() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n := c.order.Len()
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		c.remove(elem, dekEvictedCleared)
+		elem = next
+	}
+	return n
+}
+
+// len returns the number of cached entries
+func (c *dekCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// snapshot returns copies of the unexpired entries, least recently used
+// first, so re-inserting them in order restores the LRU order
+func (c *dekCache) snapshot() []*dekCacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	entries := make([]*dekCacheEntry, 0, c.order.Len())
+	for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*dekCacheEntry)
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			continue
+		}
+		copied := *entry
+		copied.dek = append([]byte(nil), entry.dek...)
+		entries = append(entries, &copied)
+	}
+	return entries
+}
+
+// dekCacheFile is a persisted DEK cache. The entries of each provider are
+// sealed with a fresh file key wrapped by that provider, so loading them
+// takes one unwrap per provider and keeps tenant providers apart.
+type dekCacheFile struct {
+	Version int                 `json:"version"`
+	Groups  []dekCacheFileGroup `json:"groups"`
+}
+
+type dekCacheFileGroup struct {
+	Fingerprint string `json:"fingerprint"`
+	WrappedKey  []byte `json:"wrapped_key"`
+	Sealed      []byte `json:"sealed"` // nonce || AES-256-GCM of the JSON encoded entries
+}
+
+type dekCacheFileEntry struct {
+	Key     string    `json:"key"`
+	DEK     []byte    `json:"dek"`
+	Expires time.Time `json:"expires,omitzero"`
+	Order   int       `json:"order"` // position from least recently used
+}
+
+// dekCacheFileAAD binds a sealed group to the file version and provider
+func dekCacheFileAAD(fingerprint string) []byte {
+	return fmt.Appendf(nil, "s3ep-dek-cache-v%d:%s", dekCacheFileVersion, fingerprint)
+}
+
+// dekWrapper wraps and unwraps the file keys of a persisted cache
+type dekWrapper interface {
+	wrapKey(ctx context.Context, fingerprint string, key []byte) ([]byte, error)
+	unwrapKey(ctx context.Context, fingerprint string, wrapped []byte) ([]byte, error)
+}
+
+// save writes the unexpired entries to path, replacing it atomically. It
+// returns the number of entries written; providers whose file key cannot be
+// wrapped are left out.
+func (c *dekCache) save(ctx context.Context, path string, wrapper dekWrapper) (int, error) {
+	entries := c.snapshot()
+	defer func() {
+		for _, entry := range entries {
+			clear(entry.dek)
+		}
+	}()
+
+	byProvider := make(map[string][]dekCacheFileEntry)
+	for i, entry := range entries {
+		byProvider[entry.fingerprint] = append(byProvider[entry.fingerprint], dekCacheFileEntry{
+			Key: entry.key, DEK: entry.dek, Expires: entry.expires, Order: i,
+		})
+	}
+	fingerprints := make([]string, 0, len(byProvider))
+	for fingerprint := range byProvider {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	file := dekCacheFile{Version: dekCacheFileVersion}
+	var saved int
+	var errs []error
+	for _, fingerprint := range fingerprints {
+		group, err := sealDEKCacheGroup(ctx, fingerprint, byProvider[fingerprint], wrapper)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provider %s: %w", fingerprint, err))
+			continue
+		}
+		file.Groups = append(file.Groups, *group)
+		saved += len(byProvider[fingerprint])
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return 0, err
+	}
+	return saved, errors.Join(errs...)
+}
+
+func sealDEKCacheGroup(ctx context.Context, fingerprint string, entries []dekCacheFileEntry, wrapper dekWrapper) (*dekCacheFileGroup, error) {
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+
+	fileKey := make([]byte, 32)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	defer clear(fileKey)
+	wrapped, err := wrapper.wrapKey(ctx, fingerprint, fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap cache key: %w", err)
+	}
+
+	aead, err := dekCacheAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, dekCacheFileAAD(fingerprint))
+	return &dekCacheFileGroup{Fingerprint: fingerprint, WrappedKey: wrapped, Sealed: sealed}, nil
+}
+
+// load reads the entries saved to path and removes the file, so a cache is
+// restored at most once. Expired entries and providers whose file key cannot
+// be unwrapped are skipped. It returns the number of entries restored.
+func (c *dekCache) load(ctx context.Context, path string, wrapper dekWrapper) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("failed to remove loaded DEK cache: %w", err)
+	}
+
+	var file dekCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("failed to decode DEK cache: %w", err)
+	}
+	if file.Version != dekCacheFileVersion {
+		return 0, fmt.Errorf("unsupported DEK cache version %d", file.Version)
+	}
+
+	var restored []*dekCacheEntry
+	var order []int
+	var errs []error
+	for _, group := range file.Groups {
+		entries, err := openDEKCacheGroup(ctx, group, wrapper)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provider %s: %w", group.Fingerprint, err))
+			continue
+		}
+		for _, entry := range entries {
+			restored = append(restored, &dekCacheEntry{key: entry.Key, fingerprint: group.Fingerprint, dek: entry.DEK, expires: entry.Expires})
+			order = append(order, entry.Order)
+		}
+	}
+
+	// Least recently used first, so the most recent entries end up in front
+	indexes := make([]int, len(restored))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Slice(indexes, func(a, b int) bool { return order[indexes[a]] < order[indexes[b]] })
+
+	now := c.now()
+	var loaded int
+	for _, i := range indexes {
+		entry := restored[i]
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			clear(entry.dek)
+			continue
+		}
+		c.insert(entry)
+		loaded++
+	}
+	return loaded, errors.Join(errs...)
+}
+
+func openDEKCacheGroup(ctx context.Context, group dekCacheFileGroup, wrapper dekWrapper) ([]dekCacheFileEntry, error) {
+	fileKey, err := wrapper.unwrapKey(ctx, group.Fingerprint, group.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap cache key: %w", err)
+	}
+	defer clear(fileKey)
+
+	aead, err := dekCacheAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(group.Sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed entries are truncated")
+	}
+	nonce, ciphertext := group.Sealed[:aead.NonceSize()], group.Sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, dekCacheFileAAD(group.Fingerprint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entries: %w", err)
+	}
+	defer clear(plaintext)
+
+	var entries []dekCacheFileEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode entries: %w", err)
+	}
+	return entries, nil
+}
+
+func dekCacheAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes data to a temporary file readable only by the owner
+// and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
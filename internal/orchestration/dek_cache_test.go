@@ -0,0 +1,181 @@
+package orchestration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// xorWrapper wraps file keys by XOR with a per-provider byte; providers in
+// fail cannot unwrap
+type xorWrapper struct {
+	fail map[string]bool
+}
+
+func (w xorWrapper) wrapKey(_ context.Context, fingerprint string, key []byte) ([]byte, error) {
+	return xorKey(fingerprint, key), nil
+}
+
+func (w xorWrapper) unwrapKey(_ context.Context, fingerprint string, wrapped []byte) ([]byte, error) {
+	if w.fail[fingerprint] {
+		return nil, errors.New("provider unavailable")
+	}
+	return xorKey(fingerprint, wrapped), nil
+}
+
+func xorKey(fingerprint string, key []byte) []byte {
+	out := make([]byte, len(key))
+	for i := range key {
+		out[i] = key[i] ^ fingerprint[0]
+	}
+	return out
+}
+
+// TestDEKCache_LRUEviction verifies that the bounded LRU evicts the
+// least-recently-used entry once the cache exceeds its capacity, so
+// long-running proxies cannot grow the DEK cache without bound.
+func TestDEKCache_LRUEviction(t *testing.T) {
+	cache := newDEKCache(defaultDEKCacheSize, 0)
+
+	// Fill exactly to capacity.
+	for i := 0; i < defaultDEKCacheSize; i++ {
+		cache.put(fmt.Sprintf("key-%d", i), "fp", []byte{byte(i)})
+	}
+	require.Equal(t, defaultDEKCacheSize, cache.len())
+
+	// Touch the oldest entry so it becomes MRU.
+	_, ok := cache.get("key-0")
+	require.True(t, ok)
+
+	// Insert one more entry — this should evict the now-oldest, which is key-1.
+	cache.put("key-new", "fp", []byte{0xff})
+	require.Equal(t, defaultDEKCacheSize, cache.len())
+
+	_, ok = cache.get("key-1")
+	assert.False(t, ok, "expected key-1 to be evicted (LRU)")
+	_, ok = cache.get("key-0")
+	assert.True(t, ok, "expected key-0 to survive eviction (recently touched)")
+	_, ok = cache.get("key-new")
+	assert.True(t, ok, "expected freshly inserted key-new to be cached")
+}
+
+func TestDEKCache_TTL(t *testing.T) {
+	cache := newDEKCache(4, time.Minute)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", "fp", []byte{1})
+	now = now.Add(59 * time.Second)
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Zero(t, cache.len(), "expired entries are removed on access")
+}
+
+func TestDEKCache_Zeroization(t *testing.T) {
+	cache := newDEKCache(1, 0)
+	cache.put("a", "fp", []byte{1, 2, 3})
+	stored := cache.items["a"].Value.(*dekCacheEntry).dek
+
+	got, ok := cache.get("a")
+	require.True(t, ok)
+	got[0] = 9
+	again, _ := cache.get("a")
+	assert.Equal(t, []byte{1, 2, 3}, again, "callers get a copy")
+
+	cache.put("b", "fp", []byte{4})
+	assert.Equal(t, []byte{0, 0, 0}, stored, "evicted DEKs are zeroed")
+
+	stored = cache.items["b"].Value.(*dekCacheEntry).dek
+	assert.Equal(t, 1, cache.clear())
+	assert.Equal(t, []byte{0}, stored, "cleared DEKs are zeroed")
+}
+
+func TestDEKCache_Disabled(t *testing.T) {
+	disabled := false
+	cache := newDEKCacheFromConfig(config.DEKCacheConfig{Enabled: &disabled})
+	cache.put("a", "fp", []byte{1})
+	_, ok := cache.get("a")
+	assert.False(t, ok)
+
+	assert.Equal(t, defaultDEKCacheSize, newDEKCacheFromConfig(config.DEKCacheConfig{}).capacity)
+}
+
+func TestDEKCache_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dek-cache")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cache := newDEKCache(8, time.Hour)
+	cache.now = func() time.Time { return now }
+	cache.put("a", "fp-a", []byte("dek-a"))
+	cache.put("b", "fp-b", []byte("dek-b"))
+	cache.put("c", "fp-a", []byte("dek-c"))
+	cache.get("a") // LRU order is now b, c, a
+
+	saved, err := cache.save(ctx, path, xorWrapper{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, saved)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("dek-")), "DEKs must not be stored in the clear")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	restored := newDEKCache(8, time.Hour)
+	restored.now = func() time.Time { return now.Add(time.Minute) }
+	loaded, err := restored.load(ctx, path, xorWrapper{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded)
+	var keys []string
+	for _, entry := range restored.snapshot() {
+		keys = append(keys, entry.key)
+	}
+	assert.Equal(t, []string{"b", "c", "a"}, keys)
+	dek, ok := restored.get("c")
+	require.True(t, ok)
+	assert.Equal(t, []byte("dek-c"), dek)
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the file is removed once loaded")
+	_, err = restored.load(ctx, path, xorWrapper{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDEKCache_PersistenceSkipsUnavailableProvidersAndExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dek-cache")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cache := newDEKCache(8, time.Hour)
+	cache.now = func() time.Time { return now }
+	cache.put("a", "fp-a", []byte("dek-a"))
+	cache.put("b", "fp-b", []byte("dek-b"))
+	now = now.Add(30 * time.Minute)
+	cache.put("c", "fp-b", []byte("dek-c"))
+	_, err := cache.save(ctx, path, xorWrapper{})
+	require.NoError(t, err)
+
+	restored := newDEKCache(8, time.Hour)
+	restored.now = func() time.Time { return now.Add(45 * time.Minute) }
+	loaded, err := restored.load(ctx, path, xorWrapper{fail: map[string]bool{"fp-a": true}})
+	assert.ErrorContains(t, err, "provider fp-a")
+	assert.Equal(t, 1, loaded, "entry b has expired and provider fp-a cannot unwrap")
+	_, ok := restored.get("c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,159 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// EncryptionContextHeader carries client supplied key=value pairs that are
+// bound to an object at PUT time and must be presented again on GET
+const EncryptionContextHeader = "x-s3ep-encryption-context"
+
+// maxEncryptionContextSize bounds the canonical form, which is stored in the
+// object metadata (S3 allows 2KB of user metadata in total)
+const maxEncryptionContextSize = 1024
+
+// EncryptionContext is a set of key=value pairs bound into the associated
+// data of an object, comparable to a KMS encryption context
+type EncryptionContext map[string]string
+
+// ParseEncryptionContext parses the header form "k1=v1,k2=v2". Keys and values
+// may be percent-encoded to carry "," or "=".
+func ParseEncryptionContext(header string) (EncryptionContext, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+
+	ec := EncryptionContext{}
+	for _, pair := range strings.Split(header, ",") {
+		rawKey, rawValue, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("encryption context entry %q is not key=value", pair)
+		}
+		key, err := url.QueryUnescape(strings.TrimSpace(rawKey))
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("encryption context key %q is invalid", rawKey)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("encryption context value of %q is invalid", key)
+		}
+		if _, exists := ec[key]; exists {
+			return nil, fmt.Errorf("encryption context key %q is repeated", key)
+		}
+		ec[key] = value
+	}
+
+	if len(ec.Canonical()) > maxEncryptionContextSize {
+		return nil, fmt.Errorf("encryption context exceeds %d bytes", maxEncryptionContextSize)
+	}
+	return ec, nil
+}
+
+// Canonical returns the order-independent form stored in metadata and bound
+// into the associated data: escaped pairs sorted by key, joined with "&"
+func (ec EncryptionContext) Canonical() string {
+	if len(ec) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(ec))
+	for key := range ec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = url.QueryEscape(key) + "=" + url.QueryEscape(ec[key])
+	}
+	return strings.Join(pairs, "&")
+}
+
+type encryptionContextKey struct{}
+
+// WithEncryptionContext attaches the client encryption context to ctx. The
+// Manager binds it to objects encrypted with ctx and checks it on decryption.
+func WithEncryptionContext(ctx context.Context, ec EncryptionContext) context.Context {
+	return context.WithValue(ctx, encryptionContextKey{}, ec)
+}
+
+// encryptionContextFrom returns the canonical encryption context of ctx and
+// whether the client supplied one
+func encryptionContextFrom(ctx context.Context) (string, bool) {
+	ec, ok := ctx.Value(encryptionContextKey{}).(EncryptionContext)
+	if !ok {
+		return "", false
+	}
+	return ec.Canonical(), true
+}
+
+// associatedData returns the AEAD associated data of an object: its key,
+// followed by the canonical encryption context if there is one. Objects
+// without a context keep the key-only associated data.
+func associatedData(objectKey, canonicalContext string) []byte {
+	if canonicalContext == "" {
+		return []byte(objectKey)
+	}
+	return []byte(objectKey + "\x00" + canonicalContext)
+}
+
+// bindEncryptionContext records the encryption context of ctx in metadata
+// and returns it in canonical form
+func (m *Manager) bindEncryptionContext(ctx context.Context, metadata map[string]string) string {
+	canonical, _ := encryptionContextFrom(ctx)
+	if canonical != "" {
+		m.metadataManager.SetEncryptionContext(metadata, canonical)
+	}
+	return canonical
+}
+
+// CheckEncryptionContextBinding reports whether the encryption context of ctx
+// can be bound to an AES-CTR object. Without integrity verification there is
+// no HMAC to carry it, so the request has to be rejected.
+func (m *Manager) CheckEncryptionContextBinding(ctx context.Context) error {
+	if canonical, _ := encryptionContextFrom(ctx); canonical != "" && !m.hmacManager.IsEnabled() {
+		return ErrEncryptionContextUnbound
+	}
+	return nil
+}
+
+// WithStoredEncryptionContext attaches the encryption context stored in
+// metadata to ctx. It is meant for trusted internal readers, such as the
+// integrity scrubber, that have no client to present the context.
+func (m *Manager) WithStoredEncryptionContext(ctx context.Context, metadata map[string]string) context.Context {
+	stored := m.metadataManager.GetEncryptionContext(metadata)
+	if stored == "" {
+		return ctx
+	}
+	pairs, err := url.ParseQuery(stored)
+	if err != nil {
+		// CheckEncryptionContext reports the unreadable context
+		return ctx
+	}
+	ec := make(EncryptionContext, len(pairs))
+	for key, values := range pairs {
+		ec[key] = values[0]
+	}
+	return WithEncryptionContext(ctx, ec)
+}
+
+// CheckEncryptionContext compares the encryption context of ctx with the one
+// stored in metadata. A context supplied by the client always has to match;
+// with encryption.strict_encryption_context an object that has a context
+// cannot be read without presenting it.
+func (m *Manager) CheckEncryptionContext(ctx context.Context, metadata map[string]string) error {
+	stored := m.metadataManager.GetEncryptionContext(metadata)
+	supplied, ok := encryptionContextFrom(ctx)
+
+	switch {
+	case ok && supplied != stored:
+		return ErrEncryptionContextMismatch
+	case !ok && stored != "" && m.config.Encryption.StrictEncryptionContext:
+		return fmt.Errorf("%w: object requires an encryption context", ErrEncryptionContextMismatch)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func TestParseEncryptionContext(t *testing.T) {
+	ec, err := ParseEncryptionContext(" tenant=acme , app=billing%2Cv2")
+	require.NoError(t, err)
+	assert.Equal(t, EncryptionContext{"tenant": "acme", "app": "billing,v2"}, ec)
+	assert.Equal(t, "app=billing%2Cv2&tenant=acme", ec.Canonical(), "canonical form is sorted")
+
+	ec, err = ParseEncryptionContext("")
+	require.NoError(t, err)
+	assert.Empty(t, ec)
+
+	for _, header := range []string{"tenant", "=acme", "tenant=a,tenant=b", "tenant=%zz"} {
+		_, err := ParseEncryptionContext(header)
+		assert.Error(t, err, header)
+	}
+	_, err = ParseEncryptionContext("k=" + string(bytes.Repeat([]byte("v"), maxEncryptionContextSize)))
+	assert.Error(t, err)
+}
+
+func encryptWithContext(t *testing.T, manager *Manager, ctx context.Context, plaintext []byte, contentType factory.ContentType) ([]byte, map[string]string) {
+	t.Helper()
+	result, err := manager.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader(plaintext)), "tenant/object.bin", contentType)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	return ciphertext, result.Metadata
+}
+
+func decryptWithContext(manager *Manager, ctx context.Context, ciphertext []byte, metadata map[string]string) ([]byte, error) {
+	reader, err := manager.DecryptDataWithMetadata(ctx, bytes.NewReader(ciphertext), metadata, "tenant/object.bin")
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestEncryptionContext_BoundIntoGCMAssociatedData(t *testing.T) {
+	manager := newSelfTestManager(t)
+	plaintext := []byte("tenant scoped data")
+	acme := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+
+	ciphertext, metadata := encryptWithContext(t, manager, acme, plaintext, factory.ContentTypeWhole)
+	assert.Equal(t, "tenant=acme", metadata["s3ep-encryption-context"])
+
+	decrypted, err := decryptWithContext(manager, acme, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// Without strict mode a request may omit the context
+	decrypted, err = decryptWithContext(manager, context.Background(), ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	other := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "other"})
+	_, err = decryptWithContext(manager, other, ciphertext, metadata)
+	assert.ErrorIs(t, err, ErrEncryptionContextMismatch)
+
+	// Rewriting the stored context breaks the AEAD tag
+	metadata["s3ep-encryption-context"] = "tenant=other"
+	_, err = decryptWithContext(manager, other, ciphertext, metadata)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrEncryptionContextMismatch)
+}
+
+func TestEncryptionContext_StrictModeRequiresContext(t *testing.T) {
+	manager := newSelfTestManager(t)
+	manager.config.Encryption.StrictEncryptionContext = true
+	manager.config.Encryption.IntegrityVerification = config.HMACVerificationStrict
+	acme := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+
+	for _, contentType := range []factory.ContentType{factory.ContentTypeWhole, factory.ContentTypeMultipart} {
+		t.Run(string(contentType), func(t *testing.T) {
+			ciphertext, metadata := encryptWithContext(t, manager, acme, []byte("data"), contentType)
+			assert.Equal(t, "tenant=acme", metadata["s3ep-encryption-context"])
+
+			_, err := decryptWithContext(manager, context.Background(), ciphertext, metadata)
+			assert.ErrorIs(t, err, ErrEncryptionContextMismatch)
+
+			decrypted, err := decryptWithContext(manager, acme, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("data"), decrypted)
+		})
+	}
+
+	// Objects stored without a context stay readable
+	ciphertext, metadata := encryptWithContext(t, manager, context.Background(), []byte("legacy"), factory.ContentTypeWhole)
+	_, err := decryptWithContext(manager, context.Background(), ciphertext, metadata)
+	assert.NoError(t, err)
+}
+
+func TestEncryptionContext_BoundIntoCTRIntegrity(t *testing.T) {
+	manager := newSelfTestManager(t)
+	manager.config.Encryption.IntegrityVerification = config.HMACVerificationStrict
+	plaintext := []byte("tenant scoped data")
+	acme := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+
+	ciphertext, metadata := encryptWithContext(t, manager, acme, plaintext, factory.ContentTypeMultipart)
+	require.Equal(t, "aes-ctr", metadata["s3ep-dek-algorithm"])
+	require.Contains(t, metadata, "s3ep-hmac")
+
+	decrypted, err := decryptWithContext(manager, acme, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// Rewriting the stored context breaks the HMAC
+	rewritten := maps.Clone(metadata)
+	rewritten["s3ep-encryption-context"] = "tenant=other"
+	other := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "other"})
+	_, err = decryptWithContext(manager, other, ciphertext, rewritten)
+	assert.ErrorIs(t, err, ErrIntegrityFailure)
+
+	// So does dropping the HMAC that binds it
+	stripped := maps.Clone(rewritten)
+	delete(stripped, "s3ep-hmac")
+	_, err = decryptWithContext(manager, other, ciphertext, stripped)
+	assert.ErrorIs(t, err, ErrIntegrityFailure)
+
+	// Without integrity verification there is nothing to bind the context to
+	manager.config.Encryption.IntegrityVerification = config.HMACVerificationOff
+	_, err = manager.EncryptDataWithContentType(acme, bufio.NewReader(bytes.NewReader(plaintext)), "tenant/object.bin", factory.ContentTypeMultipart)
+	assert.ErrorIs(t, err, ErrEncryptionContextUnbound)
+	assert.ErrorIs(t, manager.CheckEncryptionContextBinding(acme), ErrEncryptionContextUnbound)
+	assert.NoError(t, manager.CheckEncryptionContextBinding(context.Background()))
+}
+
+func TestEncryptionContext_BoundIntoMultipartUpload(t *testing.T) {
+	manager := newSelfTestManager(t)
+	manager.config.Encryption.IntegrityVerification = config.HMACVerificationStrict
+	acme := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+	plaintext := bytes.Repeat([]byte("tenant scoped part "), 1000)
+
+	require.NoError(t, manager.InitiateMultipartUpload(acme, "upload-ec", "tenant/object.bin", "bucket"))
+	result, err := manager.UploadPart(context.Background(), "upload-ec", 1, bufio.NewReader(bytes.NewReader(plaintext)))
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	metadata, err := manager.CompleteMultipartUpload(context.Background(), "upload-ec", map[int]string{1: "etag"})
+	require.NoError(t, err)
+	assert.Equal(t, "tenant=acme", metadata["s3ep-encryption-context"])
+
+	decrypted, err := decryptWithContext(manager, acme, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	metadata["s3ep-encryption-context"] = "tenant=other"
+	_, err = decryptWithContext(manager, context.Background(), ciphertext, metadata)
+	assert.ErrorIs(t, err, ErrIntegrityFailure)
+
+	manager.config.Encryption.IntegrityVerification = config.HMACVerificationOff
+	err = manager.InitiateMultipartUpload(acme, "upload-unbound", "tenant/object.bin", "bucket")
+	assert.ErrorIs(t, err, ErrEncryptionContextUnbound)
+}
+
+func TestWithStoredEncryptionContext(t *testing.T) {
+	manager := newSelfTestManager(t)
+	manager.config.Encryption.StrictEncryptionContext = true
+	ec := EncryptionContext{"tenant": "acme", "app": "billing,v2 & more"}
+	ciphertext, metadata := encryptWithContext(t, manager, WithEncryptionContext(context.Background(), ec), []byte("data"), factory.ContentTypeWhole)
+
+	ctx := manager.WithStoredEncryptionContext(context.Background(), metadata)
+	canonical, ok := encryptionContextFrom(ctx)
+	require.True(t, ok)
+	assert.Equal(t, ec.Canonical(), canonical)
+
+	decrypted, err := decryptWithContext(manager, ctx, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), decrypted)
+
+	// Objects without a context get none attached
+	_, ok = encryptionContextFrom(manager.WithStoredEncryptionContext(context.Background(), map[string]string{}))
+	assert.False(t, ok)
+}
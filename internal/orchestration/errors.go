@@ -0,0 +1,77 @@
+package orchestration
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+)
+
+// Errors returned by the Manager. They are wrapped with context, so callers
+// have to test for them with errors.Is.
+var (
+	// ErrSessionNotFound is returned for multipart operations on an upload ID
+	// without an active encryption session
+	ErrSessionNotFound = errors.New("multipart upload not found")
+
+	// ErrDuplicateSession is returned when a multipart session is initiated
+	// for an upload ID that already has one
+	ErrDuplicateSession = errors.New("multipart upload already exists")
+
+	// ErrKeyUnavailable is returned when no configured provider can wrap or
+	// unwrap a data encryption key. Use errors.As with *KeyUnavailableError to
+	// get the fingerprint.
+	ErrKeyUnavailable = errors.New("key encryption key unavailable")
+
+	// ErrKeyRateLimited is returned when a key operation could not get through
+	// the rate limit of its provider within the queue timeout
+	ErrKeyRateLimited = errors.New("key operation rate limit exceeded")
+
+	// ErrIntegrityFailure is returned when decrypted data does not match its HMAC
+	ErrIntegrityFailure = validation.ErrIntegrityFailure
+
+	// ErrEncryptionContextMismatch is returned when the encryption context of a
+	// request does not match the one the object was encrypted with
+	ErrEncryptionContextMismatch = errors.New("encryption context does not match")
+
+	// ErrEncryptionContextUnbound is returned when an encryption context is
+	// supplied for an AES-CTR object while integrity verification is off: the
+	// HMAC is the only place such an object can bind it
+	ErrEncryptionContextUnbound = errors.New("encryption context requires integrity verification")
+
+	// ErrProviderNotSelectable is returned when a client selects an
+	// encryption provider that is not listed in encryption.selectable_providers
+	ErrProviderNotSelectable = errors.New("encryption provider cannot be selected")
+
+	// ErrTenantKeyDenied is returned when a request would unwrap a DEK with
+	// the provider dedicated to a tenant it does not belong to
+	ErrTenantKeyDenied = errors.New("key belongs to another tenant")
+
+	// ErrIncompleteBody is returned when a streamed part ends before the
+	// length the client announced
+	ErrIncompleteBody = errors.New("request body is shorter than its content length")
+)
+
+// KeyUnavailableError reports the key fingerprint that could not be used.
+// It matches ErrKeyUnavailable with errors.Is.
+type KeyUnavailableError struct {
+	Fingerprint string
+	Err         error // underlying provider error, may be nil
+}
+
+func (e *KeyUnavailableError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("no provider found with fingerprint '%s'", e.Fingerprint)
+	}
+	return fmt.Sprintf("no provider found with fingerprint '%s': %v", e.Fingerprint, e.Err)
+}
+
+// Is makes errors.Is(err, ErrKeyUnavailable) report true
+func (e *KeyUnavailableError) Is(target error) bool {
+	return target == ErrKeyUnavailable
+}
+
+// Unwrap returns the underlying provider error
+func (e *KeyUnavailableError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,135 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Object formats, recorded as format-version in the metadata of every object
+// written since format versioning
+const (
+	// FormatVersionUnversioned is an object written before format-version was
+	// recorded: one keystream or AEAD ciphertext whose encryption metadata
+	// may lack the prefix
+	FormatVersionUnversioned = 0
+	// FormatVersionContinuous is one keystream or AEAD ciphertext over the
+	// whole object, including multipart objects encrypted in part order
+	FormatVersionContinuous = 1
+	// FormatVersionParted is a multipart object whose parts were encrypted at
+	// their own keystream offsets, see SetPartLayout
+	FormatVersionParted = 2
+
+	// LatestFormatVersion is the newest format this release writes
+	LatestFormatVersion = FormatVersionParted
+)
+
+// ErrUnsupportedFormat is returned for objects of a format this release
+// cannot read, usually written by a newer release
+var ErrUnsupportedFormat = errors.New("unsupported format-version")
+
+// formatSupport is a row of the compatibility matrix
+type formatSupport struct {
+	name      string
+	upgradeTo int // format MigrateMetadata records for objects of this format
+}
+
+// formatCompatibility is the compatibility matrix of the read path. Every
+// listed format is readable; objects of unlisted formats are refused with
+// ErrUnsupportedFormat. A format is only upgraded in metadata, so it
+// upgrades to the newest format with the same ciphertext layout.
+var formatCompatibility = map[int]formatSupport{
+	FormatVersionUnversioned: {name: "unversioned", upgradeTo: FormatVersionContinuous},
+	FormatVersionContinuous:  {name: "continuous", upgradeTo: FormatVersionContinuous},
+	FormatVersionParted:      {name: "parted", upgradeTo: FormatVersionParted},
+}
+
+// legacyMetadataKeys are the encryption metadata keys early releases stored
+// without the prefix
+var legacyMetadataKeys = []string{"encrypted-dek", "dek-algorithm", "kek-fingerprint", "kek-algorithm", "aes-iv"}
+
+// SetFormatVersion records the format of an object in metadata
+func (mm *MetadataManager) SetFormatVersion(metadata map[string]string, version int) {
+	metadata[mm.prefix+"format-version"] = strconv.Itoa(version)
+}
+
+// GetFormatVersion returns the format of an object. Objects without a
+// recorded version are FormatVersionUnversioned; formats missing from the
+// compatibility matrix are an ErrUnsupportedFormat.
+func (mm *MetadataManager) GetFormatVersion(metadata map[string]string) (int, error) {
+	recorded, exists := metadata[mm.prefix+"format-version"]
+	if !exists {
+		return FormatVersionUnversioned, nil
+	}
+	version, err := strconv.Atoi(recorded)
+	if _, known := formatCompatibility[version]; err != nil || !known || version == FormatVersionUnversioned {
+		return 0, fmt.Errorf("%w %q", ErrUnsupportedFormat, recorded)
+	}
+	return version, nil
+}
+
+// prefixLegacyKeys moves encryption metadata stored without the prefix under
+// it and reports whether metadata changed
+func (mm *MetadataManager) prefixLegacyKeys(metadata map[string]string) bool {
+	if mm.prefix == "" {
+		return false
+	}
+	changed := false
+	for _, key := range legacyMetadataKeys {
+		value, exists := metadata[key]
+		if !exists {
+			continue
+		}
+		if _, prefixed := metadata[mm.prefix+key]; !prefixed {
+			metadata[mm.prefix+key] = value
+		}
+		delete(metadata, key)
+		changed = true
+	}
+	return changed
+}
+
+// MigrateMetadata upgrades the encryption metadata of an object to the newest
+// format with the same ciphertext layout and re-wraps its DEK like RewrapDEK.
+// Keys early releases stored without the prefix move under it and
+// unversioned objects get a format-version; the ciphertext stays valid.
+//
+// It returns the updated copy of metadata and true, or metadata unchanged and
+// false for objects that are not encrypted or already current.
+func (m *Manager) MigrateMetadata(ctx context.Context, metadata map[string]string, objectKey string) (map[string]string, bool, error) {
+	if _, err := m.metadataManager.GetEncryptedDEK(metadata); err != nil {
+		return metadata, false, nil
+	}
+	version, err := m.metadataManager.GetFormatVersion(metadata)
+	if err != nil {
+		return nil, false, err
+	}
+
+	updated := maps.Clone(metadata)
+	changed := m.metadataManager.prefixLegacyKeys(updated)
+	upgradeTo := formatCompatibility[version].upgradeTo
+	if upgradeTo != version {
+		m.metadataManager.SetFormatVersion(updated, upgradeTo)
+		changed = true
+	}
+
+	rewrapped, rewrappedChanged, err := m.RewrapDEK(ctx, updated, objectKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if !changed && !rewrappedChanged {
+		return metadata, false, nil
+	}
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"object_key":    objectKey,
+		"old_format":    formatCompatibility[version].name,
+		"new_format":    formatCompatibility[upgradeTo].name,
+		"dek_rewrapped": rewrappedChanged,
+	}).Debug("Migrated object metadata")
+	return rewrapped, true, nil
+}
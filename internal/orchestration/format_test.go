@@ -0,0 +1,118 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestGetFormatVersion(t *testing.T) {
+	mm := NewMetadataManager(&config.Config{}, "s3ep-")
+	for recorded, expected := range map[string]int{"1": FormatVersionContinuous, "2": FormatVersionParted} {
+		version, err := mm.GetFormatVersion(map[string]string{"s3ep-format-version": recorded})
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+	version, err := mm.GetFormatVersion(map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersionUnversioned, version)
+
+	for _, unsupported := range []string{"0", "3", "-1", "x", ""} {
+		_, err := mm.GetFormatVersion(map[string]string{"s3ep-format-version": unsupported})
+		assert.ErrorIs(t, err, ErrUnsupportedFormat, unsupported)
+	}
+}
+
+func TestManager_MigrateMetadata(t *testing.T) {
+	oldProvider := config.EncryptionProvider{
+		Alias:  "kek-2025",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+	}
+	newProvider := config.EncryptionProvider{
+		Alias:  "kek-2026",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+	}
+	newManager := func(active string, providers ...config.EncryptionProvider) *Manager {
+		manager, err := NewManager(&config.Config{
+			Encryption: config.EncryptionConfig{
+				EncryptionMethodAlias: active,
+				Providers:             providers,
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+		return manager
+	}
+	decrypt := func(manager *Manager, ciphertext []byte, metadata map[string]string) ([]byte, error) {
+		reader, err := manager.DecryptData(context.Background(), bufio.NewReader(bytes.NewReader(ciphertext)), metadata, "docs/report.pdf")
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+
+	ctx := context.Background()
+	plaintext := bytes.Repeat([]byte("migrate me "), 500)
+	result, err := newManager("kek-2025", oldProvider).EncryptData(ctx, bufio.NewReader(bytes.NewReader(plaintext)), "docs/report.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "1", result.Metadata["s3ep-format-version"], "new objects record their format")
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+
+	rotating := newManager("kek-2026", newProvider, oldProvider)
+	unversioned := maps.Clone(result.Metadata)
+	delete(unversioned, "s3ep-format-version")
+	data, err := decrypt(rotating, ciphertext, unversioned)
+	require.NoError(t, err, "unversioned objects stay readable")
+	assert.Equal(t, plaintext, data)
+
+	// The metadata of an early release: no format-version, keys without prefix
+	legacy := maps.Clone(unversioned)
+	for _, key := range legacyMetadataKeys {
+		legacy[key] = legacy["s3ep-"+key]
+		delete(legacy, "s3ep-"+key)
+	}
+	legacy["owner"] = "alice"
+
+	migrated, changed, err := rotating.MigrateMetadata(ctx, legacy, "docs/report.pdf")
+	require.NoError(t, err)
+	require.True(t, changed)
+	assert.Equal(t, "1", migrated["s3ep-format-version"])
+	assert.Equal(t, "alice", migrated["owner"])
+	for _, key := range legacyMetadataKeys {
+		assert.NotContains(t, migrated, key)
+		assert.Contains(t, migrated, "s3ep-"+key)
+	}
+	assert.NotEqual(t, legacy["kek-fingerprint"], migrated["s3ep-kek-fingerprint"], "the DEK is re-wrapped with the active provider")
+	assert.NotContains(t, legacy, "s3ep-format-version", "the metadata passed in is not changed")
+
+	_, changed, err = rotating.MigrateMetadata(ctx, migrated, "docs/report.pdf")
+	require.NoError(t, err)
+	assert.False(t, changed, "migrated objects are current")
+
+	data, err = decrypt(newManager("kek-2026", newProvider), ciphertext, migrated)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, data)
+
+	// Objects of a newer release are refused, on reads and migrations
+	newer := maps.Clone(migrated)
+	newer["s3ep-format-version"] = "3"
+	_, err = decrypt(rotating, ciphertext, newer)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	_, _, err = rotating.MigrateMetadata(ctx, newer, "docs/report.pdf")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, changed, err = rotating.MigrateMetadata(ctx, map[string]string{"owner": "alice"}, "plain.txt")
+	require.NoError(t, err)
+	assert.False(t, changed, "unencrypted objects are left alone")
+}
@@ -0,0 +1,86 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+)
+
+type bucketKey struct{}
+
+// WithBucket attaches the target bucket of a request to ctx. The Manager uses
+// it to select per-bucket settings such as the integrity algorithm.
+func WithBucket(ctx context.Context, bucket string) context.Context {
+	return context.WithValue(ctx, bucketKey{}, bucket)
+}
+
+// bucketFrom returns the bucket attached with WithBucket, or ""
+func bucketFrom(ctx context.Context) string {
+	bucket, _ := ctx.Value(bucketKey{}).(string)
+	return bucket
+}
+
+// hmacContextLabel separates the encryption context from the plaintext in
+// the integrity HMAC
+const hmacContextLabel = "s3ep-encryption-context\x00"
+
+// integrityCalculator creates the calculator for a new object. AES-CTR has no
+// associated data, so the canonical encryption context is fed into the HMAC
+// ahead of the plaintext, length-prefixed so it cannot run into the data.
+// Objects without a context keep the plaintext-only HMAC.
+func integrityCalculator(hm *validation.HMACManager, dek []byte, algorithm, canonicalContext string) (*validation.HMACCalculator, error) {
+	calculator, err := hm.CreateCalculatorWithAlgorithm(dek, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if canonicalContext != "" {
+		prefix := make([]byte, 0, len(hmacContextLabel)+4+len(canonicalContext))
+		prefix = append(prefix, hmacContextLabel...)
+		prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(canonicalContext))) // #nosec G115 - bounded by maxEncryptionContextSize
+		prefix = append(prefix, canonicalContext...)
+		if _, err := calculator.Write(prefix); err != nil {
+			calculator.Cleanup()
+			return nil, err
+		}
+	}
+	return calculator, nil
+}
+
+// verificationCalculator creates the calculator for the integrity algorithm
+// recorded in metadata, primed with the encryption context stored there, so
+// a rewritten context fails verification. Objects with a part layout are
+// verified part by part.
+func verificationCalculator(hm *validation.HMACManager, mm *MetadataManager, dek []byte, metadata map[string]string) (*validation.HMACCalculator, error) {
+	_, parts, err := mm.GetPartLayout(metadata)
+	if err != nil {
+		return nil, err
+	}
+	calculator, err := integrityCalculator(hm, dek, mm.GetHMACAlgorithm(metadata), mm.GetEncryptionContext(metadata))
+	if err != nil || parts == nil {
+		return calculator, err
+	}
+	parted, err := hm.CreatePartedCalculator(calculator, dek, partRecords(parts))
+	if err != nil {
+		calculator.Cleanup()
+		return nil, err
+	}
+	return parted, nil
+}
+
+// auditIntegrity returns the Verifier callback reporting the result of an
+// HMAC verification to the audit details of ctx, or nil without them
+func auditIntegrity(ctx context.Context) func(error) {
+	details := audit.DetailsFrom(ctx)
+	if details == nil {
+		return nil
+	}
+	return func(err error) {
+		if err != nil {
+			details.SetIntegrity(audit.IntegrityFailed)
+			return
+		}
+		details.SetIntegrity(audit.IntegrityVerified)
+	}
+}
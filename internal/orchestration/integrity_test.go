@@ -0,0 +1,87 @@
+package orchestration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func newIntegrityTestManager(t *testing.T) *Manager {
+	t.Helper()
+	cfg := createTestMultipartConfig()
+	cfg.Encryption.IntegrityAlgorithmOverrides = []config.IntegrityAlgorithmOverride{
+		{Buckets: []string{"finance-*"}, Algorithm: config.IntegrityAlgorithmHMACSHA512},
+		{Buckets: []string{"media"}, Algorithm: config.IntegrityAlgorithmBLAKE3},
+	}
+	manager, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+func TestIntegrityAlgorithm_SelectedPerBucketAndRecorded(t *testing.T) {
+	manager := newIntegrityTestManager(t)
+	plaintext := []byte("integrity protected payload")
+
+	tests := []struct {
+		bucket   string
+		recorded string
+	}{
+		{bucket: "finance-eu", recorded: config.IntegrityAlgorithmHMACSHA512},
+		{bucket: "media", recorded: config.IntegrityAlgorithmBLAKE3},
+		{bucket: "other", recorded: ""}, // hmac-sha256 is not recorded
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			ctx := WithBucket(context.Background(), tt.bucket)
+			ciphertext, metadata := encryptWithContext(t, manager, ctx, plaintext, factory.ContentTypeMultipart)
+			assert.NotEmpty(t, metadata["s3ep-hmac"])
+			assert.Equal(t, tt.recorded, metadata["s3ep-hmac-algorithm"])
+
+			// Reads use the recorded algorithm regardless of the bucket
+			decrypted, err := decryptWithContext(manager, context.Background(), ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			tampered := append([]byte(nil), ciphertext...)
+			tampered[0] ^= 0x01
+			_, err = decryptWithContext(manager, context.Background(), tampered, metadata)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIntegrityAlgorithm_RecordedAlgorithmMustMatch(t *testing.T) {
+	manager := newIntegrityTestManager(t)
+	ctx := WithBucket(context.Background(), "media")
+	ciphertext, metadata := encryptWithContext(t, manager, ctx, []byte("payload"), factory.ContentTypeMultipart)
+
+	// Downgrading the recorded algorithm makes the stored MAC fail verification
+	delete(metadata, "s3ep-hmac-algorithm")
+	_, err := decryptWithContext(manager, context.Background(), ciphertext, metadata)
+	assert.Error(t, err)
+
+	metadata["s3ep-hmac-algorithm"] = "md5"
+	_, err = decryptWithContext(manager, context.Background(), ciphertext, metadata)
+	assert.Error(t, err)
+}
+
+func TestIntegrityAlgorithm_MultipartSession(t *testing.T) {
+	manager := newIntegrityTestManager(t)
+	ctx := context.Background()
+
+	require.NoError(t, manager.InitiateMultipartUpload(ctx, "upload-1", "report.bin", "finance-us"))
+	_, err := manager.UploadPart(ctx, "upload-1", 1, testDataToReader([]byte("part one")))
+	require.NoError(t, err)
+	require.NoError(t, manager.StorePartETag("upload-1", 1, "etag-1"))
+
+	metadata, err := manager.CompleteMultipartUpload(ctx, "upload-1", map[int]string{1: "etag-1"})
+	require.NoError(t, err)
+	assert.Equal(t, config.IntegrityAlgorithmHMACSHA512, metadata["s3ep-hmac-algorithm"])
+}
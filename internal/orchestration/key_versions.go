@@ -0,0 +1,128 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
+)
+
+// registerKeyVersioner remembers a provider whose KEK has versions. It is
+// called with the bare KeyEncryptor, before the wrappers hide the interface.
+func (pm *ProviderManager) registerKeyVersioner(keyEncryptor encryption.KeyEncryptor) {
+	versioner, ok := keyEncryptor.(encryption.KeyVersioner)
+	if !ok {
+		return
+	}
+	pm.providersMutex.Lock()
+	pm.keyVersioners[keyEncryptor.Fingerprint()] = versioner
+	pm.providersMutex.Unlock()
+}
+
+func (pm *ProviderManager) keyVersioner(fingerprint string) encryption.KeyVersioner {
+	pm.providersMutex.RLock()
+	defer pm.providersMutex.RUnlock()
+	return pm.keyVersioners[fingerprint]
+}
+
+// KeyVersion returns the KEK version an encrypted DEK of the provider with
+// fingerprint was wrapped with. ok is false for providers without versions.
+func (pm *ProviderManager) KeyVersion(fingerprint string, encryptedDEK []byte) (version int, ok bool, err error) {
+	versioner := pm.keyVersioner(fingerprint)
+	if versioner == nil {
+		return 0, false, nil
+	}
+	primary, _, err := keyencryption.SplitRecoveryEnvelope(encryptedDEK)
+	if err != nil {
+		return 0, false, err
+	}
+	version, err = versioner.KeyVersion(primary)
+	if err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
+
+// recordKEKVersion stores the KEK version of the encrypted DEK in metadata,
+// for providers whose KEK has versions
+func (pm *ProviderManager) recordKEKVersion(mm *MetadataManager, metadata map[string]string) {
+	fingerprint, err := mm.GetFingerprint(metadata)
+	if err != nil || pm.keyVersioner(fingerprint) == nil {
+		return
+	}
+	encryptedDEK, err := mm.GetEncryptedDEK(metadata)
+	if err != nil {
+		return
+	}
+	version, ok, err := pm.KeyVersion(fingerprint, encryptedDEK)
+	if err != nil {
+		pm.logger.WithError(err).WithField("fingerprint", fingerprint).Warn("Failed to determine KEK version of encrypted DEK")
+		return
+	}
+	if ok {
+		mm.SetKEKVersion(metadata, version)
+	}
+}
+
+// rewrapKeyVersion wraps an encrypted DEK with the latest version of the KEK
+// of the provider with fingerprint, inside the provider. changed is false if
+// the provider has no versions or the DEK is wrapped with the latest one.
+func (pm *ProviderManager) rewrapKeyVersion(ctx context.Context, fingerprint string, encryptedDEK []byte) ([]byte, bool, error) {
+	versioner := pm.keyVersioner(fingerprint)
+	if versioner == nil {
+		return encryptedDEK, false, nil
+	}
+	primary, _, err := keyencryption.SplitRecoveryEnvelope(encryptedDEK)
+	if err != nil {
+		return nil, false, err
+	}
+	version, err := versioner.KeyVersion(primary)
+	if err != nil {
+		return nil, false, err
+	}
+	latest, err := versioner.LatestKeyVersion(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get latest KEK version: %w", err)
+	}
+	if version >= latest {
+		return encryptedDEK, false, nil
+	}
+
+	rewrapped, err := versioner.RewrapDEK(ctx, primary)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to re-wrap DEK: %w", err)
+	}
+	rewrapped, err = keyencryption.ReplaceRecoveryPrimary(encryptedDEK, rewrapped)
+	if err != nil {
+		return nil, false, err
+	}
+	return rewrapped, true, nil
+}
+
+// rewrapKeyVersion is RewrapDEK for a DEK that stays with its provider: it
+// moves the DEK to the latest version of a versioned KEK
+func (m *Manager) rewrapKeyVersion(ctx context.Context, metadata map[string]string, encryptedDEK []byte, fingerprint, objectKey string) (map[string]string, bool, error) {
+	rewrapped, changed, err := m.providerManager.rewrapKeyVersion(ctx, fingerprint, encryptedDEK)
+	if err != nil {
+		return nil, false, err
+	}
+	if !changed {
+		return metadata, false, nil
+	}
+
+	updated := maps.Clone(metadata)
+	updated[m.metadataManager.GetMetadataPrefix()+"encrypted-dek"] = base64.StdEncoding.EncodeToString(rewrapped)
+	m.providerManager.recordKEKVersion(m.metadataManager, updated)
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"object_key":  objectKey,
+		"fingerprint": fingerprint,
+		"kek_version": updated[m.metadataManager.GetMetadataPrefix()+"kek-version"],
+	}).Debug("Re-wrapped DEK with the latest KEK version")
+	return updated, true, nil
+}
@@ -3,16 +3,25 @@ package orchestration
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
 )
 
@@ -34,7 +43,12 @@ type Manager struct {
 	hmacManager     *validation.HMACManager
 	logger          *logrus.Entry // Public for testing
 
-	segmentSize int64 // Size of each streaming segment in bytes
+	segmentSize int64                       // Size of each streaming segment in bytes
+	parallelism *dataencryption.Parallelism // AES-CTR workers, shared by all streams
+
+	keysReady atomic.Bool // set once PreloadKeys succeeded
+
+	usage UsageRecorder // nil until SetUsageRecorder
 
 	// Background cleanup management
 	cleanupCtx    context.Context
@@ -66,8 +80,20 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 	// Get segment size from configuration (default is defined in config)
 	segmentSize := cfg.GetStreamingSegmentSize()
 
+	// One worker budget for all streams, so the global cap holds across single-part and multipart
+	parallelism := dataencryption.NewParallelism(cfg.GetCryptoParallelism())
+
+	// Multipart sessions live in the configured store, so uploads survive restarts
+	sessionStore, err := NewSessionStore(cfg)
+	if err != nil {
+		logger.WithError(err).Error("Failed to create multipart session store")
+		return nil, fmt.Errorf("failed to create multipart session store: %w", err)
+	}
+
 	// Create specialized operation handlers
 	multipartOps := NewMultipartOperations(providerManager, hmacManager, metadataManager, cfg)
+	multipartOps.parallelism = parallelism
+	multipartOps.store = sessionStore
 
 	// Create background cleanup context
 	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
@@ -79,11 +105,15 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		metadataManager: metadataManager,
 		hmacManager:     hmacManager,
 		segmentSize:     segmentSize,
+		parallelism:     parallelism,
 		logger:          logger,
 		cleanupCtx:      cleanupCtx,
 		cleanupCancel:   cleanupCancel,
 	}
 
+	// Continue the uploads that were in progress when the last process stopped
+	manager.restoreSessions()
+
 	// Start background cleanup if cleanup interval is configured
 	if cfg.Optimizations.MultipartSessionCleanupInterval > 0 {
 		manager.startBackgroundCleanup()
@@ -95,6 +125,7 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		"hmac_enabled":    hmacManager.IsEnabled(),
 		"metadata_prefix": metadataManager.GetMetadataPrefix(),
 		"segment_size":    segmentSize,
+		"session_store":   cfg.SessionStore.Type,
 	}).Info("Successfully initialized Manager")
 
 	return manager, nil
@@ -104,11 +135,11 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 
 // EncryptData encrypts data from a reader using streaming encryption
 func (m *Manager) EncryptData(ctx context.Context, dataReader *bufio.Reader, objectKey string) (*StreamingEncryptionResult, error) {
-	m.logger.WithField("object_key", objectKey).Debug("Starting streaming data encryption")
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Starting streaming data encryption")
 
 	// Check for none provider - complete pass-through
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithField("object_key", objectKey).Debug("Using none provider - complete pass-through")
+		m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none provider - complete pass-through")
 		return &StreamingEncryptionResult{
 			EncryptedDataReader: dataReader,
 			Metadata:            make(map[string]string),
@@ -131,19 +162,20 @@ func (m *Manager) EncryptData(ctx context.Context, dataReader *bufio.Reader, obj
 
 // EncryptDataWithContentType encrypts data with explicit content type using streaming
 func (m *Manager) EncryptDataWithContentType(ctx context.Context, dataReader *bufio.Reader, objectKey string, contentType factory.ContentType) (*StreamingEncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key":   objectKey,
 		"content_type": contentType,
 	}).Debug("Encrypting data stream with specified content type")
 
 	// Check for none provider - complete pass-through with no encryption or metadata
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithField("object_key", objectKey).Debug("Using none provider - complete pass-through without encryption or HMAC")
+		m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none provider - complete pass-through without encryption or HMAC")
 		return &StreamingEncryptionResult{
 			EncryptedDataReader: dataReader,              // Return data reader unchanged
 			Metadata:            make(map[string]string), // No metadata
 		}, nil
 	}
+	dataReader = m.countEncryptedBuffered(ctx, dataReader)
 
 	// Route based on content type
 	switch contentType {
@@ -157,8 +189,11 @@ func (m *Manager) EncryptDataWithContentType(ctx context.Context, dataReader *bu
 }
 
 // EncryptDataWithHTTPContentType encrypts data based on HTTP content type using streaming
-func (m *Manager) EncryptDataWithHTTPContentType(ctx context.Context, dataReader *bufio.Reader, objectKey string, httpContentType string, isMultipart bool) (*StreamingEncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+func (m *Manager) EncryptDataWithHTTPContentType(ctx context.Context, dataReader *bufio.Reader, objectKey string, httpContentType string, isMultipart bool) (_ *StreamingEncryptionResult, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.EncryptData", attribute.Bool("s3ep.multipart", isMultipart))
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key":        objectKey,
 		"http_content_type": httpContentType,
 		"is_multipart":      isMultipart,
@@ -179,33 +214,50 @@ func (m *Manager) EncryptDataWithHTTPContentType(ctx context.Context, dataReader
 
 // DecryptData decrypts data from a reader using metadata to determine the algorithm.
 // This is the preferred method for performance as it uses streaming decryption throughout.
-func (m *Manager) DecryptData(ctx context.Context, encryptedDataReader *bufio.Reader, metadata map[string]string, objectKey string) (*bufio.Reader, error) {
-	m.logger.WithField("object_key", objectKey).Debug("Starting streaming data decryption")
+func (m *Manager) DecryptData(ctx context.Context, encryptedDataReader *bufio.Reader, metadata map[string]string, objectKey string) (decrypted *bufio.Reader, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.DecryptData")
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Starting streaming data decryption")
 
 	// Check for none provider - if no encryption metadata exists, assume none provider pass-through
 	if len(metadata) == 0 || m.isNoneProviderData(metadata) {
-		m.logger.WithField("object_key", objectKey).Debug("No encryption metadata found - assuming none provider pass-through")
+		m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("No encryption metadata found - assuming none provider pass-through")
 		return encryptedDataReader, nil
 	}
 
+	if err := m.CheckEncryptionContext(ctx, metadata); err != nil {
+		return nil, err
+	}
+	if _, err := m.metadataManager.GetFormatVersion(metadata); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil && decrypted != encryptedDataReader {
+			if counted := m.countDecrypted(ctx, decrypted, metadata); counted != io.Reader(decrypted) {
+				decrypted = bufio.NewReader(counted)
+			}
+		}
+	}()
+
 	// Extract algorithm from metadata
 	algorithm, err := m.metadataManager.GetAlgorithm(metadata)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to get algorithm from metadata")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to get algorithm from metadata")
 		return nil, fmt.Errorf("failed to get algorithm from metadata: %w", err)
 	}
 
 	// Route to appropriate decryption method
 	switch algorithm {
-	case "aes-gcm":
+	case "aes-gcm", "aes-gcm-siv", "chacha20-poly1305":
 		return m.DecryptGCMStream(ctx, encryptedDataReader, metadata, objectKey)
-	case "aes-ctr":
+	case "aes-ctr", "xchacha20":
 		return m.DecryptCTRStream(ctx, encryptedDataReader, metadata, objectKey)
 	case "none":
-		m.logger.WithField("object_key", objectKey).Debug("Using none algorithm - returning data as-is")
+		m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none algorithm - returning data as-is")
 		return encryptedDataReader, nil
 	default:
-		m.logger.WithFields(logrus.Fields{
+		m.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"algorithm":  algorithm,
 			"object_key": objectKey,
 		}).Error("Unknown algorithm in metadata")
@@ -216,15 +268,18 @@ func (m *Manager) DecryptData(ctx context.Context, encryptedDataReader *bufio.Re
 // ===== MULTIPART OPERATIONS =====
 
 // UploadPart encrypts and processes a multipart upload part from a reader
-func (m *Manager) UploadPart(ctx context.Context, uploadID string, partNumber int, dataReader *bufio.Reader) (*StreamingEncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+func (m *Manager) UploadPart(ctx context.Context, uploadID string, partNumber int, dataReader *bufio.Reader) (_ *StreamingEncryptionResult, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.UploadPart", attribute.Int("aws.s3.part_number", partNumber))
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"part_number": partNumber,
 	}).Debug("Processing streaming multipart upload part")
 
 	// Check for none provider - complete pass-through with no encryption or metadata
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithFields(logrus.Fields{
+		m.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"upload_id":   uploadID,
 			"part_number": partNumber,
 		}).Debug("Using none provider - multipart part pass-through without encryption")
@@ -233,6 +288,7 @@ func (m *Manager) UploadPart(ctx context.Context, uploadID string, partNumber in
 			Metadata:            make(map[string]string), // No metadata
 		}, nil
 	}
+	dataReader = m.countEncryptedBuffered(ctx, dataReader)
 
 	// Use the new multipart ProcessPart method that maintains persistent CTR state
 	// This ensures continuous encryption stream across all parts
@@ -241,7 +297,7 @@ func (m *Manager) UploadPart(ctx context.Context, uploadID string, partNumber in
 		return nil, fmt.Errorf("failed to process multipart part: %w", err)
 	}
 
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":       uploadID,
 		"part_number":     partNumber,
 		"algorithm":       result.Algorithm,
@@ -251,30 +307,50 @@ func (m *Manager) UploadPart(ctx context.Context, uploadID string, partNumber in
 	return &StreamingEncryptionResult{
 		EncryptedDataReader: result.EncryptedData,
 		Metadata:            result.Metadata,
+		Algorithm:           result.Algorithm,
 	}, nil
 }
 
 // InitiateMultipartUpload starts a new multipart upload session
 func (m *Manager) InitiateMultipartUpload(ctx context.Context, uploadID, objectKey, bucketName string) error {
-	m.logger.WithFields(logrus.Fields{
+	return m.InitiateMultipartUploadForClient(ctx, uploadID, objectKey, bucketName, "")
+}
+
+// InitiateMultipartUploadForClient starts a new multipart upload session owned
+// by clientID (the access key ID of the requesting client)
+func (m *Manager) InitiateMultipartUploadForClient(ctx context.Context, uploadID, objectKey, bucketName, clientID string) (err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.InitiateMultipartUpload")
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"object_key":  objectKey,
 		"bucket_name": bucketName,
+		"client_id":   clientID,
 	}).Debug("Initiating multipart upload")
 
 	// Check for none provider - no session needed for pass-through
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithField("upload_id", uploadID).Debug("Using none provider - no multipart session needed")
+		m.logger.WithContext(ctx).WithField("upload_id", uploadID).Debug("Using none provider - no multipart session needed")
 		return nil // No session setup needed for none provider
 	}
 
-	_, err := m.multipartOps.InitiateSession(ctx, uploadID, objectKey, bucketName)
+	_, err = m.multipartOps.InitiateClientSession(ctx, uploadID, objectKey, bucketName, clientID)
 	return err
 }
 
+// TakeOverStaleMultipartUploads applies the configured stale session policy
+// (optimizations.multipart_stale_session_policy) before clientID initiates a
+// new multipart upload for bucketName/objectKey. The caller has to abort the
+// returned AbortUploadIDs on the backend and, if ReuseUploadID is set, hand
+// out that upload instead of creating a new one.
+func (m *Manager) TakeOverStaleMultipartUploads(ctx context.Context, bucketName, objectKey, clientID string) *StaleSessionTakeover {
+	return m.multipartOps.TakeOverStaleSessions(ctx, bucketName, objectKey, clientID, m.config.Optimizations.MultipartStaleSessionPolicy)
+}
+
 // UploadPartStreaming encrypts and processes a multipart upload part from a reader
 func (m *Manager) UploadPartStreaming(ctx context.Context, uploadID string, partNumber int, reader io.Reader) (*EncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"part_number": partNumber,
 	}).Debug("Processing streaming multipart upload part (memory-optimized)")
@@ -302,28 +378,60 @@ func (m *Manager) UploadPartStreaming(ctx context.Context, uploadID string, part
 	}, nil
 }
 
+// UploadPartKnownLength encrypts a multipart upload part of size plaintext
+// bytes as it is read from reader, without buffering it when it is the next
+// part of the upload. EncryptedData then implements io.Closer and has to be
+// closed once the part was uploaded; see MultipartOperations.ProcessPartStream.
+func (m *Manager) UploadPartKnownLength(ctx context.Context, uploadID string, partNumber int, size int64, reader io.Reader) (_ *EncryptionResult, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.UploadPart", attribute.Int("aws.s3.part_number", partNumber), attribute.Int64("s3ep.part_size", size))
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":   uploadID,
+		"part_number": partNumber,
+		"part_size":   size,
+	}).Debug("Processing multipart upload part of known length")
+
+	// Check for none provider - complete pass-through with no encryption or metadata
+	if m.providerManager.IsNoneProvider() {
+		return &EncryptionResult{
+			EncryptedData: reader,
+			Metadata:      make(map[string]string),
+		}, nil
+	}
+
+	result, err := m.multipartOps.ProcessPartStream(ctx, uploadID, partNumber, size, m.countEncrypted(ctx, reader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to process multipart part: %w", err)
+	}
+	return result, nil
+}
+
 // StorePartETag stores the ETag for a multipart upload part
 func (m *Manager) StorePartETag(uploadID string, partNumber int, etag string) error {
 	return m.multipartOps.StorePartETag(uploadID, partNumber, etag)
 }
 
 // CompleteMultipartUpload finalizes a multipart upload and returns final metadata
-func (m *Manager) CompleteMultipartUpload(ctx context.Context, uploadID string, parts map[int]string) (map[string]string, error) {
-	m.logger.WithFields(logrus.Fields{
+func (m *Manager) CompleteMultipartUpload(ctx context.Context, uploadID string, parts map[int]string) (_ map[string]string, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.CompleteMultipartUpload", attribute.Int("s3ep.parts", len(parts)))
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"parts_count": len(parts),
 	}).Debug("Completing multipart upload")
 
 	// Check for none provider - no metadata to generate
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithField("upload_id", uploadID).Debug("Using none provider - no multipart metadata to generate")
+		m.logger.WithContext(ctx).WithField("upload_id", uploadID).Debug("Using none provider - no multipart metadata to generate")
 		return make(map[string]string), nil // Return empty metadata
 	}
 
 	// Store all part ETags
 	for partNumber, etag := range parts {
 		if err := m.StorePartETag(uploadID, partNumber, etag); err != nil {
-			m.logger.WithFields(logrus.Fields{
+			m.logger.WithContext(ctx).WithFields(logrus.Fields{
 				"upload_id":   uploadID,
 				"part_number": partNumber,
 				"etag":        etag,
@@ -333,12 +441,20 @@ func (m *Manager) CompleteMultipartUpload(ctx context.Context, uploadID string,
 		}
 	}
 
-	return m.multipartOps.FinalizeSession(ctx, uploadID)
+	// Uploads encrypted in parallel are authenticated over the completed parts
+	var partNumbers []int
+	if len(parts) > 0 {
+		partNumbers = slices.Sorted(maps.Keys(parts))
+	}
+	return m.multipartOps.FinalizeSessionWithParts(ctx, uploadID, partNumbers)
 }
 
 // AbortMultipartUpload cancels a multipart upload and cleans up resources
-func (m *Manager) AbortMultipartUpload(ctx context.Context, uploadID string) error {
-	m.logger.WithField("upload_id", uploadID).Debug("Aborting multipart upload")
+func (m *Manager) AbortMultipartUpload(ctx context.Context, uploadID string) (err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.AbortMultipartUpload")
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithField("upload_id", uploadID).Debug("Aborting multipart upload")
 	return m.multipartOps.AbortSession(ctx, uploadID)
 }
 
@@ -356,7 +472,7 @@ func (m *Manager) GetMultipartUploadState(uploadID string) (*MultipartSession, e
 
 // CreateEncryptionReader creates a reader that encrypts data on-the-fly
 func (m *Manager) CreateEncryptionReader(ctx context.Context, reader io.Reader, objectKey string) (io.Reader, map[string]string, error) {
-	m.logger.WithField("object_key", objectKey).Debug("Creating encryption reader")
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Creating encryption reader")
 
 	// Convert to bufio.Reader
 	var bufReader *bufio.Reader
@@ -368,7 +484,7 @@ func (m *Manager) CreateEncryptionReader(ctx context.Context, reader io.Reader,
 
 	// Check for none provider
 	if m.providerManager.IsNoneProvider() {
-		m.logger.WithField("object_key", objectKey).Debug("Using none provider - streaming pass-through")
+		m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none provider - streaming pass-through")
 		return bufReader, make(map[string]string), nil
 	}
 
@@ -378,7 +494,7 @@ func (m *Manager) CreateEncryptionReader(ctx context.Context, reader io.Reader,
 
 // CreateEncryptionReaderBuffered creates a bufio.Reader that encrypts data on-the-fly
 func (m *Manager) CreateEncryptionReaderBuffered(ctx context.Context, reader io.Reader, objectKey string) (*bufio.Reader, map[string]string, error) {
-	m.logger.WithField("object_key", objectKey).Debug("Creating buffered encryption reader")
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Creating buffered encryption reader")
 
 	encReader, metadata, err := m.CreateEncryptionReader(ctx, reader, objectKey)
 	if err != nil {
@@ -394,7 +510,7 @@ func (m *Manager) CreateEncryptionReaderBuffered(ctx context.Context, reader io.
 
 // CreateDecryptionReader creates a reader that decrypts data on-the-fly
 func (m *Manager) CreateDecryptionReader(ctx context.Context, reader io.Reader, metadata map[string]string) (io.Reader, error) {
-	m.logger.Debug("Creating decryption reader")
+	m.logger.WithContext(ctx).Debug("Creating decryption reader")
 
 	// Convert to bufio.Reader
 	var bufReader *bufio.Reader
@@ -405,12 +521,16 @@ func (m *Manager) CreateDecryptionReader(ctx context.Context, reader io.Reader,
 	}
 
 	// Direct call to internal method
-	return m.createDecryptionReaderWithSizeInternal(ctx, bufReader, metadata, "", -1)
+	decReader, err := m.createDecryptionReaderWithSizeInternal(ctx, bufReader, metadata, "", -1)
+	if err != nil {
+		return nil, err
+	}
+	return m.countDecrypted(ctx, decReader, metadata), nil
 }
 
 // CreateDecryptionReaderBuffered creates a bufio.Reader that decrypts data on-the-fly
 func (m *Manager) CreateDecryptionReaderBuffered(ctx context.Context, reader io.Reader, metadata map[string]string) (*bufio.Reader, error) {
-	m.logger.Debug("Creating buffered decryption reader")
+	m.logger.WithContext(ctx).Debug("Creating buffered decryption reader")
 
 	decReader, err := m.CreateDecryptionReader(ctx, reader, metadata)
 	if err != nil {
@@ -446,6 +566,11 @@ func (m *Manager) GetLoadedProviders() []ProviderSummary {
 	return m.providerManager.GetLoadedProviders()
 }
 
+// AddProviders registers providers added to the configuration by a reload
+func (m *Manager) AddProviders(providers []config.EncryptionProvider) error {
+	return m.providerManager.AddProviders(providers)
+}
+
 // GetProvider returns a provider by alias
 func (m *Manager) GetProvider(_ string) (encryption.EncryptionProvider, bool) {
 	// In the modular architecture, we don't expose individual providers
@@ -467,7 +592,7 @@ func (m *Manager) GetStreamingSegmentSize() int64 {
 
 // UploadPartStreamingBuffer encrypts and uploads a part using true streaming with segment buffering
 func (m *Manager) UploadPartStreamingBuffer(ctx context.Context, uploadID string, partNumber int, reader io.Reader, segmentSize int64, onSegmentReady func([]byte) error) error {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":    uploadID,
 		"part_number":  partNumber,
 		"segment_size": segmentSize,
@@ -533,9 +658,66 @@ func (m *Manager) FilterMetadataForClient(metadata map[string]string) map[string
 	return m.metadataManager.FilterMetadataForClient(metadata)
 }
 
-// RotateKEK rotates the Key Encryption Key (not implemented)
-func (m *Manager) RotateKEK(_ context.Context) error {
-	return fmt.Errorf("KEK rotation not implemented in Manager")
+// RewrapDEK re-encrypts the DEK of an object with the active provider. KEKs
+// are rotated by making a new provider active; objects written before keep
+// the fingerprint of the old one until their DEK is re-wrapped. Only the
+// encrypted DEK and its KEK metadata change, the ciphertext stays valid.
+//
+// DEKs of the active provider, of one of encryption.selectable_providers or
+// of a tenant provider stay with it; for providers whose KEK has versions, like vault-transit,
+// they are moved to the latest version inside the provider instead.
+//
+// It returns the updated copy of metadata and true, or metadata unchanged and
+// false for objects that are not encrypted or need no re-wrap.
+func (m *Manager) RewrapDEK(ctx context.Context, metadata map[string]string, objectKey string) (map[string]string, bool, error) {
+	encryptedDEK, err := m.metadataManager.GetEncryptedDEK(metadata)
+	if err != nil {
+		return metadata, false, nil
+	}
+	fingerprint, err := m.metadataManager.GetFingerprint(metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	activeFingerprint := m.providerManager.GetActiveFingerprint()
+	if fingerprint == "none-provider-fingerprint" {
+		return metadata, false, nil
+	}
+	// The active provider, one selected by the client for this object or
+	// one dedicated to a tenant: the DEK stays with it, unless its KEK has a
+	// newer version
+	if fingerprint == activeFingerprint || m.providerManager.isSelectableFingerprint(fingerprint) || m.providerManager.tenantOfFingerprint(fingerprint) != nil {
+		return m.rewrapKeyVersion(ctx, metadata, encryptedDEK, fingerprint, objectKey)
+	}
+	if m.providerManager.IsNoneProvider() {
+		return nil, false, fmt.Errorf("cannot re-wrap DEKs while the none provider is active")
+	}
+
+	dek, err := m.providerManager.DecryptDEK(ctx, encryptedDEK, fingerprint, objectKey)
+	if err != nil {
+		return nil, false, err
+	}
+	rewrapped, err := m.providerManager.EncryptDEK(ctx, dek, objectKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	updated := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		updated[key] = value
+	}
+	prefix := m.metadataManager.GetMetadataPrefix()
+	updated[prefix+"encrypted-dek"] = base64.StdEncoding.EncodeToString(rewrapped)
+	updated[prefix+"kek-fingerprint"] = activeFingerprint
+	updated[prefix+"kek-algorithm"] = m.providerManager.GetActiveProviderAlgorithm()
+	delete(updated, prefix+"kek-version")
+	m.providerManager.recordKEKVersion(m.metadataManager, updated)
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"object_key":      objectKey,
+		"old_fingerprint": fingerprint,
+		"new_fingerprint": activeFingerprint,
+	}).Debug("Re-wrapped DEK with the active provider")
+	return updated, true, nil
 }
 
 // ===== MAINTENANCE OPERATIONS =====
@@ -547,6 +729,22 @@ func (m *Manager) CleanupExpiredSessions(maxAge time.Duration) int {
 	return cleaned
 }
 
+// ListMultipartSessions returns the sessions in the session store, including
+// those of other replicas sharing it
+func (m *Manager) ListMultipartSessions(ctx context.Context) ([]*SessionState, error) {
+	return m.multipartOps.store.List(ctx)
+}
+
+// DiscardMultipartSession removes the session of uploadID without touching
+// the upload on the backend. A missing session is not an error.
+func (m *Manager) DiscardMultipartSession(ctx context.Context, uploadID string) error {
+	_, _, err := m.multipartOps.removeSession(ctx, uploadID, "discarded")
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
 // ClearCaches clears all internal caches for memory management
 func (m *Manager) ClearCaches() {
 	m.providerManager.ClearKeyCache()
@@ -607,9 +805,54 @@ func (m *Manager) startBackgroundCleanup() {
 	}()
 }
 
+// saveSessions writes the sessions of the memory store to
+// session_store.persist_path, if set, so the next start can continue their
+// uploads. Shared stores keep their sessions anyway.
+func (m *Manager) saveSessions() {
+	path := m.config.SessionStore.PersistPath
+	store, ok := m.multipartOps.store.(*MemorySessionStore)
+	if path == "" || !ok {
+		return
+	}
+	saved, err := store.Save(path)
+	entry := m.logger.WithFields(logrus.Fields{
+		"path":     path,
+		"sessions": saved,
+	})
+	if err != nil {
+		entry.WithError(err).Error("Failed to save multipart sessions, their uploads cannot be completed after the restart")
+		return
+	}
+	entry.Info("Saved multipart sessions")
+}
+
+// restoreSessions loads the sessions saved by saveSessions. A missing file
+// is not an error; sessions that cannot be read are lost and the proxy
+// starts with an empty store.
+func (m *Manager) restoreSessions() {
+	path := m.config.SessionStore.PersistPath
+	store, ok := m.multipartOps.store.(*MemorySessionStore)
+	if path == "" || !ok {
+		return
+	}
+	restored, err := store.Restore(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	entry := m.logger.WithFields(logrus.Fields{
+		"path":     path,
+		"sessions": restored,
+	})
+	if err != nil {
+		entry.WithError(err).Warn("Failed to restore the saved multipart sessions")
+		return
+	}
+	entry.Info("Restored saved multipart sessions")
+}
+
 // Shutdown gracefully shuts down the manager and stops background cleanup
 func (m *Manager) Shutdown(ctx context.Context) error {
-	m.logger.Info("Shutting down encryption manager")
+	m.logger.WithContext(ctx).Info("Shutting down encryption manager")
 
 	// Cancel background cleanup
 	if m.cleanupCancel != nil {
@@ -625,11 +868,18 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 
 	select {
 	case <-done:
-		m.logger.Debug("Background cleanup stopped successfully")
+		m.logger.WithContext(ctx).Debug("Background cleanup stopped successfully")
 	case <-ctx.Done():
-		m.logger.Warn("Timeout waiting for background cleanup to stop")
+		m.logger.WithContext(ctx).Warn("Timeout waiting for background cleanup to stop")
+	}
+
+	m.saveSessions()
+	if err := m.multipartOps.store.Close(); err != nil {
+		m.logger.WithContext(ctx).WithError(err).Warn("Failed to close multipart session store")
+	}
+	if err := m.providerManager.SaveKeyCache(ctx); err != nil {
+		m.logger.WithContext(ctx).WithError(err).Warn("Failed to save the DEK cache")
 	}
 
 	return nil
 }
-
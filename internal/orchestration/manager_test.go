@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
 )
 
 // calculateSHA256ForManagerTest computes SHA256 hash of data for test comparisons
@@ -501,3 +502,184 @@ func TestManager_NoneProvider(t *testing.T) {
 		assert.Equal(t, calculateSHA256ForManagerTest(originalData), calculateSHA256ForManagerTest(decryptedData))
 	})
 }
+
+func TestManager_RewrapDEK(t *testing.T) {
+	oldProvider := config.EncryptionProvider{
+		Alias:  "kek-2025",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+	}
+	newProvider := config.EncryptionProvider{
+		Alias:  "kek-2026",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+	}
+	newManager := func(active string, providers ...config.EncryptionProvider) *Manager {
+		manager, err := NewManager(&config.Config{
+			Encryption: config.EncryptionConfig{
+				EncryptionMethodAlias: active,
+				Providers:             providers,
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+		return manager
+	}
+
+	ctx := context.Background()
+	plaintext := bytes.Repeat([]byte("rotate me "), 500)
+	result, err := newManager("kek-2025", oldProvider).EncryptData(ctx, bufio.NewReader(bytes.NewReader(plaintext)), "docs/report.pdf")
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+
+	// kek-2026 becomes active, kek-2025 stays configured to unwrap old DEKs
+	rotating := newManager("kek-2026", newProvider, oldProvider)
+	metadata, changed, err := rotating.RewrapDEK(ctx, result.Metadata, "docs/report.pdf")
+	require.NoError(t, err)
+	require.True(t, changed)
+	assert.NotEqual(t, result.Metadata["s3ep-encrypted-dek"], metadata["s3ep-encrypted-dek"])
+	assert.NotEqual(t, result.Metadata["s3ep-kek-fingerprint"], metadata["s3ep-kek-fingerprint"])
+	assert.Equal(t, result.Metadata["s3ep-aes-iv"], metadata["s3ep-aes-iv"])
+
+	_, changed, err = rotating.RewrapDEK(ctx, metadata, "docs/report.pdf")
+	require.NoError(t, err)
+	assert.False(t, changed, "a DEK wrapped with the active provider is current")
+
+	// The old KEK can be removed once every DEK was re-wrapped
+	decrypted, err := newManager("kek-2026", newProvider).DecryptData(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), metadata, "docs/report.pdf")
+	require.NoError(t, err)
+	data, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, data)
+
+	_, changed, err = rotating.RewrapDEK(ctx, map[string]string{"owner": "alice"}, "plain.txt")
+	require.NoError(t, err)
+	assert.False(t, changed, "unencrypted objects are left alone")
+}
+
+// stubKeyVersioner marks a DEK wrapped with version 2 by a "v2:" prefix
+type stubKeyVersioner struct{}
+
+func (stubKeyVersioner) KeyVersion(encryptedDEK []byte) (int, error) {
+	if bytes.HasPrefix(encryptedDEK, []byte("v2:")) {
+		return 2, nil
+	}
+	return 1, nil
+}
+
+func (stubKeyVersioner) LatestKeyVersion(context.Context) (int, error) { return 2, nil }
+
+func (stubKeyVersioner) RewrapDEK(_ context.Context, encryptedDEK []byte) ([]byte, error) {
+	return append([]byte("v2:"), encryptedDEK...), nil
+}
+
+func TestManager_RewrapDEK_KeyVersion(t *testing.T) {
+	manager, err := NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "versioned",
+			Providers: []config.EncryptionProvider{{
+				Alias:  "versioned",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	fingerprint := manager.providerManager.GetActiveFingerprint()
+	manager.providerManager.keyVersioners[fingerprint] = stubKeyVersioner{}
+
+	ctx := context.Background()
+	result, err := manager.EncryptData(ctx, bufio.NewReader(bytes.NewReader([]byte("versioned"))), "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "1", result.Metadata["s3ep-kek-version"])
+
+	// The DEK stays with the active provider, on the latest KEK version
+	metadata, changed, err := manager.RewrapDEK(ctx, result.Metadata, "a.txt")
+	require.NoError(t, err)
+	require.True(t, changed)
+	assert.Equal(t, "2", metadata["s3ep-kek-version"])
+	assert.Equal(t, fingerprint, metadata["s3ep-kek-fingerprint"])
+	assert.NotEqual(t, result.Metadata["s3ep-encrypted-dek"], metadata["s3ep-encrypted-dek"])
+
+	_, changed, err = manager.RewrapDEK(ctx, metadata, "a.txt")
+	require.NoError(t, err)
+	assert.False(t, changed, "a DEK wrapped with the latest version is current")
+}
+
+func TestManager_ChaCha20DataCipher(t *testing.T) {
+	for _, integrity := range []string{"strict", "off"} {
+		t.Run("integrity "+integrity, func(t *testing.T) {
+			cfg := createTestMultipartConfig()
+			cfg.Encryption.IntegrityVerification = integrity
+			cfg.Encryption.Providers[0].DataCipher = "chacha20"
+			manager, err := NewManager(cfg)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+			ctx := context.Background()
+			plaintext := bytes.Repeat([]byte("chacha20 payload "), 1000)
+
+			ciphertext, metadata := encryptWithContext(t, manager, ctx, plaintext, factory.ContentTypeWhole)
+			assert.Equal(t, "chacha20-poly1305", metadata["s3ep-dek-algorithm"])
+			assert.Len(t, ciphertext, len(plaintext)+28)
+			decrypted, err := decryptWithContext(manager, ctx, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			ciphertext, metadata = encryptWithContext(t, manager, ctx, plaintext, factory.ContentTypeMultipart)
+			assert.Equal(t, "xchacha20", metadata["s3ep-dek-algorithm"])
+			assert.Len(t, ciphertext, len(plaintext))
+			decrypted, err = decryptWithContext(manager, ctx, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			// Multipart uploads continue one XChaCha20 stream across parts
+			require.NoError(t, manager.InitiateMultipartUpload(ctx, "upload-1", "tenant/object.bin", "bucket"))
+			var uploaded []byte
+			for part := 1; part <= 2; part++ {
+				result, err := manager.UploadPart(ctx, "upload-1", part, bufio.NewReader(bytes.NewReader(plaintext)))
+				require.NoError(t, err)
+				assert.Equal(t, "xchacha20", result.Algorithm)
+				encryptedPart, err := io.ReadAll(result.EncryptedDataReader)
+				require.NoError(t, err)
+				uploaded = append(uploaded, encryptedPart...)
+				require.NoError(t, manager.StorePartETag("upload-1", part, fmt.Sprintf("etag-%d", part)))
+			}
+			metadata, err = manager.CompleteMultipartUpload(ctx, "upload-1", map[int]string{1: "etag-1", 2: "etag-2"})
+			require.NoError(t, err)
+			assert.Equal(t, "xchacha20", metadata["s3ep-dek-algorithm"])
+			decrypted, err = decryptWithContext(manager, ctx, uploaded, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, append(bytes.Clone(plaintext), plaintext...), decrypted)
+
+			assert.False(t, SelfTestFailed(manager.SelfTest(ctx)))
+		})
+	}
+}
+
+func TestManager_AESGCMSIVDataCipher(t *testing.T) {
+	cfg := createTestMultipartConfig()
+	cfg.Encryption.Providers[0].DataCipher = "aes-gcm-siv"
+	manager, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	ctx := context.Background()
+	plaintext := bytes.Repeat([]byte("gcm-siv payload "), 1000)
+
+	ciphertext, metadata := encryptWithContext(t, manager, ctx, plaintext, factory.ContentTypeWhole)
+	assert.Equal(t, "aes-gcm-siv", metadata["s3ep-dek-algorithm"])
+	assert.Len(t, ciphertext, len(plaintext)+28)
+	decrypted, err := decryptWithContext(manager, ctx, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// Streams keep aes-ctr
+	ciphertext, metadata = encryptWithContext(t, manager, ctx, plaintext, factory.ContentTypeMultipart)
+	assert.Equal(t, "aes-ctr", metadata["s3ep-dek-algorithm"])
+	decrypted, err = decryptWithContext(manager, ctx, ciphertext, metadata)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	assert.False(t, SelfTestFailed(manager.SelfTest(ctx)))
+}
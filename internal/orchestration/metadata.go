@@ -3,11 +3,13 @@ package orchestration
 import (
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 )
 
 // MetadataManager handles all encryption metadata operations with comprehensive functionality
@@ -51,6 +53,7 @@ func (mm *MetadataManager) BuildMetadataForEncryption(_, encryptedDEK, iv []byte
 	metadata[mm.prefix+"dek-algorithm"] = algorithm
 	metadata[mm.prefix+"kek-fingerprint"] = fingerprint
 	metadata[mm.prefix+"kek-algorithm"] = kekAlgorithm
+	mm.SetFormatVersion(metadata, FormatVersionContinuous)
 
 	// Add IV if provided
 	if len(iv) > 0 {
@@ -156,6 +159,17 @@ func (mm *MetadataManager) GetAlgorithm(metadata map[string]string) (string, err
 	return "", fmt.Errorf("algorithm not found in metadata")
 }
 
+// streamAlgorithm returns the stream cipher (aes-ctr or xchacha20) an object
+// or upload session was encrypted with. Objects from before the algorithm was
+// recorded are aes-ctr.
+func (mm *MetadataManager) streamAlgorithm(metadata map[string]string) string {
+	algorithm, err := mm.GetAlgorithm(metadata)
+	if err != nil || !encryption.IsStreamingAlgorithm(algorithm) {
+		return string(encryption.EncryptionTypeAESCTR)
+	}
+	return algorithm
+}
+
 // GetFingerprint extracts the KEK fingerprint from metadata
 func (mm *MetadataManager) GetFingerprint(metadata map[string]string) (string, error) {
 	// Try with prefix first
@@ -243,12 +257,46 @@ func (mm *MetadataManager) SetHMAC(metadata map[string]string, hmacBytes []byte)
 	mm.logger.WithField("hmac_size", len(hmacBytes)).Debug("Set HMAC in metadata")
 }
 
+// GetHMACAlgorithm returns the integrity algorithm recorded in metadata.
+// Objects without one were written with hmac-sha256.
+func (mm *MetadataManager) GetHMACAlgorithm(metadata map[string]string) string {
+	if algorithm, exists := metadata[mm.prefix+"hmac-algorithm"]; exists && algorithm != "" {
+		return algorithm
+	}
+	return config.IntegrityAlgorithmHMACSHA256
+}
+
+// SetHMACAlgorithm records the integrity algorithm in metadata. hmac-sha256 is
+// not recorded, so its objects keep the metadata of earlier versions.
+func (mm *MetadataManager) SetHMACAlgorithm(metadata map[string]string, algorithm string) {
+	if algorithm == "" || algorithm == config.IntegrityAlgorithmHMACSHA256 {
+		return
+	}
+	metadata[mm.prefix+"hmac-algorithm"] = algorithm
+}
+
 // HasHMAC checks if HMAC exists in metadata
 func (mm *MetadataManager) HasHMAC(metadata map[string]string) bool {
 	_, exists := metadata[mm.prefix+"hmac"]
 	return exists
 }
 
+// GetEncryptionContext returns the canonical encryption context stored in
+// metadata, or "" for objects encrypted without one
+func (mm *MetadataManager) GetEncryptionContext(metadata map[string]string) string {
+	return metadata[mm.prefix+"encryption-context"]
+}
+
+// SetEncryptionContext stores the canonical encryption context in metadata
+func (mm *MetadataManager) SetEncryptionContext(metadata map[string]string, canonical string) {
+	metadata[mm.prefix+"encryption-context"] = canonical
+}
+
+// SetKEKVersion stores the version of a versioned KEK the DEK was wrapped with
+func (mm *MetadataManager) SetKEKVersion(metadata map[string]string, version int) {
+	metadata[mm.prefix+"kek-version"] = strconv.Itoa(version)
+}
+
 // ValidateEncryptionMetadata validates that all required encryption metadata is present
 func (mm *MetadataManager) ValidateEncryptionMetadata(metadata map[string]string) error {
 	requiredKeys := []string{"encrypted-dek", "dek-algorithm", "kek-fingerprint", "kek-algorithm"}
@@ -310,8 +358,15 @@ func (mm *MetadataManager) IsEncryptionMetadata(key string) bool {
 		"aes-iv",
 		"kek-algorithm",
 		"kek-fingerprint",
+		"kek-version",
 		"hmac",
+		"hmac-algorithm",
 		"encryption-mode",
+		"encryption-context",
+		"format-version",
+		"part-size",
+		"part-layout",
+		"segment-size",
 		"content-type",
 		"algorithm",
 	}
@@ -5,14 +5,18 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/streaming"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
@@ -32,28 +36,47 @@ type MultipartSession struct {
 	ObjectKey      string
 	BucketName     string
 	DEK            []byte
+	EncryptedDEK   []byte // DEK wrapped by the key encryption provider
 	IV             []byte
 	KeyFingerprint string
 	PartETags      map[int]string
 	HMACCalculator *validation.HMACCalculator
 	CreatedAt      time.Time
+	ClientID       string // Access key ID of the client that initiated the upload (may be empty)
 
 	// Additional fields for proxy handler compatibility
 	ContentType factory.ContentType
 	Metadata    map[string]string
 	IsCompleted bool
 
-	// Persistent CTR encryptor for streaming throughout the entire upload
-	CTREncryptor *dataencryption.AESCTRStatefulEncryptor
+	// Persistent CTR encryptor for streaming throughout the entire upload,
+	// AES-CTR or XChaCha20 depending on the data cipher of the provider
+	CTREncryptor dataencryption.StatefulEncryptor
 
 	// Ordered part processing
 	ExpectedPartNumber int                 // Next part number we expect to process
 	PendingParts       map[int]*PartBuffer // Parts waiting to be processed in order
 	OrderingMutex      sync.Mutex          // Separate mutex for ordering logic
 
+	// Session store state, guarded by mutex
+	nextPartNumber int   // Part after the last one encrypted and saved
+	version        int64 // Store version of the state last loaded or saved
+	stale          bool  // Local state may differ from the store and has to be reloaded
+	streamingPart  int   // Part being streamed, its keystream is reserved in the store
+	streamFailed   bool  // streamingPart broke off after using part of its keystream
+
+	// Parallel part encryption, guarded by mutex
+	parallel bool                  // Parts are encrypted independently at their own keystream offsets (format 2)
+	partSize int64                 // Declared part size, the keystream distance between parts
+	parts    map[int]*partProgress // Parts by number, replaced rather than modified on every change
+
 	mutex sync.RWMutex
 }
 
+// sessionStorePollInterval is how often a buffered part checks a shared
+// session store for parts processed by other replicas
+const sessionStorePollInterval = 100 * time.Millisecond
+
 // MultipartOperations handles encryption and decryption for multipart uploads
 // with session-based state management
 type MultipartOperations struct {
@@ -63,6 +86,9 @@ type MultipartOperations struct {
 	hmacManager     *validation.HMACManager
 	metadataManager *MetadataManager
 	config          *config.Config
+	parallelism     *dataencryption.Parallelism // nil processes parts sequentially
+	partWorkers     chan struct{}               // Bounds parts encrypted at once in parallel uploads, nil = no bound
+	store           SessionStore                // Persisted session state
 	logger          *logrus.Entry
 }
 
@@ -81,8 +107,12 @@ func NewMultipartOperations(
 		hmacManager:     hmacManager,
 		metadataManager: metadataManager,
 		config:          config,
+		store:           NewMemorySessionStore(),
 		logger:          logger,
 	}
+	if workers := config.Optimizations.MultipartEncryptionWorkers; workers > 0 {
+		mpo.partWorkers = make(chan struct{}, workers)
+	}
 
 	logger.Info("Initialized multipart operations")
 	return mpo
@@ -113,39 +143,65 @@ func NewMultipartOperations(
 // - HMAC calculator initialization is lightweight
 // - Memory usage remains constant regardless of planned upload size
 // - Thread-safe design supports concurrent part uploads
-func (mpo *MultipartOperations) InitiateSession(_ context.Context, uploadID, objectKey, bucketName string) (*MultipartSession, error) {
-	mpo.logger.WithFields(logrus.Fields{
+func (mpo *MultipartOperations) InitiateSession(ctx context.Context, uploadID, objectKey, bucketName string) (*MultipartSession, error) {
+	return mpo.InitiateClientSession(ctx, uploadID, objectKey, bucketName, "")
+}
+
+// InitiateClientSession is like InitiateSession but records the client that
+// initiated the upload, so a retried initiation can take over the session
+// (see TakeOverStaleSessions)
+func (mpo *MultipartOperations) InitiateClientSession(ctx context.Context, uploadID, objectKey, bucketName, clientID string) (*MultipartSession, error) {
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"object_key":  objectKey,
 		"bucket_name": bucketName,
+		"client_id":   clientID,
 	}).Debug("Initiating multipart upload session with streaming HMAC")
 
-	mpo.mutex.Lock()
-	defer mpo.mutex.Unlock()
+	// Check for none provider
+	if mpo.providerManager.IsNoneProvider() {
+		mpo.mutex.Lock()
+		defer mpo.mutex.Unlock()
+		if _, exists := mpo.sessions[uploadID]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateSession, uploadID)
+		}
+		return mpo.createNoneProviderSession(uploadID, objectKey, bucketName, clientID)
+	}
 
-	// Check if session already exists
-	if _, exists := mpo.sessions[uploadID]; exists {
-		return nil, fmt.Errorf("multipart upload %s already exists", uploadID)
+	// The encryption context is bound through the HMAC, like single-part CTR
+	encryptionContext, _ := encryptionContextFrom(ctx)
+	if encryptionContext != "" && !mpo.hmacManager.IsEnabled() {
+		return nil, ErrEncryptionContextUnbound
 	}
 
-	// Check for none provider
-	if mpo.providerManager.IsNoneProvider() {
-		return mpo.createNoneProviderSession(uploadID, objectKey, bucketName)
+	// Tenants are matched on the bucket of the upload
+	ctx = WithBucket(ctx, bucketName)
+	fingerprint, err := mpo.providerManager.fingerprintFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if session already exists
+	mpo.mutex.RLock()
+	_, exists := mpo.sessions[uploadID]
+	mpo.mutex.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateSession, uploadID)
 	}
 
 	// Generate DEK for this upload session
 	dek := make([]byte, 32) // 256-bit key
 	if _, err := rand.Read(dek); err != nil {
-		mpo.logger.WithError(err).Error("Failed to generate DEK for multipart session")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to generate DEK for multipart session")
 		return nil, fmt.Errorf("failed to generate DEK: %w", err)
 	}
 
 	// Create persistent CTR encryptor for this session
 	// This encryptor will be used for all parts, maintaining stream continuity
 	// The encryptor will generate its own IV which we'll use for the session
-	ctrEncryptor, err := dataencryption.NewAESCTRStatefulEncryptor(dek)
+	ctrEncryptor, err := dataencryption.NewStatefulEncryptor(mpo.providerManager.DataAlgorithm(factory.ContentTypeMultipart, fingerprint), dek)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to create CTR encryptor for multipart session")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to create CTR encryptor for multipart session")
 		return nil, fmt.Errorf("failed to create CTR encryptor: %w", err)
 	}
 
@@ -156,9 +212,9 @@ func (mpo *MultipartOperations) InitiateSession(_ context.Context, uploadID, obj
 	var hmacCalculator *validation.HMACCalculator
 	if mpo.hmacManager.IsEnabled() {
 		var err error
-		hmacCalculator, err = mpo.hmacManager.CreateCalculator(dek)
+		hmacCalculator, err = integrityCalculator(mpo.hmacManager, dek, mpo.hmacManager.AlgorithmForProvider(bucketName, mpo.providerManager.providerAliasFor(ctx)), encryptionContext)
 		if err != nil {
-			mpo.logger.WithError(err).Error("Failed to create HMAC calculator for multipart session")
+			mpo.logger.WithContext(ctx).WithError(err).Error("Failed to create HMAC calculator for multipart session")
 			return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
 		}
 	}
@@ -166,7 +222,10 @@ func (mpo *MultipartOperations) InitiateSession(_ context.Context, uploadID, obj
 	// Initialize metadata map with DEK algorithm for multipart uploads
 	metadata := make(map[string]string)
 	metadataPrefix := mpo.metadataManager.GetMetadataPrefix()
-	metadata[metadataPrefix+"dek-algorithm"] = "aes-ctr"
+	metadata[metadataPrefix+"dek-algorithm"] = ctrEncryptor.Algorithm()
+	if encryptionContext != "" {
+		mpo.metadataManager.SetEncryptionContext(metadata, encryptionContext)
+	}
 
 	session := &MultipartSession{
 		UploadID:           uploadID,
@@ -174,20 +233,46 @@ func (mpo *MultipartOperations) InitiateSession(_ context.Context, uploadID, obj
 		BucketName:         bucketName,
 		DEK:                dek,
 		IV:                 iv,
-		KeyFingerprint:     mpo.providerManager.GetActiveFingerprint(),
+		KeyFingerprint:     fingerprint,
 		PartETags:          make(map[int]string),
 		HMACCalculator:     hmacCalculator,
 		CreatedAt:          time.Now(),
+		ClientID:           clientID,
 		ContentType:        factory.ContentTypeMultipart,
 		Metadata:           metadata,
 		CTREncryptor:       ctrEncryptor,
 		ExpectedPartNumber: 1,
 		PendingParts:       make(map[int]*PartBuffer),
+		nextPartNumber:     1,
+		parallel:           mpo.partWorkers != nil,
+		partSize:           partSizeFrom(ctx),
+		parts:              make(map[int]*partProgress),
+	}
+
+	// Wrap the DEK now, so the session can be continued from the store
+	session.EncryptedDEK, err = mpo.providerManager.EncryptDEKWith(ctx, fingerprint, dek, objectKey)
+	if err != nil {
+		mpo.releaseSession(session, "failed")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to encrypt DEK for multipart session")
+		return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
 	}
 
+	state, err := mpo.sessionState(session, sessionProgress{NextPartNumber: 1})
+	if err == nil {
+		err = mpo.store.Create(ctx, state)
+	}
+	if err != nil {
+		mpo.releaseSession(session, "failed")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to save multipart session")
+		return nil, fmt.Errorf("failed to save multipart session: %w", err)
+	}
+	session.version = state.Version
+
+	mpo.mutex.Lock()
 	mpo.sessions[uploadID] = session
+	mpo.mutex.Unlock()
 
-	mpo.logger.WithFields(logrus.Fields{
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":       uploadID,
 		"object_key":      objectKey,
 		"bucket_name":     bucketName,
@@ -215,14 +300,14 @@ func (mpo *MultipartOperations) InitiateSession(_ context.Context, uploadID, obj
 // - Only stores parts waiting for their sequence turn
 // - Processed parts are immediately removed from memory
 // - No buffering of already processed data
-func (mpo *MultipartOperations) ProcessPart(_ context.Context, uploadID string, partNumber int, dataReader *bufio.Reader) (*EncryptionResult, error) {
+func (mpo *MultipartOperations) ProcessPart(ctx context.Context, uploadID string, partNumber int, dataReader *bufio.Reader) (*EncryptionResult, error) {
 	// Validate part number (S3 allows 1-10000)
 	if partNumber < 1 || partNumber > 10000 {
 		return nil, fmt.Errorf("invalid part number %d: must be between 1 and 10000", partNumber)
 	}
 
 	// Get session
-	session, err := mpo.getSession(uploadID)
+	session, err := mpo.getSession(ctx, uploadID)
 	if err != nil {
 		return nil, err
 	}
@@ -234,105 +319,201 @@ func (mpo *MultipartOperations) ProcessPart(_ context.Context, uploadID string,
 		return mpo.processNoneProviderPartStream(session, partNumber, dataReader)
 	}
 
+	if session.isParallel() {
+		return mpo.processPartParallel(ctx, session, partNumber, dataReader)
+	}
+
 	// For ordered processing, we need to handle parts that may arrive out of sequence
-	return mpo.processPartOrdered(session, partNumber, dataReader)
+	return mpo.processPartOrdered(ctx, session, partNumber, dataReader)
 }
 
 // processPartOrdered handles part processing with strict ordering for HMAC and CTR encryption integrity
-func (mpo *MultipartOperations) processPartOrdered(session *MultipartSession, partNumber int, dataReader *bufio.Reader) (*EncryptionResult, error) {
+func (mpo *MultipartOperations) processPartOrdered(ctx context.Context, session *MultipartSession, partNumber int, dataReader *bufio.Reader) (*EncryptionResult, error) {
 	// Pre-size a single buffer to the configured part size so the read is a
 	// single allocation instead of ~log2(partSize/512) append growths.
 	buf := bytes.NewBuffer(make([]byte, 0, int(mpo.config.GetStreamingSegmentSize())))
 	if _, err := buf.ReadFrom(dataReader); err != nil {
-		mpo.logger.WithError(err).Error("Error reading part data for ordered processing")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Error reading part data for ordered processing")
 		return nil, fmt.Errorf("failed to read part data: %w", err)
 	}
 	partData := buf.Bytes()
 	totalBytes := len(partData)
 
-	session.OrderingMutex.Lock()
+	for {
+		// Pick up parts processed by other replicas
+		if err := mpo.syncSession(ctx, session); err != nil {
+			return nil, err
+		}
+		if err := session.checkStreamFailed(); err != nil {
+			return nil, err
+		}
+
+		session.OrderingMutex.Lock()
+
+		// The keystream of an encrypted part must not be used for other data
+		if partNumber < session.ExpectedPartNumber {
+			expected := session.ExpectedPartNumber
+			session.OrderingMutex.Unlock()
+			return nil, fmt.Errorf("part %d of upload %s was already encrypted, next part is %d", partNumber, session.UploadID, expected)
+		}
+
+		// Check if this is the part we're waiting for
+		if partNumber == session.ExpectedPartNumber {
+			// Process this part immediately
+			session.OrderingMutex.Unlock()
 
-	// Check if this is the part we're waiting for
-	if partNumber == session.ExpectedPartNumber {
-		// Process this part immediately
+			// Process the part data with proper ordering
+			result, err := mpo.processPartDataInOrder(ctx, session, partNumber, partData)
+			if errors.Is(err, ErrSessionConflict) {
+				continue // another replica got ahead, reload and order again
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			// After processing, check if we can process any buffered parts
+			mpo.processBufferedPartsData(context.WithoutCancel(ctx), session)
+			return result, nil
+		}
+		// This part is out of order, buffer it
+		partBuffer := &PartBuffer{
+			PartNumber: partNumber,
+			Data:       partData,
+			ResultChan: make(chan *EncryptionResult, 1),
+			ErrorChan:  make(chan error, 1),
+		}
+
+		session.PendingParts[partNumber] = partBuffer
+		expected, buffered := session.ExpectedPartNumber, len(session.PendingParts)
 		session.OrderingMutex.Unlock()
 
-		// Process the part data with proper ordering
-		result, err := mpo.processPartDataInOrder(session, partNumber, partData)
-		if err != nil {
-			return nil, err
+		mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"upload_id":       session.UploadID,
+			"part_number":     partNumber,
+			"expected_part":   expected,
+			"buffered_parts":  buffered,
+			"part_size_bytes": totalBytes,
+		}).Debug("Buffered out-of-order part for sequential processing")
+
+		// Wait for this part to be processed in order
+		result, retry, err := mpo.waitForPart(ctx, session, partBuffer)
+		if retry || errors.Is(err, ErrSessionConflict) {
+			continue
 		}
+		return result, err
+	}
+}
 
-		// After processing, check if we can process any buffered parts
-		mpo.processBufferedPartsData(session)
-		return result, nil
+// waitForPart waits until a buffered part has been processed. With a shared
+// session store the earlier parts may be processed by other replicas, so the
+// part is taken back periodically and retry reports that it has to be
+// ordered again against the stored session.
+func (mpo *MultipartOperations) waitForPart(ctx context.Context, session *MultipartSession, partBuffer *PartBuffer) (*EncryptionResult, bool, error) {
+	var poll <-chan time.Time
+	if mpo.sharedStore() {
+		ticker := time.NewTicker(sessionStorePollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
 	}
-	// This part is out of order, buffer it
-	partBuffer := &PartBuffer{
-		PartNumber: partNumber,
-		Data:       partData,
-		ResultChan: make(chan *EncryptionResult, 1),
-		ErrorChan:  make(chan error, 1),
+
+	done := ctx.Done()
+	for {
+		select {
+		case result := <-partBuffer.ResultChan:
+			return result, false, nil
+		case err := <-partBuffer.ErrorChan:
+			return nil, false, err
+		case <-poll:
+			if takeBackPart(session, partBuffer) {
+				return nil, true, nil
+			}
+		case <-done:
+			if takeBackPart(session, partBuffer) {
+				return nil, false, ctx.Err()
+			}
+			done = nil // already being processed, wait for the result
+		}
 	}
+}
 
-	session.PendingParts[partNumber] = partBuffer
-	session.OrderingMutex.Unlock()
+// takeBackPart removes a buffered part that has not been picked up for
+// processing yet and reports whether it did
+func takeBackPart(session *MultipartSession, partBuffer *PartBuffer) bool {
+	session.OrderingMutex.Lock()
+	defer session.OrderingMutex.Unlock()
 
-	mpo.logger.WithFields(logrus.Fields{
-		"upload_id":       session.UploadID,
-		"part_number":     partNumber,
-		"expected_part":   session.ExpectedPartNumber,
-		"buffered_parts":  len(session.PendingParts),
-		"part_size_bytes": totalBytes,
-	}).Debug("Buffered out-of-order part for sequential processing")
-
-	// Wait for this part to be processed in order
-	select {
-	case result := <-partBuffer.ResultChan:
-		return result, nil
-	case err := <-partBuffer.ErrorChan:
-		return nil, err
-		// Note: We don't use context timeout here as the proxy handles request timeouts
+	if session.PendingParts[partBuffer.PartNumber] != partBuffer {
+		return false
 	}
+	delete(session.PendingParts, partBuffer.PartNumber)
+	return true
 }
 
-// processPartDataInOrder processes part data ensuring both HMAC and CTR encryption happen sequentially
-func (mpo *MultipartOperations) processPartDataInOrder(session *MultipartSession, partNumber int, partData []byte) (*EncryptionResult, error) {
+// processPartDataInOrder processes part data ensuring both HMAC and CTR encryption happen sequentially.
+// The new state is saved to the session store before the part is encrypted, so
+// a part is only ever encrypted once at its offset, whichever replica does it.
+func (mpo *MultipartOperations) processPartDataInOrder(ctx context.Context, session *MultipartSession, partNumber int, partData []byte) (*EncryptionResult, error) {
 	session.mutex.Lock()
 	defer session.mutex.Unlock()
 
+	if session.stale {
+		return nil, fmt.Errorf("%w: %s", ErrSessionConflict, session.UploadID)
+	}
+	if err := session.streamError(); err != nil {
+		return nil, err
+	}
+
 	// Use the session's persistent CTR encryptor
 	if session.CTREncryptor == nil {
-		mpo.logger.WithError(fmt.Errorf("CTR encryptor not initialized")).Error("Session CTR encryptor is nil")
+		mpo.logger.WithContext(ctx).WithError(fmt.Errorf("CTR encryptor not initialized")).Error("Session CTR encryptor is nil")
 		return nil, fmt.Errorf("CTR encryptor not initialized for session %s", session.UploadID)
 	}
 
+	// Until the new state is saved, the local state is ahead of the store
+	session.stale = true
+
 	// Update HMAC calculator with plaintext data BEFORE encryption (in correct order)
 	if mpo.hmacManager.IsEnabled() && session.HMACCalculator != nil {
 		if _, hmacErr := session.HMACCalculator.Add(partData); hmacErr != nil {
-			mpo.logger.WithError(hmacErr).Error("Failed to update HMAC during ordered processing")
+			mpo.logger.WithContext(ctx).WithError(hmacErr).Error("Failed to update HMAC during ordered processing")
 			return nil, fmt.Errorf("failed to update HMAC: %w", hmacErr)
 		}
 	}
 
+	state, err := mpo.sessionState(session, sessionProgress{
+		NextPartNumber: partNumber + 1,
+		BytesEncrypted: session.CTREncryptor.Offset() + uint64(len(partData)),
+	})
+	if err == nil {
+		err = mpo.store.Update(ctx, state)
+	}
+	if err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save multipart session")
+		return nil, fmt.Errorf("failed to save multipart session: %w", err)
+	}
+
 	// Encrypt the data with persistent CTR encryptor (maintains state across parts)
-	encryptedData, err := session.CTREncryptor.EncryptPart(partData)
+	encryptedData, err := session.CTREncryptor.EncryptPartParallel(partData, mpo.parallelism)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to encrypt part data in order")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to encrypt part data in order")
 		return nil, fmt.Errorf("failed to encrypt part: %w", err)
 	}
 
+	session.version = state.Version
+	session.nextPartNumber = partNumber + 1
+	session.stale = false
+
 	// Create minimal metadata (KEK encryption will be handled during finalization)
 	metadata := make(map[string]string)
 
 	result := &EncryptionResult{
 		EncryptedData:  bytes.NewReader(encryptedData),
 		Metadata:       metadata,
-		Algorithm:      "aes-ctr",
+		Algorithm:      session.CTREncryptor.Algorithm(),
 		KeyFingerprint: session.KeyFingerprint,
 	}
 
-	mpo.logger.WithFields(logrus.Fields{
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":       session.UploadID,
 		"part_number":     partNumber,
 		"bytes_processed": len(partData),
@@ -344,7 +525,7 @@ func (mpo *MultipartOperations) processPartDataInOrder(session *MultipartSession
 }
 
 // processBufferedPartsData checks for and processes any parts that are now in sequence
-func (mpo *MultipartOperations) processBufferedPartsData(session *MultipartSession) {
+func (mpo *MultipartOperations) processBufferedPartsData(ctx context.Context, session *MultipartSession) {
 	session.OrderingMutex.Lock()
 	defer session.OrderingMutex.Unlock()
 
@@ -366,18 +547,19 @@ func (mpo *MultipartOperations) processBufferedPartsData(session *MultipartSessi
 		session.OrderingMutex.Unlock()
 
 		// Process this part in sequence — data was already fully read when buffered
-		result, err := mpo.processPartDataInOrder(session, partBuffer.PartNumber, partBuffer.Data)
+		result, err := mpo.processPartDataInOrder(ctx, session, partBuffer.PartNumber, partBuffer.Data)
+
+		// Re-acquire the ordering mutex
+		session.OrderingMutex.Lock()
 		if err != nil {
+			// The part was not encrypted, so it is still the next one
 			partBuffer.ErrorChan <- err
-		} else {
-			partBuffer.ResultChan <- result
+			return
 		}
-
-		// Re-acquire the ordering mutex and increment expected part number
-		session.OrderingMutex.Lock()
+		partBuffer.ResultChan <- result
 		session.ExpectedPartNumber++
 
-		mpo.logger.WithFields(logrus.Fields{
+		mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"upload_id":          session.UploadID,
 			"processed_part":     partBuffer.PartNumber,
 			"next_expected":      session.ExpectedPartNumber,
@@ -388,7 +570,7 @@ func (mpo *MultipartOperations) processBufferedPartsData(session *MultipartSessi
 
 // StorePartETag stores the ETag for a completed part
 func (mpo *MultipartOperations) StorePartETag(uploadID string, partNumber int, etag string) error {
-	session, err := mpo.getSession(uploadID)
+	session, err := mpo.getSession(context.Background(), uploadID)
 	if err != nil {
 		return err
 	}
@@ -430,27 +612,47 @@ func (mpo *MultipartOperations) StorePartETag(uploadID string, partNumber int, e
 // - HMAC finalization is O(1) operation regardless of object size
 // - No additional data processing - HMAC was calculated during upload streaming
 // - Memory usage remains constant during finalization
-func (mpo *MultipartOperations) FinalizeSession(_ context.Context, uploadID string) (map[string]string, error) {
-	mpo.logger.WithField("upload_id", uploadID).Debug("Finalizing multipart upload session with HMAC")
+func (mpo *MultipartOperations) FinalizeSession(ctx context.Context, uploadID string) (map[string]string, error) {
+	return mpo.FinalizeSessionWithParts(ctx, uploadID, nil)
+}
+
+// FinalizeSessionWithParts is FinalizeSession for the parts a client
+// completes the upload with. Uploads whose parts are encrypted in parallel
+// are authenticated over exactly these parts; nil takes all encrypted parts.
+// Uploads encrypted in order always cover all encrypted parts.
+func (mpo *MultipartOperations) FinalizeSessionWithParts(ctx context.Context, uploadID string, partNumbers []int) (map[string]string, error) {
+	mpo.logger.WithContext(ctx).WithField("upload_id", uploadID).Debug("Finalizing multipart upload session with HMAC")
 
-	session, err := mpo.getSession(uploadID)
+	session, err := mpo.getSession(ctx, uploadID)
 	if err != nil {
 		return nil, err
 	}
 
-	session.mutex.Lock()
-	defer session.mutex.Unlock()
-
 	// Check for none provider
 	if mpo.providerManager.IsNoneProvider() {
 		return nil, nil // No metadata for none provider
 	}
 
-	// Encrypt the DEK for final metadata
-	encryptedDEK, err := mpo.providerManager.EncryptDEK(session.DEK, session.ObjectKey)
-	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to encrypt DEK for final metadata")
-		return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
+	// The last parts may have been processed by another replica
+	if err := mpo.syncSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if err := session.streamError(); err != nil {
+		return nil, err
+	}
+
+	// The DEK was wrapped when the session was initiated
+	encryptedDEK := session.EncryptedDEK
+	if len(encryptedDEK) == 0 {
+		encryptedDEK, err = mpo.providerManager.EncryptDEKWith(ctx, session.KeyFingerprint, session.DEK, session.ObjectKey)
+		if err != nil {
+			mpo.logger.WithContext(ctx).WithError(err).Error("Failed to encrypt DEK for final metadata")
+			return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
+		}
 	}
 
 	// Build final metadata
@@ -458,13 +660,18 @@ func (mpo *MultipartOperations) FinalizeSession(_ context.Context, uploadID stri
 		session.DEK,
 		encryptedDEK,
 		session.IV,
-		"aes-ctr",
+		mpo.metadataManager.streamAlgorithm(session.Metadata),
 		session.KeyFingerprint,
-		mpo.providerManager.GetActiveProviderAlgorithm(),
+		mpo.providerManager.ProviderAlgorithm(session.KeyFingerprint),
 		nil,
 	)
+	mpo.providerManager.recordKEKVersion(mpo.metadataManager, metadata)
+	// The context was bound into the HMAC when the upload was initiated
+	if encryptionContext := mpo.metadataManager.GetEncryptionContext(session.Metadata); encryptionContext != "" {
+		mpo.metadataManager.SetEncryptionContext(metadata, encryptionContext)
+	}
 
-	mpo.logger.WithFields(logrus.Fields{
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":   uploadID,
 		"total_parts": len(session.PartETags),
 		"part_size":   5242880, // 5MB standard part size
@@ -472,22 +679,27 @@ func (mpo *MultipartOperations) FinalizeSession(_ context.Context, uploadID stri
 
 	// Finalize and add streaming HMAC if enabled
 	// The HMAC calculator contains the cumulative hash of all parts processed in sequence
-	if mpo.hmacManager.IsEnabled() && session.HMACCalculator != nil {
+	if session.parallel {
+		if err := mpo.finalizeParts(session, metadata, partNumbers); err != nil {
+			return nil, err
+		}
+	} else if mpo.hmacManager.IsEnabled() && session.HMACCalculator != nil {
 		finalHMAC := mpo.hmacManager.FinalizeCalculator(session.HMACCalculator)
 		if len(finalHMAC) > 0 {
 			mpo.metadataManager.SetHMAC(metadata, finalHMAC)
+			mpo.metadataManager.SetHMACAlgorithm(metadata, session.HMACCalculator.Algorithm())
 
-			mpo.logger.WithFields(logrus.Fields{
+			mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 				"upload_id":   uploadID,
 				"hmac_size":   len(finalHMAC),
 				"total_parts": len(session.PartETags),
 			}).Debug("Added final streaming HMAC to metadata")
 		} else {
-			mpo.logger.WithField("upload_id", uploadID).Warn("HMAC calculator returned empty result")
+			mpo.logger.WithContext(ctx).WithField("upload_id", uploadID).Warn("HMAC calculator returned empty result")
 		}
 	}
 
-	mpo.logger.WithFields(logrus.Fields{
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"upload_id":       uploadID,
 		"object_key":      session.ObjectKey,
 		"total_parts":     len(session.PartETags),
@@ -500,18 +712,84 @@ func (mpo *MultipartOperations) FinalizeSession(_ context.Context, uploadID stri
 }
 
 // AbortSession cleans up a multipart upload session
-func (mpo *MultipartOperations) AbortSession(_ context.Context, uploadID string) error {
-	mpo.logger.WithField("upload_id", uploadID).Debug("Aborting multipart upload session")
+func (mpo *MultipartOperations) AbortSession(ctx context.Context, uploadID string) error {
+	mpo.logger.WithContext(ctx).WithField("upload_id", uploadID).Debug("Aborting multipart upload session")
 
-	mpo.mutex.Lock()
-	defer mpo.mutex.Unlock()
+	objectKey, partsCount, err := mpo.removeSession(ctx, uploadID, "aborted")
+	if errors.Is(err, ErrSessionNotFound) {
+		mpo.logger.WithContext(ctx).WithField("upload_id", uploadID).Warn("Multipart upload session not found for abort")
+	}
+	if err != nil {
+		return err
+	}
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":   uploadID,
+		"object_key":  objectKey,
+		"parts_count": partsCount,
+	}).Debug("Successfully aborted multipart upload session")
 
+	return nil
+}
+
+// CleanupSession removes a multipart upload session without logging as "abort"
+// This is used after successful completion to clean up resources
+func (mpo *MultipartOperations) CleanupSession(uploadID string) error {
+	objectKey, partsCount, err := mpo.removeSession(context.Background(), uploadID, "cleaned up")
+	if errors.Is(err, ErrSessionNotFound) {
+		mpo.logger.WithField("upload_id", uploadID).Debug("Multipart upload session not found for cleanup")
+	}
+	if err != nil {
+		return err
+	}
+
+	mpo.logger.WithFields(logrus.Fields{
+		"upload_id":   uploadID,
+		"object_key":  objectKey,
+		"parts_count": partsCount,
+	}).Debug("Successfully cleaned up multipart upload session")
+
+	return nil
+}
+
+// removeSession releases the session of uploadID and deletes it from the
+// session store. It returns the object key and the number of parts with an
+// ETag for logging.
+func (mpo *MultipartOperations) removeSession(ctx context.Context, uploadID, reason string) (string, int, error) {
+	var objectKey string
+	var partsCount int
+
+	mpo.mutex.Lock()
 	session, exists := mpo.sessions[uploadID]
+	if exists {
+		mpo.releaseSession(session, reason)
+		delete(mpo.sessions, uploadID)
+
+		session.mutex.RLock()
+		objectKey, partsCount = session.ObjectKey, len(session.PartETags)
+		session.mutex.RUnlock()
+	}
+	mpo.mutex.Unlock()
+
 	if !exists {
-		mpo.logger.WithField("upload_id", uploadID).Warn("Multipart upload session not found for abort")
-		return fmt.Errorf("multipart upload %s not found", uploadID)
+		// Sessions of other replicas are only in the store
+		state, err := mpo.store.Load(ctx, uploadID)
+		if err != nil {
+			return "", 0, err
+		}
+		objectKey = state.ObjectKey
 	}
 
+	if err := mpo.store.Delete(ctx, uploadID); err != nil {
+		return "", 0, err
+	}
+	return objectKey, partsCount, nil
+}
+
+// releaseSession wipes the key material of session and fails any parts still
+// waiting for their turn. The caller must hold mpo.mutex and remove the
+// session from mpo.sessions.
+func (mpo *MultipartOperations) releaseSession(session *MultipartSession, reason string) {
 	// Clean up sensitive data
 	if session.DEK != nil {
 		mpo.hmacManager.ClearSensitiveData(session.DEK)
@@ -535,97 +813,383 @@ func (mpo *MultipartOperations) AbortSession(_ context.Context, uploadID string)
 	for partNumber, partBuffer := range session.PendingParts {
 		// Send error to any waiting goroutines
 		select {
-		case partBuffer.ErrorChan <- fmt.Errorf("session aborted"):
+		case partBuffer.ErrorChan <- fmt.Errorf("session %s", reason):
 		default:
 		}
 		delete(session.PendingParts, partNumber)
 	}
 	session.OrderingMutex.Unlock()
+}
 
-	delete(mpo.sessions, uploadID)
+// StaleSessionTakeover is the outcome of TakeOverStaleSessions
+type StaleSessionTakeover struct {
+	// ReuseUploadID is the upload ID to hand out again instead of creating a
+	// new upload on the backend (empty if a new upload has to be created)
+	ReuseUploadID string
+	// AbortUploadIDs lists the uploads whose sessions were discarded. They
+	// still have to be aborted on the backend.
+	AbortUploadIDs []string
+}
 
-	mpo.logger.WithFields(logrus.Fields{
-		"upload_id":   uploadID,
-		"object_key":  session.ObjectKey,
-		"parts_count": len(session.PartETags),
-	}).Debug("Successfully aborted multipart upload session")
+// TakeOverStaleSessions applies policy (one of the config.StaleSessionPolicy*
+// values) to the sessions clientID already has open for bucketName/objectKey.
+// It is called before a new upload for the same target is created, e.g. when
+// a client retries CreateMultipartUpload after a network failure. Sessions of
+// other clients and sessions without a client ID are never touched. Only a
+// session bound to the encryption context of ctx is reused.
+func (mpo *MultipartOperations) TakeOverStaleSessions(ctx context.Context, bucketName, objectKey, clientID, policy string) *StaleSessionTakeover {
+	takeover := &StaleSessionTakeover{}
+	if clientID == "" || (policy != config.StaleSessionPolicyAbort && policy != config.StaleSessionPolicyReuse) {
+		return takeover
+	}
 
-	return nil
+	type staleSession struct {
+		uploadID          string
+		createdAt         time.Time
+		started           bool
+		encryptionContext string
+	}
+	candidates := make(map[string]*staleSession)
+
+	mpo.mutex.RLock()
+	for _, session := range mpo.sessions {
+		if session.ClientID == clientID && session.BucketName == bucketName && session.ObjectKey == objectKey {
+			candidates[session.UploadID] = &staleSession{
+				uploadID:          session.UploadID,
+				createdAt:         session.CreatedAt,
+				started:           session.hasParts(),
+				encryptionContext: mpo.metadataManager.GetEncryptionContext(session.Metadata),
+			}
+		}
+	}
+	mpo.mutex.RUnlock()
+
+	// Other replicas may have sessions for the same target
+	if mpo.sharedStore() {
+		states, err := mpo.store.List(ctx)
+		if err != nil {
+			mpo.logger.WithContext(ctx).WithError(err).Warn("Failed to list stored multipart sessions for takeover")
+		}
+		for _, state := range states {
+			if state.ClientID != clientID || state.BucketName != bucketName || state.ObjectKey != objectKey {
+				continue
+			}
+			if candidate, exists := candidates[state.UploadID]; exists {
+				candidate.started = candidate.started || state.NextPartNumber > 1
+			} else {
+				candidates[state.UploadID] = &staleSession{
+					uploadID:          state.UploadID,
+					createdAt:         state.CreatedAt,
+					started:           state.NextPartNumber > 1,
+					encryptionContext: mpo.metadataManager.GetEncryptionContext(state.Metadata),
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return takeover
+	}
+
+	stale := make([]*staleSession, 0, len(candidates))
+	for _, candidate := range candidates {
+		stale = append(stale, candidate)
+	}
+
+	// Newest first, so the most recent untouched session is the one reused
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].createdAt.After(stale[j].createdAt)
+	})
+
+	encryptionContext, _ := encryptionContextFrom(ctx)
+	for _, session := range stale {
+		if policy == config.StaleSessionPolicyReuse && takeover.ReuseUploadID == "" && !session.started &&
+			session.encryptionContext == encryptionContext {
+			takeover.ReuseUploadID = session.uploadID
+			continue
+		}
+
+		if _, _, err := mpo.removeSession(ctx, session.uploadID, "taken over"); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.uploadID).Warn("Failed to remove stale multipart session")
+		}
+		takeover.AbortUploadIDs = append(takeover.AbortUploadIDs, session.uploadID)
+	}
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"bucket_name":     bucketName,
+		"object_key":      objectKey,
+		"client_id":       clientID,
+		"policy":          policy,
+		"reuse_upload_id": takeover.ReuseUploadID,
+		"aborted_uploads": len(takeover.AbortUploadIDs),
+	}).Info("Took over stale multipart upload sessions")
+
+	return takeover
 }
 
-// CleanupSession removes a multipart upload session without logging as "abort"
-// This is used after successful completion to clean up resources
-func (mpo *MultipartOperations) CleanupSession(uploadID string) error {
-	mpo.mutex.Lock()
-	defer mpo.mutex.Unlock()
+// hasParts reports whether any part has been uploaded to the session
+func (s *MultipartSession) hasParts() bool {
+	s.OrderingMutex.Lock()
+	started := s.ExpectedPartNumber > 1 || len(s.PendingParts) > 0
+	s.OrderingMutex.Unlock()
+	if started {
+		return true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.PartETags) > 0 || len(s.parts) > 0
+}
 
+// GetSession returns a multipart upload session (for external access)
+func (mpo *MultipartOperations) GetSession(uploadID string) (*MultipartSession, error) {
+	return mpo.getSession(context.Background(), uploadID)
+}
+
+// getSession returns a multipart upload session (internal use). Sessions
+// started before a restart or by another replica are loaded from the store.
+func (mpo *MultipartOperations) getSession(ctx context.Context, uploadID string) (*MultipartSession, error) {
+	mpo.mutex.RLock()
 	session, exists := mpo.sessions[uploadID]
-	if !exists {
-		mpo.logger.WithField("upload_id", uploadID).Debug("Multipart upload session not found for cleanup")
-		return fmt.Errorf("multipart upload %s not found", uploadID)
+	mpo.mutex.RUnlock()
+	if exists {
+		return session, nil
 	}
 
-	// Clean up sensitive data
-	if session.DEK != nil {
-		mpo.hmacManager.ClearSensitiveData(session.DEK)
+	state, err := mpo.store.Load(ctx, uploadID)
+	if err != nil {
+		return nil, err
 	}
-	if session.IV != nil {
-		mpo.hmacManager.ClearSensitiveData(session.IV)
+	session, err = mpo.sessionFromState(ctx, state)
+	if err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", uploadID).Error("Failed to restore multipart session from store")
+		return nil, fmt.Errorf("failed to restore multipart session %s: %w", uploadID, err)
 	}
 
-	// Clean up CTR encryptor
-	if session.CTREncryptor != nil {
-		session.CTREncryptor.Cleanup()
+	mpo.mutex.Lock()
+	defer mpo.mutex.Unlock()
+	if existing, exists := mpo.sessions[uploadID]; exists {
+		// Loaded concurrently
+		mpo.releaseSession(session, "duplicate")
+		return existing, nil
 	}
+	mpo.sessions[uploadID] = session
 
-	// Clean up HMAC calculator
-	if session.HMACCalculator != nil {
-		session.HMACCalculator.Cleanup()
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":   uploadID,
+		"object_key":  session.ObjectKey,
+		"bucket_name": session.BucketName,
+		"next_part":   session.ExpectedPartNumber,
+	}).Info("Restored multipart upload session from session store")
+
+	return session, nil
+}
+
+// sharedStore reports whether other processes can change sessions in the store
+func (mpo *MultipartOperations) sharedStore() bool {
+	_, local := mpo.store.(*MemorySessionStore)
+	return !local
+}
+
+// sessionState returns the persisted form of session with the given
+// progress; the integrity state is taken from the session. The caller must
+// hold session.mutex or own the session exclusively.
+func (mpo *MultipartOperations) sessionState(session *MultipartSession, progress sessionProgress) (*SessionState, error) {
+	if session.parallel {
+		progress.Parallel = true
+		progress.PartSize = session.partSize
+		if progress.Parts == nil {
+			progress.Parts = session.parts
+		}
+		progress.NextPartNumber = 1
+		for partNumber := range progress.Parts {
+			progress.NextPartNumber = max(progress.NextPartNumber, partNumber+1)
+		}
 	}
 
-	// Clean up pending parts
-	session.OrderingMutex.Lock()
-	for partNumber, partBuffer := range session.PendingParts {
-		// Send error to any waiting goroutines
-		select {
-		case partBuffer.ErrorChan <- fmt.Errorf("session cleaned up"):
-		default:
+	var integrityAlgorithm string
+	if session.HMACCalculator != nil && session.parallel {
+		// The object MAC is built from the part MACs at completion
+		integrityAlgorithm = session.HMACCalculator.Algorithm()
+	} else if session.HMACCalculator != nil {
+		integrityAlgorithm = session.HMACCalculator.Algorithm()
+		macState, err := session.HMACCalculator.MarshalState()
+		if err != nil && !errors.Is(err, validation.ErrStateNotResumable) {
+			return nil, err
 		}
-		delete(session.PendingParts, partNumber)
+		progress.IntegrityState = macState
+	}
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode session progress: %w", err)
+	}
+	sealed, err := sealSessionSecret(session.DEK, session.UploadID, data)
+	if err != nil {
+		return nil, err
 	}
-	session.OrderingMutex.Unlock()
 
-	delete(mpo.sessions, uploadID)
+	return &SessionState{
+		UploadID:           session.UploadID,
+		ObjectKey:          session.ObjectKey,
+		BucketName:         session.BucketName,
+		ClientID:           session.ClientID,
+		CreatedAt:          session.CreatedAt,
+		KeyFingerprint:     session.KeyFingerprint,
+		EncryptedDEK:       session.EncryptedDEK,
+		IV:                 session.IV,
+		Metadata:           session.Metadata,
+		NextPartNumber:     progress.NextPartNumber,
+		IntegrityAlgorithm: integrityAlgorithm,
+		Progress:           sealed,
+		Version:            session.version,
+	}, nil
+}
 
-	mpo.logger.WithFields(logrus.Fields{
-		"upload_id":   uploadID,
-		"object_key":  session.ObjectKey,
-		"parts_count": len(session.PartETags),
-	}).Debug("Successfully cleaned up multipart upload session")
+// applySessionState resets the encryption progress of session to state. The
+// caller must hold session.mutex or own the session exclusively.
+func (mpo *MultipartOperations) applySessionState(session *MultipartSession, state *SessionState) error {
+	data, err := openSessionSecret(session.DEK, session.UploadID, state.Progress)
+	if err != nil {
+		return err
+	}
+	var progress sessionProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return fmt.Errorf("failed to decode session progress: %w", err)
+	}
+
+	ctrEncryptor, err := dataencryption.NewStatefulEncryptorAt(mpo.metadataManager.streamAlgorithm(session.Metadata), session.DEK, session.IV, progress.BytesEncrypted)
+	if err != nil {
+		return fmt.Errorf("failed to create CTR encryptor: %w", err)
+	}
 
+	var hmacCalculator *validation.HMACCalculator
+	if state.IntegrityAlgorithm != "" && progress.Parallel {
+		hmacCalculator, err = integrityCalculator(mpo.hmacManager, session.DEK, state.IntegrityAlgorithm, mpo.metadataManager.GetEncryptionContext(session.Metadata))
+		if err != nil {
+			ctrEncryptor.Cleanup()
+			return fmt.Errorf("failed to create HMAC calculator: %w", err)
+		}
+	} else if state.IntegrityAlgorithm != "" {
+		if progress.IntegrityState == nil {
+			ctrEncryptor.Cleanup()
+			return fmt.Errorf("the %s state of the session was not saved and cannot be restored", state.IntegrityAlgorithm)
+		}
+		hmacCalculator, err = mpo.hmacManager.CreateCalculatorWithAlgorithm(session.DEK, state.IntegrityAlgorithm)
+		if err == nil {
+			err = hmacCalculator.RestoreState(progress.IntegrityState)
+		}
+		if err != nil {
+			ctrEncryptor.Cleanup()
+			return fmt.Errorf("failed to restore HMAC calculator: %w", err)
+		}
+	}
+
+	if session.CTREncryptor != nil {
+		session.CTREncryptor.Cleanup()
+	}
+	if session.HMACCalculator != nil {
+		session.HMACCalculator.Cleanup()
+	}
+	session.CTREncryptor = ctrEncryptor
+	session.HMACCalculator = hmacCalculator
+	session.nextPartNumber = progress.NextPartNumber
+	session.streamingPart = progress.StreamingPart
+	session.streamFailed = progress.StreamFailed
+	session.parallel = progress.Parallel
+	session.partSize = progress.PartSize
+	if session.partSize == 0 {
+		session.partSize = maxPartSize
+	}
+	session.parts = progress.Parts
+	if session.parts == nil {
+		session.parts = make(map[int]*partProgress)
+	}
+	if progress.StreamingPart != 0 {
+		// Still being streamed by another replica, later parts wait for it
+		session.nextPartNumber = progress.StreamingPart
+	}
+	session.version = state.Version
+	session.stale = false
 	return nil
 }
 
-// GetSession returns a multipart upload session (for external access)
-func (mpo *MultipartOperations) GetSession(uploadID string) (*MultipartSession, error) {
-	return mpo.getSession(uploadID)
+// sessionFromState creates a session from its persisted form
+func (mpo *MultipartOperations) sessionFromState(ctx context.Context, state *SessionState) (*MultipartSession, error) {
+	// The unwrapped DEK is owned by the DEK cache, the session needs its own copy
+	cachedDEK, err := mpo.providerManager.DecryptDEK(ctx, state.EncryptedDEK, state.KeyFingerprint, state.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DEK: %w", err)
+	}
+
+	metadata := state.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	session := &MultipartSession{
+		UploadID:       state.UploadID,
+		ObjectKey:      state.ObjectKey,
+		BucketName:     state.BucketName,
+		DEK:            append([]byte(nil), cachedDEK...),
+		EncryptedDEK:   state.EncryptedDEK,
+		IV:             state.IV,
+		KeyFingerprint: state.KeyFingerprint,
+		PartETags:      make(map[int]string),
+		CreatedAt:      state.CreatedAt,
+		ClientID:       state.ClientID,
+		ContentType:    factory.ContentTypeMultipart,
+		Metadata:       metadata,
+		PendingParts:   make(map[int]*PartBuffer),
+	}
+	if err := mpo.applySessionState(session, state); err != nil {
+		mpo.releaseSession(session, "failed")
+		return nil, err
+	}
+	session.ExpectedPartNumber = session.nextPartNumber
+	return session, nil
 }
 
-// getSession returns a multipart upload session (internal use)
-func (mpo *MultipartOperations) getSession(uploadID string) (*MultipartSession, error) {
-	mpo.mutex.RLock()
-	defer mpo.mutex.RUnlock()
+// syncSession reloads the encryption progress of session from the store when
+// it may be outdated: after a failed part, and with a shared store whenever
+// another replica saved a newer version.
+func (mpo *MultipartOperations) syncSession(ctx context.Context, session *MultipartSession) error {
+	session.mutex.RLock()
+	stale, version := session.stale, session.version
+	session.mutex.RUnlock()
+	if !stale && !mpo.sharedStore() {
+		return nil
+	}
 
-	session, exists := mpo.sessions[uploadID]
-	if !exists {
-		return nil, fmt.Errorf("multipart upload %s not found", uploadID)
+	state, err := mpo.store.Load(ctx, session.UploadID)
+	if err != nil {
+		return err
+	}
+	if !stale && state.Version == version {
+		return nil
 	}
 
-	return session, nil
+	session.OrderingMutex.Lock()
+	defer session.OrderingMutex.Unlock()
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	// Saved locally in the meantime
+	if !session.stale && state.Version <= session.version {
+		return nil
+	}
+	if err := mpo.applySessionState(session, state); err != nil {
+		return fmt.Errorf("failed to restore multipart session %s: %w", session.UploadID, err)
+	}
+	session.ExpectedPartNumber = session.nextPartNumber
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id": session.UploadID,
+		"next_part": session.nextPartNumber,
+	}).Debug("Reloaded multipart upload session from session store")
+	return nil
 }
 
 // createNoneProviderSession creates a minimal session for the none provider
-func (mpo *MultipartOperations) createNoneProviderSession(uploadID, objectKey, bucketName string) (*MultipartSession, error) {
+func (mpo *MultipartOperations) createNoneProviderSession(uploadID, objectKey, bucketName, clientID string) (*MultipartSession, error) {
 	session := &MultipartSession{
 		UploadID:           uploadID,
 		ObjectKey:          objectKey,
@@ -633,6 +1197,7 @@ func (mpo *MultipartOperations) createNoneProviderSession(uploadID, objectKey, b
 		PartETags:          make(map[int]string),
 		KeyFingerprint:     "none-provider-fingerprint",
 		CreatedAt:          time.Now(),
+		ClientID:           clientID,
 		ContentType:        factory.ContentTypeMultipart,
 		Metadata:           make(map[string]string),
 		ExpectedPartNumber: 1,
@@ -661,68 +1226,62 @@ func (mpo *MultipartOperations) processNoneProviderPartStream(_ *MultipartSessio
 	}, nil
 }
 
-// CleanupExpiredSessions removes sessions that have been idle for too long
+// CleanupExpiredSessions removes sessions that have been idle for too long,
+// including stored sessions of replicas that are gone
 func (mpo *MultipartOperations) CleanupExpiredSessions(maxAge time.Duration) int {
-	mpo.mutex.Lock()
-	defer mpo.mutex.Unlock()
-
+	ctx := context.Background()
 	now := time.Now()
-	expiredCount := 0
+	expired := make(map[string]bool)
 
+	mpo.mutex.Lock()
 	for uploadID, session := range mpo.sessions {
 		if now.Sub(session.CreatedAt) > maxAge {
-			// Clean up sensitive data
-			if session.DEK != nil {
-				mpo.hmacManager.ClearSensitiveData(session.DEK)
-			}
-			if session.IV != nil {
-				mpo.hmacManager.ClearSensitiveData(session.IV)
-			}
-
-			// Clean up CTR encryptor
-			if session.CTREncryptor != nil {
-				session.CTREncryptor.Cleanup()
-			}
-
-			// Clean up HMAC calculator
-			if session.HMACCalculator != nil {
-				session.HMACCalculator.Cleanup()
-			}
-
-			// Clean up pending parts
-			session.OrderingMutex.Lock()
-			for partNumber, partBuffer := range session.PendingParts {
-				// Send error to any waiting goroutines
-				select {
-				case partBuffer.ErrorChan <- fmt.Errorf("session expired"):
-				default:
-				}
-				delete(session.PendingParts, partNumber)
-			}
-			session.OrderingMutex.Unlock()
+			mpo.releaseSession(session, "expired")
 
 			delete(mpo.sessions, uploadID)
-			expiredCount++
+			expired[uploadID] = true
 
-			mpo.logger.WithFields(logrus.Fields{
+			mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 				"upload_id":  uploadID,
 				"object_key": session.ObjectKey,
 				"age":        now.Sub(session.CreatedAt),
 			}).Info("Cleaned up expired multipart upload session")
 		}
 	}
+	remaining := len(mpo.sessions)
+	mpo.mutex.Unlock()
+
+	states, err := mpo.store.List(ctx)
+	if err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).Warn("Failed to list stored multipart sessions for cleanup")
+	}
+	for _, state := range states {
+		if !expired[state.UploadID] && now.Sub(state.CreatedAt) > maxAge {
+			expired[state.UploadID] = true
+			mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+				"upload_id":  state.UploadID,
+				"object_key": state.ObjectKey,
+				"age":        now.Sub(state.CreatedAt),
+			}).Info("Cleaned up expired stored multipart upload session")
+		}
+	}
+	for uploadID := range expired {
+		if err := mpo.store.Delete(ctx, uploadID); err != nil {
+			mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", uploadID).Warn("Failed to delete expired multipart session from store")
+		}
+	}
 
-	if expiredCount > 0 {
-		mpo.logger.WithFields(logrus.Fields{
-			"expired_sessions":   expiredCount,
-			"remaining_sessions": len(mpo.sessions),
+	if len(expired) > 0 {
+		mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"expired_sessions":   len(expired),
+			"remaining_sessions": remaining,
 		}).Info("Completed multipart session cleanup")
 	}
 
-	return expiredCount
+	return len(expired)
 }
 
-// GetSessionCount returns the number of active sessions
+// GetSessionCount returns the number of sessions active in this process
 func (mpo *MultipartOperations) GetSessionCount() int {
 	mpo.mutex.RLock()
 	defer mpo.mutex.RUnlock()
@@ -752,35 +1311,39 @@ func (mpo *MultipartOperations) GetSessionCount() int {
 // - Streaming decryption with constant memory usage
 // - HMAC verification happens during decryption for optimal performance
 // - Supports objects from 5MB to 5TB without memory concerns
-func (mpo *MultipartOperations) DecryptMultipartWithHMACVerification(_ context.Context, objectKey string, metadata map[string]string, encryptedReader *bufio.Reader) (*bufio.Reader, error) {
-	mpo.logger.WithField("object_key", objectKey).Debug("Starting multipart decryption with HMAC verification")
+func (mpo *MultipartOperations) DecryptMultipartWithHMACVerification(ctx context.Context, objectKey string, metadata map[string]string, encryptedReader *bufio.Reader) (*bufio.Reader, error) {
+	mpo.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Starting multipart decryption with HMAC verification")
 
 	// Get encrypted DEK from metadata
 	encryptedDEK, err := mpo.metadataManager.GetEncryptedDEK(metadata)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to get encrypted DEK from metadata")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to get encrypted DEK from metadata")
 		return nil, fmt.Errorf("failed to get encrypted DEK: %w", err)
 	}
 
 	// Get key fingerprint from metadata
 	keyFingerprint, err := mpo.metadataManager.GetFingerprint(metadata)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to get key fingerprint from metadata")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to get key fingerprint from metadata")
 		return nil, fmt.Errorf("failed to get key fingerprint: %w", err)
 	}
 
+	if err := mpo.providerManager.checkTenantAccess(ctx, keyFingerprint, objectKey); err != nil {
+		return nil, err
+	}
+
 	// Decrypt DEK. The returned slice is owned by ProviderManager's DEK cache
 	// and must be treated as read-only (no ClearSensitiveData on this one).
-	dek, err := mpo.providerManager.DecryptDEK(encryptedDEK, keyFingerprint, objectKey)
+	dek, err := mpo.providerManager.DecryptDEK(ctx, encryptedDEK, keyFingerprint, objectKey)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to decrypt DEK for multipart object")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to decrypt DEK for multipart object")
 		return nil, fmt.Errorf("failed to decrypt DEK: %w", err)
 	}
 
 	// Get IV from metadata
 	iv, err := mpo.metadataManager.GetIV(metadata)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to get IV from metadata")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to get IV from metadata")
 		return nil, fmt.Errorf("failed to get IV: %w", err)
 	}
 	defer mpo.hmacManager.ClearSensitiveData(iv)
@@ -790,7 +1353,7 @@ func (mpo *MultipartOperations) DecryptMultipartWithHMACVerification(_ context.C
 	if mpo.hmacManager.IsEnabled() {
 		expectedHMAC, err = mpo.metadataManager.GetHMAC(metadata)
 		if err != nil {
-			mpo.logger.WithError(err).Error("Failed to get HMAC from metadata")
+			mpo.logger.WithContext(ctx).WithError(err).Error("Failed to get HMAC from metadata")
 			return nil, fmt.Errorf("failed to get HMAC from metadata: %w", err)
 		}
 	}
@@ -798,24 +1361,24 @@ func (mpo *MultipartOperations) DecryptMultipartWithHMACVerification(_ context.C
 	// Create HMAC calculator for verification if enabled
 	var hmacCalculator *validation.HMACCalculator
 	if mpo.hmacManager.IsEnabled() && len(expectedHMAC) > 0 {
-		hmacCalculator, err = mpo.hmacManager.CreateCalculator(dek)
+		hmacCalculator, err = verificationCalculator(mpo.hmacManager, mpo.metadataManager, dek, metadata)
 		if err != nil {
-			mpo.logger.WithError(err).Error("Failed to create HMAC calculator for multipart decryption")
+			mpo.logger.WithContext(ctx).WithError(err).Error("Failed to create HMAC calculator for multipart decryption")
 			return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
 		}
 	}
 
 	// Create CTR decryptor (using the same stateful encryptor but with existing IV)
-	ctrDecryptor, err := dataencryption.NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	ctrDecryptor, err := streamDecryptor(mpo.metadataManager, dek, iv, metadata)
 	if err != nil {
-		mpo.logger.WithError(err).Error("Failed to create CTR decryptor for multipart object")
+		mpo.logger.WithContext(ctx).WithError(err).Error("Failed to create CTR decryptor for multipart object")
 		return nil, fmt.Errorf("failed to create CTR decryptor: %w", err)
 	}
 
 	// Create streaming decryption reader with HMAC verification
 	decryptedReader := mpo.createStreamingDecryptionReader(encryptedReader, ctrDecryptor, hmacCalculator, expectedHMAC, objectKey)
 
-	mpo.logger.WithFields(logrus.Fields{
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key":        objectKey,
 		"hmac_enabled":      mpo.hmacManager.IsEnabled(),
 		"has_expected_hmac": len(expectedHMAC) > 0,
@@ -830,17 +1393,22 @@ func (mpo *MultipartOperations) DecryptMultipartWithHMACVerification(_ context.C
 // SECURITY: This implementation uses last-chunk buffering to validate HMAC BEFORE releasing final data to client.
 func (mpo *MultipartOperations) createStreamingDecryptionReader(
 	encryptedReader *bufio.Reader,
-	decryptor *dataencryption.AESCTRStatefulEncryptor,
+	decryptor dataencryption.StatefulEncryptor,
 	hmacCalculator *validation.HMACCalculator,
 	expectedHMAC []byte,
 	objectKey string,
 ) io.Reader {
-	return newHMACGatedDecryptionReader(
-		encryptedReader,
-		decryptor,
-		hmacCalculator,
-		mpo.hmacManager,
-		expectedHMAC,
-		objectKey,
-	)
+	var verifier *streaming.Verifier
+	if hmacCalculator != nil {
+		verifier = &streaming.Verifier{
+			Calculator: hmacCalculator,
+			Manager:    mpo.hmacManager,
+			Expected:   expectedHMAC,
+			ObjectKey:  objectKey,
+		}
+	}
+	reader := streaming.NewDecryptReaderSize(encryptedReader, decryptor, verifier, mpo.config.GetStreamingReadAheadSize())
+	reader.SetParallelism(mpo.parallelism)
+	reader.SetReadAhead(mpo.config.Optimizations.StreamingReadAhead)
+	return reader
 }
@@ -0,0 +1,514 @@
+package orchestration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+// isParallel reports whether the parts of the upload are encrypted
+// independently instead of in one ordered keystream
+func (s *MultipartSession) isParallel() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.parallel
+}
+
+// acquirePartWorker waits for one of the optimizations.multipart_encryption_workers slots
+func (mpo *MultipartOperations) acquirePartWorker(ctx context.Context) error {
+	if mpo.partWorkers == nil {
+		return nil
+	}
+	select {
+	case mpo.partWorkers <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (mpo *MultipartOperations) releasePartWorker() {
+	if mpo.partWorkers != nil {
+		<-mpo.partWorkers
+	}
+}
+
+// updateParts applies change to a copy of the part records of session and
+// saves it. On a conflict with another replica the session is reloaded and
+// change is applied again.
+func (mpo *MultipartOperations) updateParts(ctx context.Context, session *MultipartSession, change func(parts map[int]*partProgress) error) error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	for {
+		if session.stale {
+			state, err := mpo.store.Load(ctx, session.UploadID)
+			if err != nil {
+				return err
+			}
+			if err := mpo.applySessionState(session, state); err != nil {
+				return fmt.Errorf("failed to restore multipart session %s: %w", session.UploadID, err)
+			}
+		}
+
+		parts := maps.Clone(session.parts)
+		if err := change(parts); err != nil {
+			return err
+		}
+		state, err := mpo.sessionState(session, sessionProgress{Parts: parts})
+		if err == nil {
+			err = mpo.store.Update(ctx, state)
+		}
+		if errors.Is(err, ErrSessionConflict) {
+			session.stale = true
+			continue
+		}
+		if err != nil {
+			session.stale = true
+			mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save multipart session")
+			return fmt.Errorf("failed to save multipart session: %w", err)
+		}
+
+		session.version = state.Version
+		session.parts = parts
+		return nil
+	}
+}
+
+// partSlot is the reservation of an upload attempt of a part
+type partSlot struct {
+	number    int
+	size      int64
+	attempt   int
+	offset    uint64        // Keystream offset of the part
+	iv        []byte        // IV of the attempt
+	algorithm string        // Integrity algorithm, "" without integrity
+	previous  *partProgress // Record of the earlier attempt, restored on release
+}
+
+// reservePart records an upload attempt of partNumber before it is
+// encrypted, so no keystream is ever used twice. A part that was encrypted
+// before gets a new attempt with a keystream of its own; a part that is
+// still being encrypted cannot be uploaded at the same time.
+func (mpo *MultipartOperations) reservePart(ctx context.Context, session *MultipartSession, partNumber int, size int64) (*partSlot, error) {
+	var slot *partSlot
+	err := mpo.updateParts(ctx, session, func(parts map[int]*partProgress) error {
+		if size > session.partSize {
+			return fmt.Errorf("part %d of upload %s has %d bytes, more than the declared part size of %d", partNumber, session.UploadID, size, session.partSize)
+		}
+		slot = &partSlot{number: partNumber, size: size, offset: partKeystreamOffset(partNumber, session.partSize), iv: session.IV}
+		if previous, exists := parts[partNumber]; exists {
+			if !previous.Done && !previous.Failed {
+				return fmt.Errorf("part %d of upload %s is still being encrypted", partNumber, session.UploadID)
+			}
+			slot.attempt = previous.Attempt + 1
+			slot.iv = partIV(session.IV, slot.attempt)
+			slot.previous = previous
+		}
+		parts[partNumber] = &partProgress{Size: size, Attempt: slot.attempt}
+
+		if mpo.hmacManager.IsEnabled() && session.HMACCalculator != nil {
+			slot.algorithm = session.HMACCalculator.Algorithm()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slot, nil
+}
+
+// commitPart records the attempt as encrypted with the MAC of its plaintext
+func (mpo *MultipartOperations) commitPart(ctx context.Context, session *MultipartSession, slot *partSlot, mac []byte) error {
+	return mpo.updateParts(ctx, session, func(parts map[int]*partProgress) error {
+		parts[slot.number] = &partProgress{Size: slot.size, Attempt: slot.attempt, MAC: mac, Done: true}
+		return nil
+	})
+}
+
+// releasePart gives back an attempt none of whose ciphertext left the proxy,
+// restoring the record of the earlier attempt if there was one
+func (mpo *MultipartOperations) releasePart(ctx context.Context, session *MultipartSession, slot *partSlot) {
+	err := mpo.updateParts(ctx, session, func(parts map[int]*partProgress) error {
+		if slot.previous != nil {
+			parts[slot.number] = slot.previous
+		} else {
+			delete(parts, slot.number)
+		}
+		return nil
+	})
+	if err != nil {
+		// The reservation stays in the store, later attempts report the part as being encrypted
+		mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to release multipart part reservation")
+	}
+}
+
+// failPart records that an attempt broke off after part of its keystream was
+// used. The part cannot be completed until it is uploaded again.
+func (mpo *MultipartOperations) failPart(ctx context.Context, session *MultipartSession, slot *partSlot) {
+	err := mpo.updateParts(ctx, session, func(parts map[int]*partProgress) error {
+		parts[slot.number] = &partProgress{Size: slot.size, Attempt: slot.attempt, Failed: true}
+		return nil
+	})
+	if err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save failed multipart part")
+	}
+}
+
+// partCrypto creates the encryptor positioned at the keystream of an attempt
+// and, with an integrity algorithm, the calculator of its MAC
+func (mpo *MultipartOperations) partCrypto(session *MultipartSession, slot *partSlot) (dataencryption.StatefulEncryptor, *validation.HMACCalculator, error) {
+	encryptor, err := dataencryption.NewStatefulEncryptorAt(mpo.metadataManager.streamAlgorithm(session.Metadata), session.DEK, slot.iv, slot.offset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CTR encryptor: %w", err)
+	}
+	if slot.algorithm == "" {
+		return encryptor, nil, nil
+	}
+	calculator, err := mpo.hmacManager.CreatePartCalculator(session.DEK, slot.algorithm, slot.number)
+	if err != nil {
+		encryptor.Cleanup()
+		return nil, nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
+	}
+	return encryptor, calculator, nil
+}
+
+// processPartParallel encrypts a part of an upload whose parts are
+// encrypted independently. The part is read completely, so it can be
+// uploaded again if anything fails before its ciphertext is handed out.
+func (mpo *MultipartOperations) processPartParallel(ctx context.Context, session *MultipartSession, partNumber int, dataReader io.Reader) (*EncryptionResult, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, int(mpo.config.GetStreamingSegmentSize())))
+	if _, err := buf.ReadFrom(dataReader); err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).Error("Error reading part data for parallel processing")
+		return nil, fmt.Errorf("failed to read part data: %w", err)
+	}
+	partData := buf.Bytes()
+	size := int64(len(partData))
+
+	slot, err := mpo.reservePart(ctx, session, partNumber, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := mpo.acquirePartWorker(ctx); err != nil {
+		mpo.releasePart(context.WithoutCancel(ctx), session, slot)
+		return nil, err
+	}
+	mac, err := mpo.encryptPart(session, slot, partData)
+	mpo.releasePartWorker()
+	if err != nil {
+		mpo.releasePart(context.WithoutCancel(ctx), session, slot)
+		return nil, err
+	}
+	if err := mpo.commitPart(context.WithoutCancel(ctx), session, slot, mac); err != nil {
+		return nil, err
+	}
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":       session.UploadID,
+		"part_number":     partNumber,
+		"attempt":         slot.attempt,
+		"bytes_processed": size,
+		"hmac_enabled":    slot.algorithm != "",
+	}).Debug("Successfully encrypted multipart upload part at its own keystream offset")
+
+	return &EncryptionResult{
+		EncryptedData:  bytes.NewReader(partData),
+		Metadata:       make(map[string]string),
+		Algorithm:      mpo.metadataManager.streamAlgorithm(session.Metadata),
+		KeyFingerprint: session.KeyFingerprint,
+	}, nil
+}
+
+// encryptPart encrypts data in place as the reserved attempt and returns the
+// MAC of its plaintext
+func (mpo *MultipartOperations) encryptPart(session *MultipartSession, slot *partSlot, data []byte) ([]byte, error) {
+	encryptor, calculator, err := mpo.partCrypto(session, slot)
+	if err != nil {
+		return nil, err
+	}
+	defer encryptor.Cleanup()
+
+	var mac []byte
+	if calculator != nil {
+		defer calculator.Cleanup()
+		if _, err := calculator.Add(data); err != nil {
+			return nil, fmt.Errorf("failed to update HMAC: %w", err)
+		}
+		mac = calculator.Sum()
+	}
+	if _, err := encryptor.EncryptPartParallel(data, mpo.parallelism); err != nil {
+		return nil, fmt.Errorf("failed to encrypt part: %w", err)
+	}
+	return mac, nil
+}
+
+// processPartStreamParallel reserves a part of an upload whose parts are
+// encrypted independently and returns the reader that encrypts it as it is
+// read. A part that breaks off after it was partly read has to be uploaded
+// again before the upload can be completed.
+func (mpo *MultipartOperations) processPartStreamParallel(ctx context.Context, session *MultipartSession, partNumber int, size int64, src io.Reader) (*EncryptionResult, error) {
+	slot, err := mpo.reservePart(ctx, session, partNumber, size)
+	if err != nil {
+		return nil, err
+	}
+	encryptor, calculator, err := mpo.partCrypto(session, slot)
+	if err != nil {
+		mpo.releasePart(context.WithoutCancel(ctx), session, slot)
+		return nil, err
+	}
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":   session.UploadID,
+		"part_number": partNumber,
+		"attempt":     slot.attempt,
+		"part_size":   size,
+	}).Debug("Reserved multipart upload part for parallel streaming")
+
+	return &EncryptionResult{
+		EncryptedData: &parallelPartReader{
+			mpo:        mpo,
+			ctx:        context.WithoutCancel(ctx),
+			session:    session,
+			slot:       slot,
+			partNumber: partNumber,
+			encryptor:  encryptor,
+			calculator: calculator,
+			src:        src,
+			size:       size,
+			remaining:  size,
+		},
+		Metadata:       make(map[string]string),
+		Algorithm:      encryptor.Algorithm(),
+		KeyFingerprint: session.KeyFingerprint,
+	}, nil
+}
+
+// parallelPartReader encrypts a reserved part of an upload whose parts are
+// encrypted independently as it is read. Each read holds a worker slot while
+// it is encrypted, so slow clients do not keep slots.
+type parallelPartReader struct {
+	mpo        *MultipartOperations
+	ctx        context.Context
+	session    *MultipartSession
+	slot       *partSlot
+	partNumber int
+	encryptor  dataencryption.StatefulEncryptor
+	calculator *validation.HMACCalculator
+	src        io.Reader
+	size       int64
+	remaining  int64
+	finished   bool
+	err        error
+}
+
+// Read implements io.Reader
+func (r *parallelPartReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.remaining == 0 {
+		r.err = r.complete(nil)
+		if r.err == nil {
+			r.err = io.EOF
+		}
+		return 0, r.err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.src.Read(p)
+	if n > 0 {
+		if encErr := r.encrypt(p[:n]); encErr != nil {
+			r.err = r.fail(encErr)
+			return 0, r.err
+		}
+		r.remaining -= int64(n)
+	}
+
+	switch {
+	case r.remaining == 0:
+		// The consumer may stop at the content length without reading EOF
+		if completeErr := r.complete(err); completeErr != nil {
+			r.err = completeErr
+			return n, r.err
+		}
+		r.err = io.EOF
+		return n, nil
+	case errors.Is(err, io.EOF):
+		r.err = r.fail(fmt.Errorf("%w: part %d ended after %d of %d bytes", ErrIncompleteBody, r.partNumber, r.size-r.remaining, r.size))
+		return n, r.err
+	case err != nil:
+		r.err = r.fail(fmt.Errorf("failed to read part %d: %w", r.partNumber, err))
+		return n, r.err
+	}
+	return n, nil
+}
+
+func (r *parallelPartReader) encrypt(data []byte) error {
+	if err := r.mpo.acquirePartWorker(r.ctx); err != nil {
+		return err
+	}
+	defer r.mpo.releasePartWorker()
+
+	if r.calculator != nil {
+		if _, err := r.calculator.Add(data); err != nil {
+			return fmt.Errorf("failed to update HMAC: %w", err)
+		}
+	}
+	if _, err := r.encryptor.EncryptPartParallel(data, r.mpo.parallelism); err != nil {
+		return fmt.Errorf("failed to encrypt part: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the part if it was read completely. Otherwise the
+// reservation is released if nothing was read yet, or the part is marked
+// failed. Close implements io.Closer.
+func (r *parallelPartReader) Close() error {
+	if r.finished {
+		return nil
+	}
+	if r.remaining == 0 {
+		return r.complete(nil)
+	}
+	if r.remaining == r.size {
+		r.finish()
+		r.mpo.releasePart(r.ctx, r.session, r.slot)
+		return nil
+	}
+	_ = r.fail(fmt.Errorf("%w: part %d was closed after %d of %d bytes", ErrIncompleteBody, r.partNumber, r.size-r.remaining, r.size))
+	return nil
+}
+
+// finish wipes the encryptor and calculator of the part and returns its MAC
+func (r *parallelPartReader) finish() []byte {
+	r.finished = true
+	var mac []byte
+	if r.calculator != nil {
+		mac = r.calculator.Sum()
+		r.calculator.Cleanup()
+	}
+	r.encryptor.Cleanup()
+	return mac
+}
+
+// complete commits the part once all of it was read, or fails it if the
+// body does not end there. err is the error of the last read.
+func (r *parallelPartReader) complete(err error) error {
+	if endErr := readBodyEnd(r.src, err, r.partNumber, r.size); endErr != nil {
+		return r.fail(endErr)
+	}
+	return r.commit()
+}
+
+// commit records the part as encrypted
+func (r *parallelPartReader) commit() error {
+	if r.finished {
+		return nil
+	}
+	mac := r.finish()
+	if err := r.mpo.commitPart(r.ctx, r.session, r.slot, mac); err != nil {
+		return err
+	}
+
+	r.mpo.logger.WithFields(logrus.Fields{
+		"upload_id":       r.session.UploadID,
+		"part_number":     r.partNumber,
+		"attempt":         r.slot.attempt,
+		"bytes_processed": r.size,
+		"hmac_enabled":    r.calculator != nil,
+	}).Debug("Successfully streamed multipart upload part at its own keystream offset")
+	return nil
+}
+
+// fail records the part as failed after part of its keystream was used
+func (r *parallelPartReader) fail(cause error) error {
+	if r.finished {
+		return cause
+	}
+	r.finish()
+	r.mpo.failPart(r.ctx, r.session, r.slot)
+
+	r.mpo.logger.WithError(cause).WithFields(logrus.Fields{
+		"upload_id":   r.session.UploadID,
+		"part_number": r.partNumber,
+		"bytes_read":  r.size - r.remaining,
+		"part_size":   r.size,
+	}).Warn("Streamed multipart upload part broke off, it has to be uploaded again")
+	return cause
+}
+
+// finalizeParts adds the part layout and the object MAC over the part MACs
+// of partNumbers (nil for all encrypted parts) to metadata. The caller must
+// hold session.mutex.
+func (mpo *MultipartOperations) finalizeParts(session *MultipartSession, metadata map[string]string, partNumbers []int) error {
+	if partNumbers == nil {
+		for partNumber, part := range session.parts {
+			if part.Done {
+				partNumbers = append(partNumbers, partNumber)
+			}
+		}
+	} else {
+		partNumbers = append([]int(nil), partNumbers...)
+	}
+	sort.Ints(partNumbers)
+	if len(partNumbers) == 0 {
+		return fmt.Errorf("upload %s has no encrypted parts", session.UploadID)
+	}
+
+	var object *validation.HMACCalculator
+	if mpo.hmacManager.IsEnabled() && session.HMACCalculator != nil {
+		var err error
+		object, err = integrityCalculator(mpo.hmacManager, session.DEK, session.HMACCalculator.Algorithm(), mpo.metadataManager.GetEncryptionContext(session.Metadata))
+		if err != nil {
+			return fmt.Errorf("failed to create HMAC calculator: %w", err)
+		}
+		defer object.Cleanup()
+	}
+
+	parts := make([]layoutPart, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		part := session.parts[partNumber]
+		switch {
+		case part == nil:
+			return fmt.Errorf("part %d of upload %s was not encrypted", partNumber, session.UploadID)
+		case part.Failed:
+			return fmt.Errorf("part %d of upload %s broke off while it was streamed and has to be uploaded again", partNumber, session.UploadID)
+		case !part.Done:
+			return fmt.Errorf("part %d of upload %s is still being encrypted", partNumber, session.UploadID)
+		case object != nil && part.MAC == nil:
+			return fmt.Errorf("part %d of upload %s was encrypted without a MAC", partNumber, session.UploadID)
+		}
+		parts = append(parts, layoutPart{Number: partNumber, Size: part.Size, Attempt: part.Attempt})
+		if object != nil {
+			if err := object.AddPart(partNumber, part.Size, part.MAC); err != nil {
+				return fmt.Errorf("failed to update HMAC: %w", err)
+			}
+		}
+	}
+
+	if err := mpo.metadataManager.SetPartLayout(metadata, session.partSize, parts); err != nil {
+		return err
+	}
+	if object != nil {
+		mpo.metadataManager.SetHMAC(metadata, object.Sum())
+		mpo.metadataManager.SetHMACAlgorithm(metadata, object.Algorithm())
+	}
+
+	mpo.logger.WithFields(logrus.Fields{
+		"upload_id":    session.UploadID,
+		"total_parts":  len(parts),
+		"hmac_enabled": object != nil,
+	}).Debug("Added part layout and object MAC over the part MACs to metadata")
+	return nil
+}
@@ -0,0 +1,325 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+func createTestParallelMultipartConfig() *config.Config {
+	cfg := createTestMultipartConfig()
+	cfg.Optimizations.MultipartEncryptionWorkers = 2
+	cfg.Optimizations.StreamingBufferSize = 4096
+	cfg.Optimizations.StreamingReadAhead = 3
+	return cfg
+}
+
+func newParallelTestSession(t *testing.T) *MultipartOperations {
+	t.Helper()
+	mpo, err := createTestMultipartOperations(createTestParallelMultipartConfig())
+	require.NoError(t, err)
+	_, err = mpo.InitiateSession(context.Background(), testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+	return mpo
+}
+
+// encryptPartsConcurrently uploads all parts at once, odd ones buffered and
+// even ones streamed, and returns their ciphertexts by part number
+func encryptPartsConcurrently(t *testing.T, mpo *MultipartOperations, parts map[int][]byte) map[int][]byte {
+	t.Helper()
+	ctx := context.Background()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ciphertexts := make(map[int][]byte)
+	for partNumber, data := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result *EncryptionResult
+			var err error
+			if partNumber%2 == 1 {
+				result, err = mpo.ProcessPart(ctx, testUploadID, partNumber, testDataToReader(data))
+			} else {
+				result, err = mpo.ProcessPartStream(ctx, testUploadID, partNumber, int64(len(data)), bytes.NewReader(data))
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			ciphertext, err := io.ReadAll(result.EncryptedData)
+			assert.NoError(t, err)
+			mu.Lock()
+			ciphertexts[partNumber] = ciphertext
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	require.Len(t, ciphertexts, len(parts))
+	return ciphertexts
+}
+
+func decryptParallelObject(mpo *MultipartOperations, metadata map[string]string, ciphertext []byte) ([]byte, error) {
+	reader, err := mpo.DecryptMultipartWithHMACVerification(context.Background(), testObjectKey, metadata, bufio.NewReader(bytes.NewReader(ciphertext)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestParallelParts_ConcurrentOutOfOrderRoundTrip(t *testing.T) {
+	mpo := newParallelTestSession(t)
+	parts := map[int][]byte{
+		1: generateMultipartTestData(70*1024 + 3),
+		2: generateMultipartTestData(33*1024 + 1),
+		3: generateMultipartTestData(5),
+		4: generateMultipartTestData(16 * 1024),
+	}
+	ciphertexts := encryptPartsConcurrently(t, mpo, parts)
+
+	metadata, err := mpo.FinalizeSessionWithParts(context.Background(), testUploadID, []int{4, 1, 3, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "1:71683,2:33793,3:5,4:16384", metadata["s3ep-part-layout"])
+	assert.NotEmpty(t, metadata["s3ep-hmac"])
+
+	plaintext, err := decryptParallelObject(mpo, metadata, bytes.Join([][]byte{ciphertexts[1], ciphertexts[2], ciphertexts[3], ciphertexts[4]}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join([][]byte{parts[1], parts[2], parts[3], parts[4]}, nil), plaintext)
+}
+
+func TestParallelParts_CompleteSubset(t *testing.T) {
+	mpo := newParallelTestSession(t)
+	parts := map[int][]byte{1: generateMultipartTestData(2048), 2: generateMultipartTestData(1024), 3: generateMultipartTestData(512)}
+	ciphertexts := encryptPartsConcurrently(t, mpo, parts)
+
+	_, err := mpo.FinalizeSessionWithParts(context.Background(), testUploadID, []int{1, 5})
+	assert.ErrorContains(t, err, "part 5 of upload test-upload-123 was not encrypted")
+
+	metadata, err := mpo.FinalizeSessionWithParts(context.Background(), testUploadID, []int{1, 3})
+	require.NoError(t, err)
+	plaintext, err := decryptParallelObject(mpo, metadata, append(ciphertexts[1], ciphertexts[3]...))
+	require.NoError(t, err)
+	assert.Equal(t, append(parts[1], parts[3]...), plaintext)
+}
+
+func TestParallelParts_TamperingIsDetected(t *testing.T) {
+	mpo := newParallelTestSession(t)
+	parts := map[int][]byte{1: generateMultipartTestData(3000), 2: generateMultipartTestData(3000)}
+	ciphertexts := encryptPartsConcurrently(t, mpo, parts)
+	metadata, err := mpo.FinalizeSession(context.Background(), testUploadID)
+	require.NoError(t, err)
+
+	// parts swapped
+	_, err = decryptParallelObject(mpo, metadata, append(ciphertexts[2], ciphertexts[1]...))
+	assert.Error(t, err)
+
+	// a layout that moves the part boundary
+	moved := maps.Clone(metadata)
+	moved["s3ep-part-layout"] = "1:2999,2:3001"
+	_, err = decryptParallelObject(mpo, moved, append(ciphertexts[1], ciphertexts[2]...))
+	assert.Error(t, err)
+
+	// data beyond the last part
+	_, err = decryptParallelObject(mpo, metadata, append(append(ciphertexts[1], ciphertexts[2]...), 0))
+	assert.Error(t, err)
+}
+
+func TestParallelParts_Retries(t *testing.T) {
+	mpo := newParallelTestSession(t)
+	ctx := context.Background()
+	first, retried := generateMultipartTestData(1024), bytes.Repeat([]byte{7}, 1024)
+
+	result, err := mpo.ProcessPart(ctx, testUploadID, 1, testDataToReader(first))
+	require.NoError(t, err)
+	firstCiphertext, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+	result, err = mpo.ProcessPart(ctx, testUploadID, 1, testDataToReader(first))
+	require.NoError(t, err)
+	retriedCiphertext, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+	assert.NotEqual(t, firstCiphertext, retriedCiphertext, "a retry must not reuse the keystream of the first attempt")
+
+	// a streamed part that was never read keeps its attempt
+	result, err = mpo.ProcessPartStream(ctx, testUploadID, 2, int64(len(first)), bytes.NewReader(first))
+	require.NoError(t, err)
+	_, err = mpo.ProcessPart(ctx, testUploadID, 2, testDataToReader(first))
+	assert.ErrorContains(t, err, "still being encrypted")
+	require.NoError(t, result.EncryptedData.(io.Closer).Close())
+	result, err = mpo.ProcessPart(ctx, testUploadID, 2, testDataToReader(first))
+	require.NoError(t, err)
+	secondCiphertext, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	// a streamed part that broke off has to be uploaded again
+	result, err = mpo.ProcessPartStream(ctx, testUploadID, 3, 100, bytes.NewReader(make([]byte, 60)))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedData)
+	assert.ErrorIs(t, err, ErrIncompleteBody)
+	_, err = mpo.FinalizeSessionWithParts(ctx, testUploadID, []int{1, 2, 3})
+	assert.ErrorContains(t, err, "has to be uploaded again")
+	result, err = mpo.ProcessPart(ctx, testUploadID, 3, testDataToReader(retried[:100]))
+	require.NoError(t, err)
+	thirdCiphertext, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	metadata, err := mpo.FinalizeSessionWithParts(ctx, testUploadID, []int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, "1:1024@1,2:1024,3:100@1", metadata["s3ep-part-layout"])
+	plaintext, err := decryptParallelObject(mpo, metadata, bytes.Join([][]byte{retriedCiphertext, secondCiphertext, thirdCiphertext}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join([][]byte{first, first, retried[:100]}, nil), plaintext)
+
+	// the ciphertext of the first attempt does not verify as the part
+	_, err = decryptParallelObject(mpo, metadata, bytes.Join([][]byte{firstCiphertext, secondCiphertext, thirdCiphertext}, nil))
+	assert.Error(t, err)
+}
+
+func TestParallelParts_BodyCheckedAtItsEndFailsPart(t *testing.T) {
+	mpo := newParallelTestSession(t)
+	ctx := context.Background()
+	data := generateMultipartTestData(1024)
+	errBody := errors.New("payload hash mismatch")
+
+	// The body fails only when read past the part size, as bodies checked
+	// against X-Amz-Content-Sha256 do
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(data)), io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errBody)))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedData)
+	assert.ErrorIs(t, err, errBody)
+	_, err = mpo.FinalizeSessionWithParts(ctx, testUploadID, []int{1})
+	assert.ErrorContains(t, err, "has to be uploaded again")
+
+	// So does a body longer than the part
+	result, err = mpo.ProcessPartStream(ctx, testUploadID, 2, int64(len(data)-1), bytes.NewReader(data))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedData)
+	assert.ErrorContains(t, err, "is longer than")
+}
+
+func TestParallelParts_DeclaredPartSize(t *testing.T) {
+	mpo, err := createTestMultipartOperations(createTestParallelMultipartConfig())
+	require.NoError(t, err)
+	ctx := WithPartSize(context.Background(), 4096)
+	_, err = mpo.InitiateSession(ctx, testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+
+	_, err = mpo.ProcessPart(ctx, testUploadID, 1, testDataToReader(generateMultipartTestData(4097)))
+	assert.ErrorContains(t, err, "more than the declared part size of 4096")
+
+	parts := map[int][]byte{1: generateMultipartTestData(4096), 2: generateMultipartTestData(4096), 3: generateMultipartTestData(100)}
+	ciphertexts := encryptPartsConcurrently(t, mpo, parts)
+	metadata, err := mpo.FinalizeSession(ctx, testUploadID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", metadata["s3ep-format-version"])
+	assert.Equal(t, "4096", metadata["s3ep-part-size"])
+	assert.Equal(t, "1-2:4096,3:100", metadata["s3ep-part-layout"])
+
+	// parts of the declared size form one continuous keystream
+	session, err := mpo.GetSession(testUploadID)
+	require.NoError(t, err)
+	continuous, err := dataencryption.NewStatefulEncryptorAt(mpo.metadataManager.streamAlgorithm(session.Metadata), session.DEK, session.IV, 0)
+	require.NoError(t, err)
+	defer continuous.Cleanup()
+	ciphertext := bytes.Join([][]byte{ciphertexts[1], ciphertexts[2], ciphertexts[3]}, nil)
+	plaintext, err := continuous.EncryptPart(append([]byte(nil), ciphertext...))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join([][]byte{parts[1], parts[2], parts[3]}, nil), plaintext)
+
+	plaintext, err = decryptParallelObject(mpo, metadata, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join([][]byte{parts[1], parts[2], parts[3]}, nil), plaintext)
+
+	unknown := maps.Clone(metadata)
+	unknown["s3ep-format-version"] = "3"
+	_, err = decryptParallelObject(mpo, unknown, ciphertext)
+	assert.ErrorContains(t, err, "unsupported format-version")
+}
+
+func TestParallelParts_SharedStoreAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	newReplica := func() *MultipartOperations {
+		store, err := NewRedisSessionStore(config.SessionStoreConfig{
+			KeyPrefix: "s3ep/multipart/",
+			Timeout:   5,
+			Redis:     config.RedisSessionStoreConfig{Address: server.Addr()},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = store.Close() })
+		mpo, err := createTestMultipartOperations(createTestParallelMultipartConfig())
+		require.NoError(t, err)
+		mpo.store = store
+		return mpo
+	}
+	replicaA, replicaB := newReplica(), newReplica()
+	ctx := context.Background()
+	_, err := replicaA.InitiateSession(ctx, testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+
+	parts := [][]byte{generateMultipartTestData(4096), generateMultipartTestData(100), generateMultipartTestData(9000)}
+	ciphertexts := make([][]byte, len(parts))
+	var wg sync.WaitGroup
+	for i, data := range parts {
+		replica := replicaA
+		if i%2 == 1 {
+			replica = replicaB
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := replica.ProcessPart(ctx, testUploadID, i+1, testDataToReader(data))
+			if assert.NoError(t, err) {
+				ciphertexts[i], err = io.ReadAll(result.EncryptedData)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// replica B learns of the parts of replica A from the store, so its
+	// retry of part 1 is a new attempt
+	result, err := replicaB.ProcessPart(ctx, testUploadID, 1, testDataToReader(parts[0]))
+	require.NoError(t, err)
+	ciphertexts[0], err = io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	metadata, err := replicaB.FinalizeSessionWithParts(ctx, testUploadID, []int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, "1:4096@1,2:100,3:9000", metadata["s3ep-part-layout"])
+	plaintext, err := decryptParallelObject(replicaA, metadata, bytes.Join(ciphertexts, nil))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join(parts, nil), plaintext)
+}
+
+func TestPartLayout_FormatAndParse(t *testing.T) {
+	parts := []layoutPart{{Number: 1, Size: 8}, {Number: 2, Size: 8}, {Number: 3, Size: 8, Attempt: 1}, {Number: 5, Size: 8}, {Number: 6, Size: 2}}
+	layout := formatPartLayout(parts)
+	assert.Equal(t, "1-2:8,3:8@1,5:8,6:2", layout)
+	parsed, err := parsePartLayout(layout, 8)
+	require.NoError(t, err)
+	assert.Equal(t, parts, parsed)
+
+	_, err = parsePartLayout(layout, 7)
+	assert.Error(t, err, "parts larger than the part size")
+	for _, invalid := range []string{"", "1", "0:5", "2-1:5", "1:-1", "10001:5", "3:5,2:5", "1-2:x", "1:5368709121", "1:5@0", "1:5@-1", "1:5@x"} {
+		_, err := parsePartLayout(invalid, maxPartSize)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPartIV(t *testing.T) {
+	iv := bytes.Repeat([]byte{0xff}, 16)
+	assert.Nil(t, partIV(iv, 0))
+	assert.Equal(t, append([]byte{0xff, 0xff, 0xff, 0xfe}, iv[4:]...), partIV(iv, 1))
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, 16), iv, "the IV of the upload is not changed")
+}
@@ -0,0 +1,389 @@
+package orchestration
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProcessPartStream is ProcessPart for a part of size plaintext bytes that
+// does not have to be buffered. Parts of uploads encrypted in parallel are
+// always streamed. Otherwise, if partNumber is the next part of the upload,
+// its keystream is reserved in the session store and the returned reader
+// encrypts src as it is read, so memory use does not depend on the part size.
+// The caller must read EncryptedData to the end or close it; a part that
+// breaks off after it was partly read cannot be repeated and the upload has to
+// be aborted. Parts that arrive ahead of earlier ones are buffered as in
+// ProcessPart.
+func (mpo *MultipartOperations) ProcessPartStream(ctx context.Context, uploadID string, partNumber int, size int64, src io.Reader) (*EncryptionResult, error) {
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, fmt.Errorf("invalid part number %d: must be between 1 and 10000", partNumber)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid part size %d", size)
+	}
+	if mpo.providerManager.IsNoneProvider() {
+		return mpo.ProcessPart(ctx, uploadID, partNumber, bufio.NewReader(src))
+	}
+
+	session, err := mpo.getSession(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if session.isParallel() {
+		return mpo.processPartStreamParallel(ctx, session, partNumber, size, src)
+	}
+
+	for {
+		// Pick up parts processed by other replicas
+		if err := mpo.syncSession(ctx, session); err != nil {
+			return nil, err
+		}
+
+		session.OrderingMutex.Lock()
+		next := partNumber == session.ExpectedPartNumber
+		session.OrderingMutex.Unlock()
+		if !next {
+			// Earlier parts are missing, or this one was encrypted already
+			return mpo.processPartOrdered(ctx, session, partNumber, bufio.NewReader(src))
+		}
+
+		reader, reserved, err := mpo.reservePartStream(ctx, session, partNumber, size, src)
+		if errors.Is(err, ErrSessionConflict) {
+			continue // another replica got ahead, reload and order again
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !reserved {
+			return mpo.processPartOrdered(ctx, session, partNumber, bufio.NewReader(src))
+		}
+
+		return &EncryptionResult{
+			EncryptedData:  reader,
+			Metadata:       make(map[string]string),
+			Algorithm:      mpo.metadataManager.streamAlgorithm(session.Metadata),
+			KeyFingerprint: session.KeyFingerprint,
+		}, nil
+	}
+}
+
+// reservePartStream saves the session with the keystream of partNumber
+// reserved and returns the reader that encrypts the part. reserved is false
+// if partNumber is no longer the next part.
+func (mpo *MultipartOperations) reservePartStream(ctx context.Context, session *MultipartSession, partNumber int, size int64, src io.Reader) (*partStreamReader, bool, error) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if session.stale {
+		return nil, false, fmt.Errorf("%w: %s", ErrSessionConflict, session.UploadID)
+	}
+	if err := session.streamError(); err != nil {
+		return nil, false, err
+	}
+	if session.nextPartNumber != partNumber {
+		return nil, false, nil
+	}
+	if session.CTREncryptor == nil {
+		return nil, false, fmt.Errorf("CTR encryptor not initialized for session %s", session.UploadID)
+	}
+
+	// Until the reservation is saved, the local state is ahead of the store
+	session.stale = true
+	offset := session.CTREncryptor.Offset()
+	state, err := mpo.sessionState(session, sessionProgress{
+		NextPartNumber: partNumber + 1,
+		BytesEncrypted: offset + uint64(size),
+		StreamingPart:  partNumber,
+	})
+	if err == nil {
+		err = mpo.store.Update(ctx, state)
+	}
+	if err != nil {
+		mpo.logger.WithContext(ctx).WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save multipart session")
+		return nil, false, fmt.Errorf("failed to save multipart session: %w", err)
+	}
+
+	session.version = state.Version
+	session.streamingPart = partNumber
+	session.stale = false
+
+	mpo.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"upload_id":   session.UploadID,
+		"part_number": partNumber,
+		"part_size":   size,
+		"offset":      offset,
+	}).Debug("Reserved keystream for streamed multipart upload part")
+
+	return &partStreamReader{
+		mpo:        mpo,
+		ctx:        context.WithoutCancel(ctx),
+		session:    session,
+		partNumber: partNumber,
+		src:        src,
+		size:       size,
+		remaining:  size,
+	}, true, nil
+}
+
+// streamError reports a streamed part that keeps other parts from being
+// encrypted and the upload from being completed. The caller must hold
+// session.mutex.
+func (s *MultipartSession) streamError() error {
+	switch {
+	case s.streamFailed:
+		return fmt.Errorf("part %d of upload %s broke off while it was streamed, the upload has to be aborted", s.streamingPart, s.UploadID)
+	case s.streamingPart != 0:
+		return fmt.Errorf("part %d of upload %s is still being streamed", s.streamingPart, s.UploadID)
+	default:
+		return nil
+	}
+}
+
+// checkStreamFailed returns the error of a streamed part that broke off, so
+// later parts are not buffered behind it in vain
+func (s *MultipartSession) checkStreamFailed() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if !s.streamFailed {
+		return nil
+	}
+	return s.streamError()
+}
+
+// partStreamReader encrypts a reserved part as it is read. It owns the CTR
+// encryptor and HMAC calculator of the session until the part is finished:
+// every other user checks streamingPart first.
+type partStreamReader struct {
+	mpo        *MultipartOperations
+	ctx        context.Context
+	session    *MultipartSession
+	partNumber int
+	src        io.Reader
+	size       int64
+	remaining  int64
+	finished   bool
+	err        error
+}
+
+// Read implements io.Reader
+func (r *partStreamReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.remaining == 0 {
+		r.err = r.complete(nil)
+		if r.err == nil {
+			r.err = io.EOF
+		}
+		return 0, r.err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.src.Read(p)
+	if n > 0 {
+		if r.session.HMACCalculator != nil {
+			if _, hmacErr := r.session.HMACCalculator.Add(p[:n]); hmacErr != nil {
+				r.err = r.fail(fmt.Errorf("failed to update HMAC: %w", hmacErr))
+				return 0, r.err
+			}
+		}
+		if _, encErr := r.session.CTREncryptor.EncryptPartParallel(p[:n], r.mpo.parallelism); encErr != nil {
+			r.err = r.fail(fmt.Errorf("failed to encrypt part: %w", encErr))
+			return 0, r.err
+		}
+		r.remaining -= int64(n)
+	}
+
+	switch {
+	case r.remaining == 0:
+		// The consumer may stop at the content length without reading EOF
+		if completeErr := r.complete(err); completeErr != nil {
+			r.err = completeErr
+			return n, r.err
+		}
+		r.err = io.EOF
+		return n, nil
+	case errors.Is(err, io.EOF):
+		r.err = r.fail(fmt.Errorf("%w: part %d ended after %d of %d bytes", ErrIncompleteBody, r.partNumber, r.size-r.remaining, r.size))
+		return n, r.err
+	case err != nil:
+		r.err = r.fail(fmt.Errorf("failed to read part %d: %w", r.partNumber, err))
+		return n, r.err
+	}
+	return n, nil
+}
+
+// Close finishes the part if it was read completely. Otherwise the
+// reservation is released if nothing was read yet, or the session is marked
+// failed. Close implements io.Closer.
+func (r *partStreamReader) Close() error {
+	if r.finished {
+		return nil
+	}
+	if r.remaining == 0 {
+		return r.complete(nil)
+	}
+	if r.remaining == r.size {
+		r.release()
+		return nil
+	}
+	_ = r.fail(fmt.Errorf("%w: part %d was closed after %d of %d bytes", ErrIncompleteBody, r.partNumber, r.size-r.remaining, r.size))
+	return nil
+}
+
+// complete commits the part once all of it was read, or fails it if the
+// body does not end there. err is the error of the last read.
+func (r *partStreamReader) complete(err error) error {
+	if endErr := readBodyEnd(r.src, err, r.partNumber, r.size); endErr != nil {
+		return r.fail(endErr)
+	}
+	return r.commit()
+}
+
+// readBodyEnd reads src, of which the size bytes of part partNumber were
+// read, to its end. Checks that run at the end of the request body, such as
+// the one of X-Amz-Content-Sha256, then fail the part before it is
+// committed. err is the error of the last read.
+func readBodyEnd(src io.Reader, err error, partNumber int, size int64) error {
+	if err == nil {
+		var extra [1]byte
+		var n int
+		n, err = io.ReadFull(src, extra[:])
+		if n > 0 {
+			return fmt.Errorf("part %d is longer than %d bytes", partNumber, size)
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return fmt.Errorf("failed to read part %d: %w", partNumber, err)
+}
+
+// commit saves the session after the streamed part, including its HMAC
+// state, and continues with the parts buffered behind it
+func (r *partStreamReader) commit() error {
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+	session := r.session
+
+	session.mutex.Lock()
+	session.stale = true
+	state, err := r.mpo.sessionState(session, sessionProgress{
+		NextPartNumber: r.partNumber + 1,
+		BytesEncrypted: session.CTREncryptor.Offset(),
+	})
+	if err == nil {
+		err = r.mpo.store.Update(r.ctx, state)
+	}
+	if err != nil {
+		session.stale = false
+		session.streamFailed = true
+		session.mutex.Unlock()
+		r.mpo.logger.WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save multipart session after streamed part")
+		err = fmt.Errorf("failed to save multipart session: %w", err)
+		r.mpo.failPendingParts(session, err)
+		return err
+	}
+	session.version = state.Version
+	session.nextPartNumber = r.partNumber + 1
+	session.streamingPart = 0
+	session.stale = false
+	session.mutex.Unlock()
+
+	r.mpo.logger.WithFields(logrus.Fields{
+		"upload_id":       session.UploadID,
+		"part_number":     r.partNumber,
+		"bytes_processed": r.size,
+		"hmac_enabled":    r.mpo.hmacManager.IsEnabled(),
+	}).Debug("Successfully streamed multipart upload part in correct sequential order")
+
+	r.mpo.processBufferedPartsData(r.ctx, session)
+	return nil
+}
+
+// release gives back the reservation of a part none of which was encrypted,
+// so the part can be uploaded again
+func (r *partStreamReader) release() {
+	r.finished = true
+	session := r.session
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.stale = true
+	state, err := r.mpo.sessionState(session, sessionProgress{
+		NextPartNumber: r.partNumber,
+		BytesEncrypted: session.CTREncryptor.Offset(),
+	})
+	if err == nil {
+		err = r.mpo.store.Update(r.ctx, state)
+	}
+	if err != nil {
+		// The reservation stays in the store, later attempts report the part as streaming
+		r.mpo.logger.WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to release streamed part reservation")
+		session.stale = false
+		return
+	}
+	session.version = state.Version
+	session.streamingPart = 0
+	session.stale = false
+}
+
+// fail marks the session failed after part of the reserved keystream was
+// used: the part cannot be encrypted again and no later part can follow it
+func (r *partStreamReader) fail(cause error) error {
+	if r.finished {
+		return cause
+	}
+	r.finished = true
+	session := r.session
+
+	session.mutex.Lock()
+	session.streamFailed = true
+	state, err := r.mpo.sessionState(session, sessionProgress{
+		NextPartNumber: r.partNumber + 1,
+		BytesEncrypted: session.CTREncryptor.Offset() + uint64(r.remaining),
+		StreamingPart:  r.partNumber,
+		StreamFailed:   true,
+	})
+	if err == nil {
+		err = r.mpo.store.Update(r.ctx, state)
+	}
+	if err == nil {
+		session.version = state.Version
+	}
+	session.mutex.Unlock()
+
+	r.mpo.logger.WithError(cause).WithFields(logrus.Fields{
+		"upload_id":   session.UploadID,
+		"part_number": r.partNumber,
+		"bytes_read":  r.size - r.remaining,
+		"part_size":   r.size,
+	}).Warn("Streamed multipart upload part broke off, the upload has to be aborted")
+	if err != nil {
+		r.mpo.logger.WithError(err).WithField("upload_id", session.UploadID).Warn("Failed to save failed multipart session")
+	}
+
+	r.mpo.failPendingParts(session, cause)
+	return cause
+}
+
+// failPendingParts fails all parts buffered behind a streamed part that broke off
+func (mpo *MultipartOperations) failPendingParts(session *MultipartSession, cause error) {
+	session.OrderingMutex.Lock()
+	defer session.OrderingMutex.Unlock()
+
+	for partNumber, partBuffer := range session.PendingParts {
+		delete(session.PendingParts, partNumber)
+		partBuffer.ErrorChan <- fmt.Errorf("part %d cannot be encrypted: %w", partNumber, cause)
+	}
+}
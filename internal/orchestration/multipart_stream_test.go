@@ -0,0 +1,136 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamTestSession(t *testing.T) *MultipartOperations {
+	t.Helper()
+	mpo, err := createTestMultipartOperations(createTestMultipartConfig())
+	require.NoError(t, err)
+	_, err = mpo.InitiateSession(context.Background(), testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+	return mpo
+}
+
+func TestProcessPartStream_MatchesBufferedParts(t *testing.T) {
+	mpo := newStreamTestSession(t)
+	ctx := context.Background()
+	part1 := generateMultipartTestData(300*1024 + 7)
+	part2 := generateMultipartTestData(1024)
+
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(part1)), iotest.HalfReader(bytes.NewReader(part1)))
+	require.NoError(t, err)
+	_, streamed := result.EncryptedData.(io.Closer)
+	assert.True(t, streamed, "the next part must not be buffered")
+	ciphertext1, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+	require.Len(t, ciphertext1, len(part1))
+
+	result, err = mpo.ProcessPart(ctx, testUploadID, 2, testDataToReader(part2))
+	require.NoError(t, err)
+	ciphertext2, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	metadata, err := mpo.FinalizeSession(ctx, testUploadID)
+	require.NoError(t, err)
+
+	ciphertext := bufio.NewReader(bytes.NewReader(append(ciphertext1, ciphertext2...)))
+	decrypted, err := mpo.DecryptMultipartWithHMACVerification(ctx, testObjectKey, metadata, ciphertext)
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, append(part1, part2...), plaintext)
+}
+
+func TestProcessPartStream_LaterPartsWaitForStream(t *testing.T) {
+	mpo := newStreamTestSession(t)
+	ctx := context.Background()
+	part1 := generateMultipartTestData(4096)
+
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(part1)), bytes.NewReader(part1))
+	require.NoError(t, err)
+
+	// A retry of the part being streamed must not reuse its keystream
+	_, err = mpo.ProcessPart(ctx, testUploadID, 1, testDataToReader(part1))
+	assert.ErrorContains(t, err, "still being streamed")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mpo.ProcessPartStream(ctx, testUploadID, 2, 10, bytes.NewReader(make([]byte, 10)))
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("part 2 was encrypted before part 1 was streamed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err = io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("part 2 was not encrypted after part 1")
+	}
+}
+
+func TestProcessPartStream_ShortBodyFailsUpload(t *testing.T) {
+	mpo := newStreamTestSession(t)
+	ctx := context.Background()
+
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, 100, bytes.NewReader(make([]byte, 60)))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedData)
+	assert.ErrorIs(t, err, ErrIncompleteBody)
+
+	_, err = mpo.ProcessPart(ctx, testUploadID, 2, testDataToReader([]byte("next")))
+	assert.ErrorContains(t, err, "has to be aborted")
+	_, err = mpo.FinalizeSession(ctx, testUploadID)
+	assert.ErrorContains(t, err, "has to be aborted")
+}
+
+func TestProcessPartStream_BodyCheckedAtItsEndFailsUpload(t *testing.T) {
+	mpo := newStreamTestSession(t)
+	ctx := context.Background()
+	data := generateMultipartTestData(1024)
+	errBody := errors.New("payload hash mismatch")
+
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(data)), io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errBody)))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedData)
+	assert.ErrorIs(t, err, errBody)
+	_, err = mpo.FinalizeSession(ctx, testUploadID)
+	assert.ErrorContains(t, err, "has to be aborted")
+}
+
+func TestProcessPartStream_UnreadPartCanBeRetried(t *testing.T) {
+	mpo := newStreamTestSession(t)
+	ctx := context.Background()
+	part1 := generateMultipartTestData(2048)
+
+	result, err := mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(part1)), bytes.NewReader(part1))
+	require.NoError(t, err)
+	require.NoError(t, result.EncryptedData.(io.Closer).Close())
+
+	result, err = mpo.ProcessPartStream(ctx, testUploadID, 1, int64(len(part1)), bytes.NewReader(part1))
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+	assert.Len(t, ciphertext, len(part1))
+
+	_, err = mpo.FinalizeSession(ctx, testUploadID)
+	assert.NoError(t, err)
+}
@@ -13,11 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
 )
 
 // calculateSHA256ForMultipartTest computes SHA256 hash for multipart testing
@@ -299,7 +301,7 @@ func TestInitiateSession_DuplicateUploadID(t *testing.T) {
 	session2, err := mpo.InitiateSession(ctx, testUploadID, "different-key", testBucketName)
 	assert.Error(t, err, "Should return error for duplicate upload ID")
 	assert.Nil(t, session2, "Second session should be nil")
-	assert.Contains(t, err.Error(), "already exists", "Error should mention duplicate session")
+	assert.ErrorIs(t, err, ErrDuplicateSession)
 
 	// Verify only one session exists
 	assert.Equal(t, 1, mpo.GetSessionCount(), "Should have exactly one session")
@@ -465,7 +467,7 @@ func TestProcessPart_SessionNotFound(t *testing.T) {
 	result, err := mpo.ProcessPart(ctx, "non-existent-upload", 1, testDataToReader(testData))
 	assert.Error(t, err, "Should return error for non-existent session")
 	assert.Nil(t, result, "Result should be nil")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestProcessPart_EmptyData(t *testing.T) {
@@ -611,7 +613,7 @@ func TestStorePartETag_SessionNotFound(t *testing.T) {
 	// Try to store ETag for non-existent session
 	err = mpo.StorePartETag("non-existent-upload", 1, testETag)
 	assert.Error(t, err, "Should return error for non-existent session")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestStorePartETag_OverwriteETag(t *testing.T) {
@@ -754,7 +756,7 @@ func TestFinalizeSession_SessionNotFound(t *testing.T) {
 	metadata, err := mpo.FinalizeSession(ctx, "non-existent-upload")
 	assert.Error(t, err, "Should return error for non-existent session")
 	assert.Nil(t, metadata, "Metadata should be nil on error")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestFinalizeSession_EmptySession(t *testing.T) {
@@ -880,7 +882,7 @@ func TestAbortSession_SessionNotFound(t *testing.T) {
 
 	err = mpo.AbortSession(ctx, "non-existent-upload")
 	assert.Error(t, err, "Should return error for non-existent session")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestAbortSession_MultipleSessionsCleanup(t *testing.T) {
@@ -981,7 +983,7 @@ func TestCleanupSession_SessionNotFound(t *testing.T) {
 
 	err = mpo.CleanupSession("non-existent-upload")
 	assert.Error(t, err, "Should return error for non-existent session")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestCleanupSession_AfterSuccessfulFinalization(t *testing.T) {
@@ -1052,7 +1054,7 @@ func TestGetSession_NotFound(t *testing.T) {
 	session, err := mpo.GetSession("non-existent-upload")
 	assert.Error(t, err, "Should return error for non-existent session")
 	assert.Nil(t, session, "Session should be nil")
-	assert.Contains(t, err.Error(), "not found", "Error should mention session not found")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestGetSession_MultipleSessionsRetrieval(t *testing.T) {
@@ -1233,6 +1235,80 @@ func TestCleanupExpiredSessions_NoExpiredSessions(t *testing.T) {
 
 // Tests for concurrent access and thread safety
 
+func TestTakeOverStaleSessions(t *testing.T) {
+	ctx := context.Background()
+	const client = "AKIACLIENT"
+
+	setup := func(t *testing.T) *MultipartOperations {
+		mpo, err := createTestMultipartOperations(createTestMultipartConfig())
+		require.NoError(t, err)
+		_, err = mpo.InitiateClientSession(ctx, "old-upload", testObjectKey, testBucketName, client)
+		require.NoError(t, err)
+		_, err = mpo.InitiateClientSession(ctx, "other-client", testObjectKey, testBucketName, "AKIAOTHER")
+		require.NoError(t, err)
+		_, err = mpo.InitiateSession(ctx, "anonymous", testObjectKey, testBucketName)
+		require.NoError(t, err)
+		return mpo
+	}
+
+	t.Run("keep leaves sessions alone", func(t *testing.T) {
+		mpo := setup(t)
+		takeover := mpo.TakeOverStaleSessions(ctx, testBucketName, testObjectKey, client, config.StaleSessionPolicyKeep)
+		assert.Empty(t, takeover.ReuseUploadID)
+		assert.Empty(t, takeover.AbortUploadIDs)
+		assert.Equal(t, 3, mpo.GetSessionCount())
+	})
+
+	t.Run("abort discards only the client's session for the target", func(t *testing.T) {
+		mpo := setup(t)
+		_, err := mpo.InitiateClientSession(ctx, "other-key", "other-key", testBucketName, client)
+		require.NoError(t, err)
+
+		takeover := mpo.TakeOverStaleSessions(ctx, testBucketName, testObjectKey, client, config.StaleSessionPolicyAbort)
+		assert.Empty(t, takeover.ReuseUploadID)
+		assert.Equal(t, []string{"old-upload"}, takeover.AbortUploadIDs)
+
+		_, err = mpo.GetSession("old-upload")
+		assert.Error(t, err, "taken over session must be removed")
+		assert.Equal(t, 3, mpo.GetSessionCount())
+	})
+
+	t.Run("reuse hands out an unstarted session", func(t *testing.T) {
+		mpo := setup(t)
+		takeover := mpo.TakeOverStaleSessions(ctx, testBucketName, testObjectKey, client, config.StaleSessionPolicyReuse)
+		assert.Equal(t, "old-upload", takeover.ReuseUploadID)
+		assert.Empty(t, takeover.AbortUploadIDs)
+		assert.Equal(t, 3, mpo.GetSessionCount())
+	})
+
+	t.Run("reuse requires the same encryption context", func(t *testing.T) {
+		mpo := setup(t)
+		acme := WithEncryptionContext(ctx, EncryptionContext{"tenant": "acme"})
+		takeover := mpo.TakeOverStaleSessions(acme, testBucketName, testObjectKey, client, config.StaleSessionPolicyReuse)
+		assert.Empty(t, takeover.ReuseUploadID)
+		assert.Equal(t, []string{"old-upload"}, takeover.AbortUploadIDs)
+	})
+
+	t.Run("reuse aborts a session with uploaded parts", func(t *testing.T) {
+		mpo := setup(t)
+		result, err := mpo.ProcessPart(ctx, "old-upload", 1, testDataToReader(generateMultipartTestData(1024)))
+		require.NoError(t, err)
+		_, err = io.ReadAll(result.EncryptedData)
+		require.NoError(t, err)
+
+		takeover := mpo.TakeOverStaleSessions(ctx, testBucketName, testObjectKey, client, config.StaleSessionPolicyReuse)
+		assert.Empty(t, takeover.ReuseUploadID)
+		assert.Equal(t, []string{"old-upload"}, takeover.AbortUploadIDs)
+	})
+
+	t.Run("requests without client are never matched", func(t *testing.T) {
+		mpo := setup(t)
+		takeover := mpo.TakeOverStaleSessions(ctx, testBucketName, testObjectKey, "", config.StaleSessionPolicyAbort)
+		assert.Empty(t, takeover.AbortUploadIDs)
+		assert.Equal(t, 3, mpo.GetSessionCount())
+	})
+}
+
 func TestConcurrentSessionCreation(t *testing.T) {
 	mpo, err := createTestMultipartOperations(createTestMultipartConfig())
 	require.NoError(t, err)
@@ -1829,7 +1905,7 @@ func TestNewHMACManagerInterfaceIntegration(t *testing.T) {
 	// Verify should fail
 	err = hmacManager.VerifyIntegrity(corruptCalculator, hmacBytes)
 	assert.Error(t, err, "HMAC verification should fail with corrupted data")
-	assert.Contains(t, err.Error(), "HMAC verification failed", "Error should mention HMAC verification failure")
+	assert.ErrorIs(t, err, ErrIntegrityFailure)
 
 	t.Logf("Phase 6: Testing HMACManager.ClearSensitiveData functionality")
 
@@ -1859,3 +1935,127 @@ func TestNewHMACManagerInterfaceIntegration(t *testing.T) {
 	t.Logf("   ✓ Multipart upload with %d parts processed successfully", numParts)
 	t.Logf("   ✓ HMAC value: %s", hmacValue)
 }
+
+// createTestMultipartOperationsWithStore creates multipart operations that keep sessions in store
+func createTestMultipartOperationsWithStore(t *testing.T, store SessionStore) *MultipartOperations {
+	mpo, err := createTestMultipartOperations(createTestMultipartConfig())
+	require.NoError(t, err)
+	mpo.store = store
+	return mpo
+}
+
+// assertMultipartResult decrypts the concatenated parts and checks them and the HMAC metadata against plaintext
+func assertMultipartResult(t *testing.T, mpo *MultipartOperations, dek, iv, ciphertext, plaintext []byte, metadata map[string]string) {
+	decryptor, err := dataencryption.NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	require.NoError(t, err)
+	decrypted, err := decryptor.DecryptPart(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	calculator, err := mpo.hmacManager.CreateCalculator(dek)
+	require.NoError(t, err)
+	_, err = calculator.Add(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(mpo.hmacManager.FinalizeCalculator(calculator)), metadata["s3ep-hmac"])
+}
+
+func TestMultipartSession_ContinuesAfterRestart(t *testing.T) {
+	store := NewMemorySessionStore()
+	ctx := context.Background()
+	parts := [][]byte{generateMultipartTestData(1000), generateMultipartTestData(2049), generateMultipartTestData(77)}
+	var ciphertext bytes.Buffer
+
+	first := createTestMultipartOperationsWithStore(t, store)
+	session, err := first.InitiateSession(ctx, testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+	dek := append([]byte(nil), session.DEK...)
+	iv := append([]byte(nil), session.IV...)
+
+	result, err := first.ProcessPart(ctx, testUploadID, 1, testDataToReader(parts[0]))
+	require.NoError(t, err)
+	_, err = ciphertext.ReadFrom(result.EncryptedData)
+	require.NoError(t, err)
+
+	// A new process continues the upload from the store
+	second := createTestMultipartOperationsWithStore(t, store)
+	for i, part := range parts[1:] {
+		result, err := second.ProcessPart(ctx, testUploadID, i+2, testDataToReader(part))
+		require.NoError(t, err)
+		_, err = ciphertext.ReadFrom(result.EncryptedData)
+		require.NoError(t, err)
+	}
+
+	// The first process sees the progress and does not reuse the keystream
+	_, err = first.ProcessPart(ctx, testUploadID, 2, testDataToReader(parts[1]))
+	assert.ErrorContains(t, err, "already encrypted")
+
+	metadata, err := second.FinalizeSession(ctx, testUploadID)
+	require.NoError(t, err)
+	assertMultipartResult(t, second, dek, iv, ciphertext.Bytes(), bytes.Join(parts, nil), metadata)
+
+	require.NoError(t, second.CleanupSession(testUploadID))
+	_, err = store.Load(ctx, testUploadID)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestMultipartSession_SharedStoreOrdersPartsAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	newReplica := func() *MultipartOperations {
+		store, err := NewRedisSessionStore(config.SessionStoreConfig{
+			KeyPrefix: "s3ep/multipart/",
+			Timeout:   5,
+			Redis:     config.RedisSessionStoreConfig{Address: server.Addr()},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = store.Close() })
+		return createTestMultipartOperationsWithStore(t, store)
+	}
+	replicaA, replicaB := newReplica(), newReplica()
+	ctx := context.Background()
+	parts := [][]byte{generateMultipartTestData(512), generateMultipartTestData(1024), generateMultipartTestData(256)}
+
+	session, err := replicaA.InitiateSession(ctx, testUploadID, testObjectKey, testBucketName)
+	require.NoError(t, err)
+	dek := append([]byte(nil), session.DEK...)
+	iv := append([]byte(nil), session.IV...)
+
+	// Part 2 arrives at replica B first and waits for part 1 at replica A
+	type partResult struct {
+		data []byte
+		err  error
+	}
+	done := make(chan partResult, 1)
+	go func() {
+		result, err := replicaB.ProcessPart(ctx, testUploadID, 2, testDataToReader(parts[1]))
+		if err != nil {
+			done <- partResult{err: err}
+			return
+		}
+		data, err := io.ReadAll(result.EncryptedData)
+		done <- partResult{data: data, err: err}
+	}()
+
+	time.Sleep(2 * sessionStorePollInterval)
+	result, err := replicaA.ProcessPart(ctx, testUploadID, 1, testDataToReader(parts[0]))
+	require.NoError(t, err)
+	part1, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	var part2 partResult
+	select {
+	case part2 = <-done:
+		require.NoError(t, part2.err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("part 2 was not processed after part 1")
+	}
+
+	result, err = replicaA.ProcessPart(ctx, testUploadID, 3, testDataToReader(parts[2]))
+	require.NoError(t, err)
+	part3, err := io.ReadAll(result.EncryptedData)
+	require.NoError(t, err)
+
+	metadata, err := replicaB.FinalizeSession(ctx, testUploadID)
+	require.NoError(t, err)
+	ciphertext := bytes.Join([][]byte{part1, part2.data, part3}, nil)
+	assertMultipartResult(t, replicaB, dek, iv, ciphertext, bytes.Join(parts, nil), metadata)
+}
@@ -0,0 +1,214 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+// PartSizeHeader declares the size of all but the last part of a multipart
+// upload when it is initiated. Part n of an upload whose parts are encrypted
+// independently starts (n-1) part sizes into the keystream, so uploads whose
+// parts all have the declared size form one continuous keystream.
+const PartSizeHeader = "x-s3ep-part-size"
+
+// maxPartSize is the largest part S3 accepts and the part size of uploads
+// that declare none
+const maxPartSize = 5 << 30
+
+// maxPartLayoutSize bounds the encoded part layout; S3 allows 2 KB of user
+// metadata in total
+const maxPartLayoutSize = 1024
+
+// maxPartNumber is the highest part number S3 accepts
+const maxPartNumber = 10000
+
+// layoutPart is a part of an object whose parts were encrypted independently.
+// Every upload attempt of a part uses its own keystream, see partIV.
+type layoutPart struct {
+	Number  int
+	Size    int64
+	Attempt int
+}
+
+type partSizeKey struct{}
+
+// WithPartSize attaches the part size declared by the client to ctx
+func WithPartSize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, partSizeKey{}, size)
+}
+
+// partSizeFrom returns the part size attached with WithPartSize, or maxPartSize
+func partSizeFrom(ctx context.Context) int64 {
+	if size, ok := ctx.Value(partSizeKey{}).(int64); ok {
+		return size
+	}
+	return maxPartSize
+}
+
+// ParsePartSize parses the value of PartSizeHeader
+func ParsePartSize(header string) (int64, error) {
+	size, err := strconv.ParseInt(strings.TrimSpace(header), 10, 64)
+	if err != nil || size < 1 || size > maxPartSize {
+		return 0, fmt.Errorf("invalid %s %q: must be between 1 and %d bytes", PartSizeHeader, header, int64(maxPartSize))
+	}
+	return size, nil
+}
+
+// partKeystreamOffset returns the keystream offset part n is encrypted at
+func partKeystreamOffset(partNumber int, partSize int64) uint64 {
+	return uint64(partNumber-1) * uint64(partSize) // #nosec G115 -- part numbers are between 1 and 10000, part sizes positive
+}
+
+// partIV returns the IV of an upload attempt of a part. The first attempt
+// uses the IV of the upload; later ones flip the top 32 bits of the AES-CTR
+// counter block or the XChaCha20 nonce, so a retried part never reuses the
+// keystream of an earlier attempt, wherever that attempt's data ended up.
+func partIV(iv []byte, attempt int) []byte {
+	if attempt == 0 {
+		return nil
+	}
+	derived := append([]byte(nil), iv...)
+	var mask [4]byte
+	binary.BigEndian.PutUint32(mask[:], uint32(attempt)) // #nosec G115 -- attempts are positive and bounded by the layout size
+	for i := range mask {
+		derived[i] ^= mask[i]
+	}
+	return derived
+}
+
+// formatPartLayout encodes the parts of an object as comma-separated runs of
+// consecutive part numbers with the same size and attempt, e.g.
+// "1-99:8388608,100:1234@1" for a last part that was uploaded twice
+func formatPartLayout(parts []layoutPart) string {
+	var b strings.Builder
+	for i := 0; i < len(parts); {
+		j := i
+		for j+1 < len(parts) && parts[j+1].Number == parts[j].Number+1 &&
+			parts[j+1].Size == parts[i].Size && parts[j+1].Attempt == parts[i].Attempt {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(parts[i].Number))
+		if j > i {
+			b.WriteByte('-')
+			b.WriteString(strconv.Itoa(parts[j].Number))
+		}
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(parts[i].Size, 10))
+		if parts[i].Attempt > 0 {
+			b.WriteByte('@')
+			b.WriteString(strconv.Itoa(parts[i].Attempt))
+		}
+		i = j + 1
+	}
+	return b.String()
+}
+
+// parsePartLayout decodes a layout written by formatPartLayout. Part numbers
+// must be ascending and part sizes at most partSize.
+func parsePartLayout(layout string, partSize int64) ([]layoutPart, error) {
+	var parts []layoutPart
+	for _, run := range strings.Split(layout, ",") {
+		numbers, rest, ok := strings.Cut(run, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid part layout run %q", run)
+		}
+		sizeStr, attemptStr, retried := strings.Cut(rest, "@")
+		if !retried {
+			attemptStr = "0"
+		}
+		firstStr, lastStr, isRange := strings.Cut(numbers, "-")
+		if !isRange {
+			lastStr = firstStr
+		}
+		first, err1 := strconv.Atoi(firstStr)
+		last, err2 := strconv.Atoi(lastStr)
+		size, err3 := strconv.ParseInt(sizeStr, 10, 64)
+		attempt, err4 := strconv.Atoi(attemptStr)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return nil, fmt.Errorf("invalid part layout run %q", run)
+		}
+		if first < 1 || last < first || last > maxPartNumber || size < 0 || size > partSize || attempt < 0 || (retried && attempt == 0) {
+			return nil, fmt.Errorf("part layout run %q is out of range", run)
+		}
+		if len(parts) > 0 && first <= parts[len(parts)-1].Number {
+			return nil, fmt.Errorf("part layout is not in ascending order at %q", run)
+		}
+		for n := first; n <= last; n++ {
+			parts = append(parts, layoutPart{Number: n, Size: size, Attempt: attempt})
+		}
+	}
+	return parts, nil
+}
+
+// partRecords returns the parts as authenticated by the object MAC
+func partRecords(parts []layoutPart) []validation.PartRecord {
+	records := make([]validation.PartRecord, len(parts))
+	for i, part := range parts {
+		records[i] = validation.PartRecord{Number: part.Number, Size: part.Size}
+	}
+	return records
+}
+
+// SetPartLayout records an object whose parts were encrypted independently:
+// the format version, the declared part size and the parts
+func (mm *MetadataManager) SetPartLayout(metadata map[string]string, partSize int64, parts []layoutPart) error {
+	layout := formatPartLayout(parts)
+	if len(layout) > maxPartLayoutSize {
+		return fmt.Errorf("part layout of %d parts needs %d bytes of metadata, more than %d", len(parts), len(layout), maxPartLayoutSize)
+	}
+	metadata[mm.prefix+"format-version"] = strconv.Itoa(FormatVersionParted)
+	metadata[mm.prefix+"part-size"] = strconv.FormatInt(partSize, 10)
+	metadata[mm.prefix+"part-layout"] = layout
+	return nil
+}
+
+// GetPartLayout returns the declared part size and the parts recorded with
+// SetPartLayout, or no parts for objects encrypted as one continuous stream
+func (mm *MetadataManager) GetPartLayout(metadata map[string]string) (int64, []layoutPart, error) {
+	version, err := mm.GetFormatVersion(metadata)
+	if err != nil || version != FormatVersionParted {
+		return 0, nil, err
+	}
+
+	partSize, err := strconv.ParseInt(metadata[mm.prefix+"part-size"], 10, 64)
+	if err != nil || partSize < 1 || partSize > maxPartSize {
+		return 0, nil, fmt.Errorf("invalid part-size %q", metadata[mm.prefix+"part-size"])
+	}
+	parts, err := parsePartLayout(metadata[mm.prefix+"part-layout"], partSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	return partSize, parts, nil
+}
+
+// streamDecryptor creates the decryptor for an object encrypted with a
+// stream cipher, following its part layout if it has one
+func streamDecryptor(mm *MetadataManager, dek, iv []byte, metadata map[string]string) (dataencryption.StatefulEncryptor, error) {
+	algorithm := mm.streamAlgorithm(metadata)
+	partSize, parts, err := mm.GetPartLayout(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if parts == nil {
+		return dataencryption.NewStatefulEncryptorAt(algorithm, dek, iv, 0)
+	}
+
+	extents := make([]dataencryption.Extent, len(parts))
+	for i, part := range parts {
+		extents[i] = dataencryption.Extent{
+			Size:   uint64(part.Size), // #nosec G115 -- sizes are validated by parsePartLayout
+			Offset: partKeystreamOffset(part.Number, partSize),
+			IV:     partIV(iv, part.Attempt),
+		}
+	}
+	return dataencryption.NewPartedDecryptor(algorithm, dek, iv, extents)
+}
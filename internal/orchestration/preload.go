@@ -0,0 +1,78 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// PreloadKeys fetches and exercises the key material of every provider that
+// implements encryption.KeyPreloader. The keys are reported ready once a
+// preload has succeeded for all of them; later failures are logged by the
+// caller but do not clear readiness, since the loaded key material stays usable.
+func (m *Manager) PreloadKeys(ctx context.Context) error {
+	var errs []error
+	for _, provider := range m.providerManager.GetAllProviders() {
+		preloader, ok := provider.Encryptor.(encryption.KeyPreloader)
+		if !ok {
+			continue
+		}
+
+		start := time.Now()
+		if err := preloader.Preload(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("provider '%s': %w", provider.Alias, err))
+			continue
+		}
+		m.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"provider_alias": provider.Alias,
+			"provider_type":  provider.Type,
+			"duration":       time.Since(start),
+		}).Debug("Preloaded provider key material")
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	m.keysReady.Store(true)
+	return nil
+}
+
+// KeysReady reports whether the key material of all providers has been
+// preloaded at least once
+func (m *Manager) KeysReady() bool {
+	return m.keysReady.Load()
+}
+
+// RunKeyPreload preloads the provider keys immediately and then once per
+// interval until ctx is cancelled. Each attempt is bounded by timeout.
+func (m *Manager) RunKeyPreload(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		preloadCtx, cancel := context.WithTimeout(ctx, timeout)
+		wasReady := m.KeysReady()
+		err := m.PreloadKeys(preloadCtx)
+		cancel()
+
+		switch {
+		case err != nil && wasReady:
+			m.logger.WithContext(ctx).WithError(err).Warn("Key material refresh failed, keeping loaded keys")
+		case err != nil:
+			m.logger.WithContext(ctx).WithError(err).Error("Key material preload failed, proxy is not ready")
+		case !wasReady:
+			m.logger.WithContext(ctx).Info("Key material preloaded, proxy is ready")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
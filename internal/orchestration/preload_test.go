@@ -0,0 +1,74 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// preloadingKeyEncryptor fails the first failures preloads
+type preloadingKeyEncryptor struct {
+	encryption.KeyEncryptor
+	failures int
+	calls    int
+}
+
+func (p *preloadingKeyEncryptor) Preload(_ context.Context) error {
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("kms unreachable")
+	}
+	return nil
+}
+
+func withPreloader(manager *Manager, preloader *preloadingKeyEncryptor) {
+	pm := manager.providerManager
+	info := pm.registeredProviders["test-aes"]
+	preloader.KeyEncryptor = info.Encryptor
+	info.Encryptor = preloader
+	pm.registeredProviders["test-aes"] = info
+}
+
+func TestPreloadKeys_LocalProvidersAreReady(t *testing.T) {
+	manager := newSelfTestManager(t)
+	assert.False(t, manager.KeysReady())
+
+	require.NoError(t, manager.PreloadKeys(context.Background()))
+	assert.True(t, manager.KeysReady())
+}
+
+func TestPreloadKeys_ReadyAfterFirstSuccess(t *testing.T) {
+	manager := newSelfTestManager(t)
+	preloader := &preloadingKeyEncryptor{failures: 1}
+	withPreloader(manager, preloader)
+
+	err := manager.PreloadKeys(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "provider 'test-aes'")
+	assert.False(t, manager.KeysReady())
+
+	require.NoError(t, manager.PreloadKeys(context.Background()))
+	assert.True(t, manager.KeysReady())
+
+	// A failed refresh keeps the already loaded keys usable
+	preloader.failures = 10
+	require.Error(t, manager.PreloadKeys(context.Background()))
+	assert.True(t, manager.KeysReady())
+}
+
+func TestRunKeyPreload_RetriesUntilReady(t *testing.T) {
+	manager := newSelfTestManager(t)
+	withPreloader(manager, &preloadingKeyEncryptor{failures: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.RunKeyPreload(ctx, 10*time.Millisecond, time.Second)
+
+	assert.Eventually(t, manager.KeysReady, 5*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,188 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// Error types of failed provider calls in s3ep_provider_dek_errors_total
+const (
+	providerErrorThrottle = "throttle"
+	providerErrorAuth     = "auth"
+	providerErrorNetwork  = "network"
+	providerErrorCanceled = "canceled"
+	providerErrorOther    = "other"
+)
+
+// Error codes of cloud KMS APIs (AWS style) by error type
+var (
+	throttleErrorCodes = map[string]bool{
+		"ThrottlingException":      true,
+		"Throttling":               true,
+		"TooManyRequestsException": true,
+		"RequestLimitExceeded":     true,
+		"LimitExceededException":   true,
+		"SlowDown":                 true,
+		"ServiceUnavailable":       true,
+		"RESOURCE_EXHAUSTED":       true,
+	}
+	authErrorCodes = map[string]bool{
+		"AccessDenied":                true,
+		"AccessDeniedException":       true,
+		"UnrecognizedClientException": true,
+		"InvalidSignatureException":   true,
+		"ExpiredTokenException":       true,
+		"InvalidClientTokenId":        true,
+		"IncompleteSignature":         true,
+		"PERMISSION_DENIED":           true,
+		"UNAUTHENTICATED":             true,
+	}
+)
+
+// metricsEncryptor records the latency and errors of the DEK wrap and unwrap
+// calls of a provider, and traces them as spans. It sits below the rate
+// limit, so it measures the key service itself, without queueing and without
+// coalesced unwraps.
+type metricsEncryptor struct {
+	encryption.KeyEncryptor
+	alias string
+}
+
+// metricsPreloader keeps encryption.KeyPreloader visible through the wrapper
+type metricsPreloader struct {
+	*metricsEncryptor
+	preloader encryption.KeyPreloader
+}
+
+func (p *metricsPreloader) Preload(ctx context.Context) error {
+	return p.preloader.Preload(ctx)
+}
+
+// withMetrics wraps keyEncryptor with latency and error metrics. The "none"
+// provider makes no calls and is not wrapped.
+func withMetrics(keyEncryptor encryption.KeyEncryptor, provider config.EncryptionProvider) encryption.KeyEncryptor {
+	if provider.Type == "none" {
+		return keyEncryptor
+	}
+	instrumented := &metricsEncryptor{KeyEncryptor: keyEncryptor, alias: provider.Alias}
+	if preloader, ok := keyEncryptor.(encryption.KeyPreloader); ok {
+		return &metricsPreloader{metricsEncryptor: instrumented, preloader: preloader}
+	}
+	return instrumented
+}
+
+// observeKeyFallback logs and counts the DEK wraps and unwraps a provider
+// served with a fallback key, such as a KMS key replica in another region.
+// It is called with the bare KeyEncryptor, before the wrappers hide the
+// interface.
+func (pm *ProviderManager) observeKeyFallback(keyEncryptor encryption.KeyEncryptor, alias string) {
+	notifier, ok := keyEncryptor.(encryption.KeyFallbackNotifier)
+	if !ok {
+		return
+	}
+	notifier.SetKeyFallbackHandler(func(fallback encryption.KeyFallback) {
+		monitoring.RecordProviderKeyFallback(alias, fallback.Operation)
+		pm.logger.WithError(fallback.Err).WithFields(logrus.Fields{
+			"provider":  alias,
+			"operation": fallback.Operation,
+			"primary":   fallback.Primary,
+			"fallback":  fallback.Fallback,
+		}).Warn("Primary KEK unavailable, used fallback key")
+	})
+}
+
+// EncryptDEK wraps the DEK and records the call
+func (m *metricsEncryptor) EncryptDEK(ctx context.Context, dek []byte) ([]byte, string, error) {
+	ctx, span := tracing.Start(ctx, "provider.EncryptDEK", attribute.String("s3ep.provider", m.alias))
+	start := time.Now()
+	encryptedDEK, keyID, err := m.KeyEncryptor.EncryptDEK(ctx, dek)
+	monitoring.RecordProviderDEKOperation(m.alias, "encrypt", time.Since(start), classifyProviderError(err))
+	tracing.End(span, err)
+	return encryptedDEK, keyID, err
+}
+
+// DecryptDEK unwraps the DEK and records the call
+func (m *metricsEncryptor) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "provider.DecryptDEK", attribute.String("s3ep.provider", m.alias))
+	start := time.Now()
+	dek, err := m.KeyEncryptor.DecryptDEK(ctx, encryptedDEK, keyID)
+	monitoring.RecordProviderDEKOperation(m.alias, "decrypt", time.Since(start), classifyProviderError(err))
+	tracing.End(span, err)
+	return dek, err
+}
+
+// classifyProviderError returns the error type of a failed provider call, or
+// "" for nil. Typed errors (API error codes, HTTP status codes, network
+// errors) are preferred; the message is only a fallback for providers that
+// return plain errors.
+func classifyProviderError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return providerErrorCanceled
+	}
+	if errors.Is(err, ErrKeyRateLimited) {
+		return providerErrorThrottle
+	}
+
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		switch code := coded.ErrorCode(); {
+		case throttleErrorCodes[code]:
+			return providerErrorThrottle
+		case authErrorCodes[code]:
+			return providerErrorAuth
+		}
+	}
+
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		switch status.HTTPStatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return providerErrorThrottle
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return providerErrorAuth
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return providerErrorNetwork
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case containsAny(message, "throttl", "rate exceeded", "too many requests", "resource exhausted", "slow down"):
+		return providerErrorThrottle
+	case containsAny(message, "access denied", "unauthorized", "unauthenticated", "permission denied", "forbidden", "invalid token", "expired token"):
+		return providerErrorAuth
+	case containsAny(message, "connection refused", "connection reset", "no such host", "i/o timeout", "timeout", "unreachable", "broken pipe"):
+		return providerErrorNetwork
+	}
+	return providerErrorOther
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+type codedError struct{ code string }
+
+func (e *codedError) Error() string     { return "api error " + e.code }
+func (e *codedError) ErrorCode() string { return e.code }
+
+type statusError struct{ status int }
+
+func (e *statusError) Error() string       { return fmt.Sprintf("http status %d", e.status) }
+func (e *statusError) HTTPStatusCode() int { return e.status }
+
+// failingKeyEncryptor fails every call with err
+type failingKeyEncryptor struct {
+	encryption.KeyEncryptor
+	err error
+}
+
+func (f *failingKeyEncryptor) EncryptDEK(_ context.Context, _ []byte) ([]byte, string, error) {
+	return nil, "", f.err
+}
+
+func (f *failingKeyEncryptor) DecryptDEK(_ context.Context, _ []byte, _ string) ([]byte, error) {
+	return nil, f.err
+}
+
+func TestClassifyProviderError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "success", err: nil, expected: ""},
+		{name: "canceled", err: fmt.Errorf("unwrap: %w", context.Canceled), expected: providerErrorCanceled},
+		{name: "rate limited", err: fmt.Errorf("%w: provider 'kms'", ErrKeyRateLimited), expected: providerErrorThrottle},
+		{name: "throttling code", err: fmt.Errorf("kms: %w", &codedError{code: "ThrottlingException"}), expected: providerErrorThrottle},
+		{name: "access denied code", err: &codedError{code: "AccessDeniedException"}, expected: providerErrorAuth},
+		{name: "unknown code", err: &codedError{code: "NotFoundException"}, expected: providerErrorOther},
+		{name: "HTTP 429", err: &statusError{status: 429}, expected: providerErrorThrottle},
+		{name: "HTTP 403", err: &statusError{status: 403}, expected: providerErrorAuth},
+		{name: "HTTP 500", err: &statusError{status: 500}, expected: providerErrorOther},
+		{name: "net error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("boom")}, expected: providerErrorNetwork},
+		{name: "deadline", err: fmt.Errorf("kms: %w", context.DeadlineExceeded), expected: providerErrorNetwork},
+		{name: "connection refused", err: fmt.Errorf("dial: %w", syscall.ECONNREFUSED), expected: providerErrorNetwork},
+		{name: "throttle message", err: errors.New("Rate exceeded"), expected: providerErrorThrottle},
+		{name: "auth message", err: errors.New("permission denied on key"), expected: providerErrorAuth},
+		{name: "network message", err: errors.New("lookup kms.example.com: no such host"), expected: providerErrorNetwork},
+		{name: "decryption failure", err: errors.New("cipher: message authentication failed"), expected: providerErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyProviderError(tt.err))
+		})
+	}
+}
+
+func histogramSampleCount(t *testing.T, provider, operation string) uint64 {
+	var metric dto.Metric
+	require.NoError(t, monitoring.ProviderDEKDuration.WithLabelValues(provider, operation).(prometheus.Histogram).Write(&metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestWithMetrics(t *testing.T) {
+	inner := &countingKeyEncryptor{}
+	assert.Same(t, encryption.KeyEncryptor(inner), withMetrics(inner, config.EncryptionProvider{Alias: "metrics-none", Type: "none"}))
+
+	ctx := context.Background()
+	instrumented := withMetrics(inner, config.EncryptionProvider{Alias: "metrics-ok", Type: "aes"})
+	encryptedDEK, _, err := instrumented.EncryptDEK(ctx, []byte("dek"))
+	require.NoError(t, err)
+	_, err = instrumented.DecryptDEK(ctx, encryptedDEK, "fp")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), histogramSampleCount(t, "metrics-ok", "encrypt"))
+	assert.Equal(t, uint64(1), histogramSampleCount(t, "metrics-ok", "decrypt"))
+
+	// Failed calls are timed and counted by type
+	failing := withMetrics(&failingKeyEncryptor{err: &codedError{code: "ThrottlingException"}}, config.EncryptionProvider{Alias: "metrics-fail", Type: "tink"})
+	_, err = failing.DecryptDEK(ctx, []byte("wrapped"), "fp")
+	require.Error(t, err)
+	_, err = failing.DecryptDEK(ctx, []byte("wrapped"), "fp")
+	require.Error(t, err)
+	assert.Equal(t, uint64(2), histogramSampleCount(t, "metrics-fail", "decrypt"))
+	assert.Equal(t, float64(2), testutil.ToFloat64(monitoring.ProviderDEKErrors.WithLabelValues("metrics-fail", "decrypt", providerErrorThrottle)))
+
+	// Preloaders stay preloaders
+	preloader := &preloadingKeyEncryptor{KeyEncryptor: inner}
+	loader, ok := withMetrics(preloader, config.EncryptionProvider{Alias: "metrics-ok", Type: "tink"}).(encryption.KeyPreloader)
+	require.True(t, ok)
+	require.NoError(t, loader.Preload(ctx))
+	assert.Equal(t, 1, preloader.calls)
+}
+
+func TestProviderManager_DEKCacheMetrics(t *testing.T) {
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "metrics-cache",
+			Providers: []config.EncryptionProvider{
+				{
+					Alias:  "metrics-cache",
+					Type:   "aes",
+					Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+				},
+			},
+		},
+	}
+	pm, err := NewProviderManager(cfg)
+	require.NoError(t, err)
+
+	encryptedDEK, err := pm.EncryptDEK(context.Background(), []byte("test-data-encryption-32-byte-key"), "object")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := pm.DecryptDEK(context.Background(), encryptedDEK, pm.GetActiveFingerprint(), "object")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitoring.ProviderDEKCache.WithLabelValues("metrics-cache", "miss")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(monitoring.ProviderDEKCache.WithLabelValues("metrics-cache", "hit")))
+	assert.Equal(t, uint64(1), histogramSampleCount(t, "metrics-cache", "decrypt"))
+	assert.Equal(t, "unknown", pm.aliasForFingerprint("attacker-controlled"))
+}
+
+// fallbackKeyEncryptor keeps the handler of a provider with fallback keys
+type fallbackKeyEncryptor struct {
+	encryption.KeyEncryptor
+	handler func(encryption.KeyFallback)
+}
+
+func (f *fallbackKeyEncryptor) SetKeyFallbackHandler(handler func(encryption.KeyFallback)) {
+	f.handler = handler
+}
+
+func TestProviderManager_ObserveKeyFallback(t *testing.T) {
+	pm, err := NewProviderManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "default",
+			Providers:             []config.EncryptionProvider{{Alias: "default", Type: "none"}},
+		},
+	})
+	require.NoError(t, err)
+
+	notifier := &fallbackKeyEncryptor{}
+	pm.observeKeyFallback(notifier, "kms-multi-region")
+	require.NotNil(t, notifier.handler)
+	notifier.handler(encryption.KeyFallback{Operation: "decrypt", Primary: "eu-west-1", Fallback: "eu-central-1", Err: errors.New("unavailable")})
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitoring.ProviderKeyFallbacks.WithLabelValues("kms-multi-region", "decrypt")))
+
+	// Providers without fallback keys are left alone
+	pm.observeKeyFallback(&countingKeyEncryptor{}, "single-region")
+}
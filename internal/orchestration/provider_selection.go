@@ -0,0 +1,82 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+// EncryptionProviderHeader selects, by alias, the provider that wraps the DEK
+// of a new object instead of the active one
+const EncryptionProviderHeader = "x-s3ep-encryption-provider"
+
+type encryptionProviderKey struct{}
+
+// SelectEncryptionProvider attaches the provider alias a client selected to
+// ctx. The Manager wraps the DEKs of objects encrypted with ctx with that
+// provider. Only the active provider and those listed in
+// encryption.selectable_providers can be selected, others fail with
+// ErrProviderNotSelectable. Requests of a tenant can only select the
+// provider of the tenant.
+func (m *Manager) SelectEncryptionProvider(ctx context.Context, alias string) (context.Context, error) {
+	alias = strings.TrimSpace(alias)
+	// Tenants always write with their own provider
+	if tenant := m.providerManager.tenantFor(ctx); tenant != nil {
+		if alias != "" && alias != tenant.Provider {
+			return ctx, fmt.Errorf("%w: %s (tenant %s writes with its own provider)", ErrProviderNotSelectable, alias, tenant.Name)
+		}
+		return ctx, nil
+	}
+	if alias == "" || alias == m.config.Encryption.EncryptionMethodAlias {
+		return ctx, nil
+	}
+	if !m.config.IsSelectableProvider(alias) {
+		return ctx, fmt.Errorf("%w: %s", ErrProviderNotSelectable, alias)
+	}
+	return context.WithValue(ctx, encryptionProviderKey{}, alias), nil
+}
+
+// encryptionProviderFrom returns the provider alias selected for ctx, or ""
+// for the active provider
+func encryptionProviderFrom(ctx context.Context) string {
+	alias, _ := ctx.Value(encryptionProviderKey{}).(string)
+	return alias
+}
+
+// fingerprintFor returns the fingerprint of the provider that wraps the DEKs
+// of new objects encrypted with ctx
+func (pm *ProviderManager) fingerprintFor(ctx context.Context) (string, error) {
+	alias := pm.providerAliasFor(ctx)
+	if alias == "" {
+		audit.DetailsFrom(ctx).SetKEKFingerprint(pm.activeFingerprint)
+		return pm.activeFingerprint, nil
+	}
+
+	pm.providersMutex.RLock()
+	info, ok := pm.registeredProviders[alias]
+	pm.providersMutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: provider '%s' is not loaded", ErrKeyUnavailable, alias)
+	}
+	if tenant := pm.config.TenantOfProvider(alias); tenant != nil {
+		monitoring.RecordTenantDEKOperation(tenant.Name, "wrap", true)
+	}
+	audit.DetailsFrom(ctx).SetKEKFingerprint(info.Fingerprint)
+	return info.Fingerprint, nil
+}
+
+// isSelectableFingerprint reports whether fingerprint belongs to a provider
+// clients may select for new objects, other than the active one
+func (pm *ProviderManager) isSelectableFingerprint(fingerprint string) bool {
+	pm.providersMutex.RLock()
+	defer pm.providersMutex.RUnlock()
+	for _, alias := range pm.config.Encryption.SelectableProviders {
+		if info, ok := pm.registeredProviders[alias]; ok && info.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
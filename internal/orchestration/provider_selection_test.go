@@ -0,0 +1,71 @@
+package orchestration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func TestSelectEncryptionProvider(t *testing.T) {
+	defaultProvider := config.EncryptionProvider{
+		Alias:  "kek-default",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+	}
+	tenantProvider := config.EncryptionProvider{
+		Alias:  "kek-tenant",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+	}
+	newManager := func(active string, selectable []string, providers ...config.EncryptionProvider) *Manager {
+		manager, err := NewManager(&config.Config{
+			Encryption: config.EncryptionConfig{
+				EncryptionMethodAlias: active,
+				SelectableProviders:   selectable,
+				IntegrityVerification: "strict",
+				Providers:             providers,
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+		return manager
+	}
+
+	manager := newManager("kek-default", []string{"kek-tenant"}, defaultProvider, tenantProvider)
+	tenantOnly := newManager("kek-tenant", nil, tenantProvider)
+	plaintext := []byte("tenant owned data")
+
+	_, err := manager.SelectEncryptionProvider(context.Background(), "kek-unknown")
+	assert.ErrorIs(t, err, ErrProviderNotSelectable)
+
+	ctx, err := manager.SelectEncryptionProvider(context.Background(), "kek-tenant")
+	require.NoError(t, err)
+
+	for name, contentType := range map[string]factory.ContentType{"gcm": factory.ContentTypeWhole, "ctr": factory.ContentTypeMultipart} {
+		t.Run(name, func(t *testing.T) {
+			_, defaultMetadata := encryptWithContext(t, manager, context.Background(), plaintext, contentType)
+			ciphertext, metadata := encryptWithContext(t, manager, ctx, plaintext, contentType)
+			assert.NotEqual(t, defaultMetadata["s3ep-kek-fingerprint"], metadata["s3ep-kek-fingerprint"])
+
+			// The DEK is wrapped with the selected KEK only
+			decrypted, err := decryptWithContext(tenantOnly, context.Background(), ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			decrypted, err = decryptWithContext(manager, context.Background(), ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			// Rotation leaves DEKs of selectable providers with their provider
+			rewrapped, changed, err := manager.RewrapDEK(context.Background(), metadata, "tenant/object.bin")
+			require.NoError(t, err)
+			assert.False(t, changed)
+			assert.Equal(t, metadata, rewrapped)
+		})
+	}
+}
@@ -1,31 +1,24 @@
 package orchestration
 
 import (
-	"container/list"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
 )
 
-// dekCacheCapacity bounds the LRU of decrypted DEKs. Entries are tiny (~32 B
-// of key material plus map overhead), so the absolute memory cost at the bound
-// is negligible — the bound exists to stop unbounded growth on long-running
-// proxies that touch many distinct objects.
-const dekCacheCapacity = 1024
-
-type dekCacheEntry struct {
-	key string
-	dek []byte
-}
-
 // ProviderInfo contains information about a registered encryption provider
 type ProviderInfo struct {
 	Alias       string
@@ -41,6 +34,7 @@ type ProviderSummary struct {
 	Type        string
 	Fingerprint string
 	IsActive    bool
+	Tenant      string // Tenant the provider is dedicated to, "" for shared providers
 }
 
 // ProviderManager handles provider registration, lifecycle management, and KEK/DEK operations
@@ -49,11 +43,12 @@ type ProviderManager struct {
 	activeFingerprint   string
 	activeAlias         string
 	config              *config.Config
-	keyCacheMutex       sync.Mutex // guards keyCacheItems / keyCacheOrder
-	keyCacheItems       map[string]*list.Element
-	keyCacheOrder       *list.List // front = most recently used
+	dekCache            *dekCache
 	registeredProviders map[string]ProviderInfo
 	providersMutex      sync.RWMutex
+	recovery            *keyencryption.AgeProvider         // nil without recovery recipients
+	keyVersioners       map[string]encryption.KeyVersioner // by fingerprint, guarded by providersMutex
+	addedProviders      []config.EncryptionProvider        // added by configuration reloads, guarded by providersMutex
 	logger              *logrus.Entry
 }
 
@@ -77,12 +72,25 @@ func NewProviderManager(cfg *config.Config) (*ProviderManager, error) {
 		activeFingerprint:   "",
 		activeAlias:         activeProvider.Alias,
 		config:              cfg,
-		keyCacheItems:       make(map[string]*list.Element),
-		keyCacheOrder:       list.New(),
+		dekCache:            newDEKCacheFromConfig(cfg.Optimizations.DEKCache),
 		registeredProviders: make(map[string]ProviderInfo),
+		keyVersioners:       make(map[string]encryption.KeyVersioner),
 		logger:              logger,
 	}
 
+	recovery, err := newRecoveryProvider(cfg)
+	if err != nil {
+		logger.WithError(err).Error("Failed to load recovery recipients")
+		return nil, err
+	}
+	pm.recovery = recovery
+	if recovery != nil {
+		logger.WithFields(logrus.Fields{
+			"recipients":  len(recovery.Recipients()),
+			"fingerprint": recovery.Fingerprint(),
+		}).Info("New DEKs get a recovery copy for the recovery recipients")
+	}
+
 	allProviders := cfg.GetAllProviders()
 	var activeFingerprint string
 
@@ -96,6 +104,8 @@ func NewProviderManager(cfg *config.Config) (*ProviderManager, error) {
 			keyType = factory.KeyEncryptionTypeRSA
 		case "tink":
 			keyType = factory.KeyEncryptionTypeTink
+		case "vault-transit":
+			keyType = factory.KeyEncryptionTypeVaultTransit
 		case "none":
 			keyType = factory.KeyEncryptionTypeNone
 		default:
@@ -116,9 +126,17 @@ func NewProviderManager(cfg *config.Config) (*ProviderManager, error) {
 			}).Error("Failed to create key encryptor")
 			return nil, fmt.Errorf("failed to create key encryptor for provider '%s': %w", provider.Alias, err)
 		}
+		pm.registerKeyVersioner(keyEncryptor)
+		pm.observeKeyFallback(keyEncryptor, provider.Alias)
+		keyEncryptor = withRateLimit(withMetrics(withRecovery(keyEncryptor, provider, recovery), provider), provider)
 
 		// Register with factory
 		factoryInstance.RegisterKeyEncryptor(keyEncryptor)
+		dataCipher, err := factory.ResolveDataCipher(provider.DataCipher)
+		if err != nil {
+			return nil, fmt.Errorf("provider '%s': %w", provider.Alias, err)
+		}
+		factoryInstance.SetDataCipher(keyEncryptor.Fingerprint(), dataCipher)
 
 		// Create provider info and register in manager
 		providerInfo := ProviderInfo{
@@ -137,62 +155,116 @@ func NewProviderManager(cfg *config.Config) (*ProviderManager, error) {
 				"provider_alias": provider.Alias,
 				"provider_type":  provider.Type,
 				"fingerprint":    activeFingerprint,
+				"data_cipher":    dataCipher,
 			}).Info("Registered active provider")
 		} else {
 			logger.WithFields(logrus.Fields{
 				"provider_alias": provider.Alias,
 				"provider_type":  provider.Type,
 				"fingerprint":    keyEncryptor.Fingerprint(),
+				"data_cipher":    dataCipher,
 			}).Info("Registered provider")
 		}
 	}
 
 	if activeFingerprint == "" {
 		logger.WithField("active_provider_alias", activeProvider.Alias).Error("Active provider not found or not supported")
-		return nil, fmt.Errorf("active provider '%s' not found or not supported", activeProvider.Alias)
+		return nil, fmt.Errorf("%w: active provider '%s' not found or not supported", ErrKeyUnavailable, activeProvider.Alias)
 	}
 
 	pm.activeFingerprint = activeFingerprint
+	if err := pm.checkTenantKeys(); err != nil {
+		logger.WithError(err).Error("Tenant providers do not have keys of their own")
+		return nil, err
+	}
+	pm.loadKeyCache(context.Background())
 	return pm, nil
 }
 
+// checkTenantKeys makes sure no other provider has the key of a tenant
+// provider. Providers are told apart by their key fingerprint, so a shared
+// key would let another provider unwrap the tenant's DEKs.
+func (pm *ProviderManager) checkTenantKeys() error {
+	for _, tenant := range pm.config.Encryption.Tenants {
+		dedicated, ok := pm.registeredProviders[tenant.Provider]
+		if !ok {
+			return fmt.Errorf("%w: provider '%s' of tenant '%s' is not loaded", ErrKeyUnavailable, tenant.Provider, tenant.Name)
+		}
+		for alias, info := range pm.registeredProviders {
+			if alias != tenant.Provider && info.Fingerprint == dedicated.Fingerprint {
+				return fmt.Errorf("provider '%s' of tenant '%s' has the same key as provider '%s'", tenant.Provider, tenant.Name, alias)
+			}
+		}
+	}
+	return nil
+}
+
+// newRecoveryProvider returns the age provider of the recovery recipients, or
+// nil if none are configured
+func newRecoveryProvider(cfg *config.Config) (*keyencryption.AgeProvider, error) {
+	if len(cfg.Encryption.RecoveryRecipients) == 0 {
+		return nil, nil
+	}
+	recovery, err := keyencryption.NewAgeProviderFromStrings(cfg.Encryption.RecoveryRecipients, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption.recovery_recipients: %w", err)
+	}
+	return recovery, nil
+}
+
+// withRecovery adds the recovery copy to the DEKs of a provider. Every
+// provider except "none" is wrapped, even without recovery recipients, so
+// DEKs written while recipients were configured stay readable.
+func withRecovery(keyEncryptor encryption.KeyEncryptor, provider config.EncryptionProvider, recovery *keyencryption.AgeProvider) encryption.KeyEncryptor {
+	if provider.Type == "none" {
+		return keyEncryptor
+	}
+	return keyencryption.NewRecoveryEncryptor(keyEncryptor, recovery)
+}
+
 // EncryptDEK encrypts a Data Encryption Key using the active provider
-func (pm *ProviderManager) EncryptDEK(dek []byte, objectKey string) ([]byte, error) {
+func (pm *ProviderManager) EncryptDEK(ctx context.Context, dek []byte, objectKey string) ([]byte, error) {
+	return pm.EncryptDEKWith(ctx, pm.activeFingerprint, dek, objectKey)
+}
+
+// EncryptDEKWith encrypts a Data Encryption Key using the provider identified
+// by fingerprint. The provider call is traced as part of ctx but not canceled
+// with it.
+func (pm *ProviderManager) EncryptDEKWith(ctx context.Context, fingerprint string, dek []byte, objectKey string) ([]byte, error) {
 	// Validate input
 	if len(dek) == 0 {
 		return nil, fmt.Errorf("DEK cannot be empty")
 	}
 
-	if pm.activeFingerprint == "none-provider-fingerprint" {
+	if fingerprint == "none-provider-fingerprint" {
 		// For none provider, return the DEK as-is (no encryption)
-		pm.logger.WithField("object_key", objectKey).Debug("Using none provider - DEK not encrypted")
+		pm.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none provider - DEK not encrypted")
 		return dek, nil
 	}
 
-	// Get active provider from factory
-	keyEncryptor, err := pm.factory.GetKeyEncryptor(pm.activeFingerprint)
+	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
 	if err != nil {
-		pm.logger.WithFields(logrus.Fields{
-			"fingerprint": pm.activeFingerprint,
+		pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"fingerprint": fingerprint,
 			"object_key":  objectKey,
 			"error":       err,
-		}).Error("Failed to get active key encryptor")
-		return nil, fmt.Errorf("failed to get active key encryptor: %w", err)
+		}).Error("Failed to get key encryptor")
+		return nil, fmt.Errorf("failed to get key encryptor: %w", err)
 	}
 
 	// Encrypt the DEK
-	encryptedDEK, _, err := keyEncryptor.EncryptDEK(context.Background(), dek)
+	encryptedDEK, _, err := keyEncryptor.EncryptDEK(context.WithoutCancel(ctx), dek)
 	if err != nil {
-		pm.logger.WithFields(logrus.Fields{
-			"fingerprint": pm.activeFingerprint,
+		pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"fingerprint": fingerprint,
 			"object_key":  objectKey,
 			"error":       err,
 		}).Error("Failed to encrypt DEK")
 		return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
 	}
 
-	pm.logger.WithFields(logrus.Fields{
-		"fingerprint": pm.activeFingerprint,
+	pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"fingerprint": fingerprint,
 		"object_key":  objectKey,
 		"dek_size":    len(dek),
 	}).Debug("Successfully encrypted DEK")
@@ -200,13 +272,13 @@ func (pm *ProviderManager) EncryptDEK(dek []byte, objectKey string) ([]byte, err
 	return encryptedDEK, nil
 }
 
-// DecryptDEK decrypts a Data Encryption Key using the provider identified by fingerprint.
+// DecryptDEK decrypts a Data Encryption Key using the provider identified by
+// fingerprint. The provider call is traced as part of ctx but not canceled with
+// it, since identical unwraps of other requests may wait for its result.
 //
-// The returned slice is either the cache's backing array (on a hit) or the
-// encryptor's fresh allocation (on a miss); in both cases callers MUST treat
-// it as read-only. Mutating (including zeroing) the returned slice will
-// corrupt subsequent cache hits.
-func (pm *ProviderManager) DecryptDEK(encryptedDEK []byte, fingerprint, objectKey string) ([]byte, error) {
+// The returned slice belongs to the caller: the cache keeps its own copy,
+// which it zeroes on eviction.
+func (pm *ProviderManager) DecryptDEK(ctx context.Context, encryptedDEK []byte, fingerprint, objectKey string) ([]byte, error) {
 	// Validate input
 	if len(encryptedDEK) == 0 {
 		return nil, fmt.Errorf("encrypted DEK cannot be empty")
@@ -218,8 +290,9 @@ func (pm *ProviderManager) DecryptDEK(encryptedDEK []byte, fingerprint, objectKe
 	// this, the cache would serve a stale DEK for the new ciphertext and
 	// HMAC verification would fail. See ticket 011.
 	cacheKey := buildDEKCacheKey(fingerprint, objectKey, encryptedDEK)
-	if cachedDEK, ok := pm.cacheGet(cacheKey); ok {
-		pm.logger.WithFields(logrus.Fields{
+	if cachedDEK, ok := pm.dekCache.get(cacheKey); ok {
+		monitoring.RecordProviderDEKCache(pm.aliasForFingerprint(fingerprint), true)
+		pm.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"fingerprint": fingerprint,
 			"object_key":  objectKey,
 		}).Debug("Retrieved DEK from cache")
@@ -228,25 +301,26 @@ func (pm *ProviderManager) DecryptDEK(encryptedDEK []byte, fingerprint, objectKe
 
 	if fingerprint == "none-provider-fingerprint" {
 		// For none provider, return the encrypted DEK as-is (no decryption)
-		pm.logger.WithField("object_key", objectKey).Debug("Using none provider - DEK not decrypted")
+		pm.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Using none provider - DEK not decrypted")
 		return encryptedDEK, nil
 	}
+	monitoring.RecordProviderDEKCache(pm.aliasForFingerprint(fingerprint), false)
 
 	// Get provider by fingerprint
 	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
 	if err != nil {
-		pm.logger.WithFields(logrus.Fields{
+		pm.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"fingerprint": fingerprint,
 			"object_key":  objectKey,
 			"error":       err,
 		}).Error("Failed to get key encryptor by fingerprint")
-		return nil, fmt.Errorf("no provider found with fingerprint '%s': %w", fingerprint, err)
+		return nil, &KeyUnavailableError{Fingerprint: fingerprint, Err: err}
 	}
 
 	// Decrypt the DEK
-	dek, err := keyEncryptor.DecryptDEK(context.Background(), encryptedDEK, fingerprint)
+	dek, err := keyEncryptor.DecryptDEK(context.WithoutCancel(ctx), encryptedDEK, fingerprint)
 	if err != nil {
-		pm.logger.WithFields(logrus.Fields{
+		pm.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"fingerprint": fingerprint,
 			"object_key":  objectKey,
 			"error":       err,
@@ -254,11 +328,10 @@ func (pm *ProviderManager) DecryptDEK(encryptedDEK []byte, fingerprint, objectKe
 		return nil, fmt.Errorf("failed to decrypt DEK: %w", err)
 	}
 
-	// Cache the decrypted DEK. The cache owns the stored copy; callers of
-	// DecryptDEK must treat the returned slice as read-only (see cacheGet).
-	pm.cachePut(cacheKey, dek)
+	// Cache the decrypted DEK. The cache stores a copy of its own.
+	pm.dekCache.put(cacheKey, fingerprint, dek)
 
-	pm.logger.WithFields(logrus.Fields{
+	pm.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"fingerprint": fingerprint,
 		"object_key":  objectKey,
 		"dek_size":    len(dek),
@@ -267,6 +340,21 @@ func (pm *ProviderManager) DecryptDEK(encryptedDEK []byte, fingerprint, objectKe
 	return dek, nil
 }
 
+// aliasForFingerprint returns the alias of the provider with fingerprint for
+// metric labels. Fingerprints come from object metadata, so unknown ones map
+// to a fixed value instead of becoming a label.
+func (pm *ProviderManager) aliasForFingerprint(fingerprint string) string {
+	pm.providersMutex.RLock()
+	defer pm.providersMutex.RUnlock()
+
+	for alias, info := range pm.registeredProviders {
+		if info.Fingerprint == fingerprint {
+			return alias
+		}
+	}
+	return "unknown"
+}
+
 // GetActiveFingerprint returns the fingerprint of the active provider
 func (pm *ProviderManager) GetActiveFingerprint() string {
 	return pm.activeFingerprint
@@ -284,16 +372,22 @@ func (pm *ProviderManager) GetActiveProviderAlias() string {
 
 // GetActiveProviderAlgorithm returns the algorithm name of the active provider
 func (pm *ProviderManager) GetActiveProviderAlgorithm() string {
-	if pm.activeFingerprint == "none-provider-fingerprint" {
+	return pm.ProviderAlgorithm(pm.activeFingerprint)
+}
+
+// ProviderAlgorithm returns the algorithm name of the provider identified by
+// fingerprint, or "" if there is none
+func (pm *ProviderManager) ProviderAlgorithm(fingerprint string) string {
+	if fingerprint == "none-provider-fingerprint" {
 		return "none"
 	}
 
-	keyEncryptor, err := pm.factory.GetKeyEncryptor(pm.activeFingerprint)
+	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
 	if err != nil {
 		pm.logger.WithFields(logrus.Fields{
-			"fingerprint": pm.activeFingerprint,
+			"fingerprint": fingerprint,
 			"error":       err,
-		}).Error("Failed to get active provider for algorithm name")
+		}).Error("Failed to get provider for algorithm name")
 		return ""
 	}
 
@@ -305,7 +399,7 @@ func (pm *ProviderManager) GetActiveProviderAlgorithm() string {
 func (pm *ProviderManager) GetProviderByFingerprint(fingerprint string) (encryption.KeyEncryptor, error) {
 	if fingerprint == "none-provider-fingerprint" {
 		pm.logger.Debug("Requested none provider by fingerprint")
-		return nil, fmt.Errorf("none provider does not support key encryption")
+		return nil, fmt.Errorf("%w: none provider does not support key encryption", ErrKeyUnavailable)
 	}
 
 	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
@@ -314,7 +408,7 @@ func (pm *ProviderManager) GetProviderByFingerprint(fingerprint string) (encrypt
 			"fingerprint": fingerprint,
 			"error":       err,
 		}).Error("Failed to get provider by fingerprint")
-		return nil, fmt.Errorf("no provider found with fingerprint '%s': %w", fingerprint, err)
+		return nil, &KeyUnavailableError{Fingerprint: fingerprint, Err: err}
 	}
 
 	return keyEncryptor, nil
@@ -322,11 +416,17 @@ func (pm *ProviderManager) GetProviderByFingerprint(fingerprint string) (encrypt
 
 // CreateEnvelopeEncryptor creates an envelope encryptor for the given content type
 func (pm *ProviderManager) CreateEnvelopeEncryptor(contentType factory.ContentType, metadataPrefix string) (encryption.EnvelopeEncryptor, error) {
-	envelopeEncryptor, err := pm.factory.CreateEnvelopeEncryptor(contentType, pm.activeFingerprint, metadataPrefix)
+	return pm.CreateEnvelopeEncryptorWith(pm.activeFingerprint, contentType, metadataPrefix)
+}
+
+// CreateEnvelopeEncryptorWith creates an envelope encryptor that wraps DEKs
+// with the provider identified by fingerprint
+func (pm *ProviderManager) CreateEnvelopeEncryptorWith(fingerprint string, contentType factory.ContentType, metadataPrefix string) (encryption.EnvelopeEncryptor, error) {
+	envelopeEncryptor, err := pm.factory.CreateEnvelopeEncryptor(contentType, fingerprint, metadataPrefix)
 	if err != nil {
 		pm.logger.WithFields(logrus.Fields{
 			"content_type":    contentType,
-			"fingerprint":     pm.activeFingerprint,
+			"fingerprint":     fingerprint,
 			"metadata_prefix": metadataPrefix,
 			"error":           err,
 		}).Error("Failed to create envelope encryptor")
@@ -335,16 +435,22 @@ func (pm *ProviderManager) CreateEnvelopeEncryptor(contentType factory.ContentTy
 
 	pm.logger.WithFields(logrus.Fields{
 		"content_type":    contentType,
-		"fingerprint":     pm.activeFingerprint,
+		"fingerprint":     fingerprint,
 		"metadata_prefix": metadataPrefix,
 	}).Debug("Created envelope encryptor")
 
 	return envelopeEncryptor, nil
 }
 
+// DataAlgorithm returns the data encryption algorithm that new objects of a
+// content type get with the provider identified by fingerprint
+func (pm *ProviderManager) DataAlgorithm(contentType factory.ContentType, fingerprint string) string {
+	return pm.factory.DataAlgorithm(contentType, fingerprint)
+}
+
 // GetProviderAliases returns all provider aliases from configuration
 func (pm *ProviderManager) GetProviderAliases() []string {
-	allProviders := pm.config.GetAllProviders()
+	allProviders := pm.allProviders()
 	aliases := make([]string, 0, len(allProviders))
 	for _, provider := range allProviders {
 		aliases = append(aliases, provider.Alias)
@@ -354,7 +460,7 @@ func (pm *ProviderManager) GetProviderAliases() []string {
 
 // GetLoadedProviders returns information about all loaded encryption providers
 func (pm *ProviderManager) GetLoadedProviders() []ProviderSummary {
-	allProviders := pm.config.GetAllProviders()
+	allProviders := pm.allProviders()
 	factoryProviders := pm.factory.GetRegisteredProviderInfo()
 
 	// Create a map of fingerprints to provider info for quick lookup
@@ -372,10 +478,21 @@ func (pm *ProviderManager) GetLoadedProviders() []ProviderSummary {
 			Type:     provider.Type,
 			IsActive: provider.Alias == activeAlias,
 		}
+		if tenant := pm.config.TenantOfProvider(provider.Alias); tenant != nil {
+			summary.Tenant = tenant.Name
+		}
+
+		pm.providersMutex.RLock()
+		registered, isRegistered := pm.registeredProviders[provider.Alias]
+		pm.providersMutex.RUnlock()
 
 		if provider.Type == "none" {
 			// Special case for none provider
 			summary.Fingerprint = "none-provider-fingerprint"
+		} else if isRegistered {
+			// Providers of the same type, such as those of several tenants,
+			// can only be told apart by the fingerprint they registered with
+			summary.Fingerprint = registered.Fingerprint
 		} else {
 			// Find matching factory provider by searching through all registered providers
 			// Since we don't have a direct mapping, we need to match by type and other characteristics
@@ -396,65 +513,76 @@ func (pm *ProviderManager) GetLoadedProviders() []ProviderSummary {
 
 // ClearKeyCache clears the DEK cache for memory management
 func (pm *ProviderManager) ClearKeyCache() {
-	pm.keyCacheMutex.Lock()
-	cacheSize := len(pm.keyCacheItems)
-	pm.keyCacheItems = make(map[string]*list.Element)
-	pm.keyCacheOrder = list.New()
-	pm.keyCacheMutex.Unlock()
-
+	cacheSize := pm.dekCache.clear()
 	pm.logger.WithField("cached_keys", cacheSize).Info("Cleared DEK cache")
 }
 
-// buildDEKCacheKey returns the cache key for a (fingerprint, objectKey,
-// encryptedDEK) triple. Including a digest of the encryptedDEK ensures that
-// re-uploading the same object key under a fresh DEK does not produce a stale
-// hit (ticket 011).
-func buildDEKCacheKey(fingerprint, objectKey string, encryptedDEK []byte) string {
-	sum := sha256.Sum256(encryptedDEK)
-	return fmt.Sprintf("%s:%s:%s", fingerprint, objectKey, hex.EncodeToString(sum[:8]))
+// SaveKeyCache writes the DEK cache to optimizations.dek_cache.persist_path,
+// if set, so the next start can skip the provider unwraps of recently used
+// DEKs. The entries of each provider are encrypted with a key wrapped by that
+// provider.
+func (pm *ProviderManager) SaveKeyCache(ctx context.Context) error {
+	path := pm.config.Optimizations.DEKCache.PersistPath
+	if path == "" || pm.dekCache.capacity <= 0 {
+		return nil
+	}
+	saved, err := pm.dekCache.save(ctx, path, pm)
+	pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"path":        path,
+		"cached_keys": saved,
+	}).Info("Saved DEK cache")
+	return err
 }
 
-// cacheGet returns the cached DEK by reference and promotes the entry to MRU.
-// The returned slice is the cache's own storage — callers MUST NOT mutate it.
-// Skipping the per-hit copy keeps DEK lookups at zero allocations.
-func (pm *ProviderManager) cacheGet(key string) ([]byte, bool) {
-	pm.keyCacheMutex.Lock()
-	defer pm.keyCacheMutex.Unlock()
-
-	elem, ok := pm.keyCacheItems[key]
-	if !ok {
-		return nil, false
+// loadKeyCache restores a DEK cache saved by SaveKeyCache. A missing file is
+// not an error; a cache that cannot be read is skipped and the proxy starts
+// with an empty cache.
+func (pm *ProviderManager) loadKeyCache(ctx context.Context) {
+	path := pm.config.Optimizations.DEKCache.PersistPath
+	if path == "" || pm.dekCache.capacity <= 0 {
+		return
 	}
-	pm.keyCacheOrder.MoveToFront(elem)
-	return elem.Value.(*dekCacheEntry).dek, true
-}
-
-// cachePut inserts (or refreshes) an entry and evicts the LRU entry when the
-// cache exceeds dekCacheCapacity. The DEK is copied so the cache owns its
-// backing array, independent of whatever the caller does with their input
-// slice afterwards.
-func (pm *ProviderManager) cachePut(key string, dek []byte) {
-	pm.keyCacheMutex.Lock()
-	defer pm.keyCacheMutex.Unlock()
-
-	if elem, ok := pm.keyCacheItems[key]; ok {
-		entry := elem.Value.(*dekCacheEntry)
-		entry.dek = append([]byte(nil), dek...)
-		pm.keyCacheOrder.MoveToFront(elem)
+	loaded, err := pm.dekCache.load(ctx, path, pm)
+	if errors.Is(err, os.ErrNotExist) {
 		return
 	}
+	entry := pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"path":        path,
+		"cached_keys": loaded,
+	})
+	if err != nil {
+		entry.WithError(err).Warn("Failed to restore the saved DEK cache completely")
+		return
+	}
+	entry.Info("Restored saved DEK cache")
+}
 
-	entry := &dekCacheEntry{key: key, dek: append([]byte(nil), dek...)}
-	pm.keyCacheItems[key] = pm.keyCacheOrder.PushFront(entry)
+// wrapKey encrypts a persisted cache's file key with a provider
+func (pm *ProviderManager) wrapKey(ctx context.Context, fingerprint string, key []byte) ([]byte, error) {
+	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, _, err := keyEncryptor.EncryptDEK(ctx, key)
+	return wrapped, err
+}
 
-	for pm.keyCacheOrder.Len() > dekCacheCapacity {
-		oldest := pm.keyCacheOrder.Back()
-		if oldest == nil {
-			break
-		}
-		pm.keyCacheOrder.Remove(oldest)
-		delete(pm.keyCacheItems, oldest.Value.(*dekCacheEntry).key)
+// unwrapKey decrypts a persisted cache's file key with a provider
+func (pm *ProviderManager) unwrapKey(ctx context.Context, fingerprint string, wrapped []byte) ([]byte, error) {
+	keyEncryptor, err := pm.factory.GetKeyEncryptor(fingerprint)
+	if err != nil {
+		return nil, err
 	}
+	return keyEncryptor.DecryptDEK(ctx, wrapped, fingerprint)
+}
+
+// buildDEKCacheKey returns the cache key for a (fingerprint, objectKey,
+// encryptedDEK) triple. Including a digest of the encryptedDEK ensures that
+// re-uploading the same object key under a fresh DEK does not produce a stale
+// hit (ticket 011).
+func buildDEKCacheKey(fingerprint, objectKey string, encryptedDEK []byte) string {
+	sum := sha256.Sum256(encryptedDEK)
+	return fmt.Sprintf("%s:%s:%s", fingerprint, objectKey, hex.EncodeToString(sum[:8]))
 }
 
 // GetFactory returns the underlying factory instance (for advanced use cases)
@@ -467,73 +595,109 @@ func (pm *ProviderManager) IsNoneProvider() bool {
 	return pm.activeFingerprint == "none-provider-fingerprint"
 }
 
-// registerProvider registers a single provider with the factory
-//
-//nolint:unused // may be used for dynamic provider registration in future
-func (pm *ProviderManager) registerProvider(provider config.EncryptionProvider) error {
-	pm.logger.WithFields(logrus.Fields{
-		"provider_alias": provider.Alias,
-		"provider_type":  provider.Type,
-	}).Debug("Registering encryption provider")
-
-	// Map KEK provider types to factory types
-	var keyType factory.KeyEncryptionType
-	switch provider.Type {
-	case "aes":
-		keyType = factory.KeyEncryptionTypeAES
-	case "rsa":
-		keyType = factory.KeyEncryptionTypeRSA
-	case "tink":
-		keyType = factory.KeyEncryptionTypeTink
-	case "none":
-		keyType = factory.KeyEncryptionTypeNone
-	default:
-		return fmt.Errorf("unsupported provider type: %s", provider.Type)
-	}
-
-	// Create key encryptor
-	keyEncryptor, err := pm.factory.CreateKeyEncryptorFromConfig(keyType, provider.Config)
-	if err != nil {
-		return fmt.Errorf("failed to create key encryptor for provider '%s': %w", provider.Alias, err)
+// providerKeyTypes maps the provider types of the configuration to the key
+// encryption types of the factory
+var providerKeyTypes = map[string]factory.KeyEncryptionType{
+	"aes":           factory.KeyEncryptionTypeAES,
+	"rsa":           factory.KeyEncryptionTypeRSA,
+	"tink":          factory.KeyEncryptionTypeTink,
+	"vault-transit": factory.KeyEncryptionTypeVaultTransit,
+	"none":          factory.KeyEncryptionTypeNone,
+}
+
+// AddProviders registers providers that were added to the configuration
+// while the proxy runs, so objects whose DEKs they wrapped can be read. The
+// active provider stays the same. Either every provider is added or, if one
+// of them cannot be created, duplicates an alias or has the key of a
+// registered provider, none is.
+func (pm *ProviderManager) AddProviders(providers []config.EncryptionProvider) error {
+	type addedProvider struct {
+		provider     config.EncryptionProvider
+		keyEncryptor encryption.KeyEncryptor
+		dataCipher   factory.DataCipher
+	}
+
+	added := make([]addedProvider, 0, len(providers))
+	aliases := make(map[string]bool)
+	fingerprints := make(map[string]string)
+	pm.providersMutex.RLock()
+	for alias, info := range pm.registeredProviders {
+		aliases[alias] = true
+		fingerprints[info.Fingerprint] = alias
 	}
+	pm.providersMutex.RUnlock()
 
-	// Register with factory
-	pm.factory.RegisterKeyEncryptor(keyEncryptor)
+	for _, provider := range providers {
+		if aliases[provider.Alias] {
+			return fmt.Errorf("provider '%s' is already registered", provider.Alias)
+		}
+		aliases[provider.Alias] = true
+		keyType, ok := providerKeyTypes[provider.Type]
+		if !ok {
+			return fmt.Errorf("unsupported provider type: %s", provider.Type)
+		}
+		keyEncryptor, err := pm.factory.CreateKeyEncryptorFromConfig(keyType, provider.Config)
+		if err != nil {
+			return fmt.Errorf("failed to create key encryptor for provider '%s': %w", provider.Alias, err)
+		}
+		dataCipher, err := factory.ResolveDataCipher(provider.DataCipher)
+		if err != nil {
+			return fmt.Errorf("provider '%s': %w", provider.Alias, err)
+		}
 
-	// Store provider info
-	info := ProviderInfo{
-		Alias:       provider.Alias,
-		Type:        provider.Type,
-		Fingerprint: keyEncryptor.Fingerprint(),
-		IsActive:    provider.Alias == pm.activeAlias,
-		Encryptor:   keyEncryptor,
+		// Providers are told apart by their key fingerprint
+		if alias, exists := fingerprints[keyEncryptor.Fingerprint()]; exists && provider.Type != "none" {
+			return fmt.Errorf("provider '%s' has the same key as provider '%s'", provider.Alias, alias)
+		}
+		fingerprints[keyEncryptor.Fingerprint()] = provider.Alias
+		added = append(added, addedProvider{provider: provider, keyEncryptor: keyEncryptor, dataCipher: dataCipher})
 	}
 
 	pm.providersMutex.Lock()
-	pm.registeredProviders[provider.Alias] = info
-	pm.providersMutex.Unlock()
-
-	// Track the active provider's fingerprint
-	if provider.Alias == pm.activeAlias {
-		pm.activeFingerprint = keyEncryptor.Fingerprint()
+	defer pm.providersMutex.Unlock()
+	for _, a := range added {
+		if _, exists := pm.registeredProviders[a.provider.Alias]; exists {
+			return fmt.Errorf("provider '%s' is already registered", a.provider.Alias)
+		}
 	}
+	for _, a := range added {
+		keyEncryptor := a.keyEncryptor
+		if versioner, ok := keyEncryptor.(encryption.KeyVersioner); ok {
+			pm.keyVersioners[keyEncryptor.Fingerprint()] = versioner
+		}
+		pm.observeKeyFallback(keyEncryptor, a.provider.Alias)
+		keyEncryptor = withRateLimit(withMetrics(withRecovery(keyEncryptor, a.provider, pm.recovery), a.provider), a.provider)
+
+		pm.factory.RegisterKeyEncryptor(keyEncryptor)
+		pm.factory.SetDataCipher(keyEncryptor.Fingerprint(), a.dataCipher)
+		pm.registeredProviders[a.provider.Alias] = ProviderInfo{
+			Alias:       a.provider.Alias,
+			Type:        a.provider.Type,
+			Fingerprint: keyEncryptor.Fingerprint(),
+			Encryptor:   keyEncryptor,
+		}
+		pm.addedProviders = append(pm.addedProviders, a.provider)
 
-	pm.logger.WithFields(logrus.Fields{
-		"provider_alias": provider.Alias,
-		"provider_type":  provider.Type,
-		"fingerprint":    keyEncryptor.Fingerprint(),
-		"is_active":      provider.Alias == pm.activeAlias,
-	}).Info("Successfully registered encryption provider")
-
+		pm.logger.WithFields(logrus.Fields{
+			"provider_alias": a.provider.Alias,
+			"provider_type":  a.provider.Type,
+			"fingerprint":    keyEncryptor.Fingerprint(),
+			"data_cipher":    a.dataCipher,
+		}).Info("Registered provider added by a configuration reload")
+	}
 	return nil
 }
 
+// allProviders returns the configured providers and those added since
+func (pm *ProviderManager) allProviders() []config.EncryptionProvider {
+	pm.providersMutex.RLock()
+	defer pm.providersMutex.RUnlock()
+	return append(slices.Clone(pm.config.GetAllProviders()), pm.addedProviders...)
+}
+
 // ClearCache clears the DEK cache
 func (pm *ProviderManager) ClearCache() {
-	pm.keyCacheMutex.Lock()
-	pm.keyCacheItems = make(map[string]*list.Element)
-	pm.keyCacheOrder = list.New()
-	pm.keyCacheMutex.Unlock()
+	pm.dekCache.clear()
 	pm.logger.Debug("Cleared DEK cache")
 }
 
@@ -573,7 +737,7 @@ func (pm *ProviderManager) ValidateConfiguration() error {
 	}
 
 	if !activeProviderFound {
-		return fmt.Errorf("active provider with fingerprint '%s' not found", pm.activeFingerprint)
+		return &KeyUnavailableError{Fingerprint: pm.activeFingerprint}
 	}
 
 	return nil
@@ -1,17 +1,20 @@
 package orchestration
 
 import (
-	"container/list"
+	"bufio"
+	"bytes"
 	"context"
-	"fmt"
+	"io"
+	"strings"
 	"testing"
 
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
 )
 
 // MockKeyEncryptor implements KeyEncryptor for testing
@@ -292,7 +295,7 @@ func TestProviderManager_EncryptDecryptDEK(t *testing.T) {
 	require.Len(t, testDEK, 32)                           // AES-256 requires 32-byte key
 
 	t.Run("encrypt DEK with active provider", func(t *testing.T) {
-		encryptedDEK, err := pm.EncryptDEK(testDEK, "test-object-key")
+		encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
 		assert.NoError(t, err)
 		assert.NotNil(t, encryptedDEK)
 		assert.NotEqual(t, testDEK, encryptedDEK)
@@ -302,46 +305,100 @@ func TestProviderManager_EncryptDecryptDEK(t *testing.T) {
 	t.Run("encrypt DEK with nonexistent provider", func(t *testing.T) {
 		// This test needs to be adjusted since EncryptDEK now uses the active provider
 		// Let's test with invalid DEK instead
-		_, err := pm.EncryptDEK([]byte{}, "test-object-key")
+		_, err := pm.EncryptDEK(context.Background(), []byte{}, "test-object-key")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "DEK cannot be empty")
 	})
 
 	t.Run("encrypt empty DEK", func(t *testing.T) {
-		_, err := pm.EncryptDEK([]byte{}, "test-object-key")
+		_, err := pm.EncryptDEK(context.Background(), []byte{}, "test-object-key")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "DEK cannot be empty")
 	})
 
 	t.Run("encrypt and decrypt DEK round trip", func(t *testing.T) {
 		// Encrypt with active provider
-		encryptedDEK, err := pm.EncryptDEK(testDEK, "test-object-key")
+		encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
 		require.NoError(t, err)
 
 		// Decrypt with fingerprint
 		fingerprint := pm.GetActiveFingerprint()
-		decryptedDEK, err := pm.DecryptDEK(encryptedDEK, fingerprint, "test-object-key")
+		decryptedDEK, err := pm.DecryptDEK(context.Background(), encryptedDEK, fingerprint, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, decryptedDEK)
 	})
 
 	t.Run("decrypt with invalid fingerprint", func(t *testing.T) {
-		encryptedDEK, err := pm.EncryptDEK(testDEK, "test-object-key")
+		encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
 		require.NoError(t, err)
 
-		_, err = pm.DecryptDEK(encryptedDEK, "invalid-fingerprint", "test-object-key")
+		_, err = pm.DecryptDEK(context.Background(), encryptedDEK, "invalid-fingerprint", "test-object-key")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "no provider found with fingerprint")
+		assert.ErrorIs(t, err, ErrKeyUnavailable)
 	})
 
 	t.Run("decrypt empty encrypted DEK", func(t *testing.T) {
 		fingerprint := pm.GetActiveFingerprint()
-		_, err := pm.DecryptDEK([]byte{}, fingerprint, "test-object-key")
+		_, err := pm.DecryptDEK(context.Background(), []byte{}, fingerprint, "test-object-key")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "encrypted DEK cannot be empty")
 	})
 }
 
+func TestProviderManager_RecoveryRecipients(t *testing.T) {
+	identity, err := keyencryption.GenerateAgeIdentity()
+	require.NoError(t, err)
+
+	newConfig := func(recipients ...string) *config.Config {
+		return &config.Config{
+			Encryption: config.EncryptionConfig{
+				EncryptionMethodAlias: "test-aes",
+				Providers: []config.EncryptionProvider{
+					{
+						Alias: "test-aes",
+						Type:  "aes",
+						Config: map[string]interface{}{
+							"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY=",
+						},
+					},
+				},
+				RecoveryRecipients: recipients,
+			},
+		}
+	}
+
+	pm, err := NewProviderManager(newConfig(identity.Recipient().String()))
+	require.NoError(t, err)
+	plain, err := NewProviderManager(newConfig())
+	require.NoError(t, err)
+	assert.Equal(t, plain.GetActiveFingerprint(), pm.GetActiveFingerprint())
+	assert.Equal(t, "aes", pm.GetActiveProviderAlgorithm())
+
+	testDEK := []byte("test-data-encryption-32-byte-key")
+	encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
+	require.NoError(t, err)
+
+	recovered, err := keyencryption.RecoverDEK(encryptedDEK, []*keyencryption.AgeIdentity{identity})
+	require.NoError(t, err)
+	assert.Equal(t, testDEK, recovered)
+
+	// Objects stay readable after the recipients are removed
+	decrypted, err := plain.DecryptDEK(context.Background(), encryptedDEK, plain.GetActiveFingerprint(), "test-object-key")
+	require.NoError(t, err)
+	assert.Equal(t, testDEK, decrypted)
+
+	// Envelope encryption gets the recovery copy as well
+	envelopeEncryptor, err := pm.CreateEnvelopeEncryptor(factory.ContentTypeWhole, "s3ep-")
+	require.NoError(t, err)
+	_, wrapped, _, err := envelopeEncryptor.EncryptDataStream(context.Background(), bufio.NewReader(strings.NewReader("data")), []byte("key"))
+	require.NoError(t, err)
+	_, err = keyencryption.RecoverDEK(wrapped, []*keyencryption.AgeIdentity{identity})
+	assert.NoError(t, err)
+
+	_, err = NewProviderManager(newConfig("age1invalid"))
+	assert.ErrorContains(t, err, "recovery_recipients")
+}
+
 func TestProviderManager_NoneProvider(t *testing.T) {
 
 	// Setup test configuration with none provider
@@ -370,14 +427,14 @@ func TestProviderManager_NoneProvider(t *testing.T) {
 	})
 
 	t.Run("none provider encrypt DEK returns as-is", func(t *testing.T) {
-		encryptedDEK, err := pm.EncryptDEK(testDEK, "test-object-key")
+		encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, encryptedDEK)
 	})
 
 	t.Run("none provider decrypt DEK returns as-is", func(t *testing.T) {
 		fingerprint := pm.GetActiveFingerprint()
-		decryptedDEK, err := pm.DecryptDEK(testDEK, fingerprint, "test-object-key")
+		decryptedDEK, err := pm.DecryptDEK(context.Background(), testDEK, fingerprint, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, decryptedDEK)
 	})
@@ -408,17 +465,17 @@ func TestProviderManager_Cache(t *testing.T) {
 	fingerprint := pm.GetActiveFingerprint()
 
 	// Encrypt DEK first
-	encryptedDEK, err := pm.EncryptDEK(testDEK, "test-object-key")
+	encryptedDEK, err := pm.EncryptDEK(context.Background(), testDEK, "test-object-key")
 	require.NoError(t, err)
 
 	t.Run("cache DEK after first decryption", func(t *testing.T) {
 		// First decryption should cache the result
-		decryptedDEK1, err := pm.DecryptDEK(encryptedDEK, fingerprint, "test-object-key")
+		decryptedDEK1, err := pm.DecryptDEK(context.Background(), encryptedDEK, fingerprint, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, decryptedDEK1)
 
 		// Second decryption should use cache (should be fast and identical)
-		decryptedDEK2, err := pm.DecryptDEK(encryptedDEK, fingerprint, "test-object-key")
+		decryptedDEK2, err := pm.DecryptDEK(context.Background(), encryptedDEK, fingerprint, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, decryptedDEK2)
 		assert.Equal(t, decryptedDEK1, decryptedDEK2)
@@ -428,7 +485,7 @@ func TestProviderManager_Cache(t *testing.T) {
 		pm.ClearCache()
 
 		// After clearing cache, decryption should still work
-		decryptedDEK, err := pm.DecryptDEK(encryptedDEK, fingerprint, "test-object-key")
+		decryptedDEK, err := pm.DecryptDEK(context.Background(), encryptedDEK, fingerprint, "test-object-key")
 		assert.NoError(t, err)
 		assert.Equal(t, testDEK, decryptedDEK)
 	})
@@ -445,68 +502,33 @@ func TestProviderManager_Cache(t *testing.T) {
 		secondDEK := []byte("second-dek-32-bytes-bbbbbbbbbbbb") // 32 B
 		require.Len(t, secondDEK, 32)
 
-		firstEncrypted, err := pm.EncryptDEK(firstDEK, objectKey)
+		firstEncrypted, err := pm.EncryptDEK(context.Background(), firstDEK, objectKey)
 		require.NoError(t, err)
-		secondEncrypted, err := pm.EncryptDEK(secondDEK, objectKey)
+		secondEncrypted, err := pm.EncryptDEK(context.Background(), secondDEK, objectKey)
 		require.NoError(t, err)
 		require.NotEqual(t, firstEncrypted, secondEncrypted,
 			"AES KEK encryption must produce distinct blobs for distinct DEKs")
 
 		// Warm the cache with the first upload's DEK.
-		got1, err := pm.DecryptDEK(firstEncrypted, fingerprint, objectKey)
+		got1, err := pm.DecryptDEK(context.Background(), firstEncrypted, fingerprint, objectKey)
 		require.NoError(t, err)
 		assert.Equal(t, firstDEK, got1)
 
 		// Decrypt the second (re-uploaded) DEK under the same object key.
 		// Pre-fix this returned firstDEK from the cache.
-		got2, err := pm.DecryptDEK(secondEncrypted, fingerprint, objectKey)
+		got2, err := pm.DecryptDEK(context.Background(), secondEncrypted, fingerprint, objectKey)
 		require.NoError(t, err)
 		assert.Equal(t, secondDEK, got2,
 			"second decrypt must return the new DEK, not a stale cache hit")
 
 		// The first encryptedDEK still resolves to the first DEK (its cache
 		// entry was preserved, since the keys differ).
-		got1Again, err := pm.DecryptDEK(firstEncrypted, fingerprint, objectKey)
+		got1Again, err := pm.DecryptDEK(context.Background(), firstEncrypted, fingerprint, objectKey)
 		require.NoError(t, err)
 		assert.Equal(t, firstDEK, got1Again)
 	})
 }
 
-// TestProviderManager_CacheLRUEviction verifies that the bounded LRU evicts
-// the least-recently-used entry once the cache exceeds dekCacheCapacity, so
-// long-running proxies cannot grow the DEK cache without bound.
-func TestProviderManager_CacheLRUEviction(t *testing.T) {
-	pm := &ProviderManager{
-		keyCacheItems: make(map[string]*list.Element),
-		keyCacheOrder: list.New(),
-		logger:        logrus.WithField("component", "test"),
-	}
-
-	// Fill exactly to capacity.
-	for i := 0; i < dekCacheCapacity; i++ {
-		pm.cachePut(fmt.Sprintf("key-%d", i), []byte{byte(i)})
-	}
-	require.Equal(t, dekCacheCapacity, pm.keyCacheOrder.Len())
-
-	// Touch the oldest entry so it becomes MRU.
-	_, ok := pm.cacheGet("key-0")
-	require.True(t, ok)
-
-	// Insert one more entry — this should evict the now-oldest, which is key-1.
-	pm.cachePut("key-new", []byte{0xff})
-	require.Equal(t, dekCacheCapacity, pm.keyCacheOrder.Len())
-
-	if _, ok := pm.cacheGet("key-1"); ok {
-		t.Fatal("expected key-1 to be evicted (LRU)")
-	}
-	if _, ok := pm.cacheGet("key-0"); !ok {
-		t.Fatal("expected key-0 to survive eviction (recently touched)")
-	}
-	if _, ok := pm.cacheGet("key-new"); !ok {
-		t.Fatal("expected freshly inserted key-new to be cached")
-	}
-}
-
 func TestProviderManager_GetProviderInfo(t *testing.T) {
 
 	// Setup test configuration with multiple providers
@@ -578,7 +600,11 @@ func TestProviderManager_GetProviderInfo(t *testing.T) {
 	t.Run("get provider by invalid fingerprint", func(t *testing.T) {
 		_, err := pm.GetProviderByFingerprint("invalid-fingerprint")
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "no provider found with fingerprint")
+		assert.ErrorIs(t, err, ErrKeyUnavailable)
+
+		var keyErr *KeyUnavailableError
+		require.ErrorAs(t, err, &keyErr)
+		assert.Equal(t, "invalid-fingerprint", keyErr.Fingerprint)
 	})
 }
 
@@ -629,3 +655,56 @@ func TestProviderManager_ValidateConfiguration(t *testing.T) {
 		assert.Contains(t, err.Error(), "no providers registered")
 	})
 }
+
+func TestManager_AddProviders(t *testing.T) {
+	const (
+		currentKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+		addedKey   = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+	)
+	newManager := func(alias, key string) *Manager {
+		manager, err := NewManager(&config.Config{
+			Encryption: config.EncryptionConfig{
+				EncryptionMethodAlias: alias,
+				Providers:             []config.EncryptionProvider{{Alias: alias, Type: "aes", Config: map[string]interface{}{"aes_key": key}}},
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+		return manager
+	}
+	manager := newManager("current", currentKey)
+	other := newManager("added", addedKey)
+
+	// An object written by a proxy that already has the new provider
+	ctx := context.Background()
+	encrypted, err := other.EncryptDataWithHTTPContentType(ctx, bufio.NewReader(strings.NewReader("written elsewhere")), "key", "text/plain", false)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(encrypted.EncryptedDataReader)
+	require.NoError(t, err)
+	decrypt := func() error {
+		reader, err := manager.DecryptData(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), encrypted.Metadata, "key")
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(reader)
+		return err
+	}
+	require.Error(t, decrypt())
+
+	added := config.EncryptionProvider{Alias: "added", Type: "aes", Config: map[string]interface{}{"aes_key": addedKey}}
+	invalid := config.EncryptionProvider{Alias: "invalid", Type: "aes", Config: map[string]interface{}{"aes_key": "short"}}
+	require.Error(t, manager.AddProviders([]config.EncryptionProvider{added, invalid}))
+	assert.Equal(t, []string{"current"}, manager.GetProviderAliases(), "a failed reload adds no provider")
+
+	require.NoError(t, manager.AddProviders([]config.EncryptionProvider{added}))
+	assert.Equal(t, []string{"current", "added"}, manager.GetProviderAliases())
+	assert.Equal(t, "current", manager.GetActiveProviderAlias())
+	require.NoError(t, decrypt())
+
+	err = manager.AddProviders([]config.EncryptionProvider{added})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already registered")
+	err = manager.AddProviders([]config.EncryptionProvider{{Alias: "copy", Type: "aes", Config: map[string]interface{}{"aes_key": currentKey}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "same key")
+}
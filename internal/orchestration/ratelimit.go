@@ -0,0 +1,281 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+const (
+	defaultRateLimitMaxQueue     = 1000
+	defaultRateLimitQueueTimeout = 5 * time.Second
+)
+
+// TokenBucket is a reservation based token bucket: a caller takes its tokens
+// even when none are left, running the bucket into debt, and waits until the
+// bucket has refilled up to them, so waiting callers are served in arrival
+// order. Callers pass the current time, which lets tests control the clock.
+// It limits the calls to key providers and, in the rate limiting middleware,
+// the requests and body bytes of clients.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64 // negative while callers wait for reserved tokens
+	last   time.Time
+}
+
+// NewTokenBucket creates a full bucket refilling at rate tokens per second up
+// to burst tokens
+func NewTokenBucket(rate, burst float64, now time.Time) *TokenBucket {
+	return &TokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// Burst returns the size of the bucket
+func (b *TokenBucket) Burst() float64 {
+	return b.burst
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// Take takes a token if one is available, or returns how long it takes until
+// one is
+func (b *TokenBucket) Take(now time.Time) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return b.duration(1 - b.tokens), false
+}
+
+// Reserve takes n tokens and returns how long the caller has to wait for them
+func (b *TokenBucket) Reserve(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return b.duration(-b.tokens)
+}
+
+// ReserveWithin takes a token and returns how long the caller has to wait
+// for it. If that is longer than maxWait, no token is taken and ok is false.
+func (b *TokenBucket) ReserveWithin(maxWait time.Duration, now time.Time) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens < 1 {
+		if wait = b.duration(1 - b.tokens); wait > maxWait {
+			return 0, false
+		}
+	}
+	b.tokens--
+	return wait, true
+}
+
+// Cancel returns n reserved tokens that were not used
+func (b *TokenBucket) Cancel(n int) {
+	b.mu.Lock()
+	b.tokens = math.Min(b.burst, b.tokens+float64(n))
+	b.mu.Unlock()
+}
+
+// Backlog returns how long the debt exceeds one burst, i.e. more than a
+// single caller is waiting for tokens
+func (b *TokenBucket) Backlog(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= -b.burst {
+		return 0
+	}
+	return b.duration(-b.burst - b.tokens)
+}
+
+// Chunk returns how many of n tokens may be reserved at once without running
+// more than one burst into debt
+func (b *TokenBucket) Chunk(n int) int {
+	if float64(n) > b.burst {
+		return max(1, int(b.burst))
+	}
+	return n
+}
+
+func (b *TokenBucket) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
+
+// decryptCall is an in-flight DEK unwrap that concurrent identical unwraps wait for
+type decryptCall struct {
+	done chan struct{}
+	dek  []byte // private copy handed out to the waiters
+	err  error
+}
+
+// rateLimitedEncryptor limits the wrap and unwrap calls of a provider to its
+// configured rate. Unwraps of the same encrypted DEK that arrive while one is
+// in flight wait for its result instead of calling the provider again, which
+// absorbs read bursts on a single object.
+type rateLimitedEncryptor struct {
+	encryption.KeyEncryptor
+	alias        string
+	bucket       *TokenBucket
+	maxQueue     int64
+	queueTimeout time.Duration
+	queued       atomic.Int64
+
+	mu       sync.Mutex
+	inflight map[string]*decryptCall
+}
+
+// rateLimitedPreloader keeps encryption.KeyPreloader visible through the
+// wrapper. Preloads are rare and not limited.
+type rateLimitedPreloader struct {
+	*rateLimitedEncryptor
+	preloader encryption.KeyPreloader
+}
+
+func (p *rateLimitedPreloader) Preload(ctx context.Context) error {
+	return p.preloader.Preload(ctx)
+}
+
+// withRateLimit wraps keyEncryptor with the rate limit of provider, or
+// returns it unchanged if the provider has none
+func withRateLimit(keyEncryptor encryption.KeyEncryptor, provider config.EncryptionProvider) encryption.KeyEncryptor {
+	if provider.RateLimit <= 0 {
+		return keyEncryptor
+	}
+
+	burst := provider.RateBurst
+	if burst <= 0 {
+		burst = int(math.Ceil(provider.RateLimit))
+	}
+	maxQueue := provider.MaxQueue
+	if maxQueue <= 0 {
+		maxQueue = defaultRateLimitMaxQueue
+	}
+	queueTimeout := defaultRateLimitQueueTimeout
+	if provider.QueueTimeout > 0 {
+		queueTimeout = time.Duration(provider.QueueTimeout) * time.Second
+	}
+
+	limited := &rateLimitedEncryptor{
+		KeyEncryptor: keyEncryptor,
+		alias:        provider.Alias,
+		bucket:       NewTokenBucket(provider.RateLimit, float64(burst), time.Now()),
+		maxQueue:     int64(maxQueue),
+		queueTimeout: queueTimeout,
+		inflight:     make(map[string]*decryptCall),
+	}
+	if preloader, ok := keyEncryptor.(encryption.KeyPreloader); ok {
+		return &rateLimitedPreloader{rateLimitedEncryptor: limited, preloader: preloader}
+	}
+	return limited
+}
+
+// EncryptDEK wraps the DEK once the rate limit allows it
+func (r *rateLimitedEncryptor) EncryptDEK(ctx context.Context, dek []byte) ([]byte, string, error) {
+	if err := r.wait(ctx, "encrypt"); err != nil {
+		return nil, "", err
+	}
+	return r.KeyEncryptor.EncryptDEK(ctx, dek)
+}
+
+// DecryptDEK unwraps the DEK once the rate limit allows it, or shares the
+// result of an identical unwrap that is already in flight
+func (r *rateLimitedEncryptor) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	key := keyID + "\x00" + string(encryptedDEK)
+
+	r.mu.Lock()
+	if call, ok := r.inflight[key]; ok {
+		r.mu.Unlock()
+		monitoring.RecordProviderCoalescedRequest(r.alias)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		// Callers may zero their DEK when done, so each gets its own copy
+		return append([]byte(nil), call.dek...), nil
+	}
+	call := &decryptCall{done: make(chan struct{})}
+	r.inflight[key] = call
+	r.mu.Unlock()
+
+	dek, err := r.decrypt(ctx, encryptedDEK, keyID)
+	if err == nil {
+		call.dek = append([]byte(nil), dek...)
+	}
+	call.err = err
+
+	r.mu.Lock()
+	delete(r.inflight, key)
+	r.mu.Unlock()
+	close(call.done)
+
+	return dek, err
+}
+
+func (r *rateLimitedEncryptor) decrypt(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	if err := r.wait(ctx, "decrypt"); err != nil {
+		return nil, err
+	}
+	return r.KeyEncryptor.DecryptDEK(ctx, encryptedDEK, keyID)
+}
+
+// wait blocks until the rate limit admits one operation. It fails with
+// ErrKeyRateLimited if the queue is full or the operation would wait longer
+// than the queue timeout.
+func (r *rateLimitedEncryptor) wait(ctx context.Context, operation string) error {
+	delay, ok := r.bucket.ReserveWithin(r.queueTimeout, time.Now())
+	if !ok {
+		monitoring.RecordProviderRateLimitRejected(r.alias, operation)
+		return fmt.Errorf("%w: provider '%s' would wait longer than %s", ErrKeyRateLimited, r.alias, r.queueTimeout)
+	}
+	if delay == 0 {
+		monitoring.RecordProviderRateLimitWait(r.alias, operation, 0)
+		return nil
+	}
+
+	if queued := r.queued.Add(1); queued > r.maxQueue {
+		r.queued.Add(-1)
+		r.bucket.Cancel(1)
+		monitoring.RecordProviderRateLimitRejected(r.alias, operation)
+		return fmt.Errorf("%w: provider '%s' has %d operations queued", ErrKeyRateLimited, r.alias, r.maxQueue)
+	}
+	monitoring.SetProviderRateLimitQueued(r.alias, r.queued.Load())
+	defer func() {
+		monitoring.SetProviderRateLimitQueued(r.alias, r.queued.Add(-1))
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		monitoring.RecordProviderRateLimitWait(r.alias, operation, delay)
+		return nil
+	case <-ctx.Done():
+		r.bucket.Cancel(1)
+		return ctx.Err()
+	}
+}
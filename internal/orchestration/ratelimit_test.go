@@ -0,0 +1,179 @@
+package orchestration
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// countingKeyEncryptor counts provider calls; unwraps block until release is
+// closed so that concurrent calls overlap
+type countingKeyEncryptor struct {
+	encryption.KeyEncryptor
+	encrypts atomic.Int64
+	decrypts atomic.Int64
+	release  chan struct{}
+}
+
+func (c *countingKeyEncryptor) EncryptDEK(_ context.Context, dek []byte) ([]byte, string, error) {
+	c.encrypts.Add(1)
+	return append([]byte("wrapped:"), dek...), "fp", nil
+}
+
+func (c *countingKeyEncryptor) DecryptDEK(_ context.Context, encryptedDEK []byte, _ string) ([]byte, error) {
+	c.decrypts.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	return append([]byte(nil), encryptedDEK[len("wrapped:"):]...), nil
+}
+
+func (c *countingKeyEncryptor) Fingerprint() string { return "fp" }
+
+func TestTokenBucketReserveWithin(t *testing.T) {
+	now := time.Unix(0, 0)
+	bucket := NewTokenBucket(10, 2, now)
+
+	// The burst is available at once
+	for i := 0; i < 2; i++ {
+		wait, ok := bucket.ReserveWithin(time.Second, now)
+		require.True(t, ok)
+		assert.Zero(t, wait)
+	}
+
+	// Further callers queue behind each other at 100ms per token
+	wait, ok := bucket.ReserveWithin(time.Second, now)
+	require.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, wait)
+	wait, ok = bucket.ReserveWithin(time.Second, now)
+	require.True(t, ok)
+	assert.Equal(t, 200*time.Millisecond, wait)
+
+	// A caller that would wait too long takes nothing
+	_, ok = bucket.ReserveWithin(250*time.Millisecond, now)
+	assert.False(t, ok)
+	wait, ok = bucket.ReserveWithin(time.Second, now)
+	require.True(t, ok)
+	assert.Equal(t, 300*time.Millisecond, wait)
+
+	// The bucket refills up to the burst only
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		wait, ok = bucket.ReserveWithin(0, now)
+		require.True(t, ok)
+		assert.Zero(t, wait)
+	}
+	_, ok = bucket.ReserveWithin(0, now)
+	assert.False(t, ok)
+}
+
+func TestWithRateLimit(t *testing.T) {
+	inner := &countingKeyEncryptor{}
+	assert.Same(t, encryption.KeyEncryptor(inner), withRateLimit(inner, config.EncryptionProvider{Alias: "p"}))
+
+	limited := withRateLimit(inner, config.EncryptionProvider{Alias: "p", RateLimit: 2.5})
+	rl, ok := limited.(*rateLimitedEncryptor)
+	require.True(t, ok)
+	assert.Equal(t, "fp", rl.Fingerprint())
+	assert.Equal(t, float64(3), rl.bucket.Burst())
+	assert.Equal(t, int64(defaultRateLimitMaxQueue), rl.maxQueue)
+	assert.Equal(t, defaultRateLimitQueueTimeout, rl.queueTimeout)
+
+	// Preloaders stay preloaders
+	preloader := &preloadingKeyEncryptor{KeyEncryptor: inner}
+	limited = withRateLimit(preloader, config.EncryptionProvider{Alias: "p", RateLimit: 1})
+	loader, ok := limited.(encryption.KeyPreloader)
+	require.True(t, ok)
+	require.NoError(t, loader.Preload(context.Background()))
+	assert.Equal(t, 1, preloader.calls)
+}
+
+func TestRateLimitedEncryptor_RejectsAfterQueueTimeout(t *testing.T) {
+	inner := &countingKeyEncryptor{}
+	limited := withRateLimit(inner, config.EncryptionProvider{Alias: "p", RateLimit: 1, RateBurst: 1, QueueTimeout: 1})
+
+	_, _, err := limited.EncryptDEK(context.Background(), []byte("dek"))
+	require.NoError(t, err)
+
+	// The second token is due after one second, the third after two
+	rl := limited.(*rateLimitedEncryptor)
+	_, ok := rl.bucket.ReserveWithin(rl.queueTimeout, time.Now())
+	require.True(t, ok)
+
+	_, _, err = limited.EncryptDEK(context.Background(), []byte("dek"))
+	assert.ErrorIs(t, err, ErrKeyRateLimited)
+	assert.Equal(t, int64(1), inner.encrypts.Load())
+}
+
+func TestRateLimitedEncryptor_RejectsWhenQueueFull(t *testing.T) {
+	inner := &countingKeyEncryptor{}
+	limited := withRateLimit(inner, config.EncryptionProvider{Alias: "p", RateLimit: 1, RateBurst: 1, MaxQueue: 1, QueueTimeout: 10})
+	rl := limited.(*rateLimitedEncryptor)
+
+	_, _, err := limited.EncryptDEK(context.Background(), []byte("dek"))
+	require.NoError(t, err)
+
+	// One caller waits in the queue
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan error, 1)
+	go func() {
+		_, _, err := limited.EncryptDEK(ctx, []byte("dek"))
+		waiting <- err
+	}()
+	require.Eventually(t, func() bool { return rl.queued.Load() == 1 }, time.Second, time.Millisecond)
+
+	_, _, err = limited.EncryptDEK(context.Background(), []byte("dek"))
+	assert.ErrorIs(t, err, ErrKeyRateLimited)
+
+	cancel()
+	assert.ErrorIs(t, <-waiting, context.Canceled)
+	assert.Equal(t, int64(0), rl.queued.Load())
+	assert.Equal(t, int64(1), inner.encrypts.Load())
+}
+
+func TestRateLimitedEncryptor_CoalescesDecrypts(t *testing.T) {
+	inner := &countingKeyEncryptor{release: make(chan struct{})}
+	limited := withRateLimit(inner, config.EncryptionProvider{Alias: "p", RateLimit: 1000})
+	rl := limited.(*rateLimitedEncryptor)
+	encryptedDEK := []byte("wrapped:0123456789abcdef")
+
+	const callers = 8
+	results := make([][]byte, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dek, err := limited.DecryptDEK(context.Background(), encryptedDEK, "fp")
+			assert.NoError(t, err)
+			results[i] = dek
+		}()
+	}
+
+	// Wait for the first call to reach the provider and the others to attach
+	require.Eventually(t, func() bool { return inner.decrypts.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	assert.LessOrEqual(t, inner.decrypts.Load(), int64(2))
+	for _, dek := range results {
+		assert.Equal(t, []byte("0123456789abcdef"), dek)
+	}
+
+	// Every caller owns its DEK
+	results[0][0] = 0
+	assert.Equal(t, byte('0'), results[1][0])
+
+	rl.mu.Lock()
+	assert.Empty(t, rl.inflight)
+	rl.mu.Unlock()
+}
@@ -0,0 +1,130 @@
+package orchestration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/compression"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/streaming"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
+)
+
+// RecoverObject decrypts an object with the recovery copy of its DEK
+// (encryption.recovery_recipients) and the matching offline age identities,
+// without the provider that wrapped the DEK. metadata is the object metadata
+// as stored on the backend, metadataPrefix the prefix it was written with.
+//
+// The data is authenticated like on GET: by the tag of AES-GCM objects and
+// by the HMAC of AES-CTR objects that have one. The returned reader fails
+// before the end of the data if they do not match. Objects that were
+// compressed before encryption are decompressed.
+func RecoverObject(ctx context.Context, ciphertext io.Reader, metadata map[string]string, objectKey, metadataPrefix string, identities []*keyencryption.AgeIdentity) (io.Reader, error) {
+	plaintext, err := recoverData(ctx, ciphertext, metadata, objectKey, metadataPrefix, identities)
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := metadata[metadataPrefix+compression.MetadataKey]
+	if !ok {
+		return plaintext, nil
+	}
+	return compression.NewReader(codec, io.NopCloser(plaintext))
+}
+
+// recoverData decrypts and authenticates the data of RecoverObject
+func recoverData(ctx context.Context, ciphertext io.Reader, metadata map[string]string, objectKey, metadataPrefix string, identities []*keyencryption.AgeIdentity) (io.Reader, error) {
+	cfg := &config.Config{Encryption: config.EncryptionConfig{IntegrityVerification: config.HMACVerificationStrict}}
+	mm := NewMetadataManager(cfg, metadataPrefix)
+	if _, err := mm.GetFormatVersion(metadata); err != nil {
+		return nil, err
+	}
+
+	encryptedDEK, err := mm.GetEncryptedDEK(metadata)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := keyencryption.RecoverDEK(encryptedDEK, identities)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := mm.GetAlgorithm(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case "aes-gcm", "aes-gcm-siv", "chacha20-poly1305":
+		aad := associatedData(objectKey, mm.GetEncryptionContext(metadata))
+		decryptor, err := dataencryption.NewDataEncryptor(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		return decryptor.DecryptStream(ctx, bufio.NewReader(ciphertext), dek, nil, aad)
+	case "aes-ctr", "xchacha20":
+		iv, err := mm.GetIV(metadata)
+		if err != nil {
+			return nil, err
+		}
+		decryptor, err := streamDecryptor(mm, dek, iv, metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s decryptor: %w", algorithm, err)
+		}
+
+		var verifier *streaming.Verifier
+		if expectedHMAC, err := mm.GetHMAC(metadata); err == nil && len(expectedHMAC) > 0 {
+			hm := validation.NewHMACManager(cfg)
+			calculator, err := verificationCalculator(hm, mm, dek, metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
+			}
+			verifier = &streaming.Verifier{Calculator: calculator, Manager: hm, Expected: expectedHMAC, ObjectKey: objectKey}
+		} else if mm.GetEncryptionContext(metadata) != "" {
+			return nil, fmt.Errorf("%w: object with an encryption context has no HMAC", ErrIntegrityFailure)
+		}
+		return streaming.NewDecryptReaderSize(ciphertext, decryptor, verifier, 0), nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+// DecryptObject decrypts an object read directly from the backend with the
+// configured providers, for tools that run while the proxy is unavailable.
+// The data is authenticated like on GET and compressed objects are
+// decompressed. Closing the returned reader releases ciphertext if it is an
+// io.Closer.
+func (m *Manager) DecryptObject(ctx context.Context, ciphertext io.Reader, metadata map[string]string, objectKey string) (io.ReadCloser, error) {
+	if _, err := m.metadataManager.GetEncryptedDEK(metadata); err != nil {
+		return nil, errors.New("object metadata holds no encrypted DEK")
+	}
+	plaintext, err := m.DecryptDataWithMetadata(ctx, ciphertext, metadata, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := metadata[m.metadataManager.GetMetadataPrefix()+compression.MetadataKey]
+	if !ok {
+		return plaintext, nil
+	}
+	return compression.NewReader(codec, plaintext)
+}
+
+// ParseObjectMetadata accepts the output of "aws s3api head-object" or a
+// plain JSON object of metadata keys and values
+func ParseObjectMetadata(data []byte) (map[string]string, error) {
+	var head struct {
+		Metadata map[string]string `json:"Metadata"`
+	}
+	if err := json.Unmarshal(data, &head); err == nil && head.Metadata != nil {
+		return head.Metadata, nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("metadata is neither head-object output nor a JSON object of strings: %w", err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,160 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/compression"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/keyencryption"
+)
+
+func TestRecoverObject(t *testing.T) {
+	identity, err := keyencryption.GenerateAgeIdentity()
+	require.NoError(t, err)
+
+	// The proxy that wrote the objects; its AES key is lost afterwards
+	manager, err := NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "lost-aes",
+			IntegrityVerification: config.HMACVerificationStrict,
+			RecoveryRecipients:    []string{identity.Recipient().String()},
+			Providers: []config.EncryptionProvider{{
+				Alias:  "lost-aes",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+
+	ctx := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+	plaintext := bytes.Repeat([]byte("recoverable "), 1000)
+	identities := []*keyencryption.AgeIdentity{identity}
+
+	for _, contentType := range []factory.ContentType{factory.ContentTypeWhole, factory.ContentTypeMultipart} {
+		t.Run(string(contentType), func(t *testing.T) {
+			result, err := manager.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader(plaintext)), "tenant/object.bin", contentType)
+			require.NoError(t, err)
+			ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+			require.NoError(t, err)
+
+			reader, err := RecoverObject(context.Background(), bytes.NewReader(ciphertext), result.Metadata, "tenant/object.bin", "s3ep-", identities)
+			require.NoError(t, err)
+			recovered, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, recovered)
+
+			// Tampered data is not released
+			tampered := bytes.Clone(ciphertext)
+			tampered[len(tampered)/2] ^= 1
+			reader, err = RecoverObject(context.Background(), bytes.NewReader(tampered), result.Metadata, "tenant/object.bin", "s3ep-", identities)
+			if err == nil {
+				_, err = io.ReadAll(reader)
+			}
+			assert.Error(t, err)
+
+			stranger, err := keyencryption.GenerateAgeIdentity()
+			require.NoError(t, err)
+			_, err = RecoverObject(context.Background(), bytes.NewReader(ciphertext), result.Metadata, "tenant/object.bin", "s3ep-", []*keyencryption.AgeIdentity{stranger})
+			assert.ErrorIs(t, err, keyencryption.ErrNoAgeIdentity)
+		})
+	}
+
+	// Objects compressed before encryption are recovered decompressed
+	compressor, err := compression.New(compression.CodecGzip, 0, 0)
+	require.NoError(t, err)
+	compressed, ok, err := compressor.Compress(plaintext)
+	require.NoError(t, err)
+	require.True(t, ok)
+	result, err := manager.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader(compressed)), "tenant/object.bin", factory.ContentTypeWhole)
+	require.NoError(t, err)
+	result.Metadata["s3ep-compression"] = compression.CodecGzip
+	reader, err := RecoverObject(context.Background(), result.EncryptedDataReader, result.Metadata, "tenant/object.bin", "s3ep-", identities)
+	require.NoError(t, err)
+	recovered, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, recovered)
+}
+
+func TestManager_DecryptObject(t *testing.T) {
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias:   "aes",
+			IntegrityVerification:   config.HMACVerificationStrict,
+			StrictEncryptionContext: true,
+			Providers: []config.EncryptionProvider{{
+				Alias:  "aes",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+	}
+	writer, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = writer.Shutdown(context.Background()) })
+	// A fresh manager with the same configuration, as in an offline tool
+	offline, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = offline.Shutdown(context.Background()) })
+
+	ctx := WithEncryptionContext(context.Background(), EncryptionContext{"tenant": "acme"})
+	plaintext := bytes.Repeat([]byte("disaster recovery "), 1000)
+	compressor, err := compression.New(compression.CodecGzip, 0, 0)
+	require.NoError(t, err)
+	compressed, ok, err := compressor.Compress(plaintext)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for _, contentType := range []factory.ContentType{factory.ContentTypeWhole, factory.ContentTypeMultipart} {
+		t.Run(string(contentType), func(t *testing.T) {
+			result, err := writer.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader(compressed)), "tenant/object.bin", contentType)
+			require.NoError(t, err)
+			result.Metadata["s3ep-compression"] = compression.CodecGzip
+			ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+			require.NoError(t, err)
+
+			reader, err := offline.DecryptObject(ctx, bytes.NewReader(ciphertext), result.Metadata, "tenant/object.bin")
+			require.NoError(t, err)
+			decrypted, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, plaintext, decrypted)
+
+			_, err = offline.DecryptObject(context.Background(), bytes.NewReader(ciphertext), result.Metadata, "tenant/object.bin")
+			assert.ErrorIs(t, err, ErrEncryptionContextMismatch, "the encryption context has to be presented")
+
+			tampered := bytes.Clone(ciphertext)
+			tampered[len(tampered)/2] ^= 1
+			reader, err = offline.DecryptObject(ctx, bytes.NewReader(tampered), result.Metadata, "tenant/object.bin")
+			if err == nil {
+				_, err = io.ReadAll(reader)
+			}
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = offline.DecryptObject(ctx, bytes.NewReader(plaintext), map[string]string{"owner": "alice"}, "plain.txt")
+	assert.ErrorContains(t, err, "no encrypted DEK")
+}
+
+func TestParseObjectMetadata(t *testing.T) {
+	metadata, err := ParseObjectMetadata([]byte(`{"ContentLength": 3, "Metadata": {"s3ep-dek-algorithm": "aes-gcm"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"s3ep-dek-algorithm": "aes-gcm"}, metadata)
+
+	metadata, err = ParseObjectMetadata([]byte(`{"s3ep-dek-algorithm": "aes-ctr"}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"s3ep-dek-algorithm": "aes-ctr"}, metadata)
+
+	_, err = ParseObjectMetadata([]byte(`["not", "metadata"]`))
+	assert.Error(t, err)
+}
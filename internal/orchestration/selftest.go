@@ -0,0 +1,187 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+// selfTestProbeSize is the size of the in-memory payload encrypted per provider
+const selfTestProbeSize = 4 * 1024
+
+// selfTestAAD is the associated data bound to the AEAD probe
+var selfTestAAD = []byte("s3ep-self-test")
+
+// SelfTestResult is the outcome of the self-test for a single provider
+type SelfTestResult struct {
+	Alias       string
+	Type        string
+	Fingerprint string
+	IsActive    bool
+	Skipped     bool // true for the none provider, which has no key to test
+	Duration    time.Duration
+	Err         error
+}
+
+// SelfTestFailed reports whether any provider failed the self-test
+func SelfTestFailed(results []SelfTestResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SelfTest runs an encrypt/decrypt round-trip of an in-memory probe through the
+// envelope pipeline of every registered provider: DEK generation, DEK wrap and
+// unwrap by the provider, stream and AEAD data encryption with the data
+// cipher of the provider and the unwrapped DEK, and the HMAC derived from it.
+// Results are sorted by alias.
+func (m *Manager) SelfTest(ctx context.Context) []SelfTestResult {
+	providers := m.providerManager.GetAllProviders()
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Alias < providers[j].Alias })
+
+	results := make([]SelfTestResult, 0, len(providers))
+	for _, provider := range providers {
+		result := SelfTestResult{
+			Alias:       provider.Alias,
+			Type:        provider.Type,
+			Fingerprint: provider.Fingerprint,
+			IsActive:    provider.IsActive,
+		}
+
+		if provider.Type == "none" {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		start := time.Now()
+		result.Err = m.selfTestProvider(ctx, provider)
+		result.Duration = time.Since(start)
+
+		m.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"provider_alias": result.Alias,
+			"provider_type":  result.Type,
+			"duration":       result.Duration,
+			"success":        result.Err == nil,
+		}).Debug("Provider self-test finished")
+		results = append(results, result)
+	}
+	return results
+}
+
+// selfTestProvider runs the round-trip for a single provider
+func (m *Manager) selfTestProvider(ctx context.Context, provider ProviderInfo) error {
+	if provider.Encryptor == nil {
+		return fmt.Errorf("provider has no key encryptor")
+	}
+
+	probe := make([]byte, selfTestProbeSize)
+	if _, err := rand.Read(probe); err != nil {
+		return fmt.Errorf("failed to generate probe payload: %w", err)
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return fmt.Errorf("failed to generate DEK: %w", err)
+	}
+	defer m.hmacManager.ClearSensitiveData(dek)
+
+	// Key layer
+	wrapped, _, err := provider.Encryptor.EncryptDEK(ctx, dek)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt DEK: %w", err)
+	}
+	unwrapped, err := provider.Encryptor.DecryptDEK(ctx, wrapped, provider.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt DEK: %w", err)
+	}
+	defer m.hmacManager.ClearSensitiveData(unwrapped)
+	if !bytes.Equal(dek, unwrapped) {
+		return fmt.Errorf("decrypted DEK does not match the original")
+	}
+
+	// Integrity layer: the HMAC written at encryption time must verify with the unwrapped DEK
+	expectedHMAC, err := m.selfTestHMAC(dek, probe)
+	if err != nil {
+		return err
+	}
+
+	// Data layer, stream cipher of the provider (streaming and multipart objects)
+	streamAlgorithm := m.providerManager.DataAlgorithm(factory.ContentTypeMultipart, provider.Fingerprint)
+	encryptor, err := dataencryption.NewStatefulEncryptor(streamAlgorithm, dek)
+	if err != nil {
+		return fmt.Errorf("failed to create %s encryptor: %w", streamAlgorithm, err)
+	}
+	ciphertext, err := encryptor.EncryptPart(bytes.Clone(probe))
+	if err != nil {
+		return fmt.Errorf("%s encryption failed: %w", streamAlgorithm, err)
+	}
+	decryptor, err := dataencryption.NewStatefulEncryptorAt(streamAlgorithm, unwrapped, encryptor.GetIV(), 0)
+	if err != nil {
+		return fmt.Errorf("failed to create %s decryptor: %w", streamAlgorithm, err)
+	}
+	plaintext, err := decryptor.DecryptPart(ciphertext)
+	if err != nil {
+		return fmt.Errorf("%s decryption failed: %w", streamAlgorithm, err)
+	}
+	if !bytes.Equal(probe, plaintext) {
+		return fmt.Errorf("%s round-trip returned different data", streamAlgorithm)
+	}
+	actualHMAC, err := m.selfTestHMAC(unwrapped, plaintext)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expectedHMAC, actualHMAC) {
+		return fmt.Errorf("HMAC verification failed: %w", ErrIntegrityFailure)
+	}
+
+	// Data layer, AEAD of the provider (whole objects)
+	wholeAlgorithm := m.providerManager.DataAlgorithm(factory.ContentTypeWhole, provider.Fingerprint)
+	aead, err := dataencryption.NewDataEncryptor(wholeAlgorithm)
+	if err != nil {
+		return err
+	}
+	sealed, err := aead.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(probe)), dek, selfTestAAD)
+	if err != nil {
+		return fmt.Errorf("%s encryption failed: %w", wholeAlgorithm, err)
+	}
+	opened, err := aead.DecryptStream(ctx, sealed, unwrapped, nil, selfTestAAD)
+	if err != nil {
+		return fmt.Errorf("%s decryption failed: %w", wholeAlgorithm, err)
+	}
+	plaintext, err = io.ReadAll(opened)
+	if err != nil {
+		return fmt.Errorf("%s decryption failed: %w", wholeAlgorithm, err)
+	}
+	if !bytes.Equal(probe, plaintext) {
+		return fmt.Errorf("%s round-trip returned different data", wholeAlgorithm)
+	}
+
+	return nil
+}
+
+// selfTestHMAC computes the integrity HMAC of data for dek with the default
+// integrity algorithm
+func (m *Manager) selfTestHMAC(dek, data []byte) ([]byte, error) {
+	calculator, err := m.hmacManager.CreateCalculatorWithAlgorithm(dek, m.hmacManager.AlgorithmFor(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
+	}
+	if _, err := calculator.Add(data); err != nil {
+		return nil, fmt.Errorf("failed to calculate HMAC: %w", err)
+	}
+	return m.hmacManager.FinalizeCalculator(calculator), nil
+}
@@ -0,0 +1,115 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+func newSelfTestManager(t *testing.T) *Manager {
+	t.Helper()
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes",
+			IntegrityVerification: "off",
+			Providers: []config.EncryptionProvider{
+				{
+					Alias: "test-aes",
+					Type:  "aes",
+					Config: map[string]interface{}{
+						"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY=",
+					},
+				},
+				{
+					Alias:  "test-none",
+					Type:   "none",
+					Config: map[string]interface{}{},
+				},
+			},
+		},
+	}
+	manager, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+// brokenKeyEncryptor returns a different DEK than the one it wrapped
+type brokenKeyEncryptor struct {
+	encryption.KeyEncryptor
+	decryptErr error
+}
+
+func (b *brokenKeyEncryptor) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	if b.decryptErr != nil {
+		return nil, b.decryptErr
+	}
+	dek, err := b.KeyEncryptor.DecryptDEK(ctx, encryptedDEK, keyID)
+	if err != nil {
+		return nil, err
+	}
+	dek[0] ^= 0xff
+	return dek, nil
+}
+
+func TestSelfTest_AllProvidersPass(t *testing.T) {
+	manager := newSelfTestManager(t)
+
+	results := manager.SelfTest(context.Background())
+	require.Len(t, results, 2)
+	assert.False(t, SelfTestFailed(results))
+
+	assert.Equal(t, "test-aes", results[0].Alias)
+	assert.True(t, results[0].IsActive)
+	assert.False(t, results[0].Skipped)
+	assert.NoError(t, results[0].Err)
+	assert.Positive(t, results[0].Duration)
+
+	assert.Equal(t, "test-none", results[1].Alias)
+	assert.True(t, results[1].Skipped)
+	assert.NoError(t, results[1].Err)
+}
+
+func TestSelfTest_ReportsFailingProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		encryptor func(encryption.KeyEncryptor) encryption.KeyEncryptor
+		errorMsg  string
+	}{
+		{
+			name: "unwrap error",
+			encryptor: func(inner encryption.KeyEncryptor) encryption.KeyEncryptor {
+				return &brokenKeyEncryptor{KeyEncryptor: inner, decryptErr: errors.New("kms unavailable")}
+			},
+			errorMsg: "failed to decrypt DEK: kms unavailable",
+		},
+		{
+			name: "wrong DEK",
+			encryptor: func(inner encryption.KeyEncryptor) encryption.KeyEncryptor {
+				return &brokenKeyEncryptor{KeyEncryptor: inner}
+			},
+			errorMsg: "decrypted DEK does not match the original",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newSelfTestManager(t)
+			pm := manager.providerManager
+			info := pm.registeredProviders["test-aes"]
+			info.Encryptor = tt.encryptor(info.Encryptor)
+			pm.registeredProviders["test-aes"] = info
+
+			results := manager.SelfTest(context.Background())
+			assert.True(t, SelfTestFailed(results))
+			require.Error(t, results[0].Err)
+			assert.Contains(t, results[0].Err.Error(), tt.errorMsg)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package orchestration
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// ErrSessionConflict is returned by SessionStore.Update when the session was
+// changed since it was loaded, e.g. by another replica processing a part
+var ErrSessionConflict = errors.New("multipart session was modified concurrently")
+
+// SessionState is the persisted form of a multipart upload session: everything
+// another proxy process needs to continue the upload. Key material is never
+// stored in plaintext. The DEK is wrapped by the key encryption provider, and
+// the encryption progress with the running integrity state is sealed with a
+// key derived from the DEK. Part ETags are not stored, clients send them
+// again on completion.
+type SessionState struct {
+	UploadID       string            `json:"upload_id"`
+	ObjectKey      string            `json:"object_key"`
+	BucketName     string            `json:"bucket_name"`
+	ClientID       string            `json:"client_id,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	KeyFingerprint string            `json:"key_fingerprint"`
+	EncryptedDEK   []byte            `json:"encrypted_dek"`
+	IV             []byte            `json:"iv"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+
+	// Progress of the ordered part encryption
+	NextPartNumber     int    `json:"next_part_number"`              // Part to encrypt next, for listings (Progress is authoritative)
+	IntegrityAlgorithm string `json:"integrity_algorithm,omitempty"` // Empty if integrity verification was off
+	Progress           []byte `json:"progress"`                      // Sealed sessionProgress
+
+	// Version is assigned by the store on every write. Update only succeeds
+	// if the stored session still has this version.
+	Version int64 `json:"-"`
+}
+
+// clone returns a deep copy of s
+func (s *SessionState) clone() *SessionState {
+	c := *s
+	c.EncryptedDEK = append([]byte(nil), s.EncryptedDEK...)
+	c.IV = append([]byte(nil), s.IV...)
+	c.Progress = append([]byte(nil), s.Progress...)
+	c.Metadata = maps.Clone(s.Metadata)
+	return &c
+}
+
+// SessionStore persists multipart upload sessions, so uploads survive a
+// restart and can be continued by any replica sharing the store
+type SessionStore interface {
+	// Create stores a new session and sets its Version. It returns
+	// ErrDuplicateSession if the upload ID already has a session.
+	Create(ctx context.Context, state *SessionState) error
+
+	// Update replaces a session and sets its new Version. It returns
+	// ErrSessionConflict if the stored session does not have state.Version
+	// and ErrSessionNotFound if it no longer exists.
+	Update(ctx context.Context, state *SessionState) error
+
+	// Load returns the session of an upload ID or ErrSessionNotFound
+	Load(ctx context.Context, uploadID string) (*SessionState, error)
+
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, uploadID string) error
+
+	// List returns all stored sessions
+	List(ctx context.Context) ([]*SessionState, error)
+
+	// Close releases the connection to the store
+	Close() error
+}
+
+// NewSessionStore creates the session store selected by cfg.SessionStore
+func NewSessionStore(cfg *config.Config) (SessionStore, error) {
+	switch cfg.SessionStore.Type {
+	case "", config.SessionStoreMemory:
+		return NewMemorySessionStore(), nil
+	case config.SessionStoreRedis:
+		return NewRedisSessionStore(cfg.SessionStore)
+	case config.SessionStoreEtcd:
+		return NewEtcdSessionStore(cfg.SessionStore)
+	default:
+		return nil, fmt.Errorf("unknown session store type: %s", cfg.SessionStore.Type)
+	}
+}
+
+// MemorySessionStore keeps sessions in the memory of the process. Sessions
+// are lost on restart and not shared with other replicas.
+type MemorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string]*SessionState
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*SessionState)}
+}
+
+// Create stores a new session
+func (s *MemorySessionStore) Create(_ context.Context, state *SessionState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.sessions[state.UploadID]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateSession, state.UploadID)
+	}
+	state.Version = 1
+	s.sessions[state.UploadID] = state.clone()
+	return nil
+}
+
+// Update replaces a session if its version matches
+func (s *MemorySessionStore) Update(_ context.Context, state *SessionState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, exists := s.sessions[state.UploadID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, state.UploadID)
+	}
+	if stored.Version != state.Version {
+		return fmt.Errorf("%w: %s", ErrSessionConflict, state.UploadID)
+	}
+	state.Version++
+	s.sessions[state.UploadID] = state.clone()
+	return nil
+}
+
+// Load returns a copy of a session
+func (s *MemorySessionStore) Load(_ context.Context, uploadID string) (*SessionState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, exists := s.sessions[uploadID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, uploadID)
+	}
+	return stored.clone(), nil
+}
+
+// Delete removes a session
+func (s *MemorySessionStore) Delete(_ context.Context, uploadID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, uploadID)
+	return nil
+}
+
+// List returns copies of all sessions
+func (s *MemorySessionStore) List(_ context.Context) ([]*SessionState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	states := make([]*SessionState, 0, len(s.sessions))
+	for _, stored := range s.sessions {
+		states = append(states, stored.clone())
+	}
+	return states, nil
+}
+
+// Close does nothing for the in-memory store
+func (s *MemorySessionStore) Close() error {
+	return nil
+}
+
+// memorySessionFileVersion is the format version of saved memory sessions
+const memorySessionFileVersion = 1
+
+// memorySessionFile holds the sessions of a MemorySessionStore saved on
+// shutdown. Like in a shared store, DEKs are wrapped and progress is sealed.
+type memorySessionFile struct {
+	Version  int             `json:"version"`
+	Sessions []*SessionState `json:"sessions"`
+}
+
+// Save writes all sessions to path, replacing it atomically. It returns the
+// number of sessions written.
+func (s *MemorySessionStore) Save(path string) (int, error) {
+	s.mutex.Lock()
+	file := memorySessionFile{Version: memorySessionFileVersion, Sessions: make([]*SessionState, 0, len(s.sessions))}
+	for _, stored := range s.sessions {
+		file.Sessions = append(file.Sessions, stored.clone())
+	}
+	s.mutex.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode sessions: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return 0, err
+	}
+	return len(file.Sessions), nil
+}
+
+// Restore adds the sessions saved to path and removes the file, so sessions
+// are restored at most once. Sessions that exist already are kept. It
+// returns the number of sessions restored.
+func (s *MemorySessionStore) Restore(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("failed to remove restored sessions: %w", err)
+	}
+
+	var file memorySessionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("failed to decode sessions: %w", err)
+	}
+	if file.Version != memorySessionFileVersion {
+		return 0, fmt.Errorf("unsupported session file version %d", file.Version)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var restored int
+	for _, state := range file.Sessions {
+		if state == nil || state.UploadID == "" {
+			continue
+		}
+		if _, exists := s.sessions[state.UploadID]; exists {
+			continue
+		}
+		state.Version = 1
+		s.sessions[state.UploadID] = state
+		restored++
+	}
+	return restored, nil
+}
+
+// decodeSessionState decodes a JSON encoded session with its store version
+func decodeSessionState(data []byte, version int64) (*SessionState, error) {
+	var state SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	state.Version = version
+	return &state, nil
+}
+
+// sessionProgress is the sealed part of a SessionState. It is authenticated,
+// so the CTR offset and the MAC state cannot be altered in the store.
+type sessionProgress struct {
+	NextPartNumber int    `json:"next_part_number"`
+	BytesEncrypted uint64 `json:"bytes_encrypted"`           // Plaintext bytes encrypted so far, the CTR offset
+	IntegrityState []byte `json:"integrity_state,omitempty"` // Running MAC state, nil if it cannot be serialized
+
+	// Part whose keystream is reserved while it is streamed. NextPartNumber
+	// and BytesEncrypted already count it, IntegrityState does not.
+	StreamingPart int  `json:"streaming_part,omitempty"`
+	StreamFailed  bool `json:"stream_failed,omitempty"` // StreamingPart broke off and the upload cannot be completed
+
+	// Uploads whose parts are encrypted in parallel keep one record per part
+	// instead of a keystream position and a running MAC
+	Parallel bool                  `json:"parallel,omitempty"`
+	PartSize int64                 `json:"part_size,omitempty"` // Declared part size
+	Parts    map[int]*partProgress `json:"parts,omitempty"`
+}
+
+// partProgress is a part of an upload whose parts are encrypted in parallel.
+// A part is recorded before it is encrypted, so its keystream is never used
+// twice: an upload of a part that was encrypted before is a new attempt.
+type partProgress struct {
+	Size    int64  `json:"size"`
+	Attempt int    `json:"attempt,omitempty"` // Upload attempt, selects the IV of the part
+	MAC     []byte `json:"mac,omitempty"`     // MAC of the part plaintext once Done, nil without integrity
+	Done    bool   `json:"done,omitempty"`    // Encrypted completely
+	Failed  bool   `json:"failed,omitempty"`  // Broke off after part of its keystream was used
+}
+
+// sessionSealInfo is the HKDF info of the key the integrity state is sealed with
+const sessionSealInfo = "s3-encryption-proxy-multipart-session-state"
+
+// sessionSealAEAD returns AES-256-GCM keyed with a key derived from the DEK
+func sessionSealAEAD(dek []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dek, nil, []byte(sessionSealInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive session state key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSessionSecret encrypts secret session state bound to the upload ID
+func sealSessionSecret(dek []byte, uploadID string, plaintext []byte) ([]byte, error) {
+	aead, err := sessionSealAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(uploadID)), nil
+}
+
+// openSessionSecret decrypts state sealed by sealSessionSecret
+func openSessionSecret(dek []byte, uploadID string, sealed []byte) ([]byte, error) {
+	aead, err := sessionSealAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed session state is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(uploadID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session state: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,151 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// EtcdSessionStore keeps sessions in etcd, so every replica connected to the
+// same cluster sees them. Sessions are JSON values, and the mod revision of a
+// key is the version of its session.
+type EtcdSessionStore struct {
+	client  *clientv3.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// NewEtcdSessionStore connects to the etcd cluster of cfg.Etcd
+func NewEtcdSessionStore(cfg config.SessionStoreConfig) (*EtcdSessionStore, error) {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Etcd.Endpoints,
+		Username:    cfg.Etcd.Username,
+		Password:    cfg.Etcd.Password,
+		DialTimeout: timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd session store client: %w", err)
+	}
+
+	store := &EtcdSessionStore{client: client, prefix: cfg.KeyPrefix, timeout: timeout}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := client.Get(ctx, cfg.KeyPrefix, clientv3.WithPrefix(), clientv3.WithCountOnly()); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to etcd session store at %s: %w", strings.Join(cfg.Etcd.Endpoints, ","), err)
+	}
+	return store, nil
+}
+
+func (s *EtcdSessionStore) key(uploadID string) string {
+	return s.prefix + uploadID
+}
+
+// Create stores a new session
+func (s *EtcdSessionStore) Create(ctx context.Context, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	key := s.key(state.UploadID)
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to store session %s: %w", state.UploadID, err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %s", ErrDuplicateSession, state.UploadID)
+	}
+	state.Version = resp.Header.Revision
+	return nil
+}
+
+// Update replaces a session if its version matches
+func (s *EtcdSessionStore) Update(ctx context.Context, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	key := s.key(state.UploadID)
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", state.Version)).
+		Then(clientv3.OpPut(key, string(data))).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("failed to update session %s: %w", state.UploadID, err)
+	}
+	if !resp.Succeeded {
+		if len(resp.Responses) > 0 && resp.Responses[0].GetResponseRange().GetCount() == 0 {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, state.UploadID)
+		}
+		return fmt.Errorf("%w: %s", ErrSessionConflict, state.UploadID)
+	}
+	state.Version = resp.Header.Revision
+	return nil
+}
+
+// Load returns a session
+func (s *EtcdSessionStore) Load(ctx context.Context, uploadID string) (*SessionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, s.key(uploadID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", uploadID, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, uploadID)
+	}
+	return decodeSessionState(resp.Kvs[0].Value, resp.Kvs[0].ModRevision)
+}
+
+// Delete removes a session
+func (s *EtcdSessionStore) Delete(ctx context.Context, uploadID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.client.Delete(ctx, s.key(uploadID)); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", uploadID, err)
+	}
+	return nil
+}
+
+// List returns all sessions under the key prefix
+func (s *EtcdSessionStore) List(ctx context.Context) ([]*SessionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	states := make([]*SessionState, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		state, err := decodeSessionState(kv.Value, kv.ModRevision)
+		if err != nil {
+			return nil, fmt.Errorf("session %s: %w", strings.TrimPrefix(string(kv.Key), s.prefix), err)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Close closes the client
+func (s *EtcdSessionStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,186 @@
+package orchestration
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// Sessions are Redis hashes with a version counter and the JSON encoded state.
+// The scripts make the version check and the write one atomic step.
+var (
+	redisCreateSession = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', 1, 'state', ARGV[1])
+return 1`)
+
+	redisUpdateSession = redis.NewScript(`
+local version = redis.call('HGET', KEYS[1], 'version')
+if not version then
+	return -1
+end
+if version ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', tonumber(ARGV[1]) + 1, 'state', ARGV[2])
+return 1`)
+)
+
+// RedisSessionStore keeps sessions in Redis, so every replica connected to the
+// same server sees them
+type RedisSessionStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// NewRedisSessionStore connects to the Redis server of cfg.Redis
+func NewRedisSessionStore(cfg config.SessionStoreConfig) (*RedisSessionStore, error) {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	options := &redis.Options{
+		Addr:         cfg.Redis.Address,
+		Username:     cfg.Redis.Username,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	}
+	if cfg.Redis.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	store := &RedisSessionStore{client: redis.NewClient(options), prefix: cfg.KeyPrefix, timeout: timeout}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := store.client.Ping(ctx).Err(); err != nil {
+		_ = store.client.Close()
+		return nil, fmt.Errorf("failed to connect to redis session store at %s: %w", cfg.Redis.Address, err)
+	}
+	return store, nil
+}
+
+func (s *RedisSessionStore) key(uploadID string) string {
+	return s.prefix + uploadID
+}
+
+// Create stores a new session
+func (s *RedisSessionStore) Create(ctx context.Context, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	created, err := redisCreateSession.Run(ctx, s.client, []string{s.key(state.UploadID)}, data).Int()
+	if err != nil {
+		return fmt.Errorf("failed to store session %s: %w", state.UploadID, err)
+	}
+	if created == 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateSession, state.UploadID)
+	}
+	state.Version = 1
+	return nil
+}
+
+// Update replaces a session if its version matches
+func (s *RedisSessionStore) Update(ctx context.Context, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	result, err := redisUpdateSession.Run(ctx, s.client, []string{s.key(state.UploadID)}, state.Version, data).Int()
+	if err != nil {
+		return fmt.Errorf("failed to update session %s: %w", state.UploadID, err)
+	}
+	switch result {
+	case -1:
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, state.UploadID)
+	case 0:
+		return fmt.Errorf("%w: %s", ErrSessionConflict, state.UploadID)
+	}
+	state.Version++
+	return nil
+}
+
+// Load returns a session
+func (s *RedisSessionStore) Load(ctx context.Context, uploadID string) (*SessionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.load(ctx, s.key(uploadID), uploadID)
+}
+
+func (s *RedisSessionStore) load(ctx context.Context, key, uploadID string) (*SessionState, error) {
+	values, err := s.client.HMGet(ctx, key, "version", "state").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", uploadID, err)
+	}
+	version, ok1 := values[0].(string)
+	data, ok2 := values[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, uploadID)
+	}
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q of session %s: %w", version, uploadID, err)
+	}
+	return decodeSessionState([]byte(data), v)
+}
+
+// Delete removes a session
+func (s *RedisSessionStore) Delete(ctx context.Context, uploadID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.client.Del(ctx, s.key(uploadID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", uploadID, err)
+	}
+	return nil
+}
+
+// List returns all sessions under the key prefix
+func (s *RedisSessionStore) List(ctx context.Context) ([]*SessionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var states []*SessionState
+	iter := s.client.Scan(ctx, 0, redisGlobEscaper.Replace(s.prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		state, err := s.load(ctx, key, strings.TrimPrefix(key, s.prefix))
+		if errors.Is(err, ErrSessionNotFound) {
+			continue // deleted while listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return states, nil
+}
+
+// Close closes the connection pool
+func (s *RedisSessionStore) Close() error {
+	return s.client.Close()
+}
+
+// redisGlobEscaper escapes the glob characters of SCAN MATCH patterns
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
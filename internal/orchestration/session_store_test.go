@@ -0,0 +1,164 @@
+package orchestration
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func newTestRedisSessionStore(t *testing.T) (*RedisSessionStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisSessionStore(config.SessionStoreConfig{
+		KeyPrefix: "s3ep/multipart/",
+		Timeout:   5,
+		Redis:     config.RedisSessionStoreConfig{Address: server.Addr()},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store, server
+}
+
+func TestSessionStores_Contract(t *testing.T) {
+	stores := map[string]func(t *testing.T) SessionStore{
+		"memory": func(_ *testing.T) SessionStore { return NewMemorySessionStore() },
+		"redis": func(t *testing.T) SessionStore {
+			store, _ := newTestRedisSessionStore(t)
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+
+			state := &SessionState{
+				UploadID:       "upload-1",
+				ObjectKey:      "a/b",
+				BucketName:     "bucket",
+				CreatedAt:      time.Now().UTC().Truncate(time.Second),
+				EncryptedDEK:   []byte{1, 2, 3},
+				IV:             []byte{4, 5, 6},
+				Metadata:       map[string]string{"content-type": "text/plain"},
+				NextPartNumber: 1,
+			}
+			require.NoError(t, store.Create(ctx, state))
+			assert.ErrorIs(t, store.Create(ctx, state.clone()), ErrDuplicateSession)
+
+			loaded, err := store.Load(ctx, "upload-1")
+			require.NoError(t, err)
+			assert.Equal(t, state, loaded)
+
+			// The first update wins, the second one started from the same version
+			first, second := loaded.clone(), loaded.clone()
+			first.NextPartNumber = 2
+			require.NoError(t, store.Update(ctx, first))
+			assert.Greater(t, first.Version, state.Version)
+			second.NextPartNumber = 3
+			assert.ErrorIs(t, store.Update(ctx, second), ErrSessionConflict)
+
+			loaded, err = store.Load(ctx, "upload-1")
+			require.NoError(t, err)
+			assert.Equal(t, 2, loaded.NextPartNumber)
+			assert.Equal(t, first.Version, loaded.Version)
+
+			states, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, states, 1)
+			assert.Equal(t, "upload-1", states[0].UploadID)
+
+			require.NoError(t, store.Delete(ctx, "upload-1"))
+			require.NoError(t, store.Delete(ctx, "upload-1"))
+			_, err = store.Load(ctx, "upload-1")
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+			assert.ErrorIs(t, store.Update(ctx, loaded), ErrSessionNotFound)
+
+			states, err = store.List(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, states)
+		})
+	}
+}
+
+func TestMemorySessionStore_SaveRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions")
+
+	store := NewMemorySessionStore()
+	state := &SessionState{UploadID: "upload-1", ObjectKey: "a/b", EncryptedDEK: []byte{1, 2, 3}, Progress: []byte{4, 5}}
+	require.NoError(t, store.Create(ctx, state))
+	require.NoError(t, store.Update(ctx, state))
+
+	saved, err := store.Save(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, saved)
+
+	restored := NewMemorySessionStore()
+	require.NoError(t, restored.Create(ctx, &SessionState{UploadID: "upload-2"}))
+	count, err := restored.Restore(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	loaded, err := restored.Load(ctx, "upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), loaded.Version, "restored sessions start over with version 1")
+	loaded.Version = state.Version
+	assert.Equal(t, state, loaded)
+
+	// Sessions are restored at most once
+	_, err = restored.Restore(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRedisSessionStore_ListOnlyPrefix(t *testing.T) {
+	store, server := newTestRedisSessionStore(t)
+	require.NoError(t, server.Set("other-key", "value"))
+
+	require.NoError(t, store.Create(context.Background(), &SessionState{UploadID: "upload-1"}))
+	states, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "upload-1", states[0].UploadID)
+}
+
+func TestNewRedisSessionStore_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := NewRedisSessionStore(config.SessionStoreConfig{
+		Timeout: 1,
+		Redis:   config.RedisSessionStoreConfig{Address: addr},
+	})
+	assert.ErrorContains(t, err, "failed to connect to redis session store")
+}
+
+func TestSealSessionSecret(t *testing.T) {
+	dek := bytes.Repeat([]byte{7}, 32)
+	sealed, err := sealSessionSecret(dek, "upload-1", []byte("progress"))
+	require.NoError(t, err)
+
+	opened, err := openSessionSecret(dek, "upload-1", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("progress"), opened)
+
+	// Bound to the DEK and the upload ID, and authenticated
+	_, err = openSessionSecret(bytes.Repeat([]byte{8}, 32), "upload-1", sealed)
+	assert.Error(t, err)
+	_, err = openSessionSecret(dek, "upload-2", sealed)
+	assert.Error(t, err)
+	sealed[len(sealed)-1] ^= 1
+	_, err = openSessionSecret(dek, "upload-1", sealed)
+	assert.Error(t, err)
+	_, err = openSessionSecret(dek, "upload-1", sealed[:4])
+	assert.Error(t, err)
+}
@@ -10,32 +10,42 @@ import (
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/guided-traffic/s3-encryption-proxy/internal/streaming"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
 )
 
 // EncryptGCM encrypts data using AES-GCM with streaming (for small objects)
 func (m *Manager) EncryptGCM(ctx context.Context, dataReader *bufio.Reader, objectKey string) (*StreamingEncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key": objectKey,
 		"algorithm":  "aes-gcm",
 	}).Debug("Encrypting data stream with GCM")
 
+	fingerprint, err := m.providerManager.fingerprintFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create envelope encryptor for whole content (GCM)
-	provider, err := m.providerManager.CreateEnvelopeEncryptor(factory.ContentTypeWhole, m.metadataManager.GetMetadataPrefix())
+	provider, err := m.providerManager.CreateEnvelopeEncryptorWith(fingerprint, factory.ContentTypeWhole, m.metadataManager.GetMetadataPrefix())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create envelope encryptor: %w", err)
 	}
 
-	// Create associated data
-	associatedData := []byte(objectKey)
+	// Bind the object key and the client encryption context into the AAD
+	encryptionContext, _ := encryptionContextFrom(ctx)
 
 	// Use the provider to encrypt the stream
-	encryptedReader, _, metadata, err := provider.EncryptDataStream(ctx, dataReader, associatedData)
+	encryptedReader, _, metadata, err := provider.EncryptDataStream(ctx, dataReader, associatedData(objectKey, encryptionContext))
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt stream with GCM: %w", err)
 	}
+	m.bindEncryptionContext(ctx, metadata)
+	m.providerManager.recordKEKVersion(m.metadataManager, metadata)
 
 	// Extract the actual algorithm used from metadata
 	algorithm, err := m.metadataManager.GetAlgorithm(metadata)
@@ -57,14 +67,23 @@ func (m *Manager) EncryptGCM(ctx context.Context, dataReader *bufio.Reader, obje
 // threshold (5 MiB) here. Larger HMAC-enabled objects must go through the auto-multipart
 // path in the handler, which computes HMAC incrementally via MultipartOperations.ProcessPart.
 func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, objectKey string) (*StreamingEncryptionResult, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key": objectKey,
 		"algorithm":  "aes-ctr",
 	}).Debug("Encrypting data stream with CTR")
 
 	if !m.hmacManager.IsEnabled() {
+		if err := m.CheckEncryptionContextBinding(ctx); err != nil {
+			return nil, err
+		}
+
+		fingerprint, err := m.providerManager.fingerprintFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+
 		// HMAC disabled - stream end-to-end without buffering.
-		provider, err := m.providerManager.CreateEnvelopeEncryptor(factory.ContentTypeMultipart, m.metadataManager.GetMetadataPrefix())
+		provider, err := m.providerManager.CreateEnvelopeEncryptorWith(fingerprint, factory.ContentTypeMultipart, m.metadataManager.GetMetadataPrefix())
 		if err != nil {
 			return nil, fmt.Errorf("failed to create envelope encryptor: %w", err)
 		}
@@ -73,6 +92,8 @@ func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, obje
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt stream with CTR: %w", err)
 		}
+		m.bindEncryptionContext(ctx, metadata)
+		m.providerManager.recordKEKVersion(m.metadataManager, metadata)
 
 		algorithm, err := m.metadataManager.GetAlgorithm(metadata)
 		if err != nil {
@@ -91,6 +112,11 @@ func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, obje
 	// and return the ciphertext. Prior implementation walked the plaintext three times
 	// (ReadAll, HMAC AddFromStream, and encryption on caller Read) and did a redundant
 	// KEK DecryptDEK roundtrip just to recover the raw DEK for HMAC.
+	fingerprint, err := m.providerManager.fingerprintFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	dek := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
 		return nil, fmt.Errorf("failed to generate DEK: %w", err)
@@ -101,10 +127,12 @@ func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, obje
 		}
 	}()
 
-	hmacCalculator, err := m.hmacManager.CreateCalculator(dek)
+	encryptionContext, _ := encryptionContextFrom(ctx)
+	hmacCalculator, err := integrityCalculator(m.hmacManager, dek, m.hmacManager.AlgorithmForProvider(bucketFrom(ctx), m.providerManager.providerAliasFor(ctx)), encryptionContext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
 	}
+	hmacAlgorithm := hmacCalculator.Algorithm()
 
 	buffer, err := io.ReadAll(io.TeeReader(dataReader, hmacCalculator))
 	if err != nil {
@@ -113,19 +141,19 @@ func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, obje
 
 	hmacValue := m.hmacManager.FinalizeCalculator(hmacCalculator)
 
-	encryptor, err := m.createStreamingEncryptor(dek)
+	encryptor, err := m.createStreamingEncryptor(dek, fingerprint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CTR encryptor: %w", err)
 	}
-	if _, err := encryptor.EncryptPart(buffer); err != nil {
+	streamAlgorithm := encryptor.Algorithm()
+	if _, err := encryptor.EncryptPartParallel(buffer, m.parallelism); err != nil {
 		encryptor.Cleanup()
 		return nil, fmt.Errorf("failed to encrypt plaintext: %w", err)
 	}
 	iv := encryptor.GetIV()
 	encryptor.Cleanup()
 
-	fingerprint := m.providerManager.GetActiveFingerprint()
-	encryptedDEK, err := m.providerManager.EncryptDEK(dek, objectKey)
+	encryptedDEK, err := m.providerManager.EncryptDEKWith(ctx, fingerprint, dek, objectKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
 	}
@@ -134,31 +162,29 @@ func (m *Manager) EncryptCTR(ctx context.Context, dataReader *bufio.Reader, obje
 		dek,
 		encryptedDEK,
 		iv,
-		"aes-ctr",
+		streamAlgorithm,
 		fingerprint,
-		m.providerManager.GetActiveProviderAlgorithm(),
+		m.providerManager.ProviderAlgorithm(fingerprint),
 		nil,
 	)
 
 	if len(hmacValue) > 0 {
 		m.metadataManager.SetHMAC(metadata, hmacValue)
+		m.metadataManager.SetHMACAlgorithm(metadata, hmacAlgorithm)
 	}
-
-	algorithm, err := m.metadataManager.GetAlgorithm(metadata)
-	if err != nil {
-		algorithm = "aes-ctr"
-	}
+	m.bindEncryptionContext(ctx, metadata)
+	m.providerManager.recordKEKVersion(m.metadataManager, metadata)
 
 	return &StreamingEncryptionResult{
 		EncryptedDataReader: bytes.NewReader(buffer),
 		Metadata:            metadata,
-		Algorithm:           algorithm,
+		Algorithm:           streamAlgorithm,
 	}, nil
 }
 
 // DecryptGCMStream decrypts data using AES-GCM with streaming
 func (m *Manager) DecryptGCMStream(ctx context.Context, encryptedDataReader *bufio.Reader, metadata map[string]string, objectKey string) (*bufio.Reader, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key": objectKey,
 		"algorithm":  "aes-gcm",
 	}).Debug("Decrypting data stream with GCM")
@@ -168,7 +194,7 @@ func (m *Manager) DecryptGCMStream(ctx context.Context, encryptedDataReader *buf
 		// Peek at the first byte to check if data is available
 		_, err := encryptedDataReader.Peek(1)
 		if err == io.EOF {
-			m.logger.Error("Empty encrypted data for decryption")
+			m.logger.WithContext(ctx).Error("Empty encrypted data for decryption")
 			return nil, fmt.Errorf("encrypted data is empty")
 		}
 	}
@@ -176,40 +202,49 @@ func (m *Manager) DecryptGCMStream(ctx context.Context, encryptedDataReader *buf
 	// Get the required key encryptor fingerprint
 	fingerprint, err := m.metadataManager.GetFingerprint(metadata)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to get fingerprint from metadata")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to get fingerprint from metadata")
 		return nil, fmt.Errorf("failed to get fingerprint: %w", err)
 	}
 
 	// Get the encrypted DEK from metadata
 	encryptedDEK, err := m.metadataManager.GetEncryptedDEK(metadata)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to get encrypted DEK from metadata")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to get encrypted DEK from metadata")
 		return nil, fmt.Errorf("failed to get encrypted DEK: %w", err)
 	}
 
+	if err := m.providerManager.checkTenantAccess(ctx, fingerprint, objectKey); err != nil {
+		return nil, err
+	}
+
 	// Decrypt the DEK. The returned slice is owned by ProviderManager's DEK
 	// cache and must be treated as read-only.
-	dek, err := m.providerManager.DecryptDEK(encryptedDEK, fingerprint, objectKey)
+	dek, err := m.providerManager.DecryptDEK(ctx, encryptedDEK, fingerprint, objectKey)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to decrypt DEK")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to decrypt DEK")
 		return nil, fmt.Errorf("failed to decrypt DEK: %w", err)
 	}
 
 	// Create factory and get envelope encryptor
 	factoryInstance := m.providerManager.GetFactory()
 
-	// Use object key as associated data
-	associatedData := []byte(objectKey)
+	// The AAD binds the object key and the encryption context it was stored with
+	associatedData := associatedData(objectKey, m.metadataManager.GetEncryptionContext(metadata))
 
-	// For GCM, we need to use the envelope decryption
+	// For GCM, we need to use the envelope decryption, with the AEAD the
+	// object was written with (aes-gcm, aes-gcm-siv or chacha20-poly1305)
+	algorithm, err := m.metadataManager.GetAlgorithm(metadata)
+	if err != nil {
+		algorithm = "aes-gcm"
+	}
 	metadataPrefix := m.metadataManager.GetMetadataPrefix()
-	envelopeEncryptor, err := factoryInstance.CreateEnvelopeEncryptor(
-		factory.ContentTypeWhole,
+	envelopeEncryptor, err := factoryInstance.CreateEnvelopeEncryptorForAlgorithm(
+		algorithm,
 		fingerprint,
 		metadataPrefix,
 	)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to create GCM envelope encryptor for decryption")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to create GCM envelope encryptor for decryption")
 		return nil, fmt.Errorf("failed to create GCM envelope encryptor: %w", err)
 	}
 
@@ -221,47 +256,46 @@ func (m *Manager) DecryptGCMStream(ctx context.Context, encryptedDataReader *buf
 	// Decrypt data using streaming interface
 	decryptedReader, err := envelopeEncryptor.DecryptDataStream(ctx, encryptedDataReader, encryptedDEK, iv, associatedData)
 	if err != nil {
-		m.logger.WithError(err).Error("Failed to decrypt GCM data")
+		m.logger.WithContext(ctx).WithError(err).Error("Failed to decrypt GCM data")
 		return nil, fmt.Errorf("failed to decrypt GCM data: %w", err)
 	}
 
 	// Verify HMAC if enabled and present in metadata.
-	// Stream bytes through an hmacValidatingReader so HMAC is fed in the single
+	// Stream bytes through an HMAC-gated streaming.DecryptReader so HMAC is fed in the single
 	// read pass the caller performs — no ReadAll/NewReader round-trip, no double
-	// buffering of the plaintext. The validating reader withholds the EOF chunk
+	// buffering of the plaintext. The gated reader withholds the final chunk
 	// until HMAC verification succeeds, preserving the "verify before final
 	// release" guarantee.
 	if m.hmacManager.IsEnabled() {
 		expectedHMAC, err := m.metadataManager.GetHMAC(metadata)
 		if err != nil {
-			m.logger.WithError(err).Debug("HMAC not found in metadata, skipping verification")
+			m.logger.WithContext(ctx).WithError(err).Debug("HMAC not found in metadata, skipping verification")
 			return decryptedReader, nil
 		}
 
-		hmacCalculator, err := m.hmacManager.CreateCalculator(dek)
+		hmacCalculator, err := verificationCalculator(m.hmacManager, m.metadataManager, dek, metadata)
 		if err != nil {
-			m.logger.WithError(err).Error("Failed to create HMAC calculator for verification")
+			m.logger.WithContext(ctx).WithError(err).Error("Failed to create HMAC calculator for verification")
 			return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
 		}
 
-		hvReader := &hmacValidatingReader{
-			reader:         decryptedReader,
-			hmacCalculator: hmacCalculator,
-			hmacManager:    m.hmacManager,
-			expectedHMAC:   expectedHMAC,
-			objectKey:      objectKey,
-			logger:         m.logger.WithField("reader_type", "hmac_validating_gcm"),
-		}
+		hvReader := streaming.NewDecryptReaderSize(decryptedReader, nil, &streaming.Verifier{
+			Calculator: hmacCalculator,
+			Manager:    m.hmacManager,
+			Expected:   expectedHMAC,
+			ObjectKey:  objectKey,
+			OnResult:   auditIntegrity(ctx),
+		}, m.config.GetStreamingReadAheadSize())
 		return bufio.NewReader(hvReader), nil
 	}
 
-	m.logger.Debug("GCM decryption completed successfully")
+	m.logger.WithContext(ctx).Debug("GCM decryption completed successfully")
 	return decryptedReader, nil
 }
 
 // DecryptCTRStream decrypts data using AES-CTR with streaming
 func (m *Manager) DecryptCTRStream(ctx context.Context, encryptedDataReader *bufio.Reader, metadata map[string]string, objectKey string) (*bufio.Reader, error) {
-	m.logger.WithFields(logrus.Fields{
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key": objectKey,
 		"algorithm":  "aes-ctr",
 	}).Debug("Decrypting data stream with CTR")
@@ -294,14 +328,17 @@ func (m *Manager) DecryptDataWithMetadata(ctx context.Context, encryptedReader i
 	}
 
 	if closer, ok := encryptedReader.(io.Closer); ok {
-		return &readCloserWrapper{Reader: decryptedReader, closer: closer}, nil
+		return streaming.WithCloser(decryptedReader, closer), nil
 	}
 	return io.NopCloser(decryptedReader), nil
 }
 
 // CreateStreamingDecryptionReaderWithSize creates a streaming decryption reader with size hint
-func (m *Manager) CreateStreamingDecryptionReaderWithSize(ctx context.Context, encryptedReader io.ReadCloser, _ []byte, metadata map[string]string, objectKey string, providerAlias string, expectedSize int64) (io.ReadCloser, error) {
-	m.logger.WithFields(logrus.Fields{
+func (m *Manager) CreateStreamingDecryptionReaderWithSize(ctx context.Context, encryptedReader io.ReadCloser, _ []byte, metadata map[string]string, objectKey string, providerAlias string, expectedSize int64) (_ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "orchestration.DecryptData", attribute.Int64("s3ep.size", expectedSize))
+	defer func() { tracing.End(span, err) }()
+
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key":     objectKey,
 		"expected_size":  expectedSize,
 		"provider_alias": providerAlias,
@@ -320,7 +357,7 @@ func (m *Manager) CreateStreamingDecryptionReaderWithSize(ctx context.Context, e
 	// decryption/HMAC reader. Returning reader directly when it implements
 	// io.ReadCloser would leak the S3 connection, because the reader's Close()
 	// only releases its own resources (buffers, decryptor state).
-	return &readCloserWrapper{Reader: reader, closer: encryptedReader}, nil
+	return streaming.WithCloser(m.countDecrypted(ctx, reader, metadata), encryptedReader), nil
 }
 
 // isNoneProviderData checks if metadata indicates data was encrypted with none provider
@@ -340,30 +377,33 @@ func (m *Manager) isNoneProviderData(metadata map[string]string) bool {
 	return true // No S3EP metadata, assume none provider
 }
 
-// createStreamingEncryptor creates a streaming encryptor for the given DEK
-func (m *Manager) createStreamingEncryptor(dek []byte) (*dataencryption.AESCTRStatefulEncryptor, error) {
-	encryptor, err := dataencryption.NewAESCTRStatefulEncryptor(dek)
+// createStreamingEncryptor creates a streaming encryptor for the given DEK,
+// with the stream cipher of the provider identified by fingerprint
+func (m *Manager) createStreamingEncryptor(dek []byte, fingerprint string) (dataencryption.StatefulEncryptor, error) {
+	algorithm := m.providerManager.DataAlgorithm(factory.ContentTypeMultipart, fingerprint)
+	encryptor, err := dataencryption.NewStatefulEncryptor(algorithm, dek)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES-CTR streaming encryptor: %w", err)
+		return nil, fmt.Errorf("failed to create %s streaming encryptor: %w", algorithm, err)
 	}
-	m.logger.WithField("algorithm", "aes-ctr").Debug("Created streaming encryptor")
+	m.logger.WithField("algorithm", algorithm).Debug("Created streaming encryptor")
 	return encryptor, nil
 }
 
 // createStreamingDecryptor creates a streaming decryptor for the given DEK and metadata
-func (m *Manager) createStreamingDecryptor(dek []byte, metadata map[string]string) (*dataencryption.AESCTRStatefulEncryptor, error) {
+func (m *Manager) createStreamingDecryptor(dek []byte, metadata map[string]string) (dataencryption.StatefulEncryptor, error) {
 	iv, err := m.metadataManager.GetIV(metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get IV from metadata: %w", err)
 	}
+	algorithm := m.metadataManager.streamAlgorithm(metadata)
 
-	decryptor, err := dataencryption.NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	decryptor, err := streamDecryptor(m.metadataManager, dek, iv, metadata)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES-CTR streaming decryptor: %w", err)
+		return nil, fmt.Errorf("failed to create %s streaming decryptor: %w", algorithm, err)
 	}
 
 	m.logger.WithFields(logrus.Fields{
-		"algorithm": "aes-ctr",
+		"algorithm": algorithm,
 		"approach":  "continuous_ctr_stream",
 	}).Debug("Created streaming decryptor with continuous CTR state")
 
@@ -371,8 +411,7 @@ func (m *Manager) createStreamingDecryptor(dek []byte, metadata map[string]strin
 }
 
 // buildEncryptionMetadataSimple builds simplified metadata for streaming encryption
-func (m *Manager) buildEncryptionMetadataSimple(ctx context.Context, dek []byte, encryptor *dataencryption.AESCTRStatefulEncryptor) (map[string]string, error) {
-	fingerprint := m.providerManager.GetActiveFingerprint()
+func (m *Manager) buildEncryptionMetadataSimple(ctx context.Context, dek []byte, fingerprint string, encryptor dataencryption.StatefulEncryptor) (map[string]string, error) {
 	provider, err := m.providerManager.GetProviderByFingerprint(fingerprint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get provider: %w", err)
@@ -389,9 +428,9 @@ func (m *Manager) buildEncryptionMetadataSimple(ctx context.Context, dek []byte,
 		dek,
 		encryptedDEK,
 		iv,
-		"aes-ctr",
+		encryptor.Algorithm(),
 		fingerprint,
-		m.providerManager.GetActiveProviderAlgorithm(),
+		m.providerManager.ProviderAlgorithm(fingerprint),
 		nil,
 	)
 
@@ -400,7 +439,12 @@ func (m *Manager) buildEncryptionMetadataSimple(ctx context.Context, dek []byte,
 
 // createEncryptionReaderInternal creates encryption reader without wrapper logic
 func (m *Manager) createEncryptionReaderInternal(ctx context.Context, bufReader *bufio.Reader, objectKey string) (io.Reader, map[string]string, error) {
-	m.logger.WithField("object_key", objectKey).Debug("Creating encryption reader for streaming")
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Creating encryption reader for streaming")
+
+	// This reader computes no HMAC, so it cannot bind an encryption context
+	if canonical, _ := encryptionContextFrom(ctx); canonical != "" {
+		return nil, nil, ErrEncryptionContextUnbound
+	}
 
 	// Generate a new 32-byte DEK for AES-256
 	dek := make([]byte, 32)
@@ -408,37 +452,46 @@ func (m *Manager) createEncryptionReaderInternal(ctx context.Context, bufReader
 		return nil, nil, fmt.Errorf("failed to generate DEK: %w", err)
 	}
 
+	fingerprint, err := m.providerManager.fingerprintFor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Create streaming encryptor
-	encryptor, err := m.createStreamingEncryptor(dek)
+	encryptor, err := m.createStreamingEncryptor(dek, fingerprint)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create streaming encryptor: %w", err)
 	}
 
 	// Build metadata
-	metadata, err := m.buildEncryptionMetadataSimple(ctx, dek, encryptor)
+	metadata, err := m.buildEncryptionMetadataSimple(ctx, dek, fingerprint, encryptor)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to build encryption metadata: %w", err)
 	}
+	m.bindEncryptionContext(ctx, metadata)
+	m.providerManager.recordKEKVersion(m.metadataManager, metadata)
 
-	// Create encryption reader
-	encReader := &encryptionReader{
-		reader:    bufReader,
-		encryptor: encryptor,
-		metadata:  metadata,
-		logger:    m.logger.WithField("object_key", objectKey),
-	}
+	encReader := streaming.NewEncryptReader(bufReader, encryptor)
+	encReader.SetParallelism(m.parallelism)
 
-	m.logger.WithField("object_key", objectKey).Debug("Created encryption reader with real AES-CTR streaming")
+	m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("Created encryption reader with real AES-CTR streaming")
 	return encReader, metadata, nil
 }
 
 // createDecryptionReaderWithSizeInternal creates decryption reader without wrapper logic
-func (m *Manager) createDecryptionReaderWithSizeInternal(_ context.Context, bufReader *bufio.Reader, metadata map[string]string, objectKey string, expectedSize int64) (io.Reader, error) {
-	m.logger.WithFields(logrus.Fields{
+func (m *Manager) createDecryptionReaderWithSizeInternal(ctx context.Context, bufReader *bufio.Reader, metadata map[string]string, objectKey string, expectedSize int64) (io.Reader, error) {
+	m.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"object_key":    objectKey,
 		"expected_size": expectedSize,
 	}).Debug("Creating decryption reader with size hint for streaming")
 
+	if err := m.CheckEncryptionContext(ctx, metadata); err != nil {
+		return nil, err
+	}
+	if _, err := m.metadataManager.GetFormatVersion(metadata); err != nil {
+		return nil, err
+	}
+
 	// Extract fingerprint from metadata
 	fingerprint, err := m.metadataManager.GetFingerprint(metadata)
 	if err != nil {
@@ -447,7 +500,7 @@ func (m *Manager) createDecryptionReaderWithSizeInternal(_ context.Context, bufR
 
 	// Check for none provider
 	if fingerprint == "none-provider-fingerprint" {
-		m.logger.Debug("Using none provider - no decryption for streaming")
+		m.logger.WithContext(ctx).Debug("Using none provider - no decryption for streaming")
 		return bufReader, nil
 	}
 
@@ -457,10 +510,14 @@ func (m *Manager) createDecryptionReaderWithSizeInternal(_ context.Context, bufR
 		return nil, fmt.Errorf("failed to get encrypted DEK from metadata: %w", err)
 	}
 
+	if err := m.providerManager.checkTenantAccess(ctx, fingerprint, objectKey); err != nil {
+		return nil, err
+	}
+
 	// Decrypt DEK via ProviderManager (uses per-object DEK cache so repeated reads
 	// of the same object skip the expensive KEK operation). The returned slice
 	// is cache-owned and must be treated as read-only.
-	dek, err := m.providerManager.DecryptDEK(encryptedDEK, fingerprint, objectKey)
+	dek, err := m.providerManager.DecryptDEK(ctx, encryptedDEK, fingerprint, objectKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt DEK: %w", err)
 	}
@@ -471,45 +528,45 @@ func (m *Manager) createDecryptionReaderWithSizeInternal(_ context.Context, bufR
 		return nil, fmt.Errorf("failed to create streaming decryptor: %w", err)
 	}
 
-	// Create decryption reader
-	decReader := &decryptionReader{
-		reader:    bufReader,
-		decryptor: decryptor,
-		metadata:  metadata,
-		logger:    m.logger,
-	}
-
-	// Check if HMAC validation is enabled and we have expected size
-	if m.hmacManager.IsEnabled() && expectedSize > 0 {
+	// Check if HMAC validation is enabled; without a usable HMAC the stream is
+	// decrypted unverified
+	var verifier *streaming.Verifier
+	if m.hmacManager.IsEnabled() {
 		expectedHMAC, hmacErr := m.metadataManager.GetHMAC(metadata)
 		if hmacErr == nil && len(expectedHMAC) > 0 {
-			hmacCalculator, calcErr := m.hmacManager.CreateCalculator(dek)
+			// An unknown recorded algorithm must not skip verification
+			hmacCalculator, calcErr := verificationCalculator(m.hmacManager, m.metadataManager, dek, metadata)
 			if calcErr != nil {
-				m.logger.WithError(calcErr).Warn("Failed to create HMAC calculator, falling back to unvalidated streaming")
-				return decReader, nil
+				return nil, fmt.Errorf("failed to create HMAC calculator: %w", calcErr)
 			}
-
-			// Wrap with HMAC validating reader
-			hvReader := &hmacValidatingReader{
-				reader:         decReader,
-				hmacCalculator: hmacCalculator,
-				hmacManager:    m.hmacManager,
-				expectedHMAC:   expectedHMAC,
-				objectKey:      objectKey,
-				logger:         m.logger.WithField("reader_type", "hmac_validating"),
-				expectedSize:   expectedSize,
+			verifier = &streaming.Verifier{
+				Calculator: hmacCalculator,
+				Manager:    m.hmacManager,
+				Expected:   expectedHMAC,
+				ObjectKey:  objectKey,
+				OnResult:   auditIntegrity(ctx),
 			}
-
-			m.logger.WithFields(logrus.Fields{
-				"object_key":    objectKey,
-				"expected_size": expectedSize,
-			}).Info("🔒 Created HMAC-validating decryption reader")
-			return hvReader, nil
+		} else if m.metadataManager.GetEncryptionContext(metadata) != "" {
+			// The HMAC is what binds the context; without it the context is unverified
+			return nil, fmt.Errorf("%w: object with an encryption context has no HMAC", ErrIntegrityFailure)
+		} else {
+			m.logger.WithContext(ctx).WithField("object_key", objectKey).Debug("HMAC metadata not found, using standard decryption reader")
 		}
+	}
 
-		m.logger.WithField("object_key", objectKey).Debug("HMAC metadata not found, using standard decryption reader")
+	// Read ahead in segment-aligned chunks so CTR and HMAC work on large
+	// blocks even when the client reads in small pieces
+	decReader := streaming.NewDecryptReaderSize(bufReader, decryptor, verifier, m.config.GetStreamingReadAheadSize())
+	decReader.SetParallelism(m.parallelism)
+	decReader.SetReadAhead(m.config.Optimizations.StreamingReadAhead)
+	if verifier != nil {
+		m.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"object_key":    objectKey,
+			"expected_size": expectedSize,
+		}).Debug("Created HMAC-validating decryption reader")
+		return decReader, nil
 	}
 
-	m.logger.Debug("Created decryption reader with real AES-CTR streaming")
+	m.logger.WithContext(ctx).Debug("Created decryption reader with real AES-CTR streaming")
 	return decReader, nil
 }
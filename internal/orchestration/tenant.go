@@ -0,0 +1,111 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+type clientKey struct{}
+
+// WithClient marks ctx as serving a client request signed with accessKeyID,
+// "" for unsigned requests. Tenant isolation applies to such contexts only;
+// the proxy's own jobs, like key rotation and scrubbing, run without it.
+func WithClient(ctx context.Context, accessKeyID string) context.Context {
+	return context.WithValue(ctx, clientKey{}, accessKeyID)
+}
+
+// clientFrom returns the access key attached with WithClient and whether ctx
+// serves a client request
+func clientFrom(ctx context.Context) (accessKeyID string, ok bool) {
+	accessKeyID, ok = ctx.Value(clientKey{}).(string)
+	return accessKeyID, ok
+}
+
+type anonymousKey struct{}
+
+// WithAnonymousClient marks ctx as serving an unsigned read that a
+// public_read bucket let through without authentication. DEKs of tenant
+// providers are never unwrapped for it, whichever bucket it reads.
+func WithAnonymousClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousKey{}, true)
+}
+
+// isAnonymousClient reports whether ctx was marked with WithAnonymousClient
+func isAnonymousClient(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousKey{}).(bool)
+	return anonymous
+}
+
+// tenantFor returns the tenant of the client request ctx serves, or nil
+func (pm *ProviderManager) tenantFor(ctx context.Context) *config.TenantConfig {
+	accessKeyID, ok := clientFrom(ctx)
+	if !ok {
+		return nil
+	}
+	return pm.config.TenantFor(accessKeyID, bucketFrom(ctx))
+}
+
+// providerAliasFor returns the alias of the provider that wraps the DEKs of
+// new objects encrypted with ctx: the one the client selected, else the one
+// of its tenant, or "" for the active provider
+func (pm *ProviderManager) providerAliasFor(ctx context.Context) string {
+	if alias := encryptionProviderFrom(ctx); alias != "" {
+		return alias
+	}
+	if tenant := pm.tenantFor(ctx); tenant != nil {
+		return tenant.Provider
+	}
+	return ""
+}
+
+// tenantOfFingerprint returns the tenant whose dedicated provider has
+// fingerprint, or nil for shared providers
+func (pm *ProviderManager) tenantOfFingerprint(fingerprint string) *config.TenantConfig {
+	pm.providersMutex.RLock()
+	defer pm.providersMutex.RUnlock()
+	for i := range pm.config.Encryption.Tenants {
+		tenant := &pm.config.Encryption.Tenants[i]
+		if info, ok := pm.registeredProviders[tenant.Provider]; ok && info.Fingerprint == fingerprint {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// checkTenantAccess rejects unwrapping a DEK of the provider with fingerprint
+// for the client request ctx serves if the provider is dedicated to a tenant
+// other than that of the request, and for anonymous reads if it is dedicated
+// to any tenant. Tenants can still read objects of shared providers, such as
+// those written before they got their own. The fingerprint is reported to the
+// audit details of ctx.
+func (pm *ProviderManager) checkTenantAccess(ctx context.Context, fingerprint, objectKey string) error {
+	audit.DetailsFrom(ctx).SetKEKFingerprint(fingerprint)
+	anonymous := isAnonymousClient(ctx)
+	if _, ok := clientFrom(ctx); !ok && !anonymous {
+		return nil
+	}
+	owner := pm.tenantOfFingerprint(fingerprint)
+	if owner == nil {
+		return nil
+	}
+	if tenant := pm.tenantFor(ctx); !anonymous && tenant != nil && tenant.Name == owner.Name {
+		monitoring.RecordTenantDEKOperation(owner.Name, "unwrap", true)
+		return nil
+	}
+
+	monitoring.RecordTenantDEKOperation(owner.Name, "unwrap", false)
+	pm.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"tenant":      owner.Name,
+		"fingerprint": fingerprint,
+		"object_key":  objectKey,
+		"bucket":      bucketFrom(ctx),
+		"anonymous":   anonymous,
+	}).Warn("Rejected unwrapping a DEK of a tenant provider for a request of another client")
+	return fmt.Errorf("%w: the object belongs to another tenant", ErrTenantKeyDenied)
+}
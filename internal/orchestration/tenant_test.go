@@ -0,0 +1,123 @@
+package orchestration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	provider := func(alias, key string) config.EncryptionProvider {
+		return config.EncryptionProvider{Alias: alias, Type: "aes", Config: map[string]interface{}{"aes_key": key}}
+	}
+	manager, err := NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "kek-shared",
+			IntegrityVerification: "strict",
+			Providers: []config.EncryptionProvider{
+				provider("kek-shared", "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="),
+				provider("kek-acme", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="),
+				provider("kek-globex", "Z2xvYmV4Z2xvYmV4Z2xvYmV4Z2xvYmV4Z2xvYmV4MTI="),
+			},
+			Tenants: []config.TenantConfig{
+				{Name: "acme", Provider: "kek-acme", AccessKeys: []string{"ACMEKEY"}},
+				{Name: "globex", Provider: "kek-globex", Buckets: []string{"globex-*"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+
+	request := func(accessKeyID, bucket string) context.Context {
+		return WithClient(WithBucket(context.Background(), bucket), accessKeyID)
+	}
+	acme := request("ACMEKEY", "shared")
+	globex := request("OTHERKEY", "globex-data")
+	other := request("OTHERKEY", "shared")
+	plaintext := []byte("tenant owned data")
+
+	fingerprints := make(map[string]string)
+	for _, summary := range manager.GetLoadedProviders() {
+		fingerprints[summary.Alias] = summary.Fingerprint
+		if summary.Alias == "kek-acme" {
+			assert.Equal(t, "acme", summary.Tenant)
+		}
+	}
+	assert.Len(t, fingerprints, 3)
+	assert.NotEqual(t, fingerprints["kek-acme"], fingerprints["kek-globex"])
+
+	for name, contentType := range map[string]factory.ContentType{"gcm": factory.ContentTypeWhole, "ctr": factory.ContentTypeMultipart} {
+		t.Run(name, func(t *testing.T) {
+			ciphertext, metadata := encryptWithContext(t, manager, acme, plaintext, contentType)
+			assert.Equal(t, fingerprints["kek-acme"], metadata["s3ep-kek-fingerprint"])
+
+			decrypted, err := decryptWithContext(manager, acme, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			// Neither another tenant nor a client of no tenant can unwrap the DEK
+			_, err = decryptWithContext(manager, globex, ciphertext, metadata)
+			assert.ErrorIs(t, err, ErrTenantKeyDenied)
+			_, err = decryptWithContext(manager, other, ciphertext, metadata)
+			assert.ErrorIs(t, err, ErrTenantKeyDenied)
+
+			// The proxy's own jobs are not client requests
+			decrypted, err = decryptWithContext(manager, context.Background(), ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+
+			// Rotation leaves DEKs of tenant providers with their provider
+			_, changed, err := manager.RewrapDEK(context.Background(), metadata, "tenant/object.bin")
+			require.NoError(t, err)
+			assert.False(t, changed)
+
+			// Bucket tenants are matched on the bucket of the request
+			ciphertext, metadata = encryptWithContext(t, manager, globex, plaintext, contentType)
+			assert.Equal(t, fingerprints["kek-globex"], metadata["s3ep-kek-fingerprint"])
+
+			// Anonymous reads of public_read buckets get no tenant key, not even
+			// in the bucket of the tenant
+			anonymous := WithAnonymousClient(request("", "globex-data"))
+			_, err = decryptWithContext(manager, anonymous, ciphertext, metadata)
+			assert.ErrorIs(t, err, ErrTenantKeyDenied)
+			_, err = decryptWithContext(manager, WithAnonymousClient(context.Background()), ciphertext, metadata)
+			assert.ErrorIs(t, err, ErrTenantKeyDenied)
+
+			// Tenants still read objects of shared providers, as do anonymous reads
+			ciphertext, metadata = encryptWithContext(t, manager, other, plaintext, contentType)
+			assert.Equal(t, fingerprints["kek-shared"], metadata["s3ep-kek-fingerprint"])
+			decrypted, err = decryptWithContext(manager, acme, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+			decrypted, err = decryptWithContext(manager, anonymous, ciphertext, metadata)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+		})
+	}
+
+	// Tenants cannot select another provider
+	_, err = manager.SelectEncryptionProvider(acme, "kek-shared")
+	assert.ErrorIs(t, err, ErrProviderNotSelectable)
+	_, err = manager.SelectEncryptionProvider(acme, "kek-acme")
+	assert.NoError(t, err)
+}
+
+func TestTenantProviderWithSharedKey(t *testing.T) {
+	key := "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="
+	_, err := NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "kek-shared",
+			Providers: []config.EncryptionProvider{
+				{Alias: "kek-shared", Type: "aes", Config: map[string]interface{}{"aes_key": key}},
+				{Alias: "kek-acme", Type: "aes", Config: map[string]interface{}{"aes_key": key}},
+			},
+			Tenants: []config.TenantConfig{{Name: "acme", Provider: "kek-acme", Buckets: []string{"acme"}}},
+		},
+	})
+	assert.ErrorContains(t, err, "same key")
+}
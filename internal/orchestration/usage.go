@@ -0,0 +1,78 @@
+package orchestration
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+// UsageRecorder counts the plaintext bytes the manager encrypts and decrypts
+type UsageRecorder interface {
+	AddEncrypted(n int64)
+	AddDecrypted(n int64)
+}
+
+// SetUsageRecorder makes the manager report the plaintext bytes of every
+// encryption and decryption to recorder. Data of the none provider is passed
+// through and not reported. It must be called before the manager is used.
+func (m *Manager) SetUsageRecorder(recorder UsageRecorder) {
+	m.usage = recorder
+}
+
+// countEncrypted returns r reporting the bytes read from it as encrypted, and
+// marks the request of ctx as encrypting
+func (m *Manager) countEncrypted(ctx context.Context, r io.Reader) io.Reader {
+	audit.DetailsFrom(ctx).MarkEncrypted()
+	if m.usage == nil {
+		return r
+	}
+	return &countingReader{Reader: r, add: m.usage.AddEncrypted}
+}
+
+// countEncryptedBuffered is countEncrypted for the encryption paths that read
+// through a bufio.Reader
+func (m *Manager) countEncryptedBuffered(ctx context.Context, r *bufio.Reader) *bufio.Reader {
+	if counted := m.countEncrypted(ctx, r); counted != io.Reader(r) {
+		return bufio.NewReader(counted)
+	}
+	return r
+}
+
+// countDecrypted returns r reporting the bytes read from it as decrypted, and
+// marks the request of ctx as decrypting, unless metadata belongs to an
+// object the none provider stored
+func (m *Manager) countDecrypted(ctx context.Context, r io.Reader, metadata map[string]string) io.Reader {
+	if m.isNoneProviderData(metadata) {
+		return r
+	}
+	audit.DetailsFrom(ctx).MarkDecrypted()
+	if m.usage == nil {
+		return r
+	}
+	return &countingReader{Reader: r, add: m.usage.AddDecrypted}
+}
+
+// countingReader reports the bytes read through it. Close is passed on, so
+// wrapping a reader that releases buffers on Close does not leak them.
+type countingReader struct {
+	io.Reader
+	add func(int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if n > 0 {
+		c.add(int64(n))
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (c *countingReader) Close() error {
+	if closer, ok := c.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package orchestration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+type testUsageRecorder struct {
+	encrypted atomic.Int64
+	decrypted atomic.Int64
+}
+
+func (r *testUsageRecorder) AddEncrypted(n int64) { r.encrypted.Add(n) }
+func (r *testUsageRecorder) AddDecrypted(n int64) { r.decrypted.Add(n) }
+
+func TestManager_UsageRecorder(t *testing.T) {
+	manager, err := NewManager(createTestMultipartConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	recorder := &testUsageRecorder{}
+	manager.SetUsageRecorder(recorder)
+	ctx := context.Background()
+	plaintext := bytes.Repeat([]byte("usage payload "), 1000)
+
+	for _, contentType := range []factory.ContentType{factory.ContentTypeWhole, factory.ContentTypeMultipart} {
+		ciphertext, metadata := encryptWithContext(t, manager, ctx, plaintext, contentType)
+		decrypted, err := decryptWithContext(manager, ctx, ciphertext, metadata)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	}
+	assert.Equal(t, int64(2*len(plaintext)), recorder.encrypted.Load())
+	assert.Equal(t, int64(2*len(plaintext)), recorder.decrypted.Load())
+
+	require.NoError(t, manager.InitiateMultipartUpload(ctx, "upload-1", "tenant/object.bin", "bucket"))
+	result, err := manager.UploadPart(ctx, "upload-1", 1, bufio.NewReader(bytes.NewReader(plaintext)))
+	require.NoError(t, err)
+	_, err = io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*len(plaintext)), recorder.encrypted.Load())
+
+	// Objects of the none provider were not decrypted
+	reader, err := manager.DecryptDataWithMetadata(ctx, bytes.NewReader(plaintext), map[string]string{}, "plain.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*len(plaintext)), recorder.decrypted.Load())
+}
@@ -0,0 +1,220 @@
+// Package preflight checks a proxy configuration before it is deployed: the
+// license, every provider, the S3 backend and an encrypt/decrypt round trip
+// through a temporary object. Each check is one entry of a report, so a
+// single run shows every problem instead of stopping at the first.
+package preflight
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn" // usable, but probably not intended
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// RoundTripKeyPrefix is the key prefix of the temporary round-trip objects
+const RoundTripKeyPrefix = ".s3ep-validate/"
+
+// roundTripSize is the size of the payload of the round trip
+const roundTripSize = 64 * 1024
+
+// Check is the result of one check
+type Check struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the result of all checks of a run
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Add appends check to the report
+func (r *Report) Add(check Check) {
+	r.Checks = append(r.Checks, check)
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Backend is the subset of the S3 client used by the checks
+type Backend interface {
+	ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// timed runs fn and returns its check with the duration
+func timed(name string, fn func() (Status, string)) Check {
+	start := time.Now()
+	status, detail := fn()
+	return Check{Name: name, Status: status, Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+}
+
+// CheckLicense validates the license token. Without a license the proxy runs,
+// so a missing token is a warning.
+func CheckLicense(validator *license.LicenseValidator, token string) Check {
+	return timed("license", func() (Status, string) {
+		result := validator.ValidateLicense(token)
+		switch {
+		case token == "":
+			return StatusWarn, result.Message
+		case !result.Valid:
+			if result.Error != nil {
+				return StatusFail, fmt.Sprintf("%s: %v", result.Message, result.Error)
+			}
+			return StatusFail, result.Message
+		case result.Info.ExpiresAt.IsZero():
+			return StatusOK, fmt.Sprintf("licensed to %s, no expiry", result.Info.Claims.LicenseeName)
+		default:
+			return StatusOK, fmt.Sprintf("licensed to %s until %s", result.Info.Claims.LicenseeName, result.Info.ExpiresAt.Format(time.DateOnly))
+		}
+	})
+}
+
+// CheckProviders runs the self-test of every provider: its DEK is wrapped and
+// unwrapped, which reaches a KMS for remote providers, and data is
+// round-tripped with its data cipher
+func CheckProviders(ctx context.Context, manager *orchestration.Manager) []Check {
+	var checks []Check
+	for _, result := range manager.SelfTest(ctx) {
+		check := Check{
+			Name:       fmt.Sprintf("provider %s", result.Alias),
+			Status:     StatusOK,
+			Detail:     result.Type,
+			DurationMS: result.Duration.Milliseconds(),
+		}
+		if result.IsActive {
+			check.Detail += ", active"
+		}
+		switch {
+		case result.Skipped && result.IsActive:
+			check.Status, check.Detail = StatusWarn, check.Detail+": objects are stored unencrypted"
+		case result.Skipped:
+			check.Status, check.Detail = StatusSkip, check.Detail+": no key encryption"
+		case result.Err != nil:
+			check.Status, check.Detail = StatusFail, fmt.Sprintf("%s: %v", check.Detail, result.Err)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// CheckBackend verifies the credentials and endpoint of the backend: with a
+// bucket its objects must be listable, without one its buckets
+func CheckBackend(ctx context.Context, backend Backend, bucket string) Check {
+	return timed("backend", func() (Status, string) {
+		if bucket != "" {
+			input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), MaxKeys: aws.Int32(1)}
+			if _, err := backend.ListObjectsV2(ctx, input); err != nil {
+				return StatusFail, fmt.Sprintf("bucket %s is not accessible: %v", bucket, err)
+			}
+			return StatusOK, fmt.Sprintf("bucket %s is accessible", bucket)
+		}
+		output, err := backend.ListBuckets(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return StatusFail, fmt.Sprintf("failed to list buckets: %v", err)
+		}
+		return StatusOK, fmt.Sprintf("%d buckets listed", len(output.Buckets))
+	})
+}
+
+// CheckRoundTrip encrypts a random payload with the active provider, stores
+// it as a temporary object in bucket, reads it back, decrypts and compares
+// it, and deletes the object again
+func CheckRoundTrip(ctx context.Context, backend Backend, manager *orchestration.Manager, bucket string) Check {
+	return timed("round trip", func() (Status, string) {
+		if bucket == "" {
+			return StatusSkip, "no bucket for the temporary object"
+		}
+		if err := roundTrip(ctx, backend, manager, bucket); err != nil {
+			return StatusFail, err.Error()
+		}
+		return StatusOK, fmt.Sprintf("%d bytes through bucket %s with provider %s", roundTripSize, bucket, manager.GetActiveProviderAlias())
+	})
+}
+
+func roundTrip(ctx context.Context, backend Backend, manager *orchestration.Manager, bucket string) (err error) {
+	payload := make([]byte, roundTripSize)
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(payload); err != nil {
+		return err
+	}
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	key := RoundTripKeyPrefix + hex.EncodeToString(suffix)
+
+	result, err := manager.EncryptDataWithHTTPContentType(ctx, bufio.NewReader(bytes.NewReader(payload)), key, "application/octet-stream", false)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+	size := int64(roundTripSize)
+	if len(result.Metadata) > 0 {
+		size = encryption.ComputeCiphertextSize(size, result.Algorithm)
+	}
+	if _, err := backend.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          result.EncryptedDataReader,
+		ContentLength: aws.Int64(size),
+		Metadata:      result.Metadata,
+	}); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	defer func() {
+		_, deleteErr := backend.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err == nil && deleteErr != nil {
+			err = fmt.Errorf("failed to delete %s: %w", key, deleteErr)
+		}
+	}()
+
+	object, err := backend.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer object.Body.Close()
+	decrypted, err := manager.DecryptData(ctx, bufio.NewReader(object.Body), object.Metadata, key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	data, err := io.ReadAll(decrypted)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if !bytes.Equal(payload, data) {
+		return fmt.Errorf("decrypted data does not match the original")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/license"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+)
+
+func newEncryptionManager(t *testing.T, active string, providers ...config.EncryptionProvider) *orchestration.Manager {
+	t.Helper()
+	manager, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: active,
+			Providers:             providers,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	store := backend.NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	_, err := store.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("data")})
+	require.NoError(t, err)
+	manager := newEncryptionManager(t, "default",
+		config.EncryptionProvider{
+			Alias:  "default",
+			Type:   "aes",
+			Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+		},
+		config.EncryptionProvider{Alias: "plain", Type: "none"},
+	)
+
+	var report Report
+	report.Add(CheckLicense(license.NewValidator(), ""))
+	for _, check := range CheckProviders(ctx, manager) {
+		report.Add(check)
+	}
+	report.Add(CheckBackend(ctx, store, ""))
+	report.Add(CheckBackend(ctx, store, "data"))
+	report.Add(CheckRoundTrip(ctx, store, manager, "data"))
+	assert.False(t, report.Failed(), report.Checks)
+
+	statuses := map[string]Status{}
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	assert.Equal(t, map[string]Status{
+		"license":          StatusWarn,
+		"provider default": StatusOK,
+		"provider plain":   StatusSkip,
+		"backend":          StatusOK,
+		"round trip":       StatusOK,
+	}, statuses)
+
+	listed, err := store.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("data")})
+	require.NoError(t, err)
+	assert.Empty(t, listed.Contents, "the temporary object is deleted")
+
+	assert.Equal(t, StatusSkip, CheckRoundTrip(ctx, store, manager, "").Status)
+	assert.Equal(t, StatusFail, CheckRoundTrip(ctx, store, manager, "missing").Status)
+	assert.Equal(t, StatusFail, CheckBackend(ctx, store, "missing").Status)
+	assert.Equal(t, StatusFail, CheckLicense(license.NewValidator(), "invalid.jwt.token").Status)
+
+	report.Add(CheckRoundTrip(ctx, store, manager, "missing"))
+	assert.True(t, report.Failed())
+}
+
+func TestCheckProviders_ActiveNone(t *testing.T) {
+	checks := CheckProviders(context.Background(), newEncryptionManager(t, "plain", config.EncryptionProvider{Alias: "plain", Type: "none"}))
+	require.Len(t, checks, 1)
+	assert.Equal(t, StatusWarn, checks[0].Status)
+	assert.Contains(t, checks[0].Detail, "unencrypted")
+}
@@ -0,0 +1,140 @@
+// Package probes runs the deep checks behind the readiness and startup
+// probes: the S3 backend, the providers and the license. Every check runs in
+// the background at its own interval and the probes report the latest
+// results, so a probe answers at once and probing does not multiply the
+// requests to the backend or a KMS.
+package probes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+)
+
+// StatusPending is the status of a check that has not completed yet
+const StatusPending preflight.Status = "pending"
+
+// CheckFunc runs a check. It may report several results, e.g. one per
+// provider.
+type CheckFunc func(ctx context.Context) []preflight.Check
+
+// Result is the latest result of a check
+type Result struct {
+	preflight.Check
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// Passed reports whether no result failed or is pending
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == preflight.StatusFail || result.Status == StatusPending {
+			return false
+		}
+	}
+	return true
+}
+
+type check struct {
+	name     string
+	interval time.Duration
+	run      CheckFunc
+}
+
+// Prober runs the registered checks and keeps their latest results
+type Prober struct {
+	timeout time.Duration
+	logger  *logrus.Entry
+	checks  []check
+
+	mu      sync.RWMutex
+	results map[string][]Result // by check name
+}
+
+// NewProber creates a prober whose checks may take up to timeout each
+func NewProber(timeout time.Duration, logger *logrus.Entry) *Prober {
+	return &Prober{
+		timeout: timeout,
+		logger:  logger,
+		results: make(map[string][]Result),
+	}
+}
+
+// Register adds a check that runs every interval. Checks must be registered
+// before Run.
+func (p *Prober) Register(name string, interval time.Duration, run CheckFunc) {
+	p.checks = append(p.checks, check{name: name, interval: interval, run: run})
+}
+
+// Run runs every check right away and then at its interval, until ctx is
+// done
+func (p *Prober) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range p.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(c.interval)
+			defer ticker.Stop()
+			for {
+				p.runCheck(ctx, c)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Results returns the latest results of all checks in the order they were
+// registered. A check that has not completed yet is reported as pending.
+func (p *Prober) Results() []Result {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var results []Result
+	for _, c := range p.checks {
+		if checked, ok := p.results[c.name]; ok {
+			results = append(results, checked...)
+		} else {
+			results = append(results, Result{Check: preflight.Check{Name: c.name, Status: StatusPending}})
+		}
+	}
+	return results
+}
+
+func (p *Prober) runCheck(ctx context.Context, c check) {
+	checkCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	checks := c.run(checkCtx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	results := make([]Result, 0, len(checks))
+	for _, checked := range checks {
+		results = append(results, Result{Check: checked, CheckedAt: &now})
+	}
+
+	p.mu.Lock()
+	previous, ran := p.results[c.name]
+	p.results[c.name] = results
+	p.mu.Unlock()
+
+	switch passed := Passed(results); {
+	case !passed && (!ran || Passed(previous)):
+		for _, result := range results {
+			if result.Status == preflight.StatusFail {
+				p.logger.WithFields(logrus.Fields{"check": result.Name, "detail": result.Detail}).Warn("Probe check failed")
+			}
+		}
+	case passed && ran && !Passed(previous):
+		p.logger.WithField("check", c.name).Info("Probe check passed again")
+	}
+}
@@ -0,0 +1,69 @@
+package probes
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+)
+
+func TestProber(t *testing.T) {
+	var backendUp atomic.Bool
+	var providerRuns atomic.Int32
+	prober := NewProber(time.Second, logrus.NewEntry(logrus.New()))
+	prober.Register("backend", 10*time.Millisecond, func(context.Context) []preflight.Check {
+		if !backendUp.Load() {
+			return []preflight.Check{{Name: "backend", Status: preflight.StatusFail, Detail: "connection refused"}}
+		}
+		return []preflight.Check{{Name: "backend", Status: preflight.StatusOK}}
+	})
+	prober.Register("providers", time.Hour, func(context.Context) []preflight.Check {
+		providerRuns.Add(1)
+		return []preflight.Check{
+			{Name: "provider default", Status: preflight.StatusOK},
+			{Name: "provider plain", Status: preflight.StatusSkip},
+		}
+	})
+
+	results := prober.Results()
+	require.Len(t, results, 2)
+	assert.Equal(t, StatusPending, results[0].Status)
+	assert.Nil(t, results[0].CheckedAt)
+	assert.False(t, Passed(results))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go prober.Run(ctx)
+
+	assert.Eventually(t, func() bool { return len(prober.Results()) == 3 }, time.Second, 5*time.Millisecond)
+	results = prober.Results()
+	assert.Equal(t, []string{"backend", "provider default", "provider plain"}, []string{results[0].Name, results[1].Name, results[2].Name})
+	assert.Equal(t, preflight.StatusFail, results[0].Status)
+	assert.NotNil(t, results[0].CheckedAt)
+	assert.False(t, Passed(results))
+
+	backendUp.Store(true)
+	assert.Eventually(t, func() bool { return Passed(prober.Results()) }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), providerRuns.Load(), "each check runs at its own interval")
+}
+
+func TestProber_Timeout(t *testing.T) {
+	prober := NewProber(10*time.Millisecond, logrus.NewEntry(logrus.New()))
+	prober.Register("kms", time.Hour, func(ctx context.Context) []preflight.Check {
+		<-ctx.Done()
+		return []preflight.Check{{Name: "kms", Status: preflight.StatusFail, Detail: ctx.Err().Error()}}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go prober.Run(ctx)
+
+	assert.Eventually(t, func() bool { return prober.Results()[0].Status == preflight.StatusFail }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, prober.Results()[0].Detail, "deadline exceeded")
+}
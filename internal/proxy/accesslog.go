@@ -0,0 +1,18 @@
+package proxy
+
+import (
+	"github.com/guided-traffic/s3-encryption-proxy/internal/accesslog"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// newAccessLog opens the access log, or returns nil unless access_log.enabled
+func newAccessLog(cfg *config.Config) (*accesslog.Logger, error) {
+	if !cfg.AccessLog.Enabled {
+		return nil, nil
+	}
+	format := accesslog.Format(cfg.AccessLog.Format)
+	if format == "" {
+		format = accesslog.FormatCombined
+	}
+	return accesslog.Open(format, cfg.AccessLog.Output)
+}
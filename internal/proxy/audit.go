@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/attestation"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// newAuditRecorder opens the audit log and the sinks, or returns nil if
+// neither is configured
+func newAuditRecorder(cfg *config.Config) (*audit.Recorder, error) {
+	if !cfg.Audit.Enabled && len(cfg.Audit.Sinks) == 0 {
+		return nil, nil
+	}
+	logger := logrus.WithField("component", "audit")
+
+	var log *audit.Log
+	if cfg.Audit.Enabled {
+		signingKey, err := attestation.LoadSigningKey(cfg.Audit.SigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit signing key: %w", err)
+		}
+		log, err = audit.Open(audit.Options{
+			Directory:  cfg.Audit.Directory,
+			SigningKey: signingKey,
+			MaxRecords: cfg.Audit.SegmentMaxRecords,
+			MaxAge:     time.Duration(cfg.Audit.SegmentMaxAge) * time.Second,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+	}
+
+	recorder := audit.NewRecorder(log, logger)
+	for i, sinkConfig := range cfg.Audit.Sinks {
+		opts := audit.SinkOptions{
+			Name:          cfg.Audit.SinkName(i),
+			QueueSize:     cfg.Audit.SinkQueueSize,
+			BatchSize:     cfg.Audit.SinkBatchSize,
+			FlushInterval: time.Duration(cfg.Audit.SinkFlushInterval) * time.Millisecond,
+			Timeout:       time.Duration(cfg.Audit.SinkTimeout) * time.Second,
+		}
+		recorder.AddSink(newAuditSink(sinkConfig), opts)
+		logger.WithFields(logrus.Fields{"sink": opts.Name, "type": sinkConfig.Type}).Info("Forwarding audit records")
+	}
+	return recorder, nil
+}
+
+// newAuditSink creates the sink of a validated sink configuration
+func newAuditSink(sink config.AuditSinkConfig) audit.Sink {
+	switch sink.Type {
+	case config.AuditSinkSyslog:
+		network := sink.Network
+		if network == "" {
+			network = "udp"
+		}
+		facility, ok := audit.SyslogFacility(sink.Facility)
+		if !ok {
+			facility, _ = audit.SyslogFacility("local0")
+		}
+		return audit.NewSyslogSink(network, sink.Address, facility, "s3-encryption-proxy")
+	case config.AuditSinkKafka:
+		return audit.NewKafkaSink(sink.URL, sink.Topic, sink.Headers)
+	default:
+		return audit.NewWebhookSink(sink.URL, sink.Headers)
+	}
+}
@@ -0,0 +1,202 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// latencySmoothing is the weight of a new health check duration in the
+// smoothed latency of an endpoint
+const latencySmoothing = 0.3
+
+// Endpoint is a backend endpoint of a Failover
+type Endpoint struct {
+	Name        string
+	Client      interfaces.S3BackendInterface
+	HealthCheck func(ctx context.Context) error // optional
+}
+
+type endpoint struct {
+	Endpoint
+	healthy atomic.Bool
+	latency atomic.Int64 // smoothed health check duration in ns, 0 until measured
+}
+
+// Failover implements interfaces.S3BackendInterface over a primary backend
+// and its read replicas. Writes, bucket configuration and multipart uploads
+// go to the primary. Object reads and listings go to the primary, or with
+// nearest to the healthy endpoint with the lowest latency, and are retried on
+// the next endpoint when one cannot be reached or answers with a server error.
+type Failover struct {
+	primary   *endpoint
+	endpoints []*endpoint // primary first
+	nearest   bool
+	logger    *logrus.Entry
+}
+
+// NewFailover creates a failover backend. With nearest, reads prefer the
+// healthy endpoint with the lowest health check latency over the primary.
+func NewFailover(primary Endpoint, replicas []Endpoint, nearest bool, logger *logrus.Entry) *Failover {
+	f := &Failover{nearest: nearest, logger: logger}
+	for _, cfg := range append([]Endpoint{primary}, replicas...) {
+		ep := &endpoint{Endpoint: cfg}
+		ep.healthy.Store(true)
+		f.endpoints = append(f.endpoints, ep)
+	}
+	f.primary = f.endpoints[0]
+	return f
+}
+
+// readOrder returns the endpoints in the order reads try them: healthy ones
+// by preference, then the unhealthy ones, whose last check may be outdated
+func (f *Failover) readOrder() []*endpoint {
+	healthy := make([]*endpoint, 0, len(f.endpoints))
+	var unhealthy []*endpoint
+	for _, ep := range f.endpoints {
+		if ep.healthy.Load() {
+			healthy = append(healthy, ep)
+		} else {
+			unhealthy = append(unhealthy, ep)
+		}
+	}
+	if f.nearest {
+		// Unmeasured endpoints go last; ties keep the primary first
+		sort.SliceStable(healthy, func(i, j int) bool {
+			a, b := healthy[i].latency.Load(), healthy[j].latency.Load()
+			return a != 0 && (b == 0 || a < b)
+		})
+	}
+	return append(healthy, unhealthy...)
+}
+
+// read runs fn against the endpoints in read order until one succeeds. A
+// replica that does not know the object or bucket yet, e.g. through
+// replication lag, is followed by the primary, which writes go to.
+func read[T any](ctx context.Context, f *Failover, operation string, fn func(client interfaces.S3BackendInterface) (T, error)) (T, error) {
+	var (
+		out          T
+		err          error
+		triedPrimary bool
+	)
+	for _, ep := range f.readOrder() {
+		if ep == f.primary {
+			triedPrimary = true
+		}
+		out, err = fn(ep.Client)
+		monitoring.RecordBackendEndpointRequest(ep.Name, operation, err)
+		if err == nil || ctx.Err() != nil {
+			return out, err
+		}
+
+		status := httpStatus(err)
+		switch {
+		case status == http.StatusNotFound && ep != f.primary && !triedPrimary:
+			// Only the primary is sure to have it; while it fails, the
+			// answer of the replica is the best there is
+			monitoring.RecordBackendFailover(ep.Name, operation)
+			primaryOut, primaryErr := fn(f.primary.Client)
+			monitoring.RecordBackendEndpointRequest(f.primary.Name, operation, primaryErr)
+			if status := httpStatus(primaryErr); primaryErr != nil && (status == 0 || status >= 500) {
+				return out, err
+			}
+			return primaryOut, primaryErr
+		case status == 0 || status >= 500:
+			if status == 0 && ep.healthy.Swap(false) {
+				f.logger.WithError(err).WithField("endpoint", ep.Name).Warn("Backend endpoint unreachable, failing over until its health check passes")
+				monitoring.SetBackendEndpointHealth(ep.Name, false, time.Duration(ep.latency.Load()))
+			}
+			monitoring.RecordBackendFailover(ep.Name, operation)
+		default:
+			return out, err
+		}
+	}
+	return out, err
+}
+
+// write runs fn against the primary
+func write[T any](f *Failover, operation string, fn func(client interfaces.S3BackendInterface) (T, error)) (T, error) {
+	out, err := fn(f.primary.Client)
+	monitoring.RecordBackendEndpointRequest(f.primary.Name, operation, err)
+	return out, err
+}
+
+// httpStatus returns the HTTP status of a backend error, 0 if the backend
+// did not answer
+func httpStatus(err error) int {
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatusCode()
+	}
+	return 0
+}
+
+// Healthy returns the result of the last health check per endpoint name
+func (f *Failover) Healthy() map[string]bool {
+	status := make(map[string]bool, len(f.endpoints))
+	for _, ep := range f.endpoints {
+		status[ep.Name] = ep.healthy.Load()
+	}
+	return status
+}
+
+// RunHealthChecks checks every endpoint with a HealthCheck once per interval
+// until ctx is cancelled
+func (f *Failover) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	f.CheckHealth(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.CheckHealth(ctx)
+		}
+	}
+}
+
+// CheckHealth runs the health check of every endpoint once, updates their
+// latency and logs changes
+func (f *Failover) CheckHealth(ctx context.Context) {
+	for _, ep := range f.endpoints {
+		if ep.HealthCheck == nil {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		start := time.Now()
+		err := ep.HealthCheck(checkCtx)
+		elapsed := time.Since(start)
+		cancel()
+
+		healthy := err == nil
+		if healthy {
+			latency := elapsed.Nanoseconds()
+			if previous := ep.latency.Load(); previous != 0 {
+				latency = int64(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(previous))
+			}
+			ep.latency.Store(max(latency, 1))
+		}
+		monitoring.SetBackendEndpointHealth(ep.Name, healthy, time.Duration(ep.latency.Load()))
+		if ep.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			f.logger.WithField("endpoint", ep.Name).Info("Backend endpoint is healthy again")
+		} else {
+			f.logger.WithError(err).WithField("endpoint", ep.Name).Warn("Backend endpoint health check failed")
+		}
+	}
+}
+
+var _ interfaces.S3BackendInterface = (*Failover)(nil)
@@ -0,0 +1,433 @@
+package backend
+
+// Object reads and listings of interfaces.S3BackendInterface follow the read
+// preference of the Failover and fail over to the next endpoint; every other
+// operation goes to the primary.
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// GetBucketAcl forwards to the primary
+func (f *Failover) GetBucketAcl(ctx context.Context, params *s3.GetBucketAclInput, optFns ...func(*s3.Options)) (*s3.GetBucketAclOutput, error) {
+	return write(f, "GetBucketAcl", func(client interfaces.S3BackendInterface) (*s3.GetBucketAclOutput, error) {
+		return client.GetBucketAcl(ctx, params, optFns...)
+	})
+}
+
+// PutBucketAcl forwards to the primary
+func (f *Failover) PutBucketAcl(ctx context.Context, params *s3.PutBucketAclInput, optFns ...func(*s3.Options)) (*s3.PutBucketAclOutput, error) {
+	return write(f, "PutBucketAcl", func(client interfaces.S3BackendInterface) (*s3.PutBucketAclOutput, error) {
+		return client.PutBucketAcl(ctx, params, optFns...)
+	})
+}
+
+// GetBucketCors forwards to the primary
+func (f *Failover) GetBucketCors(ctx context.Context, params *s3.GetBucketCorsInput, optFns ...func(*s3.Options)) (*s3.GetBucketCorsOutput, error) {
+	return write(f, "GetBucketCors", func(client interfaces.S3BackendInterface) (*s3.GetBucketCorsOutput, error) {
+		return client.GetBucketCors(ctx, params, optFns...)
+	})
+}
+
+// PutBucketCors forwards to the primary
+func (f *Failover) PutBucketCors(ctx context.Context, params *s3.PutBucketCorsInput, optFns ...func(*s3.Options)) (*s3.PutBucketCorsOutput, error) {
+	return write(f, "PutBucketCors", func(client interfaces.S3BackendInterface) (*s3.PutBucketCorsOutput, error) {
+		return client.PutBucketCors(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketCors forwards to the primary
+func (f *Failover) DeleteBucketCors(ctx context.Context, params *s3.DeleteBucketCorsInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketCorsOutput, error) {
+	return write(f, "DeleteBucketCors", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketCorsOutput, error) {
+		return client.DeleteBucketCors(ctx, params, optFns...)
+	})
+}
+
+// GetBucketVersioning forwards to the primary
+func (f *Failover) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return write(f, "GetBucketVersioning", func(client interfaces.S3BackendInterface) (*s3.GetBucketVersioningOutput, error) {
+		return client.GetBucketVersioning(ctx, params, optFns...)
+	})
+}
+
+// PutBucketVersioning forwards to the primary
+func (f *Failover) PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	return write(f, "PutBucketVersioning", func(client interfaces.S3BackendInterface) (*s3.PutBucketVersioningOutput, error) {
+		return client.PutBucketVersioning(ctx, params, optFns...)
+	})
+}
+
+// GetBucketAccelerateConfiguration forwards to the primary
+func (f *Failover) GetBucketAccelerateConfiguration(ctx context.Context, params *s3.GetBucketAccelerateConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketAccelerateConfigurationOutput, error) {
+	return write(f, "GetBucketAccelerateConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketAccelerateConfigurationOutput, error) {
+		return client.GetBucketAccelerateConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketAccelerateConfiguration forwards to the primary
+func (f *Failover) PutBucketAccelerateConfiguration(ctx context.Context, params *s3.PutBucketAccelerateConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketAccelerateConfigurationOutput, error) {
+	return write(f, "PutBucketAccelerateConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketAccelerateConfigurationOutput, error) {
+		return client.PutBucketAccelerateConfiguration(ctx, params, optFns...)
+	})
+}
+
+// GetBucketRequestPayment forwards to the primary
+func (f *Failover) GetBucketRequestPayment(ctx context.Context, params *s3.GetBucketRequestPaymentInput, optFns ...func(*s3.Options)) (*s3.GetBucketRequestPaymentOutput, error) {
+	return write(f, "GetBucketRequestPayment", func(client interfaces.S3BackendInterface) (*s3.GetBucketRequestPaymentOutput, error) {
+		return client.GetBucketRequestPayment(ctx, params, optFns...)
+	})
+}
+
+// PutBucketRequestPayment forwards to the primary
+func (f *Failover) PutBucketRequestPayment(ctx context.Context, params *s3.PutBucketRequestPaymentInput, optFns ...func(*s3.Options)) (*s3.PutBucketRequestPaymentOutput, error) {
+	return write(f, "PutBucketRequestPayment", func(client interfaces.S3BackendInterface) (*s3.PutBucketRequestPaymentOutput, error) {
+		return client.PutBucketRequestPayment(ctx, params, optFns...)
+	})
+}
+
+// GetBucketTagging forwards to the primary
+func (f *Failover) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	return write(f, "GetBucketTagging", func(client interfaces.S3BackendInterface) (*s3.GetBucketTaggingOutput, error) {
+		return client.GetBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// PutBucketTagging forwards to the primary
+func (f *Failover) PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	return write(f, "PutBucketTagging", func(client interfaces.S3BackendInterface) (*s3.PutBucketTaggingOutput, error) {
+		return client.PutBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketTagging forwards to the primary
+func (f *Failover) DeleteBucketTagging(ctx context.Context, params *s3.DeleteBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketTaggingOutput, error) {
+	return write(f, "DeleteBucketTagging", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketTaggingOutput, error) {
+		return client.DeleteBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// GetBucketNotificationConfiguration forwards to the primary
+func (f *Failover) GetBucketNotificationConfiguration(ctx context.Context, params *s3.GetBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	return write(f, "GetBucketNotificationConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketNotificationConfigurationOutput, error) {
+		return client.GetBucketNotificationConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketNotificationConfiguration forwards to the primary
+func (f *Failover) PutBucketNotificationConfiguration(ctx context.Context, params *s3.PutBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	return write(f, "PutBucketNotificationConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketNotificationConfigurationOutput, error) {
+		return client.PutBucketNotificationConfiguration(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLifecycleConfiguration forwards to the primary
+func (f *Failover) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return write(f, "GetBucketLifecycleConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+		return client.GetBucketLifecycleConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketLifecycleConfiguration forwards to the primary
+func (f *Failover) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	return write(f, "PutBucketLifecycleConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+		return client.PutBucketLifecycleConfiguration(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketLifecycle forwards to the primary
+func (f *Failover) DeleteBucketLifecycle(ctx context.Context, params *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	return write(f, "DeleteBucketLifecycle", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketLifecycleOutput, error) {
+		return client.DeleteBucketLifecycle(ctx, params, optFns...)
+	})
+}
+
+// GetBucketReplication forwards to the primary
+func (f *Failover) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	return write(f, "GetBucketReplication", func(client interfaces.S3BackendInterface) (*s3.GetBucketReplicationOutput, error) {
+		return client.GetBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// PutBucketReplication forwards to the primary
+func (f *Failover) PutBucketReplication(ctx context.Context, params *s3.PutBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.PutBucketReplicationOutput, error) {
+	return write(f, "PutBucketReplication", func(client interfaces.S3BackendInterface) (*s3.PutBucketReplicationOutput, error) {
+		return client.PutBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketReplication forwards to the primary
+func (f *Failover) DeleteBucketReplication(ctx context.Context, params *s3.DeleteBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketReplicationOutput, error) {
+	return write(f, "DeleteBucketReplication", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketReplicationOutput, error) {
+		return client.DeleteBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// GetBucketWebsite forwards to the primary
+func (f *Failover) GetBucketWebsite(ctx context.Context, params *s3.GetBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.GetBucketWebsiteOutput, error) {
+	return write(f, "GetBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.GetBucketWebsiteOutput, error) {
+		return client.GetBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// PutBucketWebsite forwards to the primary
+func (f *Failover) PutBucketWebsite(ctx context.Context, params *s3.PutBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error) {
+	return write(f, "PutBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.PutBucketWebsiteOutput, error) {
+		return client.PutBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketWebsite forwards to the primary
+func (f *Failover) DeleteBucketWebsite(ctx context.Context, params *s3.DeleteBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketWebsiteOutput, error) {
+	return write(f, "DeleteBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketWebsiteOutput, error) {
+		return client.DeleteBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLocation forwards to the primary
+func (f *Failover) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	return write(f, "GetBucketLocation", func(client interfaces.S3BackendInterface) (*s3.GetBucketLocationOutput, error) {
+		return client.GetBucketLocation(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLogging forwards to the primary
+func (f *Failover) GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	return write(f, "GetBucketLogging", func(client interfaces.S3BackendInterface) (*s3.GetBucketLoggingOutput, error) {
+		return client.GetBucketLogging(ctx, params, optFns...)
+	})
+}
+
+// PutBucketLogging forwards to the primary
+func (f *Failover) PutBucketLogging(ctx context.Context, params *s3.PutBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketLoggingOutput, error) {
+	return write(f, "PutBucketLogging", func(client interfaces.S3BackendInterface) (*s3.PutBucketLoggingOutput, error) {
+		return client.PutBucketLogging(ctx, params, optFns...)
+	})
+}
+
+// GetBucketPolicy forwards to the primary
+func (f *Failover) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	return write(f, "GetBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.GetBucketPolicyOutput, error) {
+		return client.GetBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// PutBucketPolicy forwards to the primary
+func (f *Failover) PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	return write(f, "PutBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.PutBucketPolicyOutput, error) {
+		return client.PutBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketPolicy forwards to the primary
+func (f *Failover) DeleteBucketPolicy(ctx context.Context, params *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+	return write(f, "DeleteBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketPolicyOutput, error) {
+		return client.DeleteBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// ListBuckets reads from the endpoints in read order
+func (f *Failover) ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	return read(ctx, f, "ListBuckets", func(client interfaces.S3BackendInterface) (*s3.ListBucketsOutput, error) {
+		return client.ListBuckets(ctx, params, optFns...)
+	})
+}
+
+// CreateBucket forwards to the primary
+func (f *Failover) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	return write(f, "CreateBucket", func(client interfaces.S3BackendInterface) (*s3.CreateBucketOutput, error) {
+		return client.CreateBucket(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucket forwards to the primary
+func (f *Failover) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	return write(f, "DeleteBucket", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketOutput, error) {
+		return client.DeleteBucket(ctx, params, optFns...)
+	})
+}
+
+// ListObjectsV2 reads from the endpoints in read order
+func (f *Failover) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return read(ctx, f, "ListObjectsV2", func(client interfaces.S3BackendInterface) (*s3.ListObjectsV2Output, error) {
+		return client.ListObjectsV2(ctx, params, optFns...)
+	})
+}
+
+// ListObjects reads from the endpoints in read order
+func (f *Failover) ListObjects(ctx context.Context, params *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	return read(ctx, f, "ListObjects", func(client interfaces.S3BackendInterface) (*s3.ListObjectsOutput, error) {
+		return client.ListObjects(ctx, params, optFns...)
+	})
+}
+
+// ListObjectVersions reads from the endpoints in read order
+func (f *Failover) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	return read(ctx, f, "ListObjectVersions", func(client interfaces.S3BackendInterface) (*s3.ListObjectVersionsOutput, error) {
+		return client.ListObjectVersions(ctx, params, optFns...)
+	})
+}
+
+// GetObject reads from the endpoints in read order
+func (f *Failover) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return read(ctx, f, "GetObject", func(client interfaces.S3BackendInterface) (*s3.GetObjectOutput, error) {
+		return client.GetObject(ctx, params, optFns...)
+	})
+}
+
+// PutObject forwards to the primary
+func (f *Failover) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return write(f, "PutObject", func(client interfaces.S3BackendInterface) (*s3.PutObjectOutput, error) {
+		return client.PutObject(ctx, params, optFns...)
+	})
+}
+
+// DeleteObject forwards to the primary
+func (f *Failover) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return write(f, "DeleteObject", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectOutput, error) {
+		return client.DeleteObject(ctx, params, optFns...)
+	})
+}
+
+// HeadObject reads from the endpoints in read order
+func (f *Failover) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return read(ctx, f, "HeadObject", func(client interfaces.S3BackendInterface) (*s3.HeadObjectOutput, error) {
+		return client.HeadObject(ctx, params, optFns...)
+	})
+}
+
+// CopyObject forwards to the primary
+func (f *Failover) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	return write(f, "CopyObject", func(client interfaces.S3BackendInterface) (*s3.CopyObjectOutput, error) {
+		return client.CopyObject(ctx, params, optFns...)
+	})
+}
+
+// CreateMultipartUpload forwards to the primary
+func (f *Failover) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return write(f, "CreateMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.CreateMultipartUploadOutput, error) {
+		return client.CreateMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// UploadPart forwards to the primary
+func (f *Failover) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return write(f, "UploadPart", func(client interfaces.S3BackendInterface) (*s3.UploadPartOutput, error) {
+		return client.UploadPart(ctx, params, optFns...)
+	})
+}
+
+// CompleteMultipartUpload forwards to the primary
+func (f *Failover) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return write(f, "CompleteMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.CompleteMultipartUploadOutput, error) {
+		return client.CompleteMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// AbortMultipartUpload forwards to the primary
+func (f *Failover) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return write(f, "AbortMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.AbortMultipartUploadOutput, error) {
+		return client.AbortMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// ListParts forwards to the primary
+func (f *Failover) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	return write(f, "ListParts", func(client interfaces.S3BackendInterface) (*s3.ListPartsOutput, error) {
+		return client.ListParts(ctx, params, optFns...)
+	})
+}
+
+// ListMultipartUploads forwards to the primary
+func (f *Failover) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	return write(f, "ListMultipartUploads", func(client interfaces.S3BackendInterface) (*s3.ListMultipartUploadsOutput, error) {
+		return client.ListMultipartUploads(ctx, params, optFns...)
+	})
+}
+
+// GetObjectAcl reads from the endpoints in read order
+func (f *Failover) GetObjectAcl(ctx context.Context, params *s3.GetObjectAclInput, optFns ...func(*s3.Options)) (*s3.GetObjectAclOutput, error) {
+	return read(ctx, f, "GetObjectAcl", func(client interfaces.S3BackendInterface) (*s3.GetObjectAclOutput, error) {
+		return client.GetObjectAcl(ctx, params, optFns...)
+	})
+}
+
+// PutObjectAcl forwards to the primary
+func (f *Failover) PutObjectAcl(ctx context.Context, params *s3.PutObjectAclInput, optFns ...func(*s3.Options)) (*s3.PutObjectAclOutput, error) {
+	return write(f, "PutObjectAcl", func(client interfaces.S3BackendInterface) (*s3.PutObjectAclOutput, error) {
+		return client.PutObjectAcl(ctx, params, optFns...)
+	})
+}
+
+// GetObjectTagging reads from the endpoints in read order
+func (f *Failover) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	return read(ctx, f, "GetObjectTagging", func(client interfaces.S3BackendInterface) (*s3.GetObjectTaggingOutput, error) {
+		return client.GetObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// PutObjectTagging forwards to the primary
+func (f *Failover) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	return write(f, "PutObjectTagging", func(client interfaces.S3BackendInterface) (*s3.PutObjectTaggingOutput, error) {
+		return client.PutObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteObjectTagging forwards to the primary
+func (f *Failover) DeleteObjectTagging(ctx context.Context, params *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+	return write(f, "DeleteObjectTagging", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectTaggingOutput, error) {
+		return client.DeleteObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteObjects forwards to the primary
+func (f *Failover) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	return write(f, "DeleteObjects", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectsOutput, error) {
+		return client.DeleteObjects(ctx, params, optFns...)
+	})
+}
+
+// GetObjectLegalHold reads from the endpoints in read order
+func (f *Failover) GetObjectLegalHold(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+	return read(ctx, f, "GetObjectLegalHold", func(client interfaces.S3BackendInterface) (*s3.GetObjectLegalHoldOutput, error) {
+		return client.GetObjectLegalHold(ctx, params, optFns...)
+	})
+}
+
+// PutObjectLegalHold forwards to the primary
+func (f *Failover) PutObjectLegalHold(ctx context.Context, params *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+	return write(f, "PutObjectLegalHold", func(client interfaces.S3BackendInterface) (*s3.PutObjectLegalHoldOutput, error) {
+		return client.PutObjectLegalHold(ctx, params, optFns...)
+	})
+}
+
+// GetObjectRetention reads from the endpoints in read order
+func (f *Failover) GetObjectRetention(ctx context.Context, params *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+	return read(ctx, f, "GetObjectRetention", func(client interfaces.S3BackendInterface) (*s3.GetObjectRetentionOutput, error) {
+		return client.GetObjectRetention(ctx, params, optFns...)
+	})
+}
+
+// PutObjectRetention forwards to the primary
+func (f *Failover) PutObjectRetention(ctx context.Context, params *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+	return write(f, "PutObjectRetention", func(client interfaces.S3BackendInterface) (*s3.PutObjectRetentionOutput, error) {
+		return client.PutObjectRetention(ctx, params, optFns...)
+	})
+}
+
+// GetObjectTorrent reads from the endpoints in read order
+func (f *Failover) GetObjectTorrent(ctx context.Context, params *s3.GetObjectTorrentInput, optFns ...func(*s3.Options)) (*s3.GetObjectTorrentOutput, error) {
+	return read(ctx, f, "GetObjectTorrent", func(client interfaces.S3BackendInterface) (*s3.GetObjectTorrentOutput, error) {
+		return client.GetObjectTorrent(ctx, params, optFns...)
+	})
+}
+
+// SelectObjectContent reads from the endpoints in read order
+func (f *Failover) SelectObjectContent(ctx context.Context, params *s3.SelectObjectContentInput, optFns ...func(*s3.Options)) (*s3.SelectObjectContentOutput, error) {
+	return read(ctx, f, "SelectObjectContent", func(client interfaces.S3BackendInterface) (*s3.SelectObjectContentOutput, error) {
+		return client.SelectObjectContent(ctx, params, optFns...)
+	})
+}
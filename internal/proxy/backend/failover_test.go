@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// statusError is a backend answer with an HTTP status
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+// failoverBackend answers reads with err and records the calls it receives
+type failoverBackend struct {
+	interfaces.S3BackendInterface
+	name  string
+	err   error
+	calls []string
+}
+
+func (f *failoverBackend) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.calls = append(f.calls, "GetObject")
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.GetObjectOutput{ETag: aws.String(f.name)}, nil
+}
+
+func (f *failoverBackend) PutObject(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.calls = append(f.calls, "PutObject")
+	return &s3.PutObjectOutput{ETag: aws.String(f.name)}, f.err
+}
+
+func newTestFailover(nearest bool) (*Failover, *failoverBackend, *failoverBackend, *failoverBackend) {
+	primary := &failoverBackend{name: "primary"}
+	dc2 := &failoverBackend{name: "dc2"}
+	dc3 := &failoverBackend{name: "dc3"}
+	failover := NewFailover(Endpoint{Name: "default", Client: primary}, []Endpoint{
+		{Name: "dc2", Client: dc2},
+		{Name: "dc3", Client: dc3},
+	}, nearest, testLogger())
+	return failover, primary, dc2, dc3
+}
+
+func getObject(t *testing.T, failover *Failover) (string, error) {
+	t.Helper()
+	out, err := failover.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func TestFailover_ReadsFailOverToReplicas(t *testing.T) {
+	failover, primary, dc2, dc3 := newTestFailover(false)
+
+	served, err := getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", served)
+	assert.Empty(t, dc2.calls)
+
+	// A server error is retried on the next endpoint without marking the
+	// primary unhealthy
+	primary.err = statusError(http.StatusServiceUnavailable)
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "dc2", served)
+	assert.True(t, failover.Healthy()["default"])
+
+	// An unreachable endpoint is skipped until its health check passes
+	primary.err = errors.New("connection refused")
+	dc2.err = errors.New("connection refused")
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "dc3", served)
+	assert.Equal(t, map[string]bool{"default": false, "dc2": false, "dc3": true}, failover.Healthy())
+
+	primary.err, dc2.err = nil, nil
+	primary.calls = nil
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "dc3", served)
+	assert.Empty(t, primary.calls)
+
+	failover.endpoints[0].HealthCheck = func(context.Context) error { return nil }
+	failover.CheckHealth(context.Background())
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", served)
+
+	// Client errors are answers, not failures
+	primary.err = statusError(http.StatusForbidden)
+	dc2.calls = nil
+	_, err = getObject(t, failover)
+	assert.Error(t, err)
+	assert.Empty(t, dc2.calls)
+
+	// Every endpoint fails: the error of the last one tried, dc2 whose last
+	// health check failed, is returned
+	primary.err = statusError(http.StatusInternalServerError)
+	dc2.err = statusError(http.StatusBadGateway)
+	dc3.err = statusError(http.StatusInternalServerError)
+	_, err = getObject(t, failover)
+	assert.Equal(t, statusError(http.StatusBadGateway), err)
+}
+
+func TestFailover_WritesGoToPrimary(t *testing.T) {
+	failover, primary, dc2, _ := newTestFailover(true)
+	failover.endpoints[1].latency.Store(1)
+	failover.endpoints[0].healthy.Store(false)
+
+	primary.err = errors.New("connection refused")
+	_, err := failover.PutObject(context.Background(), &s3.PutObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	assert.Error(t, err, "writes are not retried on replicas")
+	assert.Equal(t, []string{"PutObject"}, primary.calls)
+	assert.Empty(t, dc2.calls)
+}
+
+func TestFailover_Nearest(t *testing.T) {
+	failover, primary, dc2, dc3 := newTestFailover(true)
+	failover.endpoints[0].latency.Store(30)
+	failover.endpoints[1].latency.Store(20)
+	failover.endpoints[2].latency.Store(10)
+
+	served, err := getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "dc3", served)
+
+	// An object the replica does not have yet is read from the primary
+	dc3.err = statusError(http.StatusNotFound)
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", served)
+	assert.Empty(t, dc2.calls)
+
+	// While the primary fails, the answer of the replica stands
+	primary.err = errors.New("connection refused")
+	_, err = getObject(t, failover)
+	assert.Equal(t, statusError(http.StatusNotFound), err)
+
+	// Unmeasured endpoints are tried after measured ones
+	failover, _, _, _ = newTestFailover(true)
+	failover.endpoints[2].latency.Store(10)
+	served, err = getObject(t, failover)
+	require.NoError(t, err)
+	assert.Equal(t, "dc3", served)
+}
+
+func TestFailover_CheckHealthSmoothsLatency(t *testing.T) {
+	failover, _, _, _ := newTestFailover(true)
+	checkErr := errors.New("timeout")
+	failover.endpoints[1].HealthCheck = func(context.Context) error { return checkErr }
+	failover.endpoints[2].HealthCheck = func(context.Context) error { return nil }
+
+	failover.CheckHealth(context.Background())
+	assert.Equal(t, map[string]bool{"default": true, "dc2": false, "dc3": true}, failover.Healthy())
+	assert.Zero(t, failover.endpoints[1].latency.Load())
+	assert.Positive(t, failover.endpoints[2].latency.Load())
+	assert.Zero(t, failover.endpoints[0].latency.Load(), "endpoints without a check are not measured")
+
+	checkErr = nil
+	failover.CheckHealth(context.Background())
+	assert.True(t, failover.Healthy()["dc2"])
+}
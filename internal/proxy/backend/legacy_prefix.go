@@ -0,0 +1,251 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// prefixMigrationTimeout bounds the background metadata copy of a migration
+// on read
+const prefixMigrationTimeout = 30 * time.Second
+
+// prefixMarkers are the metadata keys that tell which prefix the encryption
+// metadata of an object was written with; every encrypted object has one of
+// them, objects with a sidecar at least its marker
+var prefixMarkers = []string{"encrypted-dek", "dek-algorithm", "sidecar"}
+
+// LegacyPrefixStore implements interfaces.S3BackendInterface on top of another
+// backend for objects whose encryption metadata was written under an earlier
+// metadata key prefix, as listed in encryption.legacy_metadata_key_prefixes:
+//
+//   - GetObject and HeadObject return the metadata of such objects under the
+//     current prefix, so the rest of the proxy reads them like any other;
+//   - with migration on read, such objects are then rewritten under the
+//     current prefix by a metadata-only self-copy in the background,
+//     conditional on the ETag that was read;
+//   - MigrateObject rewrites one object on demand, for s3ep-rekey.
+//
+// Other operations go to the wrapped backend unchanged.
+type LegacyPrefixStore struct {
+	interfaces.S3BackendInterface
+	prefix        string   // current encryption metadata key prefix
+	legacy        []string // earlier prefixes, in the order they are tried
+	migrateOnRead bool
+	migrations    sync.Map // bucket/key of the migrations in progress
+	logger        *logrus.Entry
+}
+
+// NewLegacyPrefixStore wraps next. prefix is the current encryption metadata
+// key prefix, legacy the prefixes objects may have been written with.
+func NewLegacyPrefixStore(next interfaces.S3BackendInterface, prefix string, legacy []string, migrateOnRead bool, logger *logrus.Entry) *LegacyPrefixStore {
+	return &LegacyPrefixStore{
+		S3BackendInterface: next,
+		prefix:             prefix,
+		legacy:             legacy,
+		migrateOnRead:      migrateOnRead,
+		logger:             logger,
+	}
+}
+
+// hasPrefix reports whether metadata holds encryption metadata under prefix
+func hasPrefix(metadata map[string]string, prefix string) bool {
+	for _, marker := range prefixMarkers {
+		if _, ok := lookupMetadata(metadata, prefix+marker); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyPrefixOf returns the legacy prefix the encryption metadata of an
+// object was written with, or "" for objects under the current prefix and
+// objects without encryption metadata. The current prefix is checked first,
+// as it may extend a legacy one.
+func (s *LegacyPrefixStore) legacyPrefixOf(metadata map[string]string) string {
+	if len(s.legacy) == 0 || hasPrefix(metadata, s.prefix) {
+		return ""
+	}
+	for _, prefix := range s.legacy {
+		if hasPrefix(metadata, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// rebase returns a copy of metadata with the keys under legacy moved under
+// the current prefix
+func (s *LegacyPrefixStore) rebase(metadata map[string]string, legacy string) map[string]string {
+	rebased := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if len(k) >= len(legacy) && strings.EqualFold(k[:len(legacy)], legacy) {
+			k = s.prefix + k[len(legacy):]
+		}
+		rebased[k] = v
+	}
+	return rebased
+}
+
+// objectAttributes are the attributes of an object a metadata-only copy has
+// to send again
+type objectAttributes struct {
+	etag                    *string
+	versionID               *string
+	contentType             *string
+	cacheControl            *string
+	contentDisposition      *string
+	contentEncoding         *string
+	contentLanguage         *string
+	websiteRedirectLocation *string
+	storageClass            string
+}
+
+// GetObject returns the metadata of objects under a legacy prefix under the
+// current one
+func (s *LegacyPrefixStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := s.S3BackendInterface.GetObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if legacy := s.legacyPrefixOf(out.Metadata); legacy != "" {
+		out.Metadata = s.rebase(out.Metadata, legacy)
+		s.migrateInBackground(ctx, aws.ToString(params.Bucket), aws.ToString(params.Key), legacy, out.Metadata, getAttributes(out))
+	}
+	return out, nil
+}
+
+// HeadObject returns the metadata of objects under a legacy prefix under the
+// current one
+func (s *LegacyPrefixStore) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	out, err := s.S3BackendInterface.HeadObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if legacy := s.legacyPrefixOf(out.Metadata); legacy != "" {
+		out.Metadata = s.rebase(out.Metadata, legacy)
+		s.migrateInBackground(ctx, aws.ToString(params.Bucket), aws.ToString(params.Key), legacy, out.Metadata, headAttributes(out))
+	}
+	return out, nil
+}
+
+// getAttributes returns the attributes of an object read with GetObject
+func getAttributes(out *s3.GetObjectOutput) objectAttributes {
+	return objectAttributes{
+		etag:                    out.ETag,
+		versionID:               out.VersionId,
+		contentType:             out.ContentType,
+		cacheControl:            out.CacheControl,
+		contentDisposition:      out.ContentDisposition,
+		contentEncoding:         out.ContentEncoding,
+		contentLanguage:         out.ContentLanguage,
+		websiteRedirectLocation: out.WebsiteRedirectLocation,
+		storageClass:            string(out.StorageClass),
+	}
+}
+
+// headAttributes returns the attributes of an object read with HeadObject
+func headAttributes(out *s3.HeadObjectOutput) objectAttributes {
+	return objectAttributes{
+		etag:                    out.ETag,
+		versionID:               out.VersionId,
+		contentType:             out.ContentType,
+		cacheControl:            out.CacheControl,
+		contentDisposition:      out.ContentDisposition,
+		contentEncoding:         out.ContentEncoding,
+		contentLanguage:         out.ContentLanguage,
+		websiteRedirectLocation: out.WebsiteRedirectLocation,
+		storageClass:            string(out.StorageClass),
+	}
+}
+
+// migrateInBackground starts the migration of an object that was read, if
+// migration on read is enabled. Objects in versioned buckets are left alone:
+// the self-copy would add a version.
+func (s *LegacyPrefixStore) migrateInBackground(ctx context.Context, bucket, key, legacy string, metadata map[string]string, attributes objectAttributes) {
+	if !s.migrateOnRead {
+		return
+	}
+	if version := aws.ToString(attributes.versionID); version != "" && version != "null" {
+		return
+	}
+	metadata = maps.Clone(metadata)
+	go func() {
+		if _, running := s.migrations.LoadOrStore(bucket+"/"+key, struct{}{}); running {
+			return
+		}
+		defer s.migrations.Delete(bucket + "/" + key)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), prefixMigrationTimeout)
+		defer cancel()
+		if err := s.migrate(ctx, bucket, key, metadata, attributes); err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithFields(logrus.Fields{
+				"bucket":        bucket,
+				"key":           key,
+				"legacy_prefix": legacy,
+			}).Warn("Failed to migrate metadata to the current prefix")
+		}
+	}()
+}
+
+// migrate stores metadata, already rebased, on the object with a
+// metadata-only self-copy. The copy is conditional on the ETag that was read,
+// so an object overwritten in the meantime is left alone.
+func (s *LegacyPrefixStore) migrate(ctx context.Context, bucket, key string, metadata map[string]string, attributes objectAttributes) error {
+	// REPLACE drops every attribute that is not sent again
+	input := &s3.CopyObjectInput{
+		Bucket:                  aws.String(bucket),
+		Key:                     aws.String(key),
+		CopySource:              aws.String(bucket + "/" + url.PathEscape(key)),
+		CopySourceIfMatch:       attributes.etag,
+		Metadata:                metadata,
+		MetadataDirective:       types.MetadataDirectiveReplace,
+		ContentType:             attributes.contentType,
+		CacheControl:            attributes.cacheControl,
+		ContentDisposition:      attributes.contentDisposition,
+		ContentEncoding:         attributes.contentEncoding,
+		ContentLanguage:         attributes.contentLanguage,
+		WebsiteRedirectLocation: attributes.websiteRedirectLocation,
+	}
+	if attributes.storageClass != "" {
+		input.StorageClass = types.StorageClass(attributes.storageClass)
+	}
+	if _, err := s.S3BackendInterface.CopyObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to rewrite metadata: %w", err)
+	}
+
+	s.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"bucket": bucket,
+		"key":    key,
+	}).Debug("Migrated metadata to the current prefix")
+	return nil
+}
+
+// MigrateObject rewrites the metadata of an object stored under a legacy
+// prefix to the current prefix and reports whether it was stored under one.
+// With dryRun the object is only checked.
+func (s *LegacyPrefixStore) MigrateObject(ctx context.Context, bucket, key string, dryRun bool) (bool, error) {
+	head, err := s.S3BackendInterface.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return false, err
+	}
+	legacy := s.legacyPrefixOf(head.Metadata)
+	if legacy == "" || dryRun {
+		return legacy != "", nil
+	}
+	return true, s.migrate(ctx, bucket, key, s.rebase(head.Metadata, legacy), headAttributes(head))
+}
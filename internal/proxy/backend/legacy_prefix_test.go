@@ -0,0 +1,124 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func legacyMetadata() map[string]string {
+	return map[string]string{
+		"s3ep-encrypted-dek":   "dek",
+		"s3ep-dek-algorithm":   "aes-gcm",
+		"s3ep-kek-fingerprint": "fp",
+		"owner":                "team-a",
+	}
+}
+
+func rebasedMetadata() map[string]string {
+	return map[string]string{
+		"acme-enc-encrypted-dek":   "dek",
+		"acme-enc-dek-algorithm":   "aes-gcm",
+		"acme-enc-kek-fingerprint": "fp",
+		"owner":                    "team-a",
+	}
+}
+
+func putMemoryObject(t *testing.T, memory *MemoryBackend, key string, metadata map[string]string) {
+	t.Helper()
+	_, err := memory.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String(key),
+		Body:     strings.NewReader("ciphertext"),
+		Metadata: metadata,
+	})
+	require.NoError(t, err)
+}
+
+func TestLegacyPrefixStore_ReadsLegacyObjects(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewLegacyPrefixStore(memory, "acme-enc-", []string{"old-", "s3ep-"}, false, logrus.NewEntry(logrus.New()))
+
+	putMemoryObject(t, memory, "legacy", legacyMetadata())
+	putMemoryObject(t, memory, "current", rebasedMetadata())
+	putMemoryObject(t, memory, "plain", map[string]string{"s3ep-note": "user metadata of an unencrypted object"})
+
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, store, "bucket", "legacy"))
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, store, "bucket", "current"))
+	assert.Equal(t, map[string]string{"s3ep-note": "user metadata of an unencrypted object"}, headMetadata(t, store, "bucket", "plain"))
+
+	out, err := store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("legacy")})
+	require.NoError(t, err)
+	assert.Equal(t, rebasedMetadata(), out.Metadata)
+
+	// Without migration the stored metadata stays as it is
+	assert.Equal(t, legacyMetadata(), headMetadata(t, memory, "bucket", "legacy"))
+}
+
+func TestLegacyPrefixStore_CurrentPrefixExtendsLegacy(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewLegacyPrefixStore(memory, "s3ep-acme-", []string{"s3ep-"}, false, logrus.NewEntry(logrus.New()))
+
+	current := map[string]string{"s3ep-acme-encrypted-dek": "dek", "s3ep-acme-dek-algorithm": "aes-gcm"}
+	putMemoryObject(t, memory, "current", current)
+	putMemoryObject(t, memory, "legacy", map[string]string{"s3ep-encrypted-dek": "dek", "s3ep-dek-algorithm": "aes-gcm"})
+
+	assert.Equal(t, current, headMetadata(t, store, "bucket", "current"))
+	assert.Equal(t, current, headMetadata(t, store, "bucket", "legacy"))
+}
+
+func TestLegacyPrefixStore_MigratesOnRead(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewLegacyPrefixStore(memory, "acme-enc-", []string{"s3ep-"}, true, logrus.NewEntry(logrus.New()))
+	putMemoryObject(t, memory, "legacy", legacyMetadata())
+
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, store, "bucket", "legacy"))
+	assert.Eventually(t, func() bool {
+		stored := headMetadata(t, memory, "bucket", "legacy")
+		return stored["acme-enc-encrypted-dek"] == "dek" && stored["s3ep-encrypted-dek"] == ""
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, memory, "bucket", "legacy"))
+}
+
+func TestLegacyPrefixStore_MigrateObject(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewLegacyPrefixStore(memory, "acme-enc-", []string{"s3ep-"}, false, logrus.NewEntry(logrus.New()))
+	putMemoryObject(t, memory, "legacy", legacyMetadata())
+	putMemoryObject(t, memory, "current", rebasedMetadata())
+	ctx := context.Background()
+
+	migrated, err := store.MigrateObject(ctx, "bucket", "legacy", true)
+	require.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, legacyMetadata(), headMetadata(t, memory, "bucket", "legacy"))
+
+	migrated, err = store.MigrateObject(ctx, "bucket", "legacy", false)
+	require.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, memory, "bucket", "legacy"))
+
+	migrated, err = store.MigrateObject(ctx, "bucket", "current", false)
+	require.NoError(t, err)
+	assert.False(t, migrated)
+}
+
+func TestLegacyPrefixStore_LegacySidecar(t *testing.T) {
+	sidecars, _ := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	putSidecarObject(t, sidecars, "legacy", legacyMetadata())
+
+	// The prefix changed after the object was written with a sidecar
+	sidecars = NewSidecarStore(sidecars.S3BackendInterface, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways}, "acme-enc-", logrus.NewEntry(logrus.New()))
+	sidecars.SetLegacyPrefixes([]string{"s3ep-"})
+	store := NewLegacyPrefixStore(sidecars, "acme-enc-", []string{"s3ep-"}, false, logrus.NewEntry(logrus.New()))
+
+	assert.Equal(t, rebasedMetadata(), headMetadata(t, store, "bucket", "legacy"))
+}
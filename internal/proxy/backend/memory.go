@@ -0,0 +1,776 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 - S3 ETags are MD5 digests
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// memoryListLimit is the page size of list operations without MaxKeys
+const memoryListLimit = 1000
+
+// MemoryBackend is an in-process S3 backend that keeps all buckets and objects
+// in memory. It is meant for local development: it supports buckets, objects
+// (with ranges and copies), multipart uploads and object tags, and answers all
+// other operations with NotImplemented. Every write is traced at debug level
+// together with the encryption metadata it stored.
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucket
+	uploads map[string]*memoryUpload
+	logger  *logrus.Entry
+}
+
+type memoryBucket struct {
+	created time.Time
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	data         []byte
+	metadata     map[string]string
+	contentType  string
+	etag         string
+	lastModified time.Time
+	tags         []types.Tag
+}
+
+type memoryUpload struct {
+	bucket      string
+	key         string
+	metadata    map[string]string
+	contentType string
+	initiated   time.Time
+	parts       map[int32]*memoryObject
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend(logger *logrus.Entry) *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*memoryBucket),
+		uploads: make(map[string]*memoryUpload),
+		logger:  logger,
+	}
+}
+
+func noSuchBucket(bucket string) error {
+	return &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist: " + bucket)}
+}
+
+func notImplemented[T any](operation string) (*T, error) {
+	return nil, &smithy.GenericAPIError{
+		Code:    "NotImplemented",
+		Message: operation + " is not supported by the in-memory backend",
+	}
+}
+
+func memoryETag(data []byte) string {
+	sum := md5.Sum(data) // #nosec G401 - S3 ETags are MD5 digests
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// bucket returns the bucket or a NoSuchBucket error; callers hold mu
+func (b *MemoryBackend) bucket(name string) (*memoryBucket, error) {
+	bucket, ok := b.buckets[name]
+	if !ok {
+		return nil, noSuchBucket(name)
+	}
+	return bucket, nil
+}
+
+// object returns the object or a NoSuchBucket/NoSuchKey error; callers hold mu
+func (b *MemoryBackend) object(bucketName, key string) (*memoryObject, error) {
+	bucket, err := b.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := bucket.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist: " + key)}
+	}
+	return obj, nil
+}
+
+// trace logs a stored object with the encryption metadata that came with it
+func (b *MemoryBackend) trace(operation, bucket, key string, obj *memoryObject) {
+	if !b.logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	fields := logrus.Fields{
+		"operation":    operation,
+		"bucket":       bucket,
+		"key":          key,
+		"stored_bytes": len(obj.data),
+	}
+	for name, value := range obj.metadata {
+		// Wrapped DEKs, IVs and HMACs are long and not meaningful to read
+		if len(value) <= 64 {
+			fields["meta."+name] = value
+		}
+	}
+	b.logger.WithFields(fields).Debug("In-memory backend stored object")
+}
+
+// ListBuckets lists all buckets by name
+func (b *MemoryBackend) ListBuckets(_ context.Context, _ *s3.ListBucketsInput, _ ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	output := &s3.ListBucketsOutput{Owner: &types.Owner{ID: aws.String("s3ep-dev"), DisplayName: aws.String("s3ep-dev")}}
+	for _, name := range slices.Sorted(maps.Keys(b.buckets)) {
+		output.Buckets = append(output.Buckets, types.Bucket{
+			Name:         aws.String(name),
+			CreationDate: aws.Time(b.buckets[name].created),
+		})
+	}
+	return output, nil
+}
+
+// CreateBucket creates an empty bucket
+func (b *MemoryBackend) CreateBucket(_ context.Context, params *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name := aws.ToString(params.Bucket)
+	if _, ok := b.buckets[name]; ok {
+		return nil, &types.BucketAlreadyOwnedByYou{}
+	}
+	b.buckets[name] = &memoryBucket{created: time.Now().UTC(), objects: make(map[string]*memoryObject)}
+	b.logger.WithField("bucket", name).Debug("In-memory backend created bucket")
+	return &s3.CreateBucketOutput{Location: aws.String("/" + name)}, nil
+}
+
+// DeleteBucket deletes an empty bucket
+func (b *MemoryBackend) DeleteBucket(_ context.Context, params *s3.DeleteBucketInput, _ ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name := aws.ToString(params.Bucket)
+	bucket, err := b.bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.objects) > 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty"}
+	}
+	delete(b.buckets, name)
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+// GetBucketLocation reports the default region for every bucket
+func (b *MemoryBackend) GetBucketLocation(_ context.Context, params *s3.GetBucketLocationInput, _ ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if _, err := b.bucket(aws.ToString(params.Bucket)); err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketLocationOutput{}, nil
+}
+
+// PutObject stores an object
+func (b *MemoryBackend) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if params.Body != nil {
+		var err error
+		if data, err = io.ReadAll(params.Body); err != nil {
+			return nil, fmt.Errorf("failed to read object body: %w", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, err := b.bucket(aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMemoryWriteConditions(bucket.objects[aws.ToString(params.Key)], params.IfMatch, params.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	obj := &memoryObject{
+		data:         data,
+		metadata:     maps.Clone(params.Metadata),
+		contentType:  aws.ToString(params.ContentType),
+		etag:         memoryETag(data),
+		lastModified: time.Now().UTC(),
+		tags:         memoryTags(params.Tagging),
+	}
+	bucket.objects[aws.ToString(params.Key)] = obj
+	b.trace("PutObject", aws.ToString(params.Bucket), aws.ToString(params.Key), obj)
+	return &s3.PutObjectOutput{ETag: aws.String(obj.etag)}, nil
+}
+
+// memoryTags parses the URL-encoded tags of a PutObject
+func memoryTags(tagging *string) []types.Tag {
+	pairs, err := url.ParseQuery(aws.ToString(tagging))
+	if err != nil || len(pairs) == 0 {
+		return nil
+	}
+	tags := make([]types.Tag, 0, len(pairs))
+	for key, values := range pairs {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(values[0])})
+	}
+	return tags
+}
+
+// GetObject returns an object, or the requested byte range of it
+func (b *MemoryBackend) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, err := b.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if err != nil {
+		return nil, err
+	}
+	if params.IfMatch != nil && !memoryETagMatches(aws.ToString(params.IfMatch), obj.etag) {
+		return nil, errMemoryPreconditionFailed
+	}
+	if params.IfNoneMatch != nil && memoryETagMatches(aws.ToString(params.IfNoneMatch), obj.etag) {
+		return nil, &smithy.GenericAPIError{Code: "NotModified", Message: "Not Modified"}
+	}
+
+	data := obj.data
+	output := &s3.GetObjectOutput{
+		ContentType:   aws.String(obj.contentType),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+		Metadata:      maps.Clone(obj.metadata),
+		AcceptRanges:  aws.String("bytes"),
+		TagCount:      aws.Int32(int32(len(obj.tags))), // #nosec G115 - tag count is small
+		StorageClass:  types.StorageClassStandard,
+		ContentLength: aws.Int64(int64(len(data))),
+	}
+	if params.Range != nil {
+		start, end, err := parseMemoryRange(aws.ToString(params.Range), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = data[start : end+1]
+		output.ContentLength = aws.Int64(int64(len(data)))
+		output.ContentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data)))
+	}
+	output.Body = io.NopCloser(bytes.NewReader(data))
+	return output, nil
+}
+
+var errMemoryPreconditionFailed = &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+
+// checkMemoryWriteConditions evaluates the If-Match and If-None-Match headers
+// of a write against the object it replaces, nil if there is none
+func checkMemoryWriteConditions(existing *memoryObject, ifMatch, ifNoneMatch *string) error {
+	if ifMatch != nil {
+		if existing == nil {
+			return &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+		}
+		if !memoryETagMatches(aws.ToString(ifMatch), existing.etag) {
+			return errMemoryPreconditionFailed
+		}
+	}
+	if ifNoneMatch != nil && existing != nil && memoryETagMatches(aws.ToString(ifNoneMatch), existing.etag) {
+		return errMemoryPreconditionFailed
+	}
+	return nil
+}
+
+// memoryETagMatches reports whether a comma-separated conditional header
+// lists etag or "*"
+func memoryETagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.Trim(candidate, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMemoryRange parses a single "bytes=" range against an object of size bytes
+func parseMemoryRange(header string, size int64) (start, end int64, err error) {
+	invalid := &smithy.GenericAPIError{Code: "InvalidRange", Message: "The requested range is not satisfiable"}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, invalid
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, invalid
+		}
+		return max(size-suffix, 0), size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, invalid
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, invalid
+		}
+		end = min(end, size-1)
+	}
+	return start, end, nil
+}
+
+// HeadObject returns the attributes of an object
+func (b *MemoryBackend) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, err := b.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ContentType:   aws.String(obj.contentType),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+		Metadata:      maps.Clone(obj.metadata),
+		AcceptRanges:  aws.String("bytes"),
+		StorageClass:  types.StorageClassStandard,
+	}, nil
+}
+
+// DeleteObject deletes an object; deleting a missing key succeeds
+func (b *MemoryBackend) DeleteObject(_ context.Context, params *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, err := b.bucket(aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	delete(bucket.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// DeleteObjects deletes a batch of objects
+func (b *MemoryBackend) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, err := b.bucket(aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.DeleteObjectsOutput{}
+	if params.Delete == nil {
+		return output, nil
+	}
+	for _, object := range params.Delete.Objects {
+		delete(bucket.objects, aws.ToString(object.Key))
+		if !aws.ToBool(params.Delete.Quiet) {
+			output.Deleted = append(output.Deleted, types.DeletedObject{Key: object.Key})
+		}
+	}
+	return output, nil
+}
+
+// CopyObject copies an object within the backend. With the REPLACE
+// directives the metadata and content type, or the tags, of the request are
+// used.
+func (b *MemoryBackend) CopyObject(_ context.Context, params *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	source := aws.ToString(params.CopySource)
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, err := b.object(sourceBucket, sourceKey)
+	if err != nil {
+		return nil, err
+	}
+	if params.CopySourceIfMatch != nil && aws.ToString(params.CopySourceIfMatch) != src.etag {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+	}
+	bucket, err := b.bucket(aws.ToString(params.Bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &memoryObject{
+		data:         src.data, // never modified in place
+		metadata:     maps.Clone(src.metadata),
+		contentType:  src.contentType,
+		etag:         src.etag,
+		lastModified: time.Now().UTC(),
+		tags:         slices.Clone(src.tags),
+	}
+	if params.MetadataDirective == types.MetadataDirectiveReplace {
+		obj.metadata = maps.Clone(params.Metadata)
+		obj.contentType = aws.ToString(params.ContentType)
+	}
+	if params.TaggingDirective == types.TaggingDirectiveReplace {
+		obj.tags = memoryTags(params.Tagging)
+	}
+	bucket.objects[aws.ToString(params.Key)] = obj
+	b.trace("CopyObject", aws.ToString(params.Bucket), aws.ToString(params.Key), obj)
+
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &types.CopyObjectResult{ETag: aws.String(obj.etag), LastModified: aws.Time(obj.lastModified)},
+	}, nil
+}
+
+// listPage is one page of a bucket listing
+type listPage struct {
+	objects   []types.Object
+	prefixes  []types.CommonPrefix
+	truncated bool
+	next      string // last key or common prefix of the page
+}
+
+// list returns the keys after the given marker, grouped by delimiter; callers hold mu
+func (b *MemoryBackend) list(bucketName, prefix, delimiter, after string, maxKeys int32) (*listPage, error) {
+	bucket, err := b.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if maxKeys <= 0 {
+		maxKeys = memoryListLimit
+	}
+
+	page := &listPage{}
+	seen := make(map[string]bool)
+	for _, key := range slices.Sorted(maps.Keys(bucket.objects)) {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		entry := key
+		var commonPrefix string
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+				entry = commonPrefix
+			}
+		}
+		if seen[entry] || (commonPrefix != "" && commonPrefix <= after) {
+			continue
+		}
+		if int32(len(page.objects)+len(page.prefixes)) == maxKeys { // #nosec G115 - bounded by maxKeys
+			page.truncated = true
+			break
+		}
+
+		seen[entry] = true
+		page.next = entry
+		if commonPrefix != "" {
+			page.prefixes = append(page.prefixes, types.CommonPrefix{Prefix: aws.String(commonPrefix)})
+			continue
+		}
+		obj := bucket.objects[key]
+		page.objects = append(page.objects, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(obj.data))),
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.lastModified),
+			StorageClass: types.ObjectStorageClassStandard,
+		})
+	}
+	return page, nil
+}
+
+// ListObjectsV2 lists the objects of a bucket. Continuation tokens are the
+// last key or common prefix of the previous page.
+func (b *MemoryBackend) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	after := aws.ToString(params.StartAfter)
+	if token := aws.ToString(params.ContinuationToken); token > after {
+		after = token
+	}
+	page, err := b.list(aws.ToString(params.Bucket), aws.ToString(params.Prefix), aws.ToString(params.Delimiter), after, aws.ToInt32(params.MaxKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &s3.ListObjectsV2Output{
+		Name:              params.Bucket,
+		Prefix:            params.Prefix,
+		Delimiter:         params.Delimiter,
+		MaxKeys:           params.MaxKeys,
+		StartAfter:        params.StartAfter,
+		ContinuationToken: params.ContinuationToken,
+		Contents:          page.objects,
+		CommonPrefixes:    page.prefixes,
+		IsTruncated:       aws.Bool(page.truncated),
+		KeyCount:          aws.Int32(int32(len(page.objects) + len(page.prefixes))), // #nosec G115 - bounded by MaxKeys
+	}
+	if page.truncated {
+		output.NextContinuationToken = aws.String(page.next)
+	}
+	return output, nil
+}
+
+// ListObjects lists the objects of a bucket (version 1)
+func (b *MemoryBackend) ListObjects(_ context.Context, params *s3.ListObjectsInput, _ ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	page, err := b.list(aws.ToString(params.Bucket), aws.ToString(params.Prefix), aws.ToString(params.Delimiter), aws.ToString(params.Marker), aws.ToInt32(params.MaxKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &s3.ListObjectsOutput{
+		Name:           params.Bucket,
+		Prefix:         params.Prefix,
+		Delimiter:      params.Delimiter,
+		Marker:         params.Marker,
+		MaxKeys:        params.MaxKeys,
+		Contents:       page.objects,
+		CommonPrefixes: page.prefixes,
+		IsTruncated:    aws.Bool(page.truncated),
+	}
+	if page.truncated {
+		output.NextMarker = aws.String(page.next)
+	}
+	return output, nil
+}
+
+// CreateMultipartUpload starts a multipart upload
+func (b *MemoryBackend) CreateMultipartUpload(_ context.Context, params *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	uploadID := hex.EncodeToString(id)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.bucket(aws.ToString(params.Bucket)); err != nil {
+		return nil, err
+	}
+	b.uploads[uploadID] = &memoryUpload{
+		bucket:      aws.ToString(params.Bucket),
+		key:         aws.ToString(params.Key),
+		metadata:    maps.Clone(params.Metadata),
+		contentType: aws.ToString(params.ContentType),
+		initiated:   time.Now().UTC(),
+		parts:       make(map[int32]*memoryObject),
+	}
+	return &s3.CreateMultipartUploadOutput{Bucket: params.Bucket, Key: params.Key, UploadId: aws.String(uploadID)}, nil
+}
+
+// upload returns a multipart upload of bucket/key; callers hold mu
+func (b *MemoryBackend) upload(bucket, key, uploadID string) (*memoryUpload, error) {
+	upload, ok := b.uploads[uploadID]
+	if !ok || upload.bucket != bucket || upload.key != key {
+		return nil, &types.NoSuchUpload{Message: aws.String("The specified multipart upload does not exist: " + uploadID)}
+	}
+	return upload, nil
+}
+
+// UploadPart stores one part of a multipart upload
+func (b *MemoryBackend) UploadPart(_ context.Context, params *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	var data []byte
+	if params.Body != nil {
+		var err error
+		if data, err = io.ReadAll(params.Body); err != nil {
+			return nil, fmt.Errorf("failed to read part body: %w", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	upload, err := b.upload(aws.ToString(params.Bucket), aws.ToString(params.Key), aws.ToString(params.UploadId))
+	if err != nil {
+		return nil, err
+	}
+	part := &memoryObject{data: data, etag: memoryETag(data), lastModified: time.Now().UTC()}
+	upload.parts[aws.ToInt32(params.PartNumber)] = part
+	return &s3.UploadPartOutput{ETag: aws.String(part.etag)}, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts into the object
+func (b *MemoryBackend) CompleteMultipartUpload(_ context.Context, params *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	uploadID := aws.ToString(params.UploadId)
+	upload, err := b.upload(aws.ToString(params.Bucket), aws.ToString(params.Key), uploadID)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := b.bucket(upload.bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMemoryWriteConditions(bucket.objects[upload.key], params.IfMatch, params.IfNoneMatch); err != nil {
+		return nil, err
+	}
+
+	var completed []types.CompletedPart
+	if params.MultipartUpload != nil {
+		completed = params.MultipartUpload.Parts
+	}
+	var data []byte
+	digests := md5.New() // #nosec G401 - S3 ETags are MD5 digests
+	for _, completedPart := range completed {
+		part, ok := upload.parts[aws.ToInt32(completedPart.PartNumber)]
+		if !ok {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart", Message: fmt.Sprintf("Part %d has not been uploaded", aws.ToInt32(completedPart.PartNumber))}
+		}
+		data = append(data, part.data...)
+		digest, _ := hex.DecodeString(strings.Trim(part.etag, `"`))
+		digests.Write(digest)
+	}
+
+	obj := &memoryObject{
+		data:         data,
+		metadata:     upload.metadata,
+		contentType:  upload.contentType,
+		etag:         fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(digests.Sum(nil)), len(completed)),
+		lastModified: time.Now().UTC(),
+	}
+	bucket.objects[upload.key] = obj
+	delete(b.uploads, uploadID)
+	b.trace("CompleteMultipartUpload", upload.bucket, upload.key, obj)
+
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:   params.Bucket,
+		Key:      params.Key,
+		ETag:     aws.String(obj.etag),
+		Location: aws.String("/" + upload.bucket + "/" + upload.key),
+	}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (b *MemoryBackend) AbortMultipartUpload(_ context.Context, params *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	uploadID := aws.ToString(params.UploadId)
+	if _, err := b.upload(aws.ToString(params.Bucket), aws.ToString(params.Key), uploadID); err != nil {
+		return nil, err
+	}
+	delete(b.uploads, uploadID)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// ListParts lists the uploaded parts of a multipart upload
+func (b *MemoryBackend) ListParts(_ context.Context, params *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	upload, err := b.upload(aws.ToString(params.Bucket), aws.ToString(params.Key), aws.ToString(params.UploadId))
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.ListPartsOutput{Bucket: params.Bucket, Key: params.Key, UploadId: params.UploadId, IsTruncated: aws.Bool(false)}
+	for _, number := range slices.Sorted(maps.Keys(upload.parts)) {
+		part := upload.parts[number]
+		output.Parts = append(output.Parts, types.Part{
+			PartNumber:   aws.Int32(number),
+			ETag:         aws.String(part.etag),
+			Size:         aws.Int64(int64(len(part.data))),
+			LastModified: aws.Time(part.lastModified),
+		})
+	}
+	return output, nil
+}
+
+// ListMultipartUploads lists the multipart uploads in progress in a bucket
+func (b *MemoryBackend) ListMultipartUploads(_ context.Context, params *s3.ListMultipartUploadsInput, _ ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bucketName := aws.ToString(params.Bucket)
+	if _, err := b.bucket(bucketName); err != nil {
+		return nil, err
+	}
+	output := &s3.ListMultipartUploadsOutput{Bucket: params.Bucket, Prefix: params.Prefix, IsTruncated: aws.Bool(false)}
+	for _, uploadID := range slices.Sorted(maps.Keys(b.uploads)) {
+		upload := b.uploads[uploadID]
+		if upload.bucket != bucketName || !strings.HasPrefix(upload.key, aws.ToString(params.Prefix)) {
+			continue
+		}
+		output.Uploads = append(output.Uploads, types.MultipartUpload{
+			Key:          aws.String(upload.key),
+			UploadId:     aws.String(uploadID),
+			Initiated:    aws.Time(upload.initiated),
+			StorageClass: types.StorageClassStandard,
+		})
+	}
+	slices.SortStableFunc(output.Uploads, func(a, b types.MultipartUpload) int {
+		return strings.Compare(aws.ToString(a.Key), aws.ToString(b.Key))
+	})
+	return output, nil
+}
+
+// GetObjectTagging returns the tags of an object
+func (b *MemoryBackend) GetObjectTagging(_ context.Context, params *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, err := b.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectTaggingOutput{TagSet: slices.Clone(obj.tags)}, nil
+}
+
+// PutObjectTagging replaces the tags of an object
+func (b *MemoryBackend) PutObjectTagging(_ context.Context, params *s3.PutObjectTaggingInput, _ ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	obj, err := b.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if err != nil {
+		return nil, err
+	}
+	obj.tags = nil
+	if params.Tagging != nil {
+		obj.tags = slices.Clone(params.Tagging.TagSet)
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+// DeleteObjectTagging removes all tags of an object
+func (b *MemoryBackend) DeleteObjectTagging(_ context.Context, params *s3.DeleteObjectTaggingInput, _ ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	obj, err := b.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if err != nil {
+		return nil, err
+	}
+	obj.tags = nil
+	return &s3.DeleteObjectTaggingOutput{}, nil
+}
+
+var _ interfaces.S3BackendInterface = (*MemoryBackend)(nil)
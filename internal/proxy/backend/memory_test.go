@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryBackend(t *testing.T, keys ...string) *MemoryBackend {
+	t.Helper()
+	b := NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	ctx := context.Background()
+	_, err := b.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	for _, key := range keys {
+		_, err := b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String(key), Body: strings.NewReader(key)})
+		require.NoError(t, err)
+	}
+	return b
+}
+
+func readBody(t *testing.T, output *s3.GetObjectOutput) string {
+	t.Helper()
+	data, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMemoryBackend_ObjectRoundTrip(t *testing.T) {
+	b := newTestMemoryBackend(t)
+	ctx := context.Background()
+
+	put, err := b.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Key:         aws.String("dir/file.txt"),
+		Body:        strings.NewReader("hello world"),
+		ContentType: aws.String("text/plain"),
+		Metadata:    map[string]string{"s3ep-dek-algorithm": "aes-gcm"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `"5eb63bbbe01eeed093cb22bb8f5acdc3"`, aws.ToString(put.ETag))
+
+	get, err := b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", readBody(t, get))
+	assert.Equal(t, "text/plain", aws.ToString(get.ContentType))
+	assert.Equal(t, "aes-gcm", get.Metadata["s3ep-dek-algorithm"])
+
+	head, err := b.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), aws.ToInt64(head.ContentLength))
+
+	_, err = b.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file.txt")})
+	require.NoError(t, err)
+	_, err = b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/file.txt")})
+	var noSuchKey *types.NoSuchKey
+	assert.ErrorAs(t, err, &noSuchKey)
+
+	_, err = b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("missing"), Key: aws.String("a")})
+	var noSuchBucket *types.NoSuchBucket
+	assert.ErrorAs(t, err, &noSuchBucket)
+}
+
+func TestMemoryBackend_Range(t *testing.T) {
+	b := newTestMemoryBackend(t, "0123456789")
+	tests := []struct {
+		header       string
+		body         string
+		contentRange string
+	}{
+		{header: "bytes=2-4", body: "234", contentRange: "bytes 2-4/10"},
+		{header: "bytes=7-", body: "789", contentRange: "bytes 7-9/10"},
+		{header: "bytes=-3", body: "789", contentRange: "bytes 7-9/10"},
+		{header: "bytes=8-100", body: "89", contentRange: "bytes 8-9/10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			get, err := b.GetObject(context.Background(), &s3.GetObjectInput{
+				Bucket: aws.String("bucket"), Key: aws.String("0123456789"), Range: aws.String(tt.header),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, readBody(t, get))
+			assert.Equal(t, tt.contentRange, aws.ToString(get.ContentRange))
+		})
+	}
+
+	_, err := b.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String("bucket"), Key: aws.String("0123456789"), Range: aws.String("bytes=10-"),
+	})
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "InvalidRange", apiErr.ErrorCode())
+}
+
+func TestMemoryBackend_Conditions(t *testing.T) {
+	b := newTestMemoryBackend(t, "a")
+	ctx := context.Background()
+	etag := `"0cc175b9c0f1b6a831c399e269772661"`
+	errorCode := func(err error) string {
+		var apiErr smithy.APIError
+		require.ErrorAs(t, err, &apiErr)
+		return apiErr.ErrorCode()
+	}
+
+	_, err := b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), IfMatch: aws.String(etag)})
+	require.NoError(t, err)
+	_, err = b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), IfMatch: aws.String(`"other"`)})
+	assert.Equal(t, "PreconditionFailed", errorCode(err))
+	_, err = b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), IfNoneMatch: aws.String(etag)})
+	assert.Equal(t, "NotModified", errorCode(err))
+
+	// Create-only writes fail once the key exists
+	_, err = b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), Body: strings.NewReader("b"), IfNoneMatch: aws.String("*")})
+	assert.Equal(t, "PreconditionFailed", errorCode(err))
+	_, err = b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("new"), Body: strings.NewReader("b"), IfNoneMatch: aws.String("*")})
+	require.NoError(t, err)
+
+	// Compare-and-swap writes need the current ETag
+	_, err = b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), Body: strings.NewReader("b"), IfMatch: aws.String(`"other"`)})
+	assert.Equal(t, "PreconditionFailed", errorCode(err))
+	_, err = b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a"), Body: strings.NewReader("b"), IfMatch: aws.String(etag)})
+	require.NoError(t, err)
+	_, err = b.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("missing"), Body: strings.NewReader("b"), IfMatch: aws.String(etag)})
+	var noSuchKey *types.NoSuchKey
+	assert.ErrorAs(t, err, &noSuchKey)
+}
+
+func TestMemoryBackend_ListObjectsV2(t *testing.T) {
+	b := newTestMemoryBackend(t, "a/1", "a/2", "b/1", "c", "d")
+	ctx := context.Background()
+
+	list, err := b.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("bucket"), Delimiter: aws.String("/")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, objectKeys(list.Contents))
+	assert.Equal(t, []string{"a/", "b/"}, commonPrefixes(list.CommonPrefixes))
+
+	// Pages of two, continuing after common prefixes
+	var keys []string
+	input := &s3.ListObjectsV2Input{Bucket: aws.String("bucket"), Delimiter: aws.String("/"), MaxKeys: aws.Int32(2)}
+	for {
+		page, err := b.ListObjectsV2(ctx, input)
+		require.NoError(t, err)
+		keys = append(keys, commonPrefixes(page.CommonPrefixes)...)
+		keys = append(keys, objectKeys(page.Contents)...)
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+	assert.ElementsMatch(t, []string{"a/", "b/", "c", "d"}, keys)
+
+	list, err = b.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("bucket"), Prefix: aws.String("a/")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/1", "a/2"}, objectKeys(list.Contents))
+}
+
+func TestMemoryBackend_CopyObject(t *testing.T) {
+	b := newTestMemoryBackend(t, "source")
+	ctx := context.Background()
+	source, err := b.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("source")})
+	require.NoError(t, err)
+
+	_, err = b.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("copy"),
+		CopySource:        aws.String("bucket/source"),
+		CopySourceIfMatch: source.ETag,
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata:          map[string]string{"s3ep-plaintext-size": "6"},
+	})
+	require.NoError(t, err)
+	get, err := b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("copy")})
+	require.NoError(t, err)
+	assert.Equal(t, "source", readBody(t, get))
+	assert.Equal(t, "6", get.Metadata["s3ep-plaintext-size"])
+
+	_, err = b.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("copy"),
+		CopySource:        aws.String("bucket/source"),
+		CopySourceIfMatch: aws.String(`"stale"`),
+	})
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "PreconditionFailed", apiErr.ErrorCode())
+}
+
+func TestMemoryBackend_MultipartUpload(t *testing.T) {
+	b := newTestMemoryBackend(t)
+	ctx := context.Background()
+
+	created, err := b.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("large"),
+		Metadata: map[string]string{"s3ep-dek-algorithm": "aes-ctr"},
+	})
+	require.NoError(t, err)
+
+	var parts []types.CompletedPart
+	for i, data := range []string{"part-one|", "part-two"} {
+		number := aws.Int32(int32(i + 1)) // #nosec G115 - test loop index
+		uploaded, err := b.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket: aws.String("bucket"), Key: aws.String("large"), UploadId: created.UploadId,
+			PartNumber: number, Body: strings.NewReader(data),
+		})
+		require.NoError(t, err)
+		parts = append(parts, types.CompletedPart{PartNumber: number, ETag: uploaded.ETag})
+	}
+
+	listed, err := b.ListParts(ctx, &s3.ListPartsInput{Bucket: aws.String("bucket"), Key: aws.String("large"), UploadId: created.UploadId})
+	require.NoError(t, err)
+	assert.Len(t, listed.Parts, 2)
+
+	completed, err := b.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: aws.String("bucket"), Key: aws.String("large"), UploadId: created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(aws.ToString(completed.ETag), `-2"`))
+
+	get, err := b.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("large")})
+	require.NoError(t, err)
+	assert.Equal(t, "part-one|part-two", readBody(t, get))
+	assert.Equal(t, "aes-ctr", get.Metadata["s3ep-dek-algorithm"])
+
+	_, err = b.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("large"), UploadId: created.UploadId})
+	var noSuchUpload *types.NoSuchUpload
+	assert.ErrorAs(t, err, &noSuchUpload, "completed uploads are gone")
+}
+
+func TestMemoryBackend_UnsupportedOperation(t *testing.T) {
+	b := newTestMemoryBackend(t)
+	_, err := b.GetBucketPolicy(context.Background(), &s3.GetBucketPolicyInput{Bucket: aws.String("bucket")})
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "NotImplemented", apiErr.ErrorCode())
+}
+
+func objectKeys(objects []types.Object) []string {
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, aws.ToString(object.Key))
+	}
+	return keys
+}
+
+func commonPrefixes(prefixes []types.CommonPrefix) []string {
+	values := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		values = append(values, aws.ToString(prefix.Prefix))
+	}
+	return values
+}
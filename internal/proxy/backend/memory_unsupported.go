@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// GetBucketAcl is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketAcl(context.Context, *s3.GetBucketAclInput, ...func(*s3.Options)) (*s3.GetBucketAclOutput, error) {
+	return notImplemented[s3.GetBucketAclOutput]("GetBucketAcl")
+}
+
+// PutBucketAcl is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketAcl(context.Context, *s3.PutBucketAclInput, ...func(*s3.Options)) (*s3.PutBucketAclOutput, error) {
+	return notImplemented[s3.PutBucketAclOutput]("PutBucketAcl")
+}
+
+// GetBucketCors is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketCors(context.Context, *s3.GetBucketCorsInput, ...func(*s3.Options)) (*s3.GetBucketCorsOutput, error) {
+	return notImplemented[s3.GetBucketCorsOutput]("GetBucketCors")
+}
+
+// PutBucketCors is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketCors(context.Context, *s3.PutBucketCorsInput, ...func(*s3.Options)) (*s3.PutBucketCorsOutput, error) {
+	return notImplemented[s3.PutBucketCorsOutput]("PutBucketCors")
+}
+
+// DeleteBucketCors is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketCors(context.Context, *s3.DeleteBucketCorsInput, ...func(*s3.Options)) (*s3.DeleteBucketCorsOutput, error) {
+	return notImplemented[s3.DeleteBucketCorsOutput]("DeleteBucketCors")
+}
+
+// GetBucketVersioning is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketVersioning(context.Context, *s3.GetBucketVersioningInput, ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return notImplemented[s3.GetBucketVersioningOutput]("GetBucketVersioning")
+}
+
+// PutBucketVersioning is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketVersioning(context.Context, *s3.PutBucketVersioningInput, ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	return notImplemented[s3.PutBucketVersioningOutput]("PutBucketVersioning")
+}
+
+// GetBucketAccelerateConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketAccelerateConfiguration(context.Context, *s3.GetBucketAccelerateConfigurationInput, ...func(*s3.Options)) (*s3.GetBucketAccelerateConfigurationOutput, error) {
+	return notImplemented[s3.GetBucketAccelerateConfigurationOutput]("GetBucketAccelerateConfiguration")
+}
+
+// PutBucketAccelerateConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketAccelerateConfiguration(context.Context, *s3.PutBucketAccelerateConfigurationInput, ...func(*s3.Options)) (*s3.PutBucketAccelerateConfigurationOutput, error) {
+	return notImplemented[s3.PutBucketAccelerateConfigurationOutput]("PutBucketAccelerateConfiguration")
+}
+
+// GetBucketRequestPayment is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketRequestPayment(context.Context, *s3.GetBucketRequestPaymentInput, ...func(*s3.Options)) (*s3.GetBucketRequestPaymentOutput, error) {
+	return notImplemented[s3.GetBucketRequestPaymentOutput]("GetBucketRequestPayment")
+}
+
+// PutBucketRequestPayment is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketRequestPayment(context.Context, *s3.PutBucketRequestPaymentInput, ...func(*s3.Options)) (*s3.PutBucketRequestPaymentOutput, error) {
+	return notImplemented[s3.PutBucketRequestPaymentOutput]("PutBucketRequestPayment")
+}
+
+// GetBucketTagging is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketTagging(context.Context, *s3.GetBucketTaggingInput, ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	return notImplemented[s3.GetBucketTaggingOutput]("GetBucketTagging")
+}
+
+// PutBucketTagging is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketTagging(context.Context, *s3.PutBucketTaggingInput, ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	return notImplemented[s3.PutBucketTaggingOutput]("PutBucketTagging")
+}
+
+// DeleteBucketTagging is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketTagging(context.Context, *s3.DeleteBucketTaggingInput, ...func(*s3.Options)) (*s3.DeleteBucketTaggingOutput, error) {
+	return notImplemented[s3.DeleteBucketTaggingOutput]("DeleteBucketTagging")
+}
+
+// GetBucketNotificationConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketNotificationConfiguration(context.Context, *s3.GetBucketNotificationConfigurationInput, ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	return notImplemented[s3.GetBucketNotificationConfigurationOutput]("GetBucketNotificationConfiguration")
+}
+
+// PutBucketNotificationConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketNotificationConfiguration(context.Context, *s3.PutBucketNotificationConfigurationInput, ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	return notImplemented[s3.PutBucketNotificationConfigurationOutput]("PutBucketNotificationConfiguration")
+}
+
+// GetBucketLifecycleConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketLifecycleConfiguration(context.Context, *s3.GetBucketLifecycleConfigurationInput, ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return notImplemented[s3.GetBucketLifecycleConfigurationOutput]("GetBucketLifecycleConfiguration")
+}
+
+// PutBucketLifecycleConfiguration is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketLifecycleConfiguration(context.Context, *s3.PutBucketLifecycleConfigurationInput, ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	return notImplemented[s3.PutBucketLifecycleConfigurationOutput]("PutBucketLifecycleConfiguration")
+}
+
+// DeleteBucketLifecycle is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketLifecycle(context.Context, *s3.DeleteBucketLifecycleInput, ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	return notImplemented[s3.DeleteBucketLifecycleOutput]("DeleteBucketLifecycle")
+}
+
+// GetBucketReplication is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketReplication(context.Context, *s3.GetBucketReplicationInput, ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	return notImplemented[s3.GetBucketReplicationOutput]("GetBucketReplication")
+}
+
+// PutBucketReplication is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketReplication(context.Context, *s3.PutBucketReplicationInput, ...func(*s3.Options)) (*s3.PutBucketReplicationOutput, error) {
+	return notImplemented[s3.PutBucketReplicationOutput]("PutBucketReplication")
+}
+
+// DeleteBucketReplication is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketReplication(context.Context, *s3.DeleteBucketReplicationInput, ...func(*s3.Options)) (*s3.DeleteBucketReplicationOutput, error) {
+	return notImplemented[s3.DeleteBucketReplicationOutput]("DeleteBucketReplication")
+}
+
+// GetBucketWebsite is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketWebsite(context.Context, *s3.GetBucketWebsiteInput, ...func(*s3.Options)) (*s3.GetBucketWebsiteOutput, error) {
+	return notImplemented[s3.GetBucketWebsiteOutput]("GetBucketWebsite")
+}
+
+// PutBucketWebsite is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketWebsite(context.Context, *s3.PutBucketWebsiteInput, ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error) {
+	return notImplemented[s3.PutBucketWebsiteOutput]("PutBucketWebsite")
+}
+
+// DeleteBucketWebsite is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketWebsite(context.Context, *s3.DeleteBucketWebsiteInput, ...func(*s3.Options)) (*s3.DeleteBucketWebsiteOutput, error) {
+	return notImplemented[s3.DeleteBucketWebsiteOutput]("DeleteBucketWebsite")
+}
+
+// GetBucketLogging is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketLogging(context.Context, *s3.GetBucketLoggingInput, ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	return notImplemented[s3.GetBucketLoggingOutput]("GetBucketLogging")
+}
+
+// PutBucketLogging is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketLogging(context.Context, *s3.PutBucketLoggingInput, ...func(*s3.Options)) (*s3.PutBucketLoggingOutput, error) {
+	return notImplemented[s3.PutBucketLoggingOutput]("PutBucketLogging")
+}
+
+// GetBucketPolicy is not supported by the in-memory backend
+func (b *MemoryBackend) GetBucketPolicy(context.Context, *s3.GetBucketPolicyInput, ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	return notImplemented[s3.GetBucketPolicyOutput]("GetBucketPolicy")
+}
+
+// PutBucketPolicy is not supported by the in-memory backend
+func (b *MemoryBackend) PutBucketPolicy(context.Context, *s3.PutBucketPolicyInput, ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	return notImplemented[s3.PutBucketPolicyOutput]("PutBucketPolicy")
+}
+
+// DeleteBucketPolicy is not supported by the in-memory backend
+func (b *MemoryBackend) DeleteBucketPolicy(context.Context, *s3.DeleteBucketPolicyInput, ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+	return notImplemented[s3.DeleteBucketPolicyOutput]("DeleteBucketPolicy")
+}
+
+// GetObjectAcl is not supported by the in-memory backend
+func (b *MemoryBackend) GetObjectAcl(context.Context, *s3.GetObjectAclInput, ...func(*s3.Options)) (*s3.GetObjectAclOutput, error) {
+	return notImplemented[s3.GetObjectAclOutput]("GetObjectAcl")
+}
+
+// PutObjectAcl is not supported by the in-memory backend
+func (b *MemoryBackend) PutObjectAcl(context.Context, *s3.PutObjectAclInput, ...func(*s3.Options)) (*s3.PutObjectAclOutput, error) {
+	return notImplemented[s3.PutObjectAclOutput]("PutObjectAcl")
+}
+
+// ListObjectVersions is not supported by the in-memory backend, which
+// keeps no versions
+func (b *MemoryBackend) ListObjectVersions(context.Context, *s3.ListObjectVersionsInput, ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	return notImplemented[s3.ListObjectVersionsOutput]("ListObjectVersions")
+}
+
+// GetObjectLegalHold is not supported by the in-memory backend
+func (b *MemoryBackend) GetObjectLegalHold(context.Context, *s3.GetObjectLegalHoldInput, ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+	return notImplemented[s3.GetObjectLegalHoldOutput]("GetObjectLegalHold")
+}
+
+// PutObjectLegalHold is not supported by the in-memory backend
+func (b *MemoryBackend) PutObjectLegalHold(context.Context, *s3.PutObjectLegalHoldInput, ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+	return notImplemented[s3.PutObjectLegalHoldOutput]("PutObjectLegalHold")
+}
+
+// GetObjectRetention is not supported by the in-memory backend
+func (b *MemoryBackend) GetObjectRetention(context.Context, *s3.GetObjectRetentionInput, ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+	return notImplemented[s3.GetObjectRetentionOutput]("GetObjectRetention")
+}
+
+// PutObjectRetention is not supported by the in-memory backend
+func (b *MemoryBackend) PutObjectRetention(context.Context, *s3.PutObjectRetentionInput, ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+	return notImplemented[s3.PutObjectRetentionOutput]("PutObjectRetention")
+}
+
+// GetObjectTorrent is not supported by the in-memory backend
+func (b *MemoryBackend) GetObjectTorrent(context.Context, *s3.GetObjectTorrentInput, ...func(*s3.Options)) (*s3.GetObjectTorrentOutput, error) {
+	return notImplemented[s3.GetObjectTorrentOutput]("GetObjectTorrent")
+}
+
+// SelectObjectContent is not supported by the in-memory backend
+func (b *MemoryBackend) SelectObjectContent(context.Context, *s3.SelectObjectContentInput, ...func(*s3.Options)) (*s3.SelectObjectContentOutput, error) {
+	return notImplemented[s3.SelectObjectContentOutput]("SelectObjectContent")
+}
@@ -0,0 +1,241 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// Suffixes of the keys a split metadata value is stored under: <key>-chunks
+// holds the number of chunks, <key>-chunk-1 to <key>-chunk-<n> the chunks
+const (
+	chunkCountSuffix = "-chunks"
+	chunkSuffix      = "-chunk-"
+)
+
+// ErrMetadataChunks is returned for objects whose split encryption metadata
+// cannot be joined again
+var ErrMetadataChunks = errors.New("split metadata value of the object is incomplete")
+
+// MetadataTooLargeError is returned for writes whose user metadata exceeds
+// encryption.metadata_limits.max_size after sidecars and splitting
+type MetadataTooLargeError struct {
+	Size           int // bytes of user metadata, keys and values
+	EncryptionSize int // bytes of it that are encryption metadata
+	Limit          int
+}
+
+func (e *MetadataTooLargeError) Error() string {
+	msg := fmt.Sprintf("user metadata of %d bytes exceeds encryption.metadata_limits.max_size of %d bytes", e.Size, e.Limit)
+	if e.EncryptionSize > 0 {
+		msg += fmt.Sprintf(", %d bytes of it encryption metadata that encryption.metadata_sidecar can move off the object", e.EncryptionSize)
+	}
+	return msg
+}
+
+// MetadataLimitStore implements interfaces.S3BackendInterface on top of
+// another backend, keeping written metadata within the limits configured in
+// encryption.metadata_limits:
+//
+//   - PutObject, CopyObject with replaced metadata and CreateMultipartUpload
+//     split encryption metadata values longer than max_value_size across
+//     numbered keys, and are refused with a MetadataTooLargeError if the
+//     metadata then exceeds max_size;
+//   - GetObject and HeadObject join split values again, whatever the current
+//     limits.
+//
+// Other operations go to the wrapped backend unchanged.
+type MetadataLimitStore struct {
+	interfaces.S3BackendInterface
+	config config.MetadataLimitsConfig
+	prefix string   // encryption metadata key prefix
+	legacy []string // earlier encryption metadata key prefixes
+}
+
+// NewMetadataLimitStore wraps next. prefix is the encryption metadata key
+// prefix; only values of keys starting with it are split.
+func NewMetadataLimitStore(next interfaces.S3BackendInterface, cfg config.MetadataLimitsConfig, prefix string) *MetadataLimitStore {
+	return &MetadataLimitStore{
+		S3BackendInterface: next,
+		config:             cfg,
+		prefix:             prefix,
+	}
+}
+
+// SetLegacyPrefixes makes the values split under an earlier encryption
+// metadata key prefix joined too
+func (s *MetadataLimitStore) SetLegacyPrefixes(prefixes []string) {
+	s.legacy = prefixes
+}
+
+// limit returns the metadata to store on the object, with long encryption
+// metadata values split, or a MetadataTooLargeError
+func (s *MetadataLimitStore) limit(metadata map[string]string) (map[string]string, error) {
+	if s.config.MaxValueSize > 0 {
+		metadata = s.split(metadata)
+	}
+	if s.config.MaxSize <= 0 {
+		return metadata, nil
+	}
+
+	size, encryptionSize := 0, 0
+	for k, v := range metadata {
+		size += len(k) + len(v)
+		if strings.HasPrefix(k, s.prefix) {
+			encryptionSize += len(k) + len(v)
+		}
+	}
+	if size > s.config.MaxSize {
+		return nil, &MetadataTooLargeError{Size: size, EncryptionSize: encryptionSize, Limit: s.config.MaxSize}
+	}
+	return metadata, nil
+}
+
+// split returns a copy of metadata with the encryption metadata values
+// longer than max_value_size split across numbered keys, or metadata itself
+// if none is. Values are split at byte boundaries; encryption metadata is
+// ASCII.
+func (s *MetadataLimitStore) split(metadata map[string]string) map[string]string {
+	var split map[string]string
+	for k, v := range metadata {
+		if len(v) <= s.config.MaxValueSize || !strings.HasPrefix(k, s.prefix) {
+			continue
+		}
+		if split == nil {
+			split = maps.Clone(metadata)
+		}
+		delete(split, k)
+		n := 0
+		for ; len(v) > 0; n++ {
+			chunk := v[:min(len(v), s.config.MaxValueSize)]
+			split[k+chunkSuffix+strconv.Itoa(n+1)] = chunk
+			v = v[len(chunk):]
+		}
+		split[k+chunkCountSuffix] = strconv.Itoa(n)
+	}
+	if split == nil {
+		return metadata
+	}
+	return split
+}
+
+// isEncryptionKey reports whether key is encryption metadata under the
+// current or a legacy prefix
+func (s *MetadataLimitStore) isEncryptionKey(key string) bool {
+	if len(key) >= len(s.prefix) && strings.EqualFold(key[:len(s.prefix)], s.prefix) {
+		return true
+	}
+	for _, prefix := range s.legacy {
+		if len(key) >= len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// join replaces the chunks of split values in metadata with the values.
+// Keys are matched case-insensitively, as backends differ in the case they
+// return keys in.
+func (s *MetadataLimitStore) join(metadata map[string]string) error {
+	var counts []string
+	keys := make(map[string]string, len(metadata))
+	for k := range metadata {
+		keys[strings.ToLower(k)] = k
+		if len(k) > len(chunkCountSuffix) && strings.EqualFold(k[len(k)-len(chunkCountSuffix):], chunkCountSuffix) && s.isEncryptionKey(k) {
+			counts = append(counts, k)
+		}
+	}
+
+	for _, countKey := range counts {
+		name := countKey[:len(countKey)-len(chunkCountSuffix)]
+		n, err := strconv.Atoi(metadata[countKey])
+		if err != nil || n < 1 || n > len(metadata) {
+			return fmt.Errorf("%w: invalid chunk count %q of %s", ErrMetadataChunks, metadata[countKey], name)
+		}
+
+		var value strings.Builder
+		for i := 1; i <= n; i++ {
+			chunkKey, ok := keys[strings.ToLower(name+chunkSuffix+strconv.Itoa(i))]
+			if !ok {
+				return fmt.Errorf("%w: chunk %d of %d of %s is missing", ErrMetadataChunks, i, n, name)
+			}
+			value.WriteString(metadata[chunkKey])
+			delete(metadata, chunkKey)
+		}
+		delete(metadata, countKey)
+		metadata[name] = value.String()
+	}
+	return nil
+}
+
+// PutObject stores the object with its metadata kept within the limits
+func (s *MetadataLimitStore) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	metadata, err := s.limit(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	input := *params
+	input.Metadata = metadata
+	return s.S3BackendInterface.PutObject(ctx, &input, optFns...)
+}
+
+// CopyObject keeps replaced metadata within the limits; copied metadata is
+// already
+func (s *MetadataLimitStore) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	if params.MetadataDirective != types.MetadataDirectiveReplace {
+		return s.S3BackendInterface.CopyObject(ctx, params, optFns...)
+	}
+	metadata, err := s.limit(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	input := *params
+	input.Metadata = metadata
+	return s.S3BackendInterface.CopyObject(ctx, &input, optFns...)
+}
+
+// CreateMultipartUpload starts the upload with its metadata kept within the
+// limits
+func (s *MetadataLimitStore) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	metadata, err := s.limit(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	input := *params
+	input.Metadata = metadata
+	return s.S3BackendInterface.CreateMultipartUpload(ctx, &input, optFns...)
+}
+
+// GetObject joins the split metadata values of the object
+func (s *MetadataLimitStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := s.S3BackendInterface.GetObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.join(out.Metadata); err != nil {
+		_ = out.Body.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// HeadObject joins the split metadata values of the object
+func (s *MetadataLimitStore) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	out, err := s.S3BackendInterface.HeadObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.join(out.Metadata); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestMetadataLimitStore_SplitsLongValues(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewMetadataLimitStore(memory, config.MetadataLimitsConfig{MaxValueSize: 256}, "s3ep-")
+
+	metadata := map[string]string{
+		"s3ep-encrypted-dek": strings.Repeat("a", 256) + strings.Repeat("b", 256) + "c",
+		"s3ep-dek-algorithm": "envelope-rsa",
+		"owner":              strings.Repeat("o", 300),
+	}
+	_, err := store.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("key"),
+		Body:     strings.NewReader("ciphertext"),
+		Metadata: metadata,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"s3ep-encrypted-dek-chunks":  "3",
+		"s3ep-encrypted-dek-chunk-1": strings.Repeat("a", 256),
+		"s3ep-encrypted-dek-chunk-2": strings.Repeat("b", 256),
+		"s3ep-encrypted-dek-chunk-3": "c",
+		"s3ep-dek-algorithm":         "envelope-rsa",
+		"owner":                      strings.Repeat("o", 300),
+	}, headMetadata(t, memory, "bucket", "key"))
+	assert.Equal(t, metadata, headMetadata(t, store, "bucket", "key"))
+
+	out, err := store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	require.NoError(t, err)
+	_ = out.Body.Close()
+	assert.Equal(t, metadata, out.Metadata)
+
+	// Split values are joined without limits too
+	assert.Equal(t, metadata, headMetadata(t, NewMetadataLimitStore(memory, config.MetadataLimitsConfig{}, "s3ep-"), "bucket", "key"))
+}
+
+func TestMetadataLimitStore_JoinsCaseInsensitiveAndLegacyKeys(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	putMemoryObject(t, memory, "key", map[string]string{
+		"Old-Encrypted-Dek-Chunks":  "2",
+		"old-encrypted-dek-chunk-1": "wrapped-",
+		"OLD-ENCRYPTED-DEK-CHUNK-2": "dek",
+		"report-chunks":             "2",
+	})
+
+	store := NewMetadataLimitStore(memory, config.MetadataLimitsConfig{MaxValueSize: 64}, "s3ep-")
+	store.SetLegacyPrefixes([]string{"old-"})
+	assert.Equal(t, map[string]string{
+		"Old-Encrypted-Dek": "wrapped-dek",
+		"report-chunks":     "2",
+	}, headMetadata(t, store, "bucket", "key"))
+}
+
+func TestMetadataLimitStore_MissingChunk(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	putMemoryObject(t, memory, "key", map[string]string{
+		"s3ep-encrypted-dek-chunks":  "2",
+		"s3ep-encrypted-dek-chunk-1": "wrapped-",
+	})
+	store := NewMetadataLimitStore(memory, config.MetadataLimitsConfig{}, "s3ep-")
+
+	_, err := store.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.ErrorIs(t, err, ErrMetadataChunks)
+	assert.ErrorContains(t, err, "chunk 2 of 2")
+	_, err = store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.ErrorIs(t, err, ErrMetadataChunks)
+}
+
+func TestMetadataLimitStore_RefusesOversizeMetadata(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	store := NewMetadataLimitStore(memory, config.MetadataLimitsConfig{MaxSize: 1024, MaxValueSize: 512}, "s3ep-")
+	ctx := context.Background()
+
+	_, err := store.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("key"),
+		Body:     strings.NewReader("ciphertext"),
+		Metadata: map[string]string{"s3ep-encrypted-dek": strings.Repeat("k", 900), "owner": strings.Repeat("o", 200)},
+	})
+	var tooLarge *MetadataTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Greater(t, tooLarge.Size, 1024)
+	assert.Greater(t, tooLarge.EncryptionSize, 900)
+	assert.Equal(t, 1024, tooLarge.Limit)
+	assert.ErrorContains(t, err, "encryption.metadata_sidecar")
+
+	_, err = memory.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.Error(t, err, "refused writes must not reach the backend")
+
+	_, err = store.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("copy"),
+		CopySource:        aws.String("bucket/source"),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata:          map[string]string{"owner": strings.Repeat("o", 1100)},
+	})
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Zero(t, tooLarge.EncryptionSize)
+
+	_, err = store.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("upload"),
+		Metadata: map[string]string{"owner": strings.Repeat("o", 1100)},
+	})
+	assert.True(t, errors.As(err, &tooLarge))
+}
+
+func TestMetadataLimitStore_SidecarKeepsMetadataWithinLimit(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	limits := NewMetadataLimitStore(memory, config.MetadataLimitsConfig{MaxSize: 256}, "s3ep-")
+	sidecars := NewSidecarStore(limits, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeOversize, InlineLimit: 256}, "s3ep-", logrus.NewEntry(logrus.New()))
+
+	putSidecarObject(t, sidecars, "key", encryptedMetadata())
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, sidecars, "bucket", "key"))
+	assert.Contains(t, headMetadata(t, memory, "bucket", "key"), "s3ep-sidecar")
+}
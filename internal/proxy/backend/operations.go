@@ -0,0 +1,420 @@
+package backend
+
+// Every bucket-scoped operation of interfaces.S3BackendInterface is forwarded to
+// the route of its Bucket parameter. ListBuckets and CopyObject, which span
+// routes, are implemented in router.go.
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// GetBucketAcl forwards to the backend route of params.Bucket
+func (r *Router) GetBucketAcl(ctx context.Context, params *s3.GetBucketAclInput, optFns ...func(*s3.Options)) (*s3.GetBucketAclOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketAcl", func(client interfaces.S3BackendInterface) (*s3.GetBucketAclOutput, error) {
+		return client.GetBucketAcl(ctx, params, optFns...)
+	})
+}
+
+// PutBucketAcl forwards to the backend route of params.Bucket
+func (r *Router) PutBucketAcl(ctx context.Context, params *s3.PutBucketAclInput, optFns ...func(*s3.Options)) (*s3.PutBucketAclOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketAcl", func(client interfaces.S3BackendInterface) (*s3.PutBucketAclOutput, error) {
+		return client.PutBucketAcl(ctx, params, optFns...)
+	})
+}
+
+// GetBucketCors forwards to the backend route of params.Bucket
+func (r *Router) GetBucketCors(ctx context.Context, params *s3.GetBucketCorsInput, optFns ...func(*s3.Options)) (*s3.GetBucketCorsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketCors", func(client interfaces.S3BackendInterface) (*s3.GetBucketCorsOutput, error) {
+		return client.GetBucketCors(ctx, params, optFns...)
+	})
+}
+
+// PutBucketCors forwards to the backend route of params.Bucket
+func (r *Router) PutBucketCors(ctx context.Context, params *s3.PutBucketCorsInput, optFns ...func(*s3.Options)) (*s3.PutBucketCorsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketCors", func(client interfaces.S3BackendInterface) (*s3.PutBucketCorsOutput, error) {
+		return client.PutBucketCors(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketCors forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketCors(ctx context.Context, params *s3.DeleteBucketCorsInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketCorsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketCors", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketCorsOutput, error) {
+		return client.DeleteBucketCors(ctx, params, optFns...)
+	})
+}
+
+// GetBucketVersioning forwards to the backend route of params.Bucket
+func (r *Router) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketVersioning", func(client interfaces.S3BackendInterface) (*s3.GetBucketVersioningOutput, error) {
+		return client.GetBucketVersioning(ctx, params, optFns...)
+	})
+}
+
+// PutBucketVersioning forwards to the backend route of params.Bucket
+func (r *Router) PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketVersioning", func(client interfaces.S3BackendInterface) (*s3.PutBucketVersioningOutput, error) {
+		return client.PutBucketVersioning(ctx, params, optFns...)
+	})
+}
+
+// GetBucketAccelerateConfiguration forwards to the backend route of params.Bucket
+func (r *Router) GetBucketAccelerateConfiguration(ctx context.Context, params *s3.GetBucketAccelerateConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketAccelerateConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketAccelerateConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketAccelerateConfigurationOutput, error) {
+		return client.GetBucketAccelerateConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketAccelerateConfiguration forwards to the backend route of params.Bucket
+func (r *Router) PutBucketAccelerateConfiguration(ctx context.Context, params *s3.PutBucketAccelerateConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketAccelerateConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketAccelerateConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketAccelerateConfigurationOutput, error) {
+		return client.PutBucketAccelerateConfiguration(ctx, params, optFns...)
+	})
+}
+
+// GetBucketRequestPayment forwards to the backend route of params.Bucket
+func (r *Router) GetBucketRequestPayment(ctx context.Context, params *s3.GetBucketRequestPaymentInput, optFns ...func(*s3.Options)) (*s3.GetBucketRequestPaymentOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketRequestPayment", func(client interfaces.S3BackendInterface) (*s3.GetBucketRequestPaymentOutput, error) {
+		return client.GetBucketRequestPayment(ctx, params, optFns...)
+	})
+}
+
+// PutBucketRequestPayment forwards to the backend route of params.Bucket
+func (r *Router) PutBucketRequestPayment(ctx context.Context, params *s3.PutBucketRequestPaymentInput, optFns ...func(*s3.Options)) (*s3.PutBucketRequestPaymentOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketRequestPayment", func(client interfaces.S3BackendInterface) (*s3.PutBucketRequestPaymentOutput, error) {
+		return client.PutBucketRequestPayment(ctx, params, optFns...)
+	})
+}
+
+// GetBucketTagging forwards to the backend route of params.Bucket
+func (r *Router) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketTagging", func(client interfaces.S3BackendInterface) (*s3.GetBucketTaggingOutput, error) {
+		return client.GetBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// PutBucketTagging forwards to the backend route of params.Bucket
+func (r *Router) PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketTagging", func(client interfaces.S3BackendInterface) (*s3.PutBucketTaggingOutput, error) {
+		return client.PutBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketTagging forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketTagging(ctx context.Context, params *s3.DeleteBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketTagging", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketTaggingOutput, error) {
+		return client.DeleteBucketTagging(ctx, params, optFns...)
+	})
+}
+
+// GetBucketNotificationConfiguration forwards to the backend route of params.Bucket
+func (r *Router) GetBucketNotificationConfiguration(ctx context.Context, params *s3.GetBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketNotificationConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketNotificationConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketNotificationConfigurationOutput, error) {
+		return client.GetBucketNotificationConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketNotificationConfiguration forwards to the backend route of params.Bucket
+func (r *Router) PutBucketNotificationConfiguration(ctx context.Context, params *s3.PutBucketNotificationConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketNotificationConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketNotificationConfigurationOutput, error) {
+		return client.PutBucketNotificationConfiguration(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLifecycleConfiguration forwards to the backend route of params.Bucket
+func (r *Router) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketLifecycleConfiguration", func(client interfaces.S3BackendInterface) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+		return client.GetBucketLifecycleConfiguration(ctx, params, optFns...)
+	})
+}
+
+// PutBucketLifecycleConfiguration forwards to the backend route of params.Bucket
+func (r *Router) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketLifecycleConfiguration", func(client interfaces.S3BackendInterface) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+		return client.PutBucketLifecycleConfiguration(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketLifecycle forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketLifecycle(ctx context.Context, params *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketLifecycle", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketLifecycleOutput, error) {
+		return client.DeleteBucketLifecycle(ctx, params, optFns...)
+	})
+}
+
+// GetBucketReplication forwards to the backend route of params.Bucket
+func (r *Router) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketReplication", func(client interfaces.S3BackendInterface) (*s3.GetBucketReplicationOutput, error) {
+		return client.GetBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// PutBucketReplication forwards to the backend route of params.Bucket
+func (r *Router) PutBucketReplication(ctx context.Context, params *s3.PutBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.PutBucketReplicationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketReplication", func(client interfaces.S3BackendInterface) (*s3.PutBucketReplicationOutput, error) {
+		return client.PutBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketReplication forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketReplication(ctx context.Context, params *s3.DeleteBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketReplicationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketReplication", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketReplicationOutput, error) {
+		return client.DeleteBucketReplication(ctx, params, optFns...)
+	})
+}
+
+// GetBucketWebsite forwards to the backend route of params.Bucket
+func (r *Router) GetBucketWebsite(ctx context.Context, params *s3.GetBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.GetBucketWebsiteOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.GetBucketWebsiteOutput, error) {
+		return client.GetBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// PutBucketWebsite forwards to the backend route of params.Bucket
+func (r *Router) PutBucketWebsite(ctx context.Context, params *s3.PutBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.PutBucketWebsiteOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.PutBucketWebsiteOutput, error) {
+		return client.PutBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketWebsite forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketWebsite(ctx context.Context, params *s3.DeleteBucketWebsiteInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketWebsiteOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketWebsite", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketWebsiteOutput, error) {
+		return client.DeleteBucketWebsite(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLocation forwards to the backend route of params.Bucket
+func (r *Router) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketLocation", func(client interfaces.S3BackendInterface) (*s3.GetBucketLocationOutput, error) {
+		return client.GetBucketLocation(ctx, params, optFns...)
+	})
+}
+
+// GetBucketLogging forwards to the backend route of params.Bucket
+func (r *Router) GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketLogging", func(client interfaces.S3BackendInterface) (*s3.GetBucketLoggingOutput, error) {
+		return client.GetBucketLogging(ctx, params, optFns...)
+	})
+}
+
+// PutBucketLogging forwards to the backend route of params.Bucket
+func (r *Router) PutBucketLogging(ctx context.Context, params *s3.PutBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketLoggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketLogging", func(client interfaces.S3BackendInterface) (*s3.PutBucketLoggingOutput, error) {
+		return client.PutBucketLogging(ctx, params, optFns...)
+	})
+}
+
+// GetBucketPolicy forwards to the backend route of params.Bucket
+func (r *Router) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.GetBucketPolicyOutput, error) {
+		return client.GetBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// PutBucketPolicy forwards to the backend route of params.Bucket
+func (r *Router) PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.PutBucketPolicyOutput, error) {
+		return client.PutBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucketPolicy forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucketPolicy(ctx context.Context, params *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucketPolicy", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketPolicyOutput, error) {
+		return client.DeleteBucketPolicy(ctx, params, optFns...)
+	})
+}
+
+// CreateBucket forwards to the backend route of params.Bucket
+func (r *Router) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "CreateBucket", func(client interfaces.S3BackendInterface) (*s3.CreateBucketOutput, error) {
+		return client.CreateBucket(ctx, params, optFns...)
+	})
+}
+
+// DeleteBucket forwards to the backend route of params.Bucket
+func (r *Router) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteBucket", func(client interfaces.S3BackendInterface) (*s3.DeleteBucketOutput, error) {
+		return client.DeleteBucket(ctx, params, optFns...)
+	})
+}
+
+// ListObjectsV2 forwards to the backend route of params.Bucket
+func (r *Router) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return call(r, aws.ToString(params.Bucket), "ListObjectsV2", func(client interfaces.S3BackendInterface) (*s3.ListObjectsV2Output, error) {
+		return client.ListObjectsV2(ctx, params, optFns...)
+	})
+}
+
+// ListObjects forwards to the backend route of params.Bucket
+func (r *Router) ListObjects(ctx context.Context, params *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "ListObjects", func(client interfaces.S3BackendInterface) (*s3.ListObjectsOutput, error) {
+		return client.ListObjects(ctx, params, optFns...)
+	})
+}
+
+// ListObjectVersions forwards to the backend route of params.Bucket
+func (r *Router) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "ListObjectVersions", func(client interfaces.S3BackendInterface) (*s3.ListObjectVersionsOutput, error) {
+		return client.ListObjectVersions(ctx, params, optFns...)
+	})
+}
+
+// GetObject forwards to the backend route of params.Bucket
+func (r *Router) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObject", func(client interfaces.S3BackendInterface) (*s3.GetObjectOutput, error) {
+		return client.GetObject(ctx, params, optFns...)
+	})
+}
+
+// PutObject forwards to the backend route of params.Bucket
+func (r *Router) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutObject", func(client interfaces.S3BackendInterface) (*s3.PutObjectOutput, error) {
+		return client.PutObject(ctx, params, optFns...)
+	})
+}
+
+// DeleteObject forwards to the backend route of params.Bucket
+func (r *Router) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteObject", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectOutput, error) {
+		return client.DeleteObject(ctx, params, optFns...)
+	})
+}
+
+// HeadObject forwards to the backend route of params.Bucket
+func (r *Router) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "HeadObject", func(client interfaces.S3BackendInterface) (*s3.HeadObjectOutput, error) {
+		return client.HeadObject(ctx, params, optFns...)
+	})
+}
+
+// CreateMultipartUpload forwards to the backend route of params.Bucket
+func (r *Router) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "CreateMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.CreateMultipartUploadOutput, error) {
+		return client.CreateMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// UploadPart forwards to the backend route of params.Bucket
+func (r *Router) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "UploadPart", func(client interfaces.S3BackendInterface) (*s3.UploadPartOutput, error) {
+		return client.UploadPart(ctx, params, optFns...)
+	})
+}
+
+// CompleteMultipartUpload forwards to the backend route of params.Bucket
+func (r *Router) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "CompleteMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.CompleteMultipartUploadOutput, error) {
+		return client.CompleteMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// AbortMultipartUpload forwards to the backend route of params.Bucket
+func (r *Router) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "AbortMultipartUpload", func(client interfaces.S3BackendInterface) (*s3.AbortMultipartUploadOutput, error) {
+		return client.AbortMultipartUpload(ctx, params, optFns...)
+	})
+}
+
+// ListParts forwards to the backend route of params.Bucket
+func (r *Router) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "ListParts", func(client interfaces.S3BackendInterface) (*s3.ListPartsOutput, error) {
+		return client.ListParts(ctx, params, optFns...)
+	})
+}
+
+// ListMultipartUploads forwards to the backend route of params.Bucket
+func (r *Router) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "ListMultipartUploads", func(client interfaces.S3BackendInterface) (*s3.ListMultipartUploadsOutput, error) {
+		return client.ListMultipartUploads(ctx, params, optFns...)
+	})
+}
+
+// GetObjectAcl forwards to the backend route of params.Bucket
+func (r *Router) GetObjectAcl(ctx context.Context, params *s3.GetObjectAclInput, optFns ...func(*s3.Options)) (*s3.GetObjectAclOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObjectAcl", func(client interfaces.S3BackendInterface) (*s3.GetObjectAclOutput, error) {
+		return client.GetObjectAcl(ctx, params, optFns...)
+	})
+}
+
+// PutObjectAcl forwards to the backend route of params.Bucket
+func (r *Router) PutObjectAcl(ctx context.Context, params *s3.PutObjectAclInput, optFns ...func(*s3.Options)) (*s3.PutObjectAclOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutObjectAcl", func(client interfaces.S3BackendInterface) (*s3.PutObjectAclOutput, error) {
+		return client.PutObjectAcl(ctx, params, optFns...)
+	})
+}
+
+// GetObjectTagging forwards to the backend route of params.Bucket
+func (r *Router) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObjectTagging", func(client interfaces.S3BackendInterface) (*s3.GetObjectTaggingOutput, error) {
+		return client.GetObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// PutObjectTagging forwards to the backend route of params.Bucket
+func (r *Router) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutObjectTagging", func(client interfaces.S3BackendInterface) (*s3.PutObjectTaggingOutput, error) {
+		return client.PutObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteObjectTagging forwards to the backend route of params.Bucket
+func (r *Router) DeleteObjectTagging(ctx context.Context, params *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteObjectTagging", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectTaggingOutput, error) {
+		return client.DeleteObjectTagging(ctx, params, optFns...)
+	})
+}
+
+// DeleteObjects forwards to the backend route of params.Bucket
+func (r *Router) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "DeleteObjects", func(client interfaces.S3BackendInterface) (*s3.DeleteObjectsOutput, error) {
+		return client.DeleteObjects(ctx, params, optFns...)
+	})
+}
+
+// GetObjectLegalHold forwards to the backend route of params.Bucket
+func (r *Router) GetObjectLegalHold(ctx context.Context, params *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObjectLegalHold", func(client interfaces.S3BackendInterface) (*s3.GetObjectLegalHoldOutput, error) {
+		return client.GetObjectLegalHold(ctx, params, optFns...)
+	})
+}
+
+// PutObjectLegalHold forwards to the backend route of params.Bucket
+func (r *Router) PutObjectLegalHold(ctx context.Context, params *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutObjectLegalHold", func(client interfaces.S3BackendInterface) (*s3.PutObjectLegalHoldOutput, error) {
+		return client.PutObjectLegalHold(ctx, params, optFns...)
+	})
+}
+
+// GetObjectRetention forwards to the backend route of params.Bucket
+func (r *Router) GetObjectRetention(ctx context.Context, params *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObjectRetention", func(client interfaces.S3BackendInterface) (*s3.GetObjectRetentionOutput, error) {
+		return client.GetObjectRetention(ctx, params, optFns...)
+	})
+}
+
+// PutObjectRetention forwards to the backend route of params.Bucket
+func (r *Router) PutObjectRetention(ctx context.Context, params *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "PutObjectRetention", func(client interfaces.S3BackendInterface) (*s3.PutObjectRetentionOutput, error) {
+		return client.PutObjectRetention(ctx, params, optFns...)
+	})
+}
+
+// GetObjectTorrent forwards to the backend route of params.Bucket
+func (r *Router) GetObjectTorrent(ctx context.Context, params *s3.GetObjectTorrentInput, optFns ...func(*s3.Options)) (*s3.GetObjectTorrentOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "GetObjectTorrent", func(client interfaces.S3BackendInterface) (*s3.GetObjectTorrentOutput, error) {
+		return client.GetObjectTorrent(ctx, params, optFns...)
+	})
+}
+
+// SelectObjectContent forwards to the backend route of params.Bucket
+func (r *Router) SelectObjectContent(ctx context.Context, params *s3.SelectObjectContentInput, optFns ...func(*s3.Options)) (*s3.SelectObjectContentOutput, error) {
+	return call(r, aws.ToString(params.Bucket), "SelectObjectContent", func(client interfaces.S3BackendInterface) (*s3.SelectObjectContentOutput, error) {
+		return client.SelectObjectContent(ctx, params, optFns...)
+	})
+}
@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// healthCheckTimeout bounds a single route health check
+const healthCheckTimeout = 10 * time.Second
+
+// ErrCrossRouteCopy is returned for CopyObject requests whose source and
+// destination buckets live on different backends
+var ErrCrossRouteCopy = errors.New("copy source and destination are served by different backends")
+
+// Route is a backend endpoint reachable through the Router
+type Route struct {
+	Name        string
+	Buckets     []string // exact bucket names, or name prefixes ending in "*"
+	Client      interfaces.S3BackendInterface
+	HealthCheck func(ctx context.Context) error // optional
+}
+
+type route struct {
+	Route
+	healthy atomic.Bool
+}
+
+type prefixRoute struct {
+	prefix string
+	route  *route
+}
+
+// Router implements interfaces.S3BackendInterface by dispatching every
+// operation to the backend route serving its bucket. Exact bucket names take
+// precedence over prefixes, longer prefixes over shorter ones, and buckets
+// without a route use the fallback backend.
+type Router struct {
+	fallback *route
+	routes   []*route // fallback first
+	exact    map[string]*route
+	prefixes []prefixRoute // longest prefix first
+	logger   *logrus.Entry
+}
+
+// NewRouter creates a router. The Buckets of fallback are ignored.
+func NewRouter(fallback Route, routes []Route, logger *logrus.Entry) *Router {
+	r := &Router{
+		exact:  make(map[string]*route),
+		logger: logger,
+	}
+
+	r.fallback = &route{Route: fallback}
+	r.fallback.healthy.Store(true)
+	r.routes = append(r.routes, r.fallback)
+
+	for _, cfg := range routes {
+		rt := &route{Route: cfg}
+		rt.healthy.Store(true)
+		r.routes = append(r.routes, rt)
+
+		for _, pattern := range cfg.Buckets {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				r.prefixes = append(r.prefixes, prefixRoute{prefix: prefix, route: rt})
+			} else {
+				r.exact[pattern] = rt
+			}
+		}
+	}
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+
+	return r
+}
+
+// Resolve returns the name of the route serving bucket
+func (r *Router) Resolve(bucket string) string {
+	return r.routeFor(bucket).Name
+}
+
+func (r *Router) routeFor(bucket string) *route {
+	if rt, ok := r.exact[bucket]; ok {
+		return rt
+	}
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(bucket, p.prefix) {
+			return p.route
+		}
+	}
+	return r.fallback
+}
+
+// call runs fn against the route of bucket and records per-route metrics
+func call[T any](r *Router, bucket, operation string, fn func(client interfaces.S3BackendInterface) (T, error)) (T, error) {
+	rt := r.routeFor(bucket)
+	start := time.Now()
+	out, err := fn(rt.Client)
+	monitoring.RecordBackendRouteRequest(rt.Name, operation, time.Since(start), err)
+	return out, err
+}
+
+// ListBuckets merges the bucket lists of all routes. Each route only
+// contributes the buckets that are routed to it, so a bucket is never listed
+// from a backend the proxy would not send its requests to.
+func (r *Router) ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	var merged *s3.ListBucketsOutput
+	for _, rt := range r.routes {
+		start := time.Now()
+		out, err := rt.Client.ListBuckets(ctx, params, optFns...)
+		monitoring.RecordBackendRouteRequest(rt.Name, "ListBuckets", time.Since(start), err)
+		if err != nil {
+			return nil, fmt.Errorf("backend route %s: %w", rt.Name, err)
+		}
+
+		if merged == nil {
+			merged = &s3.ListBucketsOutput{Owner: out.Owner, ResultMetadata: out.ResultMetadata}
+		}
+		for _, bucket := range out.Buckets {
+			if r.routeFor(aws.ToString(bucket.Name)) == rt {
+				merged.Buckets = append(merged.Buckets, bucket)
+			}
+		}
+	}
+
+	sort.Slice(merged.Buckets, func(i, j int) bool {
+		return aws.ToString(merged.Buckets[i].Name) < aws.ToString(merged.Buckets[j].Name)
+	})
+	return merged, nil
+}
+
+// CopyObject forwards to the route of the destination bucket. Copies between
+// buckets on different routes are rejected, since backends cannot copy from
+// each other.
+func (r *Router) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	bucket := aws.ToString(params.Bucket)
+	if source := copySourceBucket(aws.ToString(params.CopySource)); source != "" {
+		if from, to := r.routeFor(source), r.routeFor(bucket); from != to {
+			return nil, fmt.Errorf("%w: %s (%s) -> %s (%s)", ErrCrossRouteCopy, source, from.Name, bucket, to.Name)
+		}
+	}
+	return call(r, bucket, "CopyObject", func(client interfaces.S3BackendInterface) (*s3.CopyObjectOutput, error) {
+		return client.CopyObject(ctx, params, optFns...)
+	})
+}
+
+// copySourceBucket extracts the bucket from an x-amz-copy-source value
+func copySourceBucket(source string) string {
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	source = strings.TrimPrefix(source, "/")
+	bucket, _, _ := strings.Cut(source, "/")
+	return bucket
+}
+
+// Healthy returns the result of the last health check per route name
+func (r *Router) Healthy() map[string]bool {
+	status := make(map[string]bool, len(r.routes))
+	for _, rt := range r.routes {
+		status[rt.Name] = rt.healthy.Load()
+	}
+	return status
+}
+
+// RunHealthChecks checks every route with a HealthCheck once per interval
+// until ctx is cancelled
+func (r *Router) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	r.CheckHealth(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckHealth(ctx)
+		}
+	}
+}
+
+// CheckHealth runs the health check of every route once and logs changes
+func (r *Router) CheckHealth(ctx context.Context) {
+	for _, rt := range r.routes {
+		if rt.HealthCheck == nil {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := rt.HealthCheck(checkCtx)
+		cancel()
+
+		healthy := err == nil
+		monitoring.SetBackendRouteUp(rt.Name, healthy)
+		if rt.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			r.logger.WithField("route", rt.Name).Info("Backend route is healthy again")
+		} else {
+			r.logger.WithError(err).WithField("route", rt.Name).Warn("Backend route health check failed")
+		}
+	}
+}
+
+var _ interfaces.S3BackendInterface = (*Router)(nil)
@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// fakeBackend records the calls it receives; unimplemented operations panic
+type fakeBackend struct {
+	interfaces.S3BackendInterface
+	name    string
+	buckets []string
+	calls   []string
+}
+
+func (f *fakeBackend) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.calls = append(f.calls, "GetObject:"+aws.ToString(params.Bucket))
+	return &s3.GetObjectOutput{}, nil
+}
+
+func (f *fakeBackend) CopyObject(_ context.Context, params *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.calls = append(f.calls, "CopyObject:"+aws.ToString(params.Bucket))
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeBackend) ListBuckets(_ context.Context, _ *s3.ListBucketsInput, _ ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	out := &s3.ListBucketsOutput{Owner: &types.Owner{ID: aws.String(f.name)}}
+	for _, name := range f.buckets {
+		out.Buckets = append(out.Buckets, types.Bucket{Name: aws.String(name)})
+	}
+	return out, nil
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return logrus.NewEntry(logger)
+}
+
+func newTestRouter() (*Router, *fakeBackend, *fakeBackend, *fakeBackend) {
+	fallback := &fakeBackend{name: "default", buckets: []string{"shared", "logs-old", "archive-x"}}
+	archive := &fakeBackend{name: "archive", buckets: []string{"archive-1", "archive-2"}}
+	logs := &fakeBackend{name: "logs", buckets: []string{"logs-2024", "logs-old", "unrouted"}}
+
+	router := NewRouter(Route{Name: "default", Client: fallback}, []Route{
+		{Name: "archive", Buckets: []string{"archive-*"}, Client: archive},
+		{Name: "logs", Buckets: []string{"logs-*", "archive-x"}, Client: logs},
+	}, testLogger())
+	return router, fallback, archive, logs
+}
+
+func TestRouter_Resolve(t *testing.T) {
+	router, _, _, _ := newTestRouter()
+
+	assert.Equal(t, "archive", router.Resolve("archive-1"))
+	assert.Equal(t, "logs", router.Resolve("archive-x"), "exact names win over prefixes")
+	assert.Equal(t, "logs", router.Resolve("logs-2024"))
+	assert.Equal(t, "default", router.Resolve("shared"))
+	assert.Equal(t, "default", router.Resolve("archive"), "prefix requires the dash")
+}
+
+func TestRouter_ForwardsToRoute(t *testing.T) {
+	router, fallback, archive, logs := newTestRouter()
+	ctx := context.Background()
+
+	_, err := router.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("archive-1"), Key: aws.String("k")})
+	require.NoError(t, err)
+	_, err = router.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("shared"), Key: aws.String("k")})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"GetObject:archive-1"}, archive.calls)
+	assert.Equal(t, []string{"GetObject:shared"}, fallback.calls)
+	assert.Empty(t, logs.calls)
+}
+
+func TestRouter_ListBucketsMergesRoutedBuckets(t *testing.T) {
+	router, _, _, _ := newTestRouter()
+
+	out, err := router.ListBuckets(context.Background(), &s3.ListBucketsInput{})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(out.Buckets))
+	for _, bucket := range out.Buckets {
+		names = append(names, aws.ToString(bucket.Name))
+	}
+	// logs-old and archive-x on the default backend and unrouted on the logs backend are not reachable
+	assert.Equal(t, []string{"archive-1", "archive-2", "logs-2024", "logs-old", "shared"}, names)
+	assert.Equal(t, "default", aws.ToString(out.Owner.ID))
+}
+
+func TestRouter_CopyObject(t *testing.T) {
+	router, _, archive, _ := newTestRouter()
+	ctx := context.Background()
+
+	_, err := router.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String("archive-2"),
+		Key:        aws.String("dst"),
+		CopySource: aws.String("archive-1/src%20key"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CopyObject:archive-2"}, archive.calls)
+
+	_, err = router.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String("shared"),
+		Key:        aws.String("dst"),
+		CopySource: aws.String("/archive-1/src"),
+	})
+	assert.True(t, errors.Is(err, ErrCrossRouteCopy))
+}
+
+func TestRouter_CheckHealth(t *testing.T) {
+	failing := errors.New("connection refused")
+	var archiveErr error
+	router := NewRouter(Route{Name: "default", Client: &fakeBackend{}}, []Route{
+		{
+			Name:        "archive",
+			Buckets:     []string{"archive-*"},
+			Client:      &fakeBackend{},
+			HealthCheck: func(context.Context) error { return archiveErr },
+		},
+	}, testLogger())
+
+	archiveErr = failing
+	router.CheckHealth(context.Background())
+	assert.Equal(t, map[string]bool{"default": true, "archive": false}, router.Healthy())
+
+	archiveErr = nil
+	router.CheckHealth(context.Background())
+	assert.Equal(t, map[string]bool{"default": true, "archive": true}, router.Healthy())
+}
@@ -0,0 +1,580 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// SidecarSuffix is appended to the key of an object to name its metadata
+// sidecar
+const SidecarSuffix = ".s3ep-meta"
+
+// maxSidecarSize bounds the sidecar objects read back
+const maxSidecarSize = 1 << 20
+
+var (
+	// ErrSidecarMissing is returned for objects whose metadata sidecar is
+	// missing or was written for another write of the object
+	ErrSidecarMissing = errors.New("metadata sidecar of the object is missing")
+
+	// ErrReservedKey is returned for requests addressing the sidecars
+	// themselves
+	ErrReservedKey = errors.New("key is reserved for metadata sidecars")
+)
+
+// sidecarDocument is the content of a sidecar object. ID matches the marker
+// on the object, so a sidecar left over from another write of the same key is
+// never taken for the object's own.
+type sidecarDocument struct {
+	ID       string            `json:"id"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// SidecarStore implements interfaces.S3BackendInterface on top of another
+// backend, moving the encryption metadata of written objects into sidecar
+// objects as configured in encryption.metadata_sidecar:
+//
+//   - PutObject and CopyObject with replaced metadata store the object with a
+//     marker in place of its encryption metadata, then write the sidecar;
+//   - GetObject and HeadObject of an object with a marker merge the metadata
+//     of its sidecar back in and drop the marker, whatever the current mode;
+//   - deletes of the current version remove the sidecar too, and sidecars are
+//     left out of listings and cannot be addressed by clients.
+//
+// Other operations go to the wrapped backend unchanged.
+type SidecarStore struct {
+	interfaces.S3BackendInterface
+	config       config.MetadataSidecarConfig
+	prefix       string   // encryption metadata key prefix
+	markerKey    string   // sidecar id
+	markerBucket string   // index bucket, if the sidecar is kept there
+	legacy       []string // earlier encryption metadata key prefixes
+	logger       *logrus.Entry
+}
+
+// NewSidecarStore wraps next. prefix is the encryption metadata key prefix;
+// metadata keys starting with it are moved to sidecars.
+func NewSidecarStore(next interfaces.S3BackendInterface, cfg config.MetadataSidecarConfig, prefix string, logger *logrus.Entry) *SidecarStore {
+	return &SidecarStore{
+		S3BackendInterface: next,
+		config:             cfg,
+		prefix:             prefix,
+		markerKey:          prefix + "sidecar",
+		markerBucket:       prefix + "sidecar-bucket",
+		logger:             logger,
+	}
+}
+
+// SetLegacyPrefixes makes the markers of objects written under an earlier
+// encryption metadata key prefix recognized too
+func (s *SidecarStore) SetLegacyPrefixes(prefixes []string) {
+	s.legacy = prefixes
+}
+
+// enabled reports whether new objects may get sidecars
+func (s *SidecarStore) enabled() bool {
+	return s.config.Mode == config.MetadataSidecarModeAlways || s.config.Mode == config.MetadataSidecarModeOversize
+}
+
+// reserved reports whether bucket and key address sidecars
+func (s *SidecarStore) reserved(bucket, key string) bool {
+	if !s.enabled() {
+		return false
+	}
+	if s.config.IndexBucket != "" {
+		return bucket == s.config.IndexBucket
+	}
+	return strings.HasSuffix(key, SidecarSuffix)
+}
+
+// location returns where the sidecar of an object is written
+func (s *SidecarStore) location(bucket, key string) (string, string) {
+	if s.config.IndexBucket != "" {
+		return s.config.IndexBucket, bucket + "/" + key + SidecarSuffix
+	}
+	return bucket, key + SidecarSuffix
+}
+
+// split returns the metadata to store on the object and the sidecar to write
+// for it, or a nil sidecar if the metadata stays on the object
+func (s *SidecarStore) split(metadata map[string]string) (map[string]string, *sidecarDocument, error) {
+	if !s.enabled() {
+		return metadata, nil, nil
+	}
+
+	size := 0
+	moved := make(map[string]string)
+	for k, v := range metadata {
+		size += len(k) + len(v)
+		if strings.HasPrefix(k, s.prefix) && k != s.markerKey && k != s.markerBucket {
+			moved[k] = v
+		}
+	}
+	if len(moved) == 0 || (s.config.Mode == config.MetadataSidecarModeOversize && size <= s.config.InlineLimit) {
+		return metadata, nil, nil
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate sidecar id: %w", err)
+	}
+	doc := &sidecarDocument{ID: hex.EncodeToString(id), Metadata: moved}
+
+	inline := make(map[string]string, len(metadata)-len(moved)+2)
+	for k, v := range metadata {
+		if _, ok := moved[k]; !ok {
+			inline[k] = v
+		}
+	}
+	inline[s.markerKey] = doc.ID
+	if s.config.IndexBucket != "" {
+		inline[s.markerBucket] = s.config.IndexBucket
+	}
+	return inline, doc, nil
+}
+
+// write stores the sidecar of an object
+func (s *SidecarStore) write(ctx context.Context, bucket, key string, doc *sidecarDocument) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	sidecarBucket, sidecarKey := s.location(bucket, key)
+	_, err = s.S3BackendInterface.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(sidecarBucket),
+		Key:           aws.String(sidecarKey),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("object stored but its metadata sidecar could not be written: %w", err)
+	}
+	return nil
+}
+
+// restore merges the sidecar metadata of an object into metadata, if it has
+// a marker. versionID and lastModified are those of the object read: in
+// versioned buckets the sidecar key is overwritten by later writes, so the
+// sidecar of an older version is looked up among the sidecar's versions.
+func (s *SidecarStore) restore(ctx context.Context, bucket, key string, versionID *string, lastModified *time.Time, metadata map[string]string) error {
+	markerKey, markerBucket := s.markerKey, s.markerBucket
+	id, ok := lookupMetadata(metadata, markerKey)
+	for _, prefix := range s.legacy {
+		if ok {
+			break
+		}
+		markerKey, markerBucket = prefix+"sidecar", prefix+"sidecar-bucket"
+		id, ok = lookupMetadata(metadata, markerKey)
+	}
+	if !ok {
+		return nil
+	}
+	sidecarBucket, sidecarKey := bucket, key+SidecarSuffix
+	if index, ok := lookupMetadata(metadata, markerBucket); ok {
+		sidecarBucket, sidecarKey = index, bucket+"/"+key+SidecarSuffix
+	}
+
+	doc, err := s.readSidecar(ctx, sidecarBucket, sidecarKey, nil)
+	if (err == nil && doc.ID != id) || errors.Is(err, ErrSidecarMissing) {
+		if version := aws.ToString(versionID); version != "" && version != "null" {
+			doc, err = s.findSidecarVersion(ctx, sidecarBucket, sidecarKey, id, lastModified)
+		} else if err == nil {
+			err = fmt.Errorf("%w: %s/%s belongs to another write of the object", ErrSidecarMissing, sidecarBucket, sidecarKey)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for k := range metadata {
+		if strings.EqualFold(k, markerKey) || strings.EqualFold(k, markerBucket) {
+			delete(metadata, k)
+		}
+	}
+	for k, v := range doc.Metadata {
+		metadata[k] = v
+	}
+	return nil
+}
+
+// readSidecar reads a sidecar object, or one of its versions
+func (s *SidecarStore) readSidecar(ctx context.Context, bucket, key string, versionID *string) (*sidecarDocument, error) {
+	out, err := s.S3BackendInterface.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionID,
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%w: %s/%s", ErrSidecarMissing, bucket, key)
+		}
+		return nil, fmt.Errorf("failed to read metadata sidecar %s/%s: %w", bucket, key, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(out.Body, maxSidecarSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata sidecar %s/%s: %w", bucket, key, err)
+	}
+	var doc sidecarDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid metadata sidecar %s/%s: %w", bucket, key, err)
+	}
+	return &doc, nil
+}
+
+// findSidecarVersion returns the version of a sidecar with the given id. The
+// sidecar is written right after its object, so versions older than the
+// object are not read; the remaining ones are tried oldest first.
+func (s *SidecarStore) findSidecarVersion(ctx context.Context, bucket, key, id string, written *time.Time) (*sidecarDocument, error) {
+	var candidates []*string
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket), Prefix: aws.String(key)}
+listing:
+	for {
+		page, err := s.S3BackendInterface.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions of metadata sidecar %s/%s: %w", bucket, key, err)
+		}
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) != key {
+				continue
+			}
+			// Versions of a key are listed newest first
+			if written != nil && version.LastModified != nil && version.LastModified.Before(written.Truncate(time.Second)) {
+				break listing
+			}
+			candidates = append(candidates, version.VersionId)
+		}
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker, input.VersionIdMarker = page.NextKeyMarker, page.NextVersionIdMarker
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		doc, err := s.readSidecar(ctx, bucket, key, candidates[i])
+		if errors.Is(err, ErrSidecarMissing) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if doc.ID == id {
+			return doc, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no version of %s/%s belongs to this write of the object", ErrSidecarMissing, bucket, key)
+}
+
+// lookupMetadata looks up a metadata key case-insensitively, as backends
+// differ in the case they return keys in
+func lookupMetadata(metadata map[string]string, key string) (string, bool) {
+	if v, ok := metadata[key]; ok {
+		return v, true
+	}
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// deleteSidecars removes the sidecars of deleted objects. Failures only
+// leave unreferenced sidecars behind, so they are logged.
+func (s *SidecarStore) deleteSidecars(ctx context.Context, bucket string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	var sidecarBucket string
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		var sidecarKey string
+		sidecarBucket, sidecarKey = s.location(bucket, key)
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(sidecarKey)})
+	}
+
+	var err error
+	if len(objects) == 1 {
+		_, err = s.S3BackendInterface.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(sidecarBucket), Key: objects[0].Key})
+	} else {
+		_, err = s.S3BackendInterface.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(sidecarBucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+	}
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"bucket": sidecarBucket,
+			"count":  len(objects),
+		}).Warn("Failed to delete metadata sidecars")
+	}
+}
+
+// PutObject stores the encryption metadata of the object in a sidecar if
+// the mode asks for it
+func (s *SidecarStore) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	if s.reserved(bucket, key) {
+		return nil, ErrReservedKey
+	}
+	inline, doc, err := s.split(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return s.S3BackendInterface.PutObject(ctx, params, optFns...)
+	}
+
+	input := *params
+	input.Metadata = inline
+	out, err := s.S3BackendInterface.PutObject(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(ctx, bucket, key, doc); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CopyObject moves replaced encryption metadata to a sidecar like PutObject,
+// and copies the sidecar of the source along with copied metadata
+func (s *SidecarStore) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	sourceBucket, sourceKey := copySourceObject(aws.ToString(params.CopySource))
+	if s.reserved(bucket, key) || s.reserved(sourceBucket, sourceKey) {
+		return nil, ErrReservedKey
+	}
+
+	if params.MetadataDirective != types.MetadataDirectiveReplace {
+		out, err := s.S3BackendInterface.CopyObject(ctx, params, optFns...)
+		if err != nil || !s.enabled() || sourceKey == "" {
+			return out, err
+		}
+		s.copySidecar(ctx, sourceBucket, sourceKey, bucket, key)
+		return out, nil
+	}
+
+	inline, doc, err := s.split(params.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return s.S3BackendInterface.CopyObject(ctx, params, optFns...)
+	}
+
+	input := *params
+	input.Metadata = inline
+	out, err := s.S3BackendInterface.CopyObject(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(ctx, bucket, key, doc); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// copySidecar copies the sidecar of a copied object, if it has one
+func (s *SidecarStore) copySidecar(ctx context.Context, sourceBucket, sourceKey, bucket, key string) {
+	fromBucket, fromKey := s.location(sourceBucket, sourceKey)
+	toBucket, toKey := s.location(bucket, key)
+	_, err := s.S3BackendInterface.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(toBucket),
+		Key:        aws.String(toKey),
+		CopySource: aws.String(fromBucket + "/" + url.PathEscape(fromKey)),
+	})
+	var noSuchKey *types.NoSuchKey
+	if err != nil && !errors.As(err, &noSuchKey) {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"bucket": bucket,
+			"key":    key,
+		}).Warn("Failed to copy metadata sidecar")
+	}
+}
+
+// copySourceObject extracts the bucket and key from an x-amz-copy-source
+// value, without a version id
+func copySourceObject(source string) (string, string) {
+	source, _, _ = strings.Cut(source, "?")
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	return bucket, key
+}
+
+// CreateMultipartUpload rejects uploads to sidecar keys; the metadata of
+// multipart objects is applied with a CopyObject after completion
+func (s *SidecarStore) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if s.reserved(aws.ToString(params.Bucket), aws.ToString(params.Key)) {
+		return nil, ErrReservedKey
+	}
+	return s.S3BackendInterface.CreateMultipartUpload(ctx, params, optFns...)
+}
+
+// GetObject merges the sidecar metadata of the object back in
+func (s *SidecarStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	if s.reserved(bucket, key) {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.GetObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.restore(ctx, bucket, key, out.VersionId, out.LastModified, out.Metadata); err != nil {
+		_ = out.Body.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// HeadObject merges the sidecar metadata of the object back in
+func (s *SidecarStore) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	if s.reserved(bucket, key) {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.HeadObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.restore(ctx, bucket, key, out.VersionId, out.LastModified, out.Metadata); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteObject removes the sidecar along with the current version of an
+// object. Older versions keep theirs.
+func (s *SidecarStore) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	if s.reserved(bucket, key) {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.DeleteObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if s.enabled() && params.VersionId == nil {
+		s.deleteSidecars(ctx, bucket, []string{key})
+	}
+	return out, nil
+}
+
+// DeleteObjects removes the sidecars of the deleted current versions
+func (s *SidecarStore) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	bucket := aws.ToString(params.Bucket)
+	if params.Delete != nil {
+		for _, object := range params.Delete.Objects {
+			if s.reserved(bucket, aws.ToString(object.Key)) {
+				return nil, ErrReservedKey
+			}
+		}
+	}
+	out, err := s.S3BackendInterface.DeleteObjects(ctx, params, optFns...)
+	if err != nil || !s.enabled() {
+		return out, err
+	}
+
+	// Quiet responses list no deletions, so go by the request
+	failed := make(map[string]bool, len(out.Errors))
+	for _, e := range out.Errors {
+		failed[aws.ToString(e.Key)] = true
+	}
+	var keys []string
+	for _, object := range params.Delete.Objects {
+		if key := aws.ToString(object.Key); object.VersionId == nil && !failed[key] {
+			keys = append(keys, key)
+		}
+	}
+	s.deleteSidecars(ctx, bucket, keys)
+	return out, nil
+}
+
+// ListObjectsV2 leaves sidecars out of listings
+func (s *SidecarStore) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	bucket := aws.ToString(params.Bucket)
+	if s.reserved(bucket, "") {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.ListObjectsV2(ctx, params, optFns...)
+	if err != nil || !s.enabled() {
+		return out, err
+	}
+	before := len(out.Contents)
+	out.Contents = s.withoutSidecars(out.Contents)
+	if out.KeyCount != nil {
+		out.KeyCount = aws.Int32(aws.ToInt32(out.KeyCount) - int32(before-len(out.Contents))) //nolint:gosec // bounded by the page size
+	}
+	return out, nil
+}
+
+// ListObjects leaves sidecars out of listings
+func (s *SidecarStore) ListObjects(ctx context.Context, params *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	bucket := aws.ToString(params.Bucket)
+	if s.reserved(bucket, "") {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.ListObjects(ctx, params, optFns...)
+	if err != nil || !s.enabled() {
+		return out, err
+	}
+	out.Contents = s.withoutSidecars(out.Contents)
+	return out, nil
+}
+
+// ListObjectVersions leaves the versions and delete markers of sidecars out
+// of listings
+func (s *SidecarStore) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	bucket := aws.ToString(params.Bucket)
+	if s.reserved(bucket, "") {
+		return nil, ErrReservedKey
+	}
+	out, err := s.S3BackendInterface.ListObjectVersions(ctx, params, optFns...)
+	if err != nil || !s.enabled() || s.config.IndexBucket != "" {
+		return out, err
+	}
+	out.Versions = slices.DeleteFunc(out.Versions, func(version types.ObjectVersion) bool {
+		return strings.HasSuffix(aws.ToString(version.Key), SidecarSuffix)
+	})
+	out.DeleteMarkers = slices.DeleteFunc(out.DeleteMarkers, func(marker types.DeleteMarkerEntry) bool {
+		return strings.HasSuffix(aws.ToString(marker.Key), SidecarSuffix)
+	})
+	return out, nil
+}
+
+func (s *SidecarStore) withoutSidecars(objects []types.Object) []types.Object {
+	if s.config.IndexBucket != "" {
+		return objects
+	}
+	kept := objects[:0]
+	for _, object := range objects {
+		if !strings.HasSuffix(aws.ToString(object.Key), SidecarSuffix) {
+			kept = append(kept, object)
+		}
+	}
+	return kept
+}
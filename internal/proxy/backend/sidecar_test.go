@@ -0,0 +1,273 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func newTestSidecarStore(t *testing.T, cfg config.MetadataSidecarConfig) (*SidecarStore, *MemoryBackend) {
+	t.Helper()
+	memory := newTestMemoryBackend(t)
+	if cfg.IndexBucket != "" {
+		_, err := memory.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(cfg.IndexBucket)})
+		require.NoError(t, err)
+	}
+	return NewSidecarStore(memory, cfg, "s3ep-", logrus.NewEntry(logrus.New())), memory
+}
+
+func encryptedMetadata() map[string]string {
+	return map[string]string{
+		"s3ep-encrypted-dek": strings.Repeat("k", 300),
+		"s3ep-hmac":          "mac",
+		"owner":              "team-a",
+	}
+}
+
+func putSidecarObject(t *testing.T, store *SidecarStore, key string, metadata map[string]string) {
+	t.Helper()
+	_, err := store.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String(key),
+		Body:     strings.NewReader("ciphertext"),
+		Metadata: metadata,
+	})
+	require.NoError(t, err)
+}
+
+func headMetadata(t *testing.T, backend interface {
+	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}, bucket, key string) map[string]string {
+	t.Helper()
+	out, err := backend.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	require.NoError(t, err)
+	return out.Metadata
+}
+
+func TestSidecarStore_Always(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	putSidecarObject(t, store, "key", encryptedMetadata())
+
+	stored := headMetadata(t, memory, "bucket", "key")
+	assert.Equal(t, "team-a", stored["owner"])
+	assert.NotEmpty(t, stored["s3ep-sidecar"])
+	assert.NotContains(t, stored, "s3ep-encrypted-dek")
+	assert.NotContains(t, stored, "s3ep-hmac")
+
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, store, "bucket", "key"))
+
+	out, err := store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	require.NoError(t, err)
+	body, err := io.ReadAll(out.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ciphertext", string(body))
+	assert.Equal(t, encryptedMetadata(), out.Metadata)
+}
+
+func TestSidecarStore_Oversize(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeOversize, InlineLimit: 200})
+
+	small := map[string]string{"s3ep-hmac": "mac"}
+	putSidecarObject(t, store, "small", small)
+	assert.Equal(t, small, headMetadata(t, memory, "bucket", "small"))
+
+	putSidecarObject(t, store, "large", encryptedMetadata())
+	assert.NotContains(t, headMetadata(t, memory, "bucket", "large"), "s3ep-encrypted-dek")
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, store, "bucket", "large"))
+}
+
+func TestSidecarStore_IndexBucket(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways, IndexBucket: "index"})
+	putSidecarObject(t, store, "dir/key", encryptedMetadata())
+
+	_, err := memory.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("index"), Key: aws.String("bucket/dir/key" + SidecarSuffix)})
+	require.NoError(t, err)
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, store, "bucket", "dir/key"))
+
+	_, err = store.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{Bucket: aws.String("index")})
+	assert.ErrorIs(t, err, ErrReservedKey)
+	_, err = store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("index"), Key: aws.String("bucket/dir/key" + SidecarSuffix)})
+	assert.ErrorIs(t, err, ErrReservedKey)
+}
+
+func TestSidecarStore_ReadableWhenOff(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	putSidecarObject(t, store, "key", encryptedMetadata())
+
+	off := NewSidecarStore(memory, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeOff}, "s3ep-", logrus.NewEntry(logrus.New()))
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, off, "bucket", "key"))
+
+	// New objects keep their metadata
+	putSidecarObject(t, off, "inline", encryptedMetadata())
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, memory, "bucket", "inline"))
+}
+
+func TestSidecarStore_MissingOrForeignSidecar(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	putSidecarObject(t, store, "key", encryptedMetadata())
+
+	// A sidecar written for another write of the key is not used
+	_, err := memory.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key" + SidecarSuffix),
+		Body:   strings.NewReader(`{"id":"other","metadata":{"s3ep-encrypted-dek":"other"}}`),
+	})
+	require.NoError(t, err)
+	_, err = store.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.ErrorIs(t, err, ErrSidecarMissing)
+
+	_, err = memory.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key" + SidecarSuffix)})
+	require.NoError(t, err)
+	_, err = store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.ErrorIs(t, err, ErrSidecarMissing)
+}
+
+func TestSidecarStore_CopyObject(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	putSidecarObject(t, store, "upload", map[string]string{"owner": "team-a"})
+
+	// Metadata applied after a multipart upload
+	_, err := store.CopyObject(context.Background(), &s3.CopyObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("upload"),
+		CopySource:        aws.String("bucket/upload"),
+		Metadata:          encryptedMetadata(),
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, headMetadata(t, memory, "bucket", "upload"), "s3ep-hmac")
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, store, "bucket", "upload"))
+
+	// A copy with the source's metadata takes its sidecar along
+	_, err = store.CopyObject(context.Background(), &s3.CopyObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("copy"),
+		CopySource: aws.String("bucket/upload"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, encryptedMetadata(), headMetadata(t, store, "bucket", "copy"))
+}
+
+func TestSidecarStore_DeleteAndList(t *testing.T) {
+	store, memory := newTestSidecarStore(t, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways})
+	for _, key := range []string{"a", "b", "c"} {
+		putSidecarObject(t, store, key, encryptedMetadata())
+	}
+
+	list, err := store.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	var keys []string
+	for _, object := range list.Contents {
+		keys = append(keys, aws.ToString(object.Key))
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, int32(3), aws.ToInt32(list.KeyCount))
+
+	_, err = store.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a")})
+	require.NoError(t, err)
+	_, err = store.DeleteObjects(context.Background(), &s3.DeleteObjectsInput{
+		Bucket: aws.String("bucket"),
+		Delete: &types.Delete{Objects: []types.ObjectIdentifier{{Key: aws.String("b")}, {Key: aws.String("c")}}},
+	})
+	require.NoError(t, err)
+
+	raw, err := memory.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	assert.Empty(t, raw.Contents, "sidecars are deleted with their objects")
+
+	_, err = store.PutObject(context.Background(), &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a" + SidecarSuffix), Body: strings.NewReader("{}")})
+	assert.ErrorIs(t, err, ErrReservedKey)
+}
+
+// versionedMemoryBackend keeps every write of a key as a version on top of a
+// MemoryBackend, which only keeps the current one
+type versionedMemoryBackend struct {
+	*MemoryBackend
+	versions map[string][]versionedWrite // newest first
+}
+
+type versionedWrite struct {
+	id       string
+	data     string
+	metadata map[string]string
+}
+
+func (b *versionedMemoryBackend) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	input := *params
+	input.Body = strings.NewReader(string(data))
+	out, err := b.MemoryBackend.PutObject(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	key := aws.ToString(params.Key)
+	write := versionedWrite{id: fmt.Sprintf("v%d", len(b.versions[key])+1), data: string(data), metadata: params.Metadata}
+	b.versions[key] = append([]versionedWrite{write}, b.versions[key]...)
+	out.VersionId = aws.String(write.id)
+	return out, nil
+}
+
+func (b *versionedMemoryBackend) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	versions := b.versions[aws.ToString(params.Key)]
+	for _, write := range versions {
+		if params.VersionId == nil || aws.ToString(params.VersionId) == write.id {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(write.data)), Metadata: maps.Clone(write.metadata), VersionId: aws.String(write.id)}, nil
+		}
+	}
+	return nil, &types.NoSuchKey{}
+}
+
+func (b *versionedMemoryBackend) ListObjectVersions(_ context.Context, params *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}
+	for key, versions := range b.versions {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			for i, write := range versions {
+				out.Versions = append(out.Versions, types.ObjectVersion{Key: aws.String(key), VersionId: aws.String(write.id), IsLatest: aws.Bool(i == 0)})
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestSidecarStore_Versions(t *testing.T) {
+	memory := newTestMemoryBackend(t)
+	versioned := &versionedMemoryBackend{MemoryBackend: memory, versions: make(map[string][]versionedWrite)}
+	store := NewSidecarStore(versioned, config.MetadataSidecarConfig{Mode: config.MetadataSidecarModeAlways}, "s3ep-", logrus.NewEntry(logrus.New()))
+
+	first := encryptedMetadata()
+	putSidecarObject(t, store, "key", first)
+	second := encryptedMetadata()
+	second["s3ep-hmac"] = "second"
+	putSidecarObject(t, store, "key", second)
+
+	// The older version finds its sidecar among the sidecar's versions
+	out, err := store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key"), VersionId: aws.String("v1")})
+	require.NoError(t, err)
+	assert.Equal(t, first, out.Metadata)
+
+	out, err = store.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	require.NoError(t, err)
+	assert.Equal(t, second, out.Metadata)
+
+	list, err := store.ListObjectVersions(context.Background(), &s3.ListObjectVersionsInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	for _, version := range list.Versions {
+		assert.Equal(t, "key", aws.ToString(version.Key), "sidecar versions are not listed")
+	}
+	assert.Len(t, list.Versions, 2)
+}
@@ -0,0 +1,193 @@
+package backend
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // S3 single-part ETags are MD5 digests
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+)
+
+var (
+	// ErrTruncatedResponse is returned when a backend GET body ends before
+	// its Content-Length, or runs past it, and could not be re-read
+	ErrTruncatedResponse = errors.New("backend response truncated")
+
+	// ErrETagMismatch is returned when the MD5 of a single-part backend GET
+	// body does not match its ETag
+	ErrETagMismatch = errors.New("backend response does not match its ETag")
+)
+
+// ObjectGetter is the backend call used to re-read the rest of a body
+type ObjectGetter interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// ValidateGetObject replaces output.Body with a reader that checks the body
+// against the response headers as it is consumed:
+//
+//   - a body that ends before Content-Length, or fails mid-stream, is resumed
+//     with a ranged GET pinned to the ETag, up to MaxRetries times, so the
+//     caller sees one continuous stream;
+//   - if that is not possible the read fails with ErrTruncatedResponse instead
+//     of io.EOF, so decryption never finalizes a short ciphertext;
+//   - with VerifyETag, the MD5 of a single-part body is compared with its
+//     ETag at the end and a mismatch fails with ErrETagMismatch.
+//
+// input must be the request output was returned for. Ranged GETs are left
+// alone: their Content-Length is the length of the range.
+func ValidateGetObject(ctx context.Context, backend ObjectGetter, input *s3.GetObjectInput, output *s3.GetObjectOutput, cfg config.ResponseValidationConfig, logger *logrus.Entry) {
+	if !cfg.Enabled || output == nil || output.Body == nil || input.Range != nil {
+		return
+	}
+
+	body := &validatingBody{
+		ctx:        ctx,
+		backend:    backend,
+		input:      input,
+		body:       output.Body,
+		expected:   -1,
+		etag:       aws.ToString(output.ETag),
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+	}
+	if output.ContentLength != nil {
+		body.expected = aws.ToInt64(output.ContentLength)
+	}
+	if cfg.VerifyETag && isSinglePartETag(body.etag) {
+		body.md5 = md5.New() //nolint:gosec // see import
+	}
+	output.Body = body
+}
+
+// validatingBody counts and optionally hashes the bytes of a backend body
+type validatingBody struct {
+	ctx        context.Context
+	backend    ObjectGetter
+	input      *s3.GetObjectInput
+	body       io.ReadCloser
+	expected   int64 // -1 if the backend sent no Content-Length
+	etag       string
+	received   int64
+	retries    int
+	maxRetries int
+	md5        hash.Hash
+	logger     *logrus.Entry
+	err        error // sticky once the body has failed
+}
+
+func (v *validatingBody) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+
+	for {
+		n, err := v.body.Read(p)
+		v.received += int64(n)
+		if v.md5 != nil {
+			v.md5.Write(p[:n])
+		}
+
+		if v.expected >= 0 && v.received > v.expected {
+			return n, v.fail(fmt.Errorf("%w: received more than the %d bytes announced", ErrTruncatedResponse, v.expected))
+		}
+		if err == nil {
+			return n, nil
+		}
+		if errors.Is(err, io.EOF) && (v.expected < 0 || v.received == v.expected) {
+			if v.md5 != nil {
+				if sum := hex.EncodeToString(v.md5.Sum(nil)); sum != strings.Trim(v.etag, `"`) {
+					monitoring.RecordBackendETagMismatch()
+					return n, v.fail(fmt.Errorf("%w: body MD5 %s, ETag %s", ErrETagMismatch, sum, v.etag))
+				}
+			}
+			return n, io.EOF
+		}
+
+		// The body broke off or ended early
+		cause := err
+		if errors.Is(err, io.EOF) {
+			cause = fmt.Errorf("body ended after %d of %d bytes", v.received, v.expected)
+		}
+		if resumeErr := v.resume(cause); resumeErr != nil {
+			return n, v.fail(resumeErr)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume replaces the body with a ranged re-read of the remaining bytes
+func (v *validatingBody) resume(cause error) error {
+	fields := logrus.Fields{
+		"bucket":   aws.ToString(v.input.Bucket),
+		"key":      aws.ToString(v.input.Key),
+		"received": v.received,
+		"expected": v.expected,
+		"error":    cause,
+	}
+
+	if v.ctx.Err() != nil {
+		return v.ctx.Err()
+	}
+	if v.retries >= v.maxRetries || v.etag == "" || v.expected < 0 {
+		monitoring.RecordBackendTruncation(false)
+		v.logger.WithFields(fields).Error("Backend response truncated")
+		return fmt.Errorf("%w: %v", ErrTruncatedResponse, cause)
+	}
+	v.retries++
+
+	_ = v.body.Close()
+	input := *v.input
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-", v.received))
+	input.IfMatch = aws.String(v.etag)
+	output, err := v.backend.GetObject(v.ctx, &input)
+	if err != nil {
+		monitoring.RecordBackendTruncation(false)
+		v.logger.WithFields(fields).WithField("retry_error", err).Error("Backend response truncated and re-read failed")
+		return fmt.Errorf("%w: %v; re-read failed: %v", ErrTruncatedResponse, cause, err)
+	}
+	v.body = output.Body
+
+	want := fmt.Sprintf("bytes %d-%d/%d", v.received, v.expected-1, v.expected)
+	if got := aws.ToString(output.ContentRange); got != want {
+		monitoring.RecordBackendTruncation(false)
+		v.logger.WithFields(fields).WithField("content_range", got).Error("Backend response truncated and re-read returned a different range")
+		return fmt.Errorf("%w: %v; re-read returned range %q, want %q", ErrTruncatedResponse, cause, got, want)
+	}
+
+	monitoring.RecordBackendTruncation(true)
+	v.logger.WithFields(fields).WithField("retry", v.retries).Warn("Backend response truncated, resumed with a ranged re-read")
+	return nil
+}
+
+func (v *validatingBody) fail(err error) error {
+	v.err = err
+	return err
+}
+
+func (v *validatingBody) Close() error {
+	return v.body.Close()
+}
+
+// isSinglePartETag reports whether etag is the MD5 digest of the body, which
+// holds for single-part uploads without SSE-KMS or SSE-C
+func isSinglePartETag(etag string) bool {
+	etag = strings.Trim(etag, `"`)
+	if len(etag) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
@@ -0,0 +1,177 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // ETags of test objects
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// brokenReader returns data and then fails with err
+type brokenReader struct {
+	data []byte
+	err  error
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+// rangeGetter serves ranged re-reads of object; every body it returns is cut
+// after cut bytes (0 = not cut)
+type rangeGetter struct {
+	object []byte
+	etag   string
+	cut    int
+	calls  []string
+}
+
+func (g *rangeGetter) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	g.calls = append(g.calls, aws.ToString(params.Range))
+	if aws.ToString(params.IfMatch) != g.etag {
+		return nil, errors.New("PreconditionFailed")
+	}
+	var start int
+	if _, err := fmt.Sscanf(aws.ToString(params.Range), "bytes=%d-", &start); err != nil {
+		return nil, err
+	}
+	rest := g.object[start:]
+	var body io.Reader = bytes.NewReader(rest)
+	if g.cut > 0 && g.cut < len(rest) {
+		body = &brokenReader{data: rest[:g.cut], err: io.EOF}
+	}
+	return &s3.GetObjectOutput{
+		Body:         io.NopCloser(body),
+		ContentRange: aws.String(fmt.Sprintf("bytes %d-%d/%d", start, len(g.object)-1, len(g.object))),
+	}, nil
+}
+
+func testObject() ([]byte, string) {
+	object := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	sum := md5.Sum(object) //nolint:gosec // see import
+	return object, `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func validatedOutput(getter *rangeGetter, body io.Reader, cfg config.ResponseValidationConfig) *s3.GetObjectOutput {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	output := &s3.GetObjectOutput{
+		Body:          io.NopCloser(body),
+		ContentLength: aws.Int64(int64(len(getter.object))),
+		ETag:          aws.String(getter.etag),
+	}
+	input := &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}
+	ValidateGetObject(context.Background(), getter, input, output, cfg, logrus.NewEntry(logger))
+	return output
+}
+
+func TestValidateGetObject_CompleteBody(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object, etag: etag}
+	output := validatedOutput(getter, bytes.NewReader(object), config.ResponseValidationConfig{Enabled: true, MaxRetries: 2, VerifyETag: true})
+
+	data, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	assert.Equal(t, object, data)
+	assert.Empty(t, getter.calls)
+}
+
+func TestValidateGetObject_ResumesTruncatedBody(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object, etag: etag}
+	output := validatedOutput(getter, bytes.NewReader(object[:1000]), config.ResponseValidationConfig{Enabled: true, MaxRetries: 2, VerifyETag: true})
+
+	data, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	assert.Equal(t, object, data)
+	assert.Equal(t, []string{"bytes=1000-"}, getter.calls)
+}
+
+func TestValidateGetObject_ResumesBrokenBody(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object, etag: etag, cut: 6000}
+	body := &brokenReader{data: object[:500], err: errors.New("connection reset by peer")}
+	output := validatedOutput(getter, body, config.ResponseValidationConfig{Enabled: true, MaxRetries: 3})
+
+	data, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	assert.Equal(t, object, data)
+	assert.Equal(t, []string{"bytes=500-", "bytes=6500-", "bytes=12500-"}, getter.calls)
+}
+
+func TestValidateGetObject_FailsAfterRetries(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object, etag: etag, cut: 100}
+	output := validatedOutput(getter, bytes.NewReader(object[:100]), config.ResponseValidationConfig{Enabled: true, MaxRetries: 1})
+
+	_, err := io.ReadAll(output.Body)
+	assert.ErrorIs(t, err, ErrTruncatedResponse)
+	assert.Len(t, getter.calls, 1)
+
+	// The failure is sticky
+	_, err = output.Body.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, ErrTruncatedResponse)
+}
+
+func TestValidateGetObject_ChangedObject(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object, etag: `"changed"`}
+	output := validatedOutput(&rangeGetter{object: object, etag: etag}, bytes.NewReader(object[:100]), config.ResponseValidationConfig{Enabled: true, MaxRetries: 2})
+	output.Body.(*validatingBody).backend = getter
+
+	_, err := io.ReadAll(output.Body)
+	assert.ErrorIs(t, err, ErrTruncatedResponse)
+	assert.Contains(t, err.Error(), "PreconditionFailed")
+}
+
+func TestValidateGetObject_ETagMismatch(t *testing.T) {
+	object, etag := testObject()
+	corrupted := append([]byte(nil), object...)
+	corrupted[10] ^= 0xff
+	getter := &rangeGetter{object: object, etag: etag}
+	output := validatedOutput(getter, bytes.NewReader(corrupted), config.ResponseValidationConfig{Enabled: true, VerifyETag: true})
+
+	_, err := io.ReadAll(output.Body)
+	assert.ErrorIs(t, err, ErrETagMismatch)
+
+	// Multipart ETags are not digests of the body and are not checked
+	getter.etag = `"9b2cf535f27731c974343645a3985328-3"`
+	output = validatedOutput(getter, bytes.NewReader(corrupted), config.ResponseValidationConfig{Enabled: true, VerifyETag: true})
+	_, err = io.ReadAll(output.Body)
+	assert.NoError(t, err)
+}
+
+func TestValidateGetObject_OverlongBody(t *testing.T) {
+	object, etag := testObject()
+	getter := &rangeGetter{object: object[:100], etag: etag}
+	output := validatedOutput(getter, bytes.NewReader(object), config.ResponseValidationConfig{Enabled: true})
+
+	_, err := io.ReadAll(output.Body)
+	assert.ErrorIs(t, err, ErrTruncatedResponse)
+}
+
+func TestValidateGetObject_Disabled(t *testing.T) {
+	object, etag := testObject()
+	body := io.NopCloser(bytes.NewReader(object[:100]))
+	output := &s3.GetObjectOutput{Body: body, ContentLength: aws.Int64(int64(len(object))), ETag: aws.String(etag)}
+	ValidateGetObject(context.Background(), nil, &s3.GetObjectInput{}, output, config.ResponseValidationConfig{}, logrus.NewEntry(logrus.New()))
+	assert.Equal(t, body, output.Body)
+}
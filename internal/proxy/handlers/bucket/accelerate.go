@@ -1,12 +1,16 @@
 package bucket
 
 import (
+	"encoding/xml"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
 )
 
 // AccelerateHandler handles bucket acceleration operations
@@ -39,7 +43,17 @@ func (h *AccelerateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGetBucketAccelerateConfiguration gets bucket acceleration configuration
+// accelerateConfiguration is the GetBucketAccelerateConfiguration response body
+type accelerateConfiguration struct {
+	XMLName xml.Name `xml:"AccelerateConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// handleGetBucketAccelerateConfiguration gets bucket acceleration configuration.
+// Backends without transfer acceleration (MinIO, Ceph, ...) get the AWS default
+// of a suspended configuration instead of an error, since SDKs and the AWS CLI
+// probe this sub-resource.
 func (h *AccelerateHandler) handleGetBucketAccelerateConfiguration(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket acceleration configuration")
 
@@ -47,13 +61,20 @@ func (h *AccelerateHandler) handleGetBucketAccelerateConfiguration(w http.Respon
 		Bucket: aws.String(bucket),
 	}
 
+	result := accelerateConfiguration{Xmlns: s3XMLNamespace}
 	output, err := h.S3Backend.GetBucketAccelerateConfiguration(r.Context(), input)
-	if err != nil {
+	switch {
+	case err == nil:
+		result.Status = string(output.Status)
+	case utils.IsBackendUnsupported(err):
+		h.Logger.WithError(err).WithField("bucket", bucket).Debug("Backend does not support bucket acceleration, returning default")
+		result.Status = string(types.BucketAccelerateStatusSuspended)
+	default:
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutBucketAccelerateConfiguration sets bucket acceleration configuration
@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/gorilla/mux"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
@@ -70,6 +71,26 @@ func TestAccelerateHandler_Handle(t *testing.T) {
 			},
 			expectedBody: "", // Empty status
 		},
+		{
+			name:           "GET bucket accelerate - backend not implemented returns default",
+			method:         "GET",
+			bucket:         "test-bucket",
+			expectedStatus: http.StatusOK,
+			setupMock: func(m *MockS3Backend) {
+				m.On("GetBucketAccelerateConfiguration", mock.Anything, mock.Anything).Return(nil, &smithy.GenericAPIError{Code: "NotImplemented", Message: "A header you provided implies functionality that is not implemented"})
+			},
+			expectedBody: "<Status>Suspended</Status>",
+		},
+		{
+			name:           "GET bucket accelerate - missing bucket is not masked",
+			method:         "GET",
+			bucket:         "test-bucket",
+			expectedStatus: http.StatusNotFound,
+			setupMock: func(m *MockS3Backend) {
+				m.On("GetBucketAccelerateConfiguration", mock.Anything, mock.Anything).Return(nil, &types.NoSuchBucket{})
+			},
+			expectedBody: "NoSuchBucket",
+		},
 		{
 			name:           "PUT bucket accelerate - not implemented",
 			method:         "PUT",
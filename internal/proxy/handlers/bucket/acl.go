@@ -1,7 +1,7 @@
 package bucket
 
 import (
-	"encoding/xml"
+	"bytes"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -9,6 +9,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // ACLHandler handles bucket ACL operations
@@ -72,7 +74,7 @@ func (h *ACLHandler) handlePutACL(w http.ResponseWriter, r *http.Request, bucket
 		input.ACL = types.BucketCannedACL(cannedACL)
 	} else {
 		// Parse ACL from request body
-		body, err := h.RequestParser.ReadBody(r)
+		body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 		if err != nil {
 			h.Logger.WithError(err).WithField("bucket", bucket).Error("Failed to read ACL request body")
 			h.ErrorWriter.WriteS3Error(w, err, bucket, "")
@@ -82,9 +84,9 @@ func (h *ACLHandler) handlePutACL(w http.ResponseWriter, r *http.Request, bucket
 		if len(body) > 0 {
 			// Parse XML ACL from body
 			var acp types.AccessControlPolicy
-			if err := xml.Unmarshal(body, &acp); err != nil {
+			if err := request.DecodeXML(bytes.NewReader(body), "AccessControlPolicy", &acp, request.XMLLimits{}); err != nil {
 				h.Logger.WithError(err).WithField("bucket", bucket).Error("Failed to parse ACL XML")
-				http.Error(w, "Invalid ACL XML format", http.StatusBadRequest)
+				h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 				return
 			}
 			input.AccessControlPolicy = &acp
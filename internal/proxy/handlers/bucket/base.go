@@ -32,3 +32,6 @@ func NewBaseSubResourceHandler(
 		RequestParser: requestParser,
 	}
 }
+
+// s3XMLNamespace is the namespace of S3 configuration documents
+const s3XMLNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
@@ -1,6 +1,7 @@
 package bucket
 
 import (
+	"bytes"
 	"encoding/xml"
 	"net/http"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // CORSHandler handles bucket CORS operations
@@ -49,6 +52,35 @@ func (h *CORSHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxCORSRules is the number of rules S3 allows in a CORS configuration
+const maxCORSRules = 100
+
+// corsConfiguration is the body of GetBucketCors and PutBucketCors. S3 uses
+// flattened lists, which the SDK types do not decode.
+type corsConfiguration struct {
+	XMLName   xml.Name   `xml:"CORSConfiguration"`
+	Xmlns     string     `xml:"xmlns,attr,omitempty"`
+	CORSRules []corsRule `xml:"CORSRule"`
+}
+
+type corsRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  *int32   `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// corsMethods are the methods a CORS rule can allow
+var corsMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
 // handleGetCORS handles GET bucket CORS requests
 func (h *CORSHandler) handleGetCORS(w http.ResponseWriter, r *http.Request, bucket string) {
 	output, err := h.S3Backend.GetBucketCors(r.Context(), &s3.GetBucketCorsInput{
@@ -59,37 +91,47 @@ func (h *CORSHandler) handleGetCORS(w http.ResponseWriter, r *http.Request, buck
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	result := corsConfiguration{Xmlns: s3XMLNamespace}
+	for _, rule := range output.CORSRules {
+		result.CORSRules = append(result.CORSRules, corsRule{
+			ID:             aws.ToString(rule.ID),
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		})
+	}
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutCORS handles PUT bucket CORS requests
 func (h *CORSHandler) handlePutCORS(w http.ResponseWriter, r *http.Request, bucket string) {
 	// Read CORS configuration from request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.Logger.WithError(err).WithField("bucket", bucket).Error("Failed to read CORS request body")
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	if len(body) == 0 {
-		h.Logger.WithField("bucket", bucket).Error("Empty CORS configuration in request body")
-		http.Error(w, "Missing CORS configuration", http.StatusBadRequest)
+	// Parse CORS configuration from XML
+	var requested corsConfiguration
+	if err := request.DecodeXML(bytes.NewReader(body), "CORSConfiguration", &requested, request.XMLLimits{}); err != nil {
+		h.Logger.WithError(err).WithField("bucket", bucket).Debug("Failed to parse CORS XML")
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
-
-	// Parse CORS configuration from XML
-	var corsConfig types.CORSConfiguration
-	if err := xml.Unmarshal(body, &corsConfig); err != nil {
-		h.Logger.WithError(err).WithField("bucket", bucket).Error("Failed to parse CORS XML")
-		http.Error(w, "Invalid CORS XML format", http.StatusBadRequest)
+	corsConfig, err := requested.toSDK()
+	if err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
 	// Put bucket CORS configuration
 	input := &s3.PutBucketCorsInput{
 		Bucket:            aws.String(bucket),
-		CORSConfiguration: &corsConfig,
+		CORSConfiguration: corsConfig,
 	}
 
 	_, err = h.S3Backend.PutBucketCors(r.Context(), input)
@@ -102,6 +144,36 @@ func (h *CORSHandler) handlePutCORS(w http.ResponseWriter, r *http.Request, buck
 	w.WriteHeader(http.StatusOK)
 }
 
+// toSDK validates a CORS configuration and converts it for the backend
+func (c corsConfiguration) toSDK() (*types.CORSConfiguration, error) {
+	if len(c.CORSRules) == 0 || len(c.CORSRules) > maxCORSRules {
+		return nil, request.ErrMalformedXML
+	}
+	result := &types.CORSConfiguration{}
+	for _, rule := range c.CORSRules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return nil, request.ErrMalformedXML
+		}
+		for _, method := range rule.AllowedMethods {
+			if !corsMethods[method] {
+				return nil, request.ErrMalformedXML
+			}
+		}
+		sdkRule := types.CORSRule{
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		}
+		if rule.ID != "" {
+			sdkRule.ID = aws.String(rule.ID)
+		}
+		result.CORSRules = append(result.CORSRules, sdkRule)
+	}
+	return result, nil
+}
+
 // handleDeleteCORS handles DELETE bucket CORS requests
 func (h *CORSHandler) handleDeleteCORS(w http.ResponseWriter, r *http.Request, bucket string) {
 	_, err := h.S3Backend.DeleteBucketCors(r.Context(), &s3.DeleteBucketCorsInput{
@@ -5,13 +5,19 @@ import (
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
 )
 
 // TestHandleBucketCORS_GET_NoClient tests CORS GET handler without S3 client
@@ -234,3 +240,39 @@ func TestCORSRequestBodyHandling(t *testing.T) {
 		})
 	}
 }
+
+// TestCORSPutAndGet tests that flattened CORS rules reach the backend and are
+// written back in the S3 format
+func TestCORSPutAndGet(t *testing.T) {
+	mockS3Backend := &MockS3Backend{}
+	mockS3Backend.On("PutBucketCors", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketCorsInput) bool {
+		rules := input.CORSConfiguration.CORSRules
+		return len(rules) == 1 && len(rules[0].AllowedMethods) == 2 && rules[0].AllowedOrigins[0] == "https://example.com" &&
+			aws.ToInt32(rules[0].MaxAgeSeconds) == 3000
+	})).Return(&s3.PutBucketCorsOutput{}, nil)
+	mockS3Backend.On("GetBucketCors", mock.Anything, mock.Anything).Return(&s3.GetBucketCorsOutput{
+		CORSRules: []types.CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "HEAD"}}},
+	}, nil)
+	logger := testLogger()
+	handler := NewCORSHandler(NewBaseSubResourceHandler(mockS3Backend, logger, response.NewXMLWriter(logger), response.NewErrorWriter(logger), request.NewParser(logger, &config.Config{})))
+
+	body := `<CORSConfiguration><CORSRule><AllowedOrigin>https://example.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod><AllowedMethod>PUT</AllowedMethod><MaxAgeSeconds>3000</MaxAgeSeconds></CORSRule></CORSConfiguration>`
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/test-bucket?cors", strings.NewReader(body)), map[string]string{"bucket": "test-bucket"})
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/test-bucket?cors", nil), map[string]string{"bucket": "test-bucket"})
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>GET</AllowedMethod><AllowedMethod>HEAD</AllowedMethod></CORSRule>")
+
+	// Methods outside of GET, PUT, POST, DELETE and HEAD are rejected
+	body = `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`
+	req = mux.SetURLVars(httptest.NewRequest("PUT", "/test-bucket?cors", strings.NewReader(body)), map[string]string{"bucket": "test-bucket"})
+	w = httptest.NewRecorder()
+	handler.Handle(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockS3Backend.AssertNumberOfCalls(t, "PutBucketCors", 1)
+}
@@ -19,6 +19,13 @@ type Handler struct {
 	errorWriter   *response.ErrorWriter
 	requestParser *request.Parser
 
+	// Listings report the plaintext ETags and sizes recorded under this
+	// prefix, and leave out keys below internalKeyPrefix if it is set
+	metadataPrefix    string
+	plaintextETags    bool
+	plaintextSizes    bool
+	internalKeyPrefix string
+
 	// Sub-handlers
 	aclHandler            *ACLHandler
 	corsHandler           *CORSHandler
@@ -39,7 +46,7 @@ type Handler struct {
 func NewHandler(
 	s3Backend interfaces.S3BackendInterface,
 	logger *logrus.Entry,
-	_ string,
+	metadataPrefix string,
 	cfg *config.Config,
 ) *Handler {
 	xmlWriter := response.NewXMLWriter(logger)
@@ -47,11 +54,17 @@ func NewHandler(
 	requestParser := request.NewParser(logger, cfg)
 
 	h := &Handler{
-		s3Backend:     s3Backend,
-		logger:        logger,
-		xmlWriter:     xmlWriter,
-		errorWriter:   errorWriter,
-		requestParser: requestParser,
+		s3Backend:      s3Backend,
+		logger:         logger,
+		xmlWriter:      xmlWriter,
+		errorWriter:    errorWriter,
+		requestParser:  requestParser,
+		metadataPrefix: metadataPrefix,
+		plaintextETags: cfg != nil && cfg.Encryption.ETagMode == config.ETagModePlaintext,
+		plaintextSizes: cfg != nil && cfg.Encryption.ListPlaintextSizes,
+	}
+	if cfg != nil && cfg.Encryption.HideInternalKeys {
+		h.internalKeyPrefix = cfg.Canary.KeyPrefix
 	}
 
 	// Initialize sub-handlers with shared base
@@ -60,7 +73,11 @@ func NewHandler(
 	h.corsHandler = NewCORSHandler(base)
 	h.policyHandler = NewPolicyHandler(base)
 	h.locationHandler = NewLocationHandler(base)
-	h.loggingHandler = NewLoggingHandler(base)
+	var logTargetPrefix string
+	if cfg != nil {
+		logTargetPrefix = cfg.S3Backend.AccessLogging.TargetPrefix
+	}
+	h.loggingHandler = NewLoggingHandler(base, logTargetPrefix)
 	h.versioningHandler = NewVersioningHandler(base)
 	h.taggingHandler = NewTaggingHandler(base)
 	h.notificationHandler = NewNotificationHandler(base)
@@ -89,7 +106,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	for _, param := range knownSubResources {
 		if _, has := query[param]; has {
-			h.errorWriter.WriteGenericError(w, http.StatusMethodNotAllowed,
+			h.errorWriter.WriteError(w, r, http.StatusMethodNotAllowed,
 				"MethodNotAllowed",
 				"The specified method is not allowed against this resource.")
 			return
@@ -103,7 +120,7 @@ func (h *Handler) handleBaseBucketOperations(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 		"path":   r.URL.Path,
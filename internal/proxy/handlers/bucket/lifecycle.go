@@ -1,12 +1,17 @@
 package bucket
 
 import (
+	"bytes"
+	"encoding/xml"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // LifecycleHandler handles bucket lifecycle operations
@@ -41,6 +46,48 @@ func (h *LifecycleHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxLifecycleRules is the number of rules S3 allows in a lifecycle configuration
+const maxLifecycleRules = 1000
+
+// transitionMinimumSizeHeader selects the default minimum object size of transitions
+const transitionMinimumSizeHeader = "x-amz-transition-default-minimum-object-size"
+
+// lifecycleConfiguration is the body of GetBucketLifecycleConfiguration and
+// PutBucketLifecycleConfiguration. The SDK types are reused where their
+// members match the S3 schema; rules and filters have flattened lists.
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleRule struct {
+	ID                             *string                               `xml:"ID,omitempty"`
+	Prefix                         *string                               `xml:"Prefix,omitempty"`
+	Filter                         *lifecycleFilter                      `xml:"Filter,omitempty"`
+	Status                         string                                `xml:"Status"`
+	Transitions                    []types.Transition                    `xml:"Transition,omitempty"`
+	NoncurrentVersionTransitions   []types.NoncurrentVersionTransition   `xml:"NoncurrentVersionTransition,omitempty"`
+	Expiration                     *types.LifecycleExpiration            `xml:"Expiration,omitempty"`
+	NoncurrentVersionExpiration    *types.NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *types.AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+type lifecycleFilter struct {
+	Prefix                *string       `xml:"Prefix,omitempty"`
+	Tag                   *types.Tag    `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan *int64        `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    *int64        `xml:"ObjectSizeLessThan,omitempty"`
+	And                   *lifecycleAnd `xml:"And,omitempty"`
+}
+
+type lifecycleAnd struct {
+	Prefix                *string     `xml:"Prefix,omitempty"`
+	Tags                  []types.Tag `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan *int64      `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    *int64      `xml:"ObjectSizeLessThan,omitempty"`
+}
+
 // handleGetBucketLifecycleConfiguration gets bucket lifecycle configuration
 func (h *LifecycleHandler) handleGetBucketLifecycleConfiguration(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket lifecycle configuration")
@@ -55,7 +102,14 @@ func (h *LifecycleHandler) handleGetBucketLifecycleConfiguration(w http.Response
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	if output.TransitionDefaultMinimumObjectSize != "" {
+		w.Header().Set(transitionMinimumSizeHeader, string(output.TransitionDefaultMinimumObjectSize))
+	}
+	result := lifecycleConfiguration{Xmlns: s3XMLNamespace}
+	for _, rule := range output.Rules {
+		result.Rules = append(result.Rules, lifecycleRuleFromSDK(rule))
+	}
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutBucketLifecycleConfiguration sets bucket lifecycle configuration
@@ -63,32 +117,148 @@ func (h *LifecycleHandler) handlePutBucketLifecycleConfiguration(w http.Response
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket lifecycle configuration")
 
 	// Read the request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	input := &s3.PutBucketLifecycleConfigurationInput{
-		Bucket: aws.String(bucket),
+	var requested lifecycleConfiguration
+	if err := request.DecodeXML(bytes.NewReader(body), "LifecycleConfiguration", &requested, request.XMLLimits{}); err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
 	}
-
-	// Parse lifecycle configuration from body
-	// Note: For now, we'll let the S3 client handle the body parsing
-	// In a more complete implementation, we'd parse the XML here
-	if len(body) > 0 {
-		// This would need proper XML parsing in a complete implementation
-		h.ErrorWriter.WriteNotImplemented(w, "PutBucketLifecycleConfiguration with body parsing")
+	lifecycleConfig, err := requested.toSDK()
+	if err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
+	input := &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                             aws.String(bucket),
+		LifecycleConfiguration:             lifecycleConfig,
+		TransitionDefaultMinimumObjectSize: types.TransitionDefaultMinimumObjectSize(r.Header.Get(transitionMinimumSizeHeader)),
+	}
+
 	output, err := h.S3Backend.PutBucketLifecycleConfiguration(r.Context(), input)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	if output.TransitionDefaultMinimumObjectSize != "" {
+		w.Header().Set(transitionMinimumSizeHeader, string(output.TransitionDefaultMinimumObjectSize))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// toSDK validates a lifecycle configuration and converts it for the backend
+func (c lifecycleConfiguration) toSDK() (*types.BucketLifecycleConfiguration, error) {
+	if len(c.Rules) == 0 || len(c.Rules) > maxLifecycleRules {
+		return nil, request.ErrMalformedXML
+	}
+
+	result := &types.BucketLifecycleConfiguration{}
+	ids := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		status := types.ExpirationStatus(rule.Status)
+		if status != types.ExpirationStatusEnabled && status != types.ExpirationStatusDisabled {
+			return nil, request.ErrMalformedXML
+		}
+		if id := aws.ToString(rule.ID); id != "" {
+			if len(id) > 255 || ids[id] {
+				return nil, request.ErrMalformedXML
+			}
+			ids[id] = true
+		}
+		if rule.Prefix != nil && rule.Filter != nil {
+			return nil, request.ErrMalformedXML
+		}
+		if rule.Expiration == nil && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil &&
+			len(rule.Transitions) == 0 && len(rule.NoncurrentVersionTransitions) == 0 {
+			return nil, request.ErrMalformedXML
+		}
+
+		sdkRule := types.LifecycleRule{
+			ID:                             rule.ID,
+			Prefix:                         rule.Prefix,
+			Status:                         status,
+			Transitions:                    rule.Transitions,
+			NoncurrentVersionTransitions:   rule.NoncurrentVersionTransitions,
+			Expiration:                     rule.Expiration,
+			NoncurrentVersionExpiration:    rule.NoncurrentVersionExpiration,
+			AbortIncompleteMultipartUpload: rule.AbortIncompleteMultipartUpload,
+		}
+		if rule.Filter != nil {
+			filter, err := rule.Filter.toSDK()
+			if err != nil {
+				return nil, err
+			}
+			sdkRule.Filter = filter
+		}
+		result.Rules = append(result.Rules, sdkRule)
+	}
+	return result, nil
+}
+
+// toSDK converts a filter, which may have at most one condition
+func (f lifecycleFilter) toSDK() (*types.LifecycleRuleFilter, error) {
+	conditions := 0
+	for _, set := range []bool{f.Prefix != nil, f.Tag != nil, f.ObjectSizeGreaterThan != nil, f.ObjectSizeLessThan != nil, f.And != nil} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		return nil, request.ErrMalformedXML
+	}
+
+	result := &types.LifecycleRuleFilter{
+		Prefix:                f.Prefix,
+		Tag:                   f.Tag,
+		ObjectSizeGreaterThan: f.ObjectSizeGreaterThan,
+		ObjectSizeLessThan:    f.ObjectSizeLessThan,
+	}
+	if f.And != nil {
+		result.And = &types.LifecycleRuleAndOperator{
+			Prefix:                f.And.Prefix,
+			Tags:                  f.And.Tags,
+			ObjectSizeGreaterThan: f.And.ObjectSizeGreaterThan,
+			ObjectSizeLessThan:    f.And.ObjectSizeLessThan,
+		}
+	}
+	return result, nil
+}
+
+// lifecycleRuleFromSDK converts a rule read from the backend
+func lifecycleRuleFromSDK(rule types.LifecycleRule) lifecycleRule {
+	result := lifecycleRule{
+		ID:                             rule.ID,
+		Prefix:                         rule.Prefix,
+		Status:                         string(rule.Status),
+		Transitions:                    rule.Transitions,
+		NoncurrentVersionTransitions:   rule.NoncurrentVersionTransitions,
+		Expiration:                     rule.Expiration,
+		NoncurrentVersionExpiration:    rule.NoncurrentVersionExpiration,
+		AbortIncompleteMultipartUpload: rule.AbortIncompleteMultipartUpload,
+	}
+	if rule.Filter != nil {
+		result.Filter = &lifecycleFilter{
+			Prefix:                rule.Filter.Prefix,
+			Tag:                   rule.Filter.Tag,
+			ObjectSizeGreaterThan: rule.Filter.ObjectSizeGreaterThan,
+			ObjectSizeLessThan:    rule.Filter.ObjectSizeLessThan,
+		}
+		if and := rule.Filter.And; and != nil {
+			result.Filter.And = &lifecycleAnd{
+				Prefix:                and.Prefix,
+				Tags:                  and.Tags,
+				ObjectSizeGreaterThan: and.ObjectSizeGreaterThan,
+				ObjectSizeLessThan:    and.ObjectSizeLessThan,
+			}
+		}
+	}
+	return result
 }
 
 // handleDeleteBucketLifecycle deletes bucket lifecycle configuration
@@ -99,11 +269,10 @@ func (h *LifecycleHandler) handleDeleteBucketLifecycle(w http.ResponseWriter, r
 		Bucket: aws.String(bucket),
 	}
 
-	output, err := h.S3Backend.DeleteBucketLifecycle(r.Context(), input)
-	if err != nil {
+	if _, err := h.S3Backend.DeleteBucketLifecycle(r.Context(), input); err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,138 @@
+package bucket
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// Metadata keys (after the prefix) the object handler records for encrypted
+// objects
+const (
+	plaintextETagMetadataKey = "plaintext-etag"
+	plaintextSizeMetadataKey = "plaintext-size"
+)
+
+// listingHeadConcurrency bounds the HeadObject requests of one listing
+const listingHeadConcurrency = 8
+
+// translateListedObjects replaces the backend ETags and sizes of listed
+// objects with the plaintext ones HEAD reports, as configured. Listings do not
+// carry metadata, so every object is HEADed; objects whose HEAD fails keep
+// the backend values.
+func (h *Handler) translateListedObjects(ctx context.Context, bucket string, objects []s3types.Object) {
+	h.translateListed(ctx, bucket, len(objects), func(i int) listedEntry {
+		return listedEntry{key: objects[i].Key, etag: &objects[i].ETag, size: &objects[i].Size}
+	})
+}
+
+// translateListedVersions is translateListedObjects for a version listing,
+// HEADing every listed version
+func (h *Handler) translateListedVersions(ctx context.Context, bucket string, versions []s3types.ObjectVersion) {
+	h.translateListed(ctx, bucket, len(versions), func(i int) listedEntry {
+		return listedEntry{key: versions[i].Key, versionID: versions[i].VersionId, etag: &versions[i].ETag, size: &versions[i].Size}
+	})
+}
+
+// listedEntry points at the fields of a listed object or version
+type listedEntry struct {
+	key       *string
+	versionID *string
+	etag      **string
+	size      **int64
+}
+
+func (h *Handler) translateListed(ctx context.Context, bucket string, count int, entry func(int) listedEntry) {
+	if (!h.plaintextETags && !h.plaintextSizes) || count == 0 {
+		return
+	}
+
+	semaphore := make(chan struct{}, listingHeadConcurrency)
+	var wg sync.WaitGroup
+	for i := range count {
+		listed := entry(i)
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			head, err := h.s3Backend.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       listed.key,
+				VersionId: listed.versionID,
+			})
+			if err != nil {
+				h.logger.WithContext(ctx).WithError(err).WithField("key", aws.ToString(listed.key)).Debug("Failed to read object metadata, listing the backend values")
+				return
+			}
+			if h.plaintextETags {
+				if etag := head.Metadata[h.metadataPrefix+plaintextETagMetadataKey]; etag != "" {
+					*listed.etag = aws.String(`"` + etag + `"`)
+				}
+			}
+			if h.plaintextSizes {
+				*listed.size = h.plaintextSize(head.Metadata, *listed.size)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// plaintextSize returns the size a GET of an object of size stored bytes
+// returns: the recorded plaintext size, else the stored size less the nonces
+// and tags of aes-gcm, aes-gcm-siv and chacha20-poly1305 objects, else size.
+// It mirrors the Content-Length of HEAD.
+func (h *Handler) plaintextSize(metadata map[string]string, size *int64) *int64 {
+	if value, ok := metadata[h.metadataPrefix+plaintextSizeMetadataKey]; ok {
+		if recorded, err := strconv.ParseInt(value, 10, 64); err == nil && recorded >= 0 {
+			return aws.Int64(recorded)
+		}
+	}
+	_, encrypted := metadata[h.metadataPrefix+"encrypted-dek"]
+	if size == nil || !encrypted || metadata[h.metadataPrefix+"sse-c"] == config.SSECModePassthrough {
+		return size
+	}
+
+	dekAlgorithm, ok := metadata[h.metadataPrefix+"dek-algorithm"]
+	if !ok {
+		dekAlgorithm = "aes-gcm" // legacy objects, as on GET
+	}
+	segmentSize, _ := strconv.ParseInt(metadata[h.metadataPrefix+"segment-size"], 10, 64)
+	if plaintext := encryption.ComputePlaintextSize(*size, dekAlgorithm, segmentSize); plaintext >= 0 {
+		return aws.Int64(plaintext)
+	}
+	return size
+}
+
+// isInternalKey reports whether key, or every key below a common prefix,
+// belongs to the objects the proxy writes for itself. A common prefix that
+// cuts the internal prefix short may group client objects too and is kept.
+func (h *Handler) isInternalKey(key string) bool {
+	return h.internalKeyPrefix != "" && strings.HasPrefix(key, h.internalKeyPrefix)
+}
+
+// hideInternalObjects drops internal keys from listed objects and common
+// prefixes and returns how many entries were removed
+func (h *Handler) hideInternalObjects(objects *[]s3types.Object, prefixes *[]s3types.CommonPrefix) int {
+	if h.internalKeyPrefix == "" {
+		return 0
+	}
+	before := len(*objects) + len(*prefixes)
+	*objects = slices.DeleteFunc(*objects, func(object s3types.Object) bool {
+		return h.isInternalKey(aws.ToString(object.Key))
+	})
+	*prefixes = slices.DeleteFunc(*prefixes, func(prefix s3types.CommonPrefix) bool {
+		return h.isInternalKey(aws.ToString(prefix.Prefix))
+	})
+	return before - len(*objects) - len(*prefixes)
+}
@@ -0,0 +1,199 @@
+package bucket
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestListObjects_PlaintextETags(t *testing.T) {
+	backend := &MockS3Backend{}
+	cfg := &config.Config{Encryption: config.EncryptionConfig{ETagMode: config.ETagModePlaintext}}
+	handler := NewHandler(backend, logrus.NewEntry(logrus.New()), "s3ep-", cfg)
+
+	backend.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []s3types.Object{
+			{Key: aws.String("encrypted.txt"), ETag: aws.String(`"ciphertext"`)},
+			{Key: aws.String("legacy.txt"), ETag: aws.String(`"legacy"`)},
+			{Key: aws.String("gone.txt"), ETag: aws.String(`"gone"`)},
+		},
+	}, nil)
+	headFor := func(key string) interface{} {
+		return mock.MatchedBy(func(input *s3.HeadObjectInput) bool { return aws.ToString(input.Key) == key })
+	}
+	backend.On("HeadObject", mock.Anything, headFor("encrypted.txt")).Return(&s3.HeadObjectOutput{
+		Metadata: map[string]string{"s3ep-plaintext-etag": "0123abcd"},
+	}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("legacy.txt")).Return(&s3.HeadObjectOutput{}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("gone.txt")).Return(nil, errors.New("NoSuchKey"))
+
+	rr := httptest.NewRecorder()
+	handler.handleListObjects(rr, httptest.NewRequest(http.MethodGet, "/bucket?list-type=2", nil), "bucket")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, "0123abcd")
+	assert.NotContains(t, body, "ciphertext")
+	assert.Contains(t, body, "legacy")
+	assert.Contains(t, body, "gone")
+}
+
+func TestListObjects_BackendETags(t *testing.T) {
+	backend := &MockS3Backend{}
+	handler := NewHandler(backend, logrus.NewEntry(logrus.New()), "s3ep-", &config.Config{})
+
+	backend.On("ListObjects", mock.Anything, mock.Anything).Return(&s3.ListObjectsOutput{
+		Contents: []s3types.Object{{Key: aws.String("encrypted.txt"), ETag: aws.String(`"ciphertext"`)}},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.handleListObjects(rr, httptest.NewRequest(http.MethodGet, "/bucket", nil), "bucket")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "ciphertext")
+	backend.AssertNotCalled(t, "HeadObject", mock.Anything, mock.Anything)
+}
+
+func TestListObjects_PlaintextSizes(t *testing.T) {
+	backend := &MockS3Backend{}
+	cfg := &config.Config{Encryption: config.EncryptionConfig{ListPlaintextSizes: true}}
+	handler := NewHandler(backend, logrus.NewEntry(logrus.New()), "s3ep-", cfg)
+
+	backend.On("ListObjects", mock.Anything, mock.Anything).Return(&s3.ListObjectsOutput{
+		Contents: []s3types.Object{
+			{Key: aws.String("recorded.txt"), Size: aws.Int64(1128), ETag: aws.String(`"recorded"`)},
+			{Key: aws.String("legacy-gcm.txt"), Size: aws.Int64(1028)},
+			{Key: aws.String("ctr.bin"), Size: aws.Int64(2048)},
+			{Key: aws.String("sse-c.bin"), Size: aws.Int64(4096)},
+			{Key: aws.String("plain.txt"), Size: aws.Int64(512)},
+		},
+	}, nil)
+	headFor := func(key string) interface{} {
+		return mock.MatchedBy(func(input *s3.HeadObjectInput) bool { return aws.ToString(input.Key) == key })
+	}
+	backend.On("HeadObject", mock.Anything, headFor("recorded.txt")).Return(&s3.HeadObjectOutput{
+		Metadata: map[string]string{"s3ep-encrypted-dek": "dek", "s3ep-plaintext-size": "1100", "s3ep-plaintext-etag": "0123abcd"},
+	}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("legacy-gcm.txt")).Return(&s3.HeadObjectOutput{
+		Metadata: map[string]string{"s3ep-encrypted-dek": "dek"},
+	}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("ctr.bin")).Return(&s3.HeadObjectOutput{
+		Metadata: map[string]string{"s3ep-encrypted-dek": "dek", "s3ep-dek-algorithm": "aes-ctr"},
+	}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("sse-c.bin")).Return(&s3.HeadObjectOutput{
+		Metadata: map[string]string{"s3ep-encrypted-dek": "dek", "s3ep-sse-c": config.SSECModePassthrough},
+	}, nil)
+	backend.On("HeadObject", mock.Anything, headFor("plain.txt")).Return(&s3.HeadObjectOutput{}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.handleListObjects(rr, httptest.NewRequest(http.MethodGet, "/bucket", nil), "bucket")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var result struct {
+		Contents []struct {
+			Key  string
+			Size int64
+			ETag string
+		}
+	}
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &result))
+	sizes := make(map[string]int64)
+	for _, object := range result.Contents {
+		sizes[object.Key] = object.Size
+	}
+	assert.Equal(t, map[string]int64{
+		"recorded.txt":   1100,
+		"legacy-gcm.txt": 1000,
+		"ctr.bin":        2048,
+		"sse-c.bin":      4096,
+		"plain.txt":      512,
+	}, sizes)
+	// ETags are only rewritten in plaintext ETag mode
+	assert.Equal(t, `"recorded"`, result.Contents[0].ETag)
+}
+
+func TestListObjects_HideInternalKeys(t *testing.T) {
+	backend := &MockS3Backend{}
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{HideInternalKeys: true},
+		Canary:     config.CanaryConfig{KeyPrefix: ".s3ep-canary/"},
+	}
+	handler := NewHandler(backend, logrus.NewEntry(logrus.New()), "s3ep-", cfg)
+
+	backend.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []s3types.Object{
+			{Key: aws.String(".s3ep-canary/host-1/probe")},
+			{Key: aws.String(".s3ep-canary-notes.txt")},
+			{Key: aws.String("data.txt")},
+		},
+		CommonPrefixes: []s3types.CommonPrefix{{Prefix: aws.String(".s3ep-canary/")}, {Prefix: aws.String("logs/")}},
+		KeyCount:       aws.Int32(5),
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.handleListObjects(rr, httptest.NewRequest(http.MethodGet, "/bucket?list-type=2", nil), "bucket")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	assert.NotContains(t, body, ".s3ep-canary/")
+	assert.Contains(t, body, ".s3ep-canary-notes.txt")
+	assert.Contains(t, body, "data.txt")
+	assert.Contains(t, body, "logs/")
+	assert.Contains(t, body, "<KeyCount>3</KeyCount>")
+	backend.AssertNotCalled(t, "HeadObject", mock.Anything, mock.Anything)
+}
+
+func TestListObjectVersions(t *testing.T) {
+	backend := &MockS3Backend{}
+	cfg := &config.Config{Encryption: config.EncryptionConfig{ETagMode: config.ETagModePlaintext}}
+	handler := NewHandler(backend, logrus.NewEntry(logrus.New()), "s3ep-", cfg)
+
+	backend.On("ListObjectVersions", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectVersionsInput) bool {
+		return aws.ToString(input.Prefix) == "doc" && aws.ToString(input.KeyMarker) == "doc.txt" && aws.ToInt32(input.MaxKeys) == 2
+	})).Return(&s3.ListObjectVersionsOutput{
+		Versions: []s3types.ObjectVersion{
+			{Key: aws.String("doc.txt"), VersionId: aws.String("v2"), IsLatest: aws.Bool(false), ETag: aws.String(`"ciphertext-2"`)},
+			{Key: aws.String("doc.txt"), VersionId: aws.String("v1"), IsLatest: aws.Bool(false), ETag: aws.String(`"ciphertext-1"`)},
+		},
+		DeleteMarkers: []s3types.DeleteMarkerEntry{{Key: aws.String("doc.txt"), VersionId: aws.String("v3"), IsLatest: aws.Bool(true)}},
+		IsTruncated:   aws.Bool(false),
+	}, nil)
+	// Every version is translated with its own metadata
+	for _, version := range []string{"v1", "v2"} {
+		backend.On("HeadObject", mock.Anything, mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+			return aws.ToString(input.VersionId) == version
+		})).Return(&s3.HeadObjectOutput{Metadata: map[string]string{"s3ep-plaintext-etag": "plain-" + version}}, nil)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.handleListObjects(rr, httptest.NewRequest(http.MethodGet, "/bucket?versions&prefix=doc&key-marker=doc.txt&max-keys=2", nil), "bucket")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var result struct {
+		Versions []struct {
+			VersionID string `xml:"VersionId"`
+			ETag      string `xml:"ETag"`
+		} `xml:"Version"`
+		DeleteMarkers []struct {
+			VersionID string `xml:"VersionId"`
+			IsLatest  bool   `xml:"IsLatest"`
+		} `xml:"DeleteMarker"`
+	}
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &result))
+	require.Len(t, result.Versions, 2)
+	assert.Equal(t, `"plain-v2"`, result.Versions[0].ETag)
+	assert.Equal(t, `"plain-v1"`, result.Versions[1].ETag)
+	require.Len(t, result.DeleteMarkers, 1)
+	assert.True(t, result.DeleteMarkers[0].IsLatest)
+}
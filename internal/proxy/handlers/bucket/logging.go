@@ -1,14 +1,18 @@
 package bucket
 
 import (
+	"bytes"
 	"encoding/xml"
 	"net/http"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // BucketLoggingStatus represents the XML structure for bucket logging configuration
@@ -46,11 +50,36 @@ type Grantee struct {
 // LoggingHandler handles bucket logging operations
 type LoggingHandler struct {
 	BaseSubResourceHandler
+	targetPrefix string // prepended to client TargetPrefix values, empty = passthrough
+}
+
+// NewLoggingHandler creates a new logging handler. A non-empty targetPrefix is
+// added in front of the TargetPrefix of every configuration written to the
+// backend and removed again when reading it, so server access logs of traffic
+// configured through the proxy are kept apart from those of direct access.
+func NewLoggingHandler(base BaseSubResourceHandler, targetPrefix string) *LoggingHandler {
+	return &LoggingHandler{BaseSubResourceHandler: base, targetPrefix: targetPrefix}
 }
 
-// NewLoggingHandler creates a new logging handler
-func NewLoggingHandler(base BaseSubResourceHandler) *LoggingHandler {
-	return &LoggingHandler{BaseSubResourceHandler: base}
+// backendTargetPrefix returns the TargetPrefix to store on the backend for a
+// client value. Values that already carry the proxy prefix are kept as is.
+func (h *LoggingHandler) backendTargetPrefix(prefix *string) *string {
+	if h.targetPrefix == "" {
+		return prefix
+	}
+	value := aws.ToString(prefix)
+	if strings.HasPrefix(value, h.targetPrefix) {
+		return aws.String(value)
+	}
+	return aws.String(h.targetPrefix + value)
+}
+
+// clientTargetPrefix reverses backendTargetPrefix for a value read from the backend
+func (h *LoggingHandler) clientTargetPrefix(prefix *string) *string {
+	if h.targetPrefix == "" || prefix == nil {
+		return prefix
+	}
+	return aws.String(strings.TrimPrefix(*prefix, h.targetPrefix))
 }
 
 // Handle handles bucket logging operations (?logging)
@@ -100,7 +129,7 @@ func (h *LoggingHandler) handleGetLogging(w http.ResponseWriter, r *http.Request
 		}
 
 		if output.LoggingEnabled.TargetPrefix != nil {
-			loggingEnabled.TargetPrefix = output.LoggingEnabled.TargetPrefix
+			loggingEnabled.TargetPrefix = h.clientTargetPrefix(output.LoggingEnabled.TargetPrefix)
 		}
 
 		// Convert grants if present
@@ -166,7 +195,7 @@ func (h *LoggingHandler) handlePutLogging(w http.ResponseWriter, r *http.Request
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket logging configuration")
 
 	// Read the request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
@@ -175,7 +204,7 @@ func (h *LoggingHandler) handlePutLogging(w http.ResponseWriter, r *http.Request
 	// Validate that body is not empty
 	if len(body) == 0 {
 		h.Logger.WithField("bucket", bucket).Error("Empty logging configuration in request body")
-		h.ErrorWriter.WriteGenericError(w, http.StatusBadRequest, "MalformedXML", "Request body cannot be empty for logging configuration")
+		h.ErrorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", "Request body cannot be empty for logging configuration")
 		return
 	}
 
@@ -185,12 +214,12 @@ func (h *LoggingHandler) handlePutLogging(w http.ResponseWriter, r *http.Request
 
 	// Parse XML body
 	var loggingConfig BucketLoggingStatus
-	if err := xml.Unmarshal(body, &loggingConfig); err != nil {
+	if err := request.DecodeXML(bytes.NewReader(body), "BucketLoggingStatus", &loggingConfig, request.XMLLimits{}); err != nil {
 		h.Logger.WithFields(logrus.Fields{
 			"bucket": bucket,
 			"error":  err,
 		}).Error("Failed to parse logging configuration XML")
-		h.ErrorWriter.WriteGenericError(w, http.StatusBadRequest, "MalformedXML", "Invalid XML format")
+		h.ErrorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", "Invalid XML format")
 		return
 	}
 
@@ -202,9 +231,8 @@ func (h *LoggingHandler) handlePutLogging(w http.ResponseWriter, r *http.Request
 			loggingEnabled.TargetBucket = loggingConfig.LoggingEnabled.TargetBucket
 		}
 
-		if loggingConfig.LoggingEnabled.TargetPrefix != nil {
-			loggingEnabled.TargetPrefix = loggingConfig.LoggingEnabled.TargetPrefix
-		}
+		// The proxy prefix is applied even when the client sent no TargetPrefix
+		loggingEnabled.TargetPrefix = h.backendTargetPrefix(loggingConfig.LoggingEnabled.TargetPrefix)
 
 		// Convert grants if present
 		if loggingConfig.LoggingEnabled.TargetGrants != nil {
@@ -447,3 +447,61 @@ func TestBucketLoggingInvalidXML(t *testing.T) {
 		})
 	}
 }
+
+// TestBucketLoggingTargetPrefixEnrichment tests that the configured proxy prefix is
+// added to the backend configuration and hidden from clients
+func TestBucketLoggingTargetPrefixEnrichment(t *testing.T) {
+	cfg := &config.Config{S3Backend: config.S3BackendConfig{
+		AccessLogging: config.AccessLoggingConfig{TargetPrefix: "s3ep/"},
+	}}
+
+	tests := []struct {
+		name         string
+		clientPrefix string
+		backend      string
+	}{
+		{name: "prefix added", clientPrefix: "<TargetPrefix>logs/</TargetPrefix>", backend: "s3ep/logs/"},
+		{name: "not added twice", clientPrefix: "<TargetPrefix>s3ep/logs/</TargetPrefix>", backend: "s3ep/logs/"},
+		{name: "missing client prefix", clientPrefix: "", backend: "s3ep/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockS3Backend := &MockS3Backend{}
+			mockS3Backend.On("PutBucketLogging", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketLoggingInput) bool {
+				enabled := input.BucketLoggingStatus.LoggingEnabled
+				return enabled != nil && enabled.TargetPrefix != nil && *enabled.TargetPrefix == tt.backend
+			})).Return(&s3.PutBucketLoggingOutput{}, nil).Once()
+			handler := NewHandler(mockS3Backend, testLogger(), "s3ep-", cfg)
+
+			body := "<BucketLoggingStatus><LoggingEnabled><TargetBucket>access-logs</TargetBucket>" +
+				tt.clientPrefix + "</LoggingEnabled></BucketLoggingStatus>"
+			req := httptest.NewRequest("PUT", "/test-bucket?logging", strings.NewReader(body))
+			req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket"})
+			rr := httptest.NewRecorder()
+
+			handler.GetLoggingHandler().Handle(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			mockS3Backend.AssertExpectations(t)
+		})
+	}
+
+	t.Run("stripped on GET", func(t *testing.T) {
+		mockS3Backend := &MockS3Backend{}
+		backendPrefix := "s3ep/logs/"
+		mockS3Backend.On("GetBucketLogging", mock.Anything, mock.Anything).Return(&s3.GetBucketLoggingOutput{
+			LoggingEnabled: &types.LoggingEnabled{TargetPrefix: &backendPrefix},
+		}, nil)
+		handler := NewHandler(mockS3Backend, testLogger(), "s3ep-", cfg)
+
+		req := httptest.NewRequest("GET", "/test-bucket?logging", nil)
+		req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket"})
+		rr := httptest.NewRecorder()
+
+		handler.GetLoggingHandler().Handle(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "<TargetPrefix>logs/</TargetPrefix>")
+	})
+}
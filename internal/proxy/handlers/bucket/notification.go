@@ -7,6 +7,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // NotificationHandler handles bucket notification operations
@@ -61,7 +63,7 @@ func (h *NotificationHandler) handlePutBucketNotificationConfiguration(w http.Re
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket notification configuration")
 
 	// Read the request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
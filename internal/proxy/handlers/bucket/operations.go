@@ -4,21 +4,25 @@ import (
 	"encoding/xml"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
 )
 
 // handleListObjects handles listing objects in a bucket (GET /bucket)
 func (h *Handler) handleListObjects(w http.ResponseWriter, r *http.Request, bucket string) {
-	h.logger.WithField("bucket", bucket).Debug("Listing objects in bucket")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Listing objects in bucket")
 
-	// Check if this is a ListObjectsV2 or ListObjects request
+	// Check if this is a ListObjectVersions, ListObjectsV2 or ListObjects request
 	query := r.URL.Query()
 
-	if query.Get("list-type") == "2" {
+	if query.Has("versions") {
+		h.handleListObjectVersions(w, r, bucket)
+	} else if query.Get("list-type") == "2" {
 		// ListObjectsV2
 		input := &s3.ListObjectsV2Input{
 			Bucket: aws.String(bucket),
@@ -47,10 +51,14 @@ func (h *Handler) handleListObjects(w http.ResponseWriter, r *http.Request, buck
 			utils.HandleS3Error(w, h.logger, err, "Failed to list objects", bucket, "")
 			return
 		}
+		if hidden := h.hideInternalObjects(&output.Contents, &output.CommonPrefixes); hidden > 0 && output.KeyCount != nil {
+			output.KeyCount = aws.Int32(max(aws.ToInt32(output.KeyCount)-int32(hidden), 0)) // #nosec G115 - at most one page of entries
+		}
+		h.translateListedObjects(r.Context(), bucket, output.Contents)
 
 		w.Header().Set("Content-Type", "application/xml")
 		if err := xml.NewEncoder(w).Encode(output); err != nil {
-			h.logger.WithError(err).Error("Failed to encode list objects response")
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to encode list objects response")
 		}
 	} else {
 		// ListObjects
@@ -74,17 +82,19 @@ func (h *Handler) handleListObjects(w http.ResponseWriter, r *http.Request, buck
 			utils.HandleS3Error(w, h.logger, err, "Failed to list objects", bucket, "")
 			return
 		}
+		h.hideInternalObjects(&output.Contents, &output.CommonPrefixes)
+		h.translateListedObjects(r.Context(), bucket, output.Contents)
 
 		w.Header().Set("Content-Type", "application/xml")
 		if err := xml.NewEncoder(w).Encode(output); err != nil {
-			h.logger.WithError(err).Error("Failed to encode list objects response")
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to encode list objects response")
 		}
 	}
 }
 
 // handleCreateBucket handles creating a bucket (PUT /bucket)
 func (h *Handler) handleCreateBucket(w http.ResponseWriter, r *http.Request, bucket string) {
-	h.logger.WithField("bucket", bucket).Debug("Creating bucket")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Creating bucket")
 
 	// Parse the request to build CreateBucketInput
 	input := &s3.CreateBucketInput{
@@ -97,15 +107,18 @@ func (h *Handler) handleCreateBucket(w http.ResponseWriter, r *http.Request, buc
 			LocationConstraint string `xml:"LocationConstraint"`
 		}
 
-		if err := xml.NewDecoder(r.Body).Decode(&createBucketConfig); err == nil {
-			if createBucketConfig.LocationConstraint != "" {
-				input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
-					LocationConstraint: s3types.BucketLocationConstraint(createBucketConfig.LocationConstraint),
-				}
-			}
+		err := request.DecodeXML(r.Body, "CreateBucketConfiguration", &createBucketConfig, request.XMLLimits{MaxBytes: request.MaxXMLConfigSize})
+		if closeErr := r.Body.Close(); closeErr != nil {
+			h.logger.WithContext(r.Context()).WithError(closeErr).Debug("Failed to close request body")
+		}
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, "")
+			return
 		}
-		if err := r.Body.Close(); err != nil {
-			h.logger.WithError(err).Debug("Failed to close request body")
+		if createBucketConfig.LocationConstraint != "" {
+			input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
+				LocationConstraint: s3types.BucketLocationConstraint(createBucketConfig.LocationConstraint),
+			}
 		}
 	}
 
@@ -134,6 +147,11 @@ func (h *Handler) handleCreateBucket(w http.ResponseWriter, r *http.Request, buc
 		input.GrantWriteACP = aws.String(grantWriteACP)
 	}
 
+	// Object lock can only be enabled when the bucket is created
+	if strings.EqualFold(r.Header.Get("x-amz-bucket-object-lock-enabled"), "true") {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+
 	// Create the bucket
 	output, err := h.s3Backend.CreateBucket(r.Context(), input)
 	if err != nil {
@@ -150,12 +168,12 @@ func (h *Handler) handleCreateBucket(w http.ResponseWriter, r *http.Request, buc
 
 	w.WriteHeader(http.StatusOK)
 
-	h.logger.WithField("bucket", bucket).Debug("Bucket created successfully")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Bucket created successfully")
 }
 
 // handleDeleteBucket handles deleting a bucket (DELETE /bucket)
 func (h *Handler) handleDeleteBucket(w http.ResponseWriter, r *http.Request, bucket string) {
-	h.logger.WithField("bucket", bucket).Debug("Deleting bucket")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Deleting bucket")
 
 	// Create the DeleteBucketInput
 	input := &s3.DeleteBucketInput{
@@ -177,12 +195,12 @@ func (h *Handler) handleDeleteBucket(w http.ResponseWriter, r *http.Request, buc
 	// Success - no content response
 	w.WriteHeader(http.StatusNoContent)
 
-	h.logger.WithField("bucket", bucket).Debug("Bucket deleted successfully")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Bucket deleted successfully")
 }
 
 // handleHeadBucket handles bucket metadata requests (HEAD /bucket)
 func (h *Handler) handleHeadBucket(w http.ResponseWriter, r *http.Request, bucket string) {
-	h.logger.WithField("bucket", bucket).Debug("Getting bucket metadata")
+	h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Getting bucket metadata")
 
 	// For HEAD requests, we typically just need to check if the bucket exists
 	// We can do this by trying to list objects with max-keys=0
@@ -80,7 +80,7 @@ func (h *PolicyHandler) handlePutPolicy(w http.ResponseWriter, r *http.Request,
 	// Validate that body is not empty
 	if len(body) == 0 {
 		h.Logger.WithField("bucket", bucket).Error("Empty policy in request body")
-		h.ErrorWriter.WriteGenericError(w, http.StatusBadRequest, "MalformedPolicy", "Request body cannot be empty for policy configuration")
+		h.ErrorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedPolicy", "Request body cannot be empty for policy configuration")
 		return
 	}
 
@@ -92,7 +92,7 @@ func (h *PolicyHandler) handlePutPolicy(w http.ResponseWriter, r *http.Request,
 			"bucket": bucket,
 			"error":  err,
 		}).Error("Failed to parse policy JSON")
-		h.ErrorWriter.WriteGenericError(w, http.StatusBadRequest, "MalformedPolicy", "Invalid JSON format")
+		h.ErrorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedPolicy", "Invalid JSON format")
 		return
 	}
 
@@ -1,12 +1,16 @@
 package bucket
 
 import (
+	"encoding/xml"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
 )
 
 // RequestPaymentHandler handles bucket request payment operations
@@ -39,7 +43,16 @@ func (h *RequestPaymentHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGetBucketRequestPayment gets bucket request payment configuration
+// requestPaymentConfiguration is the GetBucketRequestPayment response body
+type requestPaymentConfiguration struct {
+	XMLName xml.Name `xml:"RequestPaymentConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Payer   string   `xml:"Payer"`
+}
+
+// handleGetBucketRequestPayment gets bucket request payment configuration.
+// Backends without requester-pays support get the AWS default (the bucket
+// owner pays) instead of an error.
 func (h *RequestPaymentHandler) handleGetBucketRequestPayment(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket request payment configuration")
 
@@ -47,13 +60,24 @@ func (h *RequestPaymentHandler) handleGetBucketRequestPayment(w http.ResponseWri
 		Bucket: aws.String(bucket),
 	}
 
+	result := requestPaymentConfiguration{
+		Xmlns: s3XMLNamespace,
+		Payer: string(types.PayerBucketOwner),
+	}
 	output, err := h.S3Backend.GetBucketRequestPayment(r.Context(), input)
-	if err != nil {
+	switch {
+	case err == nil:
+		if output.Payer != "" {
+			result.Payer = string(output.Payer)
+		}
+	case utils.IsBackendUnsupported(err):
+		h.Logger.WithError(err).WithField("bucket", bucket).Debug("Backend does not support request payment, returning default")
+	default:
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutBucketRequestPayment sets bucket request payment configuration
@@ -1,12 +1,19 @@
 package bucket
 
 import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
 	"net/http"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // TaggingHandler handles bucket tagging operations
@@ -41,6 +48,21 @@ func (h *TaggingHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxBucketTags is the number of tags S3 allows on a bucket
+const maxBucketTags = 50
+
+// bucketTagging is the body of GetBucketTagging and PutBucketTagging
+type bucketTagging struct {
+	XMLName xml.Name    `xml:"Tagging"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	TagSet  []bucketTag `xml:"TagSet>Tag"`
+}
+
+type bucketTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
 // handleGetBucketTagging gets bucket tags
 func (h *TaggingHandler) handleGetBucketTagging(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket tags")
@@ -55,7 +77,11 @@ func (h *TaggingHandler) handleGetBucketTagging(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	result := bucketTagging{Xmlns: s3XMLNamespace, TagSet: []bucketTag{}}
+	for _, t := range output.TagSet {
+		result.TagSet = append(result.TagSet, bucketTag{Key: aws.ToString(t.Key), Value: aws.ToString(t.Value)})
+	}
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutBucketTagging sets bucket tags
@@ -63,32 +89,58 @@ func (h *TaggingHandler) handlePutBucketTagging(w http.ResponseWriter, r *http.R
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket tags")
 
 	// Read the request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	input := &s3.PutBucketTaggingInput{
-		Bucket: aws.String(bucket),
+	var requested bucketTagging
+	if err := request.DecodeXML(bytes.NewReader(body), "Tagging", &requested, request.XMLLimits{}); err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
 	}
-
-	// Parse tagging configuration from body
-	// Note: For now, we'll let the S3 client handle the body parsing
-	// In a more complete implementation, we'd parse the XML here
-	if len(body) > 0 {
-		// This would need proper XML parsing in a complete implementation
-		h.ErrorWriter.WriteNotImplemented(w, "PutBucketTagging with body parsing")
+	if message := validateBucketTags(requested.TagSet); message != "" {
+		h.ErrorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidTag", message)
 		return
 	}
 
-	output, err := h.S3Backend.PutBucketTagging(r.Context(), input)
-	if err != nil {
+	tagSet := make([]types.Tag, 0, len(requested.TagSet))
+	for _, t := range requested.TagSet {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(t.Key), Value: aws.String(t.Value)})
+	}
+
+	input := &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	}
+	if _, err := h.S3Backend.PutBucketTagging(r.Context(), input); err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateBucketTags applies the limits S3 has for bucket tags and returns
+// the S3 error message of the first violation, or ""
+func validateBucketTags(tags []bucketTag) string {
+	if len(tags) > maxBucketTags {
+		return fmt.Sprintf("Bucket tag count cannot be greater than %d", maxBucketTags)
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		switch {
+		case t.Key == "" || utf8.RuneCountInString(t.Key) > 128:
+			return "The TagKey you have provided is invalid"
+		case utf8.RuneCountInString(t.Value) > 256:
+			return "The TagValue you have provided is invalid"
+		case seen[t.Key]:
+			return "Cannot provide multiple Tags with the same key"
+		}
+		seen[t.Key] = true
+	}
+	return ""
 }
 
 // handleDeleteBucketTagging deletes bucket tags
@@ -99,11 +151,10 @@ func (h *TaggingHandler) handleDeleteBucketTagging(w http.ResponseWriter, r *htt
 		Bucket: aws.String(bucket),
 	}
 
-	output, err := h.S3Backend.DeleteBucketTagging(r.Context(), input)
-	if err != nil {
+	if _, err := h.S3Backend.DeleteBucketTagging(r.Context(), input); err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	w.WriteHeader(http.StatusNoContent)
 }
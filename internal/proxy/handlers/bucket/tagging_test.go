@@ -63,19 +63,17 @@ func TestTaggingHandler_Handle(t *testing.T) {
 			name:           "PUT bucket tagging - empty body",
 			method:         "PUT",
 			bucket:         "test-bucket",
-			expectedStatus: http.StatusOK,
+			expectedStatus: http.StatusBadRequest,
 			setupMock: func(m *MockS3Backend) {
-				m.On("PutBucketTagging", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketTaggingInput) bool {
-					return *input.Bucket == "test-bucket"
-				})).Return(&s3.PutBucketTaggingOutput{}, nil)
+				// The body is rejected before the backend is called
 			},
-			expectedBody: "",
+			expectedBody: "MalformedXML",
 		},
 		{
 			name:           "DELETE bucket tagging - success",
 			method:         "DELETE",
 			bucket:         "test-bucket",
-			expectedStatus: http.StatusOK,
+			expectedStatus: http.StatusNoContent,
 			setupMock: func(m *MockS3Backend) {
 				m.On("DeleteBucketTagging", mock.Anything, mock.MatchedBy(func(input *s3.DeleteBucketTaggingInput) bool {
 					return *input.Bucket == "test-bucket"
@@ -215,7 +213,7 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 					</Tag>
 				</TagSet>
 			</Tagging>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusNoContent,
 			description:    "Standard single tag request",
 		},
 		{
@@ -232,7 +230,7 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 					</Tag>
 				</TagSet>
 			</Tagging>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusNoContent,
 			description:    "Standard multiple tags request",
 		},
 		{
@@ -244,14 +242,14 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 						<Value>Production</Value>
 					</Tag>
 				</TagSet>`, // Missing closing tag
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Malformed XML should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Malformed XML is rejected",
 		},
 		{
 			name:           "Empty body",
 			body:           "",
-			expectedStatus: http.StatusOK, // Empty body calls S3 client
-			description:    "Empty body should call S3 client with no Tagging",
+			expectedStatus: http.StatusBadRequest,
+			description:    "A tag set is required",
 		},
 		{
 			name: "Empty tag key",
@@ -263,8 +261,8 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 					</Tag>
 				</TagSet>
 			</Tagging>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Empty tag key should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Empty tag key is rejected",
 		},
 		{
 			name: "Tag key too long",
@@ -276,8 +274,8 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 					</Tag>
 				</TagSet>
 			</Tagging>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Tag key over 128 characters should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Tag key over 128 characters is rejected",
 		},
 		{
 			name: "Tag value too long",
@@ -289,8 +287,8 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 					</Tag>
 				</TagSet>
 			</Tagging>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Tag value over 256 characters should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Tag value over 256 characters is rejected",
 		},
 	}
 
@@ -299,10 +297,9 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 			// Setup mock S3 client
 			mockS3Backend := &MockS3Backend{}
 
-			// Add mock for empty body test that calls S3 client
-			if tt.name == "Empty body" {
+			if tt.expectedStatus == http.StatusNoContent {
 				mockS3Backend.On("PutBucketTagging", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketTaggingInput) bool {
-					return *input.Bucket == "test-bucket"
+					return *input.Bucket == "test-bucket" && len(input.Tagging.TagSet) > 0
 				})).Return(&s3.PutBucketTaggingOutput{}, nil)
 			}
 
@@ -329,6 +326,7 @@ func TestTaggingHandler_XMLTagValidation(t *testing.T) {
 
 			// Assert
 			assert.Equal(t, tt.expectedStatus, w.Code, tt.description)
+			mockS3Backend.AssertExpectations(t)
 		})
 	}
 }
@@ -474,3 +472,13 @@ func TestTaggingHandler_MaxTagLimits(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateBucketTags(t *testing.T) {
+	tags := make([]bucketTag, 0, maxBucketTags+1)
+	for i := 0; i < maxBucketTags; i++ {
+		tags = append(tags, bucketTag{Key: fmt.Sprintf("key%d", i)})
+	}
+	assert.Empty(t, validateBucketTags(tags))
+	assert.NotEmpty(t, validateBucketTags(append(tags, bucketTag{Key: "one-more"})))
+	assert.Contains(t, validateBucketTags([]bucketTag{{Key: "a"}, {Key: "a"}}), "same key")
+}
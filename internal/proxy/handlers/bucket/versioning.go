@@ -1,12 +1,17 @@
 package bucket
 
 import (
+	"bytes"
+	"encoding/xml"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // VersioningHandler handles bucket versioning operations
@@ -39,6 +44,14 @@ func (h *VersioningHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// versioningConfiguration is the body of GetBucketVersioning and PutBucketVersioning
+type versioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
 // handleGetBucketVersioning gets bucket versioning configuration
 func (h *VersioningHandler) handleGetBucketVersioning(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket versioning configuration")
@@ -53,7 +66,11 @@ func (h *VersioningHandler) handleGetBucketVersioning(w http.ResponseWriter, r *
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	h.XMLWriter.WriteXML(w, versioningConfiguration{
+		Xmlns:     s3XMLNamespace,
+		Status:    string(output.Status),
+		MfaDelete: string(output.MFADelete),
+	})
 }
 
 // handlePutBucketVersioning sets bucket versioning configuration
@@ -61,30 +78,42 @@ func (h *VersioningHandler) handlePutBucketVersioning(w http.ResponseWriter, r *
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket versioning configuration")
 
 	// Read the request body
-	body, err := h.RequestParser.ReadBody(r)
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
 	if err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	input := &s3.PutBucketVersioningInput{
-		Bucket: aws.String(bucket),
+	var config versioningConfiguration
+	if err := request.DecodeXML(bytes.NewReader(body), "VersioningConfiguration", &config, request.XMLLimits{}); err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
 	}
 
-	// Parse versioning configuration from body
-	// Note: For now, we'll let the S3 client handle the body parsing
-	// In a more complete implementation, we'd parse the XML here
-	if len(body) > 0 {
-		// This would need proper XML parsing in a complete implementation
-		h.ErrorWriter.WriteNotImplemented(w, "PutBucketVersioning with body parsing")
+	status := types.BucketVersioningStatus(config.Status)
+	mfaDelete := types.MFADelete(config.MfaDelete)
+	if (status != types.BucketVersioningStatusEnabled && status != types.BucketVersioningStatusSuspended) ||
+		(mfaDelete != "" && mfaDelete != types.MFADeleteEnabled && mfaDelete != types.MFADeleteDisabled) {
+		h.ErrorWriter.WriteS3Error(w, request.ErrMalformedXML, bucket, "")
 		return
 	}
 
-	output, err := h.S3Backend.PutBucketVersioning(r.Context(), input)
-	if err != nil {
+	input := &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status:    status,
+			MFADelete: mfaDelete,
+		},
+	}
+	// Changing MfaDelete has to be authorized with the device of the root account
+	if mfa := r.Header.Get("x-amz-mfa"); mfa != "" {
+		input.MFA = aws.String(mfa)
+	}
+
+	if _, err := h.S3Backend.PutBucketVersioning(r.Context(), input); err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	w.WriteHeader(http.StatusOK)
 }
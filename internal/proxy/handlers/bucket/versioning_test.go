@@ -60,13 +60,11 @@ func TestVersioningHandler_Handle(t *testing.T) {
 			name:           "PUT bucket versioning - empty body",
 			method:         "PUT",
 			bucket:         "test-bucket",
-			expectedStatus: http.StatusOK,
-			setupMock: func(m *MockS3Backend) {
-				m.On("PutBucketVersioning", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketVersioningInput) bool {
-					return *input.Bucket == "test-bucket"
-				})).Return(&s3.PutBucketVersioningOutput{}, nil)
+			expectedStatus: http.StatusBadRequest,
+			setupMock: func(_ *MockS3Backend) {
+				// The body is rejected before the backend is called
 			},
-			expectedBody: "",
+			expectedBody: "MalformedXML",
 		},
 		{
 			name:           "POST bucket versioning - not supported",
@@ -182,38 +180,30 @@ func TestVersioningHandler_HandleErrors(t *testing.T) {
 	}
 }
 
-func TestVersioningHandler_MFAValidation(t *testing.T) {
+func TestVersioningHandler_MFAForwarded(t *testing.T) {
 	tests := []struct {
-		name          string
-		mfaHeader     string
-		expectedValid bool
+		name      string
+		mfaHeader string
 	}{
 		{
-			name:          "Valid MFA header",
-			mfaHeader:     "123456789012 123456",
-			expectedValid: true,
-		},
-		{
-			name:          "Empty MFA header",
-			mfaHeader:     "",
-			expectedValid: true, // Empty is allowed
+			name:      "MFA header",
+			mfaHeader: "123456789012 123456",
 		},
 		{
-			name:          "Invalid MFA format - no space",
-			mfaHeader:     "123456789012123456",
-			expectedValid: false,
-		},
-		{
-			name:          "Invalid MFA format - too short",
-			mfaHeader:     "123 456",
-			expectedValid: false,
+			name:      "No MFA header",
+			mfaHeader: "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Setup mock S3 client
+			// The backend validates the MFA token
 			mockS3Backend := &MockS3Backend{}
+			mockS3Backend.On("PutBucketVersioning", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketVersioningInput) bool {
+				return aws.ToString(input.MFA) == tt.mfaHeader &&
+					input.VersioningConfiguration.Status == types.BucketVersioningStatusEnabled &&
+					input.VersioningConfiguration.MFADelete == types.MFADeleteEnabled
+			})).Return(&s3.PutBucketVersioningOutput{}, nil)
 
 			// Create logger
 			logger := logrus.NewEntry(logrus.New())
@@ -226,7 +216,7 @@ func TestVersioningHandler_MFAValidation(t *testing.T) {
 			handler := NewVersioningHandler(NewBaseSubResourceHandler(mockS3Backend, logger, xmlWriter, errorWriter, request.NewParser(logger, &config.Config{})))
 
 			// Setup request with MFA header
-			req := httptest.NewRequest("PUT", "/test-bucket?versioning", strings.NewReader(`<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`))
+			req := httptest.NewRequest("PUT", "/test-bucket?versioning", strings.NewReader(`<VersioningConfiguration><Status>Enabled</Status><MfaDelete>Enabled</MfaDelete></VersioningConfiguration>`))
 			req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket"})
 			if tt.mfaHeader != "" {
 				req.Header.Set("x-amz-mfa", tt.mfaHeader)
@@ -238,9 +228,8 @@ func TestVersioningHandler_MFAValidation(t *testing.T) {
 			// Execute
 			handler.Handle(w, req)
 
-			// For now, all PUT operations return not implemented
-			// This test prepares for future MFA validation implementation
-			assert.Equal(t, http.StatusNotImplemented, w.Code)
+			assert.Equal(t, http.StatusOK, w.Code)
+			mockS3Backend.AssertExpectations(t)
 		})
 	}
 }
@@ -255,32 +244,32 @@ func TestVersioningHandler_XMLParsing(t *testing.T) {
 		{
 			name:           "Valid versioning configuration - Enabled",
 			body:           `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Standard enable versioning request",
 		},
 		{
 			name:           "Valid versioning configuration - Suspended",
 			body:           `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Standard suspend versioning request",
 		},
 		{
 			name:           "Invalid XML format",
 			body:           `<VersioningConfiguration><Status>Enabled</Status>`, // Missing closing tag
-			expectedStatus: http.StatusNotImplemented,                           // PUT not implemented yet
-			description:    "Malformed XML should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Malformed XML is rejected",
 		},
 		{
 			name:           "Empty body",
 			body:           "",
-			expectedStatus: http.StatusOK, // Empty body calls S3 client
-			description:    "Empty body should call S3 client with no VersioningConfiguration",
+			expectedStatus: http.StatusBadRequest,
+			description:    "A versioning configuration is required",
 		},
 		{
 			name:           "Invalid status value",
 			body:           `<VersioningConfiguration><Status>Invalid</Status></VersioningConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Invalid status should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Invalid status is rejected",
 		},
 	}
 
@@ -289,10 +278,9 @@ func TestVersioningHandler_XMLParsing(t *testing.T) {
 			// Setup mock S3 client
 			mockS3Backend := &MockS3Backend{}
 
-			// Add mock for empty body test that calls S3 client
-			if tt.name == "Empty body" {
+			if tt.expectedStatus == http.StatusOK {
 				mockS3Backend.On("PutBucketVersioning", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketVersioningInput) bool {
-					return *input.Bucket == "test-bucket"
+					return *input.Bucket == "test-bucket" && input.VersioningConfiguration.Status != ""
 				})).Return(&s3.PutBucketVersioningOutput{}, nil)
 			}
 
@@ -319,6 +307,7 @@ func TestVersioningHandler_XMLParsing(t *testing.T) {
 
 			// Assert
 			assert.Equal(t, tt.expectedStatus, w.Code, tt.description)
+			mockS3Backend.AssertExpectations(t)
 		})
 	}
 }
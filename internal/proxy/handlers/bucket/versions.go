@@ -0,0 +1,157 @@
+package bucket
+
+import (
+	"encoding/xml"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// listVersionsResult is the body of ListObjectVersions. The SDK output
+// groups versions and delete markers differently from S3's XML.
+type listVersionsResult struct {
+	XMLName             xml.Name             `xml:"ListVersionsResult"`
+	Xmlns               string               `xml:"xmlns,attr,omitempty"`
+	Name                string               `xml:"Name"`
+	Prefix              string               `xml:"Prefix"`
+	KeyMarker           string               `xml:"KeyMarker"`
+	VersionIDMarker     string               `xml:"VersionIdMarker"`
+	NextKeyMarker       string               `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string               `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int32                `xml:"MaxKeys"`
+	Delimiter           string               `xml:"Delimiter,omitempty"`
+	EncodingType        string               `xml:"EncodingType,omitempty"`
+	IsTruncated         bool                 `xml:"IsTruncated"`
+	Versions            []listedVersion      `xml:"Version"`
+	DeleteMarkers       []listedDeleteMarker `xml:"DeleteMarker"`
+	CommonPrefixes      []listedPrefix       `xml:"CommonPrefixes"`
+}
+
+type listedVersion struct {
+	Key          string       `xml:"Key"`
+	VersionID    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	ETag         string       `xml:"ETag"`
+	Size         int64        `xml:"Size"`
+	StorageClass string       `xml:"StorageClass,omitempty"`
+	Owner        *listedOwner `xml:"Owner,omitempty"`
+}
+
+type listedDeleteMarker struct {
+	Key          string       `xml:"Key"`
+	VersionID    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	Owner        *listedOwner `xml:"Owner,omitempty"`
+}
+
+type listedOwner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+}
+
+type listedPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// handleListObjectVersions handles GET /bucket?versions. Listed versions get
+// the plaintext ETags and sizes of their own metadata, like ListObjects.
+func (h *Handler) handleListObjectVersions(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+	if prefix := query.Get("prefix"); prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter := query.Get("delimiter"); delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	if keyMarker := query.Get("key-marker"); keyMarker != "" {
+		input.KeyMarker = aws.String(keyMarker)
+	}
+	if versionIDMarker := query.Get("version-id-marker"); versionIDMarker != "" {
+		input.VersionIdMarker = aws.String(versionIDMarker)
+	}
+	if encodingType := query.Get("encoding-type"); encodingType != "" {
+		input.EncodingType = s3types.EncodingType(encodingType)
+	}
+	if maxKeys, err := strconv.Atoi(query.Get("max-keys")); err == nil && maxKeys > 0 && maxKeys <= 1000 {
+		input.MaxKeys = aws.Int32(int32(maxKeys)) // #nosec G115 - range validated (1-1000)
+	}
+
+	output, err := h.s3Backend.ListObjectVersions(r.Context(), input)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+	if h.internalKeyPrefix != "" {
+		output.Versions = slices.DeleteFunc(output.Versions, func(version s3types.ObjectVersion) bool {
+			return h.isInternalKey(aws.ToString(version.Key))
+		})
+		output.DeleteMarkers = slices.DeleteFunc(output.DeleteMarkers, func(marker s3types.DeleteMarkerEntry) bool {
+			return h.isInternalKey(aws.ToString(marker.Key))
+		})
+		output.CommonPrefixes = slices.DeleteFunc(output.CommonPrefixes, func(prefix s3types.CommonPrefix) bool {
+			return h.isInternalKey(aws.ToString(prefix.Prefix))
+		})
+	}
+	h.translateListedVersions(r.Context(), bucket, output.Versions)
+
+	result := listVersionsResult{
+		Xmlns:               s3XMLNamespace,
+		Name:                bucket,
+		Prefix:              aws.ToString(output.Prefix),
+		KeyMarker:           aws.ToString(output.KeyMarker),
+		VersionIDMarker:     aws.ToString(output.VersionIdMarker),
+		NextKeyMarker:       aws.ToString(output.NextKeyMarker),
+		NextVersionIDMarker: aws.ToString(output.NextVersionIdMarker),
+		MaxKeys:             aws.ToInt32(output.MaxKeys),
+		Delimiter:           aws.ToString(output.Delimiter),
+		EncodingType:        string(output.EncodingType),
+		IsTruncated:         aws.ToBool(output.IsTruncated),
+	}
+	for _, version := range output.Versions {
+		result.Versions = append(result.Versions, listedVersion{
+			Key:          aws.ToString(version.Key),
+			VersionID:    aws.ToString(version.VersionId),
+			IsLatest:     aws.ToBool(version.IsLatest),
+			LastModified: listedTime(version.LastModified),
+			ETag:         aws.ToString(version.ETag),
+			Size:         aws.ToInt64(version.Size),
+			StorageClass: string(version.StorageClass),
+			Owner:        newListedOwner(version.Owner),
+		})
+	}
+	for _, marker := range output.DeleteMarkers {
+		result.DeleteMarkers = append(result.DeleteMarkers, listedDeleteMarker{
+			Key:          aws.ToString(marker.Key),
+			VersionID:    aws.ToString(marker.VersionId),
+			IsLatest:     aws.ToBool(marker.IsLatest),
+			LastModified: listedTime(marker.LastModified),
+			Owner:        newListedOwner(marker.Owner),
+		})
+	}
+	for _, prefix := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, listedPrefix{Prefix: aws.ToString(prefix.Prefix)})
+	}
+	h.xmlWriter.WriteXML(w, result)
+}
+
+func listedTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func newListedOwner(owner *s3types.Owner) *listedOwner {
+	if owner == nil {
+		return nil
+	}
+	return &listedOwner{ID: aws.ToString(owner.ID), DisplayName: aws.ToString(owner.DisplayName)}
+}
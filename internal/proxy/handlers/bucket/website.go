@@ -1,12 +1,18 @@
 package bucket
 
 import (
+	"bytes"
+	"encoding/xml"
 	"net/http"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 // WebsiteHandler handles bucket website operations
@@ -41,6 +47,27 @@ func (h *WebsiteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxRoutingRules is the number of routing rules S3 allows in a website
+// configuration
+const maxRoutingRules = 50
+
+// websiteConfiguration is the body of GetBucketWebsite and PutBucketWebsite
+type websiteConfiguration struct {
+	XMLName               xml.Name                     `xml:"WebsiteConfiguration"`
+	Xmlns                 string                       `xml:"xmlns,attr,omitempty"`
+	RedirectAllRequestsTo *types.RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *types.IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *types.ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []routingRule                `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+// routingRule is a RoutingRule. The SDK type has its members in an order
+// that does not match the S3 schema.
+type routingRule struct {
+	Condition *types.Condition `xml:"Condition,omitempty"`
+	Redirect  *types.Redirect  `xml:"Redirect"`
+}
+
 // handleGetBucketWebsite gets bucket website configuration
 func (h *WebsiteHandler) handleGetBucketWebsite(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Getting bucket website configuration")
@@ -55,15 +82,92 @@ func (h *WebsiteHandler) handleGetBucketWebsite(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	result := websiteConfiguration{
+		Xmlns:                 s3XMLNamespace,
+		RedirectAllRequestsTo: output.RedirectAllRequestsTo,
+		IndexDocument:         output.IndexDocument,
+		ErrorDocument:         output.ErrorDocument,
+	}
+	for _, rule := range output.RoutingRules {
+		result.RoutingRules = append(result.RoutingRules, routingRule{Condition: rule.Condition, Redirect: rule.Redirect})
+	}
+	h.XMLWriter.WriteXML(w, result)
 }
 
 // handlePutBucketWebsite sets bucket website configuration
-func (h *WebsiteHandler) handlePutBucketWebsite(w http.ResponseWriter, _ *http.Request, bucket string) {
+func (h *WebsiteHandler) handlePutBucketWebsite(w http.ResponseWriter, r *http.Request, bucket string) {
 	h.Logger.WithField("bucket", bucket).Debug("Setting bucket website configuration")
 
-	// For now, return not implemented
-	h.ErrorWriter.WriteNotImplemented(w, "PutBucketWebsite")
+	body, err := h.RequestParser.ReadXMLBody(r, request.MaxXMLConfigSize)
+	if err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+
+	var requested websiteConfiguration
+	if err := request.DecodeXML(bytes.NewReader(body), "WebsiteConfiguration", &requested, request.XMLLimits{}); err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+	websiteConfig, err := requested.toSDK()
+	if err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+
+	input := &s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(bucket),
+		WebsiteConfiguration: websiteConfig,
+	}
+	if _, err := h.S3Backend.PutBucketWebsite(r.Context(), input); err != nil {
+		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// toSDK validates a website configuration and converts it for the backend.
+// A configuration either redirects all requests or names an index document.
+func (c websiteConfiguration) toSDK() (*types.WebsiteConfiguration, error) {
+	if c.RedirectAllRequestsTo != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 ||
+			aws.ToString(c.RedirectAllRequestsTo.HostName) == "" || !validRedirectProtocol(c.RedirectAllRequestsTo.Protocol) {
+			return nil, request.ErrMalformedXML
+		}
+		return &types.WebsiteConfiguration{RedirectAllRequestsTo: c.RedirectAllRequestsTo}, nil
+	}
+
+	if c.IndexDocument == nil {
+		return nil, request.ErrMalformedXML
+	}
+	if suffix := aws.ToString(c.IndexDocument.Suffix); suffix == "" || strings.Contains(suffix, "/") {
+		return nil, request.ErrMalformedXML
+	}
+	if c.ErrorDocument != nil && aws.ToString(c.ErrorDocument.Key) == "" {
+		return nil, request.ErrMalformedXML
+	}
+	if len(c.RoutingRules) > maxRoutingRules {
+		return nil, request.ErrMalformedXML
+	}
+
+	result := &types.WebsiteConfiguration{
+		IndexDocument: c.IndexDocument,
+		ErrorDocument: c.ErrorDocument,
+	}
+	for _, rule := range c.RoutingRules {
+		if rule.Redirect == nil || !validRedirectProtocol(rule.Redirect.Protocol) ||
+			(rule.Redirect.ReplaceKeyPrefixWith != nil && rule.Redirect.ReplaceKeyWith != nil) {
+			return nil, request.ErrMalformedXML
+		}
+		result.RoutingRules = append(result.RoutingRules, types.RoutingRule{Condition: rule.Condition, Redirect: rule.Redirect})
+	}
+	return result, nil
+}
+
+// validRedirectProtocol reports whether protocol is empty, http or https
+func validRedirectProtocol(protocol types.Protocol) bool {
+	return protocol == "" || protocol == types.ProtocolHttp || protocol == types.ProtocolHttps
 }
 
 // handleDeleteBucketWebsite deletes bucket website configuration
@@ -74,11 +178,10 @@ func (h *WebsiteHandler) handleDeleteBucketWebsite(w http.ResponseWriter, r *htt
 		Bucket: aws.String(bucket),
 	}
 
-	output, err := h.S3Backend.DeleteBucketWebsite(r.Context(), input)
-	if err != nil {
+	if _, err := h.S3Backend.DeleteBucketWebsite(r.Context(), input); err != nil {
 		h.ErrorWriter.WriteS3Error(w, err, bucket, "")
 		return
 	}
 
-	h.XMLWriter.WriteXML(w, output)
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -61,20 +61,20 @@ func TestWebsiteHandler_Handle(t *testing.T) {
 			expectedBody: "NoSuchWebsiteConfiguration",
 		},
 		{
-			name:           "PUT bucket website - not implemented",
+			name:           "PUT bucket website - empty body",
 			method:         "PUT",
 			bucket:         "test-bucket",
-			expectedStatus: http.StatusNotImplemented,
+			expectedStatus: http.StatusBadRequest,
 			setupMock: func(m *MockS3Backend) {
-				// No setup needed for not implemented
+				// The body is rejected before the backend is called
 			},
-			expectedBody: "not yet implemented",
+			expectedBody: "MalformedXML",
 		},
 		{
 			name:           "DELETE bucket website - success",
 			method:         "DELETE",
 			bucket:         "test-bucket",
-			expectedStatus: http.StatusOK,
+			expectedStatus: http.StatusNoContent,
 			setupMock: func(m *MockS3Backend) {
 				// Setup mock for DELETE operation
 				m.On("DeleteBucketWebsite", mock.Anything, mock.MatchedBy(func(input *s3.DeleteBucketWebsiteInput) bool {
@@ -343,7 +343,7 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					<Suffix>index.html</Suffix>
 				</IndexDocument>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Standard website configuration with index document",
 		},
 		{
@@ -356,7 +356,7 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					<Key>error.html</Key>
 				</ErrorDocument>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Website configuration with index and error documents",
 		},
 		{
@@ -367,7 +367,7 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					<Protocol>https</Protocol>
 				</RedirectAllRequestsTo>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Website configuration redirecting all requests",
 		},
 		{
@@ -387,7 +387,7 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					</RoutingRule>
 				</RoutingRules>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
+			expectedStatus: http.StatusOK,
 			description:    "Website configuration with routing rules",
 		},
 		{
@@ -396,14 +396,14 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 				<IndexDocument>
 					<Suffix>index.html</Suffix>
 				</IndexDocument>`, // Missing closing tag
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Malformed XML should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Malformed XML is rejected",
 		},
 		{
 			name:           "Empty body",
 			body:           "",
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Empty body should clear website configuration when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "A website configuration is required",
 		},
 		{
 			name: "Invalid protocol",
@@ -413,8 +413,8 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					<Protocol>ftp</Protocol>
 				</RedirectAllRequestsTo>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Invalid protocol should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Invalid protocol is rejected",
 		},
 		{
 			name: "Missing required fields",
@@ -422,8 +422,8 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 				<IndexDocument>
 				</IndexDocument>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Missing required suffix should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Missing required suffix is rejected",
 		},
 		{
 			name: "Invalid hostname",
@@ -433,8 +433,8 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 					<Protocol>https</Protocol>
 				</RedirectAllRequestsTo>
 			</WebsiteConfiguration>`,
-			expectedStatus: http.StatusNotImplemented, // PUT not implemented yet
-			description:    "Empty hostname should be rejected when implemented",
+			expectedStatus: http.StatusBadRequest,
+			description:    "Empty hostname is rejected",
 		},
 	}
 
@@ -442,6 +442,11 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock S3 client
 			mockS3Backend := &MockS3Backend{}
+			if tt.expectedStatus == http.StatusOK {
+				mockS3Backend.On("PutBucketWebsite", mock.Anything, mock.MatchedBy(func(input *s3.PutBucketWebsiteInput) bool {
+					return *input.Bucket == "test-bucket" && input.WebsiteConfiguration != nil
+				})).Return(&s3.PutBucketWebsiteOutput{}, nil)
+			}
 
 			// Create logger
 			logger := logrus.NewEntry(logrus.New())
@@ -466,6 +471,7 @@ func TestWebsiteHandler_XMLValidation(t *testing.T) {
 
 			// Assert
 			assert.Equal(t, tt.expectedStatus, w.Code, tt.description)
+			mockS3Backend.AssertExpectations(t)
 		})
 	}
 }
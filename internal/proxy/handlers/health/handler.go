@@ -3,9 +3,12 @@ package health
 import (
 	"encoding/json"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/probes"
 )
 
 // Handler handles health and version endpoints
@@ -13,8 +16,18 @@ type Handler struct {
 	logger               *logrus.Entry
 	logHealthRequests    bool
 	shutdownStateHandler func() (bool, time.Time)
+	readinessHandler     func() bool
+	probeResults         func() []probes.Result
 	requestStartHandler  func()
 	requestEndHandler    func()
+
+	started atomic.Bool // set once /startupz succeeded
+}
+
+// probeResponse is the body of the probe endpoints
+type probeResponse struct {
+	Status string          `json:"status"`
+	Checks []probes.Result `json:"checks,omitempty"`
 }
 
 // NewHandler creates a new health handler
@@ -30,6 +43,18 @@ func (h *Handler) SetShutdownStateHandler(handler func() (bool, time.Time)) {
 	h.shutdownStateHandler = handler
 }
 
+// SetReadinessHandler sets the handler reporting whether the proxy is ready
+// for traffic. While it returns false the health endpoint answers 503.
+func (h *Handler) SetReadinessHandler(handler func() bool) {
+	h.readinessHandler = handler
+}
+
+// SetProbeResults sets the source of the check results reported by /readyz
+// and /startupz
+func (h *Handler) SetProbeResults(results func() []probes.Result) {
+	h.probeResults = results
+}
+
 // SetRequestTracker sets handlers for tracking active requests
 func (h *Handler) SetRequestTracker(onStart, onEnd func()) {
 	h.requestStartHandler = onStart
@@ -48,7 +73,7 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 
 	// Optional logging for health requests
 	if h.logHealthRequests {
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"method": r.Method,
 			"path":   r.URL.Path,
 			"remote": r.RemoteAddr,
@@ -68,12 +93,28 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if err := json.NewEncoder(w).Encode(response); err != nil {
-				h.logger.WithError(err).Error("Failed to write health response")
+				h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write health response")
 			}
 			return
 		}
 	}
 
+	// Keep traffic away until key material has been preloaded
+	if h.readinessHandler != nil && !h.readinessHandler() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		response := map[string]string{
+			"status":  "warming_up",
+			"message": "Key material is not loaded yet",
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write health response")
+		}
+		return
+	}
+
 	// Normal health response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -83,7 +124,86 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.WithError(err).Error("Failed to write health response")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write health response")
+	}
+}
+
+// Livez handles the liveness probe: the process serves requests. It stays
+// alive while shutting down, so in-flight requests can complete.
+func (h *Handler) Livez(w http.ResponseWriter, r *http.Request) {
+	defer h.trackProbe(r)()
+	h.writeProbe(w, http.StatusOK, probeResponse{Status: "alive"})
+}
+
+// Readyz handles the readiness probe: the proxy is not shutting down, its key
+// material is loaded and the latest backend, provider and license checks
+// passed. The body lists the result of every check.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	defer h.trackProbe(r)()
+	if h.shutdownStateHandler != nil {
+		if shutdownInitiated, _ := h.shutdownStateHandler(); shutdownInitiated {
+			h.writeProbe(w, http.StatusServiceUnavailable, probeResponse{Status: "shutting_down"})
+			return
+		}
+	}
+	results := h.checkResults()
+	if !probes.Passed(results) {
+		h.writeProbe(w, http.StatusServiceUnavailable, probeResponse{Status: "not_ready", Checks: results})
+		return
+	}
+	h.writeProbe(w, http.StatusOK, probeResponse{Status: "ready", Checks: results})
+}
+
+// Startupz handles the startup probe: it succeeds once every check passed,
+// and from then on without running them again
+func (h *Handler) Startupz(w http.ResponseWriter, r *http.Request) {
+	defer h.trackProbe(r)()
+	if h.started.Load() {
+		h.writeProbe(w, http.StatusOK, probeResponse{Status: "started"})
+		return
+	}
+	results := h.checkResults()
+	if !probes.Passed(results) {
+		h.writeProbe(w, http.StatusServiceUnavailable, probeResponse{Status: "starting", Checks: results})
+		return
+	}
+	h.started.Store(true)
+	h.writeProbe(w, http.StatusOK, probeResponse{Status: "started", Checks: results})
+}
+
+// checkResults returns the results of the probe checks
+func (h *Handler) checkResults() []probes.Result {
+	if h.probeResults == nil {
+		return nil
+	}
+	return h.probeResults()
+}
+
+// trackProbe tracks and logs a probe request; the returned function ends it
+func (h *Handler) trackProbe(r *http.Request) func() {
+	if h.requestStartHandler != nil {
+		h.requestStartHandler()
+	}
+	if h.logHealthRequests {
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"remote": r.RemoteAddr,
+		}).Debug("Probe request")
+	}
+	return func() {
+		if h.requestEndHandler != nil {
+			h.requestEndHandler()
+		}
+	}
+}
+
+func (h *Handler) writeProbe(w http.ResponseWriter, status int, response probeResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to write probe response")
 	}
 }
 
@@ -99,7 +219,7 @@ func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
 
 	// Optional logging for version requests (also controlled by logHealthRequests)
 	if h.logHealthRequests {
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"method": r.Method,
 			"path":   r.URL.Path,
 			"remote": r.RemoteAddr,
@@ -116,6 +236,6 @@ func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.WithError(err).Error("Failed to write version response")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write version response")
 	}
 }
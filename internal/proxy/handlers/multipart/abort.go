@@ -52,7 +52,7 @@ func (h *AbortHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	uploadID := r.URL.Query().Get("uploadId")
 
-	log := h.logger.WithFields(logrus.Fields{
+	log := h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":   bucket,
 		"key":      key,
 		"uploadId": uploadID,
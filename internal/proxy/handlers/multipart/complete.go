@@ -2,6 +2,7 @@ package multipart
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
@@ -13,10 +14,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,6 +31,9 @@ type CompleteHandler struct {
 	xmlWriter     *response.XMLWriter
 	errorWriter   *response.ErrorWriter
 	requestParser *request.Parser
+
+	// backendProfile decides how encryption metadata reaches the completed object
+	backendProfile config.BackendProfile
 }
 
 // NewCompleteHandler creates a new complete handler
@@ -49,6 +55,11 @@ func NewCompleteHandler(
 	}
 }
 
+// SetBackendProfile sets the backend compatibility profile used to attach metadata
+func (h *CompleteHandler) SetBackendProfile(profile config.BackendProfile) {
+	h.backendProfile = profile
+}
+
 // CompleteMultipartUpload represents the XML payload for completing a multipart upload
 type CompleteMultipartUpload struct {
 	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
@@ -61,6 +72,110 @@ type CompletedPart struct {
 	ETag       string `xml:"ETag"`
 }
 
+const (
+	// maxCompleteParts is the S3 limit on parts per multipart upload
+	maxCompleteParts = 10000
+
+	// maxCompleteBodySize bounds the CompleteMultipartUpload body. A 10,000-part
+	// request with checksums and pretty-printing is about 2 MiB.
+	maxCompleteBodySize = 8 * 1024 * 1024
+
+	// maxCompleteElements bounds the elements of the body: a part has at most
+	// PartNumber, ETag and a few checksums
+	maxCompleteElements = maxCompleteParts * 8
+)
+
+var (
+	errCompleteBodyTooLarge = request.ErrXMLTooLarge
+	errTooManyParts         = fmt.Errorf("CompleteMultipartUpload lists more than %d parts", maxCompleteParts)
+	errMalformedComplete    = request.ErrMalformedXML
+)
+
+// decodeCompleteMultipartUpload decodes the CompleteMultipartUpload XML element
+// by element instead of buffering the body, stopping as soon as the part limit
+// is exceeded. ETags are HTML-unescaped individually, since some clients send
+// them double-encoded (&amp;quot;).
+func decodeCompleteMultipartUpload(body io.Reader) (*CompleteMultipartUpload, error) {
+	decoder := request.NewXMLDecoder(body, request.XMLLimits{MaxElements: maxCompleteElements})
+	result := &CompleteMultipartUpload{}
+	sawRoot := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, completeDecodeError(err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "CompleteMultipartUpload":
+			if sawRoot {
+				return nil, errMalformedComplete
+			}
+			sawRoot = true
+		case "Part":
+			if !sawRoot {
+				return nil, errMalformedComplete
+			}
+			if len(result.Parts) >= maxCompleteParts {
+				return nil, errTooManyParts
+			}
+			var part CompletedPart
+			if err := decoder.DecodeElement(&part, &start); err != nil {
+				return nil, completeDecodeError(err)
+			}
+			part.ETag = html.UnescapeString(part.ETag)
+			result.Parts = append(result.Parts, part)
+		default:
+			if !sawRoot {
+				return nil, errMalformedComplete
+			}
+			// Unknown elements (e.g. checksums) are skipped without buffering
+			if err := decoder.Skip(); err != nil {
+				return nil, completeDecodeError(err)
+			}
+		}
+	}
+
+	if !sawRoot {
+		return nil, errMalformedComplete
+	}
+	return result, nil
+}
+
+// completeDecodeError maps decoder errors to errCompleteBodyTooLarge or
+// errMalformedComplete
+func completeDecodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errCompleteBodyTooLarge):
+		return errCompleteBodyTooLarge
+	case errors.Is(err, errMalformedComplete):
+		return err
+	default:
+		return fmt.Errorf("%w: %v", errMalformedComplete, err)
+	}
+}
+
+// writeDecodeError maps body decoding errors to S3 error codes
+func (h *CompleteHandler) writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errCompleteBodyTooLarge):
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MaxMessageLengthExceeded", "Your request was too big.")
+	case errors.Is(err, errTooManyParts):
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidRequest", errTooManyParts.Error())
+	default:
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", errMalformedComplete.Error())
+	}
+}
+
 // Handle handles complete multipart upload requests
 func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -70,7 +185,7 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	uploadID := r.URL.Query().Get("uploadId")
 
-	log := h.logger.WithFields(logrus.Fields{
+	log := h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":   bucket,
 		"key":      key,
 		"uploadID": uploadID,
@@ -85,25 +200,12 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read and decode the request body
-	bodyData, err := io.ReadAll(r.Body)
+	// Decode the request body as a stream. The body is bounded by
+	// maxCompleteBodySize and never logged: with 10,000 parts it can be megabytes.
+	completeUpload, err := decodeCompleteMultipartUpload(http.MaxBytesReader(w, r.Body, maxCompleteBodySize))
 	if err != nil {
-		log.WithError(err).Error("Failed to read request body")
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
-		return
-	}
-
-	log.WithField("body_size", len(bodyData)).Debug("Read request body")
-
-	// Decode HTML entities (AWS clients sometimes send encoded XML)
-	decodedBody := html.UnescapeString(string(bodyData))
-	// log.WithField("decoded_body", decodedBody).Debug("Decoded request body")
-
-	// Parse the XML
-	var completeUpload CompleteMultipartUpload
-	if err := xml.Unmarshal([]byte(decodedBody), &completeUpload); err != nil {
-		log.WithError(err).WithField("body", decodedBody).Error("Failed to parse XML body")
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		log.WithError(err).Warn("Rejected CompleteMultipartUpload body")
+		h.writeDecodeError(w, r, err)
 		return
 	}
 
@@ -151,13 +253,13 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	for _, part := range completeUpload.Parts {
 		// Validate part number is within int32 range
 		if part.PartNumber < 1 || part.PartNumber > 10000 {
-			h.logger.WithFields(logrus.Fields{
+			h.logger.WithContext(ctx).WithFields(logrus.Fields{
 				"bucket":     bucket,
 				"key":        key,
 				"uploadID":   uploadID,
 				"partNumber": part.PartNumber,
 			}).Error("Part number out of valid range in complete request")
-			h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidPartNumber", "Part number must be between 1 and 10000")
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidPartNumber", "Part number must be between 1 and 10000")
 			return
 		}
 
@@ -177,12 +279,10 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Debug: Log the finalMetadata content in a single entry
 	if len(finalMetadata) > 0 {
 		log.WithFields(logrus.Fields{
 			"uploadID":      uploadID,
 			"metadataCount": len(finalMetadata),
-			"metadata":      finalMetadata,
 		}).Debug("Final metadata entries")
 	} else {
 		log.WithFields(logrus.Fields{
@@ -200,7 +300,7 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	result, err := h.s3Backend.CompleteMultipartUpload(ctx, completeInput)
+	result, err := h.s3Backend.CompleteMultipartUpload(ctx, completeInput, utils.CompleteMultipartMetadataOptions(h.backendProfile, finalMetadata)...)
 	if err != nil {
 		log.WithError(err).Error("Failed to complete multipart upload")
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
@@ -210,36 +310,28 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Store the original ETag before any metadata operations
 	originalETag := aws.ToString(result.ETag)
 
-	// After completing the multipart upload, we need to add the encryption metadata
-	// to the final object since S3 doesn't transfer metadata from CreateMultipartUpload
+	// After completing the multipart upload, the encryption metadata has to end up on
+	// the final object. Depending on the backend profile it was sent with Complete or
+	// is re-applied via a CopyObject self-copy; either way it is verified if required.
 	// Skip this entirely for "none" provider to maintain pure pass-through
 	if len(finalMetadata) > 0 {
 		log.WithFields(logrus.Fields{
 			"uploadID":      uploadID,
 			"metadataCount": len(finalMetadata),
+			"phase":         h.backendProfile.MultipartMetadataPhase,
 		}).Debug("Adding encryption metadata to completed object")
 
-		// Copy the object to itself with the encryption metadata
-		copyInput := &s3.CopyObjectInput{
-			Bucket:            aws.String(bucket),
-			Key:               aws.String(key),
-			CopySource:        aws.String(fmt.Sprintf("%s/%s", bucket, key)),
-			Metadata:          finalMetadata,
-			MetadataDirective: types.MetadataDirectiveReplace,
-		}
-
-		copyResult, err := h.s3Backend.CopyObject(ctx, copyInput)
-		if err != nil {
+		// The object lock was sent with CreateMultipartUpload and is read back for the self-copy
+		if err := utils.EnsureMultipartObjectMetadata(ctx, h.s3Backend, h.backendProfile, bucket, key, finalMetadata, log, utils.WithStoredObjectLock(h.s3Backend, bucket, key)); err != nil {
 			log.WithFields(logrus.Fields{
 				"uploadID": uploadID,
 			}).WithError(err).Error("Failed to add encryption metadata to completed object")
 
 			// CRITICAL: Without metadata, the encrypted object is unusable!
 			// Return error to client to indicate the upload failed completely
-			h.errorWriter.WriteS3Error(w, fmt.Errorf("upload completed but encryption metadata could not be applied: %w", err), bucket, key)
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
 			return
 		}
-		_ = copyResult // Silence unused variable warning
 		log.WithFields(logrus.Fields{
 			"uploadID": uploadID,
 		}).Debug("Successfully added encryption metadata to completed object")
@@ -289,7 +381,7 @@ func (h *CompleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write([]byte(responseXML)); err != nil {
-		h.logger.WithError(err).Error("Failed to write complete multipart upload response")
+		h.logger.WithContext(ctx).WithError(err).Error("Failed to write complete multipart upload response")
 	}
 
 	log.WithFields(logrus.Fields{
@@ -0,0 +1,135 @@
+package multipart
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// buildCompleteBody renders a CompleteMultipartUpload body the way SDKs do,
+// including checksum elements the proxy does not interpret
+func buildCompleteBody(parts int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<CompleteMultipartUpload xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` + "\n")
+	for i := 1; i <= parts; i++ {
+		fmt.Fprintf(&b, "  <Part>\n    <ChecksumCRC32>AAAAAA==</ChecksumCRC32>\n    <ETag>&quot;%032x&quot;</ETag>\n    <PartNumber>%d</PartNumber>\n  </Part>\n", i, i)
+	}
+	b.WriteString(`</CompleteMultipartUpload>`)
+	return b.String()
+}
+
+func TestDecodeCompleteMultipartUpload_TenThousandParts(t *testing.T) {
+	body := buildCompleteBody(maxCompleteParts)
+	require.Less(t, len(body), maxCompleteBodySize, "a maximal request must fit the body limit")
+
+	result, err := decodeCompleteMultipartUpload(strings.NewReader(body))
+	require.NoError(t, err)
+	require.Len(t, result.Parts, maxCompleteParts)
+	assert.Equal(t, 1, result.Parts[0].PartNumber)
+	assert.Equal(t, fmt.Sprintf(`"%032x"`, 1), result.Parts[0].ETag)
+	assert.Equal(t, maxCompleteParts, result.Parts[maxCompleteParts-1].PartNumber)
+}
+
+func TestDecodeCompleteMultipartUpload_TooManyParts(t *testing.T) {
+	_, err := decodeCompleteMultipartUpload(strings.NewReader(buildCompleteBody(maxCompleteParts + 1)))
+	assert.ErrorIs(t, err, errTooManyParts)
+}
+
+func TestDecodeCompleteMultipartUpload_DoubleEncodedETag(t *testing.T) {
+	body := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>&amp;quot;abc&amp;quot;</ETag></Part></CompleteMultipartUpload>`
+	result, err := decodeCompleteMultipartUpload(strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, `"abc"`, result.Parts[0].ETag)
+}
+
+func TestDecodeCompleteMultipartUpload_Malformed(t *testing.T) {
+	tests := map[string]string{
+		"empty":         ``,
+		"wrong root":    `<Something><Part><PartNumber>1</PartNumber></Part></Something>`,
+		"truncated":     `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber>`,
+		"bad part type": `<CompleteMultipartUpload><Part><PartNumber>one</PartNumber></Part></CompleteMultipartUpload>`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeCompleteMultipartUpload(strings.NewReader(body))
+			assert.ErrorIs(t, err, errMalformedComplete)
+		})
+	}
+}
+
+func newCompleteRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/test-bucket/test-key?uploadId=test-upload-id", strings.NewReader(body))
+	return mux.SetURLVars(req, map[string]string{
+		"bucket": "test-bucket",
+		"key":    "test-key",
+	})
+}
+
+func TestCompleteHandler_RejectsOversizedBody(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	handler := NewCompleteHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	padding := strings.Repeat(" ", maxCompleteBodySize)
+	w := httptest.NewRecorder()
+	handler.Handle(w, newCompleteRequest("<CompleteMultipartUpload>"+padding+"</CompleteMultipartUpload>"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MaxMessageLengthExceeded")
+	mockS3Backend.AssertNotCalled(t, "CompleteMultipartUpload", mock.Anything, mock.Anything)
+}
+
+func TestCompleteHandler_RejectsMalformedXML(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	handler := NewCompleteHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, newCompleteRequest(`<CompleteMultipartUpload><Part>`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MalformedXML")
+}
+
+func TestCompleteHandler_TenThousandParts(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	createHandler := NewCreateHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+	handler := NewCompleteHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("test-upload-id"),
+	}, nil)
+	createReq := mux.SetURLVars(httptest.NewRequest("POST", "/test-bucket/test-key?uploads", nil), map[string]string{
+		"bucket": "test-bucket",
+		"key":    "test-key",
+	})
+	createW := httptest.NewRecorder()
+	createHandler.Handle(createW, createReq)
+	require.Equal(t, http.StatusOK, createW.Code)
+
+	mockS3Backend.On("CompleteMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.CompleteMultipartUploadInput) bool {
+		parts := input.MultipartUpload.Parts
+		return len(parts) == maxCompleteParts &&
+			aws.ToInt32(parts[0].PartNumber) == 1 &&
+			aws.ToInt32(parts[maxCompleteParts-1].PartNumber) == maxCompleteParts
+	})).Return(&s3.CompleteMultipartUploadOutput{
+		ETag: aws.String(`"complete-etag-10000"`),
+	}, nil)
+	mockS3Backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+	mockS3Backend.On("CopyObject", mock.Anything, mock.Anything).Return(&s3.CopyObjectOutput{}, nil)
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, newCompleteRequest(buildCompleteBody(maxCompleteParts)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "complete-etag-10000")
+	mockS3Backend.AssertExpectations(t)
+}
@@ -3,6 +3,7 @@ package multipart
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -15,6 +16,11 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// RecommendedPartSizeHeader advises clients of the part size that lines up with
+// the proxy's crypto segmenting. Parts below it work, but many tiny parts cost
+// a session update and a backend request each.
+const RecommendedPartSizeHeader = "X-S3ep-Recommended-Part-Size"
+
 // CreateHandler handles create multipart upload operations
 type CreateHandler struct {
 	s3Backend     interfaces.S3BackendInterface
@@ -23,6 +29,8 @@ type CreateHandler struct {
 	xmlWriter     *response.XMLWriter
 	errorWriter   *response.ErrorWriter
 	requestParser *request.Parser
+
+	recommendedPartSize int64
 }
 
 // NewCreateHandler creates a new create handler
@@ -44,28 +52,100 @@ func NewCreateHandler(
 	}
 }
 
+// SetRecommendedPartSize sets the part size advertised on initiation responses.
+// Zero disables the header.
+func (h *CreateHandler) SetRecommendedPartSize(size int64) {
+	h.recommendedPartSize = size
+}
+
 // Handle handles create multipart upload requests
 func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
 	key := vars["key"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Handling create multipart upload")
 
+	// The encryption context and provider are bound when the upload is
+	// initiated; parts and completion carry none of their own
+	r = r.WithContext(orchestration.WithClient(orchestration.WithBucket(r.Context(), bucket), request.AccessKeyID(r)))
+	if header := r.Header.Get(orchestration.EncryptionContextHeader); header != "" {
+		encryptionContext, err := orchestration.ParseEncryptionContext(header)
+		if err != nil {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+		r = r.WithContext(orchestration.WithEncryptionContext(r.Context(), encryptionContext))
+		if err := h.encryptionMgr.CheckEncryptionContextBinding(r.Context()); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+	}
+	if header := r.Header.Get(orchestration.EncryptionProviderHeader); header != "" {
+		ctx, err := h.encryptionMgr.SelectEncryptionProvider(r.Context(), header)
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		r = r.WithContext(ctx)
+	}
+	if header := r.Header.Get(orchestration.PartSizeHeader); header != "" {
+		partSize, err := orchestration.ParsePartSize(header)
+		if err != nil {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+		r = r.WithContext(orchestration.WithPartSize(r.Context(), partSize))
+	}
+	lock, err := request.ParseObjectLock(r)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	// A retried initiation may replace the client's previous upload for this key
+	clientID := request.AccessKeyID(r)
+	takeover := h.encryptionMgr.TakeOverStaleMultipartUploads(r.Context(), bucket, key, clientID)
+	for _, staleID := range takeover.AbortUploadIDs {
+		abortInput := &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: aws.String(staleID),
+		}
+		if _, err := h.s3Backend.AbortMultipartUpload(r.Context(), abortInput); err != nil {
+			// Left for the backend's incomplete-upload lifecycle rules
+			h.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+				"bucket":   bucket,
+				"key":      key,
+				"uploadId": staleID,
+			}).Warn("Failed to abort stale multipart upload")
+		}
+	}
+	if takeover.ReuseUploadID != "" {
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"bucket":   bucket,
+			"key":      key,
+			"uploadId": takeover.ReuseUploadID,
+		}).Debug("Reusing unstarted multipart upload for retried initiation")
+		h.writeInitiateResult(w, bucket, key, takeover.ReuseUploadID)
+		return
+	}
+
 	// Create the S3 input
 	input := &s3.CreateMultipartUploadInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	}
+	lock.Apply(&input.ObjectLockMode, &input.ObjectLockRetainUntilDate, &input.ObjectLockLegalHoldStatus)
 
 	// Copy headers that should be preserved
 	if contentType := r.Header.Get("Content-Type"); contentType != "" {
 		input.ContentType = aws.String(contentType)
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"bucket":      bucket,
 			"key":         key,
 			"contentType": contentType,
@@ -73,7 +153,7 @@ func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 	if contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding != "" {
 		input.ContentEncoding = aws.String(contentEncoding)
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"bucket":          bucket,
 			"key":             key,
 			"contentEncoding": contentEncoding,
@@ -83,7 +163,7 @@ func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Create the multipart upload with S3
 	result, err := h.s3Backend.CreateMultipartUpload(r.Context(), input)
 	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
 			"bucket": bucket,
 			"key":    key,
 		}).Error("Failed to create multipart upload with S3")
@@ -94,9 +174,9 @@ func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	uploadID := aws.ToString(result.UploadId)
 
 	// Initialize encryption session for multipart uploads
-	err = h.encryptionMgr.InitiateMultipartUpload(r.Context(), uploadID, key, bucket)
+	err = h.encryptionMgr.InitiateMultipartUploadForClient(r.Context(), uploadID, key, bucket, clientID)
 	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
 			"bucket":   bucket,
 			"key":      key,
 			"uploadId": uploadID,
@@ -109,7 +189,7 @@ func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			UploadId: aws.String(uploadID),
 		}
 		if _, abortErr := h.s3Backend.AbortMultipartUpload(r.Context(), abortInput); abortErr != nil {
-			h.logger.WithError(abortErr).Warn("Failed to abort multipart upload after encryption initialization failure")
+			h.logger.WithContext(r.Context()).WithError(abortErr).Warn("Failed to abort multipart upload after encryption initialization failure")
 		}
 
 		utils.HandleS3Error(w, h.logger, err, "Failed to initialize encryption for multipart upload", bucket, key)
@@ -123,13 +203,21 @@ func (h *CreateHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	input.Metadata = metadata
 
 	// Return the CreateMultipartUploadResult
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":   bucket,
 		"key":      key,
 		"uploadId": uploadID,
 	}).Debug("Sending CreateMultipartUploadResult response to client")
 
+	h.writeInitiateResult(w, bucket, key, uploadID)
+}
+
+// writeInitiateResult writes the InitiateMultipartUploadResult for uploadID
+func (h *CreateHandler) writeInitiateResult(w http.ResponseWriter, bucket, key, uploadID string) {
 	w.Header().Set("Content-Type", "application/xml")
+	if h.recommendedPartSize > 0 {
+		w.Header().Set(RecommendedPartSizeHeader, strconv.FormatInt(h.recommendedPartSize, 10))
+	}
 	w.WriteHeader(http.StatusOK)
 
 	response := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
@@ -52,9 +52,11 @@ func NewHandler(
 
 	// Initialize sub-handlers
 	h.createHandler = NewCreateHandler(s3Backend, encryptionMgr, logger, xmlWriter, errorWriter, requestParser)
+	h.createHandler.SetRecommendedPartSize(cfg.GetRecommendedPartSize())
 	h.uploadHandler = NewUploadHandler(s3Backend, encryptionMgr, logger, xmlWriter, errorWriter, requestParser)
 	h.copyHandler = NewCopyHandler(s3Backend, encryptionMgr, logger)
 	h.completeHandler = NewCompleteHandler(s3Backend, encryptionMgr, logger, xmlWriter, errorWriter, requestParser)
+	h.completeHandler.SetBackendProfile(cfg.GetBackendProfile())
 	h.abortHandler = NewAbortHandler(s3Backend, encryptionMgr, logger, xmlWriter, errorWriter, requestParser)
 	h.listHandler = NewListHandler(s3Backend, logger, xmlWriter, errorWriter, requestParser)
 
@@ -46,7 +46,7 @@ func (h *ListHandler) HandleListParts(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	uploadID := query.Get("uploadId")
 
-	log := h.logger.WithFields(logrus.Fields{
+	log := h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method":   r.Method,
 		"bucket":   bucket,
 		"key":      key,
@@ -78,7 +78,7 @@ func (h *ListHandler) HandleListParts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write([]byte(responseXML)); err != nil {
-		h.logger.WithError(err).Error("Failed to write list parts response")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write list parts response")
 	}
 
 	log.Debug("Returned basic ListParts response")
@@ -89,7 +89,7 @@ func (h *ListHandler) HandleListMultipartUploads(w http.ResponseWriter, r *http.
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 	}).Debug("Handling list multipart uploads")
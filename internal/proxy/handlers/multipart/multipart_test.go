@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -239,6 +240,11 @@ func (m *MockS3Backend) ListObjects(ctx context.Context, params *s3.ListObjectsI
 	return args.Get(0).(*s3.ListObjectsOutput), args.Error(1)
 }
 
+func (m *MockS3Backend) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*s3.ListObjectVersionsOutput), args.Error(1)
+}
+
 func (m *MockS3Backend) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
 	args := m.Called(ctx, params)
 	return args.Get(0).(*s3.ListPartsOutput), args.Error(1)
@@ -390,6 +396,7 @@ func TestCreateHandler_Handle(t *testing.T) {
 
 	// Create handler
 	handler := NewCreateHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+	handler.SetRecommendedPartSize((&config.Config{}).GetRecommendedPartSize())
 
 	// Mock S3 response
 	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.CreateMultipartUploadInput) bool {
@@ -417,11 +424,151 @@ func TestCreateHandler_Handle(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "test-upload-id")
 	assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+	assert.Equal(t, "12582912", w.Header().Get(RecommendedPartSizeHeader))
 
 	// Verify mock expectations
 	mockS3Backend.AssertExpectations(t)
 }
 
+func TestCreateHandler_Handle_TakesOverStaleUpload(t *testing.T) {
+	_, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+
+	metadataPrefix := "s3ep-"
+	encMgr, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes-ctr",
+			MetadataKeyPrefix:     &metadataPrefix,
+			Providers: []config.EncryptionProvider{
+				{
+					Alias: "test-aes-ctr",
+					Type:  "aes",
+					Config: map[string]interface{}{
+						"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY=",
+					},
+				},
+			},
+		},
+		Optimizations: config.OptimizationsConfig{
+			MultipartStaleSessionPolicy: config.StaleSessionPolicyAbort,
+		},
+	})
+	require.NoError(t, err)
+
+	handler := NewCreateHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("first-upload"),
+	}, nil).Once()
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("retried-upload"),
+	}, nil).Once()
+	mockS3Backend.On("AbortMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.AbortMultipartUploadInput) bool {
+		return aws.ToString(input.UploadId) == "first-upload"
+	})).Return(&s3.AbortMultipartUploadOutput{}, nil).Once()
+
+	initiate := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/test-bucket/test-key?uploads", nil)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIACLIENT/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+		req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket", "key": "test-key"})
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		return w
+	}
+
+	first := initiate()
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Contains(t, first.Body.String(), "first-upload")
+
+	// The client retries after losing the first response
+	retried := initiate()
+	assert.Equal(t, http.StatusOK, retried.Code)
+	assert.Contains(t, retried.Body.String(), "retried-upload")
+
+	_, err = encMgr.GetMultipartUploadState("first-upload")
+	assert.Error(t, err, "stale session must be discarded")
+	assert.Equal(t, 1, encMgr.GetSessionCount())
+
+	mockS3Backend.AssertExpectations(t)
+}
+
+func TestCreateHandler_Handle_EncryptionContext(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	handler := NewCreateHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("context-upload"),
+	}, nil).Once()
+
+	initiate := func(encryptionContext string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/test-bucket/test-key?uploads", nil)
+		req.Header.Set(orchestration.EncryptionContextHeader, encryptionContext)
+		req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket", "key": "test-key"})
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		return w
+	}
+
+	w := initiate("tenant=acme")
+	require.Equal(t, http.StatusOK, w.Code)
+	session, err := encMgr.GetMultipartUploadState("context-upload")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant=acme", session.Metadata["s3ep-encryption-context"])
+
+	w = initiate("tenant")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidArgument")
+
+	// Without integrity verification the context could not be bound
+	unbound, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes-ctr",
+			IntegrityVerification: config.HMACVerificationOff,
+			Providers: []config.EncryptionProvider{{
+				Alias:  "test-aes-ctr",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	handler = NewCreateHandler(mockS3Backend, unbound, logger, xmlWriter, errorWriter, requestParser)
+	w = initiate("tenant=acme")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidArgument")
+
+	mockS3Backend.AssertExpectations(t)
+}
+
+func TestCreateHandler_Handle_PartSize(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	handler := NewCreateHandler(mockS3Backend, encMgr, logger, xmlWriter, errorWriter, requestParser)
+
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("sized-upload"),
+	}, nil).Once()
+
+	initiate := func(partSize string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/test-bucket/test-key?uploads", nil)
+		req.Header.Set(orchestration.PartSizeHeader, partSize)
+		req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket", "key": "test-key"})
+		w := httptest.NewRecorder()
+		handler.Handle(w, req)
+		return w
+	}
+
+	w := initiate("8388608")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "sized-upload")
+
+	for _, invalid := range []string{"0", "-1", "5368709121", "8MB"} {
+		w = initiate(invalid)
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+		assert.Contains(t, w.Body.String(), "InvalidArgument", invalid)
+	}
+
+	mockS3Backend.AssertExpectations(t)
+}
+
 func TestUploadHandler_HandleStandard(t *testing.T) {
 	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
 
@@ -523,10 +670,16 @@ func TestCompleteHandler_Handle(t *testing.T) {
 		Location: aws.String("http://test-bucket.s3.amazonaws.com/test-key"),
 	}, nil)
 
+	// The self-copy keeps the object lock of the completed object
+	mockS3Backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+	}, nil)
+
 	// Mock CopyObject for metadata (when finalMetadata is not empty)
 	mockS3Backend.On("CopyObject", mock.Anything, mock.MatchedBy(func(input *s3.CopyObjectInput) bool {
 		return aws.ToString(input.Bucket) == "test-bucket" &&
-			aws.ToString(input.Key) == "test-key"
+			aws.ToString(input.Key) == "test-key" &&
+			input.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn
 	})).Return(&s3.CopyObjectOutput{
 		CopyObjectResult: &types.CopyObjectResult{
 			ETag: aws.String(`"complete-etag"`),
@@ -658,6 +811,51 @@ func TestUploadHandler_HandleStreaming(t *testing.T) {
 	mockS3Backend.AssertExpectations(t)
 }
 
+// readingS3Backend reads the body of UploadPart like the SDK does and only
+// stores parts whose body was read without error
+type readingS3Backend struct {
+	*MockS3Backend
+	storedParts int
+}
+
+func (b *readingS3Backend) UploadPart(_ context.Context, params *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if _, err := io.ReadAll(params.Body); err != nil {
+		return nil, err
+	}
+	b.storedParts++
+	return &s3.UploadPartOutput{ETag: aws.String(`"etag"`)}, nil
+}
+
+func TestUploadHandler_HandleStreaming_PayloadHashMismatch(t *testing.T) {
+	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
+	backend := &readingS3Backend{MockS3Backend: mockS3Backend}
+	mockS3Backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{
+		UploadId: aws.String("test-upload-id"),
+	}, nil)
+
+	createReq := mux.SetURLVars(httptest.NewRequest("POST", "/test-bucket/test-key?uploads", nil), map[string]string{"bucket": "test-bucket", "key": "test-key"})
+	createW := httptest.NewRecorder()
+	NewCreateHandler(backend, encMgr, logger, xmlWriter, errorWriter, requestParser).Handle(createW, createReq)
+	require.Equal(t, http.StatusOK, createW.Code)
+
+	// The body of known length is streamed to the backend, checked against
+	// a X-Amz-Content-Sha256 it does not match as the authentication
+	// middleware does
+	testData := []byte("streamed part whose signed hash does not match")
+	req := httptest.NewRequest("PUT", "/test-bucket/test-key?partNumber=1&uploadId=test-upload-id", bytes.NewReader(testData))
+	body, err := request.NewPayloadHashReader(req.Body, strings.Repeat("ab", 32))
+	require.NoError(t, err)
+	req.Body = body
+	req = mux.SetURLVars(req, map[string]string{"bucket": "test-bucket", "key": "test-key"})
+
+	w := httptest.NewRecorder()
+	NewUploadHandler(backend, encMgr, logger, xmlWriter, errorWriter, requestParser).Handle(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>XAmzContentSHA256Mismatch</Code>")
+	assert.Zero(t, backend.storedParts, "the part must not reach the backend")
+}
+
 func TestMultipartHandlers_Integration(t *testing.T) {
 	encMgr, mockS3Backend, logger, xmlWriter, errorWriter, requestParser := setupMultipartTestEnv(t)
 
@@ -686,6 +884,7 @@ func TestMultipartHandlers_Integration(t *testing.T) {
 		Location: aws.String("http://test-bucket.s3.amazonaws.com/test-key"),
 	}, nil)
 
+	mockS3Backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
 	mockS3Backend.On("CopyObject", mock.Anything, mock.Anything).Return(&s3.CopyObjectOutput{
 		CopyObjectResult: &types.CopyObjectResult{
 			ETag: aws.String(`"integration-complete-etag"`),
@@ -785,6 +984,8 @@ func TestCompleteHandler_Handle_CopyObjectFailure(t *testing.T) {
 		Location: aws.String("http://test-bucket.s3.amazonaws.com/test-key"),
 	}, nil)
 
+	mockS3Backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+
 	// Mock CopyObject for metadata - THIS FAILS (simulating context canceled or other error)
 	mockS3Backend.On("CopyObject", mock.Anything, mock.MatchedBy(func(input *s3.CopyObjectInput) bool {
 		return aws.ToString(input.Bucket) == "test-bucket" &&
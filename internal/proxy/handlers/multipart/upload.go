@@ -2,7 +2,6 @@ package multipart
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -15,6 +14,7 @@ import (
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 	"github.com/sirupsen/logrus"
 )
 
@@ -58,7 +58,7 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	partNumberStr := r.URL.Query().Get("partNumber")
 
 	// Detailed request logging for debugging
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":        bucket,
 		"key":           key,
 		"uploadId":      uploadID,
@@ -74,31 +74,20 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		"requestURI":    r.RequestURI,
 	}).Debug("UploadPart - Request details")
 
-	// Read request body with automatic chunked decoding if needed
-	bodyData, err := h.requestParser.ReadBody(r)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to read request body")
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-
-	// Reset request body with processed data
-	h.requestParser.ResetBody(r, bodyData)
-
 	if uploadID == "" || partNumberStr == "" {
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"bucket":     bucket,
 			"key":        key,
 			"uploadId":   uploadID,
 			"partNumber": partNumberStr,
 		}).Error("Missing uploadId or partNumber")
-		http.Error(w, "Missing uploadId or partNumber", http.StatusBadRequest)
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidRequest", "Missing uploadId or partNumber")
 		return
 	}
 
 	partNumber, err := strconv.Atoi(partNumberStr)
 	if err != nil || partNumber < 1 || partNumber > 10000 {
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"bucket":       bucket,
 			"key":          key,
 			"uploadId":     uploadID,
@@ -106,11 +95,11 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			"parsedNumber": partNumber,
 			"parseError":   err,
 		}).Error("Invalid partNumber")
-		http.Error(w, "Invalid partNumber", http.StatusBadRequest)
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
 		return
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":     bucket,
 		"key":        key,
 		"uploadId":   uploadID,
@@ -119,13 +108,13 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	uploadState, err := h.encryptionMgr.GetMultipartUploadState(uploadID)
 	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
+		h.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
 			"bucket":     bucket,
 			"key":        key,
 			"uploadId":   uploadID,
 			"partNumber": partNumber,
 		}).Error("Failed to get multipart upload state")
-		http.Error(w, "Invalid upload ID", http.StatusBadRequest)
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
 
@@ -133,7 +122,7 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	contentType := string(uploadState.ContentType)
 	metadataPrefix := h.encryptionMgr.GetMetadataKeyPrefix()
 	dataAlgorithm := uploadState.Metadata[metadataPrefix+"dek-algorithm"]
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":         bucket,
 		"key":            key,
 		"uploadId":       uploadID,
@@ -144,19 +133,19 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}).Debug("Upload state retrieved - determining handler")
 
 	// For multipart uploads (ContentTypeMultipart), always use streaming handler
-	if contentType == "multipart" || dataAlgorithm == "aes-ctr" {
-		h.logger.WithFields(logrus.Fields{
+	if contentType == "multipart" || encryption.IsStreamingAlgorithm(dataAlgorithm) {
+		h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"bucket":     bucket,
 			"key":        key,
 			"uploadId":   uploadID,
 			"partNumber": partNumber,
 		}).Debug("Using streaming upload handler for multipart upload")
-		h.handleStreamingUploadPart(w, r, bucket, key, uploadID, partNumber, uploadState, bodyData)
+		h.handleStreamingUploadPart(w, r, bucket, key, uploadID, partNumber, uploadState)
 		return
 	}
 
 	// ERROR: This should never happen for multipart uploads
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"bucket":         bucket,
 		"key":            key,
 		"uploadId":       uploadID,
@@ -167,265 +156,68 @@ func (h *UploadHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		"uploadState":    uploadState,
 	}).Error("Unexpected fallback to standard upload handler for multipart upload - this indicates a configuration error")
 
-	h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "InternalError",
+	h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "InternalError",
 		"Multipart upload configuration error: unexpected handler selection")
 }
 
-// handleStandardUploadPart handles streaming upload part requests (no memory buffering)
-//
-//nolint:unused // alternative implementation for different upload strategies
-func (h *UploadHandler) handleStandardUploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string, partNumber int) {
+// handleStreamingUploadPart encrypts the part while it is sent to the backend.
+// A body of known length is never held in memory: AES-CTR adds no overhead, so
+// the backend Content-Length is the plaintext length. A body of unknown length,
+// or a part that arrives ahead of earlier ones, is buffered.
+func (h *UploadHandler) handleStreamingUploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string, partNumber int, _ *orchestration.MultipartSession) {
 	ctx := r.Context()
 
-	log := h.logger.WithFields(logrus.Fields{
+	log := h.logger.WithContext(ctx).WithFields(logrus.Fields{
 		"bucket":     bucket,
 		"key":        key,
 		"uploadId":   uploadID,
 		"partNumber": partNumber,
-		"handler":    "streaming-standard",
+		"handler":    "streaming",
 	})
 
-	log.Debug("Using streaming encryption (NO memory buffering) to prevent OOM")
-
-	// Read request body with automatic chunked decoding if needed
-	bodyData, err := h.requestParser.ReadBody(r)
-	if err != nil {
-		log.WithError(err).Error("Failed to read request body for streaming")
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-
-	// Create reader from processed body data
-	var bodyReader io.Reader = bytes.NewReader(bodyData)
-
-	// Check Content-Length to determine if we need segmented streaming
-	var contentLength int64
-	if contentLengthStr := r.Header.Get("Content-Length"); contentLengthStr != "" {
-		if parsedLength, err := strconv.ParseInt(contentLengthStr, 10, 64); err == nil {
-			contentLength = parsedLength
-		}
-	}
-
-	// Get configured streaming segment size (default 12MB)
-	maxSegmentSize := h.encryptionMgr.GetStreamingSegmentSize()
-
-	// Use segmented streaming for chunks larger than the configured segment size
-	if contentLength > maxSegmentSize {
-		log.WithFields(logrus.Fields{
-			"contentLength":   contentLength,
-			"maxSegmentSize":  maxSegmentSize,
-			"segmentRequired": true,
-		}).Debug("Chunk size exceeds segment limit, using streaming buffer approach")
-
-		// Track segments for final part assembly
-		var segmentETags []string
-		segmentNumber := 0
-
-		// Define callback function for when segments are ready
-		onSegmentReady := func(segmentData []byte) error {
-			segmentNumber++
-
-			// Create unique part number for this segment (part * 1000 + segment)
-			segmentPartNumber := partNumber*1000 + segmentNumber
-
-			// Validate segment part number is within reasonable range and fits in int32
-			if segmentPartNumber > 10000 || segmentPartNumber > 2147483647 {
-				return fmt.Errorf("segment part number %d exceeds S3 limit or int32 range", segmentPartNumber)
-			}
-
-			uploadInput := &s3.UploadPartInput{
-				Bucket:        aws.String(bucket),
-				Key:           aws.String(key),
-				UploadId:      aws.String(uploadID),
-				PartNumber:    aws.Int32(int32(segmentPartNumber)), // #nosec G115 - segmentPartNumber is validated against int32 range above
-				Body:          bytes.NewReader(segmentData),
-				ContentLength: aws.Int64(int64(len(segmentData))),
-			}
-
-			// Copy required headers to S3 request (only for first segment)
-			if segmentNumber == 1 && r.Header.Get("Content-MD5") != "" {
-				uploadInput.ContentMD5 = aws.String(r.Header.Get("Content-MD5"))
-			}
-
-			// Upload the encrypted segment to S3
-			uploadOutput, err := h.s3Backend.UploadPart(ctx, uploadInput)
-			if err != nil {
-				return fmt.Errorf("failed to upload segment %d to S3: %w", segmentNumber, err)
-			}
-
-			log.WithFields(logrus.Fields{
-				"segment":    segmentNumber,
-				"partNumber": segmentPartNumber,
-				"etag":       aws.ToString(uploadOutput.ETag),
-				"size":       len(segmentData),
-			}).Debug("Segment uploaded successfully")
-
-			// Store the segment ETag
-			if uploadOutput.ETag != nil {
-				cleanETag := strings.Trim(aws.ToString(uploadOutput.ETag), "\"")
-				err = h.encryptionMgr.StorePartETag(uploadID, segmentPartNumber, cleanETag)
-				if err != nil {
-					log.WithFields(logrus.Fields{
-						"uploadID":          uploadID,
-						"segmentPartNumber": segmentPartNumber,
-					}).Warn("Failed to store segment ETag for completion")
-				}
-				segmentETags = append(segmentETags, aws.ToString(uploadOutput.ETag))
-			}
-
-			return nil
-		}
-
-		// Use streaming buffer encryption with callback
-		err := h.encryptionMgr.UploadPartStreamingBuffer(ctx, uploadID, partNumber, bodyReader, maxSegmentSize, onSegmentReady)
+	var encResult *orchestration.EncryptionResult
+	partSize := h.requestParser.DecodedContentLength(r)
+	if partSize >= 0 {
+		log.WithField("partSize", partSize).Debug("Streaming part of known length to the backend")
+		bodyStream := h.requestParser.StreamingReader(r)
+		var err error
+		encResult, err = h.encryptionMgr.UploadPartKnownLength(ctx, uploadID, partNumber, partSize, bodyStream)
 		if err != nil {
-			log.WithError(err).Error("Failed to process part with streaming buffer")
+			log.WithError(err).Error("Failed to encrypt part with streaming")
 			h.errorWriter.WriteS3Error(w, err, bucket, key)
 			return
 		}
-
-		log.WithFields(logrus.Fields{
-			"segments":  segmentNumber,
-			"totalSize": contentLength,
-			"lastETag":  segmentETags[len(segmentETags)-1],
-		}).Debug("Part processed successfully with streaming buffer")
-
-		// Return successful response with last segment ETag
-		if len(segmentETags) > 0 {
-			w.Header().Set("ETag", segmentETags[len(segmentETags)-1])
+	} else {
+		// The backend needs the length up front, so the part is read completely
+		bodyData, err := h.requestParser.ReadBody(r)
+		if err != nil {
+			log.WithError(err).Error("Failed to read request body")
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "ReadError", "Failed to read request body")
+			return
 		}
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	// Use standard streaming encryption for smaller chunks
-	encResult, err := h.encryptionMgr.UploadPartStreaming(ctx, uploadID, partNumber, bodyReader)
-	if err != nil {
-		log.WithError(err).Error("Failed to encrypt part")
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
-		return
-	}
-
-	// Convert streaming result to bytes for S3 upload
-	encryptedData, err := io.ReadAll(encResult.EncryptedData)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to read encrypted part data from stream")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "EncryptionError", "Failed to read encrypted part data")
-		return
-	}
-
-	log.WithFields(logrus.Fields{
-		"encryptedSize": len(encryptedData),
-	}).Debug("Part encrypted successfully with streaming")
-
-	// Prepare S3 upload part input with encrypted data
-	// Validate part number is within int32 range
-	if partNumber < 1 || partNumber > 10000 {
-		h.logger.WithFields(logrus.Fields{
-			"bucket":     bucket,
-			"key":        key,
-			"uploadId":   uploadID,
-			"partNumber": partNumber,
-		}).Error("Part number out of valid range")
-		h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidPartNumber", "Part number must be between 1 and 10000")
-		return
-	}
-
-	uploadInput := &s3.UploadPartInput{
-		Bucket:        aws.String(bucket),
-		Key:           aws.String(key),
-		UploadId:      aws.String(uploadID),
-		PartNumber:    aws.Int32(int32(partNumber)),
-		Body:          bytes.NewReader(encryptedData),
-		ContentLength: aws.Int64(int64(len(encryptedData))),
-	}
-
-	// Copy required headers to S3 request
-	if r.Header.Get("Content-MD5") != "" {
-		uploadInput.ContentMD5 = aws.String(r.Header.Get("Content-MD5"))
-	}
-
-	// Upload the encrypted part to S3
-	uploadOutput, err := h.s3Backend.UploadPart(ctx, uploadInput)
-	if err != nil {
-		log.WithError(err).Error("Failed to upload part to S3")
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
-		return
-	}
-
-	log.WithField("etag", aws.ToString(uploadOutput.ETag)).Debug("Part uploaded successfully")
-
-	// Store the part ETag for completion
-	if uploadOutput.ETag != nil {
-		cleanETag := strings.Trim(aws.ToString(uploadOutput.ETag), "\"")
-		err = h.encryptionMgr.StorePartETag(uploadID, partNumber, cleanETag)
+		log.WithField("bodySize", len(bodyData)).Debug("Buffered part of unknown length")
+		partSize = int64(len(bodyData))
+		encResult, err = h.encryptionMgr.UploadPartStreaming(ctx, uploadID, partNumber, bytes.NewReader(bodyData))
 		if err != nil {
-			log.WithFields(logrus.Fields{
-				"uploadID":   uploadID,
-				"partNumber": partNumber,
-			}).Warn("Failed to store part ETag for completion")
+			log.WithError(err).Error("Failed to encrypt part with streaming")
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
 		}
 	}
-
-	// Release encrypted data immediately after upload (memory management)
-	encResult = nil
-
-	// Return successful response with ETag
-	w.Header().Set("ETag", aws.ToString(uploadOutput.ETag))
-	if uploadOutput.ServerSideEncryption != "" {
-		w.Header().Set("x-amz-server-side-encryption", string(uploadOutput.ServerSideEncryption))
-	}
-	w.WriteHeader(http.StatusOK)
-}
-
-// handleStreamingUploadPart handles streaming upload part requests with encryption
-func (h *UploadHandler) handleStreamingUploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string, partNumber int, _ *orchestration.MultipartSession, bodyData []byte) {
-	ctx := r.Context()
-
-	log := h.logger.WithFields(logrus.Fields{
-		"bucket":     bucket,
-		"key":        key,
-		"uploadId":   uploadID,
-		"partNumber": partNumber,
-		"handler":    "streaming",
-	})
-
-	// Use the already read and processed body data to avoid double reading
-	log.WithField("bodySize", len(bodyData)).Debug("Using pre-read body data for streaming upload")
-
-	// Create reader from processed body data
-	var bodyReader io.Reader = bytes.NewReader(bodyData)
-
-	// Use streaming encryption instead of buffering entire part in memory
-	log.Debug("Using streaming encryption for part upload")
-
-	// Use the streaming encryption that processes data in chunks
-	encResult, err := h.encryptionMgr.UploadPartStreaming(ctx, uploadID, partNumber, bodyReader)
-	if err != nil {
-		log.WithError(err).Error("Failed to encrypt part with streaming")
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
-		return
+	// Finishes a streamed part the backend did not read to the end
+	if closer, ok := encResult.EncryptedData.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
 	}
 
-	// Convert streaming result to bytes for S3 upload
-	encryptedData, err := io.ReadAll(encResult.EncryptedData)
-	if err != nil {
-		log.WithError(err).Error("Failed to read encrypted part data from stream")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "EncryptionError", "Failed to read encrypted part data")
-		return
-	}
-
-	log.WithField("encryptedSize", len(encryptedData)).Debug("Part encrypted successfully with streaming")
-
 	// Validate part number is within int32 range (should already be validated but double check)
 	if partNumber < 1 || partNumber > 10000 {
-		h.logger.WithFields(logrus.Fields{
+		h.logger.WithContext(ctx).WithFields(logrus.Fields{
 			"bucket":     bucket,
 			"key":        key,
 			"uploadId":   uploadID,
 			"partNumber": partNumber,
 		}).Error("Part number out of valid range for streaming")
-		h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidPartNumber", "Part number must be between 1 and 10000")
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidPartNumber", "Part number must be between 1 and 10000")
 		return
 	}
 
@@ -435,8 +227,8 @@ func (h *UploadHandler) handleStreamingUploadPart(w http.ResponseWriter, r *http
 		Key:           aws.String(key),
 		UploadId:      aws.String(uploadID),
 		PartNumber:    aws.Int32(int32(partNumber)),
-		Body:          bytes.NewReader(encryptedData),
-		ContentLength: aws.Int64(int64(len(encryptedData))),
+		Body:          encResult.EncryptedData,
+		ContentLength: aws.Int64(partSize),
 	}
 
 	// Copy relevant headers
@@ -462,9 +254,6 @@ func (h *UploadHandler) handleStreamingUploadPart(w http.ResponseWriter, r *http
 		}
 	}
 
-	// Release encrypted data immediately after upload (memory management)
-	encResult = nil
-
 	// Set response headers
 	if result.ETag != nil {
 		w.Header().Set("ETag", *result.ETag)
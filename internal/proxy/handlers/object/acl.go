@@ -42,7 +42,7 @@ func (h *ACLHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	bucket := vars["bucket"]
 	key := vars["key"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 		"key":    key,
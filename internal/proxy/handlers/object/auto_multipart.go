@@ -0,0 +1,83 @@
+package object
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxMultipartParts is the S3 limit on the number of parts per upload
+	maxMultipartParts = 10000
+
+	// partRetryBaseDelay is the backoff before the first part retry; it doubles per attempt
+	partRetryBaseDelay = 200 * time.Millisecond
+)
+
+// getAutoMultipartThreshold returns the plaintext size from which single PUTs
+// are converted into a backend multipart upload, 0 when disabled
+func (h *Handler) getAutoMultipartThreshold() int64 {
+	if h.config == nil {
+		return 0
+	}
+	return h.config.Optimizations.AutoMultipartThreshold
+}
+
+// getAutoMultipartPartRetries returns the number of extra attempts per failed part
+func (h *Handler) getAutoMultipartPartRetries() int {
+	if h.config == nil {
+		return 0
+	}
+	return h.config.Optimizations.AutoMultipartPartRetries
+}
+
+// autoMultipartPartSize returns the configured segment size, raised to the next
+// MiB when an object of plaintextLen bytes would otherwise need more than
+// maxMultipartParts parts. plaintextLen < 0 means the size is unknown.
+func (h *Handler) autoMultipartPartSize(plaintextLen int64) int64 {
+	const mib = 1024 * 1024
+	partSize := h.getSegmentSize()
+	if plaintextLen > partSize*maxMultipartParts {
+		minSize := (plaintextLen + maxMultipartParts - 1) / maxMultipartParts
+		partSize = (minSize + mib - 1) / mib * mib
+	}
+	return partSize
+}
+
+// uploadPartWithRetry uploads a single part and retries it with exponential
+// backoff when the backend fails. Only seekable bodies can be sent again;
+// other bodies get a single attempt.
+func (h *Handler) uploadPartWithRetry(ctx context.Context, input *s3.UploadPartInput, log *logrus.Entry) (*s3.UploadPartOutput, error) {
+	seeker, seekable := input.Body.(io.Seeker)
+	retries := h.getAutoMultipartPartRetries()
+	if !seekable {
+		retries = 0
+	}
+
+	delay := partRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		output, err := h.s3Backend.UploadPart(ctx, input)
+		if err == nil || attempt >= retries || ctx.Err() != nil {
+			return output, err
+		}
+
+		log.WithError(err).WithFields(logrus.Fields{
+			"part_number": *input.PartNumber,
+			"attempt":     attempt + 1,
+			"retries":     retries,
+		}).Warn("Auto-multipart: part upload failed, retrying")
+
+		if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestAutoMultipartPartSize(t *testing.T) {
+	handler := &Handler{config: &config.Config{Optimizations: config.OptimizationsConfig{StreamingSegmentSize: 12 * 1024 * 1024}}}
+
+	assert.Equal(t, int64(12*1024*1024), handler.autoMultipartPartSize(-1), "unknown size uses the segment size")
+	assert.Equal(t, int64(12*1024*1024), handler.autoMultipartPartSize(100*1024*1024*1024))
+
+	// 200 GiB would need 17067 parts of 12 MiB
+	size := int64(200 * 1024 * 1024 * 1024)
+	partSize := handler.autoMultipartPartSize(size)
+	assert.Zero(t, partSize%(1024*1024))
+	assert.LessOrEqual(t, (size+partSize-1)/partSize, int64(maxMultipartParts))
+}
+
+func TestPutObject_ConvertsLargePutAndRetriesFailedPart(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.AutoMultipartThreshold = 5 * 1024 * 1024
+	handler.config.Optimizations.AutoMultipartPartRetries = 2
+	handler.config.Optimizations.StreamingSegmentSize = 5 * 1024 * 1024
+	handler.config.Optimizations.MultipartUploadConcurrency = 1
+
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), (6*1024*1024)/16)
+
+	backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).
+		Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil).Once()
+
+	// One upload worker, so the first UploadPart call is part 1
+	var mu sync.Mutex
+	attempts := map[int32][][]byte{}
+	record := func(args mock.Arguments) {
+		input := args.Get(1).(*s3.UploadPartInput)
+		body, err := io.ReadAll(input.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[aws.ToInt32(input.PartNumber)] = append(attempts[aws.ToInt32(input.PartNumber)], body)
+	}
+	backend.On("UploadPart", mock.Anything, mock.Anything).Run(record).Return(nil, errors.New("connection reset by peer")).Once()
+	backend.On("UploadPart", mock.Anything, mock.Anything).Run(record).Return(&s3.UploadPartOutput{ETag: aws.String(`"part-etag"`)}, nil)
+	backend.On("CompleteMultipartUpload", mock.Anything, mock.Anything).
+		Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"final-etag"`)}, nil).Once()
+	// The metadata self-copy may be verified with a HEAD, which has to see the copied metadata
+	head := &s3.HeadObjectOutput{}
+	backend.On("CopyObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		head.Metadata = args.Get(1).(*s3.CopyObjectInput).Metadata
+	}).Return(&s3.CopyObjectOutput{}, nil).Maybe()
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(head, nil).Maybe()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/large.bin", bytes.NewReader(plaintext))
+	req.ContentLength = int64(len(plaintext))
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "large.bin")
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `"final-etag"`, rr.Header().Get("ETag"))
+
+	require.Len(t, attempts[1], 2, "the failed part is retried")
+	assert.Equal(t, attempts[1][0], attempts[1][1], "a retry sends the same ciphertext")
+	require.Len(t, attempts[2], 1)
+	assert.Equal(t, len(plaintext), len(attempts[1][1])+len(attempts[2][0]))
+	assert.NotEqual(t, plaintext[:len(attempts[1][1])], attempts[1][1], "parts are encrypted")
+	backend.AssertNotCalled(t, "AbortMultipartUpload", mock.Anything, mock.Anything)
+}
+
+func TestPutObject_AbortsAfterPartRetriesExhausted(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.AutoMultipartThreshold = 5 * 1024 * 1024
+	handler.config.Optimizations.AutoMultipartPartRetries = 1
+	handler.config.Optimizations.StreamingSegmentSize = 5 * 1024 * 1024
+
+	plaintext := make([]byte, 5*1024*1024)
+
+	backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).
+		Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-2")}, nil).Once()
+	backend.On("UploadPart", mock.Anything, mock.Anything).Return(nil, errors.New("backend unavailable")).Twice()
+	backend.On("AbortMultipartUpload", mock.Anything, mock.Anything).Return(&s3.AbortMultipartUploadOutput{}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/large.bin", bytes.NewReader(plaintext))
+	req.ContentLength = int64(len(plaintext))
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "large.bin")
+
+	assert.GreaterOrEqual(t, rr.Code, 500)
+	backend.AssertExpectations(t)
+	backend.AssertNotCalled(t, "CompleteMultipartUpload", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,248 @@
+package object
+
+import (
+	"crypto/sha1" // #nosec G505 - SHA1 is an S3 checksum algorithm, not used for security
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// checksumMetadataKey (with the metadata prefix and the lower-case algorithm)
+// stores the base64 checksum of the plaintext, e.g. s3ep-checksum-crc32
+const checksumMetadataKey = "checksum-"
+
+var (
+	// errInvalidChecksumRequest is returned for an unsupported algorithm or
+	// more than one checksum on a request
+	errInvalidChecksumRequest = errors.New("invalid checksum request")
+	// errChecksumMismatch is returned if data does not match its checksum
+	errChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// checksumAlgorithm is an S3 additional checksum algorithm
+type checksumAlgorithm struct {
+	name   string // as in x-amz-sdk-checksum-algorithm
+	header string
+	hash   func() hash.Hash
+}
+
+var checksumAlgorithms = []checksumAlgorithm{
+	{name: "CRC32", header: "X-Amz-Checksum-Crc32", hash: func() hash.Hash { return crc32.NewIEEE() }},
+	{name: "CRC32C", header: "X-Amz-Checksum-Crc32c", hash: func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+	{name: "SHA1", header: "X-Amz-Checksum-Sha1", hash: sha1.New},
+	{name: "SHA256", header: "X-Amz-Checksum-Sha256", hash: sha256.New},
+}
+
+// metadataKey returns the metadata key, without prefix, of the algorithm's
+// checksum
+func (a checksumAlgorithm) metadataKey() string {
+	return checksumMetadataKey + strings.ToLower(a.name)
+}
+
+// requestChecksum computes the checksum of the plaintext of an upload. The
+// client either sends the expected value as header or trailer, or only
+// names the algorithm and the checksum is recorded as computed.
+type requestChecksum struct {
+	algorithm checksumAlgorithm
+	expected  string // base64, empty if not sent as header
+	hash      hash.Hash
+	sum       string // base64, set by verify
+}
+
+// parseRequestChecksum returns the checksum requested for the body of r, or
+// nil if the client requested none
+func parseRequestChecksum(r *http.Request) (*requestChecksum, error) {
+	var checksum *requestChecksum
+	set := func(algorithm checksumAlgorithm, expected string) error {
+		if checksum != nil && checksum.algorithm.name != algorithm.name {
+			return fmt.Errorf("%w: expecting a single x-amz-checksum- header", errInvalidChecksumRequest)
+		}
+		if checksum == nil {
+			checksum = &requestChecksum{algorithm: algorithm, hash: algorithm.hash()}
+		}
+		if expected != "" {
+			checksum.expected = expected
+		}
+		return nil
+	}
+
+	for _, name := range []string{"X-Amz-Sdk-Checksum-Algorithm", "X-Amz-Checksum-Algorithm"} {
+		if value := r.Header.Get(name); value != "" {
+			algorithm, ok := checksumAlgorithmByName(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: unsupported checksum algorithm %q", errInvalidChecksumRequest, value)
+			}
+			if err := set(algorithm, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, algorithm := range checksumAlgorithms {
+		if value := r.Header.Get(algorithm.header); value != "" {
+			if err := set(algorithm, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, trailer := range strings.Split(r.Header.Get("X-Amz-Trailer"), ",") {
+		trailer = http.CanonicalHeaderKey(strings.TrimSpace(trailer))
+		if !strings.HasPrefix(trailer, "X-Amz-Checksum-") {
+			continue
+		}
+		algorithm, ok := checksumAlgorithmByHeader(trailer)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported checksum trailer %q", errInvalidChecksumRequest, trailer)
+		}
+		if err := set(algorithm, ""); err != nil {
+			return nil, err
+		}
+	}
+	return checksum, nil
+}
+
+func checksumAlgorithmByName(name string) (checksumAlgorithm, bool) {
+	for _, algorithm := range checksumAlgorithms {
+		if strings.EqualFold(algorithm.name, name) {
+			return algorithm, true
+		}
+	}
+	return checksumAlgorithm{}, false
+}
+
+func checksumAlgorithmByHeader(header string) (checksumAlgorithm, bool) {
+	for _, algorithm := range checksumAlgorithms {
+		if strings.EqualFold(algorithm.header, header) {
+			return algorithm, true
+		}
+	}
+	return checksumAlgorithm{}, false
+}
+
+// Write adds plaintext to the checksum
+func (c *requestChecksum) Write(p []byte) (int, error) {
+	return c.hash.Write(p)
+}
+
+// verify computes the checksum of the plaintext written and returns
+// errChecksumMismatch if it differs from the one the client sent. Trailers
+// are only known once the body was read.
+func (c *requestChecksum) verify(r *http.Request) error {
+	sum := base64.StdEncoding.EncodeToString(c.hash.Sum(nil))
+	expected := c.expected
+	if expected == "" {
+		expected = r.Trailer.Get(c.algorithm.header)
+	}
+	if expected != "" && expected != sum {
+		return fmt.Errorf("%w: %s", errChecksumMismatch, strings.ToLower(c.algorithm.header))
+	}
+	c.sum = sum
+	return nil
+}
+
+// setResponseHeader reports the verified checksum on the response of an
+// upload, as S3 does
+func (c *requestChecksum) setResponseHeader(w http.ResponseWriter) {
+	if c != nil && c.sum != "" {
+		w.Header().Set(c.algorithm.header, c.sum)
+	}
+}
+
+// writeChecksumError writes the S3 error of a failed parseRequestChecksum or
+// verify
+func (h *Handler) writeChecksumError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errChecksumMismatch) {
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "BadDigest", "The checksum you specified did not match the calculated checksum.")
+		return
+	}
+	h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidRequest", err.Error())
+}
+
+// recordChecksum stores the verified plaintext checksum in the metadata of a
+// new object
+func (h *Handler) recordChecksum(metadata map[string]string, checksum *requestChecksum) {
+	if checksum != nil && checksum.sum != "" {
+		metadata[h.metadataPrefix+checksum.algorithm.metadataKey()] = checksum.sum
+	}
+}
+
+// storedChecksum returns the algorithm and value of the plaintext checksum
+// recorded for an object
+func (h *Handler) storedChecksum(metadata map[string]string) (checksumAlgorithm, string, bool) {
+	for _, algorithm := range checksumAlgorithms {
+		if sum, ok := metadata[h.metadataPrefix+algorithm.metadataKey()]; ok && sum != "" {
+			return algorithm, sum, true
+		}
+	}
+	return checksumAlgorithm{}, "", false
+}
+
+// setChecksumHeaders reports the recorded plaintext checksum of an object to
+// clients that enabled checksum mode. The backend's checksums are of the
+// ciphertext and never reported.
+func (h *Handler) setChecksumHeaders(w http.ResponseWriter, r *http.Request, metadata map[string]string) {
+	if !strings.EqualFold(r.Header.Get("X-Amz-Checksum-Mode"), "ENABLED") {
+		return
+	}
+	if algorithm, sum, ok := h.storedChecksum(metadata); ok {
+		w.Header().Set(algorithm.header, sum)
+		w.Header().Set("X-Amz-Checksum-Type", "FULL_OBJECT")
+	}
+}
+
+// verifyChecksum validates body against the recorded plaintext checksum of
+// an object. The last byte is held back until the checksum matched, so a
+// mismatch leaves the response short of its Content-Length and the client
+// sees a failed download instead of corrupt data.
+func (h *Handler) verifyChecksum(body io.ReadCloser, metadata map[string]string) io.ReadCloser {
+	algorithm, sum, ok := h.storedChecksum(metadata)
+	if !ok {
+		return body
+	}
+	return &checksumVerifyingReader{ReadCloser: body, hash: algorithm.hash(), header: algorithm.header, expected: sum}
+}
+
+// checksumVerifyingReader hashes a body and fails at EOF if the checksum
+// does not match
+type checksumVerifyingReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	header   string
+	expected string
+	held     []byte // the last byte read, not yet returned
+	err      error
+}
+
+func (r *checksumVerifyingReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) <= len(r.held) {
+		return 0, nil
+	}
+
+	n := copy(p, r.held)
+	m, err := r.ReadCloser.Read(p[n:])
+	_, _ = r.hash.Write(p[n : n+m])
+	n += m
+
+	if err == io.EOF {
+		if base64.StdEncoding.EncodeToString(r.hash.Sum(nil)) != r.expected {
+			r.err = fmt.Errorf("%w: %s", errChecksumMismatch, strings.ToLower(r.header))
+			return 0, r.err
+		}
+		r.held = nil
+		r.err = io.EOF
+		return n, io.EOF
+	}
+	if n > 0 {
+		r.held = append(r.held[:0], p[n-1])
+		n--
+	}
+	return n, err
+}
@@ -0,0 +1,187 @@
+package object
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func checksumOf(h hash.Hash, data []byte) string {
+	_, _ = h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func crc32Base64(data []byte) string {
+	return checksumOf(crc32.NewIEEE(), data)
+}
+
+func TestChecksum_PutGetHead(t *testing.T) {
+	for _, streamingThreshold := range []int64{5 * 1024 * 1024, 1024} {
+		handler, backend, _ := newBackfillTestHandler(t, false)
+		handler.config.Optimizations.StreamingThreshold = streamingThreshold
+		plaintext := bytes.Repeat([]byte("checksummed "), 1000)
+		checksum := crc32Base64(plaintext)
+
+		var stored []byte
+		var metadata map[string]string
+		backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			input := args.Get(1).(*s3.PutObjectInput)
+			stored, _ = io.ReadAll(input.Body)
+			metadata = input.Metadata
+			assert.Nil(t, input.ChecksumCRC32, "the plaintext checksum is not sent with the ciphertext")
+		}).Return(&s3.PutObjectOutput{ETag: aws.String(`"ciphertext-etag"`)}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(plaintext))
+		req.Header.Set("x-amz-checksum-crc32", checksum)
+		rr := httptest.NewRecorder()
+		handler.handlePutObject(rr, req, "bucket", "key")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, checksum, rr.Header().Get("x-amz-checksum-crc32"))
+		assert.Equal(t, checksum, metadata["s3ep-checksum-crc32"])
+
+		backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+			Body:          io.NopCloser(bytes.NewReader(stored)),
+			ContentLength: aws.Int64(int64(len(stored))),
+			ChecksumCRC32: aws.String("ciphertext-checksum"),
+			Metadata:      metadata,
+		}, nil).Once()
+		req = httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+		rr = httptest.NewRecorder()
+		handler.handleGetObject(rr, req, "bucket", "key")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, plaintext, rr.Body.Bytes())
+		assert.Equal(t, checksum, rr.Header().Get("x-amz-checksum-crc32"))
+		assert.Equal(t, "FULL_OBJECT", rr.Header().Get("x-amz-checksum-type"))
+		assert.Empty(t, rr.Header().Get("x-amz-meta-s3ep-checksum-crc32"))
+
+		backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{Metadata: metadata}, nil).Twice()
+		req = httptest.NewRequest(http.MethodHead, "/bucket/key", nil)
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+		rr = httptest.NewRecorder()
+		handler.handleHeadObject(rr, req, "bucket", "key")
+		assert.Equal(t, checksum, rr.Header().Get("x-amz-checksum-crc32"))
+
+		// Only reported in checksum mode
+		rr = httptest.NewRecorder()
+		handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+		assert.Empty(t, rr.Header().Get("x-amz-checksum-crc32"))
+	}
+}
+
+func TestChecksum_PutRejected(t *testing.T) {
+	plaintext := []byte("data")
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    string
+	}{
+		{"mismatch", map[string]string{"x-amz-checksum-sha256": checksumOf(sha256.New(), []byte("other"))}, "BadDigest"},
+		{"unsupported algorithm", map[string]string{"x-amz-sdk-checksum-algorithm": "MD4"}, "InvalidRequest"},
+		{"two algorithms", map[string]string{
+			"x-amz-checksum-crc32":  crc32Base64(plaintext),
+			"x-amz-checksum-sha256": checksumOf(sha256.New(), plaintext),
+		}, "InvalidRequest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, backend, _ := newBackfillTestHandler(t, false)
+			handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+			req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(plaintext))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.handlePutObject(rr, req, "bucket", "key")
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), "<Code>"+tt.code+"</Code>")
+			backend.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestChecksum_AlgorithmOnly(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+	plaintext := []byte("data")
+
+	var metadata map[string]string
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		metadata = args.Get(1).(*s3.PutObjectInput).Metadata
+	}).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(plaintext))
+	req.Header.Set("x-amz-sdk-checksum-algorithm", "SHA256")
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, checksumOf(sha256.New(), plaintext), metadata["s3ep-checksum-sha256"])
+}
+
+func TestChecksum_Trailer(t *testing.T) {
+	crc32c := func(data []byte) string {
+		return checksumOf(crc32.New(crc32.MakeTable(crc32.Castagnoli)), data)
+	}
+	plaintext := []byte("trailing checksum")
+
+	for _, trailer := range []string{crc32c(plaintext), crc32c([]byte("other"))} {
+		handler, backend, _ := newBackfillTestHandler(t, false)
+		handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+		handler.config.Optimizations.CleanAWSSignatureV4Chunked = true
+
+		var metadata map[string]string
+		backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			metadata = args.Get(1).(*s3.PutObjectInput).Metadata
+		}).Return(&s3.PutObjectOutput{}, nil).Maybe()
+
+		body := fmt.Sprintf("%x\r\n%s\r\n0\r\nx-amz-checksum-crc32c:%s\r\n\r\n", len(plaintext), plaintext, trailer)
+		req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Encoding", "aws-chunked")
+		req.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+		req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(len(plaintext)))
+		req.Header.Set("X-Amz-Trailer", "x-amz-checksum-crc32c")
+		rr := httptest.NewRecorder()
+		handler.handlePutObject(rr, req, "bucket", "key")
+
+		if trailer == crc32c(plaintext) {
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			assert.Equal(t, trailer, metadata["s3ep-checksum-crc32c"])
+		} else {
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			backend.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+		}
+	}
+}
+
+func TestChecksum_GetMismatchAborts(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, false)
+	plaintext := bytes.Repeat([]byte("x"), 1000)
+
+	for _, checksum := range []string{crc32Base64(plaintext), crc32Base64([]byte("other"))} {
+		output := gcmObject(t, encMgr, plaintext)
+		output.Metadata["s3ep-checksum-crc32"] = checksum
+		backend.On("GetObject", mock.Anything, mock.Anything).Return(output, nil).Once()
+
+		rr := httptest.NewRecorder()
+		handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/legacy.bin", nil), "bucket", "legacy.bin")
+		assert.Equal(t, fmt.Sprint(len(plaintext)), rr.Header().Get("Content-Length"))
+		if checksum == crc32Base64(plaintext) {
+			assert.Equal(t, plaintext, rr.Body.Bytes())
+		} else {
+			assert.Less(t, rr.Body.Len(), len(plaintext), "a corrupt body is not completed")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package object
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/compression"
+)
+
+// compressForPut compresses the body of a PUT before it is encrypted. It
+// returns the data to encrypt and the metadata to store with the object,
+// which is empty if the data is stored as sent. Bodies the client already
+// encoded, and bodies that do not get smaller, are stored as sent.
+func (h *Handler) compressForPut(r *http.Request, data []byte) ([]byte, map[string]string) {
+	if h.compressor == nil || r.Header.Get("Content-Encoding") != "" || h.encryptionMgr.IsNoneProvider() {
+		return data, nil
+	}
+
+	compressed, ok, err := h.compressor.Compress(data)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Warn("Failed to compress object, storing it uncompressed")
+		return data, nil
+	}
+	if !ok {
+		return data, nil
+	}
+
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"codec":           h.compressor.Codec(),
+		"size":            len(data),
+		"compressed_size": len(compressed),
+	}).Debug("Compressed object before encryption")
+
+	// The stored size lets HEAD and GET report the size the client sent
+	return compressed, map[string]string{
+		h.metadataPrefix + compression.MetadataKey:  h.compressor.Codec(),
+		h.metadataPrefix + plaintextSizeMetadataKey: strconv.Itoa(len(data)),
+	}
+}
+
+// compressesStream reports whether a PUT body of size bytes, or of unknown
+// size if negative, is compressed while it is streamed to the backend
+func (h *Handler) compressesStream(r *http.Request, size int64) bool {
+	return h.compressor != nil && r.Header.Get("Content-Encoding") == "" &&
+		!h.encryptionMgr.IsNoneProvider() && h.compressor.Compresses(size)
+}
+
+// compressedStreamMetadata records the codec of an object compressed while
+// it was streamed, and its size as sent
+func (h *Handler) compressedStreamMetadata(metadata map[string]string, size int64) {
+	metadata[h.metadataPrefix+compression.MetadataKey] = h.compressor.Codec()
+	metadata[h.metadataPrefix+plaintextSizeMetadataKey] = strconv.FormatInt(size, 10)
+}
+
+// decompressBody undoes the compression of a decrypted object body. It
+// returns body unchanged for objects stored uncompressed, and closes it on
+// error.
+func (h *Handler) decompressBody(body io.ReadCloser, metadata map[string]string) (io.ReadCloser, error) {
+	codec, ok := metadata[h.metadataPrefix+compression.MetadataKey]
+	if !ok {
+		return body, nil
+	}
+	reader, err := compression.NewReader(codec, body)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	return reader, nil
+}
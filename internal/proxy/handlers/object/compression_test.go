@@ -0,0 +1,160 @@
+package object
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/compression"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+func newCompressionTestHandler(t *testing.T) (*Handler, *MockS3Backend) {
+	t.Helper()
+	prefix := "s3ep-"
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes",
+			MetadataKeyPrefix:     &prefix,
+			IntegrityVerification: config.HMACVerificationStrict,
+			Providers: []config.EncryptionProvider{{
+				Alias:  "test-aes",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+		Compression:   config.CompressionConfig{Enabled: true, Codec: "gzip", MinSize: 1024},
+		Optimizations: config.OptimizationsConfig{StreamingThreshold: 5 * 1024 * 1024},
+	}
+	encMgr, err := orchestration.NewManager(cfg)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	backend := new(MockS3Backend)
+	return NewHandler(backend, encMgr, cfg, logger.WithField("component", "object-handler")), backend
+}
+
+// putAndCapture PUTs body and returns what was stored on the backend
+func putAndCapture(t *testing.T, handler *Handler, backend *MockS3Backend, body []byte, header http.Header) ([]byte, map[string]string) {
+	t.Helper()
+	var stored []byte
+	var metadata map[string]string
+	call := backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		stored, _ = io.ReadAll(input.Body)
+		assert.Equal(t, int64(len(stored)), aws.ToInt64(input.ContentLength))
+		metadata = input.Metadata
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil).Once()
+	defer call.Unset()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/app.log", bytes.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "app.log")
+	require.Equal(t, http.StatusOK, rr.Code)
+	return stored, metadata
+}
+
+func TestCompression_PutAndGet(t *testing.T) {
+	handler, backend := newCompressionTestHandler(t)
+	logs := bytes.Repeat([]byte("2026-10-16T12:00:00Z INFO GET /health 200\n"), 2000)
+
+	stored, metadata := putAndCapture(t, handler, backend, logs, nil)
+	assert.Equal(t, "gzip", metadata["s3ep-compression"])
+	assert.Equal(t, strconv.Itoa(len(logs)), metadata["s3ep-plaintext-size"])
+	assert.Less(t, len(stored), len(logs)/10)
+
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(stored)),
+		ContentLength: aws.Int64(int64(len(stored))),
+		Metadata:      metadata,
+	}, nil).Once()
+	rr := httptest.NewRecorder()
+	handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/app.log", nil), "bucket", "app.log")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, strconv.Itoa(len(logs)), rr.Header().Get("Content-Length"))
+	assert.Empty(t, rr.Header().Get("x-amz-meta-s3ep-compression"))
+	assert.True(t, bytes.Equal(logs, rr.Body.Bytes()))
+}
+
+func TestCompression_StoresUncompressed(t *testing.T) {
+	handler, backend := newCompressionTestHandler(t)
+	logs := bytes.Repeat([]byte("line\n"), 1000)
+
+	// Bodies the client encoded itself are stored as sent
+	stored, metadata := putAndCapture(t, handler, backend, logs, http.Header{"Content-Encoding": {"gzip"}})
+	assert.NotContains(t, metadata, "s3ep-compression")
+	assert.Len(t, stored, len(logs)+28)
+
+	// Objects below the minimum size as well
+	_, metadata = putAndCapture(t, handler, backend, logs[:1000], nil)
+	assert.NotContains(t, metadata, "s3ep-compression")
+	assert.Equal(t, "1000", metadata["s3ep-plaintext-size"])
+}
+
+func TestCompression_StreamedPutIsCompressedInParts(t *testing.T) {
+	for _, codec := range []string{compression.CodecGzip, compression.CodecZstd} {
+		t.Run(codec, func(t *testing.T) {
+			handler, backend := newCompressionTestHandler(t)
+			handler.config.Optimizations.StreamingThreshold = 64 * 1024
+			handler.config.Optimizations.MultipartUploadConcurrency = 1
+			compressor, err := compression.New(codec, 0, 1024)
+			require.NoError(t, err)
+			handler.compressor = compressor
+
+			logs := bytes.Repeat([]byte("2026-10-16T12:00:00Z INFO GET /health 200\n"), 50000)
+
+			backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).
+				Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil).Once()
+			var stored []byte
+			backend.On("UploadPart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				body, err := io.ReadAll(args.Get(1).(*s3.UploadPartInput).Body)
+				require.NoError(t, err)
+				stored = append(stored, body...)
+			}).Return(&s3.UploadPartOutput{ETag: aws.String(`"part-etag"`)}, nil)
+			backend.On("CompleteMultipartUpload", mock.Anything, mock.Anything).
+				Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"final-etag"`)}, nil).Once()
+			head := &s3.HeadObjectOutput{}
+			backend.On("CopyObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				head.Metadata = args.Get(1).(*s3.CopyObjectInput).Metadata
+			}).Return(&s3.CopyObjectOutput{}, nil).Maybe()
+			backend.On("HeadObject", mock.Anything, mock.Anything).Return(head, nil).Maybe()
+
+			req := httptest.NewRequest(http.MethodPut, "/bucket/app.log", bytes.NewReader(logs))
+			rr := httptest.NewRecorder()
+			handler.handlePutObject(rr, req, "bucket", "app.log")
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			backend.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+
+			metadata := head.Metadata
+			assert.Equal(t, codec, metadata["s3ep-compression"])
+			assert.Equal(t, strconv.Itoa(len(logs)), metadata["s3ep-plaintext-size"])
+			assert.Less(t, len(stored), len(logs)/10)
+
+			backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(stored)),
+				ContentLength: aws.Int64(int64(len(stored))),
+				Metadata:      metadata,
+			}, nil).Once()
+			rr = httptest.NewRecorder()
+			handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/app.log", nil), "bucket", "app.log")
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, strconv.Itoa(len(logs)), rr.Header().Get("Content-Length"))
+			assert.True(t, bytes.Equal(logs, rr.Body.Bytes()))
+		})
+	}
+}
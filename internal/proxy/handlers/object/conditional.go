@@ -0,0 +1,82 @@
+package object
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// translateConditions rewrites If-Match and If-None-Match of r, written
+// against the ETags the proxy reports, into conditions on backend ETags. In
+// plaintext ETag mode the object is HEADed: a failed condition is answered
+// here, with 304 for If-None-Match on a read and 412 otherwise, and a
+// matching If-Match becomes an If-Match on the backend ETag, so the backend
+// still refuses an object replaced in the meantime. If-None-Match has no such
+// counterpart and is dropped once it held. It returns false if the response
+// was written.
+func (h *Handler) translateConditions(w http.ResponseWriter, r *http.Request, bucket, key string, write bool) bool {
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if h.config.Encryption.ETagMode != config.ETagModePlaintext || (ifMatch == "" && ifNoneMatch == "") {
+		return true
+	}
+	// "*" only tests for existence, which the backend checks itself
+	if (ifMatch == "" || ifMatch == "*") && (ifNoneMatch == "" || ifNoneMatch == "*") {
+		return true
+	}
+
+	input := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), VersionId: versionIDParam(r)}
+	applySSEC(r, &input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+	head, err := h.s3Backend.HeadObject(r.Context(), input)
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			// The backend answers conditions on a missing object
+			return true
+		}
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return false
+	}
+
+	etag := aws.ToString(h.responseETag(head.Metadata, head.ETag))
+	if ifMatch != "" {
+		if !etagListMatches(ifMatch, etag) {
+			h.writePreconditionFailed(w, r)
+			return false
+		}
+		r.Header.Set("If-Match", aws.ToString(head.ETag))
+	}
+	if ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag) {
+			if write {
+				h.writePreconditionFailed(w, r)
+			} else {
+				w.Header().Set("ETag", etag)
+				w.WriteHeader(http.StatusNotModified)
+			}
+			return false
+		}
+		r.Header.Del("If-None-Match")
+	}
+	return true
+}
+
+func (h *Handler) writePreconditionFailed(w http.ResponseWriter, r *http.Request) {
+	h.errorWriter.WriteError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+}
+
+// applyConditions forwards If-Match and If-None-Match of r to a backend
+// request
+func applyConditions(r *http.Request, ifMatch, ifNoneMatch **string) {
+	if value := r.Header.Get("If-Match"); value != "" {
+		*ifMatch = aws.String(value)
+	}
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		*ifNoneMatch = aws.String(value)
+	}
+}
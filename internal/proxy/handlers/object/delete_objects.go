@@ -0,0 +1,250 @@
+package object
+
+import (
+	"bytes"
+	"crypto/md5" // #nosec G501 - Content-MD5 is part of the S3 API
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
+)
+
+const (
+	// maxDeleteObjectsKeys is the largest batch S3 accepts in one request
+	maxDeleteObjectsKeys = 1000
+
+	// maxDeleteObjectsBody bounds the request body: 1000 keys of at most
+	// 1024 bytes each plus the XML around them
+	maxDeleteObjectsBody = 2 << 20
+
+	// maxDeleteObjectsElements bounds the elements of the body: Quiet and an
+	// Object with Key, VersionId and a few conditions per key
+	maxDeleteObjectsElements = maxDeleteObjectsKeys*6 + 2
+
+	// deleteFanOutConcurrency bounds the parallel DeleteObject calls made
+	// for backends without DeleteObjects
+	deleteFanOutConcurrency = 16
+)
+
+// deleteObjectsRequest is the body of a DeleteObjects request
+type deleteObjectsRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key       string `xml:"Key"`
+		VersionID string `xml:"VersionId"`
+	} `xml:"Object"`
+}
+
+// deleteResult is the DeleteObjects response body
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key                   string `xml:"Key"`
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
+
+// handleDeleteObjects handles multi-object delete (POST ?delete). Objects are
+// deleted with one backend DeleteObjects call; backends that do not implement
+// it get one DeleteObject call per key. Per-key failures are reported in the
+// DeleteResult, successes only without Quiet.
+func (h *Handler) handleDeleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"operation": "delete-objects",
+		"bucket":    bucket,
+	}).Debug("Handling delete objects")
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDeleteObjectsBody+1))
+	if err != nil {
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidRequest", "Failed to read request body")
+		return
+	}
+	if len(body) > maxDeleteObjectsBody {
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		return
+	}
+
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		expected, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(expected) != md5.Size {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified was invalid")
+			return
+		}
+		if actual := md5.Sum(body); !bytes.Equal(actual[:], expected) { // #nosec G401 - Content-MD5 is part of the S3 API
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received")
+			return
+		}
+	}
+
+	var deleteRequest deleteObjectsRequest
+	if err := request.DecodeXML(bytes.NewReader(body), "Delete", &deleteRequest, request.XMLLimits{MaxElements: maxDeleteObjectsElements}); err != nil {
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+			"operation": "delete-objects",
+			"bucket":    bucket,
+			"error":     err.Error(),
+			"bodySize":  len(body),
+		}).Warn("Failed to parse delete objects XML request")
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		return
+	}
+	if len(deleteRequest.Objects) == 0 || len(deleteRequest.Objects) > maxDeleteObjectsKeys {
+		h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML",
+			fmt.Sprintf("A delete request must contain between 1 and %d objects", maxDeleteObjectsKeys))
+		return
+	}
+
+	objects := make([]types.ObjectIdentifier, len(deleteRequest.Objects))
+	for i, object := range deleteRequest.Objects {
+		if object.Key == "" {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "MalformedXML", "Every object of a delete request needs a key")
+			return
+		}
+		objects[i] = types.ObjectIdentifier{Key: aws.String(object.Key)}
+		if object.VersionID != "" {
+			objects[i].VersionId = aws.String(object.VersionID)
+		}
+	}
+
+	output, err := h.s3Backend.DeleteObjects(r.Context(), &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(deleteRequest.Quiet),
+		},
+	})
+	if utils.IsBackendUnsupported(err) {
+		h.logger.WithContext(r.Context()).WithField("bucket", bucket).Debug("Backend does not implement DeleteObjects, deleting objects one by one")
+		output, err = h.deleteObjectsOneByOne(r, bucket, objects), nil
+	}
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, "")
+		return
+	}
+
+	result := deleteResult{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	// Not every backend honors Quiet, so successes are dropped here as well
+	if !deleteRequest.Quiet {
+		for _, deleted := range output.Deleted {
+			result.Deleted = append(result.Deleted, deletedObject{
+				Key:                   aws.ToString(deleted.Key),
+				VersionID:             aws.ToString(deleted.VersionId),
+				DeleteMarker:          aws.ToBool(deleted.DeleteMarker),
+				DeleteMarkerVersionID: aws.ToString(deleted.DeleteMarkerVersionId),
+			})
+		}
+	}
+	for _, failed := range output.Errors {
+		result.Errors = append(result.Errors, deleteError{
+			Key:       aws.ToString(failed.Key),
+			VersionID: aws.ToString(failed.VersionId),
+			Code:      aws.ToString(failed.Code),
+			Message:   aws.ToString(failed.Message),
+		})
+	}
+
+	xmlData, err := xml.Marshal(result)
+	if err != nil {
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "InternalError", "Failed to generate response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write XML header")
+		return
+	}
+	if _, err := w.Write(xmlData); err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to write XML data")
+		return
+	}
+
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"operation": "delete-objects",
+		"bucket":    bucket,
+		"requested": len(objects),
+		"deleted":   len(output.Deleted),
+		"errors":    len(output.Errors),
+	}).Debug("Delete objects completed")
+}
+
+// deleteObjectsOneByOne deletes objects with parallel DeleteObject calls and
+// collects the outcome in request order like DeleteObjects would
+func (h *Handler) deleteObjectsOneByOne(r *http.Request, bucket string, objects []types.ObjectIdentifier) *s3.DeleteObjectsOutput {
+	deleted := make([]*types.DeletedObject, len(objects))
+	failed := make([]*types.Error, len(objects))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, deleteFanOutConcurrency)
+	for i, object := range objects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			output, err := h.s3Backend.DeleteObject(r.Context(), &s3.DeleteObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       object.Key,
+				VersionId: object.VersionId,
+			})
+			var noSuchKey *types.NoSuchKey
+			switch {
+			case errors.As(err, &noSuchKey):
+				// Deleting a missing key succeeds in S3
+				deleted[i] = &types.DeletedObject{Key: object.Key, VersionId: object.VersionId}
+			case err != nil:
+				code, message := "InternalError", err.Error()
+				var apiErr smithy.APIError
+				if errors.As(err, &apiErr) {
+					code, message = apiErr.ErrorCode(), apiErr.ErrorMessage()
+				}
+				failed[i] = &types.Error{Key: object.Key, VersionId: object.VersionId, Code: aws.String(code), Message: aws.String(message)}
+			default:
+				deleted[i] = &types.DeletedObject{
+					Key:                   object.Key,
+					VersionId:             object.VersionId,
+					DeleteMarker:          output.DeleteMarker,
+					DeleteMarkerVersionId: output.VersionId,
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	output := &s3.DeleteObjectsOutput{}
+	for i := range objects {
+		if deleted[i] != nil {
+			output.Deleted = append(output.Deleted, *deleted[i])
+		}
+		if failed[i] != nil {
+			output.Errors = append(output.Errors, *failed[i])
+		}
+	}
+	return output
+}
@@ -0,0 +1,139 @@
+package object
+
+import (
+	"crypto/md5" // #nosec G501 - Content-MD5 is part of the S3 API
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+func newDeleteObjectsHandler(backend *MockS3Backend) *Handler {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return &Handler{
+		s3Backend:   backend,
+		logger:      logger.WithField("component", "object-handler"),
+		errorWriter: response.NewErrorWriter(logger.WithField("component", "error-writer")),
+	}
+}
+
+func deleteObjectsBody(quiet bool, keys ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<Delete><Quiet>%t</Quiet>", quiet)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "<Object><Key>%s</Key></Object>", key)
+	}
+	b.WriteString("</Delete>")
+	return b.String()
+}
+
+func TestHandleDeleteObjects(t *testing.T) {
+	backend := new(MockS3Backend)
+	backend.On("DeleteObjects", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectsInput) bool {
+		return aws.ToString(input.Bucket) == "bucket" && len(input.Delete.Objects) == 2 && !aws.ToBool(input.Delete.Quiet)
+	})).Return(&s3.DeleteObjectsOutput{
+		Deleted: []types.DeletedObject{{Key: aws.String("a")}},
+		Errors:  []types.Error{{Key: aws.String("b"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")}},
+	}, nil)
+
+	body := deleteObjectsBody(false, "a", "b")
+	sum := md5.Sum([]byte(body)) // #nosec G401 - Content-MD5 is part of the S3 API
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(body))
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	rr := httptest.NewRecorder()
+	newDeleteObjectsHandler(backend).handleDeleteObjects(rr, req, "bucket")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Deleted><Key>a</Key></Deleted>")
+	assert.Contains(t, rr.Body.String(), "<Error><Key>b</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>")
+	backend.AssertExpectations(t)
+}
+
+func TestHandleDeleteObjects_QuietOmitsDeleted(t *testing.T) {
+	backend := new(MockS3Backend)
+	// The backend ignores Quiet and reports the deleted key anyway
+	backend.On("DeleteObjects", mock.Anything, mock.Anything).Return(&s3.DeleteObjectsOutput{
+		Deleted: []types.DeletedObject{{Key: aws.String("a")}},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(deleteObjectsBody(true, "a")))
+	rr := httptest.NewRecorder()
+	newDeleteObjectsHandler(backend).handleDeleteObjects(rr, req, "bucket")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "<Deleted>")
+}
+
+func TestHandleDeleteObjects_FansOutWithoutBatchDelete(t *testing.T) {
+	backend := new(MockS3Backend)
+	backend.On("DeleteObjects", mock.Anything, mock.Anything).Return(nil, &smithy.GenericAPIError{Code: "NotImplemented"})
+	backend.On("DeleteObject", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return aws.ToString(input.Key) == "a"
+	})).Return(&s3.DeleteObjectOutput{}, nil)
+	backend.On("DeleteObject", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return aws.ToString(input.Key) == "missing"
+	})).Return(nil, &types.NoSuchKey{})
+	backend.On("DeleteObject", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return aws.ToString(input.Key) == "locked"
+	})).Return(nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "Object is locked"})
+
+	req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(deleteObjectsBody(false, "a", "missing", "locked")))
+	rr := httptest.NewRecorder()
+	newDeleteObjectsHandler(backend).handleDeleteObjects(rr, req, "bucket")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Deleted><Key>a</Key></Deleted><Deleted><Key>missing</Key></Deleted>")
+	assert.Contains(t, rr.Body.String(), "<Error><Key>locked</Key><Code>AccessDenied</Code><Message>Object is locked</Message></Error>")
+	backend.AssertNumberOfCalls(t, "DeleteObject", 3)
+}
+
+func TestHandleDeleteObjects_InvalidRequests(t *testing.T) {
+	tooMany := make([]string, maxDeleteObjectsKeys+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		contentMD5 string
+		code       string
+	}{
+		{"malformed XML", "<Delete><Object>", "", "MalformedXML"},
+		{"no objects", "<Delete></Delete>", "", "MalformedXML"},
+		{"DOCTYPE", `<!DOCTYPE d [<!ENTITY k "a">]><Delete><Object><Key>&k;</Key></Object></Delete>`, "", "MalformedXML"},
+		{"wrong root", "<Tagging><Object><Key>a</Key></Object></Tagging>", "", "MalformedXML"},
+		{"too many objects", deleteObjectsBody(false, tooMany...), "", "MalformedXML"},
+		{"empty key", deleteObjectsBody(false, ""), "", "MalformedXML"},
+		{"invalid Content-MD5", deleteObjectsBody(false, "a"), "not-base64", "InvalidDigest"},
+		{"mismatching Content-MD5", deleteObjectsBody(false, "a"), base64.StdEncoding.EncodeToString(make([]byte, md5.Size)), "BadDigest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := new(MockS3Backend)
+			req := httptest.NewRequest(http.MethodPost, "/bucket?delete", strings.NewReader(tt.body))
+			if tt.contentMD5 != "" {
+				req.Header.Set("Content-MD5", tt.contentMD5)
+			}
+			rr := httptest.NewRecorder()
+			newDeleteObjectsHandler(backend).handleDeleteObjects(rr, req, "bucket")
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), "<Code>"+tt.code+"</Code>")
+			backend.AssertNotCalled(t, "DeleteObjects", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func objectRequest(method, body, encryptionContext string) *http.Request {
+	req := httptest.NewRequest(method, "/bucket/tenant.bin", bytes.NewReader([]byte(body)))
+	if encryptionContext != "" {
+		req.Header.Set(orchestration.EncryptionContextHeader, encryptionContext)
+	}
+	return mux.SetURLVars(req, map[string]string{"bucket": "bucket", "key": "tenant.bin"})
+}
+
+func TestPutObject_StoresEncryptionContext(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	stored := make(chan *s3.PutObjectInput, 1)
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored <- args.Get(1).(*s3.PutObjectInput)
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.handleBaseObjectOperations(rr, objectRequest(http.MethodPut, "tenant data", "tenant=acme"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "tenant=acme", (<-stored).Metadata["s3ep-encryption-context"])
+
+	rr = httptest.NewRecorder()
+	handler.handleBaseObjectOperations(rr, objectRequest(http.MethodPut, "tenant data", "tenant"))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "InvalidArgument")
+}
+
+func TestGetObject_RejectsMismatchedEncryptionContext(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, false)
+	ctx := orchestration.WithEncryptionContext(context.Background(), orchestration.EncryptionContext{"tenant": "acme"})
+	result, err := encMgr.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader([]byte("tenant data"))), "tenant.bin", factory.ContentTypeWhole)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+
+	for range 2 {
+		backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+			Body:          io.NopCloser(bytes.NewReader(ciphertext)),
+			ContentLength: aws.Int64(int64(len(ciphertext))),
+			Metadata:      result.Metadata,
+		}, nil).Once()
+	}
+
+	rr := httptest.NewRecorder()
+	handler.handleBaseObjectOperations(rr, objectRequest(http.MethodGet, "", "tenant=other"))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "AccessDenied")
+
+	rr = httptest.NewRecorder()
+	handler.handleBaseObjectOperations(rr, objectRequest(http.MethodGet, "", "tenant=acme"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "tenant data", rr.Body.String())
+}
@@ -0,0 +1,55 @@
+package object
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// plaintextETagMetadataKey (with the metadata prefix) stores the hex MD5 of
+// the plaintext, the ETag S3 would report for the unencrypted object
+const plaintextETagMetadataKey = "plaintext-etag"
+
+// plaintextETags reports whether new encrypted objects record their plaintext
+// MD5. Objects of the none provider already have it as their backend ETag.
+func (h *Handler) plaintextETags() bool {
+	return h.config.Encryption.ETagMode == config.ETagModePlaintext && !h.encryptionMgr.IsNoneProvider()
+}
+
+// recordPlaintextETag stores the plaintext MD5 sum in the metadata of a new
+// object
+func (h *Handler) recordPlaintextETag(metadata map[string]string, sum []byte) {
+	metadata[h.metadataPrefix+plaintextETagMetadataKey] = hex.EncodeToString(sum)
+}
+
+// responseETag returns the ETag reported for an object: in plaintext mode its
+// recorded plaintext ETag, else the backend ETag
+func (h *Handler) responseETag(metadata map[string]string, backendETag *string) *string {
+	if h.config.Encryption.ETagMode != config.ETagModePlaintext {
+		return backendETag
+	}
+	if etag, ok := metadata[h.metadataPrefix+plaintextETagMetadataKey]; ok && etag != "" {
+		return aws.String(`"` + etag + `"`)
+	}
+	return backendETag
+}
+
+// etagListMatches reports whether a comma-separated list of ETags, or "*",
+// contains etag. Quotes and weak prefixes are ignored.
+func etagListMatches(list, etag string) bool {
+	etag = normalizeETag(etag)
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || (candidate != "" && normalizeETag(candidate) == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
@@ -0,0 +1,194 @@
+package object
+
+import (
+	"bytes"
+	"crypto/md5" // #nosec G501 - the S3 ETag is an MD5
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data) // #nosec G401 - the S3 ETag is an MD5
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPlaintextETag_PutGetHead(t *testing.T) {
+	for _, streamingThreshold := range []int64{5 * 1024 * 1024, 1024} {
+		handler, backend, _ := newBackfillTestHandler(t, false)
+		handler.config.Encryption.ETagMode = config.ETagModePlaintext
+		handler.config.Optimizations.StreamingThreshold = streamingThreshold
+		plaintext := bytes.Repeat([]byte("plaintext etag "), 1000)
+		etag := `"` + md5Hex(plaintext) + `"`
+
+		var stored []byte
+		var metadata map[string]string
+		backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			input := args.Get(1).(*s3.PutObjectInput)
+			stored, _ = io.ReadAll(input.Body)
+			metadata = input.Metadata
+		}).Return(&s3.PutObjectOutput{ETag: aws.String(`"ciphertext-etag"`)}, nil).Once()
+
+		rr := httptest.NewRecorder()
+		handler.handlePutObject(rr, httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(plaintext)), "bucket", "key")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+		assert.Equal(t, md5Hex(plaintext), metadata["s3ep-plaintext-etag"])
+
+		// Conditions are checked against a HEAD of the object; a matching
+		// If-Match is forwarded as the backend ETag
+		get := func(header, value string, forwarded *string) *httptest.ResponseRecorder {
+			backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+				ETag:     aws.String(`"ciphertext-etag"`),
+				Metadata: metadata,
+			}, nil).Once()
+			if forwarded != nil {
+				backend.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+					return aws.ToString(input.IfMatch) == aws.ToString(forwarded) && input.IfNoneMatch == nil
+				})).Return(&s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewReader(stored)),
+					ContentLength: aws.Int64(int64(len(stored))),
+					ETag:          aws.String(`"ciphertext-etag"`),
+					Metadata:      metadata,
+				}, nil).Once()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+			req.Header.Set(header, value)
+			rr := httptest.NewRecorder()
+			handler.handleGetObject(rr, req, "bucket", "key")
+			return rr
+		}
+
+		rr = get("If-Match", etag, aws.String(`"ciphertext-etag"`))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+		assert.Equal(t, plaintext, rr.Body.Bytes())
+		rr = get("If-None-Match", `"other"`, aws.String(""))
+		require.Equal(t, http.StatusOK, rr.Code)
+		rr = get("If-None-Match", etag, nil)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+		assert.Equal(t, http.StatusPreconditionFailed, get("If-Match", `"ciphertext-etag"`, nil).Code)
+
+		backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+			ETag:     aws.String(`"ciphertext-etag"`),
+			Metadata: metadata,
+		}, nil).Once()
+		rr = httptest.NewRecorder()
+		handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+	}
+}
+
+func TestPlaintextETag_ConditionalPut(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Encryption.ETagMode = config.ETagModePlaintext
+	handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+	current := []byte("current")
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ETag:     aws.String(`"ciphertext-etag"`),
+		Metadata: map[string]string{"s3ep-plaintext-etag": md5Hex(current)},
+	}, nil)
+
+	put := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte("next")))
+		req.Header.Set(header, value)
+		rr := httptest.NewRecorder()
+		handler.handlePutObject(rr, req, "bucket", "key")
+		return rr
+	}
+
+	// A stale plaintext ETag and an existing object fail before the upload
+	assert.Equal(t, http.StatusPreconditionFailed, put("If-Match", `"`+md5Hex([]byte("stale"))+`"`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, put("If-None-Match", `"`+md5Hex(current)+`"`).Code)
+	backend.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+
+	// A current one becomes a condition on the backend ETag
+	backend.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.IfMatch) == `"ciphertext-etag"`
+	})).Return(&s3.PutObjectOutput{ETag: aws.String(`"next-etag"`)}, nil).Once()
+	assert.Equal(t, http.StatusOK, put("If-Match", `"`+md5Hex(current)+`"`).Code)
+
+	// "*" is left to the backend
+	backend.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.IfNoneMatch) == "*"
+	})).Return(nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}).Once()
+	assert.Equal(t, http.StatusPreconditionFailed, put("If-None-Match", "*").Code)
+	backend.AssertExpectations(t)
+}
+
+func TestPlaintextETag_BackendMode(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+
+	var metadata map[string]string
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		metadata = args.Get(1).(*s3.PutObjectInput).Metadata
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"ciphertext-etag"`)}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte("data"))), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"ciphertext-etag"`, rr.Header().Get("ETag"))
+	assert.NotContains(t, metadata, "s3ep-plaintext-etag")
+
+	// A recorded plaintext ETag is ignored
+	metadata["s3ep-plaintext-etag"] = md5Hex([]byte("data"))
+	assert.Equal(t, `"ciphertext-etag"`, aws.ToString(handler.responseETag(metadata, aws.String(`"ciphertext-etag"`))))
+}
+
+func TestPlaintextETag_AutoMultipart(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Encryption.ETagMode = config.ETagModePlaintext
+	handler.config.Optimizations.StreamingThreshold = 1024
+	handler.config.Optimizations.StreamingSegmentSize = 5 * 1024 * 1024
+
+	// Above the S3 minimum part size, so the MD5 is attached when the upload completes
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), (6*1024*1024)/16)
+
+	backend.On("CreateMultipartUpload", mock.Anything, mock.Anything).
+		Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil).Once()
+	backend.On("UploadPart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = io.Copy(io.Discard, args.Get(1).(*s3.UploadPartInput).Body)
+	}).Return(&s3.UploadPartOutput{ETag: aws.String(`"part-etag"`)}, nil)
+	backend.On("CompleteMultipartUpload", mock.Anything, mock.Anything).
+		Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"final-etag-2"`)}, nil).Once()
+	var mu sync.Mutex
+	head := &s3.HeadObjectOutput{}
+	backend.On("CopyObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		head.Metadata = args.Get(1).(*s3.CopyObjectInput).Metadata
+	}).Return(&s3.CopyObjectOutput{}, nil).Maybe()
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(head, nil).Maybe()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/large.bin", bytes.NewReader(plaintext))
+	req.ContentLength = int64(len(plaintext))
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "large.bin")
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `"`+md5Hex(plaintext)+`"`, rr.Header().Get("ETag"))
+	backend.AssertCalled(t, "CreateMultipartUpload", mock.Anything, mock.Anything)
+}
+
+func TestETagListMatches(t *testing.T) {
+	assert.True(t, etagListMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagListMatches(`abc`, `"abc"`))
+	assert.True(t, etagListMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagListMatches(`*`, `"abc"`))
+	assert.False(t, etagListMatches(`"abcd"`, `"abc"`))
+	assert.False(t, etagListMatches(` , `, `"abc"`))
+}
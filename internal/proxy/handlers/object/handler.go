@@ -2,8 +2,10 @@ package object
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/compression"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
@@ -23,6 +25,15 @@ type Handler struct {
 	metadataPrefix string
 	config         *config.Config
 
+	// In-flight plaintext size backfills, keyed by bucket/key
+	sizeBackfills sync.Map
+
+	// Compresses objects before encryption; nil if compression is off
+	compressor *compression.Compressor
+
+	// Buffers for streaming GET bodies to clients
+	responseBuffers *sync.Pool
+
 	// Sub-handlers
 	aclHandler      *ACLHandler
 	taggingHandler  *TaggingHandler
@@ -46,19 +57,29 @@ func NewHandler(
 	requestParser := request.NewParser(logger, config)
 
 	h := &Handler{
-		s3Backend:      s3Backend,
-		encryptionMgr:  encryptionMgr,
-		logger:         logger,
-		xmlWriter:      xmlWriter,
-		errorWriter:    errorWriter,
-		requestParser:  requestParser,
-		metadataPrefix: metadataPrefix,
-		config:         config,
+		s3Backend:       s3Backend,
+		encryptionMgr:   encryptionMgr,
+		logger:          logger,
+		xmlWriter:       xmlWriter,
+		errorWriter:     errorWriter,
+		requestParser:   requestParser,
+		metadataPrefix:  metadataPrefix,
+		config:          config,
+		responseBuffers: newResponseBufferPool(config.GetResponseBufferSize()),
+	}
+
+	if config.Compression.Enabled {
+		compressor, err := compression.New(config.Compression.Codec, config.Compression.Level, config.Compression.MinSize)
+		if err != nil {
+			logger.WithError(err).Error("Invalid compression configuration, objects are stored uncompressed")
+		} else {
+			h.compressor = compressor
+		}
 	}
 
 	// Initialize sub-handlers
 	h.aclHandler = NewACLHandler(s3Backend, logger, xmlWriter, errorWriter, requestParser)
-	h.taggingHandler = NewTaggingHandler(s3Backend, logger, xmlWriter, errorWriter, requestParser)
+	h.taggingHandler = NewTaggingHandler(s3Backend, logger, xmlWriter, errorWriter, requestParser, metadataPrefix)
 	h.metadataHandler = NewMetadataHandler(s3Backend, logger, xmlWriter, errorWriter, requestParser)
 
 	return h
@@ -89,13 +110,39 @@ func (h *Handler) handleBaseObjectOperations(w http.ResponseWriter, r *http.Requ
 	bucket := vars["bucket"]
 	key := vars["key"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 		"key":    key,
 		"path":   r.URL.Path,
 	}).Debug("Handling base object operation")
 
+	// Per-bucket encryption settings and the tenant are selected from the
+	// request context
+	ctx := orchestration.WithBucket(r.Context(), bucket)
+	r = r.WithContext(orchestration.WithClient(ctx, request.AccessKeyID(r)))
+
+	// Bind the client encryption context to the request for the encryption manager
+	if header := r.Header.Get(orchestration.EncryptionContextHeader); header != "" {
+		encryptionContext, err := orchestration.ParseEncryptionContext(header)
+		if err != nil {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+		r = r.WithContext(orchestration.WithEncryptionContext(r.Context(), encryptionContext))
+	}
+
+	// The provider is selected when an object is written; reads use the one
+	// recorded in its metadata
+	if header := r.Header.Get(orchestration.EncryptionProviderHeader); header != "" && r.Method == http.MethodPut {
+		ctx, err := h.encryptionMgr.SelectEncryptionProvider(r.Context(), header)
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		r = r.WithContext(ctx)
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.handleGetObject(w, r, bucket, key)
@@ -127,7 +174,7 @@ func (h *Handler) GetMetadataHandler() *MetadataHandler {
 
 // ===== PASSTHROUGH OPERATION HANDLERS =====
 
-// HandleDeleteObjects handles bulk object deletion (passthrough)
+// HandleDeleteObjects handles multi-object delete (POST ?delete)
 func (h *Handler) HandleDeleteObjects(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
@@ -162,7 +209,7 @@ func (h *Handler) HandleObjectTorrent(w http.ResponseWriter, r *http.Request) {
 	h.handleObjectTorrent(w, r, bucket, key)
 }
 
-// HandleSelectObjectContent handles S3 Select operations (passthrough)
+// HandleSelectObjectContent handles S3 Select operations (?select&select-type=2)
 func (h *Handler) HandleSelectObjectContent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
@@ -11,24 +11,31 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tuning"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 )
 
-const getResponseBufferSize = 128 * 1024
-
-var getResponseBufferPool = sync.Pool{
-	New: func() any {
-		b := make([]byte, getResponseBufferSize)
-		return &b
-	},
+// newResponseBufferPool returns a pool of buffers of size bytes for streaming
+// GET bodies, avoiding io.Copy's per-call 32 KiB allocation
+func newResponseBufferPool(size int) *sync.Pool {
+	return &sync.Pool{
+		New: func() any {
+			b := make([]byte, size)
+			return &b
+		},
+	}
 }
 
-// copyWithPooledBuffer streams src into dst using a pooled 128 KiB buffer,
-// avoiding io.Copy's per-call 32 KiB allocation on the GET response path.
-func copyWithPooledBuffer(dst io.Writer, src io.Reader) (int64, error) {
-	bufp := getResponseBufferPool.Get().(*[]byte)
-	defer getResponseBufferPool.Put(bufp)
-	return io.CopyBuffer(dst, src, *bufp)
+// copyResponse streams src into dst using a pooled buffer of
+// optimizations.network.response_buffer_size bytes
+func (h *Handler) copyResponse(dst io.Writer, src io.Reader) (int64, error) {
+	bufp := h.responseBuffers.Get().(*[]byte)
+	defer h.responseBuffers.Put(bufp)
+	n, err := io.CopyBuffer(dst, src, *bufp)
+	tuning.RecordResponse(n)
+	return n, err
 }
 
 // extractEncryptionMetadata extracts encryption metadata from S3 object metadata
@@ -45,11 +52,27 @@ func (h *Handler) extractEncryptionMetadata(metadata map[string]string) (string,
 
 	// Check if this is streaming encryption by looking for streaming-specific metadata
 	dekAlgorithm := metadata[h.metadataPrefix+"dek-algorithm"]
-	isStreamingEncryption := dekAlgorithm == "aes-ctr" || dekAlgorithm == "AES-CTR"
+	isStreamingEncryption := encryption.IsStreamingAlgorithm(dekAlgorithm) || dekAlgorithm == "AES-CTR"
 
 	return encryptedDEKB64, true, isStreamingEncryption
 }
 
+// isProxyEncrypted reports whether the proxy encrypted the object: it has a
+// wrapped DEK and was not stored with SSE-C passthrough
+func (h *Handler) isProxyEncrypted(metadata map[string]string) bool {
+	_, encrypted := metadata[h.metadataPrefix+"encrypted-dek"]
+	return encrypted && !h.isSSECObject(metadata)
+}
+
+// setEncryptedObjectAcceptRanges announces that a proxy-encrypted object
+// cannot be read in ranges; range GETs of it are rejected with
+// RangeNotSupported
+func (h *Handler) setEncryptedObjectAcceptRanges(w http.ResponseWriter, metadata map[string]string) {
+	if h.isProxyEncrypted(metadata) {
+		w.Header().Set("Accept-Ranges", "none")
+	}
+}
+
 // decodeEncryptedDEK decodes the base64-encoded encrypted DEK
 func (h *Handler) decodeEncryptedDEK(encryptedDEKB64 string) ([]byte, error) {
 	encryptedDEK, err := base64.StdEncoding.DecodeString(encryptedDEKB64)
@@ -148,3 +171,12 @@ func (h *Handler) getMultipartUploadConcurrency() int {
 	}
 	return defaultConcurrency
 }
+
+// backendProfile returns the backend compatibility profile, defaulting to a plain
+// self-copy when no configuration is available (tests)
+func (h *Handler) backendProfile() config.BackendProfile {
+	if h.config == nil {
+		return config.BackendProfile{Name: "generic", MultipartMetadataPhase: config.MultipartMetadataPhaseCopy}
+	}
+	return h.config.GetBackendProfile()
+}
@@ -0,0 +1,194 @@
+package object
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// maxObjectLockBody bounds a PutObjectLegalHold or PutObjectRetention body
+const maxObjectLockBody = 4 << 10
+
+// legalHold is the body of GetObjectLegalHold and PutObjectLegalHold
+type legalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+}
+
+// retention is the body of GetObjectRetention and PutObjectRetention
+type retention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Xmlns           string   `xml:"xmlns,attr,omitempty"`
+	Mode            string   `xml:"Mode,omitempty"`
+	RetainUntilDate string   `xml:"RetainUntilDate,omitempty"`
+}
+
+// handleObjectLegalHold passes ?legal-hold through to the backend. Object
+// lock applies to the stored object as a whole, so it is the same for the
+// ciphertext as for the plaintext.
+func (h *Handler) handleObjectLegalHold(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		output, err := h.s3Backend.GetObjectLegalHold(r.Context(), &s3.GetObjectLegalHoldInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+		})
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+
+		result := legalHold{Xmlns: taggingXMLNS, Status: string(types.ObjectLockLegalHoldStatusOff)}
+		if output.LegalHold != nil && output.LegalHold.Status != "" {
+			result.Status = string(output.LegalHold.Status)
+		}
+		h.xmlWriter.WriteXML(w, result)
+
+	case http.MethodPut:
+		body, err := h.requestParser.ReadXMLBody(r, maxObjectLockBody)
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		var requested legalHold
+		if err := request.DecodeXML(bytes.NewReader(body), "LegalHold", &requested, request.XMLLimits{}); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		status := types.ObjectLockLegalHoldStatus(requested.Status)
+		if status != types.ObjectLockLegalHoldStatusOn && status != types.ObjectLockLegalHoldStatusOff {
+			h.errorWriter.WriteS3Error(w, request.ErrMalformedXML, bucket, key)
+			return
+		}
+
+		if _, err := h.s3Backend.PutObjectLegalHold(r.Context(), &s3.PutObjectLegalHoldInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+			LegalHold: &types.ObjectLockLegalHold{Status: status},
+		}); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		h.errorWriter.WriteError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed for legal hold")
+	}
+}
+
+// handleObjectRetention passes ?retention through to the backend
+func (h *Handler) handleObjectRetention(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		output, err := h.s3Backend.GetObjectRetention(r.Context(), &s3.GetObjectRetentionInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+		})
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+
+		result := retention{Xmlns: taggingXMLNS}
+		if output.Retention != nil {
+			result.Mode = string(output.Retention.Mode)
+			if output.Retention.RetainUntilDate != nil {
+				result.RetainUntilDate = output.Retention.RetainUntilDate.UTC().Format(time.RFC3339)
+			}
+		}
+		h.xmlWriter.WriteXML(w, result)
+
+	case http.MethodPut:
+		body, err := h.requestParser.ReadXMLBody(r, maxObjectLockBody)
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		var requested retention
+		if err := request.DecodeXML(bytes.NewReader(body), "Retention", &requested, request.XMLLimits{}); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		objectRetention, err := parseRetention(requested)
+		if err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+
+		input := &s3.PutObjectRetentionInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+			Retention: objectRetention,
+		}
+		if strings.EqualFold(r.Header.Get("x-amz-bypass-governance-retention"), "true") {
+			input.BypassGovernanceRetention = aws.Bool(true)
+		}
+		if _, err := h.s3Backend.PutObjectRetention(r.Context(), input); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		h.errorWriter.WriteError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed for retention")
+	}
+}
+
+// parseRetention validates a PutObjectRetention body. An empty one removes
+// a governance mode retention, with x-amz-bypass-governance-retention.
+func parseRetention(requested retention) (*types.ObjectLockRetention, error) {
+	result := &types.ObjectLockRetention{}
+	switch mode := types.ObjectLockRetentionMode(requested.Mode); mode {
+	case "", types.ObjectLockRetentionModeGovernance, types.ObjectLockRetentionModeCompliance:
+		result.Mode = mode
+	default:
+		return nil, fmt.Errorf("%w: unknown retention mode %q", request.ErrInvalidObjectLock, mode)
+	}
+	if requested.RetainUntilDate != "" {
+		until, err := time.Parse(time.RFC3339, requested.RetainUntilDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: RetainUntilDate is not an ISO 8601 date", request.ErrInvalidObjectLock)
+		}
+		result.RetainUntilDate = &until
+	}
+	if (result.Mode == "") != (result.RetainUntilDate == nil) {
+		return nil, fmt.Errorf("%w: Mode and RetainUntilDate must both be supplied", request.ErrInvalidObjectLock)
+	}
+	return result, nil
+}
+
+// applyObjectLock sets the object lock headers of r, validated by
+// handlePutObject, on a backend request
+func applyObjectLock(r *http.Request, mode *types.ObjectLockMode, retainUntilDate **time.Time, legalHold *types.ObjectLockLegalHoldStatus) {
+	if lock, err := request.ParseObjectLock(r); err == nil {
+		lock.Apply(mode, retainUntilDate, legalHold)
+	}
+}
+
+// setObjectLockResponseHeaders reports the object lock of a stored object on
+// GET and HEAD, like S3
+func setObjectLockResponseHeaders(w http.ResponseWriter, mode types.ObjectLockMode, retainUntilDate *time.Time, legalHold types.ObjectLockLegalHoldStatus) {
+	if mode != "" {
+		w.Header().Set(request.ObjectLockModeHeader, string(mode))
+	}
+	if retainUntilDate != nil {
+		w.Header().Set(request.ObjectLockRetainUntilDateHeader, retainUntilDate.UTC().Format(time.RFC3339))
+	}
+	if legalHold != "" {
+		w.Header().Set(request.ObjectLockLegalHoldHeader, string(legalHold))
+	}
+}
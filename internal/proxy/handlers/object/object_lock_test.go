@@ -0,0 +1,126 @@
+package object
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLock_PutForwardsHeaders(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024
+
+	var input *s3.PutObjectInput
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input = args.Get(1).(*s3.PutObjectInput)
+	}).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte("data")))
+	req.Header.Set("x-amz-object-lock-mode", "GOVERNANCE")
+	req.Header.Set("x-amz-object-lock-retain-until-date", "2030-01-02T03:04:05Z")
+	req.Header.Set("x-amz-object-lock-legal-hold", "ON")
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	assert.Equal(t, types.ObjectLockModeGovernance, input.ObjectLockMode)
+	require.NotNil(t, input.ObjectLockRetainUntilDate)
+	assert.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), input.ObjectLockRetainUntilDate.UTC())
+	assert.Equal(t, types.ObjectLockLegalHoldStatusOn, input.ObjectLockLegalHoldStatus)
+}
+
+func TestObjectLock_PutRejectsInvalidHeaders(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte("data")))
+	req.Header.Set("x-amz-object-lock-mode", "GOVERNANCE")
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "key")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Code>InvalidArgument</Code>")
+	backend.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+}
+
+func TestObjectLock_HeadReportsLock(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ObjectLockMode:            types.ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: &until,
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOff,
+	}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+	assert.Equal(t, "COMPLIANCE", rr.Header().Get("x-amz-object-lock-mode"))
+	assert.Equal(t, "2030-01-02T03:04:05Z", rr.Header().Get("x-amz-object-lock-retain-until-date"))
+	assert.Equal(t, "OFF", rr.Header().Get("x-amz-object-lock-legal-hold"))
+}
+
+func TestObjectLock_LegalHold(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	backend.On("PutObjectLegalHold", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectLegalHoldInput) bool {
+		return input.LegalHold.Status == types.ObjectLockLegalHoldStatusOn && aws.ToString(input.VersionId) == "v1"
+	})).Return(&s3.PutObjectLegalHoldOutput{}, nil).Once()
+	body := `<LegalHold xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Status>ON</Status></LegalHold>`
+	rr := httptest.NewRecorder()
+	handler.handleObjectLegalHold(rr, httptest.NewRequest(http.MethodPut, "/bucket/key?legal-hold&versionId=v1", strings.NewReader(body)), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	backend.On("GetObjectLegalHold", mock.Anything, mock.Anything).Return(&s3.GetObjectLegalHoldOutput{
+		LegalHold: &types.ObjectLockLegalHold{Status: types.ObjectLockLegalHoldStatusOn},
+	}, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.handleObjectLegalHold(rr, httptest.NewRequest(http.MethodGet, "/bucket/key?legal-hold", nil), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Status>ON</Status>")
+
+	rr = httptest.NewRecorder()
+	body = `<LegalHold><Status>MAYBE</Status></LegalHold>`
+	handler.handleObjectLegalHold(rr, httptest.NewRequest(http.MethodPut, "/bucket/key?legal-hold", strings.NewReader(body)), "bucket", "key")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	backend.AssertNumberOfCalls(t, "PutObjectLegalHold", 1)
+}
+
+func TestObjectLock_Retention(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	backend.On("PutObjectRetention", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectRetentionInput) bool {
+		return input.Retention.Mode == types.ObjectLockRetentionModeGovernance &&
+			input.Retention.RetainUntilDate.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) &&
+			aws.ToBool(input.BypassGovernanceRetention)
+	})).Return(&s3.PutObjectRetentionOutput{}, nil).Once()
+	body := `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>2030-01-02T03:04:05Z</RetainUntilDate></Retention>`
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key?retention", strings.NewReader(body))
+	req.Header.Set("x-amz-bypass-governance-retention", "true")
+	rr := httptest.NewRecorder()
+	handler.handleObjectRetention(rr, req, "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	backend.On("GetObjectRetention", mock.Anything, mock.Anything).Return(&s3.GetObjectRetentionOutput{
+		Retention: &types.ObjectLockRetention{Mode: types.ObjectLockRetentionModeGovernance, RetainUntilDate: &until},
+	}, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.handleObjectRetention(rr, httptest.NewRequest(http.MethodGet, "/bucket/key?retention", nil), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Mode>GOVERNANCE</Mode>")
+	assert.Contains(t, rr.Body.String(), "<RetainUntilDate>2030-01-02T03:04:05Z</RetainUntilDate>")
+
+	rr = httptest.NewRecorder()
+	body = `<Retention><Mode>GOVERNANCE</Mode></Retention>`
+	handler.handleObjectRetention(rr, httptest.NewRequest(http.MethodPut, "/bucket/key?retention", strings.NewReader(body)), "bucket", "key")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	backend.AssertNumberOfCalls(t, "PutObjectRetention", 1)
+}
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"encoding/xml"
+	"crypto/md5" // #nosec G501 - the S3 ETag is an MD5
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"maps"
 	"net/http"
 	"sort"
 	"strconv"
@@ -18,12 +21,15 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 )
 
 // handleGetObject handles GET object requests with decryption support
 func (h *Handler) handleGetObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Getting object")
@@ -31,27 +37,27 @@ func (h *Handler) handleGetObject(w http.ResponseWriter, r *http.Request, bucket
 	// Check if Range request is present - currently not supported with encryption
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader != "" {
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket": bucket,
 			"key":    key,
 			"range":  rangeHeader,
 		}).Warn("Range requests are not supported with encryption")
-		h.errorWriter.WriteGenericError(w, http.StatusNotImplemented, "RangeNotSupported", "Range requests are not currently supported for encrypted objects. Please download the complete object.")
+		h.errorWriter.WriteError(w, r, http.StatusNotImplemented, "RangeNotSupported", "Range requests are not currently supported for encrypted objects. Please download the complete object.")
 		return
 	}
 
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
 	}
 
-	// Add if-match headers
-	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
-		input.IfMatch = aws.String(ifMatch)
-	}
-	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
-		input.IfNoneMatch = aws.String(ifNoneMatch)
+	// Add if-match headers, in terms of backend ETags
+	if !h.translateConditions(w, r, bucket, key, false) {
+		return
 	}
+	applyConditions(r, &input.IfMatch, &input.IfNoneMatch)
+	applySSEC(r, &input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
 
 	// Get the encrypted object from S3
 	output, err := h.s3Backend.GetObject(r.Context(), input)
@@ -59,14 +65,31 @@ func (h *Handler) handleGetObject(w http.ResponseWriter, r *http.Request, bucket
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
+	backend.ValidateGetObject(r.Context(), h.s3Backend, input, output, h.config.S3Backend.ResponseValidation, h.logger)
 	defer output.Body.Close()
 
+	// SSE-C objects are encrypted by the backend; the body is already plaintext
+	if h.isSSECObject(output.Metadata) {
+		output.Metadata = h.cleanMetadata(output.Metadata)
+		setSSECResponseHeaders(w, output.SSECustomerAlgorithm, output.SSECustomerKeyMD5)
+		h.writeGetObjectResponse(w, output, false)
+		return
+	}
+
 	// Check if the object has encryption metadata
 	encryptedDEKB64, hasEncryption, _ := h.extractEncryptionMetadata(output.Metadata)
 
+	// Refuse before any plaintext is produced when the encryption context does not match
+	if hasEncryption {
+		if err := h.encryptionMgr.CheckEncryptionContext(r.Context(), output.Metadata); err != nil {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+	}
+
 	if !hasEncryption {
 		// Object is not encrypted, return as-is
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket": bucket,
 			"key":    key,
 		}).Debug("Object not encrypted, returning as-is")
@@ -77,8 +100,8 @@ func (h *Handler) handleGetObject(w http.ResponseWriter, r *http.Request, bucket
 	// Decode the encrypted DEK
 	encryptedDEK, err := h.decodeEncryptedDEK(encryptedDEKB64)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to decode encrypted DEK")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "DecryptionError", "Failed to decode encryption key")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to decode encrypted DEK")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "DecryptionError", "Failed to decode encryption key")
 		return
 	}
 
@@ -88,31 +111,31 @@ func (h *Handler) handleGetObject(w http.ResponseWriter, r *http.Request, bucket
 		dekAlgorithm = dekAlgorithmValue
 	}
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket":       bucket,
 		"key":          key,
 		"dekAlgorithm": dekAlgorithm,
 	}).Debug("Processing encrypted object based on DEK algorithm")
 
-	if dekAlgorithm == "aes-ctr" {
-		// For AES-CTR, ALWAYS use streaming decryption for consistent HMAC calculation
+	if encryption.IsStreamingAlgorithm(dekAlgorithm) {
+		// For AES-CTR and XChaCha20, ALWAYS use streaming decryption for consistent HMAC calculation
 		// This ensures upload and download use the same sequential HMAC approach
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket": bucket,
 			"key":    key,
 		}).Debug("Using streaming decryption for CTR object")
 		h.handleGetObjectStreamingDecryption(w, r, output, encryptedDEK, key)
 	} else {
 		// AES-GCM: Use memory decryption for whole file processing
-		h.handleGetObjectMemoryDecryption(w, r, output, encryptedDEK, key)
+		h.handleGetObjectMemoryDecryption(w, r, output, encryptedDEK, bucket, key)
 	}
 }
 
 // handleGetObjectStreamingDecryption handles memory-optimized decryption for multipart objects
 func (h *Handler) handleGetObjectStreamingDecryption(w http.ResponseWriter, r *http.Request, output *s3.GetObjectOutput, encryptedDEK []byte, objectKey string) {
-	h.logger.WithField("objectKey", objectKey).Debug("🚀 ENTERED handleGetObjectStreamingDecryption function!")
+	h.logger.WithContext(r.Context()).WithField("objectKey", objectKey).Debug("🚀 ENTERED handleGetObjectStreamingDecryption function!")
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"operation": "get-streaming",
 		"key":       objectKey,
 	}).Debug("Using streaming decryption for multipart object")
@@ -129,21 +152,30 @@ func (h *Handler) handleGetObjectStreamingDecryption(w http.ResponseWriter, r *h
 
 	decryptedReader, err := h.encryptionMgr.CreateStreamingDecryptionReaderWithSize(r.Context(), output.Body, encryptedDEK, output.Metadata, objectKey, providerAlias, contentLength)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to create streaming decryption reader")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "DecryptionError", "Failed to create decryption reader")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to create streaming decryption reader")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "DecryptionError", "Failed to create decryption reader")
 		return
 	}
+	decryptedReader, err = h.decompressBody(decryptedReader, output.Metadata)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to decompress object data")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "DecompressionError", "Failed to decompress object data")
+		return
+	}
+
+	// Same length for AES-CTR, unless the object was compressed
+	plaintextLen := h.plaintextContentLength(output.Metadata, output.ContentLength)
 
 	// 🚨 CRITICAL: Add defer to ensure HMAC verification happens
 	defer func() {
 		if closer, ok := decryptedReader.(io.Closer); ok {
 			if closeErr := closer.Close(); closeErr != nil {
-				h.logger.WithError(closeErr).WithField("objectKey", objectKey).Error("❌ Error during forced Close()")
+				h.logger.WithContext(r.Context()).WithError(closeErr).WithField("objectKey", objectKey).Error("❌ Error during forced Close()")
 			} else {
-				h.logger.WithField("objectKey", objectKey).Debug("✅ Successfully forced Close() on decryptedReader")
+				h.logger.WithContext(r.Context()).WithField("objectKey", objectKey).Debug("✅ Successfully forced Close() on decryptedReader")
 			}
 		} else {
-			h.logger.WithField("objectKey", objectKey).Error("❌ decryptedReader does not implement io.Closer")
+			h.logger.WithContext(r.Context()).WithField("objectKey", objectKey).Error("❌ decryptedReader does not implement io.Closer")
 		}
 	}()
 
@@ -155,11 +187,11 @@ func (h *Handler) handleGetObjectStreamingDecryption(w http.ResponseWriter, r *h
 		ContentDisposition:        output.ContentDisposition,
 		ContentEncoding:           output.ContentEncoding,
 		ContentLanguage:           output.ContentLanguage,
-		ContentLength:             output.ContentLength, // Same length for AES-CTR
+		ContentLength:             plaintextLen,
 		ContentRange:              output.ContentRange,
 		ContentType:               output.ContentType,
 		DeleteMarker:              output.DeleteMarker,
-		ETag:                      output.ETag,
+		ETag:                      h.responseETag(output.Metadata, output.ETag),
 		Expiration:                output.Expiration,
 		ExpiresString:             output.ExpiresString,
 		LastModified:              output.LastModified,
@@ -180,30 +212,30 @@ func (h *Handler) handleGetObjectStreamingDecryption(w http.ResponseWriter, r *h
 		TagCount:                  output.TagCount,
 		VersionId:                 output.VersionId,
 		WebsiteRedirectLocation:   output.WebsiteRedirectLocation,
-		ChecksumCRC32:             output.ChecksumCRC32,
-		ChecksumCRC32C:            output.ChecksumCRC32C,
-		ChecksumSHA1:              output.ChecksumSHA1,
-		ChecksumSHA256:            output.ChecksumSHA256,
 	}
 
 	// *** HMAC VALIDATION CRITICAL POINT ***
 	// Perform early HMAC validation by reading a small portion of the stream
 	// This ensures we catch HMAC failures BEFORE sending HTTP response headers
 	if h.shouldValidateHMACEarly(output.Metadata) {
-		h.logger.WithField("objectKey", objectKey).Debug("🔍 Performing early HMAC validation before HTTP response")
+		h.logger.WithContext(r.Context()).WithField("objectKey", objectKey).Debug("🔍 Performing early HMAC validation before HTTP response")
 
 		validatedReader, validationErr := h.validateHMACEarly(decryptedReader, objectKey)
 		if validationErr != nil {
-			h.logger.WithError(validationErr).WithField("objectKey", objectKey).Error("❌ Early HMAC validation failed")
-			h.errorWriter.WriteGenericError(w, http.StatusForbidden, "HMACValidationFailed", "HMAC integrity verification failed - data may be corrupted or tampered")
+			h.logger.WithContext(r.Context()).WithError(validationErr).WithField("objectKey", objectKey).Error("❌ Early HMAC validation failed")
+			h.errorWriter.WriteError(w, r, http.StatusForbidden, "HMACValidationFailed", "HMAC integrity verification failed - data may be corrupted or tampered")
 			return
 		}
 
 		// Replace the reader with the validated one
 		decryptedOutput.Body = validatedReader
-		h.logger.WithField("objectKey", objectKey).Debug("✅ Early HMAC validation successful")
+		h.logger.WithContext(r.Context()).WithField("objectKey", objectKey).Debug("✅ Early HMAC validation successful")
 	}
+	decryptedOutput.Body = h.verifyChecksum(decryptedOutput.Body, output.Metadata)
 
+	h.setChecksumHeaders(w, r, output.Metadata)
+	h.setEmulatedSSEHeaders(w, output.Metadata)
+	h.setEncryptedObjectAcceptRanges(w, output.Metadata)
 	h.writeGetObjectResponse(w, decryptedOutput, true)
 }
 
@@ -266,35 +298,41 @@ func (h *Handler) validateHMACEarly(reader io.ReadCloser, objectKey string) (io.
 }
 
 // handleGetObjectMemoryDecryption streams AES-GCM plaintext directly to the
-// ResponseWriter without buffering the encrypted ciphertext or the decrypted
-// plaintext in memory. Note: the underlying AES-GCM implementation still
-// buffers internally for auth-tag verification (Tier 3.1 covers that); this
-// change eliminates only the handler-side double allocation.
-func (h *Handler) handleGetObjectMemoryDecryption(w http.ResponseWriter, r *http.Request, output *s3.GetObjectOutput, _ []byte, objectKey string) {
+// ResponseWriter. Objects up to 4 MiB are authenticated before the response
+// starts; larger ones are sealed in 64 KiB segments, each released only once
+// its tag is verified, and a failed tag check aborts the response.
+func (h *Handler) handleGetObjectMemoryDecryption(w http.ResponseWriter, r *http.Request, output *s3.GetObjectOutput, _ []byte, bucket, objectKey string) {
 	plaintextReader, err := h.encryptionMgr.DecryptDataWithMetadata(r.Context(), output.Body, output.Metadata, objectKey)
 	if err != nil {
 		if output.Body != nil {
 			_ = output.Body.Close()
 		}
-		h.logger.WithError(err).Error("Failed to decrypt object data")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "DecryptionError", "Failed to decrypt object data")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to decrypt object data")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "DecryptionError", "Failed to decrypt object data")
+		return
+	}
+
+	plaintextReader, err = h.decompressBody(plaintextReader, output.Metadata)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to decompress object data")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "DecompressionError", "Failed to decompress object data")
 		return
 	}
 
 	// GCM ciphertext carries a 12-byte nonce prefix and a 16-byte auth tag
-	// suffix. Plaintext length = encrypted length - 28.
-	var plaintextLen *int64
-	if output.ContentLength != nil {
-		l := aws.ToInt64(output.ContentLength) - 28
-		if l >= 0 {
-			plaintextLen = aws.Int64(l)
-		}
+	// suffix, which the stored or derived plaintext length excludes
+	plaintextLen := h.plaintextContentLength(output.Metadata, output.ContentLength)
+
+	// Legacy objects without a stored plaintext size report the ciphertext
+	// size on HEAD; record the real size once this GET has decrypted it all
+	if h.shouldBackfillPlaintextSize(output) {
+		plaintextReader = h.withPlaintextSizeBackfill(r.Context(), plaintextReader, output, bucket, objectKey)
 	}
 
 	// Create modified output with decrypted data
 	decryptedOutput := &s3.GetObjectOutput{
 		AcceptRanges:              output.AcceptRanges,
-		Body:                      plaintextReader,
+		Body:                      h.verifyChecksum(plaintextReader, output.Metadata),
 		CacheControl:              output.CacheControl,
 		ContentDisposition:        output.ContentDisposition,
 		ContentEncoding:           output.ContentEncoding,
@@ -303,7 +341,7 @@ func (h *Handler) handleGetObjectMemoryDecryption(w http.ResponseWriter, r *http
 		ContentRange:              output.ContentRange,
 		ContentType:               output.ContentType,
 		DeleteMarker:              output.DeleteMarker,
-		ETag:                      output.ETag,
+		ETag:                      h.responseETag(output.Metadata, output.ETag),
 		Expiration:                output.Expiration,
 		ExpiresString:             output.ExpiresString,
 		LastModified:              output.LastModified,
@@ -324,12 +362,11 @@ func (h *Handler) handleGetObjectMemoryDecryption(w http.ResponseWriter, r *http
 		TagCount:                  output.TagCount,
 		VersionId:                 output.VersionId,
 		WebsiteRedirectLocation:   output.WebsiteRedirectLocation,
-		ChecksumCRC32:             output.ChecksumCRC32,
-		ChecksumCRC32C:            output.ChecksumCRC32C,
-		ChecksumSHA1:              output.ChecksumSHA1,
-		ChecksumSHA256:            output.ChecksumSHA256,
 	}
 
+	h.setChecksumHeaders(w, r, output.Metadata)
+	h.setEmulatedSSEHeaders(w, output.Metadata)
+	h.setEncryptedObjectAcceptRanges(w, output.Metadata)
 	h.writeGetObjectResponse(w, decryptedOutput, true)
 }
 
@@ -346,8 +383,10 @@ func (h *Handler) writeGetObjectResponse(w http.ResponseWriter, output *s3.GetOb
 		w.Header().Set("ETag", *output.ETag)
 	}
 	if output.LastModified != nil {
-		w.Header().Set("Last-Modified", output.LastModified.Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+		w.Header().Set("Last-Modified", output.LastModified.UTC().Format(http.TimeFormat))
 	}
+	setVersionIDHeader(w, output.VersionId)
+	setObjectLockResponseHeaders(w, output.ObjectLockMode, output.ObjectLockRetainUntilDate, output.ObjectLockLegalHoldStatus)
 
 	// Copy metadata headers (encryption metadata is already cleaned)
 	if output.Metadata != nil {
@@ -368,7 +407,7 @@ func (h *Handler) writeGetObjectResponse(w http.ResponseWriter, output *s3.GetOb
 
 		// Stream directly - the streamingDecryptionReader handles HMAC verification internally
 		// No need for additional wrapper since HMAC verification happens in Close()
-		if _, err := copyWithPooledBuffer(w, output.Body); err != nil {
+		if _, err := h.copyResponse(w, output.Body); err != nil {
 			h.logger.WithError(err).Error("❌ Streaming response failed during copy")
 			// Connection will be automatically closed
 			return
@@ -387,7 +426,7 @@ func (h *Handler) writeGetObjectResponse(w http.ResponseWriter, output *s3.GetOb
 		w.WriteHeader(http.StatusOK)
 
 		// Stream the object body
-		if _, err := copyWithPooledBuffer(w, output.Body); err != nil {
+		if _, err := h.copyResponse(w, output.Body); err != nil {
 			h.logger.WithError(err).Error("Failed to write object data")
 		}
 
@@ -402,14 +441,14 @@ func (h *Handler) writeGetObjectResponse(w http.ResponseWriter, output *s3.GetOb
 
 // handlePutObject handles PUT object requests with encryption support
 func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Putting object")
 
 	// Check if this is a CopyObject request (PUT with x-amz-copy-source header)
 	if copySource := r.Header.Get("x-amz-copy-source"); copySource != "" {
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket":     bucket,
 			"key":        key,
 			"copySource": copySource,
@@ -424,6 +463,32 @@ func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket
 		return
 	}
 
+	// Object lock headers are passed through; invalid ones fail before the
+	// body is read
+	if _, err := request.ParseObjectLock(r); err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	// Conditional writes, in terms of backend ETags
+	if !h.translateConditions(w, r, bucket, key, true) {
+		return
+	}
+
+	// SSE-C passthrough: the backend encrypts with the client's key
+	if sse, ok := request.ParseSSEC(r); ok {
+		h.putObjectSSEC(w, r, bucket, key, sse)
+		return
+	}
+
+	// Checksums are of the plaintext: they are verified by the proxy and not
+	// sent with the ciphertext, for which the backend client computes its own
+	checksum, err := parseRequestChecksum(r)
+	if err != nil {
+		h.writeChecksumError(w, r, err)
+		return
+	}
+
 	// Get content type for encryption mode forcing
 	contentType := r.Header.Get("Content-Type")
 
@@ -431,7 +496,7 @@ func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket
 	// Check if content-type forces streaming (AES-CTR)
 	forced := contentType == fmt.Sprintf("application/x-%sforce-aes-ctr", h.metadataPrefix)
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket":          bucket,
 		"key":             key,
 		"content_type":    contentType,
@@ -445,7 +510,7 @@ func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket
 	// Very small files (< 1KB) can't use multipart upload due to S3 constraints
 	// For these, use direct encryption but with CTR content type to force AES-CTR
 	if forced && r.ContentLength >= 0 && r.ContentLength < 1024 {
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket":        bucket,
 			"key":           key,
 			"contentLength": r.ContentLength,
@@ -456,47 +521,58 @@ func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket
 		// Read the small amount of data into memory
 		data, err := io.ReadAll(r.Body)
 		if err != nil {
-			h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "ReadError", "Failed to read request body")
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "ReadError", "Failed to read request body")
 			return
 		}
 
-		h.putObjectDirect(w, r, bucket, key, data, contentType)
+		h.putObjectDirect(w, r, bucket, key, data, contentType, checksum)
 		return
 	}
 
-	// Auto-multipart branch handles two cases where single-part PutObject is unsafe:
+	// Auto-multipart branch handles three cases where single-part PutObject is unsafe:
 	//   (a) HMAC enabled + large object: HMAC must be known before the PutObject header is sent,
 	//       but single-part EncryptCTR can only produce it by buffering the whole plaintext.
 	//       The multipart pipeline computes HMAC incrementally per part.
 	//   (b) Unknown Content-Length: single-part PutObject requires a known Content-Length;
 	//       multipart uses per-part lengths, so it handles streaming uploads of any size.
+	//   (c) Size at or above optimizations.auto_multipart_threshold: avoids the backend's
+	//       single PUT size limit, and a failed part is retried instead of the whole upload.
 	// The none provider skips auto-multipart for (a) (no HMAC to compute), but still uses it
-	// for (b) so the body can be streamed without knowing the total size up front.
+	// for (b) and (c) so the body can be streamed in parts. Plaintext ETags and checksums are
+	// routed like (a): the MD5 and checksum are only known once the body was read. Streamed
+	// bodies that are compressed are routed like (b): their compressed size is not known
+	// before they were read.
 	const multipartMinSize = 5 * 1024 * 1024 // S3 minimum part size
 	plaintextLen := h.requestParser.DecodedContentLength(r)
 	contentLengthUnknown := plaintextLen < 0
 	largeEnough := plaintextLen >= multipartMinSize
 	hmacLarge := h.isHMACEnabled() && largeEnough && !h.encryptionMgr.IsNoneProvider()
-	if contentLengthUnknown || hmacLarge {
-		h.putObjectAutoMultipart(w, r, bucket, key, contentType)
+	etagLarge := h.plaintextETags() && largeEnough
+	checksumLarge := checksum != nil && largeEnough
+	threshold := h.getAutoMultipartThreshold()
+	overThreshold := threshold > 0 && plaintextLen >= threshold
+	streamed := forced || r.ContentLength < 0 || r.ContentLength >= h.config.Optimizations.StreamingThreshold
+	compressedStream := streamed && h.compressesStream(r, plaintextLen)
+	if contentLengthUnknown || hmacLarge || etagLarge || checksumLarge || overThreshold || compressedStream {
+		h.putObjectAutoMultipart(w, r, bucket, key, contentType, plaintextLen, checksum)
 		return
 	}
 
 	// Use size-based routing unless forced by content-type
 	// Use streaming for: forced CTR (>=1KB), unknown size, or files >= streaming threshold
-	if forced || r.ContentLength < 0 || r.ContentLength >= h.config.Optimizations.StreamingThreshold {
+	if streamed {
 		reason := getStreamingReason(forced, r.ContentLength, h.config.Optimizations.StreamingThreshold)
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket":        bucket,
 			"key":           key,
 			"contentLength": r.ContentLength,
 			"streaming":     true,
 			"reason":        reason,
 		}).Debug("Using streaming upload")
-		h.putObjectStreamingReader(w, r, bucket, key, r.Body, contentType)
+		h.putObjectStreamingReader(w, r, bucket, key, r.Body, contentType, checksum)
 	} else {
 		// Use direct encryption for small files (AES-GCM)
-		h.logger.WithFields(map[string]interface{}{
+		h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 			"bucket":        bucket,
 			"key":           key,
 			"contentLength": r.ContentLength,
@@ -507,15 +583,15 @@ func (h *Handler) handlePutObject(w http.ResponseWriter, r *http.Request, bucket
 		// Read request body with automatic chunked decoding if needed
 		data, err := h.requestParser.ReadBody(r)
 		if err != nil {
-			h.logger.WithError(err).Error("Failed to read request body")
-			h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "ReadError", "Failed to read request body")
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to read request body")
+			h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "ReadError", "Failed to read request body")
 			return
 		}
 
 		// Reset request body with processed data for downstream use
 		h.requestParser.ResetBody(r, data)
 
-		h.putObjectDirect(w, r, bucket, key, data, contentType)
+		h.putObjectDirect(w, r, bucket, key, data, contentType, checksum)
 	}
 }
 
@@ -528,7 +604,24 @@ func getStreamingReason(forced bool, contentLength int64, threshold int64) strin
 }
 
 // putObjectDirect handles direct encryption for small objects (AES-GCM)
-func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket, key string, data []byte, contentType string) {
+func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket, key string, data []byte, contentType string, checksum *requestChecksum) {
+	if checksum != nil {
+		_, _ = checksum.Write(data)
+		if err := checksum.verify(r); err != nil {
+			h.writeChecksumError(w, r, err)
+			return
+		}
+	}
+
+	var plaintextMD5 []byte
+	if h.plaintextETags() {
+		sum := md5.Sum(data) // #nosec G401 - the S3 ETag is an MD5
+		plaintextMD5 = sum[:]
+	}
+
+	// Compress before encrypting; ciphertext does not compress
+	data, compressionMetadata := h.compressForPut(r, data)
+
 	// Convert byte slice to bufio.Reader for streaming
 	dataReader := bufio.NewReader(bytes.NewReader(data))
 
@@ -537,9 +630,13 @@ func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket
 
 	// Encrypt the data with HTTP Content-Type awareness for encryption mode forcing
 	streamResult, err := h.encryptionMgr.EncryptDataWithHTTPContentType(r.Context(), dataReader, key, contentType, isMultipart)
+	if errors.Is(err, orchestration.ErrEncryptionContextUnbound) {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to encrypt object data")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "EncryptionError", "Failed to encrypt object data")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to encrypt object data")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "EncryptionError", "Failed to encrypt object data")
 		return
 	}
 
@@ -553,6 +650,14 @@ func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket
 
 	// Prepare metadata
 	metadata := h.prepareEncryptionMetadata(r, encResult)
+	maps.Copy(metadata, compressionMetadata)
+	h.recordPlaintextSize(metadata, streamResult.Algorithm, int64(len(data)))
+	if len(streamResult.Metadata) > 0 {
+		if plaintextMD5 != nil {
+			h.recordPlaintextETag(metadata, plaintextMD5)
+		}
+		h.recordChecksum(metadata, checksum)
+	}
 
 	// Create input for S3 — stream the ciphertext directly without buffering
 	input := &s3.PutObjectInput{
@@ -565,6 +670,8 @@ func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket
 
 	// Add other headers from request
 	h.addRequestHeaders(r, input)
+	applyConditions(r, &input.IfMatch, &input.IfNoneMatch)
+	applyObjectLock(r, &input.ObjectLockMode, &input.ObjectLockRetainUntilDate, &input.ObjectLockLegalHoldStatus)
 
 	// Content length is computable without buffering. For the none provider the stream is
 	// plaintext pass-through (empty Algorithm, no metadata); for encrypted paths we add the
@@ -578,20 +685,23 @@ func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket
 	// Store the encrypted object
 	output, err := h.s3Backend.PutObject(r.Context(), input)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to store encrypted object")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to store encrypted object")
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Object encrypted and stored successfully")
 
 	// Set response headers
-	if output.ETag != nil {
-		w.Header().Set("ETag", *output.ETag)
+	if etag := h.responseETag(metadata, output.ETag); etag != nil {
+		w.Header().Set("ETag", *etag)
 	}
+	setVersionIDHeader(w, output.VersionId)
+	checksum.setResponseHeader(w)
+	h.setEmulatedSSEHeaders(w, metadata)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -600,8 +710,8 @@ func (h *Handler) putObjectDirect(w http.ResponseWriter, r *http.Request, bucket
 // The body is never fully buffered — aws-chunked is decoded on the fly, plaintext length is
 // taken from X-Amz-Decoded-Content-Length or Content-Length, and ciphertext length is computed
 // deterministically so the AWS SDK can emit Content-Length without touching the body.
-func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Request, bucket, key string, _ io.Reader, contentType string) {
-	h.logger.WithFields(map[string]interface{}{
+func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Request, bucket, key string, _ io.Reader, contentType string, checksum *requestChecksum) {
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Starting streaming single-part upload with AES-CTR")
@@ -610,18 +720,47 @@ func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Reques
 	if plaintextLen < 0 {
 		// Unknown plaintext size — we can't compute ciphertext Content-Length and PutObject
 		// requires one. Caller must route such uploads to auto-multipart; this is a safety net.
-		h.logger.Error("Streaming single-part upload requires known Content-Length")
-		h.errorWriter.WriteGenericError(w, http.StatusLengthRequired, "MissingContentLength", "Content-Length required for streaming upload")
+		h.logger.WithContext(r.Context()).Error("Streaming single-part upload requires known Content-Length")
+		h.errorWriter.WriteError(w, r, http.StatusLengthRequired, "MissingContentLength", "Content-Length required for streaming upload")
 		return
 	}
 
-	bodyStream := h.requestParser.StreamingReader(r)
+	// The body is checked against its payload hash and chunk signatures at
+	// its end, which the backend, reading putContentLength bytes, never asks
+	// for. The sized reader reads on to it before the last bytes are released.
+	bodyStream := request.NewSizedBodyReader(h.requestParser.StreamingReader(r), plaintextLen)
 	bodyReader := bufio.NewReaderSize(bodyStream, 64*1024)
 
+	// The plaintext ETag and checksum are sent in the metadata, ahead of the
+	// body. Objects are only routed here in plaintext ETag mode or with a
+	// checksum if they are smaller than the S3 minimum part size, so they are
+	// read into memory to hash them.
+	var plaintextMD5 []byte
+	if h.plaintextETags() || checksum != nil {
+		data, err := io.ReadAll(bodyReader)
+		if err != nil {
+			h.logger.WithContext(r.Context()).WithError(err).Error("Failed to read request body")
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+			return
+		}
+		if h.plaintextETags() {
+			sum := md5.Sum(data) // #nosec G401 - the S3 ETag is an MD5
+			plaintextMD5 = sum[:]
+		}
+		if checksum != nil {
+			_, _ = checksum.Write(data)
+			if err := checksum.verify(r); err != nil {
+				h.writeChecksumError(w, r, err)
+				return
+			}
+		}
+		bodyReader = bufio.NewReader(bytes.NewReader(data))
+	}
+
 	isMultipart := contentType == fmt.Sprintf("application/x-%sforce-aes-ctr", h.metadataPrefix) ||
 		plaintextLen >= h.config.Optimizations.StreamingThreshold
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"content_type":        contentType,
 		"plaintext_length":    plaintextLen,
 		"streaming_threshold": h.config.Optimizations.StreamingThreshold,
@@ -630,7 +769,7 @@ func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Reques
 
 	encResult, err := h.encryptionMgr.EncryptDataWithHTTPContentType(r.Context(), bodyReader, key, contentType, isMultipart)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to encrypt data with streaming")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to encrypt data with streaming")
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
@@ -678,6 +817,11 @@ func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Reques
 			KeyFingerprint: encResult.KeyFingerprint,
 		}
 		metadata = h.prepareEncryptionMetadata(r, compatibleResult)
+		h.recordPlaintextSize(metadata, encResult.Algorithm, plaintextLen)
+		if plaintextMD5 != nil {
+			h.recordPlaintextETag(metadata, plaintextMD5)
+		}
+		h.recordChecksum(metadata, checksum)
 	}
 	putInput.Metadata = metadata
 
@@ -698,16 +842,21 @@ func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Reques
 		putInput.ContentMD5 = aws.String(r.Header.Get("Content-MD5"))
 	}
 	// Skip Expires header as it requires time parsing
+	applyConditions(r, &putInput.IfMatch, &putInput.IfNoneMatch)
+	applyObjectLock(r, &putInput.ObjectLockMode, &putInput.ObjectLockRetainUntilDate, &putInput.ObjectLockLegalHoldStatus)
 
 	// Upload to S3 using single-part PutObject
 	putOutput, err := h.s3Backend.PutObject(r.Context(), putInput)
+	if bodyErr := bodyStream.Err(); bodyErr != nil {
+		err = bodyErr
+	}
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to upload object to S3")
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to upload object to S3")
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
 
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket":        bucket,
 		"key":           key,
 		"originalSize":  plaintextLen,
@@ -717,42 +866,60 @@ func (h *Handler) putObjectStreamingReader(w http.ResponseWriter, r *http.Reques
 	}).Debug("Streaming single-part upload completed successfully")
 
 	// Write successful response
-	w.Header().Set("ETag", aws.ToString(putOutput.ETag))
+	w.Header().Set("ETag", aws.ToString(h.responseETag(metadata, putOutput.ETag)))
+	setVersionIDHeader(w, putOutput.VersionId)
+	checksum.setResponseHeader(w)
+	h.setEmulatedSSEHeaders(w, metadata)
 	w.WriteHeader(http.StatusOK)
 }
 
 // handleDeleteObject handles DELETE object requests
 func (h *Handler) handleDeleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Deleting object")
 
 	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
+	}
+	if mfa := r.Header.Get("x-amz-mfa"); mfa != "" {
+		input.MFA = aws.String(mfa)
+	}
+	if strings.EqualFold(r.Header.Get("x-amz-bypass-governance-retention"), "true") {
+		input.BypassGovernanceRetention = aws.Bool(true)
 	}
 
-	_, err := h.s3Backend.DeleteObject(r.Context(), input)
+	output, err := h.s3Backend.DeleteObject(r.Context(), input)
 	if err != nil {
 		h.errorWriter.WriteS3Error(w, err, bucket, key)
 		return
 	}
 
+	// A delete without versionId in a versioned bucket adds a delete marker,
+	// reported with its version id
+	setVersionIDHeader(w, output.VersionId)
+	if aws.ToBool(output.DeleteMarker) {
+		w.Header().Set("x-amz-delete-marker", "true")
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleHeadObject handles HEAD object requests with encryption metadata filtering
 func (h *Handler) handleHeadObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"bucket": bucket,
 		"key":    key,
 	}).Debug("Getting object metadata")
 
 	input := &s3.HeadObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
 	}
+	applySSEC(r, &input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
 
 	output, err := h.s3Backend.HeadObject(r.Context(), input)
 	if err != nil {
@@ -764,15 +931,21 @@ func (h *Handler) handleHeadObject(w http.ResponseWriter, r *http.Request, bucke
 	if output.ContentType != nil {
 		w.Header().Set("Content-Type", *output.ContentType)
 	}
-	if output.ContentLength != nil {
-		w.Header().Set("Content-Length", strconv.FormatInt(*output.ContentLength, 10))
+	if contentLength := h.plaintextContentLength(output.Metadata, output.ContentLength); contentLength != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(*contentLength, 10))
 	}
-	if output.ETag != nil {
-		w.Header().Set("ETag", *output.ETag)
+	if etag := h.responseETag(output.Metadata, output.ETag); etag != nil {
+		w.Header().Set("ETag", *etag)
 	}
 	if output.LastModified != nil {
-		w.Header().Set("Last-Modified", output.LastModified.Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+		w.Header().Set("Last-Modified", output.LastModified.UTC().Format(http.TimeFormat))
 	}
+	setVersionIDHeader(w, output.VersionId)
+	setSSECResponseHeaders(w, output.SSECustomerAlgorithm, output.SSECustomerKeyMD5)
+	setObjectLockResponseHeaders(w, output.ObjectLockMode, output.ObjectLockRetainUntilDate, output.ObjectLockLegalHoldStatus)
+	h.setChecksumHeaders(w, r, output.Metadata)
+	h.setEmulatedSSEHeaders(w, output.Metadata)
+	h.setEncryptedObjectAcceptRanges(w, output.Metadata)
 
 	// Copy metadata headers (but filter out encryption metadata)
 	cleanedMetadata := h.cleanMetadata(output.Metadata)
@@ -786,273 +959,9 @@ func (h *Handler) handleHeadObject(w http.ResponseWriter, r *http.Request, bucke
 // ===== PASSTHROUGH OPERATIONS =====
 // These operations are passed through to S3 without encryption/decryption
 
-// handleDeleteObjects handles bulk object deletion
-func (h *Handler) handleDeleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "delete-objects",
-		"bucket":    bucket,
-	}).Debug("Handling delete objects (passthrough)")
-
-	// Parse request body to get delete request
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidRequest", "Failed to read request body")
-		return
-	}
-	defer r.Body.Close()
-
-	// Parse XML delete request
-	var deleteRequest struct {
-		XMLName xml.Name `xml:"Delete"`
-		Objects []struct {
-			Key       string `xml:"Key"`
-			VersionID string `xml:"VersionId,omitempty"`
-		} `xml:"Object"`
-		Quiet bool `xml:"Quiet"`
-	}
-
-	if err := xml.Unmarshal(body, &deleteRequest); err != nil {
-		h.logger.WithFields(map[string]interface{}{
-			"operation": "delete-objects",
-			"bucket":    bucket,
-			"error":     err.Error(),
-			"bodySize":  len(body),
-		}).Error("Failed to parse delete objects XML request")
-		h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
-		return
-	}
-
-	// Convert parsed objects to AWS SDK types
-	objects := make([]types.ObjectIdentifier, len(deleteRequest.Objects))
-	for i, obj := range deleteRequest.Objects {
-		objects[i] = types.ObjectIdentifier{
-			Key: aws.String(obj.Key),
-		}
-		if obj.VersionID != "" {
-			objects[i].VersionId = aws.String(obj.VersionID)
-		}
-	}
-
-	input := &s3.DeleteObjectsInput{
-		Bucket: aws.String(bucket),
-		Delete: &types.Delete{
-			Objects: objects,
-			Quiet:   aws.Bool(deleteRequest.Quiet),
-		},
-	}
-
-	// Copy headers for checksum validation
-	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
-		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
-	}
-
-	h.logger.WithFields(map[string]interface{}{
-		"operation":   "delete-objects",
-		"bucket":      bucket,
-		"objectCount": len(objects),
-		"quiet":       deleteRequest.Quiet,
-	}).Debug("Calling S3 delete objects")
-
-	output, err := h.s3Backend.DeleteObjects(r.Context(), input)
-	if err != nil {
-		h.errorWriter.WriteS3Error(w, err, bucket, "")
-		return
-	}
-
-	// Set response headers
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	// Create XML response structure
-	type DeleteError struct {
-		Key       string `xml:"Key"`
-		Code      string `xml:"Code"`
-		Message   string `xml:"Message"`
-		VersionID string `xml:"VersionId,omitempty"`
-	}
-
-	type DeleteResult struct {
-		XMLName xml.Name `xml:"DeleteResult"`
-		Deleted []struct {
-			Key       string `xml:"Key"`
-			VersionID string `xml:"VersionId,omitempty"`
-		} `xml:"Deleted"`
-		Errors []DeleteError `xml:"Error"`
-	}
-
-	result := DeleteResult{}
-
-	// Add successfully deleted objects
-	for _, deleted := range output.Deleted {
-		item := struct {
-			Key       string `xml:"Key"`
-			VersionID string `xml:"VersionId,omitempty"`
-		}{
-			Key: aws.ToString(deleted.Key),
-		}
-		if deleted.VersionId != nil {
-			item.VersionID = aws.ToString(deleted.VersionId)
-		}
-		result.Deleted = append(result.Deleted, item)
-	}
-
-	// Add errors
-	for _, errItem := range output.Errors {
-		deleteErr := DeleteError{
-			Key:     aws.ToString(errItem.Key),
-			Code:    aws.ToString(errItem.Code),
-			Message: aws.ToString(errItem.Message),
-		}
-		if errItem.VersionId != nil {
-			deleteErr.VersionID = aws.ToString(errItem.VersionId)
-		}
-		result.Errors = append(result.Errors, deleteErr)
-	}
-
-	// Marshal and write XML response
-	xmlData, err := xml.Marshal(result)
-	if err != nil {
-		h.logger.WithFields(map[string]interface{}{
-			"operation": "delete-objects",
-			"bucket":    bucket,
-			"error":     err.Error(),
-		}).Error("Failed to marshal delete objects response")
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "InternalError", "Failed to generate response")
-		return
-	}
-
-	// Write XML declaration and response
-	if _, err := w.Write([]byte(xml.Header)); err != nil {
-		h.logger.WithError(err).Error("Failed to write XML header")
-		return
-	}
-	if _, err := w.Write(xmlData); err != nil {
-		h.logger.WithError(err).Error("Failed to write XML data")
-		return
-	}
-
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "delete-objects",
-		"bucket":    bucket,
-		"deleted":   len(output.Deleted),
-		"errors":    len(output.Errors),
-	}).Debug("Delete objects completed")
-}
-
-// handleObjectLegalHold handles object legal hold operations
-func (h *Handler) handleObjectLegalHold(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "object-legal-hold",
-		"bucket":    bucket,
-		"key":       key,
-		"method":    r.Method,
-	}).Debug("Handling object legal hold (passthrough)")
-
-	switch r.Method {
-	case "GET":
-		input := &s3.GetObjectLegalHoldInput{
-			Bucket: aws.String(bucket),
-			Key:    aws.String(key),
-		}
-
-		_, err := h.s3Backend.GetObjectLegalHold(r.Context(), input)
-		if err != nil {
-			h.errorWriter.WriteS3Error(w, err, bucket, key)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/xml")
-		w.WriteHeader(http.StatusOK)
-		// TODO: Write proper XML response based on output.LegalHold
-
-	case "PUT":
-		_, err := io.ReadAll(r.Body)
-		if err != nil {
-			h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidRequest", "Failed to read request body")
-			return
-		}
-		defer r.Body.Close()
-
-		input := &s3.PutObjectLegalHoldInput{
-			Bucket: aws.String(bucket),
-			Key:    aws.String(key),
-			LegalHold: &types.ObjectLockLegalHold{
-				Status: types.ObjectLockLegalHoldStatusOn, // Parse from body
-			},
-		}
-
-		_, err = h.s3Backend.PutObjectLegalHold(r.Context(), input)
-		if err != nil {
-			h.errorWriter.WriteS3Error(w, err, bucket, key)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-
-	default:
-		h.errorWriter.WriteGenericError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed for legal hold")
-	}
-}
-
-// handleObjectRetention handles object retention operations
-func (h *Handler) handleObjectRetention(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "object-retention",
-		"bucket":    bucket,
-		"key":       key,
-		"method":    r.Method,
-	}).Debug("Handling object retention (passthrough)")
-
-	switch r.Method {
-	case "GET":
-		input := &s3.GetObjectRetentionInput{
-			Bucket: aws.String(bucket),
-			Key:    aws.String(key),
-		}
-
-		_, err := h.s3Backend.GetObjectRetention(r.Context(), input)
-		if err != nil {
-			h.errorWriter.WriteS3Error(w, err, bucket, key)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/xml")
-		w.WriteHeader(http.StatusOK)
-		// TODO: Write proper XML response based on output.Retention
-
-	case "PUT":
-		_, err := io.ReadAll(r.Body)
-		if err != nil {
-			h.errorWriter.WriteGenericError(w, http.StatusBadRequest, "InvalidRequest", "Failed to read request body")
-			return
-		}
-		defer r.Body.Close()
-
-		input := &s3.PutObjectRetentionInput{
-			Bucket: aws.String(bucket),
-			Key:    aws.String(key),
-			Retention: &types.ObjectLockRetention{
-				Mode: types.ObjectLockRetentionModeGovernance, // Parse from body
-				// RetainUntilDate: Parse from body
-			},
-		}
-
-		_, err = h.s3Backend.PutObjectRetention(r.Context(), input)
-		if err != nil {
-			h.errorWriter.WriteS3Error(w, err, bucket, key)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-
-	default:
-		h.errorWriter.WriteGenericError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed for retention")
-	}
-}
-
 // handleObjectTorrent handles object torrent operations
 func (h *Handler) handleObjectTorrent(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
 		"operation": "object-torrent",
 		"bucket":    bucket,
 		"key":       key,
@@ -1075,84 +984,10 @@ func (h *Handler) handleObjectTorrent(w http.ResponseWriter, r *http.Request, bu
 
 	// Copy the torrent data
 	w.WriteHeader(http.StatusOK)
-	_, err = copyWithPooledBuffer(w, output.Body)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to copy torrent data")
-	}
-}
-
-// handleSelectObjectContent handles S3 Select operations
-func (h *Handler) handleSelectObjectContent(w http.ResponseWriter, r *http.Request, bucket, key string) {
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "select-object-content",
-		"bucket":    bucket,
-		"key":       key,
-	}).Debug("Handling select object content (passthrough)")
-
-	// TODO: For encrypted objects, we would need to:
-	// 1. Check if object is encrypted
-	// 2. If encrypted, decrypt first then apply select
-	// 3. For now, this is a simple passthrough
-
-	// For true passthrough mode, we should forward the entire HTTP request to the backend
-	// For now, we'll create a minimal valid input to avoid validation errors
-	// In a real implementation, we would parse the request body to extract all parameters
-
-	// Get query parameters that might contain select parameters
-	queryParams := r.URL.Query()
-	expression := queryParams.Get("expression")
-	if expression == "" {
-		expression = "SELECT * FROM S3Object" // Default fallback
-	}
-
-	input := &s3.SelectObjectContentInput{
-		Bucket:         aws.String(bucket),
-		Key:            aws.String(key),
-		Expression:     aws.String(expression),
-		ExpressionType: types.ExpressionTypeSql,
-		InputSerialization: &types.InputSerialization{
-			CompressionType: types.CompressionTypeNone,
-			CSV: &types.CSVInput{
-				FileHeaderInfo: types.FileHeaderInfoUse,
-			},
-		},
-		OutputSerialization: &types.OutputSerialization{
-			CSV: &types.CSVOutput{},
-		},
-	}
-
-	output, err := h.s3Backend.SelectObjectContent(r.Context(), input)
+	_, err = h.copyResponse(w, output.Body)
 	if err != nil {
-		h.errorWriter.WriteS3Error(w, err, bucket, key)
-		return
+		h.logger.WithContext(r.Context()).WithError(err).Error("Failed to copy torrent data")
 	}
-
-	// For EventStream handling in passthrough mode, we'll simply forward the response
-	// In a real implementation with encryption, we'd need to handle the event stream properly
-	eventStream := output.GetStream()
-	defer eventStream.Close()
-
-	// Stream the select results
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	// Simplified event processing - just forward events as-is
-	// TODO: Implement proper event handling for encrypted objects
-	for event := range eventStream.Events() {
-		// In a real implementation, we would parse event types and handle accordingly
-		// For now, this is a placeholder to ensure compilation
-		_ = event // Use the event variable to avoid "unused" error
-	}
-
-	if err := eventStream.Err(); err != nil {
-		h.logger.WithError(err).Error("Error in select object content event stream")
-	}
-
-	h.logger.WithFields(map[string]interface{}{
-		"operation": "select-object-content",
-		"bucket":    bucket,
-		"key":       key,
-	}).Debug("Select object content completed (simplified passthrough)")
 }
 
 // isHMACEnabled returns true when the configuration requires HMAC to be written on upload.
@@ -1171,17 +1006,19 @@ func (h *Handler) isHMACEnabled() bool {
 // via a self-copy (CopyObject with MetadataDirective=REPLACE).
 //
 // The lifecycle mirrors internal/proxy/handlers/multipart/: Create → UploadParts → Complete →
-// CopyObject-self-copy to attach HMAC metadata.
-func (h *Handler) putObjectAutoMultipart(w http.ResponseWriter, r *http.Request, bucket, key, contentType string) {
+// CopyObject-self-copy to attach HMAC metadata. plaintextLen is the announced body size, or
+// -1 when unknown; it only serves to pick a part size that stays within 10000 parts.
+func (h *Handler) putObjectAutoMultipart(w http.ResponseWriter, r *http.Request, bucket, key, contentType string, plaintextLen int64, checksum *requestChecksum) {
 	ctx := r.Context()
-	partSize := h.getSegmentSize() // configured segment size, default 12 MiB
+	partSize := h.autoMultipartPartSize(plaintextLen) // configured segment size, default 12 MiB
 
-	log := h.logger.WithFields(map[string]interface{}{
-		"bucket":    bucket,
-		"key":       key,
-		"part_size": partSize,
+	log := h.logger.WithContext(ctx).WithFields(map[string]interface{}{
+		"bucket":         bucket,
+		"key":            key,
+		"part_size":      partSize,
+		"content_length": plaintextLen,
 	})
-	log.Debug("Starting auto-multipart upload for HMAC-enabled large object")
+	log.Debug("Starting auto-multipart upload")
 
 	// 1. Create the S3 multipart upload.
 	createInput := &s3.CreateMultipartUploadInput{
@@ -1200,6 +1037,7 @@ func (h *Handler) putObjectAutoMultipart(w http.ResponseWriter, r *http.Request,
 		}
 	}
 	createInput.Metadata = userMetadata
+	applyObjectLock(r, &createInput.ObjectLockMode, &createInput.ObjectLockRetainUntilDate, &createInput.ObjectLockLegalHoldStatus)
 
 	createOutput, err := h.s3Backend.CreateMultipartUpload(ctx, createInput)
 	if err != nil {
@@ -1229,13 +1067,36 @@ func (h *Handler) putObjectAutoMultipart(w http.ResponseWriter, r *http.Request,
 	if err := h.encryptionMgr.InitiateMultipartUpload(ctx, s3UploadID, key, bucket); err != nil {
 		log.WithError(err).Error("Auto-multipart: failed to initialize encryption session")
 		abortUpload("encryption init failed", err)
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "EncryptionError", "Failed to initialize encryption for upload")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "EncryptionError", "Failed to initialize encryption for upload")
 		return
 	}
 
 	// 3. Stream the request body — do NOT buffer the whole object. The parser returns a
 	//    streaming reader that transparently decodes aws-chunked on the fly.
-	bodyStream := h.requestParser.StreamingReader(r)
+	var bodyStream io.Reader = h.requestParser.StreamingReader(r)
+
+	// The MD5 of the whole body, the ETag of the single PUT the client made. It, the
+	// checksum and the size are those of the body as sent, before any compression.
+	var plaintextHash hash.Hash
+	if h.plaintextETags() {
+		plaintextHash = md5.New() // #nosec G401 - the S3 ETag is an MD5
+		bodyStream = io.TeeReader(bodyStream, plaintextHash)
+	}
+	if checksum != nil {
+		bodyStream = io.TeeReader(bodyStream, checksum)
+	}
+	compressed := h.compressesStream(r, plaintextLen)
+	var sentSize int64
+	if compressed {
+		bodyStream = &sizeCountingReader{ReadCloser: io.NopCloser(bodyStream), onEOF: func(size int64) { sentSize = size }}
+		compressedStream, err := h.compressor.CompressReader(bodyStream)
+		if err != nil {
+			abortUpload("compression init failed", err)
+			h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "UploadError", "Failed to compress upload")
+			return
+		}
+		bodyStream = compressedStream
+	}
 	bufferedBody := bufio.NewReaderSize(bodyStream, 64*1024)
 
 	// A single part-sized buffer is reused across iterations; peak RSS stays near
@@ -1280,7 +1141,7 @@ func (h *Handler) putObjectAutoMultipart(w http.ResponseWriter, r *http.Request,
 					Body:          job.body,
 					ContentLength: aws.Int64(job.cipherLen),
 				}
-				uploadOutput, err := h.s3Backend.UploadPart(uploadCtx, uploadInput)
+				uploadOutput, err := h.uploadPartWithRetry(uploadCtx, uploadInput, log)
 				if err != nil {
 					results <- partUploadResult{partNumber: job.partNumber, err: err}
 					continue
@@ -1400,7 +1261,7 @@ producerLoop:
 
 	if producerErr != nil {
 		abortUpload("producer failed", producerErr)
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "UploadError", producerErr.Error())
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "UploadError", producerErr.Error())
 		return
 	}
 	if firstUploadErr != nil {
@@ -1408,6 +1269,14 @@ producerLoop:
 		h.errorWriter.WriteS3Error(w, firstUploadErr, bucket, key)
 		return
 	}
+	// The whole body was read, so a trailing checksum has arrived as well
+	if checksum != nil {
+		if err := checksum.verify(r); err != nil {
+			abortUpload("checksum mismatch", err)
+			h.writeChecksumError(w, r, err)
+			return
+		}
+	}
 
 	// CompleteMultipartUpload requires parts in ascending PartNumber order.
 	partNums := make([]int, 0, len(partsMap))
@@ -1427,11 +1296,33 @@ producerLoop:
 	finalMetadata, err := h.encryptionMgr.CompleteMultipartUpload(ctx, s3UploadID, partsMap)
 	if err != nil {
 		abortUpload("encryption finalize failed", err)
-		h.errorWriter.WriteGenericError(w, http.StatusInternalServerError, "EncryptionError", "Failed to finalize encryption metadata")
+		h.errorWriter.WriteError(w, r, http.StatusInternalServerError, "EncryptionError", "Failed to finalize encryption metadata")
 		return
 	}
 
-	// 6. Complete the S3 multipart upload (no metadata here — S3 ignores metadata on Complete).
+	// Merge user metadata into the encryption metadata map: whichever phase attaches
+	// it, the final object carries both.
+	var mergedMetadata map[string]string
+	if len(finalMetadata) > 0 {
+		mergedMetadata = make(map[string]string, len(finalMetadata)+len(userMetadata))
+		for k, v := range userMetadata {
+			mergedMetadata[k] = v
+		}
+		for k, v := range finalMetadata {
+			mergedMetadata[k] = v
+		}
+		if plaintextHash != nil {
+			h.recordPlaintextETag(mergedMetadata, plaintextHash.Sum(nil))
+		}
+		h.recordChecksum(mergedMetadata, checksum)
+		if compressed {
+			h.compressedStreamMetadata(mergedMetadata, sentSize)
+		}
+	}
+	profile := h.backendProfile()
+
+	// 6. Complete the S3 multipart upload. Metadata is only sent here for backends
+	//    whose profile attaches it in the complete phase.
 	completeInput := &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(bucket),
 		Key:      aws.String(key),
@@ -1440,7 +1331,8 @@ producerLoop:
 			Parts: completedParts,
 		},
 	}
-	completeOutput, err := h.s3Backend.CompleteMultipartUpload(ctx, completeInput)
+	applyConditions(r, &completeInput.IfMatch, &completeInput.IfNoneMatch)
+	completeOutput, err := h.s3Backend.CompleteMultipartUpload(ctx, completeInput, utils.CompleteMultipartMetadataOptions(profile, mergedMetadata)...)
 	if err != nil {
 		// S3 multipart is already committed at this point if Complete succeeded partially,
 		// but on error we can still attempt abort.
@@ -1450,36 +1342,20 @@ producerLoop:
 	}
 	finalETag := aws.ToString(completeOutput.ETag)
 
-	// 7. Attach encryption metadata (including HMAC) via a self-copy.
-	// S3 does not propagate metadata from CreateMultipartUpload to the completed object, and
-	// CompleteMultipartUpload does not accept a Metadata field. The established pattern
-	// (mirrored from internal/proxy/handlers/multipart/complete.go:223–244) is a CopyObject
-	// call with MetadataDirective=REPLACE on the just-completed object.
-	if len(finalMetadata) > 0 {
-		// Merge user metadata into the encryption metadata map for the self-copy.
-		mergedMetadata := make(map[string]string, len(finalMetadata)+len(userMetadata))
-		for k, v := range userMetadata {
-			mergedMetadata[k] = v
-		}
-		for k, v := range finalMetadata {
-			mergedMetadata[k] = v
-		}
-
-		copyInput := &s3.CopyObjectInput{
-			Bucket:            aws.String(bucket),
-			Key:               aws.String(key),
-			CopySource:        aws.String(fmt.Sprintf("%s/%s", bucket, key)),
-			Metadata:          mergedMetadata,
-			MetadataDirective: types.MetadataDirectiveReplace,
-		}
-		if _, err := h.s3Backend.CopyObject(ctx, copyInput); err != nil {
+	// 7. Make sure the encryption metadata (including HMAC) is on the final object.
+	// S3 does not propagate metadata from CreateMultipartUpload to the completed object, so
+	// it is either verified after the complete phase or re-applied via a self-copy (shared
+	// with internal/proxy/handlers/multipart/complete.go).
+	if len(mergedMetadata) > 0 {
+		lock, _ := request.ParseObjectLock(r)
+		if err := utils.EnsureMultipartObjectMetadata(ctx, h.s3Backend, profile, bucket, key, mergedMetadata, log, utils.WithObjectLock(lock)); err != nil {
 			// The object is stored but the metadata is missing — without it decryption is
 			// impossible. Return an error so the client knows the upload effectively failed.
-			log.WithError(err).Error("Auto-multipart: failed to attach encryption metadata via self-copy")
-			h.errorWriter.WriteS3Error(w, fmt.Errorf("upload completed but encryption metadata could not be applied: %w", err), bucket, key)
+			log.WithError(err).Error("Auto-multipart: failed to attach encryption metadata")
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
 			return
 		}
-		log.Debug("Auto-multipart: encryption metadata attached via self-copy")
+		log.Debug("Auto-multipart: encryption metadata attached")
 	}
 
 	// 8. Clean up the encryption session.
@@ -1494,7 +1370,9 @@ producerLoop:
 		"metadata_entries": len(finalMetadata),
 	}).Debug("Auto-multipart upload completed successfully")
 
-	w.Header().Set("ETag", finalETag)
+	w.Header().Set("ETag", aws.ToString(h.responseETag(mergedMetadata, aws.String(finalETag))))
+	checksum.setResponseHeader(w)
+	h.setEmulatedSSEHeaders(w, mergedMetadata)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -0,0 +1,96 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// sizedReadingBackend reads the Content-Length of a PutObject body and no
+// further, like the S3 client, and counts the bodies it received all of
+type sizedReadingBackend struct {
+	*MockS3Backend
+	stored int
+}
+
+func (b *sizedReadingBackend) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if _, err := io.CopyN(io.Discard, input.Body, aws.ToInt64(input.ContentLength)); err != nil {
+		return nil, err
+	}
+	b.stored++
+	return &s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil
+}
+
+// putStreamedWithPayloadHash PUTs body over the streaming single-part path,
+// checked against payloadHash as the authentication middleware does, and
+// returns whether the backend received all of it
+func putStreamedWithPayloadHash(t *testing.T, body []byte, payloadHash string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	prefix := "s3ep-"
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes",
+			MetadataKeyPrefix:     &prefix,
+			// Without HMAC the body is streamed to the backend, not read
+			// into memory to compute it first
+			IntegrityVerification: config.HMACVerificationOff,
+			Providers: []config.EncryptionProvider{{
+				Alias:  "test-aes",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+		Optimizations: config.OptimizationsConfig{StreamingThreshold: 1024},
+	}
+	encMgr, err := orchestration.NewManager(cfg)
+	require.NoError(t, err)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	backend := &sizedReadingBackend{MockS3Backend: new(MockS3Backend)}
+	handler := NewHandler(backend, encMgr, cfg, logger.WithField("component", "object-handler"))
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(body))
+	hashed, err := request.NewPayloadHashReader(req.Body, payloadHash)
+	require.NoError(t, err)
+	req.Body = hashed
+
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "key")
+	return rr, backend.stored == 1
+}
+
+func TestStreamingPut_PayloadHashMismatch(t *testing.T) {
+	body := bytes.Repeat([]byte("streamed body "), 1024)
+
+	rr, stored := putStreamedWithPayloadHash(t, body, strings.Repeat("ab", 32))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Code>XAmzContentSHA256Mismatch</Code>")
+	assert.False(t, stored, "the backend must not receive the whole body")
+}
+
+func TestStreamingPut_PayloadHashMatch(t *testing.T) {
+	body := bytes.Repeat([]byte("streamed body "), 1024)
+	sum := sha256.Sum256(body)
+
+	rr, stored := putStreamedWithPayloadHash(t, body, hex.EncodeToString(sum[:]))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, stored)
+}
@@ -0,0 +1,152 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/s3select"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// handleSelectObjectContent runs S3 Select in the proxy. The backend only
+// holds ciphertext, so the object is decrypted here and the expression is
+// evaluated over its plaintext.
+func (h *Handler) handleSelectObjectContent(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"operation": "select-object-content",
+		"bucket":    bucket,
+		"key":       key,
+	}).Debug("Handling select object content")
+
+	body, err := h.requestParser.ReadXMLBody(r, s3select.MaxRequestSize)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+	var selectRequest s3select.Request
+	if err := request.DecodeXML(bytes.NewReader(body), "SelectObjectContentRequest", &selectRequest, request.XMLLimits{}); err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+	query, err := s3select.Compile(&selectRequest)
+	if err != nil {
+		var selectErr *s3select.Error
+		if errors.As(err, &selectErr) {
+			h.errorWriter.WriteError(w, r, selectErr.StatusCode, selectErr.Code, selectErr.Message)
+		} else {
+			h.errorWriter.WriteS3Error(w, err, bucket, key)
+		}
+		return
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	applySSEC(r, &input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+	output, err := h.s3Backend.GetObject(r.Context(), input)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+	defer output.Body.Close()
+
+	plaintext, err := h.plaintextBody(r.Context(), output, key)
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).WithField("key", key).Error("Failed to decrypt object for select")
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+	defer plaintext.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if err := query.Run(r.Context(), plaintext, w); err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).WithField("key", key).Warn("Failed to stream select results")
+	}
+}
+
+// plaintextBody returns the decrypted, decompressed body of an object, as
+// GET would send it. Integrity failures surface as read errors before EOF.
+func (h *Handler) plaintextBody(ctx context.Context, output *s3.GetObjectOutput, key string) (io.ReadCloser, error) {
+	// SSE-C objects are encrypted by the backend; the body is already plaintext
+	if h.isSSECObject(output.Metadata) {
+		return h.verifyChecksum(output.Body, output.Metadata), nil
+	}
+	encryptedDEKB64, hasEncryption, _ := h.extractEncryptionMetadata(output.Metadata)
+	if !hasEncryption {
+		return output.Body, nil
+	}
+	if err := h.encryptionMgr.CheckEncryptionContext(ctx, output.Metadata); err != nil {
+		return nil, err
+	}
+
+	dekAlgorithm := "aes-gcm" // Default fallback for legacy objects
+	if value, exists := output.Metadata[h.metadataPrefix+"dek-algorithm"]; exists {
+		dekAlgorithm = value
+	}
+
+	var plaintext io.ReadCloser
+	if encryption.IsStreamingAlgorithm(dekAlgorithm) {
+		encryptedDEK, err := h.decodeEncryptedDEK(encryptedDEKB64)
+		if err != nil {
+			return nil, err
+		}
+		contentLength := int64(-1)
+		if output.ContentLength != nil {
+			contentLength = aws.ToInt64(output.ContentLength)
+		}
+		reader, err := h.encryptionMgr.CreateStreamingDecryptionReaderWithSize(ctx, output.Body, encryptedDEK, output.Metadata, key, "", contentLength)
+		if err != nil {
+			return nil, err
+		}
+		// The HMAC is verified on Close, which must happen before EOF is
+		// reported so that no End event follows tampered data
+		plaintext = &closeAtEOFReader{ReadCloser: reader}
+	} else {
+		reader, err := h.encryptionMgr.DecryptDataWithMetadata(ctx, output.Body, output.Metadata, key)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = reader
+	}
+
+	plaintext, err := h.decompressBody(plaintext, output.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return h.verifyChecksum(plaintext, output.Metadata), nil
+}
+
+// closeAtEOFReader closes its reader when it reaches EOF and reports a
+// failed Close in place of the EOF
+type closeAtEOFReader struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (c *closeAtEOFReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) && !c.closed {
+		c.closed = true
+		if closeErr := c.ReadCloser.Close(); closeErr != nil {
+			return n, closeErr
+		}
+	}
+	return n, err
+}
+
+func (c *closeAtEOFReader) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,128 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const selectCSVRequest = `<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Expression>SELECT s.name FROM S3Object s WHERE CAST(s.qty AS INT) &gt; 1</Expression>
+  <ExpressionType>SQL</ExpressionType>
+  <InputSerialization><CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV></InputSerialization>
+  <OutputSerialization><CSV/></OutputSerialization>
+</SelectObjectContentRequest>`
+
+func selectRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/bucket/legacy.bin?select&select-type=2", strings.NewReader(body))
+	return mux.SetURLVars(req, map[string]string{"bucket": "bucket", "key": "legacy.bin"})
+}
+
+// selectRecords decodes the event stream and returns the records and the
+// type of the last message
+func selectRecords(t *testing.T, body []byte) (string, string) {
+	t.Helper()
+	var records, last string
+	decoder := eventstream.NewDecoder()
+	reader := bytes.NewReader(body)
+	for {
+		msg, err := decoder.Decode(reader, nil)
+		if errors.Is(err, io.EOF) {
+			return records, last
+		}
+		require.NoError(t, err)
+		if msg.Headers.Get(":message-type").String() == "error" {
+			last = "error:" + msg.Headers.Get(":error-code").String()
+			continue
+		}
+		last = msg.Headers.Get(":event-type").String()
+		if last == "Records" {
+			records += string(msg.Payload)
+		}
+	}
+}
+
+func TestSelectObjectContent_EncryptedObject(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, false)
+	output := gcmObject(t, encMgr, []byte("name,qty\napple,3\npear,1\nplum,2\n"))
+	backend.On("GetObject", mock.Anything, mock.MatchedBy(func(in *s3.GetObjectInput) bool {
+		return *in.Key == "legacy.bin"
+	})).Return(output, nil)
+
+	rr := httptest.NewRecorder()
+	handler.HandleSelectObjectContent(rr, selectRequest(selectCSVRequest))
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	records, last := selectRecords(t, rr.Body.Bytes())
+	assert.Equal(t, "apple\nplum\n", records)
+	assert.Equal(t, "End", last)
+	backend.AssertNotCalled(t, "SelectObjectContent", mock.Anything, mock.Anything)
+}
+
+func TestSelectObjectContent_PlainObject(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader("name,qty\nfig,5\n")),
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.HandleSelectObjectContent(rr, selectRequest(selectCSVRequest))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	records, last := selectRecords(t, rr.Body.Bytes())
+	assert.Equal(t, "fig\n", records)
+	assert.Equal(t, "End", last)
+}
+
+func TestSelectObjectContent_TamperedObject(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, false)
+	output := gcmObject(t, encMgr, []byte("name,qty\napple,3\n"))
+	ciphertext, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	output.Body = io.NopCloser(bytes.NewReader(ciphertext))
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(output, nil)
+
+	rr := httptest.NewRecorder()
+	handler.HandleSelectObjectContent(rr, selectRequest(selectCSVRequest))
+
+	// Small GCM objects are authenticated before the response starts
+	assert.NotEqual(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "apple")
+}
+
+func TestSelectObjectContent_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"syntax error", strings.Replace(selectCSVRequest, "SELECT s.name", "SELECT FROM", 1), http.StatusBadRequest, "ParseUnexpectedToken"},
+		{"parquet", strings.Replace(selectCSVRequest, "<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>", "<Parquet/>", 1), http.StatusNotImplemented, "NotImplemented"},
+		{"malformed XML", "<SelectObjectContentRequest>", http.StatusBadRequest, "MalformedXML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, backend, _ := newBackfillTestHandler(t, false)
+
+			rr := httptest.NewRecorder()
+			handler.HandleSelectObjectContent(rr, selectRequest(tt.body))
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.code)
+			backend.AssertNotCalled(t, "GetObject", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package object
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// plaintextSizeMetadataKey (with the metadata prefix) stores the plaintext size
+// of objects whose ciphertext size differs from it, so HEAD can report the
+// size a client will actually receive
+const plaintextSizeMetadataKey = "plaintext-size"
+
+// sizeBackfillTimeout bounds the background metadata copy
+const sizeBackfillTimeout = 30 * time.Second
+
+// storedPlaintextSize returns the plaintext size recorded in metadata
+func (h *Handler) storedPlaintextSize(metadata map[string]string) (int64, bool) {
+	value, ok := metadata[h.metadataPrefix+plaintextSizeMetadataKey]
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// recordPlaintextSize stores size in the metadata of a new object encrypted
+// with algorithm if its ciphertext is larger than the plaintext, unless a size
+// is recorded already (compressed objects)
+func (h *Handler) recordPlaintextSize(metadata map[string]string, algorithm string, size int64) {
+	if encryption.ComputeCiphertextSize(0, algorithm) <= 0 {
+		return
+	}
+	if _, ok := metadata[h.metadataPrefix+plaintextSizeMetadataKey]; !ok {
+		metadata[h.metadataPrefix+plaintextSizeMetadataKey] = strconv.FormatInt(size, 10)
+	}
+}
+
+// plaintextContentLength returns the size a client receives for an object of
+// contentLength stored bytes: the recorded plaintext size, else the stored
+// size less the nonces and tags of aes-gcm, aes-gcm-siv and chacha20-poly1305
+// objects, else contentLength
+func (h *Handler) plaintextContentLength(metadata map[string]string, contentLength *int64) *int64 {
+	if size, ok := h.storedPlaintextSize(metadata); ok {
+		return aws.Int64(size)
+	}
+	if contentLength == nil || !h.isProxyEncrypted(metadata) {
+		return contentLength
+	}
+
+	dekAlgorithm, ok := metadata[h.metadataPrefix+"dek-algorithm"]
+	if !ok {
+		dekAlgorithm = "aes-gcm" // legacy objects, as on GET
+	}
+	segmentSize, _ := strconv.ParseInt(metadata[h.metadataPrefix+"segment-size"], 10, 64)
+	if size := encryption.ComputePlaintextSize(*contentLength, dekAlgorithm, segmentSize); size >= 0 {
+		return aws.Int64(size)
+	}
+	return contentLength
+}
+
+// shouldBackfillPlaintextSize reports whether a GET of this object should
+// record its plaintext size once the object has been fully decrypted. Objects
+// in versioned buckets are left alone: the self-copy would add a version.
+func (h *Handler) shouldBackfillPlaintextSize(output *s3.GetObjectOutput) bool {
+	if h.config == nil || !h.config.Encryption.PlaintextSizeBackfill || output.ContentRange != nil || isObjectVersion(output.VersionId) {
+		return false
+	}
+	_, stored := h.storedPlaintextSize(output.Metadata)
+	return !stored
+}
+
+// withPlaintextSizeBackfill wraps the decrypted body of a GET. Once the body
+// has been read to EOF without error, the number of plaintext bytes is written
+// back to the object metadata in the background.
+func (h *Handler) withPlaintextSizeBackfill(ctx context.Context, body io.ReadCloser, output *s3.GetObjectOutput, bucket, key string) io.ReadCloser {
+	return &sizeCountingReader{
+		ReadCloser: body,
+		onEOF: func(size int64) {
+			go h.backfillPlaintextSize(context.WithoutCancel(ctx), output, bucket, key, size)
+		},
+	}
+}
+
+// backfillPlaintextSize stores size in the object metadata using a
+// metadata-only self-copy. The copy is conditional on the ETag that was read,
+// so an object overwritten in the meantime is left alone.
+func (h *Handler) backfillPlaintextSize(ctx context.Context, output *s3.GetObjectOutput, bucket, key string, size int64) {
+	if _, loaded := h.sizeBackfills.LoadOrStore(bucket+"/"+key, struct{}{}); loaded {
+		return
+	}
+	defer h.sizeBackfills.Delete(bucket + "/" + key)
+
+	ctx, cancel := context.WithTimeout(ctx, sizeBackfillTimeout)
+	defer cancel()
+
+	metadata := make(map[string]string, len(output.Metadata)+1)
+	for k, v := range output.Metadata {
+		metadata[k] = v
+	}
+	metadata[h.metadataPrefix+plaintextSizeMetadataKey] = strconv.FormatInt(size, 10)
+
+	// REPLACE drops every attribute that is not sent again
+	input := &s3.CopyObjectInput{
+		Bucket:                  aws.String(bucket),
+		Key:                     aws.String(key),
+		CopySource:              aws.String(bucket + "/" + url.PathEscape(key)),
+		CopySourceIfMatch:       output.ETag,
+		Metadata:                metadata,
+		MetadataDirective:       types.MetadataDirectiveReplace,
+		ContentType:             output.ContentType,
+		CacheControl:            output.CacheControl,
+		ContentDisposition:      output.ContentDisposition,
+		ContentEncoding:         output.ContentEncoding,
+		ContentLanguage:         output.ContentLanguage,
+		WebsiteRedirectLocation: output.WebsiteRedirectLocation,
+	}
+	if output.StorageClass != "" {
+		input.StorageClass = types.StorageClass(output.StorageClass)
+	}
+
+	log := h.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"bucket":         bucket,
+		"key":            key,
+		"plaintext_size": size,
+	})
+	if _, err := h.s3Backend.CopyObject(ctx, input); err != nil {
+		log.WithError(err).Warn("Failed to backfill plaintext size metadata")
+		return
+	}
+	log.Debug("Backfilled plaintext size metadata")
+}
+
+// sizeCountingReader counts the bytes read and calls onEOF once when the
+// underlying reader reports io.EOF
+type sizeCountingReader struct {
+	io.ReadCloser
+	n     int64
+	onEOF func(size int64)
+}
+
+func (r *sizeCountingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if err == io.EOF && r.onEOF != nil {
+		r.onEOF(r.n)
+		r.onEOF = nil
+	}
+	return n, err
+}
@@ -0,0 +1,223 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+func newBackfillTestHandler(t *testing.T, backfill bool) (*Handler, *MockS3Backend, *orchestration.Manager) {
+	t.Helper()
+	prefix := "s3ep-"
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "test-aes",
+			MetadataKeyPrefix:     &prefix,
+			PlaintextSizeBackfill: backfill,
+			Providers: []config.EncryptionProvider{
+				{
+					Alias: "test-aes",
+					Type:  "aes",
+					Config: map[string]interface{}{
+						"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY=",
+					},
+				},
+			},
+		},
+	}
+	encMgr, err := orchestration.NewManager(cfg)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	backend := new(MockS3Backend)
+	return NewHandler(backend, encMgr, cfg, logger.WithField("component", "object-handler")), backend, encMgr
+}
+
+// gcmObject returns a GetObjectOutput for plaintext encrypted as a whole (AES-GCM) object
+func gcmObject(t *testing.T, encMgr *orchestration.Manager, plaintext []byte) *s3.GetObjectOutput {
+	t.Helper()
+	result, err := encMgr.EncryptDataWithContentType(context.Background(), bufio.NewReader(bytes.NewReader(plaintext)), "legacy.bin", factory.ContentTypeWhole)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	require.Equal(t, "aes-gcm", result.Metadata["s3ep-dek-algorithm"])
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(ciphertext)),
+		ContentLength: aws.Int64(int64(len(ciphertext))),
+		ContentType:   aws.String("application/octet-stream"),
+		ETag:          aws.String(`"etag-1"`),
+		Metadata:      result.Metadata,
+		StorageClass:  types.StorageClassStandardIa,
+	}
+}
+
+func TestGetObject_BackfillsPlaintextSize(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, true)
+	plaintext := bytes.Repeat([]byte("legacy"), 1000)
+	output := gcmObject(t, encMgr, plaintext)
+
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(output, nil)
+	copied := make(chan *s3.CopyObjectInput, 1)
+	backend.On("CopyObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		copied <- args.Get(1).(*s3.CopyObjectInput)
+	}).Return(&s3.CopyObjectOutput{}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/legacy.bin", nil), "bucket", "legacy.bin")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, plaintext, rr.Body.Bytes())
+
+	select {
+	case input := <-copied:
+		assert.Equal(t, "bucket", aws.ToString(input.Bucket))
+		assert.Equal(t, "bucket/legacy.bin", aws.ToString(input.CopySource))
+		assert.Equal(t, `"etag-1"`, aws.ToString(input.CopySourceIfMatch))
+		assert.Equal(t, types.MetadataDirectiveReplace, input.MetadataDirective)
+		assert.Equal(t, types.StorageClassStandardIa, input.StorageClass)
+		assert.Equal(t, "application/octet-stream", aws.ToString(input.ContentType))
+		assert.Equal(t, strconv.Itoa(len(plaintext)), input.Metadata["s3ep-plaintext-size"])
+		assert.Equal(t, output.Metadata["s3ep-encrypted-dek"], input.Metadata["s3ep-encrypted-dek"], "encryption metadata must be kept")
+	case <-time.After(5 * time.Second):
+		t.Fatal("plaintext size was not backfilled")
+	}
+}
+
+func TestGetObject_NoBackfillWhenDisabledOrStored(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, false)
+	output := gcmObject(t, encMgr, []byte("data"))
+	assert.False(t, handler.shouldBackfillPlaintextSize(output))
+
+	handler, _, _ = newBackfillTestHandler(t, true)
+	output.Metadata["s3ep-plaintext-size"] = "4"
+	assert.False(t, handler.shouldBackfillPlaintextSize(output))
+	backend.AssertNotCalled(t, "CopyObject", mock.Anything, mock.Anything)
+}
+
+func TestSizeCountingReader_OnlyCompletesOnEOF(t *testing.T) {
+	var reported []int64
+	reader := &sizeCountingReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(make([]byte, 100))),
+		onEOF:      func(size int64) { reported = append(reported, size) },
+	}
+
+	_, err := reader.Read(make([]byte, 60))
+	require.NoError(t, err)
+	assert.Empty(t, reported, "partial reads must not report a size")
+
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	_, _ = reader.Read(make([]byte, 1))
+	assert.Equal(t, []int64{100}, reported)
+}
+
+func TestHeadObject_ReportsStoredPlaintextSize(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, true)
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(6028),
+		Metadata: map[string]string{
+			"s3ep-encrypted-dek":  "ZGVr",
+			"s3ep-plaintext-size": "6000",
+		},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/legacy.bin", nil), "bucket", "legacy.bin")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "6000", rr.Header().Get("Content-Length"))
+	assert.Empty(t, rr.Header().Get("x-amz-meta-s3ep-plaintext-size"))
+}
+
+func TestHeadObject_DerivesPlaintextSize(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	head := func(contentLength int64, metadata map[string]string) http.Header {
+		call := backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+			ContentLength: aws.Int64(contentLength),
+			Metadata:      metadata,
+		}, nil).Once()
+		defer call.Unset()
+		rr := httptest.NewRecorder()
+		handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header()
+	}
+
+	// Legacy objects without a stored size, with and without dek-algorithm
+	for _, metadata := range []map[string]string{
+		{"s3ep-encrypted-dek": "ZGVr", "s3ep-dek-algorithm": "aes-gcm"},
+		{"s3ep-encrypted-dek": "ZGVr", "s3ep-dek-algorithm": "chacha20-poly1305"},
+		{"s3ep-encrypted-dek": "ZGVr"},
+	} {
+		header := head(6028, metadata)
+		assert.Equal(t, "6000", header.Get("Content-Length"))
+		assert.Equal(t, "none", header.Get("Accept-Ranges"))
+	}
+
+	// Segmented objects carry a tag per segment
+	segmented := encryption.ComputeCiphertextSize(5<<20, "aes-gcm")
+	assert.Equal(t, strconv.Itoa(5<<20), head(segmented, map[string]string{"s3ep-encrypted-dek": "ZGVr", "s3ep-dek-algorithm": "aes-gcm", "s3ep-segment-size": "65536"}).Get("Content-Length"))
+
+	// Stream ciphers add no overhead
+	assert.Equal(t, "6028", head(6028, map[string]string{"s3ep-encrypted-dek": "ZGVr", "s3ep-dek-algorithm": "aes-ctr"}).Get("Content-Length"))
+
+	// Unencrypted and SSE-C objects report the stored size and leave
+	// Accept-Ranges to the middleware
+	for _, metadata := range []map[string]string{nil, {"s3ep-sse-c": "passthrough", "s3ep-encrypted-dek": "Zm9yZ2Vk"}} {
+		header := head(6028, metadata)
+		assert.Equal(t, "6028", header.Get("Content-Length"))
+		assert.Empty(t, header.Get("Accept-Ranges"))
+	}
+}
+
+func TestPutObject_RecordsPlaintextSize(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Optimizations.StreamingThreshold = 5 * 1024 * 1024 // encrypt in memory with AES-GCM
+	plaintext := bytes.Repeat([]byte("x"), 5000)
+
+	var stored []byte
+	var metadata map[string]string
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		stored, _ = io.ReadAll(input.Body)
+		metadata = input.Metadata
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader(plaintext)), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "aes-gcm", metadata["s3ep-dek-algorithm"])
+	assert.Len(t, stored, len(plaintext)+28)
+	assert.Equal(t, "5000", metadata["s3ep-plaintext-size"])
+
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(stored)),
+		ContentLength: aws.Int64(int64(len(stored))),
+		Metadata:      metadata,
+	}, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/key", nil), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "5000", rr.Header().Get("Content-Length"))
+	assert.Equal(t, "none", rr.Header().Get("Accept-Ranges"))
+	assert.Equal(t, plaintext, rr.Body.Bytes())
+}
@@ -0,0 +1,33 @@
+package object
+
+import (
+	"net/http"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// Server-side encryption response headers reported by S3
+const (
+	sseHeader         = "x-amz-server-side-encryption"
+	sseKMSKeyIDHeader = "x-amz-server-side-encryption-aws-kms-key-id"
+)
+
+// setEmulatedSSEHeaders reports the configured server-side encryption for an
+// object the proxy encrypted. metadata is the backend metadata of the object,
+// before it is cleaned; objects without a wrapped DEK (none provider, SSE-C
+// passthrough) get no headers.
+func (h *Handler) setEmulatedSSEHeaders(w http.ResponseWriter, metadata map[string]string) {
+	sse := h.config.Encryption.SSEHeaders
+	if !sse.Enabled || !h.isProxyEncrypted(metadata) {
+		return
+	}
+
+	algorithm := sse.Algorithm
+	if algorithm == "" {
+		algorithm = config.SSEHeaderAlgorithmKMS
+	}
+	w.Header().Set(sseHeader, algorithm)
+	if algorithm == config.SSEHeaderAlgorithmKMS {
+		w.Header().Set(sseKMSKeyIDHeader, sse.KMSKeyID)
+	}
+}
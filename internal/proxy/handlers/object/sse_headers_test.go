@@ -0,0 +1,92 @@
+package object
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+const testVirtualKeyID = "arn:aws:kms:eu-central-1:111122223333:key/s3ep-virtual"
+
+func TestEmulatedSSEHeaders_PutGetHead(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Encryption.SSEHeaders = config.SSEHeaderEmulationConfig{
+		Enabled:   true,
+		Algorithm: config.SSEHeaderAlgorithmKMS,
+		KMSKeyID:  testVirtualKeyID,
+	}
+
+	var stored []byte
+	var metadata map[string]string
+	backend.On("PutObject", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		stored, _ = io.ReadAll(input.Body)
+		metadata = input.Metadata
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, httptest.NewRequest(http.MethodPut, "/bucket/key", bytes.NewReader([]byte("secret data"))), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "aws:kms", rr.Header().Get(sseHeader))
+	assert.Equal(t, testVirtualKeyID, rr.Header().Get(sseKMSKeyIDHeader))
+
+	backend.On("GetObject", mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(stored)),
+		ContentLength: aws.Int64(int64(len(stored))),
+		Metadata:      metadata,
+	}, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/key", nil), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "secret data", rr.Body.String())
+	assert.Equal(t, "aws:kms", rr.Header().Get(sseHeader))
+	assert.Equal(t, testVirtualKeyID, rr.Header().Get(sseKMSKeyIDHeader))
+
+	backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(stored))),
+		Metadata:      metadata,
+	}, nil).Once()
+	rr = httptest.NewRecorder()
+	handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "aws:kms", rr.Header().Get(sseHeader))
+	assert.Equal(t, testVirtualKeyID, rr.Header().Get(sseKMSKeyIDHeader))
+	backend.AssertExpectations(t)
+}
+
+func TestEmulatedSSEHeaders_OnlyForProxyEncryptedObjects(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	handler.config.Encryption.SSEHeaders = config.SSEHeaderEmulationConfig{Enabled: true, Algorithm: config.SSEHeaderAlgorithmAES256}
+
+	head := func(metadata map[string]string) http.Header {
+		call := backend.On("HeadObject", mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{Metadata: metadata}, nil).Once()
+		defer call.Unset()
+		rr := httptest.NewRecorder()
+		handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key", nil), "bucket", "key")
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Header()
+	}
+
+	header := head(map[string]string{"s3ep-encrypted-dek": "ZGVr", "s3ep-dek-algorithm": "aes-gcm"})
+	assert.Equal(t, "AES256", header.Get(sseHeader))
+	assert.Empty(t, header.Get(sseKMSKeyIDHeader))
+
+	// Stored unencrypted, e.g. by the none provider or before the proxy
+	assert.Empty(t, head(map[string]string{"owner": "alice"}).Get(sseHeader))
+
+	// SSE-C passthrough objects are encrypted by the backend, not the proxy
+	assert.Empty(t, head(map[string]string{"s3ep-sse-c": "passthrough", "s3ep-encrypted-dek": "Zm9yZ2Vk"}).Get(sseHeader))
+
+	handler.config.Encryption.SSEHeaders.Enabled = false
+	assert.Empty(t, head(map[string]string{"s3ep-encrypted-dek": "ZGVr"}).Get(sseHeader))
+}
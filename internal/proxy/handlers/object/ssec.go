@@ -0,0 +1,102 @@
+package object
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// ssecMetadataKey marks objects stored with SSE-C passthrough. GETs of these
+// objects are never decrypted by the proxy, whatever else their metadata says.
+const ssecMetadataKey = "sse-c"
+
+// isSSECObject reports whether the object was stored with SSE-C passthrough
+func (h *Handler) isSSECObject(metadata map[string]string) bool {
+	return metadata[h.metadataPrefix+ssecMetadataKey] == config.SSECModePassthrough
+}
+
+// putObjectSSEC stores the request body untouched with the client's SSE-C
+// key; the backend encrypts it. Only reached in passthrough mode, the SSE-C
+// middleware rejects these requests otherwise.
+func (h *Handler) putObjectSSEC(w http.ResponseWriter, r *http.Request, bucket, key string, sse request.SSEC) {
+	contentLength := h.requestParser.DecodedContentLength(r)
+	if contentLength < 0 {
+		h.errorWriter.WriteError(w, r, http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header.")
+		return
+	}
+
+	metadata := map[string]string{h.metadataPrefix + ssecMetadataKey: config.SSECModePassthrough}
+	for name, values := range r.Header {
+		if len(values) > 0 && strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			metaKey := strings.ToLower(name[len("x-amz-meta-"):])
+			if !h.isEncryptionMetadata(metaKey) {
+				metadata[metaKey] = values[0]
+			}
+		}
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 h.requestParser.StreamingReader(r),
+		ContentLength:        aws.Int64(contentLength),
+		Metadata:             metadata,
+		SSECustomerAlgorithm: aws.String(sse.Algorithm),
+		SSECustomerKey:       aws.String(sse.Key),
+		SSECustomerKeyMD5:    aws.String(sse.KeyMD5),
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		input.ContentMD5 = aws.String(contentMD5)
+	}
+	h.addRequestHeaders(r, input)
+	applyConditions(r, &input.IfMatch, &input.IfNoneMatch)
+	applyObjectLock(r, &input.ObjectLockMode, &input.ObjectLockRetainUntilDate, &input.ObjectLockLegalHoldStatus)
+
+	output, err := h.s3Backend.PutObject(r.Context(), input)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	h.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+		"bucket": bucket,
+		"key":    key,
+		"size":   contentLength,
+	}).Debug("Stored SSE-C object without proxy encryption")
+
+	if output.ETag != nil {
+		w.Header().Set("ETag", *output.ETag)
+	}
+	setVersionIDHeader(w, output.VersionId)
+	setSSECResponseHeaders(w, output.SSECustomerAlgorithm, output.SSECustomerKeyMD5)
+	w.WriteHeader(http.StatusOK)
+}
+
+// applySSEC forwards the SSE-C headers of r to a GET or HEAD
+func applySSEC(r *http.Request, algorithm, key, keyMD5 **string) {
+	sse, ok := request.ParseSSEC(r)
+	if !ok {
+		return
+	}
+	*algorithm = aws.String(sse.Algorithm)
+	*key = aws.String(sse.Key)
+	*keyMD5 = aws.String(sse.KeyMD5)
+}
+
+// setSSECResponseHeaders echoes the SSE-C algorithm and key MD5 like S3
+func setSSECResponseHeaders(w http.ResponseWriter, algorithm, keyMD5 *string) {
+	if algorithm != nil {
+		w.Header().Set(request.SSECAlgorithmHeader, *algorithm)
+	}
+	if keyMD5 != nil {
+		w.Header().Set(request.SSECKeyMD5Header, *keyMD5)
+	}
+}
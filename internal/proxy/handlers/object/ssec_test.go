@@ -0,0 +1,75 @@
+package object
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+func setSSECHeaders(req *http.Request) {
+	req.Header.Set(request.SSECAlgorithmHeader, "AES256")
+	req.Header.Set(request.SSECKeyHeader, "a2V5")
+	req.Header.Set(request.SSECKeyMD5Header, "bWQ1")
+}
+
+func TestPutObject_SSECPassthrough(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	var stored []byte
+	backend.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return aws.ToString(input.SSECustomerAlgorithm) == "AES256" &&
+			aws.ToString(input.SSECustomerKey) == "a2V5" &&
+			input.Metadata["s3ep-sse-c"] == "passthrough" &&
+			input.Metadata["owner"] == "alice" &&
+			input.Metadata["s3ep-encrypted-dek"] == ""
+	})).Run(func(args mock.Arguments) {
+		stored, _ = io.ReadAll(args.Get(1).(*s3.PutObjectInput).Body)
+	}).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`), SSECustomerAlgorithm: aws.String("AES256")}, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("customer data"))
+	setSSECHeaders(req)
+	req.Header.Set("X-Amz-Meta-Owner", "alice")
+	req.Header.Set("X-Amz-Meta-S3ep-Encrypted-Dek", "forged")
+	rr := httptest.NewRecorder()
+	handler.handlePutObject(rr, req, "bucket", "key")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "customer data", string(stored))
+	assert.Equal(t, "AES256", rr.Header().Get(request.SSECAlgorithmHeader))
+	backend.AssertExpectations(t)
+}
+
+func TestGetObject_SSECObjectIsNotDecrypted(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	backend.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return aws.ToString(input.SSECustomerKeyMD5) == "bWQ1"
+	})).Return(&s3.GetObjectOutput{
+		Body:                 io.NopCloser(bytes.NewReader([]byte("customer data"))),
+		ContentLength:        aws.Int64(13),
+		SSECustomerAlgorithm: aws.String("AES256"),
+		// A forged DEK must not make the proxy try to decrypt the object
+		Metadata: map[string]string{"s3ep-sse-c": "passthrough", "s3ep-encrypted-dek": "Zm9yZ2Vk", "owner": "alice"},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	setSSECHeaders(req)
+	rr := httptest.NewRecorder()
+	handler.handleGetObject(rr, req, "bucket", "key")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "customer data", rr.Body.String())
+	assert.Equal(t, "alice", rr.Header().Get("X-Amz-Meta-Owner"))
+	assert.Empty(t, rr.Header().Get("X-Amz-Meta-S3ep-Sse-C"))
+	assert.Equal(t, "AES256", rr.Header().Get(request.SSECAlgorithmHeader))
+}
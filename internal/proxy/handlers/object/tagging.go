@@ -1,8 +1,14 @@
 package object
 
 import (
+	"bytes"
+	"encoding/xml"
 	"net/http"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gorilla/mux"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
@@ -10,6 +16,25 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// taggingXMLNS is the namespace of S3 tagging documents
+const taggingXMLNS = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// maxTaggingBody bounds a PutObjectTagging body; S3 allows 10 tags with keys
+// of 128 and values of 256 characters
+const maxTaggingBody = 64 << 10
+
+// tagging is the body of GetObjectTagging and PutObjectTagging
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
 // TaggingHandler handles object tagging operations
 type TaggingHandler struct {
 	s3Backend     interfaces.S3BackendInterface
@@ -17,6 +42,9 @@ type TaggingHandler struct {
 	xmlWriter     *response.XMLWriter
 	errorWriter   *response.ErrorWriter
 	requestParser *request.Parser
+
+	// Tags with this prefix are internal to the proxy and hidden from clients
+	metadataPrefix string
 }
 
 // NewTaggingHandler creates a new object tagging handler
@@ -26,13 +54,15 @@ func NewTaggingHandler(
 	xmlWriter *response.XMLWriter,
 	errorWriter *response.ErrorWriter,
 	requestParser *request.Parser,
+	metadataPrefix string,
 ) *TaggingHandler {
 	return &TaggingHandler{
-		s3Backend:     s3Backend,
-		logger:        logger,
-		xmlWriter:     xmlWriter,
-		errorWriter:   errorWriter,
-		requestParser: requestParser,
+		s3Backend:      s3Backend,
+		logger:         logger,
+		xmlWriter:      xmlWriter,
+		errorWriter:    errorWriter,
+		requestParser:  requestParser,
+		metadataPrefix: metadataPrefix,
 	}
 }
 
@@ -42,7 +72,7 @@ func (h *TaggingHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	bucket := vars["bucket"]
 	key := vars["key"]
 
-	h.logger.WithFields(logrus.Fields{
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"method": r.Method,
 		"bucket": bucket,
 		"key":    key,
@@ -60,17 +90,161 @@ func (h *TaggingHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGetTagging handles GET object tagging requests
-func (h *TaggingHandler) handleGetTagging(w http.ResponseWriter, _ *http.Request, _, _ string) {
-	h.errorWriter.WriteNotImplemented(w, "GetObjectTagging")
+// handleGetTagging returns the object's tags without the internal ones
+func (h *TaggingHandler) handleGetTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	output, err := h.s3Backend.GetObjectTagging(r.Context(), &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
+	})
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	result := tagging{Xmlns: taggingXMLNS, TagSet: []tag{}}
+	for _, t := range output.TagSet {
+		if h.isInternalTag(aws.ToString(t.Key)) {
+			continue
+		}
+		result.TagSet = append(result.TagSet, tag{Key: aws.ToString(t.Key), Value: aws.ToString(t.Value)})
+	}
+
+	setVersionIDHeader(w, output.VersionId)
+	h.xmlWriter.WriteXML(w, result)
 }
 
-// handlePutTagging handles PUT object tagging requests
-func (h *TaggingHandler) handlePutTagging(w http.ResponseWriter, _ *http.Request, _, _ string) {
-	h.errorWriter.WriteNotImplemented(w, "PutObjectTagging")
+// handlePutTagging replaces the object's tags. Clients cannot set internal
+// tags, and internal tags already on the object are kept.
+func (h *TaggingHandler) handlePutTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	body, err := h.requestParser.ReadXMLBody(r, maxTaggingBody)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	var requested tagging
+	if err := request.DecodeXML(bytes.NewReader(body), "Tagging", &requested, request.XMLLimits{}); err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	tagSet := make([]types.Tag, 0, len(requested.TagSet))
+	for _, t := range requested.TagSet {
+		if h.isInternalTag(t.Key) {
+			h.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidTag",
+				"Tag keys starting with "+h.metadataPrefix+" are reserved")
+			return
+		}
+		tagSet = append(tagSet, types.Tag{Key: aws.String(t.Key), Value: aws.String(t.Value)})
+	}
+
+	internal, err := h.internalTags(r, bucket, key)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	output, err := h.s3Backend.PutObjectTagging(r.Context(), &s3.PutObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
+		Tagging:   &types.Tagging{TagSet: append(tagSet, internal...)},
+	})
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	setVersionIDHeader(w, output.VersionId)
+	w.WriteHeader(http.StatusOK)
 }
 
-// handleDeleteTagging handles DELETE object tagging requests
-func (h *TaggingHandler) handleDeleteTagging(w http.ResponseWriter, _ *http.Request, _, _ string) {
-	h.errorWriter.WriteNotImplemented(w, "DeleteObjectTagging")
+// handleDeleteTagging removes the client tags of an object. Internal tags
+// are kept by writing them back instead of deleting the whole tag set.
+func (h *TaggingHandler) handleDeleteTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	internal, err := h.internalTags(r, bucket, key)
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	var versionID *string
+	if len(internal) > 0 {
+		output, putErr := h.s3Backend.PutObjectTagging(r.Context(), &s3.PutObjectTaggingInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+			Tagging:   &types.Tagging{TagSet: internal},
+		})
+		if output != nil {
+			versionID = output.VersionId
+		}
+		err = putErr
+	} else {
+		output, deleteErr := h.s3Backend.DeleteObjectTagging(r.Context(), &s3.DeleteObjectTaggingInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(key),
+			VersionId: versionIDParam(r),
+		})
+		if output != nil {
+			versionID = output.VersionId
+		}
+		err = deleteErr
+	}
+	if err != nil {
+		h.errorWriter.WriteS3Error(w, err, bucket, key)
+		return
+	}
+
+	setVersionIDHeader(w, versionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// internalTags returns the internal tags currently set on the object
+func (h *TaggingHandler) internalTags(r *http.Request, bucket, key string) ([]types.Tag, error) {
+	output, err := h.s3Backend.GetObjectTagging(r.Context(), &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: versionIDParam(r),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var internal []types.Tag
+	for _, t := range output.TagSet {
+		if h.isInternalTag(aws.ToString(t.Key)) {
+			internal = append(internal, t)
+		}
+	}
+	return internal, nil
+}
+
+// isInternalTag reports whether a tag key belongs to the proxy
+func (h *TaggingHandler) isInternalTag(key string) bool {
+	return h.metadataPrefix != "" && strings.HasPrefix(key, h.metadataPrefix)
+}
+
+// isObjectVersion reports whether versionID names a version of an object in
+// a versioned bucket. Unversioned buckets report no or the "null" version.
+func isObjectVersion(versionID *string) bool {
+	version := aws.ToString(versionID)
+	return version != "" && version != "null"
+}
+
+// setVersionIDHeader reports the version of the object a request read or
+// wrote, as S3 does for versioned buckets
+func setVersionIDHeader(w http.ResponseWriter, versionID *string) {
+	if versionID != nil {
+		w.Header().Set("x-amz-version-id", aws.ToString(versionID))
+	}
+}
+
+// versionIDParam returns the versionId query parameter, or nil without one
+func versionIDParam(r *http.Request) *string {
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		return aws.String(versionID)
+	}
+	return nil
 }
@@ -0,0 +1,124 @@
+package object
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+func newTestTaggingHandler(backend *MockS3Backend) *TaggingHandler {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	entry := logger.WithField("component", "tagging-handler")
+	return NewTaggingHandler(backend, entry, response.NewXMLWriter(entry), response.NewErrorWriter(entry),
+		request.NewParser(entry, &config.Config{}), "s3ep-")
+}
+
+func taggingRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	return mux.SetURLVars(req, map[string]string{"bucket": "bucket", "key": "key"})
+}
+
+func storedTags(tags ...string) *s3.GetObjectTaggingOutput {
+	output := &s3.GetObjectTaggingOutput{}
+	for i := 0; i < len(tags); i += 2 {
+		output.TagSet = append(output.TagSet, types.Tag{Key: aws.String(tags[i]), Value: aws.String(tags[i+1])})
+	}
+	return output
+}
+
+func TestTaggingHandler_GetHidesInternalTags(t *testing.T) {
+	backend := new(MockS3Backend)
+	backend.On("GetObjectTagging", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectTaggingInput) bool {
+		return aws.ToString(input.Key) == "key" && aws.ToString(input.VersionId) == "v1"
+	})).Return(storedTags("project", "alpha", "s3ep-state", "internal"), nil)
+
+	rr := httptest.NewRecorder()
+	newTestTaggingHandler(backend).Handle(rr, taggingRequest(http.MethodGet, "/bucket/key?tagging&versionId=v1", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Tag><Key>project</Key><Value>alpha</Value></Tag>")
+	assert.NotContains(t, rr.Body.String(), "s3ep-")
+}
+
+func TestTaggingHandler_PutKeepsInternalTags(t *testing.T) {
+	backend := new(MockS3Backend)
+	backend.On("GetObjectTagging", mock.Anything, mock.Anything).Return(storedTags("old", "x", "s3ep-state", "internal"), nil)
+	backend.On("PutObjectTagging", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectTaggingInput) bool {
+		tags := input.Tagging.TagSet
+		return len(tags) == 2 && aws.ToString(tags[0].Key) == "project" && aws.ToString(tags[1].Key) == "s3ep-state"
+	})).Return(&s3.PutObjectTaggingOutput{}, nil)
+
+	body := "<Tagging><TagSet><Tag><Key>project</Key><Value>alpha</Value></Tag></TagSet></Tagging>"
+	rr := httptest.NewRecorder()
+	newTestTaggingHandler(backend).Handle(rr, taggingRequest(http.MethodPut, "/bucket/key?tagging", body))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	backend.AssertExpectations(t)
+}
+
+func TestTaggingHandler_PutRejectsInvalidBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"internal tag", "<Tagging><TagSet><Tag><Key>s3ep-state</Key><Value>x</Value></Tag></TagSet></Tagging>", "InvalidTag"},
+		{"wrong root", "<TagSet><Tag><Key>a</Key><Value>b</Value></Tag></TagSet>", "MalformedXML"},
+		{"doctype", `<!DOCTYPE Tagging [<!ENTITY x "y">]><Tagging><TagSet/></Tagging>`, "MalformedXML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := new(MockS3Backend)
+			rr := httptest.NewRecorder()
+			newTestTaggingHandler(backend).Handle(rr, taggingRequest(http.MethodPut, "/bucket/key?tagging", tt.body))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), "<Code>"+tt.code+"</Code>")
+			backend.AssertNotCalled(t, "PutObjectTagging", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTaggingHandler_Delete(t *testing.T) {
+	t.Run("without internal tags", func(t *testing.T) {
+		backend := new(MockS3Backend)
+		backend.On("GetObjectTagging", mock.Anything, mock.Anything).Return(storedTags("project", "alpha"), nil)
+		backend.On("DeleteObjectTagging", mock.Anything, mock.Anything).Return(&s3.DeleteObjectTaggingOutput{}, nil)
+
+		rr := httptest.NewRecorder()
+		newTestTaggingHandler(backend).Handle(rr, taggingRequest(http.MethodDelete, "/bucket/key?tagging", ""))
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		backend.AssertExpectations(t)
+	})
+
+	t.Run("with internal tags", func(t *testing.T) {
+		backend := new(MockS3Backend)
+		backend.On("GetObjectTagging", mock.Anything, mock.Anything).Return(storedTags("project", "alpha", "s3ep-state", "internal"), nil)
+		backend.On("PutObjectTagging", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectTaggingInput) bool {
+			tags := input.Tagging.TagSet
+			return len(tags) == 1 && aws.ToString(tags[0].Key) == "s3ep-state"
+		})).Return(&s3.PutObjectTaggingOutput{}, nil)
+
+		rr := httptest.NewRecorder()
+		newTestTaggingHandler(backend).Handle(rr, taggingRequest(http.MethodDelete, "/bucket/key?tagging", ""))
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		backend.AssertNotCalled(t, "DeleteObjectTagging", mock.Anything, mock.Anything)
+	})
+}
@@ -315,6 +315,14 @@ func (m *MockS3Backend) ListObjects(ctx context.Context, params *s3.ListObjectsI
 	return args.Get(0).(*s3.ListObjectsOutput), args.Error(1)
 }
 
+func (m *MockS3Backend) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.ListObjectVersionsOutput), args.Error(1)
+}
+
 func (m *MockS3Backend) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 	args := m.Called(ctx, params)
 	if args.Get(0) == nil {
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetObject_Version(t *testing.T) {
+	handler, backend, encMgr := newBackfillTestHandler(t, true)
+	versions := map[string][]byte{
+		"v1": bytes.Repeat([]byte("first "), 100),
+		"v2": bytes.Repeat([]byte("second "), 100),
+	}
+	for id, plaintext := range versions {
+		output := gcmObject(t, encMgr, plaintext)
+		output.VersionId = aws.String(id)
+		backend.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return aws.ToString(input.VersionId) == id
+		})).Return(output, nil).Once()
+	}
+
+	// Each version is decrypted with its own metadata
+	for id, plaintext := range versions {
+		rr := httptest.NewRecorder()
+		handler.handleGetObject(rr, httptest.NewRequest(http.MethodGet, "/bucket/legacy.bin?versionId="+id, nil), "bucket", "legacy.bin")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, plaintext, rr.Body.Bytes())
+		assert.Equal(t, id, rr.Header().Get("x-amz-version-id"))
+	}
+	// Backfilling the size would add a version
+	backend.AssertNotCalled(t, "CopyObject", mock.Anything, mock.Anything)
+}
+
+func TestHeadObject_Version(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+	backend.On("HeadObject", mock.Anything, mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return aws.ToString(input.VersionId) == "v1"
+	})).Return(&s3.HeadObjectOutput{VersionId: aws.String("v1"), ContentLength: aws.Int64(4)}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	handler.handleHeadObject(rr, httptest.NewRequest(http.MethodHead, "/bucket/key?versionId=v1", nil), "bucket", "key")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "v1", rr.Header().Get("x-amz-version-id"))
+	backend.AssertExpectations(t)
+}
+
+func TestDeleteObject_Versions(t *testing.T) {
+	handler, backend, _ := newBackfillTestHandler(t, false)
+
+	// Without versionId a delete marker is added
+	backend.On("DeleteObject", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return input.VersionId == nil
+	})).Return(&s3.DeleteObjectOutput{VersionId: aws.String("marker"), DeleteMarker: aws.Bool(true)}, nil).Once()
+	rr := httptest.NewRecorder()
+	handler.handleDeleteObject(rr, httptest.NewRequest(http.MethodDelete, "/bucket/key", nil), "bucket", "key")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "marker", rr.Header().Get("x-amz-version-id"))
+	assert.Equal(t, "true", rr.Header().Get("x-amz-delete-marker"))
+
+	// With versionId that version is removed for good
+	backend.On("DeleteObject", mock.Anything, mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return aws.ToString(input.VersionId) == "v1" && aws.ToBool(input.BypassGovernanceRetention)
+	})).Return(&s3.DeleteObjectOutput{VersionId: aws.String("v1")}, nil).Once()
+	req := httptest.NewRequest(http.MethodDelete, "/bucket/key?versionId=v1", nil)
+	req.Header.Set("x-amz-bypass-governance-retention", "true")
+	rr = httptest.NewRecorder()
+	handler.handleDeleteObject(rr, req, "bucket", "key")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "v1", rr.Header().Get("x-amz-version-id"))
+	assert.Empty(t, rr.Header().Get("x-amz-delete-marker"))
+	backend.AssertExpectations(t)
+}
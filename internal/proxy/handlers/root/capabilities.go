@@ -0,0 +1,143 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/handlers/multipart"
+)
+
+// CapabilitiesQuery selects the capability document on GET /?s3ep-capabilities
+const CapabilitiesQuery = "s3ep-capabilities"
+
+// capabilitiesVersion is raised when the layout of the document changes
+const capabilitiesVersion = 1
+
+// Capabilities lists the proxy extensions clients can feature-detect instead
+// of checking proxy versions. Extensions that are missing from the list are
+// not supported.
+type Capabilities struct {
+	Version    int                   `json:"version"`
+	Service    string                `json:"service"`
+	Extensions map[string]Capability `json:"extensions"`
+}
+
+// Capability describes one extension. Version is raised when its behavior
+// changes incompatibly; Settings holds what a client needs to use it.
+type Capability struct {
+	Version  int                    `json:"version"`
+	Enabled  bool                   `json:"enabled"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+}
+
+// NewCapabilities lists the extensions of a proxy running with cfg
+func NewCapabilities(cfg *config.Config) *Capabilities {
+	integrityAlgorithm := cfg.Encryption.IntegrityAlgorithm
+	if integrityAlgorithm == "" {
+		integrityAlgorithm = config.IntegrityAlgorithmHMACSHA256
+	}
+	sessionStore := cfg.SessionStore.Type
+	if sessionStore == "" {
+		sessionStore = config.SessionStoreMemory
+	}
+	integrityMode := cfg.Encryption.IntegrityVerification
+	if integrityMode == "" {
+		integrityMode = config.HMACVerificationOff
+	}
+	recommendedPartSize := cfg.GetRecommendedPartSize()
+
+	return &Capabilities{
+		Version: capabilitiesVersion,
+		Service: "s3-encryption-proxy",
+		Extensions: map[string]Capability{
+			"encryption-context": {
+				Version: 1,
+				Enabled: true,
+				Settings: map[string]interface{}{
+					"header": orchestration.EncryptionContextHeader,
+					"strict": cfg.Encryption.StrictEncryptionContext,
+				},
+			},
+			// Clients pick a provider per object on PUT and multipart initiation
+			"provider-selection": {
+				Version: 1,
+				Enabled: len(cfg.Encryption.SelectableProviders) > 0,
+				Settings: map[string]interface{}{
+					"header":    orchestration.EncryptionProviderHeader,
+					"providers": append([]string{cfg.Encryption.EncryptionMethodAlias}, cfg.Encryption.SelectableProviders...),
+				},
+			},
+			"integrity-verification": {
+				Version: 1,
+				Enabled: integrityMode != config.HMACVerificationOff,
+				Settings: map[string]interface{}{
+					"mode":      integrityMode,
+					"algorithm": integrityAlgorithm,
+				},
+			},
+			"recommended-part-size": {
+				Version: 1,
+				Enabled: recommendedPartSize > 0,
+				Settings: map[string]interface{}{
+					"header": multipart.RecommendedPartSizeHeader,
+					"bytes":  recommendedPartSize,
+				},
+			},
+			// Multipart uploads survive restarts and can be continued on any replica
+			"resumable-uploads": {
+				Version:  1,
+				Enabled:  sessionStore != config.SessionStoreMemory,
+				Settings: map[string]interface{}{"session_store": sessionStore},
+			},
+			// Legacy objects get their plaintext size recorded once read in full;
+			// "listings" tells whether listings report it too
+			"plaintext-size": {
+				Version:  1,
+				Enabled:  cfg.Encryption.PlaintextSizeBackfill,
+				Settings: map[string]interface{}{"listings": cfg.Encryption.ListPlaintextSizes},
+			},
+			// ETags are the MD5 of the plaintext for objects that recorded one
+			"plaintext-etag": {
+				Version: 1,
+				Enabled: cfg.Encryption.ETagMode == config.ETagModePlaintext,
+			},
+			// Range GETs of encrypted objects are rejected with RangeNotSupported
+			"ranged-reads": {
+				Version: 1,
+				Enabled: false,
+			},
+			// Customer-provided keys (SSE-C) are forwarded for single-part objects
+			"sse-c-passthrough": {
+				Version: 1,
+				Enabled: cfg.Encryption.SSECMode == config.SSECModePassthrough,
+			},
+			// GET ?partNumber= returns the whole object
+			"part-number-get": {
+				Version: 1,
+				Enabled: false,
+			},
+		},
+	}
+}
+
+// SetCapabilities sets the document served by HandleCapabilities
+func (h *Handler) SetCapabilities(capabilities *Capabilities) {
+	h.capabilities = capabilities
+}
+
+// HandleCapabilities serves the capability document as JSON
+func (h *Handler) HandleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if h.capabilities == nil {
+		h.errorWriter.WriteError(w, r, http.StatusNotFound, "NotFound", "The proxy does not publish its capabilities")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.capabilities); err != nil {
+		h.logger.WithError(err).Error("Failed to encode capabilities response")
+	}
+}
@@ -0,0 +1,62 @@
+package root
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestHandleCapabilities(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Encryption.IntegrityVerification = config.HMACVerificationStrict
+	cfg.SessionStore.Type = "redis"
+
+	handler := NewHandler(&MockS3Backend{}, logrus.New())
+	handler.SetCapabilities(NewCapabilities(cfg))
+
+	w := httptest.NewRecorder()
+	handler.HandleCapabilities(w, httptest.NewRequest("GET", "/?"+CapabilitiesQuery, nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var capabilities Capabilities
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &capabilities))
+	assert.Equal(t, capabilitiesVersion, capabilities.Version)
+
+	integrity := capabilities.Extensions["integrity-verification"]
+	assert.True(t, integrity.Enabled)
+	assert.Equal(t, config.HMACVerificationStrict, integrity.Settings["mode"])
+	assert.Equal(t, config.IntegrityAlgorithmHMACSHA256, integrity.Settings["algorithm"])
+	assert.True(t, capabilities.Extensions["resumable-uploads"].Enabled)
+	assert.True(t, capabilities.Extensions["encryption-context"].Enabled)
+	assert.False(t, capabilities.Extensions["ranged-reads"].Enabled)
+	assert.False(t, capabilities.Extensions["part-number-get"].Enabled)
+}
+
+func TestNewCapabilities_Defaults(t *testing.T) {
+	capabilities := NewCapabilities(&config.Config{})
+
+	assert.False(t, capabilities.Extensions["integrity-verification"].Enabled)
+	assert.Equal(t, config.HMACVerificationOff, capabilities.Extensions["integrity-verification"].Settings["mode"])
+	assert.False(t, capabilities.Extensions["resumable-uploads"].Enabled)
+	assert.False(t, capabilities.Extensions["plaintext-size"].Enabled)
+	assert.Equal(t, false, capabilities.Extensions["plaintext-size"].Settings["listings"])
+	assert.False(t, capabilities.Extensions["plaintext-etag"].Enabled)
+}
+
+func TestHandleCapabilities_NotConfigured(t *testing.T) {
+	handler := NewHandler(&MockS3Backend{}, logrus.New())
+
+	w := httptest.NewRecorder()
+	handler.HandleCapabilities(w, httptest.NewRequest("GET", "/?"+CapabilitiesQuery, nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,15 +34,18 @@ type S3Bucket struct {
 
 // Handler handles root-level S3 operations
 type Handler struct {
-	s3Backend interfaces.S3BackendInterface
-	logger    logrus.FieldLogger
+	s3Backend    interfaces.S3BackendInterface
+	logger       logrus.FieldLogger
+	errorWriter  *response.ErrorWriter
+	capabilities *Capabilities
 }
 
 // NewHandler creates a new root handler
 func NewHandler(s3Backend interfaces.S3BackendInterface, logger logrus.FieldLogger) *Handler {
 	return &Handler{
-		s3Backend: s3Backend,
-		logger:    logger,
+		s3Backend:   s3Backend,
+		errorWriter: response.NewErrorWriter(logger),
+		logger:      logger,
 	}
 }
 
@@ -52,8 +56,7 @@ func (h *Handler) HandleListBuckets(w http.ResponseWriter, r *http.Request) {
 	// Use the S3 client to list buckets
 	response, err := h.s3Backend.ListBuckets(r.Context(), &s3.ListBucketsInput{})
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to list buckets")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.errorWriter.WriteS3Error(w, err, "", "")
 		return
 	}
 
@@ -65,7 +65,7 @@ func TestHandleListBucketsError(t *testing.T) {
 
 	// Verify error response
 	assert.Equal(t, 500, w.Code)
-	assert.Contains(t, w.Body.String(), "Internal Server Error")
+	assert.Contains(t, w.Body.String(), "<Code>InternalError</Code>")
 }
 
 func TestHandleListBucketsMultipleBuckets(t *testing.T) {
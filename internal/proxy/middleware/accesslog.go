@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/accesslog"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+// AccessLog writes one access log line per S3 request, including requests
+// rejected by authentication or the listener limits. Without a log it
+// passes requests through.
+type AccessLog struct {
+	log    *accesslog.Logger
+	logger *logrus.Entry
+}
+
+// NewAccessLog creates a new access log middleware; log may be nil
+func NewAccessLog(log *accesslog.Logger, logger *logrus.Entry) *AccessLog {
+	return &AccessLog{log: log, logger: logger}
+}
+
+// Middleware returns the HTTP middleware function
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	if a.log == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		tracked := newStatusWriter(w, nil)
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		// The encryption layer marks whether it encrypted or decrypted data;
+		// the details of the audit middleware are shared when it runs first
+		if audit.DetailsFrom(r.Context()) == nil {
+			ctx, _ := audit.WithDetails(r.Context())
+			r = r.WithContext(ctx)
+		}
+
+		// Also log requests whose connection was aborted by a panic
+		defer func() {
+			recovered := recover()
+			status := tracked.status
+			if recovered != nil {
+				status = 0
+			}
+			a.write(r, tracked, body.bytes, status, start)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		next.ServeHTTP(tracked, r)
+
+		// Send the implicit 200 now, so the line gets its request ID
+		if !tracked.wroteHeader {
+			tracked.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func (a *AccessLog) write(r *http.Request, w *statusWriter, received int64, status int, start time.Time) {
+	vars := mux.Vars(r)
+	encrypted, decrypted := audit.DetailsFrom(r.Context()).Crypto()
+	err := a.log.Log(accesslog.Entry{
+		Time:        start,
+		RequestID:   w.Header().Get("X-Amz-Request-Id"),
+		RemoteAddr:  r.RemoteAddr,
+		AccessKeyID: presentedAccessKeyID(r),
+		Method:      r.Method,
+		URI:         r.URL.RequestURI(),
+		Proto:       r.Proto,
+		Operation:   s3Operation(r, vars["bucket"], vars["key"]),
+		Bucket:      vars["bucket"],
+		Key:         vars["key"],
+		Status:      status,
+		BytesIn:     received,
+		BytesOut:    w.bytes,
+		DurationMs:  time.Since(start).Milliseconds(),
+		Encrypted:   encrypted,
+		Decrypted:   decrypted,
+		Referer:     r.Referer(),
+		UserAgent:   r.UserAgent(),
+	})
+	if err != nil {
+		a.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Error("Failed to write access log")
+	}
+}
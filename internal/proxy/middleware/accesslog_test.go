@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/accesslog"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+func TestAccessLog_LogsRequests(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	router := mux.NewRouter()
+	router.Use(NewS3Headers().Middleware)
+	router.Use(NewAccessLog(accesslog.New(accesslog.FormatJSON, &out), logrus.NewEntry(logger)).Middleware)
+	router.HandleFunc("/{bucket}/{key:.*}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			_, _ = io.Copy(io.Discard, r.Body)
+			audit.DetailsFrom(r.Context()).MarkEncrypted()
+			return
+		}
+		audit.DetailsFrom(r.Context()).MarkDecrypted()
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/some/key", strings.NewReader("payload"))
+	req.Header.Set(AuthorizationHeader, "AWS4-HMAC-SHA256 Credential=client-key/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bucket/some/key", nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var put, get accesslog.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &put))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &get))
+
+	assert.Equal(t, "PutObject", put.Operation)
+	assert.Equal(t, "bucket", put.Bucket)
+	assert.Equal(t, "some/key", put.Key)
+	assert.Equal(t, "client-key", put.AccessKeyID)
+	assert.Equal(t, http.StatusOK, put.Status)
+	assert.Equal(t, int64(7), put.BytesIn)
+	assert.True(t, put.Encrypted)
+	assert.False(t, put.Decrypted)
+	assert.NotEmpty(t, put.RequestID)
+
+	assert.Equal(t, "GetObject", get.Operation)
+	assert.Equal(t, int64(5), get.BytesOut)
+	assert.True(t, get.Decrypted)
+	assert.False(t, get.Encrypted)
+}
+
+func TestAccessLog_SharesAuditDetails(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	router, _, _ := newTestAuditRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		audit.DetailsFrom(r.Context()).MarkDecrypted()
+	})
+	router.(*mux.Router).Use(NewAccessLog(accesslog.New(accesslog.FormatJSON, &out), logrus.NewEntry(logger)).Middleware)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+
+	var entry accesslog.Entry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.True(t, entry.Decrypted, "the access log sees the details of the audit middleware")
+}
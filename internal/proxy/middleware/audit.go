@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+// Audit records every S3 request with the audit recorder, including requests
+// rejected by authentication or the listener limits. Without a recorder it
+// passes requests through.
+type Audit struct {
+	recorder *audit.Recorder
+	logger   *logrus.Entry
+}
+
+// NewAudit creates a new audit middleware; recorder may be nil
+func NewAudit(recorder *audit.Recorder, logger *logrus.Entry) *Audit {
+	return &Audit{recorder: recorder, logger: logger}
+}
+
+// Middleware returns the HTTP middleware function
+func (a *Audit) Middleware(next http.Handler) http.Handler {
+	if a.recorder == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		tracked := newStatusWriter(w, nil)
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		// The encryption layer reports the key and integrity check it used
+		ctx, _ := audit.WithDetails(r.Context())
+		r = r.WithContext(ctx)
+
+		// Also record requests whose connection was aborted by a panic
+		defer func() {
+			recovered := recover()
+			status := tracked.status
+			if recovered != nil {
+				status = 0
+			}
+			a.record(r, tracked, body.bytes, status, start)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		next.ServeHTTP(tracked, r)
+
+		// Send the implicit 200 now, so the record gets its request ID
+		if !tracked.wroteHeader {
+			tracked.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func (a *Audit) record(r *http.Request, w *statusWriter, received int64, status int, start time.Time) {
+	vars := mux.Vars(r)
+	err := a.recorder.Record(r.Context(), audit.Record{
+		Time:          start.UTC(),
+		RequestID:     w.Header().Get("X-Amz-Request-Id"),
+		AccessKeyID:   presentedAccessKeyID(r),
+		RemoteAddr:    r.RemoteAddr,
+		Method:        r.Method,
+		Operation:     s3Operation(r, vars["bucket"], vars["key"]),
+		Bucket:        vars["bucket"],
+		Key:           vars["key"],
+		Query:         queryNames(r),
+		Status:        status,
+		BytesReceived: received,
+		BytesSent:     w.bytes,
+		DurationMs:    time.Since(start).Milliseconds(),
+	})
+	if err != nil {
+		a.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Error("Failed to write audit record")
+	}
+}
+
+// presentedAccessKeyID returns the access key ID of the Authorization header
+// or of a presigned URL, without checking the signature
+func presentedAccessKeyID(r *http.Request) string {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if auth := r.Header.Get(AuthorizationHeader); auth != "" {
+		if _, rest, ok := strings.Cut(auth, "Credential="); ok {
+			credential = rest
+		}
+	}
+	accessKeyID, _, _ := strings.Cut(credential, "/")
+	if len(accessKeyID) > 128 {
+		accessKeyID = accessKeyID[:128]
+	}
+	return accessKeyID
+}
+
+// countingBody counts the request body bytes the handlers read
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+// objectSubresourceOperations name the S3 operations on object subresources
+// by query parameter
+var objectSubresourceOperations = [][2]string{
+	{"acl", "ObjectAcl"},
+	{"tagging", "ObjectTagging"},
+	{"retention", "ObjectRetention"},
+	{"legal-hold", "ObjectLegalHold"},
+	{"attributes", "ObjectAttributes"},
+}
+
+// s3Operation names the S3 API operation of r, e.g. GetObject. Bucket
+// configuration requests are named after their subresource, like
+// PutBucketVersioning.
+func s3Operation(r *http.Request, bucket, key string) string {
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		return "ListBuckets"
+	case key == "":
+		return bucketOperation(r, query)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		return "CreateMultipartUpload"
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		return "CompleteMultipartUpload"
+	case r.Method == http.MethodPost && query.Has("select"):
+		return "SelectObjectContent"
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return "UploadPartCopy"
+		}
+		return "UploadPart"
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		return "ListParts"
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		return "AbortMultipartUpload"
+	}
+	for _, subresource := range objectSubresourceOperations {
+		if query.Has(subresource[0]) {
+			return methodVerb(r.Method) + subresource[1]
+		}
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return "CopyObject"
+		}
+		return "PutObject"
+	case http.MethodGet:
+		return "GetObject"
+	case http.MethodHead:
+		return "HeadObject"
+	case http.MethodDelete:
+		return "DeleteObject"
+	}
+	return r.Method + "Object"
+}
+
+// bucketOperation names the S3 API operation of a bucket request
+func bucketOperation(r *http.Request, query url.Values) string {
+	switch {
+	case r.Method == http.MethodPost && query.Has("delete"):
+		return "DeleteObjects"
+	case r.Method == http.MethodHead:
+		return "HeadBucket"
+	case r.Method == http.MethodGet && query.Has("uploads"):
+		return "ListMultipartUploads"
+	case r.Method == http.MethodGet && query.Has("versions"):
+		return "ListObjectVersions"
+	}
+	for _, param := range bucketSubresources {
+		if query.Has(param) {
+			return methodVerb(r.Method) + "Bucket" + subresourceName(param)
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if query.Get("list-type") == "2" {
+			return "ListObjectsV2"
+		}
+		return "ListObjects"
+	case http.MethodPut:
+		return "CreateBucket"
+	case http.MethodDelete:
+		return "DeleteBucket"
+	}
+	return r.Method + "Bucket"
+}
+
+// methodVerb returns the verb S3 operation names start with for method
+func methodVerb(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "Get"
+	case http.MethodDelete:
+		return "Delete"
+	default:
+		return "Put"
+	}
+}
+
+// subresourceName turns a query parameter like "object-lock" into "ObjectLock"
+func subresourceName(param string) string {
+	var name strings.Builder
+	for _, part := range strings.Split(param, "-") {
+		if part != "" {
+			name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return name.String()
+}
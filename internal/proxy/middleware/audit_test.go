@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+)
+
+func newTestAuditRouter(t *testing.T, handler http.HandlerFunc) (http.Handler, *audit.Recorder, string) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	log, err := audit.Open(audit.Options{Directory: dir, SigningKey: key, MaxRecords: 100}, logrus.NewEntry(logger))
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(NewS3Headers().Middleware)
+	recorder := audit.NewRecorder(log, logrus.NewEntry(logger))
+	router.Use(NewAudit(recorder, logrus.NewEntry(logger)).Middleware)
+	router.HandleFunc("/{bucket}/{key:.*}", handler)
+	return router, recorder, dir
+}
+
+// readAuditRecords returns the records of the first segment
+func readAuditRecords(t *testing.T, dir string) []audit.Record {
+	segments, err := filepath.Glob(filepath.Join(dir, "segment-*.jsonl"))
+	require.NoError(t, err)
+	require.NotEmpty(t, segments)
+
+	file, err := os.Open(segments[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []audit.Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line struct {
+			Record *audit.Record `json:"record"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		if line.Record != nil {
+			records = append(records, *line.Record)
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestAudit_RecordsRequests(t *testing.T) {
+	router, log, dir := newTestAuditRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		details := audit.DetailsFrom(r.Context())
+		details.SetKEKFingerprint("kek-fingerprint")
+		details.SetIntegrity(audit.IntegrityVerified)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/some/key?versionId=secret", nil)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=client-key/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/bucket/other?X-Amz-Credential=presigned-key%2F20260101%2Fus-east-1%2Fs3%2Faws4_request", strings.NewReader("upload"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	forbiddenID := rec.Header().Get("X-Amz-Request-Id")
+
+	require.NoError(t, log.Close())
+	records := readAuditRecords(t, dir)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, uint64(1), records[0].Sequence)
+	assert.Equal(t, audit.GenesisHash, records[0].PrevHash)
+	assert.Equal(t, "client-key", records[0].AccessKeyID)
+	assert.Equal(t, http.MethodGet, records[0].Method)
+	assert.Equal(t, "bucket", records[0].Bucket)
+	assert.Equal(t, "some/key", records[0].Key)
+	assert.Equal(t, []string{"versionId"}, records[0].Query)
+	assert.Equal(t, http.StatusOK, records[0].Status)
+	assert.Equal(t, int64(5), records[0].BytesSent)
+	assert.NotEmpty(t, records[0].RequestID)
+	assert.Equal(t, "GetObject", records[0].Operation)
+	assert.Equal(t, audit.OutcomeSuccess, records[0].Outcome)
+	assert.Equal(t, "kek-fingerprint", records[0].KEKFingerprint)
+	assert.Equal(t, audit.IntegrityVerified, records[0].Integrity)
+
+	assert.Equal(t, "presigned-key", records[1].AccessKeyID)
+	assert.Equal(t, http.StatusForbidden, records[1].Status)
+	assert.Equal(t, forbiddenID, records[1].RequestID)
+	assert.Equal(t, "PutObject", records[1].Operation)
+	assert.Equal(t, audit.OutcomeDenied, records[1].Outcome)
+	assert.Equal(t, int64(6), records[1].BytesReceived)
+	assert.Empty(t, records[1].KEKFingerprint)
+}
+
+func TestAudit_RecordsPanics(t *testing.T) {
+	router, log, dir := newTestAuditRouter(t, func(_ http.ResponseWriter, _ *http.Request) {
+		panic("boom")
+	})
+
+	assert.Panics(t, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+	})
+
+	require.NoError(t, log.Close())
+	records := readAuditRecords(t, dir)
+	require.Len(t, records, 1)
+	assert.Zero(t, records[0].Status)
+	assert.Equal(t, audit.OutcomeAborted, records[0].Outcome)
+}
+
+func TestAudit_WithoutLogPassesThrough(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	wrapped := NewAudit(nil, logrus.NewEntry(logrus.New())).Middleware(handler)
+
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/bucket/key", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestS3Operation(t *testing.T) {
+	for _, test := range []struct {
+		method, target, copySource, operation string
+	}{
+		{http.MethodGet, "/", "", "ListBuckets"},
+		{http.MethodGet, "/bucket?list-type=2", "", "ListObjectsV2"},
+		{http.MethodGet, "/bucket", "", "ListObjects"},
+		{http.MethodGet, "/bucket?uploads", "", "ListMultipartUploads"},
+		{http.MethodPut, "/bucket", "", "CreateBucket"},
+		{http.MethodPut, "/bucket?versioning", "", "PutBucketVersioning"},
+		{http.MethodGet, "/bucket?object-lock", "", "GetBucketObjectLock"},
+		{http.MethodPost, "/bucket?delete", "", "DeleteObjects"},
+		{http.MethodHead, "/bucket", "", "HeadBucket"},
+		{http.MethodGet, "/bucket/key", "", "GetObject"},
+		{http.MethodPut, "/bucket/key", "", "PutObject"},
+		{http.MethodPut, "/bucket/key", "/source/key", "CopyObject"},
+		{http.MethodPut, "/bucket/key?tagging", "", "PutObjectTagging"},
+		{http.MethodPost, "/bucket/key?uploads", "", "CreateMultipartUpload"},
+		{http.MethodPut, "/bucket/key?partNumber=1&uploadId=u", "", "UploadPart"},
+		{http.MethodPut, "/bucket/key?partNumber=1&uploadId=u", "/source/key", "UploadPartCopy"},
+		{http.MethodPost, "/bucket/key?uploadId=u", "", "CompleteMultipartUpload"},
+		{http.MethodDelete, "/bucket/key?uploadId=u", "", "AbortMultipartUpload"},
+	} {
+		var bucket, key string
+		router := mux.NewRouter()
+		capture := func(_ http.ResponseWriter, r *http.Request) {
+			bucket, key = mux.Vars(r)["bucket"], mux.Vars(r)["key"]
+		}
+		router.HandleFunc("/", capture)
+		router.HandleFunc("/{bucket}", capture)
+		router.HandleFunc("/{bucket}/{key:.*}", capture)
+
+		req := httptest.NewRequest(test.method, test.target, nil)
+		if test.copySource != "" {
+			req.Header.Set("X-Amz-Copy-Source", test.copySource)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, test.operation, s3Operation(req, bucket, key), "%s %s", test.method, test.target)
+	}
+}
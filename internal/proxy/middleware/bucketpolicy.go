@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// objectSubresources are the query parameters that turn an object PUT into a
+// change of object settings that stores no data
+var objectSubresources = []string{"acl", "tagging", "retention", "legal-hold"}
+
+// anonymousReadQuery are the query parameters an unsigned object read may
+// carry besides the response-* overrides; others select subresources like
+// ACLs and tags, or carry a presigned signature. x-id only names the
+// operation: AWS SDKs add x-id=GetObject to every GetObject.
+var anonymousReadQuery = []string{"versionId", "partNumber", "x-id"}
+
+// BucketPolicy enforces bucket_policies: requests a policy of one of the
+// buckets they touch does not allow are rejected with 403 AccessDenied
+type BucketPolicy struct {
+	config      atomic.Pointer[config.Config]
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+}
+
+// NewBucketPolicy creates the bucket policy middleware
+func NewBucketPolicy(cfg *config.Config, logger *logrus.Entry) *BucketPolicy {
+	p := &BucketPolicy{
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+	}
+	p.Update(cfg)
+	return p
+}
+
+// Update enforces the policies of a reloaded configuration
+func (p *BucketPolicy) Update(cfg *config.Config) {
+	p.config.Store(cfg)
+}
+
+// Middleware returns the HTTP middleware function
+func (p *BucketPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := p.config.Load()
+		if cfg == nil || (len(cfg.BucketPolicies.Rules) == 0 && cfg.BucketPolicies.Default.IsZero()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Malformed requests are left to the handlers to reject
+		accesses, err := scopeAccesses(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, access := range accesses {
+			if access.bucket == "" {
+				continue
+			}
+			if violation := policyViolation(cfg, r, access); violation != "" {
+				p.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"bucket": access.bucket,
+				}).Info("Rejected request by bucket policy: " + violation)
+				p.errorWriter.WriteError(w, r, http.StatusForbidden, "AccessDenied",
+					fmt.Sprintf("The bucket policy of %s does not allow this request: %s", access.bucket, violation))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AllowsAnonymousRead reports whether r is an unsigned GetObject or
+// HeadObject of a key the bucket policy opens with public_read
+func (p *BucketPolicy) AllowsAnonymousRead(r *http.Request) bool {
+	cfg := p.config.Load()
+	if cfg == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for name := range r.URL.Query() {
+		if !slices.Contains(anonymousReadQuery, name) && !strings.HasPrefix(name, "response-") {
+			return false
+		}
+	}
+	vars := mux.Vars(r)
+	bucket, key := vars["bucket"], vars["key"]
+	if bucket == "" || !plainAnonymousKey(r, key) {
+		return false
+	}
+	return cfg.BucketPolicyFor(bucket).PublicReadable(key)
+}
+
+// plainAnonymousKey reports whether key, as routed, can be matched against
+// public_read_prefixes: it has no empty, "." or ".." segments, and the
+// client sent no encoded slash, so no hop that normalizes the path or splits
+// it on %2F differently can resolve it to a key outside the prefixes
+func plainAnonymousKey(r *http.Request, key string) bool {
+	if key == "" || strings.Contains(strings.ToUpper(originalPath(r)), "%2F") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// policyViolation returns why the policy of the bucket of access rejects r, or ""
+func policyViolation(cfg *config.Config, r *http.Request, access scopeAccess) string {
+	policy := cfg.BucketPolicyFor(access.bucket)
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if policy.ReadOnly && !readOnly && access.operation != config.ScopeOperationRead && access.operation != config.ScopeOperationList {
+		return "the bucket is read-only"
+	}
+	if (access.operation != config.ScopeOperationWrite && access.operation != config.ScopeOperationMultipart) || !storesObjectData(r) {
+		return ""
+	}
+	if _, ok := request.ParseSSEC(r); ok {
+		if policy.DenySSECPassthrough {
+			return "uploads with customer-provided keys (SSE-C) are not encrypted by the proxy"
+		}
+		return ""
+	}
+	if policy.RequireEncryption && uploadsUnencrypted(cfg, r) {
+		return "objects must be encrypted"
+	}
+	return ""
+}
+
+// uploadsUnencrypted reports whether the provider r writes with, the selected
+// one or else the active one, stores objects without encryption
+func uploadsUnencrypted(cfg *config.Config, r *http.Request) bool {
+	alias := strings.TrimSpace(r.Header.Get(orchestration.EncryptionProviderHeader))
+	if alias == "" {
+		alias = cfg.Encryption.EncryptionMethodAlias
+	}
+	provider, err := cfg.GetProviderByAlias(alias)
+	return err == nil && provider.Type == "none"
+}
+
+// storesObjectData reports whether r uploads an object: PutObject, CopyObject
+// or CreateMultipartUpload. The provider of a multipart upload is chosen when
+// it is created, so its parts are not checked again.
+func storesObjectData(r *http.Request) bool {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodPut:
+		return !query.Has("uploadId") && !hasAny(query, objectSubresources)
+	case http.MethodPost:
+		return query.Has("uploads")
+	default:
+		return false
+	}
+}
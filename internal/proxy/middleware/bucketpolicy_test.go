@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+func TestBucketPolicy(t *testing.T) {
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "aes",
+			Providers:             []config.EncryptionProvider{{Alias: "aes", Type: "aes"}, {Alias: "plain", Type: "none"}},
+		},
+		BucketPolicies: config.BucketPoliciesConfig{
+			Default: config.BucketPolicy{RequireEncryption: true},
+			Rules: []config.BucketPolicyRule{
+				{Buckets: []string{"archive-*"}, BucketPolicy: config.BucketPolicy{ReadOnly: true}},
+				{Buckets: []string{"secure"}, BucketPolicy: config.BucketPolicy{RequireEncryption: true, DenySSECPassthrough: true}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		headers  map[string]string
+		body     string
+		status   int
+		contains string
+	}{
+		{"read from read-only", http.MethodGet, "/archive-2026/key", nil, "", http.StatusOK, ""},
+		{"list read-only", http.MethodGet, "/archive-2026?list-type=2", nil, "", http.StatusOK, ""},
+		{"read bucket config of read-only", http.MethodGet, "/archive-2026?tagging", nil, "", http.StatusOK, ""},
+		{"put to read-only", http.MethodPut, "/archive-2026/key", nil, "", http.StatusForbidden, "read-only"},
+		{"delete from read-only", http.MethodDelete, "/archive-2026/key", nil, "", http.StatusForbidden, "read-only"},
+		{"multipart upload to read-only", http.MethodPost, "/archive-2026/key?uploads", nil, "", http.StatusForbidden, "read-only"},
+		{"bucket config of read-only", http.MethodPut, "/archive-2026?versioning", nil, "", http.StatusForbidden, "read-only"},
+		{"delete objects from read-only", http.MethodPost, "/archive-2026?delete", nil, "<Delete><Object><Key>a</Key></Object></Delete>", http.StatusForbidden, "read-only"},
+		{"copy out of read-only", http.MethodPut, "/data/key", map[string]string{"X-Amz-Copy-Source": "/archive-2026/key"}, "", http.StatusOK, ""},
+		{"copy into read-only", http.MethodPut, "/archive-2026/key", map[string]string{"X-Amz-Copy-Source": "/data/key"}, "", http.StatusForbidden, "read-only"},
+		{"encrypted put", http.MethodPut, "/data/key", nil, "", http.StatusOK, ""},
+		{"none provider put", http.MethodPut, "/data/key", map[string]string{orchestration.EncryptionProviderHeader: "plain"}, "", http.StatusForbidden, "must be encrypted"},
+		{"none provider multipart", http.MethodPost, "/data/key?uploads", map[string]string{orchestration.EncryptionProviderHeader: "plain"}, "", http.StatusForbidden, "must be encrypted"},
+		{"none provider tagging", http.MethodPut, "/data/key?tagging", map[string]string{orchestration.EncryptionProviderHeader: "plain"}, "", http.StatusOK, ""},
+		{"SSE-C put allowed", http.MethodPut, "/data/key", map[string]string{request.SSECAlgorithmHeader: "AES256"}, "", http.StatusOK, ""},
+		{"SSE-C put denied", http.MethodPut, "/secure/key", map[string]string{request.SSECAlgorithmHeader: "AES256"}, "", http.StatusForbidden, "SSE-C"},
+		{"SSE-C get", http.MethodGet, "/secure/key", map[string]string{request.SSECAlgorithmHeader: "AES256"}, "", http.StatusOK, ""},
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	handler := NewBucketPolicy(cfg, logrus.NewEntry(logger)).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.contains != "" {
+				assert.Contains(t, rr.Body.String(), "<Code>AccessDenied</Code>")
+				assert.Contains(t, rr.Body.String(), tt.contains)
+			}
+		})
+	}
+}
+
+func TestBucketPolicy_ActiveNoneProvider(t *testing.T) {
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "plain",
+			Providers:             []config.EncryptionProvider{{Alias: "plain", Type: "none"}},
+		},
+		BucketPolicies: config.BucketPoliciesConfig{
+			Rules: []config.BucketPolicyRule{{Buckets: []string{"secure"}, BucketPolicy: config.BucketPolicy{RequireEncryption: true}}},
+		},
+	}
+	handler := NewBucketPolicy(cfg, logrus.NewEntry(logrus.New())).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for target, status := range map[string]int{"/secure/key": http.StatusForbidden, "/data/key": http.StatusOK} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, target, nil))
+		assert.Equal(t, status, rr.Code, target)
+	}
+}
+
+func TestBucketPolicy_Update(t *testing.T) {
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "aes",
+			Providers:             []config.EncryptionProvider{{Alias: "aes", Type: "aes"}},
+		},
+	}
+	policy := NewBucketPolicy(cfg, logrus.NewEntry(logrus.New()))
+	handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	put := func() int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/secure/key", nil)
+		req.Header.Set(orchestration.EncryptionProviderHeader, "plain")
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, put())
+
+	// The reloaded configuration adds the policy and the provider it checks
+	reloaded := *cfg
+	reloaded.Encryption.Providers = append(reloaded.Encryption.Providers, config.EncryptionProvider{Alias: "plain", Type: "none"})
+	reloaded.BucketPolicies = config.BucketPoliciesConfig{
+		Rules: []config.BucketPolicyRule{{Buckets: []string{"secure"}, BucketPolicy: config.BucketPolicy{RequireEncryption: true}}},
+	}
+	policy.Update(&reloaded)
+	assert.Equal(t, http.StatusForbidden, put())
+}
+
+func TestBucketPolicy_AllowsAnonymousRead(t *testing.T) {
+	cfg := &config.Config{
+		BucketPolicies: config.BucketPoliciesConfig{
+			Rules: []config.BucketPolicyRule{
+				{Buckets: []string{"assets"}, BucketPolicy: config.BucketPolicy{PublicRead: true, PublicReadPrefixes: []string{"public/"}}},
+				{Buckets: []string{"www"}, BucketPolicy: config.BucketPolicy{PublicRead: true}},
+			},
+		},
+	}
+	policy := NewBucketPolicy(cfg, logrus.NewEntry(logrus.New()))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		signed bool
+		allow  bool
+	}{
+		{"get of public bucket", http.MethodGet, "/www/index.html", false, true},
+		{"head of public bucket", http.MethodHead, "/www/index.html", false, true},
+		{"get of public prefix", http.MethodGet, "/assets/public/logo.png", false, true},
+		{"get with response overrides", http.MethodGet, "/www/index.html?response-content-type=text%2Fhtml&versionId=v1", false, true},
+		{"get of part", http.MethodGet, "/www/index.html?partNumber=2", false, true},
+		{"get by sdk", http.MethodGet, "/www/index.html?x-id=GetObject", false, true},
+		{"get outside public prefix", http.MethodGet, "/assets/private/key", false, false},
+		{"dot segments out of public prefix", http.MethodGet, "/assets/public/../private/key", false, false},
+		{"encoded dot segments out of public prefix", http.MethodGet, "/assets/public/%2E%2E/private/key", false, false},
+		{"encoded slashes out of public prefix", http.MethodGet, "/assets/public%2F..%2Fprivate%2Fkey", false, false},
+		{"encoded slash in public prefix", http.MethodGet, "/assets/public/a%2Fb", false, false},
+		{"empty segment", http.MethodGet, "/assets/public//key", false, false},
+		{"current segment", http.MethodGet, "/www/./index.html", false, false},
+		{"get of private bucket", http.MethodGet, "/data/key", false, false},
+		{"put to public bucket", http.MethodPut, "/www/index.html", false, false},
+		{"delete from public bucket", http.MethodDelete, "/www/index.html", false, false},
+		{"list public bucket", http.MethodGet, "/www?list-type=2", false, false},
+		{"get of subresource", http.MethodGet, "/www/index.html?acl", false, false},
+		{"presigned get", http.MethodGet, "/www/index.html?X-Amz-Signature=abc", false, false},
+		{"signed get", http.MethodGet, "/www/index.html", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := routedRequest(tt.method, tt.target)
+			if tt.signed {
+				req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=key/20260101/us-east-1/s3/aws4_request")
+			}
+			assert.Equal(t, tt.allow, policy.AllowsAnonymousRead(req))
+		})
+	}
+
+	// Reloading without the rule closes the bucket
+	policy.Update(&config.Config{})
+	assert.False(t, policy.AllowsAnonymousRead(routedRequest(http.MethodGet, "/www/index.html")))
+}
+
+// routedRequest returns a request with the bucket and key variables the
+// object routes of the proxy set
+func routedRequest(method, target string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	router := mux.NewRouter().SkipClean(true)
+	router.Path("/{bucket}/{key:.*}")
+	var match mux.RouteMatch
+	if router.Match(req, &match) {
+		req = mux.SetURLVars(req, match.Vars)
+	}
+	return req
+}
@@ -25,7 +25,7 @@ func (c *CORS) Middleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, HEAD, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, x-amz-*, Content-MD5, Content-Length")
-		w.Header().Set("Access-Control-Expose-Headers", "ETag, x-amz-*, Content-Length")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, x-amz-*, Content-Length, X-S3ep-Recommended-Part-Size")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
 		// Handle preflight requests
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// Hardening rejects requests that exceed the configured listener limits or
+// address an unknown host, and sets security headers on every response
+type Hardening struct {
+	limits      config.ListenerConfig
+	tlsEnabled  bool
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+}
+
+// NewHardening creates a new hardening middleware. limits is expected to have
+// defaults applied (see config.Config.GetListenerConfig).
+func NewHardening(limits config.ListenerConfig, tlsEnabled bool, logger *logrus.Entry) *Hardening {
+	allowed := make([]string, 0, len(limits.AllowedHosts))
+	for _, host := range limits.AllowedHosts {
+		allowed = append(allowed, strings.ToLower(strings.TrimSuffix(host, ".")))
+	}
+	limits.AllowedHosts = allowed
+
+	return &Hardening{
+		limits:      limits,
+		tlsEnabled:  tlsEnabled,
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (h *Hardening) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if h.tlsEnabled && h.limits.HSTSMaxAge > 0 {
+			w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(h.limits.HSTSMaxAge))
+		}
+
+		if !h.hostAllowed(r.Host) {
+			h.reject(w, r, http.StatusBadRequest, "InvalidRequest", "The Host header does not match a configured domain")
+			return
+		}
+
+		// Count before parsing so a huge query string is never split into a map
+		if h.limits.MaxQueryParams > 0 && r.URL.RawQuery != "" &&
+			strings.Count(r.URL.RawQuery, "&")+1 > h.limits.MaxQueryParams {
+			h.reject(w, r, http.StatusBadRequest, "InvalidArgument", "Too many query parameters")
+			return
+		}
+
+		if h.limits.MaxContentLength > 0 {
+			if r.ContentLength > h.limits.MaxContentLength || h.decodedLengthTooLarge(r) {
+				h.reject(w, r, http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed size")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodedLengthTooLarge checks the payload size announced by aws-chunked
+// uploads, which is not covered by Content-Length
+func (h *Hardening) decodedLengthTooLarge(r *http.Request) bool {
+	value := r.Header.Get("X-Amz-Decoded-Content-Length")
+	if value == "" {
+		return false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	return err != nil || n < 0 || n > h.limits.MaxContentLength
+}
+
+// hostAllowed reports whether host (with optional port) equals a configured
+// domain or is a subdomain of one (virtual-hosted style bucket addressing)
+func (h *Hardening) hostAllowed(host string) bool {
+	if len(h.limits.AllowedHosts) == 0 {
+		return true
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+
+	for _, allowed := range h.limits.AllowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hardening) reject(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	h.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method":         r.Method,
+		"host":           r.Host,
+		"remote_addr":    r.RemoteAddr,
+		"content_length": r.ContentLength,
+		"code":           code,
+	}).Warn("Rejected request by listener limits")
+
+	// Do not read an oversized or unwanted body to keep the connection alive
+	w.Header().Set("Connection", "close")
+	h.errorWriter.WriteError(w, r, status, code, message)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func newTestHardening(listener config.ListenerConfig, tlsEnabled bool) http.Handler {
+	cfg := &config.Config{Listener: listener}
+	h := NewHardening(cfg.GetListenerConfig(), tlsEnabled, logrus.NewEntry(logrus.New()))
+	return h.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestHardening_AllowedHosts(t *testing.T) {
+	handler := newTestHardening(config.ListenerConfig{AllowedHosts: []string{"S3.Example.com"}}, false)
+
+	tests := map[string]int{
+		"s3.example.com":           http.StatusOK,
+		"s3.example.com:8080":      http.StatusOK,
+		"my-bucket.s3.example.com": http.StatusOK,
+		"s3.example.com.":          http.StatusOK,
+		"evil.com":                 http.StatusBadRequest,
+		"nots3.example.com":        http.StatusBadRequest,
+		"s3.example.com.evil.com":  http.StatusBadRequest,
+		"":                         http.StatusBadRequest,
+	}
+	for host, status := range tests {
+		t.Run(host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/bucket", nil)
+			req.Host = host
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code)
+		})
+	}
+}
+
+func TestHardening_MaxQueryParams(t *testing.T) {
+	handler := newTestHardening(config.ListenerConfig{MaxQueryParams: 3}, false)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket?list-type=2&prefix=a&max-keys=10", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket?"+strings.Repeat("a=1&", 10), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidArgument")
+}
+
+func TestHardening_ContentLength(t *testing.T) {
+	handler := newTestHardening(config.ListenerConfig{MaxContentLength: 1024}, false)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("small"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("small"))
+	req.ContentLength = 1 << 50
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "EntityTooLarge")
+
+	req = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("small"))
+	req.Header.Set("X-Amz-Decoded-Content-Length", "999999999999")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHardening_SecurityHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHardening(config.ListenerConfig{HSTSMaxAge: 31536000}, true).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	newTestHardening(config.ListenerConfig{HSTSMaxAge: 31536000}, false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "HSTS is only sent over TLS")
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// ErrInvalidKey is returned for object keys the key canonicalization rejects
+var ErrInvalidKey = errors.New("invalid object key")
+
+// originalPathKey is the context key of the escaped request path as the
+// client sent it, before the key was canonicalized
+type originalPathKey struct{}
+
+// KeyCanonicalizer rewrites the object key of path-style requests to its
+// canonical form before routing, so handlers, scope checks and the backend
+// all see the same key no matter how the client spelled it. It has to wrap
+// the router: routing on a non-canonical path would split bucket and key
+// differently than the backend does.
+type KeyCanonicalizer struct {
+	cfg         config.KeyCanonicalizationConfig
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+}
+
+// NewKeyCanonicalizer creates a new key canonicalization middleware. Empty
+// settings select the S3 compatible defaults.
+func NewKeyCanonicalizer(cfg config.KeyCanonicalizationConfig, logger *logrus.Entry) *KeyCanonicalizer {
+	if cfg.Slashes == "" {
+		cfg.Slashes = config.KeySlashesPreserve
+	}
+	if cfg.DotSegments == "" {
+		cfg.DotSegments = config.KeyDotSegmentsPreserve
+	}
+	if cfg.EncodedSlashes == "" {
+		cfg.EncodedSlashes = config.KeyEncodedSlashesDecode
+	}
+	return &KeyCanonicalizer{
+		cfg:         cfg,
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (k *KeyCanonicalizer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if bucket == "" || key == "" {
+			// Service and bucket requests have no key
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if k.cfg.EncodedSlashes == config.KeyEncodedSlashesReject && strings.Contains(strings.ToUpper(r.URL.RawPath), "%2F") {
+			k.reject(w, r, fmt.Errorf("%w: encoded slashes are not allowed", ErrInvalidKey))
+			return
+		}
+
+		canonical, err := k.CanonicalKey(key)
+		if err != nil {
+			k.reject(w, r, err)
+			return
+		}
+
+		// The signature covers the path the client sent
+		r = r.WithContext(context.WithValue(r.Context(), originalPathKey{}, r.URL.EscapedPath()))
+		if canonical != key {
+			k.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+				"bucket":        bucket,
+				"key":           key,
+				"canonical_key": canonical,
+			}).Debug("Canonicalized object key")
+
+			u := *r.URL
+			u.Path = "/" + bucket + "/" + canonical
+			u.RawPath = ""
+			r.URL = &u
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CanonicalKey returns the canonical form of a decoded object key. Slashes are
+// collapsed first, then dot segments are handled. A key that would become
+// empty is rejected, since the request would address the bucket instead.
+func (k *KeyCanonicalizer) CanonicalKey(key string) (string, error) {
+	canonical := key
+	if k.cfg.Slashes == config.KeySlashesCollapse {
+		canonical = collapseSlashes(canonical)
+	}
+
+	switch k.cfg.DotSegments {
+	case config.KeyDotSegmentsReject:
+		for _, segment := range strings.Split(canonical, "/") {
+			if segment == "." || segment == ".." {
+				return "", fmt.Errorf("%w: %q segments are not allowed", ErrInvalidKey, segment)
+			}
+		}
+	case config.KeyDotSegmentsRemove:
+		var err error
+		if canonical, err = removeDotSegments(canonical); err != nil {
+			return "", err
+		}
+	}
+
+	if canonical == "" {
+		return "", fmt.Errorf("%w: key %q is empty after canonicalization", ErrInvalidKey, key)
+	}
+	return canonical, nil
+}
+
+// collapseSlashes folds runs of slashes into one and drops a leading slash.
+// A trailing slash is kept, it marks a folder object.
+func collapseSlashes(key string) string {
+	var b strings.Builder
+	b.Grow(len(key))
+	previous := byte('/')
+	for i := 0; i < len(key); i++ {
+		if key[i] == '/' && previous == '/' {
+			continue
+		}
+		previous = key[i]
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// removeDotSegments drops "." segments and resolves ".." against the previous
+// segment like RFC 3986 does for paths. Resolving above the bucket is an
+// error. A key ending in a dot segment keeps its trailing slash.
+func removeDotSegments(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	resolved := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		switch segment {
+		case ".":
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("%w: %q leaves the bucket", ErrInvalidKey, key)
+			}
+			resolved = resolved[:len(resolved)-1]
+		default:
+			resolved = append(resolved, segment)
+			continue
+		}
+		if i == len(segments)-1 {
+			resolved = append(resolved, "")
+		}
+	}
+	return strings.Join(resolved, "/"), nil
+}
+
+func (k *KeyCanonicalizer) reject(w http.ResponseWriter, r *http.Request, err error) {
+	k.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}).WithError(err).Warn("Rejected request by key canonicalization")
+
+	k.errorWriter.WriteError(w, r, http.StatusBadRequest, "InvalidURI", "Couldn't parse the specified URI: "+err.Error())
+}
+
+// originalPath returns the escaped request path as the client sent it
+func originalPath(r *http.Request) string {
+	if path, ok := r.Context().Value(originalPathKey{}).(string); ok {
+		return path
+	}
+	return r.URL.EscapedPath()
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// rejected marks keys the configuration rejects in the canonicalization matrix
+const rejected = "<rejected>"
+
+func TestKeyCanonicalizer_CanonicalKey(t *testing.T) {
+	configs := []struct {
+		name string
+		cfg  config.KeyCanonicalizationConfig
+	}{
+		{"preserve/preserve", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesPreserve, DotSegments: config.KeyDotSegmentsPreserve}},
+		{"preserve/remove", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesPreserve, DotSegments: config.KeyDotSegmentsRemove}},
+		{"preserve/reject", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesPreserve, DotSegments: config.KeyDotSegmentsReject}},
+		{"collapse/preserve", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesCollapse, DotSegments: config.KeyDotSegmentsPreserve}},
+		{"collapse/remove", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesCollapse, DotSegments: config.KeyDotSegmentsRemove}},
+		{"collapse/reject", config.KeyCanonicalizationConfig{Slashes: config.KeySlashesCollapse, DotSegments: config.KeyDotSegmentsReject}},
+	}
+
+	// Expected canonical key per configuration, in the order of configs
+	matrix := []struct {
+		key      string
+		expected [6]string
+	}{
+		{"a/b", [6]string{"a/b", "a/b", "a/b", "a/b", "a/b", "a/b"}},
+		{"a/b/", [6]string{"a/b/", "a/b/", "a/b/", "a/b/", "a/b/", "a/b/"}},
+		{"a//b", [6]string{"a//b", "a//b", "a//b", "a/b", "a/b", "a/b"}},
+		{"a///b//", [6]string{"a///b//", "a///b//", "a///b//", "a/b/", "a/b/", "a/b/"}},
+		{"/a", [6]string{"/a", "/a", "/a", "a", "a", "a"}},
+		{"//", [6]string{"//", "//", "//", rejected, rejected, rejected}},
+		{"./c", [6]string{"./c", "c", rejected, "./c", "c", rejected}},
+		{".", [6]string{".", rejected, rejected, ".", rejected, rejected}},
+		{"..", [6]string{"..", rejected, rejected, "..", rejected, rejected}},
+		{"a/./b", [6]string{"a/./b", "a/b", rejected, "a/./b", "a/b", rejected}},
+		{"a/../b", [6]string{"a/../b", "b", rejected, "a/../b", "b", rejected}},
+		{"a/b/..", [6]string{"a/b/..", "a/", rejected, "a/b/..", "a/", rejected}},
+		{"a/b/.", [6]string{"a/b/.", "a/b/", rejected, "a/b/.", "a/b/", rejected}},
+		{"a/..", [6]string{"a/..", rejected, rejected, "a/..", rejected, rejected}},
+		{"../a", [6]string{"../a", rejected, rejected, "../a", rejected, rejected}},
+		{"a/../../b", [6]string{"a/../../b", rejected, rejected, "a/../../b", rejected, rejected}},
+		{"a//./b", [6]string{"a//./b", "a//b", rejected, "a/./b", "a/b", rejected}},
+		{"a//../b", [6]string{"a//../b", "a/b", rejected, "a/../b", "b", rejected}},
+		{"/./a", [6]string{"/./a", "/a", rejected, "./a", "a", rejected}},
+		{"a/.b/..c/...", [6]string{"a/.b/..c/...", "a/.b/..c/...", "a/.b/..c/...", "a/.b/..c/...", "a/.b/..c/...", "a/.b/..c/..."}},
+		{"a b/ü%/+", [6]string{"a b/ü%/+", "a b/ü%/+", "a b/ü%/+", "a b/ü%/+", "a b/ü%/+", "a b/ü%/+"}},
+	}
+
+	for i, c := range configs {
+		canonicalizer := NewKeyCanonicalizer(c.cfg, logrus.NewEntry(logrus.New()))
+		for _, tt := range matrix {
+			t.Run(c.name+"/"+tt.key, func(t *testing.T) {
+				canonical, err := canonicalizer.CanonicalKey(tt.key)
+				if tt.expected[i] == rejected {
+					assert.ErrorIs(t, err, ErrInvalidKey)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected[i], canonical)
+
+				// Canonical keys are fixed points
+				again, err := canonicalizer.CanonicalKey(canonical)
+				require.NoError(t, err)
+				assert.Equal(t, canonical, again)
+			})
+		}
+	}
+}
+
+// newTestKeyRouter routes like the proxy and answers with the key the handler sees
+func newTestKeyRouter(cfg config.KeyCanonicalizationConfig) http.Handler {
+	router := mux.NewRouter()
+	router.SkipClean(true)
+	router.HandleFunc("/{bucket}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Route", "bucket")
+	})
+	router.HandleFunc("/{bucket}/{key:.*}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Route", "object")
+		w.Header().Set("X-Key", mux.Vars(r)["key"])
+		w.Header().Set("X-Original-Path", originalPath(r))
+	})
+	return NewKeyCanonicalizer(cfg, logrus.NewEntry(logrus.New())).Middleware(router)
+}
+
+func TestKeyCanonicalizer_Middleware(t *testing.T) {
+	collapseRemove := config.KeyCanonicalizationConfig{Slashes: config.KeySlashesCollapse, DotSegments: config.KeyDotSegmentsRemove}
+	rejectEncoded := config.KeyCanonicalizationConfig{EncodedSlashes: config.KeyEncodedSlashesReject}
+
+	tests := []struct {
+		name   string
+		cfg    config.KeyCanonicalizationConfig
+		path   string
+		status int
+		route  string
+		key    string
+	}{
+		{name: "default keeps double slashes", path: "/bucket/a//b", status: http.StatusOK, route: "object", key: "a//b"},
+		{name: "default keeps dot segments", path: "/bucket/./c", status: http.StatusOK, route: "object", key: "./c"},
+		{name: "default keeps leading slash", path: "/bucket//a", status: http.StatusOK, route: "object", key: "/a"},
+		{name: "default decodes encoded slash", path: "/bucket/a%2Fb", status: http.StatusOK, route: "object", key: "a/b"},
+		{name: "default decodes once", path: "/bucket/a%252Fb", status: http.StatusOK, route: "object", key: "a%2Fb"},
+		{name: "encoded space", path: "/bucket/a%20b", status: http.StatusOK, route: "object", key: "a b"},
+		{name: "bucket", path: "/bucket", status: http.StatusOK, route: "bucket"},
+		{name: "collapse", cfg: collapseRemove, path: "/bucket//a//b", status: http.StatusOK, route: "object", key: "a/b"},
+		{name: "collapse encoded slashes", cfg: collapseRemove, path: "/bucket/a%2F%2Fb", status: http.StatusOK, route: "object", key: "a/b"},
+		{name: "remove dot segments", cfg: collapseRemove, path: "/bucket/x/../y/./z", status: http.StatusOK, route: "object", key: "y/z"},
+		{name: "dot segments above the bucket", cfg: collapseRemove, path: "/bucket/../other/key", status: http.StatusBadRequest},
+		{name: "key that collapses to nothing", cfg: collapseRemove, path: "/bucket/./", status: http.StatusBadRequest},
+		{name: "reject encoded slash", cfg: rejectEncoded, path: "/bucket/a%2fb", status: http.StatusBadRequest},
+		{name: "reject encoded slash allows plain slash", cfg: rejectEncoded, path: "/bucket/a/b", status: http.StatusOK, route: "object", key: "a/b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestKeyRouter(tt.cfg).ServeHTTP(w, httptest.NewRequest(http.MethodPut, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				assert.Contains(t, w.Body.String(), "InvalidURI")
+				return
+			}
+			assert.Equal(t, tt.route, w.Header().Get("X-Route"))
+			assert.Equal(t, tt.key, w.Header().Get("X-Key"))
+			if tt.route == "object" {
+				assert.Equal(t, tt.path, w.Header().Get("X-Original-Path"))
+			}
+		})
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	tests := map[string]string{
+		"":                "/",
+		"/":               "/",
+		"/bucket/a/b":     "/bucket/a/b",
+		"/bucket/a//b":    "/bucket/a//b",
+		"/bucket/./c":     "/bucket/./c",
+		"/bucket/a%2Fb":   "/bucket/a%2Fb",
+		"/bucket/a%2fb":   "/bucket/a%2Fb",
+		"/bucket/a%20b":   "/bucket/a%20b",
+		"/bucket/a+b":     "/bucket/a%2Bb",
+		"/bucket/a~b*c":   "/bucket/a~b%2Ac",
+		"/bucket/%C3%BC":  "/bucket/%C3%BC",
+		"/bucket/a%252Fb": "/bucket/a%252Fb",
+	}
+	for path, expected := range tests {
+		assert.Equal(t, expected, canonicalURI(path), path)
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// LicenseGate rejects uploads with 403 AccessDenied while the license is
+// restricted, i.e. expired beyond its grace period. Reads, listings and
+// deletes keep working so existing data stays accessible.
+type LicenseGate struct {
+	restricted  func() bool
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+}
+
+// NewLicenseGate creates the license middleware. restricted is called per
+// request.
+func NewLicenseGate(restricted func() bool, logger *logrus.Entry) *LicenseGate {
+	return &LicenseGate{
+		restricted:  restricted,
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (g *LicenseGate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !uploadsObjectData(r) || !g.restricted() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		g.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Warn("Rejected upload: the license has expired")
+		g.errorWriter.WriteError(w, r, http.StatusForbidden, "AccessDenied",
+			"The proxy license has expired; uploads are rejected until it is renewed")
+	})
+}
+
+// uploadsObjectData reports whether r stores object data: PutObject,
+// CopyObject, CreateMultipartUpload and UploadPart
+func uploadsObjectData(r *http.Request) bool {
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		return false
+	}
+	return storesObjectData(r) || (r.Method == http.MethodPut && r.URL.Query().Has("uploadId"))
+}
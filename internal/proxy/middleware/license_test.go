@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLicenseGate(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{"put object", http.MethodPut, "/bucket/key", http.StatusForbidden},
+		{"create multipart upload", http.MethodPost, "/bucket/key?uploads", http.StatusForbidden},
+		{"upload part", http.MethodPut, "/bucket/key?uploadId=1&partNumber=1", http.StatusForbidden},
+		{"get object", http.MethodGet, "/bucket/key", http.StatusOK},
+		{"head object", http.MethodHead, "/bucket/key", http.StatusOK},
+		{"list objects", http.MethodGet, "/bucket?list-type=2", http.StatusOK},
+		{"delete object", http.MethodDelete, "/bucket/key", http.StatusOK},
+		{"put tagging", http.MethodPut, "/bucket/key?tagging", http.StatusOK},
+		{"create bucket", http.MethodPut, "/bucket", http.StatusOK},
+		{"complete multipart upload", http.MethodPost, "/bucket/key?uploadId=1", http.StatusOK},
+	}
+
+	restricted := true
+	gate := NewLicenseGate(func() bool { return restricted }, logrus.NewEntry(logrus.New()))
+	handler := gate.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusForbidden {
+				assert.Contains(t, rec.Body.String(), "AccessDenied")
+			}
+		})
+	}
+
+	// a renewed license lifts the restriction
+	restricted = false
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/bucket/key", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -41,7 +41,7 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		l.logger.WithFields(logrus.Fields{
+		l.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"method":      r.Method,
 			"path":        r.URL.Path,
 			"status":      wrapped.statusCode,
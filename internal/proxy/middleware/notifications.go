@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/notification"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// maxNotificationResponse bounds the response bodies kept to read the result
+// of CopyObject, CompleteMultipartUpload and DeleteObjects
+const maxNotificationResponse = 4 << 20
+
+// Notifications emits S3 event notifications for the successful object
+// writes and deletes of clients: PutObject, CopyObject,
+// CompleteMultipartUpload, DeleteObject and DeleteObjects. Without an emitter
+// it passes requests through.
+type Notifications struct {
+	emitter *notification.Emitter
+	logger  *logrus.Entry
+}
+
+// NewNotifications creates the event notification middleware; emitter may
+// be nil
+func NewNotifications(emitter *notification.Emitter, logger *logrus.Entry) *Notifications {
+	return &Notifications{emitter: emitter, logger: logger}
+}
+
+// Middleware returns the HTTP middleware function
+func (n *Notifications) Middleware(next http.Handler) http.Handler {
+	if n.emitter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucket, key := vars["bucket"], vars["key"]
+		if bucket == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch s3Operation(r, bucket, key) {
+		case "PutObject":
+			tracked := newStatusWriter(w, nil)
+			next.ServeHTTP(tracked, r)
+			if succeeded(tracked) {
+				event := n.newEvent(r, tracked, notification.ObjectCreatedPut, bucket, key)
+				event.S3.Object.Size = plaintextLength(r)
+				event.S3.Object.ETag = unquote(tracked.Header().Get("ETag"))
+				n.emitter.Emit(event)
+			}
+		case "CopyObject":
+			capture := &responseCapture{statusWriter: newStatusWriter(w, nil)}
+			next.ServeHTTP(capture, r)
+			var result struct {
+				ETag string `xml:"ETag"`
+			}
+			if succeeded(capture.statusWriter) && capture.decode("CopyObjectResult", &result) {
+				event := n.newEvent(r, capture.statusWriter, notification.ObjectCreatedCopy, bucket, key)
+				event.S3.Object.ETag = unquote(result.ETag)
+				n.emitter.Emit(event)
+			}
+		case "CompleteMultipartUpload":
+			// S3 reports errors of completions in a 200 response, too
+			capture := &responseCapture{statusWriter: newStatusWriter(w, nil)}
+			next.ServeHTTP(capture, r)
+			var result struct {
+				ETag string `xml:"ETag"`
+			}
+			if succeeded(capture.statusWriter) && capture.decode("CompleteMultipartUploadResult", &result) {
+				event := n.newEvent(r, capture.statusWriter, notification.ObjectCreatedCompleteMultipartUpload, bucket, key)
+				event.S3.Object.ETag = unquote(result.ETag)
+				n.emitter.Emit(event)
+			}
+		case "DeleteObject":
+			tracked := newStatusWriter(w, nil)
+			next.ServeHTTP(tracked, r)
+			if succeeded(tracked) {
+				name := notification.ObjectRemovedDelete
+				if tracked.Header().Get("X-Amz-Delete-Marker") == "true" {
+					name = notification.ObjectRemovedDeleteMarkerCreated
+				}
+				n.emitter.Emit(n.newEvent(r, tracked, name, bucket, key))
+			}
+		case "DeleteObjects":
+			n.deleteObjects(w, r, next, bucket)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// deleteObjects emits an event per key of a DeleteObjects request that was
+// not reported as failed. Quiet requests only list the failed keys in the
+// response, so the keys are taken from the request.
+func (n *Notifications) deleteObjects(w http.ResponseWriter, r *http.Request, next http.Handler, bucket string) {
+	var requested struct {
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if r.Body != nil {
+		// The handler reads the body as sent, including what is beyond the limit
+		original := r.Body
+		body, err := io.ReadAll(io.LimitReader(original, maxDeleteObjectsBody+1))
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), original), Closer: original}
+		if err == nil {
+			_ = request.DecodeXML(bytes.NewReader(body), "Delete", &requested, request.XMLLimits{MaxBytes: maxDeleteObjectsBody})
+		}
+	}
+
+	capture := &responseCapture{statusWriter: newStatusWriter(w, nil)}
+	next.ServeHTTP(capture, r)
+	var result struct {
+		Deleted []struct {
+			Key                   string `xml:"Key"`
+			VersionID             string `xml:"VersionId"`
+			DeleteMarker          bool   `xml:"DeleteMarker"`
+			DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId"`
+		} `xml:"Deleted"`
+		Errors []struct {
+			Key string `xml:"Key"`
+		} `xml:"Error"`
+	}
+	if !succeeded(capture.statusWriter) || !capture.decode("DeleteResult", &result) {
+		return
+	}
+
+	failed := make(map[string]bool, len(result.Errors))
+	for _, e := range result.Errors {
+		failed[e.Key] = true
+	}
+	emitted := make(map[string]bool, len(requested.Objects))
+	for _, deleted := range result.Deleted {
+		name, versionID := notification.ObjectRemovedDelete, deleted.VersionID
+		if deleted.DeleteMarker {
+			name, versionID = notification.ObjectRemovedDeleteMarkerCreated, deleted.DeleteMarkerVersionID
+		}
+		event := n.newEvent(r, capture.statusWriter, name, bucket, deleted.Key)
+		event.S3.Object.VersionID = versionID
+		n.emitter.Emit(event)
+		emitted[deleted.Key] = true
+	}
+	for _, object := range requested.Objects {
+		if !emitted[object.Key] && !failed[object.Key] {
+			n.emitter.Emit(n.newEvent(r, capture.statusWriter, notification.ObjectRemovedDelete, bucket, object.Key))
+			emitted[object.Key] = true
+		}
+	}
+}
+
+// newEvent creates an event of the request with the requester and the
+// response headers of w
+func (n *Notifications) newEvent(r *http.Request, w *statusWriter, name, bucket, key string) notification.Event {
+	event := notification.NewEvent(name, bucket, key, time.Now())
+	event.UserIdentity.PrincipalID = request.AccessKeyID(r)
+	event.RequestParameters.SourceIPAddress = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		event.RequestParameters.SourceIPAddress = host
+	}
+	if requestID := w.Header().Get("X-Amz-Request-Id"); requestID != "" {
+		event.ResponseElements["x-amz-request-id"] = requestID
+	}
+	if id2 := w.Header().Get("X-Amz-Id-2"); id2 != "" {
+		event.ResponseElements["x-amz-id-2"] = id2
+	}
+	event.S3.Object.VersionID = w.Header().Get("X-Amz-Version-Id")
+	return event
+}
+
+// succeeded reports whether the handler answered with a 2xx status
+func succeeded(w *statusWriter) bool {
+	return w.status >= 200 && w.status <= 299
+}
+
+// plaintextLength returns the length of the object uploaded by r, without
+// the chunk signatures of aws-chunked uploads
+func plaintextLength(r *http.Request) int64 {
+	if decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil {
+		return decoded
+	}
+	return max(r.ContentLength, 0)
+}
+
+func unquote(etag string) string {
+	if unquoted, err := strconv.Unquote(etag); err == nil {
+		return unquoted
+	}
+	return etag
+}
+
+// responseCapture keeps a copy of a response body of up to
+// maxNotificationResponse bytes
+type responseCapture struct {
+	*statusWriter
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (c *responseCapture) Write(p []byte) (int, error) {
+	n, err := c.statusWriter.Write(p)
+	if !c.overflow {
+		if c.buf.Len()+n > maxNotificationResponse {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// decode decodes the captured body into v and reports whether it is a
+// complete document named root
+func (c *responseCapture) decode(root string, v interface{}) bool {
+	return !c.overflow && request.DecodeXML(bytes.NewReader(c.buf.Bytes()), root, v, request.XMLLimits{}) == nil
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/notification"
+)
+
+// eventRecorder keeps the events emitted to it
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []notification.Event
+}
+
+func (t *eventRecorder) Send(_ context.Context, events []notification.Event) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events = append(t.events, events...)
+	return nil
+}
+
+func (t *eventRecorder) Close() error { return nil }
+
+// notifiedObjectStore answers object requests like S3 for the middleware
+func notifiedObjectStore(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	query := r.URL.Query()
+	w.Header().Set("X-Amz-Request-Id", "req-1")
+	switch {
+	case strings.Contains(r.URL.Path, "denied"):
+		w.WriteHeader(http.StatusForbidden)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		if strings.Contains(string(body), "<Quiet>true</Quiet>") {
+			_, _ = io.WriteString(w, `<DeleteResult><Error><Key>locked</Key><Code>AccessDenied</Code></Error></DeleteResult>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<DeleteResult><Deleted><Key>a</Key><DeleteMarker>true</DeleteMarker><DeleteMarkerVersionId>v2</DeleteMarkerVersionId></Deleted><Deleted><Key>b</Key><VersionId>v1</VersionId></Deleted></DeleteResult>`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		if strings.Contains(r.URL.Path, "broken") {
+			_, _ = io.WriteString(w, `<Error><Code>InternalError</Code></Error>`)
+			return
+		}
+		_, _ = io.WriteString(w, ` <CompleteMultipartUploadResult><ETag>"multi-2"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		_, _ = io.WriteString(w, `<CopyObjectResult><ETag>"copied"</ETag></CopyObjectResult>`)
+	case r.Method == http.MethodPut && !query.Has("tagging"):
+		w.Header().Set("ETag", `"put"`)
+		w.Header().Set("X-Amz-Version-Id", "v1")
+	case r.Method == http.MethodDelete:
+		w.Header().Set("X-Amz-Delete-Marker", "true")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	emitter := notification.NewEmitter("us-east-1", logrus.NewEntry(logger))
+	target := &eventRecorder{}
+	emitter.AddTarget(target, notification.TargetOptions{Name: "test", QueueSize: 100, BatchSize: 100, FlushInterval: time.Hour, Timeout: time.Second})
+
+	router := mux.NewRouter()
+	router.Use(NewNotifications(emitter, logrus.NewEntry(logger)).Middleware)
+	router.HandleFunc("/{bucket}", notifiedObjectStore)
+	router.HandleFunc("/{bucket}/{key:.*}", notifiedObjectStore)
+
+	requests := []struct {
+		method, target, body string
+		header               http.Header
+	}{
+		{method: http.MethodPut, target: "/bucket/dir/put.txt", body: "hello", header: http.Header{"Authorization": {"AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/s3/aws4_request"}}},
+		{method: http.MethodPut, target: "/bucket/copy", header: http.Header{"X-Amz-Copy-Source": {"/bucket/dir/put.txt"}}},
+		{method: http.MethodPost, target: "/bucket/multi?uploadId=1", body: "<CompleteMultipartUpload/>"},
+		{method: http.MethodDelete, target: "/bucket/removed"},
+		{method: http.MethodPost, target: "/bucket?delete", body: "<Delete><Object><Key>a</Key></Object><Object><Key>b</Key></Object></Delete>"},
+		{method: http.MethodPost, target: "/bucket?delete", body: "<Delete><Quiet>true</Quiet><Object><Key>c</Key></Object><Object><Key>locked</Key></Object></Delete>"},
+		// No events: failures, reads and other operations
+		{method: http.MethodPut, target: "/bucket/denied"},
+		{method: http.MethodPost, target: "/bucket/broken?uploadId=1", body: "<CompleteMultipartUpload/>"},
+		{method: http.MethodGet, target: "/bucket/dir/put.txt"},
+		{method: http.MethodPut, target: "/bucket/dir/put.txt?tagging", body: "<Tagging/>"},
+		{method: http.MethodPost, target: "/bucket/multi?uploads"},
+	}
+	for _, req := range requests {
+		serveObject(router, req.method, req.target, req.body, req.header)
+	}
+	require.NoError(t, emitter.Close())
+
+	require.Len(t, target.events, 7)
+	put := target.events[0]
+	assert.Equal(t, notification.ObjectCreatedPut, put.EventName)
+	assert.Equal(t, "dir/put.txt", put.S3.Object.Key)
+	assert.Equal(t, int64(5), put.S3.Object.Size)
+	assert.Equal(t, "put", put.S3.Object.ETag)
+	assert.Equal(t, "v1", put.S3.Object.VersionID)
+	assert.Equal(t, "AKID", put.UserIdentity.PrincipalID)
+	assert.Equal(t, "192.0.2.1", put.RequestParameters.SourceIPAddress)
+	assert.Equal(t, "req-1", put.ResponseElements["x-amz-request-id"])
+
+	assert.Equal(t, notification.ObjectCreatedCopy, target.events[1].EventName)
+	assert.Equal(t, "copied", target.events[1].S3.Object.ETag)
+	assert.Equal(t, notification.ObjectCreatedCompleteMultipartUpload, target.events[2].EventName)
+	assert.Equal(t, "multi-2", target.events[2].S3.Object.ETag)
+	assert.Equal(t, notification.ObjectRemovedDeleteMarkerCreated, target.events[3].EventName)
+
+	var deleted []string
+	for _, event := range target.events[4:] {
+		deleted = append(deleted, event.EventName+" "+event.S3.Object.Key+" "+event.S3.Object.VersionID)
+	}
+	assert.Equal(t, []string{
+		notification.ObjectRemovedDeleteMarkerCreated + " a v2",
+		notification.ObjectRemovedDelete + " b v1",
+		notification.ObjectRemovedDelete + " c ",
+	}, deleted)
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/objectcache"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// uncacheableHeaders select responses that differ from the plain object
+// read, or need the backend to answer them
+var uncacheableHeaders = []string{
+	"Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+	"X-Amz-Server-Side-Encryption-Customer-Algorithm",
+	"X-Amz-Server-Side-Encryption-Customer-Key",
+	orchestration.EncryptionContextHeader,
+}
+
+// requestScopedHeaders are response headers that belong to one response only
+var requestScopedHeaders = []string{"X-Amz-Request-Id", "X-Amz-Id-2", "Date", "Connection"}
+
+// ObjectCache serves repeated GETs of small objects from an
+// objectcache.Cache. Plain GetObject responses are cached: no query besides
+// x-id, no Range, conditional, SSE-C or encryption context header. HEAD is
+// answered from cached GETs. Any other request to an object, and DeleteObjects
+// and DeleteBucket, invalidates what is cached for it once answered.
+type ObjectCache struct {
+	cache     *objectcache.Cache
+	buckets   []string // glob patterns, empty = every bucket
+	perClient bool     // keep entries per access key, for tenant keys
+	logger    *logrus.Entry
+}
+
+// NewObjectCache creates the object cache middleware. With perClient, a
+// client is only served responses it read itself, as clients of different
+// tenants may not be able to decrypt each other's objects.
+func NewObjectCache(cache *objectcache.Cache, buckets []string, perClient bool, logger *logrus.Entry) *ObjectCache {
+	return &ObjectCache{cache: cache, buckets: buckets, perClient: perClient, logger: logger}
+}
+
+// Middleware returns the HTTP middleware function
+func (c *ObjectCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucket, key := vars["bucket"], vars["key"]
+
+		switch {
+		case bucket == "":
+			next.ServeHTTP(w, r)
+		case key == "":
+			if r.Method == http.MethodDelete && r.URL.RawQuery == "" || r.Method == http.MethodPost && r.URL.Query().Has("delete") {
+				defer c.cache.InvalidateBucket(bucket)
+			}
+			next.ServeHTTP(w, r)
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			// Invalidate after the write, so that reads racing it are not stored
+			defer c.cache.Invalidate(bucket, key)
+			next.ServeHTTP(w, r)
+		case !c.cacheable(r, bucket):
+			next.ServeHTTP(w, r)
+		default:
+			c.serve(w, r, next, bucket, key)
+		}
+	})
+}
+
+// cacheable reports whether r reads a whole object of a cached bucket
+func (c *ObjectCache) cacheable(r *http.Request, bucket string) bool {
+	for name := range r.URL.Query() {
+		if name != "x-id" {
+			return false
+		}
+	}
+	for _, name := range uncacheableHeaders {
+		if r.Header.Get(name) != "" {
+			return false
+		}
+	}
+	if len(c.buckets) == 0 {
+		return true
+	}
+	for _, pattern := range c.buckets {
+		if matched, _ := path.Match(pattern, bucket); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// serve answers r from the cache, or passes a GET on and captures its response
+func (c *ObjectCache) serve(w http.ResponseWriter, r *http.Request, next http.Handler, bucket, key string) {
+	variant := ""
+	if c.perClient {
+		variant = request.AccessKeyID(r)
+	}
+
+	if object, body, ok := c.cache.Get(bucket, key, variant); ok {
+		defer body.Close() //nolint:errcheck // read-only body
+		header := w.Header()
+		for name, values := range object.Header {
+			header[name] = slices.Clone(values)
+		}
+		header.Set("Content-Length", strconv.FormatInt(object.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			if _, err := io.Copy(w, body); err != nil {
+				c.logger.WithContext(r.Context()).WithError(err).WithFields(logrus.Fields{"bucket": bucket, "key": key}).Debug("Failed to send cached object")
+			}
+		}
+		return
+	}
+	if r.Method != http.MethodGet {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// The fill also ends if the handler panics
+	fill := c.cache.Begin(bucket, key, variant)
+	capture := newCaptureWriter(w, c.cache.MaxObjectSize())
+	var body []byte
+	defer func() {
+		fill.Commit(objectcache.Object{Header: capture.cachedHeader}, body)
+	}()
+
+	next.ServeHTTP(capture, r)
+	if !capture.wroteHeader {
+		// Send the headers of a handler that wrote nothing
+		capture.WriteHeader(http.StatusOK)
+	}
+	body = capture.body()
+}
+
+// captureWriter passes a response on and keeps a copy of its headers and a
+// body of up to limit bytes. The handler writes into its own header map, so
+// that headers set by the middlewares around it are not captured.
+type captureWriter struct {
+	*statusWriter
+	header       http.Header
+	cachedHeader http.Header
+	buf          bytes.Buffer
+	limit        int64
+	overflow     bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	c := &captureWriter{header: make(http.Header), limit: limit}
+	c.statusWriter = newStatusWriter(w, func(status int) {
+		dst := w.Header()
+		for name, values := range c.header {
+			dst[name] = values
+		}
+		if status != http.StatusOK {
+			return
+		}
+		if length, err := strconv.ParseInt(c.header.Get("Content-Length"), 10, 64); err == nil && length > limit {
+			c.overflow = true
+			return
+		}
+		c.cachedHeader = c.header.Clone()
+		for _, name := range requestScopedHeaders {
+			c.cachedHeader.Del(name)
+		}
+	})
+	return c
+}
+
+// Header returns the header map of the handler
+func (c *captureWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	n, err := c.statusWriter.Write(p)
+	if !c.overflow && c.cachedHeader != nil {
+		if int64(c.buf.Len()+n) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// body returns the captured body of a complete 200 response, or nil
+func (c *captureWriter) body() []byte {
+	if c.cachedHeader == nil || c.overflow {
+		return nil
+	}
+	if length := c.cachedHeader.Get("Content-Length"); length != "" && length != strconv.Itoa(c.buf.Len()) {
+		// The handler gave up mid-stream
+		return nil
+	}
+	if c.buf.Len() == 0 {
+		return []byte{}
+	}
+	return c.buf.Bytes()
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/objectcache"
+)
+
+// cachedObjectStore answers GETs with the stored objects and counts them
+type cachedObjectStore struct {
+	objects map[string]string
+	gets    int
+}
+
+func (s *cachedObjectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["bucket"] + "/" + vars["key"]
+	switch r.Method {
+	case http.MethodGet:
+		s.gets++
+		body, ok := s.objects[id]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("X-Amz-Request-Id", "req")
+		_, _ = io.WriteString(w, body)
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[id] = string(data)
+	case http.MethodDelete:
+		delete(s.objects, id)
+	}
+}
+
+func newObjectCacheRouter(t *testing.T, store *cachedObjectStore, perClient bool) http.Handler {
+	t.Helper()
+	cache, err := objectcache.New(objectcache.Config{TTL: time.Minute, MaxObjectSize: 16, MemorySize: 64}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	router.Use(NewObjectCache(cache, []string{"cached-*"}, perClient, logrus.NewEntry(logrus.New())).Middleware)
+	router.Handle("/{bucket}", store)
+	router.Handle("/{bucket}/{key:.*}", store)
+	return router
+}
+
+func serveObject(handler http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestObjectCache_ServesAndInvalidates(t *testing.T) {
+	store := &cachedObjectStore{objects: map[string]string{"cached-a/key": "hello"}}
+	handler := newObjectCacheRouter(t, store, false)
+
+	for range 3 {
+		rec := serveObject(handler, http.MethodGet, "/cached-a/key?x-id=GetObject", "", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	}
+	assert.Equal(t, 1, store.gets)
+
+	// HEAD is answered from the cached GET, without request-scoped headers
+	rec := serveObject(handler, http.MethodHead, "/cached-a/key", "", nil)
+	assert.Equal(t, "5", rec.Header().Get("Content-Length"))
+	assert.Empty(t, rec.Header().Get("X-Amz-Request-Id"))
+	assert.Empty(t, rec.Body.String())
+
+	// A write through the proxy invalidates the object
+	serveObject(handler, http.MethodPut, "/cached-a/key", "world", nil)
+	assert.Equal(t, "world", serveObject(handler, http.MethodGet, "/cached-a/key", "", nil).Body.String())
+	assert.Equal(t, 2, store.gets)
+
+	serveObject(handler, http.MethodDelete, "/cached-a/key", "", nil)
+	assert.Equal(t, http.StatusNotFound, serveObject(handler, http.MethodGet, "/cached-a/key", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveObject(handler, http.MethodGet, "/cached-a/key", "", nil).Code, "errors are not cached")
+	assert.Equal(t, 4, store.gets)
+
+	// DeleteObjects invalidates the bucket
+	store.objects["cached-a/key"] = "again"
+	serveObject(handler, http.MethodGet, "/cached-a/key", "", nil)
+	delete(store.objects, "cached-a/key")
+	serveObject(handler, http.MethodPost, "/cached-a?delete", "<Delete/>", nil)
+	assert.Equal(t, http.StatusNotFound, serveObject(handler, http.MethodGet, "/cached-a/key", "", nil).Code)
+}
+
+func TestObjectCache_Bypass(t *testing.T) {
+	store := &cachedObjectStore{objects: map[string]string{
+		"cached-a/key":   "hello",
+		"cached-a/large": strings.Repeat("x", 17),
+		"other/key":      "hello",
+	}}
+	handler := newObjectCacheRouter(t, store, false)
+
+	requests := []struct {
+		target string
+		header http.Header
+	}{
+		{target: "/cached-a/key", header: http.Header{"Range": {"bytes=0-1"}}},
+		{target: "/cached-a/key", header: http.Header{"If-None-Match": {`"etag"`}}},
+		{target: "/cached-a/key", header: http.Header{"X-Amz-Server-Side-Encryption-Customer-Key": {"key"}}},
+		{target: "/cached-a/key", header: http.Header{"X-S3ep-Encryption-Context": {"team=a"}}},
+		{target: "/cached-a/key?versionId=v1"},
+		{target: "/cached-a/large"},
+		{target: "/other/key"},
+	}
+	for _, req := range requests {
+		serveObject(handler, http.MethodGet, req.target, "", req.header)
+		serveObject(handler, http.MethodGet, req.target, "", req.header)
+	}
+	assert.Equal(t, 2*len(requests), store.gets)
+}
+
+func TestObjectCache_PerClient(t *testing.T) {
+	store := &cachedObjectStore{objects: map[string]string{"cached-a/key": "hello"}}
+	handler := newObjectCacheRouter(t, store, true)
+	signedBy := func(accessKey string) http.Header {
+		return http.Header{"Authorization": {"AWS4-HMAC-SHA256 Credential=" + accessKey + "/20260101/us-east-1/s3/aws4_request"}}
+	}
+
+	serveObject(handler, http.MethodGet, "/cached-a/key", "", signedBy("tenant-a"))
+	serveObject(handler, http.MethodGet, "/cached-a/key", "", signedBy("tenant-a"))
+	assert.Equal(t, 1, store.gets)
+	serveObject(handler, http.MethodGet, "/cached-a/key", "", signedBy("tenant-b"))
+	assert.Equal(t, 2, store.gets)
+}
@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// RateLimiter enforces rate_limiting: every client gets a token bucket of
+// requests and one of body bytes. Requests without a request token, or sent
+// while the client's transfers are backlogged by more than a bandwidth burst,
+// are rejected with 503 SlowDown; request and response bodies are paced to
+// the bandwidth. It goes after authentication, so a client cannot spend the
+// budget of an access key it cannot sign for.
+type RateLimiter struct {
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+	now         func() time.Time
+
+	mu        sync.Mutex
+	config    config.RateLimitingConfig
+	overrides map[string]config.RateLimitClientConfig
+	clients   map[string]*list.Element
+	idle      *list.List // of *clientLimiter, most recently used first
+}
+
+// clientLimiter holds the buckets of one client; a nil bucket is unlimited
+type clientLimiter struct {
+	key       string
+	requests  *orchestration.TokenBucket
+	bandwidth *orchestration.TokenBucket
+}
+
+// NewRateLimiter creates the rate limiting middleware. cfg is expected to be
+// validated; a disabled cfg passes requests through.
+func NewRateLimiter(cfg config.RateLimitingConfig, logger *logrus.Entry) *RateLimiter {
+	l := &RateLimiter{
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+		now:         time.Now,
+	}
+	l.Update(cfg)
+	return l
+}
+
+// Update replaces the limits with those of a reloaded configuration. Clients
+// start over with full buckets of the new limits.
+func (l *RateLimiter) Update(cfg config.RateLimitingConfig) {
+	overrides := make(map[string]config.RateLimitClientConfig, len(cfg.Clients))
+	for _, client := range cfg.Clients {
+		overrides[client.AccessKeyID] = client
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = cfg
+	l.overrides = overrides
+	l.clients = make(map[string]*list.Element)
+	l.idle = list.New()
+}
+
+// Middleware returns the HTTP middleware function
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := l.client(r)
+		if client == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := l.now()
+
+		if client.requests != nil {
+			if wait, ok := client.requests.Take(now); !ok {
+				l.reject(w, r, client, "requests", wait)
+				return
+			}
+		}
+		if client.bandwidth != nil {
+			if wait := client.bandwidth.Backlog(now); wait > 0 {
+				l.reject(w, r, client, "bandwidth", wait)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &pacedBody{ReadCloser: r.Body, ctx: r.Context(), bucket: client.bandwidth, now: l.now}
+			}
+			w = &pacedWriter{ResponseWriter: w, ctx: r.Context(), bucket: client.bandwidth, now: l.now}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client returns the limiter of the client of r, creating it on first use,
+// or nil if rate limiting is disabled
+func (l *RateLimiter) client(r *http.Request) *clientLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.config.Enabled {
+		return nil
+	}
+
+	key, accessKey := l.clientKey(r)
+	if element, ok := l.clients[key]; ok {
+		l.idle.MoveToFront(element)
+		return element.Value.(*clientLimiter)
+	}
+
+	client := l.newClient(key, accessKey)
+	l.clients[key] = l.idle.PushFront(client)
+	for len(l.clients) > l.config.MaxClients {
+		oldest := l.idle.Back()
+		l.idle.Remove(oldest)
+		delete(l.clients, oldest.Value.(*clientLimiter).key)
+	}
+	return client
+}
+
+// clientKey returns the key the client of r is tracked by and, if it is
+// keyed by access key, the access key. Only the connection's address is
+// used as IP: X-Forwarded-For is set by the client and could be varied to
+// get a fresh budget with every request.
+func (l *RateLimiter) clientKey(r *http.Request) (key, accessKey string) {
+	if l.config.KeyBy == config.RateLimitKeyByAccessKey {
+		if accessKey = request.AccessKeyID(r); accessKey != "" {
+			return "key:" + accessKey, accessKey
+		}
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip, ""
+}
+
+// newClient creates the buckets of a client from the limits of its access
+// key, falling back to the rate_limiting section
+func (l *RateLimiter) newClient(key, accessKey string) *clientLimiter {
+	requestsPerSecond, requestBurst := l.config.RequestsPerSecond, l.config.RequestBurst
+	bytesPerSecond, bandwidthBurst := l.config.BytesPerSecond, l.config.BandwidthBurst
+	if override, ok := l.overrides[accessKey]; ok && accessKey != "" {
+		if override.RequestsPerSecond > 0 {
+			requestsPerSecond, requestBurst = override.RequestsPerSecond, override.RequestBurst
+		}
+		if override.RequestBurst > 0 {
+			requestBurst = override.RequestBurst
+		}
+		if override.BytesPerSecond > 0 {
+			bytesPerSecond, bandwidthBurst = override.BytesPerSecond, override.BandwidthBurst
+		}
+		if override.BandwidthBurst > 0 {
+			bandwidthBurst = override.BandwidthBurst
+		}
+	}
+
+	client := &clientLimiter{key: key}
+	now := l.now()
+	if requestsPerSecond > 0 {
+		if requestBurst == 0 {
+			requestBurst = int(math.Ceil(requestsPerSecond))
+		}
+		client.requests = orchestration.NewTokenBucket(requestsPerSecond, float64(requestBurst), now)
+	}
+	if bytesPerSecond > 0 {
+		if bandwidthBurst == 0 {
+			bandwidthBurst = bytesPerSecond
+		}
+		client.bandwidth = orchestration.NewTokenBucket(float64(bytesPerSecond), float64(bandwidthBurst), now)
+	}
+	return client
+}
+
+func (l *RateLimiter) reject(w http.ResponseWriter, r *http.Request, client *clientLimiter, reason string, wait time.Duration) {
+	monitoring.RecordClientRateLimited(reason)
+	l.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"client": client.key,
+		"reason": reason,
+	}).Debug("Rejected request by client rate limit")
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	l.errorWriter.WriteError(w, r, http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.")
+}
+
+// pace waits for wait or until ctx is done
+func pace(ctx context.Context, direction string, wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	monitoring.RecordClientBandwidthWait(direction, wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pacedBody paces the reads of a request body to the client's bandwidth
+type pacedBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *orchestration.TokenBucket
+	now    func() time.Time
+}
+
+func (b *pacedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p[:b.bucket.Chunk(len(p))])
+	if n > 0 {
+		if waitErr := pace(b.ctx, "upload", b.bucket.Reserve(n, b.now())); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// pacedWriter paces the response body to the client's bandwidth
+type pacedWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	bucket *orchestration.TokenBucket
+	now    func() time.Time
+}
+
+func (w *pacedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := w.bucket.Chunk(len(p))
+		if err := pace(w.ctx, "download", w.bucket.Reserve(size, w.now())); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(p[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[size:]
+	}
+	return written, nil
+}
+
+// Flush flushes streamed responses
+func (w *pacedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *pacedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// newTestRateLimiter returns a rate limiter on a clock that only advance moves
+func newTestRateLimiter(cfg config.RateLimitingConfig) (*RateLimiter, http.Handler, func(time.Duration)) {
+	cfg.Enabled = true
+	if cfg.KeyBy == "" {
+		cfg.KeyBy = config.RateLimitKeyByAccessKey
+	}
+	if cfg.MaxClients == 0 {
+		cfg.MaxClients = 100
+	}
+	limiter := NewRateLimiter(cfg, logrus.NewEntry(logrus.New()))
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	return limiter, handler, func(d time.Duration) { now = now.Add(d) }
+}
+
+func rateLimitedRequest(accessKey, remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	if accessKey != "" {
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/20250101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	}
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func serveRateLimited(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_RequestRate(t *testing.T) {
+	_, handler, advance := newTestRateLimiter(config.RateLimitingConfig{RequestsPerSecond: 1, RequestBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+	}
+	w := serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>SlowDown</Code>")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("other", "10.0.0.1:1234")).Code,
+		"every access key has its own budget")
+
+	advance(time.Second)
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+}
+
+func TestRateLimiter_KeyByIP(t *testing.T) {
+	_, handler, _ := newTestRateLimiter(config.RateLimitingConfig{KeyBy: config.RateLimitKeyByIP, RequestsPerSecond: 1})
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, rateLimitedRequest("other", "10.0.0.1:5678")).Code)
+
+	req := rateLimitedRequest("", "10.0.0.1:1234")
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, req).Code, "X-Forwarded-For is not trusted")
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.2:1234")).Code)
+}
+
+func TestRateLimiter_UnsignedRequestsKeyedByIP(t *testing.T) {
+	_, handler, _ := newTestRateLimiter(config.RateLimitingConfig{RequestsPerSecond: 1})
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("", "10.0.0.1:1234")).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, rateLimitedRequest("", "10.0.0.1:5678")).Code)
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+}
+
+func TestRateLimiter_ClientOverrides(t *testing.T) {
+	_, handler, _ := newTestRateLimiter(config.RateLimitingConfig{
+		RequestsPerSecond: 1,
+		Clients:           []config.RateLimitClientConfig{{AccessKeyID: "batch", RequestsPerSecond: 3}},
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("batch", "10.0.0.1:1234")).Code)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, rateLimitedRequest("batch", "10.0.0.1:1234")).Code)
+}
+
+func TestRateLimiter_MaxClients(t *testing.T) {
+	limiter, handler, _ := newTestRateLimiter(config.RateLimitingConfig{RequestsPerSecond: 1, MaxClients: 1})
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("other", "10.0.0.1:1234")).Code)
+	assert.Len(t, limiter.clients, 1)
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code,
+		"the evicted client starts with a full bucket")
+}
+
+func TestRateLimiter_Bandwidth(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitingConfig{
+		Enabled:        true,
+		KeyBy:          config.RateLimitKeyByAccessKey,
+		BytesPerSecond: 10000,
+		BandwidthBurst: 1000,
+		MaxClients:     100,
+	}, logrus.NewEntry(logrus.New()))
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+
+	body := strings.Repeat("x", 1500)
+	req := rateLimitedRequest("app", "10.0.0.1:1234")
+	req.Method = http.MethodPut
+	req.Body = io.NopCloser(strings.NewReader(body))
+
+	start := time.Now()
+	w := serveRateLimited(handler, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	// 3000 bytes uploaded and downloaded, of which the burst of 1000 is free
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestRateLimiter_BandwidthBacklog(t *testing.T) {
+	limiter, handler, advance := newTestRateLimiter(config.RateLimitingConfig{BytesPerSecond: 1000, BandwidthBurst: 100})
+
+	client := limiter.client(rateLimitedRequest("app", "10.0.0.1:1234"))
+	client.bandwidth.Reserve(300, limiter.now())
+
+	w := serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>SlowDown</Code>")
+
+	advance(200 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitingConfig{RequestsPerSecond: 1}, logrus.NewEntry(logrus.New()))
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+	}
+}
+
+func TestRateLimiter_Update(t *testing.T) {
+	limiter, handler, _ := newTestRateLimiter(config.RateLimitingConfig{RequestsPerSecond: 1, RequestBurst: 1})
+
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+
+	limiter.Update(config.RateLimitingConfig{Enabled: true, KeyBy: config.RateLimitKeyByAccessKey, RequestsPerSecond: 1, RequestBurst: 3, MaxClients: 100})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code, "the new burst applies")
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code)
+
+	limiter.Update(config.RateLimitingConfig{})
+	assert.Equal(t, http.StatusOK, serveRateLimited(handler, rateLimitedRequest("app", "10.0.0.1:1234")).Code, "disabled by the reload")
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// crashBundlePattern matches the files written to the crash bundle directory
+const crashBundlePattern = "crash-*.json"
+
+// redactedHeaders carry credentials or key material and are left out of
+// crash bundles
+var redactedHeaders = map[string]bool{
+	"Authorization":        true,
+	"Cookie":               true,
+	"X-Amz-Security-Token": true,
+	"X-Amz-Server-Side-Encryption-Customer-Key":                 true,
+	"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key":     true,
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5":             true,
+	"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5": true,
+}
+
+// CrashBundle is the JSON document written per recovered panic
+type CrashBundle struct {
+	Time       time.Time           `json:"time"`
+	RequestID  string              `json:"request_id"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Query      []string            `json:"query_parameters"` // names only, values may carry presigned credentials
+	Endpoint   string              `json:"endpoint"`
+	Bucket     string              `json:"bucket,omitempty"`
+	Key        string              `json:"key,omitempty"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Panic      string              `json:"panic"`
+	Stack      string              `json:"stack"`
+	GoVersion  string              `json:"go_version"`
+	Goroutines int                 `json:"goroutines"`
+}
+
+// Recovery converts handler panics into 500 InternalError responses. The
+// panic is logged with its stack and the request ID, bucket, key and endpoint,
+// counted in s3ep_handler_panics_total and optionally written as a crash
+// bundle. If the response was already started it cannot be replaced, so the
+// connection is aborted to keep the client from taking a truncated body for
+// a complete one.
+type Recovery struct {
+	crashBundleDir  string
+	maxCrashBundles int
+	logger          *logrus.Entry
+	bundleMutex     sync.Mutex
+}
+
+// NewRecovery creates a new panic recovery middleware
+func NewRecovery(cfg config.RecoveryConfig, logger *logrus.Entry) *Recovery {
+	return &Recovery{
+		crashBundleDir:  cfg.CrashBundleDir,
+		maxCrashBundles: cfg.MaxCrashBundles,
+		logger:          logger,
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (rc *Recovery) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked := newStatusWriter(w, nil)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			rc.handlePanic(tracked, r, recovered, debug.Stack())
+		}()
+		next.ServeHTTP(tracked, r)
+	})
+}
+
+func (rc *Recovery) handlePanic(w *statusWriter, r *http.Request, recovered interface{}, stack []byte) {
+	requestID := response.RequestID(w)
+	endpoint := routeTemplate(r)
+	vars := mux.Vars(r)
+
+	monitoring.RecordHandlerPanic(r.Method, endpoint)
+
+	fields := logrus.Fields{
+		"request_id":       requestID,
+		"method":           r.Method,
+		"endpoint":         endpoint,
+		"bucket":           vars["bucket"],
+		"key":              vars["key"],
+		"panic":            fmt.Sprint(recovered),
+		"stack":            string(stack),
+		"response_started": w.wroteHeader,
+	}
+
+	if rc.crashBundleDir != "" {
+		bundle := CrashBundle{
+			Time:       time.Now().UTC(),
+			RequestID:  requestID,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      queryNames(r),
+			Endpoint:   endpoint,
+			Bucket:     vars["bucket"],
+			Key:        vars["key"],
+			RemoteAddr: r.RemoteAddr,
+			Headers:    redactHeaders(r.Header),
+			Panic:      fmt.Sprint(recovered),
+			Stack:      string(stack),
+			GoVersion:  runtime.Version(),
+			Goroutines: runtime.NumGoroutine(),
+		}
+		path, err := rc.writeCrashBundle(bundle)
+		switch {
+		case err != nil:
+			fields["crash_bundle_error"] = err.Error()
+		case path != "":
+			fields["crash_bundle"] = path
+		}
+	}
+
+	rc.logger.WithContext(r.Context()).WithFields(fields).Error("Recovered from panic in request handler")
+
+	if w.wroteHeader {
+		panic(http.ErrAbortHandler)
+	}
+
+	// Drop the object headers the handler may have set before panicking
+	for _, name := range []string{"Content-Length", "Content-Range", "Content-Encoding", "ETag", "Last-Modified", "Accept-Ranges"} {
+		w.Header().Del(name)
+	}
+	_ = response.WriteError(w, http.StatusInternalServerError, response.Error{
+		Code:     "InternalError",
+		Message:  "We encountered an internal error. Please try again.",
+		Resource: r.URL.Path,
+	})
+}
+
+// writeCrashBundle writes bundle to the crash bundle directory and returns
+// its path. Nothing is written once the directory holds maxCrashBundles
+// bundles, so a panicking code path cannot fill the disk.
+func (rc *Recovery) writeCrashBundle(bundle CrashBundle) (string, error) {
+	rc.bundleMutex.Lock()
+	defer rc.bundleMutex.Unlock()
+
+	if err := os.MkdirAll(rc.crashBundleDir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create crash bundle directory: %w", err)
+	}
+	existing, err := filepath.Glob(filepath.Join(rc.crashBundleDir, crashBundlePattern))
+	if err != nil {
+		return "", err
+	}
+	if len(existing) >= rc.maxCrashBundles {
+		return "", fmt.Errorf("crash bundle directory holds %d bundles, skipping", len(existing))
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode crash bundle: %w", err)
+	}
+	name := fmt.Sprintf("crash-%s-%s.json", bundle.Time.Format("20060102T150405.000000000Z"), bundle.RequestID)
+	path := filepath.Join(rc.crashBundleDir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write crash bundle: %w", err)
+	}
+	return path, nil
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
+
+func queryNames(r *http.Request) []string {
+	query := r.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func redactHeaders(header http.Header) map[string][]string {
+	result := make(map[string][]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			result[name] = []string{"REDACTED"}
+			continue
+		}
+		result[name] = values
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func newTestRecoveryRouter(cfg config.RecoveryConfig, handler http.HandlerFunc) http.Handler {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	router := mux.NewRouter()
+	router.Use(NewS3Headers().Middleware)
+	router.Use(NewRecovery(cfg, logrus.NewEntry(logger)).Middleware)
+	router.HandleFunc("/{bucket}/{key:.*}", handler)
+	return router
+}
+
+func TestRecovery_AnswersPanicWithInternalError(t *testing.T) {
+	dir := t.TempDir()
+	router := newTestRecoveryRouter(config.RecoveryConfig{CrashBundleDir: dir, MaxCrashBundles: 10}, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "1234")
+		panic("nil map write")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/some/key?versionId=secret", nil)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=client/...")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	requestID := rec.Header().Get("X-Amz-Request-Id")
+	require.NotEmpty(t, requestID)
+	assert.Contains(t, rec.Body.String(), "<Code>InternalError</Code>")
+	assert.Contains(t, rec.Body.String(), "<RequestId>"+requestID+"</RequestId>")
+
+	bundles, err := filepath.Glob(filepath.Join(dir, crashBundlePattern))
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+
+	data, err := os.ReadFile(bundles[0])
+	require.NoError(t, err)
+	var bundle CrashBundle
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	assert.Equal(t, requestID, bundle.RequestID)
+	assert.Equal(t, "bucket", bundle.Bucket)
+	assert.Equal(t, "some/key", bundle.Key)
+	assert.Equal(t, "/{bucket}/{key:.*}", bundle.Endpoint)
+	assert.Equal(t, "nil map write", bundle.Panic)
+	assert.Contains(t, bundle.Stack, "recovery_test.go")
+	assert.Equal(t, []string{"versionId"}, bundle.Query)
+	assert.Equal(t, []string{"REDACTED"}, bundle.Headers["Authorization"])
+	assert.NotContains(t, string(data), "secret")
+}
+
+func TestRecovery_LimitsCrashBundles(t *testing.T) {
+	dir := t.TempDir()
+	router := newTestRecoveryRouter(config.RecoveryConfig{CrashBundleDir: dir, MaxCrashBundles: 2}, func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	for i := 0; i < 4; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/bucket/key", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	}
+
+	bundles, err := filepath.Glob(filepath.Join(dir, crashBundlePattern))
+	require.NoError(t, err)
+	assert.Len(t, bundles, 2)
+}
+
+func TestRecovery_AbortsStartedResponse(t *testing.T) {
+	router := newTestRecoveryRouter(config.RecoveryConfig{}, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+}
+
+func TestRecovery_PassesThrough(t *testing.T) {
+	router := newTestRecoveryRouter(config.RecoveryConfig{}, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/bucket/key", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
+)
+
+// RequestID assigns every S3 request a new request ID before anything else
+// sees it. The ID is sent as x-amz-request-id, together with an x-amz-id-2,
+// and attached to the request context, from which the logs, the audit and
+// access logs, event notifications and error bodies take it.
+type RequestID struct{}
+
+// NewRequestID creates a new request ID middleware
+func NewRequestID() *RequestID {
+	return &RequestID{}
+}
+
+// Middleware returns the HTTP middleware function
+func (m *RequestID) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.New()
+		w.Header().Set("X-Amz-Request-Id", id)
+		w.Header().Set("X-Amz-Id-2", requestid.NewHostID())
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
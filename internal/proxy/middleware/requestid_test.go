@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
+)
+
+func TestRequestID_PropagatesToContextAndErrors(t *testing.T) {
+	var seen string
+	handler := NewRequestID().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.FromContext(r.Context())
+		_ = response.WriteError(w, http.StatusNotFound, response.Error{Code: "NoSuchKey", Message: "The specified key does not exist."})
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+
+	id := w.Header().Get("X-Amz-Request-Id")
+	assert.Regexp(t, `^[0-9A-F]{16}$`, id)
+	assert.NotEmpty(t, w.Header().Get("X-Amz-Id-2"))
+	assert.Equal(t, id, seen)
+
+	var body response.Error
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, id, body.RequestID)
+}
+
+func TestRequestID_IgnoresClientHeader(t *testing.T) {
+	handler := NewRequestID().Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	first := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	req.Header.Set("X-Amz-Request-Id", "0000000000000000")
+	handler.ServeHTTP(first, req)
+
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+
+	assert.NotEqual(t, "0000000000000000", first.Header().Get("X-Amz-Request-Id"))
+	assert.NotEqual(t, first.Header().Get("X-Amz-Request-Id"), second.Header().Get("X-Amz-Request-Id"))
+}
+
+func TestS3Headers_UsesRequestIDOfContext(t *testing.T) {
+	router := newTestS3HeadersRouter(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/bucket/key", nil)
+	router.ServeHTTP(w, req.WithContext(requestid.WithID(req.Context(), "4442587FB7D0A2F9")))
+
+	assert.Equal(t, "4442587FB7D0A2F9", w.Header().Get("X-Amz-Request-Id"))
+}
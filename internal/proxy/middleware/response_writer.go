@@ -0,0 +1,56 @@
+package middleware
+
+import "net/http"
+
+// statusWriter wraps a ResponseWriter for middlewares that need to know what
+// the handler sent: whether the response was started, its status and the
+// number of body bytes. onHeader, if set, runs once right before the final
+// header is sent and may still change it.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	onHeader    func(status int)
+}
+
+// newStatusWriter wraps w; the status defaults to 200 like net/http
+func newStatusWriter(w http.ResponseWriter, onHeader func(status int)) *statusWriter {
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK, onHeader: onHeader}
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	// Informational responses (100 Continue) precede the final header
+	if !w.wroteHeader && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.wroteHeader = true
+		w.status = status
+		if w.onHeader != nil {
+			w.onHeader(status)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends the header if needed and flushes streamed responses
+func (w *statusWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
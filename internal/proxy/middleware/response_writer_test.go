@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// informationalRecorder passes 1xx headers through like net/http does;
+// httptest.ResponseRecorder would take them as the final header
+type informationalRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r informationalRecorder) WriteHeader(status int) {
+	if status >= 200 {
+		r.ResponseRecorder.WriteHeader(status)
+	}
+}
+
+func TestStatusWriter(t *testing.T) {
+	rec := informationalRecorder{httptest.NewRecorder()}
+	var headers []int
+	w := newStatusWriter(rec, func(status int) { headers = append(headers, status) })
+
+	w.WriteHeader(http.StatusContinue)
+	assert.False(t, w.wroteHeader, "100 Continue is not the final header")
+
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte("missing"))
+	w.WriteHeader(http.StatusOK)
+
+	assert.True(t, w.wroteHeader)
+	assert.Equal(t, http.StatusNotFound, w.status)
+	assert.Equal(t, int64(7), w.bytes)
+	assert.Equal(t, []int{http.StatusNotFound}, headers)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, http.ResponseWriter(rec), w.Unwrap())
+}
+
+func TestStatusWriter_ImplicitHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var headers []int
+	w := newStatusWriter(rec, func(status int) { headers = append(headers, status) })
+
+	w.Flush()
+	_, _ = w.Write([]byte("ok"))
+
+	assert.Equal(t, http.StatusOK, w.status)
+	assert.Equal(t, []int{http.StatusOK}, headers)
+	assert.True(t, rec.Flushed)
+}
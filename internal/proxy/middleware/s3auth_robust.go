@@ -10,10 +10,13 @@ import (
 	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/guided-traffic/s3-encryption-proxy/internal/authz"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,7 +24,9 @@ const (
 	// AWS Signature V4 constants
 	AWS4RequestType = "aws4_request"
 	AWS4Algorithm   = "AWS4-HMAC-SHA256"
-	AWS4Prefix      = "AWS4"
+	// AWS4ChunkAlgorithm signs the chunks of STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+	AWS4ChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	AWS4Prefix         = "AWS4"
 
 	// Time formats
 	ISO8601BasicFormat = "20060102T150405Z"
@@ -37,10 +42,20 @@ const (
 	// Special values
 	UnsignedPayload    = "UNSIGNED-PAYLOAD"
 	StreamingSignature = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	EmptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// Presigned URL query parameters
+	XAmzAlgorithmQuery     = "X-Amz-Algorithm"
+	XAmzCredentialQuery    = "X-Amz-Credential"
+	XAmzDateQuery          = "X-Amz-Date"
+	XAmzExpiresQuery       = "X-Amz-Expires"
+	XAmzSignedHeadersQuery = "X-Amz-SignedHeaders"
+	XAmzSignatureQuery     = "X-Amz-Signature"
 
 	// Security limits
-	MaxClockSkewSeconds = 900  // 15 minutes
-	MaxAuthHeaderSize   = 8192 // 8KB max authorization header
+	MaxClockSkewSeconds = 900    // 15 minutes
+	MaxAuthHeaderSize   = 8192   // 8KB max authorization header
+	MaxPresignExpires   = 604800 // 7 days, as in S3
 )
 
 // S3AuthenticationService provides comprehensive S3 authentication
@@ -49,6 +64,9 @@ type S3AuthenticationService struct {
 	logger          *logrus.Logger
 	clientCache     map[string]*config.S3ClientCredentials
 	securityMetrics *SecurityMetrics
+
+	// Scopes that replace those of s3_clients, if set
+	scopeSource authz.ScopeSource
 }
 
 // SecurityMetrics tracks authentication security events
@@ -73,6 +91,11 @@ type SignatureInfo struct {
 	PayloadHash     string
 	Timestamp       time.Time
 	CredentialScope string
+
+	// Presigned URLs only: the signing time and how long the URL is valid
+	Presigned   bool
+	RequestTime time.Time
+	Expires     time.Duration
 }
 
 // NewS3AuthenticationService creates a secure S3 authentication service
@@ -97,51 +120,86 @@ func NewS3AuthenticationService(cfg *config.Config, logger *logrus.Logger) *S3Au
 
 // AuthenticateRequest performs comprehensive S3 request authentication
 func (s *S3AuthenticationService) AuthenticateRequest(r *http.Request) error {
+	_, err := s.Authenticate(r)
+	return err
+}
+
+// Authenticate verifies the request signature, from the Authorization header
+// or the query string of a presigned URL, and returns the client that signed
+// it. The client is nil in developer mode. The body of r is replaced by one
+// that fails to read if it does not match the signed payload hash or, for
+// STREAMING-AWS4-HMAC-SHA256-PAYLOAD uploads, the chunk signatures.
+func (s *S3AuthenticationService) Authenticate(r *http.Request) (*config.S3ClientCredentials, error) {
 	// Security check: Authorization header size limit
 	authHeader := r.Header.Get(AuthorizationHeader)
 	if len(authHeader) > MaxAuthHeaderSize {
 		s.logSecurityEvent("oversized_auth_header", r, "Authorization header exceeds size limit")
-		return fmt.Errorf("authorization header too large")
+		return nil, fmt.Errorf("authorization header too large")
+	}
+
+	// Developer mode accepts any or no credentials
+	if s.config.DevMode {
+		s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Trace("Developer mode: skipping S3 client authentication")
+		return nil, nil
 	}
 
 	// Extract and validate signature information
-	sigInfo, err := s.parseAuthorizationHeader(authHeader)
+	var sigInfo *SignatureInfo
+	var err error
+	if authHeader == "" && r.URL.Query().Has(XAmzAlgorithmQuery) {
+		sigInfo, err = s.parsePresignedQuery(r.URL.Query())
+	} else {
+		sigInfo, err = s.parseAuthorizationHeader(authHeader)
+	}
 	if err != nil {
 		s.logSecurityEvent("malformed_auth_header", r, err.Error())
-		return fmt.Errorf("malformed authorization header: %w", err)
+		return nil, fmt.Errorf("malformed authorization header: %w", err)
 	}
 
-	// Security check: Clock skew protection
-	if err := s.validateTimestamp(sigInfo.Timestamp, r); err != nil {
+	if sigInfo.Presigned {
+		if err := s.validatePresignedExpiry(sigInfo); err != nil {
+			s.logSecurityEvent("expired_presigned_url", r, err.Error())
+			return nil, err
+		}
+	} else if err := s.validateTimestamp(sigInfo.Timestamp, r); err != nil {
+		// Security check: Clock skew protection
 		s.securityMetrics.ClockSkewErrors++
 		s.logSecurityEvent("clock_skew_error", r, err.Error())
-		return fmt.Errorf("timestamp validation failed: %w", err)
+		return nil, fmt.Errorf("timestamp validation failed: %w", err)
 	}
 
 	// Lookup client credentials
 	client, exists := s.clientCache[sigInfo.AccessKeyID]
 	if !exists {
 		s.logSecurityEvent("unknown_access_key", r, sigInfo.AccessKeyID)
-		return fmt.Errorf("access key not found: %s", sigInfo.AccessKeyID)
+		return nil, fmt.Errorf("access key not found: %s", sigInfo.AccessKeyID)
 	}
 
 	// Validate signature
 	if err := s.validateSignature(r, sigInfo, client.SecretKey); err != nil {
 		s.securityMetrics.InvalidSignatures++
 		s.logSecurityEvent("signature_verification_failed", r, err.Error())
-		return fmt.Errorf("signature verification failed: %w", err)
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	if err := s.verifyPayload(r, sigInfo, client.SecretKey); err != nil {
+		s.logSecurityEvent("malformed_payload_hash", r, err.Error())
+		return nil, fmt.Errorf("malformed authorization header: %w", err)
 	}
 
 	// Log successful authentication
-	s.logger.WithFields(logrus.Fields{
+	s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"access_key_id": sigInfo.AccessKeyID,
 		"method":        r.Method,
 		"path":          r.URL.Path,
 		"description":   client.Description,
 		"timestamp":     sigInfo.Timestamp.Format(time.RFC3339),
+		"presigned":     sigInfo.Presigned,
 	}).Debug("S3 client authenticated successfully")
 
-	return nil
+	return client, nil
 }
 
 // parseAuthorizationHeader parses AWS4-HMAC-SHA256 authorization header
@@ -167,11 +225,57 @@ func (s *S3AuthenticationService) parseAuthorizationHeader(authHeader string) (*
 		return nil, fmt.Errorf("incomplete authorization header components")
 	}
 
-	credential := credentialMatch[1]
-	signedHeaders := strings.Split(signedHeadersMatch[1], ";")
-	signature := signatureMatch[1]
+	sigInfo, err := parseCredential(credentialMatch[1])
+	if err != nil {
+		return nil, err
+	}
+	sigInfo.SignedHeaders = strings.Split(signedHeadersMatch[1], ";")
+	sigInfo.Signature = signatureMatch[1]
+	return sigInfo, nil
+}
 
-	// Parse credential scope: AccessKeyID/Date/Region/Service/aws4_request
+// parsePresignedQuery parses the X-Amz-* query parameters of a presigned URL
+func (s *S3AuthenticationService) parsePresignedQuery(query url.Values) (*SignatureInfo, error) {
+	if query.Get(XAmzAlgorithmQuery) != AWS4Algorithm {
+		return nil, fmt.Errorf("unsupported authorization algorithm")
+	}
+	for _, name := range []string{XAmzCredentialQuery, XAmzDateQuery, XAmzExpiresQuery, XAmzSignedHeadersQuery, XAmzSignatureQuery} {
+		if query.Get(name) == "" {
+			return nil, fmt.Errorf("incomplete presigned URL: missing %s", name)
+		}
+	}
+
+	sigInfo, err := parseCredential(query.Get(XAmzCredentialQuery))
+	if err != nil {
+		return nil, err
+	}
+	requestTime, err := time.Parse(ISO8601BasicFormat, query.Get(XAmzDateQuery))
+	if err != nil {
+		return nil, fmt.Errorf("invalid X-Amz-Date format: %w", err)
+	}
+	if requestTime.Format(ISO8601DateFormat) != sigInfo.Date {
+		return nil, fmt.Errorf("credential date mismatch: %s != %s", sigInfo.Date, requestTime.Format(ISO8601DateFormat))
+	}
+	expires, err := strconv.Atoi(query.Get(XAmzExpiresQuery))
+	if err != nil || expires < 1 || expires > MaxPresignExpires {
+		return nil, fmt.Errorf("X-Amz-Expires must be between 1 and %d seconds", MaxPresignExpires)
+	}
+	signature := query.Get(XAmzSignatureQuery)
+	if len(signature) != 64 || strings.Trim(signature, "0123456789abcdefABCDEF") != "" {
+		return nil, fmt.Errorf("invalid signature format")
+	}
+
+	sigInfo.SignedHeaders = strings.Split(query.Get(XAmzSignedHeadersQuery), ";")
+	sigInfo.Signature = signature
+	sigInfo.Presigned = true
+	sigInfo.RequestTime = requestTime
+	sigInfo.Expires = time.Duration(expires) * time.Second
+	return sigInfo, nil
+}
+
+// parseCredential parses the credential scope
+// AccessKeyID/Date/Region/Service/aws4_request
+func parseCredential(credential string) (*SignatureInfo, error) {
 	credentialParts := strings.Split(credential, "/")
 	if len(credentialParts) != 5 {
 		return nil, fmt.Errorf("invalid credential format")
@@ -204,8 +308,6 @@ func (s *S3AuthenticationService) parseAuthorizationHeader(authHeader string) (*
 		Region:          region,
 		Service:         service,
 		RequestType:     requestType,
-		SignedHeaders:   signedHeaders,
-		Signature:       signature,
 		Timestamp:       timestamp,
 		CredentialScope: credentialScope,
 	}, nil
@@ -256,24 +358,47 @@ func (s *S3AuthenticationService) validateTimestamp(credentialTime time.Time, r
 	return nil
 }
 
-// validateSignature performs AWS Signature V4 validation with security checks
-func (s *S3AuthenticationService) validateSignature(r *http.Request, sigInfo *SignatureInfo, secretKey string) error {
-	// Get request timestamp for string-to-sign
-	var requestTime string
+// validatePresignedExpiry checks that a presigned URL is already and still
+// valid, allowing for clock skew at its start
+func (s *S3AuthenticationService) validatePresignedExpiry(sigInfo *SignatureInfo) error {
+	now := time.Now().UTC()
+	if sigInfo.RequestTime.Sub(now) > MaxClockSkewSeconds*time.Second {
+		return fmt.Errorf("request timestamp too far from current time: presigned URL is not valid yet")
+	}
+	if now.After(sigInfo.RequestTime.Add(sigInfo.Expires)) {
+		return fmt.Errorf("request has expired")
+	}
+	return nil
+}
+
+// requestTimestamp returns the signing time of the string-to-sign
+func requestTimestamp(r *http.Request, sigInfo *SignatureInfo) (string, error) {
+	if sigInfo.Presigned {
+		return sigInfo.RequestTime.Format(ISO8601BasicFormat), nil
+	}
 	if amzDate := r.Header.Get(XAmzDateHeader); amzDate != "" {
-		requestTime = amzDate
-	} else if date := r.Header.Get(DateHeader); date != "" {
+		return amzDate, nil
+	}
+	if date := r.Header.Get(DateHeader); date != "" {
 		t, err := time.Parse(time.RFC1123, date)
 		if err != nil {
-			return fmt.Errorf("invalid date format: %w", err)
+			return "", fmt.Errorf("invalid date format: %w", err)
 		}
-		requestTime = t.UTC().Format(ISO8601BasicFormat)
-	} else {
-		return fmt.Errorf("missing timestamp for signature")
+		return t.UTC().Format(ISO8601BasicFormat), nil
+	}
+	return "", fmt.Errorf("missing timestamp for signature")
+}
+
+// validateSignature performs AWS Signature V4 validation with security checks
+func (s *S3AuthenticationService) validateSignature(r *http.Request, sigInfo *SignatureInfo, secretKey string) error {
+	// Get request timestamp for string-to-sign
+	requestTime, err := requestTimestamp(r, sigInfo)
+	if err != nil {
+		return err
 	}
 
 	// Build canonical request
-	canonicalRequest, err := s.buildCanonicalRequest(r, sigInfo.SignedHeaders)
+	canonicalRequest, err := s.buildCanonicalRequest(r, sigInfo)
 	if err != nil {
 		return fmt.Errorf("failed to build canonical request: %w", err)
 	}
@@ -293,20 +418,21 @@ func (s *S3AuthenticationService) validateSignature(r *http.Request, sigInfo *Si
 }
 
 // buildCanonicalRequest creates the canonical request for signature verification
-func (s *S3AuthenticationService) buildCanonicalRequest(r *http.Request, signedHeaders []string) (string, error) {
+func (s *S3AuthenticationService) buildCanonicalRequest(r *http.Request, sigInfo *SignatureInfo) (string, error) {
+	signedHeaders := sigInfo.SignedHeaders
+
 	// HTTP Method
 	method := r.Method
 
-	// Canonical URI
-	uri := r.URL.Path
-	if uri == "" {
-		uri = "/"
-	}
-	// URL encode the path but preserve slashes
-	uri = strings.ReplaceAll(url.QueryEscape(uri), "%2F", "/")
+	// Canonical URI of the path as sent, before key canonicalization
+	uri := canonicalURI(originalPath(r))
 
-	// Canonical Query String
-	query := s.buildCanonicalQueryString(r.URL.Query())
+	// Canonical Query String, without the signature of a presigned URL
+	values := r.URL.Query()
+	if sigInfo.Presigned {
+		values.Del(XAmzSignatureQuery)
+	}
+	query := s.buildCanonicalQueryString(values)
 
 	// Canonical Headers
 	canonicalHeaders, err := s.buildCanonicalHeaders(r, signedHeaders)
@@ -321,12 +447,12 @@ func (s *S3AuthenticationService) buildCanonicalRequest(r *http.Request, signedH
 	payloadHash := r.Header.Get(XAmzContentSha256)
 	if payloadHash == "" {
 		// If not provided, calculate from body or use UNSIGNED-PAYLOAD
-		if r.Body != nil && r.ContentLength > 0 {
+		if sigInfo.Presigned || r.Body != nil && r.ContentLength > 0 {
 			// For security, we require explicit payload hash for non-empty bodies
 			payloadHash = UnsignedPayload
 		} else {
 			// Empty payload
-			payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // empty SHA256
+			payloadHash = EmptyPayloadSHA256
 		}
 	}
 
@@ -341,6 +467,41 @@ func (s *S3AuthenticationService) buildCanonicalRequest(r *http.Request, signedH
 	return canonicalRequest, nil
 }
 
+// canonicalURI returns the SigV4 canonical URI of an escaped path. Each
+// segment is URI-encoded once, so an encoded slash stays part of its segment.
+func canonicalURI(escapedPath string) string {
+	if escapedPath == "" {
+		return "/"
+	}
+	segments := strings.Split(escapedPath, "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode percent-encodes everything except the unreserved characters of
+// RFC 3986, as SigV4 requires
+func uriEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
 // buildCanonicalQueryString creates canonical query string
 func (s *S3AuthenticationService) buildCanonicalQueryString(values url.Values) string {
 	var keys []string
@@ -351,8 +512,13 @@ func (s *S3AuthenticationService) buildCanonicalQueryString(values url.Values) s
 
 	var parts []string
 	for _, key := range keys {
-		for _, value := range values[key] {
-			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		encoded := append([]string(nil), values[key]...)
+		for i, value := range encoded {
+			encoded[i] = uriEncode(value)
+		}
+		sort.Strings(encoded)
+		for _, value := range encoded {
+			parts = append(parts, uriEncode(key)+"="+value)
 		}
 	}
 
@@ -375,6 +541,11 @@ func (s *S3AuthenticationService) buildCanonicalHeaders(r *http.Request, signedH
 		headers["host"] = []string{r.Host}
 	}
 
+	// net/http keeps the content length of a request out of its headers
+	if _, exists := headers["content-length"]; !exists && r.ContentLength > 0 {
+		headers["content-length"] = []string{strconv.FormatInt(r.ContentLength, 10)}
+	}
+
 	// Build canonical headers for signed headers only
 	var canonicalHeaders strings.Builder
 	for _, headerName := range signedHeaders {
@@ -412,11 +583,7 @@ func (s *S3AuthenticationService) buildStringToSign(timestamp, credentialScope,
 
 // calculateSignature calculates AWS Signature V4
 func (s *S3AuthenticationService) calculateSignature(secretKey, date, region, service, stringToSign string) string {
-	// Derive signing key
-	kDate := s.hmacSHA256([]byte(AWS4Prefix+secretKey), []byte(date))
-	kRegion := s.hmacSHA256(kDate, []byte(region))
-	kService := s.hmacSHA256(kRegion, []byte(service))
-	kSigning := s.hmacSHA256(kService, []byte(AWS4RequestType))
+	kSigning := s.signingKey(secretKey, date, region, service)
 
 	// Calculate signature
 	signature := s.hmacSHA256(kSigning, []byte(stringToSign))
@@ -424,6 +591,53 @@ func (s *S3AuthenticationService) calculateSignature(secretKey, date, region, se
 	return hex.EncodeToString(signature)
 }
 
+// signingKey derives the SigV4 signing key of a credential scope
+func (s *S3AuthenticationService) signingKey(secretKey, date, region, service string) []byte {
+	kDate := s.hmacSHA256([]byte(AWS4Prefix+secretKey), []byte(date))
+	kRegion := s.hmacSHA256(kDate, []byte(region))
+	kService := s.hmacSHA256(kRegion, []byte(service))
+	return s.hmacSHA256(kService, []byte(AWS4RequestType))
+}
+
+// verifyPayload replaces the body of r by one that checks it against the
+// signed payload: the SHA-256 in X-Amz-Content-Sha256, or the chunk
+// signatures of a STREAMING-AWS4-HMAC-SHA256-PAYLOAD upload. Unsigned
+// payloads are passed on as they are.
+func (s *S3AuthenticationService) verifyPayload(r *http.Request, sigInfo *SignatureInfo, secretKey string) error {
+	payloadHash := r.Header.Get(XAmzContentSha256)
+	if r.Body == nil || r.Body == http.NoBody || payloadHash == "" || payloadHash == UnsignedPayload {
+		return nil
+	}
+
+	switch {
+	case payloadHash == StreamingSignature:
+		requestTime, err := requestTimestamp(r, sigInfo)
+		if err != nil {
+			return err
+		}
+		key := s.signingKey(secretKey, sigInfo.Date, sigInfo.Region, sigInfo.Service)
+		r.Body = request.NewChunkSignatureReader(r.Body, sigInfo.Signature, func(previousSignature string, chunkSHA256 []byte) string {
+			stringToSign := AWS4ChunkAlgorithm + "\n" +
+				requestTime + "\n" +
+				sigInfo.CredentialScope + "\n" +
+				previousSignature + "\n" +
+				EmptyPayloadSHA256 + "\n" +
+				hex.EncodeToString(chunkSHA256)
+			return hex.EncodeToString(s.hmacSHA256(key, []byte(stringToSign)))
+		})
+	case strings.HasPrefix(payloadHash, "STREAMING-"):
+		// Trailer and unsigned streaming variants carry no chunk signatures
+		// the proxy verifies
+	default:
+		body, err := request.NewPayloadHashReader(r.Body, payloadHash)
+		if err != nil {
+			return err
+		}
+		r.Body = body
+	}
+	return nil
+}
+
 // hmacSHA256 computes HMAC-SHA256
 func (s *S3AuthenticationService) hmacSHA256(key, data []byte) []byte {
 	h := hmac.New(sha256.New, key)
@@ -440,7 +654,7 @@ func (s *S3AuthenticationService) logSecurityEvent(eventType string, r *http.Req
 		s.securityMetrics.FailedAttempts[clientIP]++
 	}
 
-	s.logger.WithFields(logrus.Fields{
+	s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"event_type":   eventType,
 		"client_ip":    clientIP,
 		"user_agent":   r.UserAgent(),
@@ -452,7 +666,7 @@ func (s *S3AuthenticationService) logSecurityEvent(eventType string, r *http.Req
 
 	// Alert on repeated failures from same IP
 	if s.securityMetrics.FailedAttempts[clientIP] > 5 {
-		s.logger.WithFields(logrus.Fields{
+		s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"client_ip":    clientIP,
 			"failed_count": s.securityMetrics.FailedAttempts[clientIP],
 		}).Error("Potential brute force attack detected")
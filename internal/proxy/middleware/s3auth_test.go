@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+var testCredentials = aws.Credentials{AccessKeyID: "app-client", SecretAccessKey: "app-secret"}
+
+func newTestAuthService() *S3AuthenticationService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewS3AuthenticationService(&config.Config{
+		S3Clients: []config.S3ClientCredentials{{AccessKeyID: testCredentials.AccessKeyID, SecretKey: testCredentials.SecretAccessKey}},
+	}, logger)
+}
+
+// s3Signer signs like the S3 clients of the SDK, which escape the path once
+func s3Signer() *v4.Signer {
+	return v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func signRequest(t *testing.T, r *http.Request, payloadHash string, signingTime time.Time) {
+	t.Helper()
+	r.Header.Set(XAmzContentSha256, payloadHash)
+	require.NoError(t, s3Signer().SignHTTP(context.Background(), testCredentials, r, payloadHash, "s3", "us-east-1", signingTime))
+}
+
+func TestAuthenticate_SignedPayload(t *testing.T) {
+	service := newTestAuthService()
+	body := "hello encrypted world"
+
+	r := httptest.NewRequest(http.MethodPut, "/bucket/dir/a%20file.txt?tagging=&versionId=a+b", strings.NewReader(body))
+	signRequest(t, r, sha256Hex(body), time.Now())
+	client, err := service.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, "app-client", client.AccessKeyID)
+	data, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+
+	// A body other than the signed one fails to read
+	r = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("tampered"))
+	signRequest(t, r, sha256Hex(body), time.Now())
+	_, err = service.Authenticate(r)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.Body)
+	assert.ErrorIs(t, err, request.ErrContentSHA256Mismatch)
+
+	r = httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	signRequest(t, r, EmptyPayloadSHA256, time.Now())
+	r.Header.Set("Range", "bytes=0-1") // not signed, ignored
+	r.URL.RawQuery = "partNumber=1"
+	_, err = service.Authenticate(r)
+	assert.ErrorContains(t, err, "signature mismatch")
+}
+
+func presign(t *testing.T, method, target string, expires time.Duration, signingTime time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	query := r.URL.Query()
+	query.Set(XAmzExpiresQuery, fmt.Sprint(int(expires.Seconds())))
+	r.URL.RawQuery = query.Encode()
+
+	signed, _, err := s3Signer().PresignHTTP(context.Background(), testCredentials, r, UnsignedPayload, "s3", "us-east-1", signingTime)
+	require.NoError(t, err)
+	return httptest.NewRequest(method, signed, nil)
+}
+
+func TestAuthenticate_PresignedURL(t *testing.T) {
+	service := newTestAuthService()
+
+	r := presign(t, http.MethodGet, "http://proxy.local/bucket/reports/q1%2B.pdf", time.Hour, time.Now().Add(-30*time.Minute))
+	client, err := service.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, "app-client", client.AccessKeyID)
+
+	r = presign(t, http.MethodGet, "http://proxy.local/bucket/reports/q1.pdf", time.Hour, time.Now().Add(-2*time.Hour))
+	_, err = service.Authenticate(r)
+	assert.ErrorContains(t, err, "expired")
+
+	r = presign(t, http.MethodGet, "http://proxy.local/bucket/reports/q1.pdf", time.Hour, time.Now())
+	r.URL.Path = "/bucket/reports/q2.pdf"
+	_, err = service.Authenticate(r)
+	assert.ErrorContains(t, err, "signature mismatch")
+
+	r = presign(t, http.MethodGet, "http://proxy.local/bucket/reports/q1.pdf", 8*24*time.Hour, time.Now())
+	_, err = service.Authenticate(r)
+	assert.ErrorContains(t, err, "X-Amz-Expires")
+}
+
+// chunkedBody encodes chunks as STREAMING-AWS4-HMAC-SHA256-PAYLOAD, chained
+// to the signature of the request headers
+func chunkedBody(t *testing.T, r *http.Request, signingTime time.Time, chunks ...string) string {
+	t.Helper()
+	auth := r.Header.Get(AuthorizationHeader)
+	seed := auth[strings.LastIndex(auth, "Signature=")+len("Signature="):]
+	date := signingTime.UTC().Format(ISO8601DateFormat)
+	scope := date + "/us-east-1/s3/aws4_request"
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac(mac(mac(mac([]byte("AWS4"+testCredentials.SecretAccessKey), date), "us-east-1"), "s3"), "aws4_request")
+
+	var body strings.Builder
+	previous := seed
+	for _, chunk := range append(chunks, "") {
+		stringToSign := strings.Join([]string{AWS4ChunkAlgorithm, signingTime.UTC().Format(ISO8601BasicFormat), scope, previous, EmptyPayloadSHA256, sha256Hex(chunk)}, "\n")
+		previous = hex.EncodeToString(mac(key, stringToSign))
+		fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(chunk), previous, chunk)
+	}
+	return body.String()
+}
+
+func TestAuthenticate_StreamingChunkSignatures(t *testing.T) {
+	service := newTestAuthService()
+	now := time.Now()
+
+	newRequest := func(tamper func(string) string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/bucket/key", nil)
+		r.Header.Set("Content-Encoding", "aws-chunked")
+		r.Header.Set("X-Amz-Decoded-Content-Length", "10")
+		signRequest(t, r, StreamingSignature, now)
+		body := tamper(chunkedBody(t, r, now, "first", "second"))
+		r.Body = io.NopCloser(strings.NewReader(body))
+		return r
+	}
+
+	r := newRequest(func(body string) string { return body })
+	_, err := service.Authenticate(r)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), ";chunk-signature=", "the body is passed on aws-chunked")
+
+	r = newRequest(func(body string) string { return strings.Replace(body, "second", "secomd", 1) })
+	_, err = service.Authenticate(r)
+	require.NoError(t, err)
+	raw, err = io.ReadAll(r.Body)
+	assert.ErrorIs(t, err, request.ErrChunkSignatureMismatch)
+	assert.NotContains(t, string(raw), "secomd", "a chunk is released only after its signature is verified")
+
+	// Dropping the final chunk is not accepted either
+	r = newRequest(func(body string) string { return body[:strings.LastIndex(body, "0;chunk-signature=")] })
+	_, err = service.Authenticate(r)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
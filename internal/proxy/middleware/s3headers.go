@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
+)
+
+// headerTimeLayouts are the date formats normalized to http.TimeFormat
+var headerTimeLayouts = []string{
+	http.TimeFormat,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC3339Nano,
+	time.RFC850,
+	time.ANSIC,
+}
+
+// S3Headers makes every response carry the headers the AWS, MinIO Go and MinIO
+// Java SDKs expect from S3, regardless of which handler wrote it:
+// x-amz-request-id, x-amz-id-2 and Date are always present, dates use the HTTP
+// date format in GMT, ETags are quoted and successful object reads announce
+// Accept-Ranges. Headers set by handlers are kept unless they are malformed.
+type S3Headers struct{}
+
+// NewS3Headers creates a new response header normalization middleware
+func NewS3Headers() *S3Headers {
+	return &S3Headers{}
+}
+
+// Middleware returns the HTTP middleware function
+func (h *S3Headers) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Normalize the headers right before they are sent
+		next.ServeHTTP(newStatusWriter(w, func(status int) {
+			normalizeS3Headers(w.Header(), r, status)
+		}), r)
+	})
+}
+
+func normalizeS3Headers(header http.Header, r *http.Request, status int) {
+	if header.Get("X-Amz-Request-Id") == "" {
+		id := requestid.FromContext(r.Context())
+		if id == "" {
+			id = requestid.New()
+		}
+		header.Set("X-Amz-Request-Id", id)
+	}
+	if header.Get("X-Amz-Id-2") == "" {
+		header.Set("X-Amz-Id-2", requestid.NewHostID())
+	}
+
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	} else {
+		normalizeDateHeader(header, "Date")
+	}
+	normalizeDateHeader(header, "Last-Modified")
+
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", `"`+etag+`"`)
+	}
+
+	if header.Get("Accept-Ranges") == "" && isObjectRead(r, status) {
+		header.Set("Accept-Ranges", "bytes")
+	}
+}
+
+// normalizeDateHeader rewrites a parseable date in http.TimeFormat (GMT).
+// Unparseable values are left alone rather than replaced with a wrong date.
+func normalizeDateHeader(header http.Header, name string) {
+	value := header.Get(name)
+	if value == "" {
+		return
+	}
+	for _, layout := range headerTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			header.Set(name, t.UTC().Format(http.TimeFormat))
+			return
+		}
+	}
+}
+
+// isObjectRead reports whether the response is a successful GET or HEAD of an
+// object (not a bucket or a sub-resource such as ?acl)
+func isObjectRead(r *http.Request, status int) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if status != http.StatusOK && status != http.StatusPartialContent {
+		return false
+	}
+	if mux.Vars(r)["key"] == "" {
+		return false
+	}
+	query := r.URL.Query()
+	for _, subresource := range []string{"acl", "tagging", "legal-hold", "retention", "torrent", "uploadId"} {
+		if query.Has(subresource) {
+			return false
+		}
+	}
+	return true
+}
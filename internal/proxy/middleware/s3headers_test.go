@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestS3HeadersRouter routes like the proxy so the {key} variable is set
+func newTestS3HeadersRouter(handler http.HandlerFunc) http.Handler {
+	router := mux.NewRouter()
+	router.Use(NewS3Headers().Middleware)
+	router.HandleFunc("/{bucket}", handler)
+	router.HandleFunc("/{bucket}/{key:.*}", handler)
+	return router
+}
+
+func TestS3Headers_AddsMissingHeaders(t *testing.T) {
+	router := newTestS3HeadersRouter(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("data"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket/key.txt", nil))
+
+	assert.Regexp(t, `^[0-9A-F]{16}$`, w.Header().Get("X-Amz-Request-Id"))
+	assert.NotEmpty(t, w.Header().Get("X-Amz-Id-2"))
+	_, err := time.Parse(http.TimeFormat, w.Header().Get("Date"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "data", w.Body.String())
+}
+
+func TestS3Headers_NormalizesHandlerHeaders(t *testing.T) {
+	modified := time.Date(2026, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	router := newTestS3HeadersRouter(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Amz-Request-Id", "FROMBACKEND00001")
+		w.Header().Set("ETag", "d41d8cd98f00b204e9800998ecf8427e")
+		w.Header().Set("Last-Modified", modified.Format(time.RFC3339))
+		w.Header().Set("Content-Range", "bytes 0-0/10")
+		w.WriteHeader(http.StatusPartialContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket/key.txt", nil))
+
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "FROMBACKEND00001", w.Header().Get("X-Amz-Request-Id"), "existing request IDs are kept")
+	assert.Equal(t, `"d41d8cd98f00b204e9800998ecf8427e"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Wed, 04 Mar 2026 04:06:07 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+}
+
+func TestS3Headers_AcceptRangesOnlyForObjectReads(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{name: "bucket listing", method: http.MethodGet, target: "/bucket", status: http.StatusOK},
+		{name: "object put", method: http.MethodPut, target: "/bucket/key", status: http.StatusOK},
+		{name: "object acl", method: http.MethodGet, target: "/bucket/key?acl", status: http.StatusOK},
+		{name: "missing object", method: http.MethodHead, target: "/bucket/key", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestS3HeadersRouter(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("ETag", `W/"weak"`)
+				w.WriteHeader(tt.status)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+			assert.Empty(t, w.Header().Get("Accept-Ranges"))
+			assert.Equal(t, `W/"weak"`, w.Header().Get("ETag"), "weak ETags are not quoted again")
+			assert.NotEmpty(t, w.Header().Get("X-Amz-Request-Id"))
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/authz"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// maxDeleteObjectsBody bounds the DeleteObjects body read for scope checks.
+// S3 allows 1000 keys of at most 1024 bytes each.
+const maxDeleteObjectsBody = 2 << 20
+
+// ErrAccessDenied is returned when an authenticated client has no scope that
+// allows the request
+var ErrAccessDenied = errors.New("access denied")
+
+// bucketSubresources are the query parameters that turn a bucket GET, PUT or
+// DELETE into a bucket configuration request
+var bucketSubresources = []string{
+	"accelerate", "acl", "analytics", "cors", "encryption", "intelligent-tiering",
+	"inventory", "lifecycle", "location", "logging", "metrics", "notification",
+	"object-lock", "ownershipControls", "policy", "policyStatus", "publicAccessBlock",
+	"replication", "requestPayment", "tagging", "versioning", "website",
+}
+
+// scopeAccess is one permission a request needs
+type scopeAccess struct {
+	operation string // empty: the bucket only has to be in some scope (HeadBucket)
+	bucket    string // empty for ListBuckets
+	key       string // object key, or the prefix of a listing
+	object    bool   // key applies; false for bucket-level requests
+}
+
+// SetScopeSource makes the scopes of source replace the s3_clients scopes of
+// the clients it lists. Clients it does not list keep their s3_clients scopes
+// and are denied every request without them. It must be called before the
+// service authorizes requests.
+func (s *S3AuthenticationService) SetScopeSource(source authz.ScopeSource) {
+	s.scopeSource = source
+}
+
+// AuthorizeRequest checks the request against the scopes of the client. A
+// client without scopes may make any request, unless a scope source is set.
+func (s *S3AuthenticationService) AuthorizeRequest(r *http.Request, client *config.S3ClientCredentials) error {
+	if client == nil {
+		return nil
+	}
+	scopes := client.Scopes
+	if s.scopeSource != nil {
+		if listed, ok := s.scopeSource.Scopes(client.AccessKeyID); ok {
+			scopes = listed
+		} else if len(scopes) == 0 {
+			s.logSecurityEvent("scope_denied", r, client.AccessKeyID+": not in the authorization policy")
+			return fmt.Errorf("%w: %s has no scopes in the authorization policy", ErrAccessDenied, client.AccessKeyID)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	accesses, err := scopeAccesses(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+	}
+	for _, access := range accesses {
+		if !scopesAllow(scopes, access) {
+			s.logSecurityEvent("scope_denied", r, fmt.Sprintf("%s: %s %s/%s", client.AccessKeyID, access.operation, access.bucket, access.key))
+			return fmt.Errorf("%w: %s is not allowed to %s %s", ErrAccessDenied, client.AccessKeyID, accessOperation(access), accessResource(access))
+		}
+	}
+	return nil
+}
+
+// scopeAccesses returns the permissions a path-style request needs
+func scopeAccesses(r *http.Request) ([]scopeAccess, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	if bucket == "" {
+		return []scopeAccess{{operation: config.ScopeOperationList}}, nil
+	}
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPost && query.Has("delete"):
+			return deleteObjectsAccesses(r, bucket)
+		case r.Method == http.MethodHead:
+			return []scopeAccess{{bucket: bucket}}, nil
+		case hasAny(query, bucketSubresources) || r.Method != http.MethodGet:
+			return []scopeAccess{{operation: config.ScopeOperationBucket, bucket: bucket}}, nil
+		default:
+			// ListObjects, ListObjectsV2, ListObjectVersions, ListMultipartUploads
+			return []scopeAccess{{operation: config.ScopeOperationList, bucket: bucket, key: query.Get("prefix"), object: true}}, nil
+		}
+	}
+
+	operation := config.ScopeOperationWrite
+	switch {
+	case query.Has("uploadId") || (r.Method == http.MethodPost && query.Has("uploads")):
+		operation = config.ScopeOperationMultipart
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		operation = config.ScopeOperationRead
+	case r.Method == http.MethodPost:
+		if query.Has("select") {
+			operation = config.ScopeOperationRead
+		}
+	case r.Method == http.MethodDelete:
+		if !query.Has("tagging") {
+			operation = config.ScopeOperationDelete
+		}
+	}
+	accesses := []scopeAccess{{operation: operation, bucket: bucket, key: key, object: true}}
+
+	if source := r.Header.Get("X-Amz-Copy-Source"); source != "" && r.Method == http.MethodPut {
+		sourceBucket, sourceKey, err := parseCopySource(source)
+		if err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, scopeAccess{operation: config.ScopeOperationRead, bucket: sourceBucket, key: sourceKey, object: true})
+	}
+	return accesses, nil
+}
+
+// deleteObjectsAccesses returns a delete permission per key of a DeleteObjects
+// request. The body is restored for the handler.
+func deleteObjectsAccesses(r *http.Request, bucket string) ([]scopeAccess, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("missing delete request body")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDeleteObjectsBody+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delete request: %w", err)
+	}
+	if len(body) > maxDeleteObjectsBody {
+		return nil, fmt.Errorf("delete request too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var deleteRequest struct {
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := request.DecodeXML(bytes.NewReader(body), "Delete", &deleteRequest, request.XMLLimits{}); err != nil {
+		return nil, fmt.Errorf("malformed delete request: %w", err)
+	}
+
+	accesses := make([]scopeAccess, 0, len(deleteRequest.Objects))
+	for _, object := range deleteRequest.Objects {
+		accesses = append(accesses, scopeAccess{operation: config.ScopeOperationDelete, bucket: bucket, key: object.Key, object: true})
+	}
+	return accesses, nil
+}
+
+// parseCopySource splits an x-amz-copy-source value ("bucket/key" or
+// "/bucket/key", URL-encoded, optionally with "?versionId=") into bucket and key
+func parseCopySource(source string) (string, string, error) {
+	source, _, _ = strings.Cut(strings.TrimPrefix(source, "/"), "?")
+	decoded, err := url.PathUnescape(source)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid copy source: %w", err)
+	}
+	bucket, key, _ := strings.Cut(decoded, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid copy source: %s", source)
+	}
+	return bucket, key, nil
+}
+
+// scopesAllow reports whether any scope allows access
+func scopesAllow(scopes []config.S3ClientScope, access scopeAccess) bool {
+	for _, scope := range scopes {
+		if scopeAllows(scope, access) {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeAllows(scope config.S3ClientScope, access scopeAccess) bool {
+	if access.bucket == "" {
+		// ListBuckets reveals every bucket name
+		return len(scope.Buckets) == 0 && containsOrEmpty(scope.Operations, access.operation)
+	}
+	if !bucketMatches(scope.Buckets, access.bucket) {
+		return false
+	}
+	if access.operation == "" {
+		return true
+	}
+	if !containsOrEmpty(scope.Operations, access.operation) &&
+		(access.operation != config.ScopeOperationMultipart || !slices.Contains(scope.Operations, config.ScopeOperationWrite)) {
+		// write implies multipart
+		return false
+	}
+	if len(scope.Prefixes) == 0 {
+		return true
+	}
+	if !access.object {
+		return false
+	}
+	for _, prefix := range scope.Prefixes {
+		if strings.HasPrefix(access.key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketMatches reports whether bucket matches one of the patterns; a
+// trailing "*" matches a name prefix
+func bucketMatches(patterns []string, bucket string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(bucket, prefix) {
+				return true
+			}
+		} else if pattern == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOrEmpty(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAny(query url.Values, names []string) bool {
+	for _, name := range names {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func accessOperation(access scopeAccess) string {
+	if access.operation == "" {
+		return "access"
+	}
+	return access.operation
+}
+
+func accessResource(access scopeAccess) string {
+	switch {
+	case access.bucket == "":
+		return "buckets"
+	case access.object:
+		return access.bucket + "/" + access.key
+	default:
+		return access.bucket
+	}
+}
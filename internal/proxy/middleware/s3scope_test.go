@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func newTestScopeService() *S3AuthenticationService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewS3AuthenticationService(&config.Config{}, logger)
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+	client := &config.S3ClientCredentials{
+		AccessKeyID: "app-client",
+		Scopes: []config.S3ClientScope{
+			{Buckets: []string{"data"}, Prefixes: []string{"app/"}, Operations: []string{"read", "write", "list"}},
+			{Buckets: []string{"logs-*"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		body    string
+		allowed bool
+	}{
+		{name: "get in prefix", method: http.MethodGet, target: "/data/app/file.txt", allowed: true},
+		{name: "head in prefix", method: http.MethodHead, target: "/data/app/file.txt", allowed: true},
+		{name: "get outside prefix", method: http.MethodGet, target: "/data/other/file.txt"},
+		{name: "put in prefix", method: http.MethodPut, target: "/data/app/file.txt", allowed: true},
+		{name: "multipart part", method: http.MethodPut, target: "/data/app/big?partNumber=1&uploadId=u1", allowed: true},
+		{name: "abort multipart", method: http.MethodDelete, target: "/data/app/big?uploadId=u1", allowed: true},
+		{name: "delete not allowed", method: http.MethodDelete, target: "/data/app/file.txt"},
+		{name: "list with prefix", method: http.MethodGet, target: "/data?list-type=2&prefix=app/sub/", allowed: true},
+		{name: "list without prefix", method: http.MethodGet, target: "/data?list-type=2"},
+		{name: "head bucket", method: http.MethodHead, target: "/data", allowed: true},
+		{name: "bucket configuration", method: http.MethodPut, target: "/data?versioning"},
+		{name: "other bucket", method: http.MethodGet, target: "/secret/app/file.txt"},
+		{name: "list buckets", method: http.MethodGet, target: "/"},
+		{name: "wildcard bucket", method: http.MethodDelete, target: "/logs-2024/any/key", allowed: true},
+		{name: "wildcard bucket configuration", method: http.MethodPut, target: "/logs-2024?lifecycle", allowed: true},
+		{name: "copy from allowed source", method: http.MethodPut, target: "/data/app/copy",
+			headers: map[string]string{"X-Amz-Copy-Source": "/logs-2024/a%20b.txt?versionId=1"}, allowed: true},
+		{name: "copy from denied source", method: http.MethodPut, target: "/data/app/copy",
+			headers: map[string]string{"X-Amz-Copy-Source": "data/other/file.txt"}},
+		{name: "delete objects allowed", method: http.MethodPost, target: "/logs-2024?delete",
+			body: `<Delete><Object><Key>a</Key></Object><Object><Key>b</Key></Object></Delete>`, allowed: true},
+		{name: "delete objects denied", method: http.MethodPost, target: "/data?delete",
+			body: `<Delete><Object><Key>app/a</Key></Object></Delete>`},
+		{name: "delete objects malformed", method: http.MethodPost, target: "/logs-2024?delete", body: `<Delete>`},
+	}
+
+	service := newTestScopeService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			err := service.AuthorizeRequest(req, client)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrAccessDenied)
+		})
+	}
+}
+
+func TestAuthorizeRequest_Unscoped(t *testing.T) {
+	service := newTestScopeService()
+	req := httptest.NewRequest(http.MethodDelete, "/any/key", nil)
+
+	assert.NoError(t, service.AuthorizeRequest(req, nil))
+	assert.NoError(t, service.AuthorizeRequest(req, &config.S3ClientCredentials{AccessKeyID: "admin-client"}))
+
+	// ListBuckets needs a scope without bucket restriction
+	client := &config.S3ClientCredentials{Scopes: []config.S3ClientScope{{Operations: []string{"list"}}}}
+	assert.NoError(t, service.AuthorizeRequest(httptest.NewRequest(http.MethodGet, "/", nil), client))
+}
+
+func TestAuthorizeRequest_RestoresDeleteBody(t *testing.T) {
+	service := newTestScopeService()
+	client := &config.S3ClientCredentials{Scopes: []config.S3ClientScope{{Buckets: []string{"data"}}}}
+	body := `<Delete><Object><Key>a</Key></Object></Delete>`
+	req := httptest.NewRequest(http.MethodPost, "/data?delete", strings.NewReader(body))
+
+	require.NoError(t, service.AuthorizeRequest(req, client))
+	restored, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(restored))
+}
+
+func TestAuthorizeRequest_Multipart(t *testing.T) {
+	service := newTestScopeService()
+	client := &config.S3ClientCredentials{Scopes: []config.S3ClientScope{{Buckets: []string{"uploads"}, Operations: []string{"multipart"}}}}
+
+	for _, target := range []string{"/uploads/big?uploads", "/uploads/big?uploadId=u1"} {
+		assert.NoError(t, service.AuthorizeRequest(httptest.NewRequest(http.MethodPost, target, nil), client), target)
+	}
+	assert.NoError(t, service.AuthorizeRequest(httptest.NewRequest(http.MethodPut, "/uploads/big?partNumber=1&uploadId=u1", nil), client))
+	assert.NoError(t, service.AuthorizeRequest(httptest.NewRequest(http.MethodGet, "/uploads/big?uploadId=u1", nil), client))
+
+	// Multipart alone allows no single PUT
+	assert.ErrorIs(t, service.AuthorizeRequest(httptest.NewRequest(http.MethodPut, "/uploads/small", nil), client), ErrAccessDenied)
+}
+
+type staticScopeSource map[string][]config.S3ClientScope
+
+func (s staticScopeSource) Scopes(accessKeyID string) ([]config.S3ClientScope, bool) {
+	scopes, ok := s[accessKeyID]
+	return scopes, ok
+}
+
+func TestAuthorizeRequest_ScopeSource(t *testing.T) {
+	service := newTestScopeService()
+	service.SetScopeSource(staticScopeSource{
+		"tenant-a": {{Buckets: []string{"tenant-a-*"}}},
+	})
+	get := func(target string) *http.Request { return httptest.NewRequest(http.MethodGet, target, nil) }
+
+	// The policy replaces the s3_clients scopes
+	tenant := &config.S3ClientCredentials{AccessKeyID: "tenant-a", Scopes: []config.S3ClientScope{{Buckets: []string{"shared"}}}}
+	assert.NoError(t, service.AuthorizeRequest(get("/tenant-a-data/key"), tenant))
+	assert.ErrorIs(t, service.AuthorizeRequest(get("/shared/key"), tenant), ErrAccessDenied)
+
+	// Unlisted clients keep their own scopes, and need some
+	scoped := &config.S3ClientCredentials{AccessKeyID: "reporting", Scopes: []config.S3ClientScope{{Buckets: []string{"shared"}}}}
+	assert.NoError(t, service.AuthorizeRequest(get("/shared/key"), scoped))
+	assert.ErrorIs(t, service.AuthorizeRequest(get("/shared/key"), &config.S3ClientCredentials{AccessKeyID: "unlisted"}), ErrAccessDenied)
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+)
+
+// SSEC enforces encryption.sse_c_mode on requests with customer-provided
+// keys. In passthrough mode only the single-part object operations reach
+// the handlers; they store and read such objects without proxy encryption.
+type SSEC struct {
+	mode        string
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+}
+
+// NewSSEC creates the SSE-C middleware; an empty mode rejects SSE-C requests
+func NewSSEC(mode string, logger *logrus.Entry) *SSEC {
+	if mode == "" {
+		mode = config.SSECModeReject
+	}
+	return &SSEC{
+		mode:        mode,
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (s *SSEC) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasSSEC := request.ParseSSEC(r)
+		copySSEC := request.HasCopySourceSSEC(r)
+		if !hasSSEC && !copySSEC {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.mode != config.SSECModePassthrough {
+			s.reject(w, r, http.StatusBadRequest, "InvalidRequest",
+				"Server-side encryption with customer-provided keys is not supported: objects are encrypted by the proxy")
+			return
+		}
+
+		query := r.URL.Query()
+		_, initiate := query["uploads"]
+		if initiate || query.Has("uploadId") || copySSEC || r.Header.Get("X-Amz-Copy-Source") != "" {
+			s.reject(w, r, http.StatusNotImplemented, "NotImplemented",
+				"Server-side encryption with customer-provided keys is only passed through for single-part uploads, GET and HEAD")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *SSEC) reject(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"mode":   s.mode,
+		"code":   code,
+	}).Debug("Rejected SSE-C request")
+	s.errorWriter.WriteError(w, r, status, code, message)
+}
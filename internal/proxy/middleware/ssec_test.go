@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+func TestSSEC(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		target string
+		ssec   bool
+		copy   bool
+		status int
+	}{
+		{"plain request", config.SSECModeReject, "/bucket/key", false, false, http.StatusOK},
+		{"rejected", config.SSECModeReject, "/bucket/key", true, false, http.StatusBadRequest},
+		{"default mode rejects", "", "/bucket/key", true, false, http.StatusBadRequest},
+		{"copy source rejected", config.SSECModeReject, "/bucket/key", false, true, http.StatusBadRequest},
+		{"passthrough", config.SSECModePassthrough, "/bucket/key", true, false, http.StatusOK},
+		{"passthrough multipart", config.SSECModePassthrough, "/bucket/key?uploads", true, false, http.StatusNotImplemented},
+		{"passthrough part", config.SSECModePassthrough, "/bucket/key?uploadId=u&partNumber=1", true, false, http.StatusNotImplemented},
+		{"passthrough copy", config.SSECModePassthrough, "/bucket/key", false, true, http.StatusNotImplemented},
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSSEC(tt.mode, logrus.NewEntry(logger)).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPut, tt.target, nil)
+			if tt.ssec {
+				req.Header.Set(request.SSECAlgorithmHeader, "AES256")
+			}
+			if tt.copy {
+				req.Header.Set("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Algorithm", "AES256")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
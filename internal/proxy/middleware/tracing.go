@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
+)
+
+// Tracing starts the server span of every S3 request, named after its S3
+// operation and continuing the trace of the client's traceparent header.
+// The spans of the handlers, the encryption layer and the backend calls are
+// its children. When tracing is disabled it passes requests through.
+type Tracing struct {
+	enabled bool
+}
+
+// NewTracing creates a new tracing middleware
+func NewTracing(enabled bool) *Tracing {
+	return &Tracing{enabled: enabled}
+}
+
+// Middleware returns the HTTP middleware function
+func (t *Tracing) Middleware(next http.Handler) http.Handler {
+	if !t.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		attributes := []attribute.KeyValue{
+			attribute.String("http.request.method", r.Method),
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.service", "S3"),
+		}
+		if id := requestid.FromContext(ctx); id != "" {
+			attributes = append(attributes, attribute.String("aws.request_id", id))
+		}
+		if bucket := vars["bucket"]; bucket != "" {
+			attributes = append(attributes, attribute.String("aws.s3.bucket", bucket))
+		}
+		query := r.URL.Query()
+		if uploadID := query.Get("uploadId"); uploadID != "" {
+			attributes = append(attributes, attribute.String("aws.s3.upload_id", uploadID))
+		}
+		if partNumber, err := strconv.Atoi(query.Get("partNumber")); err == nil {
+			attributes = append(attributes, attribute.Int("aws.s3.part_number", partNumber))
+		}
+		if r.ContentLength > 0 {
+			attributes = append(attributes, attribute.Int64("http.request.body.size", r.ContentLength))
+		}
+
+		operation := s3Operation(r, vars["bucket"], vars["key"])
+		attributes = append(attributes, attribute.String("rpc.method", operation))
+		ctx, span := tracing.StartServer(ctx, operation, attributes...)
+		defer span.End()
+
+		tracked := newStatusWriter(w, nil)
+		next.ServeHTTP(tracked, r.WithContext(ctx))
+
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", tracked.status),
+			attribute.Int64("http.response.body.size", tracked.bytes),
+		)
+		if tracked.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(tracked.status))
+		}
+	})
+}
+
+// HandlerMiddleware returns the HTTP middleware function starting the span of
+// the handler of the matched route, e.g. "object.(*Handler).Handle". It goes
+// innermost, so the request span also covers authentication and the other
+// middleware.
+func (t *Tracing) HandlerMiddleware(next http.Handler) http.Handler {
+	if !t.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "handler"
+		if route := mux.CurrentRoute(r); route != nil && route.GetHandler() != nil {
+			name = handlerName(route.GetHandler())
+		}
+		ctx, span := tracing.Start(r.Context(), name)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// handlerName returns the package-qualified function name of handler
+func handlerName(handler http.Handler) string {
+	value := reflect.ValueOf(handler)
+	if value.Kind() != reflect.Func {
+		return reflect.Indirect(value).Type().String()
+	}
+	name := path.Base(runtime.FuncForPC(value.Pointer()).Name())
+	return strings.TrimSuffix(name, "-fm")
+}
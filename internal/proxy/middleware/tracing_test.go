@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes the global tracer provider record the ended spans for
+// the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+type tracedTestHandler struct{}
+
+func (tracedTestHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if !trace.SpanFromContext(r.Context()).SpanContext().IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+
+	tracing := NewTracing(true)
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware)
+	router.Use(tracing.HandlerMiddleware)
+	router.HandleFunc("/{bucket}/{key:.*}", tracedTestHandler{}.Handle)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key?partNumber=3&uploadId=upload", strings.NewReader("data"))
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	handlerSpan, requestSpan := spans[0], spans[1]
+
+	assert.Equal(t, "UploadPart", requestSpan.Name())
+	assert.Equal(t, trace.SpanKindServer, requestSpan.SpanKind())
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", requestSpan.SpanContext().TraceID().String())
+	assert.Equal(t, "b7ad6b7169203331", requestSpan.Parent().SpanID().String(), "continues the client's trace")
+	assert.Equal(t, codes.Error, requestSpan.Status().Code)
+	attributes := spanAttributes(requestSpan)
+	assert.Equal(t, "bucket", attributes["aws.s3.bucket"].AsString())
+	assert.Equal(t, "upload", attributes["aws.s3.upload_id"].AsString())
+	assert.Equal(t, int64(3), attributes["aws.s3.part_number"].AsInt64())
+	assert.Equal(t, int64(4), attributes["http.request.body.size"].AsInt64())
+	assert.Equal(t, int64(http.StatusServiceUnavailable), attributes["http.response.status_code"].AsInt64())
+
+	assert.Equal(t, "middleware.tracedTestHandler.Handle", handlerSpan.Name())
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), handlerSpan.Parent().SpanID())
+}
+
+func TestTracing_Disabled(t *testing.T) {
+	recorder := recordSpans(t)
+
+	tracing := NewTracing(false)
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware)
+	router.Use(tracing.HandlerMiddleware)
+	router.HandleFunc("/{bucket}/{key:.*}", tracedTestHandler{}.Handle)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, recorder.Ended())
+}
@@ -1,16 +1,23 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// requestWaitInterval is how often Wait checks for requests still in flight
+const requestWaitInterval = 10 * time.Millisecond
+
 // RequestTracker tracks active requests for graceful shutdown
 type RequestTracker struct {
 	logger              *logrus.Entry
 	requestStartHandler func()
 	requestEndHandler   func()
+	active              atomic.Int64
 }
 
 // NewRequestTracker creates a new request tracker middleware
@@ -26,9 +33,32 @@ func (rt *RequestTracker) SetHandlers(onStart, onEnd func()) {
 	rt.requestEndHandler = onEnd
 }
 
+// Active returns the number of requests in flight
+func (rt *RequestTracker) Active() int64 {
+	return rt.active.Load()
+}
+
+// Wait blocks until no request is in flight or ctx is done
+func (rt *RequestTracker) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(requestWaitInterval)
+	defer ticker.Stop()
+
+	for rt.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
 // Middleware returns the HTTP middleware function
 func (rt *RequestTracker) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.active.Add(1)
+		defer rt.active.Add(-1)
+
 		if rt.requestStartHandler != nil {
 			rt.requestStartHandler()
 		}
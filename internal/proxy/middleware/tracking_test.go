@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTracker_Wait(t *testing.T) {
+	tracker := NewRequestTracker(logrus.NewEntry(logrus.New()))
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := tracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	require.NoError(t, tracker.Wait(context.Background()), "nothing in flight")
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/bucket/key", nil))
+		close(done)
+	}()
+	<-started
+	assert.Equal(t, int64(1), tracker.Active())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tracker.Wait(ctx), context.DeadlineExceeded)
+
+	close(release)
+	<-done
+	require.NoError(t, tracker.Wait(context.Background()))
+	assert.Equal(t, int64(0), tracker.Active())
+}
@@ -0,0 +1,332 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/usage"
+)
+
+// multipartIdleTimeout is how long the part sizes of a multipart upload
+// without new parts are kept; backends usually abort such uploads by lifecycle
+const multipartIdleTimeout = 7 * 24 * time.Hour
+
+// multipartPruneInterval is the time between scans for idle multipart uploads
+const multipartPruneInterval = time.Hour
+
+// UploadLimits rejects uploads above the configured object and part sizes
+// with 400 EntityTooLarge, and uploads that would take a bucket beyond its
+// quota with 403 QuotaExceeded.
+//
+// Quotas are checked against the plaintext bytes of the last usage
+// collection plus the bytes uploaded through the proxy since. Overwrites and
+// deletes only count once the next collection sees them, and buckets that
+// were not collected yet are not limited. Copies are not limited, as their
+// size is unknown before they are made.
+type UploadLimits struct {
+	limits      config.UploadLimitsConfig
+	bucketUsage func(bucket string) (usage.BucketUsage, error)
+	logger      *logrus.Entry
+	errorWriter *response.ErrorWriter
+	now         func() time.Time
+
+	mu       sync.Mutex
+	uploads  map[string]*multipartUpload // by upload ID
+	added    map[string]*addedBytes      // by bucket
+	prunedAt time.Time
+}
+
+// multipartUpload holds the part sizes of an upload, for max_object_size
+type multipartUpload struct {
+	parts    map[string]int64 // by part number
+	total    int64
+	lastUsed time.Time
+}
+
+// addedBytes are the bytes uploaded to a bucket that its last usage
+// collection may not include
+type addedBytes struct {
+	slots    []addedSlot // per minute, oldest first
+	inFlight int64       // of uploads not answered yet
+}
+
+type addedSlot struct {
+	minute time.Time
+	bytes  int64
+}
+
+// NewUploadLimits creates the upload limits middleware. bucketUsage is called
+// per upload to a bucket with a quota.
+func NewUploadLimits(limits config.UploadLimitsConfig, bucketUsage func(bucket string) (usage.BucketUsage, error), logger *logrus.Entry) *UploadLimits {
+	return &UploadLimits{
+		limits:      limits,
+		bucketUsage: bucketUsage,
+		logger:      logger,
+		errorWriter: response.NewErrorWriter(logger),
+		now:         time.Now,
+		uploads:     make(map[string]*multipartUpload),
+		added:       make(map[string]*addedBytes),
+	}
+}
+
+// Middleware returns the HTTP middleware function
+func (u *UploadLimits) Middleware(next http.Handler) http.Handler {
+	if u.limits.MaxObjectSize == 0 && u.limits.MaxPartSize == 0 && len(u.limits.Quotas) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucket, key := vars["bucket"], vars["key"]
+		query := r.URL.Query()
+		uploadID := query.Get("uploadId")
+
+		switch {
+		case key == "" || r.Header.Get("X-Amz-Copy-Source") != "":
+			next.ServeHTTP(w, r)
+		case r.Method == http.MethodPut && uploadID == "" && storesObjectData(r):
+			u.serveUpload(w, r, next, bucket, "", "")
+		case r.Method == http.MethodPut && uploadID != "" && query.Has("partNumber"):
+			u.serveUpload(w, r, next, bucket, uploadID, query.Get("partNumber"))
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			u.serveCreateMultipartUpload(w, r, next, bucket)
+		case (r.Method == http.MethodPost || r.Method == http.MethodDelete) && uploadID != "":
+			// CompleteMultipartUpload and AbortMultipartUpload end the upload
+			tracked := newStatusWriter(w, nil)
+			next.ServeHTTP(tracked, r)
+			if tracked.status < 300 {
+				u.forgetUpload(uploadID)
+			}
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serveUpload checks and accounts a PutObject, or an UploadPart if uploadID
+// is set
+func (u *UploadLimits) serveUpload(w http.ResponseWriter, r *http.Request, next http.Handler, bucket, uploadID, partNumber string) {
+	quota := u.quotaFor(bucket)
+	if quota == 0 && u.limits.MaxObjectSize == 0 && (uploadID == "" || u.limits.MaxPartSize == 0) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	size, ok := uploadSize(r)
+	if !ok {
+		u.reject(w, r, http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header")
+		return
+	}
+	if uploadID == "" && u.limits.MaxObjectSize > 0 && size > u.limits.MaxObjectSize {
+		u.reject(w, r, http.StatusBadRequest, "EntityTooLarge",
+			fmt.Sprintf("Your proposed upload exceeds the maximum allowed object size of %d bytes", u.limits.MaxObjectSize))
+		return
+	}
+	if uploadID != "" && u.limits.MaxPartSize > 0 && size > u.limits.MaxPartSize {
+		u.reject(w, r, http.StatusBadRequest, "EntityTooLarge",
+			fmt.Sprintf("Your proposed upload exceeds the maximum allowed part size of %d bytes", u.limits.MaxPartSize))
+		return
+	}
+
+	trackPart := uploadID != "" && u.limits.MaxObjectSize > 0
+	var previous int64
+	if trackPart {
+		var ok bool
+		if previous, ok = u.reservePart(uploadID, partNumber, size); !ok {
+			u.reject(w, r, http.StatusBadRequest, "EntityTooLarge",
+				fmt.Sprintf("The parts of this multipart upload exceed the maximum allowed object size of %d bytes", u.limits.MaxObjectSize))
+			return
+		}
+	}
+	trackQuota := false
+	if quota > 0 {
+		var ok bool
+		if trackQuota, ok = u.reserveQuota(bucket, quota, size); !ok {
+			if trackPart {
+				u.releasePart(uploadID, partNumber, size, previous)
+			}
+			u.rejectQuota(w, r, bucket, quota)
+			return
+		}
+	}
+
+	succeeded := false
+	defer func() {
+		if trackPart && !succeeded {
+			u.releasePart(uploadID, partNumber, size, previous)
+		}
+		if trackQuota {
+			u.settleQuota(bucket, size, succeeded)
+		}
+	}()
+	tracked := newStatusWriter(w, nil)
+	next.ServeHTTP(tracked, r)
+	succeeded = tracked.status < 300
+}
+
+// serveCreateMultipartUpload rejects new multipart uploads to a bucket that
+// has reached its quota
+func (u *UploadLimits) serveCreateMultipartUpload(w http.ResponseWriter, r *http.Request, next http.Handler, bucket string) {
+	if quota := u.quotaFor(bucket); quota > 0 {
+		if _, ok := u.reserveQuota(bucket, quota, 0); !ok {
+			u.rejectQuota(w, r, bucket, quota)
+			return
+		}
+	}
+	next.ServeHTTP(w, r)
+}
+
+// quotaFor returns the quota of bucket, 0 without one or without usage
+func (u *UploadLimits) quotaFor(bucket string) int64 {
+	if u.bucketUsage == nil {
+		return 0
+	}
+	return u.limits.QuotaFor(bucket)
+}
+
+// uploadSize returns the payload size of r: the decoded length of
+// aws-chunked uploads, else Content-Length. ok is false if it is unknown.
+func uploadSize(r *http.Request) (size int64, ok bool) {
+	if value := r.Header.Get("X-Amz-Decoded-Content-Length"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil && n >= 0
+	}
+	return r.ContentLength, r.ContentLength >= 0
+}
+
+// reservePart records size as the size of a part and returns the size of the
+// part it replaces. ok is false if the parts would exceed max_object_size.
+func (u *UploadLimits) reservePart(uploadID, partNumber string, size int64) (previous int64, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.now()
+	if now.Sub(u.prunedAt) >= multipartPruneInterval {
+		u.prunedAt = now
+		for id, upload := range u.uploads {
+			if now.Sub(upload.lastUsed) > multipartIdleTimeout {
+				delete(u.uploads, id)
+			}
+		}
+	}
+
+	upload, exists := u.uploads[uploadID]
+	if !exists {
+		upload = &multipartUpload{parts: make(map[string]int64)}
+		u.uploads[uploadID] = upload
+	}
+	previous = upload.parts[partNumber]
+	total := upload.total - previous + size
+	if total > u.limits.MaxObjectSize {
+		if !exists {
+			delete(u.uploads, uploadID)
+		}
+		return 0, false
+	}
+	upload.parts[partNumber] = size
+	upload.total = total
+	upload.lastUsed = now
+	return previous, true
+}
+
+// releasePart restores the size of a part that failed to upload
+func (u *UploadLimits) releasePart(uploadID, partNumber string, size, previous int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload, ok := u.uploads[uploadID]
+	if !ok || upload.parts[partNumber] != size {
+		return
+	}
+	upload.total += previous - size
+	if previous == 0 {
+		delete(upload.parts, partNumber)
+	} else {
+		upload.parts[partNumber] = previous
+	}
+}
+
+// forgetUpload drops the part sizes of a completed or aborted upload
+func (u *UploadLimits) forgetUpload(uploadID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.uploads, uploadID)
+}
+
+// reserveQuota counts size as in flight to bucket. tracked is false if the
+// bucket was not collected yet, so the quota cannot be checked; ok is false if
+// size would exceed quota.
+func (u *UploadLimits) reserveQuota(bucket string, quota, size int64) (tracked, ok bool) {
+	collected, err := u.bucketUsage(bucket)
+	if err != nil || collected.CollectedAt.IsZero() {
+		return false, true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	added := u.added[bucket]
+	if added == nil {
+		added = &addedBytes{}
+		u.added[bucket] = added
+	}
+	// Uploads answered before the collection started are part of its counts
+	started := collected.CollectedAt.Add(-collected.Duration)
+	for len(added.slots) > 0 && !added.slots[0].minute.Add(time.Minute).After(started) {
+		added.slots = added.slots[1:]
+	}
+	used := collected.PlaintextBytes + added.inFlight
+	for _, slot := range added.slots {
+		used += slot.bytes
+	}
+	// Once full, even empty objects and new multipart uploads are rejected
+	if used+size > quota || used >= quota {
+		return false, false
+	}
+	added.inFlight += size
+	return true, true
+}
+
+// settleQuota ends an upload reserved by reserveQuota, counting its bytes if
+// it succeeded
+func (u *UploadLimits) settleQuota(bucket string, size int64, succeeded bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	added := u.added[bucket]
+	added.inFlight -= size
+	if !succeeded || size == 0 {
+		return
+	}
+	minute := u.now().Truncate(time.Minute)
+	if n := len(added.slots); n > 0 && added.slots[n-1].minute.Equal(minute) {
+		added.slots[n-1].bytes += size
+		return
+	}
+	added.slots = append(added.slots, addedSlot{minute: minute, bytes: size})
+}
+
+func (u *UploadLimits) rejectQuota(w http.ResponseWriter, r *http.Request, bucket string, quota int64) {
+	u.reject(w, r, http.StatusForbidden, "QuotaExceeded",
+		fmt.Sprintf("The upload exceeds the quota of %d bytes of bucket %s", quota, bucket))
+}
+
+func (u *UploadLimits) reject(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	u.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method":         r.Method,
+		"path":           r.URL.Path,
+		"content_length": r.ContentLength,
+		"code":           code,
+	}).Warn("Rejected upload by upload limits")
+
+	// Close the connection instead of reading the rejected body
+	w.Header().Set("Connection", "close")
+	u.errorWriter.WriteError(w, r, status, code, message)
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/usage"
+)
+
+// newUploadLimitsRouter serves the middleware in front of a handler answering
+// uploads with status
+func newUploadLimitsRouter(limits *UploadLimits, status *int) http.Handler {
+	router := mux.NewRouter()
+	router.Use(limits.Middleware)
+	router.HandleFunc("/{bucket}/{key:.*}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(*status)
+	})
+	return router
+}
+
+func serveUpload(handler http.Handler, method, target string, size int) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(strings.Repeat("x", size))))
+	return rec
+}
+
+func TestUploadLimits_ObjectAndPartSize(t *testing.T) {
+	limits := NewUploadLimits(config.UploadLimitsConfig{MaxObjectSize: 100, MaxPartSize: 40}, nil, logrus.NewEntry(logrus.New()))
+	status := http.StatusOK
+	handler := newUploadLimitsRouter(limits, &status)
+
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key", 100).Code)
+	rec := serveUpload(handler, http.MethodPut, "/bucket/key", 101)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "EntityTooLarge")
+	assert.Equal(t, "close", rec.Header().Get("Connection"))
+
+	rec = serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=1", 41)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "part above max_part_size")
+
+	// The parts count towards max_object_size together; a re-uploaded part
+	// replaces the size of the previous one
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=1", 40).Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=2", 40).Code)
+	assert.Equal(t, http.StatusBadRequest, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=3", 40).Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=2", 20).Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u1&partNumber=3", 40).Code)
+
+	// A failed part does not count
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u2&partNumber=1", 40).Code)
+	status = http.StatusOK
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u2&partNumber=2", 40).Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?uploadId=u2&partNumber=3", 40).Code)
+
+	// Completing an upload forgets its parts
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPost, "/bucket/key?uploadId=u1", 0).Code)
+	limits.mu.Lock()
+	assert.NotContains(t, limits.uploads, "u1")
+	assert.Contains(t, limits.uploads, "u2")
+	limits.mu.Unlock()
+}
+
+func TestUploadLimits_MissingContentLength(t *testing.T) {
+	limits := NewUploadLimits(config.UploadLimitsConfig{MaxObjectSize: 100}, nil, logrus.NewEntry(logrus.New()))
+	status := http.StatusOK
+	handler := newUploadLimitsRouter(limits, &status)
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("data"))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusLengthRequired, rec.Code)
+	assert.Contains(t, rec.Body.String(), "MissingContentLength")
+
+	// aws-chunked uploads announce the payload size separately
+	req = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("data"))
+	req.ContentLength = -1
+	req.Header.Set("X-Amz-Decoded-Content-Length", "101")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Copies and other requests are passed through
+	req = httptest.NewRequest(http.MethodPut, "/bucket/key", nil)
+	req.Header.Set("X-Amz-Copy-Source", "/other/key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/bucket/key?tagging", 1000).Code)
+}
+
+func TestUploadLimits_Quota(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	collected := map[string]usage.BucketUsage{
+		"team-a": {Bucket: "team-a", PlaintextBytes: 60, CollectedAt: now.Add(-time.Hour), Duration: time.Minute},
+	}
+	bucketUsage := func(bucket string) (usage.BucketUsage, error) {
+		if u, ok := collected[bucket]; ok {
+			return u, nil
+		}
+		return usage.BucketUsage{}, usage.ErrNotCollected
+	}
+	limits := NewUploadLimits(config.UploadLimitsConfig{
+		Quotas: []config.BucketQuotaRule{{Buckets: []string{"team-*"}, MaxBytes: 100}},
+	}, bucketUsage, logrus.NewEntry(logrus.New()))
+	limits.now = func() time.Time { return now }
+	status := http.StatusOK
+	handler := newUploadLimitsRouter(limits, &status)
+
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/team-a/key", 30).Code)
+	rec := serveUpload(handler, http.MethodPut, "/team-a/key", 20)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "60 collected and 30 uploaded since")
+	assert.Contains(t, rec.Body.String(), "QuotaExceeded")
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/team-a/key?uploadId=u1&partNumber=1", 10).Code)
+	assert.Equal(t, http.StatusForbidden, serveUpload(handler, http.MethodPost, "/team-a/key?uploads", 0).Code, "the bucket is full")
+
+	// Buckets without a quota or a collection are not limited
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/other/key", 1000).Code)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/team-b/key", 1000).Code)
+
+	// A new collection replaces the bytes uploaded before it started
+	now = now.Add(10 * time.Minute)
+	collected["team-a"] = usage.BucketUsage{Bucket: "team-a", PlaintextBytes: 90, CollectedAt: now, Duration: time.Minute}
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/team-a/key", 10).Code)
+	assert.Equal(t, http.StatusForbidden, serveUpload(handler, http.MethodPut, "/team-a/key", 1).Code)
+
+	// Failed uploads do not count
+	collected["team-a"] = usage.BucketUsage{Bucket: "team-a", PlaintextBytes: 0, CollectedAt: now.Add(time.Minute)}
+	now = now.Add(2 * time.Minute)
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serveUpload(handler, http.MethodPut, "/team-a/key", 100).Code)
+	status = http.StatusOK
+	assert.Equal(t, http.StatusOK, serveUpload(handler, http.MethodPut, "/team-a/key", 100).Code)
+}
@@ -1,10 +1,14 @@
 package proxy
 
 import (
-	"fmt"
 	"net/http"
 
+	"github.com/sirupsen/logrus"
+
+	proxyconfig "github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/middleware"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
 )
 
 // setupMiddleware sets up the middleware for the server
@@ -20,9 +24,44 @@ func (s *Server) setupMiddleware() {
 	}
 	s.httpLogger = middleware.NewLogger(s.logger, logHealthRequests)
 	s.corsHandler = middleware.NewCORS(s.logger)
+	s.requestIDs = middleware.NewRequestID()
+	s.s3Headers = middleware.NewS3Headers()
+	if s.config != nil {
+		s.hardening = middleware.NewHardening(s.config.GetListenerConfig(), s.config.TLS.Enabled, s.logger)
+		s.rateLimiter = middleware.NewRateLimiter(s.config.RateLimiting, s.logger)
+		s.recovery = middleware.NewRecovery(s.config.Recovery, s.logger)
+		s.keyCanonical = middleware.NewKeyCanonicalizer(s.config.KeyCanonicalization, s.logger)
+		s.ssec = middleware.NewSSEC(s.config.Encryption.SSECMode, s.logger)
+	} else {
+		s.hardening = middleware.NewHardening((&proxyconfig.Config{}).GetListenerConfig(), false, s.logger)
+		s.rateLimiter = middleware.NewRateLimiter(proxyconfig.RateLimitingConfig{}, s.logger)
+		s.recovery = middleware.NewRecovery(proxyconfig.RecoveryConfig{}, s.logger)
+		s.keyCanonical = middleware.NewKeyCanonicalizer(proxyconfig.KeyCanonicalizationConfig{}, s.logger)
+		s.ssec = middleware.NewSSEC(proxyconfig.SSECModeReject, s.logger)
+	}
+
+	s.audit = middleware.NewAudit(s.auditRecorder, s.logger)
+	s.notifications = middleware.NewNotifications(s.notificationEmitter, s.logger)
+	s.accessLogger = middleware.NewAccessLog(s.accessLog, s.logger)
+	s.tracing = middleware.NewTracing(s.config != nil && s.config.Tracing.Enabled)
+	s.bucketPolicy = middleware.NewBucketPolicy(s.config, s.logger)
+	s.licenseGate = middleware.NewLicenseGate(s.isLicenseRestricted, s.logger)
+	var uploadLimits proxyconfig.UploadLimitsConfig
+	if s.config != nil {
+		uploadLimits = s.config.UploadLimits
+	}
+	s.uploadLimits = middleware.NewUploadLimits(uploadLimits, s.bucketUsage, s.logger)
+	if s.objectCache != nil {
+		// Clients of different tenants may not decrypt each other's objects
+		perClient := len(s.config.Encryption.Tenants) > 0
+		s.objectCacher = middleware.NewObjectCache(s.objectCache, s.config.ObjectCache.Buckets, perClient, s.logger)
+	}
 
 	// Initialize S3 authentication service
 	s.s3AuthService = middleware.NewS3AuthenticationService(s.config, s.logger.Logger)
+	if s.scopeSource != nil {
+		s.s3AuthService.SetScopeSource(s.scopeSource)
+	}
 }
 
 // Middleware wrapper functions for compatibility with existing code
@@ -47,14 +86,144 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return s.corsHandler.Middleware(next)
 }
 
+func (s *Server) hardeningMiddleware(next http.Handler) http.Handler {
+	if s.hardening == nil {
+		s.setupMiddleware()
+	}
+	return s.hardening.Middleware(next)
+}
+
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	if s.rateLimiter == nil {
+		s.setupMiddleware()
+	}
+	return s.rateLimiter.Middleware(next)
+}
+
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	if s.requestIDs == nil {
+		s.setupMiddleware()
+	}
+	return s.requestIDs.Middleware(next)
+}
+
+func (s *Server) s3HeadersMiddleware(next http.Handler) http.Handler {
+	if s.s3Headers == nil {
+		s.setupMiddleware()
+	}
+	return s.s3Headers.Middleware(next)
+}
+
+func (s *Server) keyCanonicalizationMiddleware(next http.Handler) http.Handler {
+	if s.keyCanonical == nil {
+		s.setupMiddleware()
+	}
+	return s.keyCanonical.Middleware(next)
+}
+
+func (s *Server) ssecMiddleware(next http.Handler) http.Handler {
+	if s.ssec == nil {
+		s.setupMiddleware()
+	}
+	return s.ssec.Middleware(next)
+}
+
+func (s *Server) bucketPolicyMiddleware(next http.Handler) http.Handler {
+	if s.bucketPolicy == nil {
+		s.setupMiddleware()
+	}
+	return s.bucketPolicy.Middleware(next)
+}
+
+func (s *Server) licenseMiddleware(next http.Handler) http.Handler {
+	if s.licenseGate == nil {
+		s.setupMiddleware()
+	}
+	return s.licenseGate.Middleware(next)
+}
+
+func (s *Server) uploadLimitsMiddleware(next http.Handler) http.Handler {
+	if s.uploadLimits == nil {
+		s.setupMiddleware()
+	}
+	return s.uploadLimits.Middleware(next)
+}
+
+func (s *Server) objectCacheMiddleware(next http.Handler) http.Handler {
+	if s.objectCache == nil {
+		return next
+	}
+	if s.objectCacher == nil {
+		s.setupMiddleware()
+	}
+	return s.objectCacher.Middleware(next)
+}
+
+func (s *Server) notificationsMiddleware(next http.Handler) http.Handler {
+	if s.notifications == nil {
+		s.setupMiddleware()
+	}
+	return s.notifications.Middleware(next)
+}
+
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	if s.recovery == nil {
+		s.setupMiddleware()
+	}
+	return s.recovery.Middleware(next)
+}
+
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	if s.audit == nil {
+		s.setupMiddleware()
+	}
+	return s.audit.Middleware(next)
+}
+
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	if s.accessLogger == nil {
+		s.setupMiddleware()
+	}
+	return s.accessLogger.Middleware(next)
+}
+
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	if s.tracing == nil {
+		s.setupMiddleware()
+	}
+	return s.tracing.Middleware(next)
+}
+
+func (s *Server) handlerTracingMiddleware(next http.Handler) http.Handler {
+	if s.tracing == nil {
+		s.setupMiddleware()
+	}
+	return s.tracing.HandlerMiddleware(next)
+}
+
 func (s *Server) s3AuthMiddleware(next http.Handler) http.Handler {
 	if s.s3AuthService == nil {
 		s.setupMiddleware()
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Objects of public_read buckets are served to unsigned requests
+		if s.bucketPolicy.AllowsAnonymousRead(r) {
+			s.logger.WithFields(logrus.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Debug("Serving anonymous read of a public_read bucket")
+			next.ServeHTTP(w, r.WithContext(orchestration.WithAnonymousClient(r.Context())))
+			return
+		}
+
 		// Perform comprehensive authentication using the robust service
-		if err := s.s3AuthService.AuthenticateRequest(r); err != nil {
-			s.writeS3Error(w, s.determineErrorCode(err), err.Error(), http.StatusForbidden)
+		client, err := s.s3AuthService.Authenticate(r)
+		if err != nil {
+			s.writeS3Error(w, r, s.determineErrorCode(err), err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := s.s3AuthService.AuthorizeRequest(r, client); err != nil {
+			s.writeS3Error(w, r, "AccessDenied", err.Error(), http.StatusForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -101,22 +270,11 @@ func findInString(s, substr string) bool {
 }
 
 // writeS3Error writes an S3-compatible error response with security headers
-func (s *Server) writeS3Error(w http.ResponseWriter, code, message string, statusCode int) {
+func (s *Server) writeS3Error(w http.ResponseWriter, r *http.Request, code, message string, statusCode int) {
 	// Security headers
-	w.Header().Set("Content-Type", "application/xml")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("X-Frame-Options", "DENY")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.WriteHeader(statusCode)
-
-	// S3-compatible error response
-	errorXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-	<Code>%s</Code>
-	<Message>%s</Message>
-	<RequestId>%s</RequestId>
-	<Resource>%s</Resource>
-</Error>`, code, message, "s3-encryption-proxy", "")
-
-	_, _ = w.Write([]byte(errorXML)) // gosec: ignore any write errors to response writer
+
+	_ = response.WriteError(w, statusCode, response.Error{Code: code, Message: message, Resource: r.URL.Path}) // gosec: ignore any write errors to response writer
 }
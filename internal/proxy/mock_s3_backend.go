@@ -343,6 +343,14 @@ func (m *MockS3Backend) ListObjects(ctx context.Context, params *s3.ListObjectsI
 	return &s3.ListObjectsOutput{}, nil
 }
 
+// ListObjectVersions lists object versions
+func (m *MockS3Backend) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if err := m.shouldError["ListObjectVersions"]; err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectVersionsOutput{}, nil
+}
+
 // CopyObject copies an object
 func (m *MockS3Backend) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
 	if err := m.shouldError["CopyObject"]; err != nil {
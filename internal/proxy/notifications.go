@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/notification"
+)
+
+// newNotificationEmitter creates the emitter of notifications.targets, or
+// returns nil without targets
+func newNotificationEmitter(cfg *config.Config) (*notification.Emitter, error) {
+	n := cfg.Notifications
+	if len(n.Targets) == 0 {
+		return nil, nil
+	}
+	logger := logrus.WithField("component", "notifications")
+
+	emitter := notification.NewEmitter(cfg.S3Backend.Region, logger)
+	for i, targetConfig := range n.Targets {
+		target, err := newNotificationTarget(targetConfig)
+		if err != nil {
+			_ = emitter.Close()
+			return nil, fmt.Errorf("notifications.targets[%d]: %w", i, err)
+		}
+		opts := notification.TargetOptions{
+			Name: n.TargetName(i),
+			Filter: notification.Filter{
+				Events:  targetConfig.Events,
+				Buckets: targetConfig.Buckets,
+				Prefix:  targetConfig.Prefix,
+				Suffix:  targetConfig.Suffix,
+			},
+			QueueSize:     n.QueueSize,
+			BatchSize:     n.BatchSize,
+			FlushInterval: time.Duration(n.FlushInterval) * time.Millisecond,
+			Timeout:       time.Duration(n.Timeout) * time.Second,
+		}
+		emitter.AddTarget(target, opts)
+		logger.WithFields(logrus.Fields{"target": opts.Name, "type": targetConfig.Type}).Info("Sending event notifications")
+	}
+	return emitter, nil
+}
+
+// newNotificationTarget creates the target of a validated target configuration
+func newNotificationTarget(target config.NotificationTargetConfig) (notification.Target, error) {
+	switch target.Type {
+	case config.NotificationTargetKafka:
+		return notification.NewKafkaTarget(target.URL, target.Topic, target.Headers), nil
+	case config.NotificationTargetSQS:
+		return notification.NewSQSTarget(target.URL, target.Region, target.AccessKeyID, target.SecretKey)
+	default:
+		return notification.NewWebhookTarget(target.URL, target.Headers), nil
+	}
+}
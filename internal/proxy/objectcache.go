@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/objectcache"
+)
+
+// newObjectCache creates the cache of object_cache, nil if it is disabled
+func newObjectCache(cfg *config.Config) (*objectcache.Cache, error) {
+	if !cfg.ObjectCache.Enabled {
+		return nil, nil
+	}
+	c := cfg.ObjectCache
+	return objectcache.New(objectcache.Config{
+		TTL:           time.Duration(c.TTL) * time.Second,
+		MaxObjectSize: c.MaxObjectSize,
+		MemorySize:    c.MemorySize,
+		DiskDirectory: c.DiskDirectory,
+		DiskSize:      c.DiskSize,
+	}, logrus.WithField("component", "object-cache"))
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	proxyconfig "github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// ApplyReload applies the reloadable settings of a reloaded configuration,
+// next, as listed by plan. Added providers are registered first, so a
+// provider that cannot be created fails the reload before anything changed.
+func (s *Server) ApplyReload(next *proxyconfig.Config, plan proxyconfig.ReloadPlan) error {
+	level, err := logrus.ParseLevel(next.LogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+	if len(plan.AddedProviders) > 0 {
+		if err := s.encryptionMgr.AddProviders(plan.AddedProviders); err != nil {
+			return fmt.Errorf("failed to add providers: %w", err)
+		}
+	}
+
+	if s.rateLimiter == nil {
+		s.setupMiddleware()
+	}
+	if slices.Contains(plan.Applied, "log_level") {
+		logrus.SetLevel(level)
+	}
+	if slices.Contains(plan.Applied, "rate_limiting") {
+		s.rateLimiter.Update(next.RateLimiting)
+	}
+	// The policies look up providers, so they also get the added ones
+	s.bucketPolicy.Update(next)
+	return nil
+}
@@ -0,0 +1,29 @@
+package request
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AccessKeyID returns the access key ID the request was signed with, taken
+// from the SigV4 Authorization header or the X-Amz-Credential query parameter
+// of a presigned URL. It does not verify the signature and returns an empty
+// string for unsigned requests.
+func AccessKeyID(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if idx := strings.Index(auth, "Credential="); idx >= 0 {
+			return credentialAccessKey(auth[idx+len("Credential="):])
+		}
+		return ""
+	}
+	return credentialAccessKey(r.URL.Query().Get("X-Amz-Credential"))
+}
+
+// credentialAccessKey extracts the access key from a credential scope of the
+// form AccessKeyID/Date/Region/Service/aws4_request
+func credentialAccessKey(credential string) string {
+	if end := strings.IndexAny(credential, "/, "); end >= 0 {
+		credential = credential[:end]
+	}
+	return credential
+}
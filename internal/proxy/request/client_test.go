@@ -0,0 +1,53 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessKeyID(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		target   string
+		expected string
+	}{
+		{
+			name: "sigv4 authorization header",
+			headers: map[string]string{
+				"Authorization": "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/20260101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc",
+			},
+			target:   "/bucket/key",
+			expected: "AKIAEXAMPLE",
+		},
+		{
+			name:     "presigned url",
+			target:   "/bucket/key?X-Amz-Credential=AKIAPRESIGN%2F20260101%2Fus-east-1%2Fs3%2Faws4_request",
+			expected: "AKIAPRESIGN",
+		},
+		{
+			name:     "unsupported authorization scheme",
+			headers:  map[string]string{"Authorization": "Bearer token"},
+			target:   "/bucket/key?X-Amz-Credential=AKIAPRESIGN%2Fscope",
+			expected: "",
+		},
+		{
+			name:     "unsigned",
+			target:   "/bucket/key",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.expected, AccessKeyID(r))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Object lock request headers of PutObject, CopyObject and
+// CreateMultipartUpload
+const (
+	ObjectLockModeHeader            = "X-Amz-Object-Lock-Mode"
+	ObjectLockRetainUntilDateHeader = "X-Amz-Object-Lock-Retain-Until-Date"
+	ObjectLockLegalHoldHeader       = "X-Amz-Object-Lock-Legal-Hold"
+)
+
+// ErrInvalidObjectLock is returned for object lock headers S3 would reject
+var ErrInvalidObjectLock = errors.New("invalid object lock settings")
+
+// ObjectLock holds the object lock settings of a new object
+type ObjectLock struct {
+	Mode            types.ObjectLockMode
+	RetainUntilDate *time.Time
+	LegalHold       types.ObjectLockLegalHoldStatus
+}
+
+// ParseObjectLock returns the object lock headers of r. Mode and retain
+// until date must be set together.
+func ParseObjectLock(r *http.Request) (ObjectLock, error) {
+	var lock ObjectLock
+
+	switch mode := types.ObjectLockMode(r.Header.Get(ObjectLockModeHeader)); mode {
+	case "", types.ObjectLockModeGovernance, types.ObjectLockModeCompliance:
+		lock.Mode = mode
+	default:
+		return ObjectLock{}, fmt.Errorf("%w: unknown %s %q", ErrInvalidObjectLock, ObjectLockModeHeader, mode)
+	}
+	if value := r.Header.Get(ObjectLockRetainUntilDateHeader); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ObjectLock{}, fmt.Errorf("%w: %s is not an ISO 8601 date", ErrInvalidObjectLock, ObjectLockRetainUntilDateHeader)
+		}
+		lock.RetainUntilDate = &until
+	}
+	if (lock.Mode == "") != (lock.RetainUntilDate == nil) {
+		return ObjectLock{}, fmt.Errorf("%w: %s and %s must both be supplied", ErrInvalidObjectLock, ObjectLockModeHeader, ObjectLockRetainUntilDateHeader)
+	}
+
+	switch hold := types.ObjectLockLegalHoldStatus(r.Header.Get(ObjectLockLegalHoldHeader)); hold {
+	case "", types.ObjectLockLegalHoldStatusOn, types.ObjectLockLegalHoldStatusOff:
+		lock.LegalHold = hold
+	default:
+		return ObjectLock{}, fmt.Errorf("%w: unknown %s %q", ErrInvalidObjectLock, ObjectLockLegalHoldHeader, hold)
+	}
+	return lock, nil
+}
+
+// IsZero reports whether no object lock setting was requested
+func (l ObjectLock) IsZero() bool {
+	return l.Mode == "" && l.RetainUntilDate == nil && l.LegalHold == ""
+}
+
+// Apply sets the object lock fields of a backend request
+func (l ObjectLock) Apply(mode *types.ObjectLockMode, retainUntilDate **time.Time, legalHold *types.ObjectLockLegalHoldStatus) {
+	*mode = l.Mode
+	*retainUntilDate = l.RetainUntilDate
+	*legalHold = l.LegalHold
+}
@@ -0,0 +1,43 @@
+package request
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseObjectLock(t *testing.T) {
+	lock, err := ParseObjectLock(newTestRequest(map[string]string{
+		ObjectLockModeHeader:            "COMPLIANCE",
+		ObjectLockRetainUntilDateHeader: "2030-01-02T03:04:05Z",
+		ObjectLockLegalHoldHeader:       "ON",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, types.ObjectLockModeCompliance, lock.Mode)
+	assert.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), lock.RetainUntilDate.UTC())
+	assert.Equal(t, types.ObjectLockLegalHoldStatusOn, lock.LegalHold)
+
+	lock, err = ParseObjectLock(newTestRequest(nil))
+	require.NoError(t, err)
+	assert.True(t, lock.IsZero())
+}
+
+func TestParseObjectLock_Invalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown mode":  {ObjectLockModeHeader: "FOREVER", ObjectLockRetainUntilDateHeader: "2030-01-02T03:04:05Z"},
+		"bad date":      {ObjectLockModeHeader: "GOVERNANCE", ObjectLockRetainUntilDateHeader: "tomorrow"},
+		"mode only":     {ObjectLockModeHeader: "GOVERNANCE"},
+		"date only":     {ObjectLockRetainUntilDateHeader: "2030-01-02T03:04:05Z"},
+		"bad legalhold": {ObjectLockLegalHoldHeader: "MAYBE"},
+	}
+	for name, headers := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseObjectLock(newTestRequest(headers))
+			assert.True(t, errors.Is(err, ErrInvalidObjectLock), "got %v", err)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package request
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -30,6 +31,11 @@ func (p *Parser) ReadBody(r *http.Request) ([]byte, error) {
 		return nil, nil
 	}
 
+	// The buffered decoder drops trailers, e.g. trailing checksums
+	if p.config.Optimizations.CleanAWSSignatureV4Chunked && isAWSChunkedRequest(r) && r.Header.Get("X-Amz-Trailer") != "" {
+		return io.ReadAll(p.StreamingReader(r))
+	}
+
 	// Create decoders
 	awsDecoder := NewAWSChunkedDecoder(p.logger)
 	httpDecoder := NewHTTPChunkedDecoder(p.logger)
@@ -61,6 +67,20 @@ func (p *Parser) ReadBody(r *http.Request) ([]byte, error) {
 	return io.ReadAll(r.Body)
 }
 
+// ReadXMLBody reads a client XML body of at most maxBytes, handling chunked
+// encoding like ReadBody. Larger bodies fail with ErrXMLTooLarge.
+func (p *Parser) ReadXMLBody(r *http.Request, maxBytes int64) ([]byte, error) {
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+	}
+	data, err := p.ReadBody(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || int64(len(data)) > maxBytes {
+		return nil, ErrXMLTooLarge
+	}
+	return data, err
+}
+
 // GetMetadataPrefix returns the configured metadata prefix
 func (p *Parser) GetMetadataPrefix() string {
 	if p.config.Encryption.MetadataKeyPrefix != nil {
@@ -82,7 +102,8 @@ func (p *Parser) ResetBody(r *http.Request, body []byte) {
 // Behavior:
 //   - aws-chunked (detected via Content-Encoding or X-Amz-Content-Sha256):
 //     wraps r.Body in a streaming chunk-decoder. Per-chunk signatures are not
-//     re-verified; that happens earlier in the auth pipeline.
+//     re-verified; the authentication middleware verifies them as the body
+//     is read. Trailers are added to r.Trailer once the body was read.
 //   - Transfer-Encoding: chunked: transparent — net/http already decodes it
 //     before r.Body is read, so we return r.Body as-is.
 //   - identity: returns r.Body unchanged.
@@ -95,7 +116,10 @@ func (p *Parser) StreamingReader(r *http.Request) io.Reader {
 	}
 	if p.config.Optimizations.CleanAWSSignatureV4Chunked && isAWSChunkedRequest(r) {
 		p.logger.Debug("Streaming aws-chunked body without buffering")
-		return newStreamingAWSChunkedReader(r.Body, p.logger)
+		if r.Trailer == nil {
+			r.Trailer = make(http.Header)
+		}
+		return newStreamingAWSChunkedReader(r.Body, r.Trailer, p.logger)
 	}
 	return r.Body
 }
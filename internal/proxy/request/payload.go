@@ -0,0 +1,232 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrContentSHA256Mismatch is returned when a body does not match the
+	// SHA-256 the client signed in X-Amz-Content-Sha256
+	ErrContentSHA256Mismatch = errors.New("the provided x-amz-content-sha256 header does not match what was computed")
+
+	// ErrChunkSignatureMismatch is returned when a chunk of a
+	// STREAMING-AWS4-HMAC-SHA256-PAYLOAD body does not match its signature
+	ErrChunkSignatureMismatch = errors.New("aws-chunked: chunk signature does not match")
+)
+
+const (
+	// maxSignedChunkSize bounds the chunks that are held back until their
+	// signature is verified. SDKs send chunks of 64 KiB to 1 MiB.
+	maxSignedChunkSize = 16 << 20
+	maxChunkHeaderSize = 4096
+)
+
+// payloadHashReader fails the last read of a body whose SHA-256 does not
+// match the signed one, so the upload it feeds is not completed. The check
+// runs at io.EOF: consumers that know the length of the body still have to
+// read on to it, see NewSizedBodyReader.
+type payloadHashReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+// NewPayloadHashReader returns body, checked against expectedHex at its end
+func NewPayloadHashReader(body io.ReadCloser, expectedHex string) (io.ReadCloser, error) {
+	expected, err := hex.DecodeString(expectedHex)
+	if err != nil || len(expected) != sha256.Size {
+		return nil, fmt.Errorf("invalid payload hash %q", expectedHex)
+	}
+	return &payloadHashReader{ReadCloser: body, hash: sha256.New(), expected: expected}, nil
+}
+
+func (r *payloadHashReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && subtle.ConstantTimeCompare(r.hash.Sum(nil), r.expected) != 1 {
+		return n, ErrContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// ErrBodyTooLong is returned when a body is longer than its announced length
+var ErrBodyTooLong = errors.New("request body is longer than its content length")
+
+// SizedBodyReader returns the size bytes of a body, but reads on to the end
+// of the body before it returns the last of them
+type SizedBodyReader struct {
+	body      io.Reader
+	remaining int64
+	err       error
+}
+
+// NewSizedBodyReader returns a reader of the size bytes of body. The read
+// that would return the last of them reads body on to io.EOF first and
+// returns none of them if that fails, so checks that run at the end of the
+// body, like those of payloadHashReader and aws-chunked signatures, fail an
+// upload before the backend received all of it. A consumer that stops
+// reading at size bytes cannot skip them.
+func NewSizedBodyReader(body io.Reader, size int64) *SizedBodyReader {
+	return &SizedBodyReader{body: body, remaining: size}
+}
+
+// Err returns the error that failed the body, if any. Consumers like the S3
+// client wrap the errors of their body, so callers check it to report the
+// body error rather than the one of the consumer.
+func (r *SizedBodyReader) Err() error {
+	return r.err
+}
+
+func (r *SizedBodyReader) Read(p []byte) (int, error) {
+	n, err := r.read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *SizedBodyReader) read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, r.readEnd()
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.body.Read(p)
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining > 0:
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	case err != nil && !errors.Is(err, io.EOF):
+		return 0, err
+	}
+	if err := r.readEnd(); err != io.EOF {
+		return 0, err
+	}
+	return n, nil
+}
+
+// readEnd reads the body past its size and returns io.EOF if it ends there
+func (r *SizedBodyReader) readEnd() error {
+	var extra [1]byte
+	n, err := io.ReadFull(r.body, extra[:])
+	switch {
+	case n > 0:
+		return ErrBodyTooLong
+	case errors.Is(err, io.EOF):
+		return io.EOF
+	default:
+		return err
+	}
+}
+
+// ChunkSigner returns the signature of a chunk with the given SHA-256 that
+// follows a chunk with previousSignature
+type ChunkSigner func(previousSignature string, chunkSHA256 []byte) string
+
+// chunkSignatureReader verifies the chunk signatures of an aws-chunked body
+// and passes the body on unchanged, so the decoders of the handlers still see
+// the aws-chunked framing. Each chunk is held back until its signature is
+// verified.
+type chunkSignatureReader struct {
+	src           *bufio.Reader
+	closer        io.Closer
+	sign          ChunkSigner
+	prevSignature string
+	chunk         bytes.Buffer
+	out           []byte
+	err           error
+}
+
+// NewChunkSignatureReader returns body, verified chunk by chunk. The first
+// chunk is chained to seedSignature, the signature of the request headers.
+func NewChunkSignatureReader(body io.ReadCloser, seedSignature string, sign ChunkSigner) io.ReadCloser {
+	return &chunkSignatureReader{
+		src:           bufio.NewReaderSize(body, maxChunkHeaderSize),
+		closer:        body,
+		sign:          sign,
+		prevSignature: seedSignature,
+	}
+}
+
+func (r *chunkSignatureReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.nextChunk()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *chunkSignatureReader) Close() error {
+	return r.closer.Close()
+}
+
+// nextChunk reads and verifies the next chunk into out. It returns io.EOF
+// after the final, empty chunk.
+func (r *chunkSignatureReader) nextChunk() error {
+	header, err := r.src.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("aws-chunked: read chunk header: %w", err)
+	}
+
+	line := strings.TrimRight(string(header), "\r\n")
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 || size > maxSignedChunkSize {
+		return fmt.Errorf("aws-chunked: invalid chunk size %q", sizeField)
+	}
+	signature, ok := strings.CutPrefix(strings.TrimSpace(extension), "chunk-signature=")
+	if !ok {
+		return fmt.Errorf("aws-chunked: chunk without signature")
+	}
+
+	r.chunk.Reset()
+	r.chunk.Write(header)
+	if _, err := io.CopyN(&r.chunk, r.src, size); err != nil {
+		return fmt.Errorf("aws-chunked: read chunk data: %w", io.ErrUnexpectedEOF)
+	}
+	data := r.chunk.Bytes()[len(header):]
+	chunkHash := sha256.Sum256(data)
+
+	expected := r.sign(r.prevSignature, chunkHash[:])
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return ErrChunkSignatureMismatch
+	}
+	r.prevSignature = signature
+
+	// The CRLF after the data, or the empty line after the final chunk
+	trailer, err := r.src.ReadSlice('\n')
+	if err != nil && !(size == 0 && errors.Is(err, io.EOF)) {
+		return fmt.Errorf("aws-chunked: read chunk end: %w", io.ErrUnexpectedEOF)
+	}
+	if strings.TrimRight(string(trailer), "\r\n") != "" {
+		return fmt.Errorf("aws-chunked: expected CRLF after chunk data")
+	}
+	r.chunk.Write(trailer)
+	r.out = r.chunk.Bytes()
+
+	if size == 0 {
+		return io.EOF
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package request
+
+import "net/http"
+
+// SSE-C request headers
+const (
+	SSECAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	SSECKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	SSECKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+
+	copySourceSSECPrefix = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-"
+)
+
+// SSEC holds the customer-provided key of an SSE-C request
+type SSEC struct {
+	Algorithm string
+	Key       string
+	KeyMD5    string
+}
+
+// ParseSSEC returns the SSE-C headers of r. ok is true if any of them is
+// set; incomplete sets are left for the backend to reject.
+func ParseSSEC(r *http.Request) (SSEC, bool) {
+	sse := SSEC{
+		Algorithm: r.Header.Get(SSECAlgorithmHeader),
+		Key:       r.Header.Get(SSECKeyHeader),
+		KeyMD5:    r.Header.Get(SSECKeyMD5Header),
+	}
+	return sse, sse.Algorithm != "" || sse.Key != "" || sse.KeyMD5 != ""
+}
+
+// HasCopySourceSSEC reports whether r carries SSE-C headers for a copy source
+func HasCopySourceSSEC(r *http.Request) bool {
+	for _, suffix := range []string{"Algorithm", "Key", "Key-Md5"} {
+		if r.Header.Get(copySourceSSECPrefix+suffix) != "" {
+			return true
+		}
+	}
+	return false
+}
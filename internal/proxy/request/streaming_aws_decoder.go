@@ -23,19 +23,23 @@ import (
 //	0;chunk-signature=<sig>\r\n
 //	\r\n
 //
-// Trailing signatures and optional trailers after the zero-length chunk are
-// drained best-effort and discarded — the proxy does not re-verify them.
+// Chunk signatures were verified by the authentication middleware (see
+// NewChunkSignatureReader). Trailers after the zero-length chunk, such as the
+// x-amz-checksum-* trailers of newer SDKs, are added to trailer if it is not
+// nil; the trailing signature is drained and discarded.
 type streamingAWSChunkedReader struct {
 	br        *bufio.Reader
 	remaining int64
 	finished  bool
+	trailer   http.Header
 	logger    *logrus.Entry
 }
 
-func newStreamingAWSChunkedReader(src io.Reader, logger *logrus.Entry) *streamingAWSChunkedReader {
+func newStreamingAWSChunkedReader(src io.Reader, trailer http.Header, logger *logrus.Entry) *streamingAWSChunkedReader {
 	return &streamingAWSChunkedReader{
-		br:     bufio.NewReaderSize(src, 128*1024),
-		logger: logger,
+		br:      bufio.NewReaderSize(src, 128*1024),
+		trailer: trailer,
+		logger:  logger,
 	}
 }
 
@@ -99,13 +103,15 @@ func (r *streamingAWSChunkedReader) readChunkHeader() error {
 
 	if size == 0 {
 		r.finished = true
-		// Drain any remaining trailer lines until a blank CRLF or EOF.
+		// Read any trailer lines until a blank CRLF or EOF.
 		for {
 			tline, terr := r.br.ReadString('\n')
-			if terr != nil {
+			tline = strings.TrimRight(tline, "\r\n")
+			if tline == "" {
 				return nil
 			}
-			if strings.TrimRight(tline, "\r\n") == "" {
+			r.addTrailer(tline)
+			if terr != nil {
 				return nil
 			}
 		}
@@ -114,6 +120,19 @@ func (r *streamingAWSChunkedReader) readChunkHeader() error {
 	return nil
 }
 
+// addTrailer records a "name:value" trailer line
+func (r *streamingAWSChunkedReader) addTrailer(line string) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || r.trailer == nil {
+		return
+	}
+	name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	if name == "X-Amz-Trailer-Signature" {
+		return
+	}
+	r.trailer.Set(name, strings.TrimSpace(value))
+}
+
 func (r *streamingAWSChunkedReader) consumeCRLF() error {
 	b, err := r.br.ReadByte()
 	if err != nil {
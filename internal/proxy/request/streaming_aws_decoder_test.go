@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -54,7 +55,7 @@ func TestStreamingAWSChunkedReader_RoundTrip(t *testing.T) {
 			}
 			framed := buildAWSChunked(t, plaintext, tc.chunkSize)
 
-			reader := newStreamingAWSChunkedReader(bytes.NewReader(framed), logger)
+			reader := newStreamingAWSChunkedReader(bytes.NewReader(framed), nil, logger)
 			got, err := io.ReadAll(reader)
 			if err != nil {
 				t.Fatalf("ReadAll: %v", err)
@@ -69,7 +70,7 @@ func TestStreamingAWSChunkedReader_RoundTrip(t *testing.T) {
 func TestStreamingAWSChunkedReader_InvalidSize(t *testing.T) {
 	logger := logrus.NewEntry(logrus.New())
 	bad := strings.NewReader("zz;chunk-signature=abc\r\nhello\r\n0;chunk-signature=abc\r\n\r\n")
-	reader := newStreamingAWSChunkedReader(bad, logger)
+	reader := newStreamingAWSChunkedReader(bad, nil, logger)
 	if _, err := io.ReadAll(reader); err == nil {
 		t.Fatal("expected error on invalid chunk size")
 	}
@@ -78,12 +79,32 @@ func TestStreamingAWSChunkedReader_InvalidSize(t *testing.T) {
 func TestStreamingAWSChunkedReader_TruncatedMidChunk(t *testing.T) {
 	logger := logrus.NewEntry(logrus.New())
 	// Declare 10 bytes but only supply 3.
-	reader := newStreamingAWSChunkedReader(strings.NewReader("a;chunk-signature=deadbeef\r\nabc"), logger)
+	reader := newStreamingAWSChunkedReader(strings.NewReader("a;chunk-signature=deadbeef\r\nabc"), nil, logger)
 	if _, err := io.ReadAll(reader); err == nil {
 		t.Fatal("expected error on truncated chunk")
 	}
 }
 
+func TestStreamingAWSChunkedReader_Trailers(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	trailer := make(http.Header)
+	framed := "5\r\nhello\r\n0\r\nx-amz-checksum-crc32:NhCmhg==\r\nx-amz-trailer-signature:abc\r\n\r\n"
+	reader := newStreamingAWSChunkedReader(strings.NewReader(framed), trailer, logger)
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("decoded payload %q, want %q", got, "hello")
+	}
+	if v := trailer.Get("X-Amz-Checksum-Crc32"); v != "NhCmhg==" {
+		t.Fatalf("checksum trailer %q, want %q", v, "NhCmhg==")
+	}
+	if v := trailer.Get("X-Amz-Trailer-Signature"); v != "" {
+		t.Fatalf("trailer signature recorded as trailer: %q", v)
+	}
+}
+
 func TestIsAWSChunkedRequest_Headers(t *testing.T) {
 	cases := map[string]map[string]string{
 		"content_encoding":       {"Content-Encoding": "aws-chunked"},
@@ -0,0 +1,173 @@
+package request
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// MaxXMLConfigSize bounds bucket configuration documents (ACL, CORS,
+	// logging, ...). S3 itself rejects most of them well below this size.
+	MaxXMLConfigSize = 1 << 20
+
+	// defaultMaxXMLDepth is far deeper than any S3 request document nests
+	defaultMaxXMLDepth = 32
+
+	// defaultMaxXMLElements bounds the elements of one document, so a body
+	// within the size limit cannot become an unbounded number of decoded values
+	defaultMaxXMLElements = 100000
+)
+
+var (
+	// ErrXMLTooLarge is returned when a client XML body exceeds its size limit
+	ErrXMLTooLarge = errors.New("XML request body exceeds the maximum allowed size")
+
+	// ErrMalformedXML is returned for client XML that is not well-formed, uses
+	// a DTD, exceeds the nesting or element limits or has the wrong root element
+	ErrMalformedXML = errors.New("the XML you provided was not well-formed or did not validate against our published schema")
+)
+
+// XMLLimits bound the resources spent on one client XML document. Zero values
+// select the defaults; MaxBytes has no default and 0 means the body is
+// already bounded by the caller.
+type XMLLimits struct {
+	MaxBytes    int64 // Largest accepted body in bytes
+	MaxDepth    int   // Deepest accepted element nesting (default: 32)
+	MaxElements int   // Most elements in one document (default: 100000)
+}
+
+// NewXMLDecoder returns a decoder for client XML that enforces limits while
+// tokens are read, so oversized or deeply nested documents are rejected before
+// they are buffered. DOCTYPE and other directives are rejected: encoding/xml
+// never expands entities declared in a DTD, and S3 request documents have no
+// use for them. Only the predefined XML entities are accepted.
+func NewXMLDecoder(body io.Reader, limits XMLLimits) *xml.Decoder {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = defaultMaxXMLDepth
+	}
+	if limits.MaxElements <= 0 {
+		limits.MaxElements = defaultMaxXMLElements
+	}
+	if limits.MaxBytes > 0 {
+		body = &sizeLimitedReader{r: body, remaining: limits.MaxBytes}
+	}
+
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = true
+	return xml.NewTokenDecoder(&limitedTokenReader{decoder: decoder, limits: limits})
+}
+
+// DecodeXML decodes a client XML document into v. The document must consist
+// of a single element named root (any namespace), optionally preceded by an
+// XML declaration; anything but whitespace and comments after it is rejected.
+// Errors match ErrXMLTooLarge or ErrMalformedXML.
+func DecodeXML(body io.Reader, root string, v interface{}, limits XMLLimits) error {
+	decoder := NewXMLDecoder(body, limits)
+
+	decoded := false
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return xmlError(err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if decoded || t.Name.Local != root {
+				return ErrMalformedXML
+			}
+			if err := decoder.DecodeElement(v, &t); err != nil {
+				return xmlError(err)
+			}
+			decoded = true
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return ErrMalformedXML
+			}
+		case xml.ProcInst:
+			if decoded || t.Target != "xml" {
+				return ErrMalformedXML
+			}
+		}
+	}
+
+	if !decoded {
+		return ErrMalformedXML
+	}
+	return nil
+}
+
+// xmlError maps decoder errors to ErrXMLTooLarge or ErrMalformedXML
+func xmlError(err error) error {
+	if errors.Is(err, ErrXMLTooLarge) || errors.Is(err, ErrMalformedXML) {
+		return err
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrXMLTooLarge
+	}
+	return fmt.Errorf("%w: %v", ErrMalformedXML, err)
+}
+
+// limitedTokenReader enforces XMLLimits on the raw tokens of a decoder. The
+// decoder returned by NewXMLDecoder still matches end elements and resolves
+// namespaces on top of it.
+type limitedTokenReader struct {
+	decoder  *xml.Decoder
+	limits   XMLLimits
+	depth    int
+	elements int
+}
+
+func (l *limitedTokenReader) Token() (xml.Token, error) {
+	tok, err := l.decoder.RawToken()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.(type) {
+	case xml.StartElement:
+		l.depth++
+		l.elements++
+		if l.depth > l.limits.MaxDepth {
+			return nil, fmt.Errorf("%w: elements nested deeper than %d levels", ErrMalformedXML, l.limits.MaxDepth)
+		}
+		if l.elements > l.limits.MaxElements {
+			return nil, fmt.Errorf("%w: more than %d elements", ErrMalformedXML, l.limits.MaxElements)
+		}
+	case xml.EndElement:
+		l.depth--
+	case xml.Directive:
+		return nil, fmt.Errorf("%w: DOCTYPE and other directives are not allowed", ErrMalformedXML)
+	}
+	return tok, nil
+}
+
+// sizeLimitedReader fails with ErrXMLTooLarge once more than remaining bytes
+// were read, instead of silently truncating like io.LimitReader
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (s *sizeLimitedReader) Read(p []byte) (int, error) {
+	if s.remaining < 0 {
+		return 0, ErrXMLTooLarge
+	}
+	if int64(len(p)) > s.remaining+1 {
+		p = p[:s.remaining+1]
+	}
+	n, err := s.r.Read(p)
+	s.remaining -= int64(n)
+	if s.remaining < 0 {
+		return n, ErrXMLTooLarge
+	}
+	return n, err
+}
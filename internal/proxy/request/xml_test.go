@@ -0,0 +1,77 @@
+package request
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDelete struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+func TestDecodeXML(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<Delete xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Quiet>true</Quiet><Object><Key>a&amp;b</Key></Object></Delete>
+`
+	var request testDelete
+	require.NoError(t, DecodeXML(strings.NewReader(body), "Delete", &request, XMLLimits{MaxBytes: 1024}))
+	assert.True(t, request.Quiet)
+	require.Len(t, request.Objects, 1)
+	assert.Equal(t, "a&b", request.Objects[0].Key)
+}
+
+func TestDecodeXML_Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		limits XMLLimits
+		err    error
+	}{
+		{name: "too large", body: "<Delete>" + strings.Repeat(" ", 100) + "</Delete>", limits: XMLLimits{MaxBytes: 64}, err: ErrXMLTooLarge},
+		{name: "too deep", body: strings.Repeat("<a>", 10) + strings.Repeat("</a>", 10), limits: XMLLimits{MaxDepth: 5}, err: ErrMalformedXML},
+		{name: "too many elements", body: "<Delete>" + strings.Repeat("<Object/>", 20) + "</Delete>", limits: XMLLimits{MaxElements: 10}, err: ErrMalformedXML},
+		{name: "entity expansion", body: `<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;">]><Delete><Quiet>&lol2;</Quiet></Delete>`, err: ErrMalformedXML},
+		{name: "undefined entity", body: `<Delete><Object><Key>&xxe;</Key></Object></Delete>`, err: ErrMalformedXML},
+		{name: "wrong root", body: `<Tagging></Tagging>`, err: ErrMalformedXML},
+		{name: "two roots", body: `<Delete></Delete><Delete></Delete>`, err: ErrMalformedXML},
+		{name: "trailing text", body: `<Delete></Delete>garbage`, err: ErrMalformedXML},
+		{name: "mismatched tags", body: `<Delete><Object></Delete></Object>`, err: ErrMalformedXML},
+		{name: "unclosed", body: `<Delete><Object>`, err: ErrMalformedXML},
+		{name: "empty", body: ``, err: ErrMalformedXML},
+		{name: "processing instruction", body: `<?php echo 1 ?><Delete></Delete>`, err: ErrMalformedXML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request testDelete
+			err := DecodeXML(strings.NewReader(tt.body), "Delete", &request, tt.limits)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewXMLDecoder_Streaming(t *testing.T) {
+	decoder := NewXMLDecoder(strings.NewReader(`<a><b>1</b><b>2</b></a>`), XMLLimits{MaxDepth: 2})
+
+	var values []string
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "b" {
+			var value string
+			require.NoError(t, decoder.DecodeElement(&value, &start))
+			values = append(values, value)
+		}
+	}
+	assert.Equal(t, []string{"1", "2"}, values)
+}
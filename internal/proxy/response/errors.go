@@ -1,69 +1,127 @@
 package response
 
 import (
+	"encoding/xml"
+	"errors"
 	"fmt"
-	"html"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/requestid"
 )
 
+// Error is the XML body of an S3 error response
+type Error struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	Resource   string   `xml:"Resource,omitempty"`
+	RequestID  string   `xml:"RequestId"`
+	RequestURL string   `xml:"RequestURL,omitempty"`
+}
+
+// WriteError writes an S3 error response. Every error the proxy answers
+// with goes through it, so SDKs can parse the code, and the request ID of
+// the body matches the x-amz-request-id header. A 304 is sent without body.
+func WriteError(w http.ResponseWriter, statusCode int, body Error) error {
+	body.RequestID = RequestID(w)
+	if statusCode == http.StatusNotModified {
+		w.WriteHeader(statusCode)
+		return nil
+	}
+
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	_, err = w.Write(append([]byte(xml.Header), data...))
+	return err
+}
+
+// RequestID returns the x-amz-request-id of the response and sets a new one
+// if it has none yet
+func RequestID(w http.ResponseWriter) string {
+	if requestID := w.Header().Get("X-Amz-Request-Id"); requestID != "" {
+		return requestID
+	}
+	requestID := requestid.New()
+	w.Header().Set("X-Amz-Request-Id", requestID)
+	return requestID
+}
+
+// resourcePath returns the S3 resource of bucket and key
+func resourcePath(bucket, key string) string {
+	if bucket == "" {
+		return "/"
+	}
+	if key == "" {
+		return "/" + bucket
+	}
+	return "/" + bucket + "/" + key
+}
+
 // ErrorWriter handles S3 error responses
 type ErrorWriter struct {
-	logger *logrus.Entry
+	logger logrus.FieldLogger
 }
 
 // NewErrorWriter creates a new error response writer
-func NewErrorWriter(logger *logrus.Entry) *ErrorWriter {
+func NewErrorWriter(logger logrus.FieldLogger) *ErrorWriter {
 	return &ErrorWriter{
 		logger: logger,
 	}
 }
 
-// WriteS3Error writes an S3 error response with proper HTTP status codes
-func (e *ErrorWriter) WriteS3Error(w http.ResponseWriter, err error, bucket, key string) {
-	// Determine the appropriate HTTP status code and error code based on the error type
-	var statusCode int
-	var errorCode string
-	var message string
-
-	// Handle specific S3 error types
-	switch err := err.(type) {
-	case *types.BucketAlreadyExists:
-		statusCode = http.StatusConflict
-		errorCode = "BucketAlreadyExists"
-		message = "The requested bucket name is not available"
-	case *types.BucketAlreadyOwnedByYou:
-		statusCode = http.StatusConflict
-		errorCode = "BucketAlreadyOwnedByYou"
-		message = "Your previous request to create the named bucket succeeded and you already own it"
-	case *types.NoSuchBucket:
-		statusCode = http.StatusNotFound
-		errorCode = "NoSuchBucket"
-		message = "The specified bucket does not exist"
-
+// ClassifyError returns the HTTP status, S3 error code and client message of
+// err: typed errors of the S3 API, the encryption manager, request parsing
+// and the backend layer, and errors the backend answered with. Anything else
+// is an InternalError whose details are only logged.
+func ClassifyError(err error) (statusCode int, errorCode, message string) {
+	var bucketExists *types.BucketAlreadyExists
+	var bucketOwned *types.BucketAlreadyOwnedByYou
+	var noSuchBucket *types.NoSuchBucket
+	var noSuchKey *types.NoSuchKey
+	switch {
+	case errors.As(err, &bucketExists):
+		return http.StatusConflict, "BucketAlreadyExists", "The requested bucket name is not available"
+	case errors.As(err, &bucketOwned):
+		return http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it"
+	case errors.As(err, &noSuchBucket):
 		// Handle special cases based on the error message
-		if err.Message != nil {
-			if *err.Message == "The specified bucket does not have a website configuration" {
-				errorCode = "NoSuchWebsiteConfiguration"
-				message = "The specified bucket does not have a website configuration"
-			} else if *err.Message != "" {
-				message = *err.Message
+		if noSuchBucket.Message != nil {
+			if *noSuchBucket.Message == "The specified bucket does not have a website configuration" {
+				return http.StatusNotFound, "NoSuchWebsiteConfiguration", *noSuchBucket.Message
+			} else if *noSuchBucket.Message != "" {
+				return http.StatusNotFound, "NoSuchBucket", *noSuchBucket.Message
 			}
 		}
-	case *types.NoSuchKey:
-		statusCode = http.StatusNotFound
-		errorCode = "NoSuchKey"
-		message = "The specified key does not exist"
-	default:
-		// For unknown errors, use internal server error
-		statusCode = http.StatusInternalServerError
-		errorCode = "InternalError"
-		message = err.Error()
+		return http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist"
+	case errors.As(err, &noSuchKey):
+		return http.StatusNotFound, "NoSuchKey", "The specified key does not exist"
 	}
 
+	for _, classify := range []func(error) (int, string, string, bool){orchestrationError, requestError, conditionalError, backendError, apiError} {
+		if statusCode, errorCode, message, ok := classify(err); ok {
+			return statusCode, errorCode, message
+		}
+	}
+	return http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again."
+}
+
+// WriteS3Error writes an S3 error response with proper HTTP status codes
+func (e *ErrorWriter) WriteS3Error(w http.ResponseWriter, err error, bucket, key string) {
+	statusCode, errorCode, message := ClassifyError(err)
+
 	// Log the error with appropriate level
 	logEntry := e.logger.WithError(err).WithFields(logrus.Fields{
 		"bucket":      bucket,
@@ -71,6 +129,7 @@ func (e *ErrorWriter) WriteS3Error(w http.ResponseWriter, err error, bucket, key
 		"error_code":  errorCode,
 		"status_code": statusCode,
 		"message":     message,
+		"request_id":  RequestID(w),
 	})
 
 	if statusCode >= 500 {
@@ -79,41 +138,121 @@ func (e *ErrorWriter) WriteS3Error(w http.ResponseWriter, err error, bucket, key
 		logEntry.Warn("S3 operation failed with client error")
 	}
 
-	// Write the error response
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(statusCode)
+	e.write(w, statusCode, Error{Code: errorCode, Message: message, Resource: resourcePath(bucket, key)})
+}
+
+// WriteError writes an S3 error response with custom code and message for
+// the resource of r
+func (e *ErrorWriter) WriteError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	e.write(w, statusCode, Error{Code: code, Message: message, Resource: r.URL.Path})
+}
 
-	resource := bucket
-	if key != "" {
-		resource = bucket + "/" + key
+// write writes an error response and logs when the client cannot receive it
+func (e *ErrorWriter) write(w http.ResponseWriter, statusCode int, body Error) {
+	if err := WriteError(w, statusCode, body); err != nil {
+		e.logger.WithError(err).WithField("error_code", body.Code).Error("Failed to write error response")
 	}
+}
 
-	response := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>%s</Code>
-    <Message>%s</Message>
-    <Resource>%s</Resource>
-    <RequestId>%s</RequestId>
-</Error>`, html.EscapeString(errorCode), html.EscapeString(message), html.EscapeString(resource), "proxy-request")
+// orchestrationError maps the typed errors of the encryption manager to S3
+// error responses
+func orchestrationError(err error) (statusCode int, errorCode, message string, ok bool) {
+	switch {
+	case errors.Is(err, orchestration.ErrSessionNotFound):
+		return http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist", true
+	case errors.Is(err, orchestration.ErrDuplicateSession):
+		return http.StatusConflict, "OperationAborted", "A multipart upload with this upload ID is already in progress", true
+	case errors.Is(err, orchestration.ErrKeyUnavailable):
+		return http.StatusServiceUnavailable, "ServiceUnavailable", "The key required to process this request is unavailable", true
+	case errors.Is(err, orchestration.ErrKeyRateLimited):
+		return http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.", true
+	case errors.Is(err, orchestration.ErrEncryptionContextMismatch):
+		return http.StatusForbidden, "AccessDenied", "The encryption context does not match the object", true
+	case errors.Is(err, orchestration.ErrEncryptionContextUnbound):
+		return http.StatusBadRequest, "InvalidArgument", "An encryption context can only be bound to this object with integrity verification enabled", true
+	case errors.Is(err, orchestration.ErrProviderNotSelectable):
+		return http.StatusBadRequest, "InvalidArgument", "The requested encryption provider cannot be selected", true
+	case errors.Is(err, orchestration.ErrTenantKeyDenied):
+		return http.StatusForbidden, "AccessDenied", "Access Denied", true
+	case errors.Is(err, orchestration.ErrIncompleteBody):
+		return http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header", true
+	case errors.Is(err, orchestration.ErrIntegrityFailure):
+		return http.StatusInternalServerError, "InternalError", "Object integrity verification failed", true
+	default:
+		return 0, "", "", false
+	}
+}
 
-	if _, writeErr := w.Write([]byte(response)); writeErr != nil {
-		e.logger.WithError(writeErr).Error("Failed to write error response")
+// requestError maps the typed errors of request body parsing to S3 error
+// responses
+func requestError(err error) (statusCode int, errorCode, message string, ok bool) {
+	switch {
+	case errors.Is(err, request.ErrXMLTooLarge):
+		return http.StatusBadRequest, "MaxMessageLengthExceeded", "Your request was too big.", true
+	case errors.Is(err, request.ErrMalformedXML):
+		return http.StatusBadRequest, "MalformedXML", request.ErrMalformedXML.Error(), true
+	case errors.Is(err, request.ErrContentSHA256Mismatch):
+		return http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", true
+	case errors.Is(err, request.ErrBodyTooLong):
+		return http.StatusBadRequest, "InvalidRequest", "The request body is longer than the Content-Length HTTP header", true
+	case errors.Is(err, request.ErrChunkSignatureMismatch):
+		return http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", true
+	case errors.Is(err, request.ErrInvalidObjectLock):
+		return http.StatusBadRequest, "InvalidArgument", err.Error(), true
+	default:
+		return 0, "", "", false
 	}
 }
 
-// WriteGenericError writes a generic error response with custom code and message
-func (e *ErrorWriter) WriteGenericError(w http.ResponseWriter, statusCode int, code, message string) {
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(statusCode)
+// backendError maps the typed errors of the backend layer to S3 error
+// responses
+func backendError(err error) (statusCode int, errorCode, message string, ok bool) {
+	var tooLarge *backend.MetadataTooLargeError
+	switch {
+	case errors.Is(err, backend.ErrReservedKey):
+		return http.StatusForbidden, "AccessDenied", "The key is reserved for encryption metadata", true
+	case errors.As(err, &tooLarge):
+		return http.StatusBadRequest, "MetadataTooLarge", fmt.Sprintf("Your metadata headers exceed the maximum allowed metadata size: %d bytes including %d bytes of encryption metadata, limit %d bytes", tooLarge.Size, tooLarge.EncryptionSize, tooLarge.Limit), true
+	default:
+		return 0, "", "", false
+	}
+}
 
-	response := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>%s</Code>
-    <Message>%s</Message>
-</Error>`, html.EscapeString(code), html.EscapeString(message))
+// apiError passes on the errors the backend answered with, such as
+// NoSuchUpload or InvalidPart, with the backend's status code
+func apiError(err error) (statusCode int, errorCode, message string, ok bool) {
+	var apiErr smithy.APIError
+	var respErr *smithyhttp.ResponseError
+	if !errors.As(err, &apiErr) || !errors.As(err, &respErr) {
+		return 0, "", "", false
+	}
+	statusCode = respErr.HTTPStatusCode()
+	if statusCode < 400 || apiErr.ErrorCode() == "" {
+		return 0, "", "", false
+	}
+	message = apiErr.ErrorMessage()
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return statusCode, apiErr.ErrorCode(), message, true
+}
 
-	if _, writeErr := w.Write([]byte(response)); writeErr != nil {
-		e.logger.WithError(writeErr).Error("Failed to write generic error response")
+// conditionalError maps the backend's answers to conditional requests, which
+// the SDK only reports as generic API errors, to S3 error responses
+func conditionalError(err error) (statusCode int, errorCode, message string, ok bool) {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return 0, "", "", false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed":
+		return http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold", true
+	case "NotModified":
+		return http.StatusNotModified, "NotModified", "Not Modified", true
+	case "ConditionalRequestConflict":
+		return http.StatusConflict, "ConditionalRequestConflict", "A conflicting operation occurred. If using PutObject you can retry the request.", true
+	default:
+		return 0, "", "", false
 	}
 }
 
@@ -122,17 +261,11 @@ func (e *ErrorWriter) WriteNotImplemented(w http.ResponseWriter, operation strin
 	// Log to stdout for console tracking
 	fmt.Printf("[NOT IMPLEMENTED] Operation '%s' called but not yet implemented\n", operation)
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusNotImplemented)
-	response := `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>NotImplemented</Code>
-    <Message>` + operation + ` operation is not yet implemented</Message>
-    <Resource>` + operation + `</Resource>
-</Error>`
-	if _, err := w.Write([]byte(response)); err != nil {
-		e.logger.WithError(err).Error("Failed to write not implemented response")
-	}
+	e.write(w, http.StatusNotImplemented, Error{
+		Code:     "NotImplemented",
+		Message:  operation + " operation is not yet implemented",
+		Resource: operation,
+	})
 }
 
 // WriteDetailedNotImplemented writes a detailed "not implemented" response
@@ -158,29 +291,23 @@ func (e *ErrorWriter) WriteDetailedNotImplemented(w http.ResponseWriter, r *http
 	}
 
 	// Add resource path information
-	resourcePath := r.URL.Path
+	resource := r.URL.Path
 	if bucket != "" {
-		resourcePath = fmt.Sprintf("bucket: %s", bucket)
+		resource = fmt.Sprintf("bucket: %s", bucket)
 		if key != "" {
-			resourcePath = fmt.Sprintf("bucket: %s, key: %s", bucket, key)
+			resource = fmt.Sprintf("bucket: %s, key: %s", bucket, key)
 		}
 	}
 
 	// Log detailed information to stdout for console tracking
-	fmt.Printf("[NOT IMPLEMENTED] %s (Resource: %s, URL: %s)\n", message, resourcePath, r.URL.String())
+	fmt.Printf("[NOT IMPLEMENTED] %s (Resource: %s, URL: %s)\n", message, resource, r.URL.String())
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusNotImplemented)
-	response := `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>NotImplemented</Code>
-    <Message>` + message + `</Message>
-    <Resource>` + resourcePath + `</Resource>
-    <RequestURL>` + r.URL.String() + `</RequestURL>
-</Error>`
-	if _, err := w.Write([]byte(response)); err != nil {
-		e.logger.WithError(err).Error("Failed to write detailed not implemented response")
-	}
+	e.write(w, http.StatusNotImplemented, Error{
+		Code:       "NotImplemented",
+		Message:    message,
+		Resource:   resource,
+		RequestURL: r.URL.String(),
+	})
 }
 
 // WriteNotSupportedWithEncryption writes a "not supported with encryption" response
@@ -188,15 +315,10 @@ func (e *ErrorWriter) WriteNotSupportedWithEncryption(w http.ResponseWriter, ope
 	// Log to stdout for console tracking
 	fmt.Printf("[NOT SUPPORTED WITH ENCRYPTION] Operation '%s' is not supported when encryption is enabled\n", operation)
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusUnprocessableEntity) // 422 - request cannot be processed due to semantic errors
-	response := `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>NotSupportedWithEncryption</Code>
-    <Message>` + operation + ` operation is not supported when encryption is enabled. Encrypted objects cannot use S3 server-side copy functionality.</Message>
-    <Resource>` + operation + `</Resource>
-</Error>`
-	if _, err := w.Write([]byte(response)); err != nil {
-		e.logger.WithError(err).Error("Failed to write not supported with encryption response")
-	}
+	// 422 - request cannot be processed due to semantic errors
+	e.write(w, http.StatusUnprocessableEntity, Error{
+		Code:     "NotSupportedWithEncryption",
+		Message:  operation + " operation is not supported when encryption is enabled. Encrypted objects cannot use S3 server-side copy functionality.",
+		Resource: operation,
+	})
 }
@@ -1,14 +1,23 @@
 package response
 
 import (
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
 )
 
 func TestErrorWriter_WriteNotSupportedWithEncryption(t *testing.T) {
@@ -120,3 +129,103 @@ func TestErrorWriter_WriteNotSupportedWithEncryption_XMLFormat(t *testing.T) {
 	assert.Contains(t, bodyStr, "</Message>")
 	assert.Contains(t, bodyStr, "<Resource>TestOp</Resource>")
 }
+
+func TestErrorWriter_WriteS3Error_OrchestrationErrors(t *testing.T) {
+	errorWriter := NewErrorWriter(logrus.NewEntry(logrus.New()))
+
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+	}{
+		{"session not found", fmt.Errorf("%w: upload-1", orchestration.ErrSessionNotFound), http.StatusNotFound, "NoSuchUpload"},
+		{"duplicate session", fmt.Errorf("%w: upload-1", orchestration.ErrDuplicateSession), http.StatusConflict, "OperationAborted"},
+		{"provider not selectable", fmt.Errorf("%w: fast", orchestration.ErrProviderNotSelectable), http.StatusBadRequest, "InvalidArgument"},
+		{"tenant key denied", fmt.Errorf("%w: the object belongs to another tenant", orchestration.ErrTenantKeyDenied), http.StatusForbidden, "AccessDenied"},
+		{"incomplete body", fmt.Errorf("%w: part 1", orchestration.ErrIncompleteBody), http.StatusBadRequest, "IncompleteBody"},
+		{"key unavailable", &orchestration.KeyUnavailableError{Fingerprint: "abc"}, http.StatusServiceUnavailable, "ServiceUnavailable"},
+		{"key rate limited", fmt.Errorf("failed to encrypt DEK: %w", orchestration.ErrKeyRateLimited), http.StatusServiceUnavailable, "SlowDown"},
+		{"encryption context mismatch", orchestration.ErrEncryptionContextMismatch, http.StatusForbidden, "AccessDenied"},
+		{"integrity failure", fmt.Errorf("HMAC verification failed: %w", orchestration.ErrIntegrityFailure), http.StatusInternalServerError, "InternalError"},
+		{"payload hash mismatch", fmt.Errorf("failed to read body: %w", request.ErrContentSHA256Mismatch), http.StatusBadRequest, "XAmzContentSHA256Mismatch"},
+		{"body too long", request.ErrBodyTooLong, http.StatusBadRequest, "InvalidRequest"},
+		{"chunk signature mismatch", request.ErrChunkSignatureMismatch, http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"invalid object lock", fmt.Errorf("%w: unknown mode", request.ErrInvalidObjectLock), http.StatusBadRequest, "InvalidArgument"},
+		{"precondition failed", &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "etag mismatch"}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"conditional conflict", &smithy.GenericAPIError{Code: "ConditionalRequestConflict"}, http.StatusConflict, "ConditionalRequestConflict"},
+		{"reserved sidecar key", backend.ErrReservedKey, http.StatusForbidden, "AccessDenied"},
+		{"metadata too large", &backend.MetadataTooLargeError{Size: 2400, EncryptionSize: 900, Limit: 2048}, http.StatusBadRequest, "MetadataTooLarge"},
+		{"unknown error", errors.New("boom"), http.StatusInternalServerError, "InternalError"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			errorWriter.WriteS3Error(w, fmt.Errorf("failed to upload part: %w", tt.err), "bucket", "key")
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), "<Code>"+tt.code+"</Code>")
+		})
+	}
+}
+
+func TestErrorWriter_WriteS3Error_NotModified(t *testing.T) {
+	errorWriter := NewErrorWriter(logrus.NewEntry(logrus.New()))
+
+	w := httptest.NewRecorder()
+	errorWriter.WriteS3Error(w, &smithy.GenericAPIError{Code: "NotModified"}, "bucket", "key")
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestErrorWriter_WriteS3Error_BackendAPIError(t *testing.T) {
+	errorWriter := NewErrorWriter(logrus.NewEntry(logrus.New()))
+
+	// An error as returned by the SDK when the backend answers 409
+	err := &smithy.OperationError{
+		ServiceID:     "S3",
+		OperationName: "CreateBucket",
+		Err: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusConflict}},
+			Err:      &smithy.GenericAPIError{Code: "BucketAlreadyOwnedByYou", Message: "Your previous request to create the named bucket succeeded"},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	errorWriter.WriteS3Error(w, err, "bucket", "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>BucketAlreadyOwnedByYou</Code>")
+	assert.Contains(t, w.Body.String(), "<Message>Your previous request to create the named bucket succeeded</Message>")
+}
+
+func TestErrorWriter_WriteS3Error_Envelope(t *testing.T) {
+	errorWriter := NewErrorWriter(logrus.NewEntry(logrus.New()))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Amz-Request-Id", "4442587FB7D0A2F9")
+	errorWriter.WriteS3Error(w, errors.New("connection reset by peer"), "bucket", "dir/key")
+
+	var body Error
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "InternalError", body.Code)
+	// Internal details stay in the log
+	assert.Equal(t, "We encountered an internal error. Please try again.", body.Message)
+	assert.Equal(t, "/bucket/dir/key", body.Resource)
+	assert.Equal(t, "4442587FB7D0A2F9", body.RequestID)
+}
+
+func TestWriteError_AssignsRequestID(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := WriteError(w, http.StatusForbidden, Error{Code: "AccessDenied", Message: "Access Denied", Resource: "/bucket"})
+	assert.NoError(t, err)
+
+	requestID := w.Header().Get("X-Amz-Request-Id")
+	assert.Len(t, requestID, 16)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), xml.Header))
+	assert.Contains(t, w.Body.String(), "<RequestId>"+requestID+"</RequestId>")
+}
@@ -19,29 +19,60 @@ func (s *Server) setupRoutes(router *mux.Router) {
 
 	// Initialize handlers
 	healthHandler := health.NewHandler(s.logger, s.config.LogHealthRequests)
-	healthHandler.SetShutdownStateHandler(s.shutdownStateHandler)
+	healthHandler.SetShutdownStateHandler(s.shutdownState)
+	healthHandler.SetReadinessHandler(s.isReady)
+	healthHandler.SetProbeResults(s.probeResults)
 	healthHandler.SetRequestTracker(s.requestStartHandler, s.requestEndHandler)
 
 	// Health and version endpoints - before middleware to avoid authentication
 	healthRouter := router.NewRoute().Subrouter()
 	healthRouter.HandleFunc("/health", healthHandler.Health).Methods("GET")
 	healthRouter.HandleFunc("/version", healthHandler.Version).Methods("GET")
+	healthRouter.HandleFunc("/livez", healthHandler.Livez).Methods("GET")
+	healthRouter.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	healthRouter.HandleFunc("/startupz", healthHandler.Startupz).Methods("GET")
 
 	// S3 API endpoints - protected by S3 authentication
 	s3Router := router.NewRoute().Subrouter()
 
-	// Add middleware to S3 router only - order matters: auth first, then tracking, logging, and cors
+	// Add middleware to S3 router only - order matters: the request ID is
+	// assigned first, the request span covers everything else, response
+	// header normalization wraps the rest so rejections carry the S3 headers
+	// too, then the audit and access logs (which also record rejected and
+	// panicking requests), panic recovery, listener limits, auth, client rate
+	// limits, SSE-C and bucket policies, the license gate, upload limits,
+	// event notifications, tracking, logging, cors, the object cache, and the
+	// span of the handler
+	s3Router.Use(s.requestIDMiddleware)
+	s3Router.Use(s.tracingMiddleware)
+	s3Router.Use(s.s3HeadersMiddleware)
+	s3Router.Use(s.auditMiddleware)
+	s3Router.Use(s.accessLogMiddleware)
+	s3Router.Use(s.recoveryMiddleware)
+	s3Router.Use(s.hardeningMiddleware)
 	s3Router.Use(s.s3AuthMiddleware)
+	s3Router.Use(s.rateLimitMiddleware)
+	s3Router.Use(s.ssecMiddleware)
+	s3Router.Use(s.bucketPolicyMiddleware)
+	s3Router.Use(s.licenseMiddleware)
+	s3Router.Use(s.uploadLimitsMiddleware)
+	s3Router.Use(s.notificationsMiddleware)
 	s3Router.Use(s.requestTrackingMiddleware)
 	s3Router.Use(s.loggingMiddleware)
 	s3Router.Use(s.corsMiddleware)
+	s3Router.Use(s.objectCacheMiddleware)
+	s3Router.Use(s.handlerTracingMiddleware)
 
 	rootHandler := root.NewHandler(s.s3Backend, s.logger)
+	if s.config != nil {
+		rootHandler.SetCapabilities(root.NewCapabilities(s.config))
+	}
 	bucketHandler := bucket.NewHandler(s.s3Backend, s.logger, s.getMetadataPrefix(), s.config)
 	objectHandler := object.NewHandler(s.s3Backend, s.encryptionMgr, s.config, s.logger)
 	multipartHandler := multipart.NewHandler(s.s3Backend, s.encryptionMgr, s.logger, s.getMetadataPrefix(), s.config)
 
-	// Root endpoint - list buckets
+	// Root endpoint - proxy capabilities and list buckets
+	s3Router.HandleFunc("/", rootHandler.HandleCapabilities).Methods("GET").Queries(root.CapabilitiesQuery, "")
 	s3Router.HandleFunc("/", rootHandler.HandleListBuckets).Methods("GET")
 
 	// Bucket sub-resources (must be defined BEFORE general bucket operations)
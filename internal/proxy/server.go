@@ -2,34 +2,59 @@ package proxy
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"runtime"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gorilla/mux"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/accesslog"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/audit"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/authz"
 	proxyconfig "github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/notification"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/objectcache"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/probes"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/middleware"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/replication"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tracing"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/tuning"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/usage"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
 	"github.com/sirupsen/logrus"
 )
 
 // Server represents the S3 encryption proxy server
 type Server struct {
-	httpServer    *http.Server
-	s3Backend     *s3.Client
-	encryptionMgr *orchestration.Manager
-	config        *proxyconfig.Config
-	logger        *logrus.Entry
+	httpServer      *http.Server
+	s3Backend       interfaces.S3BackendInterface
+	backendRouter   *backend.Router         // nil without s3_backend.routes
+	backendFailover *backend.Failover       // nil without s3_backend.replicas
+	replicator      *replication.Replicator // nil without replication
+	encryptionMgr   *orchestration.Manager
+	config          *proxyconfig.Config
+	logger          *logrus.Entry
 
 	// Monitoring
 	monitoringEnabled bool
 
 	// Graceful shutdown tracking
+	requestCtx           context.Context    // Base context of every request
+	cancelRequests       context.CancelFunc // Aborts the requests still running when the shutdown timeout expires
 	shutdownStateHandler func() (bool, time.Time)
+	readinessHandler     func() bool
+	prober               *probes.Prober // nil until SetProber
+	licenseRestricted    func() bool    // nil until SetLicenseRestriction
 	requestStartHandler  func()
 	requestEndHandler    func()
 
@@ -37,7 +62,47 @@ type Server struct {
 	requestTracker *middleware.RequestTracker
 	httpLogger     *middleware.Logger
 	corsHandler    *middleware.CORS
+	hardening      *middleware.Hardening
+	rateLimiter    *middleware.RateLimiter
+	keyCanonical   *middleware.KeyCanonicalizer
+	ssec           *middleware.SSEC
+	bucketPolicy   *middleware.BucketPolicy
+	licenseGate    *middleware.LicenseGate
+	uploadLimits   *middleware.UploadLimits
+	objectCacher   *middleware.ObjectCache
+	notifications  *middleware.Notifications
+	requestIDs     *middleware.RequestID
+	s3Headers      *middleware.S3Headers
+	recovery       *middleware.Recovery
+	audit          *middleware.Audit
+	accessLogger   *middleware.AccessLog
+	tracing        *middleware.Tracing
 	s3AuthService  *middleware.S3AuthenticationService
+
+	// Client scopes replacing those of s3_clients, nil unless
+	// authorization.policy_file is set
+	scopeSource authz.ScopeSource
+
+	// Audit log and sinks, nil unless audit.enabled or audit.sinks
+	auditRecorder *audit.Recorder
+
+	// Event notification targets, nil unless notifications.targets
+	notificationEmitter *notification.Emitter
+
+	// Access log, nil unless access_log.enabled
+	accessLog *accesslog.Logger
+
+	// Decrypted object cache, nil unless object_cache.enabled
+	objectCache *objectcache.Cache
+
+	// Bucket usage the upload quotas are checked against, nil until
+	// SetUsageSource
+	usageSource UsageSource
+}
+
+// UsageSource reports the usage of a bucket at its last collection
+type UsageSource interface {
+	BucketUsage(bucket string) (usage.BucketUsage, error)
 }
 
 // NewServer creates a new proxy server instance
@@ -60,6 +125,9 @@ func NewServer(cfg *proxyconfig.Config) (*Server, error) {
 			"type":        provider.Type,
 			"fingerprint": provider.Fingerprint,
 		}
+		if provider.Tenant != "" {
+			fields["tenant"] = provider.Tenant
+		}
 
 		if provider.IsActive {
 			logger.WithFields(fields).Info("🔒🔑 Active KEK provider to encrypt and decrypt data")
@@ -68,6 +136,11 @@ func NewServer(cfg *proxyconfig.Config) (*Server, error) {
 		}
 	}
 
+	logger.WithFields(logrus.Fields{
+		"arch":         runtime.GOARCH,
+		"hardware_aes": dataencryption.HardwareAESAvailable(),
+	}).Info("AES data encryption backend")
+
 	// Get metadata prefix from encryption config
 	metadataPrefix := "s3ep-" // default when not set
 	var metadataSource string
@@ -111,70 +184,92 @@ func NewServer(cfg *proxyconfig.Config) (*Server, error) {
 		s3Config.InsecureSkipVerify = cfg.SkipSSLVerification // fallback to legacy
 	}
 
-	awsConfig := aws.Config{
-		Region:      s3Config.Region,
-		Credentials: credentials.NewStaticCredentialsProvider(s3Config.AccessKeyID, s3Config.SecretKey, ""),
-	}
-
-	// Configure endpoint resolver for MinIO/custom S3 endpoints
-	s3Client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
-		// Force path-style addressing for MinIO/custom S3 endpoints
-		o.UsePathStyle = true
+	s3Client := newS3Client(s3Config, cfg.Optimizations.Network.Backend, logger)
 
-		// Disable checksum validation for MinIO compatibility
-		// MinIO doesn't support AWS checksum headers, causing SDK warnings
-		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenSupported
-		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenSupported
-
-		// Configure custom endpoint if specified
-		if s3Config.TargetEndpoint != "" {
-			o.BaseEndpoint = aws.String(s3Config.TargetEndpoint)
-		}
-		// Configure TLS verification based on configuration
-		if s3Config.TargetEndpoint != "" {
-			// Use the unified s3Config which includes migrated values
-			skipTLSVerification := s3Config.InsecureSkipVerify
+	// Fail reads over to the replicas of the backend when they are configured
+	var defaultBackend interfaces.S3BackendInterface = s3Client
+	var backendFailover *backend.Failover
+	if !cfg.DevMode && len(cfg.S3Backend.Replicas) > 0 {
+		backendFailover = newBackendFailover(cfg, s3Config, s3Client, logger)
+		defaultBackend = backendFailover
+	}
 
-			logger.WithFields(logrus.Fields{
-				"target_endpoint":                 s3Config.TargetEndpoint,
-				"s3_backend_insecure_skip_verify": s3Config.InsecureSkipVerify,
-				"final_skip_tls_verification":     skipTLSVerification,
-			}).Debug("TLS configuration for S3 client")
+	// Route buckets to their own backends when a routing table is configured
+	s3Backend := defaultBackend
+	var backendRouter *backend.Router
+	if cfg.DevMode {
+		logger.Warn("🧪 Developer mode: objects are kept in memory and lost on restart")
+		s3Backend = backend.NewMemoryBackend(logrus.WithField("component", "memory-backend"))
+	} else if len(cfg.S3Backend.Routes) > 0 {
+		backendRouter = newBackendRouter(cfg, s3Config, s3Client, defaultBackend, logger)
+		s3Backend = backendRouter
+	}
+	// Queue written objects for replication, sidecars included
+	var replicator *replication.Replicator
+	if cfg.Replication.Enabled {
+		replicator = newReplicator(cfg, s3Config, s3Backend, logger)
+		s3Backend = replicator.Backend()
+	}
+	// Objects with split metadata values are readable whatever the limits
+	metadataLimits := backend.NewMetadataLimitStore(s3Backend, cfg.Encryption.MetadataLimits, metadataPrefix)
+	metadataLimits.SetLegacyPrefixes(cfg.Encryption.LegacyMetadataKeyPrefixes)
+	s3Backend = metadataLimits
+	// Objects written with a metadata sidecar are readable in every mode
+	sidecars := backend.NewSidecarStore(s3Backend, cfg.Encryption.MetadataSidecar, metadataPrefix, logrus.WithField("component", "metadata-sidecar"))
+	sidecars.SetLegacyPrefixes(cfg.Encryption.LegacyMetadataKeyPrefixes)
+	s3Backend = sidecars
+	// Objects written under an earlier metadata key prefix read like the others
+	if len(cfg.Encryption.LegacyMetadataKeyPrefixes) > 0 {
+		migrateOnRead := cfg.Encryption.MetadataPrefixMigration == proxyconfig.MetadataPrefixMigrationOnRead
+		s3Backend = backend.NewLegacyPrefixStore(s3Backend, metadataPrefix, cfg.Encryption.LegacyMetadataKeyPrefixes, migrateOnRead, logrus.WithField("component", "metadata-prefix"))
+	}
 
-			if skipTLSVerification {
-				logger.Warn("TLS certificate verification is disabled - this should only be used for development/testing")
-				o.HTTPClient = &http.Client{
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{
-							InsecureSkipVerify: true, // #nosec G402 - This is configurable and warns user
-						},
-					},
-				}
-			} else {
-				logger.Debug("TLS certificate verification is enabled")
-			}
-		}
-	})
+	// Continue the audit log chain before the first request is served
+	auditRecorder, err := newAuditRecorder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	accessLog, err := newAccessLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	objectCache, err := newObjectCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+	notificationEmitter, err := newNotificationEmitter(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create HTTP server with routes
-	router := mux.NewRouter()
 	server := &Server{
-		s3Backend:         s3Client,
-		encryptionMgr:     encryptionMgr,
-		config:            cfg,
-		logger:            logger,
-		monitoringEnabled: cfg.Monitoring.Enabled,
+		s3Backend:           s3Backend,
+		backendRouter:       backendRouter,
+		backendFailover:     backendFailover,
+		replicator:          replicator,
+		encryptionMgr:       encryptionMgr,
+		config:              cfg,
+		logger:              logger,
+		monitoringEnabled:   cfg.Monitoring.Enabled,
+		auditRecorder:       auditRecorder,
+		accessLog:           accessLog,
+		objectCache:         objectCache,
+		notificationEmitter: notificationEmitter,
 	}
 
-	// Setup routes
-	server.setupRoutes(router)
+	server.requestCtx, server.cancelRequests = context.WithCancel(context.Background())
 
+	listener := cfg.GetListenerConfig()
 	httpServer := &http.Server{
-		Addr:         cfg.BindAddress,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              cfg.BindAddress,
+		Handler:           server.newHandler(),
+		BaseContext:       func(net.Listener) context.Context { return server.requestCtx },
+		ReadHeaderTimeout: time.Duration(listener.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    listener.MaxHeaderBytes,
 	}
 
 	server.httpServer = httpServer
@@ -187,6 +282,86 @@ func (s *Server) SetShutdownStateHandler(handler func() (bool, time.Time)) {
 	s.shutdownStateHandler = handler
 }
 
+// SetReadinessHandler sets the handler gating the health endpoint until the
+// proxy is ready for traffic
+func (s *Server) SetReadinessHandler(handler func() bool) {
+	s.readinessHandler = handler
+}
+
+// shutdownState reports the shutdown state handler result. Like isReady, it
+// is looked up per request.
+func (s *Server) shutdownState() (bool, time.Time) {
+	if s.shutdownStateHandler == nil {
+		return false, time.Time{}
+	}
+	return s.shutdownStateHandler()
+}
+
+// isReady reports the readiness handler result. Routes are set up before the
+// handler is set, so it is looked up per request.
+func (s *Server) isReady() bool {
+	return s.readinessHandler == nil || s.readinessHandler()
+}
+
+// SetLicenseRestriction sets the handler reporting whether uploads are
+// rejected because the license expired
+func (s *Server) SetLicenseRestriction(restricted func() bool) {
+	s.licenseRestricted = restricted
+}
+
+// isLicenseRestricted reports the license restriction handler result. Like
+// isReady, it is looked up per request.
+func (s *Server) isLicenseRestricted() bool {
+	return s.licenseRestricted != nil && s.licenseRestricted()
+}
+
+// SetProber sets the checks reported by the readiness and startup probes
+func (s *Server) SetProber(prober *probes.Prober) {
+	s.prober = prober
+}
+
+// probeResults returns the results of the prober's checks and, with a
+// readiness handler, of the key preloading. Routes are set up before both
+// are set, so they are looked up per request.
+func (s *Server) probeResults() []probes.Result {
+	var results []probes.Result
+	if s.prober != nil {
+		results = s.prober.Results()
+	}
+	if s.readinessHandler != nil {
+		keys := probes.Result{Check: preflight.Check{Name: "key_preload", Status: preflight.StatusOK}}
+		if !s.readinessHandler() {
+			keys.Status, keys.Detail = preflight.StatusFail, "key material is not loaded yet"
+		}
+		results = append(results, keys)
+	}
+	return results
+}
+
+// SetScopeSource makes the scopes of source replace the s3_clients scopes of
+// the clients it lists. It must be called before the server starts.
+func (s *Server) SetScopeSource(source authz.ScopeSource) {
+	s.scopeSource = source
+	if s.s3AuthService != nil {
+		s.s3AuthService.SetScopeSource(source)
+	}
+}
+
+// SetUsageSource sets the bucket usage the upload_limits quotas are checked
+// against
+func (s *Server) SetUsageSource(source UsageSource) {
+	s.usageSource = source
+}
+
+// bucketUsage reports the usage source result. Like isReady, it is looked up
+// per request.
+func (s *Server) bucketUsage(bucket string) (usage.BucketUsage, error) {
+	if s.usageSource == nil {
+		return usage.BucketUsage{}, usage.ErrNotCollected
+	}
+	return s.usageSource.BucketUsage(bucket)
+}
+
 // SetRequestTracker sets handlers for tracking active requests
 func (s *Server) SetRequestTracker(onStart, onEnd func()) {
 	s.requestStartHandler = onStart
@@ -195,13 +370,54 @@ func (s *Server) SetRequestTracker(onStart, onEnd func()) {
 
 // GetHandler returns the HTTP handler for testing purposes
 func (s *Server) GetHandler() http.Handler {
+	return s.newHandler()
+}
+
+// newHandler creates the router with all routes behind key canonicalization
+func (s *Server) newHandler() http.Handler {
 	router := mux.NewRouter()
+	// Keys are canonicalized as configured instead of being redirected to a cleaned path
+	router.SkipClean(true)
 	s.setupRoutes(router)
-	return router
+	return s.keyCanonicalizationMiddleware(router)
+}
+
+// GetS3Backend returns the backend used by the proxy, routed by bucket when
+// backend routes are configured
+func (s *Server) GetS3Backend() interfaces.S3BackendInterface {
+	return s.s3Backend
+}
+
+// GetEncryptionManager returns the encryption manager used by the handlers
+func (s *Server) GetEncryptionManager() *orchestration.Manager {
+	return s.encryptionMgr
 }
 
 // Start starts the proxy server
 func (s *Server) Start(ctx context.Context) error {
+	if s.backendRouter != nil && s.config.S3Backend.RouteHealthCheckInterval > 0 {
+		go s.backendRouter.RunHealthChecks(ctx, time.Duration(s.config.S3Backend.RouteHealthCheckInterval)*time.Second)
+	}
+	if s.backendFailover != nil {
+		go s.backendFailover.RunHealthChecks(ctx, time.Duration(s.config.S3Backend.ReplicaHealthCheckInterval)*time.Second)
+	}
+	if s.replicator != nil {
+		go s.replicator.Run(ctx)
+	}
+
+	// Listen before serving so that the socket options of
+	// optimizations.network.listener apply to every client connection
+	ln, err := net.Listen("tcp", s.config.BindAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.BindAddress, err)
+	}
+	listenerTuning := s.config.Optimizations.Network.Listener
+	ln = tuning.NewListener(ln, tuning.SocketOptions{
+		NoDelay:       listenerTuning.NoDelay(),
+		SendBuffer:    listenerTuning.SendBuffer,
+		ReceiveBuffer: listenerTuning.ReceiveBuffer,
+	}, s.logger)
+
 	// Start HTTP server in a goroutine
 	serverErrChan := make(chan error, 1)
 	go func() {
@@ -212,12 +428,12 @@ func (s *Server) Start(ctx context.Context) error {
 				"key_file":  s.config.TLS.KeyFile,
 			}).Info("Starting HTTPS server")
 
-			if err := s.httpServer.ListenAndServeTLS(s.config.TLS.CertFile, s.config.TLS.KeyFile); err != nil && err != http.ErrServerClosed {
+			if err := s.httpServer.ServeTLS(ln, s.config.TLS.CertFile, s.config.TLS.KeyFile); err != nil && err != http.ErrServerClosed {
 				serverErrChan <- fmt.Errorf("HTTPS server failed: %w", err)
 			}
 		} else {
 			s.logger.WithField("address", s.config.BindAddress).Info("Starting HTTP server")
-			if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
 				serverErrChan <- fmt.Errorf("HTTP server failed: %w", err)
 			}
 		}
@@ -232,20 +448,98 @@ func (s *Server) Start(ctx context.Context) error {
 		if s.config.TLS.Enabled {
 			protocol = "HTTPS"
 		}
-		s.logger.WithField("protocol", protocol).Info("Shutting down server")
+		s.logger.WithFields(logrus.Fields{
+			"protocol":       protocol,
+			"activeRequests": s.ActiveRequests(),
+		}).Info("Shutting down server")
 
-		// Create shutdown context with timeout
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout())
 		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	}
+}
 
-		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
-			s.logger.WithError(err).Error("Failed to gracefully shutdown server")
-			return err
+// shutdownGrace is how long requests may take to return once their context
+// was canceled, and how long the encryption manager may take to save its
+// sessions and DEK cache
+const shutdownGrace = 5 * time.Second
+
+// ShutdownTimeout returns how long active requests may take to complete on
+// shutdown (shutdown_timeout, default 30 seconds)
+func (s *Server) ShutdownTimeout() time.Duration {
+	if s.config != nil && s.config.ShutdownTimeout > 0 {
+		return time.Duration(s.config.ShutdownTimeout) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// ActiveRequests returns the number of S3 requests in flight
+func (s *Server) ActiveRequests() int64 {
+	if s.requestTracker == nil {
+		return 0
+	}
+	return s.requestTracker.Active()
+}
+
+// Shutdown stops accepting connections and waits for the active requests
+// until ctx is done. Requests still running then have their context
+// canceled, so multipart parts record where their keystream broke off, and
+// get shutdownGrace to return before their connections are closed. Once no
+// request runs anymore, the logs and notification targets are flushed and
+// the encryption manager saves its multipart sessions.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.logger.WithError(err).WithField("activeRequests", s.ActiveRequests()).Warn("Shutdown timeout reached, canceling active requests")
+		if s.cancelRequests != nil {
+			s.cancelRequests()
+		}
+		if s.requestTracker != nil {
+			abortCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+			if waitErr := s.requestTracker.Wait(abortCtx); waitErr != nil {
+				s.logger.WithField("activeRequests", s.ActiveRequests()).Error("Canceled requests did not return, closing their connections")
+			}
+			cancel()
+		}
+		if closeErr := s.httpServer.Close(); closeErr != nil {
+			s.logger.WithError(closeErr).Warn("Failed to close client connections")
+		}
+	} else {
+		s.logger.Info("All requests completed")
+	}
+	if s.cancelRequests != nil {
+		s.cancelRequests()
+	}
+
+	// Seal the open audit segment and flush the sinks once no request
+	// can append to them
+	if s.auditRecorder != nil {
+		if err := s.auditRecorder.Close(); err != nil {
+			s.logger.WithError(err).Error("Failed to close audit log")
+		}
+	}
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			s.logger.WithError(err).Error("Failed to close access log")
 		}
+	}
+	if s.notificationEmitter != nil {
+		if err := s.notificationEmitter.Close(); err != nil {
+			s.logger.WithError(err).Error("Failed to close event notification targets")
+		}
+	}
 
-		s.logger.Info("Server stopped")
-		return nil
+	// Save the multipart sessions after their last part was recorded
+	if s.encryptionMgr != nil {
+		managerCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		if err := s.encryptionMgr.Shutdown(managerCtx); err != nil {
+			s.logger.WithError(err).Error("Failed to shut down encryption manager")
+		}
+		cancel()
 	}
+
+	s.logger.Info("Server stopped")
+	return err
 }
 
 // getMetadataPrefix returns the metadata prefix from config
@@ -255,3 +549,211 @@ func (s *Server) getMetadataPrefix() string {
 	}
 	return "s3ep-" // default
 }
+
+// newS3Client creates the AWS SDK client for a backend endpoint
+func newS3Client(s3Config proxyconfig.S3BackendConfig, transport proxyconfig.BackendTransportConfig, logger *logrus.Entry) *s3.Client {
+	awsConfig := aws.Config{
+		Region:      s3Config.Region,
+		Credentials: credentials.NewStaticCredentialsProvider(s3Config.AccessKeyID, s3Config.SecretKey, ""),
+	}
+
+	// Configure endpoint resolver for MinIO/custom S3 endpoints
+	s3Client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		// Force path-style addressing for MinIO/custom S3 endpoints
+		o.UsePathStyle = true
+
+		// Disable checksum validation for MinIO compatibility
+		// MinIO doesn't support AWS checksum headers, causing SDK warnings
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenSupported
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenSupported
+
+		// Backend calls are spans of the request they are made for
+		o.TracerProvider = tracing.SmithyTracerProvider()
+
+		// Identify proxy traffic in the backend's server access logs
+		if s3Config.AccessLogging.UserAgentTag != "" {
+			o.APIOptions = append(o.APIOptions, awsmiddleware.AddUserAgentKey(s3Config.AccessLogging.UserAgentTag))
+		}
+
+		// Configure custom endpoint if specified
+		if s3Config.TargetEndpoint != "" {
+			o.BaseEndpoint = aws.String(s3Config.TargetEndpoint)
+		}
+		// Configure TLS verification based on configuration
+		skipTLSVerification := false
+		if s3Config.TargetEndpoint != "" {
+			// Use the unified s3Config which includes migrated values
+			skipTLSVerification = s3Config.InsecureSkipVerify
+
+			logger.WithFields(logrus.Fields{
+				"target_endpoint":                 s3Config.TargetEndpoint,
+				"s3_backend_insecure_skip_verify": s3Config.InsecureSkipVerify,
+				"final_skip_tls_verification":     skipTLSVerification,
+			}).Debug("TLS configuration for S3 client")
+
+			if skipTLSVerification {
+				logger.Warn("TLS certificate verification is disabled - this should only be used for development/testing")
+			} else {
+				logger.Debug("TLS certificate verification is enabled")
+			}
+		}
+
+		// Socket options and transport buffers from optimizations.network.backend
+		dialer := &net.Dialer{Timeout: awshttp.DefaultDialConnectTimeout, KeepAlive: awshttp.DefaultDialKeepAliveTimeout}
+		socketOptions := tuning.SocketOptions{
+			NoDelay:       transport.NoDelay(),
+			SendBuffer:    transport.SendBuffer,
+			ReceiveBuffer: transport.ReceiveBuffer,
+		}
+		o.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.DialContext = tuning.DialContext(dialer, socketOptions, logger)
+			tr.WriteBufferSize = transport.WriteBufferSize
+			tr.ReadBufferSize = transport.ReadBufferSize
+			if skipTLSVerification {
+				tr.TLSClientConfig.InsecureSkipVerify = true // #nosec G402 - This is configurable and warns user
+			}
+		})
+	})
+
+	return s3Client
+}
+
+// newBackendFailover creates the failover backend over the default backend and
+// its replicas. Replicas use the region and credentials of the default backend
+// unless they set their own.
+func newBackendFailover(cfg *proxyconfig.Config, defaultConfig proxyconfig.S3BackendConfig, defaultClient *s3.Client, logger *logrus.Entry) *backend.Failover {
+	primary := backend.Endpoint{
+		Name:        proxyconfig.DefaultBackendRouteName,
+		Client:      defaultClient,
+		HealthCheck: backendHealthCheck(defaultClient, ""),
+	}
+
+	replicas := make([]backend.Endpoint, 0, len(defaultConfig.Replicas))
+	for _, replicaConfig := range defaultConfig.Replicas {
+		s3Config := proxyconfig.S3BackendConfig{
+			TargetEndpoint:     replicaConfig.TargetEndpoint,
+			Region:             replicaConfig.Region,
+			AccessKeyID:        replicaConfig.AccessKeyID,
+			SecretKey:          replicaConfig.SecretKey,
+			UseTLS:             defaultConfig.UseTLS,
+			InsecureSkipVerify: replicaConfig.InsecureSkipVerify,
+			AccessLogging:      defaultConfig.AccessLogging,
+		}
+		if s3Config.Region == "" {
+			s3Config.Region = defaultConfig.Region
+		}
+		if s3Config.AccessKeyID == "" {
+			s3Config.AccessKeyID, s3Config.SecretKey = defaultConfig.AccessKeyID, defaultConfig.SecretKey
+		}
+
+		client := newS3Client(s3Config, cfg.Optimizations.Network.Backend, logger.WithField("replica", replicaConfig.Name))
+		replicas = append(replicas, backend.Endpoint{
+			Name:        replicaConfig.Name,
+			Client:      client,
+			HealthCheck: backendHealthCheck(client, replicaConfig.HealthCheckBucket),
+		})
+
+		logger.WithFields(logrus.Fields{
+			"replica":         replicaConfig.Name,
+			"target_endpoint": replicaConfig.TargetEndpoint,
+			"read_preference": defaultConfig.ReadPreference,
+		}).Info("Registered backend replica")
+	}
+
+	nearest := defaultConfig.ReadPreference == proxyconfig.ReadPreferenceNearest
+	return backend.NewFailover(primary, replicas, nearest, logrus.WithField("component", "backend-failover"))
+}
+
+// newReplicator creates the replicator copying the objects of source to the
+// replication target. The target uses the region and credentials of the
+// default backend unless it sets its own.
+func newReplicator(cfg *proxyconfig.Config, defaultConfig proxyconfig.S3BackendConfig, source interfaces.S3BackendInterface, logger *logrus.Entry) *replication.Replicator {
+	r := cfg.Replication
+	s3Config := proxyconfig.S3BackendConfig{
+		TargetEndpoint:     r.TargetEndpoint,
+		Region:             r.Region,
+		AccessKeyID:        r.AccessKeyID,
+		SecretKey:          r.SecretKey,
+		UseTLS:             defaultConfig.UseTLS,
+		InsecureSkipVerify: r.InsecureSkipVerify,
+		AccessLogging:      defaultConfig.AccessLogging,
+	}
+	if s3Config.Region == "" {
+		s3Config.Region = defaultConfig.Region
+	}
+	if s3Config.AccessKeyID == "" {
+		s3Config.AccessKeyID, s3Config.SecretKey = defaultConfig.AccessKeyID, defaultConfig.SecretKey
+	}
+	target := newS3Client(s3Config, cfg.Optimizations.Network.Backend, logger.WithField("replication", r.TargetEndpoint))
+
+	logger.WithFields(logrus.Fields{
+		"target_endpoint": r.TargetEndpoint,
+		"buckets":         r.Buckets,
+		"workers":         r.Workers,
+	}).Info("Replicating written objects")
+
+	return replication.New(source, target, replication.Config{
+		Buckets:      r.Buckets,
+		QueueSize:    r.QueueSize,
+		Workers:      r.Workers,
+		MaxRetries:   r.MaxRetries,
+		RetryBackoff: time.Duration(r.RetryBackoff) * time.Second,
+	}, logrus.WithField("component", "replication"))
+}
+
+// newBackendRouter creates the bucket router for the configured backend routes.
+// Routes use the region of the default backend unless they set their own.
+// defaultBackend serves the unrouted buckets: defaultClient, or the failover
+// over it and its replicas.
+func newBackendRouter(cfg *proxyconfig.Config, defaultConfig proxyconfig.S3BackendConfig, defaultClient *s3.Client, defaultBackend interfaces.S3BackendInterface, logger *logrus.Entry) *backend.Router {
+	fallback := backend.Route{
+		Name:        proxyconfig.DefaultBackendRouteName,
+		Client:      defaultBackend,
+		HealthCheck: backendHealthCheck(defaultClient, ""),
+	}
+
+	routes := make([]backend.Route, 0, len(cfg.S3Backend.Routes))
+	for _, routeConfig := range cfg.S3Backend.Routes {
+		s3Config := proxyconfig.S3BackendConfig{
+			TargetEndpoint:     routeConfig.TargetEndpoint,
+			Region:             routeConfig.Region,
+			AccessKeyID:        routeConfig.AccessKeyID,
+			SecretKey:          routeConfig.SecretKey,
+			UseTLS:             defaultConfig.UseTLS,
+			InsecureSkipVerify: routeConfig.InsecureSkipVerify,
+			AccessLogging:      defaultConfig.AccessLogging,
+		}
+		if s3Config.Region == "" {
+			s3Config.Region = defaultConfig.Region
+		}
+
+		client := newS3Client(s3Config, cfg.Optimizations.Network.Backend, logger.WithField("route", routeConfig.Name))
+		routes = append(routes, backend.Route{
+			Name:        routeConfig.Name,
+			Buckets:     routeConfig.Buckets,
+			Client:      client,
+			HealthCheck: backendHealthCheck(client, routeConfig.HealthCheckBucket),
+		})
+
+		logger.WithFields(logrus.Fields{
+			"route":           routeConfig.Name,
+			"buckets":         routeConfig.Buckets,
+			"target_endpoint": routeConfig.TargetEndpoint,
+		}).Info("Registered backend route")
+	}
+
+	return backend.NewRouter(fallback, routes, logrus.WithField("component", "backend-router"))
+}
+
+// backendHealthCheck probes a backend with HeadBucket on bucket, or with
+// ListBuckets when no bucket is given
+func backendHealthCheck(client *s3.Client, bucket string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if bucket != "" {
+			_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+			return err
+		}
+		_, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+		return err
+	}
+}
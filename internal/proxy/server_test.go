@@ -1,15 +1,26 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/preflight"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/probes"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/middleware"
 	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/utils"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -99,6 +110,106 @@ func TestServer_HealthEndpoint(t *testing.T) {
 	assert.Contains(t, string(body), "healthy")
 }
 
+func TestServer_ShutdownCancelsRequestsAtTimeout(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	server, err := NewServer(createTestConfigNone())
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	server.httpServer.Handler = server.requestTracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.httpServer.Serve(ln) }()
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/bucket/key")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	assert.Equal(t, int64(1), server.ActiveRequests())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("the request context was not canceled at the shutdown timeout")
+	}
+	assert.Equal(t, int64(0), server.ActiveRequests())
+}
+
+func TestServer_ProbeEndpoints(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+	server, err := NewServer(createTestConfigNone())
+	require.NoError(t, err)
+	handler := server.GetHandler()
+
+	var shuttingDown, backendUp atomic.Bool
+	server.SetShutdownStateHandler(func() (bool, time.Time) { return shuttingDown.Load(), time.Now() })
+	prober := probes.NewProber(time.Second, logrus.NewEntry(logrus.New()))
+	prober.Register("backend", 10*time.Millisecond, func(context.Context) []preflight.Check {
+		if !backendUp.Load() {
+			return []preflight.Check{{Name: "backend", Status: preflight.StatusFail, Detail: "connection refused"}}
+		}
+		return []preflight.Check{{Name: "backend", Status: preflight.StatusOK}}
+	})
+	server.SetProber(prober)
+
+	probe := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	// Before the first check ran
+	code, body := probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, `"status":"pending"`)
+	code, _ = probe("/livez")
+	assert.Equal(t, http.StatusOK, code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go prober.Run(ctx)
+
+	assert.Eventually(t, func() bool {
+		_, body := probe("/readyz")
+		return strings.Contains(body, "connection refused")
+	}, time.Second, 5*time.Millisecond)
+	code, body = probe("/startupz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, `"status":"starting"`)
+
+	backendUp.Store(true)
+	assert.Eventually(t, func() bool { code, _ := probe("/readyz"); return code == http.StatusOK }, time.Second, 5*time.Millisecond)
+	code, body = probe("/startupz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"status":"started"`)
+
+	// Once started, startup no longer depends on the checks
+	backendUp.Store(false)
+	assert.Eventually(t, func() bool { code, _ := probe("/readyz"); return code == http.StatusServiceUnavailable }, time.Second, 5*time.Millisecond)
+	code, _ = probe("/startupz")
+	assert.Equal(t, http.StatusOK, code)
+
+	shuttingDown.Store(true)
+	code, body = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "shutting_down")
+	code, _ = probe("/livez")
+	assert.Equal(t, http.StatusOK, code, "alive while draining")
+}
+
 func TestServer_HealthEndpointLogging(t *testing.T) {
 	// Create test configurations
 	cfgWithLogging := &config.Config{
@@ -609,3 +720,51 @@ func TestServer_handleS3Error_KEK_MISSING(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_AnonymousReadGetsNoTenantKey(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cfg := &config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: "kek-shared",
+			Providers: []config.EncryptionProvider{
+				{Alias: "kek-shared", Type: "aes", Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="}},
+				{Alias: "kek-acme", Type: "aes", Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}},
+			},
+			Tenants: []config.TenantConfig{{Name: "acme", Provider: "kek-acme", Buckets: []string{"acme-www"}}},
+		},
+		BucketPolicies: config.BucketPoliciesConfig{
+			Rules: []config.BucketPolicyRule{{Buckets: []string{"acme-www"}, BucketPolicy: config.BucketPolicy{PublicRead: true}}},
+		},
+	}
+	manager, err := orchestration.NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+
+	// An object the tenant wrote to its public_read bucket
+	tenantCtx := orchestration.WithClient(orchestration.WithBucket(context.Background(), "acme-www"), "")
+	result, err := manager.EncryptDataWithContentType(tenantCtx, bufio.NewReader(strings.NewReader("tenant page")), "index.html", factory.ContentTypeWhole)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+
+	logger := logrus.WithField("component", "test-proxy-server")
+	server := &Server{
+		config:        cfg,
+		logger:        logger,
+		bucketPolicy:  middleware.NewBucketPolicy(cfg, logger),
+		s3AuthService: middleware.NewS3AuthenticationService(cfg, logger.Logger),
+	}
+
+	// The object handler adds the bucket and the (empty) access key of the request
+	var decryptErr error
+	handler := server.s3AuthMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx := orchestration.WithClient(orchestration.WithBucket(r.Context(), "acme-www"), "")
+		_, decryptErr = manager.DecryptDataWithMetadata(ctx, bytes.NewReader(ciphertext), result.Metadata, "index.html")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/acme-www/index.html", nil)
+	req = mux.SetURLVars(req, map[string]string{"bucket": "acme-www", "key": "index.html"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.ErrorIs(t, decryptErr, orchestration.ErrTenantKeyDenied)
+}
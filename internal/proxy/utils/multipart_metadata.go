@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// CompleteMultipartMetadataOptions returns S3 client options that send the
+// metadata as x-amz-meta-* headers on CompleteMultipartUpload. It returns nil
+// unless the profile attaches metadata in the complete phase.
+func CompleteMultipartMetadataOptions(profile config.BackendProfile, metadata map[string]string) []func(*s3.Options) {
+	if profile.MultipartMetadataPhase != config.MultipartMetadataPhaseComplete || len(metadata) == 0 {
+		return nil
+	}
+
+	return []func(*s3.Options){
+		func(o *s3.Options) {
+			for k, v := range metadata {
+				o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue("x-amz-meta-"+k, v))
+			}
+		},
+	}
+}
+
+// EnsureMultipartObjectMetadata makes sure the metadata of a just-completed
+// multipart object is present on the backend. Without it an encrypted object
+// can never be decrypted again, so every phase ends in a verified state:
+//
+//   - complete phase: the metadata was sent with CompleteMultipartUpload; if the
+//     backend dropped it, it is re-applied via a CopyObject self-copy.
+//   - copy phase: the metadata is applied via a CopyObject self-copy.
+//
+// When the profile requests verification, the object is read back with
+// HeadObject after the copy and an error is returned if metadata is missing.
+// opts adjust the self-copy and are only evaluated if it is made.
+func EnsureMultipartObjectMetadata(ctx context.Context, backend interfaces.S3BackendInterface, profile config.BackendProfile, bucket, key string, metadata map[string]string, logger logrus.FieldLogger, opts ...CopyOption) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	log := logger.WithFields(logrus.Fields{
+		"bucket":  bucket,
+		"key":     key,
+		"profile": profile.Name,
+		"phase":   profile.MultipartMetadataPhase,
+	})
+
+	if profile.MultipartMetadataPhase == config.MultipartMetadataPhaseComplete {
+		missing, err := missingObjectMetadata(ctx, backend, bucket, key, metadata)
+		if err == nil && len(missing) == 0 {
+			log.Debug("Encryption metadata attached with CompleteMultipartUpload")
+			return nil
+		}
+		log.WithFields(logrus.Fields{
+			"missing": missing,
+			"error":   err,
+		}).Warn("Backend did not keep metadata sent with CompleteMultipartUpload, re-applying via CopyObject")
+	}
+
+	copyInput := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(bucket + "/" + url.PathEscape(key)),
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	}
+	for _, opt := range opts {
+		if err := opt(ctx, copyInput); err != nil {
+			return fmt.Errorf("upload completed but encryption metadata could not be applied: %w", err)
+		}
+	}
+	if _, err := backend.CopyObject(ctx, copyInput); err != nil {
+		return fmt.Errorf("upload completed but encryption metadata could not be applied: %w", err)
+	}
+
+	if !profile.VerifyMultipartMetadata {
+		return nil
+	}
+
+	missing, err := missingObjectMetadata(ctx, backend, bucket, key, metadata)
+	if err != nil {
+		return fmt.Errorf("upload completed but encryption metadata could not be verified: %w", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("upload completed but backend dropped encryption metadata: missing %s", strings.Join(missing, ", "))
+	}
+
+	log.Debug("Encryption metadata verified on completed object")
+	return nil
+}
+
+// CopyOption adjusts the self-copy of EnsureMultipartObjectMetadata
+type CopyOption func(ctx context.Context, input *s3.CopyObjectInput) error
+
+// WithObjectLock gives the self-copy the object lock of the upload. The copy
+// is a new version of the object, which would otherwise only get the
+// bucket's default retention.
+func WithObjectLock(lock request.ObjectLock) CopyOption {
+	return func(_ context.Context, input *s3.CopyObjectInput) error {
+		lock.Apply(&input.ObjectLockMode, &input.ObjectLockRetainUntilDate, &input.ObjectLockLegalHoldStatus)
+		return nil
+	}
+}
+
+// WithStoredObjectLock gives the self-copy the object lock of the completed
+// object, for uploads whose settings were sent with CreateMultipartUpload
+func WithStoredObjectLock(backend interfaces.S3BackendInterface, bucket, key string) CopyOption {
+	return func(ctx context.Context, input *s3.CopyObjectInput) error {
+		head, err := backend.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		lock := request.ObjectLock{
+			Mode:            head.ObjectLockMode,
+			RetainUntilDate: head.ObjectLockRetainUntilDate,
+			LegalHold:       head.ObjectLockLegalHoldStatus,
+		}
+		return WithObjectLock(lock)(ctx, input)
+	}
+}
+
+// missingObjectMetadata returns the metadata keys whose value is absent or
+// different on the stored object (keys are compared case-insensitively)
+func missingObjectMetadata(ctx context.Context, backend interfaces.S3BackendInterface, bucket, key string, metadata map[string]string) ([]string, error) {
+	head, err := backend.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]string, len(head.Metadata))
+	for k, v := range head.Metadata {
+		stored[strings.ToLower(k)] = v
+	}
+
+	var missing []string
+	for k, v := range metadata {
+		if stored[strings.ToLower(k)] != v {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
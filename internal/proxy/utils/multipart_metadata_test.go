@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/request"
+)
+
+// metadataBackend simulates a backend that may or may not keep object metadata
+type metadataBackend struct {
+	interfaces.S3BackendInterface
+
+	stored      map[string]string
+	dropOnCopy  bool
+	copyCalls   int
+	headCalls   int
+	copiedInput *s3.CopyObjectInput
+}
+
+func (b *metadataBackend) HeadObject(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	b.headCalls++
+	return &s3.HeadObjectOutput{Metadata: b.stored}, nil
+}
+
+func (b *metadataBackend) CopyObject(_ context.Context, params *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.copyCalls++
+	b.copiedInput = params
+	if !b.dropOnCopy {
+		b.stored = params.Metadata
+	}
+	return &s3.CopyObjectOutput{}, nil
+}
+
+var testEncryptionMetadata = map[string]string{
+	"s3ep-encrypted-dek": "ZGVr",
+	"s3ep-dek-algorithm": "aes-ctr",
+}
+
+func TestEnsureMultipartObjectMetadata_CopyWithoutVerify(t *testing.T) {
+	backend := &metadataBackend{}
+	profile := config.BackendProfile{Name: "aws", MultipartMetadataPhase: config.MultipartMetadataPhaseCopy}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "key", testEncryptionMetadata, logrus.New())
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.copyCalls)
+	assert.Equal(t, 0, backend.headCalls)
+	assert.Equal(t, "bucket/key", *backend.copiedInput.CopySource)
+}
+
+func TestEnsureMultipartObjectMetadata_EscapesCopySource(t *testing.T) {
+	backend := &metadataBackend{}
+	profile := config.BackendProfile{Name: "generic", MultipartMetadataPhase: config.MultipartMetadataPhaseCopy}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "dir/a b+c?d#e%f", testEncryptionMetadata, logrus.New())
+	require.NoError(t, err)
+	assert.Equal(t, "bucket/dir%2Fa%20b+c%3Fd%23e%25f", *backend.copiedInput.CopySource)
+}
+
+func TestEnsureMultipartObjectMetadata_VerifyDetectsDroppedMetadata(t *testing.T) {
+	backend := &metadataBackend{dropOnCopy: true}
+	profile := config.BackendProfile{Name: "generic", MultipartMetadataPhase: config.MultipartMetadataPhaseCopy, VerifyMultipartMetadata: true}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "key", testEncryptionMetadata, logrus.New())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "s3ep-dek-algorithm, s3ep-encrypted-dek")
+}
+
+func TestEnsureMultipartObjectMetadata_CompletePhaseKept(t *testing.T) {
+	// Backends return metadata keys in their own casing
+	backend := &metadataBackend{stored: map[string]string{"S3ep-Encrypted-Dek": "ZGVr", "s3ep-dek-algorithm": "aes-ctr"}}
+	profile := config.BackendProfile{MultipartMetadataPhase: config.MultipartMetadataPhaseComplete, VerifyMultipartMetadata: true}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "key", testEncryptionMetadata, logrus.New())
+	require.NoError(t, err)
+	assert.Equal(t, 0, backend.copyCalls, "metadata already present, no self-copy needed")
+}
+
+func TestEnsureMultipartObjectMetadata_CompletePhaseDroppedFallsBackToCopy(t *testing.T) {
+	backend := &metadataBackend{stored: map[string]string{}}
+	profile := config.BackendProfile{MultipartMetadataPhase: config.MultipartMetadataPhaseComplete, VerifyMultipartMetadata: true}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "key", testEncryptionMetadata, logrus.New())
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.copyCalls)
+	assert.Equal(t, 2, backend.headCalls)
+	assert.Equal(t, testEncryptionMetadata, backend.stored)
+}
+
+func TestEnsureMultipartObjectMetadata_NoMetadata(t *testing.T) {
+	backend := &metadataBackend{}
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, config.BackendProfile{}, "bucket", "key", nil, logrus.New())
+	require.NoError(t, err)
+	assert.Equal(t, 0, backend.copyCalls)
+}
+
+func TestCompleteMultipartMetadataOptions(t *testing.T) {
+	copyProfile := config.BackendProfile{MultipartMetadataPhase: config.MultipartMetadataPhaseCopy}
+	assert.Nil(t, CompleteMultipartMetadataOptions(copyProfile, testEncryptionMetadata))
+
+	completeProfile := config.BackendProfile{MultipartMetadataPhase: config.MultipartMetadataPhaseComplete}
+	assert.Nil(t, CompleteMultipartMetadataOptions(completeProfile, nil))
+
+	opts := CompleteMultipartMetadataOptions(completeProfile, testEncryptionMetadata)
+	require.Len(t, opts, 1)
+	o := &s3.Options{}
+	opts[0](o)
+	assert.Len(t, o.APIOptions, len(testEncryptionMetadata))
+}
+
+func TestEnsureMultipartObjectMetadata_KeepsObjectLock(t *testing.T) {
+	backend := &metadataBackend{}
+	profile := config.BackendProfile{Name: "aws", MultipartMetadataPhase: config.MultipartMetadataPhaseCopy}
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	lock := request.ObjectLock{Mode: types.ObjectLockModeGovernance, RetainUntilDate: &until, LegalHold: types.ObjectLockLegalHoldStatusOn}
+
+	err := EnsureMultipartObjectMetadata(context.Background(), backend, profile, "bucket", "key", testEncryptionMetadata, logrus.New(), WithObjectLock(lock))
+	require.NoError(t, err)
+	assert.Equal(t, types.ObjectLockModeGovernance, backend.copiedInput.ObjectLockMode)
+	assert.Equal(t, &until, backend.copiedInput.ObjectLockRetainUntilDate)
+	assert.Equal(t, types.ObjectLockLegalHoldStatusOn, backend.copiedInput.ObjectLockLegalHoldStatus)
+}
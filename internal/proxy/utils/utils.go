@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,7 +10,11 @@ import (
 
 	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/response"
 )
 
 // GetQueryParam safely retrieves a query parameter value
@@ -34,21 +38,15 @@ func ParseMaxKeys(maxKeysStr string) *int32 {
 	return nil
 }
 
-// S3ErrorResponse represents an S3 error response
-type S3ErrorResponse struct {
-	XMLName   xml.Name `xml:"Error"`
-	Code      string   `xml:"Code"`
-	Message   string   `xml:"Message"`
-	Resource  string   `xml:"Resource,omitempty"`
-	RequestID string   `xml:"RequestId,omitempty"`
-}
-
 // HandleS3Error handles S3 errors with proper logging and response formatting
 func HandleS3Error(w http.ResponseWriter, logger logrus.FieldLogger, err error, message, bucket, key string) {
 	// Handle nil error case
 	if err == nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		logger.Error("HandleS3Error called with nil error")
+		_ = response.WriteError(w, http.StatusInternalServerError, response.Error{
+			Code:    "InternalError",
+			Message: "We encountered an internal error. Please try again.",
+		})
 		return
 	}
 
@@ -57,12 +55,9 @@ func HandleS3Error(w http.ResponseWriter, logger logrus.FieldLogger, err error,
 	var errorMessage string
 
 	// Build resource string
-	resource := ""
-	if bucket != "" {
-		resource = bucket
-		if key != "" {
-			resource = fmt.Sprintf("%s/%s", bucket, key)
-		}
+	resource := "/" + bucket
+	if key != "" {
+		resource = fmt.Sprintf("/%s/%s", bucket, key)
 	}
 
 	// Handle different error types
@@ -153,41 +148,34 @@ func HandleS3Error(w http.ResponseWriter, logger logrus.FieldLogger, err error,
 
 	logger.WithFields(logFields).Error("S3 operation failed")
 
-	// Create error response
-	errorResponse := S3ErrorResponse{
+	if err := response.WriteError(w, statusCode, response.Error{
 		Code:     errorCode,
 		Message:  errorMessage,
 		Resource: resource,
+	}); err != nil {
+		logger.WithError(err).Error("Failed to write error response")
 	}
+}
 
-	// Marshal XML response
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(statusCode)
-
-	xmlData, xmlErr := xml.Marshal(errorResponse)
-	if xmlErr != nil {
-		logger.WithError(xmlErr).Error("Failed to marshal error response")
-		// Fallback to simple error
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>InternalError</Code>
-    <Message>Internal Server Error</Message>
-</Error>`)
-		return
+// IsBackendUnsupported reports whether err means the backend does not
+// implement the requested operation (as opposed to e.g. a missing bucket)
+func IsBackendUnsupported(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotImplemented", "XNotImplemented", "MethodNotAllowed", "UnsupportedOperation":
+			return true
+		}
 	}
 
-	// Write XML header and response
-	if _, err := w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>`)); err != nil {
-		// If we can't write the header, log the error but continue
-		// This is a non-recoverable situation at the HTTP level
-		return
-	}
-	if _, err := w.Write(xmlData); err != nil {
-		// If we can't write the body, log the error but there's nothing more we can do
-		// at this point in the HTTP response lifecycle
-		return
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotImplemented, http.StatusMethodNotAllowed:
+			return true
+		}
 	}
+	return false
 }
 
 // WriteNotImplementedResponse writes a standard "not implemented" response
@@ -197,15 +185,11 @@ func WriteNotImplementedResponse(w http.ResponseWriter, logger logrus.FieldLogge
 
 	logger.WithField("operation", operation).Warn("Not implemented operation called")
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusNotImplemented)
-	response := `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>NotImplemented</Code>
-    <Message>` + operation + ` operation is not yet implemented</Message>
-    <Resource>` + operation + `</Resource>
-</Error>`
-	if _, err := w.Write([]byte(response)); err != nil {
+	if err := response.WriteError(w, http.StatusNotImplemented, response.Error{
+		Code:     "NotImplemented",
+		Message:  operation + " operation is not yet implemented",
+		Resource: operation,
+	}); err != nil {
 		logger.WithError(err).Error("Failed to write not implemented response")
 	}
 }
@@ -252,16 +236,12 @@ func WriteDetailedNotImplementedResponse(w http.ResponseWriter, logger logrus.Fi
 		"url":           r.URL.String(),
 	}).Warn("Detailed not implemented operation called")
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusNotImplemented)
-	response := `<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>NotImplemented</Code>
-    <Message>` + message + `</Message>
-    <Resource>` + resourcePath + `</Resource>
-    <RequestURL>` + r.URL.String() + `</RequestURL>
-</Error>`
-	if _, err := w.Write([]byte(response)); err != nil {
+	if err := response.WriteError(w, http.StatusNotImplemented, response.Error{
+		Code:       "NotImplemented",
+		Message:    message,
+		Resource:   resourcePath,
+		RequestURL: r.URL.String(),
+	}); err != nil {
 		logger.WithError(err).Error("Failed to write detailed not implemented response")
 	}
 }
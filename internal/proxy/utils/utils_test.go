@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -133,6 +135,14 @@ func TestParseMaxKeys(t *testing.T) {
 	}
 }
 
+func TestIsBackendUnsupported(t *testing.T) {
+	assert.True(t, IsBackendUnsupported(&smithy.GenericAPIError{Code: "NotImplemented"}))
+	assert.True(t, IsBackendUnsupported(&smithy.GenericAPIError{Code: "MethodNotAllowed"}))
+	assert.False(t, IsBackendUnsupported(&types.NoSuchBucket{}))
+	assert.False(t, IsBackendUnsupported(errors.New("connection refused")))
+	assert.False(t, IsBackendUnsupported(nil))
+}
+
 func TestWriteNotImplementedResponse(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.DebugLevel)
@@ -165,7 +175,7 @@ func TestWriteDetailedNotImplementedResponse(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "NotImplemented")
 	assert.Contains(t, w.Body.String(), operation)
 	assert.Contains(t, w.Body.String(), "GET")
-	assert.Contains(t, w.Body.String(), req.URL.String())
+	assert.Contains(t, w.Body.String(), "<RequestURL>/bucket/key?acl&amp;versioning</RequestURL>")
 }
 
 func TestHandleS3Error_Basic(t *testing.T) {
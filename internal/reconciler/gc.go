@@ -0,0 +1,203 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+// Actions of a garbage collection besides ActionAborted, ActionDiscarded and
+// ActionFailed
+const (
+	// ActionWouldAbort means a dry run found the upload old enough to abort
+	ActionWouldAbort = "would_abort"
+	// ActionWouldDiscard means a dry run found the session old enough to delete
+	ActionWouldDiscard = "would_discard"
+)
+
+// GCOptions selects what a garbage collection removes
+type GCOptions struct {
+	OlderThan      time.Duration // Uploads and sessions created since are left alone
+	IncludeOrphans bool          // Also abort backend uploads without session, which may not be the proxy's
+	DryRun         bool          // Only report what would be removed
+}
+
+// GCUpload is an incomplete upload or a session removed by a garbage collection
+type GCUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+	Session   bool      `json:"session"` // The proxy had an encryption session for it
+	Parts     int       `json:"parts"`   // Parts stored on the backend
+	Bytes     int64     `json:"bytes"`   // Bytes of the parts
+	Action    string    `json:"action"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// GCReport is the result of a garbage collection
+type GCReport struct {
+	DryRun            bool       `json:"dry_run"`
+	Cutoff            time.Time  `json:"cutoff"`
+	Buckets           int        `json:"buckets"`
+	UploadsAborted    int        `json:"uploads_aborted"`
+	SessionsDiscarded int        `json:"sessions_discarded"`
+	PartsReclaimed    int        `json:"parts_reclaimed"`
+	BytesReclaimed    int64      `json:"bytes_reclaimed"`
+	Failed            int        `json:"failed"`
+	Uploads           []GCUpload `json:"uploads"`
+}
+
+// CollectGarbage aborts the incomplete multipart uploads initiated through
+// the proxy before opts.OlderThan, deletes their sessions, and deletes old
+// sessions whose upload no longer exists. The parts of aborted uploads are
+// counted before, as the backend releases them with the upload. Sessions are
+// only judged in buckets whose uploads could be listed.
+func (r *Reconciler) CollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	states, err := r.sessions.ListMultipartSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list multipart sessions: %w", err)
+	}
+	sessions := make(map[string]*orchestration.SessionState, len(states))
+	for _, state := range states {
+		sessions[state.UploadID] = state
+	}
+
+	buckets, err := r.bucketNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{DryRun: opts.DryRun, Cutoff: r.now().Add(-opts.OlderThan), Uploads: []GCUpload{}}
+	listed := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		type candidate struct {
+			key, uploadID string
+			initiated     time.Time
+			session       bool
+		}
+		// Uploads are aborted after the listing, which they would shift
+		var candidates []candidate
+		err := r.listUploads(ctx, bucket, func(key, uploadID string, initiated time.Time) {
+			_, session := sessions[uploadID]
+			delete(sessions, uploadID)
+			if initiated.After(report.Cutoff) || (!session && !opts.IncludeOrphans) {
+				return
+			}
+			candidates = append(candidates, candidate{key: key, uploadID: uploadID, initiated: initiated, session: session})
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			r.logger.WithError(err).WithField("bucket", bucket).Warn("Failed to list multipart uploads for garbage collection")
+			continue
+		}
+		listed[bucket] = true
+
+		for _, c := range candidates {
+			report.add(r.collectUpload(ctx, bucket, c.key, c.uploadID, c.initiated, c.session, opts.DryRun))
+		}
+	}
+	report.Buckets = len(listed)
+
+	for _, state := range sessions {
+		if !listed[state.BucketName] || state.CreatedAt.After(report.Cutoff) {
+			continue
+		}
+		upload := GCUpload{Bucket: state.BucketName, Key: state.ObjectKey, UploadID: state.UploadID, Initiated: state.CreatedAt, Session: true, Action: ActionWouldDiscard}
+		if !opts.DryRun {
+			upload.Action = ActionDiscarded
+			if err := r.sessions.DiscardMultipartSession(ctx, state.UploadID); err != nil {
+				upload.Action, upload.Error = ActionFailed, err.Error()
+			}
+		}
+		report.add(upload)
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"dry_run":            report.DryRun,
+		"buckets":            report.Buckets,
+		"uploads_aborted":    report.UploadsAborted,
+		"sessions_discarded": report.SessionsDiscarded,
+		"parts_reclaimed":    report.PartsReclaimed,
+		"bytes_reclaimed":    report.BytesReclaimed,
+		"failed":             report.Failed,
+	}).Info("Multipart garbage collection finished")
+	return report, nil
+}
+
+// collectUpload counts the parts of an upload, aborts it and deletes its session
+func (r *Reconciler) collectUpload(ctx context.Context, bucket, key, uploadID string, initiated time.Time, session, dryRun bool) GCUpload {
+	upload := GCUpload{Bucket: bucket, Key: key, UploadID: uploadID, Initiated: initiated, Session: session, Action: ActionWouldAbort}
+
+	var err error
+	upload.Parts, upload.Bytes, err = r.countParts(ctx, bucket, key, uploadID)
+	if err == nil && !dryRun {
+		upload.Action = ActionAborted
+		err = r.abort(ctx, bucket, key, uploadID)
+		if err == nil && session {
+			if discardErr := r.sessions.DiscardMultipartSession(ctx, uploadID); discardErr != nil {
+				err = fmt.Errorf("upload aborted, but failed to delete its session: %w", discardErr)
+			}
+		}
+	}
+	if err != nil {
+		upload.Action, upload.Error = ActionFailed, err.Error()
+		r.logger.WithError(err).WithFields(logrus.Fields{
+			"bucket":    bucket,
+			"key":       key,
+			"upload_id": uploadID,
+		}).Warn("Failed to collect incomplete multipart upload")
+	}
+	return upload
+}
+
+// countParts returns the number and total size of the parts of an upload
+func (r *Reconciler) countParts(ctx context.Context, bucket, key, uploadID string) (int, int64, error) {
+	input := &s3.ListPartsInput{Bucket: aws.String(bucket), Key: aws.String(key), UploadId: aws.String(uploadID)}
+	var parts int
+	var bytes int64
+	for {
+		output, err := r.backend.ListParts(ctx, input)
+		if err != nil {
+			return parts, bytes, fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, part := range output.Parts {
+			parts++
+			bytes += aws.ToInt64(part.Size)
+		}
+		if !aws.ToBool(output.IsTruncated) {
+			return parts, bytes, nil
+		}
+		if aws.ToString(output.NextPartNumberMarker) == "" {
+			return parts, bytes, errors.New("failed to list parts: truncated listing without next marker")
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+}
+
+// add counts an upload or session into the report
+func (g *GCReport) add(upload GCUpload) {
+	switch upload.Action {
+	case ActionAborted, ActionWouldAbort:
+		g.UploadsAborted++
+		g.PartsReclaimed += upload.Parts
+		g.BytesReclaimed += upload.Bytes
+		if upload.Session {
+			g.SessionsDiscarded++
+		}
+	case ActionDiscarded, ActionWouldDiscard:
+		g.SessionsDiscarded++
+	case ActionFailed:
+		g.Failed++
+	}
+	g.Uploads = append(g.Uploads, upload)
+}
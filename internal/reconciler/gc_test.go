@@ -0,0 +1,77 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectGarbage(t *testing.T, opts GCOptions, backend *fakeBackend, sessions *fakeSessions) (*GCReport, map[string]string) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	r := New(backend, sessions, Config{}, logrus.NewEntry(logger))
+	r.now = func() time.Time { return now }
+
+	report, err := r.CollectGarbage(context.Background(), opts)
+	require.NoError(t, err)
+	actions := make(map[string]string)
+	for _, upload := range report.Uploads {
+		actions[upload.UploadID] = upload.Action
+	}
+	return report, actions
+}
+
+func TestCollectGarbage(t *testing.T) {
+	backend, sessions := fixture()
+	report, actions := collectGarbage(t, GCOptions{OlderThan: time.Hour}, backend, sessions)
+
+	// Uploads without session are not the proxy's, the locked bucket is not listable
+	assert.Equal(t, map[string]string{
+		"u-matched": ActionAborted,
+		"s-gone":    ActionDiscarded,
+	}, actions)
+	assert.Equal(t, []string{"u-matched"}, backend.aborted)
+	assert.ElementsMatch(t, []string{"u-matched", "s-gone"}, sessions.discarded)
+	assert.Equal(t, 1, report.Buckets)
+	assert.Equal(t, 1, report.UploadsAborted)
+	assert.Equal(t, 2, report.SessionsDiscarded)
+	assert.Equal(t, 2, report.PartsReclaimed)
+	assert.Equal(t, int64(2*partSize), report.BytesReclaimed)
+	assert.Zero(t, report.Failed)
+}
+
+func TestCollectGarbage_IncludeOrphans(t *testing.T) {
+	backend, sessions := fixture()
+	report, actions := collectGarbage(t, GCOptions{OlderThan: time.Hour, IncludeOrphans: true}, backend, sessions)
+
+	assert.Equal(t, map[string]string{
+		"u-matched": ActionAborted,
+		"u-parts":   ActionAborted,
+		"u-empty":   ActionAborted,
+		"s-gone":    ActionDiscarded,
+	}, actions)
+	assert.ElementsMatch(t, []string{"u-matched", "u-parts", "u-empty"}, backend.aborted)
+	assert.Equal(t, 3, report.UploadsAborted)
+	assert.Equal(t, 5, report.PartsReclaimed)
+}
+
+func TestCollectGarbage_DryRun(t *testing.T) {
+	backend, sessions := fixture()
+	report, actions := collectGarbage(t, GCOptions{OlderThan: time.Minute / 2, DryRun: true}, backend, sessions)
+
+	assert.Equal(t, map[string]string{
+		"u-matched": ActionWouldAbort,
+		"s-gone":    ActionWouldDiscard,
+		"s-young":   ActionWouldDiscard,
+	}, actions)
+	assert.Empty(t, backend.aborted)
+	assert.Empty(t, sessions.discarded)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.PartsReclaimed)
+	assert.Equal(t, 3, report.SessionsDiscarded)
+}
@@ -0,0 +1,96 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the garbage collection endpoint is mounted on the
+// monitoring server
+const BasePath = "/admin/multipart-gc"
+
+// maxRequestBodySize bounds the JSON body of a collection request
+const maxRequestBodySize = 64 * 1024
+
+// GCRequest is the body of a collection request
+type GCRequest struct {
+	OlderThan      string `json:"older_than"` // Go duration, e.g. "24h"
+	DryRun         bool   `json:"dry_run"`
+	IncludeOrphans bool   `json:"include_orphans"`
+}
+
+// Handler exposes the multipart garbage collection over HTTP:
+//
+//	POST /admin/multipart-gc  collect ({"older_than","dry_run","include_orphans"}) and return the report
+//
+// One collection runs at a time; requests meanwhile get 409 Conflict.
+type Handler struct {
+	reconciler *Reconciler
+	logger     *logrus.Entry
+	mux        *http.ServeMux
+	running    sync.Mutex
+}
+
+// NewHandler creates a new garbage collection HTTP handler
+func NewHandler(reconciler *Reconciler, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		reconciler: reconciler,
+		logger:     logger,
+		mux:        http.NewServeMux(),
+	}
+	h.mux.HandleFunc("POST "+BasePath, h.handleCollect)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleCollect(w http.ResponseWriter, r *http.Request) {
+	req := GCRequest{OlderThan: "24h"}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	olderThan, err := time.ParseDuration(req.OlderThan)
+	if err != nil || olderThan < 0 {
+		h.writeError(w, http.StatusBadRequest, "older_than must be a non-negative duration like 24h")
+		return
+	}
+
+	if !h.running.TryLock() {
+		h.writeError(w, http.StatusConflict, "a multipart garbage collection is already running")
+		return
+	}
+	defer h.running.Unlock()
+
+	report, err := h.reconciler.CollectGarbage(r.Context(), GCOptions{
+		OlderThan:      olderThan,
+		IncludeOrphans: req.IncludeOrphans,
+		DryRun:         req.DryRun,
+	})
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write multipart garbage collection response")
+	}
+}
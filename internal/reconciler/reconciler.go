@@ -0,0 +1,335 @@
+// Package reconciler implements a background job that matches the multipart
+// uploads of the backend against the proxy's multipart session store.
+//
+// The two drift apart: with the in-memory store every session is lost on
+// restart, expired sessions are removed without aborting their upload, and
+// uploads completed or aborted directly on the backend leave their session
+// behind. A backend upload without session can never be completed into a
+// decryptable object, since its DEK is gone; a session without upload is
+// never cleaned up by a client. The reconciler finds both kinds of orphans and
+// keeps, aborts or adopts them according to its policy.
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+const defaultInterval = time.Hour
+
+// Kinds of orphans, as reported in s3ep_multipart_orphans_total
+const (
+	// KindUpload is a backend upload without encryption session
+	KindUpload = "upload"
+	// KindSession is an encryption session without backend upload
+	KindSession = "session"
+)
+
+// Actions taken on orphans, as reported in s3ep_multipart_orphans_total
+const (
+	// ActionKept means the orphan was only reported (policy keep)
+	ActionKept = "kept"
+	// ActionAborted means the backend upload was aborted
+	ActionAborted = "aborted"
+	// ActionAdopted means the backend upload was given a new session
+	ActionAdopted = "adopted"
+	// ActionDiscarded means the session was deleted from the store
+	ActionDiscarded = "discarded"
+	// ActionFailed means aborting, adopting or discarding failed
+	ActionFailed = "failed"
+)
+
+// Backend is the subset of the S3 client used by the reconciler
+type Backend interface {
+	ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+	ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// Sessions is the session store as seen through the encryption manager
+type Sessions interface {
+	ListMultipartSessions(ctx context.Context) ([]*orchestration.SessionState, error)
+	DiscardMultipartSession(ctx context.Context, uploadID string) error
+	InitiateMultipartUpload(ctx context.Context, uploadID, objectKey, bucketName string) error
+}
+
+// Config holds reconciler configuration
+type Config struct {
+	Buckets  []string      // Buckets to reconcile; empty reconciles every bucket of the backend
+	Interval time.Duration // Time between two runs (default: 1h)
+	MinAge   time.Duration // Younger uploads and sessions are left alone
+	Policy   string        // One of the config.ReconcilePolicy* values (default: keep)
+}
+
+// Orphan is an upload or session found without its counterpart
+type Orphan struct {
+	Kind     string    `json:"kind"`
+	Bucket   string    `json:"bucket"`
+	Key      string    `json:"key"`
+	UploadID string    `json:"upload_id"`
+	Created  time.Time `json:"created"`
+	Action   string    `json:"action"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Reconciler matches backend uploads and sessions. It is not safe for
+// concurrent use; Run is its only caller in the proxy.
+type Reconciler struct {
+	backend  Backend
+	sessions Sessions
+	cfg      Config
+	logger   *logrus.Entry
+	now      func() time.Time
+}
+
+// New creates a reconciler
+func New(backend Backend, sessions Sessions, cfg Config, logger *logrus.Entry) *Reconciler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = config.ReconcilePolicyKeep
+	}
+	return &Reconciler{
+		backend:  backend,
+		sessions: sessions,
+		cfg:      cfg,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Run reconciles once per interval until ctx is cancelled, starting
+// immediately
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			r.logger.WithError(err).Warn("Multipart reconciliation failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile lists the uploads of every bucket and the stored sessions once
+// and applies the policy to the orphans. Sessions are only judged in buckets
+// whose uploads could be listed.
+func (r *Reconciler) Reconcile(ctx context.Context) ([]Orphan, error) {
+	// Sessions are listed first: an upload created after this point is not
+	// matched, but too young to count as orphaned
+	states, err := r.sessions.ListMultipartSessions(ctx)
+	if err != nil {
+		monitoring.RecordMultipartReconcileError()
+		return nil, fmt.Errorf("failed to list multipart sessions: %w", err)
+	}
+	unmatched := make(map[string]*orchestration.SessionState, len(states))
+	for _, state := range states {
+		unmatched[state.UploadID] = state
+	}
+
+	buckets, err := r.bucketNames(ctx)
+	if err != nil {
+		monitoring.RecordMultipartReconcileError()
+		return nil, err
+	}
+
+	cutoff := r.now().Add(-r.cfg.MinAge)
+	var orphans []Orphan
+	listed := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		err := r.listUploads(ctx, bucket, func(key, uploadID string, initiated time.Time) {
+			if _, ok := unmatched[uploadID]; ok {
+				delete(unmatched, uploadID)
+				return
+			}
+			if initiated.After(cutoff) {
+				return
+			}
+			orphans = append(orphans, r.orphanedUpload(ctx, bucket, key, uploadID, initiated))
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return orphans, ctx.Err()
+			}
+			monitoring.RecordMultipartReconcileError()
+			r.logger.WithError(err).WithField("bucket", bucket).Warn("Failed to list multipart uploads for reconciliation")
+			continue
+		}
+		listed[bucket] = true
+	}
+
+	for _, state := range unmatched {
+		if !listed[state.BucketName] || state.CreatedAt.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, r.orphanedSession(ctx, state))
+	}
+
+	counts := make(map[string]int)
+	for _, orphan := range orphans {
+		counts[orphan.Action]++
+	}
+	r.logger.WithFields(logrus.Fields{
+		"buckets":   len(listed),
+		"sessions":  len(states),
+		"orphans":   len(orphans),
+		"kept":      counts[ActionKept],
+		"aborted":   counts[ActionAborted],
+		"adopted":   counts[ActionAdopted],
+		"discarded": counts[ActionDiscarded],
+		"failed":    counts[ActionFailed],
+	}).Info("Multipart reconciliation finished")
+	return orphans, nil
+}
+
+// bucketNames returns the configured buckets, or all backend buckets
+func (r *Reconciler) bucketNames(ctx context.Context) ([]string, error) {
+	if len(r.cfg.Buckets) > 0 {
+		return r.cfg.Buckets, nil
+	}
+
+	output, err := r.backend.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	names := make([]string, 0, len(output.Buckets))
+	for _, bucket := range output.Buckets {
+		names = append(names, aws.ToString(bucket.Name))
+	}
+	return names, nil
+}
+
+// listUploads calls fn for every multipart upload in progress in bucket
+func (r *Reconciler) listUploads(ctx context.Context, bucket string, fn func(key, uploadID string, initiated time.Time)) error {
+	input := &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)}
+	for {
+		output, err := r.backend.ListMultipartUploads(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, upload := range output.Uploads {
+			fn(aws.ToString(upload.Key), aws.ToString(upload.UploadId), aws.ToTime(upload.Initiated))
+		}
+		if !aws.ToBool(output.IsTruncated) {
+			return nil
+		}
+		if aws.ToString(output.NextKeyMarker) == "" && aws.ToString(output.NextUploadIdMarker) == "" {
+			return errors.New("truncated listing without next marker")
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.UploadIdMarker = output.NextUploadIdMarker
+	}
+}
+
+// orphanedUpload applies the policy to a backend upload without session
+func (r *Reconciler) orphanedUpload(ctx context.Context, bucket, key, uploadID string, initiated time.Time) Orphan {
+	orphan := Orphan{Kind: KindUpload, Bucket: bucket, Key: key, UploadID: uploadID, Created: initiated}
+
+	var err error
+	switch r.cfg.Policy {
+	case config.ReconcilePolicyKeep:
+		orphan.Action = ActionKept
+	case config.ReconcilePolicyAdopt:
+		var adopted bool
+		if adopted, err = r.adopt(ctx, bucket, key, uploadID); err == nil && adopted {
+			orphan.Action = ActionAdopted
+			break
+		}
+		if err == nil {
+			err = r.abort(ctx, bucket, key, uploadID)
+			orphan.Action = ActionAborted
+		}
+	default:
+		err = r.abort(ctx, bucket, key, uploadID)
+		orphan.Action = ActionAborted
+	}
+	return r.finish(orphan, err)
+}
+
+// adopt gives an upload without parts a new session. Uploads with parts are
+// not adopted: their parts were encrypted with a DEK that is lost.
+func (r *Reconciler) adopt(ctx context.Context, bucket, key, uploadID string) (bool, error) {
+	parts, err := r.backend.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MaxParts: aws.Int32(1),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list parts: %w", err)
+	}
+	if len(parts.Parts) > 0 {
+		return false, nil
+	}
+	if err := r.sessions.InitiateMultipartUpload(ctx, uploadID, key, bucket); err != nil {
+		return false, fmt.Errorf("failed to create session: %w", err)
+	}
+	return true, nil
+}
+
+func (r *Reconciler) abort(ctx context.Context, bucket, key, uploadID string) error {
+	_, err := r.backend.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort upload: %w", err)
+	}
+	return nil
+}
+
+// orphanedSession applies the policy to a session without backend upload
+func (r *Reconciler) orphanedSession(ctx context.Context, state *orchestration.SessionState) Orphan {
+	orphan := Orphan{Kind: KindSession, Bucket: state.BucketName, Key: state.ObjectKey, UploadID: state.UploadID, Created: state.CreatedAt}
+	if r.cfg.Policy == config.ReconcilePolicyKeep {
+		orphan.Action = ActionKept
+		return r.finish(orphan, nil)
+	}
+	orphan.Action = ActionDiscarded
+	return r.finish(orphan, r.sessions.DiscardMultipartSession(ctx, state.UploadID))
+}
+
+// finish records the outcome of an orphan
+func (r *Reconciler) finish(orphan Orphan, err error) Orphan {
+	if err != nil {
+		orphan.Action = ActionFailed
+		orphan.Error = err.Error()
+	}
+	monitoring.RecordMultipartOrphan(orphan.Kind, orphan.Action)
+
+	entry := r.logger.WithFields(logrus.Fields{
+		"kind":      orphan.Kind,
+		"bucket":    orphan.Bucket,
+		"key":       orphan.Key,
+		"upload_id": orphan.UploadID,
+		"created":   orphan.Created,
+		"action":    orphan.Action,
+	})
+	switch {
+	case err != nil:
+		entry.WithError(err).Warn("Failed to reconcile orphaned multipart upload")
+	case orphan.Action == ActionKept:
+		entry.Warn("Found orphaned multipart upload")
+	default:
+		entry.Info("Reconciled orphaned multipart upload")
+	}
+	return orphan
+}
@@ -0,0 +1,238 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+)
+
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// partSize is the size of every part of a fake upload
+const partSize = 5 << 20
+
+type fakeUpload struct {
+	key       string
+	initiated time.Time
+	parts     int
+}
+
+// fakeBackend holds uploads per bucket and pages listings one upload at a time
+type fakeBackend struct {
+	uploads  map[string]map[string]fakeUpload // bucket -> upload ID -> upload
+	failList map[string]bool
+	aborted  []string
+}
+
+func (f *fakeBackend) ListBuckets(_ context.Context, _ *s3.ListBucketsInput, _ ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	out := &s3.ListBucketsOutput{}
+	for name := range f.uploads {
+		out.Buckets = append(out.Buckets, types.Bucket{Name: aws.String(name)})
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) ListMultipartUploads(_ context.Context, params *s3.ListMultipartUploadsInput, _ ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	bucket := aws.ToString(params.Bucket)
+	if f.failList[bucket] {
+		return nil, errors.New("access denied")
+	}
+	var ids []string
+	for id := range f.uploads[bucket] {
+		if id > aws.ToString(params.UploadIdMarker) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	out := &s3.ListMultipartUploadsOutput{IsTruncated: aws.Bool(len(ids) > 1)}
+	if len(ids) > 0 {
+		upload := f.uploads[bucket][ids[0]]
+		out.Uploads = []types.MultipartUpload{{Key: aws.String(upload.key), UploadId: aws.String(ids[0]), Initiated: aws.Time(upload.initiated)}}
+		out.NextKeyMarker = aws.String(upload.key)
+		out.NextUploadIdMarker = aws.String(ids[0])
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) ListParts(_ context.Context, params *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	upload := f.uploads[aws.ToString(params.Bucket)][aws.ToString(params.UploadId)]
+	out := &s3.ListPartsOutput{}
+	parts := upload.parts
+	if params.MaxParts != nil {
+		parts = min(parts, int(*params.MaxParts))
+	}
+	for i := range parts {
+		out.Parts = append(out.Parts, types.Part{PartNumber: aws.Int32(int32(i + 1)), Size: aws.Int64(partSize)})
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) AbortMultipartUpload(_ context.Context, params *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted = append(f.aborted, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+type fakeSessions struct {
+	states    []*orchestration.SessionState
+	discarded []string
+	adopted   []string
+}
+
+func (f *fakeSessions) ListMultipartSessions(_ context.Context) ([]*orchestration.SessionState, error) {
+	return f.states, nil
+}
+
+func (f *fakeSessions) DiscardMultipartSession(_ context.Context, uploadID string) error {
+	f.discarded = append(f.discarded, uploadID)
+	return nil
+}
+
+func (f *fakeSessions) InitiateMultipartUpload(_ context.Context, uploadID, _, _ string) error {
+	f.adopted = append(f.adopted, uploadID)
+	return nil
+}
+
+// fixture has, in bucket "data": a matched upload, an old orphan with parts,
+// an old orphan without parts and a young orphan; a session whose upload is
+// gone, a young one, and one in the unlistable bucket "locked"
+func fixture() (*fakeBackend, *fakeSessions) {
+	old := now.Add(-2 * time.Hour)
+	backend := &fakeBackend{
+		uploads: map[string]map[string]fakeUpload{
+			"data": {
+				"u-matched": {key: "a", initiated: old, parts: 2},
+				"u-parts":   {key: "b", initiated: old, parts: 3},
+				"u-empty":   {key: "c", initiated: old},
+				"u-young":   {key: "d", initiated: now.Add(-time.Minute)},
+			},
+			"locked": {},
+		},
+		failList: map[string]bool{"locked": true},
+	}
+	sessions := &fakeSessions{states: []*orchestration.SessionState{
+		{UploadID: "u-matched", BucketName: "data", ObjectKey: "a", CreatedAt: old},
+		{UploadID: "s-gone", BucketName: "data", ObjectKey: "e", CreatedAt: old},
+		{UploadID: "s-young", BucketName: "data", ObjectKey: "f", CreatedAt: now.Add(-time.Minute)},
+		{UploadID: "s-locked", BucketName: "locked", ObjectKey: "g", CreatedAt: old},
+	}}
+	return backend, sessions
+}
+
+func reconcile(t *testing.T, policy string, backend *fakeBackend, sessions *fakeSessions) map[string]string {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	r := New(backend, sessions, Config{MinAge: time.Hour, Policy: policy}, logrus.NewEntry(logger))
+	r.now = func() time.Time { return now }
+
+	orphans, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	actions := make(map[string]string)
+	for _, orphan := range orphans {
+		actions[orphan.Kind+":"+orphan.UploadID] = orphan.Action
+	}
+	return actions
+}
+
+func TestReconcile_Keep(t *testing.T) {
+	backend, sessions := fixture()
+	actions := reconcile(t, config.ReconcilePolicyKeep, backend, sessions)
+
+	assert.Equal(t, map[string]string{
+		"upload:u-parts": ActionKept,
+		"upload:u-empty": ActionKept,
+		"session:s-gone": ActionKept,
+	}, actions)
+	assert.Empty(t, backend.aborted)
+	assert.Empty(t, sessions.discarded)
+}
+
+func TestReconcile_Abort(t *testing.T) {
+	backend, sessions := fixture()
+	actions := reconcile(t, config.ReconcilePolicyAbort, backend, sessions)
+
+	assert.Equal(t, map[string]string{
+		"upload:u-parts": ActionAborted,
+		"upload:u-empty": ActionAborted,
+		"session:s-gone": ActionDiscarded,
+	}, actions)
+	assert.ElementsMatch(t, []string{"u-parts", "u-empty"}, backend.aborted)
+	assert.Equal(t, []string{"s-gone"}, sessions.discarded)
+	assert.Empty(t, sessions.adopted)
+}
+
+func TestReconcile_Adopt(t *testing.T) {
+	backend, sessions := fixture()
+	actions := reconcile(t, config.ReconcilePolicyAdopt, backend, sessions)
+
+	assert.Equal(t, map[string]string{
+		"upload:u-parts": ActionAborted,
+		"upload:u-empty": ActionAdopted,
+		"session:s-gone": ActionDiscarded,
+	}, actions)
+	assert.Equal(t, []string{"u-parts"}, backend.aborted)
+	assert.Equal(t, []string{"u-empty"}, sessions.adopted)
+}
+
+func TestReconcile_ConfiguredBuckets(t *testing.T) {
+	backend, sessions := fixture()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	r := New(backend, sessions, Config{Buckets: []string{"locked"}, Policy: config.ReconcilePolicyAbort}, logrus.NewEntry(logger))
+
+	orphans, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+	assert.Empty(t, backend.aborted)
+	assert.Empty(t, sessions.discarded)
+}
+
+// The memory store of a real manager loses nothing but what the reconciler
+// discards, and adopted uploads take parts
+func TestReconcile_Manager(t *testing.T) {
+	aesKey := "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="
+	prefix := "s3ep-"
+	cfg := &config.Config{Encryption: config.EncryptionConfig{
+		EncryptionMethodAlias: "test-aes",
+		MetadataKeyPrefix:     &prefix,
+		Providers: []config.EncryptionProvider{
+			{Alias: "test-aes", Type: "aes", Config: map[string]interface{}{"aes_key": aesKey}},
+		},
+	}}
+	manager, err := orchestration.NewManager(cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, manager.InitiateMultipartUpload(ctx, "s-gone", "e", "data"))
+
+	backend := &fakeBackend{uploads: map[string]map[string]fakeUpload{
+		"data": {"u-empty": {key: "c", initiated: time.Now().Add(-time.Hour)}},
+	}}
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	r := New(backend, manager, Config{Policy: config.ReconcilePolicyAdopt}, logrus.NewEntry(logger))
+	_, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+
+	states, err := manager.ListMultipartSessions(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "u-empty", states[0].UploadID)
+	assert.Equal(t, "c", states[0].ObjectKey)
+
+	_, err = manager.UploadPartStreaming(ctx, "u-empty", 1, strings.NewReader("part one"))
+	assert.NoError(t, err)
+}
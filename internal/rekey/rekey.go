@@ -0,0 +1,501 @@
+// Package rekey re-encrypts the objects of a bucket with the active provider.
+// Unlike a KEK rotation, which only re-wraps DEKs, every selected object is
+// read, decrypted and written again with a new DEK, so it also encrypts
+// objects that were stored unencrypted or by the "none" provider. Objects are
+// streamed one at a time per worker through a temporary file; progress is
+// saved to a checkpoint after every listing page, so an interrupted run is
+// resumed where it stopped.
+package rekey
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// FromNone selects the objects that are not encrypted
+const FromNone = "none"
+
+// FromLegacyPrefix selects the objects whose encryption metadata is stored
+// under a legacy metadata key prefix. Their metadata is rewritten under the
+// current prefix; the objects are not re-encrypted.
+const FromLegacyPrefix = "legacy-prefix"
+
+// Results of one object
+const (
+	// ResultRekeyed means the object was re-encrypted, or would be in a dry run
+	ResultRekeyed = "rekeyed"
+	// ResultSkipped means the object is not selected or was deleted
+	ResultSkipped = "skipped"
+	// ResultFailed means the object could not be re-encrypted or stored
+	ResultFailed = "failed"
+)
+
+const (
+	defaultWorkers = 4
+
+	// maxReportedFailures bounds the failed keys kept in the report
+	maxReportedFailures = 100
+
+	// maxPutObjectSize is the largest object a single PutObject can store
+	maxPutObjectSize = 5 * 1024 * 1024 * 1024
+)
+
+// Backend is the subset of the S3 client used to re-encrypt objects
+type Backend interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// PrefixMigrator rewrites the metadata of an object stored under a legacy
+// metadata key prefix and reports whether it was, see
+// backend.LegacyPrefixStore
+type PrefixMigrator interface {
+	MigrateObject(ctx context.Context, bucket, key string, dryRun bool) (bool, error)
+}
+
+// Config describes a re-encryption run
+type Config struct {
+	Bucket string
+	Prefix string
+	// From selects the objects to re-encrypt: FromNone for unencrypted
+	// objects, a provider alias for objects encrypted with that provider, or
+	// "" for every object not encrypted with the active provider.
+	// FromLegacyPrefix migrates metadata with PrefixMigrator instead.
+	From               string
+	Workers            int    // Objects re-encrypted in parallel (default: 4)
+	DryRun             bool   // Only count the objects that would be re-encrypted
+	Checkpoint         string // File progress is saved to and resumed from, "" for none
+	StreamingThreshold int64  // Plaintext size from which objects are encrypted with aes-ctr
+	TempDir            string // Directory of the temporary plaintext files, "" for the default
+	PrefixMigrator     PrefixMigrator
+}
+
+// Failure is an object that could not be re-encrypted
+type Failure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Report is the progress of a run. It is also the content of the checkpoint
+// file, so a resumed run continues the counts of the runs before.
+type Report struct {
+	Bucket         string    `json:"bucket"`
+	Prefix         string    `json:"prefix,omitempty"`
+	From           string    `json:"from,omitempty"`
+	ActiveProvider string    `json:"active_provider"`
+	DryRun         bool      `json:"dry_run,omitempty"`
+	ObjectsScanned int64     `json:"objects_scanned"`
+	ObjectsRekeyed int64     `json:"objects_rekeyed"`
+	ObjectsSkipped int64     `json:"objects_skipped"`
+	ObjectsFailed  int64     `json:"objects_failed"`
+	BytesRekeyed   int64     `json:"bytes_rekeyed"`
+	LastKey        string    `json:"last_key,omitempty"` // every key up to this one was processed
+	Failures       []Failure `json:"failures,omitempty"` // the first failed keys
+}
+
+// Rekeyer re-encrypts the objects of a bucket with the active provider
+type Rekeyer struct {
+	backend           Backend
+	encryptionMgr     *orchestration.Manager
+	config            Config
+	logger            *logrus.Entry
+	prefix            string // Metadata key prefix of the proxy
+	activeAlias       string
+	activeFingerprint string
+	fromFingerprint   string
+}
+
+// NewRekeyer validates cfg against the loaded providers
+func NewRekeyer(backend Backend, encryptionMgr *orchestration.Manager, cfg Config, logger *logrus.Entry) (*Rekeyer, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+
+	r := &Rekeyer{
+		backend:       backend,
+		encryptionMgr: encryptionMgr,
+		config:        cfg,
+		logger:        logger.WithField("component", "rekey"),
+		prefix:        encryptionMgr.GetMetadataKeyPrefix(),
+	}
+	if cfg.From == FromLegacyPrefix {
+		// Only metadata is rewritten, whatever the providers
+		if cfg.PrefixMigrator == nil {
+			return nil, fmt.Errorf("no legacy metadata key prefixes are configured in encryption.legacy_metadata_key_prefixes")
+		}
+		return r, nil
+	}
+	for _, provider := range encryptionMgr.GetLoadedProviders() {
+		if provider.IsActive {
+			if provider.Type == "none" {
+				return nil, fmt.Errorf("the active provider %q does not encrypt, make an encrypting provider active", provider.Alias)
+			}
+			r.activeAlias, r.activeFingerprint = provider.Alias, provider.Fingerprint
+		}
+		if cfg.From != FromNone && provider.Alias == cfg.From {
+			r.fromFingerprint = provider.Fingerprint
+		}
+	}
+	switch {
+	case r.activeAlias == "":
+		return nil, fmt.Errorf("no active provider is configured")
+	case cfg.From == r.activeAlias:
+		return nil, fmt.Errorf("provider %q is the active provider, objects are re-encrypted from another one", cfg.From)
+	case cfg.From != "" && cfg.From != FromNone && r.fromFingerprint == "":
+		return nil, fmt.Errorf("provider %q is not configured", cfg.From)
+	}
+	return r, nil
+}
+
+// Run re-encrypts the selected objects and returns the report. A run with a
+// checkpoint resumes after its last_key; a cancelled run stops after the
+// page in flight and can be resumed the same way.
+func (r *Rekeyer) Run(ctx context.Context) (Report, error) {
+	report, err := r.loadCheckpoint()
+	if err != nil {
+		return Report{}, err
+	}
+	report.ActiveProvider = r.activeAlias
+	report.DryRun = r.config.DryRun
+
+	r.logger.WithFields(logrus.Fields{
+		"bucket":          r.config.Bucket,
+		"prefix":          r.config.Prefix,
+		"from":            r.config.From,
+		"active_provider": r.activeAlias,
+		"dry_run":         r.config.DryRun,
+		"start_after":     report.LastKey,
+	}).Info("Starting re-encryption")
+
+	paginator := s3.NewListObjectsV2Paginator(r.backend, &s3.ListObjectsV2Input{
+		Bucket:     aws.String(r.config.Bucket),
+		Prefix:     optionalString(r.config.Prefix),
+		StartAfter: optionalString(report.LastKey),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return report, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		results := make([]string, len(page.Contents))
+		sizes := make([]int64, len(page.Contents))
+		errs := make([]error, len(page.Contents))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, r.config.Workers)
+		for i, object := range page.Contents {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i], sizes[i], errs[i] = r.rekeyObject(ctx, object)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+
+		for i, object := range page.Contents {
+			report.ObjectsScanned++
+			switch results[i] {
+			case ResultRekeyed:
+				report.ObjectsRekeyed++
+				report.BytesRekeyed += sizes[i]
+			case ResultSkipped:
+				report.ObjectsSkipped++
+			default:
+				report.ObjectsFailed++
+				if len(report.Failures) < maxReportedFailures {
+					report.Failures = append(report.Failures, Failure{Key: aws.ToString(object.Key), Error: errs[i].Error()})
+				}
+			}
+			report.LastKey = aws.ToString(object.Key)
+		}
+		if err := r.saveCheckpoint(report); err != nil {
+			return report, err
+		}
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"scanned": report.ObjectsScanned,
+		"rekeyed": report.ObjectsRekeyed,
+		"skipped": report.ObjectsSkipped,
+		"failed":  report.ObjectsFailed,
+	}).Info("Re-encryption completed")
+	return report, nil
+}
+
+// selected reports whether an object with metadata is re-encrypted
+func (r *Rekeyer) selected(metadata map[string]string) (bool, error) {
+	if _, legacy := metadata["encrypted-dek"]; legacy {
+		return false, fmt.Errorf("object has encryption metadata without the %q prefix, run s3ep-migrate first", r.prefix)
+	}
+	_, encrypted := metadata[r.prefix+"encrypted-dek"]
+	fingerprint := metadata[r.prefix+"kek-fingerprint"]
+	switch r.config.From {
+	case FromNone:
+		return !encrypted, nil
+	case "":
+		return !encrypted || fingerprint != r.activeFingerprint, nil
+	default:
+		return encrypted && fingerprint == r.fromFingerprint, nil
+	}
+}
+
+// rekeyObject re-encrypts one object and returns its result and plaintext
+// size. The object is read and overwritten conditional on the ETag of its
+// metadata, so an object changed in the meantime is not overwritten with
+// stale data.
+func (r *Rekeyer) rekeyObject(ctx context.Context, object types.Object) (string, int64, error) {
+	key := aws.ToString(object.Key)
+	if r.config.From == FromLegacyPrefix {
+		return r.migratePrefix(ctx, object)
+	}
+	head, err := r.backend.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.config.Bucket),
+		Key:    object.Key,
+	})
+	if isNotFound(err) {
+		// Deleted after it was listed
+		return ResultSkipped, 0, nil
+	}
+	if err != nil {
+		return ResultFailed, 0, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	selected, err := r.selected(head.Metadata)
+	if err != nil {
+		return ResultFailed, 0, err
+	}
+	if !selected {
+		return ResultSkipped, 0, nil
+	}
+	if aws.ToInt64(head.ContentLength) > maxPutObjectSize {
+		return ResultFailed, 0, fmt.Errorf("objects larger than 5 GiB cannot be re-encrypted with PutObject")
+	}
+	if r.config.DryRun {
+		return ResultRekeyed, aws.ToInt64(head.ContentLength), nil
+	}
+
+	size, err := r.reencrypt(ctx, key, head)
+	if isNotFound(err) {
+		return ResultSkipped, 0, nil
+	}
+	if err != nil {
+		return ResultFailed, 0, err
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"object_key": key,
+		"bytes":      size,
+	}).Debug("Re-encrypted object")
+	return ResultRekeyed, size, nil
+}
+
+// migratePrefix rewrites the metadata of an object stored under a legacy
+// metadata key prefix and returns its result and stored size
+func (r *Rekeyer) migratePrefix(ctx context.Context, object types.Object) (string, int64, error) {
+	key := aws.ToString(object.Key)
+	migrated, err := r.config.PrefixMigrator.MigrateObject(ctx, r.config.Bucket, key, r.config.DryRun)
+	switch {
+	case isNotFound(err):
+		return ResultSkipped, 0, nil
+	case err != nil:
+		return ResultFailed, 0, err
+	case !migrated:
+		return ResultSkipped, 0, nil
+	}
+	r.logger.WithField("object_key", key).Debug("Migrated metadata prefix")
+	return ResultRekeyed, aws.ToInt64(object.Size), nil
+}
+
+// reencrypt writes the object described by head again with a new DEK of the
+// active provider and returns its plaintext size
+func (r *Rekeyer) reencrypt(ctx context.Context, key string, head *s3.HeadObjectOutput) (int64, error) {
+	get, err := r.backend.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(r.config.Bucket),
+		Key:     aws.String(key),
+		IfMatch: head.ETag,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read object: %w", err)
+	}
+	defer get.Body.Close()
+
+	// Objects stored with an encryption context keep it
+	ctx = r.encryptionMgr.WithStoredEncryptionContext(ctx, head.Metadata)
+	var plaintext io.Reader = get.Body
+	if _, encrypted := head.Metadata[r.prefix+"encrypted-dek"]; encrypted {
+		decrypted, err := r.encryptionMgr.DecryptObject(ctx, get.Body, head.Metadata, key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt object: %w", err)
+		}
+		defer decrypted.Close()
+		plaintext = decrypted
+	}
+
+	// The plaintext is spooled to learn its size before the ciphertext is
+	// stored, and so a failed integrity check stores nothing
+	spool, err := os.CreateTemp(r.config.TempDir, "s3ep-rekey-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+	size, err := io.Copy(spool, plaintext)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read object: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	contentType := aws.ToString(head.ContentType)
+	isMultipart := r.config.StreamingThreshold > 0 && size >= r.config.StreamingThreshold
+	result, err := r.encryptionMgr.EncryptDataWithHTTPContentType(ctx, bufio.NewReader(spool), key, contentType, isMultipart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt object: %w", err)
+	}
+
+	metadata := r.encryptionMgr.FilterMetadataForClient(head.Metadata)
+	maps.Copy(metadata, result.Metadata)
+	input := &s3.PutObjectInput{
+		Bucket:                  aws.String(r.config.Bucket),
+		Key:                     aws.String(key),
+		Body:                    result.EncryptedDataReader,
+		ContentLength:           aws.Int64(encryption.ComputeCiphertextSize(size, result.Algorithm)),
+		IfMatch:                 head.ETag,
+		Metadata:                metadata,
+		ContentType:             head.ContentType,
+		CacheControl:            head.CacheControl,
+		ContentDisposition:      head.ContentDisposition,
+		ContentEncoding:         head.ContentEncoding,
+		ContentLanguage:         head.ContentLanguage,
+		WebsiteRedirectLocation: head.WebsiteRedirectLocation,
+	}
+	if head.StorageClass != "" {
+		input.StorageClass = head.StorageClass
+	}
+	// PutObject drops the tags of the object it replaces
+	if aws.ToInt32(get.TagCount) > 0 {
+		if input.Tagging, err = r.tagging(ctx, key); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := r.backend.PutObject(ctx, input); err != nil {
+		return 0, fmt.Errorf("failed to store re-encrypted object: %w", err)
+	}
+	return size, nil
+}
+
+// tagging returns the tags of an object URL-encoded for PutObject
+func (r *Rekeyer) tagging(ctx context.Context, key string) (*string, error) {
+	output, err := r.backend.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(r.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	tags := url.Values{}
+	for _, tag := range output.TagSet {
+		tags.Add(aws.ToString(tag.Key), aws.ToString(tag.Value))
+	}
+	return aws.String(tags.Encode()), nil
+}
+
+// loadCheckpoint returns the report saved by an earlier run of the same
+// bucket, prefix and selection, or an empty report
+func (r *Rekeyer) loadCheckpoint() (Report, error) {
+	report := Report{Bucket: r.config.Bucket, Prefix: r.config.Prefix, From: r.config.From}
+	if r.config.Checkpoint == "" {
+		return report, nil
+	}
+	data, err := os.ReadFile(r.config.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var saved Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return Report{}, fmt.Errorf("failed to parse checkpoint %s: %w", r.config.Checkpoint, err)
+	}
+	if saved.Bucket != report.Bucket || saved.Prefix != report.Prefix || saved.From != report.From {
+		return Report{}, fmt.Errorf("checkpoint %s belongs to a run of bucket %q, prefix %q and from %q", r.config.Checkpoint, saved.Bucket, saved.Prefix, saved.From)
+	}
+	return saved, nil
+}
+
+// saveCheckpoint replaces the checkpoint file with report. Dry runs change
+// nothing and leave the checkpoint alone.
+func (r *Rekeyer) saveCheckpoint(report Report) error {
+	if r.config.Checkpoint == "" || r.config.DryRun {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written to a temporary file first, so an interrupted write keeps the
+	// previous checkpoint
+	f, err := os.CreateTemp(filepath.Dir(r.config.Checkpoint), ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if err := os.Rename(f.Name(), r.config.Checkpoint); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	return errors.As(err, &notFound) || errors.As(err, &noSuchKey)
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
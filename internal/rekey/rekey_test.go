@@ -0,0 +1,235 @@
+package rekey
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+)
+
+var (
+	oldProvider = config.EncryptionProvider{
+		Alias:  "kek-2025",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+	}
+	newProvider = config.EncryptionProvider{
+		Alias:  "kek-2026",
+		Type:   "aes",
+		Config: map[string]interface{}{"aes_key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+	}
+	noneProvider = config.EncryptionProvider{Alias: "plain", Type: "none"}
+)
+
+func newEncryptionManager(t *testing.T, active string, providers ...config.EncryptionProvider) *orchestration.Manager {
+	t.Helper()
+	manager, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias: active,
+			Providers:             providers,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+func newStore(t *testing.T) *backend.MemoryBackend {
+	t.Helper()
+	store := backend.NewMemoryBackend(logrus.NewEntry(logrus.New()))
+	_, err := store.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String("data")})
+	require.NoError(t, err)
+	return store
+}
+
+// put stores plaintext encrypted by manager like the PUT handler does, or
+// unencrypted without a manager
+func put(t *testing.T, store *backend.MemoryBackend, manager *orchestration.Manager, key string, plaintext []byte) {
+	t.Helper()
+	body, metadata := io.Reader(bytes.NewReader(plaintext)), map[string]string{"owner": "alice"}
+	if manager != nil {
+		result, err := manager.EncryptData(context.Background(), bufio.NewReader(body), key)
+		require.NoError(t, err)
+		body = result.EncryptedDataReader
+		for k, v := range result.Metadata {
+			metadata[k] = v
+		}
+	}
+	_, err := store.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:      aws.String("data"),
+		Key:         aws.String(key),
+		Body:        body,
+		Metadata:    metadata,
+		ContentType: aws.String("text/plain"),
+	})
+	require.NoError(t, err)
+}
+
+func newRekeyer(t *testing.T, store Backend, manager *orchestration.Manager, cfg Config) *Rekeyer {
+	t.Helper()
+	cfg.Bucket = "data"
+	cfg.TempDir = t.TempDir()
+	r, err := NewRekeyer(store, manager, cfg, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	return r
+}
+
+func TestRekeyer_Run(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	rekeying := newEncryptionManager(t, "kek-2026", newProvider, oldProvider)
+	plaintext := []byte(strings.Repeat("rekey me ", 100))
+
+	put(t, store, newEncryptionManager(t, "kek-2025", oldProvider), "a/old.txt", plaintext)
+	put(t, store, rekeying, "a/new.txt", plaintext)
+	put(t, store, nil, "a/plain-1.txt", plaintext)
+	put(t, store, nil, "a/plain-2.txt", plaintext)
+	_, err := store.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String("data"),
+		Key:     aws.String("a/plain-1.txt"),
+		Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String("team"), Value: aws.String("billing")}}},
+	})
+	require.NoError(t, err)
+	before, err := store.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("data"), Key: aws.String("a/plain-1.txt")})
+	require.NoError(t, err)
+
+	// A dry run counts the objects without touching them
+	checkpoint := filepath.Join(t.TempDir(), "rekey.json")
+	report, err := newRekeyer(t, store, rekeying, Config{Prefix: "a/", DryRun: true, Checkpoint: checkpoint}).Run(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, int64(3), report.ObjectsRekeyed)
+	assert.Equal(t, int64(1), report.ObjectsSkipped)
+	assert.NoFileExists(t, checkpoint, "dry runs save no checkpoint")
+	after, err := store.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("data"), Key: aws.String("a/plain-1.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, before.ETag, after.ETag)
+
+	report, err = newRekeyer(t, store, rekeying, Config{Prefix: "a/", Workers: 2, Checkpoint: checkpoint}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "kek-2026", report.ActiveProvider)
+	assert.Equal(t, int64(4), report.ObjectsScanned)
+	assert.Equal(t, int64(3), report.ObjectsRekeyed)
+	assert.Equal(t, int64(1), report.ObjectsSkipped)
+	assert.Zero(t, report.ObjectsFailed)
+	assert.Equal(t, int64(3*len(plaintext)), report.BytesRekeyed)
+	assert.Equal(t, "a/plain-2.txt", report.LastKey)
+
+	var saved Report
+	data, err := os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, report, saved)
+
+	// Without the old KEK every object decrypts and keeps its attributes
+	current := newEncryptionManager(t, "kek-2026", newProvider)
+	for _, key := range []string{"a/old.txt", "a/new.txt", "a/plain-1.txt", "a/plain-2.txt"} {
+		object, err := store.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("data"), Key: aws.String(key)})
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", aws.ToString(object.ContentType), key)
+		assert.Equal(t, "alice", object.Metadata["owner"], key)
+		assert.Contains(t, object.Metadata, "s3ep-encrypted-dek", key)
+		decrypted, err := current.DecryptData(ctx, bufio.NewReader(object.Body), object.Metadata, key)
+		require.NoError(t, err, key)
+		data, err := io.ReadAll(decrypted)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, data, key)
+	}
+	tags, err := store.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String("data"), Key: aws.String("a/plain-1.txt")})
+	require.NoError(t, err)
+	require.Len(t, tags.TagSet, 1)
+	assert.Equal(t, "billing", aws.ToString(tags.TagSet[0].Value))
+
+	// A resumed run continues after the checkpoint
+	put(t, store, nil, "a/plain-3.txt", plaintext)
+	report, err = newRekeyer(t, store, rekeying, Config{Prefix: "a/", Checkpoint: checkpoint}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), report.ObjectsScanned)
+	assert.Equal(t, int64(4), report.ObjectsRekeyed)
+
+	_, err = newRekeyer(t, store, rekeying, Config{Prefix: "b/", Checkpoint: checkpoint}).Run(ctx)
+	assert.ErrorContains(t, err, "belongs to a run")
+}
+
+func TestRekeyer_From(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	rekeying := newEncryptionManager(t, "kek-2026", newProvider, oldProvider)
+	put(t, store, newEncryptionManager(t, "kek-2025", oldProvider), "old.txt", []byte("old"))
+	put(t, store, nil, "plain.txt", []byte("plain"))
+
+	report, err := newRekeyer(t, store, rekeying, Config{From: FromNone, DryRun: true}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ObjectsRekeyed)
+
+	report, err = newRekeyer(t, store, rekeying, Config{From: "kek-2025"}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ObjectsRekeyed)
+	object, err := store.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("data"), Key: aws.String("plain.txt")})
+	require.NoError(t, err)
+	assert.NotContains(t, object.Metadata, "s3ep-encrypted-dek", "unencrypted objects are not selected")
+
+	// Objects whose DEK cannot be unwrapped are reported
+	report, err = newRekeyer(t, store, newEncryptionManager(t, "kek-2025", oldProvider), Config{}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ObjectsFailed)
+	require.Len(t, report.Failures, 1)
+	assert.Equal(t, "old.txt", report.Failures[0].Key)
+
+	for _, invalid := range []struct {
+		manager *orchestration.Manager
+		cfg     Config
+	}{
+		{rekeying, Config{}},
+		{rekeying, Config{Bucket: "data", From: "kek-2026"}},
+		{rekeying, Config{Bucket: "data", From: "missing"}},
+		{newEncryptionManager(t, "plain", noneProvider), Config{Bucket: "data"}},
+	} {
+		_, err := NewRekeyer(store, invalid.manager, invalid.cfg, logrus.NewEntry(logrus.New()))
+		assert.Error(t, err, invalid.cfg)
+	}
+}
+
+func TestRekeyer_FromLegacyPrefix(t *testing.T) {
+	ctx := context.Background()
+	memory := newStore(t)
+	manager := newEncryptionManager(t, "kek-2025", oldProvider)
+	put(t, memory, manager, "old.txt", []byte("old"))
+	put(t, memory, nil, "plain.txt", []byte("plain"))
+
+	// metadata_key_prefix was changed from s3ep- to acme-
+	store := backend.NewLegacyPrefixStore(memory, "acme-", []string{"s3ep-"}, false, logrus.NewEntry(logrus.New()))
+
+	report, err := newRekeyer(t, store, manager, Config{From: FromLegacyPrefix, PrefixMigrator: store, DryRun: true}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ObjectsRekeyed)
+	assert.Equal(t, int64(1), report.ObjectsSkipped)
+
+	report, err = newRekeyer(t, store, manager, Config{From: FromLegacyPrefix, PrefixMigrator: store}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ObjectsRekeyed)
+	object, err := memory.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("data"), Key: aws.String("old.txt")})
+	require.NoError(t, err)
+	assert.Contains(t, object.Metadata, "acme-encrypted-dek")
+	assert.NotContains(t, object.Metadata, "s3ep-encrypted-dek")
+	assert.Equal(t, "alice", object.Metadata["owner"])
+
+	_, err = NewRekeyer(store, manager, Config{Bucket: "data", From: FromLegacyPrefix}, logrus.NewEntry(logrus.New()))
+	assert.ErrorContains(t, err, "legacy_metadata_key_prefixes")
+}
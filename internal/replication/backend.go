@@ -0,0 +1,50 @@
+package replication
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// mirroredBackend queues the objects written through it for replication.
+// Deletes, tags and ACLs are not replicated.
+type mirroredBackend struct {
+	interfaces.S3BackendInterface
+	replicator *Replicator
+}
+
+// Backend returns the source backend of the replicator, queueing every object
+// written through it
+func (r *Replicator) Backend() interfaces.S3BackendInterface {
+	return &mirroredBackend{S3BackendInterface: r.source, replicator: r}
+}
+
+// PutObject stores an object and queues it
+func (b *mirroredBackend) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	out, err := b.S3BackendInterface.PutObject(ctx, params, optFns...)
+	if err == nil {
+		b.replicator.Enqueue(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	}
+	return out, err
+}
+
+// CopyObject copies an object and queues the copy
+func (b *mirroredBackend) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	out, err := b.S3BackendInterface.CopyObject(ctx, params, optFns...)
+	if err == nil {
+		b.replicator.Enqueue(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	}
+	return out, err
+}
+
+// CompleteMultipartUpload completes an upload and queues the object
+func (b *mirroredBackend) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	out, err := b.S3BackendInterface.CompleteMultipartUpload(ctx, params, optFns...)
+	if err == nil {
+		b.replicator.Enqueue(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	}
+	return out, err
+}
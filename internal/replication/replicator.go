@@ -0,0 +1,312 @@
+// Package replication mirrors the objects written through the proxy to a
+// secondary S3 endpoint. The stored ciphertext and metadata are copied as
+// they are, so the secondary is readable by any proxy with the same keys.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+// Defaults of Config
+const (
+	defaultQueueSize    = 10000
+	defaultWorkers      = 4
+	defaultRetryBackoff = 5 * time.Second
+)
+
+// maxRetryBackoff caps the doubling delay between attempts of an object
+const maxRetryBackoff = 5 * time.Minute
+
+// minPartSize is the part size of objects copied with a multipart upload;
+// larger objects use larger parts to stay within the S3 limit of parts
+const (
+	minPartSize = 16 * 1024 * 1024
+	maxParts    = 10000
+)
+
+// Config holds replication configuration
+type Config struct {
+	Buckets      []string      // Glob patterns of replicated buckets; empty replicates every bucket
+	QueueSize    int           // Objects waiting to be copied before new ones are dropped (default: 10000)
+	Workers      int           // Objects copied in parallel (default: 4)
+	MaxRetries   int           // Further attempts after a failed copy
+	RetryBackoff time.Duration // Delay before the first retry, doubled per attempt (default: 5s)
+}
+
+// object is a queued object
+type object struct {
+	bucket  string
+	key     string
+	attempt int
+}
+
+// Replicator copies objects from the source to the target backend in the
+// background. Objects are queued by key: the copy reads the current state of
+// the object, so repeated writes of a key waiting in the queue are copied once.
+type Replicator struct {
+	source interfaces.S3BackendInterface
+	target interfaces.S3BackendInterface
+	config Config
+	logger *logrus.Entry
+
+	queue    chan object
+	partSize int64 // smallest part size of multipart copies
+
+	mu      sync.Mutex
+	pending map[string]bool // keys in the queue, by bucket/key
+}
+
+// New creates a replicator copying from source to target
+func New(source, target interfaces.S3BackendInterface, cfg Config, logger *logrus.Entry) *Replicator {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	return &Replicator{
+		source:   source,
+		target:   target,
+		config:   cfg,
+		logger:   logger,
+		queue:    make(chan object, cfg.QueueSize),
+		partSize: minPartSize,
+		pending:  make(map[string]bool),
+	}
+}
+
+// Replicates reports whether objects of bucket are replicated
+func (r *Replicator) Replicates(bucket string) bool {
+	if len(r.config.Buckets) == 0 {
+		return true
+	}
+	for _, pattern := range r.config.Buckets {
+		if matched, _ := path.Match(pattern, bucket); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueue queues an object for copying. It never blocks: objects already in
+// the queue are skipped, and objects arriving at a full queue are dropped.
+func (r *Replicator) Enqueue(bucket, key string) {
+	if r.Replicates(bucket) {
+		r.enqueue(object{bucket: bucket, key: key})
+	}
+}
+
+func (r *Replicator) enqueue(obj object) {
+	id := obj.bucket + "/" + obj.key
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[id] {
+		return
+	}
+	select {
+	case r.queue <- obj:
+		r.pending[id] = true
+		monitoring.SetReplicationQueueLength(len(r.queue))
+	default:
+		monitoring.RecordReplication("dropped")
+		r.logger.WithFields(logrus.Fields{
+			"bucket": obj.bucket,
+			"key":    obj.key,
+		}).Error("Replication queue full, object is not replicated")
+	}
+}
+
+// Run copies queued objects with the configured workers until ctx is
+// cancelled. Objects still queued then are not replicated.
+func (r *Replicator) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range r.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case obj := <-r.queue:
+					r.process(ctx, obj)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if queued := len(r.queue); queued > 0 {
+		r.logger.WithField("queued", queued).Warn("Replication stopped with objects left in the queue")
+	}
+}
+
+// process copies one object and schedules a retry if that fails
+func (r *Replicator) process(ctx context.Context, obj object) {
+	r.mu.Lock()
+	delete(r.pending, obj.bucket+"/"+obj.key)
+	monitoring.SetReplicationQueueLength(len(r.queue))
+	r.mu.Unlock()
+
+	fields := logrus.Fields{"bucket": obj.bucket, "key": obj.key, "attempt": obj.attempt + 1}
+	copied, err := r.Copy(ctx, obj.bucket, obj.key)
+	switch {
+	case err == nil && copied:
+		monitoring.RecordReplication("replicated")
+		r.logger.WithFields(fields).Debug("Replicated object")
+	case err == nil:
+		// Deleted since it was written; a later write queues it again
+		monitoring.RecordReplication("skipped")
+	case ctx.Err() != nil:
+	case obj.attempt < r.config.MaxRetries:
+		monitoring.RecordReplication("retried")
+		delay := min(r.config.RetryBackoff<<obj.attempt, maxRetryBackoff)
+		r.logger.WithError(err).WithFields(fields).WithField("retry_in", delay).Warn("Failed to replicate object")
+		obj.attempt++
+		time.AfterFunc(delay, func() {
+			if ctx.Err() == nil {
+				r.enqueue(obj)
+			}
+		})
+	default:
+		monitoring.RecordReplication("failed")
+		r.logger.WithError(err).WithFields(fields).Error("Failed to replicate object, giving up")
+	}
+}
+
+// Copy copies the current version of an object as stored, body and metadata,
+// to the target. copied is false if the object no longer exists.
+func (r *Replicator) Copy(ctx context.Context, bucket, key string) (copied bool, err error) {
+	out, err := r.source.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		var (
+			noSuchKey *types.NoSuchKey
+			status    interface{ HTTPStatusCode() int }
+		)
+		if errors.As(err, &noSuchKey) || errors.As(err, &status) && status.HTTPStatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read source object: %w", err)
+	}
+	defer out.Body.Close() //nolint:errcheck // read-only body
+
+	partSize := r.partSize
+	if size := aws.ToInt64(out.ContentLength); size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	first, err := readPart(out.Body, partSize)
+	if err != nil {
+		return false, fmt.Errorf("failed to read source object: %w", err)
+	}
+
+	put := &s3.PutObjectInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		Metadata:           out.Metadata,
+		ContentType:        out.ContentType,
+		CacheControl:       out.CacheControl,
+		ContentDisposition: out.ContentDisposition,
+		ContentEncoding:    out.ContentEncoding,
+		ContentLanguage:    out.ContentLanguage,
+	}
+	if int64(len(first)) < partSize {
+		put.Body = bytes.NewReader(first)
+		put.ContentLength = aws.Int64(int64(len(first)))
+		if _, err := r.target.PutObject(ctx, put); err != nil {
+			return false, fmt.Errorf("failed to write target object: %w", err)
+		}
+		return true, nil
+	}
+	return true, r.copyMultipart(ctx, put, first, out.Body, partSize)
+}
+
+// copyMultipart uploads first and the rest of body in parts of partSize
+func (r *Replicator) copyMultipart(ctx context.Context, put *s3.PutObjectInput, first []byte, body io.Reader, partSize int64) error {
+	created, err := r.target.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             put.Bucket,
+		Key:                put.Key,
+		Metadata:           put.Metadata,
+		ContentType:        put.ContentType,
+		CacheControl:       put.CacheControl,
+		ContentDisposition: put.ContentDisposition,
+		ContentEncoding:    put.ContentEncoding,
+		ContentLanguage:    put.ContentLanguage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create target multipart upload: %w", err)
+	}
+	abort := func(cause error) error {
+		// The context may be cancelled already; the abort must still be sent
+		abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if _, err := r.target.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+			Bucket: put.Bucket, Key: put.Key, UploadId: created.UploadId,
+		}); err != nil {
+			r.logger.WithError(err).WithField("upload_id", aws.ToString(created.UploadId)).Warn("Failed to abort target multipart upload")
+		}
+		return cause
+	}
+
+	var parts []types.CompletedPart
+	part := first
+	for number := int32(1); len(part) > 0; number++ {
+		uploaded, err := r.target.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        put.Bucket,
+			Key:           put.Key,
+			UploadId:      created.UploadId,
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(part),
+			ContentLength: aws.Int64(int64(len(part))),
+		})
+		if err != nil {
+			return abort(fmt.Errorf("failed to write target part %d: %w", number, err))
+		}
+		parts = append(parts, types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(number)})
+
+		if int64(len(part)) < partSize {
+			break
+		}
+		if part, err = readPart(body, partSize); err != nil {
+			return abort(fmt.Errorf("failed to read source object: %w", err))
+		}
+	}
+
+	if _, err := r.target.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          put.Bucket,
+		Key:             put.Key,
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}); err != nil {
+		return abort(fmt.Errorf("failed to complete target multipart upload: %w", err))
+	}
+	return nil
+}
+
+// readPart reads up to size bytes; a shorter part is the last one
+func readPart(body io.Reader, size int64) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(body, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return buf[:n], err
+}
@@ -0,0 +1,198 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/backend"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/proxy/interfaces"
+)
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+// flakyTarget fails the first failures writes and counts the parts it receives
+type flakyTarget struct {
+	interfaces.S3BackendInterface
+	mu       sync.Mutex
+	failures int
+	puts     int
+	parts    int
+}
+
+func (f *flakyTarget) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	f.puts++
+	if f.failures > 0 {
+		f.failures--
+		f.mu.Unlock()
+		return nil, errors.New("connection refused")
+	}
+	f.mu.Unlock()
+	return f.S3BackendInterface.PutObject(ctx, params, optFns...)
+}
+
+func (f *flakyTarget) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	f.mu.Lock()
+	f.parts++
+	f.mu.Unlock()
+	return f.S3BackendInterface.UploadPart(ctx, params, optFns...)
+}
+
+func newTestBackends(t *testing.T) (source *backend.MemoryBackend, target *flakyTarget) {
+	t.Helper()
+	source = backend.NewMemoryBackend(testLogger())
+	targetBackend := backend.NewMemoryBackend(testLogger())
+	for _, b := range []*backend.MemoryBackend{source, targetBackend} {
+		for _, bucket := range []string{"prod-data", "scratch"} {
+			_, err := b.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+		}
+	}
+	return source, &flakyTarget{S3BackendInterface: targetBackend}
+}
+
+func readObject(t *testing.T, b interfaces.S3BackendInterface, bucket, key string) (*s3.GetObjectOutput, []byte) {
+	t.Helper()
+	out, err := b.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	require.NoError(t, err)
+	data, err := io.ReadAll(out.Body)
+	require.NoError(t, err)
+	return out, data
+}
+
+func TestReplicator_CopiesBodyAndMetadata(t *testing.T) {
+	source, target := newTestBackends(t)
+	replicator := New(source, target, Config{}, testLogger())
+	ctx := context.Background()
+
+	_, err := replicator.Backend().PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("prod-data"),
+		Key:         aws.String("report.csv"),
+		Body:        strings.NewReader("ciphertext"),
+		ContentType: aws.String("text/csv"),
+		Metadata:    map[string]string{"s3ep-dek-algorithm": "aes-gcm", "owner": "billing"},
+	})
+	require.NoError(t, err)
+	require.Len(t, replicator.queue, 1)
+
+	copied, err := replicator.Copy(ctx, "prod-data", "report.csv")
+	require.NoError(t, err)
+	assert.True(t, copied)
+	out, data := readObject(t, target, "prod-data", "report.csv")
+	assert.Equal(t, "ciphertext", string(data))
+	assert.Equal(t, "text/csv", aws.ToString(out.ContentType))
+	assert.Equal(t, map[string]string{"s3ep-dek-algorithm": "aes-gcm", "owner": "billing"}, out.Metadata)
+
+	// Objects deleted before their copy are skipped
+	copied, err = replicator.Copy(ctx, "prod-data", "missing")
+	require.NoError(t, err)
+	assert.False(t, copied)
+}
+
+func TestReplicator_MultipartCopy(t *testing.T) {
+	source, target := newTestBackends(t)
+	replicator := New(source, target, Config{}, testLogger())
+	replicator.partSize = 4
+	ctx := context.Background()
+
+	for _, body := range []string{"0123456789", "01234567"} {
+		_, err := source.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:   aws.String("prod-data"),
+			Key:      aws.String("large"),
+			Body:     bytes.NewReader([]byte(body)),
+			Metadata: map[string]string{"s3ep-encrypted-dek": "wrapped"},
+		})
+		require.NoError(t, err)
+		target.parts = 0
+
+		copied, err := replicator.Copy(ctx, "prod-data", "large")
+		require.NoError(t, err)
+		assert.True(t, copied)
+		assert.Equal(t, (len(body)+3)/4, target.parts)
+		out, data := readObject(t, target, "prod-data", "large")
+		assert.Equal(t, body, string(data))
+		assert.Equal(t, "wrapped", out.Metadata["s3ep-encrypted-dek"])
+	}
+}
+
+func TestReplicator_QueueAndRetry(t *testing.T) {
+	source, target := newTestBackends(t)
+	target.failures = 2
+	replicator := New(source, target, Config{
+		Buckets:      []string{"prod-*"},
+		QueueSize:    2,
+		Workers:      1,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}, testLogger())
+	ctx := context.Background()
+	mirrored := replicator.Backend()
+
+	for _, key := range []string{"a", "a", "b", "c"} {
+		_, err := mirrored.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("prod-data"), Key: aws.String(key), Body: strings.NewReader(key)})
+		require.NoError(t, err)
+	}
+	_, err := mirrored.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("scratch"), Key: aws.String("tmp"), Body: strings.NewReader("tmp")})
+	require.NoError(t, err)
+	assert.Len(t, replicator.queue, 2, "a is queued once, c is dropped at the full queue and scratch is not replicated")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		replicator.Run(runCtx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		_, errA := target.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("prod-data"), Key: aws.String("a")})
+		_, errB := target.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("prod-data"), Key: aws.String("b")})
+		return errA == nil && errB == nil
+	}, 5*time.Second, time.Millisecond, "failed copies are retried")
+	cancel()
+	<-done
+
+	target.mu.Lock()
+	assert.Equal(t, 4, target.puts)
+	target.mu.Unlock()
+	_, err = target.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("prod-data"), Key: aws.String("c")})
+	assert.Error(t, err)
+	_, err = target.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("scratch"), Key: aws.String("tmp")})
+	assert.Error(t, err)
+}
+
+func TestReplicator_GivesUpAfterMaxRetries(t *testing.T) {
+	source, target := newTestBackends(t)
+	target.failures = 3
+	replicator := New(source, target, Config{Workers: 1, MaxRetries: 1, RetryBackoff: time.Millisecond}, testLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := replicator.Backend().PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("prod-data"), Key: aws.String("k"), Body: strings.NewReader("k")})
+	require.NoError(t, err)
+	go replicator.Run(ctx)
+
+	assert.Eventually(t, func() bool {
+		target.mu.Lock()
+		defer target.mu.Unlock()
+		return target.puts == 2
+	}, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	target.mu.Lock()
+	assert.Equal(t, 2, target.puts, "one attempt and one retry")
+	target.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+// Package requestid generates the IDs the proxy answers S3 requests with and
+// carries them through the context of a request, so the x-amz-request-id a
+// client reports can be found in the logs, audit records and error bodies.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field is the log field the request ID is written to
+const Field = "request_id"
+
+type requestIDKey struct{}
+
+// New returns a request ID in the format used by S3 (16 upper-case hex
+// characters)
+func New() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// NewHostID returns an opaque extended request ID for x-amz-id-2
+func NewHostID() string {
+	b := make([]byte, 48)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// WithID attaches the request ID id to ctx
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID attached with WithID, "" if there is none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Hook adds the request ID of the context of a log entry, set with
+// Entry.WithContext, as the request_id field. Entries that already carry the
+// field or have no request ID in their context are left alone.
+type Hook struct{}
+
+// Levels returns the levels the hook fires for
+func (Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the request ID to entry
+func (Hook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data[Field]; ok {
+		return nil
+	}
+	if id := FromContext(entry.Context); id != "" {
+		entry.Data[Field] = id
+	}
+	return nil
+}
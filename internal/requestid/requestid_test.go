@@ -0,0 +1,55 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	id := New()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-F]{16}$`), id)
+	assert.NotEqual(t, id, New())
+	assert.Len(t, NewHostID(), 64)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+	assert.Equal(t, "4442587FB7D0A2F9", FromContext(WithID(context.Background(), "4442587FB7D0A2F9")))
+}
+
+func TestHook(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(Hook{})
+
+	decode := func() map[string]any {
+		t.Helper()
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &fields))
+		out.Reset()
+		return fields
+	}
+
+	ctx := WithID(context.Background(), "4442587FB7D0A2F9")
+
+	logger.WithContext(ctx).Info("with request")
+	assert.Equal(t, "4442587FB7D0A2F9", decode()[Field])
+
+	logger.WithContext(ctx).WithField(Field, "0123456789ABCDEF").Info("explicit field")
+	assert.Equal(t, "0123456789ABCDEF", decode()[Field])
+
+	logger.WithContext(context.Background()).Info("background job")
+	assert.NotContains(t, decode(), Field)
+
+	logger.Info("no context")
+	assert.NotContains(t, decode(), Field)
+}
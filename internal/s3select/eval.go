@@ -0,0 +1,729 @@
+package s3select
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Values are nil (NULL or MISSING), bool, int64, float64, string and, for
+// JSON input, *object and []any.
+
+// pathElem is one step of a column path: a name, an array index or [*]
+type pathElem struct {
+	name     string
+	quoted   bool // names in double quotes match case-sensitively
+	index    int
+	indexed  bool
+	wildcard bool
+}
+
+// record is one row of input
+type record interface {
+	// lookup returns the value at path, or nil if there is none
+	lookup(path []pathElem) any
+}
+
+// expr is an expression of a SELECT statement
+type expr interface {
+	eval(rec record) (any, error)
+}
+
+type literal struct{ value any }
+
+func (e *literal) eval(record) (any, error) { return e.value, nil }
+
+type column struct{ path []pathElem }
+
+func (e *column) eval(rec record) (any, error) {
+	if rec == nil {
+		return nil, evalError("EvaluatorInvalidArguments", "column references need a record")
+	}
+	return rec.lookup(e.path), nil
+}
+
+// logical is AND or OR with SQL's three-valued logic
+type logical struct {
+	and         bool
+	left, right expr
+}
+
+func (e *logical) eval(rec record) (any, error) {
+	left, err := evalBool(e.left, rec)
+	if err != nil {
+		return nil, err
+	}
+	if left != nil && *left != e.and {
+		return *left, nil // false AND x, true OR x
+	}
+	right, err := evalBool(e.right, rec)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case right != nil && *right != e.and:
+		return *right, nil
+	case left == nil || right == nil:
+		return nil, nil
+	default:
+		return e.and, nil
+	}
+}
+
+type not struct{ operand expr }
+
+func (e *not) eval(rec record) (any, error) {
+	b, err := evalBool(e.operand, rec)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return !*b, nil
+}
+
+// evalBool evaluates a condition, nil meaning NULL
+func evalBool(e expr, rec record) (*bool, error) {
+	v, err := e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case bool:
+		return &v, nil
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return &b, nil
+		}
+	}
+	return nil, evalError("EvaluatorInvalidArguments", fmt.Sprintf("%s is not a boolean", formatValue(v)))
+}
+
+type comparison struct {
+	op          string
+	left, right expr
+}
+
+func (e *comparison) eval(rec record) (any, error) {
+	left, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(rec)
+	if err != nil || left == nil || right == nil {
+		return nil, err
+	}
+	if e.op == "=" || e.op == "!=" || e.op == "<>" {
+		equal := valuesEqual(left, right)
+		return equal == (e.op == "="), nil
+	}
+	c, err := compareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type isNull struct {
+	operand expr
+	negate  bool
+}
+
+func (e *isNull) eval(rec record) (any, error) {
+	v, err := e.operand.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.negate, nil
+}
+
+type like struct {
+	operand, pattern, escape expr
+	negate                   bool
+
+	compiledFor string // pattern and escape the regexp was compiled for
+	compiled    *regexp.Regexp
+}
+
+func (e *like) eval(rec record) (any, error) {
+	v, err := e.operand.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := e.pattern.eval(rec)
+	if err != nil || v == nil || pattern == nil {
+		return nil, err
+	}
+	escape := ""
+	if e.escape != nil {
+		value, err := e.escape.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		if escape = formatValue(value); len([]rune(escape)) > 1 {
+			return nil, evalError("InvalidArgument", "ESCAPE expects a single character")
+		}
+	}
+	key := formatValue(pattern) + "\x00" + escape
+	if e.compiled == nil || e.compiledFor != key {
+		e.compiled = likeRegexp(formatValue(pattern), escape)
+		e.compiledFor = key
+	}
+	return e.compiled.MatchString(formatValue(v)) != e.negate, nil
+}
+
+// likeRegexp translates a LIKE pattern: % matches any run of characters, _
+// a single one
+func likeRegexp(pattern, escape string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case escape != "" && string(r) == escape:
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+type inList struct {
+	operand expr
+	list    []expr
+	negate  bool
+}
+
+func (e *inList) eval(rec record) (any, error) {
+	v, err := e.operand.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	sawNull := false
+	for _, item := range e.list {
+		candidate, err := item.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			sawNull = true
+			continue
+		}
+		if valuesEqual(v, candidate) {
+			return !e.negate, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return e.negate, nil
+}
+
+type between struct {
+	operand, low, high expr
+	negate             bool
+}
+
+func (e *between) eval(rec record) (any, error) {
+	lower := &comparison{op: ">=", left: e.operand, right: e.low}
+	upper := &comparison{op: "<=", left: e.operand, right: e.high}
+	result, err := (&logical{and: true, left: lower, right: upper}).eval(rec)
+	if err != nil || result == nil || !e.negate {
+		return result, err
+	}
+	return !result.(bool), nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right expr
+}
+
+func (e *arithmetic) eval(rec record) (any, error) {
+	left, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(rec)
+	if err != nil || left == nil || right == nil {
+		return nil, err
+	}
+	if e.op == "||" {
+		return formatValue(left) + formatValue(right), nil
+	}
+
+	a, aok := toNumber(left)
+	b, bok := toNumber(right)
+	if !aok || !bok {
+		return nil, evalError("EvaluatorInvalidArguments", fmt.Sprintf("%s %s %s needs numbers", formatValue(left), e.op, formatValue(right)))
+	}
+	ai, aInt := a.(int64)
+	bi, bInt := b.(int64)
+	if aInt && bInt {
+		switch e.op {
+		case "+":
+			return ai + bi, nil
+		case "-":
+			return ai - bi, nil
+		case "*":
+			return ai * bi, nil
+		case "/", "%":
+			if bi == 0 {
+				return nil, evalError("DivisionByZero", "division by zero")
+			}
+			if e.op == "/" {
+				return ai / bi, nil
+			}
+			return ai % bi, nil
+		}
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch e.op {
+	case "+":
+		return af + bf, nil
+	case "-":
+		return af - bf, nil
+	case "*":
+		return af * bf, nil
+	default:
+		if bf == 0 {
+			return nil, evalError("DivisionByZero", "division by zero")
+		}
+		if e.op == "/" {
+			return af / bf, nil
+		}
+		return math.Mod(af, bf), nil
+	}
+}
+
+// castTypes maps the type names of CAST to the kinds of value they produce
+var castTypes = map[string]string{
+	"INT": "int", "INTEGER": "int", "BIGINT": "int", "SMALLINT": "int",
+	"FLOAT": "float", "REAL": "float", "DOUBLE": "float", "DECIMAL": "float", "NUMERIC": "float",
+	"STRING": "string", "VARCHAR": "string", "CHAR": "string",
+	"BOOL": "bool", "BOOLEAN": "bool",
+}
+
+type cast struct {
+	operand expr
+	target  string
+}
+
+func (e *cast) eval(rec record) (any, error) {
+	v, err := e.operand.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	failed := func() (any, error) {
+		return nil, evalError("CastFailed", fmt.Sprintf("cannot cast %s to %s", formatValue(v), strings.ToUpper(e.target)))
+	}
+	switch e.target {
+	case "string":
+		return formatValue(v), nil
+	case "bool":
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return failed()
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return failed()
+	}
+	if e.target == "float" {
+		return toFloat(n), nil
+	}
+	if f, ok := n.(float64); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return failed()
+		}
+		return int64(f), nil
+	}
+	return n, nil
+}
+
+// function is a scalar function call
+type function struct {
+	name  string
+	args  []expr
+	trim  string // BOTH, LEADING or TRAILING for TRIM
+	chars expr   // characters TRIM removes, spaces without
+}
+
+var functionArity = map[string][2]int{
+	"LOWER": {1, 1}, "UPPER": {1, 1}, "CHAR_LENGTH": {1, 1}, "CHARACTER_LENGTH": {1, 1},
+	"TRIM": {1, 1}, "SUBSTRING": {2, 3}, "COALESCE": {1, math.MaxInt}, "NULLIF": {2, 2},
+}
+
+// check validates the name and number of arguments of a call
+func (e *function) check(pos int) error {
+	arity, ok := functionArity[e.name]
+	if !ok {
+		return unsupported("function " + e.name)
+	}
+	if len(e.args) < arity[0] || len(e.args) > arity[1] {
+		return parseError(pos, fmt.Sprintf("wrong number of arguments for %s", e.name))
+	}
+	return nil
+}
+
+func (e *function) eval(rec record) (any, error) {
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "COALESCE":
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if args[0] != nil && args[1] != nil && valuesEqual(args[0], args[1]) {
+			return nil, nil
+		}
+		return args[0], nil
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	s := formatValue(args[0])
+
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(s), nil
+	case "UPPER":
+		return strings.ToUpper(s), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(len([]rune(s))), nil
+	case "TRIM":
+		chars := " "
+		if e.chars != nil {
+			v, err := e.chars.eval(rec)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			chars = formatValue(v)
+		}
+		switch e.trim {
+		case "LEADING":
+			return strings.TrimLeft(s, chars), nil
+		case "TRAILING":
+			return strings.TrimRight(s, chars), nil
+		default:
+			return strings.Trim(s, chars), nil
+		}
+	default: // SUBSTRING, 1-based as in SQL
+		runes := []rune(s)
+		start, ok := toInt(args[1])
+		if !ok {
+			return nil, evalError("EvaluatorInvalidArguments", "SUBSTRING expects an integer start")
+		}
+		end := int64(len(runes)) + 1
+		if len(args) == 3 {
+			length, ok := toInt(args[2])
+			if !ok || length < 0 {
+				return nil, evalError("EvaluatorInvalidArguments", "SUBSTRING expects a non-negative integer length")
+			}
+			end = start + length
+		}
+		start = max(start, 1)
+		end = min(end, int64(len(runes))+1)
+		if start >= end {
+			return "", nil
+		}
+		return string(runes[start-1 : end-1]), nil
+	}
+}
+
+var aggregateFunctions = map[string]bool{"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true}
+
+// aggregate accumulates over the records that match and evaluates to the
+// result once all were seen
+type aggregate struct {
+	name string
+	arg  expr // nil for COUNT(*)
+
+	count int64
+	sum   any
+	best  any // MIN or MAX
+}
+
+func (e *aggregate) accumulate(rec record) error {
+	if e.arg == nil {
+		e.count++
+		return nil
+	}
+	v, err := e.arg.eval(rec)
+	if err != nil || v == nil {
+		return err
+	}
+	e.count++
+
+	switch e.name {
+	case "SUM", "AVG":
+		n, ok := toNumber(v)
+		if !ok {
+			return evalError("EvaluatorInvalidArguments", fmt.Sprintf("%s of %s, which is not a number", e.name, formatValue(v)))
+		}
+		switch sum := e.sum.(type) {
+		case nil:
+			e.sum = n
+		case int64:
+			if i, ok := n.(int64); ok {
+				e.sum = sum + i
+			} else {
+				e.sum = float64(sum) + toFloat(n)
+			}
+		case float64:
+			e.sum = sum + toFloat(n)
+		}
+	case "MIN", "MAX":
+		if n, ok := toNumber(v); ok {
+			v = n
+		}
+		if e.best == nil {
+			e.best = v
+			return nil
+		}
+		c, err := compareValues(v, e.best)
+		if err != nil {
+			return err
+		}
+		if (e.name == "MIN" && c < 0) || (e.name == "MAX" && c > 0) {
+			e.best = v
+		}
+	}
+	return nil
+}
+
+func (e *aggregate) eval(record) (any, error) {
+	switch e.name {
+	case "COUNT":
+		return e.count, nil
+	case "SUM":
+		return e.sum, nil
+	case "AVG":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return toFloat(e.sum) / float64(e.count), nil
+	default:
+		return e.best, nil
+	}
+}
+
+// walk calls fn for e and every expression below it
+func walk(e expr, fn func(expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch e := e.(type) {
+	case *logical:
+		walk(e.left, fn)
+		walk(e.right, fn)
+	case *not:
+		walk(e.operand, fn)
+	case *comparison:
+		walk(e.left, fn)
+		walk(e.right, fn)
+	case *isNull:
+		walk(e.operand, fn)
+	case *like:
+		walk(e.operand, fn)
+		walk(e.pattern, fn)
+		walk(e.escape, fn)
+	case *inList:
+		walk(e.operand, fn)
+		for _, item := range e.list {
+			walk(item, fn)
+		}
+	case *between:
+		walk(e.operand, fn)
+		walk(e.low, fn)
+		walk(e.high, fn)
+	case *arithmetic:
+		walk(e.left, fn)
+		walk(e.right, fn)
+	case *cast:
+		walk(e.operand, fn)
+	case *function:
+		for _, arg := range e.args {
+			walk(arg, fn)
+		}
+		walk(e.chars, fn)
+	case *aggregate:
+		walk(e.arg, fn)
+	}
+}
+
+func containsAggregate(e expr) bool {
+	found := false
+	walk(e, func(e expr) {
+		if _, ok := e.(*aggregate); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// containsColumn reports whether e references a column outside aggregates
+func containsColumn(e expr) bool {
+	switch e := e.(type) {
+	case *column:
+		return true
+	case *aggregate:
+		return false
+	case nil:
+		return false
+	default:
+		found := false
+		walk(e, func(child expr) {
+			if child != e && !found {
+				found = containsColumn(child)
+			}
+		})
+		return found
+	}
+}
+
+// toNumber returns v as int64 or float64; strings, as CSV fields are, are
+// parsed
+func toNumber(v any) (any, bool) {
+	switch v := v.(type) {
+	case int64, float64:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(n any) float64 {
+	switch n := n.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func toInt(v any) (int64, bool) {
+	n, ok := toNumber(v)
+	if !ok {
+		return 0, false
+	}
+	if f, ok := n.(float64); ok {
+		return int64(f), f == math.Trunc(f)
+	}
+	return n.(int64), true
+}
+
+// valuesEqual compares numbers numerically, numbers with strings by parsing
+// the string, and everything else by its text
+func valuesEqual(a, b any) bool {
+	if c, err := compareValues(a, b); err == nil {
+		return c == 0
+	}
+	return formatValue(a) == formatValue(b)
+}
+
+// compareValues orders two non-NULL values
+func compareValues(a, b any) (int, error) {
+	an, aNumber := toNumber(a)
+	bn, bNumber := toNumber(b)
+	_, aString := a.(string)
+	_, bString := b.(string)
+	switch {
+	case aNumber && bNumber && !(aString && bString):
+		ai, aInt := an.(int64)
+		bi, bInt := bn.(int64)
+		if aInt && bInt {
+			return cmpOrdered(ai, bi), nil
+		}
+		return cmpOrdered(toFloat(an), toFloat(bn)), nil
+	case aString && bString:
+		return strings.Compare(a.(string), b.(string)), nil
+	}
+	if ab, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			if ab == bb {
+				return 0, nil
+			}
+			if !ab {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, evalError("EvaluatorInvalidArguments", fmt.Sprintf("cannot compare %s with %s", formatValue(a), formatValue(b)))
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// formatValue returns the text of a value, as CSV output and string
+// functions use it
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		var b strings.Builder
+		writeJSONValue(&b, v)
+		return b.String()
+	}
+}
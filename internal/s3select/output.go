@@ -0,0 +1,142 @@
+package s3select
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+)
+
+// recordWriter serializes one output record
+type recordWriter interface {
+	write(buf *bytes.Buffer, names []string, values []any)
+}
+
+func newRecordWriter(output OutputSerialization) (recordWriter, error) {
+	switch {
+	case output.CSV != nil && output.JSON != nil, output.CSV == nil && output.JSON == nil:
+		return nil, requestError("MissingRequiredParameter", "OutputSerialization needs exactly one of CSV or JSON")
+	case output.JSON != nil:
+		return &jsonWriter{delimiter: withDefault(output.JSON.RecordDelimiter, "\n")}, nil
+	}
+
+	w := &csvWriter{
+		fieldDelimiter:  withDefault(output.CSV.FieldDelimiter, ","),
+		recordDelimiter: withDefault(output.CSV.RecordDelimiter, "\n"),
+		quote:           withDefault(output.CSV.QuoteCharacter, `"`),
+		escape:          withDefault(output.CSV.QuoteEscapeCharacter, `"`),
+	}
+	switch strings.ToUpper(output.CSV.QuoteFields) {
+	case "", "ASNEEDED":
+	case "ALWAYS":
+		w.always = true
+	default:
+		return nil, requestError("InvalidQuoteFields", "QuoteFields must be ALWAYS or ASNEEDED")
+	}
+	return w, nil
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+type csvWriter struct {
+	fieldDelimiter, recordDelimiter string
+	quote, escape                   string
+	always                          bool
+}
+
+func (w *csvWriter) write(buf *bytes.Buffer, _ []string, values []any) {
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(w.fieldDelimiter)
+		}
+		field := formatValue(v)
+		if w.always || strings.Contains(field, w.fieldDelimiter) || strings.Contains(field, w.quote) ||
+			strings.Contains(field, w.recordDelimiter) || strings.ContainsAny(field, "\r\n") {
+			buf.WriteString(w.quote)
+			buf.WriteString(strings.ReplaceAll(field, w.quote, w.escape+w.quote))
+			buf.WriteString(w.quote)
+		} else {
+			buf.WriteString(field)
+		}
+	}
+	buf.WriteString(w.recordDelimiter)
+}
+
+type jsonWriter struct {
+	delimiter string
+}
+
+func (w *jsonWriter) write(buf *bytes.Buffer, names []string, values []any) {
+	o := &object{keys: make([]string, 0, len(names)), values: make(map[string]any, len(names))}
+	for i, name := range names {
+		if _, seen := o.values[name]; !seen {
+			o.keys = append(o.keys, name)
+		}
+		o.values[name] = values[i]
+	}
+	var b strings.Builder
+	writeJSONValue(&b, o)
+	buf.WriteString(b.String())
+	buf.WriteString(w.delimiter)
+}
+
+// eventWriter frames the response of SelectObjectContent as event stream
+// messages, flushing each one to the client
+type eventWriter struct {
+	w       io.Writer
+	encoder *eventstream.Encoder
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{w: w, encoder: eventstream.NewEncoder()}
+}
+
+func (e *eventWriter) send(headers eventstream.Headers, payload []byte) error {
+	if err := e.encoder.Encode(e.w, eventstream.Message{Headers: headers, Payload: payload}); err != nil {
+		return err
+	}
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func event(eventType, contentType string) eventstream.Headers {
+	headers := eventstream.Headers{}
+	headers.Set(":message-type", eventstream.StringValue("event"))
+	headers.Set(":event-type", eventstream.StringValue(eventType))
+	if contentType != "" {
+		headers.Set(":content-type", eventstream.StringValue(contentType))
+	}
+	return headers
+}
+
+func (e *eventWriter) records(payload []byte) error {
+	return e.send(event("Records", "application/octet-stream"), payload)
+}
+
+// stats sends a Stats or Progress event
+func (e *eventWriter) stats(eventType string, details []byte) error {
+	payload := append([]byte("<"+eventType+">"), details...)
+	payload = append(payload, "</"+eventType+">"...)
+	return e.send(event(eventType, "text/xml"), payload)
+}
+
+func (e *eventWriter) end() error {
+	return e.send(event("End", ""), nil)
+}
+
+func (e *eventWriter) error(code, message string) error {
+	headers := eventstream.Headers{}
+	headers.Set(":message-type", eventstream.StringValue("error"))
+	headers.Set(":error-code", eventstream.StringValue(code))
+	headers.Set(":error-message", eventstream.StringValue(message))
+	return e.send(headers, nil)
+}
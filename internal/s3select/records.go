@@ -0,0 +1,356 @@
+package s3select
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// recordReader yields the records of an object's plaintext
+type recordReader interface {
+	// next returns the next record, or io.EOF after the last one
+	next() (record, error)
+}
+
+// csvRecord is a CSV row; header maps column names to positions when the
+// first row names them
+type csvRecord struct {
+	fields []string
+	header *csvHeader
+}
+
+type csvHeader struct {
+	names []string
+	index map[string]int // exact names
+	fold  map[string]int // lower-case names
+}
+
+func newCSVHeader(names []string) *csvHeader {
+	h := &csvHeader{names: names, index: make(map[string]int), fold: make(map[string]int)}
+	for i, name := range names {
+		if _, ok := h.index[name]; !ok {
+			h.index[name] = i
+		}
+		if _, ok := h.fold[strings.ToLower(name)]; !ok {
+			h.fold[strings.ToLower(name)] = i
+		}
+	}
+	return h
+}
+
+func (r *csvRecord) lookup(path []pathElem) any {
+	if len(path) != 1 || path[0].name == "" {
+		return nil
+	}
+	i, ok := r.position(path[0])
+	if !ok || i >= len(r.fields) {
+		return nil
+	}
+	return r.fields[i]
+}
+
+// position resolves a column name: a header name, else _1, _2 and so on
+func (r *csvRecord) position(elem pathElem) (int, bool) {
+	if r.header != nil {
+		if i, ok := r.header.index[elem.name]; ok {
+			return i, true
+		}
+		if !elem.quoted {
+			if i, ok := r.header.fold[strings.ToLower(elem.name)]; ok {
+				return i, true
+			}
+		}
+	}
+	if strings.HasPrefix(elem.name, "_") {
+		if n, err := strconv.Atoi(elem.name[1:]); err == nil && n >= 1 {
+			return n - 1, true
+		}
+	}
+	return 0, false
+}
+
+// names returns the output names of SELECT * over CSV
+func (r *csvRecord) names() []string {
+	names := make([]string, len(r.fields))
+	for i := range r.fields {
+		if r.header != nil && i < len(r.header.names) {
+			names[i] = r.header.names[i]
+		} else {
+			names[i] = "_" + strconv.Itoa(i+1)
+		}
+	}
+	return names
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header *csvHeader
+}
+
+func newCSVReader(r io.Reader, input *CSVInput) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = false
+
+	if input.FieldDelimiter != "" {
+		delimiter := []rune(input.FieldDelimiter)
+		if len(delimiter) != 1 {
+			return nil, requestError("InvalidFieldDelimiter", "FieldDelimiter must be a single character")
+		}
+		reader.Comma = delimiter[0]
+	}
+	if input.Comments != "" {
+		comment := []rune(input.Comments)
+		if len(comment) != 1 {
+			return nil, requestError("InvalidRequestParameter", "Comments must be a single character")
+		}
+		reader.Comment = comment[0]
+	}
+
+	c := &csvReader{reader: reader}
+	switch strings.ToUpper(input.FileHeaderInfo) {
+	case "", "NONE":
+	case "USE", "IGNORE":
+		names, err := reader.Read()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, csvError(err)
+		}
+		if strings.EqualFold(input.FileHeaderInfo, "USE") {
+			c.header = newCSVHeader(names)
+		}
+	default:
+		return nil, requestError("InvalidFileHeaderInfo", "FileHeaderInfo must be USE, IGNORE or NONE")
+	}
+	return c, nil
+}
+
+func (c *csvReader) next() (record, error) {
+	fields, err := c.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, csvError(err)
+	}
+	return &csvRecord{fields: fields, header: c.header}, nil
+}
+
+func csvError(err error) error {
+	return evalError("CSVParsingError", "failed to parse CSV input: "+err.Error())
+}
+
+// object is a JSON object that keeps the order of its keys
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) get(elem pathElem) (any, bool) {
+	if v, ok := o.values[elem.name]; ok {
+		return v, true
+	}
+	if !elem.quoted {
+		for _, key := range o.keys {
+			if strings.EqualFold(key, elem.name) {
+				return o.values[key], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// jsonRecord is a JSON value below the FROM path
+type jsonRecord struct{ value any }
+
+func (r *jsonRecord) lookup(path []pathElem) any {
+	return navigate(r.value, path)
+}
+
+// navigate follows path from v; [*] is only meaningful in FROM
+func navigate(v any, path []pathElem) any {
+	for _, elem := range path {
+		switch {
+		case elem.indexed:
+			list, ok := v.([]any)
+			if !ok || elem.index >= len(list) {
+				return nil
+			}
+			v = list[elem.index]
+		case elem.wildcard:
+			return nil
+		default:
+			o, ok := v.(*object)
+			if !ok {
+				return nil
+			}
+			if v, ok = o.get(elem); !ok {
+				return nil
+			}
+		}
+	}
+	return v
+}
+
+// jsonReader reads a sequence of JSON values. DOCUMENT and LINES input
+// differ only in where values may span lines, which the decoder does not
+// care about. Each value is expanded through the FROM path.
+type jsonReader struct {
+	decoder *json.Decoder
+	from    []pathElem
+	pending []any
+}
+
+func newJSONReader(r io.Reader, input *JSONInput, from []pathElem) (*jsonReader, error) {
+	switch strings.ToUpper(input.Type) {
+	case "DOCUMENT", "LINES":
+	default:
+		return nil, requestError("InvalidJsonType", "JSON Type must be DOCUMENT or LINES")
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &jsonReader{decoder: decoder, from: from}, nil
+}
+
+func (j *jsonReader) next() (record, error) {
+	for len(j.pending) == 0 {
+		v, err := decodeJSONValue(j.decoder)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, evalError("JSONParsingError", "failed to parse JSON input: "+err.Error())
+		}
+		j.pending = expandPath(v, j.from, true)
+	}
+	v := j.pending[0]
+	j.pending = j.pending[1:]
+	return &jsonRecord{value: v}, nil
+}
+
+// expandPath applies the FROM path to a top-level value; [*] yields every
+// element of an array. A leading [*] over a value that is not an array
+// yields the value itself, as S3Object[*] does for JSON lines.
+func expandPath(v any, path []pathElem, top bool) []any {
+	if len(path) == 0 {
+		return []any{v}
+	}
+	elem := path[0]
+	if !elem.wildcard {
+		next := navigate(v, path[:1])
+		if next == nil {
+			return nil
+		}
+		return expandPath(next, path[1:], false)
+	}
+	list, ok := v.([]any)
+	if !ok {
+		if top {
+			return expandPath(v, path[1:], false)
+		}
+		return nil
+	}
+	var values []any
+	for _, item := range list {
+		values = append(values, expandPath(item, path[1:], false)...)
+	}
+	return values
+}
+
+// decodeJSONValue decodes the next value keeping the order of object keys
+func decodeJSONValue(decoder *json.Decoder) (any, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			o := &object{values: make(map[string]any)}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected %v as object key", keyToken)
+				}
+				value, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				if _, seen := o.values[key]; !seen {
+					o.keys = append(o.keys, key)
+				}
+				o.values[key] = value
+			}
+			_, err := decoder.Token() // }
+			return o, err
+		case '[':
+			list := []any{}
+			for decoder.More() {
+				value, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := decoder.Token() // ]
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	default: // string, bool, nil
+		return t, nil
+	}
+}
+
+// writeJSONValue encodes a value as JSON
+func writeJSONValue(b *strings.Builder, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case *object:
+		b.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, key)
+			b.WriteByte(':')
+			writeJSONValue(b, v.values[key])
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(b, item)
+		}
+		b.WriteByte(']')
+	case string:
+		writeJSONString(b, v)
+	default:
+		b.WriteString(formatValue(v))
+	}
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	encoded, _ := json.Marshal(s)
+	b.Write(encoded)
+}
@@ -0,0 +1,361 @@
+// Package s3select evaluates S3 Select (SelectObjectContent) requests in the
+// proxy. Backends only see ciphertext, so Select cannot be pushed down to
+// them: the proxy decrypts the object and runs the SQL expression over its
+// plaintext itself, answering in S3's event stream framing.
+//
+// The SQL subset covers what S3 Select offers for CSV and JSON input:
+// projections with aliases, WHERE with comparisons, AND/OR/NOT, LIKE, IN,
+// BETWEEN and IS NULL, arithmetic and ||, CAST, the string functions LOWER,
+// UPPER, TRIM, SUBSTRING and CHAR_LENGTH, COALESCE and NULLIF, the
+// aggregates COUNT, SUM, AVG, MIN and MAX, and LIMIT. Parquet input and scan
+// ranges are not supported.
+package s3select
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxRequestSize bounds the XML body of a SelectObjectContent request
+const MaxRequestSize = 256 << 10
+
+// recordsEventSize is the size at which buffered records are sent
+const recordsEventSize = 128 << 10
+
+// Request is the body of a SelectObjectContent request
+type Request struct {
+	XMLName             xml.Name                     `xml:"SelectObjectContentRequest"`
+	Expression          string                       `xml:"Expression"`
+	ExpressionType      string                       `xml:"ExpressionType"`
+	RequestProgress     *RequestProgress             `xml:"RequestProgress"`
+	InputSerialization  InputSerialization           `xml:"InputSerialization"`
+	OutputSerialization OutputSerialization          `xml:"OutputSerialization"`
+	ScanRange           *struct{ Start, End string } `xml:"ScanRange"`
+}
+
+// RequestProgress asks for Progress events
+type RequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+// InputSerialization describes the format of the object
+type InputSerialization struct {
+	CompressionType string     `xml:"CompressionType"`
+	CSV             *CSVInput  `xml:"CSV"`
+	JSON            *JSONInput `xml:"JSON"`
+	Parquet         *struct{}  `xml:"Parquet"`
+}
+
+// CSVInput describes CSV objects
+type CSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo"`
+	Comments                   string `xml:"Comments"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter            string `xml:"RecordDelimiter"`
+	FieldDelimiter             string `xml:"FieldDelimiter"`
+	QuoteCharacter             string `xml:"QuoteCharacter"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter"`
+}
+
+// JSONInput describes JSON objects
+type JSONInput struct {
+	Type string `xml:"Type"`
+}
+
+// OutputSerialization describes the format of the returned records
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:"CSV"`
+	JSON *JSONOutput `xml:"JSON"`
+}
+
+// CSVOutput describes CSV records
+type CSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter      string `xml:"RecordDelimiter"`
+	FieldDelimiter       string `xml:"FieldDelimiter"`
+	QuoteCharacter       string `xml:"QuoteCharacter"`
+}
+
+// JSONOutput describes JSON records
+type JSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter"`
+}
+
+// Error is an S3 Select error. Errors of Compile are returned as HTTP
+// errors; errors while the records are evaluated are sent as error events.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func requestError(code, message string) *Error {
+	return &Error{StatusCode: http.StatusBadRequest, Code: code, Message: message}
+}
+
+func parseError(pos int, message string) *Error {
+	return requestError("ParseUnexpectedToken", fmt.Sprintf("%s at position %d", message, pos+1))
+}
+
+func unsupported(what string) *Error {
+	return requestError("UnsupportedSyntax", what+" is not supported")
+}
+
+func evalError(code, message string) *Error {
+	return &Error{StatusCode: http.StatusBadRequest, Code: code, Message: message}
+}
+
+// Query is a compiled SelectObjectContent request
+type Query struct {
+	request *Request
+	query   *query
+	output  recordWriter
+}
+
+// Compile validates a request and parses its expression
+func Compile(req *Request) (*Query, error) {
+	if !strings.EqualFold(req.ExpressionType, "SQL") {
+		return nil, requestError("InvalidExpressionType", "The ExpressionType is invalid. Only SQL expressions are supported.")
+	}
+	if req.ScanRange != nil {
+		return nil, &Error{StatusCode: http.StatusNotImplemented, Code: "NotImplemented", Message: "ScanRange is not supported for encrypted objects"}
+	}
+
+	input := req.InputSerialization
+	switch formats := btoi(input.CSV != nil) + btoi(input.JSON != nil) + btoi(input.Parquet != nil); {
+	case input.Parquet != nil:
+		return nil, &Error{StatusCode: http.StatusNotImplemented, Code: "NotImplemented", Message: "Parquet input is not supported for encrypted objects"}
+	case formats != 1:
+		return nil, requestError("MissingRequiredParameter", "InputSerialization needs exactly one of CSV or JSON")
+	}
+	switch strings.ToUpper(input.CompressionType) {
+	case "", "NONE", "GZIP", "BZIP2":
+	default:
+		return nil, requestError("InvalidCompressionFormat", "CompressionType must be NONE, GZIP or BZIP2")
+	}
+	if csvInput := input.CSV; csvInput != nil {
+		if csvInput.QuoteCharacter != "" && csvInput.QuoteCharacter != `"` ||
+			csvInput.QuoteEscapeCharacter != "" && csvInput.QuoteEscapeCharacter != `"` {
+			return nil, requestError("InvalidQuoteFields", `only " is supported as QuoteCharacter and QuoteEscapeCharacter of CSV input`)
+		}
+		if csvInput.RecordDelimiter != "" && csvInput.RecordDelimiter != "\n" && csvInput.RecordDelimiter != "\r\n" {
+			return nil, requestError("InvalidRequestParameter", "only \\n and \\r\\n are supported as RecordDelimiter of CSV input")
+		}
+	}
+
+	output, err := newRecordWriter(req.OutputSerialization)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.from) > 0 && input.JSON == nil {
+		return nil, unsupported("a path after S3Object for CSV input")
+	}
+	return &Query{request: req, query: q, output: output}, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Run evaluates the query over plaintext and writes the event stream to w:
+// Records events, Progress events if requested, then Stats and End, or an
+// error event. Only failures to write are returned.
+func (q *Query) Run(ctx context.Context, plaintext io.Reader, w io.Writer) error {
+	events := newEventWriter(w)
+	scanned := &countingReader{Reader: plaintext}
+	processed := &countingReader{}
+	var returned int64
+
+	stats := func() []byte {
+		return fmt.Appendf(nil, "<BytesScanned>%d</BytesScanned><BytesProcessed>%d</BytesProcessed><BytesReturned>%d</BytesReturned>",
+			scanned.n, processed.n, returned)
+	}
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		returned += int64(buf.Len())
+		if err := events.records(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+		if q.request.RequestProgress != nil && q.request.RequestProgress.Enabled {
+			return events.stats("Progress", stats())
+		}
+		return nil
+	}
+	fail := func(err error) error {
+		if flushErr := flush(); flushErr != nil {
+			return flushErr
+		}
+		var selectErr *Error
+		if !errors.As(err, &selectErr) {
+			selectErr = &Error{Code: "InternalError", Message: err.Error()}
+		}
+		return events.error(selectErr.Code, selectErr.Message)
+	}
+
+	reader, err := q.records(scanned, processed)
+	if err != nil {
+		return fail(err)
+	}
+	var emitted int64
+	for q.query.limit < 0 || emitted < q.query.limit || q.query.aggregates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		if q.query.where != nil {
+			match, err := evalBool(q.query.where, rec)
+			if err != nil {
+				return fail(err)
+			}
+			if match == nil || !*match {
+				continue
+			}
+		}
+		if q.query.aggregates {
+			if err := q.accumulate(rec); err != nil {
+				return fail(err)
+			}
+			continue
+		}
+		if err := q.project(&buf, rec); err != nil {
+			return fail(err)
+		}
+		emitted++
+		if buf.Len() >= recordsEventSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if q.query.aggregates && q.query.limit != 0 {
+		if err := q.project(&buf, nil); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := events.stats("Stats", stats()); err != nil {
+		return err
+	}
+	return events.end()
+}
+
+// records opens the record reader over the decompressed plaintext
+func (q *Query) records(scanned io.Reader, processed *countingReader) (recordReader, error) {
+	input := q.request.InputSerialization
+	switch strings.ToUpper(input.CompressionType) {
+	case "GZIP":
+		gz, err := gzip.NewReader(scanned)
+		if err != nil {
+			return nil, evalError("InvalidCompressionFormat", "the object is not GZIP compressed: "+err.Error())
+		}
+		processed.Reader = gz
+	case "BZIP2":
+		processed.Reader = bzip2.NewReader(scanned)
+	default:
+		processed.Reader = scanned
+	}
+
+	if input.CSV != nil {
+		return newCSVReader(processed, input.CSV)
+	}
+	return newJSONReader(processed, input.JSON, q.query.from)
+}
+
+func (q *Query) accumulate(rec record) error {
+	for _, item := range q.query.items {
+		var err error
+		walk(item.expr, func(e expr) {
+			if a, ok := e.(*aggregate); ok && err == nil {
+				err = a.accumulate(rec)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project writes the selected columns of a record, or the aggregates for a
+// nil record
+func (q *Query) project(buf *bytes.Buffer, rec record) error {
+	if q.query.star {
+		switch rec := rec.(type) {
+		case *csvRecord:
+			values := make([]any, len(rec.fields))
+			for i, field := range rec.fields {
+				values[i] = field
+			}
+			q.output.write(buf, rec.names(), values)
+		case *jsonRecord:
+			if o, ok := rec.value.(*object); ok {
+				values := make([]any, len(o.keys))
+				for i, key := range o.keys {
+					values[i] = o.values[key]
+				}
+				q.output.write(buf, o.keys, values)
+			} else {
+				q.output.write(buf, []string{"_1"}, []any{rec.value})
+			}
+		}
+		return nil
+	}
+
+	names := make([]string, len(q.query.items))
+	values := make([]any, len(q.query.items))
+	for i, item := range q.query.items {
+		v, err := item.expr.eval(rec)
+		if err != nil {
+			return err
+		}
+		names[i], values[i] = item.name, v
+	}
+	q.output.write(buf, names, values)
+	return nil
+}
@@ -0,0 +1,214 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const peopleCSV = "name,age,city\nAlice,34,Berlin\nBob,27,\"Hamburg, Port\"\nCarol,41,Munich\n"
+
+// result is a decoded SelectObjectContent event stream
+type result struct {
+	records   string
+	events    []string
+	errorCode string
+}
+
+func run(t *testing.T, req *Request, input []byte) result {
+	t.Helper()
+	q, err := Compile(req)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, q.Run(context.Background(), bytes.NewReader(input), &out))
+
+	var res result
+	decoder := eventstream.NewDecoder()
+	for {
+		msg, err := decoder.Decode(&out, nil)
+		if errors.Is(err, io.EOF) {
+			return res
+		}
+		require.NoError(t, err)
+		if msg.Headers.Get(":message-type").String() == "error" {
+			res.errorCode = msg.Headers.Get(":error-code").String()
+			res.events = append(res.events, "error")
+			continue
+		}
+		eventType := msg.Headers.Get(":event-type").String()
+		res.events = append(res.events, eventType)
+		if eventType == "Records" {
+			res.records += string(msg.Payload)
+		}
+	}
+}
+
+func csvRequest(expression string) *Request {
+	return &Request{
+		Expression:          expression,
+		ExpressionType:      "SQL",
+		InputSerialization:  InputSerialization{CSV: &CSVInput{FileHeaderInfo: "USE"}},
+		OutputSerialization: OutputSerialization{CSV: &CSVOutput{}},
+	}
+}
+
+func TestRun_CSV(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"SELECT * FROM S3Object", "Alice,34,Berlin\nBob,27,\"Hamburg, Port\"\nCarol,41,Munich\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.age > 30", "Alice\nCarol\n"},
+		{"SELECT name, CAST(age AS INT) + 1 FROM S3Object WHERE city LIKE 'H%'", "Bob,28\n"},
+		{"SELECT _1 FROM S3Object WHERE _3 IN ('Munich', 'Berlin') LIMIT 1", "Alice\n"},
+		{`SELECT UPPER("name") FROM S3Object WHERE age BETWEEN 30 AND 40`, "ALICE\n"},
+		{"SELECT COUNT(*), SUM(age), MIN(name), MAX(CAST(age AS INT)) FROM S3Object", "3,102,Alice,41\n"},
+		{"SELECT AVG(age) FROM S3Object s WHERE s.city <> 'Berlin'", "34\n"},
+		{"SELECT name FROM S3Object WHERE NOT (age < 30 OR city = 'Munich')", "Alice\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			res := run(t, csvRequest(tt.expression), []byte(peopleCSV))
+			assert.Empty(t, res.errorCode)
+			assert.Equal(t, tt.want, res.records)
+			assert.Equal(t, []string{"Records", "Stats", "End"}, res.events)
+		})
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	lines := `{"id":1,"user":{"name":"alice","tags":["a","b"]},"score":9.5}
+{"id":2,"user":{"name":"bob","tags":[]},"score":null}
+{"id":3,"user":{"name":"carol","tags":["c"]},"score":7}
+`
+	req := &Request{
+		Expression:          "SELECT s.id, s.user.name AS who, s.user.tags[0] FROM S3Object[*] s WHERE s.score IS NOT NULL",
+		ExpressionType:      "SQL",
+		InputSerialization:  InputSerialization{JSON: &JSONInput{Type: "LINES"}},
+		OutputSerialization: OutputSerialization{JSON: &JSONOutput{}},
+	}
+	res := run(t, req, []byte(lines))
+	assert.Equal(t, "{\"id\":1,\"who\":\"alice\",\"_3\":\"a\"}\n{\"id\":3,\"who\":\"carol\",\"_3\":\"c\"}\n", res.records)
+
+	// A path in FROM selects the records within a document
+	document := `{"items":[{"sku":"x","qty":2},{"sku":"y","qty":5}]}`
+	req.InputSerialization.JSON.Type = "DOCUMENT"
+	req.Expression = "SELECT * FROM S3Object[*].items[*] i WHERE i.qty > 3"
+	res = run(t, req, []byte(document))
+	assert.Equal(t, "{\"sku\":\"y\",\"qty\":5}\n", res.records)
+
+	req.OutputSerialization = OutputSerialization{CSV: &CSVOutput{QuoteFields: "ALWAYS"}}
+	res = run(t, req, []byte(document))
+	assert.Equal(t, "\"y\",\"5\"\n", res.records)
+}
+
+func TestRun_GZIP(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(peopleCSV))
+	require.NoError(t, gz.Close())
+
+	req := csvRequest("SELECT COUNT(*) FROM S3Object")
+	req.InputSerialization.CompressionType = "GZIP"
+	req.RequestProgress = &RequestProgress{Enabled: true}
+	res := run(t, req, compressed.Bytes())
+	assert.Equal(t, "3\n", res.records)
+	assert.Equal(t, []string{"Records", "Progress", "Stats", "End"}, res.events)
+}
+
+func TestRun_ErrorEvent(t *testing.T) {
+	res := run(t, csvRequest("SELECT CAST(city AS INT) FROM S3Object"), []byte(peopleCSV))
+	assert.Equal(t, "CastFailed", res.errorCode)
+	assert.Equal(t, []string{"error"}, res.events)
+
+	res = run(t, csvRequest("SELECT age / 0 FROM S3Object"), []byte(peopleCSV))
+	assert.Equal(t, "DivisionByZero", res.errorCode)
+}
+
+func TestCompile_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *Request
+		code string
+	}{
+		{"syntax", csvRequest("SELECT FROM S3Object"), "ParseUnexpectedToken"},
+		{"not S3Object", csvRequest("SELECT * FROM table"), "ParseUnexpectedToken"},
+		{"unknown function", csvRequest("SELECT MD5(name) FROM S3Object"), "UnsupportedSyntax"},
+		{"mixed aggregate", csvRequest("SELECT name, COUNT(*) FROM S3Object"), "UnsupportedSyntax"},
+		{"expression type", &Request{Expression: "SELECT * FROM S3Object", ExpressionType: "XPATH"}, "InvalidExpressionType"},
+		{"parquet", &Request{
+			Expression:          "SELECT * FROM S3Object",
+			ExpressionType:      "SQL",
+			InputSerialization:  InputSerialization{Parquet: &struct{}{}},
+			OutputSerialization: OutputSerialization{CSV: &CSVOutput{}},
+		}, "NotImplemented"},
+		{"no output", &Request{
+			Expression:         "SELECT * FROM S3Object",
+			ExpressionType:     "SQL",
+			InputSerialization: InputSerialization{CSV: &CSVInput{}},
+		}, "MissingRequiredParameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.req)
+			var selectErr *Error
+			require.ErrorAs(t, err, &selectErr)
+			assert.Equal(t, tt.code, selectErr.Code)
+		})
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	rec := &csvRecord{fields: []string{"  Hello ", "", "12"}}
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{"TRIM(_1)", "Hello"},
+		{"TRIM(LEADING FROM _1)", "Hello "},
+		{"SUBSTRING('abcdef' FROM 2 FOR 3)", "bcd"},
+		{"SUBSTRING('abcdef', 4)", "def"},
+		{"CHAR_LENGTH(TRIM(_1))", int64(5)},
+		{"COALESCE(NULL, _3)", "12"},
+		{"NULLIF(_3, '12')", nil},
+		{"_3 * 2.5", float64(30)},
+		{"'a' || _3", "a12"},
+		{"_3 = 12", true},
+		{"NULL = 1", nil},
+		{"NULL AND FALSE", false},
+		{"NULL OR TRUE", true},
+		{"_4 IS NULL", true},
+		{"'50%' LIKE '50!%' ESCAPE '!'", true},
+		{"'abc' NOT LIKE 'a_c'", false},
+		{"CAST('1.9' AS INT)", int64(1)},
+		{"-_3 + 2", int64(-10)},
+		{"7 % 4", int64(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			q, err := parseQuery("SELECT " + tt.expression + " FROM S3Object")
+			require.NoError(t, err)
+			got, err := q.items[0].expr.eval(rec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRun_HeaderNamesCaseInsensitive(t *testing.T) {
+	res := run(t, csvRequest(`SELECT NAME, "city" FROM S3Object WHERE "name" = 'Bob'`), []byte(peopleCSV))
+	assert.Equal(t, "Bob,\"Hamburg, Port\"\n", res.records)
+
+	// Quoted names match exactly
+	res = run(t, csvRequest(`SELECT "NAME" FROM S3Object LIMIT 1`), []byte(peopleCSV))
+	assert.Equal(t, "\n", res.records)
+	assert.True(t, strings.HasPrefix(strings.Join(res.events, ","), "Records"))
+}
@@ -0,0 +1,676 @@
+package s3select
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind classifies the tokens of a SELECT statement
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits a SELECT statement into tokens. Keywords are identifiers; the
+// parser compares them case-insensitively.
+func lex(sql string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '\'' || c == '"':
+			text, n, err := lexQuoted(sql[i:], c)
+			if err != nil {
+				return nil, parseError(i, err.Error())
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i += n
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: sql[i:j], pos: i})
+			i = j
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			j := i
+			for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.') {
+				j++
+			}
+			if j < len(sql) && (sql[j] == 'e' || sql[j] == 'E') {
+				j++
+				if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+					j++
+				}
+				for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+					j++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: sql[i:j], pos: i})
+			i = j
+		default:
+			symbol := string(c)
+			if i+1 < len(sql) {
+				switch two := sql[i : i+2]; two {
+				case "<=", ">=", "<>", "!=", "||":
+					symbol = two
+				}
+			}
+			if !strings.Contains("*,().[]=<>+-/%!|", symbol[:1]) || symbol == "!" || symbol == "|" {
+				return nil, parseError(i, fmt.Sprintf("unexpected character %q", c))
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, pos: i})
+			i += len(symbol)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(sql)}), nil
+}
+
+// lexQuoted reads a string or quoted identifier; doubled quotes escape
+// themselves
+func lexQuoted(s string, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated %c", quote)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// query is a parsed SELECT statement
+type query struct {
+	star       bool
+	items      []selectItem
+	alias      string     // of S3Object, empty without one
+	from       []pathElem // below S3Object, JSON only
+	where      expr
+	limit      int64 // -1 without LIMIT
+	aggregates bool
+}
+
+type selectItem struct {
+	expr expr
+	name string // output name
+}
+
+// parser is a recursive descent parser over the tokens of a statement
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseQuery parses the S3 Select subset of SQL: SELECT with a projection,
+// FROM S3Object with an optional alias and, for JSON, a path, WHERE and
+// LIMIT.
+func parseQuery(sql string) (*query, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &query{limit: -1}
+
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.acceptSymbol("*") {
+		q.star = true
+	} else {
+		for {
+			item, err := p.parseSelectItem(len(q.items) + 1)
+			if err != nil {
+				return nil, err
+			}
+			q.items = append(q.items, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		return nil, parseError(t.pos, "expected S3Object")
+	}
+	if q.from, err = p.parsePathTail(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+			return nil, parseError(t.pos, "expected an alias")
+		}
+		q.alias = t.text
+	} else if t := p.peek(); t.kind == tokenIdent && !isReserved(t.text) {
+		q.alias = p.next().text
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if q.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if containsAggregate(q.where) {
+			return nil, unsupported("aggregate functions in WHERE")
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			return nil, parseError(t.pos, "LIMIT expects a non-negative integer")
+		}
+		q.limit = limit
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, parseError(t.pos, fmt.Sprintf("unexpected %q", t.text))
+	}
+
+	for _, item := range q.items {
+		if containsAggregate(item.expr) {
+			q.aggregates = true
+		}
+	}
+	if q.aggregates {
+		for _, item := range q.items {
+			if !containsAggregate(item.expr) && containsColumn(item.expr) {
+				return nil, unsupported("mixing aggregate and non-aggregate projections")
+			}
+		}
+	}
+	q.stripAlias()
+	return q, nil
+}
+
+// stripAlias removes the alias, or S3Object, from the front of column paths
+func (q *query) stripAlias() {
+	strip := func(e expr) {
+		walk(e, func(e expr) {
+			c, ok := e.(*column)
+			if !ok || len(c.path) < 2 || c.path[0].name == "" {
+				return
+			}
+			first := c.path[0]
+			if (q.alias != "" && (first.name == q.alias || !first.quoted && strings.EqualFold(first.name, q.alias))) ||
+				(!first.quoted && strings.EqualFold(first.name, "S3Object")) {
+				c.path = c.path[1:]
+			}
+		})
+	}
+	for _, item := range q.items {
+		strip(item.expr)
+	}
+	if q.where != nil {
+		strip(q.where)
+	}
+}
+
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true, "AND": true, "OR": true,
+	"NOT": true, "IS": true, "NULL": true, "LIKE": true, "ESCAPE": true, "IN": true, "BETWEEN": true,
+	"TRUE": true, "FALSE": true, "CAST": true, "MISSING": true,
+}
+
+func isReserved(word string) bool {
+	return reserved[strings.ToUpper(word)]
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if p.isKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		t := p.peek()
+		return parseError(t.pos, "expected "+word)
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		t := p.peek()
+		return parseError(t.pos, "expected "+symbol)
+	}
+	return nil
+}
+
+func (p *parser) parseSelectItem(position int) (selectItem, error) {
+	e, err := p.parseExpr()
+	if err != nil {
+		return selectItem{}, err
+	}
+	item := selectItem{expr: e, name: "_" + strconv.Itoa(position)}
+	if c, ok := e.(*column); ok && c.path[len(c.path)-1].name != "" {
+		item.name = c.path[len(c.path)-1].name
+	}
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+			return selectItem{}, parseError(t.pos, "expected a column alias")
+		}
+		item.name = t.text
+	}
+	return item, nil
+}
+
+// parsePathTail parses the .name, [n] and [*] steps after a path's first name
+func (p *parser) parsePathTail() ([]pathElem, error) {
+	var path []pathElem
+	for {
+		switch {
+		case p.acceptSymbol("."):
+			t := p.next()
+			switch t.kind {
+			case tokenIdent:
+				path = append(path, pathElem{name: t.text})
+			case tokenQuotedIdent:
+				path = append(path, pathElem{name: t.text, quoted: true})
+			default:
+				return nil, parseError(t.pos, "expected a name after .")
+			}
+		case p.acceptSymbol("["):
+			if p.acceptSymbol("*") {
+				path = append(path, pathElem{wildcard: true})
+			} else {
+				t := p.next()
+				index, err := strconv.Atoi(t.text)
+				if t.kind != tokenNumber || err != nil || index < 0 {
+					return nil, parseError(t.pos, "expected an array index")
+				}
+				path = append(path, pathElem{index: index, indexed: true})
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) parseExpr() (expr, error) { return p.parseOr() }
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &not{operand: operand}, nil
+	}
+	return p.parsePredicate()
+}
+
+// parsePredicate parses comparisons, IS [NOT] NULL, [NOT] LIKE, [NOT] IN
+// and [NOT] BETWEEN
+func (p *parser) parsePredicate() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokenSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &comparison{op: t.text, left: left, right: right}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") && !p.acceptKeyword("MISSING") {
+			t := p.peek()
+			return nil, parseError(t.pos, "expected NULL")
+		}
+		return &isNull{operand: left, negate: negate}, nil
+	}
+
+	negate := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		var escape expr
+		if p.acceptKeyword("ESCAPE") {
+			if escape, err = p.parseAdditive(); err != nil {
+				return nil, err
+			}
+		}
+		return &like{operand: left, pattern: pattern, escape: escape, negate: negate}, nil
+	case p.acceptKeyword("IN"):
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		in := &inList{operand: left, negate: negate}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		return in, p.expectSymbol(")")
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &between{operand: left, low: low, high: high, negate: negate}, nil
+	}
+	if negate {
+		t := p.peek()
+		return nil, parseError(t.pos, "expected LIKE, IN or BETWEEN after NOT")
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenSymbol || (t.text != "+" && t.text != "-" && t.text != "||") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmetic{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenSymbol || (t.text != "*" && t.text != "/" && t.text != "%") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmetic{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptSymbol("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmetic{op: "-", left: &literal{value: int64(0)}, right: operand}, nil
+	}
+	p.acceptSymbol("+")
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, parseError(t.pos, "invalid number "+t.text)
+		}
+		return &literal{value: f}, nil
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenQuotedIdent:
+		return p.parseColumn(pathElem{name: t.text, quoted: true})
+	case tokenSymbol:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectSymbol(")")
+		}
+	case tokenIdent:
+		switch strings.ToUpper(t.text) {
+		case "NULL", "MISSING":
+			return &literal{value: nil}, nil
+		case "TRUE":
+			return &literal{value: true}, nil
+		case "FALSE":
+			return &literal{value: false}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		if p.peek().kind == tokenSymbol && p.peek().text == "(" {
+			return p.parseCall(t)
+		}
+		if isReserved(t.text) {
+			return nil, parseError(t.pos, fmt.Sprintf("unexpected %s", t.text))
+		}
+		return p.parseColumn(pathElem{name: t.text})
+	}
+	if t.kind == tokenEOF {
+		return nil, parseError(t.pos, "unexpected end of expression")
+	}
+	return nil, parseError(t.pos, fmt.Sprintf("unexpected %q", t.text))
+}
+
+func (p *parser) parseColumn(first pathElem) (expr, error) {
+	tail, err := p.parsePathTail()
+	if err != nil {
+		return nil, err
+	}
+	return &column{path: append([]pathElem{first}, tail...)}, nil
+}
+
+func (p *parser) parseCast() (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	operand, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	target, ok := castTypes[strings.ToUpper(t.text)]
+	if t.kind != tokenIdent || !ok {
+		return nil, parseError(t.pos, fmt.Sprintf("unsupported CAST type %q", t.text))
+	}
+	// Precision and scale, e.g. DECIMAL(10, 2), do not change the result
+	if p.acceptSymbol("(") {
+		for !p.acceptSymbol(")") {
+			if p.next().kind == tokenEOF {
+				return nil, parseError(t.pos, "unterminated type")
+			}
+		}
+	}
+	return &cast{operand: operand, target: target}, p.expectSymbol(")")
+}
+
+// parseCall parses a function call, including the keyword forms of
+// SUBSTRING and TRIM
+func (p *parser) parseCall(name token) (expr, error) {
+	p.pos++ // (
+	upper := strings.ToUpper(name.text)
+
+	if aggregateFunctions[upper] {
+		call := &aggregate{name: upper}
+		if upper == "COUNT" && p.acceptSymbol("*") {
+			return call, p.expectSymbol(")")
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if containsAggregate(arg) {
+			return nil, unsupported("nested aggregate functions")
+		}
+		call.arg = arg
+		return call, p.expectSymbol(")")
+	}
+
+	call := &function{name: upper}
+	if upper == "TRIM" {
+		call.trim = "BOTH"
+		for _, mode := range []string{"BOTH", "LEADING", "TRAILING"} {
+			if p.acceptKeyword(mode) {
+				call.trim = mode
+			}
+		}
+		if p.acceptKeyword("FROM") {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = []expr{arg}
+			return call, p.expectSymbol(")")
+		}
+	}
+	if p.acceptSymbol(")") {
+		return call, call.check(name.pos)
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		switch {
+		case p.acceptSymbol(","):
+		case upper == "SUBSTRING" && (p.isKeyword("FROM") || p.isKeyword("FOR")):
+			p.pos++
+		case upper == "TRIM" && p.acceptKeyword("FROM"):
+			// TRIM([mode] characters FROM string)
+			call.chars = call.args[0]
+			call.args = nil
+		default:
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return call, call.check(name.pos)
+		}
+	}
+}
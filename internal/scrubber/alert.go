@@ -0,0 +1,20 @@
+package scrubber
+
+import (
+	"context"
+	"time"
+)
+
+// Alert describes an object that failed decryption or integrity verification
+type Alert struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	VersionID string    `json:"version_id,omitempty"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+// Alerter delivers scrubber alerts
+type Alerter interface {
+	Send(ctx context.Context, alert Alert) error
+}
@@ -0,0 +1,403 @@
+// Package scrubber implements a background job that samples stored objects,
+// reads them from the backend and decrypts and verifies them without serving
+// them. It finds ciphertext that no longer decrypts or no longer matches its
+// integrity HMAC before a client asks for it.
+package scrubber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+const (
+	defaultSamplesPerHour = 60
+	defaultListBatchSize  = 10000
+	defaultTimeout        = 5 * time.Minute
+
+	// maxListPage is the largest page S3 returns
+	maxListPage = 1000
+)
+
+// Results of a sampled object, as reported in s3ep_scrubber_objects_total
+const (
+	// ResultOK means the object decrypted and its AEAD tag or HMAC matched
+	ResultOK = "ok"
+	// ResultUnverified means the object decrypted, but has no integrity
+	// check to run (AES-CTR without HMAC, or HMAC verification disabled)
+	ResultUnverified = "unverified"
+	// ResultFailed means the stored object does not decrypt or verify
+	ResultFailed = "failed"
+	// ResultError means the backend read failed, so nothing is known about
+	// the object
+	ResultError = "error"
+	// ResultSkipped means the object is not encrypted or was deleted after
+	// it was listed
+	ResultSkipped = "skipped"
+)
+
+// Backend is the subset of the S3 client used by the scrubber
+type Backend interface {
+	ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Decryptor decrypts object bodies the way the GET handler does
+type Decryptor interface {
+	WithStoredEncryptionContext(ctx context.Context, metadata map[string]string) context.Context
+	CreateStreamingDecryptionReaderWithSize(ctx context.Context, encryptedReader io.ReadCloser, encryptedDEK []byte, metadata map[string]string, objectKey string, providerAlias string, expectedSize int64) (io.ReadCloser, error)
+	DecryptDataWithMetadata(ctx context.Context, encryptedReader io.Reader, metadata map[string]string, objectKey string) (io.ReadCloser, error)
+}
+
+// Config holds scrubber configuration
+type Config struct {
+	Buckets        []string      // Buckets to sample from; empty samples every bucket of the backend
+	SamplesPerHour int           // Objects verified per hour (default: 60)
+	ListBatchSize  int           // Keys listed per bucket and run (default: 10000)
+	MaxObjectSize  int64         // Larger objects are not sampled; 0 = no limit
+	Timeout        time.Duration // Time allowed to verify one object (default: 5m)
+	VerifyHMAC     bool          // Integrity verification is enabled, so AES-CTR objects with an HMAC are verified
+}
+
+// Object is a sampling candidate
+type Object struct {
+	Bucket string
+	Key    string
+	Size   int64
+}
+
+// Result is the outcome of verifying one object
+type Result struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	VersionID string `json:"version_id,omitempty"`
+	Result    string `json:"result"`
+	Bytes     int64  `json:"bytes"` // plaintext bytes decrypted
+	Error     string `json:"error,omitempty"`
+}
+
+// Scrubber samples and verifies stored objects. It is not safe for
+// concurrent use; Run is its only caller in the proxy.
+type Scrubber struct {
+	backend        Backend
+	decryptor      Decryptor
+	metadataPrefix string
+	cfg            Config
+	alerter        Alerter
+	logger         *logrus.Entry
+
+	// cursors holds the last key listed per bucket. Every run lists the next
+	// window of keys, so the samples cover large buckets over time.
+	cursors map[string]string
+}
+
+// New creates a scrubber. alerter may be nil, in which case failures are only
+// logged and recorded in the metrics.
+func New(backend Backend, decryptor Decryptor, metadataPrefix string, cfg Config, alerter Alerter, logger *logrus.Entry) *Scrubber {
+	if cfg.SamplesPerHour <= 0 {
+		cfg.SamplesPerHour = defaultSamplesPerHour
+	}
+	if cfg.ListBatchSize <= 0 {
+		cfg.ListBatchSize = defaultListBatchSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Scrubber{
+		backend:        backend,
+		decryptor:      decryptor,
+		metadataPrefix: metadataPrefix,
+		cfg:            cfg,
+		alerter:        alerter,
+		logger:         logger,
+		cursors:        make(map[string]string),
+	}
+}
+
+// Run samples once per hour until ctx is cancelled, starting immediately. The
+// samples of an hour are verified one at a time, spread evenly over the hour.
+func (s *Scrubber) Run(ctx context.Context) {
+	pace := time.Hour / time.Duration(s.cfg.SamplesPerHour)
+	for {
+		start := time.Now()
+		if _, err := s.Scrub(ctx, pace); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Warn("Scrubber run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(time.Hour))):
+		}
+	}
+}
+
+// Scrub samples objects and verifies them, waiting pace between two objects.
+// It returns the results of the verified objects.
+func (s *Scrubber) Scrub(ctx context.Context, pace time.Duration) ([]Result, error) {
+	samples, err := s.Sample(ctx)
+	if err != nil {
+		monitoring.RecordScrubberSampleError()
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	results := make([]Result, 0, len(samples))
+	for i, object := range samples {
+		if i > 0 && pace > 0 {
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(pace):
+			}
+		}
+
+		result := s.Verify(ctx, object)
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		counts[result.Result]++
+		results = append(results, result)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"sampled":    len(samples),
+		"ok":         counts[ResultOK],
+		"unverified": counts[ResultUnverified],
+		"failed":     counts[ResultFailed],
+		"errors":     counts[ResultError],
+		"skipped":    counts[ResultSkipped],
+	}).Info("Scrubber run finished")
+	return results, nil
+}
+
+// Sample lists the next window of every bucket and picks up to
+// SamplesPerHour objects uniformly from all of them
+func (s *Scrubber) Sample(ctx context.Context) ([]Object, error) {
+	buckets, err := s.bucketNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := reservoir{size: s.cfg.SamplesPerHour}
+	var failed int
+	for _, bucket := range buckets {
+		if err := s.listWindow(ctx, bucket, sample.add); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			failed++
+			s.logger.WithError(err).WithField("bucket", bucket).Warn("Scrubber failed to list bucket")
+		}
+	}
+	if len(buckets) > 0 && failed == len(buckets) {
+		return nil, fmt.Errorf("failed to list all %d buckets", failed)
+	}
+	return sample.items, nil
+}
+
+// bucketNames returns the configured buckets, or all backend buckets
+func (s *Scrubber) bucketNames(ctx context.Context) ([]string, error) {
+	if len(s.cfg.Buckets) > 0 {
+		return s.cfg.Buckets, nil
+	}
+
+	output, err := s.backend.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	buckets := make([]string, 0, len(output.Buckets))
+	for _, bucket := range output.Buckets {
+		buckets = append(buckets, aws.ToString(bucket.Name))
+	}
+	sort.Strings(buckets)
+	return buckets, nil
+}
+
+// listWindow lists up to ListBatchSize keys of bucket after its cursor and
+// passes the candidates to add. Reaching the end of the bucket resets the
+// cursor, so the next window starts over.
+func (s *Scrubber) listWindow(ctx context.Context, bucket string, add func(Object)) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+	if after := s.cursors[bucket]; after != "" {
+		input.StartAfter = aws.String(after)
+	}
+
+	for listed := 0; listed < s.cfg.ListBatchSize; {
+		input.MaxKeys = aws.Int32(int32(min(maxListPage, s.cfg.ListBatchSize-listed))) // #nosec G115 - bounded by maxListPage
+		page, err := s.backend.ListObjectsV2(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range page.Contents {
+			listed++
+			key := aws.ToString(object.Key)
+			s.cursors[bucket] = key
+			size := aws.ToInt64(object.Size)
+			if s.cfg.MaxObjectSize > 0 && size > s.cfg.MaxObjectSize {
+				continue
+			}
+			add(Object{Bucket: bucket, Key: key, Size: size})
+		}
+
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil || len(page.Contents) == 0 {
+			delete(s.cursors, bucket)
+			return nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+	return nil
+}
+
+// Verify reads object from the backend and decrypts it into io.Discard,
+// records the result and alerts on a failure
+func (s *Scrubber) Verify(ctx context.Context, object Object) Result {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := s.verify(ctx, object)
+	monitoring.RecordScrubbedObject(result.Result, result.Bytes, time.Since(start))
+
+	log := s.logger.WithFields(logrus.Fields{
+		"bucket":     result.Bucket,
+		"key":        result.Key,
+		"version_id": result.VersionID,
+		"result":     result.Result,
+	})
+	switch result.Result {
+	case ResultFailed:
+		log.WithField("error", result.Error).Error("Scrubber found an object that fails decryption or integrity verification")
+		s.alert(ctx, result)
+	case ResultError:
+		log.WithField("error", result.Error).Warn("Scrubber could not read object")
+	default:
+		log.Debug("Scrubber verified object")
+	}
+	return result
+}
+
+func (s *Scrubber) verify(ctx context.Context, object Object) Result {
+	result := Result{Bucket: object.Bucket, Key: object.Key}
+
+	output, err := s.backend.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(object.Bucket),
+		Key:    aws.String(object.Key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			result.Result = ResultSkipped
+			return result
+		}
+		result.Result, result.Error = ResultError, err.Error()
+		return result
+	}
+	body := &backendBody{ReadCloser: output.Body}
+	defer func() { _ = body.Close() }()
+	result.VersionID = aws.ToString(output.VersionId)
+
+	metadata := output.Metadata
+	if _, encrypted := metadata[s.metadataPrefix+"encrypted-dek"]; !encrypted {
+		result.Result = ResultSkipped
+		return result
+	}
+
+	// Decrypt like the GET handler: AES-CTR and XChaCha20 stream and check the
+	// HMAC at the end, everything else is an AEAD with its own authentication
+	// tag
+	ctx = s.decryptor.WithStoredEncryptionContext(ctx, metadata)
+	var plaintext io.ReadCloser
+	verified := true
+	if encryption.IsStreamingAlgorithm(metadata[s.metadataPrefix+"dek-algorithm"]) {
+		_, hasHMAC := metadata[s.metadataPrefix+"hmac"]
+		verified = s.cfg.VerifyHMAC && hasHMAC
+		plaintext, err = s.decryptor.CreateStreamingDecryptionReaderWithSize(ctx, body, nil, metadata, object.Key, "", aws.ToInt64(output.ContentLength))
+	} else {
+		plaintext, err = s.decryptor.DecryptDataWithMetadata(ctx, body, metadata, object.Key)
+	}
+	if err == nil {
+		result.Bytes, err = io.Copy(io.Discard, plaintext)
+		if closeErr := plaintext.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	switch {
+	case err == nil && verified:
+		result.Result = ResultOK
+	case err == nil:
+		result.Result = ResultUnverified
+	case body.err != nil || ctx.Err() != nil:
+		// A broken connection or a timeout says nothing about the object
+		result.Result, result.Error = ResultError, err.Error()
+	default:
+		result.Result, result.Error = ResultFailed, err.Error()
+	}
+	return result
+}
+
+// alert sends a failure alert, if an alerter is configured
+func (s *Scrubber) alert(ctx context.Context, result Result) {
+	if s.alerter == nil {
+		return
+	}
+	alert := Alert{
+		Bucket:    result.Bucket,
+		Key:       result.Key,
+		VersionID: result.VersionID,
+		Error:     result.Error,
+		Time:      time.Now().UTC(),
+	}
+	if err := s.alerter.Send(context.WithoutCancel(ctx), alert); err != nil {
+		s.logger.WithError(err).Error("Failed to deliver scrubber alert")
+	}
+}
+
+// backendBody remembers read errors of the backend body, so a failing backend
+// is not reported as a corrupt object
+type backendBody struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *backendBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// reservoir keeps a uniform random sample of up to size objects from a
+// stream of unknown length
+type reservoir struct {
+	size  int
+	seen  int
+	items []Object
+}
+
+func (r *reservoir) add(object Object) {
+	r.seen++
+	if len(r.items) < r.size {
+		r.items = append(r.items, object)
+		return
+	}
+	if i := rand.IntN(r.seen); i < r.size { // #nosec G404 - sampling, not security
+		r.items[i] = object
+	}
+}
@@ -0,0 +1,273 @@
+package scrubber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/orchestration"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/factory"
+)
+
+const testPrefix = "s3ep-"
+
+type fakeObject struct {
+	data     []byte
+	metadata map[string]string
+	missing  bool // listed, but deleted before it is read
+	broken   bool // the body breaks off after the first bytes
+}
+
+// fakeBackend serves buckets of objects and honors StartAfter, MaxKeys and
+// continuation tokens like S3
+type fakeBackend struct {
+	buckets map[string]map[string]fakeObject
+}
+
+func (f *fakeBackend) ListBuckets(_ context.Context, _ *s3.ListBucketsInput, _ ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	out := &s3.ListBucketsOutput{}
+	for name := range f.buckets {
+		out.Buckets = append(out.Buckets, types.Bucket{Name: aws.String(name)})
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range f.buckets[aws.ToString(params.Bucket)] {
+		if key > aws.ToString(params.StartAfter) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if params.ContinuationToken != nil {
+		start, _ = strconv.Atoi(aws.ToString(params.ContinuationToken))
+	}
+	end := min(start+int(aws.ToInt32(params.MaxKeys)), len(keys))
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	for _, key := range keys[start:end] {
+		size := int64(len(f.buckets[aws.ToString(params.Bucket)][key].data))
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(size)})
+	}
+	if end < len(keys) {
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	object, ok := f.buckets[aws.ToString(params.Bucket)][aws.ToString(params.Key)]
+	if !ok || object.missing {
+		return nil, &types.NoSuchKey{}
+	}
+	var body io.Reader = bytes.NewReader(object.data)
+	if object.broken {
+		body = io.MultiReader(bytes.NewReader(object.data[:4]), &failingReader{})
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(body),
+		ContentLength: aws.Int64(int64(len(object.data))),
+		Metadata:      object.metadata,
+	}, nil
+}
+
+type failingReader struct{}
+
+func (*failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset by peer") }
+
+// recordingAlerter keeps the alerts it was sent
+type recordingAlerter struct {
+	alerts []Alert
+}
+
+func (r *recordingAlerter) Send(_ context.Context, alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func newTestManager(t *testing.T) *orchestration.Manager {
+	t.Helper()
+	prefix := testPrefix
+	manager, err := orchestration.NewManager(&config.Config{
+		Encryption: config.EncryptionConfig{
+			EncryptionMethodAlias:   "test-aes",
+			MetadataKeyPrefix:       &prefix,
+			IntegrityVerification:   config.HMACVerificationStrict,
+			StrictEncryptionContext: true,
+			Providers: []config.EncryptionProvider{{
+				Alias:  "test-aes",
+				Type:   "aes",
+				Config: map[string]interface{}{"aes_key": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXoxMjM0NTY="},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Shutdown(context.Background()) })
+	return manager
+}
+
+func encryptObject(t *testing.T, manager *orchestration.Manager, key string, contentType factory.ContentType) fakeObject {
+	t.Helper()
+	ctx := orchestration.WithEncryptionContext(context.Background(), orchestration.EncryptionContext{"tenant": "acme"})
+	result, err := manager.EncryptDataWithContentType(ctx, bufio.NewReader(bytes.NewReader(bytes.Repeat([]byte(key), 1000))), key, contentType)
+	require.NoError(t, err)
+	data, err := io.ReadAll(result.EncryptedDataReader)
+	require.NoError(t, err)
+	return fakeObject{data: data, metadata: result.Metadata}
+}
+
+func corrupt(object fakeObject) fakeObject {
+	data := bytes.Clone(object.data)
+	data[len(data)/2] ^= 1
+	object.data = data
+	return object
+}
+
+func TestScrubber_Scrub(t *testing.T) {
+	manager := newTestManager(t)
+	ctr := encryptObject(t, manager, "ctr.bin", factory.ContentTypeMultipart)
+	require.Equal(t, "aes-ctr", ctr.metadata[testPrefix+"dek-algorithm"])
+	require.Contains(t, ctr.metadata, testPrefix+"hmac")
+
+	// Like a CTR object written without a context while integrity was off
+	unverified := encryptObject(t, manager, "ctr-without-hmac.bin", factory.ContentTypeMultipart)
+	delete(unverified.metadata, testPrefix+"hmac")
+	delete(unverified.metadata, testPrefix+"encryption-context")
+	brokenBody := encryptObject(t, manager, "broken-body.bin", factory.ContentTypeWhole)
+	brokenBody.broken = true
+
+	backend := &fakeBackend{buckets: map[string]map[string]fakeObject{
+		"data": {
+			"gcm.bin":              encryptObject(t, manager, "gcm.bin", factory.ContentTypeWhole),
+			"ctr.bin":              ctr,
+			"ctr-without-hmac.bin": unverified,
+			"corrupt-gcm.bin":      corrupt(encryptObject(t, manager, "corrupt-gcm.bin", factory.ContentTypeWhole)),
+			"corrupt-ctr.bin":      corrupt(encryptObject(t, manager, "corrupt-ctr.bin", factory.ContentTypeMultipart)),
+			"moved.bin":            encryptObject(t, manager, "original-key.bin", factory.ContentTypeWhole),
+			"plain.txt":            {data: []byte("not encrypted")},
+			"deleted.bin":          {data: []byte("gone"), missing: true},
+			"broken-body.bin":      brokenBody,
+			"huge.bin":             {data: make([]byte, 64*1024)},
+		},
+	}}
+
+	alerter := &recordingAlerter{}
+	scrubber := New(backend, manager, testPrefix, Config{
+		SamplesPerHour: 100,
+		MaxObjectSize:  32 * 1024,
+		VerifyHMAC:     true,
+	}, alerter, testLogger())
+
+	failedBefore := testutil.ToFloat64(monitoring.ScrubberObjectsTotal.WithLabelValues(ResultFailed))
+	results, err := scrubber.Scrub(context.Background(), 0)
+	require.NoError(t, err)
+
+	byKey := make(map[string]Result)
+	for _, result := range results {
+		byKey[result.Key] = result
+	}
+	assert.NotContains(t, byKey, "huge.bin", "objects above max_object_size are not sampled")
+	expected := map[string]string{
+		"gcm.bin":              ResultOK,
+		"ctr.bin":              ResultOK,
+		"ctr-without-hmac.bin": ResultUnverified,
+		"corrupt-gcm.bin":      ResultFailed,
+		"corrupt-ctr.bin":      ResultFailed,
+		"moved.bin":            ResultFailed, // bound to another key
+		"plain.txt":            ResultSkipped,
+		"deleted.bin":          ResultSkipped,
+		"broken-body.bin":      ResultError,
+	}
+	for key, result := range expected {
+		assert.Equal(t, result, byKey[key].Result, key)
+	}
+	assert.Equal(t, int64(7000), byKey["ctr.bin"].Bytes)
+
+	var alerted []string
+	for _, alert := range alerter.alerts {
+		assert.NotEmpty(t, alert.Error)
+		alerted = append(alerted, alert.Key)
+	}
+	assert.ElementsMatch(t, []string{"corrupt-gcm.bin", "corrupt-ctr.bin", "moved.bin"}, alerted)
+	assert.Equal(t, float64(3), testutil.ToFloat64(monitoring.ScrubberObjectsTotal.WithLabelValues(ResultFailed))-failedBefore)
+}
+
+func TestScrubber_SampleWindows(t *testing.T) {
+	objects := make(map[string]fakeObject)
+	for i := 0; i < 5; i++ {
+		objects[fmt.Sprintf("key-%d", i)] = fakeObject{data: []byte("x")}
+	}
+	backend := &fakeBackend{buckets: map[string]map[string]fakeObject{"a": objects, "b": {"only": {data: []byte("x")}}}}
+	scrubber := New(backend, nil, testPrefix, Config{SamplesPerHour: 100, ListBatchSize: 2}, nil, testLogger())
+
+	keys := func(samples []Object) []string {
+		var keys []string
+		for _, object := range samples {
+			keys = append(keys, object.Bucket+"/"+object.Key)
+		}
+		return keys
+	}
+
+	// Every run continues where the last one stopped and wraps at the end
+	windows := [][]string{
+		{"a/key-0", "a/key-1", "b/only"},
+		{"a/key-2", "a/key-3", "b/only"},
+		{"a/key-4", "b/only"},
+		{"a/key-0", "a/key-1", "b/only"},
+	}
+	for _, window := range windows {
+		samples, err := scrubber.Sample(context.Background())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, window, keys(samples))
+	}
+
+	// At most SamplesPerHour objects are picked
+	scrubber = New(backend, nil, testPrefix, Config{SamplesPerHour: 3}, nil, testLogger())
+	samples, err := scrubber.Sample(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, samples, 3)
+}
+
+func TestReservoir_Uniform(t *testing.T) {
+	counts := make(map[string]int)
+	for run := 0; run < 2000; run++ {
+		sample := reservoir{size: 2}
+		for i := 0; i < 10; i++ {
+			sample.add(Object{Key: strconv.Itoa(i)})
+		}
+		require.Len(t, sample.items, 2)
+		for _, object := range sample.items {
+			counts[object.Key]++
+		}
+	}
+	// Every key is expected 400 times
+	for key, count := range counts {
+		assert.InDelta(t, 400, count, 120, key)
+	}
+	assert.Len(t, counts, 10)
+}
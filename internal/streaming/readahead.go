@@ -0,0 +1,119 @@
+package streaming
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+// readAhead fetches chunks from the source on its own goroutine and decrypts
+// each one on a separate goroutine at its offset in the keystream, so the
+// network round trips of later chunks overlap with the decryption of earlier
+// ones. Chunks are handed to the consumer strictly in source order.
+//
+// There are segments+2 chunk buffers: up to segments in flight plus the two a
+// DecryptReader with a Verifier may hold (emitted and held back). Whichever
+// side finishes last wipes all of them.
+type readAhead struct {
+	free    chan []byte          // buffers ready to be filled
+	results chan chan aheadChunk // per-chunk results, in source order
+	stop    chan struct{}
+	stopped sync.Once
+	bufs    [][]byte
+	refs    atomic.Int32
+	out     [][]byte // buffers handed to the consumer, oldest first
+}
+
+// aheadChunk is a decrypted chunk and the source error that followed it
+type aheadChunk struct {
+	data []byte
+	err  error
+}
+
+func startReadAhead(src io.Reader, decryptor dataencryption.OffsetDecryptor, offset uint64, p *dataencryption.Parallelism, segments, size int) *readAhead {
+	a := &readAhead{
+		free:    make(chan []byte, segments+2),
+		results: make(chan chan aheadChunk, segments),
+		stop:    make(chan struct{}),
+	}
+	for range segments + 2 {
+		buf := make([]byte, size)
+		a.bufs = append(a.bufs, buf)
+		a.free <- buf
+	}
+	a.refs.Store(2)
+	go a.run(src, decryptor, offset, p)
+	return a
+}
+
+func (a *readAhead) run(src io.Reader, decryptor dataencryption.OffsetDecryptor, offset uint64, p *dataencryption.Parallelism) {
+	var wg sync.WaitGroup
+	defer a.release()
+	defer wg.Wait()
+
+	for {
+		var buf []byte
+		select {
+		case buf = <-a.free:
+		case <-a.stop:
+			return
+		}
+
+		n, srcErr := io.ReadFull(src, buf)
+		if srcErr == io.ErrUnexpectedEOF {
+			srcErr = io.EOF
+		}
+		done := make(chan aheadChunk, 1)
+		select {
+		case a.results <- done:
+		case <-a.stop:
+			return
+		}
+
+		chunk := buf[:n]
+		wg.Add(1)
+		go func(at uint64) {
+			defer wg.Done()
+			decryptor.DecryptAt(chunk, at, p)
+			done <- aheadChunk{data: chunk, err: srcErr}
+		}(offset)
+		offset += uint64(n)
+
+		if srcErr != nil {
+			return
+		}
+	}
+}
+
+// next returns the next decrypted chunk. All buffers handed out earlier
+// except the last keep ones are recycled first.
+func (a *readAhead) next(keep int) ([]byte, error) {
+	for len(a.out) > keep {
+		a.free <- a.out[0]
+		a.out = a.out[1:]
+	}
+
+	chunk := <-<-a.results
+	if len(chunk.data) > 0 {
+		a.out = append(a.out, chunk.data[:cap(chunk.data)])
+	}
+	return chunk.data, chunk.err
+}
+
+// close stops the pipeline. The consumer must not use any chunk afterwards.
+func (a *readAhead) close() {
+	a.stopped.Do(func() {
+		close(a.stop)
+		a.release()
+	})
+}
+
+func (a *readAhead) release() {
+	if a.refs.Add(-1) == 0 {
+		for _, buf := range a.bufs {
+			clear(buf)
+		}
+	}
+}
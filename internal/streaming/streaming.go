@@ -0,0 +1,337 @@
+// Package streaming provides the io.Reader implementations used for on-the-fly
+// AES-CTR encryption and decryption with optional HMAC verification. Both the
+// single-part and the multipart paths of the orchestration layer use these
+// readers, so streaming behavior (error handling, HMAC gating, cleanup) is
+// identical regardless of how an object was uploaded.
+package streaming
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+// DefaultBufferSize is the read-ahead size of a DecryptReader created with
+// NewDecryptReader
+const DefaultBufferSize = 64 * 1024
+
+// EncryptReader encrypts data from src in place as it is read. The encryptor
+// keeps its keystream state across Read calls, so the output is one continuous
+// ciphertext stream.
+type EncryptReader struct {
+	src         io.Reader
+	encryptor   dataencryption.StatefulEncryptor
+	parallelism *dataencryption.Parallelism
+	err         error
+}
+
+// NewEncryptReader returns a reader that yields the AES-CTR or XChaCha20
+// ciphertext of src
+func NewEncryptReader(src io.Reader, encryptor dataencryption.StatefulEncryptor) *EncryptReader {
+	return &EncryptReader{src: src, encryptor: encryptor}
+}
+
+// SetParallelism lets large reads be encrypted by several workers. A nil p
+// (the default) encrypts on the calling goroutine.
+func (r *EncryptReader) SetParallelism(p *dataencryption.Parallelism) {
+	r.parallelism = p
+}
+
+// Read implements io.Reader
+func (r *EncryptReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.src.Read(p)
+	if n > 0 {
+		if _, encErr := r.encryptor.EncryptPartParallel(p[:n], r.parallelism); encErr != nil {
+			r.err = fmt.Errorf("encryption failed: %w", encErr)
+			return 0, r.err
+		}
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// Close implements io.Closer. It closes src if it is an io.Closer.
+func (r *EncryptReader) Close() error {
+	return closeSource(r.src)
+}
+
+// Verifier holds what a DecryptReader needs to check the HMAC of the
+// plaintext before releasing the final chunk
+type Verifier struct {
+	Calculator *validation.HMACCalculator
+	Manager    *validation.HMACManager
+	Expected   []byte
+	ObjectKey  string
+	OnResult   func(err error) // Called with the result of the verification, may be nil
+}
+
+// DecryptReader decrypts an AES-CTR stream and optionally verifies the HMAC
+// of the plaintext.
+//
+// The source is read ahead in full chunks of the configured buffer size, so
+// decryption and HMAC updates always run on large blocks no matter how small
+// the caller's reads are. Only the final chunk of a stream may be shorter.
+//
+// With a Verifier the most recent chunk is held back until either a newer
+// chunk arrives or the source hits EOF and HMAC verification succeeds, so a
+// client never receives the complete object unless it is authentic. Two
+// reusable buffers are used for this; there are no per-chunk allocations.
+//
+// With SetReadAhead, chunks are instead fetched and decrypted ahead of the
+// caller by a pipeline; HMAC updates and hold-back still happen in order.
+//
+// A nil decryptor turns the reader into a pure HMAC gate over data that is
+// already plaintext (e.g. the output of an AES-GCM decryptor).
+type DecryptReader struct {
+	src         io.Reader
+	decryptor   dataencryption.StatefulEncryptor
+	verifier    *Verifier
+	parallelism *dataencryption.Parallelism
+	size        int
+	readAhead   int
+	ahead       *readAhead
+
+	bufs     [2][]byte // emit and held reference different slots when both non-nil
+	nextSlot int       // index of bufs to read into on the next refill
+
+	emit   []byte // decrypted bytes awaiting the caller (slice into one of bufs)
+	held   []byte // decrypted chunk held back (slice into the other of bufs)
+	srcEOF bool
+	done   bool
+	err    error
+}
+
+// NewDecryptReader returns a reader that yields the plaintext of src using
+// DefaultBufferSize. The verifier may be nil, in which case no integrity
+// check is performed.
+func NewDecryptReader(src io.Reader, decryptor dataencryption.StatefulEncryptor, verifier *Verifier) *DecryptReader {
+	return NewDecryptReaderSize(src, decryptor, verifier, DefaultBufferSize)
+}
+
+// NewDecryptReaderSize is like NewDecryptReader but reads the source ahead in
+// chunks of size bytes. A non-positive size selects DefaultBufferSize.
+func NewDecryptReaderSize(src io.Reader, decryptor dataencryption.StatefulEncryptor, verifier *Verifier, size int) *DecryptReader {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	return &DecryptReader{
+		src:       src,
+		decryptor: decryptor,
+		verifier:  verifier,
+		size:      size,
+	}
+}
+
+// SetParallelism lets each chunk be decrypted by several workers. A nil p
+// (the default) decrypts on the calling goroutine.
+func (r *DecryptReader) SetParallelism(p *dataencryption.Parallelism) {
+	r.parallelism = p
+}
+
+// SetReadAhead lets up to segments chunks be fetched from the source and
+// decrypted in parallel ahead of the caller, which overlaps backend latency
+// with decryption at the cost of segments+2 chunk buffers. It must be called
+// before the first Read and only applies to decryptors that can decrypt at an
+// offset (AES-CTR and XChaCha20). Values below 2 keep the sequential path.
+func (r *DecryptReader) SetReadAhead(segments int) {
+	r.readAhead = segments
+}
+
+// Read implements io.Reader
+func (r *DecryptReader) Read(p []byte) (int, error) {
+	for {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		if len(r.emit) > 0 {
+			n := copy(p, r.emit)
+			r.emit = r.emit[n:]
+			return n, nil
+		}
+		if r.srcEOF {
+			if r.held != nil {
+				r.emit = r.held
+				r.held = nil
+				continue
+			}
+			r.cleanup()
+			r.done = true
+			return 0, io.EOF
+		}
+
+		if err := r.fill(); err != nil {
+			r.fail(err)
+			return 0, r.err
+		}
+	}
+}
+
+// fill takes the next decrypted chunk and either emits it directly or, when
+// verifying, holds it back in place of the previously held chunk
+func (r *DecryptReader) fill() error {
+	var chunk []byte
+	var srcErr error
+	if r.ahead == nil && r.readAhead > 1 {
+		if decryptor, ok := r.decryptor.(dataencryption.OffsetDecryptor); ok {
+			r.ahead = startReadAhead(r.src, decryptor, r.decryptor.Offset(), r.parallelism, r.readAhead, r.size)
+		}
+	}
+	if r.ahead != nil {
+		keep := 0
+		if r.held != nil {
+			keep = 1
+		}
+		chunk, srcErr = r.ahead.next(keep)
+	} else {
+		chunk, srcErr = r.read()
+	}
+
+	if len(chunk) > 0 {
+		if err := r.authenticate(chunk); err != nil {
+			return err
+		}
+		if r.verifier == nil {
+			r.emit = chunk
+		} else {
+			if r.held != nil {
+				r.emit = r.held
+			}
+			r.held = chunk
+		}
+	}
+
+	if srcErr == io.EOF {
+		r.srcEOF = true
+		if r.verifier != nil {
+			return r.verify()
+		}
+		return nil
+	}
+	return srcErr
+}
+
+// read fills the next buffer slot with a full chunk from the source and
+// decrypts it in place. It returns the chunk and the source error that
+// followed it, or a decryption error.
+func (r *DecryptReader) read() ([]byte, error) {
+	slot := r.nextSlot
+	if r.bufs[slot] == nil {
+		r.bufs[slot] = make([]byte, r.size)
+	}
+	n, srcErr := io.ReadFull(r.src, r.bufs[slot])
+	if srcErr == io.ErrUnexpectedEOF {
+		srcErr = io.EOF
+	}
+
+	chunk := r.bufs[slot][:n]
+	if n > 0 {
+		if r.decryptor != nil {
+			if _, err := r.decryptor.DecryptPartParallel(chunk, r.parallelism); err != nil {
+				return nil, fmt.Errorf("decryption failed: %w", err)
+			}
+		}
+		if r.verifier != nil {
+			r.nextSlot = 1 - slot
+		}
+	}
+	return chunk, srcErr
+}
+
+// authenticate feeds the plaintext of chunk to the HMAC calculator
+func (r *DecryptReader) authenticate(chunk []byte) error {
+	if r.verifier != nil && r.verifier.Calculator != nil {
+		if _, err := r.verifier.Calculator.Add(chunk); err != nil {
+			return fmt.Errorf("HMAC calculation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// verify checks the accumulated HMAC against the expected value
+func (r *DecryptReader) verify() error {
+	v := r.verifier
+	if v.Manager == nil || v.Calculator == nil || len(v.Expected) == 0 {
+		return nil
+	}
+	err := v.Manager.VerifyIntegrity(v.Calculator, v.Expected)
+	if v.OnResult != nil {
+		v.OnResult(err)
+	}
+	if err != nil {
+		return fmt.Errorf("HMAC verification failed for %s: %w", v.ObjectKey, err)
+	}
+	return nil
+}
+
+func (r *DecryptReader) fail(err error) {
+	r.err = err
+	r.cleanup()
+}
+
+// cleanup releases the HMAC state, stops the read-ahead pipeline and wipes
+// the plaintext buffers
+func (r *DecryptReader) cleanup() {
+	if r.verifier != nil && r.verifier.Calculator != nil {
+		r.verifier.Calculator.Cleanup()
+		r.verifier.Calculator = nil
+	}
+	if r.ahead != nil {
+		r.ahead.close()
+	}
+	for i := range r.bufs {
+		clear(r.bufs[i])
+	}
+	r.emit = nil
+	r.held = nil
+}
+
+// Close implements io.Closer. It releases the HMAC state and closes src if
+// it is an io.Closer.
+func (r *DecryptReader) Close() error {
+	r.cleanup()
+	return closeSource(r.src)
+}
+
+// ReadCloser pairs a reader with the closer of its underlying source
+// (typically the S3 response body). Close closes both and returns the first
+// error encountered.
+type ReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+// WithCloser returns a ReadCloser that reads from r and closes both r (if it
+// implements io.Closer) and closer
+func WithCloser(r io.Reader, closer io.Closer) *ReadCloser {
+	return &ReadCloser{Reader: r, closer: closer}
+}
+
+// Close implements io.Closer
+func (r *ReadCloser) Close() error {
+	firstErr := closeSource(r.Reader)
+	if r.closer != nil {
+		if err := r.closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func closeSource(r io.Reader) error {
+	if closer, ok := r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package streaming
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+	"github.com/guided-traffic/s3-encryption-proxy/internal/validation"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
+)
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+// encrypt returns the ciphertext of plaintext and the encryptor's IV
+func encrypt(t *testing.T, dek, plaintext []byte) ([]byte, []byte) {
+	t.Helper()
+	enc, err := dataencryption.NewAESCTRStatefulEncryptor(dek)
+	require.NoError(t, err)
+
+	ciphertext, err := io.ReadAll(NewEncryptReader(bytes.NewReader(plaintext), enc))
+	require.NoError(t, err)
+	return ciphertext, enc.GetIV()
+}
+
+func newDecryptor(t *testing.T, dek, iv []byte) *dataencryption.AESCTRStatefulEncryptor {
+	t.Helper()
+	dec, err := dataencryption.NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	require.NoError(t, err)
+	return dec
+}
+
+// newVerifier returns a verifier expecting the HMAC of plaintext
+func newVerifier(t *testing.T, dek, plaintext []byte) *Verifier {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Encryption.IntegrityVerification = config.HMACVerificationStrict
+	mgr := validation.NewHMACManager(cfg)
+
+	expectedCalc, err := mgr.CreateCalculator(dek)
+	require.NoError(t, err)
+	_, err = expectedCalc.Add(plaintext)
+	require.NoError(t, err)
+
+	calc, err := mgr.CreateCalculator(dek)
+	require.NoError(t, err)
+	return &Verifier{
+		Calculator: calc,
+		Manager:    mgr,
+		Expected:   mgr.FinalizeCalculator(expectedCalc),
+		ObjectKey:  "test-object",
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	dek := randomBytes(t, 32)
+
+	// sizes around the gated chunk size exercise the hold-back logic
+	for _, size := range []int{0, 1, 1000, DefaultBufferSize - 1, DefaultBufferSize, DefaultBufferSize + 1, 3*DefaultBufferSize + 17} {
+		plaintext := randomBytes(t, size)
+		ciphertext, iv := encrypt(t, dek, plaintext)
+		require.Len(t, ciphertext, size)
+		if size > 0 {
+			assert.NotEqual(t, plaintext, ciphertext)
+		}
+
+		direct, err := io.ReadAll(NewDecryptReader(bytes.NewReader(ciphertext), newDecryptor(t, dek, iv), nil))
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, direct, "direct, size %d", size)
+
+		// one byte at a time from the source and small caller buffers
+		gated := NewDecryptReader(iotest.OneByteReader(bytes.NewReader(ciphertext)), newDecryptor(t, dek, iv), newVerifier(t, dek, plaintext))
+		got, err := io.ReadAll(iotest.HalfReader(gated))
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, got, "gated, size %d", size)
+	}
+}
+
+func TestDecryptReader_HoldsBackFinalChunkOnHMACMismatch(t *testing.T) {
+	dek := randomBytes(t, 32)
+	plaintext := randomBytes(t, 4*DefaultBufferSize)
+	ciphertext, iv := encrypt(t, dek, plaintext)
+	ciphertext[len(ciphertext)-1] ^= 0xff
+
+	reader := NewDecryptReader(bytes.NewReader(ciphertext), newDecryptor(t, dek, iv), newVerifier(t, dek, plaintext))
+	got, err := io.ReadAll(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HMAC verification failed for test-object")
+	assert.Less(t, len(got), len(plaintext), "the final chunk must not be released")
+	assert.Equal(t, plaintext[:len(got)], got)
+
+	_, err = reader.Read(make([]byte, 16))
+	assert.Error(t, err, "errors are sticky")
+}
+
+func TestDecryptReader_PlaintextGate(t *testing.T) {
+	dek := randomBytes(t, 32)
+	plaintext := randomBytes(t, DefaultBufferSize+5)
+
+	got, err := io.ReadAll(NewDecryptReader(bytes.NewReader(plaintext), nil, newVerifier(t, dek, plaintext)))
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+
+	_, err = io.ReadAll(NewDecryptReader(bytes.NewReader(plaintext[1:]), nil, newVerifier(t, dek, plaintext)))
+	assert.Error(t, err)
+}
+
+func TestDecryptReader_SourceError(t *testing.T) {
+	dek := randomBytes(t, 32)
+	srcErr := errors.New("connection reset")
+	src := io.MultiReader(bytes.NewReader(randomBytes(t, 100)), iotest.ErrReader(srcErr))
+
+	_, err := io.ReadAll(NewDecryptReader(src, newDecryptor(t, dek, randomBytes(t, 16)), newVerifier(t, dek, nil)))
+	assert.ErrorIs(t, err, srcErr)
+
+	src = io.MultiReader(bytes.NewReader(randomBytes(t, 100)), iotest.ErrReader(srcErr))
+	_, err = io.ReadAll(NewDecryptReader(src, newDecryptor(t, dek, randomBytes(t, 16)), nil))
+	assert.ErrorIs(t, err, srcErr)
+}
+
+type trackingCloser struct {
+	io.Reader
+	closed int
+	err    error
+}
+
+func (c *trackingCloser) Close() error {
+	c.closed++
+	return c.err
+}
+
+func TestWithCloser(t *testing.T) {
+	body := &trackingCloser{err: errors.New("body close failed")}
+	inner := &trackingCloser{Reader: bytes.NewReader([]byte("data"))}
+
+	rc := WithCloser(inner, body)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	assert.EqualError(t, rc.Close(), "body close failed")
+	assert.Equal(t, 1, inner.closed)
+	assert.Equal(t, 1, body.closed)
+}
+
+type countingReader struct {
+	r     io.Reader
+	calls int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.calls++
+	return c.r.Read(p)
+}
+
+func TestDecryptReader_ReadsAheadInFullChunks(t *testing.T) {
+	dek := randomBytes(t, 32)
+	const chunkSize = 4096
+	plaintext := randomBytes(t, 10*chunkSize+100)
+	ciphertext, iv := encrypt(t, dek, plaintext)
+
+	src := &countingReader{r: bytes.NewReader(ciphertext)}
+	reader := NewDecryptReaderSize(src, newDecryptor(t, dek, iv), nil, chunkSize)
+
+	// the client reads one byte at a time, the source is still read in chunks
+	got, err := io.ReadAll(iotest.OneByteReader(reader))
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+	assert.LessOrEqual(t, src.calls, 13, "11 chunks plus EOF detection")
+}
+
+func TestDecryptReader_Parallel(t *testing.T) {
+	dek := randomBytes(t, 32)
+	plaintext := randomBytes(t, 20*dataencryption.MinParallelSlice+7)
+	ciphertext, iv := encrypt(t, dek, plaintext)
+
+	reader := NewDecryptReaderSize(bytes.NewReader(ciphertext), newDecryptor(t, dek, iv), newVerifier(t, dek, plaintext), 8*dataencryption.MinParallelSlice)
+	reader.SetParallelism(dataencryption.NewParallelism(4, 2))
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+}
+
+func TestDecryptReader_ReadAhead(t *testing.T) {
+	dek := randomBytes(t, 32)
+	const chunkSize = 4096
+
+	for _, size := range []int{0, 1, chunkSize, 10*chunkSize + 100} {
+		plaintext := randomBytes(t, size)
+		ciphertext, iv := encrypt(t, dek, plaintext)
+
+		direct := NewDecryptReaderSize(iotest.HalfReader(bytes.NewReader(ciphertext)), newDecryptor(t, dek, iv), nil, chunkSize)
+		direct.SetReadAhead(4)
+		got, err := io.ReadAll(iotest.OneByteReader(direct))
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, got, "direct, size %d", size)
+
+		gated := NewDecryptReaderSize(bytes.NewReader(ciphertext), newDecryptor(t, dek, iv), newVerifier(t, dek, plaintext), chunkSize)
+		gated.SetReadAhead(3)
+		gated.SetParallelism(dataencryption.NewParallelism(2, 2))
+		got, err = io.ReadAll(gated)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, got, "gated, size %d", size)
+	}
+}
+
+func TestDecryptReader_ReadAheadAtOffset(t *testing.T) {
+	dek := randomBytes(t, 32)
+	plaintext := randomBytes(t, 5*4096+7)
+	ciphertext, iv := encrypt(t, dek, plaintext)
+
+	// a range read starting mid-block
+	const start = 1000
+	decryptor, err := dataencryption.NewAESCTRStatefulEncryptorAt(dek, iv, start)
+	require.NoError(t, err)
+	reader := NewDecryptReaderSize(bytes.NewReader(ciphertext[start:]), decryptor, nil, 4096)
+	reader.SetReadAhead(4)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext[start:], got)
+}
+
+func TestDecryptReader_ReadAheadHoldsBackFinalChunkOnHMACMismatch(t *testing.T) {
+	dek := randomBytes(t, 32)
+	plaintext := randomBytes(t, 8*4096)
+	ciphertext, iv := encrypt(t, dek, plaintext)
+	ciphertext[len(ciphertext)-1] ^= 0xff
+
+	reader := NewDecryptReaderSize(bytes.NewReader(ciphertext), newDecryptor(t, dek, iv), newVerifier(t, dek, plaintext), 4096)
+	reader.SetReadAhead(4)
+	got, err := io.ReadAll(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HMAC verification failed")
+	assert.Equal(t, plaintext[:len(plaintext)-4096], got)
+}
+
+func TestDecryptReader_ReadAheadSourceErrorAndClose(t *testing.T) {
+	dek := randomBytes(t, 32)
+	srcErr := errors.New("connection reset")
+	src := io.MultiReader(bytes.NewReader(randomBytes(t, 3*4096+5)), iotest.ErrReader(srcErr))
+
+	reader := NewDecryptReaderSize(src, newDecryptor(t, dek, randomBytes(t, 16)), nil, 4096)
+	reader.SetReadAhead(2)
+	_, err := io.ReadAll(reader)
+	assert.ErrorIs(t, err, srcErr)
+
+	// closed after the first chunk, with the pipeline still filling
+	reader = NewDecryptReaderSize(bytes.NewReader(randomBytes(t, 64*4096)), newDecryptor(t, dek, randomBytes(t, 16)), nil, 4096)
+	reader.SetReadAhead(4)
+	_, err = reader.Read(make([]byte, 16))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	smithytracing "github.com/aws/smithy-go/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SmithyTracerProvider returns a tracer provider for AWS SDK clients that
+// records their operation spans as OpenTelemetry spans, so backend S3 calls
+// appear as children of the span of the context they are made with
+func SmithyTracerProvider() smithytracing.TracerProvider {
+	return smithyTracerProvider{}
+}
+
+type smithyTracerProvider struct{}
+
+func (smithyTracerProvider) Tracer(scope string, _ ...smithytracing.TracerOption) smithytracing.Tracer {
+	return smithyTracer{tracer: otel.Tracer(scope)}
+}
+
+type smithyTracer struct {
+	tracer trace.Tracer
+}
+
+func (t smithyTracer) StartSpan(ctx context.Context, name string, opts ...smithytracing.SpanOption) (context.Context, smithytracing.Span) {
+	var options smithytracing.SpanOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(spanKind(options.Kind)),
+		trace.WithAttributes(smithyAttributes(options.Properties.Values())...))
+	return ctx, &smithySpan{name: name, span: span}
+}
+
+// smithySpan adapts an OpenTelemetry span to the AWS SDK
+type smithySpan struct {
+	name string
+	span trace.Span
+}
+
+func (s *smithySpan) Name() string {
+	return s.name
+}
+
+func (s *smithySpan) Context() smithytracing.SpanContext {
+	spanContext := s.span.SpanContext()
+	return smithytracing.SpanContext{
+		TraceID:  spanContext.TraceID().String(),
+		SpanID:   spanContext.SpanID().String(),
+		IsRemote: spanContext.IsRemote(),
+	}
+}
+
+func (s *smithySpan) AddEvent(name string, opts ...smithytracing.EventOption) {
+	var options smithytracing.EventOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s.span.AddEvent(name, trace.WithAttributes(smithyAttributes(options.Properties.Values())...))
+}
+
+func (s *smithySpan) SetStatus(status smithytracing.SpanStatus) {
+	switch status {
+	case smithytracing.SpanStatusOK:
+		s.span.SetStatus(codes.Ok, "")
+	case smithytracing.SpanStatusError:
+		s.span.SetStatus(codes.Error, "")
+	}
+}
+
+func (s *smithySpan) SetProperty(k, v any) {
+	s.span.SetAttributes(smithyAttribute(k, v))
+}
+
+func (s *smithySpan) End() {
+	s.span.End()
+}
+
+func spanKind(kind smithytracing.SpanKind) trace.SpanKind {
+	switch kind {
+	case smithytracing.SpanKindClient:
+		return trace.SpanKindClient
+	case smithytracing.SpanKindServer:
+		return trace.SpanKindServer
+	case smithytracing.SpanKindProducer:
+		return trace.SpanKindProducer
+	case smithytracing.SpanKindConsumer:
+		return trace.SpanKindConsumer
+	default:
+		return trace.SpanKindInternal
+	}
+}
+
+func smithyAttributes(properties map[any]any) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(properties))
+	for k, v := range properties {
+		attributes = append(attributes, smithyAttribute(k, v))
+	}
+	return attributes
+}
+
+func smithyAttribute(k, v any) attribute.KeyValue {
+	key := fmt.Sprint(k)
+	switch value := v.(type) {
+	case string:
+		return attribute.String(key, value)
+	case bool:
+		return attribute.Bool(key, value)
+	case int:
+		return attribute.Int(key, value)
+	case int64:
+		return attribute.Int64(key, value)
+	case float64:
+		return attribute.Float64(key, value)
+	default:
+		return attribute.String(key, fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,83 @@
+// Package tracing instruments the request path with OpenTelemetry spans.
+// Spans are started on the global tracer provider, which drops them until
+// Setup installs one that exports them, so instrumented code needs no checks
+// whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// instrumentationName is the name of the tracer of the proxy's own spans
+const instrumentationName = "github.com/guided-traffic/s3-encryption-proxy"
+
+// defaultURLPath is the OTLP/HTTP path of traces, used when the endpoint has
+// no path
+const defaultURLPath = "/v1/traces"
+
+// Setup installs a tracer provider exporting spans to the OTLP/HTTP collector
+// of cfg in batches, and the W3C trace context propagator. The returned
+// function flushes the pending spans and stops the export.
+func Setup(ctx context.Context, cfg config.TracingConfig, version string) (func(context.Context) error, error) {
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(cfg.Endpoint),
+		otlptracehttp.WithTimeout(time.Duration(cfg.Timeout) * time.Second),
+	}
+	if u, err := url.Parse(cfg.Endpoint); err == nil && (u.Path == "" || u.Path == "/") {
+		options = append(options, otlptracehttp.WithURLPath(defaultURLPath))
+	}
+	if len(cfg.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span that is a child of the span of ctx, if any
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartServer starts the span of a request served for a client, a child of
+// the span of ctx, if any, e.g. one extracted from the request headers
+func StartServer(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// End ends span, marking it failed with err unless err is nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	smithytracing "github.com/aws/smithy-go/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// restoreTracerProvider restores the global tracer provider after the test
+func restoreTracerProvider(t *testing.T) {
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+}
+
+func TestSetup(t *testing.T) {
+	restoreTracerProvider(t)
+	propagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+	exported := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case exported <- r:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Endpoint:    server.URL,
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		ServiceName: "s3-encryption-proxy",
+		SampleRatio: 1,
+		Timeout:     5,
+	}, "test")
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "test")
+	End(span, errors.New("failed"))
+	require.NoError(t, shutdown(context.Background()))
+
+	select {
+	case r := <-exported:
+		assert.Equal(t, defaultURLPath, r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+	default:
+		t.Fatal("no spans were exported")
+	}
+}
+
+func TestSmithyTracerProvider(t *testing.T) {
+	restoreTracerProvider(t)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := Start(context.Background(), "request")
+	tracer := SmithyTracerProvider().Tracer("github.com/aws/aws-sdk-go-v2/service/s3")
+	_, span := tracer.StartSpan(ctx, "S3.PutObject", func(o *smithytracing.SpanOptions) {
+		o.Kind = smithytracing.SpanKindClient
+		o.Properties.Set("rpc.method", "PutObject")
+	})
+	span.SetProperty("http.response.status_code", 200)
+	span.SetStatus(smithytracing.SpanStatusError)
+	span.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	sdkSpan := spans[0]
+	assert.Equal(t, "S3.PutObject", sdkSpan.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), sdkSpan.Parent().SpanID())
+	assert.Equal(t, codes.Error, sdkSpan.Status().Code)
+	assert.Equal(t, sdkSpan.SpanContext().SpanID().String(), span.Context().SpanID)
+
+	attributes := make(map[string]any)
+	for _, kv := range sdkSpan.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	assert.Equal(t, "PutObject", attributes["rpc.method"])
+	assert.Equal(t, int64(200), attributes["http.response.status_code"])
+}
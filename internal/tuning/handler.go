@@ -0,0 +1,100 @@
+package tuning
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// BasePath is where the tuning stats endpoint is mounted on the monitoring server
+const BasePath = "/admin/tuning"
+
+// Settings are the effective network tuning values
+type Settings struct {
+	ResponseBufferSize     int
+	Listener               SocketOptions
+	Backend                SocketOptions
+	BackendWriteBufferSize int
+	BackendReadBufferSize  int
+}
+
+// SettingsFromConfig returns the effective values of optimizations.network
+func SettingsFromConfig(cfg *config.Config) Settings {
+	network := cfg.Optimizations.Network
+	return Settings{
+		ResponseBufferSize: cfg.GetResponseBufferSize(),
+		Listener: SocketOptions{
+			NoDelay:       network.Listener.NoDelay(),
+			SendBuffer:    network.Listener.SendBuffer,
+			ReceiveBuffer: network.Listener.ReceiveBuffer,
+		},
+		Backend: SocketOptions{
+			NoDelay:       network.Backend.NoDelay(),
+			SendBuffer:    network.Backend.SendBuffer,
+			ReceiveBuffer: network.Backend.ReceiveBuffer,
+		},
+		BackendWriteBufferSize: network.Backend.WriteBufferSize,
+		BackendReadBufferSize:  network.Backend.ReadBufferSize,
+	}
+}
+
+// BackendStats adds the HTTP transport buffers to the backend ConnStats
+type BackendStats struct {
+	ConnStats
+	WriteBufferSize int `json:"write_buffer_size"`
+	ReadBufferSize  int `json:"read_buffer_size"`
+}
+
+// Snapshot is the document served by the tuning stats endpoint
+type Snapshot struct {
+	ResponseBufferSize int          `json:"response_buffer_size"`
+	Responses          int64        `json:"responses"`
+	ResponseBytes      int64        `json:"response_bytes"`
+	Listener           ConnStats    `json:"listener"`
+	Backend            BackendStats `json:"backend"`
+}
+
+// Stats returns the settings together with the counters since startup
+func Stats(settings Settings) Snapshot {
+	return Snapshot{
+		ResponseBufferSize: settings.ResponseBufferSize,
+		Responses:          responses.Load(),
+		ResponseBytes:      responseBytes.Load(),
+		Listener:           listenerStats.snapshot(settings.Listener),
+		Backend: BackendStats{
+			ConnStats:       backendStats.snapshot(settings.Backend),
+			WriteBufferSize: settings.BackendWriteBufferSize,
+			ReadBufferSize:  settings.BackendReadBufferSize,
+		},
+	}
+}
+
+// Handler serves the Snapshot as JSON:
+//
+//	GET /admin/tuning  effective buffer and socket settings and connection counts
+type Handler struct {
+	settings Settings
+	logger   *logrus.Entry
+}
+
+// NewHandler creates a new tuning stats HTTP handler
+func NewHandler(settings Settings, logger *logrus.Entry) *Handler {
+	return &Handler{settings: settings, logger: logger}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Stats(h.settings)); err != nil {
+		h.logger.WithError(err).Error("Failed to write tuning stats response")
+	}
+}
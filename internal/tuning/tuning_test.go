@@ -0,0 +1,180 @@
+package tuning
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+// loopbackPair returns both ends of a TCP connection over loopback
+func loopbackPair(t testing.TB) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	server := <-accepted
+	require.NotNil(t, server)
+	return client, server
+}
+
+func TestSocketOptionsApply(t *testing.T) {
+	client, server := loopbackPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	options := SocketOptions{NoDelay: false, SendBuffer: 1 << 20, ReceiveBuffer: 1 << 20}
+	assert.NoError(t, options.Apply(client))
+	assert.NoError(t, options.Apply(server))
+
+	// Non-TCP connections are left alone
+	pipeA, pipeB := net.Pipe()
+	defer pipeA.Close()
+	defer pipeB.Close()
+	assert.NoError(t, options.Apply(pipeA))
+}
+
+func TestListenerAppliesOptions(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := NewListener(inner, SocketOptions{NoDelay: true}, testLogger())
+	defer ln.Close()
+
+	before := listenerStats.connections.Load()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Equal(t, before+1, listenerStats.connections.Load())
+}
+
+func TestDialContextAppliesOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	before := backendStats.connections.Load()
+
+	dial := DialContext(&net.Dialer{}, SocketOptions{NoDelay: true, ReceiveBuffer: 1 << 20}, testLogger())
+	conn, err := dial(t.Context(), "tcp", ln.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Equal(t, before+1, backendStats.connections.Load())
+}
+
+func TestHandler(t *testing.T) {
+	settings := Settings{
+		ResponseBufferSize:     256 * 1024,
+		Listener:               SocketOptions{NoDelay: true, SendBuffer: 4 << 20},
+		Backend:                SocketOptions{NoDelay: true, ReceiveBuffer: 8 << 20},
+		BackendWriteBufferSize: 64 * 1024,
+		BackendReadBufferSize:  64 * 1024,
+	}
+	RecordResponse(1024)
+	handler := NewHandler(settings, testLogger())
+
+	t.Run("get", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var snapshot Snapshot
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+		assert.Equal(t, 256*1024, snapshot.ResponseBufferSize)
+		assert.GreaterOrEqual(t, snapshot.Responses, int64(1))
+		assert.GreaterOrEqual(t, snapshot.ResponseBytes, int64(1024))
+		assert.True(t, snapshot.Listener.NoDelay)
+		assert.Equal(t, 4<<20, snapshot.Listener.SendBuffer)
+		assert.Equal(t, 8<<20, snapshot.Backend.ReceiveBuffer)
+		assert.Equal(t, 64*1024, snapshot.Backend.WriteBufferSize)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, BasePath, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodGet, rec.Header().Get("Allow"))
+	})
+}
+
+// BenchmarkResponseCopy streams a 64MB body over a loopback TCP connection
+// with the copy buffer sizes a GET response can be written with. See the
+// package documentation for results.
+func BenchmarkResponseCopy(b *testing.B) {
+	const bodySize = 64 << 20
+	body := bytes.Repeat([]byte{0x5a}, bodySize)
+
+	for _, size := range []int{32 * 1024, 128 * 1024, 1024 * 1024} {
+		b.Run(fmt.Sprintf("buffer=%dKB", size/1024), func(b *testing.B) {
+			client, server := loopbackPair(b)
+			defer client.Close()
+			defer server.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = io.Copy(io.Discard, client)
+			}()
+
+			buf := make([]byte, size)
+			b.SetBytes(bodySize)
+			b.ResetTimer()
+			for b.Loop() {
+				if _, err := io.CopyBuffer(server, onlyReader{bytes.NewReader(body)}, buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			server.Close()
+			<-done
+		})
+	}
+}
+
+// onlyReader hides WriterTo so that io.CopyBuffer uses the buffer, as it does
+// for the decrypting readers of a GET response
+type onlyReader struct {
+	io.Reader
+}
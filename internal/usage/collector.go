@@ -0,0 +1,330 @@
+// Package usage periodically aggregates the object count and the logical
+// (plaintext) size of every bucket. Listings only report the ciphertext size,
+// so every object is HEADed for its encryption metadata: the plaintext size
+// is taken from the stored plaintext-size, derived from the ciphertext size
+// for AES-GCM objects without it, and equals the listed size otherwise.
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/monitoring"
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+const (
+	defaultInterval        = time.Hour
+	defaultMaxBuckets      = 1000
+	defaultHeadConcurrency = 8
+)
+
+// plaintextSizeMetadataKey is the metadata key (after the prefix) the object
+// handler stores the plaintext size under
+const plaintextSizeMetadataKey = "plaintext-size"
+
+// ErrNotCollected is returned for buckets without a completed collection
+var ErrNotCollected = errors.New("bucket usage has not been collected")
+
+// Backend is the subset of the S3 client used by the collector
+type Backend interface {
+	ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// Config holds usage collector configuration
+type Config struct {
+	Interval        time.Duration // Time between collections (default: 1h)
+	Buckets         []string      // Buckets to collect; empty collects every bucket of the backend
+	MaxBuckets      int           // Upper bound on collected buckets, to bound metric series (default: 1000)
+	HeadConcurrency int           // HeadObject requests in flight per bucket (default: 8)
+}
+
+// BucketUsage is the result of the last collection of one bucket
+type BucketUsage struct {
+	Bucket           string        `json:"bucket"`
+	Objects          int64         `json:"objects"`
+	EncryptedObjects int64         `json:"encrypted_objects"`
+	PlaintextBytes   int64         `json:"plaintext_bytes"`
+	StoredBytes      int64         `json:"stored_bytes"`
+	CollectedAt      time.Time     `json:"collected_at"`
+	Duration         time.Duration `json:"duration_ns"`
+	Error            string        `json:"error,omitempty"` // set when the last attempt failed; the counts are from the last success
+}
+
+// Collector runs the periodic collection and keeps the latest results
+type Collector struct {
+	backend        Backend
+	metadataPrefix string
+	config         Config
+	logger         *logrus.Entry
+
+	mu      sync.RWMutex
+	buckets map[string]BucketUsage
+}
+
+// NewCollector creates a new usage collector
+func NewCollector(backend Backend, metadataPrefix string, cfg Config, logger *logrus.Entry) *Collector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.MaxBuckets <= 0 {
+		cfg.MaxBuckets = defaultMaxBuckets
+	}
+	if cfg.HeadConcurrency <= 0 {
+		cfg.HeadConcurrency = defaultHeadConcurrency
+	}
+	return &Collector{
+		backend:        backend,
+		metadataPrefix: metadataPrefix,
+		config:         cfg,
+		logger:         logger.WithField("component", "usage"),
+		buckets:        make(map[string]BucketUsage),
+	}
+}
+
+// Run collects once immediately and then once per interval until ctx is
+// cancelled
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Collect(ctx); err != nil && ctx.Err() == nil {
+			c.logger.WithError(err).Warn("Usage collection failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect aggregates the usage of every configured bucket. A failing bucket
+// keeps its previous counts and records the error; the other buckets are
+// still collected.
+func (c *Collector) Collect(ctx context.Context) error {
+	start := time.Now()
+	buckets, err := c.bucketNames(ctx)
+	if err != nil {
+		monitoring.RecordUsageCollection(time.Since(start), err)
+		return err
+	}
+
+	var failed int
+	for _, bucket := range buckets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		usage, err := c.collectBucket(ctx, bucket)
+		if err != nil {
+			failed++
+			c.logger.WithError(err).WithField("bucket", bucket).Warn("Failed to collect bucket usage")
+		}
+		c.store(bucket, usage, err)
+	}
+	c.prune(buckets)
+
+	if failed > 0 {
+		err = fmt.Errorf("usage collection failed for %d of %d buckets", failed, len(buckets))
+	}
+	monitoring.RecordUsageCollection(time.Since(start), err)
+	c.logger.WithFields(logrus.Fields{
+		"buckets":  len(buckets),
+		"failed":   failed,
+		"duration": time.Since(start),
+	}).Debug("Usage collection finished")
+	return err
+}
+
+// Usage returns the last results of all buckets, sorted by bucket name
+func (c *Collector) Usage() []BucketUsage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]BucketUsage, 0, len(c.buckets))
+	for _, usage := range c.buckets {
+		result = append(result, usage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bucket < result[j].Bucket })
+	return result
+}
+
+// BucketUsage returns the last result of one bucket
+func (c *Collector) BucketUsage(bucket string) (BucketUsage, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	usage, ok := c.buckets[bucket]
+	if !ok {
+		return BucketUsage{}, ErrNotCollected
+	}
+	return usage, nil
+}
+
+// bucketNames returns the configured buckets, or all backend buckets, capped
+// at MaxBuckets
+func (c *Collector) bucketNames(ctx context.Context) ([]string, error) {
+	buckets := c.config.Buckets
+	if len(buckets) == 0 {
+		output, err := c.backend.ListBuckets(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list buckets: %w", err)
+		}
+		for _, bucket := range output.Buckets {
+			buckets = append(buckets, aws.ToString(bucket.Name))
+		}
+		sort.Strings(buckets)
+	}
+
+	if len(buckets) > c.config.MaxBuckets {
+		c.logger.WithFields(logrus.Fields{
+			"buckets":     len(buckets),
+			"max_buckets": c.config.MaxBuckets,
+		}).Warn("More buckets than max_buckets, collecting the first ones only")
+		buckets = buckets[:c.config.MaxBuckets]
+	}
+	return buckets, nil
+}
+
+// collectBucket lists a bucket and HEADs its objects for their plaintext size
+func (c *Collector) collectBucket(ctx context.Context, bucket string) (BucketUsage, error) {
+	start := time.Now()
+	usage := BucketUsage{Bucket: bucket}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, c.config.HeadConcurrency)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(c.backend, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+	for paginator.HasMorePages() && ctx.Err() == nil {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fail(fmt.Errorf("failed to list objects: %w", err))
+			break
+		}
+
+		for _, object := range page.Contents {
+			if ctx.Err() != nil {
+				break
+			}
+			key := aws.ToString(object.Key)
+			storedSize := aws.ToInt64(object.Size)
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-slots; wg.Done() }()
+
+				plaintextSize, encrypted, err := c.objectSize(ctx, bucket, key, storedSize)
+				if err != nil {
+					fail(fmt.Errorf("failed to read metadata of %s: %w", key, err))
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				usage.Objects++
+				usage.StoredBytes += storedSize
+				usage.PlaintextBytes += plaintextSize
+				if encrypted {
+					usage.EncryptedObjects++
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	usage.CollectedAt = time.Now().UTC()
+	usage.Duration = time.Since(start)
+	return usage, firstErr
+}
+
+// objectSize returns the plaintext size of an object and whether it is
+// encrypted
+func (c *Collector) objectSize(ctx context.Context, bucket, key string, storedSize int64) (int64, bool, error) {
+	head, err := c.backend.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	_, encrypted := head.Metadata[c.metadataPrefix+"encrypted-dek"]
+	if value, ok := head.Metadata[c.metadataPrefix+plaintextSizeMetadataKey]; ok {
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size >= 0 {
+			return size, encrypted, nil
+		}
+	}
+	// aes-gcm, aes-gcm-siv and chacha20-poly1305 add their nonces and tags
+	segmentSize, _ := strconv.ParseInt(head.Metadata[c.metadataPrefix+"segment-size"], 10, 64)
+	if size := encryption.ComputePlaintextSize(storedSize, head.Metadata[c.metadataPrefix+"dek-algorithm"], segmentSize); size >= 0 {
+		return size, encrypted, nil
+	}
+	return storedSize, encrypted, nil
+}
+
+// store records the result of one bucket. On failure the previous counts are
+// kept and only the error is updated.
+func (c *Collector) store(bucket string, usage BucketUsage, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		previous, ok := c.buckets[bucket]
+		if !ok {
+			previous = BucketUsage{Bucket: bucket}
+		}
+		previous.Error = err.Error()
+		c.buckets[bucket] = previous
+		return
+	}
+
+	c.buckets[bucket] = usage
+	monitoring.SetBucketUsage(bucket, usage.Objects, usage.PlaintextBytes, usage.StoredBytes)
+}
+
+// prune drops buckets that are no longer collected, so deleted buckets do not
+// keep reporting their last size
+func (c *Collector) prune(current []string) {
+	keep := make(map[string]bool, len(current))
+	for _, bucket := range current {
+		keep[bucket] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for bucket := range c.buckets {
+		if !keep[bucket] {
+			delete(c.buckets, bucket)
+			monitoring.DeleteBucketUsage(bucket)
+		}
+	}
+}
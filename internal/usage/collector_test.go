@@ -0,0 +1,208 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrefix = "s3ep-"
+
+type fakeObject struct {
+	size     int64
+	metadata map[string]string
+}
+
+// fakeBackend serves buckets of objects, two per listing page. Listing a
+// bucket in failing returns an error.
+type fakeBackend struct {
+	buckets map[string]map[string]fakeObject
+	failing map[string]bool
+}
+
+func (f *fakeBackend) ListBuckets(_ context.Context, _ *s3.ListBucketsInput, _ ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	out := &s3.ListBucketsOutput{}
+	for name := range f.buckets {
+		out.Buckets = append(out.Buckets, types.Bucket{Name: aws.String(name)})
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	bucket := aws.ToString(params.Bucket)
+	if f.failing[bucket] {
+		return nil, errors.New("access denied")
+	}
+
+	var keys []string
+	for key := range f.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if params.ContinuationToken != nil {
+		_, _ = fmt.Sscanf(aws.ToString(params.ContinuationToken), "%d", &start)
+	}
+	end := min(start+2, len(keys))
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	for _, key := range keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(f.buckets[bucket][key].size)})
+	}
+	if end < len(keys) {
+		out.NextContinuationToken = aws.String(fmt.Sprintf("%d", end))
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	object := f.buckets[aws.ToString(params.Bucket)][aws.ToString(params.Key)]
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(object.size), Metadata: object.metadata}, nil
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func newTestBackend() *fakeBackend {
+	return &fakeBackend{
+		buckets: map[string]map[string]fakeObject{
+			"data": {
+				// AES-GCM with stored plaintext size
+				"a": {size: 1028, metadata: map[string]string{
+					testPrefix + "encrypted-dek":  "x",
+					testPrefix + "dek-algorithm":  "aes-gcm",
+					testPrefix + "plaintext-size": "1000",
+				}},
+				// Legacy AES-GCM without stored plaintext size
+				"b": {size: 128, metadata: map[string]string{
+					testPrefix + "encrypted-dek": "x",
+					testPrefix + "dek-algorithm": "aes-gcm",
+				}},
+				// AES-CTR, ciphertext size equals plaintext size
+				"c": {size: 5000, metadata: map[string]string{
+					testPrefix + "encrypted-dek": "x",
+					testPrefix + "dek-algorithm": "aes-ctr",
+				}},
+				// Unencrypted
+				"d": {size: 42},
+			},
+			"empty": {},
+		},
+		failing: map[string]bool{},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	backend := newTestBackend()
+	collector := NewCollector(backend, testPrefix, Config{HeadConcurrency: 2}, testLogger())
+
+	require.NoError(t, collector.Collect(t.Context()))
+
+	data, err := collector.BucketUsage("data")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), data.Objects)
+	assert.Equal(t, int64(3), data.EncryptedObjects)
+	assert.Equal(t, int64(1028+128+5000+42), data.StoredBytes)
+	assert.Equal(t, int64(1000+100+5000+42), data.PlaintextBytes)
+	assert.Empty(t, data.Error)
+	assert.False(t, data.CollectedAt.IsZero())
+
+	empty, err := collector.BucketUsage("empty")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), empty.Objects)
+
+	usage := collector.Usage()
+	require.Len(t, usage, 2)
+	assert.Equal(t, "data", usage[0].Bucket)
+
+	_, err = collector.BucketUsage("missing")
+	assert.ErrorIs(t, err, ErrNotCollected)
+}
+
+func TestCollectKeepsLastResultOnFailure(t *testing.T) {
+	backend := newTestBackend()
+	collector := NewCollector(backend, testPrefix, Config{}, testLogger())
+	require.NoError(t, collector.Collect(t.Context()))
+
+	backend.failing["data"] = true
+	err := collector.Collect(t.Context())
+	require.Error(t, err)
+
+	data, err := collector.BucketUsage("data")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), data.Objects)
+	assert.Contains(t, data.Error, "access denied")
+
+	// The other bucket is still collected
+	empty, err := collector.BucketUsage("empty")
+	require.NoError(t, err)
+	assert.Empty(t, empty.Error)
+}
+
+func TestCollectBucketSelection(t *testing.T) {
+	backend := newTestBackend()
+
+	collector := NewCollector(backend, testPrefix, Config{Buckets: []string{"data"}}, testLogger())
+	require.NoError(t, collector.Collect(t.Context()))
+	assert.Len(t, collector.Usage(), 1)
+
+	collector = NewCollector(backend, testPrefix, Config{MaxBuckets: 1}, testLogger())
+	require.NoError(t, collector.Collect(t.Context()))
+	usage := collector.Usage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, "data", usage[0].Bucket)
+
+	// Buckets that disappear are dropped
+	delete(backend.buckets, "data")
+	require.NoError(t, collector.Collect(t.Context()))
+	usage = collector.Usage()
+	require.Len(t, usage, 1)
+	assert.Equal(t, "empty", usage[0].Bucket)
+}
+
+func TestHandler(t *testing.T) {
+	collector := NewCollector(newTestBackend(), testPrefix, Config{}, testLogger())
+	require.NoError(t, collector.Collect(t.Context()))
+	handler := NewHandler(collector, testLogger())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Buckets []BucketUsage `json:"buckets"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Buckets, 2)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/data", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var bucket BucketUsage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bucket))
+	assert.Equal(t, int64(6142), bucket.PlaintextBytes)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, BasePath+"/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, BasePath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BasePath is where the usage endpoints are mounted on the monitoring server
+const BasePath = "/admin/usage"
+
+// Handler exposes the collector results over HTTP:
+//
+//	GET /admin/usage           usage of all collected buckets
+//	GET /admin/usage/{bucket}  usage of one bucket
+type Handler struct {
+	collector *Collector
+	logger    *logrus.Entry
+	mux       *http.ServeMux
+}
+
+// NewHandler creates a new usage HTTP handler
+func NewHandler(collector *Collector, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		collector: collector,
+		logger:    logger,
+		mux:       http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+BasePath, h.handleList)
+	h.mux.HandleFunc("GET "+BasePath+"/{bucket}", h.handleBucket)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"buckets": h.collector.Usage()})
+}
+
+func (h *Handler) handleBucket(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.collector.BucketUsage(r.PathValue("bucket"))
+	if errors.Is(err, ErrNotCollected) {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, usage)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.WithError(err).Error("Failed to write usage response")
+	}
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// BenchmarkIntegrityAlgorithms compares the integrity MACs on a small and a
+// large object, including the per-object HKDF key derivation
+func BenchmarkIntegrityAlgorithms(b *testing.B) {
+	manager := NewHMACManager(nil)
+	dek := make([]byte, 32)
+	_, _ = rand.Read(dek)
+
+	for _, size := range []int{64 * 1024, 16 * 1024 * 1024} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+
+		for _, algorithm := range []string{
+			config.IntegrityAlgorithmHMACSHA256,
+			config.IntegrityAlgorithmHMACSHA512,
+			config.IntegrityAlgorithmBLAKE3,
+		} {
+			b.Run(fmt.Sprintf("%s/%dKB", algorithm, size/1024), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for b.Loop() {
+					calculator, err := manager.CreateCalculatorWithAlgorithm(dek, algorithm)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := calculator.Add(data); err != nil {
+						b.Fatal(err)
+					}
+					manager.FinalizeCalculator(calculator)
+				}
+			})
+		}
+	}
+}
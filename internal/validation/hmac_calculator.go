@@ -2,11 +2,15 @@ package validation
 
 import (
 	"bufio"
-	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
 	"hash"
 	"io"
+
+	"lukechampine.com/blake3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
 )
 
 // HMACCalculator provides a simplified interface for HMAC operations.
@@ -15,24 +19,49 @@ import (
 type HMACCalculator struct {
 	hmacKey    []byte    // HMAC key stored in memory
 	calculator hash.Hash // HMAC calculator instance
+	algorithm  string    // integrity algorithm, see config.IntegrityAlgorithm*
 }
 
-// NewHMACCalculator creates a new HMAC calculator with the provided HMAC key.
+// NewHMACCalculator creates a new HMAC-SHA256 calculator with the provided HMAC key.
 // The key is stored in memory for the lifetime of the calculator.
 func NewHMACCalculator(hmacKey []byte) (*HMACCalculator, error) {
+	return NewHMACCalculatorWithAlgorithm(hmacKey, config.IntegrityAlgorithmHMACSHA256)
+}
+
+// NewHMACCalculatorWithAlgorithm creates a calculator for the named integrity
+// algorithm. blake3-keyed requires a 32-byte key.
+func NewHMACCalculatorWithAlgorithm(hmacKey []byte, algorithm string) (*HMACCalculator, error) {
 	if len(hmacKey) == 0 {
 		return nil, fmt.Errorf("HMAC key is empty")
 	}
 
-	// Create HMAC calculator with the provided key
-	calculator := hmac.New(sha256.New, hmacKey)
+	var calculator hash.Hash
+	switch algorithm {
+	case config.IntegrityAlgorithmHMACSHA256:
+		calculator = newResumableHMAC(sha256.New, hmacKey)
+	case config.IntegrityAlgorithmHMACSHA512:
+		calculator = newResumableHMAC(sha512.New, hmacKey)
+	case config.IntegrityAlgorithmBLAKE3:
+		if len(hmacKey) != blake3KeySize {
+			return nil, fmt.Errorf("blake3-keyed requires a %d-byte key, got %d bytes", blake3KeySize, len(hmacKey))
+		}
+		calculator = blake3.New(blake3KeySize, hmacKey)
+	default:
+		return nil, fmt.Errorf("unsupported integrity algorithm: %s", algorithm)
+	}
 
 	return &HMACCalculator{
 		hmacKey:    hmacKey,
 		calculator: calculator,
+		algorithm:  algorithm,
 	}, nil
 }
 
+// Algorithm returns the integrity algorithm of the calculator
+func (hc *HMACCalculator) Algorithm() string {
+	return hc.algorithm
+}
+
 // Add processes data through the HMAC calculator.
 // This method can be called multiple times to feed data incrementally.
 func (hc *HMACCalculator) Add(data []byte) (int, error) {
@@ -97,6 +126,9 @@ func (hc *HMACCalculator) Cleanup() {
 		}
 		hc.hmacKey = nil
 	}
+	if clearable, ok := hc.calculator.(interface{ clear() }); ok {
+		clearable.clear()
+	}
 	hc.calculator = nil
 }
 
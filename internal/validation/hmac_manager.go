@@ -3,7 +3,9 @@ package validation
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/hkdf"
@@ -15,8 +17,22 @@ const (
 	// HKDF constants for integrity verification according to specification
 	hmacSalt = "s3-proxy-integrity-v1"
 	hmacInfo = "file-hmac-key"
+
+	// blake3KeySize is the key size of keyed BLAKE3
+	blake3KeySize = 32
 )
 
+// integrityKeySizes is the derived key size per integrity algorithm: the
+// digest size for HMAC, the fixed key size for keyed BLAKE3
+var integrityKeySizes = map[string]int{
+	config.IntegrityAlgorithmHMACSHA256: 32,
+	config.IntegrityAlgorithmHMACSHA512: 64,
+	config.IntegrityAlgorithmBLAKE3:     blake3KeySize,
+}
+
+// ErrIntegrityFailure is returned when data does not match its expected HMAC
+var ErrIntegrityFailure = errors.New("data integrity compromised")
+
 // HMACManager provides simplified HMAC operations for data integrity verification.
 // It creates calculators from DEKs, finalizes them, and verifies integrity.
 type HMACManager struct {
@@ -45,39 +61,71 @@ func (hm *HMACManager) SetConfig(cfg *config.Config) {
 	hm.config = cfg
 }
 
-// CreateCalculator creates a new HMAC calculator from a Data Encryption Key (DEK).
+// CreateCalculator creates a new HMAC-SHA256 calculator from a Data Encryption Key (DEK).
 // The DEK is used to derive an HMAC key using HKDF-SHA256.
 func (hm *HMACManager) CreateCalculator(dek []byte) (*HMACCalculator, error) {
+	return hm.CreateCalculatorWithAlgorithm(dek, config.IntegrityAlgorithmHMACSHA256)
+}
+
+// CreateCalculatorWithAlgorithm creates a calculator for the named integrity
+// algorithm from a DEK. The key is derived with HKDF-SHA256; algorithms other
+// than hmac-sha256 use their own info string, so no two algorithms ever share
+// a key for the same DEK.
+func (hm *HMACManager) CreateCalculatorWithAlgorithm(dek []byte, algorithm string) (*HMACCalculator, error) {
 	if len(dek) == 0 {
 		return nil, fmt.Errorf("DEK is empty")
 	}
+	keySize, ok := integrityKeySizes[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported integrity algorithm: %s", algorithm)
+	}
 
 	// Derive HMAC key from DEK using HKDF-SHA256 with fixed constants
-	hkdfReader := hkdf.New(sha256.New, dek, []byte(hmacSalt), []byte(hmacInfo))
+	info := hmacInfo
+	if algorithm != config.IntegrityAlgorithmHMACSHA256 {
+		info = hmacInfo + ":" + algorithm
+	}
+	hkdfReader := hkdf.New(sha256.New, dek, []byte(hmacSalt), []byte(info))
 
-	// Generate 32-byte HMAC key (for HMAC-SHA256)
-	hmacKey := make([]byte, 32)
-	n, err := hkdfReader.Read(hmacKey)
+	hmacKey := make([]byte, keySize)
+	n, err := io.ReadFull(hkdfReader, hmacKey)
 	if err != nil {
 		hm.logger.WithError(err).Error("HKDF key derivation failed")
 		return nil, fmt.Errorf("HKDF key derivation failed: %w", err)
 	}
-	if n != 32 {
+	if n != keySize {
 		hm.logger.WithField("bytes_read", n).Error("HKDF key derivation returned unexpected length")
-		return nil, fmt.Errorf("HKDF key derivation returned %d bytes instead of 32", n)
+		return nil, fmt.Errorf("HKDF key derivation returned %d bytes instead of %d", n, keySize)
 	}
 
 	// Create HMAC calculator with derived key
-	calculator, err := NewHMACCalculator(hmacKey)
+	calculator, err := NewHMACCalculatorWithAlgorithm(hmacKey, algorithm)
 	if err != nil {
 		hm.logger.WithError(err).Error("Failed to create HMAC calculator")
 		return nil, fmt.Errorf("failed to create HMAC calculator: %w", err)
 	}
 
-	hm.logger.Debug("Successfully created HMAC calculator from DEK")
+	hm.logger.WithField("algorithm", algorithm).Debug("Successfully created HMAC calculator from DEK")
 	return calculator, nil
 }
 
+// AlgorithmFor returns the integrity algorithm for new objects in bucket
+func (hm *HMACManager) AlgorithmFor(bucket string) string {
+	if hm.config == nil {
+		return config.IntegrityAlgorithmHMACSHA256
+	}
+	return hm.config.IntegrityAlgorithmFor(bucket)
+}
+
+// AlgorithmForProvider returns the integrity algorithm for new objects in
+// bucket whose DEK is wrapped by the provider with alias ("" for the active one)
+func (hm *HMACManager) AlgorithmForProvider(bucket, alias string) string {
+	if hm.config == nil {
+		return config.IntegrityAlgorithmHMACSHA256
+	}
+	return hm.config.IntegrityAlgorithmForProvider(bucket, alias)
+}
+
 // FinalizeCalculator extracts the current HMAC state from the calculator,
 // performs cleanup on the calculator, and returns the final HMAC value.
 func (hm *HMACManager) FinalizeCalculator(calculator *HMACCalculator) []byte {
@@ -143,9 +191,9 @@ func (hm *HMACManager) VerifyIntegrity(calculator *HMACCalculator, expectedHMAC
 			hm.logger.Error("HMAC verification failed but continuing delivery (lax mode)")
 			return nil // Continue delivery despite failure
 		case config.HMACVerificationStrict, config.HMACVerificationHybrid:
-			return fmt.Errorf("HMAC verification failed: data integrity compromised")
+			return fmt.Errorf("HMAC verification failed: %w", ErrIntegrityFailure)
 		default:
-			return fmt.Errorf("HMAC verification failed: data integrity compromised")
+			return fmt.Errorf("HMAC verification failed: %w", ErrIntegrityFailure)
 		}
 	}
 
@@ -573,3 +573,54 @@ func generateRandomBytes(t *testing.T, size int) []byte {
 	require.NoError(t, err)
 	return bytes
 }
+
+func TestHMACManager_CreateCalculatorWithAlgorithm(t *testing.T) {
+	manager := NewHMACManager(nil)
+	dek := []byte("test-dek-for-algorithm-selection")
+	data := []byte("integrity protected data")
+
+	sizes := map[string]int{
+		config.IntegrityAlgorithmHMACSHA256: 32,
+		config.IntegrityAlgorithmHMACSHA512: 64,
+		config.IntegrityAlgorithmBLAKE3:     32,
+	}
+	macs := make(map[string][]byte)
+	for algorithm, size := range sizes {
+		calculator, err := manager.CreateCalculatorWithAlgorithm(dek, algorithm)
+		require.NoError(t, err, algorithm)
+		assert.Equal(t, algorithm, calculator.Algorithm())
+		_, err = calculator.Add(data)
+		require.NoError(t, err)
+		mac := manager.FinalizeCalculator(calculator)
+		assert.Len(t, mac, size, algorithm)
+		macs[algorithm] = mac
+	}
+
+	// hmac-sha256 keeps the key derivation of objects written before algorithms were selectable
+	legacy, err := manager.CreateCalculator(dek)
+	require.NoError(t, err)
+	_, err = legacy.Add(data)
+	require.NoError(t, err)
+	assert.Equal(t, macs[config.IntegrityAlgorithmHMACSHA256], manager.FinalizeCalculator(legacy))
+
+	// Equal-length MACs of different algorithms must not collide
+	assert.NotEqual(t, macs[config.IntegrityAlgorithmHMACSHA256], macs[config.IntegrityAlgorithmBLAKE3])
+
+	_, err = manager.CreateCalculatorWithAlgorithm(dek, "hmac-md5")
+	assert.Error(t, err)
+	_, err = manager.CreateCalculatorWithAlgorithm(nil, config.IntegrityAlgorithmBLAKE3)
+	assert.Error(t, err)
+}
+
+func TestHMACManager_AlgorithmFor(t *testing.T) {
+	assert.Equal(t, config.IntegrityAlgorithmHMACSHA256, NewHMACManager(nil).AlgorithmFor("any"))
+
+	manager := NewHMACManager(&config.Config{Encryption: config.EncryptionConfig{
+		IntegrityAlgorithm: config.IntegrityAlgorithmHMACSHA512,
+		IntegrityAlgorithmOverrides: []config.IntegrityAlgorithmOverride{
+			{Buckets: []string{"media"}, Algorithm: config.IntegrityAlgorithmBLAKE3},
+		},
+	}})
+	assert.Equal(t, config.IntegrityAlgorithmBLAKE3, manager.AlgorithmFor("media"))
+	assert.Equal(t, config.IntegrityAlgorithmHMACSHA512, manager.AlgorithmFor("other"))
+}
@@ -0,0 +1,170 @@
+package validation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// partMACLabel separates the part number from the plaintext in the MAC of a
+// multipart upload part that was encrypted independently
+const partMACLabel = "s3ep-part\x00"
+
+// PartRecord is a part of an object whose parts were authenticated on their own
+type PartRecord struct {
+	Number int
+	Size   int64
+}
+
+// CreatePartCalculator creates the calculator for one part of a multipart
+// upload whose parts are encrypted independently. The part number is fed
+// ahead of the plaintext, so the MAC of one part cannot stand in for another.
+func (hm *HMACManager) CreatePartCalculator(dek []byte, algorithm string, partNumber int) (*HMACCalculator, error) {
+	calculator, err := hm.CreateCalculatorWithAlgorithm(dek, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := primePart(calculator, partNumber); err != nil {
+		calculator.Cleanup()
+		return nil, err
+	}
+	return calculator, nil
+}
+
+func primePart(calculator *HMACCalculator, partNumber int) error {
+	prefix := append([]byte(partMACLabel), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(prefix[len(partMACLabel):], uint32(partNumber)) // #nosec G115 -- part numbers are at most 10000
+	_, err := calculator.Write(prefix)
+	return err
+}
+
+// AddPart feeds the record of a finished part into an object calculator.
+// Parts must be added in ascending order.
+func (hc *HMACCalculator) AddPart(number int, size int64, mac []byte) error {
+	record := make([]byte, 0, 12+len(mac))
+	record = binary.BigEndian.AppendUint32(record, uint32(number)) // #nosec G115 -- part numbers are at most 10000
+	record = binary.BigEndian.AppendUint64(record, uint64(size))   // #nosec G115 -- part sizes are never negative
+	record = append(record, mac...)
+	_, err := hc.Write(record)
+	return err
+}
+
+// CreatePartedCalculator creates a calculator that verifies the plaintext of
+// a whole object made of independently authenticated parts. The plaintext is
+// split at the part boundaries, each part's MAC is fed into object with
+// AddPart, and Sum returns the object MAC. object is owned by the returned
+// calculator from then on.
+func (hm *HMACManager) CreatePartedCalculator(object *HMACCalculator, dek []byte, parts []PartRecord) (*HMACCalculator, error) {
+	template, err := hm.CreateCalculatorWithAlgorithm(dek, object.Algorithm())
+	if err != nil {
+		return nil, err
+	}
+	parted := &partedHash{object: object, key: template.hmacKey, algorithm: object.Algorithm(), parts: parts}
+	template.hmacKey = nil
+	template.Cleanup()
+
+	if err := parted.advance(); err != nil {
+		parted.clear()
+		return nil, err
+	}
+	return &HMACCalculator{calculator: parted, algorithm: object.Algorithm()}, nil
+}
+
+// partedHash is the hash.Hash behind CreatePartedCalculator
+type partedHash struct {
+	object    *HMACCalculator
+	key       []byte // derived part key
+	algorithm string
+	parts     []PartRecord
+	next      int             // index of the part being written
+	current   *HMACCalculator // calculator of parts[next]
+	remaining int64           // bytes still expected for parts[next]
+}
+
+// advance finishes the current part once it is complete, along with any
+// empty parts that follow it, and starts the next one
+func (h *partedHash) advance() error {
+	for h.current == nil || h.remaining == 0 {
+		if h.current != nil {
+			part := h.parts[h.next]
+			mac := h.current.Sum()
+			h.current.Cleanup()
+			h.current = nil
+			if err := h.object.AddPart(part.Number, part.Size, mac); err != nil {
+				return err
+			}
+			h.next++
+		}
+		if h.next == len(h.parts) {
+			return nil
+		}
+
+		current, err := NewHMACCalculatorWithAlgorithm(append([]byte(nil), h.key...), h.algorithm)
+		if err != nil {
+			return err
+		}
+		if err := primePart(current, h.parts[h.next].Number); err != nil {
+			current.Cleanup()
+			return err
+		}
+		h.current = current
+		h.remaining = h.parts[h.next].Size
+	}
+	return nil
+}
+
+func (h *partedHash) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if h.current == nil {
+			return written, fmt.Errorf("object data exceeds its %d parts", len(h.parts))
+		}
+		n := int(min(int64(len(p)), h.remaining))
+		if _, err := h.current.Write(p[:n]); err != nil {
+			return written, err
+		}
+		h.remaining -= int64(n)
+		written += n
+		p = p[n:]
+		if err := h.advance(); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Sum returns the object MAC over the parts finished so far
+func (h *partedHash) Sum(b []byte) []byte {
+	return append(b, h.object.Sum()...)
+}
+
+// Reset starts over at the first part. The object calculator is reset too,
+// so anything it was primed with is lost.
+func (h *partedHash) Reset() {
+	if h.current != nil {
+		h.current.Cleanup()
+		h.current = nil
+	}
+	h.next = 0
+	h.object.Reset()
+	_ = h.advance()
+}
+
+func (h *partedHash) Size() int {
+	return len(h.object.Sum())
+}
+
+func (h *partedHash) BlockSize() int {
+	return h.object.calculator.BlockSize()
+}
+
+func (h *partedHash) clear() {
+	clear(h.key)
+	if h.current != nil {
+		h.current.Cleanup()
+		h.current = nil
+	}
+	h.object.Cleanup()
+}
+
+var _ hash.Hash = (*partedHash)(nil)
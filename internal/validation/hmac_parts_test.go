@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestPartedCalculator_MatchesPartMACs(t *testing.T) {
+	manager := NewHMACManager(nil)
+	dek := bytes.Repeat([]byte{7}, 32)
+	parts := []PartRecord{{Number: 1, Size: 10}, {Number: 2, Size: 0}, {Number: 5, Size: 3}, {Number: 6, Size: 0}}
+	plaintext := []byte("0123456789abc")
+
+	for _, algorithm := range []string{config.IntegrityAlgorithmHMACSHA256, config.IntegrityAlgorithmBLAKE3} {
+		t.Run(algorithm, func(t *testing.T) {
+			// what the upload records
+			expected, err := manager.CreateCalculatorWithAlgorithm(dek, algorithm)
+			require.NoError(t, err)
+			var offset int64
+			for _, part := range parts {
+				calculator, err := manager.CreatePartCalculator(dek, algorithm, part.Number)
+				require.NoError(t, err)
+				_, err = calculator.Write(plaintext[offset : offset+part.Size])
+				require.NoError(t, err)
+				require.NoError(t, expected.AddPart(part.Number, part.Size, calculator.Sum()))
+				offset += part.Size
+			}
+
+			object, err := manager.CreateCalculatorWithAlgorithm(dek, algorithm)
+			require.NoError(t, err)
+			parted, err := manager.CreatePartedCalculator(object, dek, parts)
+			require.NoError(t, err)
+			for _, b := range plaintext {
+				_, err := parted.Write([]byte{b})
+				require.NoError(t, err)
+			}
+			assert.Equal(t, expected.Sum(), parted.Sum())
+
+			_, err = parted.Write([]byte{0})
+			assert.ErrorContains(t, err, "exceeds its 4 parts")
+			parted.Cleanup()
+		})
+	}
+}
+
+func TestPartCalculator_BindsPartNumber(t *testing.T) {
+	manager := NewHMACManager(nil)
+	dek := bytes.Repeat([]byte{7}, 32)
+
+	one, err := manager.CreatePartCalculator(dek, config.IntegrityAlgorithmHMACSHA256, 1)
+	require.NoError(t, err)
+	two, err := manager.CreatePartCalculator(dek, config.IntegrityAlgorithmHMACSHA256, 2)
+	require.NoError(t, err)
+	_, _ = one.Write([]byte("data"))
+	_, _ = two.Write([]byte("data"))
+	assert.NotEqual(t, one.Sum(), two.Sum())
+}
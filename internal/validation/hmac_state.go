@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrStateNotResumable is returned when the running state of an integrity
+// calculator cannot be serialized. Only the HMAC-SHA2 algorithms support it;
+// the blake3 implementation keeps its state private.
+var ErrStateNotResumable = errors.New("integrity calculator state cannot be serialized")
+
+// resumableHMAC computes HMAC (RFC 2104) over a SHA-2 hash like crypto/hmac,
+// but its running state can be serialized and restored, so an upload can be
+// continued by another process. The output is identical to crypto/hmac.
+type resumableHMAC struct {
+	newHash func() hash.Hash
+	inner   hash.Hash
+	ipad    []byte
+	opad    []byte
+}
+
+func newResumableHMAC(newHash func() hash.Hash, key []byte) *resumableHMAC {
+	inner := newHash()
+	blockSize := inner.BlockSize()
+	if len(key) > blockSize {
+		inner.Write(key)
+		key = inner.Sum(nil)
+		inner.Reset()
+	}
+
+	ipad := make([]byte, blockSize)
+	opad := make([]byte, blockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	inner.Write(ipad)
+
+	return &resumableHMAC{newHash: newHash, inner: inner, ipad: ipad, opad: opad}
+}
+
+func (h *resumableHMAC) Write(p []byte) (int, error) {
+	return h.inner.Write(p)
+}
+
+func (h *resumableHMAC) Sum(b []byte) []byte {
+	innerSum := h.inner.Sum(nil)
+	outer := h.newHash()
+	outer.Write(h.opad)
+	outer.Write(innerSum)
+	return outer.Sum(b)
+}
+
+func (h *resumableHMAC) Reset() {
+	h.inner.Reset()
+	h.inner.Write(h.ipad)
+}
+
+func (h *resumableHMAC) Size() int {
+	return h.inner.Size()
+}
+
+func (h *resumableHMAC) BlockSize() int {
+	return h.inner.BlockSize()
+}
+
+// clear zeroes the key pads
+func (h *resumableHMAC) clear() {
+	for i := range h.ipad {
+		h.ipad[i] = 0
+		h.opad[i] = 0
+	}
+}
+
+// MarshalBinary returns the state of the inner hash. It depends on the key
+// and contains up to a block of unprocessed input, so it is as sensitive as
+// the key and the data.
+func (h *resumableHMAC) MarshalBinary() ([]byte, error) {
+	return h.inner.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// UnmarshalBinary restores a state returned by MarshalBinary of an HMAC with
+// the same hash and key
+func (h *resumableHMAC) UnmarshalBinary(state []byte) error {
+	return h.inner.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+}
+
+// MarshalState returns the running state of the calculator, so the
+// calculation can be continued later with RestoreState. It returns
+// ErrStateNotResumable for algorithms that do not support it.
+func (hc *HMACCalculator) MarshalState() ([]byte, error) {
+	if hc.calculator == nil {
+		return nil, fmt.Errorf("HMAC calculator not initialized")
+	}
+	resumable, ok := hc.calculator.(*resumableHMAC)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotResumable, hc.algorithm)
+	}
+	return resumable.MarshalBinary()
+}
+
+// RestoreState continues the calculation from a state returned by
+// MarshalState of a calculator with the same algorithm and key
+func (hc *HMACCalculator) RestoreState(state []byte) error {
+	if hc.calculator == nil {
+		return fmt.Errorf("HMAC calculator not initialized")
+	}
+	resumable, ok := hc.calculator.(*resumableHMAC)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrStateNotResumable, hc.algorithm)
+	}
+	if err := resumable.UnmarshalBinary(state); err != nil {
+		return fmt.Errorf("invalid HMAC state: %w", err)
+	}
+	return nil
+}
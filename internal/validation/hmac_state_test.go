@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func TestResumableHMAC_MatchesCryptoHMAC(t *testing.T) {
+	hashes := map[string]func() hash.Hash{"sha256": sha256.New, "sha512": sha512.New}
+	for name, newHash := range hashes {
+		for _, keySize := range []int{1, 32, 64, 128, 200} {
+			key := bytes.Repeat([]byte{0x0b}, keySize)
+			for _, dataSize := range []int{0, 1, 63, 64, 65, 1000} {
+				data := bytes.Repeat([]byte{0xcd}, dataSize)
+
+				expected := hmac.New(newHash, key)
+				expected.Write(data)
+				actual := newResumableHMAC(newHash, key)
+				actual.Write(data)
+				assert.Equal(t, expected.Sum(nil), actual.Sum(nil), "%s key=%d data=%d", name, keySize, dataSize)
+
+				actual.Reset()
+				actual.Write(data)
+				assert.Equal(t, expected.Sum(nil), actual.Sum(nil), "%s after reset", name)
+			}
+		}
+	}
+}
+
+func TestHMACCalculator_MarshalState(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data := bytes.Repeat([]byte("multipart"), 1000)
+
+	for _, algorithm := range []string{config.IntegrityAlgorithmHMACSHA256, config.IntegrityAlgorithmHMACSHA512} {
+		t.Run(algorithm, func(t *testing.T) {
+			whole, err := NewHMACCalculatorWithAlgorithm(append([]byte{}, key...), algorithm)
+			require.NoError(t, err)
+			_, err = whole.Add(data)
+			require.NoError(t, err)
+
+			first, err := NewHMACCalculatorWithAlgorithm(append([]byte{}, key...), algorithm)
+			require.NoError(t, err)
+			_, err = first.Add(data[:333])
+			require.NoError(t, err)
+			state, err := first.MarshalState()
+			require.NoError(t, err)
+
+			resumed, err := NewHMACCalculatorWithAlgorithm(append([]byte{}, key...), algorithm)
+			require.NoError(t, err)
+			require.NoError(t, resumed.RestoreState(state))
+			_, err = resumed.Add(data[333:])
+			require.NoError(t, err)
+
+			assert.Equal(t, whole.GetCurrentHash(), resumed.GetCurrentHash())
+		})
+	}
+
+	blake, err := NewHMACCalculatorWithAlgorithm(key, config.IntegrityAlgorithmBLAKE3)
+	require.NoError(t, err)
+	_, err = blake.MarshalState()
+	assert.ErrorIs(t, err, ErrStateNotResumable)
+	assert.ErrorIs(t, blake.RestoreState([]byte("state")), ErrStateNotResumable)
+}
+
+func TestHMACCalculator_RestoreStateRejectsOtherHash(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sha512Calc, err := NewHMACCalculatorWithAlgorithm(append([]byte{}, key...), config.IntegrityAlgorithmHMACSHA512)
+	require.NoError(t, err)
+	state, err := sha512Calc.MarshalState()
+	require.NoError(t, err)
+
+	sha256Calc, err := NewHMACCalculator(append([]byte{}, key...))
+	require.NoError(t, err)
+	assert.Error(t, sha256Calc.RestoreState(state))
+}
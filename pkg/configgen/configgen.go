@@ -0,0 +1,360 @@
+// Package configgen builds s3-encryption-proxy configuration files from Go
+// values, so platform teams can generate the provider, bucket and client
+// sections from their own inventory instead of templating YAML by hand.
+//
+// A Config covers the settings that usually come from an inventory. Marshal
+// renders it as YAML and checks the result with the same parser and
+// validation the proxy runs at startup, minus the checks that depend on the
+// host the proxy runs on (license and TLS files):
+//
+//	cfg := configgen.Config{
+//		Backend: configgen.Backend{Endpoint: "https://s3.eu-central-1.amazonaws.com", Region: "eu-central-1"},
+//		Encryption: configgen.Encryption{
+//			ActiveProvider: "aes-2024",
+//			Providers:      []configgen.Provider{configgen.AESProvider("aes-2024", "${AES_KEY}")},
+//		},
+//		Clients: []configgen.Client{{AccessKeyID: "app", SecretKey: "${APP_SECRET}"}},
+//	}
+//	data, err := cfg.Marshal()
+//
+// ${VAR} references are written as they are and expanded by the proxy.
+package configgen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+// ProviderType is the type of an encryption provider
+type ProviderType string
+
+// Encryption provider types
+const (
+	ProviderAES  ProviderType = "aes"
+	ProviderRSA  ProviderType = "rsa"
+	ProviderNone ProviderType = "none"
+)
+
+// IntegrityVerification is the integrity MAC verification mode
+type IntegrityVerification string
+
+// Integrity verification modes
+const (
+	VerificationOff    IntegrityVerification = config.HMACVerificationOff
+	VerificationLax    IntegrityVerification = config.HMACVerificationLax
+	VerificationStrict IntegrityVerification = config.HMACVerificationStrict
+	VerificationHybrid IntegrityVerification = config.HMACVerificationHybrid
+)
+
+// IntegrityAlgorithm is the integrity MAC algorithm of new objects
+type IntegrityAlgorithm string
+
+// Integrity MAC algorithms
+const (
+	HMACSHA256 IntegrityAlgorithm = config.IntegrityAlgorithmHMACSHA256
+	HMACSHA512 IntegrityAlgorithm = config.IntegrityAlgorithmHMACSHA512
+	BLAKE3     IntegrityAlgorithm = config.IntegrityAlgorithmBLAKE3
+)
+
+// Operation is an operation class of a client policy
+type Operation string
+
+// Operation classes of client policies
+const (
+	OperationRead   Operation = config.ScopeOperationRead
+	OperationWrite  Operation = config.ScopeOperationWrite
+	OperationDelete Operation = config.ScopeOperationDelete
+	OperationList   Operation = config.ScopeOperationList
+	OperationBucket Operation = config.ScopeOperationBucket
+)
+
+// Config is a generated proxy configuration. Zero values are left out of the
+// output, so the proxy defaults apply.
+type Config struct {
+	BindAddress string // Listen address (default: 0.0.0.0:8080)
+	LogLevel    string // debug, info, warn or error (default: info)
+	LicenseFile string // Path of the license file (default: config/license.jwt)
+
+	Backend    Backend    // Backend used for all buckets without a route
+	Routes     []Route    // Further backends that buckets can be routed to
+	Encryption Encryption // Encryption providers
+	Buckets    []Bucket   // Per-bucket settings
+	Clients    []Client   // Credentials accepted from S3 clients
+}
+
+// Backend is the default S3 backend
+type Backend struct {
+	Endpoint             string // Backend URL; https:// enables TLS
+	Region               string // Region (default: us-east-1)
+	AccessKeyID          string
+	SecretKey            string
+	InsecureSkipVerify   bool   // Only for development/testing
+	CompatibilityProfile string // generic, aws, minio or ceph (default: generic)
+}
+
+// Route is a further backend endpoint with its own credentials
+type Route struct {
+	Name               string // Referenced by Bucket.Route, used in logs and metrics
+	Endpoint           string
+	Region             string // Region (default: the Backend region)
+	AccessKeyID        string
+	SecretKey          string
+	InsecureSkipVerify bool   // Only for development/testing
+	HealthCheckBucket  string // Bucket probed with HeadBucket (default: ListBuckets)
+}
+
+// Encryption holds the encryption providers
+type Encryption struct {
+	ActiveProvider        string                // Alias of the provider new objects are encrypted with
+	Providers             []Provider            // All providers, including those only needed to read old objects
+	IntegrityVerification IntegrityVerification // default: off
+	IntegrityAlgorithm    IntegrityAlgorithm    // default: hmac-sha256
+	RecoveryRecipients    []string              // age recipients ("age1...") that get a recovery copy of every DEK
+}
+
+// Provider is an encryption provider. Use AESProvider, RSAProvider or
+// NoneProvider to get the settings right for each type.
+type Provider struct {
+	Alias       string
+	Type        ProviderType
+	Description string
+	Settings    map[string]string // Provider-specific settings, written to its config section
+
+	IntegrityAlgorithm IntegrityAlgorithm // Overrides Encryption.IntegrityAlgorithm while the provider is active
+
+	// Client-side limit on key operations, see the proxy configuration
+	RateLimit    float64 // Operations per second (default: 0 = unlimited)
+	RateBurst    int
+	MaxQueue     int
+	QueueTimeout int // Seconds
+}
+
+// AESProvider returns an AES envelope encryption provider with the given
+// base64-encoded 256-bit key
+func AESProvider(alias, key string) Provider {
+	return Provider{Alias: alias, Type: ProviderAES, Settings: map[string]string{"aes_key": key}}
+}
+
+// RSAProvider returns an RSA envelope encryption provider with the given PEM
+// encoded key pair
+func RSAProvider(alias, publicKeyPEM, privateKeyPEM string) Provider {
+	return Provider{Alias: alias, Type: ProviderRSA, Settings: map[string]string{
+		"public_key_pem":  publicKeyPEM,
+		"private_key_pem": privateKeyPEM,
+	}}
+}
+
+// NoneProvider returns a pass-through provider that stores objects unencrypted
+func NoneProvider(alias string) Provider {
+	return Provider{Alias: alias, Type: ProviderNone}
+}
+
+// Bucket holds the settings of a bucket, or of all buckets starting with a
+// prefix if Name ends in "*"
+type Bucket struct {
+	Name               string
+	Route              string             // Name of the route serving the bucket (default: Backend)
+	IntegrityAlgorithm IntegrityAlgorithm // Integrity MAC of new objects in the bucket
+}
+
+// Client is an S3 client credential
+type Client struct {
+	AccessKeyID string
+	SecretKey   string
+	Description string
+	Policies    []Policy // Requests the client may make; none = any request
+}
+
+// Policy allows a set of operations on objects in a set of buckets. Empty
+// fields match everything.
+type Policy struct {
+	Buckets    []string // Bucket names; a trailing "*" matches a name prefix
+	Prefixes   []string // Object key prefixes
+	Operations []Operation
+}
+
+// Marshal validates the configuration and returns it as YAML
+func (c *Config) Marshal() ([]byte, error) {
+	data, err := c.render()
+	if err != nil {
+		return nil, err
+	}
+	if err := check(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Validate checks the configuration the way the proxy does at startup,
+// except for the license and the existence of TLS files
+func (c *Config) Validate() error {
+	_, err := c.Marshal()
+	return err
+}
+
+// WriteFile validates the configuration and writes it to path. The file
+// usually holds credentials and is created readable by the owner only.
+func (c *Config) WriteFile(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// check parses data like the proxy and validates the result
+func check(data []byte) error {
+	cfg, err := config.Parse(data)
+	if err != nil {
+		return err
+	}
+	if err := config.Check(cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// header is written above the generated configuration
+const header = "# Generated by configgen. Changes made here are lost when it is generated again.\n"
+
+// render builds the YAML document
+func (c *Config) render() ([]byte, error) {
+	doc := document{
+		BindAddress: c.BindAddress,
+		LogLevel:    c.LogLevel,
+		LicenseFile: c.LicenseFile,
+		S3Backend: backendDocument{
+			TargetEndpoint:       c.Backend.Endpoint,
+			Region:               c.Backend.Region,
+			AccessKeyID:          c.Backend.AccessKeyID,
+			SecretKey:            c.Backend.SecretKey,
+			UseTLS:               strings.HasPrefix(c.Backend.Endpoint, "https://"),
+			InsecureSkipVerify:   c.Backend.InsecureSkipVerify,
+			CompatibilityProfile: c.Backend.CompatibilityProfile,
+		},
+		Encryption: encryptionDocument{
+			EncryptionMethodAlias: c.Encryption.ActiveProvider,
+			IntegrityVerification: string(c.Encryption.IntegrityVerification),
+			IntegrityAlgorithm:    string(c.Encryption.IntegrityAlgorithm),
+			RecoveryRecipients:    c.Encryption.RecoveryRecipients,
+		},
+	}
+
+	routes, err := c.routeBuckets()
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range c.Routes {
+		doc.S3Backend.Routes = append(doc.S3Backend.Routes, routeDocument{
+			Name:               route.Name,
+			Buckets:            routes[route.Name],
+			TargetEndpoint:     route.Endpoint,
+			Region:             route.Region,
+			AccessKeyID:        route.AccessKeyID,
+			SecretKey:          route.SecretKey,
+			InsecureSkipVerify: route.InsecureSkipVerify,
+			HealthCheckBucket:  route.HealthCheckBucket,
+		})
+	}
+
+	for _, provider := range c.Encryption.Providers {
+		doc.Encryption.Providers = append(doc.Encryption.Providers, providerDocument{
+			Alias:              provider.Alias,
+			Type:               string(provider.Type),
+			Description:        provider.Description,
+			Config:             provider.Settings,
+			IntegrityAlgorithm: string(provider.IntegrityAlgorithm),
+			RateLimit:          provider.RateLimit,
+			RateBurst:          provider.RateBurst,
+			MaxQueue:           provider.MaxQueue,
+			QueueTimeout:       provider.QueueTimeout,
+		})
+	}
+	doc.Encryption.IntegrityAlgorithmOverrides = c.integrityOverrides()
+
+	for _, client := range c.Clients {
+		clientDoc := clientDocument{
+			Type:        "static",
+			AccessKeyID: client.AccessKeyID,
+			SecretKey:   client.SecretKey,
+			Description: client.Description,
+		}
+		for _, policy := range client.Policies {
+			scope := scopeDocument{Buckets: policy.Buckets, Prefixes: policy.Prefixes}
+			for _, operation := range policy.Operations {
+				scope.Operations = append(scope.Operations, string(operation))
+			}
+			clientDoc.Scopes = append(clientDoc.Scopes, scope)
+		}
+		doc.S3Clients = append(doc.S3Clients, clientDoc)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// routeBuckets returns the bucket names of each route
+func (c *Config) routeBuckets() (map[string][]string, error) {
+	routes := make(map[string][]string, len(c.Routes))
+	for _, route := range c.Routes {
+		routes[route.Name] = nil
+	}
+
+	names := make(map[string]bool, len(c.Buckets))
+	for i, bucket := range c.Buckets {
+		if bucket.Name == "" {
+			return nil, fmt.Errorf("buckets[%d].name is required", i)
+		}
+		if names[bucket.Name] {
+			return nil, fmt.Errorf("buckets[%d]: bucket '%s' is listed twice", i, bucket.Name)
+		}
+		names[bucket.Name] = true
+
+		if bucket.Route == "" {
+			continue
+		}
+		if _, ok := routes[bucket.Route]; !ok {
+			return nil, fmt.Errorf("buckets[%d]: route '%s' is not defined", i, bucket.Route)
+		}
+		routes[bucket.Route] = append(routes[bucket.Route], bucket.Name)
+	}
+	return routes, nil
+}
+
+// integrityOverrides groups the bucket integrity algorithms by algorithm, in
+// the order the algorithms first appear
+func (c *Config) integrityOverrides() []integrityOverrideDocument {
+	var overrides []integrityOverrideDocument
+	index := make(map[IntegrityAlgorithm]int)
+	for _, bucket := range c.Buckets {
+		if bucket.IntegrityAlgorithm == "" {
+			continue
+		}
+		i, ok := index[bucket.IntegrityAlgorithm]
+		if !ok {
+			i = len(overrides)
+			index[bucket.IntegrityAlgorithm] = i
+			overrides = append(overrides, integrityOverrideDocument{Algorithm: string(bucket.IntegrityAlgorithm)})
+		}
+		overrides[i].Buckets = append(overrides[i].Buckets, bucket.Name)
+	}
+	return overrides
+}
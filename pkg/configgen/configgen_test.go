@@ -0,0 +1,142 @@
+package configgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/internal/config"
+)
+
+func testConfig() Config {
+	current := AESProvider("aes-2024", "${AES_KEY_2024}")
+	current.RateLimit = 100
+	current.IntegrityAlgorithm = BLAKE3
+
+	return Config{
+		LogLevel: "debug",
+		Backend: Backend{
+			Endpoint:    "https://s3.eu-central-1.amazonaws.com",
+			Region:      "eu-central-1",
+			AccessKeyID: "${S3_ACCESS_KEY_ID}",
+			SecretKey:   "${S3_SECRET_KEY}",
+		},
+		Routes: []Route{{Name: "archive", Endpoint: "https://archive.example.com", AccessKeyID: "archive", SecretKey: "archive-secret"}},
+		Encryption: Encryption{
+			ActiveProvider:        "aes-2024",
+			IntegrityVerification: VerificationStrict,
+			Providers: []Provider{
+				current,
+				RSAProvider("rsa-2023", "${RSA_PUBLIC_KEY}", "${RSA_PRIVATE_KEY}"),
+				NoneProvider("plain"),
+			},
+			RecoveryRecipients: []string{"age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"},
+		},
+		Buckets: []Bucket{
+			{Name: "invoices", IntegrityAlgorithm: HMACSHA512},
+			{Name: "archive-*", Route: "archive", IntegrityAlgorithm: HMACSHA512},
+			{Name: "cold", Route: "archive"},
+		},
+		Clients: []Client{
+			{AccessKeyID: "admin-client", SecretKey: "${ADMIN_SECRET}"},
+			{AccessKeyID: "billing-service", SecretKey: "${BILLING_SECRET}", Description: "Billing service", Policies: []Policy{
+				{Buckets: []string{"invoices"}, Prefixes: []string{"2024/"}, Operations: []Operation{OperationRead, OperationList}},
+			}},
+		},
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	gen := testConfig()
+	data, err := gen.Marshal()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), header)
+
+	cfg, err := config.Parse(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "https://s3.eu-central-1.amazonaws.com", cfg.S3Backend.TargetEndpoint)
+	assert.True(t, cfg.S3Backend.UseTLS)
+	assert.Equal(t, "${S3_SECRET_KEY}", cfg.S3Backend.SecretKey)
+	require.Len(t, cfg.S3Backend.Routes, 1)
+	assert.Equal(t, []string{"archive-*", "cold"}, cfg.S3Backend.Routes[0].Buckets)
+
+	assert.Equal(t, "aes-2024", cfg.Encryption.EncryptionMethodAlias)
+	assert.Equal(t, config.HMACVerificationStrict, cfg.Encryption.IntegrityVerification)
+	require.Len(t, cfg.Encryption.Providers, 3)
+	assert.Equal(t, "${AES_KEY_2024}", cfg.Encryption.Providers[0].Config["aes_key"])
+	assert.Equal(t, float64(100), cfg.Encryption.Providers[0].RateLimit)
+	assert.Equal(t, config.IntegrityAlgorithmBLAKE3, cfg.Encryption.Providers[0].IntegrityAlgorithm)
+	assert.Equal(t, "${RSA_PRIVATE_KEY}", cfg.Encryption.Providers[1].Config["private_key_pem"])
+	assert.Equal(t, "none", cfg.Encryption.Providers[2].Type)
+	assert.Equal(t, []string{"age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"}, cfg.Encryption.RecoveryRecipients)
+	assert.Equal(t, []config.IntegrityAlgorithmOverride{
+		{Buckets: []string{"invoices", "archive-*"}, Algorithm: config.IntegrityAlgorithmHMACSHA512},
+	}, cfg.Encryption.IntegrityAlgorithmOverrides)
+	assert.Equal(t, config.IntegrityAlgorithmBLAKE3, cfg.IntegrityAlgorithmFor("other"))
+
+	require.Len(t, cfg.S3Clients, 2)
+	assert.Empty(t, cfg.S3Clients[0].Scopes)
+	assert.Equal(t, []config.S3ClientScope{
+		{Buckets: []string{"invoices"}, Prefixes: []string{"2024/"}, Operations: []string{"read", "list"}},
+	}, cfg.S3Clients[1].Scopes)
+
+	// Defaults of the proxy still apply to everything left out
+	assert.Equal(t, "0.0.0.0:8080", cfg.BindAddress)
+	assert.True(t, cfg.S3Backend.ResponseValidation.Enabled)
+}
+
+func TestMarshal_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "missing endpoint", modify: func(c *Config) { c.Backend.Endpoint = "" }, errorMsg: "target_endpoint is required"},
+		{name: "unknown active provider", modify: func(c *Config) { c.Encryption.ActiveProvider = "aes-2025" }, errorMsg: "does not match any provider alias"},
+		{name: "duplicate provider", modify: func(c *Config) {
+			c.Encryption.Providers = append(c.Encryption.Providers, NoneProvider("plain"))
+		}, errorMsg: "duplicate encryption provider alias"},
+		{name: "missing aes key", modify: func(c *Config) { c.Encryption.Providers[0].Settings = nil }, errorMsg: "aes_key is required"},
+		{name: "unknown route", modify: func(c *Config) { c.Buckets[2].Route = "tape" }, errorMsg: "route 'tape' is not defined"},
+		{name: "duplicate bucket", modify: func(c *Config) { c.Buckets[1].Name = "invoices" }, errorMsg: "listed twice"},
+		{name: "unnamed bucket", modify: func(c *Config) { c.Buckets[0].Name = "" }, errorMsg: "buckets[0].name is required"},
+		{name: "unknown operation", modify: func(c *Config) {
+			c.Clients[1].Policies[0].Operations = []Operation{"admin"}
+		}, errorMsg: "s3_clients[1].scopes[0].operations"},
+		{name: "unknown algorithm", modify: func(c *Config) { c.Encryption.IntegrityAlgorithm = "crc32" }, errorMsg: "encryption.integrity_algorithm"},
+		{name: "no clients", modify: func(c *Config) { c.Clients = nil }, errorMsg: "s3_clients"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := testConfig()
+			tt.modify(&gen)
+
+			_, err := gen.Marshal()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+			assert.Equal(t, err, gen.Validate())
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	gen := testConfig()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, gen.WriteFile(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	expected, err := gen.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+}
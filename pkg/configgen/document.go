@@ -0,0 +1,75 @@
+package configgen
+
+// The types below mirror the YAML layout read by internal/config, in the
+// order of config/aes-example.yaml
+
+type document struct {
+	BindAddress string             `yaml:"bind_address,omitempty"`
+	LogLevel    string             `yaml:"log_level,omitempty"`
+	LicenseFile string             `yaml:"license_file,omitempty"`
+	S3Backend   backendDocument    `yaml:"s3_backend"`
+	S3Clients   []clientDocument   `yaml:"s3_clients,omitempty"`
+	Encryption  encryptionDocument `yaml:"encryption"`
+}
+
+type backendDocument struct {
+	TargetEndpoint       string          `yaml:"target_endpoint"`
+	Region               string          `yaml:"region,omitempty"`
+	AccessKeyID          string          `yaml:"access_key_id,omitempty"`
+	SecretKey            string          `yaml:"secret_key,omitempty"`
+	UseTLS               bool            `yaml:"use_tls"`
+	InsecureSkipVerify   bool            `yaml:"insecure_skip_verify,omitempty"`
+	CompatibilityProfile string          `yaml:"compatibility_profile,omitempty"`
+	Routes               []routeDocument `yaml:"routes,omitempty"`
+}
+
+type routeDocument struct {
+	Name               string   `yaml:"name"`
+	Buckets            []string `yaml:"buckets"`
+	TargetEndpoint     string   `yaml:"target_endpoint"`
+	Region             string   `yaml:"region,omitempty"`
+	AccessKeyID        string   `yaml:"access_key_id,omitempty"`
+	SecretKey          string   `yaml:"secret_key,omitempty"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty"`
+	HealthCheckBucket  string   `yaml:"health_check_bucket,omitempty"`
+}
+
+type clientDocument struct {
+	Type        string          `yaml:"type"`
+	AccessKeyID string          `yaml:"access_key_id"`
+	SecretKey   string          `yaml:"secret_key"`
+	Description string          `yaml:"description,omitempty"`
+	Scopes      []scopeDocument `yaml:"scopes,omitempty"`
+}
+
+type scopeDocument struct {
+	Buckets    []string `yaml:"buckets,omitempty"`
+	Prefixes   []string `yaml:"prefixes,omitempty"`
+	Operations []string `yaml:"operations,omitempty"`
+}
+
+type encryptionDocument struct {
+	EncryptionMethodAlias       string                      `yaml:"encryption_method_alias,omitempty"`
+	IntegrityVerification       string                      `yaml:"integrity_verification,omitempty"`
+	IntegrityAlgorithm          string                      `yaml:"integrity_algorithm,omitempty"`
+	IntegrityAlgorithmOverrides []integrityOverrideDocument `yaml:"integrity_algorithm_overrides,omitempty"`
+	RecoveryRecipients          []string                    `yaml:"recovery_recipients,omitempty"`
+	Providers                   []providerDocument          `yaml:"providers,omitempty"`
+}
+
+type integrityOverrideDocument struct {
+	Buckets   []string `yaml:"buckets"`
+	Algorithm string   `yaml:"algorithm"`
+}
+
+type providerDocument struct {
+	Alias              string            `yaml:"alias"`
+	Type               string            `yaml:"type"`
+	Description        string            `yaml:"description,omitempty"`
+	Config             map[string]string `yaml:"config,omitempty"`
+	IntegrityAlgorithm string            `yaml:"integrity_algorithm,omitempty"`
+	RateLimit          float64           `yaml:"rate_limit,omitempty"`
+	RateBurst          int               `yaml:"rate_burst,omitempty"`
+	MaxQueue           int               `yaml:"max_queue,omitempty"`
+	QueueTimeout       int               `yaml:"queue_timeout,omitempty"`
+}
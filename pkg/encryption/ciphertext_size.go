@@ -1,22 +1,75 @@
 package encryption
 
 // GCMOverhead is the number of extra bytes AES-GCM adds to the plaintext:
-// 12-byte nonce prefix + 16-byte authentication tag.
+// 12-byte nonce prefix + 16-byte authentication tag. AES-GCM-SIV and
+// ChaCha20-Poly1305 add the same.
 const GCMOverhead = int64(28)
 
+// aes-gcm and chacha20-poly1305 objects larger than AEADBufferedLimit are
+// sealed in segments of AEADSegmentSize bytes of plaintext, each with its own
+// AEADTagSize tag, after a header of AEADSegmentHeaderSize bytes: a 16-byte
+// magic and the 7-byte nonce prefix
+const (
+	AEADBufferedLimit     = 4 << 20
+	AEADSegmentSize       = 64 << 10
+	AEADSegmentHeaderSize = 23
+	AEADTagSize           = 16
+)
+
 // ComputeCiphertextSize returns the ciphertext size for a plaintext of the given
 // size encrypted with the named algorithm. Returns -1 for unknown algorithms.
 // Algorithm overhead:
-//   - aes-gcm: 28 bytes (12-byte nonce prefix + 16-byte auth tag)
-//   - aes-ctr: 0 bytes
-//   - none:    0 bytes
+//   - aes-gcm, aes-gcm-siv, chacha20-poly1305: 28 bytes (12-byte nonce prefix + 16-byte auth tag)
+//   - segmented aes-gcm, chacha20-poly1305:    23-byte header + 16-byte tag per segment
+//   - aes-ctr, xchacha20:                      0 bytes
+//   - none:                                    0 bytes
 func ComputeCiphertextSize(plaintextSize int64, algorithm string) int64 {
 	switch algorithm {
-	case "aes-gcm":
+	case "aes-gcm", "chacha20-poly1305":
+		if plaintextSize > AEADBufferedLimit {
+			segments := (plaintextSize + AEADSegmentSize - 1) / AEADSegmentSize
+			return AEADSegmentHeaderSize + plaintextSize + segments*AEADTagSize
+		}
+		return plaintextSize + GCMOverhead
+	case "aes-gcm-siv":
 		return plaintextSize + GCMOverhead
-	case "aes-ctr", "none":
+	case "aes-ctr", "xchacha20", "none":
 		return plaintextSize
 	default:
 		return -1
 	}
 }
+
+// ComputePlaintextSize returns the plaintext size of ciphertextSize bytes
+// encrypted with the named algorithm, the inverse of ComputeCiphertextSize.
+// segmentSize is the segment-size recorded with the object, 0 for objects
+// sealed in one piece. Returns -1 for unknown algorithms and ciphertext sizes
+// no plaintext encrypts to.
+func ComputePlaintextSize(ciphertextSize int64, algorithm string, segmentSize int64) int64 {
+	switch algorithm {
+	case "aes-gcm", "aes-gcm-siv", "chacha20-poly1305":
+		if segmentSize > 0 {
+			body := ciphertextSize - AEADSegmentHeaderSize
+			segments := (body + segmentSize + AEADTagSize - 1) / (segmentSize + AEADTagSize)
+			if body <= 0 || body-segments*AEADTagSize <= 0 {
+				return -1
+			}
+			return body - segments*AEADTagSize
+		}
+		if ciphertextSize < GCMOverhead {
+			return -1
+		}
+		return ciphertextSize - GCMOverhead
+	case "aes-ctr", "xchacha20", "none":
+		return ciphertextSize
+	default:
+		return -1
+	}
+}
+
+// IsStreamingAlgorithm reports whether algorithm is a stream cipher without
+// a tag, whose ciphertext can be decrypted from any offset: aes-ctr or
+// xchacha20
+func IsStreamingAlgorithm(algorithm string) bool {
+	return algorithm == string(EncryptionTypeAESCTR) || algorithm == string(EncryptionTypeXChaCha20)
+}
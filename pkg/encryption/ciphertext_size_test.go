@@ -11,8 +11,15 @@ func TestComputeCiphertextSize(t *testing.T) {
 	}{
 		{name: "gcm normal", plaintextSize: 1000, algorithm: "aes-gcm", want: 1028},
 		{name: "gcm zero plaintext", plaintextSize: 0, algorithm: "aes-gcm", want: 28},
+		{name: "gcm buffered limit", plaintextSize: AEADBufferedLimit, algorithm: "aes-gcm", want: AEADBufferedLimit + 28},
+		{name: "gcm segmented", plaintextSize: AEADBufferedLimit + 1, algorithm: "aes-gcm", want: 23 + AEADBufferedLimit + 1 + 65*16},
 		{name: "ctr normal", plaintextSize: 1000, algorithm: "aes-ctr", want: 1000},
 		{name: "ctr zero plaintext", plaintextSize: 0, algorithm: "aes-ctr", want: 0},
+		{name: "aes-gcm-siv normal", plaintextSize: 1000, algorithm: "aes-gcm-siv", want: 1028},
+		{name: "chacha20-poly1305 normal", plaintextSize: 1000, algorithm: "chacha20-poly1305", want: 1028},
+		{name: "chacha20-poly1305 segmented", plaintextSize: AEADBufferedLimit + 1, algorithm: "chacha20-poly1305", want: 23 + AEADBufferedLimit + 1 + 65*16},
+		{name: "aes-gcm-siv is never segmented", plaintextSize: AEADBufferedLimit + 1, algorithm: "aes-gcm-siv", want: AEADBufferedLimit + 29},
+		{name: "xchacha20 normal", plaintextSize: 1000, algorithm: "xchacha20", want: 1000},
 		{name: "none normal", plaintextSize: 1000, algorithm: "none", want: 1000},
 		{name: "none zero plaintext", plaintextSize: 0, algorithm: "none", want: 0},
 		{name: "unknown algorithm", plaintextSize: 1000, algorithm: "chacha20", want: -1},
@@ -28,3 +35,39 @@ func TestComputeCiphertextSize(t *testing.T) {
 		})
 	}
 }
+
+func TestComputePlaintextSize(t *testing.T) {
+	for _, algorithm := range []string{"aes-gcm", "chacha20-poly1305", "aes-ctr", "none"} {
+		for _, size := range []int64{0, 1000, AEADBufferedLimit, AEADBufferedLimit + 1, AEADBufferedLimit + AEADSegmentSize, 3*AEADBufferedLimit + 7} {
+			segmentSize := int64(0)
+			if (algorithm == "aes-gcm" || algorithm == "chacha20-poly1305") && size > AEADBufferedLimit {
+				segmentSize = AEADSegmentSize
+			}
+			ciphertextSize := ComputeCiphertextSize(size, algorithm)
+			if got := ComputePlaintextSize(ciphertextSize, algorithm, segmentSize); got != size {
+				t.Errorf("ComputePlaintextSize(%d, %q, %d) = %d, want %d", ciphertextSize, algorithm, segmentSize, got, size)
+			}
+		}
+	}
+
+	tests := []struct {
+		name           string
+		ciphertextSize int64
+		algorithm      string
+		segmentSize    int64
+		want           int64
+	}{
+		{name: "gcm-siv", ciphertextSize: 1028, algorithm: "aes-gcm-siv", want: 1000},
+		{name: "gcm shorter than overhead", ciphertextSize: 27, algorithm: "aes-gcm", want: -1},
+		{name: "segmented header only", ciphertextSize: AEADSegmentHeaderSize + AEADTagSize, algorithm: "aes-gcm", segmentSize: AEADSegmentSize, want: -1},
+		{name: "segmented one byte", ciphertextSize: AEADSegmentHeaderSize + AEADTagSize + 1, algorithm: "aes-gcm", segmentSize: AEADSegmentSize, want: 1},
+		{name: "unknown algorithm", ciphertextSize: 1000, algorithm: "chacha20", want: -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ComputePlaintextSize(tc.ciphertextSize, tc.algorithm, tc.segmentSize); got != tc.want {
+				t.Errorf("ComputePlaintextSize(%d, %q, %d) = %d, want %d", tc.ciphertextSize, tc.algorithm, tc.segmentSize, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// Objects up to aeadBufferedLimit bytes are sealed in one call to the AEAD:
+// nonce || ciphertext || tag. Larger ones are split into segments of
+// segmentSize bytes of plaintext, each sealed on its own, so memory stays
+// bounded and no plaintext is released before its tag is verified:
+//
+//	segmentMagic || nonce prefix || segment 0 || segment 1 || ... || segment n
+//
+// The nonce of segment i is the prefix, i as a 32-bit big-endian integer and
+// a byte that is 1 for the last segment and 0 otherwise, so reordered,
+// dropped and appended segments fail to open. Every segment but the last is
+// full.
+const (
+	aeadBufferedLimit = encryption.AEADBufferedLimit
+	segmentSize       = encryption.AEADSegmentSize
+	segmentPrefixSize = encryption.AEADSegmentHeaderSize - len(segmentMagic)
+	segmentMaxCount   = 1 << 32
+)
+
+// segmentMagic starts every segmented object. Objects sealed in one piece
+// start with a random nonce instead.
+const segmentMagic = "s3ep-segments-v1"
+
+// errAEADAuthentication is returned when a segment does not match its tag
+var errAEADAuthentication = errors.New("cipher: message authentication failed")
+
+// sealAEADObject returns src sealed with aead in one piece if it fits
+// aeadBufferedLimit, else in segments. iv is the nonce, or the nonce prefix
+// of a segmented object, which DecryptStream accepts in place of the start
+// of the data; size is the segment size, 0 for objects sealed in one piece.
+func sealAEADObject(src *bufio.Reader, aead cipher.AEAD, associatedData []byte) (sealed *bufio.Reader, iv []byte, size int, err error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	head, whole, err := readUpTo(src, aeadBufferedLimit)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read data for encryption: %w", err)
+	}
+	if whole {
+		return bufio.NewReader(bytes.NewReader(aead.Seal(nonce, nonce, head, associatedData))), nonce, 0, nil
+	}
+
+	prefix := nonce[:segmentPrefixSize]
+	sealReader := newSegmentSealReader(aead, prefix, io.MultiReader(bytes.NewReader(head), src), associatedData)
+	return bufio.NewReaderSize(sealReader, segmentSize), prefix, segmentSize, nil
+}
+
+// openAEADObject returns the plaintext of an object sealed by sealAEADObject.
+// iv is the nonce or nonce prefix sealAEADObject returned, in which case the
+// data starts after it; without it the format is told by the start of the
+// data. Objects sealed in one piece are authenticated before any plaintext
+// is returned, whatever their size, as are the segments of segmented ones.
+func openAEADObject(src *bufio.Reader, aead cipher.AEAD, iv, associatedData []byte) (*bufio.Reader, error) {
+	switch {
+	case len(iv) == segmentPrefixSize:
+		return bufio.NewReaderSize(newSegmentOpenReader(aead, iv, src, associatedData), segmentSize), nil
+	case iv == nil:
+		if magic, _ := src.Peek(len(segmentMagic)); string(magic) == segmentMagic {
+			return bufio.NewReaderSize(newSegmentOpenReader(aead, nil, src, associatedData), segmentSize), nil
+		}
+	case len(iv) != aead.NonceSize():
+		return nil, fmt.Errorf("invalid nonce size: expected %d or %d bytes, got %d", aead.NonceSize(), segmentPrefixSize, len(iv))
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted data for decryption: %w", err)
+	}
+	nonce, ciphertext := iv, data
+	if iv == nil {
+		// Extract nonce from the beginning of encrypted data
+		if len(data) < aead.NonceSize() {
+			return nil, fmt.Errorf("encrypted data too short: expected at least %d bytes, got %d", aead.NonceSize(), len(data))
+		}
+		nonce, ciphertext = data[:aead.NonceSize()], data[aead.NonceSize():]
+	}
+
+	plaintext, err := aead.Open(ciphertext[:0], nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return bufio.NewReader(bytes.NewReader(plaintext)), nil
+}
+
+// segmentNonce returns the nonce of segment index
+func segmentNonce(prefix []byte, index uint64, last bool) []byte {
+	nonce := make([]byte, len(prefix)+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], uint32(index))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// readSegment reads up to len(buf) bytes of src. last reports that src ends
+// with them.
+func readSegment(src *bufio.Reader, buf []byte) (n int, last bool, err error) {
+	n, err = io.ReadFull(src, buf)
+	switch {
+	case err == nil:
+		if _, err = src.Peek(1); errors.Is(err, io.EOF) {
+			return n, true, nil
+		}
+		return n, false, err
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return n, true, nil
+	default:
+		return n, false, err
+	}
+}
+
+// segmentSealReader encrypts src segment by segment, after the header
+type segmentSealReader struct {
+	aead           cipher.AEAD
+	prefix         []byte
+	associatedData []byte
+	src            *bufio.Reader
+	index          uint64
+	buf            []byte
+	out            []byte
+	sealed         bool
+}
+
+func newSegmentSealReader(aead cipher.AEAD, prefix []byte, src io.Reader, associatedData []byte) *segmentSealReader {
+	return &segmentSealReader{
+		aead:           aead,
+		prefix:         prefix,
+		associatedData: associatedData,
+		src:            bufio.NewReaderSize(src, segmentSize),
+		buf:            make([]byte, segmentSize+aead.Overhead()),
+		out:            append([]byte(segmentMagic), prefix...),
+	}
+}
+
+func (r *segmentSealReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.sealed {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *segmentSealReader) sealNext() error {
+	if r.index == segmentMaxCount {
+		return fmt.Errorf("object exceeds %d segments of %d bytes", uint64(segmentMaxCount), segmentSize)
+	}
+	n, last, err := readSegment(r.src, r.buf[:segmentSize])
+	if err != nil {
+		return fmt.Errorf("failed to read data for encryption: %w", err)
+	}
+
+	r.out = r.aead.Seal(r.buf[:0], segmentNonce(r.prefix, r.index, last), r.buf[:n], r.associatedData)
+	r.index++
+	r.sealed = last
+	return nil
+}
+
+// segmentOpenReader decrypts src segment by segment. A segment's plaintext
+// is only returned once its tag is verified, so a tampered segment releases
+// none of it.
+type segmentOpenReader struct {
+	aead           cipher.AEAD
+	prefix         []byte // read from the header if nil
+	associatedData []byte
+	src            *bufio.Reader
+	index          uint64
+	buf            []byte
+	out            []byte
+	err            error
+}
+
+func newSegmentOpenReader(aead cipher.AEAD, prefix []byte, src *bufio.Reader, associatedData []byte) *segmentOpenReader {
+	return &segmentOpenReader{
+		aead:           aead,
+		prefix:         prefix,
+		associatedData: associatedData,
+		src:            src,
+		buf:            make([]byte, segmentSize+aead.Overhead()),
+	}
+}
+
+func (r *segmentOpenReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.openNext()
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// openNext verifies and decrypts the next segment, returning io.EOF after the
+// last one
+func (r *segmentOpenReader) openNext() error {
+	if r.prefix == nil {
+		header := make([]byte, len(segmentMagic)+segmentPrefixSize)
+		if _, err := io.ReadFull(r.src, header); err != nil {
+			return fmt.Errorf("encrypted data too short: %w", err)
+		}
+		r.prefix = header[len(segmentMagic):]
+	}
+	if r.index == segmentMaxCount {
+		return fmt.Errorf("failed to decrypt data: more than %d segments", uint64(segmentMaxCount))
+	}
+
+	n, last, err := readSegment(r.src, r.buf)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted data for decryption: %w", err)
+	}
+	plaintext, err := r.aead.Open(r.buf[:0], segmentNonce(r.prefix, r.index, last), r.buf[:n], r.associatedData)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt segment %d: %w", r.index, errAEADAuthentication)
+	}
+
+	r.index++
+	r.out = plaintext
+	if last {
+		return io.EOF
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+func newTestGCM(t *testing.T) (cipher.AEAD, []byte) {
+	t.Helper()
+	dek := make([]byte, 32)
+	_, err := rand.Read(dek)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(dek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return gcm, dek
+}
+
+// sealSegments seals plaintext in segments, header included
+func sealSegments(t *testing.T, aead cipher.AEAD, plaintext, aad []byte) []byte {
+	t.Helper()
+	prefix := randomTestBytes(t, segmentPrefixSize)
+	sealed, err := io.ReadAll(smallReads(newSegmentSealReader(aead, prefix, bytes.NewReader(plaintext), aad), len(plaintext)))
+	require.NoError(t, err)
+	return sealed
+}
+
+// openSegments returns the plaintext released before the first error
+func openSegments(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	return io.ReadAll(newSegmentOpenReader(aead, nil, bufio.NewReader(bytes.NewReader(sealed)), aad))
+}
+
+func TestSegments_RoundTrip(t *testing.T) {
+	gcm, _ := newTestGCM(t)
+
+	for _, size := range []int{0, 1, 17, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 5} {
+		for _, aad := range [][]byte{nil, []byte("bucket/key")} {
+			plaintext := randomTestBytes(t, size)
+			sealed := sealSegments(t, gcm, plaintext, aad)
+
+			segments := max(1, (size+segmentSize-1)/segmentSize)
+			assert.Len(t, sealed, encryption.AEADSegmentHeaderSize+size+segments*gcm.Overhead(), "size=%d", size)
+			assert.Equal(t, segmentMagic, string(sealed[:len(segmentMagic)]))
+
+			opened, err := openSegments(gcm, sealed, aad)
+			require.NoError(t, err, "size=%d aad=%d", size, len(aad))
+			assert.True(t, bytes.Equal(plaintext, opened), "size=%d aad=%d", size, len(aad))
+
+			_, err = openSegments(gcm, sealed, []byte("other/key"))
+			assert.ErrorIs(t, err, errAEADAuthentication)
+		}
+	}
+}
+
+// smallReads reads small messages one byte at a time to exercise
+// partial reads, larger ones as they come
+func smallReads(r io.Reader, size int) io.Reader {
+	if size > 64 {
+		return r
+	}
+	return bufio.NewReaderSize(&oneByteReader{r: r}, 16)
+}
+
+type oneByteReader struct{ r io.Reader }
+
+func (o *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return o.r.Read(p)
+}
+
+func TestSegments_TamperedSegmentReleasesNothing(t *testing.T) {
+	gcm, _ := newTestGCM(t)
+	plaintext := randomTestBytes(t, 3*segmentSize+100)
+	sealed := sealSegments(t, gcm, plaintext, nil)
+	sealedSegment := segmentSize + gcm.Overhead()
+
+	// Flip a byte in the second of four segments
+	tampered := bytes.Clone(sealed)
+	tampered[encryption.AEADSegmentHeaderSize+sealedSegment+10] ^= 1
+
+	released, err := openSegments(gcm, tampered, nil)
+	assert.ErrorIs(t, err, errAEADAuthentication)
+	assert.Len(t, released, segmentSize, "only the first segment may be released")
+	assert.Equal(t, plaintext[:segmentSize], released)
+}
+
+func TestSegments_RejectsReorderedAndTruncatedSegments(t *testing.T) {
+	gcm, _ := newTestGCM(t)
+	plaintext := randomTestBytes(t, 3*segmentSize)
+	sealed := sealSegments(t, gcm, plaintext, nil)
+	header := encryption.AEADSegmentHeaderSize
+	sealedSegment := segmentSize + gcm.Overhead()
+
+	reordered := bytes.Clone(sealed)
+	copy(reordered[header:], sealed[header+sealedSegment:header+2*sealedSegment])
+	copy(reordered[header+sealedSegment:], sealed[header:header+sealedSegment])
+
+	for name, data := range map[string][]byte{
+		"reordered":            reordered,
+		"truncated at segment": sealed[:len(sealed)-sealedSegment],
+		"truncated in segment": sealed[:len(sealed)-5],
+		"appended":             append(bytes.Clone(sealed), 0),
+		"header only":          sealed[:header],
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := openSegments(gcm, data, nil)
+			assert.ErrorIs(t, err, errAEADAuthentication)
+		})
+	}
+
+	_, err := openSegments(gcm, sealed[:header-1], nil)
+	assert.Error(t, err, "truncated header")
+}
+
+func TestAESGCMDataEncryptor_LargeObjectsAreSegmented(t *testing.T) {
+	encryptor := NewAESGCMDataEncryptor().(*AESGCMDataEncryptor)
+	_, dek := newTestGCM(t)
+	ctx := context.Background()
+	aad := []byte("bucket/large.bin")
+
+	plaintext := randomTestBytes(t, aeadBufferedLimit+segmentSize+7)
+	encrypted, err := encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(plaintext)), dek, aad)
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(encrypted)
+	require.NoError(t, err)
+	require.Len(t, ciphertext, int(encryption.ComputeCiphertextSize(int64(len(plaintext)), "aes-gcm")))
+	assert.Equal(t, segmentSize, encryptor.GetLastSegmentSize())
+	prefix := encryptor.GetLastIV()
+	assert.Equal(t, ciphertext[len(segmentMagic):encryption.AEADSegmentHeaderSize], prefix)
+
+	decrypted, err := encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, aad)
+	require.NoError(t, err)
+	roundTrip, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(plaintext, roundTrip))
+
+	// The nonce prefix may also come from metadata instead of the data
+	decrypted, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext[encryption.AEADSegmentHeaderSize:])), dek, prefix, aad)
+	require.NoError(t, err)
+	roundTrip, err = io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(plaintext, roundTrip))
+
+	// Objects within the limit are sealed in one piece
+	_, err = encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(plaintext[:aeadBufferedLimit])), dek, aad)
+	require.NoError(t, err)
+	assert.Zero(t, encryptor.GetLastSegmentSize())
+	assert.Len(t, encryptor.GetLastIV(), 12)
+}
+
+func TestAESGCMDataEncryptor_OpensLargeSingleTagObjects(t *testing.T) {
+	// Large objects written before segments carry one tag at the end
+	encryptor := NewAESGCMDataEncryptor()
+	gcm, dek := newTestGCM(t)
+	ctx := context.Background()
+	aad := []byte("bucket/large.bin")
+
+	plaintext := randomTestBytes(t, aeadBufferedLimit+7)
+	nonce := randomTestBytes(t, gcm.NonceSize())
+	sealed := gcm.Seal(bytes.Clone(nonce), nonce, plaintext, aad)
+
+	decrypted, err := encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(sealed)), dek, nil, aad)
+	require.NoError(t, err)
+	roundTrip, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(plaintext, roundTrip))
+
+	sealed[len(sealed)/2] ^= 1
+	_, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(sealed)), dek, nil, aad)
+	assert.Error(t, err, "tampered objects must fail before any plaintext is returned")
+}
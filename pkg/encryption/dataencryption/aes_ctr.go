@@ -142,7 +142,9 @@ func (r *ctrStreamReader) Read(p []byte) (n int, err error) {
 type AESCTRStatefulEncryptor struct {
 	dek    []byte
 	iv     []byte
+	block  cipher.Block
 	stream cipher.Stream
+	offset uint64 // bytes processed so far, i.e. the position of stream in the keystream
 }
 
 // NewAESCTRStatefulEncryptor creates a new stateful AES-CTR encryptor
@@ -169,6 +171,7 @@ func NewAESCTRStatefulEncryptor(dek []byte) (*AESCTRStatefulEncryptor, error) {
 	return &AESCTRStatefulEncryptor{
 		dek:    append([]byte(nil), dek...), // Copy DEK
 		iv:     append([]byte(nil), iv...),  // Copy IV
+		block:  block,
 		stream: stream,
 	}, nil
 }
@@ -194,15 +197,32 @@ func NewAESCTRStatefulEncryptorWithIV(dek, iv []byte) (*AESCTRStatefulEncryptor,
 	return &AESCTRStatefulEncryptor{
 		dek:    append([]byte(nil), dek...), // Copy DEK
 		iv:     append([]byte(nil), iv...),  // Copy IV
+		block:  block,
 		stream: stream,
 	}, nil
 }
 
+// NewAESCTRStatefulEncryptorAt creates a stateful encryptor with existing IV
+// positioned offset bytes into the keystream, to continue a stream that was
+// started elsewhere
+func NewAESCTRStatefulEncryptorAt(dek, iv []byte, offset uint64) (*AESCTRStatefulEncryptor, error) {
+	e, err := NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		e.offset = offset
+		e.stream = e.streamAt(offset)
+	}
+	return e, nil
+}
+
 // EncryptPart encrypts data in-place using the maintained cipher stream and
 // returns the same slice. The caller's buffer is mutated. Not safe for
 // concurrent use — see the type comment.
 func (e *AESCTRStatefulEncryptor) EncryptPart(data []byte) ([]byte, error) {
 	e.stream.XORKeyStream(data, data)
+	e.offset += uint64(len(data))
 	return data, nil
 }
 
@@ -211,6 +231,7 @@ func (e *AESCTRStatefulEncryptor) EncryptPart(data []byte) ([]byte, error) {
 // safe for concurrent use — see the type comment.
 func (e *AESCTRStatefulEncryptor) DecryptPart(data []byte) ([]byte, error) {
 	e.stream.XORKeyStream(data, data)
+	e.offset += uint64(len(data))
 	return data, nil
 }
 
@@ -219,6 +240,11 @@ func (e *AESCTRStatefulEncryptor) GetIV() []byte {
 	return append([]byte(nil), e.iv...) // Return a copy
 }
 
+// Offset returns the number of bytes processed so far
+func (e *AESCTRStatefulEncryptor) Offset() uint64 {
+	return e.offset
+}
+
 // Algorithm returns the algorithm identifier
 func (e *AESCTRStatefulEncryptor) Algorithm() string {
 	return "aes-ctr"
@@ -245,4 +271,5 @@ func (e *AESCTRStatefulEncryptor) Cleanup() {
 
 	// Note: cipher.Stream doesn't have a cleanup method, but clearing the key material is sufficient
 	e.stream = nil
+	e.block = nil
 }
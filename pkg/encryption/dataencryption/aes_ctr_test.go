@@ -75,3 +75,62 @@ func TestAESCTRProvider_Algorithm(t *testing.T) {
 	provider := NewAESCTRDataEncryptor()
 	assert.Equal(t, "aes-ctr", provider.Algorithm())
 }
+
+func TestAESCTRStream_MatchesKeystream(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	// not a multiple of the block size, from a source returning short segments
+	plaintext := randomTestBytes(t, 2*64*1024+1000+7)
+
+	consumers := map[string]func(io.Reader) ([]byte, error){
+		"io.Copy": func(r io.Reader) ([]byte, error) {
+			var out bytes.Buffer
+			_, err := io.Copy(&out, r)
+			return out.Bytes(), err
+		},
+		"small reads": func(r io.Reader) ([]byte, error) {
+			var out bytes.Buffer
+			_, err := io.CopyBuffer(&out, struct{ io.Reader }{r}, make([]byte, 333))
+			return out.Bytes(), err
+		},
+	}
+
+	for name, consume := range consumers {
+		t.Run(name, func(t *testing.T) {
+			provider := NewAESCTRDataEncryptor()
+			source := bufio.NewReader(&segmentReader{data: plaintext, segment: 1460})
+			encrypted, err := provider.EncryptStream(context.Background(), source, dek, nil)
+			require.NoError(t, err)
+			ciphertext, err := consume(encrypted)
+			require.NoError(t, err)
+
+			reference, err := NewAESCTRStatefulEncryptorWithIV(dek, provider.(*AESCTRDataEncryptor).GetLastIV())
+			require.NoError(t, err)
+			expected, err := reference.EncryptPart(bytes.Clone(plaintext))
+			require.NoError(t, err)
+			assert.Equal(t, expected, ciphertext)
+		})
+	}
+}
+
+func TestAESCTRStatefulEncryptorAt_ContinuesStream(t *testing.T) {
+	dek := bytes.Repeat([]byte{1}, 32)
+	whole, err := NewAESCTRStatefulEncryptor(dek)
+	require.NoError(t, err)
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	expected, err := whole.EncryptPart(append([]byte{}, data...))
+	require.NoError(t, err)
+
+	// Resume at an offset that is not block aligned
+	first, err := NewAESCTRStatefulEncryptorWithIV(dek, whole.GetIV())
+	require.NoError(t, err)
+	head, err := first.EncryptPart(append([]byte{}, data[:333]...))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(333), first.Offset())
+
+	resumed, err := NewAESCTRStatefulEncryptorAt(dek, whole.GetIV(), first.Offset())
+	require.NoError(t, err)
+	tail, err := resumed.EncryptPart(append([]byte{}, data[333:]...))
+	require.NoError(t, err)
+	assert.Equal(t, expected, append(head, tail...))
+	assert.Equal(t, uint64(len(data)), resumed.Offset())
+}
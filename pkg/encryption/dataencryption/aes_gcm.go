@@ -2,7 +2,6 @@ package dataencryption
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -16,10 +15,11 @@ import (
 
 // AESGCMDataEncryptor implements streaming aes-gcm encryption/decryption
 // This implements the unified DataEncryptor interface for both small and large data through streaming
-// It also implements IVProvider for metadata
+// It also implements IVProvider and SegmentProvider for metadata
 type AESGCMDataEncryptor struct {
-	lastNonce []byte // Store the last used nonce for metadata (GCM uses nonce, not IV)
-	mutex     sync.Mutex
+	lastNonce       []byte // Store the last used nonce for metadata (GCM uses nonce, not IV)
+	lastSegmentSize int
+	mutex           sync.Mutex
 }
 
 // NewAESGCMDataEncryptor creates a new streaming AES-GCM data encryptor
@@ -29,97 +29,64 @@ func NewAESGCMDataEncryptor() encryption.DataEncryptor {
 	}
 }
 
-// EncryptStream encrypts data from a reader using aes-gcm
-// Note: AES-GCM requires all data to calculate the authentication tag,
-// so we buffer the data internally for authentication
+// EncryptStream encrypts data from a reader using aes-gcm. Objects up to
+// aeadBufferedLimit are sealed in one piece; larger ones are encrypted as
+// they are read, in segments with a tag each.
 func (e *AESGCMDataEncryptor) EncryptStream(_ context.Context, reader *bufio.Reader, dek []byte, associatedData []byte) (*bufio.Reader, error) {
-	if len(dek) != 32 {
-		return nil, fmt.Errorf("invalid DEK size: expected 32 bytes, got %d", len(dek))
-	}
-
-	// Create AES cipher with DEK
-	block, err := aes.NewCipher(dek)
+	gcm, err := newGCM(dek)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		return nil, err
 	}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+	sealed, nonce, segmentSize, err := sealAEADObject(reader, gcm, associatedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
-	}
-
-	// Generate random nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		return nil, fmt.Errorf("GCM encryption failed: %w", err)
 	}
 
 	e.mutex.Lock()
 	e.lastNonce = nonce
+	e.lastSegmentSize = segmentSize
 	e.mutex.Unlock()
+	return sealed, nil
+}
 
-	data, err := io.ReadAll(reader)
+// DecryptStream decrypts data from an encrypted reader using aes-gcm.
+// iv contains the nonce, or the nonce prefix of a segmented object; without
+// it the nonce is read from the start of the data. Segments are released
+// one by one once their tag is verified.
+func (e *AESGCMDataEncryptor) DecryptStream(_ context.Context, encryptedReader *bufio.Reader, dek []byte, iv []byte, associatedData []byte) (*bufio.Reader, error) {
+	gcm, err := newGCM(dek)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read data for GCM encryption: %w", err)
+		return nil, err
 	}
-
-	result := gcm.Seal(nonce, nonce, data, associatedData)
-
-	return bufio.NewReader(bytes.NewReader(result)), nil
+	return openAEADObject(encryptedReader, gcm, iv, associatedData)
 }
 
-// DecryptStream decrypts data from an encrypted reader using aes-gcm
-// iv parameter contains the nonce for GCM decryption
-func (e *AESGCMDataEncryptor) DecryptStream(_ context.Context, encryptedReader *bufio.Reader, dek []byte, iv []byte, associatedData []byte) (*bufio.Reader, error) {
+// newGCM creates the AES-GCM AEAD for a 256-bit DEK
+func newGCM(dek []byte) (cipher.AEAD, error) {
 	if len(dek) != 32 {
 		return nil, fmt.Errorf("invalid DEK size: expected 32 bytes, got %d", len(dek))
 	}
 
-	// Create AES cipher with DEK
 	block, err := aes.NewCipher(dek)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
 	}
 
-	// Create GCM mode
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
+	return gcm, nil
+}
 
-	// Read all encrypted data (GCM needs all data for authentication verification)
-	encryptedData, err := io.ReadAll(encryptedReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read encrypted data for GCM decryption: %w", err)
-	}
-
-	var nonce []byte
-	var ciphertext []byte
-
-	// If IV is provided (from metadata), use it as nonce
-	if iv != nil {
-		if len(iv) != gcm.NonceSize() {
-			return nil, fmt.Errorf("invalid nonce size: expected %d bytes, got %d", gcm.NonceSize(), len(iv))
-		}
-		nonce = iv
-		ciphertext = encryptedData
-	} else {
-		// Extract nonce from the beginning of encrypted data (legacy format)
-		nonceSize := gcm.NonceSize()
-		if len(encryptedData) < nonceSize {
-			return nil, fmt.Errorf("encrypted data too short: expected at least %d bytes, got %d", nonceSize, len(encryptedData))
-		}
-		nonce = encryptedData[:nonceSize]
-		ciphertext = encryptedData[nonceSize:]
-	}
-
-	plaintext, err := gcm.Open(ciphertext[:0], nonce, ciphertext, associatedData)
+// readUpTo reads at most limit bytes. whole reports that r ended within them.
+func readUpTo(r io.Reader, limit int) ([]byte, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+		return nil, false, err
 	}
-
-	return bufio.NewReader(bytes.NewReader(plaintext)), nil
+	return data, len(data) <= limit, nil
 }
 
 // GenerateDEK generates a new 256-bit AES key
@@ -136,7 +103,8 @@ func (e *AESGCMDataEncryptor) Algorithm() string {
 	return "aes-gcm"
 }
 
-// GetLastIV returns the nonce used in the last encryption operation
+// GetLastIV returns the nonce used in the last encryption operation, or the
+// nonce prefix if it was segmented
 // This implements the IVProvider interface for metadata storage.
 // Callers must not mutate the returned slice.
 func (e *AESGCMDataEncryptor) GetLastIV() []byte {
@@ -144,3 +112,11 @@ func (e *AESGCMDataEncryptor) GetLastIV() []byte {
 	defer e.mutex.Unlock()
 	return e.lastNonce
 }
+
+// GetLastSegmentSize returns the segment size of the last encryption
+// operation, 0 if it was sealed in one piece
+func (e *AESGCMDataEncryptor) GetLastSegmentSize() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lastSegmentSize
+}
@@ -0,0 +1,127 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/tink/go/aead/subtle"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// GCMSIVMaxSize is the largest object aes-gcm-siv encrypts. The tag is
+// computed over the whole plaintext before the first byte is encrypted, so
+// objects are held in memory and cannot be streamed like aes-gcm.
+const GCMSIVMaxSize = 64 << 20
+
+// AESGCMSIVDataEncryptor implements aes-gcm-siv (RFC 8452) encryption for
+// whole objects. A repeated nonce only reveals whether two objects are
+// identical, instead of breaking confidentiality and authenticity as with
+// aes-gcm. It uses the aes-gcm layout: nonce || ciphertext || tag.
+// It also implements IVProvider for metadata.
+type AESGCMSIVDataEncryptor struct {
+	lastNonce []byte
+	mutex     sync.Mutex
+}
+
+// NewAESGCMSIVDataEncryptor creates a new AES-GCM-SIV data encryptor
+func NewAESGCMSIVDataEncryptor() encryption.DataEncryptor {
+	return &AESGCMSIVDataEncryptor{}
+}
+
+// EncryptStream encrypts data from a reader using aes-gcm-siv. Objects larger
+// than GCMSIVMaxSize are rejected.
+func (e *AESGCMSIVDataEncryptor) EncryptStream(_ context.Context, reader *bufio.Reader, dek []byte, associatedData []byte) (*bufio.Reader, error) {
+	siv, err := newGCMSIV(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, whole, err := readUpTo(reader, GCMSIVMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data for GCM-SIV encryption: %w", err)
+	}
+	if !whole {
+		return nil, fmt.Errorf("object too large for aes-gcm-siv: limit is %d bytes", GCMSIVMaxSize)
+	}
+
+	// Encrypt draws a random nonce and prepends it
+	ciphertext, err := siv.Encrypt(plaintext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+
+	e.mutex.Lock()
+	e.lastNonce = ciphertext[:subtle.AESGCMSIVNonceSize:subtle.AESGCMSIVNonceSize]
+	e.mutex.Unlock()
+
+	return bufio.NewReader(bytes.NewReader(ciphertext)), nil
+}
+
+// DecryptStream decrypts data from an encrypted reader using aes-gcm-siv.
+// iv contains the nonce; without it the nonce is read from the start of the
+// data.
+func (e *AESGCMSIVDataEncryptor) DecryptStream(_ context.Context, encryptedReader *bufio.Reader, dek []byte, iv []byte, associatedData []byte) (*bufio.Reader, error) {
+	siv, err := newGCMSIV(dek)
+	if err != nil {
+		return nil, err
+	}
+	if iv != nil && len(iv) != subtle.AESGCMSIVNonceSize {
+		return nil, fmt.Errorf("invalid nonce size: expected %d bytes, got %d", subtle.AESGCMSIVNonceSize, len(iv))
+	}
+
+	ciphertext, whole, err := readUpTo(encryptedReader, GCMSIVMaxSize+int(encryption.GCMOverhead))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted data for GCM-SIV decryption: %w", err)
+	}
+	if !whole {
+		return nil, fmt.Errorf("object too large for aes-gcm-siv: limit is %d bytes", GCMSIVMaxSize)
+	}
+	if iv != nil {
+		ciphertext = append(bytes.Clone(iv), ciphertext...)
+	}
+
+	plaintext, err := siv.Decrypt(ciphertext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return bufio.NewReader(bytes.NewReader(plaintext)), nil
+}
+
+func newGCMSIV(dek []byte) (*subtle.AESGCMSIV, error) {
+	if len(dek) != 32 {
+		return nil, fmt.Errorf("invalid DEK size: expected 32 bytes, got %d", len(dek))
+	}
+	siv, err := subtle.NewAESGCMSIV(dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES-GCM-SIV: %w", err)
+	}
+	return siv, nil
+}
+
+// GenerateDEK generates a new 256-bit AES key
+func (e *AESGCMSIVDataEncryptor) GenerateDEK(_ context.Context) ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("failed to generate DEK: %w", err)
+	}
+	return dek, nil
+}
+
+// Algorithm returns the algorithm identifier
+func (e *AESGCMSIVDataEncryptor) Algorithm() string {
+	return string(encryption.EncryptionTypeAESGCMSIV)
+}
+
+// GetLastIV returns the nonce used in the last encryption operation.
+// Callers must not mutate the returned slice.
+func (e *AESGCMSIVDataEncryptor) GetLastIV() []byte {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lastNonce
+}
@@ -0,0 +1,79 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESGCMSIVDataEncryptor_RoundTrip(t *testing.T) {
+	encryptor := NewAESGCMSIVDataEncryptor()
+	assert.Equal(t, "aes-gcm-siv", encryptor.Algorithm())
+	ctx := context.Background()
+	dek, err := encryptor.GenerateDEK(ctx)
+	require.NoError(t, err)
+	aad := []byte("bucket/object")
+
+	for _, size := range []int{0, 100, aeadBufferedLimit + 7} {
+		plaintext := randomTestBytes(t, size)
+		encrypted, err := encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(plaintext)), dek, aad)
+		require.NoError(t, err)
+		ciphertext, err := io.ReadAll(encrypted)
+		require.NoError(t, err)
+		require.Len(t, ciphertext, size+28)
+		nonce := encryptor.(*AESGCMSIVDataEncryptor).GetLastIV()
+		assert.Equal(t, nonce, ciphertext[:12])
+
+		// The nonce comes from metadata or from the start of the data
+		decrypted, err := encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext[12:])), dek, nonce, aad)
+		require.NoError(t, err)
+		opened, err := io.ReadAll(decrypted)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, opened), "size %d", size)
+
+		decrypted, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, aad)
+		require.NoError(t, err)
+		opened, err = io.ReadAll(decrypted)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, opened), "size %d", size)
+	}
+}
+
+func TestAESGCMSIVDataEncryptor_Errors(t *testing.T) {
+	encryptor := NewAESGCMSIVDataEncryptor()
+	ctx := context.Background()
+	dek := randomTestBytes(t, 32)
+
+	encrypted, err := encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader([]byte("payload"))), dek, []byte("bucket/a"))
+	require.NoError(t, err)
+	ciphertext, err := io.ReadAll(encrypted)
+	require.NoError(t, err)
+
+	// The AAD binds the object key
+	_, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, []byte("bucket/b"))
+	assert.ErrorContains(t, err, "failed to decrypt data")
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, []byte("bucket/a"))
+	assert.ErrorContains(t, err, "failed to decrypt data")
+
+	_, err = encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(nil)), dek[:16], nil)
+	assert.ErrorContains(t, err, "invalid DEK size")
+
+	// Objects are sealed in one piece, so their size is limited
+	tooLarge := io.LimitReader(zeroReader{}, GCMSIVMaxSize+1)
+	_, err = encryptor.EncryptStream(ctx, bufio.NewReader(tooLarge), dek, nil)
+	assert.ErrorContains(t, err, "too large for aes-gcm-siv")
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
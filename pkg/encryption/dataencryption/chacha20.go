@@ -0,0 +1,105 @@
+package dataencryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// ChaCha20Poly1305DataEncryptor implements chacha20-poly1305 encryption for
+// whole objects. It is the alternative to aes-gcm for hosts without AES
+// hardware support and uses the same layouts: nonce || ciphertext || tag up
+// to aeadBufferedLimit, segments beyond.
+// It also implements IVProvider and SegmentProvider for metadata.
+type ChaCha20Poly1305DataEncryptor struct {
+	lastNonce       []byte
+	lastSegmentSize int
+	mutex           sync.Mutex
+}
+
+// NewChaCha20Poly1305DataEncryptor creates a new streaming ChaCha20-Poly1305 data encryptor
+func NewChaCha20Poly1305DataEncryptor() encryption.DataEncryptor {
+	return &ChaCha20Poly1305DataEncryptor{}
+}
+
+// EncryptStream encrypts data from a reader using chacha20-poly1305. Like
+// aes-gcm, objects up to aeadBufferedLimit are sealed in one piece and larger
+// ones are encrypted as they are read, in segments with a tag each.
+func (e *ChaCha20Poly1305DataEncryptor) EncryptStream(_ context.Context, reader *bufio.Reader, dek []byte, associatedData []byte) (*bufio.Reader, error) {
+	aead, err := newChaCha20Poly1305(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, nonce, segmentSize, err := sealAEADObject(reader, aead, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("ChaCha20-Poly1305 encryption failed: %w", err)
+	}
+
+	e.mutex.Lock()
+	e.lastNonce = nonce
+	e.lastSegmentSize = segmentSize
+	e.mutex.Unlock()
+	return sealed, nil
+}
+
+// DecryptStream decrypts data from an encrypted reader using
+// chacha20-poly1305. iv contains the nonce, or the nonce prefix of a
+// segmented object; without it the nonce is read from the start of the data.
+func (e *ChaCha20Poly1305DataEncryptor) DecryptStream(_ context.Context, encryptedReader *bufio.Reader, dek []byte, iv []byte, associatedData []byte) (*bufio.Reader, error) {
+	aead, err := newChaCha20Poly1305(dek)
+	if err != nil {
+		return nil, err
+	}
+	return openAEADObject(encryptedReader, aead, iv, associatedData)
+}
+
+func newChaCha20Poly1305(dek []byte) (cipher.AEAD, error) {
+	if len(dek) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("invalid DEK size: expected %d bytes, got %d", chacha20poly1305.KeySize, len(dek))
+	}
+	aead, err := chacha20poly1305.New(dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ChaCha20-Poly1305: %w", err)
+	}
+	return aead, nil
+}
+
+// GenerateDEK generates a new 256-bit key
+func (e *ChaCha20Poly1305DataEncryptor) GenerateDEK(_ context.Context) ([]byte, error) {
+	dek := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("failed to generate DEK: %w", err)
+	}
+	return dek, nil
+}
+
+// Algorithm returns the algorithm identifier
+func (e *ChaCha20Poly1305DataEncryptor) Algorithm() string {
+	return string(encryption.EncryptionTypeChaCha20Poly1305)
+}
+
+// GetLastIV returns the nonce used in the last encryption operation, or the
+// nonce prefix if it was segmented.
+// Callers must not mutate the returned slice.
+func (e *ChaCha20Poly1305DataEncryptor) GetLastIV() []byte {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lastNonce
+}
+
+// GetLastSegmentSize returns the segment size of the last encryption
+// operation, 0 if it was sealed in one piece
+func (e *ChaCha20Poly1305DataEncryptor) GetLastSegmentSize() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lastSegmentSize
+}
@@ -0,0 +1,80 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+func TestChaCha20Poly1305Segments_TamperedSegmentReleasesNothing(t *testing.T) {
+	aead, err := chacha20poly1305.New(randomTestBytes(t, chacha20poly1305.KeySize))
+	require.NoError(t, err)
+	plaintext := randomTestBytes(t, 3*segmentSize+5)
+	sealed := sealSegments(t, aead, plaintext, []byte("bucket/key"))
+
+	opened, err := openSegments(aead, sealed, []byte("bucket/key"))
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(plaintext, opened))
+
+	sealed[encryption.AEADSegmentHeaderSize+segmentSize+chacha20poly1305.Overhead+1] ^= 1
+	released, err := openSegments(aead, sealed, []byte("bucket/key"))
+	assert.ErrorIs(t, err, errAEADAuthentication)
+	assert.Equal(t, plaintext[:segmentSize], released, "only the first segment may be released")
+}
+
+func TestChaCha20Poly1305DataEncryptor_RoundTrip(t *testing.T) {
+	encryptor := NewChaCha20Poly1305DataEncryptor()
+	assert.Equal(t, "chacha20-poly1305", encryptor.Algorithm())
+	ctx := context.Background()
+	dek, err := encryptor.GenerateDEK(ctx)
+	require.NoError(t, err)
+	aad := []byte("bucket/object")
+
+	// Objects within aeadBufferedLimit are sealed in one piece, larger ones
+	// in segments
+	for _, size := range []int{0, 100, aeadBufferedLimit + segmentSize + 7} {
+		plaintext := randomTestBytes(t, size)
+		encrypted, err := encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(plaintext)), dek, aad)
+		require.NoError(t, err)
+		ciphertext, err := io.ReadAll(encrypted)
+		require.NoError(t, err)
+		require.Len(t, ciphertext, int(encryption.ComputeCiphertextSize(int64(size), "chacha20-poly1305")))
+		nonce := encryptor.(*ChaCha20Poly1305DataEncryptor).GetLastIV()
+		header := ciphertext[:chacha20poly1305.NonceSize]
+		if size > aeadBufferedLimit {
+			assert.Equal(t, segmentSize, encryptor.(*ChaCha20Poly1305DataEncryptor).GetLastSegmentSize())
+			header = ciphertext[:encryption.AEADSegmentHeaderSize]
+		}
+		assert.Equal(t, nonce, header[len(header)-len(nonce):])
+
+		// The nonce comes from metadata or from the start of the data. The
+		// envelope clears the DEK before the stream is read.
+		key := bytes.Clone(dek)
+		decrypted, err := encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext[len(header):])), key, nonce, aad)
+		require.NoError(t, err)
+		clear(key)
+		roundTrip, err := io.ReadAll(decrypted)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, roundTrip), "size %d", size)
+		decrypted, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, aad)
+		require.NoError(t, err)
+		roundTrip, err = io.ReadAll(decrypted)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, roundTrip), "size %d", size)
+
+		ciphertext[len(ciphertext)-1] ^= 1
+		decrypted, err = encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nil, aad)
+		if err == nil {
+			_, err = io.ReadAll(decrypted)
+		}
+		assert.Error(t, err, "size %d", size)
+	}
+}
@@ -0,0 +1,11 @@
+package dataencryption
+
+import "golang.org/x/sys/cpu"
+
+// HardwareAESAvailable reports whether the CPU provides AES instructions that
+// crypto/aes uses automatically: AES-NI on amd64, the ARMv8 Cryptography
+// Extensions on arm64. Other architectures report false even though
+// crypto/aes may still use hardware support there.
+func HardwareAESAvailable() bool {
+	return (cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ) || cpu.ARM64.HasAES
+}
@@ -0,0 +1,158 @@
+package dataencryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"sync"
+)
+
+// MinParallelSlice is the smallest amount of data handed to a single crypto
+// worker. Smaller chunks are processed by the calling goroutine alone, since
+// the goroutine handoff would cost more than the keystream itself.
+const MinParallelSlice = 16 * 1024
+
+// Parallelism bounds the number of goroutines used for AES-CTR and XChaCha20
+// processing.
+//
+// Because a stream cipher keystream can be computed at any offset, a chunk can be split
+// into slices that are processed independently. PerStream limits the number of
+// slices per chunk; the global cap limits the number of helper goroutines
+// running across all streams at once. The goroutine that owns a stream always
+// processes one slice itself and never waits for a helper slot, so a busy host
+// degrades to sequential processing instead of stalling streams.
+type Parallelism struct {
+	perStream int
+	helpers   chan struct{}
+}
+
+// NewParallelism creates a Parallelism with perStream workers per chunk and at
+// most global helper goroutines in total. Values below 1 are treated as 1.
+func NewParallelism(perStream, global int) *Parallelism {
+	if perStream < 1 {
+		perStream = 1
+	}
+	if global < 1 {
+		global = 1
+	}
+	return &Parallelism{
+		perStream: perStream,
+		helpers:   make(chan struct{}, global),
+	}
+}
+
+// PerStream returns the maximum number of workers per chunk
+func (p *Parallelism) PerStream() int {
+	if p == nil {
+		return 1
+	}
+	return p.perStream
+}
+
+// workersFor returns the number of slices to split a chunk of size bytes into
+func (p *Parallelism) workersFor(size int) int {
+	workers := size / MinParallelSlice
+	if workers > p.PerStream() {
+		workers = p.PerStream()
+	}
+	return workers
+}
+
+func (p *Parallelism) tryAcquire() bool {
+	select {
+	case p.helpers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Parallelism) release() {
+	<-p.helpers
+}
+
+// EncryptPartParallel is like EncryptPart but splits data across the workers
+// allowed by p. A nil p processes data sequentially. The output is identical
+// to EncryptPart. Not safe for concurrent use — see the type comment.
+func (e *AESCTRStatefulEncryptor) EncryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	e.xorParallel(data, p)
+	return data, nil
+}
+
+// DecryptPartParallel is like DecryptPart but splits data across the workers
+// allowed by p. A nil p processes data sequentially.
+func (e *AESCTRStatefulEncryptor) DecryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	e.xorParallel(data, p)
+	return data, nil
+}
+
+// DecryptAt decrypts data in place as if it started offset bytes into the
+// stream, leaving the stateful position untouched
+func (e *AESCTRStatefulEncryptor) DecryptAt(data []byte, offset uint64, p *Parallelism) {
+	xorStreamParallel(data, p, aes.BlockSize, offset, e.streamAt(offset), e.streamAt)
+}
+
+func (e *AESCTRStatefulEncryptor) xorParallel(data []byte, p *Parallelism) {
+	e.stream = xorStreamParallel(data, p, aes.BlockSize, e.offset, e.stream, e.streamAt)
+	e.offset += uint64(len(data))
+}
+
+// xorStreamParallel applies the keystream to data, with current positioned
+// at offset and streamAt positioning a new stream anywhere in the keystream.
+// It returns the stream positioned after data.
+func xorStreamParallel(data []byte, p *Parallelism, blockSize int, offset uint64, current cipher.Stream, streamAt func(uint64) cipher.Stream) cipher.Stream {
+	workers := p.workersFor(len(data))
+	if workers <= 1 {
+		current.XORKeyStream(data, data)
+		return current
+	}
+
+	// Block-aligned slices, so every helper stream starts on a counter boundary
+	// whenever the stream itself does
+	sliceSize := (len(data)/workers + blockSize - 1) &^ (blockSize - 1)
+
+	var wg sync.WaitGroup
+	for off := sliceSize; off < len(data); off += sliceSize {
+		slice := data[off:min(off+sliceSize, len(data))]
+		stream := streamAt(offset + uint64(off))
+		if !p.tryAcquire() {
+			stream.XORKeyStream(slice, slice)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.release()
+			stream.XORKeyStream(slice, slice)
+		}()
+	}
+	current.XORKeyStream(data[:sliceSize], data[:sliceSize])
+	wg.Wait()
+
+	return streamAt(offset + uint64(len(data)))
+}
+
+// streamAt returns a new CTR stream positioned offset bytes into the keystream
+func (e *AESCTRStatefulEncryptor) streamAt(offset uint64) cipher.Stream {
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, e.iv)
+	addCounter(counter, offset/aes.BlockSize)
+
+	stream := cipher.NewCTR(e.block, counter) // #nosec G407 -- counter is derived from the session IV
+	if skip := offset % aes.BlockSize; skip > 0 {
+		var discard [aes.BlockSize]byte
+		stream.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	return stream
+}
+
+// addCounter adds n to the 128-bit big-endian counter, wrapping around like
+// the standard library's CTR implementation
+func addCounter(counter []byte, n uint64) {
+	lo := binary.BigEndian.Uint64(counter[8:])
+	sum := lo + n
+	binary.BigEndian.PutUint64(counter[8:], sum)
+	if sum < lo {
+		binary.BigEndian.PutUint64(counter[:8], binary.BigEndian.Uint64(counter[:8])+1)
+	}
+}
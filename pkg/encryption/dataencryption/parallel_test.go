@@ -0,0 +1,122 @@
+package dataencryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomTestBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+func TestEncryptPartParallel_MatchesSequential(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	ivs := map[string][]byte{
+		"random": randomTestBytes(t, 16),
+		// low counter half about to wrap into the high half
+		"carry": {0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf0},
+	}
+	// unaligned sizes shift the stream off the block boundary between calls
+	sizes := []int{7, 3*MinParallelSlice + 5, MinParallelSlice, 1, 10*MinParallelSlice + 13, 100}
+
+	for name, iv := range ivs {
+		t.Run(name, func(t *testing.T) {
+			sequential, err := NewAESCTRStatefulEncryptorWithIV(dek, iv)
+			require.NoError(t, err)
+			parallel, err := NewAESCTRStatefulEncryptorWithIV(dek, iv)
+			require.NoError(t, err)
+			p := NewParallelism(8, 4)
+
+			for _, size := range sizes {
+				plaintext := randomTestBytes(t, size)
+				expected, err := sequential.EncryptPart(bytes.Clone(plaintext))
+				require.NoError(t, err)
+				got, err := parallel.EncryptPartParallel(bytes.Clone(plaintext), p)
+				require.NoError(t, err)
+				require.Equal(t, expected, got, "size %d", size)
+			}
+			assert.Empty(t, p.helpers, "all helper slots must be released")
+		})
+	}
+}
+
+func TestDecryptPartParallel_RoundTrip(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	encryptor, err := NewAESCTRStatefulEncryptor(dek)
+	require.NoError(t, err)
+
+	plaintext := randomTestBytes(t, 8*MinParallelSlice+3)
+	ciphertext, err := encryptor.EncryptPartParallel(bytes.Clone(plaintext), NewParallelism(4, 4))
+	require.NoError(t, err)
+
+	decryptor, err := NewAESCTRStatefulEncryptorWithIV(dek, encryptor.GetIV())
+	require.NoError(t, err)
+	got, err := decryptor.DecryptPartParallel(ciphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+}
+
+func TestDecryptAt_OutOfOrder(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	plaintext := randomTestBytes(t, 6*MinParallelSlice+9)
+
+	aesCTR, err := NewAESCTRStatefulEncryptor(dek)
+	require.NoError(t, err)
+	xchacha, err := NewXChaCha20StatefulEncryptor(dek)
+	require.NoError(t, err)
+
+	for _, encryptor := range []StatefulEncryptor{aesCTR, xchacha} {
+		t.Run(encryptor.Algorithm(), func(t *testing.T) {
+			ciphertext, err := encryptor.EncryptPart(bytes.Clone(plaintext))
+			require.NoError(t, err)
+			decryptor, err := NewStatefulEncryptorAt(encryptor.Algorithm(), dek, encryptor.GetIV(), 0)
+			require.NoError(t, err)
+
+			// unaligned chunk boundaries, decrypted back to front
+			split := []int{len(ciphertext), 4*MinParallelSlice + 3, 100, 5, 0}
+			for i := 0; i+1 < len(split); i++ {
+				chunk := ciphertext[split[i+1]:split[i]]
+				decryptor.(OffsetDecryptor).DecryptAt(chunk, uint64(split[i+1]), NewParallelism(4, 4))
+			}
+			assert.Equal(t, plaintext, ciphertext)
+			assert.Zero(t, decryptor.Offset(), "the stateful position must not move")
+		})
+	}
+}
+
+func TestParallelism_GlobalCapFallsBackToCaller(t *testing.T) {
+	p := NewParallelism(8, 1)
+	// occupy the only helper slot, as another stream would
+	require.True(t, p.tryAcquire())
+
+	dek := randomTestBytes(t, 32)
+	iv := randomTestBytes(t, 16)
+	sequential, err := NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	require.NoError(t, err)
+	parallel, err := NewAESCTRStatefulEncryptorWithIV(dek, iv)
+	require.NoError(t, err)
+
+	plaintext := randomTestBytes(t, 8*MinParallelSlice)
+	expected, _ := sequential.EncryptPart(bytes.Clone(plaintext))
+	got, _ := parallel.EncryptPartParallel(bytes.Clone(plaintext), p)
+	assert.Equal(t, expected, got)
+	assert.Len(t, p.helpers, 1)
+}
+
+func TestParallelism_WorkersFor(t *testing.T) {
+	p := NewParallelism(4, 4)
+	assert.Equal(t, 0, p.workersFor(MinParallelSlice-1))
+	assert.Equal(t, 2, p.workersFor(2*MinParallelSlice))
+	assert.Equal(t, 4, p.workersFor(100*MinParallelSlice))
+
+	var none *Parallelism
+	assert.Equal(t, 1, none.workersFor(100*MinParallelSlice))
+}
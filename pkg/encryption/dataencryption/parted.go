@@ -0,0 +1,155 @@
+package dataencryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sort"
+)
+
+// keystream is implemented by the stateful encryptors whose keystream can be
+// positioned anywhere
+type keystream interface {
+	StatefulEncryptor
+	streamAt(offset uint64) cipher.Stream
+	blockSize() int
+}
+
+func (e *AESCTRStatefulEncryptor) blockSize() int { return aes.BlockSize }
+
+func (e *XChaCha20StatefulEncryptor) blockSize() int { return xchachaBlockSize }
+
+// Extent is a run of Size bytes of an object that was encrypted starting at
+// Offset in the keystream of IV
+type Extent struct {
+	Size   uint64
+	Offset uint64
+	IV     []byte // nil for the IV of the object
+}
+
+// PartedEncryptor processes an object whose consecutive extents were
+// encrypted at unrelated keystream offsets, such as a multipart upload whose
+// parts were encrypted concurrently. Data past the last extent continues the
+// keystream of the last extent; integrity verification rejects such objects.
+type PartedEncryptor struct {
+	inner   keystream   // keystream of the object IV
+	streams []keystream // keystream of each extent
+	extents []Extent
+	starts  []uint64 // object offset of each extent
+	offset  uint64
+}
+
+// NewPartedDecryptor creates a decryptor for an object made of extents
+func NewPartedDecryptor(algorithm string, dek, iv []byte, extents []Extent) (*PartedEncryptor, error) {
+	if len(extents) == 0 {
+		return nil, fmt.Errorf("parted object has no extents")
+	}
+	inner, err := newKeystream(algorithm, dek, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &PartedEncryptor{inner: inner, extents: append([]Extent(nil), extents...)}
+	var start uint64
+	for _, extent := range e.extents {
+		stream := inner
+		if extent.IV != nil {
+			if stream, err = newKeystream(algorithm, dek, extent.IV); err != nil {
+				e.Cleanup()
+				return nil, err
+			}
+		}
+		e.streams = append(e.streams, stream)
+		e.starts = append(e.starts, start)
+		start += extent.Size
+	}
+	return e, nil
+}
+
+func newKeystream(algorithm string, dek, iv []byte) (keystream, error) {
+	encryptor, err := NewStatefulEncryptorAt(algorithm, dek, iv, 0)
+	if err != nil {
+		return nil, err
+	}
+	ks, ok := encryptor.(keystream)
+	if !ok {
+		encryptor.Cleanup()
+		return nil, fmt.Errorf("algorithm %s cannot be positioned", algorithm)
+	}
+	return ks, nil
+}
+
+// keystreamAt maps an object offset to the keystream of its extent and the
+// offset in it, and returns the number of bytes from there to the end of the
+// extent; the last extent is unbounded
+func (e *PartedEncryptor) keystreamAt(offset uint64) (keystream, uint64, uint64) {
+	i := sort.Search(len(e.starts), func(i int) bool { return e.starts[i] > offset }) - 1
+	extent := e.extents[i]
+	within := offset - e.starts[i]
+	if i == len(e.extents)-1 {
+		return e.streams[i], extent.Offset + within, ^uint64(0)
+	}
+	return e.streams[i], extent.Offset + within, extent.Size - within
+}
+
+// DecryptAt decrypts data in place as if it started offset bytes into the
+// object, leaving the stateful position untouched
+func (e *PartedEncryptor) DecryptAt(data []byte, offset uint64, p *Parallelism) {
+	for len(data) > 0 {
+		stream, at, remaining := e.keystreamAt(offset)
+		n := uint64(len(data))
+		if n > remaining {
+			n = remaining
+		}
+		xorStreamParallel(data[:n], p, stream.blockSize(), at, stream.streamAt(at), stream.streamAt)
+		data = data[n:]
+		offset += n
+	}
+}
+
+// EncryptPart encrypts data in-place and returns the same slice
+func (e *PartedEncryptor) EncryptPart(data []byte) ([]byte, error) {
+	return e.EncryptPartParallel(data, nil)
+}
+
+// DecryptPart decrypts data in-place and returns the same slice
+func (e *PartedEncryptor) DecryptPart(data []byte) ([]byte, error) {
+	return e.EncryptPartParallel(data, nil)
+}
+
+// EncryptPartParallel is like EncryptPart but splits data across the workers allowed by p
+func (e *PartedEncryptor) EncryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	e.DecryptAt(data, e.offset, p)
+	e.offset += uint64(len(data))
+	return data, nil
+}
+
+// DecryptPartParallel is like DecryptPart but splits data across the workers allowed by p
+func (e *PartedEncryptor) DecryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	return e.EncryptPartParallel(data, p)
+}
+
+// GetIV returns the IV of the object
+func (e *PartedEncryptor) GetIV() []byte {
+	return e.inner.GetIV()
+}
+
+// Offset returns the number of object bytes processed so far
+func (e *PartedEncryptor) Offset() uint64 {
+	return e.offset
+}
+
+// Algorithm returns the algorithm identifier
+func (e *PartedEncryptor) Algorithm() string {
+	return e.inner.Algorithm()
+}
+
+// Cleanup clears the key material from memory
+func (e *PartedEncryptor) Cleanup() {
+	for _, stream := range e.streams {
+		if stream != e.inner {
+			stream.Cleanup()
+		}
+	}
+	e.inner.Cleanup()
+}
@@ -0,0 +1,83 @@
+package dataencryption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartedDecryptor(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	iv := randomTestBytes(t, 16)
+	nonce := randomTestBytes(t, 24)
+	sizes := []int{3*MinParallelSlice + 7, 0, 100, MinParallelSlice}
+	const stride = 1 << 30
+
+	for _, tc := range []struct {
+		algorithm string
+		iv        []byte
+	}{{"aes-ctr", iv}, {"xchacha20", nonce}} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			// encrypt each part on its own at its stride in the keystream
+			var plaintext, ciphertext []byte
+			var extents []Extent
+			for i, size := range sizes {
+				part := randomTestBytes(t, size)
+				plaintext = append(plaintext, part...)
+				offset := uint64(i) * stride
+				encryptor, err := NewStatefulEncryptorAt(tc.algorithm, dek, tc.iv, offset)
+				require.NoError(t, err)
+				encrypted, err := encryptor.EncryptPart(bytes.Clone(part))
+				require.NoError(t, err)
+				ciphertext = append(ciphertext, encrypted...)
+				extents = append(extents, Extent{Size: uint64(size), Offset: offset})
+			}
+
+			decryptor, err := NewPartedDecryptor(tc.algorithm, dek, tc.iv, extents)
+			require.NoError(t, err)
+			sequential := bytes.Clone(ciphertext)
+			for off := 0; off < len(sequential); off += 1000 {
+				_, err := decryptor.DecryptPartParallel(sequential[off:min(off+1000, len(sequential))], NewParallelism(4, 4))
+				require.NoError(t, err)
+			}
+			assert.Equal(t, plaintext, sequential)
+			assert.Equal(t, uint64(len(plaintext)), decryptor.Offset())
+
+			// chunks crossing part boundaries, decrypted back to front
+			split := []int{len(ciphertext), 3*MinParallelSlice + 50, 11, 0}
+			for i := 0; i+1 < len(split); i++ {
+				decryptor.DecryptAt(ciphertext[split[i+1]:split[i]], uint64(split[i+1]), nil)
+			}
+			assert.Equal(t, plaintext, ciphertext)
+		})
+	}
+
+	_, err := NewPartedDecryptor("aes-ctr", dek, iv, nil)
+	assert.Error(t, err)
+}
+
+func TestPartedDecryptor_ExtentIV(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	iv := randomTestBytes(t, 16)
+	other := randomTestBytes(t, 16)
+	parts := [][]byte{randomTestBytes(t, 100), randomTestBytes(t, 200)}
+
+	first, err := NewAESCTRStatefulEncryptorAt(dek, iv, 0)
+	require.NoError(t, err)
+	second, err := NewAESCTRStatefulEncryptorAt(dek, other, 1000)
+	require.NoError(t, err)
+	ciphertext1, err := first.EncryptPart(bytes.Clone(parts[0]))
+	require.NoError(t, err)
+	ciphertext2, err := second.EncryptPart(bytes.Clone(parts[1]))
+	require.NoError(t, err)
+
+	decryptor, err := NewPartedDecryptor("aes-ctr", dek, iv, []Extent{{Size: 100}, {Size: 200, Offset: 1000, IV: other}})
+	require.NoError(t, err)
+	defer decryptor.Cleanup()
+	plaintext, err := decryptor.DecryptPart(append(ciphertext1, ciphertext2...))
+	require.NoError(t, err)
+	assert.Equal(t, append(parts[0], parts[1]...), plaintext)
+	assert.Equal(t, iv, decryptor.GetIV())
+}
@@ -0,0 +1,75 @@
+package dataencryption
+
+import (
+	"fmt"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// StatefulEncryptor is a stream cipher that keeps its keystream position
+// across calls, used for multipart uploads and streamed objects. It is
+// implemented by AESCTRStatefulEncryptor and XChaCha20StatefulEncryptor.
+type StatefulEncryptor interface {
+	EncryptPart(data []byte) ([]byte, error)
+	DecryptPart(data []byte) ([]byte, error)
+	EncryptPartParallel(data []byte, p *Parallelism) ([]byte, error)
+	DecryptPartParallel(data []byte, p *Parallelism) ([]byte, error)
+	// GetIV returns the IV or nonce that is stored in the object metadata
+	GetIV() []byte
+	Offset() uint64
+	Algorithm() string
+	Cleanup()
+}
+
+// OffsetDecryptor is implemented by stateful encryptors whose keystream can
+// be positioned anywhere. DecryptAt decrypts data found offset bytes into the
+// stream without moving the stateful position, and is safe for concurrent
+// use, so chunks of one stream can be decrypted out of order.
+type OffsetDecryptor interface {
+	DecryptAt(data []byte, offset uint64, p *Parallelism)
+}
+
+// NewStatefulEncryptor creates a stateful encryptor for a streaming
+// algorithm ("aes-ctr" or "xchacha20") with a random IV
+func NewStatefulEncryptor(algorithm string, dek []byte) (StatefulEncryptor, error) {
+	switch encryption.EncryptionType(algorithm) {
+	case encryption.EncryptionTypeAESCTR:
+		return NewAESCTRStatefulEncryptor(dek)
+	case encryption.EncryptionTypeXChaCha20:
+		return NewXChaCha20StatefulEncryptor(dek)
+	default:
+		return nil, fmt.Errorf("unsupported streaming algorithm: %s", algorithm)
+	}
+}
+
+// NewStatefulEncryptorAt creates a stateful encryptor for a streaming
+// algorithm with an existing IV, positioned offset bytes into the keystream
+func NewStatefulEncryptorAt(algorithm string, dek, iv []byte, offset uint64) (StatefulEncryptor, error) {
+	switch encryption.EncryptionType(algorithm) {
+	case encryption.EncryptionTypeAESCTR:
+		return NewAESCTRStatefulEncryptorAt(dek, iv, offset)
+	case encryption.EncryptionTypeXChaCha20:
+		return NewXChaCha20StatefulEncryptorAt(dek, iv, offset)
+	default:
+		return nil, fmt.Errorf("unsupported streaming algorithm: %s", algorithm)
+	}
+}
+
+// NewDataEncryptor creates the data encryptor for an algorithm identifier as
+// stored in the dek-algorithm metadata
+func NewDataEncryptor(algorithm string) (encryption.DataEncryptor, error) {
+	switch encryption.EncryptionType(algorithm) {
+	case encryption.EncryptionTypeAESGCM:
+		return NewAESGCMDataEncryptor(), nil
+	case encryption.EncryptionTypeAESCTR:
+		return NewAESCTRDataEncryptor(), nil
+	case encryption.EncryptionTypeAESGCMSIV:
+		return NewAESGCMSIVDataEncryptor(), nil
+	case encryption.EncryptionTypeChaCha20Poly1305:
+		return NewChaCha20Poly1305DataEncryptor(), nil
+	case encryption.EncryptionTypeXChaCha20:
+		return NewXChaCha20DataEncryptor(), nil
+	default:
+		return nil, fmt.Errorf("unsupported data encryption algorithm: %s", algorithm)
+	}
+}
@@ -0,0 +1,237 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/chacha20"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// A ChaCha20 keystream has a 32-bit block counter, so one nonce covers
+// xchachaSegmentSize bytes. Longer streams continue in the next segment, whose
+// nonce is the stream nonce with the segment index added to its last eight
+// bytes. S3 objects of up to 5 TiB span at most 20 segments.
+const (
+	xchachaSegmentSize = 1 << 38
+	xchachaBlockSize   = 64
+)
+
+// XChaCha20DataEncryptor implements streaming xchacha20 encryption, the
+// counterpart of aes-ctr for hosts without AES hardware support. The 24-byte
+// nonce is stored in the metadata like the AES-CTR IV.
+type XChaCha20DataEncryptor struct {
+	lastNonce []byte
+	mutex     sync.Mutex
+}
+
+// NewXChaCha20DataEncryptor creates a new streaming XChaCha20 data encryptor
+func NewXChaCha20DataEncryptor() encryption.DataEncryptor {
+	return &XChaCha20DataEncryptor{}
+}
+
+// EncryptStream encrypts data from a reader using xchacha20
+func (e *XChaCha20DataEncryptor) EncryptStream(_ context.Context, reader *bufio.Reader, dek []byte, _ []byte) (*bufio.Reader, error) {
+	nonce := make([]byte, chacha20.NonceSizeX)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	stream, err := newXChaCha20Stream(dek, nonce, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	e.lastNonce = append([]byte(nil), nonce...)
+	e.mutex.Unlock()
+
+	return bufio.NewReader(&ctrStreamReader{reader: reader, stream: stream}), nil
+}
+
+// DecryptStream decrypts data from an encrypted reader using xchacha20 with the nonce from metadata
+func (e *XChaCha20DataEncryptor) DecryptStream(_ context.Context, encryptedReader *bufio.Reader, dek []byte, iv []byte, _ []byte) (*bufio.Reader, error) {
+	stream, err := newXChaCha20Stream(dek, iv, 0)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(&ctrStreamReader{reader: encryptedReader, stream: stream}), nil
+}
+
+// GenerateDEK generates a new 256-bit key
+func (e *XChaCha20DataEncryptor) GenerateDEK(_ context.Context) ([]byte, error) {
+	dek := make([]byte, chacha20.KeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("failed to generate DEK: %w", err)
+	}
+	return dek, nil
+}
+
+// Algorithm returns the algorithm identifier
+func (e *XChaCha20DataEncryptor) Algorithm() string {
+	return string(encryption.EncryptionTypeXChaCha20)
+}
+
+// GetLastIV returns a copy of the nonce used in the last encryption operation
+func (e *XChaCha20DataEncryptor) GetLastIV() []byte {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.lastNonce == nil {
+		return nil
+	}
+	return append([]byte(nil), e.lastNonce...)
+}
+
+// xchacha20Stream is an XChaCha20 keystream of any length, starting at an
+// arbitrary offset
+type xchacha20Stream struct {
+	key    []byte
+	nonce  []byte
+	offset uint64
+	cipher *chacha20.Cipher // nil at a segment boundary
+}
+
+func newXChaCha20Stream(key, nonce []byte, offset uint64) (*xchacha20Stream, error) {
+	if len(key) != chacha20.KeySize {
+		return nil, fmt.Errorf("invalid DEK size: expected %d bytes, got %d", chacha20.KeySize, len(key))
+	}
+	if len(nonce) != chacha20.NonceSizeX {
+		return nil, fmt.Errorf("invalid nonce size: expected %d bytes, got %d", chacha20.NonceSizeX, len(nonce))
+	}
+	// The segment ciphers are created as the stream advances, after callers
+	// may have cleared key
+	return &xchacha20Stream{key: bytes.Clone(key), nonce: bytes.Clone(nonce), offset: offset}, nil
+}
+
+func (s *xchacha20Stream) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if s.cipher == nil {
+			s.cipher = s.segmentCipher()
+		}
+		n := int(min(uint64(len(src)), xchachaSegmentSize-s.offset%xchachaSegmentSize))
+		s.cipher.XORKeyStream(dst[:n], src[:n])
+		s.offset += uint64(n)
+		if s.offset%xchachaSegmentSize == 0 {
+			// The counter is exhausted; the next byte starts a new segment
+			s.cipher = nil
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// segmentCipher returns the cipher of the segment containing offset,
+// positioned at offset
+func (s *xchacha20Stream) segmentCipher() *chacha20.Cipher {
+	nonce := append([]byte(nil), s.nonce...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)+s.offset/xchachaSegmentSize)
+
+	// The key and nonce sizes were checked by newXChaCha20Stream
+	c, _ := chacha20.NewUnauthenticatedCipher(s.key, nonce)
+	within := s.offset % xchachaSegmentSize
+	c.SetCounter(uint32(within / xchachaBlockSize)) // #nosec G115 -- within is below 2^38, so the block index fits
+	if skip := within % xchachaBlockSize; skip > 0 {
+		var discard [xchachaBlockSize]byte
+		c.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	return c
+}
+
+// XChaCha20StatefulEncryptor provides stateful XChaCha20 encryption for
+// multipart uploads. Like AESCTRStatefulEncryptor it is inherently
+// sequential: a single owner must drive it one call at a time.
+type XChaCha20StatefulEncryptor struct {
+	dek    []byte
+	nonce  []byte
+	stream cipher.Stream
+	offset uint64
+}
+
+// NewXChaCha20StatefulEncryptor creates a new stateful XChaCha20 encryptor with a random nonce
+func NewXChaCha20StatefulEncryptor(dek []byte) (*XChaCha20StatefulEncryptor, error) {
+	nonce := make([]byte, chacha20.NonceSizeX)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return NewXChaCha20StatefulEncryptorAt(dek, nonce, 0)
+}
+
+// NewXChaCha20StatefulEncryptorAt creates a stateful encryptor with an
+// existing nonce positioned offset bytes into the keystream
+func NewXChaCha20StatefulEncryptorAt(dek, nonce []byte, offset uint64) (*XChaCha20StatefulEncryptor, error) {
+	e := &XChaCha20StatefulEncryptor{
+		dek:    append([]byte(nil), dek...),
+		nonce:  append([]byte(nil), nonce...),
+		offset: offset,
+	}
+	stream, err := newXChaCha20Stream(e.dek, e.nonce, offset)
+	if err != nil {
+		return nil, err
+	}
+	e.stream = stream
+	return e, nil
+}
+
+// EncryptPart encrypts data in-place and returns the same slice
+func (e *XChaCha20StatefulEncryptor) EncryptPart(data []byte) ([]byte, error) {
+	e.stream.XORKeyStream(data, data)
+	e.offset += uint64(len(data))
+	return data, nil
+}
+
+// DecryptPart decrypts data in-place and returns the same slice
+func (e *XChaCha20StatefulEncryptor) DecryptPart(data []byte) ([]byte, error) {
+	return e.EncryptPart(data)
+}
+
+// EncryptPartParallel is like EncryptPart but splits data across the workers allowed by p
+func (e *XChaCha20StatefulEncryptor) EncryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	e.stream = xorStreamParallel(data, p, xchachaBlockSize, e.offset, e.stream, e.streamAt)
+	e.offset += uint64(len(data))
+	return data, nil
+}
+
+// DecryptPartParallel is like DecryptPart but splits data across the workers allowed by p
+func (e *XChaCha20StatefulEncryptor) DecryptPartParallel(data []byte, p *Parallelism) ([]byte, error) {
+	return e.EncryptPartParallel(data, p)
+}
+
+// DecryptAt decrypts data in place as if it started offset bytes into the
+// stream, leaving the stateful position untouched
+func (e *XChaCha20StatefulEncryptor) DecryptAt(data []byte, offset uint64, p *Parallelism) {
+	xorStreamParallel(data, p, xchachaBlockSize, offset, e.streamAt(offset), e.streamAt)
+}
+
+func (e *XChaCha20StatefulEncryptor) streamAt(offset uint64) cipher.Stream {
+	return &xchacha20Stream{key: e.dek, nonce: e.nonce, offset: offset}
+}
+
+// GetIV returns the nonce used by this encryptor
+func (e *XChaCha20StatefulEncryptor) GetIV() []byte {
+	return append([]byte(nil), e.nonce...)
+}
+
+// Offset returns the number of bytes processed so far
+func (e *XChaCha20StatefulEncryptor) Offset() uint64 {
+	return e.offset
+}
+
+// Algorithm returns the algorithm identifier
+func (e *XChaCha20StatefulEncryptor) Algorithm() string {
+	return string(encryption.EncryptionTypeXChaCha20)
+}
+
+// Cleanup clears the key material from memory
+func (e *XChaCha20StatefulEncryptor) Cleanup() {
+	clear(e.dek)
+	clear(e.nonce)
+	e.dek, e.nonce, e.stream = nil, nil, nil
+}
@@ -0,0 +1,138 @@
+package dataencryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20"
+)
+
+func TestXChaCha20Stream_MatchesLibrary(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	nonce := randomTestBytes(t, chacha20.NonceSizeX)
+	plaintext := randomTestBytes(t, 1000)
+
+	reference, err := chacha20.NewUnauthenticatedCipher(dek, nonce)
+	require.NoError(t, err)
+	expected := make([]byte, len(plaintext))
+	reference.XORKeyStream(expected, plaintext)
+
+	// Any split and any start offset yields the same keystream
+	stream, err := newXChaCha20Stream(dek, nonce, 0)
+	require.NoError(t, err)
+	got := bytes.Clone(plaintext)
+	stream.XORKeyStream(got[:7], got[:7])
+	stream.XORKeyStream(got[7:], got[7:])
+	assert.Equal(t, expected, got)
+
+	for _, offset := range []int{1, 63, 64, 65, 999} {
+		stream, err := newXChaCha20Stream(dek, nonce, uint64(offset))
+		require.NoError(t, err)
+		tail := bytes.Clone(plaintext[offset:])
+		stream.XORKeyStream(tail, tail)
+		assert.Equal(t, expected[offset:], tail, "offset %d", offset)
+	}
+}
+
+func TestXChaCha20Stream_CrossesSegmentBoundary(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	nonce := randomTestBytes(t, chacha20.NonceSizeX)
+	data := make([]byte, 200)
+
+	stream, err := newXChaCha20Stream(dek, nonce, xchachaSegmentSize-100)
+	require.NoError(t, err)
+	stream.XORKeyStream(data, data)
+
+	// The last 100 bytes of the first segment are the end of its keystream
+	first, err := chacha20.NewUnauthenticatedCipher(dek, nonce)
+	require.NoError(t, err)
+	first.SetCounter(1<<32 - 2)
+	expected := make([]byte, 128)
+	first.XORKeyStream(expected, expected)
+	assert.Equal(t, expected[28:], data[:100])
+
+	// The next segment starts over with the following nonce
+	next := bytes.Clone(nonce)
+	binary.BigEndian.PutUint64(next[16:], binary.BigEndian.Uint64(next[16:])+1)
+	second, err := chacha20.NewUnauthenticatedCipher(dek, next)
+	require.NoError(t, err)
+	expected = make([]byte, 100)
+	second.XORKeyStream(expected, expected)
+	assert.Equal(t, expected, data[100:])
+}
+
+func TestXChaCha20StatefulEncryptor_ParallelAndResume(t *testing.T) {
+	dek := randomTestBytes(t, 32)
+	sequential, err := NewStatefulEncryptor("xchacha20", dek)
+	require.NoError(t, err)
+	parallel, err := NewStatefulEncryptorAt("xchacha20", dek, sequential.GetIV(), 0)
+	require.NoError(t, err)
+	p := NewParallelism(8, 4)
+
+	var ciphertext []byte
+	for _, size := range []int{7, 3*MinParallelSlice + 5, 1, 10*MinParallelSlice + 13} {
+		plaintext := randomTestBytes(t, size)
+		expected, err := sequential.EncryptPart(bytes.Clone(plaintext))
+		require.NoError(t, err)
+		got, err := parallel.EncryptPartParallel(bytes.Clone(plaintext), p)
+		require.NoError(t, err)
+		require.Equal(t, expected, got, "size %d", size)
+		ciphertext = append(ciphertext, got...)
+	}
+	assert.Equal(t, uint64(len(ciphertext)), parallel.Offset())
+	assert.Equal(t, "xchacha20", parallel.Algorithm())
+
+	// A stream resumed at an offset continues the keystream
+	resumed, err := NewStatefulEncryptorAt("xchacha20", dek, sequential.GetIV(), 7)
+	require.NoError(t, err)
+	direct, err := NewStatefulEncryptorAt("xchacha20", dek, sequential.GetIV(), 0)
+	require.NoError(t, err)
+	plaintext, err := direct.DecryptPart(bytes.Clone(ciphertext))
+	require.NoError(t, err)
+	tail, err := resumed.DecryptPartParallel(bytes.Clone(ciphertext[7:]), p)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext[7:], tail)
+
+	_, err = NewStatefulEncryptorAt("xchacha20", dek, make([]byte, 16), 0)
+	assert.ErrorContains(t, err, "invalid nonce size")
+	_, err = NewStatefulEncryptor("rot13", dek)
+	assert.ErrorContains(t, err, "unsupported streaming algorithm")
+}
+
+func TestXChaCha20DataEncryptor_RoundTrip(t *testing.T) {
+	encryptor := NewXChaCha20DataEncryptor()
+	ctx := context.Background()
+	dek, err := encryptor.GenerateDEK(ctx)
+	require.NoError(t, err)
+	plaintext := randomTestBytes(t, 3*64*1024+11)
+
+	// The envelope clears the DEK before the stream is read
+	key := bytes.Clone(dek)
+	encrypted, err := encryptor.EncryptStream(ctx, bufio.NewReader(bytes.NewReader(plaintext)), key, nil)
+	require.NoError(t, err)
+	clear(key)
+	ciphertext, err := io.ReadAll(encrypted)
+	require.NoError(t, err)
+	require.Len(t, ciphertext, len(plaintext))
+	nonce := encryptor.(*XChaCha20DataEncryptor).GetLastIV()
+	require.Len(t, nonce, chacha20.NonceSizeX)
+
+	decrypted, err := encryptor.DecryptStream(ctx, bufio.NewReader(bytes.NewReader(ciphertext)), dek, nonce, nil)
+	require.NoError(t, err)
+	roundTrip, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(plaintext, roundTrip))
+
+	// The stateful encryptor of multipart uploads produces the same stream
+	stateful, err := NewStatefulEncryptorAt("xchacha20", dek, nonce, 0)
+	require.NoError(t, err)
+	fromParts, err := stateful.DecryptPart(bytes.Clone(ciphertext))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(plaintext, fromParts))
+}
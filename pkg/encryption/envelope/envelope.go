@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 )
@@ -72,6 +73,11 @@ func (e *EnvelopeEncryptor) EncryptDataStream(ctx context.Context, dataReader *b
 			metadata[e.metadataPrefix+"aes-iv"] = base64.StdEncoding.EncodeToString(iv)
 		}
 	}
+	if segmentProvider, ok := e.dataEncryptor.(encryption.SegmentProvider); ok {
+		if size := segmentProvider.GetLastSegmentSize(); size > 0 {
+			metadata[e.metadataPrefix+"segment-size"] = strconv.Itoa(size)
+		}
+	}
 
 	return encryptedDataReader, encryptedDEK, metadata, nil
 }
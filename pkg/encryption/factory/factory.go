@@ -2,6 +2,9 @@ package factory
 
 import (
 	"fmt"
+	"sync"
+
+	"github.com/go-viper/mapstructure/v2"
 
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption/dataencryption"
@@ -26,36 +29,98 @@ const (
 	ForceAESCTRContentType = "application/x-s3ep-force-aes-ctr"
 )
 
+// DataCipher selects the data encryption algorithms of a provider
+type DataCipher string
+
+const (
+	// DataCipherAES uses aes-gcm for whole objects and aes-ctr for streams
+	DataCipherAES DataCipher = "aes"
+	// DataCipherChaCha20 uses chacha20-poly1305 for whole objects and
+	// xchacha20 for streams, which is faster on CPUs without AES instructions
+	DataCipherChaCha20 DataCipher = "chacha20"
+	// DataCipherAESGCMSIV uses aes-gcm-siv for whole objects, which stays
+	// secure if a nonce repeats, and aes-ctr for streams
+	DataCipherAESGCMSIV DataCipher = "aes-gcm-siv"
+	// DataCipherAuto picks chacha20 when the CPU lacks AES instructions
+	DataCipherAuto DataCipher = "auto"
+)
+
+// ResolveDataCipher returns the data cipher for the data_cipher setting of a
+// provider. An empty setting means aes; auto is decided for this host.
+func ResolveDataCipher(name string) (DataCipher, error) {
+	switch DataCipher(name) {
+	case "", DataCipherAES:
+		return DataCipherAES, nil
+	case DataCipherChaCha20:
+		return DataCipherChaCha20, nil
+	case DataCipherAESGCMSIV:
+		return DataCipherAESGCMSIV, nil
+	case DataCipherAuto:
+		if dataencryption.HardwareAESAvailable() {
+			return DataCipherAES, nil
+		}
+		return DataCipherChaCha20, nil
+	default:
+		return "", fmt.Errorf("unsupported data cipher: %s", name)
+	}
+}
+
+// Algorithm returns the data encryption algorithm for a content type
+func (c DataCipher) Algorithm(contentType ContentType) string {
+	if c == DataCipherChaCha20 {
+		if contentType == ContentTypeMultipart {
+			return string(encryption.EncryptionTypeXChaCha20)
+		}
+		return string(encryption.EncryptionTypeChaCha20Poly1305)
+	}
+	if contentType == ContentTypeMultipart {
+		return string(encryption.EncryptionTypeAESCTR)
+	}
+	if c == DataCipherAESGCMSIV {
+		return string(encryption.EncryptionTypeAESGCMSIV)
+	}
+	return string(encryption.EncryptionTypeAESGCM)
+}
+
 // KeyEncryptionType represents the type of key encryption to use
 type KeyEncryptionType string
 
 const (
-	KeyEncryptionTypeAES  KeyEncryptionType = "aes"
-	KeyEncryptionTypeRSA  KeyEncryptionType = "rsa"
-	KeyEncryptionTypeTink KeyEncryptionType = "tink"
-	KeyEncryptionTypeNone KeyEncryptionType = "none"
+	KeyEncryptionTypeAES          KeyEncryptionType = "aes"
+	KeyEncryptionTypeRSA          KeyEncryptionType = "rsa"
+	KeyEncryptionTypeTink         KeyEncryptionType = "tink"
+	KeyEncryptionTypeNone         KeyEncryptionType = "none"
+	KeyEncryptionTypeVaultTransit KeyEncryptionType = "vault-transit"
 )
 
-// Factory creates encryption providers based on configuration
+// Factory creates encryption providers based on configuration. Key
+// encryptors can be registered while it is in use.
 type Factory struct {
+	mu            sync.RWMutex
 	keyEncryptors map[string]encryption.KeyEncryptor // Keyed by fingerprint
+	dataCiphers   map[string]DataCipher              // Keyed by fingerprint; aes if absent
 }
 
 // NewFactory creates a new provider factory
 func NewFactory() *Factory {
 	return &Factory{
 		keyEncryptors: make(map[string]encryption.KeyEncryptor),
+		dataCiphers:   make(map[string]DataCipher),
 	}
 }
 
 // RegisterKeyEncryptor registers a key encryptor for use in envelope encryption
 func (f *Factory) RegisterKeyEncryptor(keyEncryptor encryption.KeyEncryptor) {
 	fingerprint := keyEncryptor.Fingerprint()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.keyEncryptors[fingerprint] = keyEncryptor
 }
 
 // GetKeyEncryptor retrieves a registered key encryptor by fingerprint
 func (f *Factory) GetKeyEncryptor(fingerprint string) (encryption.KeyEncryptor, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	keyEncryptor, exists := f.keyEncryptors[fingerprint]
 	if !exists {
 		return nil, fmt.Errorf("key encryptor with fingerprint '%s' not found", fingerprint)
@@ -63,27 +128,52 @@ func (f *Factory) GetKeyEncryptor(fingerprint string) (encryption.KeyEncryptor,
 	return keyEncryptor, nil
 }
 
-// CreateEnvelopeEncryptor creates an envelope encryptor based on content type and key encryption type
-func (f *Factory) CreateEnvelopeEncryptor(contentType ContentType, keyFingerprint string, metadataPrefix string) (encryption.EnvelopeEncryptor, error) {
-	// Find the key encryptor by fingerprint
-	keyEncryptor, exists := f.keyEncryptors[keyFingerprint]
+// SetDataCipher sets the data cipher new objects of the key encryptor with
+// keyFingerprint are encrypted with
+func (f *Factory) SetDataCipher(keyFingerprint string, cipher DataCipher) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dataCiphers[keyFingerprint] = cipher
+}
+
+// DataAlgorithm returns the data encryption algorithm for new objects of a
+// content type encrypted by the key encryptor with keyFingerprint
+func (f *Factory) DataAlgorithm(contentType ContentType, keyFingerprint string) string {
+	f.mu.RLock()
+	cipher, exists := f.dataCiphers[keyFingerprint]
+	f.mu.RUnlock()
 	if !exists {
-		return nil, fmt.Errorf("key encryptor with fingerprint %s not found", keyFingerprint)
+		cipher = DataCipherAES
 	}
+	return cipher.Algorithm(contentType)
+}
 
-	// Choose data encryptor based on content type
-	var dataEncryptor encryption.DataEncryptor
+// CreateEnvelopeEncryptor creates an envelope encryptor based on content type and key encryption type
+func (f *Factory) CreateEnvelopeEncryptor(contentType ContentType, keyFingerprint string, metadataPrefix string) (encryption.EnvelopeEncryptor, error) {
 	switch contentType {
-	case ContentTypeMultipart:
-		// For multipart/chunks, use AES-CTR (streaming optimized)
-		dataEncryptor = dataencryption.NewAESCTRDataEncryptor()
-	case ContentTypeWhole:
-		// For whole files, use AES-GCM (authenticated encryption with streaming support)
-		dataEncryptor = dataencryption.NewAESGCMDataEncryptor()
+	case ContentTypeMultipart, ContentTypeWhole:
+		// Multipart/chunks use the stream cipher of the provider, whole files
+		// its authenticated cipher
+		return f.CreateEnvelopeEncryptorForAlgorithm(f.DataAlgorithm(contentType, keyFingerprint), keyFingerprint, metadataPrefix)
 	default:
 		return nil, fmt.Errorf("unsupported content type: %s", contentType)
 	}
+}
 
+// CreateEnvelopeEncryptorForAlgorithm creates an envelope encryptor for a
+// data encryption algorithm as stored in the dek-algorithm metadata, which is
+// how existing objects are decrypted whatever the provider uses today
+func (f *Factory) CreateEnvelopeEncryptorForAlgorithm(algorithm string, keyFingerprint string, metadataPrefix string) (encryption.EnvelopeEncryptor, error) {
+	f.mu.RLock()
+	keyEncryptor, exists := f.keyEncryptors[keyFingerprint]
+	f.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("key encryptor with fingerprint %s not found", keyFingerprint)
+	}
+	dataEncryptor, err := dataencryption.NewDataEncryptor(algorithm)
+	if err != nil {
+		return nil, err
+	}
 	return envelope.New(keyEncryptor, dataEncryptor, metadataPrefix), nil
 }
 
@@ -98,6 +188,8 @@ func (f *Factory) CreateKeyEncryptorFromConfig(keyType KeyEncryptionType, config
 		return nil, fmt.Errorf("tink key encryption is not yet implemented with the new KeyEncryptor interface")
 	case KeyEncryptionTypeNone:
 		return f.createNoneKeyEncryptor(config)
+	case KeyEncryptionTypeVaultTransit:
+		return f.createVaultTransitKeyEncryptor(config)
 	default:
 		return nil, fmt.Errorf("unsupported key encryption type: %s", keyType)
 	}
@@ -149,6 +241,22 @@ func (f *Factory) createNoneKeyEncryptor(config map[string]interface{}) (encrypt
 	return keyencryption.NewNoneProvider(config)
 }
 
+func (f *Factory) createVaultTransitKeyEncryptor(config map[string]interface{}) (encryption.KeyEncryptor, error) {
+	var vaultConfig keyencryption.VaultTransitConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           &vaultConfig,
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid vault-transit configuration: %w", err)
+	}
+	return keyencryption.NewVaultTransitProvider(vaultConfig)
+}
+
 // GetRegisteredKeyEncryptors returns a list of all registered key encryptor fingerprints
 // ProviderInfo holds information about a registered provider
 type ProviderInfo struct {
@@ -157,6 +265,8 @@ type ProviderInfo struct {
 }
 
 func (f *Factory) GetRegisteredKeyEncryptors() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	fingerprints := make([]string, 0, len(f.keyEncryptors))
 	for fingerprint := range f.keyEncryptors {
 		fingerprints = append(fingerprints, fingerprint)
@@ -166,6 +276,8 @@ func (f *Factory) GetRegisteredKeyEncryptors() []string {
 
 // GetRegisteredProviderInfo returns detailed information about all registered key encryptors
 func (f *Factory) GetRegisteredProviderInfo() []ProviderInfo {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	providers := make([]ProviderInfo, 0, len(f.keyEncryptors))
 	for fingerprint, keyEncryptor := range f.keyEncryptors {
 		// Determine provider type based on the encryptor type
@@ -178,7 +290,8 @@ func (f *Factory) GetRegisteredProviderInfo() []ProviderInfo {
 		case *keyencryption.NoneProvider:
 			providerType = "none"
 		default:
-			providerType = "unknown"
+			// Wrapped encryptors (recovery copies, rate limits) report the type of the provider they wrap
+			providerType = keyEncryptor.Name()
 		}
 
 		providers = append(providers, ProviderInfo{
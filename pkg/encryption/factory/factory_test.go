@@ -1,6 +1,10 @@
 package factory
 
 import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,6 +58,59 @@ func TestFactory_CreateEnvelopeEncryptor(t *testing.T) {
 	}
 }
 
+func TestFactory_DataCipher(t *testing.T) {
+	factory := NewFactory()
+	keyEncryptor, err := factory.CreateKeyEncryptorFromConfig(KeyEncryptionTypeAES, map[string]interface{}{
+		"aes_key": "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI=",
+	})
+	require.NoError(t, err)
+	factory.RegisterKeyEncryptor(keyEncryptor)
+	fingerprint := keyEncryptor.Fingerprint()
+
+	assert.Equal(t, "aes-gcm", factory.DataAlgorithm(ContentTypeWhole, fingerprint))
+	assert.Equal(t, "aes-ctr", factory.DataAlgorithm(ContentTypeMultipart, fingerprint))
+
+	cipher, err := ResolveDataCipher("chacha20")
+	require.NoError(t, err)
+	factory.SetDataCipher(fingerprint, cipher)
+	assert.Equal(t, "chacha20-poly1305", factory.DataAlgorithm(ContentTypeWhole, fingerprint))
+	assert.Equal(t, "xchacha20", factory.DataAlgorithm(ContentTypeMultipart, fingerprint))
+
+	ctx := context.Background()
+	encryptor, err := factory.CreateEnvelopeEncryptor(ContentTypeWhole, fingerprint, "s3ep-")
+	require.NoError(t, err)
+	encrypted, encryptedDEK, metadata, err := encryptor.EncryptDataStream(ctx, bufio.NewReader(strings.NewReader("hello")), []byte("bucket/key"))
+	require.NoError(t, err)
+	assert.Equal(t, "chacha20-poly1305", metadata["s3ep-dek-algorithm"])
+	ciphertext, err := io.ReadAll(encrypted)
+	require.NoError(t, err)
+
+	// Objects are decrypted by the algorithm in their metadata, whatever the provider uses now
+	factory.SetDataCipher(fingerprint, DataCipherAES)
+	decryptor, err := factory.CreateEnvelopeEncryptorForAlgorithm(metadata["s3ep-dek-algorithm"], fingerprint, "s3ep-")
+	require.NoError(t, err)
+	decrypted, err := decryptor.DecryptDataStream(ctx, bufio.NewReader(strings.NewReader(string(ciphertext))), encryptedDEK, nil, []byte("bucket/key"))
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	// aes-gcm-siv replaces aes-gcm only; streams stay on aes-ctr
+	cipher, err = ResolveDataCipher("aes-gcm-siv")
+	require.NoError(t, err)
+	factory.SetDataCipher(fingerprint, cipher)
+	assert.Equal(t, "aes-gcm-siv", factory.DataAlgorithm(ContentTypeWhole, fingerprint))
+	assert.Equal(t, "aes-ctr", factory.DataAlgorithm(ContentTypeMultipart, fingerprint))
+
+	_, err = factory.CreateEnvelopeEncryptorForAlgorithm("rot13", fingerprint, "s3ep-")
+	assert.Error(t, err)
+	_, err = ResolveDataCipher("des")
+	assert.ErrorContains(t, err, "unsupported data cipher")
+	auto, err := ResolveDataCipher("auto")
+	require.NoError(t, err)
+	assert.Contains(t, []DataCipher{DataCipherAES, DataCipherChaCha20}, auto)
+}
+
 func TestFactory_CreateKeyEncryptorFromConfig(t *testing.T) {
 	factory := NewFactory()
 
@@ -27,6 +27,44 @@ type KeyEncryptor interface {
 	RotateKEK(ctx context.Context) error
 }
 
+// KeyPreloader is an optional interface for KeyEncryptors whose key material
+// is fetched from a remote service. Preload fetches the key material if it is
+// not loaded yet and exercises it, so the first request does not pay for the
+// fetch. It is safe to call repeatedly to keep the key material warm.
+type KeyPreloader interface {
+	Preload(ctx context.Context) error
+}
+
+// KeyVersioner is an optional interface for KeyEncryptors whose KEK has
+// versions. Rotating such a KEK adds a version but keeps the fingerprint;
+// DEKs wrapped with older versions stay readable until they are re-wrapped.
+type KeyVersioner interface {
+	// KeyVersion returns the KEK version an encrypted DEK was wrapped with
+	KeyVersion(encryptedDEK []byte) (int, error)
+
+	// LatestKeyVersion returns the KEK version new DEKs are wrapped with
+	LatestKeyVersion(ctx context.Context) (int, error)
+
+	// RewrapDEK wraps an encrypted DEK with the latest KEK version without
+	// revealing the DEK
+	RewrapDEK(ctx context.Context, encryptedDEK []byte) ([]byte, error)
+}
+
+// KeyFallbackNotifier is an optional interface for KeyEncryptors with
+// fallback keys, such as replicas of a KMS key in other regions. The handler
+// is called for every DEK wrap or unwrap served by a fallback key.
+type KeyFallbackNotifier interface {
+	SetKeyFallbackHandler(handler func(KeyFallback))
+}
+
+// KeyFallback describes a DEK wrap or unwrap served by a fallback key
+type KeyFallback struct {
+	Operation string // encrypt, decrypt or preload
+	Primary   string // the key that failed
+	Fallback  string // the key that served the call
+	Err       error  // why the primary key failed
+}
+
 // DataEncryptor handles streaming encryption/decryption of data using Data Encryption Keys (DEK)
 // This unified interface works with io.Reader/io.Writer for both small and large data
 // For small data, use bytes.NewReader() and bytes.Buffer to wrap []byte data
@@ -56,6 +94,14 @@ type IVProvider interface {
 	GetLastIV() []byte
 }
 
+// SegmentProvider is an optional interface of DataEncryptors that seal large
+// objects in segments, to record the segment size in metadata
+type SegmentProvider interface {
+	// GetLastSegmentSize returns the plaintext segment size of the last
+	// encryption operation, 0 if it was sealed in one piece
+	GetLastSegmentSize() int
+}
+
 // EnvelopeEncryptor combines KeyEncryptor and DataEncryptor for envelope encryption patterns
 // All operations now work with streaming interfaces using io.Reader/io.Writer
 type EnvelopeEncryptor interface {
@@ -115,6 +161,18 @@ const (
 	// EncryptionTypeAESCTR uses AES-CTR envelope encryption
 	EncryptionTypeAESCTR EncryptionType = "aes-ctr"
 
+	// EncryptionTypeAESGCMSIV uses nonce-misuse-resistant AES-GCM-SIV for
+	// whole objects
+	EncryptionTypeAESGCMSIV EncryptionType = "aes-gcm-siv"
+
+	// EncryptionTypeChaCha20Poly1305 uses ChaCha20-Poly1305, the counterpart
+	// of aes-gcm for hosts without AES hardware support
+	EncryptionTypeChaCha20Poly1305 EncryptionType = "chacha20-poly1305"
+
+	// EncryptionTypeXChaCha20 uses the XChaCha20 stream cipher, the
+	// counterpart of aes-ctr for hosts without AES hardware support
+	EncryptionTypeXChaCha20 EncryptionType = "xchacha20"
+
 	// EncryptionTypeNone uses no encryption (testing only)
 	EncryptionTypeNone EncryptionType = "none"
 )
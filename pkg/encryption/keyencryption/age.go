@@ -0,0 +1,223 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// DEKs wrapped by AgeProvider are complete age files (age-encryption.org/v1)
+// with X25519 recipients, written and read with filippo.io/age, so they can be
+// opened with the age CLI and an age identity file as well.
+
+// ErrNoAgeIdentity is returned when none of the identities can open an age file
+var ErrNoAgeIdentity = errors.New("no identity matched any of the recipients")
+
+// AgeRecipient is an X25519 public key in age format ("age1...")
+type AgeRecipient struct {
+	*age.X25519Recipient
+}
+
+// ParseAgeRecipient parses an "age1..." recipient
+func ParseAgeRecipient(s string) (*AgeRecipient, error) {
+	recipient, err := age.ParseX25519Recipient(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("malformed age recipient: %w", err)
+	}
+	return &AgeRecipient{recipient}, nil
+}
+
+// AgeIdentity is an X25519 private key in age format ("AGE-SECRET-KEY-1...")
+type AgeIdentity struct {
+	*age.X25519Identity
+}
+
+// GenerateAgeIdentity creates a new random identity
+func GenerateAgeIdentity() (*AgeIdentity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate X25519 key: %w", err)
+	}
+	return &AgeIdentity{identity}, nil
+}
+
+// ParseAgeIdentity parses an "AGE-SECRET-KEY-1..." identity
+func ParseAgeIdentity(s string) (*AgeIdentity, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("malformed age identity: %w", err)
+	}
+	return &AgeIdentity{identity}, nil
+}
+
+// ParseAgeIdentities parses an age identity file as written by age-keygen:
+// one identity per line, empty lines and lines starting with "#" are ignored
+func ParseAgeIdentities(data []byte) ([]*AgeIdentity, error) {
+	parsed, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identities: %w", err)
+	}
+	identities := make([]*AgeIdentity, 0, len(parsed))
+	for _, identity := range parsed {
+		x25519, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("unsupported age identity type %T, only X25519 identities are supported", identity)
+		}
+		identities = append(identities, &AgeIdentity{x25519})
+	}
+	return identities, nil
+}
+
+// Recipient returns the public key of the identity
+func (i *AgeIdentity) Recipient() *AgeRecipient {
+	return &AgeRecipient{i.X25519Identity.Recipient()}
+}
+
+// AgeProvider implements encryption.KeyEncryptor by wrapping DEKs into age
+// files for one or more X25519 recipients. Decryption needs an identity of
+// one of the recipients; a provider with recipients only can wrap but not
+// unwrap, which is what offline recovery keys are for.
+type AgeProvider struct {
+	recipients  []*AgeRecipient
+	identities  []*AgeIdentity
+	fingerprint string
+}
+
+// NewAgeProvider creates an age key encryptor for the given recipients and
+// optional identities
+func NewAgeProvider(recipients []*AgeRecipient, identities []*AgeIdentity) (*AgeProvider, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required")
+	}
+
+	// The fingerprint does not depend on the order the recipients are listed in
+	encoded := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		encoded[i] = recipient.String()
+	}
+	sort.Strings(encoded)
+	hash := sha256.Sum256([]byte(strings.Join(encoded, "\n")))
+
+	return &AgeProvider{
+		recipients:  recipients,
+		identities:  identities,
+		fingerprint: hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// NewAgeProviderFromStrings creates an age key encryptor from "age1..."
+// recipients and "AGE-SECRET-KEY-1..." identities
+func NewAgeProviderFromStrings(recipients, identities []string) (*AgeProvider, error) {
+	parsedRecipients := make([]*AgeRecipient, 0, len(recipients))
+	for _, s := range recipients {
+		recipient, err := ParseAgeRecipient(s)
+		if err != nil {
+			return nil, err
+		}
+		parsedRecipients = append(parsedRecipients, recipient)
+	}
+	parsedIdentities := make([]*AgeIdentity, 0, len(identities))
+	for _, s := range identities {
+		identity, err := ParseAgeIdentity(s)
+		if err != nil {
+			return nil, err
+		}
+		parsedIdentities = append(parsedIdentities, identity)
+	}
+	return NewAgeProvider(parsedRecipients, parsedIdentities)
+}
+
+// EncryptDEK wraps the DEK into an age file for all recipients
+func (p *AgeProvider) EncryptDEK(_ context.Context, dek []byte) ([]byte, string, error) {
+	wrapped, err := ageEncrypt(dek, p.recipients)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt DEK with age: %w", err)
+	}
+	return wrapped, p.fingerprint, nil
+}
+
+// DecryptDEK opens an age file with the identities of the provider
+func (p *AgeProvider) DecryptDEK(_ context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	if keyID != p.fingerprint {
+		return nil, fmt.Errorf("key ID mismatch: expected %s, got %s", p.fingerprint, keyID)
+	}
+	if len(p.identities) == 0 {
+		return nil, fmt.Errorf("age provider has no identity to decrypt with")
+	}
+	dek, err := ageDecrypt(encryptedDEK, p.identities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DEK with age: %w", err)
+	}
+	return dek, nil
+}
+
+// Name returns the short unique name for this KeyEncryptor type
+func (p *AgeProvider) Name() string {
+	return "age"
+}
+
+// Fingerprint returns a SHA-256 fingerprint of the sorted recipients
+func (p *AgeProvider) Fingerprint() string {
+	return p.fingerprint
+}
+
+// RotateKEK is not implemented for age - recipients are changed in the configuration
+func (p *AgeProvider) RotateKEK(_ context.Context) error {
+	return fmt.Errorf("age key rotation is not implemented - change the recipients in the configuration")
+}
+
+// Recipients returns the recipients DEKs are wrapped for
+func (p *AgeProvider) Recipients() []*AgeRecipient {
+	return p.recipients
+}
+
+// ageEncrypt writes plaintext as an age file for the recipients
+func ageEncrypt(plaintext []byte, recipients []*AgeRecipient) ([]byte, error) {
+	ageRecipients := make([]age.Recipient, len(recipients))
+	for i, recipient := range recipients {
+		ageRecipients[i] = recipient.X25519Recipient
+	}
+
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, ageRecipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ageDecrypt opens an age file with any of the identities
+func ageDecrypt(data []byte, identities []*AgeIdentity) ([]byte, error) {
+	ageIdentities := make([]age.Identity, len(identities))
+	for i, identity := range identities {
+		ageIdentities[i] = identity.X25519Identity
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), ageIdentities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, ErrNoAgeIdentity
+		}
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+var _ encryption.KeyEncryptor = (*AgeProvider)(nil)
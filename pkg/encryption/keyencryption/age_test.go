@@ -0,0 +1,113 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Identity and recipient of the age test suite (private key 0x42 * 32)
+const (
+	testAgeIdentity  = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+	testAgeRecipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+)
+
+func TestAgeKeys_Encoding(t *testing.T) {
+	identity, err := ParseAgeIdentity(testAgeIdentity)
+	require.NoError(t, err)
+	assert.Equal(t, testAgeIdentity, identity.String())
+	assert.Equal(t, testAgeRecipient, identity.Recipient().String())
+
+	recipient, err := ParseAgeRecipient(testAgeRecipient)
+	require.NoError(t, err)
+	assert.Equal(t, testAgeRecipient, recipient.String())
+
+	_, err = ParseAgeRecipient(testAgeIdentity)
+	assert.ErrorContains(t, err, "malformed age recipient")
+	_, err = ParseAgeIdentity(testAgeRecipient)
+	assert.ErrorContains(t, err, "malformed age identity")
+
+	identities, err := ParseAgeIdentities([]byte("# created: 2026-01-01\n# public key: " + testAgeRecipient + "\n" + testAgeIdentity + "\n\n"))
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+
+	_, err = ParseAgeIdentities([]byte("# nothing here\n"))
+	assert.Error(t, err)
+}
+
+func TestAgeProvider_WrapAndUnwrap(t *testing.T) {
+	identity, err := ParseAgeIdentity(testAgeIdentity)
+	require.NoError(t, err)
+	other, err := GenerateAgeIdentity()
+	require.NoError(t, err)
+
+	provider, err := NewAgeProvider([]*AgeRecipient{other.Recipient(), identity.Recipient()}, []*AgeIdentity{identity})
+	require.NoError(t, err)
+	assert.Equal(t, "age", provider.Name())
+
+	ctx := context.Background()
+	dek := bytes.Repeat([]byte{7}, 32)
+	wrapped, keyID, err := provider.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	assert.Equal(t, provider.Fingerprint(), keyID)
+	assert.True(t, strings.HasPrefix(string(wrapped), "age-encryption.org/v1\n-> X25519 "))
+	assert.Equal(t, 2, strings.Count(string(wrapped), "-> X25519 "))
+
+	unwrapped, err := provider.DecryptDEK(ctx, wrapped, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	// Each recipient can open the file on its own
+	unwrapped, err = ageDecrypt(wrapped, []*AgeIdentity{other})
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	stranger, err := GenerateAgeIdentity()
+	require.NoError(t, err)
+	_, err = ageDecrypt(wrapped, []*AgeIdentity{stranger})
+	assert.ErrorIs(t, err, ErrNoAgeIdentity)
+
+	_, err = provider.DecryptDEK(ctx, wrapped, "other-fingerprint")
+	assert.ErrorContains(t, err, "key ID mismatch")
+
+	// The fingerprint does not depend on the order of the recipients
+	reordered, err := NewAgeProvider([]*AgeRecipient{identity.Recipient(), other.Recipient()}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, provider.Fingerprint(), reordered.Fingerprint())
+	_, err = reordered.DecryptDEK(ctx, wrapped, keyID)
+	assert.ErrorContains(t, err, "no identity")
+
+	_, err = NewAgeProvider(nil, nil)
+	assert.Error(t, err)
+}
+
+func TestAgeDecrypt_DetectsTampering(t *testing.T) {
+	identity, err := ParseAgeIdentity(testAgeIdentity)
+	require.NoError(t, err)
+	wrapped, err := ageEncrypt(bytes.Repeat([]byte{7}, 32), []*AgeRecipient{identity.Recipient()})
+	require.NoError(t, err)
+
+	headerEnd := bytes.Index(wrapped, []byte("\n---")) + 1
+	tests := []struct {
+		name   string
+		tamper func(data []byte) []byte
+	}{
+		{name: "version", tamper: func(data []byte) []byte { return bytes.Replace(data, []byte("v1"), []byte("v2"), 1) }},
+		{name: "added stanza", tamper: func(data []byte) []byte {
+			return append(append(append([]byte{}, data[:headerEnd]...), "-> other\n\n"...), data[headerEnd:]...)
+		}},
+		{name: "payload", tamper: func(data []byte) []byte { data[len(data)-1] ^= 1; return data }},
+		{name: "truncated payload", tamper: func(data []byte) []byte { return data[:len(data)-1] }},
+		{name: "truncated header", tamper: func(data []byte) []byte { return data[:headerEnd] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ageDecrypt(tt.tamper(append([]byte{}, wrapped...)), []*AgeIdentity{identity})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// recoveryEnvelopeMagic starts an encrypted DEK that carries a recovery copy.
+// The envelope is magic | uint32 length of the primary encrypted DEK |
+// primary encrypted DEK | age file of the DEK for the recovery recipients.
+var recoveryEnvelopeMagic = []byte("s3ep-rcv1")
+
+// RecoveryEncryptor wraps every DEK with the primary KeyEncryptor and
+// additionally for the age recipients of a recovery provider, so an offline
+// recovery key can decrypt the data even if the primary key is lost.
+//
+// Name and Fingerprint are those of the primary, so objects keep pointing at
+// the primary provider. Encrypted DEKs without a recovery copy are passed to
+// the primary unchanged, and envelopes are unwrapped without a recovery
+// provider too, so recipients can be added and removed at any time.
+type RecoveryEncryptor struct {
+	encryption.KeyEncryptor
+	recovery *AgeProvider
+}
+
+// recoveryPreloader keeps encryption.KeyPreloader visible through the wrapper
+type recoveryPreloader struct {
+	*RecoveryEncryptor
+	preloader encryption.KeyPreloader
+}
+
+func (p *recoveryPreloader) Preload(ctx context.Context) error {
+	return p.preloader.Preload(ctx)
+}
+
+// NewRecoveryEncryptor wraps primary with the recovery recipients of
+// recovery, which may be nil to only unwrap existing envelopes
+func NewRecoveryEncryptor(primary encryption.KeyEncryptor, recovery *AgeProvider) encryption.KeyEncryptor {
+	wrapped := &RecoveryEncryptor{KeyEncryptor: primary, recovery: recovery}
+	if preloader, ok := primary.(encryption.KeyPreloader); ok {
+		return &recoveryPreloader{RecoveryEncryptor: wrapped, preloader: preloader}
+	}
+	return wrapped
+}
+
+// EncryptDEK wraps the DEK with the primary and adds the recovery copy
+func (r *RecoveryEncryptor) EncryptDEK(ctx context.Context, dek []byte) ([]byte, string, error) {
+	encryptedDEK, keyID, err := r.KeyEncryptor.EncryptDEK(ctx, dek)
+	if err != nil || r.recovery == nil {
+		return encryptedDEK, keyID, err
+	}
+
+	recoveryDEK, _, err := r.recovery.EncryptDEK(ctx, dek)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to add recovery copy of DEK: %w", err)
+	}
+
+	return joinRecoveryEnvelope(encryptedDEK, recoveryDEK), keyID, nil
+}
+
+func joinRecoveryEnvelope(primary, recovery []byte) []byte {
+	envelope := make([]byte, 0, len(recoveryEnvelopeMagic)+4+len(primary)+len(recovery))
+	envelope = append(envelope, recoveryEnvelopeMagic...)
+	envelope = binary.BigEndian.AppendUint32(envelope, uint32(len(primary))) // #nosec G115 - encrypted DEKs are a few hundred bytes
+	envelope = append(envelope, primary...)
+	envelope = append(envelope, recovery...)
+	return envelope
+}
+
+// DecryptDEK unwraps the primary part of an envelope with the primary
+func (r *RecoveryEncryptor) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	primary, _, err := SplitRecoveryEnvelope(encryptedDEK)
+	if err != nil {
+		return nil, err
+	}
+	return r.KeyEncryptor.DecryptDEK(ctx, primary, keyID)
+}
+
+// Primary returns the wrapped primary KeyEncryptor
+func (r *RecoveryEncryptor) Primary() encryption.KeyEncryptor {
+	return r.KeyEncryptor
+}
+
+// SplitRecoveryEnvelope returns the encrypted DEK of the primary provider and
+// the age recovery copy of an encrypted DEK. An encrypted DEK without a
+// recovery copy is returned as the primary part with a nil recovery copy.
+func SplitRecoveryEnvelope(encryptedDEK []byte) (primary, recovery []byte, err error) {
+	if !bytes.HasPrefix(encryptedDEK, recoveryEnvelopeMagic) {
+		return encryptedDEK, nil, nil
+	}
+
+	rest := encryptedDEK[len(recoveryEnvelopeMagic):]
+	if len(rest) < 4 {
+		return nil, nil, fmt.Errorf("truncated recovery envelope")
+	}
+	length := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if uint64(length) > uint64(len(rest)) {
+		return nil, nil, fmt.Errorf("truncated recovery envelope")
+	}
+	return rest[:length], rest[length:], nil
+}
+
+// ReplaceRecoveryPrimary returns encryptedDEK with its primary part replaced
+// by primary, e.g. after the primary provider re-wrapped it. A recovery copy
+// is kept.
+func ReplaceRecoveryPrimary(encryptedDEK, primary []byte) ([]byte, error) {
+	_, recovery, err := SplitRecoveryEnvelope(encryptedDEK)
+	if err != nil {
+		return nil, err
+	}
+	if recovery == nil {
+		return primary, nil
+	}
+	return joinRecoveryEnvelope(primary, recovery), nil
+}
+
+// RecoverDEK decrypts the recovery copy of an encrypted DEK with age
+// identities, without the primary provider
+func RecoverDEK(encryptedDEK []byte, identities []*AgeIdentity) ([]byte, error) {
+	_, recovery, err := SplitRecoveryEnvelope(encryptedDEK)
+	if err != nil {
+		return nil, err
+	}
+	if recovery == nil {
+		return nil, fmt.Errorf("encrypted DEK has no recovery copy")
+	}
+	dek, err := ageDecrypt(recovery, identities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt recovery copy of DEK: %w", err)
+	}
+	return dek, nil
+}
@@ -0,0 +1,92 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+func TestRecoveryEncryptor_AddsRecoveryCopy(t *testing.T) {
+	kek := bytes.Repeat([]byte{3}, 32)
+	primary, err := NewAESKeyEncryptor(kek)
+	require.NoError(t, err)
+	identity, err := ParseAgeIdentity(testAgeIdentity)
+	require.NoError(t, err)
+	recovery, err := NewAgeProvider([]*AgeRecipient{identity.Recipient()}, nil)
+	require.NoError(t, err)
+
+	encryptor := NewRecoveryEncryptor(primary, recovery)
+	assert.Equal(t, primary.Name(), encryptor.Name())
+	assert.Equal(t, primary.Fingerprint(), encryptor.Fingerprint())
+
+	ctx := context.Background()
+	dek := bytes.Repeat([]byte{9}, 32)
+	encryptedDEK, keyID, err := encryptor.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	assert.Equal(t, primary.Fingerprint(), keyID)
+
+	primaryPart, recoveryPart, err := SplitRecoveryEnvelope(encryptedDEK)
+	require.NoError(t, err)
+	require.NotNil(t, recoveryPart)
+
+	// The primary part is a plain encrypted DEK of the primary provider
+	decrypted, err := primary.DecryptDEK(ctx, primaryPart, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+
+	decrypted, err = encryptor.DecryptDEK(ctx, encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+
+	// The recovery identity alone recovers the DEK
+	recovered, err := RecoverDEK(encryptedDEK, []*AgeIdentity{identity})
+	require.NoError(t, err)
+	assert.Equal(t, dek, recovered)
+
+	// Without recipients the envelope is still unwrapped
+	decrypted, err = NewRecoveryEncryptor(primary, nil).DecryptDEK(ctx, encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+}
+
+func TestRecoveryEncryptor_WithoutRecipients(t *testing.T) {
+	primary, err := NewAESKeyEncryptor(bytes.Repeat([]byte{3}, 32))
+	require.NoError(t, err)
+	encryptor := NewRecoveryEncryptor(primary, nil)
+
+	ctx := context.Background()
+	dek := bytes.Repeat([]byte{9}, 32)
+	encryptedDEK, keyID, err := encryptor.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+
+	// Plain encrypted DEKs, readable by the primary alone
+	decrypted, err := primary.DecryptDEK(ctx, encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+
+	_, err = RecoverDEK(encryptedDEK, nil)
+	assert.ErrorContains(t, err, "no recovery copy")
+}
+
+func TestRecoveryEncryptor_KeepsPreloader(t *testing.T) {
+	primary, err := NewAESKeyEncryptor(bytes.Repeat([]byte{3}, 32))
+	require.NoError(t, err)
+	_, ok := NewRecoveryEncryptor(primary, nil).(encryption.KeyPreloader)
+	assert.False(t, ok)
+
+	tink := &TinkProvider{}
+	_, ok = NewRecoveryEncryptor(tink, nil).(encryption.KeyPreloader)
+	assert.True(t, ok)
+}
+
+func TestSplitRecoveryEnvelope_Truncated(t *testing.T) {
+	_, _, err := SplitRecoveryEnvelope(append([]byte{}, recoveryEnvelopeMagic...))
+	assert.Error(t, err)
+	_, _, err = SplitRecoveryEnvelope(append(append([]byte{}, recoveryEnvelopeMagic...), 0, 0, 1, 0, 1))
+	assert.Error(t, err)
+}
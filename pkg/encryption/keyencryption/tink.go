@@ -1,14 +1,23 @@
 package keyencryption
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/tink/go/aead"
 	"github.com/google/tink/go/keyset"
 	"github.com/google/tink/go/tink"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
 )
 
 // TinkConfig holds configuration specific to Tink encryption
@@ -16,8 +25,16 @@ type TinkConfig struct {
 	KEKUri          string `json:"kek_uri" mapstructure:"kek_uri"`                   // Key Encryption Key URI for KMS
 	CredentialsPath string `json:"credentials_path" mapstructure:"credentials_path"` // Path to KMS credentials file
 	KeyTemplate     string `json:"key_template" mapstructure:"key_template"`         // Optional: Tink key template (defaults to AES256_GCM)
+
+	// Optional: replicas of the KEK in other regions, e.g. the ARNs or
+	// aliases of an AWS KMS multi-Region key. They are tried in order when
+	// kek_uri is unavailable and must decrypt what kek_uri encrypted.
+	FallbackKEKUris []string `json:"fallback_kek_uris" mapstructure:"fallback_kek_uris"`
 }
 
+// tinkRegionCooldown is how long a KEK that failed is tried after the others
+const tinkRegionCooldown = 30 * time.Second
+
 // Validate validates the Tink configuration
 func (c *TinkConfig) Validate() error {
 	if c.KEKUri == "" {
@@ -34,22 +51,38 @@ func (c *TinkConfig) Validate() error {
 		}
 	}
 
+	scheme, _, _ := strings.Cut(c.KEKUri, "://")
+	for i, uri := range c.FallbackKEKUris {
+		if uri == "" {
+			return fmt.Errorf("fallback_kek_uris[%d] is empty", i)
+		}
+		if uri == c.KEKUri || slices.Contains(c.FallbackKEKUris[:i], uri) {
+			return fmt.Errorf("fallback_kek_uris[%d] duplicates another KEK URI: %s", i, uri)
+		}
+		// A replica in another region is held by the same KMS
+		if fallbackScheme, _, _ := strings.Cut(uri, "://"); fallbackScheme != scheme {
+			return fmt.Errorf("fallback_kek_uris[%d] must use the KMS of kek_uri (%s://), got %s", i, scheme, uri)
+		}
+	}
+
 	return nil
 }
 
-// NewTinkProviderFromConfig creates a new Tink provider from config
+// NewTinkProviderFromConfig creates a new Tink provider from config. The KEK
+// handle is loaded on first use, or ahead of time by Preload.
 func NewTinkProviderFromConfig(config *TinkConfig) (*TinkProvider, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Load KEK handle (this would typically come from a KMS)
-	kekHandle, err := loadKEKHandle(config.KEKUri, config.CredentialsPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load KEK handle: %w", err)
+	keks := []*tinkKEK{{uri: config.KEKUri}}
+	for _, uri := range config.FallbackKEKUris {
+		keks = append(keks, &tinkKEK{uri: uri})
 	}
-
-	return NewTinkProvider(kekHandle, config.KEKUri)
+	return &TinkProvider{
+		keks:            keks,
+		credentialsPath: config.CredentialsPath,
+	}, nil
 }
 
 // loadKEKHandle loads the Key Encryption Key handle from the specified URI
@@ -70,11 +103,23 @@ func loadKEKHandle(_ string, _ string) (*keyset.Handle, error) {
 	return handle, nil
 }
 
-// TinkProvider implements envelope encryption using Google's Tink library
+// TinkProvider implements envelope encryption using Google's Tink library.
+// Its first KEK is the primary one; the others are replicas in other regions
+// that serve DEK wraps and unwraps while the primary is unavailable.
 type TinkProvider struct {
-	kekHandle *keyset.Handle
-	kekAEAD   tink.AEAD
-	kekURI    string // Store the KEK URI for fingerprinting
+	keks            []*tinkKEK
+	credentialsPath string
+
+	mu         sync.Mutex // guards lazy loading of the KEK handles and the state of the KEKs
+	onFallback func(encryption.KeyFallback)
+}
+
+// tinkKEK is one regional copy of the KEK
+type tinkKEK struct {
+	uri      string
+	kekAEAD  tink.AEAD // nil until first use
+	failedAt time.Time // last failure, zero if the last call succeeded
+	lastErr  error
 }
 
 // NewTinkProvider creates a new Tink encryption provider
@@ -90,35 +135,152 @@ func NewTinkProvider(kekHandle *keyset.Handle, kekURI string) (*TinkProvider, er
 	}
 
 	return &TinkProvider{
-		kekHandle: kekHandle,
-		kekAEAD:   kekAEAD,
-		kekURI:    kekURI,
+		keks: []*tinkKEK{{uri: kekURI, kekAEAD: kekAEAD}},
 	}, nil
 }
 
+// SetKeyFallbackHandler sets the handler that is called when a fallback KEK
+// served a call. SetKeyFallbackHandler implements encryption.KeyFallbackNotifier.
+func (p *TinkProvider) SetKeyFallbackHandler(handler func(encryption.KeyFallback)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onFallback = handler
+}
+
+// aead returns the AEAD of kek, loading its handle on first use
+func (p *TinkProvider) aead(kek *tinkKEK) (tink.AEAD, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if kek.kekAEAD != nil {
+		return kek.kekAEAD, nil
+	}
+
+	kekHandle, err := loadKEKHandle(kek.uri, p.credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load KEK handle: %w", err)
+	}
+	kekAEAD, err := aead.New(kekHandle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KEK AEAD: %w", err)
+	}
+
+	kek.kekAEAD = kekAEAD
+	return kekAEAD, nil
+}
+
+// withKEK calls call with the AEAD of the first KEK that succeeds. KEKs are
+// tried in order, except that a KEK which failed within tinkRegionCooldown
+// is tried after the others, so an unavailable region does not slow down
+// every call.
+func (p *TinkProvider) withKEK(ctx context.Context, operation string, call func(tink.AEAD) error) error {
+	p.mu.Lock()
+	order := slices.Clone(p.keks)
+	now := time.Now()
+	cooling := func(kek *tinkKEK) bool { return !kek.failedAt.IsZero() && now.Sub(kek.failedAt) < tinkRegionCooldown }
+	slices.SortStableFunc(order, func(a, b *tinkKEK) int {
+		switch {
+		case cooling(a) == cooling(b):
+			return 0
+		case cooling(a):
+			return 1
+		default:
+			return -1
+		}
+	})
+	p.mu.Unlock()
+
+	var errs []error
+	for _, kek := range order {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		kekAEAD, err := p.aead(kek)
+		if err == nil {
+			err = call(kekAEAD)
+		}
+
+		p.mu.Lock()
+		if err != nil {
+			kek.failedAt, kek.lastErr = time.Now(), err
+			p.mu.Unlock()
+			errs = append(errs, err)
+			continue
+		}
+		kek.failedAt, kek.lastErr = time.Time{}, nil
+		primary := p.keks[0]
+		fallback := encryption.KeyFallback{Operation: operation, Primary: primary.uri, Fallback: kek.uri, Err: primary.lastErr}
+		onFallback := p.onFallback
+		p.mu.Unlock()
+
+		if kek != primary && onFallback != nil {
+			onFallback(fallback)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// Preload loads the KEK handle if needed and round-trips a random probe
+// through it, which keeps KMS connections and credentials warm
+func (p *TinkProvider) Preload(ctx context.Context) error {
+	probe := make([]byte, 32)
+	if _, err := rand.Read(probe); err != nil {
+		return fmt.Errorf("failed to generate probe: %w", err)
+	}
+	return p.withKEK(ctx, "preload", func(kekAEAD tink.AEAD) error {
+		ciphertext, err := kekAEAD.Encrypt(probe, nil)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt probe with Tink KEK: %w", err)
+		}
+		plaintext, err := kekAEAD.Decrypt(ciphertext, nil)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt probe with Tink KEK: %w", err)
+		}
+		if !bytes.Equal(probe, plaintext) {
+			return fmt.Errorf("tink KEK round-trip returned different data")
+		}
+		return nil
+	})
+}
+
 // EncryptDEK encrypts a Data Encryption Key with the Key Encryption Key using Tink
-func (p *TinkProvider) EncryptDEK(_ context.Context, dek []byte) ([]byte, string, error) {
+func (p *TinkProvider) EncryptDEK(ctx context.Context, dek []byte) ([]byte, string, error) {
 	// Create a DEK handle from the raw DEK bytes
 	// For simplicity, we'll use the raw bytes directly with our KEK
-	encryptedDEK, err := p.kekAEAD.Encrypt(dek, nil)
+	var encryptedDEK []byte
+	err := p.withKEK(ctx, "encrypt", func(kekAEAD tink.AEAD) error {
+		var err error
+		if encryptedDEK, err = kekAEAD.Encrypt(dek, nil); err != nil {
+			return fmt.Errorf("failed to encrypt DEK with Tink KEK: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to encrypt DEK with Tink KEK: %w", err)
+		return nil, "", err
 	}
 
 	return encryptedDEK, p.Fingerprint(), nil
 }
 
 // DecryptDEK decrypts a Data Encryption Key using the Key Encryption Key with Tink
-func (p *TinkProvider) DecryptDEK(_ context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+func (p *TinkProvider) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
 	// Verify the key ID matches our fingerprint
 	if keyID != p.Fingerprint() {
 		return nil, fmt.Errorf("key ID mismatch: expected %s, got %s", p.Fingerprint(), keyID)
 	}
 
 	// Decrypt the DEK using our KEK
-	dek, err := p.kekAEAD.Decrypt(encryptedDEK, nil)
+	var dek []byte
+	err := p.withKEK(ctx, "decrypt", func(kekAEAD tink.AEAD) error {
+		var err error
+		if dek, err = kekAEAD.Decrypt(encryptedDEK, nil); err != nil {
+			return fmt.Errorf("failed to decrypt DEK with Tink KEK: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt DEK with Tink KEK: %w", err)
+		return nil, err
 	}
 
 	return dek, nil
@@ -132,9 +294,10 @@ func (p *TinkProvider) Name() string {
 // Fingerprint returns a SHA-256 fingerprint of the Tink KEK
 // This allows identification of the correct KEK provider during decryption
 func (p *TinkProvider) Fingerprint() string {
-	// Use the KEK URI as the basis for the fingerprint
+	// Use the URI of the primary KEK as the basis for the fingerprint, so
+	// adding fallback KEKs does not change it
 	// This is safe as it doesn't expose the actual key material
-	hash := sha256.Sum256([]byte(p.kekURI))
+	hash := sha256.Sum256([]byte(p.keks[0].uri))
 	return hex.EncodeToString(hash[:])
 }
 
@@ -142,3 +305,8 @@ func (p *TinkProvider) Fingerprint() string {
 func (p *TinkProvider) RotateKEK(_ context.Context) error {
 	return fmt.Errorf("KEK rotation not implemented")
 }
+
+var (
+	_ encryption.KeyPreloader        = (*TinkProvider)(nil)
+	_ encryption.KeyFallbackNotifier = (*TinkProvider)(nil)
+)
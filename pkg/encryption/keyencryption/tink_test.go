@@ -0,0 +1,120 @@
+package keyencryption
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+func TestTinkProvider_LoadsKEKLazily(t *testing.T) {
+	provider, err := NewTinkProviderFromConfig(&TinkConfig{KEKUri: "gcp-kms://projects/p/locations/l/keyRings/r/cryptoKeys/k"})
+	require.NoError(t, err)
+	assert.Nil(t, provider.keks[0].kekAEAD, "KEK must not be loaded before first use")
+
+	dek := make([]byte, 32)
+	encryptedDEK, keyID, err := provider.EncryptDEK(context.Background(), dek)
+	require.NoError(t, err)
+	assert.NotNil(t, provider.keks[0].kekAEAD)
+
+	decrypted, err := provider.DecryptDEK(context.Background(), encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+}
+
+func TestTinkProvider_Preload(t *testing.T) {
+	provider, err := NewTinkProviderFromConfig(&TinkConfig{KEKUri: "gcp-kms://projects/p/locations/l/keyRings/r/cryptoKeys/k"})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.Preload(context.Background()))
+	loaded := provider.keks[0].kekAEAD
+	require.NotNil(t, loaded)
+
+	encryptedDEK, keyID, err := provider.EncryptDEK(context.Background(), make([]byte, 32))
+	require.NoError(t, err)
+
+	// Refreshing must keep the loaded key, or existing DEKs become unreadable
+	require.NoError(t, provider.Preload(context.Background()))
+	assert.Same(t, loaded, provider.keks[0].kekAEAD)
+	_, err = provider.DecryptDEK(context.Background(), encryptedDEK, keyID)
+	assert.NoError(t, err)
+}
+
+// unavailableAEAD fails like a KMS key in a region that cannot be reached
+type unavailableAEAD struct{ calls int }
+
+func (u *unavailableAEAD) Encrypt(_, _ []byte) ([]byte, error) {
+	u.calls++
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func (u *unavailableAEAD) Decrypt(_, _ []byte) ([]byte, error) {
+	u.calls++
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func TestTinkProvider_FailsOverToFallbackKEK(t *testing.T) {
+	handle, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	require.NoError(t, err)
+	replica, err := aead.New(handle)
+	require.NoError(t, err)
+
+	const primaryURI = "aws-kms://arn:aws:kms:eu-west-1:111122223333:key/mrk-1234"
+	const fallbackURI = "aws-kms://arn:aws:kms:eu-central-1:111122223333:key/mrk-1234"
+	provider, err := NewTinkProviderFromConfig(&TinkConfig{KEKUri: primaryURI, FallbackKEKUris: []string{fallbackURI}})
+	require.NoError(t, err)
+	provider.keks[0].kekAEAD = replica
+	provider.keks[1].kekAEAD = replica
+
+	var fallbacks []encryption.KeyFallback
+	provider.SetKeyFallbackHandler(func(fallback encryption.KeyFallback) { fallbacks = append(fallbacks, fallback) })
+
+	ctx := context.Background()
+	dek := make([]byte, 32)
+	encryptedDEK, keyID, err := provider.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	assert.Empty(t, fallbacks, "the primary KEK serves calls while it is available")
+
+	// The primary region goes down
+	outage := &unavailableAEAD{}
+	provider.keks[0].kekAEAD = outage
+	decrypted, err := provider.DecryptDEK(ctx, encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+	require.Len(t, fallbacks, 1)
+	assert.Equal(t, "decrypt", fallbacks[0].Operation)
+	assert.Equal(t, primaryURI, fallbacks[0].Primary)
+	assert.Equal(t, fallbackURI, fallbacks[0].Fallback)
+	assert.ErrorContains(t, fallbacks[0].Err, "connection refused")
+
+	// While it cools down, the failed region is not tried first
+	_, keyID2, err := provider.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, keyID2, "the fingerprint names the primary KEK")
+	assert.Equal(t, 1, outage.calls)
+	assert.Len(t, fallbacks, 2)
+
+	// Without a working region the call fails with every error
+	provider.keks[1].kekAEAD = &unavailableAEAD{}
+	_, _, err = provider.EncryptDEK(ctx, dek)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestTinkConfig_ValidateFallbackKEKUris(t *testing.T) {
+	const primaryURI = "aws-kms://arn:aws:kms:eu-west-1:111122223333:alias/s3-proxy"
+	config := &TinkConfig{KEKUri: primaryURI, FallbackKEKUris: []string{"aws-kms://arn:aws:kms:us-east-1:111122223333:alias/s3-proxy"}}
+	assert.NoError(t, config.Validate())
+
+	config.FallbackKEKUris = []string{primaryURI}
+	assert.ErrorContains(t, config.Validate(), "duplicates")
+	config.FallbackKEKUris = []string{""}
+	assert.ErrorContains(t, config.Validate(), "is empty")
+	config.FallbackKEKUris = []string{"gcp-kms://projects/p/locations/l/keyRings/r/cryptoKeys/k"}
+	assert.ErrorContains(t, config.Validate(), "must use the KMS of kek_uri")
+}
@@ -0,0 +1,520 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEKs wrapped by VaultTransitProvider are the ciphertexts of the Transit
+// secrets engine ("vault:v<version>:<base64>"), so they carry the version of
+// the Transit key they were wrapped with. Rotating the key in Vault adds a
+// version; older DEKs stay readable and are moved to the latest version with
+// RewrapDEK, which never takes the DEK out of Vault.
+
+// Vault authentication methods
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+)
+
+const (
+	defaultVaultTransitMount     = "transit"
+	defaultVaultTimeout          = 10 * time.Second
+	defaultKubernetesTokenPath   = "/var/run/secrets/kubernetes.io/serviceaccount/token" // #nosec G101 - a file path, not a credential
+	vaultLatestVersionCacheTTL   = time.Minute
+	vaultMaxResponseSize         = 1 << 20
+	vaultCiphertextPrefix        = "vault:v"
+	vaultTokenRenewalFraction    = 2.0 / 3.0
+	vaultTokenMinRenewalInterval = 5 * time.Second
+)
+
+// VaultTransitConfig configures a KEK held by the Transit secrets engine of
+// HashiCorp Vault
+type VaultTransitConfig struct {
+	Address    string `json:"address" mapstructure:"address"`           // Vault address, e.g. https://vault.example.com:8200
+	MountPath  string `json:"mount_path" mapstructure:"mount_path"`     // Mount path of the Transit engine (default: transit)
+	KeyName    string `json:"key_name" mapstructure:"key_name"`         // Name of the Transit key
+	Namespace  string `json:"namespace" mapstructure:"namespace"`       // Vault Enterprise namespace (optional)
+	CACertFile string `json:"ca_cert_file" mapstructure:"ca_cert_file"` // PEM CA bundle for the Vault TLS certificate (optional)
+	Timeout    int    `json:"timeout" mapstructure:"timeout"`           // Timeout of Vault requests in seconds (default: 10)
+
+	AuthMethod string `json:"auth_method" mapstructure:"auth_method"` // token, approle or kubernetes (default: token)
+	AuthMount  string `json:"auth_mount" mapstructure:"auth_mount"`   // Mount path of the auth method (default: approle or kubernetes)
+
+	Token               string `json:"token" mapstructure:"token"`                                 // token auth
+	RoleID              string `json:"role_id" mapstructure:"role_id"`                             // approle auth
+	SecretID            string `json:"secret_id" mapstructure:"secret_id"`                         // approle auth
+	KubernetesRole      string `json:"kubernetes_role" mapstructure:"kubernetes_role"`             // kubernetes auth
+	KubernetesTokenPath string `json:"kubernetes_token_path" mapstructure:"kubernetes_token_path"` // kubernetes auth (default: the service account token)
+}
+
+// Validate validates the Vault Transit configuration
+func (c *VaultTransitConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required for the vault-transit provider")
+	}
+	if parsed, err := url.Parse(c.Address); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("address must be an absolute URL, got %q", c.Address)
+	}
+	if c.KeyName == "" {
+		return fmt.Errorf("key_name is required for the vault-transit provider")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %d", c.Timeout)
+	}
+
+	switch c.AuthMethod {
+	case "", VaultAuthToken:
+		if c.Token == "" {
+			return fmt.Errorf("token is required for vault token auth")
+		}
+	case VaultAuthAppRole:
+		if c.RoleID == "" || c.SecretID == "" {
+			return fmt.Errorf("role_id and secret_id are required for vault approle auth")
+		}
+	case VaultAuthKubernetes:
+		if c.KubernetesRole == "" {
+			return fmt.Errorf("kubernetes_role is required for vault kubernetes auth")
+		}
+	default:
+		return fmt.Errorf("unsupported auth_method %q (supported: token, approle, kubernetes)", c.AuthMethod)
+	}
+	return nil
+}
+
+// VaultError is an error response of the Vault API
+type VaultError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *VaultError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("vault returned status %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+// HTTPStatusCode returns the HTTP status of the response
+func (e *VaultError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// VaultTransitProvider implements encryption.KeyEncryptor with a key of the
+// Vault Transit secrets engine. The Vault token is obtained on first use, or
+// by Preload, and renewed before its lease runs out; login based methods log
+// in again when it cannot be renewed.
+type VaultTransitProvider struct {
+	config      VaultTransitConfig
+	client      *http.Client
+	fingerprint string
+
+	mutex         sync.Mutex
+	token         string
+	renewable     bool
+	renewAt       time.Time // zero if the token does not expire
+	expiresAt     time.Time
+	latestVersion int
+	latestAt      time.Time
+}
+
+// NewVaultTransitProvider creates a Vault Transit key encryptor. It does not
+// contact Vault.
+func NewVaultTransitProvider(config VaultTransitConfig) (*VaultTransitProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.Address = strings.TrimRight(config.Address, "/")
+	config.MountPath = strings.Trim(config.MountPath, "/")
+	if config.MountPath == "" {
+		config.MountPath = defaultVaultTransitMount
+	}
+	if config.AuthMethod == "" {
+		config.AuthMethod = VaultAuthToken
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = config.AuthMethod
+	}
+	config.AuthMount = strings.Trim(config.AuthMount, "/")
+	if config.KubernetesTokenPath == "" {
+		config.KubernetesTokenPath = defaultKubernetesTokenPath
+	}
+	timeout := defaultVaultTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_cert_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert_file contains no PEM certificates")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	// The fingerprint names the key, not a version or a Vault node, so it
+	// survives key rotation and Vault address changes
+	hash := sha256.Sum256([]byte("vault-transit:" + strings.Trim(config.Namespace, "/") + "/" + config.MountPath + "/" + config.KeyName))
+
+	return &VaultTransitProvider{
+		config:      config,
+		client:      &http.Client{Timeout: timeout, Transport: transport},
+		fingerprint: hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// EncryptDEK wraps the DEK with the latest version of the Transit key
+func (p *VaultTransitProvider) EncryptDEK(ctx context.Context, dek []byte) ([]byte, string, error) {
+	var response struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	request := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}
+	if err := p.transit(ctx, http.MethodPost, "encrypt", request, &response); err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt DEK with vault transit: %w", err)
+	}
+	if version, err := vaultKeyVersion(response.Ciphertext); err == nil {
+		p.noteVersion(version)
+	}
+	return []byte(response.Ciphertext), p.fingerprint, nil
+}
+
+// DecryptDEK unwraps a DEK wrapped with any version of the Transit key
+func (p *VaultTransitProvider) DecryptDEK(ctx context.Context, encryptedDEK []byte, keyID string) ([]byte, error) {
+	if keyID != "" && keyID != p.fingerprint {
+		return nil, fmt.Errorf("key ID mismatch: expected %s, got %s", p.fingerprint, keyID)
+	}
+	if _, err := vaultKeyVersion(string(encryptedDEK)); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	request := map[string]string{"ciphertext": string(encryptedDEK)}
+	if err := p.transit(ctx, http.MethodPost, "decrypt", request, &response); err != nil {
+		return nil, fmt.Errorf("failed to decrypt DEK with vault transit: %w", err)
+	}
+	dek, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault transit returned a malformed plaintext: %w", err)
+	}
+	return dek, nil
+}
+
+// Name returns the provider type
+func (p *VaultTransitProvider) Name() string {
+	return "vault-transit"
+}
+
+// Fingerprint identifies the Transit key
+func (p *VaultTransitProvider) Fingerprint() string {
+	return p.fingerprint
+}
+
+// RotateKEK adds a new version to the Transit key. New DEKs are wrapped with
+// it at once, existing ones once they are re-wrapped.
+func (p *VaultTransitProvider) RotateKEK(ctx context.Context) error {
+	if err := p.do(ctx, http.MethodPost, p.transitPath("keys")+"/rotate", nil, nil, true); err != nil {
+		return fmt.Errorf("failed to rotate vault transit key: %w", err)
+	}
+	p.mutex.Lock()
+	p.latestAt = time.Time{}
+	p.mutex.Unlock()
+	return nil
+}
+
+// Preload logs in to Vault and reads the Transit key, so the first request
+// does not pay for either. Preload implements encryption.KeyPreloader.
+func (p *VaultTransitProvider) Preload(ctx context.Context) error {
+	p.mutex.Lock()
+	p.latestAt = time.Time{}
+	p.mutex.Unlock()
+	_, err := p.LatestKeyVersion(ctx)
+	return err
+}
+
+// KeyVersion returns the Transit key version an encrypted DEK was wrapped
+// with. KeyVersion implements encryption.KeyVersioner.
+func (p *VaultTransitProvider) KeyVersion(encryptedDEK []byte) (int, error) {
+	return vaultKeyVersion(string(encryptedDEK))
+}
+
+// LatestKeyVersion returns the version new DEKs are wrapped with. It is
+// cached for a minute. LatestKeyVersion implements encryption.KeyVersioner.
+func (p *VaultTransitProvider) LatestKeyVersion(ctx context.Context) (int, error) {
+	p.mutex.Lock()
+	if !p.latestAt.IsZero() && time.Since(p.latestAt) < vaultLatestVersionCacheTTL {
+		version := p.latestVersion
+		p.mutex.Unlock()
+		return version, nil
+	}
+	p.mutex.Unlock()
+
+	var response struct {
+		LatestVersion int `json:"latest_version"`
+	}
+	if err := p.transit(ctx, http.MethodGet, "keys", nil, &response); err != nil {
+		return 0, fmt.Errorf("failed to read vault transit key: %w", err)
+	}
+
+	p.mutex.Lock()
+	p.latestVersion = response.LatestVersion
+	p.latestAt = time.Now()
+	p.mutex.Unlock()
+	return response.LatestVersion, nil
+}
+
+// RewrapDEK wraps an encrypted DEK with the latest version of the Transit
+// key inside Vault. RewrapDEK implements encryption.KeyVersioner.
+func (p *VaultTransitProvider) RewrapDEK(ctx context.Context, encryptedDEK []byte) ([]byte, error) {
+	if _, err := vaultKeyVersion(string(encryptedDEK)); err != nil {
+		return nil, err
+	}
+	var response struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	request := map[string]string{"ciphertext": string(encryptedDEK)}
+	if err := p.transit(ctx, http.MethodPost, "rewrap", request, &response); err != nil {
+		return nil, fmt.Errorf("failed to rewrap DEK with vault transit: %w", err)
+	}
+	if version, err := vaultKeyVersion(response.Ciphertext); err == nil {
+		p.noteVersion(version)
+	}
+	return []byte(response.Ciphertext), nil
+}
+
+// noteVersion raises the cached latest version to one seen in a response
+func (p *VaultTransitProvider) noteVersion(version int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if version > p.latestVersion {
+		p.latestVersion = version
+	}
+}
+
+// vaultKeyVersion parses the version of a "vault:v<version>:..." ciphertext
+func vaultKeyVersion(ciphertext string) (int, error) {
+	rest, ok := strings.CutPrefix(ciphertext, vaultCiphertextPrefix)
+	if !ok {
+		return 0, fmt.Errorf("encrypted DEK is not a vault transit ciphertext")
+	}
+	versionField, _, ok := strings.Cut(rest, ":")
+	version, err := strconv.Atoi(versionField)
+	if !ok || err != nil || version < 1 {
+		return 0, fmt.Errorf("encrypted DEK has a malformed vault transit key version")
+	}
+	return version, nil
+}
+
+func (p *VaultTransitProvider) transitPath(operation string) string {
+	return "/v1/" + p.config.MountPath + "/" + operation + "/" + url.PathEscape(p.config.KeyName)
+}
+
+// transit calls a Transit endpoint of the key and decodes its data into out
+func (p *VaultTransitProvider) transit(ctx context.Context, method, operation string, request, out interface{}) error {
+	return p.do(ctx, method, p.transitPath(operation), request, out, true)
+}
+
+// do sends an authenticated request. A login based method logs in again and
+// retries once if Vault rejects the token, e.g. after it was revoked.
+func (p *VaultTransitProvider) do(ctx context.Context, method, path string, request, out interface{}, retry bool) error {
+	token, err := p.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	err = p.send(ctx, method, path, token, request, out, nil)
+	var vaultErr *VaultError
+	if retry && p.config.AuthMethod != VaultAuthToken && errors.As(err, &vaultErr) && vaultErr.StatusCode == http.StatusForbidden {
+		p.mutex.Lock()
+		if p.token == token {
+			p.token = ""
+		}
+		p.mutex.Unlock()
+		return p.do(ctx, method, path, request, out, false)
+	}
+	return err
+}
+
+// vaultAuth is the auth block of a login or token response
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// currentToken returns a token that is valid for a while, logging in or
+// renewing as needed
+func (p *VaultTransitProvider) currentToken(ctx context.Context) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.token != "" && (p.renewAt.IsZero() || now.Before(p.renewAt)) {
+		return p.token, nil
+	}
+
+	if p.token != "" && p.renewable {
+		var auth vaultAuth
+		if err := p.send(ctx, http.MethodPost, "/v1/auth/token/renew-self", p.token, map[string]string{}, nil, &auth); err == nil {
+			p.setToken(p.token, auth.LeaseDuration, auth.Renewable)
+			return p.token, nil
+		}
+	}
+	if p.token != "" && p.config.AuthMethod == VaultAuthToken {
+		// A static token cannot be replaced, use it for as long as it lasts
+		if now.Before(p.expiresAt) {
+			p.renewAt = now.Add(vaultTokenMinRenewalInterval)
+			return p.token, nil
+		}
+		return "", fmt.Errorf("vault token expired and could not be renewed")
+	}
+
+	if err := p.login(ctx); err != nil {
+		return "", err
+	}
+	return p.token, nil
+}
+
+// login obtains a token with the configured auth method. The caller must
+// hold p.mutex.
+func (p *VaultTransitProvider) login(ctx context.Context) error {
+	var auth vaultAuth
+	switch p.config.AuthMethod {
+	case VaultAuthToken:
+		var data struct {
+			TTL       int  `json:"ttl"`
+			Renewable bool `json:"renewable"`
+		}
+		if err := p.send(ctx, http.MethodGet, "/v1/auth/token/lookup-self", p.config.Token, nil, &data, nil); err != nil {
+			return fmt.Errorf("failed to look up vault token: %w", err)
+		}
+		auth = vaultAuth{ClientToken: p.config.Token, LeaseDuration: data.TTL, Renewable: data.Renewable}
+	case VaultAuthAppRole:
+		request := map[string]string{"role_id": p.config.RoleID, "secret_id": p.config.SecretID}
+		if err := p.send(ctx, http.MethodPost, "/v1/auth/"+p.config.AuthMount+"/login", "", request, nil, &auth); err != nil {
+			return fmt.Errorf("vault approle login failed: %w", err)
+		}
+	case VaultAuthKubernetes:
+		jwt, err := os.ReadFile(p.config.KubernetesTokenPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kubernetes service account token: %w", err)
+		}
+		request := map[string]string{"role": p.config.KubernetesRole, "jwt": strings.TrimSpace(string(jwt))}
+		if err := p.send(ctx, http.MethodPost, "/v1/auth/"+p.config.AuthMount+"/login", "", request, nil, &auth); err != nil {
+			return fmt.Errorf("vault kubernetes login failed: %w", err)
+		}
+	}
+	if auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", p.config.AuthMethod)
+	}
+	p.setToken(auth.ClientToken, auth.LeaseDuration, auth.Renewable)
+	return nil
+}
+
+// setToken records a token and when to renew it. The caller must hold p.mutex.
+func (p *VaultTransitProvider) setToken(token string, leaseSeconds int, renewable bool) {
+	p.token = token
+	p.renewable = renewable
+	if leaseSeconds <= 0 {
+		// Root and other periodic-less tokens without a TTL never expire
+		p.renewAt, p.expiresAt = time.Time{}, time.Time{}
+		return
+	}
+	now := time.Now()
+	lease := time.Duration(leaseSeconds) * time.Second
+	renewIn := time.Duration(float64(lease) * vaultTokenRenewalFraction)
+	if renewIn < vaultTokenMinRenewalInterval {
+		renewIn = vaultTokenMinRenewalInterval
+	}
+	p.renewAt = now.Add(renewIn)
+	p.expiresAt = now.Add(lease)
+}
+
+// send makes one request to the Vault API and decodes the data and auth
+// blocks of the response into data and auth, which may be nil
+func (p *VaultTransitProvider) send(ctx context.Context, method, path, token string, request, data interface{}, auth *vaultAuth) error {
+	var body io.Reader
+	if request != nil {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("failed to encode vault request: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.config.Address+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only response body
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Auth   *vaultAuth      `json:"auth"`
+		Errors []string        `json:"errors"`
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, vaultMaxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &envelope); err != nil && resp.StatusCode < 300 {
+			return fmt.Errorf("failed to decode vault response: %w", err)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		return &VaultError{StatusCode: resp.StatusCode, Errors: envelope.Errors}
+	}
+
+	if data != nil {
+		if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+			return fmt.Errorf("vault response has no data")
+		}
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			return fmt.Errorf("failed to decode vault response data: %w", err)
+		}
+	}
+	if auth != nil {
+		if envelope.Auth == nil {
+			return fmt.Errorf("vault response has no auth")
+		}
+		*auth = *envelope.Auth
+	}
+	return nil
+}
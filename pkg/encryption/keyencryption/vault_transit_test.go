@@ -0,0 +1,231 @@
+package keyencryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/guided-traffic/s3-encryption-proxy/pkg/encryption"
+)
+
+// fakeVault serves the Transit and auth endpoints the provider uses. Its
+// "ciphertext" is the plaintext XORed with the key version.
+type fakeVault struct {
+	mutex         sync.Mutex
+	latestVersion int
+	tokens        map[string]bool
+	logins        int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+	vault := &fakeVault{latestVersion: 1, tokens: map[string]bool{"root-token": true}}
+	server := httptest.NewServer(http.HandlerFunc(vault.serveHTTP))
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func (v *fakeVault) seal(plaintext []byte, version int) string {
+	sealed := make([]byte, len(plaintext))
+	for i, b := range plaintext {
+		sealed[i] = b ^ byte(version)
+	}
+	return fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(sealed))
+}
+
+func (v *fakeVault) open(ciphertext string) []byte {
+	version, err := vaultKeyVersion(ciphertext)
+	if err != nil {
+		return nil
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(ciphertext[strings.LastIndex(ciphertext, ":")+1:])
+	for i := range sealed {
+		sealed[i] ^= byte(version)
+	}
+	return sealed
+}
+
+func (v *fakeVault) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var request map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&request)
+	reply := func(status int, body interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		if request["role_id"] != "role" || request["secret_id"] != "secret" {
+			reply(http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		v.logins++
+		token := fmt.Sprintf("approle-token-%d", v.logins)
+		v.tokens[token] = true
+		reply(http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{"client_token": token, "lease_duration": 3600, "renewable": true}})
+		return
+	}
+	if !v.tokens[r.Header.Get("X-Vault-Token")] {
+		reply(http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/auth/token/lookup-self":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"ttl": 0, "renewable": false}})
+	case "POST /v1/transit/encrypt/dek-key":
+		plaintext, _ := base64.StdEncoding.DecodeString(request["plaintext"])
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"ciphertext": v.seal(plaintext, v.latestVersion)}})
+	case "POST /v1/transit/decrypt/dek-key":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(v.open(request["ciphertext"]))}})
+	case "POST /v1/transit/rewrap/dek-key":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"ciphertext": v.seal(v.open(request["ciphertext"]), v.latestVersion)}})
+	case "GET /v1/transit/keys/dek-key":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]int{"latest_version": v.latestVersion}})
+	case "POST /v1/transit/keys/dek-key/rotate":
+		v.latestVersion++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func TestVaultTransitProvider_EncryptDecrypt(t *testing.T) {
+	_, server := newFakeVault(t)
+	provider, err := NewVaultTransitProvider(VaultTransitConfig{Address: server.URL + "/", KeyName: "dek-key", Token: "root-token"})
+	require.NoError(t, err)
+	assert.Equal(t, "vault-transit", provider.Name())
+	var _ encryption.KeyVersioner = provider
+
+	ctx := context.Background()
+	dek := bytes.Repeat([]byte{7}, 32)
+	encryptedDEK, keyID, err := provider.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	assert.Equal(t, provider.Fingerprint(), keyID)
+	assert.True(t, strings.HasPrefix(string(encryptedDEK), "vault:v1:"))
+
+	decrypted, err := provider.DecryptDEK(ctx, encryptedDEK, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+
+	_, err = provider.DecryptDEK(ctx, encryptedDEK, "other-fingerprint")
+	assert.ErrorContains(t, err, "key ID mismatch")
+	_, err = provider.DecryptDEK(ctx, []byte("not-a-vault-ciphertext"), keyID)
+	assert.ErrorContains(t, err, "not a vault transit ciphertext")
+
+	// The fingerprint names the key, not the Vault address
+	other, err := NewVaultTransitProvider(VaultTransitConfig{Address: "https://vault.example.com", KeyName: "dek-key", Token: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, provider.Fingerprint(), other.Fingerprint())
+}
+
+func TestVaultTransitProvider_RotateAndRewrap(t *testing.T) {
+	_, server := newFakeVault(t)
+	provider, err := NewVaultTransitProvider(VaultTransitConfig{Address: server.URL, KeyName: "dek-key", Token: "root-token"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, provider.Preload(ctx))
+
+	dek := bytes.Repeat([]byte{5}, 32)
+	encryptedDEK, _, err := provider.EncryptDEK(ctx, dek)
+	require.NoError(t, err)
+	version, err := provider.KeyVersion(encryptedDEK)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	require.NoError(t, provider.RotateKEK(ctx))
+	latest, err := provider.LatestKeyVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest)
+
+	// The old version stays readable until the DEK is re-wrapped
+	decrypted, err := provider.DecryptDEK(ctx, encryptedDEK, "")
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+
+	rewrapped, err := provider.RewrapDEK(ctx, encryptedDEK)
+	require.NoError(t, err)
+	version, err = provider.KeyVersion(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	decrypted, err = provider.DecryptDEK(ctx, rewrapped, "")
+	require.NoError(t, err)
+	assert.Equal(t, dek, decrypted)
+}
+
+func TestVaultTransitProvider_AppRoleLogsInAgain(t *testing.T) {
+	vault, server := newFakeVault(t)
+	provider, err := NewVaultTransitProvider(VaultTransitConfig{
+		Address:    server.URL,
+		KeyName:    "dek-key",
+		AuthMethod: VaultAuthAppRole,
+		RoleID:     "role",
+		SecretID:   "secret",
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, _, err = provider.EncryptDEK(ctx, []byte("dek"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, vault.logins)
+
+	// A revoked token is replaced by a new login
+	vault.mutex.Lock()
+	vault.tokens = map[string]bool{}
+	vault.mutex.Unlock()
+	_, _, err = provider.EncryptDEK(ctx, []byte("dek"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, vault.logins)
+
+	bad, err := NewVaultTransitProvider(VaultTransitConfig{
+		Address:    server.URL,
+		KeyName:    "dek-key",
+		AuthMethod: VaultAuthAppRole,
+		RoleID:     "role",
+		SecretID:   "wrong",
+	})
+	require.NoError(t, err)
+	_, _, err = bad.EncryptDEK(ctx, []byte("dek"))
+	var vaultErr *VaultError
+	require.ErrorAs(t, err, &vaultErr)
+	assert.Equal(t, http.StatusBadRequest, vaultErr.HTTPStatusCode())
+}
+
+func TestVaultTransitConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  VaultTransitConfig
+		wantErr string
+	}{
+		{name: "token", config: VaultTransitConfig{Address: "https://vault:8200", KeyName: "k", Token: "t"}},
+		{name: "kubernetes", config: VaultTransitConfig{Address: "https://vault:8200", KeyName: "k", AuthMethod: VaultAuthKubernetes, KubernetesRole: "proxy"}},
+		{name: "missing address", config: VaultTransitConfig{KeyName: "k", Token: "t"}, wantErr: "address is required"},
+		{name: "relative address", config: VaultTransitConfig{Address: "vault:8200", KeyName: "k", Token: "t"}, wantErr: "absolute URL"},
+		{name: "missing key", config: VaultTransitConfig{Address: "https://vault:8200", Token: "t"}, wantErr: "key_name is required"},
+		{name: "missing token", config: VaultTransitConfig{Address: "https://vault:8200", KeyName: "k"}, wantErr: "token is required"},
+		{name: "missing secret ID", config: VaultTransitConfig{Address: "https://vault:8200", KeyName: "k", AuthMethod: VaultAuthAppRole, RoleID: "r"}, wantErr: "secret_id"},
+		{name: "unknown method", config: VaultTransitConfig{Address: "https://vault:8200", KeyName: "k", AuthMethod: "ldap"}, wantErr: "unsupported auth_method"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Command matrix turns the JUnit XML report of a ceph/s3-tests run against the
+// proxy into a pass/fail matrix (JSON + Markdown) and compares it with the list
+// of known failures, so S3 compatibility regressions fail the build.
+//
+// Usage:
+//
+//	go run ./test/s3-tests/matrix -junit report.xml -known known-failures.txt -out artifacts/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Status is the outcome of a single s3-tests case
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusError   Status = "error"
+	StatusSkipped Status = "skipped"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Cases []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string    `xml:"classname,attr"`
+	Name      string    `xml:"name,attr"`
+	Failure   *struct{} `xml:"failure"`
+	Error     *struct{} `xml:"error"`
+	Skipped   *struct{} `xml:"skipped"`
+}
+
+// Result is the outcome of one test case
+type Result struct {
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Status Status `json:"status"`
+}
+
+// GroupSummary counts outcomes for one feature group (e.g. "multipart")
+type GroupSummary struct {
+	Group   string `json:"group"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+// Matrix is the complete compatibility report
+type Matrix struct {
+	Total       int            `json:"total"`
+	Passed      int            `json:"passed"`
+	Failed      int            `json:"failed"`
+	Skipped     int            `json:"skipped"`
+	Groups      []GroupSummary `json:"groups"`
+	Regressions []string       `json:"regressions"` // failing, but not listed as known failures
+	Fixed       []string       `json:"fixed"`       // listed as known failures, but passing now
+	Results     []Result       `json:"results"`
+}
+
+func main() {
+	junitPath := flag.String("junit", "", "path to the pytest JUnit XML report")
+	knownPath := flag.String("known", "", "path to the known failures list (one test name per line)")
+	outDir := flag.String("out", ".", "directory for matrix.json and matrix.md")
+	flag.Parse()
+
+	if *junitPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -junit is required")
+		os.Exit(2)
+	}
+
+	results, err := parseJUnitFile(*junitPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	known := map[string]bool{}
+	if *knownPath != "" {
+		if known, err = loadKnownFailures(*knownPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	matrix := buildMatrix(results, known)
+	if err := writeArtifacts(matrix, *outDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("s3-tests: %d passed, %d failed, %d skipped (%d total)\n", matrix.Passed, matrix.Failed, matrix.Skipped, matrix.Total)
+	for _, name := range matrix.Fixed {
+		fmt.Printf("  fixed (remove from known failures): %s\n", name)
+	}
+	if len(matrix.Regressions) > 0 {
+		for _, name := range matrix.Regressions {
+			fmt.Printf("  REGRESSION: %s\n", name)
+		}
+		os.Exit(1)
+	}
+}
+
+func parseJUnitFile(path string) ([]Result, error) {
+	f, err := os.Open(path) // #nosec G304 - path is provided by the operator running the suite
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JUnit report: %w", err)
+	}
+	defer f.Close()
+	return parseJUnit(f)
+}
+
+// parseJUnit accepts both a <testsuites> root and a bare <testsuite> root
+func parseJUnit(r io.Reader) ([]Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JUnit report: %w", err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		var suite junitSuite
+		if err2 := xml.Unmarshal(data, &suite); err2 != nil {
+			return nil, fmt.Errorf("failed to parse JUnit report: %w", err)
+		}
+		suites.Suites = []junitSuite{suite}
+	}
+
+	var results []Result
+	for _, suite := range suites.Suites {
+		for _, tc := range suite.Cases {
+			status := StatusPassed
+			switch {
+			case tc.Failure != nil:
+				status = StatusFailed
+			case tc.Error != nil:
+				status = StatusError
+			case tc.Skipped != nil:
+				status = StatusSkipped
+			}
+			results = append(results, Result{Name: tc.Name, Group: groupOf(tc.Name), Status: status})
+		}
+	}
+	return results, nil
+}
+
+// groupOf derives the feature group from the test name: test_multipart_upload_small -> multipart
+func groupOf(name string) string {
+	name = strings.TrimPrefix(name, "test_")
+	if i := strings.IndexAny(name, "_["); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func loadKnownFailures(path string) (map[string]bool, error) {
+	f, err := os.Open(path) // #nosec G304 - path is provided by the operator running the suite
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known failures: %w", err)
+	}
+	defer f.Close()
+
+	known := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		known[line] = true
+	}
+	return known, scanner.Err()
+}
+
+func buildMatrix(results []Result, known map[string]bool) Matrix {
+	m := Matrix{Total: len(results), Results: results, Regressions: []string{}, Fixed: []string{}}
+	groups := map[string]*GroupSummary{}
+
+	for _, res := range results {
+		g, ok := groups[res.Group]
+		if !ok {
+			g = &GroupSummary{Group: res.Group}
+			groups[res.Group] = g
+		}
+		switch res.Status {
+		case StatusPassed:
+			m.Passed++
+			g.Passed++
+			if known[res.Name] {
+				m.Fixed = append(m.Fixed, res.Name)
+			}
+		case StatusSkipped:
+			m.Skipped++
+			g.Skipped++
+		default:
+			m.Failed++
+			g.Failed++
+			if !known[res.Name] {
+				m.Regressions = append(m.Regressions, res.Name)
+			}
+		}
+	}
+
+	for _, g := range groups {
+		m.Groups = append(m.Groups, *g)
+	}
+	sort.Slice(m.Groups, func(i, j int) bool { return m.Groups[i].Group < m.Groups[j].Group })
+	sort.Slice(m.Results, func(i, j int) bool { return m.Results[i].Name < m.Results[j].Name })
+	sort.Strings(m.Regressions)
+	sort.Strings(m.Fixed)
+	return m
+}
+
+func writeArtifacts(m Matrix, dir string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode matrix: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "matrix.json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write matrix.json: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "matrix.md"), []byte(renderMarkdown(m)), 0o600); err != nil {
+		return fmt.Errorf("failed to write matrix.md: %w", err)
+	}
+	return nil
+}
+
+func renderMarkdown(m Matrix) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# S3 compatibility matrix (ceph/s3-tests)\n\n")
+	fmt.Fprintf(&b, "**%d passed**, %d failed, %d skipped of %d tests\n\n", m.Passed, m.Failed, m.Skipped, m.Total)
+
+	b.WriteString("| Group | Passed | Failed | Skipped |\n|---|---:|---:|---:|\n")
+	for _, g := range m.Groups {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d |\n", g.Group, g.Passed, g.Failed, g.Skipped)
+	}
+
+	if len(m.Regressions) > 0 {
+		b.WriteString("\n## Regressions\n\n")
+		for _, name := range m.Regressions {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+	}
+	if len(m.Fixed) > 0 {
+		b.WriteString("\n## Newly passing (remove from known failures)\n\n")
+		for _, name := range m.Fixed {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleReport = `<?xml version="1.0" encoding="utf-8"?>
+<testsuites>
+  <testsuite name="pytest" tests="5">
+    <testcase classname="s3tests_boto3.functional.test_s3" name="test_bucket_list_empty" time="0.1"/>
+    <testcase classname="s3tests_boto3.functional.test_s3" name="test_multipart_upload_small" time="0.3">
+      <failure message="AssertionError">trace</failure>
+    </testcase>
+    <testcase classname="s3tests_boto3.functional.test_s3" name="test_multipart_copy_small" time="0.2">
+      <error message="ClientError">trace</error>
+    </testcase>
+    <testcase classname="s3tests_boto3.functional.test_s3" name="test_object_lock_put" time="0.0">
+      <skipped message="not supported"/>
+    </testcase>
+    <testcase classname="s3tests_boto3.functional.test_s3" name="test_object_copy_zero_size" time="0.1"/>
+  </testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	results, err := parseJUnit(strings.NewReader(sampleReport))
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, Result{Name: "test_bucket_list_empty", Group: "bucket", Status: StatusPassed}, results[0])
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.Equal(t, StatusError, results[2].Status)
+	assert.Equal(t, StatusSkipped, results[3].Status)
+	assert.Equal(t, "multipart", results[1].Group)
+}
+
+func TestParseJUnit_SingleSuiteRoot(t *testing.T) {
+	report := `<testsuite><testcase name="test_bucket_create_naming_good_long_60"/></testsuite>`
+	results, err := parseJUnit(strings.NewReader(report))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusPassed, results[0].Status)
+}
+
+func TestBuildMatrix_RegressionsAndFixed(t *testing.T) {
+	results, err := parseJUnit(strings.NewReader(sampleReport))
+	require.NoError(t, err)
+
+	known := map[string]bool{
+		"test_multipart_copy_small":  true, // still failing
+		"test_object_copy_zero_size": true, // passes now
+	}
+	m := buildMatrix(results, known)
+
+	assert.Equal(t, 5, m.Total)
+	assert.Equal(t, 2, m.Passed)
+	assert.Equal(t, 2, m.Failed)
+	assert.Equal(t, 1, m.Skipped)
+	assert.Equal(t, []string{"test_multipart_upload_small"}, m.Regressions)
+	assert.Equal(t, []string{"test_object_copy_zero_size"}, m.Fixed)
+
+	require.Len(t, m.Groups, 3)
+	assert.Equal(t, GroupSummary{Group: "multipart", Failed: 2}, m.Groups[1])
+}
+
+func TestWriteArtifacts(t *testing.T) {
+	results, err := parseJUnit(strings.NewReader(sampleReport))
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	require.NoError(t, writeArtifacts(buildMatrix(results, nil), dir))
+
+	md, err := os.ReadFile(filepath.Join(dir, "matrix.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "| multipart | 0 | 2 | 0 |")
+	assert.Contains(t, string(md), "## Regressions")
+	assert.FileExists(t, filepath.Join(dir, "matrix.json"))
+}
+
+func TestLoadKnownFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\n\ntest_a\n  test_b  \n"), 0o600))
+
+	known, err := loadKnownFailures(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"test_a": true, "test_b": true}, known)
+}
@@ -0,0 +1,196 @@
+#!/bin/bash
+
+# S3 Encryption Proxy - ceph/s3-tests compatibility runner
+#
+# Starts a throwaway MinIO backend (docker) and a locally built proxy, runs the
+# ceph/s3-tests functional suite against the proxy and renders a pass/fail
+# matrix. Exits non-zero if a test fails that is not listed in
+# known-failures-<provider>.txt for the selected provider.
+#
+# Environment:
+#   S3TESTS_PROVIDER   none | aes           (default: none)
+#   S3TESTS_REF        s3-tests git ref     (default: master)
+#   S3TESTS_FILTER     pytest -k expression (default: empty = whole suite)
+#   S3TESTS_MARKERS    pytest -m expression (default: excludes AWS-/RGW-only features)
+#   S3TESTS_WORKDIR    scratch directory    (default: build/s3-tests)
+#   PROXY_PORT         proxy listen port    (default: 18080)
+#   MINIO_PORT         MinIO host port      (default: 19000)
+
+set -euo pipefail
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+REPO_ROOT="$(cd "${SCRIPT_DIR}/../.." && pwd)"
+
+PROVIDER="${S3TESTS_PROVIDER:-none}"
+S3TESTS_REPO="${S3TESTS_REPO:-https://github.com/ceph/s3-tests.git}"
+S3TESTS_REF="${S3TESTS_REF:-master}"
+S3TESTS_FILTER="${S3TESTS_FILTER:-}"
+S3TESTS_MARKERS="${S3TESTS_MARKERS:-not fails_on_aws and not fails_on_rgw and not lifecycle_expiration and not cloud_transition and not sse_s3 and not encryption and not iam_account and not iam_user and not iam_role and not iam_tenant and not sts_test and not webidentity_test and not auth_aws2 and not s3select and not bucket_logging and not checksum}"
+WORKDIR="${S3TESTS_WORKDIR:-${REPO_ROOT}/build/s3-tests}"
+PROXY_PORT="${PROXY_PORT:-18080}"
+MINIO_PORT="${MINIO_PORT:-19000}"
+MINIO_CONTAINER="s3ep-s3tests-minio"
+ARTIFACT_DIR="${WORKDIR}/artifacts/${PROVIDER}"
+
+# Client credentials the suite uses against the proxy (main + alt user)
+MAIN_ACCESS_KEY="s3testsmain"
+MAIN_SECRET_KEY="s3tests-main-secret-key"
+ALT_ACCESS_KEY="s3testsalt"
+ALT_SECRET_KEY="s3tests-alt-secret-key"
+
+log_info() { echo "ℹ️  $1"; }
+log_error() { echo "❌ $1" >&2; }
+
+PROXY_PID=""
+cleanup() {
+    if [ -n "${PROXY_PID}" ]; then
+        kill "${PROXY_PID}" 2>/dev/null || true
+        wait "${PROXY_PID}" 2>/dev/null || true
+    fi
+    docker rm -f "${MINIO_CONTAINER}" >/dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+for cmd in docker git python3 curl go; do
+    if ! command -v "${cmd}" &>/dev/null; then
+        log_error "${cmd} is required but not installed"
+        exit 2
+    fi
+done
+
+mkdir -p "${WORKDIR}" "${ARTIFACT_DIR}"
+
+# 1. Backend
+log_info "Starting MinIO on port ${MINIO_PORT}..."
+docker rm -f "${MINIO_CONTAINER}" >/dev/null 2>&1 || true
+docker run -d --name "${MINIO_CONTAINER}" -p "${MINIO_PORT}:9000" \
+    -e MINIO_ROOT_USER=minioadmin -e MINIO_ROOT_PASSWORD=minioadmin123 \
+    minio/minio:latest server /data >/dev/null
+for _ in $(seq 1 30); do
+    curl -sf "http://localhost:${MINIO_PORT}/minio/health/live" >/dev/null && break
+    sleep 1
+done
+
+# 2. Proxy
+log_info "Building proxy..."
+(cd "${REPO_ROOT}" && GOFLAGS="-buildvcs=false" go build -o "${WORKDIR}/s3-encryption-proxy" ./cmd/s3-encryption-proxy)
+
+case "${PROVIDER}" in
+    none)
+        PROVIDER_BLOCK='    - alias: "default"
+      type: "none"'
+        ;;
+    aes)
+        PROVIDER_BLOCK="    - alias: \"default\"
+      type: \"aes\"
+      config:
+        aes_key: \"$(openssl rand -base64 32)\""
+        ;;
+    *)
+        log_error "Unsupported S3TESTS_PROVIDER '${PROVIDER}' (use none or aes)"
+        exit 2
+        ;;
+esac
+
+cat > "${WORKDIR}/proxy-config.yaml" <<EOF
+bind_address: "127.0.0.1:${PROXY_PORT}"
+log_level: "warn"
+s3_backend:
+  target_endpoint: "http://localhost:${MINIO_PORT}"
+  region: "us-east-1"
+  access_key_id: "minioadmin"
+  secret_key: "minioadmin123"
+  use_tls: false
+s3_clients:
+  - type: "static"
+    access_key_id: "${MAIN_ACCESS_KEY}"
+    secret_key: "${MAIN_SECRET_KEY}"
+  - type: "static"
+    access_key_id: "${ALT_ACCESS_KEY}"
+    secret_key: "${ALT_SECRET_KEY}"
+s3_security:
+  enable_rate_limiting: false
+encryption:
+  integrity_verification: "strict"
+  encryption_method_alias: "default"
+  providers:
+${PROVIDER_BLOCK}
+EOF
+
+log_info "Starting proxy on port ${PROXY_PORT} (provider: ${PROVIDER})..."
+"${WORKDIR}/s3-encryption-proxy" --config "${WORKDIR}/proxy-config.yaml" > "${ARTIFACT_DIR}/proxy.log" 2>&1 &
+PROXY_PID=$!
+for _ in $(seq 1 30); do
+    curl -sf "http://localhost:${PROXY_PORT}/health" >/dev/null && break
+    sleep 1
+done
+if ! curl -sf "http://localhost:${PROXY_PORT}/health" >/dev/null; then
+    log_error "Proxy did not become healthy, see ${ARTIFACT_DIR}/proxy.log"
+    exit 2
+fi
+
+# 3. Suite
+if [ ! -d "${WORKDIR}/s3-tests/.git" ]; then
+    log_info "Cloning ${S3TESTS_REPO}..."
+    git clone --quiet "${S3TESTS_REPO}" "${WORKDIR}/s3-tests"
+fi
+(cd "${WORKDIR}/s3-tests" && git fetch --quiet origin && git checkout --quiet "${S3TESTS_REF}")
+
+if [ ! -d "${WORKDIR}/venv" ]; then
+    python3 -m venv "${WORKDIR}/venv"
+fi
+"${WORKDIR}/venv/bin/pip" install --quiet -r "${WORKDIR}/s3-tests/requirements.txt" pytest
+
+cat > "${WORKDIR}/s3tests.conf" <<EOF
+[DEFAULT]
+host = localhost
+port = ${PROXY_PORT}
+is_secure = False
+ssl_verify = False
+
+[fixtures]
+bucket prefix = s3ep-{random}-
+
+[s3 main]
+display_name = s3ep main
+user_id = s3ep-main
+email = main@example.com
+api_name = default
+access_key = ${MAIN_ACCESS_KEY}
+secret_key = ${MAIN_SECRET_KEY}
+
+[s3 alt]
+display_name = s3ep alt
+user_id = s3ep-alt
+email = alt@example.com
+access_key = ${ALT_ACCESS_KEY}
+secret_key = ${ALT_SECRET_KEY}
+
+[s3 tenant]
+display_name = s3ep tenant
+user_id = s3ep-tenant
+email = tenant@example.com
+access_key = ${ALT_ACCESS_KEY}
+secret_key = ${ALT_SECRET_KEY}
+tenant = testx
+
+[iam]
+email = iam@example.com
+user_id = s3ep-iam
+access_key = ${MAIN_ACCESS_KEY}
+secret_key = ${MAIN_SECRET_KEY}
+display_name = s3ep iam
+EOF
+
+log_info "Running s3-tests..."
+PYTEST_ARGS=(s3tests_boto3/functional/test_s3.py -m "${S3TESTS_MARKERS}" --junitxml="${ARTIFACT_DIR}/junit.xml" -q -p no:cacheprovider)
+if [ -n "${S3TESTS_FILTER}" ]; then
+    PYTEST_ARGS+=(-k "${S3TESTS_FILTER}")
+fi
+(cd "${WORKDIR}/s3-tests" && S3TEST_CONF="${WORKDIR}/s3tests.conf" "${WORKDIR}/venv/bin/pytest" "${PYTEST_ARGS[@]}") || true
+
+# 4. Matrix
+(cd "${REPO_ROOT}" && go run ./test/s3-tests/matrix \
+    -junit "${ARTIFACT_DIR}/junit.xml" \
+    -known "${SCRIPT_DIR}/known-failures-${PROVIDER}.txt" \
+    -out "${ARTIFACT_DIR}")